@@ -24,12 +24,13 @@ func agentCmd() {
 	message := ""
 	sessionKey := "cli:default"
 	modelOverride := ""
+	debug := false
 
 	args := os.Args[2:]
 	for i := 0; i < len(args); i++ {
 		switch args[i] {
 		case "--debug", "-d":
-			logger.SetLevel(logger.DEBUG)
+			debug = true
 			fmt.Println("🔍 Debug mode enabled")
 		case "-m", "--message":
 			if i+1 < len(args) {
@@ -54,6 +55,10 @@ func agentCmd() {
 		fmt.Printf("Error loading config: %v\n", err)
 		os.Exit(1)
 	}
+	configureLogging(cfg, debug)
+	configureNetwork(cfg)
+	configureMemory(cfg)
+	configureTokenizer(cfg)
 
 	if modelOverride != "" {
 		cfg.Agents.Defaults.Model = modelOverride
@@ -71,6 +76,8 @@ func agentCmd() {
 
 	msgBus := bus.NewMessageBus()
 	agentLoop := agent.NewAgentLoop(cfg, msgBus, provider)
+	closeMCP := connectMCPServers(context.Background(), cfg, agentLoop)
+	defer closeMCP()
 
 	// Print agent startup info (only for interactive mode)
 	startupInfo := agentLoop.GetStartupInfo()
@@ -14,6 +14,7 @@ import (
 
 	"github.com/sipeed/picoclaw/pkg/auth"
 	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/httpclient"
 	"github.com/sipeed/picoclaw/pkg/providers"
 )
 
@@ -237,7 +238,7 @@ func fetchGoogleUserEmail(accessToken string) (string, error) {
 	}
 	req.Header.Set("Authorization", "Bearer "+accessToken)
 
-	client := &http.Client{Timeout: 10 * time.Second}
+	client := httpclient.New(10 * time.Second)
 	resp, err := client.Do(req)
 	if err != nil {
 		return "", err
@@ -0,0 +1,107 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bench"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+func benchCmd() {
+	opts := bench.DefaultOptions()
+	asJSON := false
+
+	args := os.Args[2:]
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--requests", "-n":
+			opts.Requests = benchIntFlag(args, &i)
+		case "--concurrency", "-c":
+			opts.Concurrency = benchIntFlag(args, &i)
+		case "--latency-ms":
+			opts.Latency = time.Duration(benchIntFlag(args, &i)) * time.Millisecond
+		case "--upload-kb":
+			opts.UploadBytes = benchIntFlag(args, &i) << 10
+		case "--json":
+			asJSON = true
+		case "--help", "-h":
+			benchHelp()
+			return
+		default:
+			fmt.Printf("Unknown flag: %s\n", args[i])
+			benchHelp()
+			os.Exit(1)
+		}
+	}
+
+	// Keep per-request agent logs out of the report.
+	logger.SetLevel(logger.WARN)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	report, err := bench.Run(ctx, opts)
+	if err != nil {
+		fmt.Printf("Error running benchmark: %v\n", err)
+		os.Exit(1)
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+		return
+	}
+
+	fmt.Printf("%s picoclaw %s bench (%s, %s)\n\n", logo, formatVersion(), report.GoVersion, report.GOARCH)
+	fmt.Printf("%-11s %8s %7s %10s %10s %9s %12s %11s\n",
+		"scenario", "requests", "errors", "p50", "p95", "req/s", "allocs/req", "KB/req")
+	for _, r := range report.Results {
+		fmt.Printf("%-11s %8d %7d %10s %10s %9.1f %12d %11d\n",
+			r.Scenario, r.Requests, r.Errors,
+			r.P50.Round(time.Microsecond), r.P95.Round(time.Microsecond),
+			r.RequestsPerSec, r.AllocsPerReq, r.BytesPerReq>>10)
+	}
+	fmt.Println()
+	if report.PeakRSSKB > 0 {
+		fmt.Printf("Peak RSS: %.1f MB\n", float64(report.PeakRSSKB)/1024)
+	} else {
+		fmt.Println("Peak RSS: not reported on this OS")
+	}
+}
+
+// benchIntFlag returns the positive integer after args[*i] and advances i.
+func benchIntFlag(args []string, i *int) int {
+	if *i+1 >= len(args) {
+		fmt.Printf("Missing value for %s\n", args[*i])
+		os.Exit(1)
+	}
+	n, err := strconv.Atoi(args[*i+1])
+	if err != nil || n < 0 {
+		fmt.Printf("Invalid %s: %s\n", args[*i], args[*i+1])
+		os.Exit(1)
+	}
+	*i++
+	return n
+}
+
+func benchHelp() {
+	fmt.Println("\nBench options:")
+	fmt.Println("  -n, --requests <n>      Requests per scenario (default 50)")
+	fmt.Println("  -c, --concurrency <n>   Parallel clients in the concurrent scenario (default 4)")
+	fmt.Println("  --latency-ms <n>        Simulated provider latency (default 0)")
+	fmt.Println("  --upload-kb <n>         File size in the multipart scenario (default 1024)")
+	fmt.Println("  --json                  Print the report as JSON")
+	fmt.Println()
+	fmt.Println("Runs the webhook API in-process against a mock provider with a temporary")
+	fmt.Println("workspace; your config and workspace are not touched.")
+	fmt.Println("Example: picoclaw bench -n 200 -c 8 --json > bench-v1.2.json")
+}
@@ -33,7 +33,7 @@ func cronCmd() {
 	case "list":
 		cronListCmd(cronStorePath)
 	case "add":
-		cronAddCmd(cronStorePath)
+		cronAddCmd(cronStorePath, cfg.Locale.Timezone)
 	case "remove":
 		if len(os.Args) < 4 {
 			fmt.Println("Usage: picoclaw cron remove <job_id>")
@@ -63,6 +63,7 @@ func cronHelp() {
 	fmt.Println("  -m, --message    Message for agent")
 	fmt.Println("  -e, --every      Run every N seconds")
 	fmt.Println("  -c, --cron       Cron expression (e.g. '0 9 * * *')")
+	fmt.Println("  --tz             Timezone of --cron (default: locale.timezone)")
 	fmt.Println("  -d, --deliver     Deliver response to channel")
 	fmt.Println("  --to             Recipient for delivery")
 	fmt.Println("  --channel        Channel for delivery")
@@ -85,6 +86,9 @@ func cronListCmd(storePath string) {
 			schedule = fmt.Sprintf("every %ds", *job.Schedule.EveryMS/1000)
 		} else if job.Schedule.Kind == "cron" {
 			schedule = job.Schedule.Expr
+			if job.Schedule.TZ != "" {
+				schedule += " (" + job.Schedule.TZ + ")"
+			}
 		} else {
 			schedule = "one-time"
 		}
@@ -107,7 +111,7 @@ func cronListCmd(storePath string) {
 	}
 }
 
-func cronAddCmd(storePath string) {
+func cronAddCmd(storePath, tz string) {
 	name := ""
 	message := ""
 	var everySec *int64
@@ -141,6 +145,11 @@ func cronAddCmd(storePath string) {
 				cronExpr = args[i+1]
 				i++
 			}
+		case "--tz":
+			if i+1 < len(args) {
+				tz = args[i+1]
+				i++
+			}
 		case "-d", "--deliver":
 			deliver = true
 		case "--to":
@@ -182,6 +191,7 @@ func cronAddCmd(storePath string) {
 		schedule = cron.CronSchedule{
 			Kind: "cron",
 			Expr: cronExpr,
+			TZ:   tz,
 		}
 	}
 
@@ -0,0 +1,125 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/health"
+)
+
+// gatewayStatusCmd prints the running gateway's state, for picoclaw status.
+func gatewayStatusCmd() {
+	var status health.DaemonStatus
+	if err := controlRequest(http.MethodGet, "/status", &status); err != nil {
+		fmt.Println("Gateway: not running")
+		return
+	}
+
+	state := "ready"
+	if !status.Ready {
+		state = "not ready"
+	}
+	fmt.Printf("Gateway: running, %s (pid %d, up %s)\n", state, status.PID, status.Uptime)
+	if status.Version != "" && status.Version != formatVersion() {
+		fmt.Printf("  Version: %s (this CLI is %s)\n", status.Version, formatVersion())
+	}
+	if r := status.Release; r != nil && r.Available {
+		fmt.Printf("  Update available: %s (%s)\n", r.Latest, r.URL)
+	}
+	if status.ExternalAddress != "" {
+		fmt.Printf("  Remote address: %s\n", status.ExternalAddress)
+	}
+	fmt.Printf("  Paired devices: %d\n", status.PairedDevices)
+	fmt.Printf("  Agent runs: %d running, %d queued\n", status.Running, status.Queued)
+
+	names := make([]string, 0, len(status.Checks))
+	for name := range status.Checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		check := status.Checks[name]
+		line := fmt.Sprintf("  %s: %s", name, check.Status)
+		if check.Message != "" {
+			line += " (" + check.Message + ")"
+		}
+		if check.Flapping {
+			line += ", flapping"
+		}
+		fmt.Println(line)
+	}
+
+	if len(status.LastErrors) > 0 {
+		fmt.Println("  Last errors:")
+		for _, e := range status.LastErrors {
+			msg := e.Message
+			if err, ok := e.Fields["error"]; ok {
+				msg += fmt.Sprintf(": %v", err)
+			}
+			fmt.Printf("    %s [%s] %s\n", e.Time.Local().Format(time.DateTime), e.Component, msg)
+		}
+	}
+}
+
+func stopCmd() {
+	var status health.DaemonStatus
+	if err := controlRequest(http.MethodGet, "/status", &status); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := controlRequest(http.MethodPost, "/stop", &struct{}{}); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Stopping gateway (pid %d)...\n", status.PID)
+
+	// The control socket goes away once the gateway has drained.
+	deadline := time.Now().Add(time.Minute)
+	for time.Now().Before(deadline) {
+		time.Sleep(500 * time.Millisecond)
+		if controlRequest(http.MethodGet, "/status", &status) != nil {
+			fmt.Println("✓ Gateway stopped")
+			return
+		}
+	}
+	fmt.Println("Error: gateway still running after a minute")
+	os.Exit(1)
+}
+
+func restartCmd() {
+	var before health.DaemonStatus
+	if err := controlRequest(http.MethodGet, "/status", &before); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := restartGateway(before); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// restartGateway asks the gateway described by before to restart and waits
+// until it is back.
+func restartGateway(before health.DaemonStatus) error {
+	if err := controlRequest(http.MethodPost, "/restart", &struct{}{}); err != nil {
+		return err
+	}
+	fmt.Printf("Restarting gateway (pid %d)...\n", before.PID)
+
+	deadline := time.Now().Add(2 * time.Minute)
+	for time.Now().Before(deadline) {
+		time.Sleep(500 * time.Millisecond)
+		var after health.DaemonStatus
+		if controlRequest(http.MethodGet, "/status", &after) == nil && after.StartedAt.After(before.StartedAt) {
+			fmt.Printf("✓ Gateway restarted (pid %d, %s)\n", after.PID, after.Version)
+			return nil
+		}
+	}
+	return fmt.Errorf("gateway did not come back within two minutes; check its log")
+}
@@ -6,34 +6,72 @@ package main
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/sipeed/picoclaw/pkg/agent"
+	"github.com/sipeed/picoclaw/pkg/alerts"
+	"github.com/sipeed/picoclaw/pkg/artifact"
+	"github.com/sipeed/picoclaw/pkg/blob"
+	"github.com/sipeed/picoclaw/pkg/budget"
 	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/categorize"
 	"github.com/sipeed/picoclaw/pkg/channels"
 	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/crash"
 	"github.com/sipeed/picoclaw/pkg/cron"
 	"github.com/sipeed/picoclaw/pkg/devices"
+	"github.com/sipeed/picoclaw/pkg/documents"
+	"github.com/sipeed/picoclaw/pkg/eventlog"
+	"github.com/sipeed/picoclaw/pkg/federation"
+	"github.com/sipeed/picoclaw/pkg/hardware"
 	"github.com/sipeed/picoclaw/pkg/health"
 	"github.com/sipeed/picoclaw/pkg/heartbeat"
+	"github.com/sipeed/picoclaw/pkg/kafka"
+	"github.com/sipeed/picoclaw/pkg/leader"
+	"github.com/sipeed/picoclaw/pkg/ledgerforge"
+	"github.com/sipeed/picoclaw/pkg/locale"
 	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/mdns"
+	"github.com/sipeed/picoclaw/pkg/media"
+	"github.com/sipeed/picoclaw/pkg/nats"
+	"github.com/sipeed/picoclaw/pkg/pgstore"
+	"github.com/sipeed/picoclaw/pkg/portmap"
 	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/receipts"
+	"github.com/sipeed/picoclaw/pkg/reporting"
+	"github.com/sipeed/picoclaw/pkg/sdnotify"
+	"github.com/sipeed/picoclaw/pkg/session"
 	"github.com/sipeed/picoclaw/pkg/state"
+	"github.com/sipeed/picoclaw/pkg/subscriptions"
+	"github.com/sipeed/picoclaw/pkg/telemetry"
 	"github.com/sipeed/picoclaw/pkg/tools"
+	"github.com/sipeed/picoclaw/pkg/transcript"
+	"github.com/sipeed/picoclaw/pkg/tunnel"
+	"github.com/sipeed/picoclaw/pkg/update"
+	"github.com/sipeed/picoclaw/pkg/usage"
 	"github.com/sipeed/picoclaw/pkg/voice"
+	"github.com/sipeed/picoclaw/pkg/wirelog"
+	"github.com/sipeed/picoclaw/pkg/workflow"
+	"github.com/sipeed/picoclaw/pkg/workpool"
 )
 
-func gatewayCmd() {
+// gatewayCmd runs the gateway until it is stopped, and reports whether a
+// restart was requested through the control socket.
+func gatewayCmd() (restart bool) {
 	// Check for --debug flag
+	debug := false
 	args := os.Args[2:]
 	for _, arg := range args {
 		if arg == "--debug" || arg == "-d" {
-			logger.SetLevel(logger.DEBUG)
+			debug = true
 			fmt.Println("🔍 Debug mode enabled")
 			break
 		}
@@ -44,6 +82,41 @@ func gatewayCmd() {
 		fmt.Printf("Error loading config: %v\n", err)
 		os.Exit(1)
 	}
+	configureLogging(cfg, debug)
+	// A fresh device is set up from a browser.
+	if _, err := os.Stat(getConfigPath()); os.IsNotExist(err) {
+		return runSetupWizard(cfg)
+	}
+	configureNetwork(cfg)
+	configureMemory(cfg)
+	configureTokenizer(cfg)
+	if err := locale.Validate(cfg.Locale); err != nil {
+		fmt.Printf("Error in locale config: %v\n", err)
+		os.Exit(1)
+	}
+	if err := categorize.Validate(cfg.Receipts); err != nil {
+		fmt.Printf("Error in receipts config: %v\n", err)
+		os.Exit(1)
+	}
+
+	if cfg.ErrorReporting.Enabled && cfg.ErrorReporting.DSN != "" {
+		deviceID, err := state.DeviceID(cfg.WorkspacePath())
+		if err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+		if err := reporting.Init(reporting.Config{
+			DSN:         cfg.ErrorReporting.DSN,
+			Environment: cfg.ErrorReporting.Environment,
+			Release:     version,
+			DeviceID:    deviceID,
+		}); err != nil {
+			fmt.Printf("Error enabling error reporting: %v\n", err)
+		}
+	}
+	if err := crash.Init(cfg.WorkspacePath(), version); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+	defer crash.Recover("gateway")
 
 	provider, modelID, err := providers.CreateProvider(cfg)
 	if err != nil {
@@ -55,8 +128,22 @@ func gatewayCmd() {
 		cfg.Agents.Defaults.Model = modelID
 	}
 
+	if cfg.LLMCache.Enabled && cfg.LLMCache.TTLMinutes > 0 {
+		provider = providers.NewCachingProvider(provider,
+			time.Duration(cfg.LLMCache.TTLMinutes)*time.Minute, cfg.LLMCache.MaxEntries)
+	}
+
 	msgBus := bus.NewMessageBus()
 	agentLoop := agent.NewAgentLoop(cfg, msgBus, provider)
+	stateManager, pgDB := setupPostgres(cfg, agentLoop)
+	if stateManager == nil {
+		stateManager = state.NewManager(cfg.WorkspacePath())
+	}
+	var workPool *workpool.Pool
+	if cfg.Gateway.MaxConcurrentRequests > 0 {
+		workPool = workpool.New(cfg.Gateway.MaxConcurrentRequests, cfg.Gateway.MaxQueuedRequests)
+		agentLoop.SetWorkPool(workPool)
+	}
 
 	// Print agent startup info
 	fmt.Println("\n📦 Agent Status:")
@@ -93,65 +180,63 @@ func gatewayCmd() {
 		cfg.Heartbeat.Enabled,
 	)
 	heartbeatService.SetBus(msgBus)
+	if cfg.Storage.Postgres.DSN != "" {
+		heartbeatService.SetStateManager(stateManager)
+	}
 	heartbeatService.SetHandler(func(prompt, channel, chatID string) *tools.ToolResult {
-		// Get all active business auth entries
-		activeAuth := agentLoop.GetActiveAuth()
-
-		if len(activeAuth) == 0 {
-			// No user has sent a message yet — fall back to channel from state
-			if channel == "" || chatID == "" {
-				channel, chatID = "cli", "direct"
-			}
-			response, hbErr := agentLoop.ProcessHeartbeat(context.Background(), prompt, channel, chatID)
-			if hbErr != nil {
-				return tools.ErrorResult(fmt.Sprintf("Heartbeat error: %v", hbErr))
-			}
-			if response == "HEARTBEAT_OK" {
-				return tools.SilentResult("Heartbeat OK")
-			}
-			return tools.SilentResult(response)
+		// No user has sent a message yet — fall back to channel from state
+		if channel == "" || chatID == "" {
+			channel, chatID = "cli", "direct"
 		}
-
-		// Run heartbeat for each active business with their auth context
-		for businessID, entry := range activeAuth {
-			ctx := context.Background()
-			ctx = context.WithValue(ctx, constants.ContextKeyJWTToken, entry.JWTToken)
-			ctx = context.WithValue(ctx, constants.ContextKeyBusinessID, businessID)
-
-			// Use the business's last active channel for delivery
-			hbChannel := entry.Channel
-			hbChatID := entry.ChatID
-			if hbChannel == "" || hbChatID == "" {
-				hbChannel, hbChatID = channel, chatID
-			}
-			if hbChannel == "" || hbChatID == "" {
-				hbChannel, hbChatID = "cli", "direct"
-			}
-
-			response, hbErr := agentLoop.ProcessHeartbeat(ctx, prompt, hbChannel, hbChatID)
-			if hbErr != nil {
-				logger.WarnCF("heartbeat", "Heartbeat failed for business",
-					map[string]any{"business_id": businessID, "error": hbErr.Error()})
-				continue
-			}
-			if response != "HEARTBEAT_OK" {
-				logger.InfoCF("heartbeat", "Heartbeat result for business",
-					map[string]any{"business_id": businessID, "response_length": len(response)})
-			}
+		response, hbErr := agentLoop.ProcessHeartbeat(context.Background(), prompt, channel, chatID)
+		if hbErr != nil {
+			return tools.ErrorResult(fmt.Sprintf("Heartbeat error: %v", hbErr))
 		}
-
-		return tools.SilentResult("Heartbeat completed for all businesses")
+		if response == "HEARTBEAT_OK" {
+			return tools.SilentResult("Heartbeat OK")
+		}
+		return tools.SilentResult(response)
 	})
+	locales := locale.NewResolver(cfg.Locale)
+	heartbeatService.SetSchedules(heartbeatSchedules(cfg.Heartbeat.Businesses, locales))
+	heartbeatService.SetLocales(locales)
 
 	channelManager, err := channels.NewManager(cfg, msgBus)
 	if err != nil {
 		fmt.Printf("Error creating channel manager: %v\n", err)
 		os.Exit(1)
 	}
+	if cfg.Notifications.Enabled && cfg.Notifications.BatchSeconds > 0 {
+		fmt.Printf("✓ Batching notifications (every %ds)\n", cfg.Notifications.BatchSeconds)
+	}
 
 	// Inject channel manager into agent loop for command handling
 	agentLoop.SetChannelManager(channelManager)
 
+	reporter := heartbeatReporter(cfg, channelManager, stateManager)
+	// Run heartbeat for each active business with their auth context
+	heartbeatService.SetBusinessHandler(func(businessID, prompt string, auth state.AuthEntry) *tools.ToolResult {
+		ctx := context.Background()
+		ctx = context.WithValue(ctx, constants.ContextKeyJWTToken, auth.JWTToken)
+		ctx = context.WithValue(ctx, constants.ContextKeyBusinessID, businessID)
+
+		response, hbErr := agentLoop.ProcessHeartbeat(ctx, prompt, auth.Channel, auth.ChatID)
+		if hbErr != nil {
+			logger.WarnCF("heartbeat", "Heartbeat failed for business",
+				map[string]any{"business_id": businessID, "error": hbErr.Error()})
+			return tools.ErrorResult(hbErr.Error())
+		}
+		if response == "HEARTBEAT_OK" {
+			return tools.SilentResult("Heartbeat OK")
+		}
+		if err := reporter.Deliver(ctx, businessID, auth, response); err != nil {
+			logger.WarnCF("heartbeat", "Heartbeat report not delivered",
+				map[string]any{"business_id": businessID, "error": err.Error()})
+			return tools.ErrorResult(err.Error())
+		}
+		return tools.SilentResult(response)
+	})
+
 	var transcriber *voice.GroqTranscriber
 	if cfg.Providers.Groq.APIKey != "" {
 		transcriber = voice.NewGroqTranscriber(cfg.Providers.Groq.APIKey)
@@ -177,6 +262,12 @@ func gatewayCmd() {
 				logger.InfoC("voice", "Groq transcription attached to Slack channel")
 			}
 		}
+		if voiceChannel, ok := channelManager.GetChannel("voice"); ok {
+			if vc, ok := voiceChannel.(*channels.VoiceChannel); ok {
+				vc.SetTranscriber(transcriber)
+				logger.InfoC("voice", "Groq transcription attached to voice channel")
+			}
+		}
 	}
 
 	enabledChannels := channelManager.GetEnabledChannels()
@@ -186,12 +277,35 @@ func gatewayCmd() {
 		fmt.Println("⚠ Warning: No channels enabled")
 	}
 
+	if cfg.Tunnel.Mode != "" {
+		setupTunnel(cfg)
+	}
 	fmt.Printf("✓ Gateway started on %s:%d\n", cfg.Gateway.Host, cfg.Gateway.Port)
 	fmt.Println("Press Ctrl+C to stop")
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	var telemetryExporter *telemetry.Exporter
+	if cfg.Telemetry.Enabled && cfg.Telemetry.Endpoint != "" {
+		telemetryExporter = telemetry.Start(ctx, telemetry.Config{
+			Endpoint:       cfg.Telemetry.Endpoint,
+			Headers:        cfg.Telemetry.Headers,
+			SampleRatio:    cfg.Telemetry.SampleRatio,
+			Interval:       time.Duration(cfg.Telemetry.ExportIntervalSeconds) * time.Second,
+			ServiceName:    cfg.Telemetry.ServiceName,
+			ServiceVersion: version,
+			Resource:       cfg.Telemetry.ResourceAttributes,
+		})
+		fmt.Printf("✓ Telemetry export to %s\n", cfg.Telemetry.Endpoint)
+	}
+
+	elector := setupLeaderElection(ctx, cfg, pgDB)
+	if elector != nil {
+		cronService.SetLeaderCheck(elector.IsLeader)
+		heartbeatService.SetLeaderCheck(elector.IsLeader)
+	}
+
 	if err := cronService.Start(); err != nil {
 		fmt.Printf("Error starting cron service: %v\n", err)
 	}
@@ -202,7 +316,6 @@ func gatewayCmd() {
 	}
 	fmt.Println("✓ Heartbeat service started")
 
-	stateManager := state.NewManager(cfg.WorkspacePath())
 	deviceService := devices.NewService(devices.Config{
 		Enabled:    cfg.Devices.Enabled,
 		MonitorUSB: cfg.Devices.MonitorUSB,
@@ -214,22 +327,205 @@ func gatewayCmd() {
 		fmt.Println("✓ Device event service started")
 	}
 
+	mediaLibrary := setupMediaLibrary(ctx, cfg, agentLoop)
+	if cfg.Transcripts.Enabled {
+		transcripts, err := transcript.Open(cfg.WorkspacePath())
+		if err != nil {
+			fmt.Printf("Error opening transcript database: %v\n", err)
+		} else {
+			defer transcripts.Close()
+			agentLoop.SetTranscripts(transcripts)
+		}
+	}
+	documentStore := setupDocuments(cfg, agentLoop)
+	releases := setupReleaseCheck(ctx, cfg, msgBus, stateManager)
+	usageTracker := setupUsageDigest(ctx, cfg, msgBus, stateManager, mediaLibrary, releases)
+	spendBudget := setupBudget(cfg, agentLoop, msgBus, stateManager)
+	hardwareChecks := setupHardwareChecks(cfg)
+	setupAlerts(ctx, cfg, msgBus, stateManager, hardwareChecks)
+	setupSubscriptions(ctx, cfg)
+	setupSnapshots(ctx, cfg)
+	setupFederation(cfg, agentLoop)
+	setupExperiment(cfg, agentLoop)
+	setupInputFilter(cfg, agentLoop)
+	if cfg.Tools.Approval.Enabled {
+		fmt.Printf("✓ Approval required for %s\n", strings.Join(cfg.Tools.Approval.Tools, ", "))
+	}
+	workflows := setupWorkflows(ctx, cfg, agentLoop, cronService)
+	if err := agent.ValidateHandoff(cfg.Session.Handoff); err != nil {
+		fmt.Printf("Error in session config: %v\n", err)
+		os.Exit(1)
+	}
+	if mode := cfg.Session.Handoff; mode == agent.HandoffLink || mode == agent.HandoffUnify {
+		fmt.Printf("✓ Session handoff between linked chats and the API: %s\n", mode)
+	}
+	closeMCP := connectMCPServers(ctx, cfg, agentLoop)
+	defer closeMCP()
+
+	if cfg.Bus.RedisURL != "" {
+		transport, err := bus.NewRedisTransport(ctx, cfg.Bus.RedisURL, cfg.Bus.Prefix)
+		if err != nil {
+			fmt.Printf("Error connecting the message bus to Redis: %v\n", err)
+			os.Exit(1)
+		}
+		defer transport.Close()
+		msgBus.UseTransport(ctx, transport, channelManager.GetEnabledChannels())
+		fmt.Println("✓ Message bus shared through Redis")
+	}
+
+	if cfg.NATS.URL != "" {
+		responder := nats.NewResponder(agentLoop, cfg.Agents.Defaults.Model, locales)
+		if err := nats.Serve(ctx, cfg.NATS.URL, cfg.NATS.Subject, cfg.NATS.QueueGroup, responder.Handle); err != nil {
+			fmt.Printf("Error connecting to NATS: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✓ Answering agent requests on NATS subject %s\n", cfg.NATS.Subject)
+	}
+
 	if err := channelManager.StartAll(ctx); err != nil {
 		fmt.Printf("Error starting channels: %v\n", err)
 	}
 
 	configPath := getConfigPath()
-	healthServer := health.NewServer(
-		cfg.Gateway.Host, cfg.Gateway.Port,
+	// Shutdown requests from the control socket (picoclaw stop/restart) and
+	// the auto-updater.
+	shutdownRequests := make(chan bool, 1)
+	requestShutdown := func(restart bool) {
+		select {
+		case shutdownRequests <- restart:
+		default:
+		}
+	}
+	healthOpts := []health.ServerOption{
 		health.WithAgentLoop(agentLoop),
+		health.WithVersion(formatVersion()),
+		health.WithShutdown(requestShutdown),
 		health.WithPairing(cfg.Gateway.RequirePairing, cfg.Gateway.PairedTokens, configPath),
+		health.WithPairedDevices(cfg.Gateway.PairedDevices),
 		health.WithModel(cfg.Agents.Defaults.Model),
 		health.WithJWTAuth(cfg.Gateway.JWTSecret),
-	)
+		health.WithPprof(cfg.Gateway.Pprof),
+		health.WithWorkPool(workPool),
+		health.WithMaxUploadSize(int64(cfg.Gateway.MaxUploadMB) << 20),
+		health.WithDashboard(cfg.Gateway.Dashboard),
+		health.WithGraphQL(cfg.Gateway.GraphQL),
+		health.WithUsage(usageTracker),
+		health.WithLocales(locales),
+		health.WithListeners(cfg.Gateway.Listeners),
+	}
+	if releases != nil {
+		healthOpts = append(healthOpts, health.WithReleaseCheck(releases))
+	}
+	if code := os.Getenv(setupPairingCodeEnv); code != "" {
+		// Only the first run after the wizard accepts its code.
+		os.Unsetenv(setupPairingCodeEnv)
+		healthOpts = append(healthOpts, health.WithPairingCode(code))
+	}
+	if cfg.MCP.Serve {
+		if mcpServer, err := newMCPServer(cfg, agentLoop); err != nil {
+			fmt.Printf("Error starting MCP server: %v\n", err)
+		} else {
+			healthOpts = append(healthOpts, health.WithMCP(mcpServer))
+			fmt.Println("✓ MCP server at /mcp")
+		}
+	}
+	if mediaLibrary != nil {
+		healthOpts = append(healthOpts, health.WithMediaLibrary(mediaLibrary))
+	}
+	blobStore := setupBlobStore(cfg)
+	if blobStore != nil {
+		presign := time.Duration(cfg.Storage.PresignMinutes) * time.Minute
+		if presign <= 0 {
+			presign = time.Hour
+		}
+		healthOpts = append(healthOpts, health.WithBlobStore(blobStore, presign))
+	}
+	if artifacts := setupArtifacts(ctx, cfg, agentLoop, blobStore); artifacts != nil {
+		healthOpts = append(healthOpts, health.WithArtifacts(artifacts))
+	}
+	var eventLog *eventlog.Log
+	if cfg.EventLog.Enabled {
+		var err error
+		eventLog, err = eventlog.Open(cfg.WorkspacePath(), eventlog.Options{
+			Rotation: cfg.Logging.Rotation.Options(),
+			Sync:     cfg.EventLog.Sync,
+		})
+		if err != nil {
+			fmt.Printf("Error opening event log: %v\n", err)
+		} else {
+			defer eventLog.Close()
+			agentLoop.SetEventLog(eventLog)
+			healthOpts = append(healthOpts, health.WithEventLog(eventLog))
+			setupKafka(ctx, cfg, eventLog)
+		}
+	}
+	if client := ledgerForgeClient(cfg); client != nil {
+		pipeline := receipts.New(client, locales)
+		if cfg.Receipts.ReviewThreshold > 0 {
+			queue := receipts.NewReviewQueue(filepath.Join(cfg.WorkspacePath(), "receipts", "review"))
+			pipeline.SetReview(queue, cfg.Receipts.ReviewThreshold)
+		}
+		pipeline.SetCategoryRules(agentLoop.CategoryRules)
+		if eventLog != nil {
+			pipeline.SetEventLog(eventLog)
+		}
+		agentLoop.SetReceipts(pipeline)
+		healthOpts = append(healthOpts, health.WithReceipts(pipeline))
+	}
+	if documentStore != nil {
+		healthOpts = append(healthOpts, health.WithDocuments(documentStore))
+	}
+	if workflows != nil {
+		healthOpts = append(healthOpts, health.WithWorkflows(workflows))
+	}
+	if cfg.WireLog.Enabled {
+		wireLog, err := wirelog.New(cfg.WorkspacePath(), wirelog.Options{
+			MaxFileBytes:   int64(cfg.WireLog.MaxFileSizeKB) * 1024,
+			MaxFiles:       cfg.WireLog.MaxFiles,
+			MaxAge:         cfg.Logging.Rotation.Options().MaxAge,
+			Compress:       cfg.Logging.Rotation.Compress,
+			MaxBodyBytes:   cfg.WireLog.MaxBodyBytes,
+			RedactHeaders:  cfg.WireLog.RedactHeaders,
+			RedactPatterns: cfg.WireLog.RedactPatterns,
+		})
+		if err != nil {
+			fmt.Printf("Error creating wire log: %v\n", err)
+		} else {
+			defer wireLog.Close()
+			agentLoop.SetWireLog(wireLog)
+			healthOpts = append(healthOpts, health.WithWireLog(wireLog))
+			fmt.Println("✓ Wire log enabled")
+		}
+	}
+	healthServer := health.NewServer(cfg.Gateway.Host, cfg.Gateway.Port, healthOpts...)
+	for _, name := range enabledChannels {
+		if ch, ok := channelManager.GetChannel(name); ok {
+			healthServer.RegisterCheck("channel:"+name, func() (bool, string) {
+				if !ch.IsRunning() {
+					return false, "channel not running"
+				}
+				return true, ""
+			})
+		}
+	}
+	for _, check := range hardwareChecks {
+		healthServer.RegisterWarningCheck("hardware:"+check.Name, check.Run)
+	}
+	resourceChecks := healthServer.RegisterResourceChecks(health.ResourceOptions{
+		DiskPath:           cfg.WorkspacePath(),
+		MinDiskFreePercent: cfg.Resources.MinDiskFreePercent,
+		MinDiskFreeMB:      cfg.Resources.MinDiskFreeMB,
+		MaxMemoryPercent:   cfg.Resources.MaxMemoryPercent,
+	})
+	if len(resourceChecks) > 0 {
+		fmt.Printf("✓ Resource checks: %s\n", strings.Join(resourceChecks, ", "))
+	}
+	go healthServer.RunChecks(ctx, 30*time.Second)
 	go func() {
 		defer func() {
 			if r := recover(); r != nil {
 				fmt.Printf("PANIC in health server: %v\n", r)
+				crash.Report("health", r, nil)
 			}
 		}()
 		err := healthServer.Start()
@@ -240,29 +536,835 @@ func gatewayCmd() {
 			fmt.Printf("Health server stopped (err=%v)\n", err)
 		}
 	}()
+	go func() {
+		err := healthServer.ServeControl(controlSocketPath())
+		if err != nil && err != http.ErrServerClosed {
+			fmt.Printf("Warning: control socket unavailable, picoclaw pair won't work: %v\n", err)
+		}
+	}()
 	fmt.Printf("✓ Health endpoints available at http://%s:%d/health and /ready\n", cfg.Gateway.Host, cfg.Gateway.Port)
+	if len(cfg.Gateway.Listeners) > 0 {
+		addrs, err := healthServer.ServeListeners()
+		if err != nil {
+			fmt.Printf("Error in gateway listeners: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✓ Also listening on %s\n", strings.Join(addrs, ", "))
+	}
+	if cfg.Gateway.Dashboard {
+		fmt.Printf("✓ Dashboard available at http://%s:%d/ui/\n", cfg.Gateway.Host, cfg.Gateway.Port)
+	}
 	fmt.Printf("✓ API endpoints available: POST /webhook, POST /pair\n")
 	if code := healthServer.GetPairingCode(); code != "" {
 		fmt.Printf("\n🔑 Pairing code: %s\n", code)
 		fmt.Println("  Use this code in the desktop client to pair with this gateway.")
+		fmt.Println("  Run 'picoclaw pair' to show it again as a QR code.")
 		fmt.Println("  The code is one-time use and will expire after pairing.\n")
 	}
 
-	go agentLoop.Run(ctx)
+	go func() {
+		defer crash.Recover("agent")
+		agentLoop.Run(ctx)
+	}()
+	go func() {
+		defer crash.Recover("skills")
+		agentLoop.RunSkillEvents(ctx, time.Second)
+	}()
+
+	// Under systemd (Type=notify), report readiness now that channels and the
+	// agent loop are up, and keep the watchdog fed while checks pass.
+	if ok, err := sdnotify.Notify(sdnotify.Ready); err != nil {
+		logger.WarnCF("gateway", "systemd notification failed", map[string]any{"error": err.Error()})
+	} else if ok {
+		go sdnotify.RunWatchdog(ctx, healthServer.Healthy)
+	}
+
+	if cfg.Update.Auto {
+		setupAutoUpdate(ctx, cfg, func(string) { requestShutdown(true) })
+	}
+	setupProvision(ctx, cfg, func() { requestShutdown(true) })
+	setupMDNS(ctx, cfg, healthServer)
+	stopPortMapping := setupPortMapping(ctx, cfg, healthServer)
 
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt)
-	<-sigChan
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	select {
+	case <-sigChan:
+	case restart = <-shutdownRequests:
+	}
+	signal.Stop(sigChan)
 
 	fmt.Println("\nShutting down...")
+	sdnotify.Notify(sdnotify.Stopping)
 	cancel()
+	stopPortMapping()
 	healthServer.Stop(context.Background())
 	deviceService.Stop()
 	heartbeatService.Stop()
 	cronService.Stop()
+	elector.Resign()
 	agentLoop.Stop()
 	channelManager.StopAll(ctx)
+	if telemetryExporter != nil {
+		telemetryExporter.Shutdown(context.Background())
+	}
+	if usageTracker != nil {
+		usageTracker.Save()
+	}
+	if spendBudget != nil {
+		spendBudget.Save()
+	}
+	reporting.Flush(5 * time.Second)
 	fmt.Println("✓ Gateway stopped")
+	return restart
+}
+
+// setupAutoUpdate checks for signed releases in the background and calls
+// installed once one has replaced this binary.
+func setupAutoUpdate(ctx context.Context, cfg *config.Config, installed func(version string)) {
+	u, err := update.New(cfg.Update.ManifestURL, cfg.Update.PublicKey)
+	if err != nil {
+		fmt.Printf("Warning: auto-update disabled: %v\n", err)
+		return
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Printf("Warning: auto-update disabled: %v\n", err)
+		return
+	}
+	interval := time.Duration(cfg.Update.CheckIntervalHours) * time.Hour
+	if interval <= 0 {
+		interval = 24 * time.Hour
+	}
+	go u.Run(ctx, interval, exe, version, installed)
+	fmt.Printf("✓ Auto-update enabled (every %s)\n", interval)
+}
+
+// setupUsageDigest starts usage counting and, if enabled, the daily digest.
+func setupUsageDigest(
+	ctx context.Context,
+	cfg *config.Config,
+	msgBus *bus.MessageBus,
+	stateManager *state.Manager,
+	mediaLibrary *media.Library,
+	releases *update.ReleaseChecker,
+) *usage.Tracker {
+	tracker, err := usage.Init(cfg.WorkspacePath())
+	if err != nil {
+		fmt.Printf("Error loading usage counters: %v\n", err)
+		return nil
+	}
+	if !cfg.Digest.Enabled {
+		return tracker
+	}
+
+	prices := make(map[string]usage.TokenPrice, len(cfg.Digest.Prices))
+	for model, p := range cfg.Digest.Prices {
+		prices[model] = usage.TokenPrice{Prompt: p.Prompt, Completion: p.Completion}
+	}
+	opts := usage.DigestOptions{
+		At:     cfg.Digest.Time,
+		Prices: prices,
+		Send:   ownerSender(msgBus, stateManager, "digest", cfg.Digest.Channel, cfg.Digest.ChatID),
+	}
+	if mediaLibrary != nil {
+		opts.Storage = func() string {
+			u, err := mediaLibrary.Usage()
+			if err != nil {
+				return ""
+			}
+			return u.Format("")
+		}
+	}
+	if releases != nil {
+		opts.Release = func() string {
+			status := releases.Status()
+			if !status.Available {
+				return ""
+			}
+			return fmt.Sprintf("Update available: %s (running %s)", status.Latest, status.Current)
+		}
+	}
+	digest, err := usage.NewDigest(tracker, opts)
+	if err != nil {
+		fmt.Printf("Error enabling daily digest: %v\n", err)
+		return tracker
+	}
+	go func() {
+		defer crash.Recover("usage")
+		digest.Run(ctx)
+	}()
+	fmt.Printf("✓ Daily digest scheduled at %s\n", cfg.Digest.Time)
+	return tracker
+}
+
+// ownerSender returns a function sending to channel and chatID, or to the
+// chat the owner last used when they are empty, as heartbeat does. what
+// names the feature in errors.
+func ownerSender(msgBus *bus.MessageBus, stateManager *state.Manager, what, channel, chatID string) func(string) error {
+	return func(content string) error {
+		channel, chatID := channel, chatID
+		if channel == "" || chatID == "" {
+			last := strings.SplitN(stateManager.GetLastChannel(), ":", 2)
+			if len(last) != 2 || last[0] == "" || last[1] == "" {
+				return fmt.Errorf("no %s channel configured and no chat recorded yet", what)
+			}
+			channel, chatID = last[0], last[1]
+		}
+		msgBus.PublishOutbound(bus.OutboundMessage{Channel: channel, ChatID: chatID, Content: content})
+		return nil
+	}
+}
+
+// setupBudget enforces the spend budgets, if enabled.
+func setupBudget(
+	cfg *config.Config,
+	agentLoop *agent.AgentLoop,
+	msgBus *bus.MessageBus,
+	stateManager *state.Manager,
+) *budget.Budget {
+	if !cfg.Budget.Enabled {
+		return nil
+	}
+	prices := make(map[string]usage.TokenPrice, len(cfg.Digest.Prices))
+	for model, p := range cfg.Digest.Prices {
+		prices[model] = usage.TokenPrice{Prompt: p.Prompt, Completion: p.Completion}
+	}
+	businesses := make(map[string]budget.Limits, len(cfg.Budget.Businesses))
+	for id, b := range cfg.Budget.Businesses {
+		businesses[id] = budget.Limits{Daily: b.DailyUSD, Monthly: b.MonthlyUSD}
+	}
+	send := ownerSender(msgBus, stateManager, "budget", cfg.Budget.Channel, cfg.Budget.ChatID)
+	b, err := budget.New(cfg.WorkspacePath(), budget.Options{
+		Global:        budget.Limits{Daily: cfg.Budget.DailyUSD, Monthly: cfg.Budget.MonthlyUSD},
+		Businesses:    businesses,
+		WarnPercent:   cfg.Budget.WarnPercent,
+		StopPercent:   cfg.Budget.StopPercent,
+		Prices:        prices,
+		FallbackModel: cfg.Budget.FallbackModel,
+		Notify: func(message string) {
+			if err := send(message); err != nil {
+				logger.WarnCF("budget", "Failed to send budget notice", map[string]any{"error": err.Error()})
+			}
+		},
+	})
+	if err != nil {
+		fmt.Printf("Error in budget config: %v\n", err)
+		os.Exit(1)
+	}
+	if len(prices) == 0 {
+		fmt.Println("Warning: budget enabled but digest.prices is empty; no calls are costed")
+	}
+
+	var provider providers.LLMProvider
+	var modelID string
+	if cfg.Budget.FallbackModel != "" {
+		provider, modelID, err = providers.CreateProviderForModel(cfg, cfg.Budget.FallbackModel)
+		if err != nil {
+			fmt.Printf("Error creating budget fallback provider: %v\n", err)
+			os.Exit(1)
+		}
+		if cfg.LLMCache.Enabled && cfg.LLMCache.TTLMinutes > 0 {
+			provider = providers.NewCachingProvider(provider,
+				time.Duration(cfg.LLMCache.TTLMinutes)*time.Minute, cfg.LLMCache.MaxEntries)
+		}
+	}
+	agentLoop.SetBudget(b, provider, modelID)
+	fmt.Printf("✓ Spend budget of $%.2f/day, $%.2f/month and %d business budget(s)\n",
+		cfg.Budget.DailyUSD, cfg.Budget.MonthlyUSD, len(businesses))
+	return b
+}
+
+// setupReleaseCheck watches the release feed, if enabled, and tells the
+// owner about each newer release once.
+func setupReleaseCheck(
+	ctx context.Context,
+	cfg *config.Config,
+	msgBus *bus.MessageBus,
+	stateManager *state.Manager,
+) *update.ReleaseChecker {
+	if !cfg.Update.Notify || cfg.Update.FeedURL == "" {
+		return nil
+	}
+	checker := update.NewReleaseChecker(cfg.Update.FeedURL, version)
+	notify := func(release *update.Release) {
+		if stateManager.NotifiedRelease() == release.Version {
+			return
+		}
+		channel, chatID := cfg.Update.Channel, cfg.Update.ChatID
+		if channel == "" || chatID == "" {
+			last := strings.SplitN(stateManager.GetLastChannel(), ":", 2)
+			if len(last) != 2 || last[0] == "" || last[1] == "" {
+				logger.WarnCF("update", "No channel to announce the release on", map[string]any{
+					"version": release.Version,
+				})
+				return
+			}
+			channel, chatID = last[0], last[1]
+		}
+		msgBus.PublishOutbound(bus.OutboundMessage{
+			Channel:      channel,
+			ChatID:       chatID,
+			Content:      update.FormatNotice(release, version),
+			Notification: true,
+		})
+		if err := stateManager.SetNotifiedRelease(release.Version); err != nil {
+			logger.WarnCF("update", "Failed to record the release notice", map[string]any{"error": err.Error()})
+		}
+	}
+	interval := time.Duration(cfg.Update.CheckIntervalHours) * time.Hour
+	if interval <= 0 {
+		interval = 24 * time.Hour
+	}
+	go func() {
+		defer crash.Recover("update")
+		checker.Run(ctx, interval, notify)
+	}()
+	fmt.Printf("✓ Checking for new releases (every %s)\n", interval)
+	return checker
+}
+
+// setupAlerts starts the failure alert rules if enabled.
+func setupAlerts(
+	ctx context.Context,
+	cfg *config.Config,
+	msgBus *bus.MessageBus,
+	stateManager *state.Manager,
+	hardwareChecks []hardware.Check,
+) {
+	if !cfg.Alerts.Enabled {
+		return
+	}
+	checks := make(map[string]func() (bool, string))
+	for _, check := range hardwareChecks {
+		checks[check.Name] = check.Run
+	}
+	notifiers := []alerts.Notifier{func(alert alerts.Alert) error {
+		channel, chatID := cfg.Alerts.Channel, cfg.Alerts.ChatID
+		if channel == "" || chatID == "" {
+			last := strings.SplitN(stateManager.GetLastChannel(), ":", 2)
+			if len(last) != 2 || last[0] == "" || last[1] == "" {
+				return fmt.Errorf("no alert channel configured and no chat recorded yet")
+			}
+			channel, chatID = last[0], last[1]
+		}
+		msgBus.PublishOutbound(bus.OutboundMessage{
+			Channel: channel,
+			ChatID:  chatID,
+			Content: "⚠️ " + alert.Message,
+		})
+		return nil
+	}}
+	if cfg.Alerts.WebhookURL != "" {
+		notifiers = append(notifiers, alerts.WebhookNotifier(cfg.Alerts.WebhookURL))
+	}
+	monitor := alerts.NewMonitor(alerts.Options{
+		WebhookFailures: cfg.Alerts.WebhookFailures,
+		ProviderDownFor: time.Duration(cfg.Alerts.ProviderDownMinutes) * time.Minute,
+		DiskPercent:     cfg.Alerts.DiskUsagePercent,
+		DiskPath:        cfg.WorkspacePath(),
+		Cooldown:        time.Duration(cfg.Alerts.CooldownMinutes) * time.Minute,
+		Notifiers:       notifiers,
+		Checks:          checks,
+	})
+	go func() {
+		defer crash.Recover("alerts")
+		monitor.Run(ctx, time.Minute)
+	}()
+	fmt.Println("✓ Failure alerts enabled")
+}
+
+// setupHardwareChecks returns the board health checks this board supports.
+func setupHardwareChecks(cfg *config.Config) []hardware.Check {
+	if !cfg.Hardware.Enabled {
+		return nil
+	}
+	checks := hardware.Checks(hardware.Options{
+		MaxTemperature: cfg.Hardware.MaxTemperatureC,
+	})
+	if len(checks) > 0 {
+		names := make([]string, len(checks))
+		for i, check := range checks {
+			names[i] = check.Name
+		}
+		fmt.Printf("✓ Hardware health checks: %s\n", strings.Join(names, ", "))
+	}
+	return checks
+}
+
+// setupSubscriptions starts delivering events to subscribed URLs, if any.
+func setupSubscriptions(ctx context.Context, cfg *config.Config) {
+	if len(cfg.Subscriptions.Endpoints) == 0 {
+		return
+	}
+	dispatcher, err := subscriptions.New(cfg.Subscriptions, cfg.WorkspacePath())
+	if err != nil {
+		fmt.Printf("Error in subscriptions config: %v\n", err)
+		os.Exit(1)
+	}
+	go func() {
+		defer crash.Recover("subscriptions")
+		dispatcher.Run(ctx)
+	}()
+	fmt.Printf("✓ Posting events to %d subscribed URL(s)\n", len(cfg.Subscriptions.Endpoints))
+}
+
+// setupSnapshots schedules the nightly snapshot upload, if enabled.
+func setupSnapshots(ctx context.Context, cfg *config.Config) {
+	if !cfg.Snapshots.Enabled {
+		return
+	}
+	snapshotter, err := newSnapshotter(cfg)
+	if err != nil {
+		fmt.Printf("Error in snapshots config: %v\n", err)
+		os.Exit(1)
+	}
+	go func() {
+		defer crash.Recover("snapshot")
+		snapshotter.Run(ctx)
+	}()
+	fmt.Printf("✓ Snapshots uploaded to %s daily at %s\n", cfg.Snapshots.Target, cfg.Snapshots.Time)
+}
+
+// setupFederation delegates requests to peer instances by the configured
+// rules, if any.
+func setupFederation(cfg *config.Config, agentLoop *agent.AgentLoop) {
+	if len(cfg.Federation.Rules) == 0 {
+		return
+	}
+	origin := cfg.Federation.Name
+	if origin == "" {
+		origin, _ = os.Hostname()
+	}
+	router, err := federation.New(cfg.Federation, origin)
+	if err != nil {
+		fmt.Printf("Error in federation config: %v\n", err)
+		os.Exit(1)
+	}
+	agentLoop.SetFederation(router)
+	fmt.Printf("✓ Delegating requests to %d peer(s) as %s\n", len(cfg.Federation.Peers), origin)
+}
+
+// setupDocuments opens the document index and gives the agent the
+// search_documents tool, when documents are enabled.
+func setupDocuments(cfg *config.Config, agentLoop *agent.AgentLoop) *documents.Store {
+	if !cfg.Documents.Enabled {
+		return nil
+	}
+	store, err := newDocumentStore(cfg)
+	if err != nil {
+		fmt.Printf("Error in documents config: %v\n", err)
+		os.Exit(1)
+	}
+	agentLoop.SetDocuments(store)
+	fmt.Printf("✓ Document search enabled (embeddings from %s)\n", cfg.Documents.Model)
+	return store
+}
+
+// setupInputFilter screens group chat messages for the configured words and
+// patterns, if the filter is enabled.
+func setupInputFilter(cfg *config.Config, agentLoop *agent.AgentLoop) {
+	if !cfg.InputFilter.Enabled {
+		return
+	}
+	filter, err := agent.NewInputFilter(cfg.InputFilter)
+	if err != nil {
+		fmt.Printf("Error in input filter config: %v\n", err)
+		os.Exit(1)
+	}
+	agentLoop.SetInputFilter(filter)
+	fmt.Printf("✓ Filtering group messages (%d word(s), %d pattern(s))\n",
+		len(cfg.InputFilter.Words), len(cfg.InputFilter.Patterns))
+}
+
+// setupWorkflows loads the workflows under the workspace, schedules those
+// with a cron trigger, and runs the engine until ctx is done.
+func setupWorkflows(
+	ctx context.Context,
+	cfg *config.Config,
+	agentLoop *agent.AgentLoop,
+	cronService *cron.CronService,
+) *workflow.Engine {
+	var engine *workflow.Engine
+	var jobs []cron.CronJob
+	if cfg.Workflows.Enabled {
+		var err error
+		engine, err = workflow.New(cfg.WorkspacePath(), agentLoop.WorkflowRunner())
+		if err != nil {
+			fmt.Printf("Error in workflows: %v\n", err)
+			os.Exit(1)
+		}
+		jobs = engine.ScheduledJobs()
+	}
+	// Sync even when disabled, so jobs of removed workflows go away
+	if err := cronService.SyncJobs(cron.WorkflowJobPrefix, jobs); err != nil {
+		logger.WarnCF("cron", "Failed to sync scheduled workflows", map[string]any{"error": err.Error()})
+	}
+	if engine == nil {
+		return nil
+	}
+
+	agentLoop.SetWorkflows(engine)
+	go func() {
+		defer crash.Recover("workflow")
+		engine.Run(ctx)
+	}()
+	fmt.Printf("✓ %d workflow(s) loaded\n", len(engine.Workflows()))
+	return engine
+}
+
+// setupExperiment routes part of the traffic to the configured variant model
+// or prompt, if the experiment is enabled.
+func setupExperiment(cfg *config.Config, agentLoop *agent.AgentLoop) {
+	if !cfg.Experiment.Enabled {
+		return
+	}
+	var provider providers.LLMProvider
+	var modelID string
+	if cfg.Experiment.Model != "" {
+		var err error
+		provider, modelID, err = providers.CreateProviderForModel(cfg, cfg.Experiment.Model)
+		if err != nil {
+			fmt.Printf("Error creating experiment provider: %v\n", err)
+			os.Exit(1)
+		}
+		if cfg.LLMCache.Enabled && cfg.LLMCache.TTLMinutes > 0 {
+			provider = providers.NewCachingProvider(provider,
+				time.Duration(cfg.LLMCache.TTLMinutes)*time.Minute, cfg.LLMCache.MaxEntries)
+		}
+	}
+	experiment, err := agent.NewExperiment(cfg.Experiment, provider, modelID)
+	if err != nil {
+		fmt.Printf("Error in experiment config: %v\n", err)
+		os.Exit(1)
+	}
+	agentLoop.SetExperiment(experiment)
+	fmt.Printf("✓ Experiment %s on %v%% of sessions and %d business(es)\n",
+		experiment.Name(), cfg.Experiment.Percent, len(cfg.Experiment.Businesses))
+}
+
+// setupMDNS advertises the gateway on the LAN over mDNS, unless the LAN
+// couldn't reach it anyway.
+func setupMDNS(ctx context.Context, cfg *config.Config, healthServer *health.Server) {
+	if !cfg.MDNS.Enabled || !lanReachable(cfg) {
+		return
+	}
+	hostname, _ := os.Hostname()
+	name := cfg.MDNS.Name
+	if name == "" {
+		name = "PicoClaw on " + hostname
+	}
+	deviceID, err := state.DeviceID(cfg.WorkspacePath())
+	if err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+	version := formatVersion()
+	responder, err := mdns.New(mdns.Service{
+		Instance: name,
+		Host:     hostname,
+		Port:     cfg.Gateway.Port,
+		TXT: func() []string {
+			pairing := "closed"
+			if healthServer.GetPairingCode() != "" {
+				pairing = "open"
+			}
+			return []string{"version=" + version, "id=" + deviceID, "pairing=" + pairing}
+		},
+	})
+	if err != nil {
+		fmt.Printf("Warning: mDNS unavailable: %v\n", err)
+		return
+	}
+	go func() {
+		defer crash.Recover("mdns")
+		if err := responder.Run(ctx); err != nil {
+			logger.WarnCF("mdns", "mDNS responder stopped", map[string]any{"error": err.Error()})
+		}
+	}()
+	fmt.Printf("✓ Advertising %q as %s over mDNS\n", name, mdns.ServiceType)
+}
+
+// setupPortMapping keeps the gateway's port mapped on the router, if enabled,
+// and reports the external address. The returned function waits for the
+// mapping to be deleted once ctx is done.
+func setupPortMapping(ctx context.Context, cfg *config.Config, healthServer *health.Server) func() {
+	if !cfg.PortMapping.Enabled {
+		return func() {}
+	}
+	if !lanReachable(cfg) {
+		fmt.Println("Warning: port mapping skipped, the gateway doesn't listen on the LAN")
+		return func() {}
+	}
+	if !cfg.Gateway.RequirePairing {
+		fmt.Println("Warning: port mapping exposes the API to the internet without gateway.require_pairing")
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer crash.Recover("portmap")
+		portmap.Run(ctx, portmap.Options{
+			InternalPort: cfg.Gateway.Port,
+			ExternalPort: cfg.PortMapping.ExternalPort,
+			Lifetime:     time.Duration(cfg.PortMapping.LifetimeSeconds) * time.Second,
+			OnChange:     healthServer.SetExternalAddress,
+		})
+	}()
+	fmt.Println("✓ Port mapping requested from the router (NAT-PMP or UPnP)")
+	return func() { <-done }
+}
+
+// setupTunnel brings up the configured Tailscale or WireGuard tunnel and,
+// with tunnel.bind_only, makes the gateway listen on its address alone. The
+// gateway won't start without the tunnel then, rather than listen elsewhere.
+func setupTunnel(cfg *config.Config) {
+	t, err := tunnel.Up(context.Background(), cfg.Tunnel)
+	if err != nil {
+		if cfg.Tunnel.BindOnly {
+			fmt.Printf("Error: tunnel unavailable: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Warning: tunnel unavailable: %v\n", err)
+		return
+	}
+	if cfg.Tunnel.BindOnly {
+		cfg.Gateway.Host = t.Address.String()
+	}
+	fmt.Printf("✓ Reachable over %s (%s) at %s:%d\n", t.Mode, t.Interface, t.Address, cfg.Gateway.Port)
+}
+
+// lanReachable reports whether the gateway listens where the LAN can reach
+// it: not on loopback alone, nor on a tunnel alone.
+func lanReachable(cfg *config.Config) bool {
+	if cfg.Tunnel.Mode != "" && cfg.Tunnel.BindOnly {
+		return false
+	}
+	host := cfg.Gateway.Host
+	ip := net.ParseIP(host)
+	return host != "localhost" && (ip == nil || !ip.IsLoopback())
+}
+
+// setupKafka streams the event log to Kafka if brokers are configured.
+func setupKafka(ctx context.Context, cfg *config.Config, eventLog *eventlog.Log) {
+	if len(cfg.EventLog.Kafka.Brokers) == 0 {
+		return
+	}
+	deviceID, err := state.DeviceID(cfg.WorkspacePath())
+	if err != nil {
+		fmt.Printf("Warning: event stream to Kafka disabled: %v\n", err)
+		return
+	}
+	streamer, err := kafka.New(eventLog, cfg.WorkspacePath(), deviceID, cfg.EventLog.Kafka)
+	if err != nil {
+		fmt.Printf("Warning: event stream to Kafka disabled: %v\n", err)
+		return
+	}
+	go func() {
+		defer crash.Recover("kafka")
+		streamer.Run(ctx)
+	}()
+	fmt.Printf("✓ Streaming events to Kafka topic %s\n", cfg.EventLog.Kafka.Topic)
+}
+
+// setupBlobStore returns the configured S3 store, or nil for local storage.
+func setupBlobStore(cfg *config.Config) blob.Store {
+	if cfg.Storage.Backend != "s3" {
+		return nil
+	}
+	store, err := blob.NewS3Store(blob.S3Config{
+		Endpoint:        cfg.Storage.S3.Endpoint,
+		Region:          cfg.Storage.S3.Region,
+		Bucket:          cfg.Storage.S3.Bucket,
+		AccessKeyID:     cfg.Storage.S3.AccessKeyID,
+		SecretAccessKey: cfg.Storage.S3.SecretAccessKey,
+		PathStyle:       cfg.Storage.S3.PathStyle,
+		Prefix:          cfg.Storage.S3.Prefix,
+	})
+	if err != nil {
+		fmt.Printf("Error configuring S3 storage: %v\n", err)
+		return nil
+	}
+	fmt.Printf("✓ Uploads stored in bucket %s\n", cfg.Storage.S3.Bucket)
+	return store
+}
+
+// setupArtifacts loads the artifact registry and starts expiring artifacts.
+func setupArtifacts(
+	ctx context.Context,
+	cfg *config.Config,
+	agentLoop *agent.AgentLoop,
+	store blob.Store,
+) *artifact.Registry {
+	registry, err := artifact.NewRegistry(cfg.WorkspacePath(), artifact.Options{
+		TTL:   time.Duration(cfg.Artifacts.TTLHours) * time.Hour,
+		Store: store,
+	})
+	if err != nil {
+		fmt.Printf("Error loading artifact registry: %v\n", err)
+		return nil
+	}
+	agentLoop.SetArtifacts(registry)
+	if cfg.Artifacts.TTLHours <= 0 {
+		return registry
+	}
+
+	interval := time.Duration(cfg.Artifacts.CleanupIntervalMinutes) * time.Minute
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	go func() {
+		defer crash.Recover("artifact")
+		registry.Run(ctx, interval)
+	}()
+	return registry
+}
+
+// setupMediaLibrary loads the upload store with the storage quotas and starts
+// periodic cleanup if a retention limit is set.
+// setupPostgres moves sessions and state to Postgres when a DSN is
+// configured and returns the state manager shared by the gateway and the
+// database, or nils to keep them in the workspace. The pool stays open for
+// the life of the process.
+func setupPostgres(cfg *config.Config, agentLoop *agent.AgentLoop) (*state.Manager, *pgstore.DB) {
+	pg := cfg.Storage.Postgres
+	if pg.DSN == "" {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	db, err := pgstore.Open(ctx, pg.DSN)
+	if err != nil {
+		// Exit rather than start with empty local sessions.
+		fmt.Printf("Error opening Postgres storage: %v\n", err)
+		os.Exit(1)
+	}
+
+	stateManager := state.NewManagerWithStore(db.State(pg.Namespace))
+	agentLoop.UseStores(func(agentID string) session.Store {
+		return db.Sessions(pg.Namespace + "/" + agentID)
+	}, stateManager)
+	fmt.Printf("✓ Sessions and state stored in Postgres (namespace %s)\n", pg.Namespace)
+	return stateManager, db
+}
+
+// setupLeaderElection campaigns for the namespace's leader lease when
+// gateways share a Postgres database and election is enabled, and returns
+// the elector, or nil when this instance always leads.
+func setupLeaderElection(ctx context.Context, cfg *config.Config, db *pgstore.DB) *leader.Elector {
+	pg := cfg.Storage.Postgres
+	if db == nil || !pg.LeaderElection {
+		return nil
+	}
+	id := pg.InstanceID
+	if id == "" {
+		id = leader.InstanceID()
+	}
+	ttl := time.Duration(pg.LeaseSeconds) * time.Second
+	if ttl <= 0 {
+		ttl = 30 * time.Second
+	}
+	elector := leader.New(db.Lease(pg.Namespace+"/leader"), id, ttl)
+	go func() {
+		defer crash.Recover("leader")
+		elector.Run(ctx)
+	}()
+	fmt.Printf("✓ Leader election enabled (instance %s)\n", id)
+	return elector
+}
+
+// heartbeatReporter formats business heartbeat results and sends them
+// straight to the business's chat, falling back to the configured chats.
+func heartbeatReporter(
+	cfg *config.Config,
+	channelManager *channels.Manager,
+	stateManager *state.Manager,
+) *heartbeat.Reporter {
+	opts := heartbeat.ReporterOptions{
+		Workspace: cfg.WorkspacePath(),
+		Send:      channelManager.SendToChannel,
+		State:     stateManager,
+	}
+	for _, t := range cfg.Heartbeat.Fallbacks {
+		opts.Fallbacks = append(opts.Fallbacks, heartbeat.Target{Channel: t.Channel, ChatID: t.ChatID})
+	}
+	opts.LedgerForge = ledgerForgeClient(cfg)
+	opts.Locales = locale.NewResolver(cfg.Locale)
+	return heartbeat.NewReporter(opts)
+}
+
+// ledgerForgeClient returns a LedgerForge API client, or nil when no
+// LedgerForge base URL is configured.
+func ledgerForgeClient(cfg *config.Config) *ledgerforge.Client {
+	if cfg.LedgerForge.BaseURL == "" {
+		return nil
+	}
+	return ledgerforge.New(ledgerforge.Options{
+		BaseURL:       cfg.LedgerForge.BaseURL,
+		Timeout:       time.Duration(cfg.LedgerForge.TimeoutSeconds) * time.Second,
+		MaxRetries:    cfg.LedgerForge.MaxRetries,
+		SigningSecret: cfg.LedgerForge.SigningSecret,
+		CallbackPath:  cfg.LedgerForge.CallbackPath,
+	})
+}
+
+// heartbeatSchedules parses the per-business heartbeat schedules in each
+// business's zone, exiting on an invalid window or timezone rather than
+// running at the wrong times.
+func heartbeatSchedules(
+	businesses map[string]config.BusinessHeartbeatConfig,
+	locales *locale.Resolver,
+) map[string]heartbeat.Schedule {
+	schedules := make(map[string]heartbeat.Schedule, len(businesses))
+	for id, c := range businesses {
+		sched, err := heartbeat.ParseSchedule(c, locales.For(id).Location())
+		if err != nil {
+			fmt.Printf("Error in heartbeat schedule of business %s: %v\n", id, err)
+			os.Exit(1)
+		}
+		schedules[id] = sched
+	}
+	return schedules
+}
+
+func setupMediaLibrary(ctx context.Context, cfg *config.Config, agentLoop *agent.AgentLoop) *media.Library {
+	opts := media.Options{
+		MaxAge:         time.Duration(cfg.Media.MaxAgeDays) * 24 * time.Hour,
+		MaxBytes:       int64(cfg.Media.MaxSizeMB) << 20,
+		WorkspaceQuota: int64(cfg.Storage.WorkspaceQuotaMB) << 20,
+		BusinessQuota:  int64(cfg.Storage.BusinessQuotaMB) << 20,
+	}
+	if cfg.Media.Encrypt {
+		// Exit rather than fall back to storing uploads in clear text.
+		c, err := media.LoadOrCreateKey(cfg.WorkspacePath())
+		if err != nil {
+			fmt.Printf("Error loading media key: %v\n", err)
+			os.Exit(1)
+		}
+		opts.Cipher = c
+	}
+	library, err := media.NewLibrary(cfg.WorkspacePath(), opts)
+	if err != nil {
+		fmt.Printf("Error loading media library: %v\n", err)
+		return nil
+	}
+	if n, err := library.EncryptExisting(); err != nil {
+		fmt.Printf("Warning: failed to encrypt existing media: %v\n", err)
+	} else if n > 0 {
+		fmt.Printf("✓ Encrypted %d existing media files\n", n)
+	}
+	agentLoop.SetMediaLibrary(library)
+	if cfg.Media.MaxAgeDays <= 0 && cfg.Media.MaxSizeMB <= 0 {
+		return library
+	}
+
+	interval := time.Duration(cfg.Media.CleanupIntervalMinutes) * time.Minute
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	go func() {
+		defer crash.Recover("media")
+		library.Run(ctx, interval)
+	}()
+	return library
 }
 
 func setupCronTool(
@@ -282,8 +1384,25 @@ func setupCronTool(
 	cronTool := tools.NewCronTool(cronService, agentLoop, msgBus, workspace, restrict, execTimeout, cfg)
 	agentLoop.RegisterTool(cronTool)
 
+	// Register jobs for skills whose manifests declare a schedule
+	if err := cronService.SyncJobs(cron.SkillJobPrefix, agentLoop.ScheduledSkillJobs()); err != nil {
+		logger.WarnCF("cron", "Failed to sync scheduled skills", map[string]any{"error": err.Error()})
+	}
+
 	// Set the onJob handler
 	cronService.SetOnJob(func(job *cron.CronJob) (string, error) {
+		if job.Payload.Kind == cron.PayloadKindSkill {
+			if err := agentLoop.RunScheduledSkill(context.Background(), job.Payload.Skill, job.Schedule.TZ); err != nil {
+				return "", err
+			}
+			return "ok", nil
+		}
+		if job.Payload.Kind == cron.PayloadKindWorkflow {
+			if err := agentLoop.RunScheduledWorkflow(job.Payload.Workflow); err != nil {
+				return "", err
+			}
+			return "ok", nil
+		}
 		result := cronTool.ExecuteJob(context.Background(), job)
 		return result, nil
 	})
@@ -0,0 +1,166 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/documents"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func indexCmd() {
+	var dir, businessID string
+	args := os.Args[2:]
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--business", "-b":
+			if i+1 >= len(args) {
+				indexHelp()
+				os.Exit(1)
+			}
+			i++
+			businessID = args[i]
+		case "--help", "-h":
+			indexHelp()
+			return
+		default:
+			if strings.HasPrefix(args[i], "-") || dir != "" {
+				fmt.Printf("Unknown argument: %s\n", args[i])
+				indexHelp()
+				os.Exit(1)
+			}
+			dir = args[i]
+		}
+	}
+	if dir == "" {
+		indexHelp()
+		os.Exit(1)
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	store, err := newDocumentStore(cfg)
+	if err != nil {
+		fmt.Printf("Error in documents config: %v\n", err)
+		os.Exit(1)
+	}
+	logger.SetLevel(logger.WARN)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	indexed, unchanged, failed := 0, 0, 0
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(d.Name(), ".") && path != dir {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !d.Type().IsRegular() {
+			return nil
+		}
+		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		name = filepath.ToSlash(name)
+		info, err := indexFile(ctx, store, businessID, name, path)
+		switch {
+		case errors.Is(err, documents.ErrNotText):
+			fmt.Printf("  skipped %s (not text)\n", name)
+		case err != nil:
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			failed++
+			fmt.Printf("  failed  %s: %v\n", name, err)
+		case info.Unchanged:
+			unchanged++
+		default:
+			indexed++
+			fmt.Printf("  indexed %s (%d chunks)\n", name, info.Chunks)
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Printf("Error indexing %s: %v\n", dir, err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("✓ %d document(s) indexed, %d unchanged", indexed, unchanged)
+	if failed > 0 {
+		fmt.Printf(", %d failed", failed)
+	}
+	fmt.Println()
+	if !cfg.Documents.Enabled {
+		fmt.Println("Note: set documents.enabled so the agent searches them.")
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func indexFile(ctx context.Context, store *documents.Store, businessID, name, path string) (documents.Info, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return documents.Info{}, err
+	}
+	if fi.Size() > documents.MaxDocumentBytes {
+		return documents.Info{}, fmt.Errorf("larger than %d MB", documents.MaxDocumentBytes>>20)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return documents.Info{}, err
+	}
+	text, err := documents.Text(data)
+	if err != nil {
+		return documents.Info{}, err
+	}
+	return store.Add(ctx, businessID, name, text)
+}
+
+// newDocumentStore opens the workspace's document index, embedding with
+// the provider of documents.model.
+func newDocumentStore(cfg *config.Config) (*documents.Store, error) {
+	if cfg.Documents.Model == "" {
+		return nil, errors.New("documents.model is required, a model_name in model_list")
+	}
+	provider, modelID, err := providers.CreateProviderForModel(cfg, cfg.Documents.Model)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := provider.(providers.Embedder); !ok {
+		return nil, fmt.Errorf("the provider of %s does not serve embeddings", cfg.Documents.Model)
+	}
+	return documents.New(cfg.WorkspacePath(), provider, modelID, documents.Options{
+		ChunkSize:    cfg.Documents.ChunkSize,
+		ChunkOverlap: cfg.Documents.ChunkOverlap,
+	}), nil
+}
+
+func indexHelp() {
+	fmt.Println("\nUsage: picoclaw index <dir> [--business <id>]")
+	fmt.Println()
+	fmt.Println("Adds the text files under dir, such as contracts, price lists and policies,")
+	fmt.Println("to the document index the agent searches. Files are embedded with")
+	fmt.Println("documents.model; unchanged files are skipped, so it is cheap to run again.")
+	fmt.Println()
+	fmt.Println("  --business <id>   Index for one business (default: shared by all)")
+}
@@ -0,0 +1,161 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/agent"
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/mcp"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// mcpConnectTimeout bounds starting or reaching an external MCP server and
+// listing its tools.
+const mcpConnectTimeout = 30 * time.Second
+
+// mcpCmd serves the default agent's tools and skills as a Model Context
+// Protocol server on stdin and stdout, for clients such as Claude Desktop
+// that start the server themselves.
+func mcpCmd() {
+	businessID := ""
+	token := os.Getenv("PICOCLAW_MCP_TOKEN")
+
+	args := os.Args[2:]
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--business", "--token":
+			if i+1 >= len(args) {
+				fmt.Printf("%s requires a value\n", args[i])
+				os.Exit(1)
+			}
+			if args[i] == "--business" {
+				businessID = args[i+1]
+			} else {
+				token = args[i+1]
+			}
+			i++
+		case "--help", "-h":
+			mcpHelp()
+			return
+		default:
+			fmt.Printf("Unknown flag: %s\n", args[i])
+			mcpHelp()
+			os.Exit(1)
+		}
+	}
+
+	// Stdout carries the protocol; anything else printed goes to stderr.
+	out := os.Stdout
+	os.Stdout = os.Stderr
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	configureLogging(cfg, false)
+	configureNetwork(cfg)
+	configureMemory(cfg)
+	configureTokenizer(cfg)
+
+	provider, modelID, err := providers.CreateProvider(cfg)
+	if err != nil {
+		fmt.Printf("Error creating provider: %v\n", err)
+		os.Exit(1)
+	}
+	if modelID != "" {
+		cfg.Agents.Defaults.Model = modelID
+	}
+	agentLoop := agent.NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+	closeMCP := connectMCPServers(context.Background(), cfg, agentLoop)
+	defer closeMCP()
+
+	server, err := newMCPServer(cfg, agentLoop)
+	if err != nil {
+		fmt.Printf("Error starting MCP server: %v\n", err)
+		os.Exit(1)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	// Calls run as this user and business unless a call names a business.
+	if token != "" {
+		ctx = context.WithValue(ctx, constants.ContextKeyJWTToken, token)
+	}
+	if businessID != "" {
+		ctx = context.WithValue(ctx, constants.ContextKeyBusinessID, businessID)
+	}
+	if err := server.ServeStdio(ctx, os.Stdin, out); err != nil {
+		fmt.Printf("Error reading stdin: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// newMCPServer offers the default agent's tools, limited to mcp.tools, and
+// skills.
+func newMCPServer(cfg *config.Config, agentLoop *agent.AgentLoop) (*mcp.Server, error) {
+	registry, err := agentLoop.Tools("")
+	if err != nil {
+		return nil, err
+	}
+	loader, err := agentLoop.Skills("")
+	if err != nil {
+		return nil, err
+	}
+	return mcp.NewServer("picoclaw", formatVersion(), registry,
+		mcp.WithSkills(loader),
+		mcp.WithTools(cfg.MCP.Tools)), nil
+}
+
+// connectMCPServers registers the tools of the external MCP servers in
+// mcp.servers with the agent. A server that can't be reached is skipped.
+// The returned function closes the sessions.
+func connectMCPServers(ctx context.Context, cfg *config.Config, agentLoop *agent.AgentLoop) func() {
+	var clients []*mcp.Client
+	for _, server := range cfg.MCP.Servers {
+		connectCtx, cancel := context.WithTimeout(ctx, mcpConnectTimeout)
+		client, err := mcp.Connect(connectCtx, server, formatVersion())
+		if err != nil {
+			cancel()
+			fmt.Printf("Warning: %v\n", err)
+			continue
+		}
+		remoteTools, err := client.Tools(connectCtx)
+		cancel()
+		if err != nil {
+			fmt.Printf("Warning: mcp server %s: listing tools: %v\n", server.Name, err)
+			client.Close()
+			continue
+		}
+		for _, tool := range remoteTools {
+			agentLoop.RegisterTool(tool)
+		}
+		clients = append(clients, client)
+		fmt.Printf("✓ %d tool(s) from MCP server %s\n", len(remoteTools), server.Name)
+	}
+	return func() {
+		for _, client := range clients {
+			client.Close()
+		}
+	}
+}
+
+func mcpHelp() {
+	fmt.Println("Usage: picoclaw mcp [options]")
+	fmt.Println()
+	fmt.Println("Serves picoclaw's tools and skills to Model Context Protocol clients")
+	fmt.Println("over stdin and stdout. Set mcp.tools in the config to limit the tools.")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --business <id>   Business LedgerForge calls are scoped to")
+	fmt.Println("  --token <jwt>     LedgerForge token to call as (default $PICOCLAW_MCP_TOKEN)")
+}
@@ -4,15 +4,27 @@
 package main
 
 import (
+	"context"
 	"embed"
 	"fmt"
 	"io/fs"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/setup"
 )
 
+// setupPairingCodeEnv passes the setup wizard's pairing code to the gateway
+// it restarts.
+const setupPairingCodeEnv = "PICOCLAW_SETUP_PAIRING_CODE"
+
 //go:generate cp -r ../../workspace .
 //go:embed workspace
 var embeddedFiles embed.FS
@@ -53,6 +65,45 @@ func onboard() {
 	fmt.Println("  2. Chat: picoclaw agent -m \"Hello!\"")
 }
 
+// runSetupWizard serves the setup wizard on the gateway's address until it
+// writes the config, and reports whether the gateway should restart to run
+// with it. The restarted gateway accepts the pairing code the wizard showed.
+func runSetupWizard(cfg *config.Config) bool {
+	wizard := setup.New(getConfigPath())
+	addr := net.JoinHostPort(cfg.Gateway.Host, strconv.Itoa(cfg.Gateway.Port))
+	srv := &http.Server{Addr: addr, Handler: wizard.Handler(), ReadHeaderTimeout: 10 * time.Second}
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.ListenAndServe()
+	}()
+	fmt.Printf("%s No config found. Set up picoclaw at http://%s/setup with setup code %s\n",
+		logo, reachableAddress(addr), wizard.SetupCode())
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+	select {
+	case <-wizard.Done():
+	case err := <-errc:
+		fmt.Printf("Error serving the setup wizard: %v\n", err)
+		os.Exit(1)
+	case <-sigChan:
+		srv.Close()
+		return false
+	}
+
+	// Shutdown lets the wizard's last page reach the browser.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	srv.Shutdown(ctx)
+	if cfg, err := loadConfig(); err == nil {
+		createWorkspaceTemplates(cfg.WorkspacePath())
+	}
+	os.Setenv(setupPairingCodeEnv, wizard.PairingCode())
+	fmt.Println("✓ Config written, restarting")
+	return true
+}
+
 func copyEmbeddedToTarget(targetDir string) error {
 	// Ensure target directory exists
 	if err := os.MkdirAll(targetDir, 0o755); err != nil {
@@ -0,0 +1,176 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/health"
+	"github.com/sipeed/picoclaw/pkg/qr"
+)
+
+func pairCmd() {
+	wait, newCode, showQR := false, false, true
+	var timeout time.Duration
+
+	args := os.Args[2:]
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--wait", "-w":
+			wait = true
+		case "--new":
+			newCode = true
+		case "--no-qr":
+			showQR = false
+		case "--timeout":
+			if i+1 >= len(args) {
+				fmt.Println("--timeout requires a duration, such as 5m")
+				os.Exit(1)
+			}
+			i++
+			d, err := time.ParseDuration(args[i])
+			if err != nil {
+				fmt.Printf("Invalid --timeout: %v\n", err)
+				os.Exit(1)
+			}
+			timeout = d
+		case "--help", "-h":
+			pairHelp()
+			return
+		default:
+			fmt.Printf("Unknown flag: %s\n", args[i])
+			pairHelp()
+			os.Exit(1)
+		}
+	}
+
+	method := http.MethodGet
+	if newCode {
+		method = http.MethodPost
+	}
+	var status health.PairingStatus
+	if err := controlRequest(method, "/pairing", &status); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if status.Code == "" {
+		fmt.Println("The pairing code has been used. Run 'picoclaw pair --new' to pair another device.")
+		os.Exit(1)
+	}
+
+	addr := reachableAddress(status.Address)
+	link := pairingLink(addr, status.ExternalAddress, status.Code)
+	fmt.Printf("🔑 Pairing code: %s\n", status.Code)
+	fmt.Printf("   Gateway:      %s\n", addr)
+	if status.ExternalAddress != "" {
+		fmt.Printf("   Remote:       %s\n", status.ExternalAddress)
+	}
+	fmt.Printf("   Link:         %s\n", link)
+	if showQR {
+		if code, err := qr.Encode(link); err == nil {
+			fmt.Println()
+			fmt.Print(code.Terminal())
+		}
+	}
+	if !wait {
+		return
+	}
+
+	fmt.Println()
+	fmt.Println("Waiting for a device to pair... (Ctrl+C to stop)")
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	if err := waitForPairing(ctx, status.Code); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("✓ Device paired")
+}
+
+// waitForPairing polls the gateway until code is used.
+func waitForPairing(ctx context.Context, code string) error {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if ctx.Err() == context.DeadlineExceeded {
+				return fmt.Errorf("no device paired before the timeout")
+			}
+			return fmt.Errorf("stopped waiting")
+		case <-ticker.C:
+		}
+		var status health.PairingStatus
+		if err := controlRequest(http.MethodGet, "/pairing", &status); err != nil {
+			return err
+		}
+		switch status.Code {
+		case code:
+		case "":
+			return nil
+		default:
+			return fmt.Errorf("the pairing code was replaced; run 'picoclaw pair' again")
+		}
+	}
+}
+
+// pairingLink is what the QR code holds: the gateway address, the address
+// the router forwards to it, if any, and the code a client sends in
+// X-Pairing-Code to POST /pair.
+func pairingLink(addr, remote, code string) string {
+	values := url.Values{"addr": {addr}, "code": {code}}
+	if remote != "" {
+		values.Set("remote", remote)
+	}
+	return "picoclaw://pair?" + values.Encode()
+}
+
+// reachableAddress replaces a wildcard or loopback listen host with this
+// machine's first LAN address, so another device can use it.
+func reachableAddress(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	ip := net.ParseIP(host)
+	wildcard := host == "" || host == "localhost" || ip != nil && (ip.IsUnspecified() || ip.IsLoopback())
+	if !wildcard {
+		return addr
+	}
+	ifaces, err := net.InterfaceAddrs()
+	if err != nil {
+		return addr
+	}
+	for _, a := range ifaces {
+		ipNet, ok := a.(*net.IPNet)
+		if ok && ipNet.IP.To4() != nil && !ipNet.IP.IsLoopback() && !ipNet.IP.IsLinkLocalUnicast() {
+			return net.JoinHostPort(ipNet.IP.String(), port)
+		}
+	}
+	return addr
+}
+
+func pairHelp() {
+	fmt.Println("\nPair options:")
+	fmt.Println("  -w, --wait          Block until a device pairs")
+	fmt.Println("  --timeout <dur>     Give up waiting after this long, such as 5m")
+	fmt.Println("  --new               Issue a new code, e.g. after the current one was used")
+	fmt.Println("  --no-qr             Don't print the QR code")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  picoclaw pair")
+	fmt.Println("  picoclaw pair --new --wait --timeout 5m")
+}
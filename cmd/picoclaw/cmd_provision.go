@@ -0,0 +1,121 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/provision"
+	"github.com/sipeed/picoclaw/pkg/state"
+)
+
+// provisionCmd answers provisioning commands on serial devices until a config
+// is received, for a board's first boot. The gateway can start next.
+func provisionCmd() {
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	baud := cfg.Provision.Baud
+	var devices []string
+
+	args := os.Args[2:]
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--device", "--baud":
+			if i+1 >= len(args) {
+				fmt.Printf("%s requires a value\n", args[i])
+				os.Exit(1)
+			}
+			if args[i] == "--baud" {
+				if baud, err = strconv.Atoi(args[i+1]); err != nil {
+					fmt.Printf("Invalid --baud: %s\n", args[i+1])
+					os.Exit(1)
+				}
+			} else {
+				devices = append(devices, args[i+1])
+			}
+			i++
+		case "--help", "-h":
+			provisionHelp()
+			return
+		default:
+			fmt.Printf("Unknown flag: %s\n", args[i])
+			provisionHelp()
+			os.Exit(1)
+		}
+	}
+	if len(devices) == 0 {
+		devices = cfg.Provision.Devices
+	}
+	if len(devices) == 0 {
+		fmt.Println("No device to listen on; pass --device or set provision.devices")
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	p := newProvisioner(cfg)
+	for _, device := range devices {
+		go p.ServeSerial(ctx, device, baud)
+		fmt.Printf("✓ Waiting for provisioning on %s at %d baud\n", device, baud)
+	}
+	select {
+	case <-p.Configured():
+		fmt.Printf("✓ Config written to %s\n", getConfigPath())
+	case <-ctx.Done():
+	}
+}
+
+// setupProvision keeps answering provisioning commands on provision.devices,
+// if any, and calls configured when a new config is written.
+func setupProvision(ctx context.Context, cfg *config.Config, configured func()) {
+	if len(cfg.Provision.Devices) == 0 {
+		return
+	}
+	p := newProvisioner(cfg)
+	for _, device := range cfg.Provision.Devices {
+		go p.ServeSerial(ctx, device, cfg.Provision.Baud)
+	}
+	go func() {
+		select {
+		case <-p.Configured():
+			configured()
+		case <-ctx.Done():
+		}
+	}()
+	fmt.Printf("✓ Provisioning on %d serial device(s)\n", len(cfg.Provision.Devices))
+}
+
+func newProvisioner(cfg *config.Config) *provision.Provisioner {
+	deviceID, err := state.DeviceID(cfg.WorkspacePath())
+	if err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+	return provision.New(provision.Options{
+		ConfigPath: getConfigPath(),
+		Version:    formatVersion(),
+		DeviceID:   deviceID,
+		WiFi:       provision.SystemWiFi{WPASupplicant: cfg.Provision.WPASupplicant},
+	})
+}
+
+func provisionHelp() {
+	fmt.Println("Usage: picoclaw provision [options]")
+	fmt.Println()
+	fmt.Println("Accepts Wi-Fi credentials and a config over serial devices, such as the")
+	fmt.Println("board's console, a USB gadget port or a Bluetooth LE UART bridge, and")
+	fmt.Println("exits once a config is written.")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --device <path>   Serial device to listen on (repeatable; default provision.devices)")
+	fmt.Println("  --baud <rate>     Baud rate (default provision.baud, 115200)")
+}
@@ -0,0 +1,93 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/agent"
+)
+
+// replayTimeout covers the gateway's own limit on a replay.
+const replayTimeout = 150 * time.Second
+
+func replayCmd() {
+	var requestID string
+	live, asJSON := false, false
+	for _, arg := range os.Args[2:] {
+		switch arg {
+		case "--live":
+			live = true
+		case "--json":
+			asJSON = true
+		case "--help", "-h":
+			replayHelp()
+			return
+		default:
+			if strings.HasPrefix(arg, "-") || requestID != "" {
+				fmt.Printf("Unknown argument: %s\n", arg)
+				replayHelp()
+				os.Exit(1)
+			}
+			requestID = arg
+		}
+	}
+	if requestID == "" {
+		replayHelp()
+		os.Exit(1)
+	}
+
+	var result agent.ReplayResult
+	path := "/replay/" + url.PathEscape(requestID)
+	if err := controlCall(http.MethodPost, path, map[string]any{"live": live}, replayTimeout, &result); err != nil {
+		fmt.Printf("Error replaying %s: %v\n", requestID, err)
+		os.Exit(1)
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(result)
+		return
+	}
+
+	mode := "dry run"
+	if result.Live {
+		mode = "live"
+	}
+	fmt.Printf("Replayed %s from %s (%s, session %s)\n",
+		result.RequestID, result.Time.Local().Format(time.DateTime), mode, result.SessionKey)
+	fmt.Printf("Message: %s\n", result.Message)
+	if result.Media > 0 {
+		fmt.Printf("Note: the original's %d attachment(s) were not replayed\n", result.Media)
+	}
+	if result.Error != "" {
+		fmt.Printf("\nReplay failed: %s\n", result.Error)
+		os.Exit(1)
+	}
+	if !result.Changed {
+		fmt.Println("\nResponse unchanged.")
+		return
+	}
+	fmt.Printf("\n%s", result.Diff)
+}
+
+func replayHelp() {
+	fmt.Println("\nUsage: picoclaw replay <request-id> [--live] [--json]")
+	fmt.Println()
+	fmt.Println("Runs a past request from the event log again on the running gateway, with")
+	fmt.Println("the current model, prompt and skills, and shows how the answer changed.")
+	fmt.Println()
+	fmt.Println("  --live    Run tools for real and continue the original session (default:")
+	fmt.Println("            dry run in a scratch session, tools are not called)")
+	fmt.Println("  --json    Print the result as JSON")
+	fmt.Println()
+	fmt.Println("Needs event_log.record_content, set before the request was made.")
+}
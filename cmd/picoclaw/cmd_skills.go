@@ -25,6 +25,7 @@ func skillsHelp() {
 	fmt.Println("  remove <name>           Remove installed skill")
 	fmt.Println("  search                  Search available skills")
 	fmt.Println("  show <name>             Show skill details")
+	fmt.Println("  new <name> [--lang=L]   Scaffold a new skill (bash, python, node)")
 	fmt.Println()
 	fmt.Println("Examples:")
 	fmt.Println("  picoclaw skills list")
@@ -33,6 +34,7 @@ func skillsHelp() {
 	fmt.Println("  picoclaw skills list-builtin")
 	fmt.Println("  picoclaw skills remove weather")
 	fmt.Println("  picoclaw skills install --registry clawhub github")
+	fmt.Println("  picoclaw skills new invoice-export --lang=python")
 }
 
 func skillsListCmd(loader *skills.SkillsLoader) {
@@ -303,3 +305,41 @@ func skillsShowCmd(loader *skills.SkillsLoader, skillName string) {
 	fmt.Println("----------------------")
 	fmt.Println(content)
 }
+
+func skillsNewCmd(workspace string) {
+	if len(os.Args) < 4 {
+		fmt.Println("Usage: picoclaw skills new <name> [--lang=bash|python|node]")
+		return
+	}
+
+	name := ""
+	lang := "bash"
+	args := os.Args[3:]
+	for i := 0; i < len(args); i++ {
+		switch {
+		case strings.HasPrefix(args[i], "--lang="):
+			lang = strings.TrimPrefix(args[i], "--lang=")
+		case args[i] == "--lang" && i+1 < len(args):
+			lang = args[i+1]
+			i++
+		case name == "":
+			name = args[i]
+		}
+	}
+	if name == "" {
+		fmt.Println("Usage: picoclaw skills new <name> [--lang=bash|python|node]")
+		return
+	}
+
+	created, err := skills.Scaffold(filepath.Join(workspace, "skills"), name, lang)
+	if err != nil {
+		fmt.Printf("✗ Failed to create skill: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("✓ Skill '%s' created:\n", name)
+	for _, path := range created {
+		fmt.Printf("  %s\n", path)
+	}
+	fmt.Println("\nEdit SKILL.md to describe when the agent should use it, then implement the entrypoint.")
+}
@@ -27,6 +27,9 @@ func statusCmd() {
 	}
 	fmt.Println()
 
+	gatewayStatusCmd()
+	fmt.Println()
+
 	if _, err := os.Stat(configPath); err == nil {
 		fmt.Println("Config:", configPath, "✓")
 	} else {
@@ -0,0 +1,165 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/health"
+	"github.com/sipeed/picoclaw/pkg/update"
+)
+
+func updateCmd() {
+	args := os.Args[2:]
+	if len(args) > 0 {
+		switch args[0] {
+		case "keygen":
+			updateKeygenCmd(args[1:])
+			return
+		case "sign":
+			updateSignCmd(args[1:])
+			return
+		}
+	}
+
+	checkOnly, force := false, false
+	for _, arg := range args {
+		switch arg {
+		case "--check":
+			checkOnly = true
+		case "--force":
+			force = true
+		case "--help", "-h":
+			updateHelp()
+			return
+		default:
+			fmt.Printf("Unknown flag: %s\n", arg)
+			updateHelp()
+			os.Exit(1)
+		}
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	configureNetwork(cfg)
+	u, err := update.New(cfg.Update.ManifestURL, cfg.Update.PublicKey)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+	defer cancel()
+
+	if checkOnly {
+		m, err := u.Check(ctx)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if update.Newer(m.Version, version) {
+			fmt.Printf("Update available: %s (installed %s)\n", m.Version, version)
+		} else {
+			fmt.Printf("Up to date: %s (latest release %s)\n", version, m.Version)
+		}
+		return
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	m, installed, err := u.Apply(ctx, exe, version, force)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if !installed {
+		if version == "dev" {
+			fmt.Printf("This is a development build; use --force to install %s.\n", m.Version)
+			return
+		}
+		fmt.Printf("Up to date: %s (latest release %s)\n", version, m.Version)
+		return
+	}
+	fmt.Printf("✓ Installed %s over %s\n", m.Version, exe)
+
+	var status health.DaemonStatus
+	if controlRequest(http.MethodGet, "/status", &status) != nil {
+		return
+	}
+	if err := restartGateway(status); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func updateKeygenCmd(args []string) {
+	if len(args) != 1 {
+		fmt.Println("Usage: picoclaw update keygen <private-key-file>")
+		os.Exit(1)
+	}
+	pub, priv, err := update.GenerateKey()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(args[0], []byte(priv+"\n"), 0o600); err != nil {
+		fmt.Printf("Error writing private key: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✓ Private key written to %s; keep it off your devices.\n", args[0])
+	fmt.Printf("Public key for update.public_key:\n%s\n", pub)
+}
+
+func updateSignCmd(args []string) {
+	if len(args) != 2 {
+		fmt.Println("Usage: picoclaw update sign <manifest.json> <private-key-file>")
+		os.Exit(1)
+	}
+	manifest, err := os.ReadFile(args[0])
+	if err != nil {
+		fmt.Printf("Error reading manifest: %v\n", err)
+		os.Exit(1)
+	}
+	key, err := os.ReadFile(args[1])
+	if err != nil {
+		fmt.Printf("Error reading private key: %v\n", err)
+		os.Exit(1)
+	}
+	sig, err := update.Sign(manifest, string(key))
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(args[0]+".sig", []byte(sig+"\n"), 0o644); err != nil {
+		fmt.Printf("Error writing signature: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✓ Signature written to %s.sig\n", args[0])
+}
+
+func updateHelp() {
+	fmt.Println("\nUpdate options:")
+	fmt.Println("  --check     Only report whether a newer release is available")
+	fmt.Println("  --force     Install the release even if it is not newer")
+	fmt.Println()
+	fmt.Println("Release signing:")
+	fmt.Println("  picoclaw update keygen <private-key-file>")
+	fmt.Println("  picoclaw update sign <manifest.json> <private-key-file>")
+	fmt.Println()
+	fmt.Println("Downloads this platform's binary from update.manifest_url, verifies it against")
+	fmt.Println("update.public_key, replaces this binary and restarts a running gateway.")
+}
@@ -0,0 +1,118 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/voice"
+)
+
+// enrollDuration is how long each wake word recording lasts.
+const enrollDuration = 2 * time.Second
+
+func voiceCmd() {
+	if len(os.Args) < 3 {
+		voiceHelp()
+		return
+	}
+
+	switch os.Args[2] {
+	case "enroll":
+		voiceEnrollCmd(os.Args[3:])
+	default:
+		fmt.Printf("Unknown voice command: %s\n", os.Args[2])
+		voiceHelp()
+	}
+}
+
+func voiceHelp() {
+	fmt.Println("\nVoice commands:")
+	fmt.Println("  enroll [options]   Record the wake word the voice channel listens for")
+	fmt.Println()
+	fmt.Println("Enroll options:")
+	fmt.Println("  --count <n>        Number of recordings to make (default 3)")
+	fmt.Println("  --reset            Delete the existing recordings first")
+	fmt.Println()
+	fmt.Println("Stop the gateway first if the voice channel is using the microphone.")
+	fmt.Println("Example: picoclaw voice enroll --reset")
+}
+
+func voiceEnrollCmd(args []string) {
+	count, reset := 3, false
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--count", "-n":
+			if i+1 >= len(args) {
+				fmt.Println("--count requires a number")
+				os.Exit(1)
+			}
+			i++
+			n, err := strconv.Atoi(args[i])
+			if err != nil || n < 1 {
+				fmt.Printf("Invalid --count: %s\n", args[i])
+				os.Exit(1)
+			}
+			count = n
+		case "--reset":
+			reset = true
+		default:
+			fmt.Printf("Unknown flag: %s\n", args[i])
+			voiceHelp()
+			os.Exit(1)
+		}
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	dir := voice.WakeWordDir(cfg.WorkspacePath())
+	if reset {
+		old, _ := filepath.Glob(filepath.Join(dir, "*.wav"))
+		for _, path := range old {
+			os.Remove(path)
+		}
+	}
+
+	fmt.Printf("Say the wake word after each prompt; each recording lasts %s.\n", enrollDuration)
+	stdin := bufio.NewReader(os.Stdin)
+	for i := 1; i <= count; {
+		fmt.Printf("\nRecording %d of %d: press Enter, then speak.", i, count)
+		stdin.ReadString('\n')
+		fmt.Println("🎙  Listening...")
+		samples, err := voice.Record(context.Background(), cfg.Channels.Voice.CaptureCommand, enrollDuration)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		samples = voice.TrimSilence(samples)
+		if len(samples) < voice.SampleRate/4 {
+			fmt.Println("Too quiet or too short, try again closer to the microphone.")
+			continue
+		}
+		path, err := voice.SaveRecording(dir, samples)
+		if err != nil {
+			fmt.Printf("Error saving recording: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✓ Saved %s\n", path)
+		i++
+	}
+
+	wakeWord, err := voice.LoadWakeWord(dir, cfg.Channels.Voice.WakeWordThreshold)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("\n✓ Wake word enrolled (spread %.2f, threshold %.2f)\n", wakeWord.Spread(), wakeWord.Threshold())
+	fmt.Println("  Lower channels.voice.wake_word_threshold if it triggers by mistake, raise it if it misses you.")
+}
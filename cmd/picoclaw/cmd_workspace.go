@@ -0,0 +1,256 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/backup"
+	"github.com/sipeed/picoclaw/pkg/blob"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/snapshot"
+)
+
+// passphraseEnv supplies the archive passphrase without putting it on the
+// command line.
+const passphraseEnv = "PICOCLAW_EXPORT_PASSPHRASE"
+
+func workspaceCmd() {
+	if len(os.Args) == 3 && os.Args[2] == "snapshot" {
+		workspaceSnapshotCmd()
+		return
+	}
+	if len(os.Args) < 4 {
+		workspaceHelp()
+		return
+	}
+
+	switch os.Args[2] {
+	case "export":
+		workspaceExportCmd(os.Args[3], os.Args[4:])
+	case "import":
+		workspaceImportCmd(os.Args[3], os.Args[4:])
+	default:
+		fmt.Printf("Unknown workspace command: %s\n", os.Args[2])
+		workspaceHelp()
+	}
+}
+
+func workspaceHelp() {
+	fmt.Println("\nWorkspace commands:")
+	fmt.Println("  export <file> [options]   Archive config, state, sessions, skills and media")
+	fmt.Println("  import <file> [options]   Restore an archive or a downloaded snapshot on this device")
+	fmt.Println("  snapshot                  Upload a snapshot to the configured target now")
+	fmt.Println()
+	fmt.Println("Export options:")
+	fmt.Println("  --passphrase <p>    Encrypt secrets instead of masking them (or $" + passphraseEnv + ")")
+	fmt.Println("  --no-media          Leave out uploaded media")
+	fmt.Println("  --media-days <n>    Only include media from the last n days")
+	fmt.Println()
+	fmt.Println("Import options:")
+	fmt.Println("  --passphrase <p>    Decrypt secrets of an encrypted archive, or a snapshot")
+	fmt.Println("  --workspace <dir>   Extract the workspace here instead of the archived path")
+	fmt.Println("  --force             Replace a non-empty workspace")
+	fmt.Println()
+	fmt.Println("Stop the gateway before exporting so the archive is consistent.")
+	fmt.Println("Example: picoclaw workspace export picoclaw-backup.tar.zst --media-days 30")
+}
+
+func workspaceExportCmd(file string, args []string) {
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	opts := backup.ExportOptions{
+		ConfigPath: getConfigPath(),
+		Workspace:  cfg.WorkspacePath(),
+		Release:    formatVersion(),
+		Passphrase: os.Getenv(passphraseEnv),
+	}
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--passphrase":
+			if i+1 < len(args) {
+				opts.Passphrase = args[i+1]
+				i++
+			}
+		case "--no-media":
+			opts.NoMedia = true
+		case "--media-days":
+			if i+1 < len(args) {
+				days, err := strconv.Atoi(args[i+1])
+				if err != nil || days < 0 {
+					fmt.Printf("Invalid --media-days: %s\n", args[i+1])
+					os.Exit(1)
+				}
+				opts.MediaSince = time.Now().AddDate(0, 0, -days)
+				i++
+			}
+		default:
+			fmt.Printf("Unknown flag: %s\n", args[i])
+			workspaceHelp()
+			os.Exit(1)
+		}
+	}
+
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o600)
+	if err != nil {
+		fmt.Printf("Error creating archive: %v\n", err)
+		os.Exit(1)
+	}
+	manifest, err := backup.Export(f, opts)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file)
+		fmt.Printf("Error exporting workspace: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("✓ Exported %d files to %s\n", manifest.Files, file)
+	if manifest.MediaSkipped > 0 {
+		fmt.Printf("  %d media files left out\n", manifest.MediaSkipped)
+	}
+	if manifest.Secrets == "encrypted" {
+		fmt.Println("  Secrets are encrypted; import with the same passphrase.")
+	} else {
+		fmt.Println("  Secrets are masked; re-enter them after import (existing values on the target are kept).")
+	}
+	if manifest.KeyOmitted {
+		fmt.Println("  The media encryption key was left out; export with --passphrase to include it.")
+	}
+}
+
+func workspaceImportCmd(file string, args []string) {
+	opts := backup.ImportOptions{
+		ConfigPath: getConfigPath(),
+		Passphrase: os.Getenv(passphraseEnv),
+	}
+	var workspaceOverride string
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--passphrase":
+			if i+1 < len(args) {
+				opts.Passphrase = args[i+1]
+				i++
+			}
+		case "--workspace":
+			if i+1 < len(args) {
+				workspaceOverride = args[i+1]
+				i++
+			}
+		case "--force":
+			opts.Force = true
+		default:
+			fmt.Printf("Unknown flag: %s\n", args[i])
+			workspaceHelp()
+			os.Exit(1)
+		}
+	}
+
+	var workspace string
+	opts.Workspace = func(data []byte) (string, error) {
+		cfg := config.DefaultConfig()
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return "", fmt.Errorf("invalid config in archive: %w", err)
+		}
+		if workspaceOverride != "" {
+			cfg.Agents.Defaults.Workspace = workspaceOverride
+		}
+		workspace = cfg.WorkspacePath()
+		return workspace, nil
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		fmt.Printf("Error opening archive: %v\n", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+	_, statErr := os.Stat(opts.ConfigPath)
+	hadConfig := statErr == nil
+
+	manifest, err := backup.Import(f, opts)
+	if err != nil {
+		fmt.Printf("Error importing workspace: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✓ Imported %d files into %s\n", manifest.Files, workspace)
+	if hadConfig {
+		fmt.Printf("  Config written to %s (previous config kept as %s.bak)\n", opts.ConfigPath, opts.ConfigPath)
+	} else {
+		fmt.Printf("  Config written to %s\n", opts.ConfigPath)
+	}
+	if workspaceOverride != "" {
+		fmt.Printf("  Set agents.defaults.workspace to %s in the config to use it.\n", workspaceOverride)
+	}
+	if manifest.Secrets != "encrypted" {
+		fmt.Println("  Secrets were masked in the archive; check API keys and tokens in the config.")
+	}
+}
+
+func workspaceSnapshotCmd() {
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	snapshotter, err := newSnapshotter(cfg)
+	if err != nil {
+		fmt.Printf("Error in snapshots config: %v\n", err)
+		os.Exit(1)
+	}
+	name, err := snapshotter.Take(context.Background())
+	if err != nil {
+		fmt.Printf("Error taking snapshot: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✓ Uploaded snapshot %s\n", name)
+}
+
+// newSnapshotter creates the Snapshotter of the snapshots config.
+func newSnapshotter(cfg *config.Config) (*snapshot.Snapshotter, error) {
+	var target snapshot.Target
+	switch sc := cfg.Snapshots; sc.Target {
+	case "s3":
+		store, err := blob.NewS3Store(blob.S3Config{
+			Endpoint:        sc.S3.Endpoint,
+			Region:          sc.S3.Region,
+			Bucket:          sc.S3.Bucket,
+			AccessKeyID:     sc.S3.AccessKeyID,
+			SecretAccessKey: sc.S3.SecretAccessKey,
+			PathStyle:       sc.S3.PathStyle,
+			Prefix:          sc.S3.Prefix,
+		})
+		if err != nil {
+			return nil, err
+		}
+		target = snapshot.NewS3Target(store)
+	case "webdav":
+		t, err := snapshot.NewWebDAVTarget(sc.WebDAV.URL, sc.WebDAV.Username, sc.WebDAV.Password)
+		if err != nil {
+			return nil, err
+		}
+		target = t
+	default:
+		return nil, fmt.Errorf("unknown target %q: expected s3 or webdav", sc.Target)
+	}
+	return snapshot.New(target, snapshot.Options{
+		At:         cfg.Snapshots.Time,
+		Passphrase: cfg.Snapshots.Passphrase,
+		Keep:       cfg.Snapshots.Keep,
+		Media:      cfg.Snapshots.Media,
+		ConfigPath: getConfigPath(),
+		Workspace:  cfg.WorkspacePath(),
+		Release:    formatVersion(),
+	})
+}
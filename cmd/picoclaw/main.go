@@ -7,14 +7,26 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net"
+	"net/http"
 	"os"
 	"path/filepath"
 	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
 
 	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/httpclient"
+	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/skills"
+	"github.com/sipeed/picoclaw/pkg/tokenizer"
 )
 
 var (
@@ -106,16 +118,43 @@ func main() {
 	case "agent":
 		agentCmd()
 	case "gateway":
-		gatewayCmd()
+		if restart := gatewayCmd(); restart {
+			if err := restartProcess(); err != nil {
+				fmt.Printf("Error restarting gateway: %v\n", err)
+				os.Exit(1)
+			}
+		}
 	case "status":
 		statusCmd()
+	case "stop":
+		stopCmd()
+	case "restart":
+		restartCmd()
 	case "migrate":
 		migrateCmd()
 	case "auth":
 		authCmd()
 	case "cron":
 		cronCmd()
-	case "skills":
+	case "workspace":
+		workspaceCmd()
+	case "bench":
+		benchCmd()
+	case "pair":
+		pairCmd()
+	case "mcp":
+		mcpCmd()
+	case "provision":
+		provisionCmd()
+	case "update":
+		updateCmd()
+	case "voice":
+		voiceCmd()
+	case "index":
+		indexCmd()
+	case "replay":
+		replayCmd()
+	case "skills", "skill":
 		if len(os.Args) < 3 {
 			skillsHelp()
 			return
@@ -160,6 +199,8 @@ func main() {
 				return
 			}
 			skillsShowCmd(skillsLoader, os.Args[3])
+		case "new":
+			skillsNewCmd(workspace)
 		default:
 			fmt.Printf("Unknown skills command: %s\n", subcommand)
 			skillsHelp()
@@ -182,10 +223,21 @@ func printHelp() {
 	fmt.Println("  agent       Interact with the agent directly")
 	fmt.Println("  auth        Manage authentication (login, logout, status)")
 	fmt.Println("  gateway     Start picoclaw gateway")
-	fmt.Println("  status      Show picoclaw status")
+	fmt.Println("  pair        Show the gateway's pairing code and QR code")
+	fmt.Println("  status      Show picoclaw and gateway status")
+	fmt.Println("  stop        Stop the running gateway")
+	fmt.Println("  restart     Restart the running gateway")
 	fmt.Println("  cron        Manage scheduled tasks")
 	fmt.Println("  migrate     Migrate from OpenClaw to PicoClaw")
+	fmt.Println("  workspace   Export or import a workspace archive")
 	fmt.Println("  skills      Manage skills (install, list, remove)")
+	fmt.Println("  mcp         Serve tools and skills to MCP clients over stdio")
+	fmt.Println("  provision   Accept Wi-Fi and a config over a serial line")
+	fmt.Println("  bench       Measure request latency and memory use")
+	fmt.Println("  update      Install the latest signed release")
+	fmt.Println("  voice       Record the wake word for the voice channel")
+	fmt.Println("  replay      Run a past request again and compare the answers")
+	fmt.Println("  index       Add documents to the index the agent searches")
 	fmt.Println("  version     Show version information")
 }
 
@@ -194,6 +246,149 @@ func getConfigPath() string {
 	return filepath.Join(home, ".picoclaw", "config.json")
 }
 
+// controlSocketPath is where the gateway serves local control endpoints, such
+// as the pairing code for picoclaw pair.
+func controlSocketPath() string {
+	return filepath.Join(filepath.Dir(getConfigPath()), "picoclaw.sock")
+}
+
+// controlRequest calls an endpoint of the running gateway's control socket
+// and decodes its JSON response into out.
+func controlRequest(method, path string, out any) error {
+	return controlCall(method, path, nil, 10*time.Second, out)
+}
+
+// controlCall is controlRequest with a JSON request body, unless body is
+// nil, and a timeout for slow endpoints.
+func controlCall(method, path string, body any, timeout time.Duration, out any) error {
+	socket := controlSocketPath()
+	client := &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, "http://picoclaw"+path, reqBody)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return fmt.Errorf("gateway did not answer within %s", timeout)
+		}
+		return fmt.Errorf("gateway is not running (no control socket at %s)", socket)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var body struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+		return fmt.Errorf("gateway returned %s: %s", resp.Status, strings.TrimSpace(body.Error))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
 func loadConfig() (*config.Config, error) {
 	return config.LoadConfig(getConfigPath())
 }
+
+// configureNetwork applies the network section of the config to the shared
+// HTTP client.
+func configureNetwork(cfg *config.Config) {
+	err := httpclient.Configure(httpclient.Options{
+		Proxy:               cfg.Network.Proxy,
+		MaxConnsPerHost:     cfg.Network.MaxConnsPerHost,
+		MaxIdleConnsPerHost: cfg.Network.MaxIdleConnsPerHost,
+		IdleConnTimeout:     time.Duration(cfg.Network.IdleConnTimeoutSeconds) * time.Second,
+		LocalOnly:           cfg.Network.Offline,
+	})
+	if err != nil {
+		fmt.Printf("Warning: network: %v, using environment proxy\n", err)
+	}
+	if cfg.Network.Offline {
+		logger.InfoC("network", "Offline mode: connections outside the local network are refused")
+	}
+}
+
+// configureMemory sets the soft heap limit from memory_limit_mb, unless
+// GOMEMLIMIT is set in the environment.
+func configureMemory(cfg *config.Config) {
+	if cfg.MemoryLimitMB <= 0 || os.Getenv("GOMEMLIMIT") != "" {
+		return
+	}
+	debug.SetMemoryLimit(int64(cfg.MemoryLimitMB) << 20)
+}
+
+// configureTokenizer loads the tokenizer_file vocabulary so token counts are
+// exact; without one they are estimated.
+func configureTokenizer(cfg *config.Config) {
+	path := cfg.TokenizerPath()
+	if path == "" {
+		return
+	}
+	enc, err := tokenizer.Load(path)
+	if err != nil {
+		fmt.Printf("Warning: %v, estimating token counts\n", err)
+		return
+	}
+	tokenizer.SetDefault(enc)
+}
+
+// configureLogging applies the logging section of the config. debug forces the
+// global level to DEBUG, as requested with --debug.
+func configureLogging(cfg *config.Config, debug bool) {
+	logCfg := cfg.Logging
+
+	if logCfg.Level != "" {
+		level, err := logger.ParseLevel(logCfg.Level)
+		if err != nil {
+			fmt.Printf("Warning: %v, using info\n", err)
+		}
+		logger.SetLevel(level)
+	}
+	if debug {
+		logger.SetLevel(logger.DEBUG)
+	}
+
+	if logCfg.Format != "" {
+		format, err := logger.ParseFormat(logCfg.Format)
+		if err != nil {
+			fmt.Printf("Warning: %v, using text\n", err)
+		}
+		logger.SetFormat(format)
+	}
+
+	modules := make(map[string]logger.LogLevel, len(logCfg.Modules))
+	for component, name := range logCfg.Modules {
+		level, err := logger.ParseLevel(name)
+		if err != nil {
+			fmt.Printf("Warning: logging.modules.%s: %v\n", component, err)
+			continue
+		}
+		modules[component] = level
+	}
+	logger.SetModuleLevels(modules)
+
+	if logCfg.File != "" {
+		path := logCfg.File
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(cfg.WorkspacePath(), path)
+		}
+		if err := logger.EnableRotatingFileLogging(path, logCfg.Rotation.Options()); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,425 @@
+package agent
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/eventlog"
+	"github.com/sipeed/picoclaw/pkg/i18n"
+	"github.com/sipeed/picoclaw/pkg/ledgerforge"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/telemetry"
+	"github.com/sipeed/picoclaw/pkg/tools"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	// defaultApprovalTimeout is how long a gated call waits without
+	// timeout_minutes.
+	defaultApprovalTimeout = 10 * time.Minute
+	// maxPendingApprovals bounds the calls held at once, so a model stuck
+	// in a loop can't pile them up.
+	maxPendingApprovals = 64
+	// maxApprovalArgs bounds the arguments shown in a confirmation prompt.
+	maxApprovalArgs = 300
+)
+
+// PendingApproval is a tool call held until a user approves or rejects it.
+type PendingApproval struct {
+	ID          string         `json:"id"`
+	Tool        string         `json:"tool"`
+	Arguments   map[string]any `json:"arguments"`
+	Description string         `json:"description"`
+	ExpiresAt   time.Time      `json:"expires_at"`
+
+	agentID    string
+	sessionKey string
+	channel    string
+	chatID     string
+	businessID string
+	jwtToken   string
+	timer      *time.Timer
+}
+
+// approvalPending is the error of a gated call's placeholder result; the
+// run stops once the calls of the response are handled.
+type approvalPending struct {
+	approval *PendingApproval
+}
+
+func (e *approvalPending) Error() string {
+	return "waiting for approval " + e.approval.ID
+}
+
+// approvalGate holds the gated calls until they are decided. See
+// config.ApprovalConfig.
+type approvalGate struct {
+	tools   map[string]bool
+	timeout time.Duration
+	channel string // owner chat, if confirmations go there
+	chatID  string
+	expired func(*PendingApproval)
+
+	mu      sync.Mutex
+	pending map[string]*PendingApproval
+}
+
+func newApprovalGate(cfg config.ApprovalConfig) *approvalGate {
+	g := &approvalGate{
+		tools:   make(map[string]bool, len(cfg.Tools)),
+		timeout: time.Duration(cfg.TimeoutMinutes) * time.Minute,
+		pending: make(map[string]*PendingApproval),
+	}
+	if g.timeout <= 0 {
+		g.timeout = defaultApprovalTimeout
+	}
+	if cfg.Channel != "" && cfg.To != "" {
+		g.channel, g.chatID = cfg.Channel, cfg.To
+	}
+	for _, name := range cfg.Tools {
+		g.tools[strings.TrimSpace(name)] = true
+	}
+	return g
+}
+
+// requires reports whether tc must be approved before it runs. Reading
+// from LedgerForge never does.
+func (g *approvalGate) requires(tc providers.ToolCall) bool {
+	if !g.tools[tc.Name] {
+		return false
+	}
+	if tc.Name == "ledgerforge" {
+		method, _ := tc.Arguments["method"].(string)
+		return !strings.EqualFold(method, "GET")
+	}
+	return true
+}
+
+// hold parks tc until it is decided, or returns an error when too many
+// calls are waiting.
+func (g *approvalGate) hold(
+	ctx context.Context,
+	agentID string,
+	tc providers.ToolCall,
+	opts processOptions,
+) (*PendingApproval, error) {
+	b := make([]byte, 4)
+	rand.Read(b)
+	p := &PendingApproval{
+		ID:          hex.EncodeToString(b),
+		Tool:        tc.Name,
+		Arguments:   tc.Arguments,
+		Description: describeCall(tc),
+		ExpiresAt:   time.Now().Add(g.timeout),
+		agentID:     agentID,
+		sessionKey:  opts.SessionKey,
+		channel:     opts.Channel,
+		chatID:      opts.ChatID,
+		businessID:  ledgerforge.BusinessID(ctx),
+		jwtToken:    ledgerforge.Token(ctx),
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.pending) >= maxPendingApprovals {
+		return nil, errors.New("too many calls are waiting for approval")
+	}
+	g.pending[p.ID] = p
+	p.timer = time.AfterFunc(g.timeout, func() { g.expire(p.ID) })
+	return p, nil
+}
+
+// expire drops call id if nobody decided it in time, and hands it to
+// g.expired so its session learns it wasn't made.
+func (g *approvalGate) expire(id string) {
+	g.mu.Lock()
+	p, ok := g.pending[id]
+	if ok {
+		delete(g.pending, id)
+	}
+	g.mu.Unlock()
+	if !ok {
+		return
+	}
+	logger.InfoCF("agent", "Tool approval expired", map[string]any{"id": id, "tool": p.Tool})
+	if g.expired != nil {
+		g.expired(p)
+	}
+}
+
+// decidable reports whether p can be decided from sessionKey's chat: the
+// owner chat when one is set, or else the session that made the call.
+func (g *approvalGate) decidable(p *PendingApproval, sessionKey string, msg bus.InboundMessage) bool {
+	if g.channel != "" {
+		return msg.Channel == g.channel && msg.ChatID == g.chatID
+	}
+	return p.sessionKey == sessionKey
+}
+
+// waiting returns the calls that can be decided from sessionKey's chat,
+// oldest first.
+func (g *approvalGate) waiting(sessionKey string, msg bus.InboundMessage) []*PendingApproval {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var list []*PendingApproval
+	for _, p := range g.pending {
+		if g.decidable(p, sessionKey, msg) {
+			list = append(list, p)
+		}
+	}
+	slices.SortFunc(list, func(a, b *PendingApproval) int { return a.ExpiresAt.Compare(b.ExpiresAt) })
+	return list
+}
+
+// take removes and returns the call id, if it can be decided from here.
+func (g *approvalGate) take(id, sessionKey string, msg bus.InboundMessage) *PendingApproval {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	p, ok := g.pending[id]
+	if !ok || !g.decidable(p, sessionKey, msg) {
+		return nil
+	}
+	delete(g.pending, id)
+	p.timer.Stop()
+	return p
+}
+
+// describeCall summarizes a call for the user deciding on it.
+func describeCall(tc providers.ToolCall) string {
+	if tc.Name == "ledgerforge" {
+		method, _ := tc.Arguments["method"].(string)
+		path, _ := tc.Arguments["path"].(string)
+		desc := fmt.Sprintf("ledgerforge %s %s", strings.ToUpper(method), path)
+		if body, ok := tc.Arguments["body"]; ok {
+			b, _ := json.Marshal(body)
+			desc += " " + utils.Truncate(string(b), maxApprovalArgs)
+		}
+		return desc
+	}
+	b, _ := json.Marshal(tc.Arguments)
+	return tc.Name + " " + utils.Truncate(string(b), maxApprovalArgs)
+}
+
+// holdToolCall parks a gated call and returns the placeholder result the
+// model sees in its place.
+func (al *AgentLoop) holdToolCall(
+	ctx context.Context,
+	agent *AgentInstance,
+	tc providers.ToolCall,
+	opts processOptions,
+) *tools.ToolResult {
+	p, err := al.approvals.hold(ctx, agent.ID, tc, opts)
+	if err != nil {
+		return tools.ErrorResult(fmt.Sprintf("%s was not called: %v", tc.Name, err)).WithError(err)
+	}
+	logger.InfoCF("agent", "Tool call held for approval",
+		map[string]any{"id": p.ID, "tool": tc.Name, "session_key": opts.SessionKey})
+	result := tools.SilentResult(fmt.Sprintf("%s has not been called yet: it is waiting for the user's approval "+
+		"(request %s). You'll be told the outcome.", tc.Name, p.ID))
+	result.Err = &approvalPending{approval: p}
+	return result
+}
+
+// approvalPrompt returns the confirmation that ends a run whose response
+// made gated calls, or "" if it made none. With an owner chat, the
+// confirmation goes there and the requester is told who was asked.
+func (al *AgentLoop) approvalPrompt(ctx context.Context, opts processOptions, results []*tools.ToolResult) string {
+	var held []*PendingApproval
+	for _, result := range results {
+		var pending *approvalPending
+		if errors.As(result.Err, &pending) {
+			held = append(held, pending.approval)
+		}
+	}
+	if len(held) == 0 {
+		return ""
+	}
+
+	businessID := ledgerforge.BusinessID(ctx)
+	senderID, _ := ctx.Value(constants.ContextKeySenderID).(string)
+	lang := al.locales.Language(businessID, senderID)
+	lines := make([]string, 0, len(held))
+	for _, p := range held {
+		lines = append(lines, fmt.Sprintf("- %s\n  /approve %s | /reject %s", p.Description, p.ID, p.ID))
+	}
+	minutes := int(al.approvals.timeout.Minutes())
+	if al.approvals.channel == "" {
+		return i18n.T(lang, "approval.request", strings.Join(lines, "\n"), minutes)
+	}
+
+	al.bus.PublishOutbound(bus.OutboundMessage{
+		Channel: al.approvals.channel,
+		ChatID:  al.approvals.chatID,
+		Content: i18n.T(al.locales.Language(businessID, ""), "approval.owner_request",
+			opts.Channel+":"+opts.ChatID, strings.Join(lines, "\n"), minutes),
+	})
+	return i18n.T(lang, "approval.waiting_owner")
+}
+
+// approvalCommand approves or rejects a held call. An approved call runs
+// and the agent carries on in the session that made it, with the result; a
+// rejected one is reported to that session instead. With no ID, it decides
+// the only call waiting, or lists them.
+func (al *AgentLoop) approvalCommand(
+	ctx context.Context,
+	sessionKey string,
+	msg bus.InboundMessage,
+	args []string,
+) string {
+	lang := al.language(ctx, msg)
+	if al.approvals == nil {
+		return i18n.T(lang, "approval.unavailable")
+	}
+	approve := args[0] == "/approve"
+
+	var id string
+	switch {
+	case len(args) == 2:
+		id = args[1]
+	case len(args) == 1:
+		waiting := al.approvals.waiting(sessionKey, msg)
+		if len(waiting) == 0 {
+			return i18n.T(lang, "approval.none")
+		}
+		if len(waiting) > 1 {
+			lines := []string{i18n.T(lang, "approval.choose")}
+			for _, p := range waiting {
+				lines = append(lines, fmt.Sprintf("- %s: %s", p.ID, p.Description))
+			}
+			return strings.Join(lines, "\n")
+		}
+		id = waiting[0].ID
+	default:
+		return i18n.T(lang, "approval.usage")
+	}
+	p := al.approvals.take(id, sessionKey, msg)
+	if p == nil {
+		return i18n.T(lang, "approval.not_found", id)
+	}
+
+	decision := "rejected"
+	if approve {
+		decision = "approved"
+	}
+	response, err := al.resumeApproval(ctx, p, decision, msg.Channel+":"+msg.SenderID)
+	if err != nil {
+		return i18n.T(lang, "chat.error", err)
+	}
+	if p.sessionKey == sessionKey {
+		return response
+	}
+	// Decided from the owner chat: the answer belongs to the requester
+	al.tellRequester(p, response)
+	if approve {
+		return i18n.T(lang, "approval.approved", p.ID)
+	}
+	return i18n.T(lang, "approval.rejected", p.ID)
+}
+
+// expireApproval lets the agent answer in the session of a call nobody
+// decided in time, and sends the answer to the chat that asked.
+func (al *AgentLoop) expireApproval(p *PendingApproval) {
+	response, err := al.resumeApproval(context.Background(), p, "expired", "")
+	if err != nil {
+		logger.WarnCF("agent", "Expired approval not reported",
+			map[string]any{"id": p.ID, "session_key": p.sessionKey, "error": err.Error()})
+		return
+	}
+	al.tellRequester(p, response)
+}
+
+// tellRequester sends response to the chat that made p, unless that chat
+// was an HTTP request that has already been answered.
+func (al *AgentLoop) tellRequester(p *PendingApproval, response string) {
+	if response != "" && p.chatID != "" && !constants.IsRequestChannel(p.channel) {
+		al.bus.PublishOutbound(bus.OutboundMessage{Channel: p.channel, ChatID: p.chatID, Content: response})
+	}
+}
+
+// resumeApproval runs an approved call, then lets the agent answer in the
+// call's session with its outcome: "approved", "rejected" or "expired".
+func (al *AgentLoop) resumeApproval(
+	ctx context.Context,
+	p *PendingApproval,
+	decision string,
+	by string,
+) (string, error) {
+	agent, ok := al.registry.GetAgent(p.agentID)
+	if !ok {
+		return "", fmt.Errorf("agent %s is gone", p.agentID)
+	}
+	ctx = ledgerforge.WithAuth(ctx, p.jwtToken, p.businessID)
+
+	message := ""
+	switch decision {
+	case "rejected":
+		message = fmt.Sprintf("[Approval] %s rejected the %s call (request %s), so it was not made. "+
+			"Don't make it again unless asked.", by, p.Tool, p.ID)
+	case "expired":
+		message = fmt.Sprintf("[Approval] The %s call (request %s) expired before anyone decided on it, "+
+			"so it was not made. Tell the user, and don't make it again unless asked.", p.Tool, p.ID)
+	case "approved":
+		start := time.Now()
+		result := agent.Tools.ExecuteWithContext(ctx, p.Tool, p.Arguments, p.channel, p.chatID, nil)
+		event := map[string]any{
+			"tool":        p.Tool,
+			"approval":    p.ID,
+			"duration_ms": time.Since(start).Milliseconds(),
+		}
+		if result.Err != nil {
+			event["error"] = result.Err.Error()
+		}
+		al.recordEvent(ctx, eventlog.Event{
+			Type:    eventlog.TypeToolCalled,
+			AgentID: agent.ID,
+			Channel: p.channel,
+			ChatID:  p.chatID,
+			Data:    event,
+		})
+		message = fmt.Sprintf("[Approval] %s approved the %s call (request %s). Its result:\n%s",
+			by, p.Tool, p.ID, result.ForLLM)
+	}
+	telemetry.AddCounter("picoclaw.tool.approvals", 1,
+		telemetry.String("tool.name", p.Tool), telemetry.String("decision", decision))
+	logger.InfoCF("agent", "Tool approval decided",
+		map[string]any{"id": p.ID, "tool": p.Tool, "decision": decision, "by": by})
+
+	return al.runAgentLoop(ctx, agent, processOptions{
+		SessionKey:      p.sessionKey,
+		Channel:         p.channel,
+		ChatID:          p.chatID,
+		UserMessage:     message,
+		DefaultResponse: "Done.",
+		EnableSummary:   true,
+	})
+}
+
+// PendingApprovals returns the calls of sessionKey waiting for approval,
+// so API clients can offer to decide them.
+func (al *AgentLoop) PendingApprovals(sessionKey string) []PendingApproval {
+	if al.approvals == nil {
+		return nil
+	}
+	al.approvals.mu.Lock()
+	defer al.approvals.mu.Unlock()
+	var list []PendingApproval
+	for _, p := range al.approvals.pending {
+		if p.sessionKey == sessionKey {
+			list = append(list, *p)
+		}
+	}
+	slices.SortFunc(list, func(a, b PendingApproval) int { return a.ExpiresAt.Compare(b.ExpiresAt) })
+	return list
+}
@@ -0,0 +1,212 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// paymentProvider asks to send a payment when told "pay", and otherwise
+// answers with the start of the last user message.
+type paymentProvider struct{}
+
+func (p *paymentProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	last := messages[len(messages)-1]
+	if last.Role == "user" && last.Content == "pay" {
+		return &providers.LLMResponse{ToolCalls: []providers.ToolCall{{
+			ID:        "call-1",
+			Name:      "send_payment",
+			Arguments: map[string]any{"to": "Acme", "amount": 250},
+		}}}, nil
+	}
+	if last.Role == "tool" {
+		return &providers.LLMResponse{Content: "tool said: " + last.Content}, nil
+	}
+	content := last.Content
+	if i := strings.Index(content, " the send_payment"); i > 0 {
+		content = content[:i]
+	}
+	return &providers.LLMResponse{Content: "ack " + content}, nil
+}
+
+func (p *paymentProvider) GetDefaultModel() string {
+	return "mock-model"
+}
+
+type paymentTool struct {
+	calls atomic.Int32
+}
+
+func (t *paymentTool) Name() string               { return "send_payment" }
+func (t *paymentTool) Description() string        { return "Send a payment" }
+func (t *paymentTool) Parameters() map[string]any { return map[string]any{"type": "object"} }
+
+func (t *paymentTool) Execute(ctx context.Context, args map[string]any) *tools.ToolResult {
+	t.calls.Add(1)
+	return tools.SilentResult("paid")
+}
+
+func newApprovalLoop(t *testing.T, approval config.ApprovalConfig) (*AgentLoop, *paymentTool) {
+	t.Helper()
+	approval.Enabled = true
+	approval.Tools = []string{"send_payment"}
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+		Tools: config.ToolsConfig{Approval: approval},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &paymentProvider{})
+	tool := &paymentTool{}
+	al.RegisterTool(tool)
+	return al, tool
+}
+
+func TestApproval_HoldsCallUntilApproved(t *testing.T) {
+	al, tool := newApprovalLoop(t, config.ApprovalConfig{})
+	ctx := context.Background()
+	const session = "agent:test:pay"
+
+	response, err := al.ProcessDirectWithChannel(ctx, "pay", session, "test", "chat")
+	if err != nil {
+		t.Fatalf("ProcessDirectWithChannel() error: %v", err)
+	}
+	pending := al.PendingApprovals(session)
+	if len(pending) != 1 {
+		t.Fatalf("pending approvals = %d, want 1", len(pending))
+	}
+	id := pending[0].ID
+	if !strings.Contains(response, "/approve "+id) || !strings.Contains(response, `"amount":250`) {
+		t.Errorf("confirmation = %q", response)
+	}
+	if n := tool.calls.Load(); n != 0 {
+		t.Fatalf("tool called %d times before approval", n)
+	}
+
+	if got, _ := al.ProcessDirectWithChannel(ctx, "/approve", "agent:test:other", "test", "other"); !strings.Contains(
+		got, "Nothing is waiting") {
+		t.Errorf("approve from another session = %q", got)
+	}
+
+	response, err = al.ProcessDirectWithChannel(ctx, "/approve", session, "test", "chat")
+	if err != nil {
+		t.Fatalf("approve error: %v", err)
+	}
+	if n := tool.calls.Load(); n != 1 {
+		t.Errorf("tool called %d times after approval, want 1", n)
+	}
+	if !strings.HasPrefix(response, "ack [Approval]") || !strings.Contains(response, "approved") {
+		t.Errorf("response after approval = %q", response)
+	}
+	if got, _ := al.ProcessDirectWithChannel(ctx, "/approve "+id, session, "test", "chat"); !strings.Contains(
+		got, "not waiting") {
+		t.Errorf("second approval = %q", got)
+	}
+}
+
+func TestApproval_OwnerChatDecides(t *testing.T) {
+	al, tool := newApprovalLoop(t, config.ApprovalConfig{Channel: "telegram", To: "owner"})
+	ctx := context.Background()
+	const session = "agent:test:pay"
+
+	response, err := al.ProcessDirectWithChannel(ctx, "pay", session, "test", "chat")
+	if err != nil {
+		t.Fatalf("ProcessDirectWithChannel() error: %v", err)
+	}
+	if !strings.Contains(response, "owner's approval") {
+		t.Errorf("requester response = %q", response)
+	}
+	out, ok := al.bus.SubscribeOutbound(ctx)
+	if !ok || out.Channel != "telegram" || out.ChatID != "owner" {
+		t.Fatalf("owner request = %+v", out)
+	}
+	al.bus.SubscribeOutbound(ctx) // the requester's own copy of the response
+	id := al.PendingApprovals(session)[0].ID
+
+	if got, _ := al.ProcessDirectWithChannel(ctx, "/approve "+id, session, "test", "chat"); !strings.Contains(
+		got, "not waiting") {
+		t.Errorf("requester approving = %q", got)
+	}
+	al.bus.SubscribeOutbound(ctx)
+
+	owner := bus.InboundMessage{Channel: "telegram", ChatID: "owner", SenderID: "1", Content: "/reject " + id}
+	response, err = al.processMessage(ctx, owner)
+	if err != nil {
+		t.Fatalf("owner reject error: %v", err)
+	}
+	if !strings.Contains(response, "Rejected request "+id) {
+		t.Errorf("owner response = %q", response)
+	}
+	out, ok = al.bus.SubscribeOutbound(ctx)
+	if !ok || out.ChatID != "chat" || !strings.Contains(out.Content, "rejected") {
+		t.Errorf("requester notice = %+v", out)
+	}
+	if n := tool.calls.Load(); n != 0 {
+		t.Errorf("rejected tool called %d times", n)
+	}
+}
+
+func TestApproval_ExpiredCallIsReported(t *testing.T) {
+	al, tool := newApprovalLoop(t, config.ApprovalConfig{})
+	al.approvals.timeout = 10 * time.Millisecond
+	const session = "agent:test:pay"
+
+	if _, err := al.ProcessDirectWithChannel(context.Background(), "pay", session, "test", "chat"); err != nil {
+		t.Fatalf("ProcessDirectWithChannel() error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for {
+		out, ok := al.bus.SubscribeOutbound(ctx)
+		if !ok {
+			t.Fatal("no notice that the call expired")
+		}
+		if out.ChatID == "chat" && strings.Contains(out.Content, "expired") {
+			break
+		}
+	}
+	if pending := al.PendingApprovals(session); len(pending) != 0 {
+		t.Errorf("pending approvals = %d after expiry, want 0", len(pending))
+	}
+	if n := tool.calls.Load(); n != 0 {
+		t.Errorf("expired tool called %d times", n)
+	}
+}
+
+func TestApprovalGate_Requires(t *testing.T) {
+	g := newApprovalGate(config.ApprovalConfig{Tools: []string{"ledgerforge", "send_payment"}})
+	tests := []struct {
+		name string
+		args map[string]any
+		want bool
+	}{
+		{"ledgerforge", map[string]any{"method": "GET", "path": "/invoices"}, false},
+		{"ledgerforge", map[string]any{"method": "post", "path": "/transactions"}, true},
+		{"send_payment", nil, true},
+		{"read_file", nil, false},
+	}
+	for _, tt := range tests {
+		if got := g.requires(providers.ToolCall{Name: tt.name, Arguments: tt.args}); got != tt.want {
+			t.Errorf("requires(%s %v) = %v, want %v", tt.name, tt.args["method"], got, tt.want)
+		}
+	}
+}
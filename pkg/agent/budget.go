@@ -0,0 +1,57 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sipeed/picoclaw/pkg/budget"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// spendBudget is the budget requests are checked against, with the model
+// they use once it is spent.
+type spendBudget struct {
+	*budget.Budget
+	provider providers.LLMProvider // nil keeps the agent's model
+	model    string
+}
+
+// SetBudget checks requests against b. Past a budget, requests use model
+// through provider, if given; past the ceiling they fail with
+// budget.ErrExceeded.
+func (al *AgentLoop) SetBudget(b *budget.Budget, provider providers.LLMProvider, model string) {
+	al.budget = &spendBudget{Budget: b, provider: provider, model: model}
+}
+
+// applyBudget returns agent as the budget of businessID lets it run: as is,
+// on the fallback model, or not at all.
+func (al *AgentLoop) applyBudget(agent *AgentInstance, businessID string) (*AgentInstance, error) {
+	if al.budget == nil {
+		return agent, nil
+	}
+	status := al.budget.Check(businessID)
+	switch {
+	case status.Level == budget.LevelStop:
+		return nil, fmt.Errorf("%w: %s", budget.ErrExceeded, status)
+	case status.Level == budget.LevelFallback && al.budget.provider != nil:
+		logger.DebugCF("agent", "Budget spent; using the fallback model",
+			map[string]any{"agent_id": agent.ID, "model": al.budget.model, "budget": status.String()})
+		fallback := *agent
+		fallback.Provider = al.budget.provider
+		fallback.Model = al.budget.model
+		fallback.Candidates = nil
+		return &fallback, nil
+	}
+	return agent, nil
+}
+
+// recordSpend adds the cost of an LLM call to the budget.
+func (al *AgentLoop) recordSpend(ctx context.Context, model string, usage *providers.UsageInfo) {
+	if al.budget == nil || usage == nil {
+		return
+	}
+	businessID, _ := ctx.Value(constants.ContextKeyBusinessID).(string)
+	al.budget.Record(businessID, model, usage.PromptTokens, usage.CompletionTokens)
+}
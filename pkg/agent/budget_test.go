@@ -0,0 +1,82 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/budget"
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
+
+// meteredProvider answers with its name and reports a million prompt
+// tokens per call.
+type meteredProvider struct {
+	name  string
+	model string
+}
+
+func (p *meteredProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	p.model = model
+	return &providers.LLMResponse{
+		Content: p.name,
+		Usage:   &providers.UsageInfo{PromptTokens: 1_000_000},
+	}, nil
+}
+
+func (p *meteredProvider) GetDefaultModel() string {
+	return p.name
+}
+
+func TestBudget_FallsBackThenStops(t *testing.T) {
+	workspace := t.TempDir()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         workspace,
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &meteredProvider{name: "usual"})
+	b, err := budget.New(workspace, budget.Options{
+		Global:      budget.Limits{Daily: 2},
+		StopPercent: 150,
+		Prices: map[string]usage.TokenPrice{
+			"test-model": {Prompt: 1},
+			"cheap":      {Prompt: 1},
+		},
+	})
+	if err != nil {
+		t.Fatalf("budget.New: %v", err)
+	}
+	cheap := &meteredProvider{name: "cheap"}
+	al.SetBudget(b, cheap, "cheap")
+
+	ctx := context.Background()
+	for i, want := range []string{"usual", "usual", "cheap"} {
+		got, err := al.ProcessDirectWithChannel(ctx, "hello", "agent:main:a", "cli", "direct")
+		if err != nil || got != want {
+			t.Fatalf("request %d answered %q, %v; want %q", i+1, got, err, want)
+		}
+	}
+	if cheap.model != "cheap" {
+		t.Errorf("fallback model = %q, want cheap", cheap.model)
+	}
+
+	_, err = al.ProcessDirectWithChannel(ctx, "hello", "agent:main:a", "cli", "direct")
+	if !errors.Is(err, budget.ErrExceeded) {
+		t.Fatalf("request past the ceiling: err = %v, want budget.ErrExceeded", err)
+	}
+}
@@ -0,0 +1,157 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/i18n"
+	"github.com/sipeed/picoclaw/pkg/ledgerforge"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// ErrUnknownBusiness is returned when switching to a business the user does
+// not have.
+var ErrUnknownBusiness = errors.New("unknown business")
+
+// businessSessionKey gives each business its own session within a
+// conversation, so history and summaries from one business never reach a
+// run for another.
+func businessSessionKey(sessionKey, businessID string) string {
+	return sessionKey + ":business:" + utils.BusinessDirName(businessID)
+}
+
+// conversationKey identifies the conversation a business is bound to: the
+// caller's session for API requests, otherwise the chat.
+func conversationKey(msg bus.InboundMessage) string {
+	if msg.SessionKey != "" {
+		return msg.SessionKey
+	}
+	return msg.Channel + ":" + msg.ChatID
+}
+
+// withActiveBusiness scopes ctx to the business the conversation switched
+// to, unless the request names a business itself.
+func (al *AgentLoop) withActiveBusiness(ctx context.Context, msg bus.InboundMessage) context.Context {
+	if al.state == nil {
+		return ctx
+	}
+	if id, _ := ctx.Value(constants.ContextKeyBusinessID).(string); id != "" {
+		return ctx
+	}
+	if id := al.state.ActiveBusiness(conversationKey(msg)); id != "" {
+		return context.WithValue(ctx, constants.ContextKeyBusinessID, id)
+	}
+	return ctx
+}
+
+// Businesses lists the businesses the user in ctx can switch to: their
+// LedgerForge businesses when ctx carries a token, otherwise the ones this
+// gateway has seen requests for.
+func (al *AgentLoop) Businesses(ctx context.Context) ([]ledgerforge.Business, error) {
+	if token, _ := ctx.Value(constants.ContextKeyJWTToken).(string); token != "" && al.ledgerForge != nil {
+		return al.ledgerForge.Businesses(ctx)
+	}
+	if al.state == nil {
+		return nil, nil
+	}
+	var businesses []ledgerforge.Business
+	for id := range al.state.GetActiveAuth() {
+		businesses = append(businesses, ledgerforge.Business{ID: id})
+	}
+	sort.Slice(businesses, func(i, j int) bool { return businesses[i].ID < businesses[j].ID })
+	return businesses, nil
+}
+
+// ActiveBusiness returns the business conversation is bound to, or "".
+func (al *AgentLoop) ActiveBusiness(conversation string) string {
+	if al.state == nil {
+		return ""
+	}
+	return al.state.ActiveBusiness(conversation)
+}
+
+// SwitchBusiness binds conversation to one of the user's businesses, so
+// later requests without a business_id, and the receipts in them, use it.
+func (al *AgentLoop) SwitchBusiness(
+	ctx context.Context,
+	conversation, businessID string,
+) (ledgerforge.Business, error) {
+	if al.state == nil {
+		return ledgerforge.Business{}, errors.New("no state to store the business in")
+	}
+	businesses, err := al.Businesses(ctx)
+	if err != nil {
+		return ledgerforge.Business{}, fmt.Errorf("listing businesses: %w", err)
+	}
+	for _, b := range businesses {
+		if b.ID == businessID {
+			if err := al.state.SetActiveBusiness(conversation, businessID); err != nil {
+				return ledgerforge.Business{}, err
+			}
+			return b, nil
+		}
+	}
+	return ledgerforge.Business{}, fmt.Errorf("%w: %s", ErrUnknownBusiness, businessID)
+}
+
+// businessCommand lists the user's businesses, shows the conversation's
+// business, or switches it.
+func (al *AgentLoop) businessCommand(ctx context.Context, msg bus.InboundMessage, args []string) string {
+	lang := al.language(ctx, msg)
+	action := ""
+	if len(args) > 0 {
+		action = args[0]
+	}
+	switch {
+	case action == "":
+		id, _ := ctx.Value(constants.ContextKeyBusinessID).(string)
+		if id == "" {
+			return i18n.T(lang, "business.none_selected")
+		}
+		return i18n.T(lang, "business.current", id)
+
+	case action == "list":
+		businesses, err := al.Businesses(ctx)
+		if err != nil {
+			return i18n.T(lang, "business.list_failed", err)
+		}
+		if len(businesses) == 0 {
+			return i18n.T(lang, "business.none_found")
+		}
+		current, _ := ctx.Value(constants.ContextKeyBusinessID).(string)
+		lines := []string{i18n.T(lang, "business.list")}
+		for _, b := range businesses {
+			line := "- " + businessLabel(b)
+			if b.ID == current {
+				line += i18n.T(lang, "business.active")
+			}
+			lines = append(lines, line)
+		}
+		return strings.Join(lines, "\n")
+
+	case action == "switch" && len(args) == 2:
+		b, err := al.SwitchBusiness(ctx, conversationKey(msg), args[1])
+		if errors.Is(err, ErrUnknownBusiness) {
+			return i18n.T(lang, "business.not_found", args[1])
+		}
+		if err != nil {
+			return i18n.T(lang, "business.switch_failed", err)
+		}
+		return i18n.T(al.locales.Language(b.ID, msg.SenderID), "business.switched", businessLabel(b))
+
+	default:
+		return i18n.T(lang, "business.usage")
+	}
+}
+
+func businessLabel(b ledgerforge.Business) string {
+	if b.Name == "" {
+		return b.ID
+	}
+	return fmt.Sprintf("%s (%s)", b.Name, b.ID)
+}
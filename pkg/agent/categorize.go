@@ -0,0 +1,107 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/categorize"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/i18n"
+)
+
+// CategoryRules returns the rules that categorize a business's expenses,
+// in the order they are tried: those taught in chat, newest first, then the
+// business's configured rules, then those for every business.
+func (al *AgentLoop) CategoryRules(businessID string) []config.CategoryRule {
+	rules := al.LearnedCategoryRules(businessID)
+	rules = append(rules, al.cfg.Receipts.Businesses[businessID].Rules...)
+	return append(rules, al.cfg.Receipts.Rules...)
+}
+
+// LearnedCategoryRules returns the rules a business was taught in chat,
+// newest first.
+func (al *AgentLoop) LearnedCategoryRules(businessID string) []config.CategoryRule {
+	if al.state == nil {
+		return nil
+	}
+	return al.state.CategoryRules(businessID)
+}
+
+// LearnCategoryRule teaches a business a rule, which then goes before all
+// others.
+func (al *AgentLoop) LearnCategoryRule(businessID string, rule config.CategoryRule) error {
+	if al.state == nil {
+		return errors.New("no state to store the rule in")
+	}
+	if err := categorize.Check(rule); err != nil {
+		return err
+	}
+	return al.state.AddCategoryRule(businessID, rule)
+}
+
+// ForgetCategoryRule removes the nth (from 0) rule a business was taught.
+func (al *AgentLoop) ForgetCategoryRule(businessID string, n int) error {
+	if al.state == nil {
+		return errors.New("no state to remove the rule from")
+	}
+	return al.state.RemoveCategoryRule(businessID, n)
+}
+
+// categorizeCommand lists the category rules of the chat's business,
+// teaches it "/categorize Shell as Fuel", or forgets a learned rule.
+func (al *AgentLoop) categorizeCommand(ctx context.Context, msg bus.InboundMessage, args []string) string {
+	lang := al.language(ctx, msg)
+	businessID, _ := al.chatBusiness(ctx, msg)
+	if businessID == "" {
+		return i18n.T(lang, "categorize.no_business")
+	}
+	lang = al.locales.Language(businessID, msg.SenderID)
+
+	as := -1
+	for i, a := range args {
+		if strings.EqualFold(a, "as") {
+			as = i
+		}
+	}
+	switch {
+	case len(args) == 0 || len(args) == 1 && args[0] == "list":
+		learned := al.LearnedCategoryRules(businessID)
+		rules := al.CategoryRules(businessID)
+		if len(rules) == 0 {
+			return i18n.T(lang, "categorize.none")
+		}
+		lines := []string{i18n.T(lang, "categorize.list")}
+		for i, r := range rules {
+			if i < len(learned) {
+				lines = append(lines, fmt.Sprintf("%d. %s", i+1, categorize.Describe(r)))
+			} else {
+				lines = append(lines, "- "+categorize.Describe(r)+i18n.T(lang, "categorize.configured"))
+			}
+		}
+		return strings.Join(lines, "\n")
+
+	case len(args) == 2 && args[0] == "forget":
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			return i18n.T(lang, "categorize.usage")
+		}
+		if err := al.ForgetCategoryRule(businessID, n-1); err != nil {
+			return i18n.T(lang, "categorize.forget_failed", err)
+		}
+		return i18n.T(lang, "categorize.forgot", n)
+
+	case as > 0 && as < len(args)-1:
+		rule := categorize.MerchantRule(strings.Join(args[:as], " "), strings.Join(args[as+1:], " "))
+		if err := al.LearnCategoryRule(businessID, rule); err != nil {
+			return i18n.T(lang, "categorize.save_failed", err)
+		}
+		return i18n.T(lang, "categorize.saved", strings.Join(args[:as], " "), rule.Category)
+
+	default:
+		return i18n.T(lang, "categorize.usage")
+	}
+}
@@ -0,0 +1,63 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestCategorizeCommand(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+		Receipts: config.ReceiptsConfig{
+			Rules: []config.CategoryRule{{Keyword: "parking", Category: "Parking"}},
+		},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &simpleMockProvider{response: "hi"})
+	al.state.SetBusinessAuth("biz-1", "jwt-1", "telegram", "chat-1")
+	send := func(content string) string {
+		reply, _ := al.handleCommand(context.Background(),
+			bus.InboundMessage{Channel: "telegram", ChatID: "chat-1", Content: content})
+		return reply
+	}
+
+	if reply := send("/categorize Shell as Fuel"); reply != "Saved: receipts from Shell are now categorized as Fuel." {
+		t.Errorf("Unexpected reply: %q", reply)
+	}
+	if reply := send("/categorize Home Depot as Office Supplies"); !strings.Contains(reply, "Office Supplies") {
+		t.Errorf("Unexpected reply: %q", reply)
+	}
+	want := "Category rules, tried in this order:\n" +
+		"1. merchant Home Depot → Office Supplies\n" +
+		"2. merchant Shell → Fuel\n" +
+		"- text with \"parking\" → Parking (configured)"
+	if reply := send("/categorize"); reply != want {
+		t.Errorf("Unexpected list:\n%s", reply)
+	}
+	if rules := al.CategoryRules("biz-1"); len(rules) != 3 || rules[1].Category != "Fuel" {
+		t.Errorf("Expected the learned rules before the configured one, got %v", rules)
+	}
+
+	if reply := send("/categorize forget 1"); reply != "Rule 1 forgotten." {
+		t.Errorf("Unexpected reply: %q", reply)
+	}
+	if reply := send("/categorize forget 3"); !strings.Contains(reply, "no rule 3") {
+		t.Errorf("Expected a missing rule to be refused, got %q", reply)
+	}
+	if reply := send("/categorize Shell"); !strings.HasPrefix(reply, "Usage") {
+		t.Errorf("Expected usage, got %q", reply)
+	}
+	if rules := al.LearnedCategoryRules("biz-1"); len(rules) != 1 || rules[0].Category != "Fuel" {
+		t.Errorf("Expected only the Shell rule left, got %v", rules)
+	}
+}
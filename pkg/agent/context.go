@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/sipeed/picoclaw/pkg/locale"
 	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/providers"
 	"github.com/sipeed/picoclaw/pkg/skills"
@@ -19,8 +20,15 @@ type ContextBuilder struct {
 	skillsLoader *skills.SkillsLoader
 	memory       *MemoryStore
 	tools        *tools.ToolRegistry // Direct reference to tool registry
+	skillMatrix  skills.EnablementMatrix
+	skillLimit   int // skills described in full per request; 0 means all
+	locales      *locale.Resolver
 }
 
+// routingUserMessages is how many recent user messages, besides the current
+// one, decide which skills are relevant, so follow-ups keep their skill.
+const routingUserMessages = 3
+
 func getGlobalConfigDir() string {
 	home, err := os.UserHomeDir()
 	if err != nil {
@@ -48,9 +56,27 @@ func (cb *ContextBuilder) SetToolsRegistry(registry *tools.ToolRegistry) {
 	cb.tools = registry
 }
 
-func (cb *ContextBuilder) getIdentity() string {
+// SetSkillMatrix restricts which skills are advertised to each business.
+func (cb *ContextBuilder) SetSkillMatrix(matrix skills.EnablementMatrix) {
+	cb.skillMatrix = matrix
+}
+
+// SetSkillPromptLimit describes only the n skills most relevant to each
+// request in the system prompt and lists the rest by name. Zero describes all.
+func (cb *ContextBuilder) SetSkillPromptLimit(n int) {
+	cb.skillLimit = n
+}
+
+// SetLocales tells the model each business's locale, currency and date
+// format. Without it the prompt doesn't mention them.
+func (cb *ContextBuilder) SetLocales(r *locale.Resolver) {
+	cb.locales = r
+}
+
+func (cb *ContextBuilder) getIdentity(memory *MemoryStore) string {
 	now := time.Now().Format("2006-01-02 15:04 (Monday)")
 	workspacePath, _ := filepath.Abs(filepath.Join(cb.workspace))
+	memoryPath, _ := filepath.Abs(memory.memoryDir)
 	runtime := fmt.Sprintf("%s %s, Go %s", runtime.GOOS, runtime.GOARCH, runtime.Version())
 
 	// Build tools section dynamically
@@ -71,8 +97,8 @@ You are Oluto, an AI-powered financial assistant for Canadian small businesses.
 
 ## Workspace
 Your workspace is at: %s
-- Memory: %s/memory/MEMORY.md
-- Daily Notes: %s/memory/YYYYMM/YYYYMMDD.md
+- Memory: %s/MEMORY.md
+- Daily Notes: %s/YYYYMM/YYYYMMDD.md
 - Skills: %s/skills/{skill-name}/SKILL.md
 %s
 
@@ -84,8 +110,8 @@ Your workspace is at: %s
 
 2. **Be helpful and accurate** - When using tools, briefly explain what you're doing.
 
-3. **Memory** - When interacting with me if something seems memorable, update %s/memory/MEMORY.md`,
-		now, runtime, workspacePath, workspacePath, workspacePath, workspacePath, symlinksSection, toolsSection, workspacePath)
+3. **Memory** - When interacting with me if something seems memorable, update %s/MEMORY.md`,
+		now, runtime, workspacePath, memoryPath, memoryPath, workspacePath, symlinksSection, toolsSection, memoryPath)
 }
 
 func (cb *ContextBuilder) buildToolsSection() string {
@@ -136,10 +162,32 @@ func (cb *ContextBuilder) buildSymlinksSection() string {
 }
 
 func (cb *ContextBuilder) BuildSystemPrompt() string {
+	return cb.BuildSystemPromptForBusiness("")
+}
+
+// BuildSystemPromptForBusiness builds the system prompt, listing only the
+// skills enabled for businessID.
+func (cb *ContextBuilder) BuildSystemPromptForBusiness(businessID string) string {
+	return cb.buildSystemPrompt(businessID, "")
+}
+
+// buildSystemPrompt builds the system prompt for a request. With a query,
+// only the skills most relevant to it are described in full.
+func (cb *ContextBuilder) buildSystemPrompt(businessID, query string) string {
 	parts := []string{}
 
+	// A business's runs only see that business's memory
+	memory := cb.memory
+	if businessID != "" {
+		memory = NewBusinessMemoryStore(cb.workspace, businessID)
+	}
+
 	// Core identity section
-	parts = append(parts, cb.getIdentity())
+	parts = append(parts, cb.getIdentity(memory))
+
+	if cb.locales.Configured() {
+		parts = append(parts, cb.locales.For(businessID).PromptSection(time.Now()))
+	}
 
 	// Bootstrap files
 	bootstrapContent := cb.LoadBootstrapFiles()
@@ -148,17 +196,21 @@ func (cb *ContextBuilder) BuildSystemPrompt() string {
 	}
 
 	// Skills - show summary, AI can read full content with read_file tool
-	skillsSummary := cb.skillsLoader.BuildSkillsSummary()
+	limit := cb.skillLimit
+	if query == "" {
+		limit = 0
+	}
+	skillsSummary := cb.skillsLoader.BuildSkillsSummaryForRequest(businessID, cb.skillMatrix, query, limit)
 	if skillsSummary != "" {
-		parts = append(parts, fmt.Sprintf(`# Skills
-
-The following skills extend your capabilities. To use a skill, read its SKILL.md file using the read_file tool.
-
-%s`, skillsSummary))
+		intro := "The following skills extend your capabilities. To use a skill, read its SKILL.md file using the read_file tool."
+		if strings.Contains(skillsSummary, "<other_skills>") {
+			intro += " Skills under <other_skills> are not described here; read their SKILL.md if the request may need them."
+		}
+		parts = append(parts, fmt.Sprintf("# Skills\n\n%s\n\n%s", intro, skillsSummary))
 	}
 
 	// Memory context
-	memoryContext := cb.memory.GetMemoryContext()
+	memoryContext := memory.GetMemoryContext()
 	if memoryContext != "" {
 		parts = append(parts, "# Memory\n\n"+memoryContext)
 	}
@@ -191,11 +243,11 @@ func (cb *ContextBuilder) BuildMessages(
 	summary string,
 	currentMessage string,
 	media []string,
-	channel, chatID string,
+	channel, chatID, businessID string,
 ) []providers.Message {
 	messages := []providers.Message{}
 
-	systemPrompt := cb.BuildSystemPrompt()
+	systemPrompt := cb.buildSystemPrompt(businessID, routingQuery(history, currentMessage))
 
 	// Add Current Session info if provided
 	if channel != "" && chatID != "" {
@@ -243,6 +295,18 @@ func (cb *ContextBuilder) BuildMessages(
 	return messages
 }
 
+// routingQuery joins the current message with the last few user messages of
+// history, for choosing the skills to describe.
+func routingQuery(history []providers.Message, currentMessage string) string {
+	parts := []string{currentMessage}
+	for i := len(history) - 1; i >= 0 && len(parts) <= routingUserMessages; i-- {
+		if history[i].Role == "user" {
+			parts = append(parts, history[i].Content)
+		}
+	}
+	return strings.TrimSpace(strings.Join(parts, "\n"))
+}
+
 func sanitizeHistoryForProvider(history []providers.Message) []providers.Message {
 	if len(history) == 0 {
 		return history
@@ -334,6 +398,16 @@ func (cb *ContextBuilder) loadSkills() string {
 	return "# Skill Definitions\n\n" + content
 }
 
+// ListSkills returns all skills visible to this agent.
+func (cb *ContextBuilder) ListSkills() []skills.SkillInfo {
+	return cb.skillsLoader.ListSkills()
+}
+
+// SkillsLoader returns the loader of the skills visible to this agent.
+func (cb *ContextBuilder) SkillsLoader() *skills.SkillsLoader {
+	return cb.skillsLoader
+}
+
 // GetSkillsInfo returns information about loaded skills.
 func (cb *ContextBuilder) GetSkillsInfo() map[string]any {
 	allSkills := cb.skillsLoader.ListSkills()
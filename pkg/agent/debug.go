@@ -0,0 +1,202 @@
+package agent
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/logrotate"
+)
+
+// Debug trace events written while a session is in debug mode.
+const (
+	debugEventLLMRequest  = "llm_request"
+	debugEventLLMResponse = "llm_response"
+	debugEventToolCall    = "tool_call"
+	debugEventToolResult  = "tool_result"
+)
+
+// debugEntry is one line of a session debug trace.
+type debugEntry struct {
+	Timestamp  time.Time `json:"timestamp"`
+	SessionKey string    `json:"session_key"`
+	Event      string    `json:"event"`
+	Iteration  int       `json:"iteration,omitempty"`
+	Data       any       `json:"data"`
+}
+
+// DebugSession describes a session that has a debug trace on disk.
+type DebugSession struct {
+	AgentID    string    `json:"agent_id"`
+	SessionKey string    `json:"session_key"`
+	Enabled    bool      `json:"enabled"`
+	Bytes      int64     `json:"bytes"`
+	Updated    time.Time `json:"updated"`
+}
+
+// debugFileName maps a session key to a safe file name under <workspace>/debug.
+func debugFileName(sessionKey string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '.':
+			return r
+		}
+		return '_'
+	}, sessionKey)
+	return strings.TrimLeft(name, ".") + ".jsonl"
+}
+
+func debugFilePath(workspace, sessionKey string) string {
+	return filepath.Join(workspace, "debug", debugFileName(sessionKey))
+}
+
+// setSessionDebug turns debug mode on or off for one session.
+func (al *AgentLoop) setSessionDebug(agent *AgentInstance, sessionKey string, on bool) {
+	if agent.Sessions.IsDebug(sessionKey) == on {
+		return
+	}
+	agent.Sessions.SetDebug(sessionKey, on)
+	agent.Sessions.Save(sessionKey)
+	logger.InfoCF("agent", "Session debug mode changed",
+		map[string]any{"agent_id": agent.ID, "session_key": sessionKey, "debug": on})
+}
+
+// traceDebug appends an event to the session's debug trace if debug mode is
+// on for that session. Traces hold full prompts and tool IO, so they stay in
+// the agent workspace and are only exported on request.
+func (al *AgentLoop) traceDebug(agent *AgentInstance, sessionKey, event string, iteration int, data any) {
+	if !agent.Sessions.IsDebug(sessionKey) {
+		return
+	}
+
+	line, err := json.Marshal(debugEntry{
+		Timestamp:  time.Now().UTC(),
+		SessionKey: sessionKey,
+		Event:      event,
+		Iteration:  iteration,
+		Data:       data,
+	})
+	if err != nil {
+		logger.WarnCF("agent", "Failed to encode debug trace", map[string]any{"error": err.Error()})
+		return
+	}
+
+	var rotation logrotate.Options
+	if al.cfg != nil {
+		rotation = al.cfg.Logging.Rotation.Options()
+	}
+	w, err := logrotate.Open(debugFilePath(agent.Workspace, sessionKey), rotation)
+	if err == nil {
+		_, err = w.Write(append(line, '\n'))
+		w.Close()
+	}
+	if err != nil {
+		logger.WarnCF("agent", "Failed to write debug trace",
+			map[string]any{"session_key": sessionKey, "error": err.Error()})
+	}
+}
+
+func (al *AgentLoop) resolveAgent(agentID string) (*AgentInstance, error) {
+	if agentID == "" {
+		if agent := al.registry.GetDefaultAgent(); agent != nil {
+			return agent, nil
+		}
+		return nil, fmt.Errorf("no default agent configured")
+	}
+	agent, ok := al.registry.GetAgent(agentID)
+	if !ok {
+		return nil, fmt.Errorf("agent %q not found", agentID)
+	}
+	return agent, nil
+}
+
+// DebugSessions lists sessions of an agent that have a debug trace.
+// An empty agentID lists the default agent.
+func (al *AgentLoop) DebugSessions(agentID string) ([]DebugSession, error) {
+	agent, err := al.resolveAgent(agentID)
+	if err != nil {
+		return nil, err
+	}
+
+	paths, err := filepath.Glob(filepath.Join(agent.Workspace, "debug", "*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	sessions := []DebugSession{}
+	for _, path := range paths {
+		files, err := logrotate.Files(path)
+		if err != nil || len(files) == 0 {
+			continue
+		}
+		// Backups match the glob too; only a current file maps back to its key.
+		key := firstDebugSessionKey(files[len(files)-1])
+		if key == "" || debugFileName(key) != filepath.Base(path) {
+			continue
+		}
+		ds := DebugSession{AgentID: agent.ID, SessionKey: key, Enabled: agent.Sessions.IsDebug(key)}
+		for _, f := range files {
+			if info, err := os.Stat(f); err == nil {
+				ds.Bytes += info.Size()
+				if info.ModTime().After(ds.Updated) {
+					ds.Updated = info.ModTime()
+				}
+			}
+		}
+		sessions = append(sessions, ds)
+	}
+	return sessions, nil
+}
+
+func firstDebugSessionKey(path string) string {
+	r, err := logrotate.OpenReader(path)
+	if err != nil {
+		return ""
+	}
+	defer r.Close()
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	if !scanner.Scan() {
+		return ""
+	}
+	var entry debugEntry
+	if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+		return ""
+	}
+	return entry.SessionKey
+}
+
+// ExportSessionDebug writes a session's debug trace, oldest entry first, to w.
+// It returns os.ErrNotExist if the session has no trace.
+func (al *AgentLoop) ExportSessionDebug(agentID, sessionKey string, w io.Writer) error {
+	agent, err := al.resolveAgent(agentID)
+	if err != nil {
+		return err
+	}
+
+	files, err := logrotate.Files(debugFilePath(agent.Workspace, sessionKey))
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return os.ErrNotExist
+	}
+	for _, path := range files {
+		r, err := logrotate.OpenReader(path)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(w, r)
+		r.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// VariantControl labels the requests outside the experiment.
+const VariantControl = "control"
+
+// correctionPattern matches a user message that corrects the previous
+// answer, such as "no, I meant last month" or "that's wrong".
+var correctionPattern = regexp.MustCompile(`(?i)^\W*(no|nope|wrong|incorrect|actually|i meant|i said|not what i|` +
+	`that'?s (not|wrong|incorrect)|that is (not|wrong|incorrect)|you('re| are) wrong)\b`)
+
+// Experiment serves an alternative model or system prompt to part of the
+// traffic; see config.ExperimentConfig.
+type Experiment struct {
+	name       string
+	percent    float64
+	businesses map[string]bool
+	provider   providers.LLMProvider // nil keeps each agent's model
+	model      string
+	prompt     string
+}
+
+// NewExperiment returns the experiment cfg describes. provider and model
+// serve the variant; pass nil and "" to keep each agent's model.
+func NewExperiment(cfg config.ExperimentConfig, provider providers.LLMProvider, model string) (*Experiment, error) {
+	name := strings.TrimSpace(cfg.Name)
+	if name == "" {
+		return nil, errors.New("experiment needs a name")
+	}
+	if name == VariantControl {
+		return nil, fmt.Errorf("experiment can't be named %q", VariantControl)
+	}
+	if cfg.Percent < 0 || cfg.Percent > 100 {
+		return nil, fmt.Errorf("experiment percent %v is not between 0 and 100", cfg.Percent)
+	}
+	prompt := strings.TrimSpace(cfg.Prompt)
+	if provider == nil && prompt == "" {
+		return nil, errors.New("experiment changes neither the model nor the prompt")
+	}
+	businesses := make(map[string]bool, len(cfg.Businesses))
+	for _, id := range cfg.Businesses {
+		businesses[id] = true
+	}
+	return &Experiment{
+		name:       name,
+		percent:    cfg.Percent,
+		businesses: businesses,
+		provider:   provider,
+		model:      model,
+		prompt:     prompt,
+	}, nil
+}
+
+// Name returns the label of the variant.
+func (e *Experiment) Name() string {
+	return e.name
+}
+
+// Variant returns the side of the experiment a session is on: the variant's
+// name or VariantControl. A session keeps its side for as long as the
+// experiment is configured the same way.
+func (e *Experiment) Variant(sessionKey, businessID string) string {
+	if businessID != "" && e.businesses[businessID] {
+		return e.name
+	}
+	h := fnv.New32a()
+	h.Write([]byte(sessionKey))
+	if float64(h.Sum32()%10000) < e.percent*100 {
+		return e.name
+	}
+	return VariantControl
+}
+
+// apply returns agent as the variant runs it, with the variant's model in
+// place of the agent's and its fallbacks.
+func (e *Experiment) apply(agent *AgentInstance) *AgentInstance {
+	if e.provider == nil {
+		return agent
+	}
+	variant := *agent
+	variant.Provider = e.provider
+	variant.Model = e.model
+	variant.Candidates = nil
+	return &variant
+}
+
+// SetExperiment routes part of the traffic to e's model or prompt.
+func (al *AgentLoop) SetExperiment(e *Experiment) {
+	al.experiment = e
+}
+
+// experimentPrompt appends the experiment's prompt to the system prompt of a
+// request in the variant.
+func (al *AgentLoop) experimentPrompt(ctx context.Context, messages []providers.Message) []providers.Message {
+	variant, _ := ctx.Value(constants.ContextKeyVariant).(string)
+	if al.experiment == nil || al.experiment.prompt == "" || variant != al.experiment.name {
+		return messages
+	}
+	if len(messages) > 0 && messages[0].Role == "system" {
+		messages[0].Content += "\n\n" + al.experiment.prompt
+	}
+	return messages
+}
+
+// isCorrection reports whether a user message corrects the previous answer.
+func isCorrection(message string) bool {
+	return correctionPattern.MatchString(message)
+}
+
+type variantSlotKey struct{}
+
+// withVariantSlot returns ctx with room for the experiment variant that
+// serves the request, for the caller to tag its response with.
+func withVariantSlot(ctx context.Context) context.Context {
+	return context.WithValue(ctx, variantSlotKey{}, new(string))
+}
+
+// setVariant tags the request in ctx with variant, in ctx for the calls it
+// makes and in the caller's slot.
+func setVariant(ctx context.Context, variant string) context.Context {
+	if slot, ok := ctx.Value(variantSlotKey{}).(*string); ok {
+		*slot = variant
+	}
+	return context.WithValue(ctx, constants.ContextKeyVariant, variant)
+}
+
+// slotVariant returns the variant recorded in ctx's slot, or "".
+func slotVariant(ctx context.Context) string {
+	if slot, ok := ctx.Value(variantSlotKey{}).(*string); ok {
+		return *slot
+	}
+	return ""
+}
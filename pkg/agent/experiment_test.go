@@ -0,0 +1,168 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// promptRecorder answers with its name and keeps the last system prompt and
+// model it was called with.
+type promptRecorder struct {
+	name   string
+	model  string
+	system string
+}
+
+func (p *promptRecorder) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	p.model = model
+	p.system = messages[0].Content
+	return &providers.LLMResponse{Content: p.name}, nil
+}
+
+func (p *promptRecorder) GetDefaultModel() string {
+	return p.name
+}
+
+func TestNewExperiment_Validates(t *testing.T) {
+	provider := &promptRecorder{}
+	tests := []struct {
+		name string
+		cfg  config.ExperimentConfig
+	}{
+		{"no name", config.ExperimentConfig{Prompt: "Be brief."}},
+		{"control", config.ExperimentConfig{Name: VariantControl, Prompt: "Be brief."}},
+		{"percent", config.ExperimentConfig{Name: "brief", Percent: 120, Prompt: "Be brief."}},
+		{"no change", config.ExperimentConfig{Name: "brief", Percent: 10}},
+	}
+	for _, tt := range tests {
+		if _, err := NewExperiment(tt.cfg, nil, ""); err == nil {
+			t.Errorf("%s: NewExperiment succeeded, want an error", tt.name)
+		}
+	}
+	if _, err := NewExperiment(config.ExperimentConfig{Name: "mini", Percent: 10}, provider, "mini"); err != nil {
+		t.Errorf("NewExperiment with a model: %v", err)
+	}
+}
+
+func TestExperiment_Variant(t *testing.T) {
+	e, err := NewExperiment(config.ExperimentConfig{
+		Name:       "brief",
+		Percent:    30,
+		Businesses: []string{"biz-1"},
+		Prompt:     "Be brief.",
+	}, nil, "")
+	if err != nil {
+		t.Fatalf("NewExperiment: %v", err)
+	}
+
+	const sessions = 10000
+	inVariant := 0
+	for i := 0; i < sessions; i++ {
+		key := fmt.Sprintf("agent:main:telegram:%d", i)
+		variant := e.Variant(key, "")
+		if variant != e.Variant(key, "") {
+			t.Fatalf("session %s changed variant", key)
+		}
+		if variant == "brief" {
+			inVariant++
+		}
+	}
+	if inVariant < sessions*25/100 || inVariant > sessions*35/100 {
+		t.Errorf("%d of %d sessions in the variant, want about 30%%", inVariant, sessions)
+	}
+
+	for i := 0; i < 100; i++ {
+		if got := e.Variant(fmt.Sprintf("session-%d", i), "biz-1"); got != "brief" {
+			t.Fatalf("listed business got %q, want the variant", got)
+		}
+	}
+
+	e.percent = 0
+	if got := e.Variant("agent:main:telegram:1", "biz-2"); got != VariantControl {
+		t.Errorf("Variant at 0%% = %q, want %q", got, VariantControl)
+	}
+}
+
+func TestExperiment_RoutesVariantSessions(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	control := &promptRecorder{name: "control"}
+	variant := &promptRecorder{name: "variant"}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), control)
+	e, err := NewExperiment(config.ExperimentConfig{
+		Name:       "mini",
+		Businesses: []string{"biz-1"},
+		Prompt:     "Answer in one sentence.",
+	}, variant, "mini-model")
+	if err != nil {
+		t.Fatalf("NewExperiment: %v", err)
+	}
+	al.SetExperiment(e)
+
+	ctx := context.Background()
+	got, err := al.ProcessDirectWithChannel(ctx, "hello", "agent:main:a", "cli", "direct")
+	if err != nil || got != "control" {
+		t.Fatalf("control session answered %q, %v", got, err)
+	}
+	if strings.Contains(control.system, "Answer in one sentence.") {
+		t.Error("control request got the variant prompt")
+	}
+
+	bizCtx := context.WithValue(ctx, constants.ContextKeyBusinessID, "biz-1")
+	got, err = al.ProcessDirectWithChannel(bizCtx, "hello", "agent:main:b", "cli", "direct")
+	if err != nil || got != "variant" {
+		t.Fatalf("variant session answered %q, %v", got, err)
+	}
+	if variant.model != "mini-model" {
+		t.Errorf("variant model = %q, want %q", variant.model, "mini-model")
+	}
+	if !strings.HasSuffix(variant.system, "\n\nAnswer in one sentence.") {
+		t.Errorf("variant system prompt doesn't end with the experiment prompt: %q", variant.system)
+	}
+}
+
+func TestIsCorrection(t *testing.T) {
+	for _, msg := range []string{
+		"No, I meant last month",
+		"that's wrong",
+		"That is not what I asked",
+		"Actually it was 40 dollars",
+		"I meant the other invoice",
+		"not what i wanted",
+	} {
+		if !isCorrection(msg) {
+			t.Errorf("isCorrection(%q) = false, want true", msg)
+		}
+	}
+	for _, msg := range []string{
+		"Thanks!",
+		"Now show me March",
+		"Nobody paid yet?",
+		"What is the total?",
+	} {
+		if isCorrection(msg) {
+			t.Errorf("isCorrection(%q) = true, want false", msg)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/federation"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// SetFederation delegates the requests matching r's rules to its peers.
+func (al *AgentLoop) SetFederation(r *federation.Router) {
+	al.federation = r
+}
+
+// delegate sends msg to the peer its rules pick and records the exchange in
+// the local session, so the conversation continues here as usual. It reports
+// false when msg is not delegated or the peer fails, for msg to be answered
+// locally.
+func (al *AgentLoop) delegate(
+	ctx context.Context,
+	agent *AgentInstance,
+	sessionKey string,
+	msg bus.InboundMessage,
+) (string, bool) {
+	if al.federation == nil {
+		return "", false
+	}
+	peer, ok := al.federation.Route(msg.Channel, msg.Content, len(msg.Media))
+	if !ok {
+		return "", false
+	}
+	req := federation.Request{
+		Session: sessionKey,
+		Message: msg.Content,
+		Media:   msg.Media,
+	}
+	req.BusinessID, _ = ctx.Value(constants.ContextKeyBusinessID).(string)
+	req.UserToken, _ = ctx.Value(constants.ContextKeyJWTToken).(string)
+
+	response, err := al.federation.Delegate(ctx, peer, req)
+	if err != nil {
+		logger.WarnCF("agent", "Delegation failed, answering locally", map[string]any{
+			"peer":        peer.Name,
+			"session_key": sessionKey,
+			"error":       err.Error(),
+		})
+		return "", false
+	}
+	logger.InfoCF("agent", "Delegated request", map[string]any{
+		"peer":        peer.Name,
+		"session_key": sessionKey,
+	})
+	agent.Sessions.AddMessage(sessionKey, "user", msg.Content)
+	agent.Sessions.AddMessage(sessionKey, "assistant", response)
+	agent.Sessions.Save(sessionKey)
+	return response, true
+}
+
+// ProcessDelegated answers a request delegated by the instance origin, in a
+// session of the default agent kept for origin's session.
+func (al *AgentLoop) ProcessDelegated(
+	ctx context.Context,
+	origin, session, content string,
+	media ...string,
+) (string, error) {
+	agent := al.registry.GetDefaultAgent()
+	if agent == nil {
+		return "", fmt.Errorf("no default agent configured")
+	}
+	sessionKey := "agent:" + agent.ID + ":" + federation.Channel + ":" + origin + ":" + session
+	return al.ProcessDirectWithChannel(ctx, content, sessionKey, federation.Channel, origin, media...)
+}
@@ -0,0 +1,72 @@
+package agent
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/federation"
+)
+
+func TestDelegate_RecordsExchangeAndFallsBack(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &simpleMockProvider{response: "local"})
+
+	var peerDown atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if peerDown.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte(`{"response": null, "error": "down"}`))
+			return
+		}
+		w.Write([]byte(`{"response": "remote"}`))
+	}))
+	defer srv.Close()
+	router, err := federation.New(config.FederationConfig{
+		Peers: []config.FederationPeer{{Name: "server", URL: srv.URL, Token: "pc_token"}},
+		Rules: []config.FederationRule{{Peer: "server", Keywords: []string{"report"}}},
+	}, "home")
+	if err != nil {
+		t.Fatalf("federation.New: %v", err)
+	}
+	al.SetFederation(router)
+
+	ctx := context.Background()
+	sessionKey := "agent:main:chat"
+	process := func(content string) string {
+		response, err := al.ProcessDirectWithChannel(ctx, content, sessionKey, "cli", "direct")
+		if err != nil {
+			t.Fatalf("ProcessDirectWithChannel(%q): %v", content, err)
+		}
+		return response
+	}
+
+	if got := process("monthly report"); got != "remote" {
+		t.Errorf("delegated response = %q, want %q", got, "remote")
+	}
+	history := al.registry.GetDefaultAgent().Sessions.GetHistory(sessionKey)
+	if len(history) != 2 || history[0].Content != "monthly report" || history[1].Content != "remote" {
+		t.Errorf("history = %+v, want the delegated exchange", history)
+	}
+	if got := process("hello"); got != "local" {
+		t.Errorf("undelegated response = %q, want %q", got, "local")
+	}
+
+	peerDown.Store(true)
+	if got := process("another report"); got != "local" {
+		t.Errorf("response with the peer down = %q, want %q", got, "local")
+	}
+}
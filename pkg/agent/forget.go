@@ -0,0 +1,190 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/eventlog"
+	"github.com/sipeed/picoclaw/pkg/i18n"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/logrotate"
+	"github.com/sipeed/picoclaw/pkg/wirelog"
+)
+
+// ErrSessionNotFound is returned by DeleteSession for a session that has
+// nothing to delete.
+var ErrSessionNotFound = errors.New("session not found")
+
+// DeletionReceipt records what DeleteSession removed. It is logged and kept
+// in the event log, so a privacy request can be shown to have been handled.
+type DeletionReceipt struct {
+	ReceiptID   string    `json:"receipt_id"`
+	Time        time.Time `json:"time"`
+	AgentID     string    `json:"agent_id"`
+	SessionKey  string    `json:"session_key"`
+	Messages    int       `json:"messages"`    // history messages
+	Summary     bool      `json:"summary"`     // a conversation summary was kept
+	Transcripts int       `json:"transcripts"` // turns in history search
+	Artifacts   int       `json:"artifacts"`
+	Events      int       `json:"events"`
+	DebugFiles  int       `json:"debug_files"`
+	WireLog     int       `json:"wire_log"` // wire log entries
+	Memory      int       `json:"memory"`   // lines the session added to memory files
+	// Kept lists what may still hold the session's data, and why.
+	Kept []string `json:"kept,omitempty"`
+}
+
+// DeleteSession removes everything kept about one session of an agent (the
+// default agent for an empty agentID): its history and summary, its turns in
+// history search, its artifacts, its debug trace, its events together with
+// the other events of the same requests, and the wire log entries of those
+// requests, and the lines it added to memory files with the file tools.
+func (al *AgentLoop) DeleteSession(ctx context.Context, agentID, sessionKey string) (*DeletionReceipt, error) {
+	agent, err := al.resolveAgent(agentID)
+	if err != nil {
+		return nil, err
+	}
+	receipt := &DeletionReceipt{
+		ReceiptID:  wirelog.NewID(),
+		AgentID:    agent.ID,
+		SessionKey: sessionKey,
+		Messages:   len(agent.Sessions.GetHistory(sessionKey)),
+		Summary:    agent.Sessions.GetSummary(sessionKey) != "",
+	}
+	existed, err := agent.Sessions.Delete(sessionKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete session: %w", err)
+	}
+
+	if al.transcripts != nil {
+		if receipt.Transcripts, err = al.transcripts.DeleteSession(ctx, sessionKey); err != nil {
+			return nil, err
+		}
+	}
+	if al.artifacts != nil {
+		if receipt.Artifacts, err = al.artifacts.DeleteSession(ctx, sessionKey); err != nil {
+			return nil, err
+		}
+	}
+	debugFiles, err := logrotate.Files(debugFilePath(agent.Workspace, sessionKey))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range debugFiles {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to delete debug trace: %w", err)
+		}
+		receipt.DebugFiles++
+	}
+	if receipt.Memory, err = forgetMemoryWrites(agent.Workspace, sessionKey); err != nil {
+		return nil, err
+	}
+	var requests map[string]bool
+	if al.events != nil {
+		if requests, err = al.sessionRequests(sessionKey); err != nil {
+			return nil, err
+		}
+		if receipt.Events, err = al.deleteSessionEvents(sessionKey, requests); err != nil {
+			return nil, err
+		}
+	}
+	if al.wireLog != nil {
+		receipt.WireLog, err = al.wireLog.Delete(func(e wirelog.Entry) bool {
+			return e.SessionKey == sessionKey || requests[e.ID]
+		})
+		if err != nil {
+			return nil, err
+		}
+		if al.events == nil {
+			receipt.Kept = append(receipt.Kept,
+				"wire log entries of API calls, which are only matched to the session through the event log")
+		}
+	}
+
+	if !existed && receipt.Transcripts == 0 && receipt.Artifacts == 0 && receipt.Events == 0 &&
+		receipt.DebugFiles == 0 && receipt.WireLog == 0 && receipt.Memory == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrSessionNotFound, sessionKey)
+	}
+
+	receipt.Time = time.Now().UTC()
+	fields := map[string]any{
+		"receipt_id":  receipt.ReceiptID,
+		"agent_id":    receipt.AgentID,
+		"session_key": receipt.SessionKey,
+		"messages":    receipt.Messages,
+		"summary":     receipt.Summary,
+		"transcripts": receipt.Transcripts,
+		"artifacts":   receipt.Artifacts,
+		"events":      receipt.Events,
+		"debug_files": receipt.DebugFiles,
+		"wire_log":    receipt.WireLog,
+		"memory":      receipt.Memory,
+		"kept":        receipt.Kept,
+	}
+	logger.InfoCF("agent", "Session data deleted", fields)
+	al.recordEvent(ctx, eventlog.Event{
+		Time:       receipt.Time,
+		Type:       eventlog.TypeSessionDeleted,
+		AgentID:    receipt.AgentID,
+		SessionKey: receipt.SessionKey,
+		Data:       fields,
+	})
+	return receipt, nil
+}
+
+// sessionRequests returns the IDs of the requests a session served. Some of
+// their events (request_received) and their wire log entries are recorded
+// before the request is routed to the session, so they are found by request.
+func (al *AgentLoop) sessionRequests(sessionKey string) (map[string]bool, error) {
+	requests := map[string]bool{}
+	err := al.events.Read(0, func(e eventlog.Event) error {
+		if e.SessionKey == sessionKey && e.RequestID != "" && e.Type != eventlog.TypeSessionDeleted {
+			requests[e.RequestID] = true
+		}
+		return nil
+	})
+	return requests, err
+}
+
+// deleteSessionEvents removes the events of a session and of the requests it
+// served. Deletion receipts stay.
+func (al *AgentLoop) deleteSessionEvents(sessionKey string, requests map[string]bool) (int, error) {
+	return al.events.Delete(func(e eventlog.Event) bool {
+		if e.Type == eventlog.TypeSessionDeleted {
+			return false
+		}
+		return e.SessionKey == sessionKey || (e.RequestID != "" && requests[e.RequestID])
+	})
+}
+
+// forgetCommand deletes the conversation's session after the user confirms
+// with /forget confirm.
+func (al *AgentLoop) forgetCommand(
+	ctx context.Context,
+	agent *AgentInstance,
+	sessionKey string,
+	msg bus.InboundMessage,
+	args []string,
+) string {
+	lang := al.language(ctx, msg)
+	switch {
+	case len(args) == 0:
+		return i18n.T(lang, "forget.confirm")
+	case len(args) == 1 && args[0] == "confirm":
+		receipt, err := al.DeleteSession(ctx, agent.ID, sessionKey)
+		if errors.Is(err, ErrSessionNotFound) {
+			return i18n.T(lang, "forget.nothing")
+		}
+		if err != nil {
+			return i18n.T(lang, "forget.failed", err)
+		}
+		return i18n.T(lang, "forget.done",
+			receipt.Messages, receipt.Transcripts, receipt.Artifacts, receipt.Events, receipt.ReceiptID)
+	default:
+		return i18n.T(lang, "forget.usage")
+	}
+}
@@ -0,0 +1,181 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/eventlog"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/transcript"
+	"github.com/sipeed/picoclaw/pkg/wirelog"
+)
+
+func TestDeleteSession_RemovesEverything(t *testing.T) {
+	al, _ := newReplayLoop(t, true)
+	agent := al.registry.GetDefaultAgent()
+	transcripts, err := transcript.Open(agent.Workspace)
+	if err != nil {
+		t.Fatalf("transcript.Open: %v", err)
+	}
+	t.Cleanup(func() { transcripts.Close() })
+	al.SetTranscripts(transcripts)
+	wire, err := wirelog.New(agent.Workspace, wirelog.Options{})
+	if err != nil {
+		t.Fatalf("wirelog.New: %v", err)
+	}
+	t.Cleanup(func() { wire.Close() })
+	al.SetWireLog(wire)
+	// API calls are logged before they reach a session
+	for _, id := range []string{"req-1", "req-2"} {
+		wire.Record(wirelog.Entry{ID: id, Direction: wirelog.DirectionRequest, Source: "webhook", Body: "{}"})
+	}
+
+	ctx := context.Background()
+	for i, key := range []string{"agent:main:forget", "agent:main:keep"} {
+		reqCtx := context.WithValue(ctx, constants.ContextKeyRequestID, []string{"req-1", "req-2"}[i])
+		if _, err := al.ProcessDirectWithChannel(reqCtx, "What is the total?", key, "cli", "direct"); err != nil {
+			t.Fatalf("ProcessDirectWithChannel: %v", err)
+		}
+	}
+
+	receipt, err := al.DeleteSession(ctx, "", "agent:main:forget")
+	if err != nil {
+		t.Fatalf("DeleteSession: %v", err)
+	}
+	if receipt.Messages != 2 || receipt.Transcripts != 1 || receipt.Events == 0 || receipt.WireLog != 1 {
+		t.Errorf("receipt = %+v", receipt)
+	}
+	if len(receipt.Kept) != 0 {
+		t.Errorf("receipt kept = %q, want nothing", receipt.Kept)
+	}
+	if n, _ := wire.Delete(func(e wirelog.Entry) bool { return e.ID == "req-2" }); n != 1 {
+		t.Errorf("wire log entries of the other session = %d, want 1", n)
+	}
+	if history := agent.Sessions.GetHistory("agent:main:forget"); len(history) != 0 {
+		t.Errorf("history left: %v", history)
+	}
+	if results, _ := transcripts.Search(ctx, transcript.Query{SessionKey: "agent:main:forget"}); len(results) != 0 {
+		t.Errorf("transcripts left: %v", results)
+	}
+
+	var receipts int
+	al.events.Read(0, func(e eventlog.Event) error {
+		switch {
+		case e.Type == eventlog.TypeSessionDeleted:
+			receipts++
+		case e.RequestID == "req-1":
+			t.Errorf("event of the deleted session left: %+v", e)
+		}
+		return nil
+	})
+	if receipts != 1 {
+		t.Errorf("%d deletion receipts in the event log, want 1", receipts)
+	}
+	if history := agent.Sessions.GetHistory("agent:main:keep"); len(history) != 2 {
+		t.Errorf("other session has %d messages, want 2", len(history))
+	}
+
+	if _, err := al.DeleteSession(ctx, "", "agent:main:forget"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("second DeleteSession error = %v, want ErrSessionNotFound", err)
+	}
+}
+
+// memoryProvider saves "remember <fact>" messages to MEMORY.md.
+type memoryProvider struct{}
+
+func (p *memoryProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	last := messages[len(messages)-1]
+	if fact, ok := strings.CutPrefix(last.Content, "remember "); ok && last.Role == "user" {
+		return &providers.LLMResponse{ToolCalls: []providers.ToolCall{{
+			ID:        "call-1",
+			Name:      "append_file",
+			Arguments: map[string]any{"path": "memory/MEMORY.md", "content": "- " + fact + "\n"},
+		}}}, nil
+	}
+	return &providers.LLMResponse{Content: "Noted."}, nil
+}
+
+func (p *memoryProvider) GetDefaultModel() string {
+	return "mock-model"
+}
+
+func TestDeleteSession_RemovesMemoryNotes(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &memoryProvider{})
+	agent := al.registry.GetDefaultAgent()
+	memoryFile := filepath.Join(agent.Workspace, "memory", "MEMORY.md")
+	os.WriteFile(memoryFile, []byte("# Memory\n\n"), 0o644)
+
+	ctx := context.Background()
+	for key, fact := range map[string]string{
+		"agent:main:forget": "Ada pays by card",
+		"agent:main:keep":   "Bob prefers email",
+	} {
+		if _, err := al.ProcessDirectWithChannel(ctx, "remember "+fact, key, "cli", "direct"); err != nil {
+			t.Fatalf("ProcessDirectWithChannel: %v", err)
+		}
+	}
+
+	receipt, err := al.DeleteSession(ctx, "", "agent:main:forget")
+	if err != nil {
+		t.Fatalf("DeleteSession: %v", err)
+	}
+	if receipt.Memory != 1 {
+		t.Errorf("receipt memory = %d, want 1", receipt.Memory)
+	}
+	data, _ := os.ReadFile(memoryFile)
+	if strings.Contains(string(data), "Ada") || !strings.Contains(string(data), "- Bob prefers email") ||
+		!strings.HasPrefix(string(data), "# Memory") {
+		t.Errorf("MEMORY.md after delete = %q", data)
+	}
+	if _, err := os.Stat(memoryWritesPath(agent.Workspace, "agent:main:forget")); !os.IsNotExist(err) {
+		t.Errorf("memory trace left: %v", err)
+	}
+}
+
+func TestForgetCommand(t *testing.T) {
+	al := newHandoffLoop(t, "")
+	msg := telegramDM("hello")
+	if _, err := al.processMessage(context.Background(), msg); err != nil {
+		t.Fatalf("processMessage: %v", err)
+	}
+	agent, key := al.routeMessage(context.Background(), msg)
+
+	msg.Content = "/forget"
+	reply, _ := al.processMessage(context.Background(), msg)
+	if !strings.Contains(reply, "/forget confirm") || len(agent.Sessions.GetHistory(key)) != 2 {
+		t.Fatalf("/forget without confirm = %q", reply)
+	}
+	msg.Content = "/forget confirm"
+	if reply, _ := al.processMessage(context.Background(), msg); !strings.HasPrefix(reply, "Forgotten: 2 messages") {
+		t.Errorf("/forget confirm = %q", reply)
+	}
+	if history := agent.Sessions.GetHistory(key); len(history) != 0 {
+		t.Errorf("history left after /forget confirm: %v", history)
+	}
+	if reply, _ := al.processMessage(context.Background(), msg); !strings.Contains(reply, "nothing to forget") {
+		t.Errorf("second /forget confirm = %q", reply)
+	}
+}
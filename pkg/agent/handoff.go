@@ -0,0 +1,246 @@
+package agent
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/i18n"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// Session handoff policies; see config.SessionConfig.
+const (
+	HandoffOff   = "off"
+	HandoffLink  = "link"
+	HandoffUnify = "unify"
+)
+
+const (
+	// linkCodeTTL is how long a /link code waits for the other side.
+	linkCodeTTL = 10 * time.Minute
+	// handoffRecapTurns is how many of the latest messages of the other
+	// session a "link" handoff passes on.
+	handoffRecapTurns = 6
+)
+
+// linkCode is a pending /link, issued to a chat ("channel:peer_id") or to an
+// API user ("user:<sub>").
+type linkCode struct {
+	identity string
+	expires  time.Time
+}
+
+// lastSession is the session a linked user's last message went to.
+type lastSession struct {
+	key     string
+	channel string
+}
+
+// ValidateHandoff checks a session.handoff policy.
+func ValidateHandoff(mode string) error {
+	switch mode {
+	case "", HandoffOff, HandoffLink, HandoffUnify:
+		return nil
+	}
+	return fmt.Errorf("unknown session handoff %q (want %s, %s or %s)", mode, HandoffOff, HandoffLink, HandoffUnify)
+}
+
+// handoffMode returns the configured handoff policy, HandoffOff if none.
+func (al *AgentLoop) handoffMode() string {
+	switch al.cfg.Session.Handoff {
+	case HandoffLink, HandoffUnify:
+		return al.cfg.Session.Handoff
+	}
+	return HandoffOff
+}
+
+// chatIdentity returns "channel:peer_id" for a direct chat, or "" for a
+// group or a message without a peer.
+func chatIdentity(msg bus.InboundMessage) string {
+	peer := extractPeer(msg)
+	if peer == nil || peer.Kind != "direct" {
+		return ""
+	}
+	return msg.Channel + ":" + peer.ID
+}
+
+// handoffUser returns the API user that msg continues the conversation of:
+// the signed-in user of an API request that has linked a chat, or the user a
+// linked chat belongs to. It returns "" with handoff off.
+func (al *AgentLoop) handoffUser(ctx context.Context, msg bus.InboundMessage) string {
+	if al.handoffMode() == HandoffOff || al.state == nil {
+		return ""
+	}
+	if userID, _ := ctx.Value(constants.ContextKeyUserID).(string); userID != "" {
+		if len(al.state.LinkedChats(userID)) == 0 {
+			return ""
+		}
+		return userID
+	}
+	if chat := chatIdentity(msg); chat != "" {
+		return al.state.LinkedUser(chat)
+	}
+	return ""
+}
+
+// userSessionKey is the session a linked user's channels share under the
+// "unify" policy.
+func userSessionKey(agentID, userID string) string {
+	return fmt.Sprintf("agent:%s:user:%s", agentID, userID)
+}
+
+// handOff brings a linked user's session up to date with the one they used
+// last, on another channel, under the "link" policy: it adds the other
+// session's summary and latest messages to this session's summary.
+func (al *AgentLoop) handOff(agent *AgentInstance, userID, sessionKey, channel string) {
+	current := lastSession{key: sessionKey, channel: channel}
+	prev, ok := al.lastSessions.Swap(userID, current)
+	if !ok || prev.(lastSession).key == sessionKey {
+		return
+	}
+	last := prev.(lastSession)
+	recap := handoffRecap(agent, last)
+	if recap == "" {
+		return
+	}
+	agent.Sessions.GetOrCreate(sessionKey)
+	summary := agent.Sessions.GetSummary(sessionKey)
+	if summary != "" {
+		summary += "\n\n"
+	}
+	agent.Sessions.SetSummary(sessionKey, summary+recap)
+	if err := agent.Sessions.Save(sessionKey); err != nil {
+		logger.WarnCF("agent", "Failed to save session handoff",
+			map[string]any{"session_key": sessionKey, "error": err.Error()})
+		return
+	}
+	logger.InfoCF("agent", "Session handed off",
+		map[string]any{"from": last.key, "to": sessionKey, "agent_id": agent.ID})
+}
+
+// handoffRecap describes what was said in another session, or returns "" if
+// nothing was.
+func handoffRecap(agent *AgentInstance, from lastSession) string {
+	summary := agent.Sessions.GetSummary(from.key)
+	history := agent.Sessions.GetHistory(from.key)
+	if len(history) > handoffRecapTurns {
+		history = history[len(history)-handoffRecapTurns:]
+	}
+	var lines []string
+	for _, m := range history {
+		if (m.Role == "user" || m.Role == "assistant") && m.Content != "" {
+			lines = append(lines, fmt.Sprintf("%s: %s", m.Role, utils.Truncate(m.Content, 300)))
+		}
+	}
+	if summary == "" && len(lines) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "The user continued here from %s. There, ", from.channel)
+	if summary != "" {
+		sb.WriteString("in short: " + summary + "\n")
+	}
+	if len(lines) > 0 {
+		sb.WriteString("the latest messages were:\n" + strings.Join(lines, "\n"))
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// linkCommand links a direct chat with the signed-in API user, so that the
+// handoff policy applies to them. /link issues a code on one side, and
+// /link <code> on the other side completes the link; /link off undoes it.
+func (al *AgentLoop) linkCommand(ctx context.Context, msg bus.InboundMessage, args []string) string {
+	lang := al.language(ctx, msg)
+	if al.handoffMode() == HandoffOff || al.state == nil {
+		return i18n.T(lang, "link.unavailable")
+	}
+	userID, _ := ctx.Value(constants.ContextKeyUserID).(string)
+	chat := chatIdentity(msg)
+	identity := chat
+	if userID != "" {
+		identity = "user:" + userID
+	}
+	if identity == "" {
+		return i18n.T(lang, "link.unsupported")
+	}
+
+	switch {
+	case len(args) == 0:
+		code, err := al.issueLinkCode(identity)
+		if err != nil {
+			return i18n.T(lang, "link.failed", err)
+		}
+		return i18n.T(lang, "link.code", code, int(linkCodeTTL.Minutes()))
+
+	case len(args) == 1 && args[0] == "off":
+		chats := []string{chat}
+		if userID != "" {
+			chats = al.state.LinkedChats(userID)
+		} else if al.state.LinkedUser(chat) == "" {
+			chats = nil
+		}
+		if len(chats) == 0 {
+			return i18n.T(lang, "link.not_linked")
+		}
+		for _, c := range chats {
+			if err := al.state.SetLinkedUser(c, ""); err != nil {
+				return i18n.T(lang, "link.failed", err)
+			}
+		}
+		return i18n.T(lang, "link.unlinked")
+
+	case len(args) == 1:
+		pending, ok := al.linkCodes.LoadAndDelete(args[0])
+		if !ok || time.Now().After(pending.(linkCode).expires) {
+			return i18n.T(lang, "link.invalid")
+		}
+		other := pending.(linkCode).identity
+		otherUser, isUser := strings.CutPrefix(other, "user:")
+		switch {
+		case userID != "" && !isUser:
+			chat = other
+		case userID == "" && isUser:
+			userID = otherUser
+		default:
+			return i18n.T(lang, "link.same_side")
+		}
+		if err := al.state.SetLinkedUser(chat, userID); err != nil {
+			return i18n.T(lang, "link.failed", err)
+		}
+		logger.InfoCF("agent", "Chat linked", map[string]any{"chat": chat, "user_id": userID})
+		return i18n.T(lang, "link.linked")
+
+	default:
+		return i18n.T(lang, "link.usage")
+	}
+}
+
+// issueLinkCode returns a new eight-digit code for identity to /link with,
+// too many to guess within linkCodeTTL, and drops the expired ones.
+func (al *AgentLoop) issueLinkCode(identity string) (string, error) {
+	now := time.Now()
+	al.linkCodes.Range(func(code, pending any) bool {
+		if now.After(pending.(linkCode).expires) {
+			al.linkCodes.Delete(code)
+		}
+		return true
+	})
+	for {
+		n, err := rand.Int(rand.Reader, big.NewInt(100000000))
+		if err != nil {
+			return "", err
+		}
+		code := fmt.Sprintf("%08d", n.Int64())
+		pending := linkCode{identity: identity, expires: now.Add(linkCodeTTL)}
+		if _, taken := al.linkCodes.LoadOrStore(code, pending); !taken {
+			return code, nil
+		}
+	}
+}
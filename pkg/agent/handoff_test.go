@@ -0,0 +1,157 @@
+package agent
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/constants"
+)
+
+var linkCodePattern = regexp.MustCompile(`\d{8}`)
+
+func newHandoffLoop(t *testing.T, handoff string) *AgentLoop {
+	t.Helper()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+		Session: config.SessionConfig{DMScope: "per-channel-peer", Handoff: handoff},
+	}
+	return NewAgentLoop(cfg, bus.NewMessageBus(), &simpleMockProvider{response: "ok"})
+}
+
+func telegramDM(content string) bus.InboundMessage {
+	return bus.InboundMessage{
+		Channel:  "telegram",
+		ChatID:   "42",
+		SenderID: "42",
+		Content:  content,
+		Metadata: map[string]string{"peer_kind": "direct", "peer_id": "42"},
+	}
+}
+
+func apiRequest(content string) (context.Context, bus.InboundMessage) {
+	ctx := context.WithValue(context.Background(), constants.ContextKeyUserID, "user-1")
+	return ctx, bus.InboundMessage{
+		Channel:    "api",
+		ChatID:     "mobile-client",
+		SenderID:   "cron",
+		Content:    content,
+		SessionKey: "user:user-1",
+	}
+}
+
+// link links the Telegram chat with user-1, starting from the chat.
+func link(t *testing.T, al *AgentLoop) {
+	t.Helper()
+	reply, _ := al.handleCommand(context.Background(), telegramDM("/link"))
+	code := linkCodePattern.FindString(reply)
+	if code == "" {
+		t.Fatalf("/link gave no code: %q", reply)
+	}
+	ctx, msg := apiRequest("/link " + code)
+	if reply, _ := al.handleCommand(ctx, msg); !strings.HasPrefix(reply, "Linked.") {
+		t.Fatalf("/link %s = %q", code, reply)
+	}
+}
+
+func TestLinkCommand_Off(t *testing.T) {
+	al := newHandoffLoop(t, "")
+	reply, handled := al.handleCommand(context.Background(), telegramDM("/link"))
+	if !handled || !strings.Contains(reply, "not turned on") {
+		t.Errorf("/link with handoff off = %q", reply)
+	}
+}
+
+func TestLinkCommand_Codes(t *testing.T) {
+	al := newHandoffLoop(t, HandoffUnify)
+
+	group := telegramDM("/link")
+	group.Metadata = map[string]string{"peer_kind": "group", "peer_id": "-100"}
+	if reply, _ := al.handleCommand(context.Background(), group); !strings.Contains(reply, "Only a direct chat") {
+		t.Errorf("/link in a group = %q", reply)
+	}
+
+	reply, _ := al.handleCommand(context.Background(), telegramDM("/link"))
+	code := linkCodePattern.FindString(reply)
+	other := telegramDM("/link " + code)
+	other.Metadata = map[string]string{"peer_kind": "direct", "peer_id": "43"}
+	if reply, _ := al.handleCommand(context.Background(), other); !strings.Contains(reply, "other side") {
+		t.Errorf("/link from another chat = %q", reply)
+	}
+	ctx, msg := apiRequest("/link " + code)
+	if reply, _ := al.handleCommand(ctx, msg); !strings.Contains(reply, "wrong or has expired") {
+		t.Errorf("/link with a used code = %q", reply)
+	}
+	if got := al.state.LinkedUser("telegram:42"); got != "" {
+		t.Errorf("chat linked to %q after failed links", got)
+	}
+}
+
+func TestHandoff_Unify(t *testing.T) {
+	al := newHandoffLoop(t, HandoffUnify)
+	apiCtx, apiMsg := apiRequest("hello")
+
+	_, chatKey := al.routeMessage(context.Background(), telegramDM("hello"))
+	_, apiKey := al.routeMessage(apiCtx, apiMsg)
+	if chatKey == apiKey {
+		t.Fatalf("unlinked chat and API share session %s", chatKey)
+	}
+
+	link(t, al)
+	_, chatKey = al.routeMessage(context.Background(), telegramDM("hello"))
+	_, apiKey = al.routeMessage(apiCtx, apiMsg)
+	if want := "agent:main:user:user-1"; chatKey != want || apiKey != want {
+		t.Errorf("linked sessions = %s and %s, want %s", chatKey, apiKey, want)
+	}
+
+	apiMsg.Content = "/link off"
+	if reply, _ := al.handleCommand(apiCtx, apiMsg); !strings.HasPrefix(reply, "Unlinked.") {
+		t.Errorf("/link off = %q", reply)
+	}
+	_, chatKey = al.routeMessage(context.Background(), telegramDM("hello"))
+	if chatKey == "agent:main:user:user-1" {
+		t.Error("chat still uses the shared session after /link off")
+	}
+}
+
+func TestHandoff_Link(t *testing.T) {
+	al := newHandoffLoop(t, HandoffLink)
+	link(t, al)
+
+	if _, err := al.processMessage(context.Background(), telegramDM("My name is Ada")); err != nil {
+		t.Fatalf("chat message: %v", err)
+	}
+	apiCtx, apiMsg := apiRequest("What is my name?")
+	agent, apiKey := al.routeMessage(apiCtx, apiMsg)
+	if strings.Contains(apiKey, "telegram") {
+		t.Fatalf("link policy shared the chat's session %s", apiKey)
+	}
+	if _, err := al.processMessage(apiCtx, apiMsg); err != nil {
+		t.Fatalf("API request: %v", err)
+	}
+	summary := agent.Sessions.GetSummary(apiKey)
+	if !strings.Contains(summary, "from telegram") || !strings.Contains(summary, "user: My name is Ada") {
+		t.Errorf("API session summary lacks the chat's recap: %q", summary)
+	}
+}
+
+func TestValidateHandoff(t *testing.T) {
+	for _, mode := range []string{"", HandoffOff, HandoffLink, HandoffUnify} {
+		if err := ValidateHandoff(mode); err != nil {
+			t.Errorf("ValidateHandoff(%q): %v", mode, err)
+		}
+	}
+	if err := ValidateHandoff("merge"); err == nil {
+		t.Error("ValidateHandoff accepted an unknown policy")
+	}
+}
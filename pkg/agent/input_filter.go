@@ -0,0 +1,125 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/telemetry"
+)
+
+// Input filter policies.
+const (
+	FilterStrip  = "strip"  // remove the matches and pass the rest on
+	FilterRefuse = "refuse" // drop the message and tell the sender why
+	FilterOff    = "off"    // don't filter the channel
+)
+
+// filteredText replaces the text the filter strips from a message.
+const filteredText = "[filtered]"
+
+// InputFilter screens messages from group chats for configured words and
+// patterns before they reach the model.
+type InputFilter struct {
+	action   string
+	channels map[string]string
+	patterns []*regexp.Regexp
+}
+
+// NewInputFilter compiles cfg's words and patterns. Words match whole words,
+// ignoring case; patterns are regular expressions.
+func NewInputFilter(cfg config.InputFilterConfig) (*InputFilter, error) {
+	f := &InputFilter{action: cfg.Action, channels: cfg.Channels}
+	if f.action == "" {
+		f.action = FilterStrip
+	}
+	if !validFilterPolicy(f.action) || f.action == FilterOff {
+		return nil, fmt.Errorf("invalid action %q: use %s or %s", f.action, FilterStrip, FilterRefuse)
+	}
+	for channel, policy := range cfg.Channels {
+		if !validFilterPolicy(policy) {
+			return nil, fmt.Errorf("invalid policy %q for channel %s: use %s, %s or %s",
+				policy, channel, FilterStrip, FilterRefuse, FilterOff)
+		}
+	}
+	for _, word := range cfg.Words {
+		f.patterns = append(f.patterns, regexp.MustCompile(`(?i)\b`+regexp.QuoteMeta(word)+`\b`))
+	}
+	for _, p := range cfg.Patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", p, err)
+		}
+		f.patterns = append(f.patterns, re)
+	}
+	if len(f.patterns) == 0 {
+		return nil, fmt.Errorf("no words or patterns to filter")
+	}
+	return f, nil
+}
+
+func validFilterPolicy(policy string) bool {
+	return policy == FilterStrip || policy == FilterRefuse || policy == FilterOff
+}
+
+// Filter applies channel's policy to content. It returns the content to pass
+// on and the policy applied, or "" if nothing matched or the channel is off.
+func (f *InputFilter) Filter(channel, content string) (string, string) {
+	policy := f.action
+	if p, ok := f.channels[channel]; ok {
+		policy = p
+	}
+	if policy == FilterOff {
+		return content, ""
+	}
+	matched := false
+	for _, re := range f.patterns {
+		if re.MatchString(content) {
+			matched = true
+			content = re.ReplaceAllString(content, filteredText)
+		}
+	}
+	if !matched {
+		return content, ""
+	}
+	return content, policy
+}
+
+// SetInputFilter screens group chat messages with f before they reach the
+// model.
+func (al *AgentLoop) SetInputFilter(f *InputFilter) {
+	al.inputFilter = f
+}
+
+// filterInput applies the input filter to a message from a group chat. It
+// returns the message with any matches stripped, and whether it was refused.
+func (al *AgentLoop) filterInput(ctx context.Context, msg bus.InboundMessage) (bus.InboundMessage, bool) {
+	if al.inputFilter == nil {
+		return msg, false
+	}
+	if kind := msg.Metadata["peer_kind"]; kind != "group" && kind != "channel" {
+		return msg, false
+	}
+	content, policy := al.inputFilter.Filter(msg.Channel, msg.Content)
+	if policy == "" {
+		return msg, false
+	}
+	telemetry.AddCounter("picoclaw.messages.filtered", 1,
+		telemetry.String("channel", msg.Channel), telemetry.String("action", policy))
+	logger.InfoCF("agent", "Filtered group message", map[string]any{
+		"channel":    msg.Channel,
+		"chat_id":    msg.ChatID,
+		"sender_id":  msg.SenderID,
+		"action":     policy,
+		"request_id": ctx.Value(constants.ContextKeyRequestID),
+	})
+	if policy == FilterRefuse {
+		return msg, true
+	}
+	msg.Content = content
+	return msg, false
+}
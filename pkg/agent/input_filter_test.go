@@ -0,0 +1,79 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/i18n"
+	"github.com/sipeed/picoclaw/pkg/locale"
+)
+
+func TestNewInputFilter_Validates(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.InputFilterConfig
+	}{
+		{"no words", config.InputFilterConfig{Action: FilterStrip}},
+		{"bad action", config.InputFilterConfig{Action: "mask", Words: []string{"darn"}}},
+		{"off action", config.InputFilterConfig{Action: FilterOff, Words: []string{"darn"}}},
+		{"bad channel", config.InputFilterConfig{Words: []string{"darn"}, Channels: map[string]string{"slack": "drop"}}},
+		{"bad pattern", config.InputFilterConfig{Patterns: []string{"("}}},
+	}
+	for _, tt := range tests {
+		if _, err := NewInputFilter(tt.cfg); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func TestInputFilter_Filter(t *testing.T) {
+	f, err := NewInputFilter(config.InputFilterConfig{
+		Words:    []string{"darn"},
+		Patterns: []string{`\b\d{3}-\d{3}-\d{4}\b`},
+		Channels: map[string]string{"discord": FilterRefuse, "slack": FilterOff},
+	})
+	if err != nil {
+		t.Fatalf("NewInputFilter: %v", err)
+	}
+
+	tests := []struct {
+		channel, content string
+		want, wantPolicy string
+	}{
+		{"telegram", "Call me at 416-555-0100, DARN it", "Call me at [filtered], [filtered] it", FilterStrip},
+		{"telegram", "darned good receipts", "darned good receipts", ""},
+		{"discord", "darn", "[filtered]", FilterRefuse},
+		{"slack", "darn", "darn", ""},
+	}
+	for _, tt := range tests {
+		got, policy := f.Filter(tt.channel, tt.content)
+		if got != tt.want || policy != tt.wantPolicy {
+			t.Errorf("Filter(%q, %q) = %q, %q; want %q, %q",
+				tt.channel, tt.content, got, policy, tt.want, tt.wantPolicy)
+		}
+	}
+}
+
+func TestProcessMessage_FiltersGroupMessages(t *testing.T) {
+	f, err := NewInputFilter(config.InputFilterConfig{Action: FilterRefuse, Words: []string{"darn"}})
+	if err != nil {
+		t.Fatalf("NewInputFilter: %v", err)
+	}
+	al := &AgentLoop{locales: locale.NewResolver(config.LocaleConfig{}), inputFilter: f}
+
+	direct := bus.InboundMessage{Channel: "telegram", Content: "darn", Metadata: map[string]string{"peer_kind": "direct"}}
+	if _, refused := al.filterInput(context.Background(), direct); refused {
+		t.Error("direct message was filtered")
+	}
+
+	group := bus.InboundMessage{Channel: "telegram", Content: "darn", Metadata: map[string]string{"peer_kind": "group"}}
+	response, err := al.processMessage(context.Background(), group)
+	if err != nil {
+		t.Fatalf("processMessage: %v", err)
+	}
+	if want := i18n.T("en", "chat.filtered"); response != want {
+		t.Errorf("response = %q, want %q", response, want)
+	}
+}
@@ -5,10 +5,13 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/sipeed/picoclaw/pkg/audit"
 	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/locale"
 	"github.com/sipeed/picoclaw/pkg/providers"
 	"github.com/sipeed/picoclaw/pkg/routing"
 	"github.com/sipeed/picoclaw/pkg/session"
+	"github.com/sipeed/picoclaw/pkg/skills"
 	"github.com/sipeed/picoclaw/pkg/tools"
 )
 
@@ -24,10 +27,14 @@ type AgentInstance struct {
 	MaxTokens      int
 	Temperature    float64
 	ContextWindow  int
+	MaxHistory     int // messages kept before the session is summarized
+	ParallelTools  int // tool calls of one response run at once
 	Provider       providers.LLMProvider
 	Sessions       *session.SessionManager
 	ContextBuilder *ContextBuilder
 	Tools          *tools.ToolRegistry
+	Audit          *audit.Log
+	SkillEvents    *skills.EventQueue
 	Subagents      *config.SubagentsConfig
 	SkillsFilter   []string
 	Candidates     []providers.FallbackCandidate
@@ -51,7 +58,21 @@ func NewAgentInstance(
 	toolsRegistry.Register(tools.NewReadFileTool(workspace, restrict))
 	toolsRegistry.Register(tools.NewWriteFileTool(workspace, restrict))
 	toolsRegistry.Register(tools.NewListDirTool(workspace, restrict))
-	toolsRegistry.Register(tools.NewExecToolWithConfig(workspace, restrict, cfg))
+	var skillMatrix skills.EnablementMatrix
+	if cfg != nil {
+		skillMatrix = cfg.Tools.Skills.Businesses
+	}
+
+	auditLog := audit.NewLog(workspace)
+	if cfg != nil {
+		auditLog.SetRotation(cfg.Logging.Rotation.Options())
+	}
+	execTool := tools.NewExecToolWithConfig(workspace, restrict, cfg)
+	execTool.SetAuditLog(auditLog)
+	execTool.SetSkillMatrix(skillMatrix)
+	skillEvents := skills.NewEventQueue(workspace)
+	execTool.SetEventQueue(skillEvents)
+	toolsRegistry.Register(execTool)
 	toolsRegistry.Register(tools.NewEditFileTool(workspace, restrict))
 	toolsRegistry.Register(tools.NewAppendFileTool(workspace, restrict))
 
@@ -60,6 +81,11 @@ func NewAgentInstance(
 
 	contextBuilder := NewContextBuilder(workspace)
 	contextBuilder.SetToolsRegistry(toolsRegistry)
+	contextBuilder.SetSkillMatrix(skillMatrix)
+	if cfg != nil {
+		contextBuilder.SetSkillPromptLimit(cfg.Tools.Skills.MaxPromptSkills)
+		contextBuilder.SetLocales(locale.NewResolver(cfg.Locale))
+	}
 
 	agentID := routing.DefaultAgentID
 	agentName := ""
@@ -83,6 +109,16 @@ func NewAgentInstance(
 		maxTokens = 8192
 	}
 
+	maxHistory := defaults.MaxHistoryMessages
+	if maxHistory == 0 {
+		maxHistory = 20
+	}
+
+	parallelTools := defaults.MaxParallelTools
+	if parallelTools == 0 {
+		parallelTools = 4
+	}
+
 	temperature := 0.7
 	if defaults.Temperature != nil {
 		temperature = *defaults.Temperature
@@ -105,10 +141,14 @@ func NewAgentInstance(
 		MaxTokens:      maxTokens,
 		Temperature:    temperature,
 		ContextWindow:  maxTokens,
+		MaxHistory:     maxHistory,
+		ParallelTools:  parallelTools,
 		Provider:       provider,
 		Sessions:       sessionsManager,
 		ContextBuilder: contextBuilder,
 		Tools:          toolsRegistry,
+		Audit:          auditLog,
+		SkillEvents:    skillEvents,
 		Subagents:      subagents,
 		SkillsFilter:   skillsFilter,
 		Candidates:     candidates,
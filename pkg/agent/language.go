@@ -0,0 +1,58 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/i18n"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// replyLanguage is the language a request should be answered in.
+type replyLanguage struct {
+	lang     string // detected in the message, or ""
+	fallback string // the user's or business's language
+}
+
+type replyLanguageKey struct{}
+
+// withReplyLanguage detects the language of a user's message, so the model
+// can be told to answer in it. Requests without a sender, such as
+// heartbeats, are written by picoclaw and keep their own instructions.
+func (al *AgentLoop) withReplyLanguage(ctx context.Context, message, businessID string) context.Context {
+	senderID, _ := ctx.Value(constants.ContextKeySenderID).(string)
+	if senderID == "" {
+		return ctx
+	}
+	reply := replyLanguage{
+		lang:     i18n.Detect(message),
+		fallback: al.locales.Language(businessID, senderID),
+	}
+	if reply.lang != "" {
+		logger.DebugCF("agent", "Detected message language",
+			map[string]any{"language": reply.lang, "session_key": ctx.Value(constants.ContextKeySessionKey)})
+	}
+	return context.WithValue(ctx, replyLanguageKey{}, reply)
+}
+
+// replyLanguagePrompt tells the model which language to answer in: the
+// message's, or the user's or business's when it can't be told.
+func replyLanguagePrompt(ctx context.Context, messages []providers.Message) []providers.Message {
+	reply, ok := ctx.Value(replyLanguageKey{}).(replyLanguage)
+	if !ok || len(messages) == 0 || messages[0].Role != "system" {
+		return messages
+	}
+	var section string
+	if reply.lang != "" {
+		name := i18n.LanguageName(reply.lang)
+		section = fmt.Sprintf("The user wrote in %s. Reply in %s, even if earlier messages, documents or "+
+			"tool results are in another language.", name, name)
+	} else {
+		section = fmt.Sprintf("Reply in the language of the user's message. If you can't tell which it is, "+
+			"reply in %s.", i18n.LanguageName(reply.fallback))
+	}
+	messages[0].Content += "\n\n## Reply Language\n" + section
+	return messages
+}
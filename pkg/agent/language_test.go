@@ -0,0 +1,44 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/locale"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func TestReplyLanguagePrompt(t *testing.T) {
+	al := &AgentLoop{locales: locale.NewResolver(config.LocaleConfig{Users: map[string]string{"42": "de"}})}
+	sender := context.WithValue(context.Background(), constants.ContextKeySenderID, "42")
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		message string
+		want    string
+	}{
+		{"detected", sender, "Bonjour, combien j'ai dépensé pour le café ce mois ?", "Reply in French"},
+		{"fallback", sender, "ok", "reply in German"},
+		{"no sender", context.Background(), "Bonjour, combien j'ai dépensé ?", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := al.withReplyLanguage(tt.ctx, tt.message, "")
+			messages := replyLanguagePrompt(ctx, []providers.Message{{Role: "system", Content: "You are picoclaw."}})
+			got := messages[0].Content
+			if tt.want == "" {
+				if got != "You are picoclaw." {
+					t.Errorf("system prompt changed without a sender: %q", got)
+				}
+				return
+			}
+			if !strings.Contains(got, "## Reply Language") || !strings.Contains(got, tt.want) {
+				t.Errorf("system prompt = %q, want it to contain %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -7,26 +7,49 @@
 package agent
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
-	"unicode/utf8"
 
+	"github.com/sipeed/picoclaw/pkg/alerts"
+	"github.com/sipeed/picoclaw/pkg/artifact"
+	"github.com/sipeed/picoclaw/pkg/audit"
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/channels"
 	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/crash"
+	"github.com/sipeed/picoclaw/pkg/documents"
+	"github.com/sipeed/picoclaw/pkg/eventlog"
+	"github.com/sipeed/picoclaw/pkg/federation"
+	"github.com/sipeed/picoclaw/pkg/i18n"
+	"github.com/sipeed/picoclaw/pkg/ledgerforge"
+	"github.com/sipeed/picoclaw/pkg/locale"
 	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/media"
 	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/receipts"
+	"github.com/sipeed/picoclaw/pkg/reporting"
 	"github.com/sipeed/picoclaw/pkg/routing"
+	"github.com/sipeed/picoclaw/pkg/session"
 	"github.com/sipeed/picoclaw/pkg/skills"
 	"github.com/sipeed/picoclaw/pkg/state"
+	"github.com/sipeed/picoclaw/pkg/subscriptions"
+	"github.com/sipeed/picoclaw/pkg/telemetry"
+	"github.com/sipeed/picoclaw/pkg/tokenizer"
 	"github.com/sipeed/picoclaw/pkg/tools"
+	"github.com/sipeed/picoclaw/pkg/transcript"
+	"github.com/sipeed/picoclaw/pkg/usage"
 	"github.com/sipeed/picoclaw/pkg/utils"
+	"github.com/sipeed/picoclaw/pkg/wirelog"
+	"github.com/sipeed/picoclaw/pkg/workflow"
+	"github.com/sipeed/picoclaw/pkg/workpool"
 )
 
 type AgentLoop struct {
@@ -38,8 +61,29 @@ type AgentLoop struct {
 	summarizing    sync.Map
 	fallback       *providers.FallbackChain
 	channelManager *channels.Manager
+	wireLog        *wirelog.Log
+	media          *media.Library
+	transcripts    *transcript.Store
+	artifacts      *artifact.Registry
+	events         *eventlog.Log
+	pool           *workpool.Pool
+	maintenance    atomic.Bool
+	ledgerForge    *ledgerforge.Client
+	locales        *locale.Resolver
+	receipts       *receipts.Pipeline
+	federation     *federation.Router
+	experiment     *Experiment
+	inputFilter    *InputFilter
+	workflows      *workflow.Engine
+	approvals      *approvalGate
+	budget         *spendBudget
+	linkCodes      sync.Map // pending /link code -> linkCode
+	lastSessions   sync.Map // linked API user -> lastSession
 }
 
+// ErrMaintenance is returned for messages received in maintenance mode.
+var ErrMaintenance = errors.New("picoclaw is in maintenance mode")
+
 // processOptions configures how a message is processed
 type processOptions struct {
 	SessionKey      string // Session identifier for history/context
@@ -50,13 +94,25 @@ type processOptions struct {
 	EnableSummary   bool   // Whether to trigger summarization
 	SendResponse    bool   // Whether to send response via bus
 	NoHistory       bool   // If true, don't load session history (for heartbeat)
+	DryRun          bool   // Answer tool calls without running them, and record no chat state (replay)
 }
 
 func NewAgentLoop(cfg *config.Config, msgBus *bus.MessageBus, provider providers.LLMProvider) *AgentLoop {
 	registry := NewAgentRegistry(cfg, provider)
 
+	var ledgerForge *ledgerforge.Client
+	if cfg.LedgerForge.BaseURL != "" {
+		ledgerForge = ledgerforge.New(ledgerforge.Options{
+			BaseURL:       cfg.LedgerForge.BaseURL,
+			Timeout:       time.Duration(cfg.LedgerForge.TimeoutSeconds) * time.Second,
+			MaxRetries:    cfg.LedgerForge.MaxRetries,
+			SigningSecret: cfg.LedgerForge.SigningSecret,
+			CallbackPath:  cfg.LedgerForge.CallbackPath,
+		})
+	}
+
 	// Register shared tools to all agents
-	registerSharedTools(cfg, msgBus, registry, provider)
+	registerSharedTools(cfg, msgBus, registry, provider, ledgerForge)
 
 	// Set up shared fallback chain
 	cooldown := providers.NewCooldownTracker()
@@ -69,14 +125,24 @@ func NewAgentLoop(cfg *config.Config, msgBus *bus.MessageBus, provider providers
 		stateManager = state.NewManager(defaultAgent.Workspace)
 	}
 
-	return &AgentLoop{
+	al := &AgentLoop{
 		bus:         msgBus,
 		cfg:         cfg,
 		registry:    registry,
 		state:       stateManager,
 		summarizing: sync.Map{},
 		fallback:    fallbackChain,
+		ledgerForge: ledgerForge,
+		locales:     locale.NewResolver(cfg.Locale),
+	}
+	if cfg.Tools.Approval.Enabled {
+		al.approvals = newApprovalGate(cfg.Tools.Approval)
+		al.approvals.expired = al.expireApproval
+	}
+	if ledgerForge != nil {
+		al.RegisterTool(tools.NewCategoryRulesTool(al))
 	}
+	return al
 }
 
 // registerSharedTools registers tools that are shared across all agents (web, message, spawn).
@@ -85,7 +151,14 @@ func registerSharedTools(
 	msgBus *bus.MessageBus,
 	registry *AgentRegistry,
 	provider providers.LLMProvider,
+	ledgerForge *ledgerforge.Client,
 ) {
+	// One gpio tool for all agents, since it holds the output lines it drives
+	var gpioTool *tools.GPIOTool
+	if cfg.Tools.GPIO.Enabled && len(cfg.Tools.GPIO.Pins) > 0 {
+		gpioTool = tools.NewGPIOTool(cfg.Tools.GPIO)
+	}
+
 	for _, agentID := range registry.ListAgentIDs() {
 		agent, ok := registry.GetAgent(agentID)
 		if !ok {
@@ -94,22 +167,58 @@ func registerSharedTools(
 
 		// Web tools
 		if searchTool := tools.NewWebSearchTool(tools.WebSearchToolOptions{
+			Backend:              cfg.Tools.Web.Backend,
 			BraveAPIKey:          cfg.Tools.Web.Brave.APIKey,
 			BraveMaxResults:      cfg.Tools.Web.Brave.MaxResults,
 			BraveEnabled:         cfg.Tools.Web.Brave.Enabled,
+			BingAPIKey:           cfg.Tools.Web.Bing.APIKey,
+			BingMaxResults:       cfg.Tools.Web.Bing.MaxResults,
+			BingEnabled:          cfg.Tools.Web.Bing.Enabled,
+			SearxNGBaseURL:       cfg.Tools.Web.SearxNG.BaseURL,
+			SearxNGMaxResults:    cfg.Tools.Web.SearxNG.MaxResults,
+			SearxNGEnabled:       cfg.Tools.Web.SearxNG.Enabled,
 			DuckDuckGoMaxResults: cfg.Tools.Web.DuckDuckGo.MaxResults,
 			DuckDuckGoEnabled:    cfg.Tools.Web.DuckDuckGo.Enabled,
 			PerplexityAPIKey:     cfg.Tools.Web.Perplexity.APIKey,
 			PerplexityMaxResults: cfg.Tools.Web.Perplexity.MaxResults,
 			PerplexityEnabled:    cfg.Tools.Web.Perplexity.Enabled,
+			MaxSnippetChars:      cfg.Tools.Web.MaxSnippetChars,
+			AllowDomains:         cfg.Tools.Web.AllowDomains,
+			DenyDomains:          cfg.Tools.Web.DenyDomains,
+			Businesses:           cfg.Tools.Web.Businesses,
 		}); searchTool != nil {
 			agent.Tools.Register(searchTool)
 		}
-		agent.Tools.Register(tools.NewWebFetchTool(50000))
+		// fetch_url supersedes the legacy web_fetch tool when enabled
+		if fetchCfg := cfg.Tools.FetchURL; fetchCfg.Enabled {
+			agent.Tools.Register(tools.NewFetchURLTool(tools.FetchURLOptions{
+				AllowURLs:    fetchCfg.AllowURLs,
+				AllowPrivate: fetchCfg.AllowPrivate,
+				MaxBytes:     fetchCfg.MaxBytes,
+				MaxChars:     fetchCfg.MaxChars,
+				Timeout:      time.Duration(fetchCfg.TimeoutSeconds) * time.Second,
+				CacheTTL:     time.Duration(fetchCfg.CacheTTLSeconds) * time.Second,
+				CacheEntries: fetchCfg.CacheEntries,
+			}))
+		} else {
+			agent.Tools.Register(tools.NewWebFetchTool(50000))
+		}
+		if ledgerForge != nil {
+			agent.Tools.Register(tools.NewLedgerForgeTool(ledgerForge))
+		}
+		// Replaced by SetArtifacts with one that registers the reports
+		agent.Tools.Register(tools.NewPDFReportTool(agent.Workspace, nil))
 
 		// Hardware tools (I2C, SPI) - Linux only, returns error on other platforms
 		agent.Tools.Register(tools.NewI2CTool())
 		agent.Tools.Register(tools.NewSPITool())
+		if gpioTool != nil {
+			agent.Tools.Register(gpioTool)
+		}
+		// Replaced by SetMediaLibrary with one that saves to the library
+		if cfg.Tools.Camera.Enabled {
+			agent.Tools.Register(tools.NewCapturePhotoTool(cfg.Tools.Camera, agent.Workspace, nil))
+		}
 
 		// Message tool
 		messageTool := tools.NewMessageTool()
@@ -122,6 +231,7 @@ func registerSharedTools(
 			return nil
 		})
 		agent.Tools.Register(messageTool)
+		agent.Tools.Register(tools.NewQuickRepliesTool())
 
 		// Skill discovery and installation tools
 		registryMgr := skills.NewRegistryManagerFromConfig(skills.RegistryConfig{
@@ -163,11 +273,39 @@ func (al *AgentLoop) Run(ctx context.Context) error {
 				continue
 			}
 
-			response, err := al.processMessage(ctx, msg)
-			if err != nil {
-				response = fmt.Sprintf("Error processing message: %v", err)
+			wireID := wirelog.NewID()
+			start := time.Now()
+			al.recordWire(wirelog.Entry{
+				ID:         wireID,
+				Direction:  wirelog.DirectionInbound,
+				Source:     msg.Channel,
+				ChatID:     msg.ChatID,
+				SessionKey: msg.SessionKey,
+				Body:       msg.Content,
+			})
+
+			// The wire log ID doubles as the request ID so both can be correlated.
+			msgCtx := tools.WithQuickReplies(withVariantSlot(
+				context.WithValue(ctx, constants.ContextKeyRequestID, wireID)))
+			response, err := al.processMessageRecovered(msgCtx, msg)
+			if errors.Is(err, ErrMaintenance) {
+				response = i18n.T(al.locales.Language("", msg.SenderID), "chat.maintenance")
+			} else if err != nil {
+				response = i18n.T(al.locales.Language("", msg.SenderID), "chat.error", err)
 			}
 
+			al.recordWire(wirelog.Entry{
+				ID:         wireID,
+				Direction:  wirelog.DirectionOutbound,
+				Source:     msg.Channel,
+				ChatID:     msg.ChatID,
+				SessionKey: msg.SessionKey,
+				DurationMS: time.Since(start).Milliseconds(),
+				Body:       response,
+			})
+
+			al.recordResponseEvent(msgCtx, msg.Channel, msg.ChatID, msg.SessionKey, response, err, start)
+
 			if response != "" {
 				// Check if the message tool already sent a response during this round.
 				// If so, skip publishing to avoid duplicate messages to the user.
@@ -184,9 +322,10 @@ func (al *AgentLoop) Run(ctx context.Context) error {
 
 				if !alreadySent {
 					al.bus.PublishOutbound(bus.OutboundMessage{
-						Channel: msg.Channel,
-						ChatID:  msg.ChatID,
-						Content: response,
+						Channel:      msg.Channel,
+						ChatID:       msg.ChatID,
+						Content:      response,
+						QuickReplies: tools.QuickReplies(msgCtx),
 					})
 				}
 			}
@@ -212,6 +351,200 @@ func (al *AgentLoop) SetChannelManager(cm *channels.Manager) {
 	al.channelManager = cm
 }
 
+// SetMediaLibrary lets skills hold uploaded files against cleanup, and has
+// capture_photo store its photos there. When the library encrypts media,
+// read_file and exec get the plaintext of encrypted files.
+func (al *AgentLoop) SetMediaLibrary(l *media.Library) {
+	al.media = l
+	if al.cfg.Tools.Camera.Enabled {
+		for _, agentID := range al.registry.ListAgentIDs() {
+			if agent, ok := al.registry.GetAgent(agentID); ok {
+				agent.Tools.Register(tools.NewCapturePhotoTool(al.cfg.Tools.Camera, agent.Workspace, l))
+			}
+		}
+	}
+	c := l.Cipher()
+	if c == nil {
+		return
+	}
+	for _, agentID := range al.registry.ListAgentIDs() {
+		agent, ok := al.registry.GetAgent(agentID)
+		if !ok {
+			continue
+		}
+		if tool, ok := agent.Tools.Get("read_file"); ok {
+			if rf, ok := tool.(*tools.ReadFileTool); ok {
+				rf.SetDecrypter(c)
+			}
+		}
+		if tool, ok := agent.Tools.Get("exec"); ok {
+			if et, ok := tool.(*tools.ExecTool); ok {
+				et.SetDecrypter(c)
+			}
+		}
+	}
+}
+
+// UseStores moves session history to the stores returned by sessions (one
+// per agent) and workspace state to stateManager, for example to keep them in
+// Postgres. It must be called before the loop handles messages.
+func (al *AgentLoop) UseStores(sessions func(agentID string) session.Store, stateManager *state.Manager) {
+	for _, agentID := range al.registry.ListAgentIDs() {
+		if agent, ok := al.registry.GetAgent(agentID); ok {
+			agent.Sessions = session.NewSessionManagerWithStore(sessions(agentID))
+		}
+	}
+	al.state = stateManager
+}
+
+// SetTranscripts records every turn in s and gives agents the
+// search_history tool.
+func (al *AgentLoop) SetTranscripts(s *transcript.Store) {
+	al.transcripts = s
+	for _, agentID := range al.registry.ListAgentIDs() {
+		if agent, ok := al.registry.GetAgent(agentID); ok {
+			agent.Tools.Register(tools.NewSearchHistoryTool(s))
+		}
+	}
+}
+
+// SearchTranscripts searches recorded turns. It returns no results when
+// transcripts are not enabled.
+func (al *AgentLoop) SearchTranscripts(ctx context.Context, q transcript.Query) ([]transcript.Result, error) {
+	if al.transcripts == nil {
+		return []transcript.Result{}, nil
+	}
+	return al.transcripts.Search(ctx, q)
+}
+
+func (al *AgentLoop) recordTranscript(ctx context.Context, turn transcript.Turn) {
+	if al.transcripts == nil {
+		return
+	}
+	if err := al.transcripts.Record(ctx, turn); err != nil {
+		logger.WarnCF("agent", "Failed to record transcript", map[string]any{"error": err.Error()})
+	}
+}
+
+// SetDocuments gives agents the search_documents tool over s.
+func (al *AgentLoop) SetDocuments(s *documents.Store) {
+	al.RegisterTool(tools.NewSearchDocumentsTool(s))
+}
+
+// SetArtifacts lets skills and the pdf_report tool register the files they
+// create.
+func (al *AgentLoop) SetArtifacts(r *artifact.Registry) {
+	al.artifacts = r
+	for _, agentID := range al.registry.ListAgentIDs() {
+		if agent, ok := al.registry.GetAgent(agentID); ok {
+			agent.Tools.Register(tools.NewPDFReportTool(agent.Workspace, r))
+		}
+	}
+}
+
+// SetReceipts lets chat users confirm, correct and discard the receipts p
+// parks for review with /review.
+func (al *AgentLoop) SetReceipts(p *receipts.Pipeline) {
+	al.receipts = p
+}
+
+// SetWireLog records channel messages and agent replies to the wire log.
+func (al *AgentLoop) SetWireLog(l *wirelog.Log) {
+	al.wireLog = l
+}
+
+// SetEventLog records agent activity to the event log.
+func (al *AgentLoop) SetEventLog(l *eventlog.Log) {
+	al.events = l
+}
+
+// SetWorkPool limits how many agent runs execute at once. Runs wait for a
+// worker in p's queue and fail with workpool.ErrQueueFull when it is full.
+func (al *AgentLoop) SetWorkPool(p *workpool.Pool) {
+	al.pool = p
+}
+
+// SetMaintenance turns maintenance mode on or off. While it is on, user
+// messages, heartbeats and scheduled jobs fail with ErrMaintenance, and chat
+// users are told to try again later.
+func (al *AgentLoop) SetMaintenance(on bool) {
+	if al.maintenance.Swap(on) != on {
+		logger.InfoCF("agent", "Maintenance mode changed", map[string]any{"enabled": on})
+	}
+}
+
+// InMaintenance reports whether maintenance mode is on.
+func (al *AgentLoop) InMaintenance() bool {
+	return al.maintenance.Load()
+}
+
+func (al *AgentLoop) recordEvent(ctx context.Context, e eventlog.Event) {
+	if al.events == nil {
+		return
+	}
+	if _, err := al.events.AppendContext(ctx, e); err != nil {
+		logger.WarnCF("agent", "Failed to record event", map[string]any{"error": err.Error()})
+	}
+}
+
+func (al *AgentLoop) recordResponseEvent(
+	ctx context.Context,
+	channel, chatID, sessionKey, response string,
+	err error,
+	start time.Time,
+) {
+	data := map[string]any{
+		"content_chars": len(response),
+		"duration_ms":   time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		data["error"] = err.Error()
+	}
+	if variant := slotVariant(ctx); variant != "" {
+		data["variant"] = variant
+	}
+	if client, _ := ctx.Value(constants.ContextKeyClient).(string); client != "" {
+		data["client"] = client
+		usage.RecordClientRequest(client, err != nil)
+	}
+	if al.cfg.EventLog.RecordContent {
+		data["content"] = response
+	}
+	al.recordEvent(ctx, eventlog.Event{
+		Type:       eventlog.TypeResponseSent,
+		Channel:    channel,
+		ChatID:     chatID,
+		SessionKey: sessionKey,
+		Data:       data,
+	})
+	if err != nil {
+		return
+	}
+	requestID, _ := ctx.Value(constants.ContextKeyRequestID).(string)
+	businessID, _ := ctx.Value(constants.ContextKeyBusinessID).(string)
+	subscriptions.Publish(subscriptions.Event{
+		Type:       subscriptions.EventResponseCompleted,
+		BusinessID: businessID,
+		Data: map[string]any{
+			"request_id":  requestID,
+			"session_key": sessionKey,
+			"channel":     channel,
+			"chat_id":     chatID,
+			"response":    response,
+			"duration_ms": data["duration_ms"],
+		},
+	})
+}
+
+func (al *AgentLoop) recordWire(entry wirelog.Entry) {
+	if al.wireLog == nil {
+		return
+	}
+	if err := al.wireLog.Record(entry); err != nil {
+		logger.WarnCF("agent", "Failed to record wire log entry", map[string]any{"error": err.Error()})
+	}
+}
+
 // RecordLastChannel records the last active channel for this workspace.
 // This uses the atomic state save mechanism to prevent data loss on crash.
 func (al *AgentLoop) RecordLastChannel(channel string) error {
@@ -247,6 +580,63 @@ func (al *AgentLoop) DefaultWorkspace() string {
 	return ""
 }
 
+// QuerySkillAudit returns recorded skill invocations for an agent.
+// An empty agentID queries the default agent.
+func (al *AgentLoop) QuerySkillAudit(agentID string, q audit.SkillQuery) ([]audit.SkillInvocation, error) {
+	agent := al.registry.GetDefaultAgent()
+	if agentID != "" {
+		var ok bool
+		agent, ok = al.registry.GetAgent(agentID)
+		if !ok {
+			return nil, fmt.Errorf("agent %q not found", agentID)
+		}
+	}
+	if agent == nil || agent.Audit == nil {
+		return []audit.SkillInvocation{}, nil
+	}
+	return agent.Audit.QuerySkills(q)
+}
+
+// Sessions describes the sessions of an agent, most recently updated first.
+// An empty agentID lists the default agent.
+func (al *AgentLoop) Sessions(agentID string) ([]session.Info, error) {
+	agent, err := al.resolveAgent(agentID)
+	if err != nil {
+		return nil, err
+	}
+	return agent.Sessions.List(), nil
+}
+
+// SessionHistory returns the messages of a session of an agent, oldest
+// first. An empty agentID reads the default agent.
+func (al *AgentLoop) SessionHistory(agentID, sessionKey string) ([]providers.Message, error) {
+	agent, err := al.resolveAgent(agentID)
+	if err != nil {
+		return nil, err
+	}
+	return agent.Sessions.GetHistory(sessionKey), nil
+}
+
+// Tools returns the tool registry of an agent. An empty agentID reads the
+// default agent.
+func (al *AgentLoop) Tools(agentID string) (*tools.ToolRegistry, error) {
+	agent, err := al.resolveAgent(agentID)
+	if err != nil {
+		return nil, err
+	}
+	return agent.Tools, nil
+}
+
+// Skills returns the loader of the skills visible to an agent. An empty
+// agentID reads the default agent.
+func (al *AgentLoop) Skills(agentID string) (*skills.SkillsLoader, error) {
+	agent, err := al.resolveAgent(agentID)
+	if err != nil {
+		return nil, err
+	}
+	return agent.ContextBuilder.SkillsLoader(), nil
+}
+
 func (al *AgentLoop) ProcessDirect(ctx context.Context, content, sessionKey string) (string, error) {
 	return al.ProcessDirectWithChannel(ctx, content, sessionKey, "cli", "direct")
 }
@@ -265,19 +655,28 @@ func (al *AgentLoop) ProcessDirectWithChannel(
 		SessionKey: sessionKey,
 	}
 
+	requestID, _ := ctx.Value(constants.ContextKeyRequestID).(string)
+	if requestID == "" {
+		ctx = context.WithValue(ctx, constants.ContextKeyRequestID, wirelog.NewID())
+	}
+	ctx = tools.WithQuickReplies(withVariantSlot(ctx))
+	start := time.Now()
 	response, err := al.processMessage(ctx, msg)
+	al.recordResponseEvent(ctx, channel, chatID, sessionKey, response, err, start)
 	if err != nil {
 		return response, err
 	}
 
 	// processMessage uses SendResponse: false (the main inbound loop handles
 	// sending for regular messages). For direct/cron callers there is no outer
-	// loop, so we publish the response here.
-	if response != "" && channel != "cli" {
+	// loop, so we publish the response here, unless the caller gets it as
+	// the reply to their request.
+	if response != "" && channel != "cli" && !constants.IsRequestChannel(channel) {
 		al.bus.PublishOutbound(bus.OutboundMessage{
-			Channel: channel,
-			ChatID:  chatID,
-			Content: response,
+			Channel:      channel,
+			ChatID:       chatID,
+			Content:      response,
+			QuickReplies: tools.QuickReplies(ctx),
 		})
 	}
 
@@ -287,6 +686,9 @@ func (al *AgentLoop) ProcessDirectWithChannel(
 // ProcessHeartbeat processes a heartbeat request without session history.
 // Each heartbeat is independent and doesn't accumulate context.
 func (al *AgentLoop) ProcessHeartbeat(ctx context.Context, content, channel, chatID string) (string, error) {
+	if al.maintenance.Load() {
+		return "", ErrMaintenance
+	}
 	agent := al.registry.GetDefaultAgent()
 	return al.runAgentLoop(ctx, agent, processOptions{
 		SessionKey:      "heartbeat",
@@ -301,6 +703,20 @@ func (al *AgentLoop) ProcessHeartbeat(ctx context.Context, content, channel, cha
 }
 
 func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage) (string, error) {
+	requestID, _ := ctx.Value(constants.ContextKeyRequestID).(string)
+	if requestID == "" {
+		requestID = wirelog.NewID()
+		ctx = context.WithValue(ctx, constants.ContextKeyRequestID, requestID)
+	}
+	if msg.SenderID != "" {
+		ctx = context.WithValue(ctx, constants.ContextKeySenderID, msg.SenderID)
+	}
+	// Screen group messages before they are logged or reach the model
+	msg, refused := al.filterInput(ctx, msg)
+	if refused {
+		return i18n.T(al.locales.Language("", msg.SenderID), "chat.filtered"), nil
+	}
+
 	// Add message preview to log (show full content for error messages)
 	var logContent string
 	if strings.Contains(msg.Content, "Error:") || strings.Contains(msg.Content, "error") {
@@ -314,19 +730,100 @@ func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage)
 			"chat_id":     msg.ChatID,
 			"sender_id":   msg.SenderID,
 			"session_key": msg.SessionKey,
+			"request_id":  requestID,
 		})
+	telemetry.AddCounter("picoclaw.messages.received", 1, telemetry.String("channel", msg.Channel))
+	received := map[string]any{
+		"sender_id":     msg.SenderID,
+		"content_chars": len(msg.Content),
+		"media":         len(msg.Media),
+	}
+	if client, _ := ctx.Value(constants.ContextKeyClient).(string); client != "" {
+		received["client"] = client
+	}
+	if al.cfg.EventLog.RecordContent {
+		received["content"] = msg.Content
+	}
+	al.recordEvent(ctx, eventlog.Event{
+		Type:       eventlog.TypeRequestReceived,
+		Channel:    msg.Channel,
+		ChatID:     msg.ChatID,
+		SessionKey: msg.SessionKey,
+		Data:       received,
+	})
 
 	// Route system messages to processSystemMessage
 	if msg.Channel == "system" {
 		return al.processSystemMessage(ctx, msg)
 	}
+	if al.maintenance.Load() {
+		return "", ErrMaintenance
+	}
+	ctx = al.withActiveBusiness(ctx, msg)
+	usage.RecordRequest(msg.Channel)
+	if len(msg.Media) > 0 {
+		usage.RecordReceipt()
+	}
 
 	// Check for commands
 	if response, handled := al.handleCommand(ctx, msg); handled {
 		return response, nil
 	}
 
-	// Route to determine agent and session key
+	agent, sessionKey := al.routeMessage(ctx, msg)
+
+	if response, handled := al.handleSessionCommand(ctx, agent, sessionKey, msg); handled {
+		return response, nil
+	}
+	if response, delegated := al.delegate(ctx, agent, sessionKey, msg); delegated {
+		return response, nil
+	}
+	if al.handoffMode() == HandoffLink {
+		if userID := al.handoffUser(ctx, msg); userID != "" {
+			al.handOff(agent, userID, sessionKey, msg.Channel)
+		}
+	}
+
+	// Append media file paths to message content so the agent can reference them.
+	// Include explicit instructions — binary files (PDFs, images) cannot be read
+	// with read_file and must be processed via skill scripts (e.g. oluto-ocr.sh).
+	// Several files are announced as one batch, so the model starts their
+	// processing in a single response and the calls run in parallel.
+	userMessage := msg.Content
+	switch {
+	case len(msg.Media) == 1:
+		path := msg.Media[0]
+		userMessage += fmt.Sprintf(
+			"\n[attached_file: %s]\n"+
+				"IMPORTANT: This is a binary file (PDF/image). Do NOT use read_file on it. "+
+				"Use the exec tool to run the appropriate skill script for processing. "+
+				"For receipts, run: ~/.picoclaw/skills/oluto/scripts/oluto-receipt.sh %s",
+			path, path)
+	case len(msg.Media) > 1:
+		userMessage += fmt.Sprintf("\n[attached_files: %d]\n", len(msg.Media))
+		for _, path := range msg.Media {
+			userMessage += "- " + path + "\n"
+		}
+		userMessage += "IMPORTANT: These are binary files (PDF/image). Do NOT use read_file on them. " +
+			"Use the exec tool to run the appropriate skill script for each file, and make all " +
+			"of these calls in the same response so the files are processed in parallel. " +
+			"For receipts, run: ~/.picoclaw/skills/oluto/scripts/oluto-receipt.sh <file> once per file."
+	}
+
+	return al.runAgentLoop(ctx, agent, processOptions{
+		SessionKey:      sessionKey,
+		Channel:         msg.Channel,
+		ChatID:          msg.ChatID,
+		UserMessage:     userMessage,
+		DefaultResponse: "I've completed processing but have no response to give.",
+		EnableSummary:   true,
+		SendResponse:    false,
+	})
+}
+
+// routeMessage picks the agent that handles msg and the session it belongs
+// to.
+func (al *AgentLoop) routeMessage(ctx context.Context, msg bus.InboundMessage) (*AgentInstance, string) {
 	route := al.registry.ResolveRoute(routing.RouteInput{
 		Channel:    msg.Channel,
 		AccountID:  msg.Metadata["account_id"],
@@ -345,6 +842,15 @@ func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage)
 	sessionKey := route.SessionKey
 	if msg.SessionKey != "" && strings.HasPrefix(msg.SessionKey, "agent:") {
 		sessionKey = msg.SessionKey
+	} else if al.handoffMode() == HandoffUnify {
+		// A user's linked chats and API requests share one session
+		if userID := al.handoffUser(ctx, msg); userID != "" {
+			sessionKey = userSessionKey(agent.ID, userID)
+		}
+	}
+
+	if businessID, _ := ctx.Value(constants.ContextKeyBusinessID).(string); businessID != "" {
+		sessionKey = businessSessionKey(sessionKey, businessID)
 	}
 
 	logger.InfoCF("agent", "Routed message",
@@ -353,31 +859,29 @@ func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage)
 			"session_key": sessionKey,
 			"matched_by":  route.MatchedBy,
 		})
+	return agent, sessionKey
+}
 
-	// Append media file paths to message content so the agent can reference them.
-	// Include explicit instructions — binary files (PDFs, images) cannot be read
-	// with read_file and must be processed via skill scripts (e.g. oluto-ocr.sh).
-	userMessage := msg.Content
-	if len(msg.Media) > 0 {
-		for _, path := range msg.Media {
-			userMessage += fmt.Sprintf(
-				"\n[attached_file: %s]\n"+
-					"IMPORTANT: This is a binary file (PDF/image). Do NOT use read_file on it. "+
-					"Use the exec tool to run the appropriate skill script for processing. "+
-					"For receipts, run: ~/.picoclaw/skills/oluto/scripts/oluto-receipt.sh %s",
-				path, path)
-		}
-	}
-
-	return al.runAgentLoop(ctx, agent, processOptions{
-		SessionKey:      sessionKey,
-		Channel:         msg.Channel,
-		ChatID:          msg.ChatID,
-		UserMessage:     userMessage,
-		DefaultResponse: "I've completed processing but have no response to give.",
-		EnableSummary:   true,
-		SendResponse:    false,
+// ResetSession clears the history and summary of the session that a
+// ProcessDirectWithChannel call with the same arguments would continue, and
+// returns its key.
+func (al *AgentLoop) ResetSession(ctx context.Context, sessionKey, channel, chatID string) (string, error) {
+	agent, key := al.routeMessage(ctx, bus.InboundMessage{
+		Channel:    channel,
+		SenderID:   "cron",
+		ChatID:     chatID,
+		SessionKey: sessionKey,
 	})
+	if agent == nil {
+		return "", fmt.Errorf("no default agent configured")
+	}
+	agent.Sessions.TruncateHistory(key, 0)
+	agent.Sessions.SetSummary(key, "")
+	if err := agent.Sessions.Save(key); err != nil {
+		return "", fmt.Errorf("failed to save session: %w", err)
+	}
+	logger.InfoCF("agent", "Session reset", map[string]any{"agent_id": agent.ID, "session_key": key})
+	return key, nil
 }
 
 func (al *AgentLoop) processSystemMessage(ctx context.Context, msg bus.InboundMessage) (string, error) {
@@ -438,8 +942,15 @@ func (al *AgentLoop) processSystemMessage(ctx context.Context, msg bus.InboundMe
 
 // runAgentLoop is the core message processing logic.
 func (al *AgentLoop) runAgentLoop(ctx context.Context, agent *AgentInstance, opts processOptions) (string, error) {
+	release, err := al.pool.Acquire(ctx)
+	if err != nil {
+		telemetry.AddCounter("picoclaw.requests.rejected", 1, telemetry.String("channel", opts.Channel))
+		return "", err
+	}
+	defer release()
+
 	// 0. Record last channel for heartbeat notifications (skip internal channels)
-	if opts.Channel != "" && opts.ChatID != "" {
+	if opts.Channel != "" && opts.ChatID != "" && !opts.DryRun {
 		// Don't record internal channels (cli, system, subagent)
 		if !constants.IsInternalChannel(opts.Channel) {
 			channelKey := fmt.Sprintf("%s:%s", opts.Channel, opts.ChatID)
@@ -450,7 +961,8 @@ func (al *AgentLoop) runAgentLoop(ctx context.Context, agent *AgentInstance, opt
 	}
 
 	// 0b. Persist auth context per business for heartbeat (JWT + business_id from user requests)
-	if jwtToken, ok := ctx.Value(constants.ContextKeyJWTToken).(string); ok && jwtToken != "" {
+	jwtToken, _ := ctx.Value(constants.ContextKeyJWTToken).(string)
+	if jwtToken != "" && !opts.DryRun && !constants.IsInternalChannel(opts.Channel) {
 		if businessID, ok := ctx.Value(constants.ContextKeyBusinessID).(string); ok && businessID != "" {
 			if err := al.state.SetBusinessAuth(businessID, jwtToken, opts.Channel, opts.ChatID); err != nil {
 				logger.WarnCF("agent", "Failed to record business auth", map[string]any{"error": err.Error()})
@@ -458,10 +970,46 @@ func (al *AgentLoop) runAgentLoop(ctx context.Context, agent *AgentInstance, opt
 		}
 	}
 
+	ctx = context.WithValue(ctx, constants.ContextKeySessionKey, opts.SessionKey)
+	requestID, _ := ctx.Value(constants.ContextKeyRequestID).(string)
+	businessID, _ := ctx.Value(constants.ContextKeyBusinessID).(string)
+	runAttrs := []telemetry.Attr{telemetry.String("agent.id", agent.ID), telemetry.String("channel", opts.Channel)}
+
+	// 0c. Put conversations (not heartbeats) on a side of the model experiment
+	var variant string
+	start := time.Now()
+	if al.experiment != nil && !opts.NoHistory {
+		variant = al.experiment.Variant(opts.SessionKey, businessID)
+		ctx = setVariant(ctx, variant)
+		if variant != VariantControl {
+			agent = al.experiment.apply(agent)
+		}
+		runAttrs = append(runAttrs, telemetry.String("experiment.variant", variant))
+	}
+
+	// 0d. Keep within the spend budget: a cheaper model past it, no request
+	// past its ceiling
+	agent, err = al.applyBudget(agent, businessID)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, span := telemetry.StartSpan(ctx, "agent.run", telemetry.SpanKindInternal,
+		append(runAttrs, telemetry.String("request.id", requestID))...)
+	defer span.End()
+	telemetry.AddCounter("picoclaw.agent.runs", 1, runAttrs...)
+
+	// 0e. Apply a per-request debug flag (API callers) to the session
+	if debug, ok := ctx.Value(constants.ContextKeyDebug).(bool); ok && !opts.NoHistory {
+		al.setSessionDebug(agent, opts.SessionKey, debug)
+	}
+
 	// 1. Update tool contexts
 	al.updateToolContexts(agent, opts.Channel, opts.ChatID)
 
-	// 2. Build messages (skip history for heartbeat)
+	// 2. Build messages (skip history for heartbeat), asking for a reply in
+	// the message's language
+	ctx = al.withReplyLanguage(ctx, opts.UserMessage, businessID)
 	var history []providers.Message
 	var summary string
 	if !opts.NoHistory {
@@ -475,16 +1023,27 @@ func (al *AgentLoop) runAgentLoop(ctx context.Context, agent *AgentInstance, opt
 		nil,
 		opts.Channel,
 		opts.ChatID,
+		businessID,
 	)
+	messages = replyLanguagePrompt(ctx, messages)
+	messages = al.experimentPrompt(ctx, messages)
 
 	// 3. Save user message to session
+	if variant != "" && len(history) > 0 && isCorrection(opts.UserMessage) {
+		usage.RecordCorrection(variant)
+	}
 	agent.Sessions.AddMessage(opts.SessionKey, "user", opts.UserMessage)
 
 	// 4. Run LLM iteration loop
-	finalContent, iteration, err := al.runLLMIteration(ctx, agent, messages, opts)
+	finalContent, iteration, toolCalls, err := al.runLLMIteration(ctx, agent, messages, opts)
+	if variant != "" {
+		usage.RecordVariantRequest(variant, time.Since(start), err != nil)
+	}
 	if err != nil {
+		span.RecordError(err)
 		return "", err
 	}
+	span.SetAttributes(telemetry.Int("agent.iterations", iteration))
 
 	// If last tool had ForUser content and we already sent it, we might not need to send final response
 	// This is controlled by the tool's Silent flag and ForUser content
@@ -497,6 +1056,18 @@ func (al *AgentLoop) runAgentLoop(ctx context.Context, agent *AgentInstance, opt
 	// 6. Save final assistant message to session
 	agent.Sessions.AddMessage(opts.SessionKey, "assistant", finalContent)
 	agent.Sessions.Save(opts.SessionKey)
+	if !opts.NoHistory {
+		al.recordTranscript(ctx, transcript.Turn{
+			AgentID:    agent.ID,
+			SessionKey: opts.SessionKey,
+			Channel:    opts.Channel,
+			ChatID:     opts.ChatID,
+			BusinessID: businessID,
+			Message:    opts.UserMessage,
+			Response:   finalContent,
+			ToolCalls:  toolCalls,
+		})
+	}
 
 	// 7. Optional: summarization
 	if opts.EnableSummary {
@@ -506,21 +1077,25 @@ func (al *AgentLoop) runAgentLoop(ctx context.Context, agent *AgentInstance, opt
 	// 8. Optional: send response via bus
 	if opts.SendResponse {
 		al.bus.PublishOutbound(bus.OutboundMessage{
-			Channel: opts.Channel,
-			ChatID:  opts.ChatID,
-			Content: finalContent,
+			Channel:      opts.Channel,
+			ChatID:       opts.ChatID,
+			Content:      finalContent,
+			QuickReplies: tools.QuickReplies(ctx),
 		})
 	}
 
 	// 9. Log response
 	responsePreview := utils.Truncate(finalContent, 120)
-	logger.InfoCF("agent", fmt.Sprintf("Response: %s", responsePreview),
-		map[string]any{
-			"agent_id":     agent.ID,
-			"session_key":  opts.SessionKey,
-			"iterations":   iteration,
-			"final_length": len(finalContent),
-		})
+	fields := map[string]any{
+		"agent_id":     agent.ID,
+		"session_key":  opts.SessionKey,
+		"iterations":   iteration,
+		"final_length": len(finalContent),
+	}
+	if variant != "" {
+		fields["variant"] = variant
+	}
+	logger.InfoCF("agent", fmt.Sprintf("Response: %s", responsePreview), fields)
 
 	return finalContent, nil
 }
@@ -531,9 +1106,11 @@ func (al *AgentLoop) runLLMIteration(
 	agent *AgentInstance,
 	messages []providers.Message,
 	opts processOptions,
-) (string, int, error) {
+) (string, int, []transcript.ToolCall, error) {
 	iteration := 0
 	var finalContent string
+	var toolCalls []transcript.ToolCall
+	requestID, _ := ctx.Value(constants.ContextKeyRequestID).(string)
 
 	for iteration < agent.MaxIterations {
 		iteration++
@@ -568,6 +1145,11 @@ func (al *AgentLoop) runLLMIteration(
 				"messages_json": formatMessagesForLog(messages),
 				"tools_json":    formatToolsForLog(providerToolDefs),
 			})
+		al.traceDebug(agent, opts.SessionKey, debugEventLLMRequest, iteration, map[string]any{
+			"model":    agent.Model,
+			"messages": messages,
+			"tools":    providerToolDefs,
+		})
 
 		// Call LLM with fallback chain if candidates are configured.
 		var response *providers.LLMResponse
@@ -602,7 +1184,7 @@ func (al *AgentLoop) runLLMIteration(
 		// Retry loop for context/token errors
 		maxRetries := 2
 		for retry := 0; retry <= maxRetries; retry++ {
-			response, err = callLLM()
+			response, err = al.tracedLLMCall(ctx, agent, callLLM)
 			if err == nil {
 				break
 			}
@@ -630,10 +1212,13 @@ func (al *AgentLoop) runLLMIteration(
 				al.forceCompression(agent, opts.SessionKey)
 				newHistory := agent.Sessions.GetHistory(opts.SessionKey)
 				newSummary := agent.Sessions.GetSummary(opts.SessionKey)
+				businessID, _ := ctx.Value(constants.ContextKeyBusinessID).(string)
 				messages = agent.ContextBuilder.BuildMessages(
 					newHistory, newSummary, "",
-					nil, opts.Channel, opts.ChatID,
+					nil, opts.Channel, opts.ChatID, businessID,
 				)
+				messages = replyLanguagePrompt(ctx, messages)
+				messages = al.experimentPrompt(ctx, messages)
 				continue
 			}
 			break
@@ -646,9 +1231,22 @@ func (al *AgentLoop) runLLMIteration(
 					"iteration": iteration,
 					"error":     err.Error(),
 				})
-			return "", iteration, fmt.Errorf("LLM call failed after retries: %w", err)
+			usage.RecordError("provider")
+			reporting.CaptureError("provider", err, map[string]any{
+				"agent_id": agent.ID,
+				"model":    agent.Model,
+				"channel":  opts.Channel,
+			})
+			return "", iteration, toolCalls, fmt.Errorf("LLM call failed after retries: %w", err)
 		}
 
+		al.traceDebug(agent, opts.SessionKey, debugEventLLMResponse, iteration, map[string]any{
+			"content":       response.Content,
+			"tool_calls":    response.ToolCalls,
+			"finish_reason": response.FinishReason,
+			"usage":         response.Usage,
+		})
+
 		// Check if no tool calls - we're done
 		if len(response.ToolCalls) == 0 {
 			finalContent = response.Content
@@ -671,6 +1269,12 @@ func (al *AgentLoop) runLLMIteration(
 		for _, tc := range normalizedToolCalls {
 			toolNames = append(toolNames, tc.Name)
 		}
+		if response.Content != "" {
+			reportProgress(ctx, Progress{Type: ProgressMessage, Content: response.Content})
+		}
+		for _, tc := range normalizedToolCalls {
+			reportProgress(ctx, Progress{Type: ProgressToolCall, Tool: tc.Name})
+		}
 		logger.InfoCF("agent", "LLM requested tool calls",
 			map[string]any{
 				"agent_id":  agent.ID,
@@ -711,42 +1315,18 @@ func (al *AgentLoop) runLLMIteration(
 		// Save assistant message with tool calls to session
 		agent.Sessions.AddFullMessage(opts.SessionKey, assistantMsg)
 
-		// Execute tool calls
-		for _, tc := range normalizedToolCalls {
+		// Execute tool calls. The calls of one response are independent, so
+		// up to agent.ParallelTools run at once; their results are
+		// handled in call order.
+		toolResults := al.executeToolCalls(ctx, agent, normalizedToolCalls, opts, iteration, requestID)
+		for i, tc := range normalizedToolCalls {
 			argsJSON, _ := json.Marshal(tc.Arguments)
-			argsPreview := utils.Truncate(string(argsJSON), 200)
-			logger.InfoCF("agent", fmt.Sprintf("Tool call: %s(%s)", tc.Name, argsPreview),
-				map[string]any{
-					"agent_id":  agent.ID,
-					"tool":      tc.Name,
-					"iteration": iteration,
-				})
+			toolResult := toolResults[i]
 
-			// Create async callback for tools that implement AsyncTool
-			// NOTE: Following openclaw's design, async tools do NOT send results directly to users.
-			// Instead, they notify the agent via PublishInbound, and the agent decides
-			// whether to forward the result to the user (in processSystemMessage).
-			asyncCallback := func(callbackCtx context.Context, result *tools.ToolResult) {
-				// Log the async completion but don't send directly to user
-				// The agent will handle user notification via processSystemMessage
-				if !result.Silent && result.ForUser != "" {
-					logger.InfoCF("agent", "Async tool completed, agent will handle notification",
-						map[string]any{
-							"tool":        tc.Name,
-							"content_len": len(result.ForUser),
-						})
-				}
+			if !toolResult.Silent && toolResult.ForUser != "" {
+				reportProgress(ctx, Progress{Type: ProgressMessage, Content: toolResult.ForUser})
 			}
 
-			toolResult := agent.Tools.ExecuteWithContext(
-				ctx,
-				tc.Name,
-				tc.Arguments,
-				opts.Channel,
-				opts.ChatID,
-				asyncCallback,
-			)
-
 			// Send ForUser content to user immediately if not Silent
 			if !toolResult.Silent && toolResult.ForUser != "" && opts.SendResponse {
 				al.bus.PublishOutbound(bus.OutboundMessage{
@@ -766,6 +1346,17 @@ func (al *AgentLoop) runLLMIteration(
 			if contentForLLM == "" && toolResult.Err != nil {
 				contentForLLM = toolResult.Err.Error()
 			}
+			toolTrace := map[string]any{
+				"id":       tc.ID,
+				"tool":     tc.Name,
+				"for_llm":  contentForLLM,
+				"for_user": toolResult.ForUser,
+				"silent":   toolResult.Silent,
+			}
+			if toolResult.Err != nil {
+				toolTrace["error"] = toolResult.Err.Error()
+			}
+			al.traceDebug(agent, opts.SessionKey, debugEventToolResult, iteration, toolTrace)
 
 			toolResultMsg := providers.Message{
 				Role:       "tool",
@@ -776,10 +1367,187 @@ func (al *AgentLoop) runLLMIteration(
 
 			// Save tool result message to session
 			agent.Sessions.AddFullMessage(opts.SessionKey, toolResultMsg)
+
+			call := transcript.ToolCall{Name: tc.Name, Arguments: string(argsJSON), Result: contentForLLM}
+			if toolResult.Err != nil {
+				call.Error = toolResult.Err.Error()
+			}
+			toolCalls = append(toolCalls, call)
+		}
+
+		// Gated calls stop the run until the user decides on them
+		if prompt := al.approvalPrompt(ctx, opts, toolResults); prompt != "" {
+			finalContent = prompt
+			break
+		}
+	}
+
+	return finalContent, iteration, toolCalls, nil
+}
+
+// executeToolCalls runs the tool calls of one LLM response, up to
+// agent.ParallelTools at a time, and returns their results in call order.
+func (al *AgentLoop) executeToolCalls(
+	ctx context.Context,
+	agent *AgentInstance,
+	calls []providers.ToolCall,
+	opts processOptions,
+	iteration int,
+	requestID string,
+) []*tools.ToolResult {
+	for _, tc := range calls {
+		argsJSON, _ := json.Marshal(tc.Arguments)
+		argsPreview := utils.Truncate(string(argsJSON), 200)
+		logger.InfoCF("agent", fmt.Sprintf("Tool call: %s(%s)", tc.Name, argsPreview),
+			map[string]any{
+				"agent_id":   agent.ID,
+				"tool":       tc.Name,
+				"iteration":  iteration,
+				"request_id": requestID,
+			})
+		al.traceDebug(agent, opts.SessionKey, debugEventToolCall, iteration, map[string]any{
+			"id":        tc.ID,
+			"tool":      tc.Name,
+			"arguments": tc.Arguments,
+		})
+	}
+
+	execute := func(tc providers.ToolCall) *tools.ToolResult {
+		if opts.DryRun {
+			return tools.SilentResult(fmt.Sprintf("Dry run: %s was not called.", tc.Name))
+		}
+		if al.approvals != nil && al.approvals.requires(tc) {
+			return al.holdToolCall(ctx, agent, tc, opts)
+		}
+		// Create async callback for tools that implement AsyncTool
+		// NOTE: Following openclaw's design, async tools do NOT send results directly to users.
+		// Instead, they notify the agent via PublishInbound, and the agent decides
+		// whether to forward the result to the user (in processSystemMessage).
+		asyncCallback := func(callbackCtx context.Context, result *tools.ToolResult) {
+			// Log the async completion but don't send directly to user
+			// The agent will handle user notification via processSystemMessage
+			if !result.Silent && result.ForUser != "" {
+				logger.InfoCF("agent", "Async tool completed, agent will handle notification",
+					map[string]any{
+						"tool":        tc.Name,
+						"content_len": len(result.ForUser),
+					})
+			}
+		}
+
+		// Trace what the call adds to memory, so forgetting the session removes it
+		memoryFile := memoryTarget(agent.Workspace, tc)
+		var memoryBefore []string
+		if memoryFile != "" {
+			memoryBefore = readLines(memoryFile)
+		}
+
+		toolStart := time.Now()
+		toolResult := agent.Tools.ExecuteWithContext(
+			ctx,
+			tc.Name,
+			tc.Arguments,
+			opts.Channel,
+			opts.ChatID,
+			asyncCallback,
+		)
+		if memoryFile != "" && opts.SessionKey != "" && !toolResult.IsError {
+			traceMemoryWrite(agent.Workspace, opts.SessionKey, memoryFile, memoryBefore)
+		}
+		toolEvent := map[string]any{
+			"tool":        tc.Name,
+			"iteration":   iteration,
+			"duration_ms": time.Since(toolStart).Milliseconds(),
+		}
+		if toolResult.Err != nil {
+			toolEvent["error"] = toolResult.Err.Error()
+		}
+		al.recordEvent(ctx, eventlog.Event{
+			Type:    eventlog.TypeToolCalled,
+			AgentID: agent.ID,
+			Channel: opts.Channel,
+			ChatID:  opts.ChatID,
+			Data:    toolEvent,
+		})
+		return toolResult
+	}
+
+	results := make([]*tools.ToolResult, len(calls))
+	if agent.ParallelTools <= 1 || len(calls) == 1 {
+		for i, tc := range calls {
+			results[i] = execute(tc)
 		}
+		return results
+	}
+
+	sem := make(chan struct{}, agent.ParallelTools)
+	var wg sync.WaitGroup
+	for i, tc := range calls {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = execute(tc)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// tracedLLMCall runs one LLM call inside a span and records its latency and
+// token usage.
+func (al *AgentLoop) tracedLLMCall(
+	ctx context.Context,
+	agent *AgentInstance,
+	call func() (*providers.LLMResponse, error),
+) (*providers.LLMResponse, error) {
+	model := telemetry.String("llm.model", agent.Model)
+	_, span := telemetry.StartSpan(ctx, "llm.chat", telemetry.SpanKindClient, model)
+	defer span.End()
+
+	start := time.Now()
+	response, err := call()
+	telemetry.RecordDuration("picoclaw.llm.duration", time.Since(start), model, telemetry.Bool("error", err != nil))
+	alerts.RecordProvider(err)
+
+	event := map[string]any{
+		"model":       agent.Model,
+		"duration_ms": time.Since(start).Milliseconds(),
 	}
+	variant, _ := ctx.Value(constants.ContextKeyVariant).(string)
+	if variant != "" {
+		event["variant"] = variant
+	}
+	if err != nil {
+		event["error"] = err.Error()
+	} else if response.Usage != nil {
+		event["prompt_tokens"] = response.Usage.PromptTokens
+		event["completion_tokens"] = response.Usage.CompletionTokens
+	}
+	al.recordEvent(ctx, eventlog.Event{Type: eventlog.TypeProviderCall, AgentID: agent.ID, Data: event})
 
-	return finalContent, iteration, nil
+	if err != nil {
+		span.RecordError(err)
+		telemetry.AddCounter("picoclaw.llm.errors", 1, model)
+		return nil, err
+	}
+	if response.Usage != nil {
+		span.SetAttributes(
+			telemetry.Int("llm.prompt_tokens", response.Usage.PromptTokens),
+			telemetry.Int("llm.completion_tokens", response.Usage.CompletionTokens),
+		)
+		telemetry.AddCounter("picoclaw.llm.tokens", int64(response.Usage.PromptTokens),
+			model, telemetry.String("type", "prompt"))
+		telemetry.AddCounter("picoclaw.llm.tokens", int64(response.Usage.CompletionTokens),
+			model, telemetry.String("type", "completion"))
+		usage.RecordTokens(agent.Model, response.Usage.PromptTokens, response.Usage.CompletionTokens)
+		al.recordSpend(ctx, agent.Model, response.Usage)
+		if variant != "" {
+			usage.RecordVariantTokens(variant, agent.Model, response.Usage.PromptTokens, response.Usage.CompletionTokens)
+		}
+	}
+	return response, nil
 }
 
 // updateToolContexts updates the context for tools that need channel/chatID info.
@@ -802,17 +1570,41 @@ func (al *AgentLoop) updateToolContexts(agent *AgentInstance, channel, chatID st
 	}
 }
 
+// processMessageRecovered runs processMessage, turning a panic into a crash
+// report and an error so one bad message doesn't stop the agent loop.
+func (al *AgentLoop) processMessageRecovered(ctx context.Context, msg bus.InboundMessage) (response string, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			id := crash.Report("agent", recovered, map[string]any{
+				"channel":     msg.Channel,
+				"chat_id":     msg.ChatID,
+				"session_key": msg.SessionKey,
+			})
+			err = fmt.Errorf("internal error")
+			if id != "" {
+				err = fmt.Errorf("internal error (crash report %s)", id)
+			}
+		}
+	}()
+	return al.processMessage(ctx, msg)
+}
+
 // maybeSummarize triggers summarization if the session history exceeds thresholds.
 func (al *AgentLoop) maybeSummarize(agent *AgentInstance, sessionKey, channel, chatID string) {
 	newHistory := agent.Sessions.GetHistory(sessionKey)
-	tokenEstimate := al.estimateTokens(newHistory)
+	tokenEstimate := providers.CountTokens(agent.Provider, newHistory, nil, agent.Model)
 	threshold := agent.ContextWindow * 75 / 100
 
-	if len(newHistory) > 20 || tokenEstimate > threshold {
+	if len(newHistory) > agent.MaxHistory || tokenEstimate > threshold {
 		summarizeKey := agent.ID + ":" + sessionKey
 		if _, loading := al.summarizing.LoadOrStore(summarizeKey, true); !loading {
 			go func() {
 				defer al.summarizing.Delete(summarizeKey)
+				defer func() {
+					if recovered := recover(); recovered != nil {
+						crash.Report("agent", recovered, map[string]any{"session_key": sessionKey})
+					}
+				}()
 				if !constants.IsInternalChannel(channel) {
 					al.bus.PublishOutbound(bus.OutboundMessage{
 						Channel: channel,
@@ -982,7 +1774,7 @@ func (al *AgentLoop) summarizeSession(agent *AgentInstance, sessionKey string) {
 		if m.Role != "user" && m.Role != "assistant" {
 			continue
 		}
-		msgTokens := len(m.Content) / 2
+		msgTokens := tokenizer.Count(m.Content)
 		if msgTokens > maxMessageTokens {
 			omitted = true
 			continue
@@ -1075,18 +1867,6 @@ func (al *AgentLoop) summarizeBatch(
 	return response.Content, nil
 }
 
-// estimateTokens estimates the number of tokens in a message list.
-// Uses a safe heuristic of 2.5 characters per token to account for CJK and other
-// overheads better than the previous 3 chars/token.
-func (al *AgentLoop) estimateTokens(messages []providers.Message) int {
-	totalChars := 0
-	for _, m := range messages {
-		totalChars += utf8.RuneCountInString(m.Content)
-	}
-	// 2.5 chars per token = totalChars * 2 / 5
-	return totalChars * 2 / 5
-}
-
 func (al *AgentLoop) handleCommand(ctx context.Context, msg bus.InboundMessage) (string, bool) {
 	content := strings.TrimSpace(msg.Content)
 	if !strings.HasPrefix(content, "/") {
@@ -1100,54 +1880,76 @@ func (al *AgentLoop) handleCommand(ctx context.Context, msg bus.InboundMessage)
 
 	cmd := parts[0]
 	args := parts[1:]
+	lang := al.language(ctx, msg)
 
 	switch cmd {
 	case "/show":
 		if len(args) < 1 {
-			return "Usage: /show [model|channel|agents]", true
+			return i18n.T(lang, "cmd.show.usage"), true
 		}
 		switch args[0] {
 		case "model":
 			defaultAgent := al.registry.GetDefaultAgent()
 			if defaultAgent == nil {
-				return "No default agent configured", true
+				return i18n.T(lang, "cmd.no_default_agent"), true
 			}
-			return fmt.Sprintf("Current model: %s", defaultAgent.Model), true
+			return i18n.T(lang, "cmd.current_model", defaultAgent.Model), true
 		case "channel":
-			return fmt.Sprintf("Current channel: %s", msg.Channel), true
+			return i18n.T(lang, "cmd.current_channel", msg.Channel), true
 		case "agents":
 			agentIDs := al.registry.ListAgentIDs()
-			return fmt.Sprintf("Registered agents: %s", strings.Join(agentIDs, ", ")), true
+			return i18n.T(lang, "cmd.agents", strings.Join(agentIDs, ", ")), true
 		default:
-			return fmt.Sprintf("Unknown show target: %s", args[0]), true
+			return i18n.T(lang, "cmd.show.unknown", args[0]), true
 		}
 
 	case "/list":
 		if len(args) < 1 {
-			return "Usage: /list [models|channels|agents]", true
+			return i18n.T(lang, "cmd.list.usage"), true
 		}
 		switch args[0] {
 		case "models":
-			return "Available models: configured in config.json per agent", true
+			return i18n.T(lang, "cmd.list.models"), true
 		case "channels":
 			if al.channelManager == nil {
-				return "Channel manager not initialized", true
+				return i18n.T(lang, "cmd.no_channel_mgr"), true
 			}
 			channels := al.channelManager.GetEnabledChannels()
 			if len(channels) == 0 {
-				return "No channels enabled", true
+				return i18n.T(lang, "cmd.no_channels"), true
 			}
-			return fmt.Sprintf("Enabled channels: %s", strings.Join(channels, ", ")), true
+			return i18n.T(lang, "cmd.channels", strings.Join(channels, ", ")), true
 		case "agents":
 			agentIDs := al.registry.ListAgentIDs()
-			return fmt.Sprintf("Registered agents: %s", strings.Join(agentIDs, ", ")), true
+			return i18n.T(lang, "cmd.agents", strings.Join(agentIDs, ", ")), true
 		default:
-			return fmt.Sprintf("Unknown list target: %s", args[0]), true
+			return i18n.T(lang, "cmd.list.unknown", args[0]), true
 		}
 
+	case "/status":
+		return al.statusReport(ctx), true
+
+	case "/heartbeat":
+		return al.heartbeatCommand(ctx, msg, args), true
+
+	case "/business":
+		return al.businessCommand(ctx, msg, args), true
+
+	case "/review":
+		return al.reviewCommand(ctx, msg, args), true
+
+	case "/categorize":
+		return al.categorizeCommand(ctx, msg, args), true
+
+	case "/link":
+		return al.linkCommand(ctx, msg, args), true
+
+	case "/workflow":
+		return al.workflowCommand(ctx, msg, args), true
+
 	case "/switch":
 		if len(args) < 3 || args[1] != "to" {
-			return "Usage: /switch [model|channel] to <name>", true
+			return i18n.T(lang, "cmd.switch.usage"), true
 		}
 		target := args[0]
 		value := args[2]
@@ -1156,27 +1958,156 @@ func (al *AgentLoop) handleCommand(ctx context.Context, msg bus.InboundMessage)
 		case "model":
 			defaultAgent := al.registry.GetDefaultAgent()
 			if defaultAgent == nil {
-				return "No default agent configured", true
+				return i18n.T(lang, "cmd.no_default_agent"), true
 			}
 			oldModel := defaultAgent.Model
 			defaultAgent.Model = value
-			return fmt.Sprintf("Switched model from %s to %s", oldModel, value), true
+			return i18n.T(lang, "cmd.switch.model", oldModel, value), true
 		case "channel":
 			if al.channelManager == nil {
-				return "Channel manager not initialized", true
+				return i18n.T(lang, "cmd.no_channel_mgr"), true
 			}
 			if _, exists := al.channelManager.GetChannel(value); !exists && value != "cli" {
-				return fmt.Sprintf("Channel '%s' not found or not enabled", value), true
+				return i18n.T(lang, "cmd.channel_not_found", value), true
 			}
-			return fmt.Sprintf("Switched target channel to %s", value), true
+			return i18n.T(lang, "cmd.switch.channel", value), true
 		default:
-			return fmt.Sprintf("Unknown switch target: %s", target), true
+			return i18n.T(lang, "cmd.switch.unknown", target), true
 		}
 	}
 
 	return "", false
 }
 
+// language is the language to answer a chat command in: the sender's, else
+// that of the chat's business.
+func (al *AgentLoop) language(ctx context.Context, msg bus.InboundMessage) string {
+	businessID, _ := ctx.Value(constants.ContextKeyBusinessID).(string)
+	return al.locales.Language(businessID, msg.SenderID)
+}
+
+// statusReport describes the running agent for the /status command.
+func (al *AgentLoop) statusReport(ctx context.Context) string {
+	lines := []string{fmt.Sprintf("Agents: %s", strings.Join(al.registry.ListAgentIDs(), ", "))}
+	if defaultAgent := al.registry.GetDefaultAgent(); defaultAgent != nil {
+		lines = append(lines, fmt.Sprintf("Model: %s", defaultAgent.Model))
+	}
+	if al.media != nil {
+		usage, err := al.media.Usage()
+		if err != nil {
+			lines = append(lines, fmt.Sprintf("Storage: unavailable (%v)", err))
+		} else {
+			businessID, _ := ctx.Value(constants.ContextKeyBusinessID).(string)
+			lines = append(lines, usage.Format(businessID))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// heartbeatCommand turns the heartbeat of the chat's business on or off. The
+// business is the request's, or else the one last used from this chat.
+func (al *AgentLoop) heartbeatCommand(ctx context.Context, msg bus.InboundMessage, args []string) string {
+	lang := al.language(ctx, msg)
+	if al.state == nil {
+		return i18n.T(lang, "heartbeat.unavailable")
+	}
+	businessID, _ := al.chatBusiness(ctx, msg)
+	if businessID == "" {
+		return i18n.T(lang, "heartbeat.no_business")
+	}
+	lang = al.locales.Language(businessID, msg.SenderID)
+
+	action := "status"
+	if len(args) > 0 {
+		action = args[0]
+	}
+	switch action {
+	case "on", "off":
+		if err := al.state.SetBusinessHeartbeat(businessID, action == "on"); err != nil {
+			return i18n.T(lang, "heartbeat.save_failed", err)
+		}
+		return i18n.T(lang, "heartbeat.turned_"+action, businessID)
+	case "status":
+		return al.heartbeatStatus(lang, businessID)
+	default:
+		return i18n.T(lang, "heartbeat.usage")
+	}
+}
+
+// heartbeatStatus describes a business's heartbeat schedule.
+func (al *AgentLoop) heartbeatStatus(lang, businessID string) string {
+	hb := al.cfg.Heartbeat
+	sched := hb.Businesses[businessID]
+	enabled := sched.Enabled == nil || *sched.Enabled
+	if on, set := al.state.BusinessHeartbeat(businessID); set {
+		enabled = on
+	}
+	if !hb.Enabled {
+		return i18n.T(lang, "heartbeat.gateway_off")
+	}
+	if !enabled {
+		return i18n.T(lang, "heartbeat.is_off", businessID)
+	}
+	interval := hb.Interval
+	if sched.Interval > 0 {
+		interval = sched.Interval
+	}
+	if interval == 0 {
+		interval = 30
+	}
+	interval = max(interval, 5)
+	status := i18n.T(lang, "heartbeat.is_on", businessID, interval)
+	if sched.Window != "" {
+		status += i18n.T(lang, "heartbeat.during", sched.Window)
+		if tz := cmp.Or(sched.Timezone, al.locales.For(businessID).Timezone); tz != "" {
+			status += " " + tz
+		}
+	}
+	if sched.Skill != "" {
+		status += i18n.T(lang, "heartbeat.using_skill", sched.Skill)
+	}
+	return status + i18n.T(lang, "heartbeat.end")
+}
+
+// handleSessionCommand handles commands that act on the routed session.
+func (al *AgentLoop) handleSessionCommand(
+	ctx context.Context,
+	agent *AgentInstance,
+	sessionKey string,
+	msg bus.InboundMessage,
+) (string, bool) {
+	parts := strings.Fields(strings.TrimSpace(msg.Content))
+	if len(parts) > 0 && parts[0] == "/forget" {
+		return al.forgetCommand(ctx, agent, sessionKey, msg, parts[1:]), true
+	}
+	if len(parts) > 0 && (parts[0] == "/approve" || parts[0] == "/reject") {
+		return al.approvalCommand(ctx, sessionKey, msg, parts), true
+	}
+	if len(parts) == 0 || parts[0] != "/debug" {
+		return "", false
+	}
+	lang := al.language(ctx, msg)
+
+	if len(parts) < 2 {
+		parts = append(parts, "status")
+	}
+	switch parts[1] {
+	case "on":
+		al.setSessionDebug(agent, sessionKey, true)
+		return i18n.T(lang, "cmd.debug.on", debugFilePath(agent.Workspace, sessionKey)), true
+	case "off":
+		al.setSessionDebug(agent, sessionKey, false)
+		return i18n.T(lang, "cmd.debug.off"), true
+	case "status":
+		if agent.Sessions.IsDebug(sessionKey) {
+			return i18n.T(lang, "cmd.debug.is_on", sessionKey), true
+		}
+		return i18n.T(lang, "cmd.debug.is_off", sessionKey), true
+	default:
+		return i18n.T(lang, "cmd.debug.usage"), true
+	}
+}
+
 // extractPeer extracts the routing peer from inbound message metadata.
 func extractPeer(msg bus.InboundMessage) *routing.RoutePeer {
 	peerKind := msg.Metadata["peer_kind"]
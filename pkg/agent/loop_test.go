@@ -2,16 +2,22 @@ package agent
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/constants"
 	"github.com/sipeed/picoclaw/pkg/providers"
 	"github.com/sipeed/picoclaw/pkg/tools"
+	"github.com/sipeed/picoclaw/pkg/transcript"
 )
 
 func TestRecordLastChannel(t *testing.T) {
@@ -479,6 +485,235 @@ func TestToolResult_SilentToolDoesNotSendUserMessage(t *testing.T) {
 	}
 }
 
+func TestMaintenance_RejectsMessages(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &simpleMockProvider{response: "hi"})
+	ctx := context.Background()
+
+	al.SetMaintenance(true)
+	if !al.InMaintenance() {
+		t.Fatal("Expected maintenance mode to be on")
+	}
+	if _, err := al.ProcessDirect(ctx, "hello", "test-session"); !errors.Is(err, ErrMaintenance) {
+		t.Errorf("Expected ErrMaintenance, got %v", err)
+	}
+	if _, err := al.ProcessHeartbeat(ctx, "check", "cli", "direct"); !errors.Is(err, ErrMaintenance) {
+		t.Errorf("Expected ErrMaintenance for heartbeat, got %v", err)
+	}
+
+	al.SetMaintenance(false)
+	response, err := al.ProcessDirect(ctx, "hello", "test-session")
+	if err != nil || response != "hi" {
+		t.Errorf("Expected normal reply after maintenance, got %q, %v", response, err)
+	}
+}
+
+func TestHeartbeatCommand_TogglesChatBusiness(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+		Heartbeat: config.HeartbeatConfig{
+			Enabled:  true,
+			Interval: 30,
+			Businesses: map[string]config.BusinessHeartbeatConfig{
+				"biz-1": {Interval: 60, Window: "Mon-Fri 09:00-17:00"},
+			},
+		},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &simpleMockProvider{response: "hi"})
+	al.state.SetBusinessAuth("biz-1", "jwt", "telegram", "chat-1")
+	ctx := context.Background()
+	msg := func(content string) bus.InboundMessage {
+		return bus.InboundMessage{Channel: "telegram", ChatID: "chat-1", Content: content}
+	}
+
+	reply, handled := al.handleCommand(ctx, msg("/heartbeat"))
+	if !handled || !strings.Contains(reply, "every 60 minutes during Mon-Fri 09:00-17:00") {
+		t.Errorf("Unexpected status: %q", reply)
+	}
+
+	al.handleCommand(ctx, msg("/heartbeat off"))
+	if enabled, set := al.state.BusinessHeartbeat("biz-1"); !set || enabled {
+		t.Errorf("Expected heartbeat off for biz-1, got enabled=%v set=%v", enabled, set)
+	}
+	if reply, _ := al.handleCommand(ctx, msg("/heartbeat")); !strings.Contains(reply, "off") {
+		t.Errorf("Expected status to say off, got %q", reply)
+	}
+
+	other := bus.InboundMessage{Channel: "telegram", ChatID: "chat-2", Content: "/heartbeat off"}
+	if reply, _ := al.handleCommand(ctx, other); !strings.Contains(reply, "not linked to a business") {
+		t.Errorf("Expected unlinked chat to be refused, got %q", reply)
+	}
+}
+
+func TestBusinessCommand_SwitchesChatBusiness(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &simpleMockProvider{response: "hi"})
+	al.state.SetBusinessAuth("biz-1", "jwt", "telegram", "chat-1")
+	al.state.SetBusinessAuth("biz-2", "jwt", "telegram", "chat-1")
+	ctx := context.Background()
+	send := func(chatID, content string) string {
+		reply, err := al.processMessage(ctx, bus.InboundMessage{Channel: "telegram", ChatID: chatID, Content: content})
+		if err != nil {
+			t.Fatalf("processMessage(%q) error: %v", content, err)
+		}
+		return reply
+	}
+
+	if reply := send("chat-1", "/business list"); !strings.Contains(reply, "- biz-1\n- biz-2") {
+		t.Errorf("Unexpected list: %q", reply)
+	}
+	if reply := send("chat-1", "/business switch biz-3"); !strings.Contains(reply, "not found") {
+		t.Errorf("Expected unknown business to be refused, got %q", reply)
+	}
+	if reply := send("chat-1", "/business switch biz-2"); !strings.Contains(reply, "Switched to biz-2") {
+		t.Errorf("Unexpected switch reply: %q", reply)
+	}
+	if reply := send("chat-1", "/business"); !strings.Contains(reply, "post to business biz-2") {
+		t.Errorf("Expected chat-1 to be on biz-2, got %q", reply)
+	}
+	if reply := send("chat-1", "/business list"); !strings.Contains(reply, "- biz-2 (active)") {
+		t.Errorf("Expected biz-2 to be marked active, got %q", reply)
+	}
+	if reply := send("chat-2", "/business"); !strings.Contains(reply, "No business selected") {
+		t.Errorf("Expected chat-2 to have no business, got %q", reply)
+	}
+	if got := al.ActiveBusiness("telegram:chat-1"); got != "biz-2" {
+		t.Errorf("ActiveBusiness() = %q, want biz-2", got)
+	}
+}
+
+func TestCommands_AnswerInSendersLanguage(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+		Locale: config.LocaleConfig{
+			Businesses: map[string]config.BusinessLocaleConfig{"biz-1": {Locale: "de-DE"}},
+			Users:      map[string]string{"42": "fr"},
+		},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &simpleMockProvider{response: "hi"})
+	ctx := context.WithValue(context.Background(), constants.ContextKeyBusinessID, "biz-1")
+
+	reply, _ := al.handleCommand(ctx, bus.InboundMessage{SenderID: "42|bob", Content: "/show"})
+	if reply != "Utilisation : /show [model|channel|agents]" {
+		t.Errorf("Expected a French reply for user 42, got %q", reply)
+	}
+	reply, _ = al.handleCommand(ctx, bus.InboundMessage{SenderID: "7", Content: "/business"})
+	if reply != "Belege aus diesem Chat gehen an das Unternehmen biz-1." {
+		t.Errorf("Expected a German reply for the German business, got %q", reply)
+	}
+}
+
+// recordingProvider remembers the messages of its last call.
+type recordingProvider struct {
+	last []providers.Message
+}
+
+func (p *recordingProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	p.last = messages
+	return &providers.LLMResponse{Content: "noted"}, nil
+}
+
+func (p *recordingProvider) GetDefaultModel() string { return "test-model" }
+
+func (p *recordingProvider) sawText(text string) bool {
+	for _, m := range p.last {
+		if strings.Contains(m.Content, text) {
+			return true
+		}
+	}
+	return false
+}
+
+// TestBusinessRunsAreIsolated verifies that history and memory of one
+// business never reach a run for another, even in the same chat
+func TestBusinessRunsAreIsolated(t *testing.T) {
+	workspace := t.TempDir()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         workspace,
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	for id, fact := range map[string]string{"biz-a": "Acme banks with RBC", "biz-b": "Bolt banks with TD"} {
+		dir := filepath.Join(workspace, "memory", "businesses", id)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "MEMORY.md"), []byte(fact), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	provider := &recordingProvider{}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+	send := func(businessID, content string) {
+		ctx := context.WithValue(context.Background(), constants.ContextKeyBusinessID, businessID)
+		msg := bus.InboundMessage{Channel: "telegram", SenderID: "u1", ChatID: "chat-1", Content: content}
+		if _, err := al.processMessage(ctx, msg); err != nil {
+			t.Fatalf("processMessage(%s) error: %v", businessID, err)
+		}
+	}
+
+	send("biz-a", "Our new supplier is Foo Widgets")
+	if !provider.sawText("Acme banks with RBC") || provider.sawText("Bolt banks with TD") {
+		t.Error("Expected biz-a's run to see only biz-a's memory")
+	}
+
+	send("biz-b", "Who is our supplier?")
+	if provider.sawText("Foo Widgets") {
+		t.Error("biz-a's history reached a run for biz-b")
+	}
+	if provider.sawText("Acme banks with RBC") || !provider.sawText("Bolt banks with TD") {
+		t.Error("Expected biz-b's run to see only biz-b's memory")
+	}
+
+	send("biz-a", "Who is our supplier?")
+	if !provider.sawText("Foo Widgets") {
+		t.Error("Expected biz-a's run to keep its own history")
+	}
+}
+
 // TestToolResult_UserFacingToolDoesSendMessage verifies user-facing tools trigger outbound
 func TestToolResult_UserFacingToolDoesSendMessage(t *testing.T) {
 	tmpDir, err := os.MkdirTemp("", "agent-test-*")
@@ -631,3 +866,210 @@ func TestAgentLoop_ContextExhaustionRetry(t *testing.T) {
 		t.Errorf("Expected history to be compressed (len < 8), got %d", len(finalHistory))
 	}
 }
+
+func TestDebugCommand_TracesOnlyThatSession(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         tmpDir,
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+
+	msgBus := bus.NewMessageBus()
+	al := NewAgentLoop(cfg, msgBus, &simpleMockProvider{response: "done"})
+	helper := testHelper{al: al}
+	ctx := context.Background()
+
+	debugMsg := bus.InboundMessage{
+		Channel: "telegram", SenderID: "user1", ChatID: "chat1",
+		Content: "/debug on", SessionKey: "agent:main:debugged",
+	}
+	if resp := helper.executeAndGetResponse(t, ctx, debugMsg); !strings.Contains(resp, "Debug mode on") {
+		t.Fatalf("unexpected /debug on response: %s", resp)
+	}
+
+	debugMsg.Content = "hello"
+	helper.executeAndGetResponse(t, ctx, debugMsg)
+	helper.executeAndGetResponse(t, ctx, bus.InboundMessage{
+		Channel: "telegram", SenderID: "user2", ChatID: "chat2",
+		Content: "hello", SessionKey: "agent:main:quiet",
+	})
+
+	sessions, err := al.DebugSessions("")
+	if err != nil {
+		t.Fatalf("DebugSessions failed: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].SessionKey != "agent:main:debugged" || !sessions[0].Enabled {
+		t.Fatalf("expected only the debugged session, got %+v", sessions)
+	}
+
+	var trace strings.Builder
+	if err := al.ExportSessionDebug("", "agent:main:debugged", &trace); err != nil {
+		t.Fatalf("ExportSessionDebug failed: %v", err)
+	}
+	for _, event := range []string{debugEventLLMRequest, debugEventLLMResponse} {
+		if !strings.Contains(trace.String(), `"event":"`+event+`"`) {
+			t.Errorf("trace missing %s event", event)
+		}
+	}
+	if !strings.Contains(trace.String(), "hello") {
+		t.Error("trace should contain the full prompt")
+	}
+
+	if err := al.ExportSessionDebug("", "agent:main:quiet", &trace); !os.IsNotExist(err) {
+		t.Errorf("expected not-exist for a session without debug, got %v", err)
+	}
+}
+
+func TestTranscripts_RecordsTurnsForSearch(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         tmpDir,
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+
+	store, err := transcript.Open(tmpDir)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer store.Close()
+
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &simpleMockProvider{response: "Filed the Acme Hardware receipt"})
+	al.SetTranscripts(store)
+	if _, ok := al.registry.GetDefaultAgent().Tools.Get("search_history"); !ok {
+		t.Error("search_history tool should be registered")
+	}
+
+	helper := testHelper{al: al}
+	helper.executeAndGetResponse(t, context.Background(), bus.InboundMessage{
+		Channel: "telegram", SenderID: "user1", ChatID: "chat1",
+		Content: "here is my receipt", SessionKey: "agent:main:chat1",
+	})
+
+	results, err := al.SearchTranscripts(context.Background(), transcript.Query{Text: "hardware"})
+	if err != nil {
+		t.Fatalf("SearchTranscripts failed: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(results))
+	}
+	if r := results[0]; r.Channel != "telegram" || r.SessionKey != "agent:main:chat1" || !strings.Contains(r.Message, "receipt") {
+		t.Errorf("unexpected turn: %+v", r.Turn)
+	}
+}
+
+// slowTool sleeps, then echoes its "n" argument, tracking how many calls run
+// at once.
+type slowTool struct {
+	running atomic.Int32
+	mu      sync.Mutex
+	peak    int32
+}
+
+func (t *slowTool) Name() string        { return "slow" }
+func (t *slowTool) Description() string { return "Sleeps briefly" }
+func (t *slowTool) Parameters() map[string]any {
+	return map[string]any{"type": "object", "properties": map[string]any{}}
+}
+
+func (t *slowTool) Execute(ctx context.Context, args map[string]any) *tools.ToolResult {
+	n := t.running.Add(1)
+	t.mu.Lock()
+	t.peak = max(t.peak, n)
+	t.mu.Unlock()
+	time.Sleep(50 * time.Millisecond)
+	t.running.Add(-1)
+	return tools.SilentResult(fmt.Sprintf("done %v", args["n"]))
+}
+
+// batchToolProvider asks for three slow calls at once, then answers.
+type batchToolProvider struct {
+	calls int
+}
+
+func (p *batchToolProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	p.calls++
+	if p.calls > 1 {
+		return &providers.LLMResponse{Content: "all done"}, nil
+	}
+	var calls []providers.ToolCall
+	for i := 1; i <= 3; i++ {
+		calls = append(calls, providers.ToolCall{
+			ID:        fmt.Sprintf("call-%d", i),
+			Name:      "slow",
+			Arguments: map[string]any{"n": i},
+		})
+	}
+	return &providers.LLMResponse{ToolCalls: calls}, nil
+}
+
+func (p *batchToolProvider) GetDefaultModel() string {
+	return "mock-model"
+}
+
+func TestToolCalls_RunInParallelInCallOrder(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		parallel int
+		wantPeak int32
+	}{
+		{"parallel", 4, 3},
+		{"sequential", 1, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &config.Config{
+				Agents: config.AgentsConfig{
+					Defaults: config.AgentDefaults{
+						Workspace:         t.TempDir(),
+						Model:             "test-model",
+						MaxTokens:         4096,
+						MaxToolIterations: 10,
+						MaxParallelTools:  tc.parallel,
+					},
+				},
+			}
+			al := NewAgentLoop(cfg, bus.NewMessageBus(), &batchToolProvider{})
+			tool := &slowTool{}
+			al.RegisterTool(tool)
+
+			response, err := al.ProcessDirectWithChannel(context.Background(), "go", "agent:test:batch", "test", "chat")
+			if err != nil {
+				t.Fatalf("ProcessDirectWithChannel() error: %v", err)
+			}
+			if response != "all done" {
+				t.Errorf("response = %q, want %q", response, "all done")
+			}
+			if tool.peak != tc.wantPeak {
+				t.Errorf("peak concurrent calls = %d, want %d", tool.peak, tc.wantPeak)
+			}
+
+			var results []string
+			for _, m := range al.registry.GetDefaultAgent().Sessions.GetHistory("agent:test:batch") {
+				if m.Role == "tool" {
+					results = append(results, m.ToolCallID+"="+m.Content)
+				}
+			}
+			want := []string{"call-1=done 1", "call-2=done 2", "call-3=done 3"}
+			if strings.Join(results, ",") != strings.Join(want, ",") {
+				t.Errorf("tool results = %v, want %v", results, want)
+			}
+		})
+	}
+}
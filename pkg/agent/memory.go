@@ -12,6 +12,8 @@ import (
 	"path/filepath"
 	"strings"
 	"time"
+
+	"github.com/sipeed/picoclaw/pkg/utils"
 )
 
 // MemoryStore manages persistent memory for the agent.
@@ -39,6 +41,18 @@ func NewMemoryStore(workspace string) *MemoryStore {
 	}
 }
 
+// NewBusinessMemoryStore creates the MemoryStore of one business, kept in
+// memory/businesses/<id>/ so runs for other businesses never see it.
+func NewBusinessMemoryStore(workspace, businessID string) *MemoryStore {
+	memoryDir := filepath.Join(workspace, "memory", "businesses", utils.BusinessDirName(businessID))
+	os.MkdirAll(memoryDir, 0o755)
+	return &MemoryStore{
+		workspace:  workspace,
+		memoryDir:  memoryDir,
+		memoryFile: filepath.Join(memoryDir, "MEMORY.md"),
+	}
+}
+
 // getTodayFile returns the path to today's daily note file (memory/YYYYMM/YYYYMMDD.md).
 func (ms *MemoryStore) getTodayFile() string {
 	today := time.Now().Format("20060102") // YYYYMMDD
@@ -0,0 +1,174 @@
+package agent
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// memoryWriteTools are the file tools whose writes to memory files are traced
+// to the session that made them, so DeleteSession can take them out again.
+var memoryWriteTools = map[string]bool{"write_file": true, "edit_file": true, "append_file": true}
+
+// memoryWrite is the lines one tool call added to a memory file.
+type memoryWrite struct {
+	File  string   `json:"file"` // relative to the workspace
+	Lines []string `json:"lines"`
+}
+
+// memoryWritesPath is where the memory writes of a session are traced. It
+// is under state/, which business runs can't reach.
+func memoryWritesPath(workspace, sessionKey string) string {
+	return filepath.Join(workspace, "state", "memory_writes", debugFileName(sessionKey))
+}
+
+// memoryTarget returns the memory file tc writes to, or "" if it writes none.
+func memoryTarget(workspace string, tc providers.ToolCall) string {
+	if !memoryWriteTools[tc.Name] || workspace == "" {
+		return ""
+	}
+	path, _ := tc.Arguments["path"].(string)
+	absWorkspace, err := filepath.Abs(workspace)
+	if path == "" || err != nil {
+		return ""
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(absWorkspace, path)
+	}
+	path = filepath.Clean(path)
+	rel, err := filepath.Rel(filepath.Join(absWorkspace, "memory"), path)
+	if err != nil || !filepath.IsLocal(rel) {
+		return ""
+	}
+	return path
+}
+
+// readLines returns the lines of path, or nil if it can't be read.
+func readLines(path string) []string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	return strings.Split(string(data), "\n")
+}
+
+// traceMemoryWrite records the lines a tool call added to the memory file
+// path, given the file's lines before the call.
+func traceMemoryWrite(workspace, sessionKey, path string, before []string) {
+	seen := make(map[string]int, len(before))
+	for _, line := range before {
+		seen[line]++
+	}
+	var added []string
+	for _, line := range readLines(path) {
+		if seen[line] > 0 {
+			seen[line]--
+		} else if strings.TrimSpace(line) != "" {
+			added = append(added, line)
+		}
+	}
+	if len(added) == 0 {
+		return
+	}
+
+	err := func() error {
+		absWorkspace, err := filepath.Abs(workspace)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(absWorkspace, path)
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(memoryWrite{File: filepath.ToSlash(rel), Lines: added})
+		if err != nil {
+			return err
+		}
+		ledger := memoryWritesPath(workspace, sessionKey)
+		if err := os.MkdirAll(filepath.Dir(ledger), 0o700); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(ledger, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = f.Write(append(data, '\n'))
+		return err
+	}()
+	if err != nil {
+		logger.WarnCF("agent", "Failed to trace memory write",
+			map[string]any{"session_key": sessionKey, "error": err.Error()})
+	}
+}
+
+// forgetMemoryWrites takes the lines a session added out of the memory
+// files, and returns how many it removed. Lines since edited away are gone
+// already.
+func forgetMemoryWrites(workspace, sessionKey string) (int, error) {
+	ledger := memoryWritesPath(workspace, sessionKey)
+	f, err := os.Open(ledger)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	written := map[string][]string{}
+	var files []string
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16<<20)
+	for scanner.Scan() {
+		var w memoryWrite
+		if json.Unmarshal(scanner.Bytes(), &w) != nil {
+			continue
+		}
+		if _, ok := written[w.File]; !ok {
+			files = append(files, w.File)
+		}
+		written[w.File] = append(written[w.File], w.Lines...)
+	}
+	f.Close()
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, file := range files {
+		path := filepath.Join(workspace, filepath.FromSlash(file))
+		lines := readLines(path)
+		if lines == nil {
+			continue
+		}
+		drop := make(map[string]int)
+		for _, line := range written[file] {
+			drop[line]++
+		}
+		kept := lines[:0]
+		for _, line := range lines {
+			if drop[line] > 0 {
+				drop[line]--
+				removed++
+				continue
+			}
+			kept = append(kept, line)
+		}
+		if len(kept) == len(lines) {
+			continue
+		}
+		if err := os.WriteFile(path, []byte(strings.Join(kept, "\n")), 0o644); err != nil {
+			return removed, fmt.Errorf("failed to remove memory notes: %w", err)
+		}
+	}
+	if err := os.Remove(ledger); err != nil {
+		return removed, err
+	}
+	return removed, nil
+}
@@ -0,0 +1,35 @@
+package agent
+
+import "context"
+
+// Progress kinds.
+const (
+	// ProgressToolCall reports a tool the agent is about to run.
+	ProgressToolCall = "tool_call"
+	// ProgressMessage reports text for the user sent before the final
+	// response: the model's remarks alongside tool calls, and tool output
+	// meant for the user.
+	ProgressMessage = "message"
+)
+
+// Progress is a step of a request being processed.
+type Progress struct {
+	Type    string
+	Tool    string // for ProgressToolCall
+	Content string // for ProgressMessage
+}
+
+type progressKey struct{}
+
+// WithProgress returns a context whose requests report their steps to fn,
+// for callers that stream a request's progress. fn is called from the
+// request's goroutine and should not block.
+func WithProgress(ctx context.Context, fn func(Progress)) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+func reportProgress(ctx context.Context, p Progress) {
+	if fn, ok := ctx.Value(progressKey{}).(func(Progress)); ok {
+		fn(p)
+	}
+}
@@ -0,0 +1,64 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// askingProvider asks whether to pay, offering yes and no as quick replies.
+type askingProvider struct{}
+
+func (p *askingProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	if messages[len(messages)-1].Role == "tool" {
+		return &providers.LLMResponse{Content: "Pay the invoice now?"}, nil
+	}
+	return &providers.LLMResponse{ToolCalls: []providers.ToolCall{{
+		ID:        "call-1",
+		Name:      "suggest_replies",
+		Arguments: map[string]any{"replies": []any{"Yes", "No"}},
+	}}}, nil
+}
+
+func (p *askingProvider) GetDefaultModel() string {
+	return "mock-model"
+}
+
+func TestQuickReplies_SentWithResponse(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &askingProvider{})
+	ctx := context.Background()
+
+	response, err := al.ProcessDirectWithChannel(ctx, "pay acme", "agent:test:pay", "test", "chat")
+	if err != nil {
+		t.Fatalf("ProcessDirectWithChannel() error: %v", err)
+	}
+	if response != "Pay the invoice now?" {
+		t.Errorf("response = %q", response)
+	}
+	out, ok := al.bus.SubscribeOutbound(ctx)
+	if !ok || out.Content != response {
+		t.Fatalf("outbound = %+v", out)
+	}
+	if len(out.QuickReplies) != 2 || out.QuickReplies[0] != "Yes" || out.QuickReplies[1] != "No" {
+		t.Errorf("outbound quick replies = %q", out.QuickReplies)
+	}
+}
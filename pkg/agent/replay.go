@@ -0,0 +1,194 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pmezard/go-difflib/difflib"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/eventlog"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/session"
+	"github.com/sipeed/picoclaw/pkg/wirelog"
+)
+
+var (
+	// ErrReplayNotFound is returned by Replay for a request the event log
+	// doesn't have, or no longer has after rotation.
+	ErrReplayNotFound = errors.New("request not found in the event log")
+	// ErrReplayNoContent is returned by Replay for a request recorded
+	// without its text.
+	ErrReplayNoContent = errors.New("request was recorded without its content (set event_log.record_content)")
+)
+
+// ReplayResult compares the response to a past request with the one the
+// current model and skills give.
+type ReplayResult struct {
+	RequestID  string    `json:"request_id"`
+	ReplayID   string    `json:"replay_id"` // request ID of the replay's own events
+	Time       time.Time `json:"time"`      // of the original request
+	AgentID    string    `json:"agent_id"`
+	SessionKey string    `json:"session_key"`
+	Channel    string    `json:"channel"`
+	ChatID     string    `json:"chat_id"`
+	BusinessID string    `json:"business_id,omitempty"`
+	Media      int       `json:"media,omitempty"` // attachments of the original, not replayed
+	Live       bool      `json:"live"`
+	Message    string    `json:"message"`
+	Original   string    `json:"original"`
+	Response   string    `json:"response"`
+	Error      string    `json:"error,omitempty"` // the replay failed
+	Changed    bool      `json:"changed"`
+	Diff       string    `json:"diff,omitempty"` // unified diff from original to response
+}
+
+// recordedRequest is a request as the event log has it.
+type recordedRequest struct {
+	time        time.Time
+	agentID     string
+	sessionKey  string
+	channel     string
+	chatID      string
+	senderID    string
+	businessID  string
+	message     string
+	hasMessage  bool
+	response    string
+	hasResponse bool
+	media       int
+}
+
+// Replay runs a past request from the event log again and compares the
+// answer with the original.
+//
+// A dry run answers in a scratch session: tool calls get a placeholder
+// result instead of running, and nothing is sent or kept. A live run
+// continues the original session and runs tools for real, but doesn't send
+// the answer to the chat.
+func (al *AgentLoop) Replay(ctx context.Context, requestID string, live bool) (*ReplayResult, error) {
+	if al.events == nil {
+		return nil, errors.New("replay needs the event log (set event_log.enabled)")
+	}
+	rec, err := al.findRequest(requestID)
+	if err != nil {
+		return nil, err
+	}
+
+	if rec.businessID != "" {
+		ctx = context.WithValue(ctx, constants.ContextKeyBusinessID, rec.businessID)
+	}
+	if rec.senderID != "" {
+		ctx = context.WithValue(ctx, constants.ContextKeySenderID, rec.senderID)
+	}
+	replayID := wirelog.NewID()
+	ctx = context.WithValue(ctx, constants.ContextKeyRequestID, replayID)
+
+	agent, ok := al.registry.GetAgent(rec.agentID)
+	if !ok || rec.sessionKey == "" {
+		// The request never reached the model, so its events don't say
+		// where it was routed.
+		agent, rec.sessionKey = al.routeMessage(ctx, bus.InboundMessage{
+			Channel:  rec.channel,
+			ChatID:   rec.chatID,
+			SenderID: rec.senderID,
+			Content:  rec.message,
+		})
+	}
+	opts := processOptions{
+		SessionKey:      rec.sessionKey,
+		Channel:         rec.channel,
+		ChatID:          rec.chatID,
+		UserMessage:     rec.message,
+		DefaultResponse: "I've completed processing but have no response to give.",
+		EnableSummary:   live,
+	}
+	if !live {
+		scratch := *agent
+		scratch.Sessions = session.NewSessionManager("")
+		agent = &scratch
+		opts.NoHistory = true
+		opts.DryRun = true
+	}
+
+	logger.InfoCF("agent", "Replaying request",
+		map[string]any{
+			"request_id":  requestID,
+			"replay_id":   replayID,
+			"session_key": rec.sessionKey,
+			"live":        live,
+		})
+	result := &ReplayResult{
+		RequestID:  requestID,
+		ReplayID:   replayID,
+		Time:       rec.time,
+		AgentID:    agent.ID,
+		SessionKey: rec.sessionKey,
+		Channel:    rec.channel,
+		ChatID:     rec.chatID,
+		BusinessID: rec.businessID,
+		Media:      rec.media,
+		Live:       live,
+		Message:    rec.message,
+		Original:   rec.response,
+	}
+	response, err := al.runAgentLoop(ctx, agent, opts)
+	if err != nil {
+		result.Error = err.Error()
+		return result, nil
+	}
+	result.Response = response
+	result.Changed = response != rec.response
+	if result.Changed {
+		result.Diff, _ = difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(rec.response),
+			B:        difflib.SplitLines(response),
+			FromFile: "original",
+			ToFile:   "replay",
+			Context:  3,
+		})
+	}
+	return result, nil
+}
+
+// findRequest collects the events of requestID.
+func (al *AgentLoop) findRequest(requestID string) (*recordedRequest, error) {
+	var rec recordedRequest
+	found := false
+	err := al.events.Read(0, func(e eventlog.Event) error {
+		if e.RequestID != requestID {
+			return nil
+		}
+		switch e.Type {
+		case eventlog.TypeRequestReceived:
+			found = true
+			rec.time = e.Time
+			rec.channel, rec.chatID, rec.businessID = e.Channel, e.ChatID, e.BusinessID
+			rec.senderID, _ = e.Data["sender_id"].(string)
+			rec.message, rec.hasMessage = e.Data["content"].(string)
+			if media, ok := e.Data["media"].(float64); ok {
+				rec.media = int(media)
+			}
+		case eventlog.TypeProviderCall:
+			if rec.sessionKey == "" {
+				rec.agentID, rec.sessionKey = e.AgentID, e.SessionKey
+			}
+		case eventlog.TypeResponseSent:
+			rec.response, rec.hasResponse = e.Data["content"].(string)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("%w: %s", ErrReplayNotFound, requestID)
+	}
+	if !rec.hasMessage || !rec.hasResponse {
+		return nil, ErrReplayNoContent
+	}
+	return &rec, nil
+}
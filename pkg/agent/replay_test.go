@@ -0,0 +1,95 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/eventlog"
+)
+
+func newReplayLoop(t *testing.T, recordContent bool) (*AgentLoop, *promptRecorder) {
+	t.Helper()
+	workspace := t.TempDir()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         workspace,
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+		EventLog: config.EventLogConfig{Enabled: true, RecordContent: recordContent},
+	}
+	provider := &promptRecorder{name: "The total is 40."}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+	events, err := eventlog.Open(workspace, eventlog.Options{})
+	if err != nil {
+		t.Fatalf("eventlog.Open: %v", err)
+	}
+	t.Cleanup(func() { events.Close() })
+	al.SetEventLog(events)
+	return al, provider
+}
+
+func TestReplay_DiffsAgainstOriginal(t *testing.T) {
+	al, provider := newReplayLoop(t, true)
+	ctx := context.WithValue(context.Background(), constants.ContextKeyRequestID, "req-1")
+	sessionKey := "agent:main:replay-test"
+	if _, err := al.ProcessDirectWithChannel(ctx, "What is the total?", sessionKey, "cli", "direct"); err != nil {
+		t.Fatalf("ProcessDirectWithChannel: %v", err)
+	}
+	history := al.registry.GetDefaultAgent().Sessions.GetHistory(sessionKey)
+
+	result, err := al.Replay(context.Background(), "req-1", false)
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	if result.Message != "What is the total?" || result.SessionKey != sessionKey || result.Changed {
+		t.Errorf("unchanged replay = %+v", result)
+	}
+	if result.ReplayID == "" || result.ReplayID == "req-1" {
+		t.Errorf("replay ID = %q, want a new request ID", result.ReplayID)
+	}
+
+	provider.name = "The total is 42."
+	result, err = al.Replay(context.Background(), "req-1", false)
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	if !result.Changed || !strings.Contains(result.Diff, "-The total is 40.") ||
+		!strings.Contains(result.Diff, "+The total is 42.") {
+		t.Errorf("changed replay = %+v", result)
+	}
+	if got := al.registry.GetDefaultAgent().Sessions.GetHistory(sessionKey); len(got) != len(history) {
+		t.Errorf("dry run changed the session: %d messages, want %d", len(got), len(history))
+	}
+
+	result, err = al.Replay(context.Background(), "req-1", true)
+	if err != nil || result.Response != "The total is 42." {
+		t.Fatalf("live Replay = %+v, %v", result, err)
+	}
+	if got := al.registry.GetDefaultAgent().Sessions.GetHistory(sessionKey); len(got) != len(history)+2 {
+		t.Errorf("live replay left %d messages in the session, want %d", len(got), len(history)+2)
+	}
+}
+
+func TestReplay_NeedsRecordedContent(t *testing.T) {
+	al, _ := newReplayLoop(t, false)
+	ctx := context.WithValue(context.Background(), constants.ContextKeyRequestID, "req-1")
+	if _, err := al.ProcessDirectWithChannel(ctx, "hello", "agent:main:a", "cli", "direct"); err != nil {
+		t.Fatalf("ProcessDirectWithChannel: %v", err)
+	}
+
+	if _, err := al.Replay(context.Background(), "req-1", false); !errors.Is(err, ErrReplayNoContent) {
+		t.Errorf("Replay without content: err = %v, want ErrReplayNoContent", err)
+	}
+	if _, err := al.Replay(context.Background(), "req-2", false); !errors.Is(err, ErrReplayNotFound) {
+		t.Errorf("Replay of an unknown request: err = %v, want ErrReplayNotFound", err)
+	}
+}
@@ -0,0 +1,146 @@
+package agent
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/i18n"
+	"github.com/sipeed/picoclaw/pkg/ledgerforge"
+	"github.com/sipeed/picoclaw/pkg/receipts"
+	"github.com/sipeed/picoclaw/pkg/state"
+)
+
+// correctionField starts a field=value pair of /review confirm. Values run
+// to the next pair, so "vendor=Corner Cafe amount=12.50" has two.
+var correctionField = regexp.MustCompile(`(?:^|\s)(vendor|amount|currency|date|category)=`)
+
+// chatBusiness returns the business a chat command acts on: the request's,
+// or else the one last used from this chat. auth is what was stored for
+// it, if anything.
+func (al *AgentLoop) chatBusiness(ctx context.Context, msg bus.InboundMessage) (string, state.AuthEntry) {
+	businessID, _ := ctx.Value(constants.ContextKeyBusinessID).(string)
+	if al.state == nil {
+		return businessID, state.AuthEntry{}
+	}
+	all := al.state.GetActiveAuth()
+	if businessID == "" {
+		var latest time.Time
+		for id, entry := range all {
+			if entry.Channel == msg.Channel && entry.ChatID == msg.ChatID && entry.UpdatedAt.After(latest) {
+				businessID, latest = id, entry.UpdatedAt
+			}
+		}
+	}
+	return businessID, all[businessID]
+}
+
+// reviewCommand lists the receipts of the chat's business waiting for
+// review, or confirms or discards one. Confirming creates the draft, so it
+// needs the user's LedgerForge token: the request's, or the one last used
+// from this chat.
+func (al *AgentLoop) reviewCommand(ctx context.Context, msg bus.InboundMessage, args []string) string {
+	lang := al.language(ctx, msg)
+	if al.receipts == nil {
+		return i18n.T(lang, "review.unavailable")
+	}
+	businessID, auth := al.chatBusiness(ctx, msg)
+	if businessID == "" {
+		return i18n.T(lang, "review.no_business")
+	}
+	lang = al.locales.Language(businessID, msg.SenderID)
+	token := ledgerforge.Token(ctx)
+	if token == "" && auth.Channel == msg.Channel && auth.ChatID == msg.ChatID {
+		token = auth.JWTToken
+	}
+	ctx = ledgerforge.WithAuth(ctx, token, businessID)
+
+	action := "list"
+	if len(args) > 0 {
+		action = args[0]
+	}
+	switch {
+	case action == "list":
+		reviews, err := al.receipts.Reviews(ctx)
+		if err != nil {
+			return i18n.T(lang, "review.list_failed", err)
+		}
+		if len(reviews) == 0 {
+			return i18n.T(lang, "review.none")
+		}
+		lines := []string{i18n.T(lang, "review.list")}
+		for _, rv := range reviews {
+			f := rv.Fields
+			lines = append(lines, fmt.Sprintf("- %s: %s, %s %s, %s (%s %s)", rv.ID,
+				cmp.Or(f.Vendor, "?"), cmp.Or(f.Amount, "?"), f.Currency, cmp.Or(f.Date, "?"),
+				i18n.T(lang, "review.check"), strings.Join(rv.Unsure, ", ")))
+		}
+		return strings.Join(lines, "\n")
+
+	case action == "confirm" && len(args) >= 2:
+		c, ok := parseCorrection(strings.Join(args[2:], " "))
+		if !ok {
+			return i18n.T(lang, "review.usage")
+		}
+		if token == "" {
+			return i18n.T(lang, "review.sign_in")
+		}
+		res, err := al.receipts.Confirm(ctx, args[1], c)
+		if errors.Is(err, receipts.ErrReviewNotFound) {
+			return i18n.T(lang, "review.not_found", args[1])
+		}
+		if err != nil {
+			return i18n.T(lang, "review.confirm_failed", args[1], err)
+		}
+		return i18n.T(lang, "review.confirmed", args[1], res.Transaction.ID, res.Amount, res.Currency, res.Vendor)
+
+	case action == "discard" && len(args) == 2:
+		err := al.receipts.Discard(ctx, args[1])
+		if errors.Is(err, receipts.ErrReviewNotFound) {
+			return i18n.T(lang, "review.not_found", args[1])
+		}
+		if err != nil {
+			return i18n.T(lang, "review.discard_failed", args[1], err)
+		}
+		return i18n.T(lang, "review.discarded", args[1])
+
+	default:
+		return i18n.T(lang, "review.usage")
+	}
+}
+
+// parseCorrection reads the field=value pairs of /review confirm. It
+// reports false for text that isn't made of such pairs.
+func parseCorrection(text string) (receipts.Correction, bool) {
+	var c receipts.Correction
+	locs := correctionField.FindAllStringSubmatchIndex(text, -1)
+	if text != "" && (len(locs) == 0 || locs[0][0] != 0) {
+		return c, false
+	}
+	for i, loc := range locs {
+		end := len(text)
+		if i+1 < len(locs) {
+			end = locs[i+1][0]
+		}
+		value := strings.Trim(strings.TrimSpace(text[loc[1]:end]), `"`)
+		switch text[loc[2]:loc[3]] {
+		case "vendor":
+			c.Vendor = value
+		case "amount":
+			c.Amount = value
+		case "currency":
+			c.Currency = value
+		case "date":
+			c.Date = value
+		case "category":
+			c.Category = value
+		}
+	}
+	return c, true
+}
@@ -0,0 +1,91 @@
+package agent
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/ledgerforge"
+	"github.com/sipeed/picoclaw/pkg/receipts"
+)
+
+func TestReviewCommand_ConfirmsWithCorrections(t *testing.T) {
+	var draft string
+	lf := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/businesses/biz-1/receipts/extract-ocr":
+			io.WriteString(w, `{"success":true,"data":{"ocr_data":{"vendor":"Unknown","amount":"42.10",`+
+				`"date":"2026-03-02"}}}`)
+		case "/api/v1/businesses/biz-1/transactions":
+			if r.Header.Get("Authorization") != "Bearer jwt-1" {
+				t.Errorf("Expected the chat's stored token, got %q", r.Header.Get("Authorization"))
+			}
+			body, _ := io.ReadAll(r.Body)
+			draft = string(body)
+			io.WriteString(w, `{"id":"tx-9","status":"draft"}`)
+		default:
+			io.WriteString(w, `{}`)
+		}
+	}))
+	defer lf.Close()
+
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &simpleMockProvider{response: "hi"})
+	al.state.SetBusinessAuth("biz-1", "jwt-1", "telegram", "chat-1")
+	send := func(content string) string {
+		reply, _ := al.handleCommand(context.Background(),
+			bus.InboundMessage{Channel: "telegram", ChatID: "chat-1", Content: content})
+		return reply
+	}
+
+	if reply := send("/review"); !strings.Contains(reply, "not turned on") {
+		t.Errorf("Expected review to be unavailable, got %q", reply)
+	}
+
+	pipeline := receipts.New(ledgerforge.New(ledgerforge.Options{BaseURL: lf.URL}), nil)
+	pipeline.SetReview(receipts.NewReviewQueue(t.TempDir()), 0.6)
+	al.SetReceipts(pipeline)
+	res, err := pipeline.Process(ledgerforge.WithAuth(context.Background(), "jwt-1", "biz-1"),
+		receipts.Receipt{Filename: "r.jpg", Data: []byte("image-bytes")})
+	if err != nil || res.ReviewID == "" {
+		t.Fatalf("Expected the receipt to wait for review, got %+v, %v", res, err)
+	}
+
+	if reply := send("/review"); !strings.Contains(reply, "- "+res.ReviewID+": Unknown, 42.10 CAD, 2026-03-02 "+
+		"(check vendor)") {
+		t.Errorf("Unexpected list: %q", reply)
+	}
+	if reply := send("/review confirm " + res.ReviewID); !strings.Contains(reply, "vendor is missing") {
+		t.Errorf("Expected the missing vendor to be refused, got %q", reply)
+	}
+	if reply := send("/review confirm " + res.ReviewID + " oops"); !strings.HasPrefix(reply, "Usage") {
+		t.Errorf("Expected usage for a bad correction, got %q", reply)
+	}
+	reply := send("/review confirm " + res.ReviewID + " vendor=Corner Cafe category=Meals")
+	if reply != "Receipt "+res.ReviewID+" confirmed: draft tx-9 for 42.10 CAD from Corner Cafe." {
+		t.Errorf("Unexpected confirmation: %q", reply)
+	}
+	if !strings.Contains(draft, `"vendor_name":"Corner Cafe"`) || !strings.Contains(draft, `"category":"Meals"`) {
+		t.Errorf("Expected the corrections in the draft, got %s", draft)
+	}
+	if reply := send("/review"); !strings.Contains(reply, "No receipts") {
+		t.Errorf("Expected an empty queue, got %q", reply)
+	}
+	if reply := send("/review discard " + res.ReviewID); !strings.Contains(reply, "not waiting for review") {
+		t.Errorf("Expected a confirmed receipt to be gone, got %q", reply)
+	}
+}
@@ -0,0 +1,210 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+
+	"github.com/adhocore/gronx"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/cron"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/skills"
+)
+
+// scheduledSkillTarget is one synthetic run of a scheduled skill.
+type scheduledSkillTarget struct {
+	businessID string
+	jwtToken   string
+	channel    string
+	chatID     string
+}
+
+// ScheduledSkillJobs returns cron jobs for the default agent's skills whose
+// manifests declare a schedule. Pass them to CronService.SyncJobs with
+// cron.SkillJobPrefix.
+//
+// Schedules run in the businesses' timezones, so each skill gets a job per
+// configured zone; jobs for zones other than the default one have the zone
+// in their ID. Skills with an invalid cron expression are skipped with a
+// warning.
+func (al *AgentLoop) ScheduledSkillJobs() []cron.CronJob {
+	agent := al.registry.GetDefaultAgent()
+	if agent == nil {
+		return nil
+	}
+
+	defaultZone := al.locales.For("").Timezone
+	zones := []string{defaultZone}
+	seen := map[string]bool{defaultZone: true}
+	for _, id := range slices.Sorted(maps.Keys(al.cfg.Locale.Businesses)) {
+		if tz := al.locales.For(id).Timezone; !seen[tz] {
+			seen[tz] = true
+			zones = append(zones, tz)
+		}
+	}
+
+	var jobs []cron.CronJob
+	cronParser := gronx.New()
+	for _, skill := range agent.ContextBuilder.ListSkills() {
+		if skill.Schedule == nil {
+			continue
+		}
+		if !cronParser.IsValid(skill.Schedule.Cron) {
+			logger.WarnCF("skills", "Skipping skill with an invalid schedule",
+				map[string]any{
+					"skill":    skill.Name,
+					"schedule": skill.Schedule.Cron,
+				})
+			continue
+		}
+		for _, tz := range zones {
+			id := cron.SkillJobPrefix + skill.Name
+			if tz != defaultZone {
+				id += "@" + tz
+			}
+			jobs = append(jobs, cron.CronJob{
+				ID:       id,
+				Name:     "skill: " + skill.Name,
+				Enabled:  true,
+				Schedule: cron.CronSchedule{Kind: "cron", Expr: skill.Schedule.Cron, TZ: tz},
+				Payload: cron.CronPayload{
+					Kind:    cron.PayloadKindSkill,
+					Skill:   skill.Name,
+					Channel: skill.Schedule.Channel,
+					To:      skill.Schedule.To,
+				},
+			})
+		}
+	}
+	return jobs
+}
+
+// RunScheduledSkill runs a scheduled skill once per active business in
+// timezone tz, using the business's persisted auth as a synthetic user
+// context. Output is delivered to the channel from the skill manifest, or the
+// business's last active chat.
+func (al *AgentLoop) RunScheduledSkill(ctx context.Context, name, tz string) error {
+	agent := al.registry.GetDefaultAgent()
+	if agent == nil {
+		return fmt.Errorf("no default agent")
+	}
+
+	var skill *skills.SkillInfo
+	for _, s := range agent.ContextBuilder.ListSkills() {
+		if s.Name == name && s.Schedule != nil {
+			skill = &s
+			break
+		}
+	}
+	if skill == nil {
+		return fmt.Errorf("skill %q not found or has no schedule", name)
+	}
+
+	var errs error
+	for _, target := range al.scheduledSkillTargets(skill, tz) {
+		runCtx := ctx
+		if target.businessID != "" {
+			runCtx = context.WithValue(runCtx, constants.ContextKeyJWTToken, target.jwtToken)
+			runCtx = context.WithValue(runCtx, constants.ContextKeyBusinessID, target.businessID)
+		}
+
+		logger.InfoCF("skills", "Running scheduled skill",
+			map[string]any{
+				"skill":       skill.Name,
+				"business_id": target.businessID,
+				"channel":     target.channel,
+			})
+
+		if err := al.runScheduledSkill(runCtx, agent, skill, target); err != nil {
+			logger.WarnCF("skills", "Scheduled skill failed",
+				map[string]any{
+					"skill":       skill.Name,
+					"business_id": target.businessID,
+					"error":       err.Error(),
+				})
+			errs = errors.Join(errs, err)
+		}
+	}
+	return errs
+}
+
+func (al *AgentLoop) scheduledSkillTargets(skill *skills.SkillInfo, tz string) []scheduledSkillTarget {
+	schedule := skill.Schedule
+	activeAuth := al.GetActiveAuth()
+
+	matrix := skills.EnablementMatrix(al.cfg.Tools.Skills.Businesses)
+	if len(activeAuth) == 0 {
+		if !matrix.Allowed("", skill.Name) || tz != al.locales.For("").Timezone {
+			return nil
+		}
+		channel, chatID := schedule.Channel, schedule.To
+		if channel == "" || chatID == "" {
+			channel, chatID = "cli", "direct"
+		}
+		return []scheduledSkillTarget{{channel: channel, chatID: chatID}}
+	}
+
+	targets := make([]scheduledSkillTarget, 0, len(activeAuth))
+	for businessID, entry := range activeAuth {
+		if !matrix.Allowed(businessID, skill.Name) || al.locales.For(businessID).Timezone != tz {
+			continue
+		}
+		target := scheduledSkillTarget{
+			businessID: businessID,
+			jwtToken:   entry.JWTToken,
+			channel:    entry.Channel,
+			chatID:     entry.ChatID,
+		}
+		if schedule.Channel != "" {
+			target.channel = schedule.Channel
+		}
+		if schedule.To != "" {
+			target.chatID = schedule.To
+		}
+		if target.channel == "" || target.chatID == "" {
+			target.channel, target.chatID = "cli", "direct"
+		}
+		targets = append(targets, target)
+	}
+	return targets
+}
+
+func (al *AgentLoop) runScheduledSkill(
+	ctx context.Context,
+	agent *AgentInstance,
+	skill *skills.SkillInfo,
+	target scheduledSkillTarget,
+) error {
+	if skill.Schedule.Command == "" {
+		sessionKey := fmt.Sprintf("skill-cron:%s:%s", skill.Name, target.businessID)
+		_, err := al.ProcessDirectWithChannel(ctx, skill.Schedule.Prompt, sessionKey, target.channel, target.chatID)
+		return err
+	}
+
+	// Run from the skill directory so manifests can use relative script paths
+	args := map[string]any{
+		"command":     skill.Schedule.Command,
+		"working_dir": filepath.Dir(skill.Path),
+	}
+	result := agent.Tools.ExecuteWithContext(ctx, "exec", args, target.channel, target.chatID, nil)
+
+	if target.channel != "cli" {
+		al.bus.PublishOutbound(bus.OutboundMessage{
+			Channel:      target.channel,
+			ChatID:       target.chatID,
+			Content:      fmt.Sprintf("Scheduled skill '%s':\n%s", skill.Name, result.ForLLM),
+			Notification: true,
+		})
+	}
+
+	if result.IsError {
+		return fmt.Errorf("skill %s: %s", skill.Name, result.ForLLM)
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/cron"
+)
+
+// writeBankSyncSkill writes a nightly skill that echoes the business it
+// runs for.
+func writeBankSyncSkill(t *testing.T, tmpDir string) {
+	t.Helper()
+	skillDir := filepath.Join(tmpDir, "skills", "bank-sync")
+	if err := os.MkdirAll(filepath.Join(skillDir, "scripts"), 0o755); err != nil {
+		t.Fatalf("Failed to create skill dir: %v", err)
+	}
+	manifest := "---\nname: bank-sync\ndescription: Nightly bank feed sync\n" +
+		"schedule: \"0 2 * * *\"\nschedule_command: sh scripts/sync.sh\n---\n"
+	if err := os.WriteFile(filepath.Join(skillDir, "SKILL.md"), []byte(manifest), 0o644); err != nil {
+		t.Fatalf("Failed to write manifest: %v", err)
+	}
+	script := "#!/bin/sh\necho \"synced $OLUTO_BUSINESS_ID\"\n"
+	if err := os.WriteFile(filepath.Join(skillDir, "scripts", "sync.sh"), []byte(script), 0o755); err != nil {
+		t.Fatalf("Failed to write script: %v", err)
+	}
+}
+
+func TestRunScheduledSkill_PerBusiness(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeBankSyncSkill(t, tmpDir)
+
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         tmpDir,
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	cfg.Tools.Skills.Businesses = map[string][]string{"biz-off": {}}
+
+	msgBus := bus.NewMessageBus()
+	al := NewAgentLoop(cfg, msgBus, &mockProvider{})
+	if err := al.state.SetBusinessAuth("biz-on", "jwt-on", "telegram", "chat-on"); err != nil {
+		t.Fatalf("SetBusinessAuth failed: %v", err)
+	}
+	if err := al.state.SetBusinessAuth("biz-off", "jwt-off", "telegram", "chat-off"); err != nil {
+		t.Fatalf("SetBusinessAuth failed: %v", err)
+	}
+
+	jobs := al.ScheduledSkillJobs()
+	if len(jobs) != 1 || jobs[0].ID != cron.SkillJobPrefix+"bank-sync" || jobs[0].Schedule.Expr != "0 2 * * *" {
+		t.Fatalf("Unexpected scheduled jobs: %+v", jobs)
+	}
+
+	if err := al.RunScheduledSkill(context.Background(), "bank-sync", ""); err != nil {
+		t.Fatalf("RunScheduledSkill failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	msg, ok := msgBus.SubscribeOutbound(ctx)
+	if !ok {
+		t.Fatal("Expected outbound message from scheduled skill")
+	}
+	if msg.ChatID != "chat-on" || !strings.Contains(msg.Content, "synced biz-on") {
+		t.Errorf("Unexpected outbound message: %+v", msg)
+	}
+
+	ctx2, cancel2 := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel2()
+	if extra, ok := msgBus.SubscribeOutbound(ctx2); ok {
+		t.Errorf("Expected disabled business to be skipped, got: %+v", extra)
+	}
+
+	if err := al.RunScheduledSkill(context.Background(), "missing", ""); err == nil {
+		t.Error("Expected error for unknown skill")
+	}
+}
+
+func TestScheduledSkillJobs_PerTimezone(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeBankSyncSkill(t, tmpDir)
+
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         tmpDir,
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	cfg.Locale.Timezone = "America/Toronto"
+	cfg.Locale.Businesses = map[string]config.BusinessLocaleConfig{
+		"berlin": {Timezone: "Europe/Berlin"},
+		"ottawa": {Timezone: "America/Toronto"},
+	}
+
+	msgBus := bus.NewMessageBus()
+	al := NewAgentLoop(cfg, msgBus, &mockProvider{})
+	if err := al.state.SetBusinessAuth("berlin", "jwt-b", "telegram", "chat-berlin"); err != nil {
+		t.Fatalf("SetBusinessAuth failed: %v", err)
+	}
+	if err := al.state.SetBusinessAuth("ottawa", "jwt-o", "telegram", "chat-ottawa"); err != nil {
+		t.Fatalf("SetBusinessAuth failed: %v", err)
+	}
+
+	jobs := al.ScheduledSkillJobs()
+	if len(jobs) != 2 {
+		t.Fatalf("Expected a job per zone, got %+v", jobs)
+	}
+	if jobs[0].ID != cron.SkillJobPrefix+"bank-sync" || jobs[0].Schedule.TZ != "America/Toronto" {
+		t.Errorf("Unexpected default zone job: %+v", jobs[0])
+	}
+	if jobs[1].ID != cron.SkillJobPrefix+"bank-sync@Europe/Berlin" || jobs[1].Schedule.TZ != "Europe/Berlin" {
+		t.Errorf("Unexpected Berlin job: %+v", jobs[1])
+	}
+
+	if err := al.RunScheduledSkill(context.Background(), "bank-sync", "Europe/Berlin"); err != nil {
+		t.Fatalf("RunScheduledSkill failed: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	msg, ok := msgBus.SubscribeOutbound(ctx)
+	if !ok || msg.ChatID != "chat-berlin" {
+		t.Fatalf("Expected the Berlin business to run, got %+v", msg)
+	}
+	ctx2, cancel2 := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel2()
+	if extra, ok := msgBus.SubscribeOutbound(ctx2); ok {
+		t.Errorf("Expected businesses in other zones to be skipped, got: %+v", extra)
+	}
+}
+
+func TestScheduledSkillJobs_SkipsInvalidSchedule(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeBankSyncSkill(t, tmpDir)
+	skillDir := filepath.Join(tmpDir, "skills", "broken")
+	if err := os.MkdirAll(skillDir, 0o755); err != nil {
+		t.Fatalf("Failed to create skill dir: %v", err)
+	}
+	manifest := "---\nname: broken\ndescription: Bad schedule\n" +
+		"schedule: \"every night\"\nschedule_command: echo hi\n---\n"
+	if err := os.WriteFile(filepath.Join(skillDir, "SKILL.md"), []byte(manifest), 0o644); err != nil {
+		t.Fatalf("Failed to write manifest: %v", err)
+	}
+
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         tmpDir,
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &mockProvider{})
+
+	jobs := al.ScheduledSkillJobs()
+	if len(jobs) != 1 || jobs[0].Payload.Skill != "bank-sync" {
+		t.Errorf("Expected only the valid schedule, got %+v", jobs)
+	}
+}
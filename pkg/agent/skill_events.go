@@ -0,0 +1,99 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/skills"
+)
+
+// RunSkillEvents delivers asynchronous events emitted by running skills to the
+// chats that invoked them, polling every agent's event queue until ctx is done.
+func (al *AgentLoop) RunSkillEvents(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			al.deliverSkillEvents()
+		}
+	}
+}
+
+func (al *AgentLoop) deliverSkillEvents() {
+	for _, agentID := range al.registry.ListAgentIDs() {
+		agent, ok := al.registry.GetAgent(agentID)
+		if !ok || agent.SkillEvents == nil {
+			continue
+		}
+		events, err := agent.SkillEvents.Poll()
+		if err != nil {
+			logger.WarnCF("skills", "Failed to poll skill events",
+				map[string]any{"agent_id": agentID, "error": err.Error()})
+			continue
+		}
+		for _, event := range events {
+			if event.Type == skills.EventMediaHold || event.Type == skills.EventMediaRelease {
+				al.applyMediaHold(event)
+				continue
+			}
+			if event.Type == skills.EventArtifact {
+				event = al.registerArtifact(event)
+			}
+			al.bus.PublishOutbound(bus.OutboundMessage{
+				Channel:      event.Route.Channel,
+				ChatID:       event.Route.ChatID,
+				Content:      event.Message,
+				Notification: true,
+			})
+		}
+	}
+}
+
+// applyMediaHold holds or releases an uploaded file for the event's business,
+// e.g. while the transaction it backs is still unposted.
+func (al *AgentLoop) applyMediaHold(event skills.Event) {
+	if al.media == nil {
+		return
+	}
+	hold := al.media.Release
+	if event.Type == skills.EventMediaHold {
+		hold = al.media.Hold
+	}
+	if err := hold(event.Route.BusinessID, event.Message); err != nil {
+		logger.WarnCF("media", "Failed to update media hold", map[string]any{
+			"skill":       event.Route.Skill,
+			"business_id": event.Route.BusinessID,
+			"type":        event.Type,
+			"error":       err.Error(),
+		})
+	}
+}
+
+// registerArtifact registers the file named by an artifact event and turns
+// the event into a chat message that refers to it by ID.
+func (al *AgentLoop) registerArtifact(event skills.Event) skills.Event {
+	if al.artifacts == nil {
+		event.Message = fmt.Sprintf("📎 %s is ready", filepath.Base(event.Message))
+		return event
+	}
+	a, err := al.artifacts.Register(context.Background(), event.Message, event.Route.SessionKey, event.Route.BusinessID)
+	if err != nil {
+		logger.WarnCF("artifact", "Failed to register artifact", map[string]any{
+			"skill": event.Route.Skill,
+			"path":  event.Message,
+			"error": err.Error(),
+		})
+		event.Message = fmt.Sprintf("⚠️ %s could not be saved", filepath.Base(event.Message))
+		return event
+	}
+	event.Message = fmt.Sprintf("📎 %s is ready (artifact %s, %d bytes)", a.Name, a.ID, a.Size)
+	return event
+}
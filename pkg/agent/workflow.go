@@ -0,0 +1,215 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/i18n"
+	"github.com/sipeed/picoclaw/pkg/workflow"
+)
+
+// workflowChannel is the channel workflow prompts run on. It is internal and
+// a request channel, so replies go back to the workflow rather than a chat.
+const workflowChannel = "workflow"
+
+// maxWorkflowRuns is how many runs /workflow runs lists.
+const maxWorkflowRuns = 10
+
+// SetWorkflows lets chat users list, start and approve workflow runs with
+// /workflow.
+func (al *AgentLoop) SetWorkflows(e *workflow.Engine) {
+	al.workflows = e
+}
+
+// WorkflowRunner returns the workflow.Runner that carries out workflow steps
+// with the default agent.
+func (al *AgentLoop) WorkflowRunner() workflow.Runner {
+	return workflowRunner{al: al}
+}
+
+// RunScheduledWorkflow starts a run of the named workflow for its cron job.
+func (al *AgentLoop) RunScheduledWorkflow(name string) error {
+	if al.workflows == nil {
+		return fmt.Errorf("workflows are not enabled")
+	}
+	_, err := al.workflows.Start(name, workflow.TriggerSchedule, "", nil)
+	return err
+}
+
+type workflowRunner struct {
+	al *AgentLoop
+}
+
+// context acts as the run's business, with its persisted auth, as scheduled
+// skills do.
+func (r workflowRunner) context(ctx context.Context, run *workflow.Run) context.Context {
+	if run.BusinessID == "" || r.al.state == nil {
+		return ctx
+	}
+	ctx = context.WithValue(ctx, constants.ContextKeyBusinessID, run.BusinessID)
+	if auth, ok := r.al.GetActiveAuth()[run.BusinessID]; ok {
+		ctx = context.WithValue(ctx, constants.ContextKeyJWTToken, auth.JWTToken)
+	}
+	return ctx
+}
+
+// target returns the chat that a workflow's notifications go to: its own,
+// the business's last active chat, or the gateway's.
+func (r workflowRunner) target(wf *workflow.Workflow, run *workflow.Run) (string, string) {
+	if wf.Channel != "" && wf.To != "" {
+		return wf.Channel, wf.To
+	}
+	if r.al.state == nil {
+		return "", ""
+	}
+	if auth, ok := r.al.GetActiveAuth()[run.BusinessID]; ok && auth.Channel != "" && auth.ChatID != "" {
+		return auth.Channel, auth.ChatID
+	}
+	channel, chatID, _ := strings.Cut(r.al.state.GetLastChannel(), ":")
+	return channel, chatID
+}
+
+func (r workflowRunner) Prompt(ctx context.Context, run *workflow.Run, prompt string) (string, error) {
+	sessionKey := fmt.Sprintf("workflow:%s:%s", run.Workflow, run.ID)
+	return r.al.ProcessDirectWithChannel(r.context(ctx, run), prompt, sessionKey, workflowChannel, run.ID)
+}
+
+func (r workflowRunner) Command(
+	ctx context.Context,
+	run *workflow.Run,
+	skill, command, varsFile string,
+) (string, error) {
+	agent := r.al.registry.GetDefaultAgent()
+	if agent == nil {
+		return "", fmt.Errorf("no default agent")
+	}
+	dir := agent.Workspace
+	if skill != "" {
+		dir = ""
+		for _, s := range agent.ContextBuilder.ListSkills() {
+			if s.Name == skill {
+				dir = filepath.Dir(s.Path)
+				break
+			}
+		}
+		if dir == "" {
+			return "", fmt.Errorf("skill %q not found", skill)
+		}
+	}
+
+	command = fmt.Sprintf("cd %q && %s=%q %s", dir, workflow.VarsFileEnv, varsFile, command)
+	result := agent.Tools.ExecuteWithContext(r.context(ctx, run), "exec", map[string]any{"command": command},
+		workflowChannel, run.ID, nil)
+	if result.IsError {
+		return "", errors.New(result.ForLLM)
+	}
+	return result.ForLLM, nil
+}
+
+func (r workflowRunner) Notify(ctx context.Context, wf *workflow.Workflow, run *workflow.Run, message string) error {
+	channel, chatID := r.target(wf, run)
+	if channel == "" || chatID == "" || constants.IsInternalChannel(channel) {
+		return fmt.Errorf("workflow %s has no chat to notify", wf.Name)
+	}
+	r.al.bus.PublishOutbound(bus.OutboundMessage{
+		Channel:      channel,
+		ChatID:       chatID,
+		Content:      message,
+		Notification: true,
+	})
+	return nil
+}
+
+func (r workflowRunner) Report(ctx context.Context, wf *workflow.Workflow, run *workflow.Run) {
+	lang := r.al.locales.Language(run.BusinessID, "")
+	var message string
+	switch run.Status {
+	case workflow.StatusWaiting:
+		message = i18n.T(lang, "workflow.approval", wf.Name, run.Pending, run.ID)
+	case workflow.StatusFailed:
+		message = i18n.T(lang, "workflow.failed", wf.Name, run.ID, run.Error)
+	default:
+		return
+	}
+	r.Notify(ctx, wf, run, message)
+}
+
+// workflowCommand lists workflows and runs, starts a workflow for the chat's
+// business, and approves, rejects or resumes its runs.
+func (al *AgentLoop) workflowCommand(ctx context.Context, msg bus.InboundMessage, args []string) string {
+	lang := al.language(ctx, msg)
+	if al.workflows == nil {
+		return i18n.T(lang, "workflow.unavailable")
+	}
+	businessID, _ := al.chatBusiness(ctx, msg)
+
+	action := "list"
+	if len(args) > 0 {
+		action = args[0]
+	}
+	switch {
+	case action == "list":
+		workflows := al.workflows.Workflows()
+		if len(workflows) == 0 {
+			return i18n.T(lang, "workflow.none")
+		}
+		lines := []string{i18n.T(lang, "workflow.list")}
+		for _, wf := range workflows {
+			line := "- " + wf.Name
+			if wf.Description != "" {
+				line += ": " + wf.Description
+			}
+			lines = append(lines, line)
+		}
+		return strings.Join(lines, "\n")
+
+	case action == "runs":
+		runs, err := al.workflows.Runs(0)
+		if err != nil {
+			return i18n.T(lang, "workflow.failed_action", err)
+		}
+		lines := []string{i18n.T(lang, "workflow.runs")}
+		for _, run := range runs {
+			if run.BusinessID != businessID {
+				continue
+			}
+			if len(lines) > maxWorkflowRuns {
+				break
+			}
+			lines = append(lines, fmt.Sprintf("- %s: %s, %s (%s)", run.ID, run.Workflow, run.Status,
+				run.StartedAt.Format("2006-01-02 15:04")))
+		}
+		if len(lines) == 1 {
+			return i18n.T(lang, "workflow.no_runs")
+		}
+		return strings.Join(lines, "\n")
+
+	case action == "run" && len(args) == 2:
+		run, err := al.workflows.Start(args[1], workflow.TriggerManual, businessID, nil)
+		if err != nil {
+			return i18n.T(lang, "workflow.failed_action", err)
+		}
+		return i18n.T(lang, "workflow.started", run.Workflow, run.ID)
+
+	case (action == "approve" || action == "reject" || action == "resume") && len(args) == 2:
+		run, err := al.workflows.Get(args[1])
+		if err != nil || run.BusinessID != businessID {
+			return i18n.T(lang, "workflow.not_found", args[1])
+		}
+		if action == "resume" {
+			run, err = al.workflows.Resume(run.ID)
+		} else {
+			run, err = al.workflows.Decide(run.ID, action == "approve", msg.Channel+":"+msg.SenderID)
+		}
+		if err != nil {
+			return i18n.T(lang, "workflow.failed_action", err)
+		}
+		return i18n.T(lang, "workflow.decided", run.ID, run.Status)
+	}
+	return i18n.T(lang, "workflow.usage")
+}
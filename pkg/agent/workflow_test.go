@@ -0,0 +1,76 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/locale"
+	"github.com/sipeed/picoclaw/pkg/workflow"
+)
+
+func TestWorkflowCommand(t *testing.T) {
+	workspace := t.TempDir()
+	dir := filepath.Join(workspace, "workflows")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	text := "name: pay\nchannel: telegram\nto: \"42\"\nsteps:\n" +
+		"  - approval: Pay the rent?\n  - notify: Rent paid.\n"
+	if err := os.WriteFile(filepath.Join(dir, "pay.yaml"), []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	msgBus := bus.NewMessageBus()
+	al := &AgentLoop{bus: msgBus, locales: locale.NewResolver(config.LocaleConfig{})}
+	got := al.workflowCommand(context.Background(), bus.InboundMessage{}, nil)
+	if !strings.Contains(got, "not turned on") {
+		t.Errorf("without workflows: %q", got)
+	}
+	engine, err := workflow.New(workspace, al.WorkflowRunner())
+	if err != nil {
+		t.Fatalf("workflow.New: %v", err)
+	}
+	al.SetWorkflows(engine)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	msg := bus.InboundMessage{Channel: "telegram", ChatID: "42", SenderID: "7"}
+	command := func(args ...string) string {
+		return al.workflowCommand(ctx, msg, args)
+	}
+	if got := command("list"); !strings.Contains(got, "- pay") {
+		t.Errorf("list = %q", got)
+	}
+	if got := command("run", "pay"); !strings.HasPrefix(got, "Started workflow pay") {
+		t.Fatalf("run = %q", got)
+	}
+
+	out, ok := msgBus.SubscribeOutbound(ctx)
+	if !ok || out.ChatID != "42" || !strings.Contains(out.Content, "Pay the rent?") {
+		t.Fatalf("approval request = %+v", out)
+	}
+	runs, err := engine.Runs(1)
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("Runs = %v, %v", runs, err)
+	}
+	if !strings.Contains(out.Content, "/workflow approve "+runs[0].ID) {
+		t.Errorf("approval request has no approve hint: %q", out.Content)
+	}
+
+	if got := command("approve", "nope"); !strings.Contains(got, "not found") {
+		t.Errorf("approve unknown run = %q", got)
+	}
+	if got := command("approve", runs[0].ID); !strings.Contains(got, "running") {
+		t.Errorf("approve = %q", got)
+	}
+	out, ok = msgBus.SubscribeOutbound(ctx)
+	if !ok || out.Content != "Rent paid." || !out.Notification {
+		t.Errorf("notification = %+v", out)
+	}
+}
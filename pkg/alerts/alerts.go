@@ -0,0 +1,218 @@
+// Package alerts watches for failure conditions (repeated webhook failures,
+// an unreachable LLM provider, a nearly full disk) and notifies the owner,
+// with a cooldown so a lasting problem is reported once rather than on every
+// check.
+//
+// Record functions are no-ops until a Monitor is running.
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/httpclient"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// Rules that can fire.
+const (
+	RuleWebhookFailures = "webhook_failures"
+	RuleProviderDown    = "provider_unreachable"
+	RuleDiskUsage       = "disk_usage"
+)
+
+// Alert is a notification that a rule fired.
+type Alert struct {
+	Rule      string    `json:"rule"`
+	Message   string    `json:"message"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Notifier delivers an alert.
+type Notifier func(Alert) error
+
+// Options configures the alert rules. A zero threshold disables its rule.
+type Options struct {
+	WebhookFailures int           // consecutive failed webhook requests
+	ProviderDownFor time.Duration // LLM calls failing with no success for this long
+	DiskPercent     float64       // used space on the workspace filesystem
+	DiskPath        string
+	Cooldown        time.Duration // minimum time between alerts for the same rule
+	Notifiers       []Notifier
+
+	// Checks are further rules by name, each firing while its check fails,
+	// such as the hardware checks.
+	Checks map[string]func() (bool, string)
+}
+
+// Monitor evaluates the rules and sends alerts.
+type Monitor struct {
+	opts Options
+	now  func() time.Time
+
+	mu              sync.Mutex
+	webhookFailures int
+	providerFailing time.Time // first failure since the last success; zero when healthy
+	providerLastErr string
+	firing          map[string]bool      // rule condition currently true
+	lastSent        map[string]time.Time // by rule
+}
+
+var active atomic.Pointer[Monitor]
+
+// NewMonitor creates a monitor. It receives recorded events once Run is called.
+func NewMonitor(opts Options) *Monitor {
+	return &Monitor{
+		opts:     opts,
+		now:      time.Now,
+		firing:   map[string]bool{},
+		lastSent: map[string]time.Time{},
+	}
+}
+
+// Run makes m the target of the package-level Record functions and
+// evaluates the time-based rules every interval until ctx is done.
+func (m *Monitor) Run(ctx context.Context, interval time.Duration) {
+	active.Store(m)
+	defer active.CompareAndSwap(m, nil)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.Evaluate()
+		}
+	}
+}
+
+// RecordWebhook records the outcome of a webhook request.
+func RecordWebhook(ok bool) {
+	if m := active.Load(); m != nil {
+		m.RecordWebhook(ok)
+	}
+}
+
+// RecordProvider records the outcome of an LLM call.
+func RecordProvider(err error) {
+	if m := active.Load(); m != nil {
+		m.RecordProvider(err)
+	}
+}
+
+// RecordWebhook records the outcome of a webhook request.
+func (m *Monitor) RecordWebhook(ok bool) {
+	if m.opts.WebhookFailures <= 0 {
+		return
+	}
+	m.mu.Lock()
+	if ok {
+		m.webhookFailures = 0
+	} else {
+		m.webhookFailures++
+	}
+	failures := m.webhookFailures
+	m.mu.Unlock()
+
+	m.update(RuleWebhookFailures, failures >= m.opts.WebhookFailures,
+		fmt.Sprintf("%d consecutive webhook requests failed", failures))
+}
+
+// RecordProvider records the outcome of an LLM call. The provider rule itself
+// is time-based and checked by Evaluate.
+func (m *Monitor) RecordProvider(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err == nil {
+		m.providerFailing = time.Time{}
+		m.providerLastErr = ""
+		return
+	}
+	if m.providerFailing.IsZero() {
+		m.providerFailing = m.now()
+	}
+	m.providerLastErr = err.Error()
+}
+
+// Evaluate checks the time- and resource-based rules.
+func (m *Monitor) Evaluate() {
+	if m.opts.ProviderDownFor > 0 {
+		m.mu.Lock()
+		since, lastErr := m.providerFailing, m.providerLastErr
+		m.mu.Unlock()
+		down := !since.IsZero() && m.now().Sub(since) >= m.opts.ProviderDownFor
+		m.update(RuleProviderDown, down, fmt.Sprintf("LLM provider unreachable for %s: %s",
+			m.now().Sub(since).Round(time.Minute), lastErr))
+	}
+
+	if m.opts.DiskPercent > 0 && m.opts.DiskPath != "" {
+		used, err := diskUsage(m.opts.DiskPath)
+		if err != nil {
+			logger.DebugCF("alerts", "Disk usage unavailable", map[string]any{"error": err.Error()})
+		} else {
+			m.update(RuleDiskUsage, used >= m.opts.DiskPercent,
+				fmt.Sprintf("Disk %.0f%% full (%s)", used, m.opts.DiskPath))
+		}
+	}
+
+	for rule, check := range m.opts.Checks {
+		ok, msg := check()
+		m.update(rule, !ok, msg)
+	}
+}
+
+// update fires rule when its condition becomes true, unless it fired within
+// the cooldown. A rule that stays true is not re-sent until it clears.
+func (m *Monitor) update(rule string, condition bool, message string) {
+	m.mu.Lock()
+	wasFiring := m.firing[rule]
+	m.firing[rule] = condition
+	if !condition || wasFiring {
+		m.mu.Unlock()
+		return
+	}
+	now := m.now()
+	if last, ok := m.lastSent[rule]; ok && now.Sub(last) < m.opts.Cooldown {
+		m.mu.Unlock()
+		logger.InfoCF("alerts", "Alert suppressed by cooldown", map[string]any{"rule": rule})
+		return
+	}
+	m.lastSent[rule] = now
+	m.mu.Unlock()
+
+	alert := Alert{Rule: rule, Message: message, Timestamp: now.UTC()}
+	logger.WarnCF("alerts", "Alert fired", map[string]any{"rule": rule, "message": message})
+	for _, notify := range m.opts.Notifiers {
+		if err := notify(alert); err != nil {
+			logger.WarnCF("alerts", "Failed to deliver alert",
+				map[string]any{"rule": rule, "error": err.Error()})
+		}
+	}
+}
+
+// WebhookNotifier posts alerts as JSON to url.
+func WebhookNotifier(url string) Notifier {
+	client := httpclient.New(10 * time.Second)
+	return func(alert Alert) error {
+		body, err := json.Marshal(alert)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("alert webhook returned %s", resp.Status)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,100 @@
+package alerts
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestMonitor(opts Options) (*Monitor, *[]Alert, *time.Time) {
+	var sent []Alert
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	opts.Notifiers = append(opts.Notifiers, func(a Alert) error {
+		sent = append(sent, a)
+		return nil
+	})
+	m := NewMonitor(opts)
+	m.now = func() time.Time { return now }
+	return m, &sent, &now
+}
+
+func TestWebhookFailuresFireOnceWithCooldown(t *testing.T) {
+	m, sent, now := newTestMonitor(Options{WebhookFailures: 3, Cooldown: time.Hour})
+
+	for i := 0; i < 5; i++ {
+		m.RecordWebhook(false)
+	}
+	require.Len(t, *sent, 1, "a lasting failure is reported once")
+	assert.Equal(t, RuleWebhookFailures, (*sent)[0].Rule)
+	assert.Contains(t, (*sent)[0].Message, "3 consecutive")
+
+	// Recovers and fails again within the cooldown: suppressed.
+	m.RecordWebhook(true)
+	for i := 0; i < 3; i++ {
+		m.RecordWebhook(false)
+	}
+	assert.Len(t, *sent, 1)
+
+	// After the cooldown a new episode is reported.
+	m.RecordWebhook(true)
+	*now = now.Add(2 * time.Hour)
+	for i := 0; i < 3; i++ {
+		m.RecordWebhook(false)
+	}
+	assert.Len(t, *sent, 2)
+}
+
+func TestProviderDown(t *testing.T) {
+	m, sent, now := newTestMonitor(Options{ProviderDownFor: 10 * time.Minute, Cooldown: time.Hour})
+
+	m.RecordProvider(errors.New("connection refused"))
+	*now = now.Add(5 * time.Minute)
+	m.RecordProvider(errors.New("connection refused"))
+	m.Evaluate()
+	assert.Empty(t, *sent)
+
+	*now = now.Add(5 * time.Minute)
+	m.Evaluate()
+	m.Evaluate()
+	require.Len(t, *sent, 1)
+	assert.Equal(t, RuleProviderDown, (*sent)[0].Rule)
+	assert.Contains(t, (*sent)[0].Message, "connection refused")
+
+	m.RecordProvider(nil)
+	m.Evaluate()
+	assert.Len(t, *sent, 1)
+}
+
+func TestCheckRules(t *testing.T) {
+	hot := false
+	m, sent, _ := newTestMonitor(Options{Checks: map[string]func() (bool, string){
+		"cpu_temperature": func() (bool, string) { return !hot, "CPU at 86.0°C (limit 80°C)" },
+	}})
+
+	m.Evaluate()
+	assert.Empty(t, *sent)
+	hot = true
+	m.Evaluate()
+	m.Evaluate()
+	require.Len(t, *sent, 1)
+	assert.Equal(t, "cpu_temperature", (*sent)[0].Rule)
+	assert.Equal(t, "CPU at 86.0°C (limit 80°C)", (*sent)[0].Message)
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var got Alert
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+	}))
+	defer srv.Close()
+
+	alert := Alert{Rule: RuleDiskUsage, Message: "Disk 95% full", Timestamp: time.Now().UTC().Truncate(time.Second)}
+	require.NoError(t, WebhookNotifier(srv.URL)(alert))
+	assert.Equal(t, alert, got)
+}
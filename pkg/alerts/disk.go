@@ -0,0 +1,12 @@
+package alerts
+
+import "github.com/sipeed/picoclaw/pkg/utils"
+
+// diskUsage returns the used fraction (0-100) of the filesystem holding path.
+func diskUsage(path string) (float64, error) {
+	free, total, err := utils.DiskSpace(path)
+	if err != nil || total == 0 {
+		return 0, err
+	}
+	return 100 * float64(total-free) / float64(total), nil
+}
@@ -0,0 +1,298 @@
+// Package artifact keeps an index of files created by tools and skills
+// (reports, exports, generated images) so the download API, the cleanup job
+// and channels can refer to them by ID instead of by path.
+//
+// The index lives at <workspace>/state/artifacts.json. Each artifact expires
+// after its TTL; Cleanup then removes the file, its blob store copy and the
+// index entry.
+package artifact
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/blob"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/telemetry"
+)
+
+// ErrNotFound is returned for unknown or expired artifact IDs.
+var ErrNotFound = errors.New("artifact not found")
+
+// blobKeyPrefix is where artifacts are stored in the blob store.
+const blobKeyPrefix = "artifacts/"
+
+// Artifact is a registered file.
+type Artifact struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Path       string    `json:"path"`               // absolute local path
+	BlobKey    string    `json:"blob_key,omitempty"` // set when copied to the blob store
+	MIME       string    `json:"mime"`
+	Size       int64     `json:"size"`
+	SessionKey string    `json:"session_key,omitempty"`
+	BusinessID string    `json:"business_id,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at,omitzero"` // zero: never
+}
+
+// Options configures a registry.
+type Options struct {
+	TTL   time.Duration // default lifetime; 0 keeps artifacts until removed
+	Store blob.Store    // optional: artifacts are also copied here
+}
+
+// Registry is the artifact index of a workspace.
+type Registry struct {
+	workspace string
+	path      string
+	opts      Options
+	now       func() time.Time
+
+	mu        sync.Mutex
+	artifacts map[string]*Artifact
+}
+
+// NewRegistry loads the artifact index for workspace.
+func NewRegistry(workspace string, opts Options) (*Registry, error) {
+	r := &Registry{
+		workspace: workspace,
+		path:      filepath.Join(workspace, "state", "artifacts.json"),
+		opts:      opts,
+		now:       time.Now,
+		artifacts: map[string]*Artifact{},
+	}
+	data, err := os.ReadFile(r.path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to load artifact index: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &r.artifacts); err != nil {
+			return nil, fmt.Errorf("failed to load artifact index: %w", err)
+		}
+	}
+	return r, nil
+}
+
+// Register adds the file at path, which must be inside the workspace, for
+// the given session and business. A relative path is resolved against the
+// workspace.
+func (r *Registry) Register(ctx context.Context, path, sessionKey, businessID string) (Artifact, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(r.workspace, path)
+	}
+	path = filepath.Clean(path)
+	if rel, err := filepath.Rel(r.workspace, path); err != nil || rel == ".." ||
+		strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return Artifact{}, fmt.Errorf("%s is outside the workspace", path)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return Artifact{}, err
+	}
+	if !info.Mode().IsRegular() {
+		return Artifact{}, fmt.Errorf("%s is not a regular file", path)
+	}
+
+	id, err := newID()
+	if err != nil {
+		return Artifact{}, err
+	}
+	now := r.now().UTC()
+	a := Artifact{
+		ID:         id,
+		Name:       filepath.Base(path),
+		Path:       path,
+		MIME:       detectMIME(path),
+		Size:       info.Size(),
+		SessionKey: sessionKey,
+		BusinessID: businessID,
+		CreatedAt:  now,
+	}
+	if r.opts.TTL > 0 {
+		a.ExpiresAt = now.Add(r.opts.TTL)
+	}
+	if r.opts.Store != nil {
+		key := blobKeyPrefix + id + "/" + a.Name
+		if err := blob.PutFile(ctx, r.opts.Store, key, path, a.MIME); err != nil {
+			logger.WarnCF("artifact", "Failed to copy artifact to blob store", map[string]any{
+				"id": id, "error": err.Error(),
+			})
+		} else {
+			a.BlobKey = key
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.artifacts[id] = &a
+	if err := r.save(); err != nil {
+		delete(r.artifacts, id)
+		return Artifact{}, err
+	}
+	telemetry.AddCounter("picoclaw.artifacts.registered", 1)
+	return a, nil
+}
+
+// Get returns an unexpired artifact.
+func (r *Registry) Get(id string) (Artifact, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	a, ok := r.artifacts[id]
+	if !ok || r.expired(a) {
+		return Artifact{}, ErrNotFound
+	}
+	return *a, nil
+}
+
+// List returns the unexpired artifacts of a session and business, newest
+// first. An empty sessionKey or businessID matches any.
+func (r *Registry) List(sessionKey, businessID string) []Artifact {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list := []Artifact{}
+	for _, a := range r.artifacts {
+		if !r.expired(a) && (sessionKey == "" || a.SessionKey == sessionKey) &&
+			(businessID == "" || a.BusinessID == businessID) {
+			list = append(list, *a)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	return list
+}
+
+// Run removes expired artifacts immediately and then every interval until
+// ctx is done.
+func (r *Registry) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := r.Cleanup(ctx); err != nil {
+			logger.WarnCF("artifact", "Artifact cleanup failed", map[string]any{"error": err.Error()})
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Cleanup removes expired artifacts and entries whose file is gone, and
+// returns how many were removed.
+func (r *Registry) Cleanup(ctx context.Context) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	removed := 0
+	for id, a := range r.artifacts {
+		_, statErr := os.Stat(a.Path)
+		missing := os.IsNotExist(statErr) && a.BlobKey == ""
+		if !r.expired(a) && !missing {
+			continue
+		}
+		if err := os.Remove(a.Path); err != nil && !os.IsNotExist(err) {
+			logger.WarnCF("artifact", "Failed to remove artifact file", map[string]any{"id": id, "error": err.Error()})
+			continue
+		}
+		if a.BlobKey != "" && r.opts.Store != nil {
+			if err := r.opts.Store.Delete(ctx, a.BlobKey); err != nil {
+				logger.WarnCF("artifact", "Failed to remove artifact from blob store", map[string]any{
+					"id": id, "error": err.Error(),
+				})
+			}
+		}
+		delete(r.artifacts, id)
+		removed++
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+	telemetry.AddCounter("picoclaw.artifacts.expired", int64(removed))
+	logger.InfoCF("artifact", "Removed expired artifacts", map[string]any{"count": removed})
+	return removed, r.save()
+}
+
+// DeleteSession removes the artifacts of a session, expired or not, with
+// their files and blob store copies, and returns how many it removed.
+func (r *Registry) DeleteSession(ctx context.Context, sessionKey string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	removed := 0
+	for id, a := range r.artifacts {
+		if a.SessionKey != sessionKey {
+			continue
+		}
+		if err := os.Remove(a.Path); err != nil && !os.IsNotExist(err) {
+			return removed, fmt.Errorf("failed to remove artifact %s: %w", id, err)
+		}
+		if a.BlobKey != "" && r.opts.Store != nil {
+			if err := r.opts.Store.Delete(ctx, a.BlobKey); err != nil {
+				return removed, fmt.Errorf("failed to remove artifact %s from the blob store: %w", id, err)
+			}
+		}
+		delete(r.artifacts, id)
+		removed++
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+	return removed, r.save()
+}
+
+func (r *Registry) expired(a *Artifact) bool {
+	return !a.ExpiresAt.IsZero() && !r.now().Before(a.ExpiresAt)
+}
+
+// save persists the index. Callers must hold r.mu.
+func (r *Registry) save() error {
+	data, err := json.MarshalIndent(r.artifacts, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return err
+	}
+	tmp := r.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write artifact index: %w", err)
+	}
+	return os.Rename(tmp, r.path)
+}
+
+func newID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate artifact id: %w", err)
+	}
+	return "art_" + hex.EncodeToString(b), nil
+}
+
+// detectMIME guesses a file's type from its extension, then its contents.
+func detectMIME(path string) string {
+	if t := mime.TypeByExtension(filepath.Ext(path)); t != "" {
+		return t
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "application/octet-stream"
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, _ := f.Read(head)
+	return http.DetectContentType(head[:n])
+}
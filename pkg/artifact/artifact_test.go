@@ -0,0 +1,92 @@
+package artifact
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterGetAndExpire(t *testing.T) {
+	workspace := t.TempDir()
+	path := filepath.Join(workspace, "exports", "march.pdf")
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte("%PDF-1.4\n"), 0o644))
+
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	r, err := NewRegistry(workspace, Options{TTL: time.Hour})
+	require.NoError(t, err)
+	r.now = func() time.Time { return now }
+
+	a, err := r.Register(t.Context(), "exports/march.pdf", "agent:main:chat1", "biz-1")
+	require.NoError(t, err)
+	assert.Contains(t, a.ID, "art_")
+	assert.Equal(t, "march.pdf", a.Name)
+	assert.Equal(t, path, a.Path)
+	assert.Equal(t, int64(9), a.Size)
+	assert.Equal(t, "application/pdf", a.MIME)
+	assert.Equal(t, now.Add(time.Hour), a.ExpiresAt)
+
+	// The index survives a restart.
+	r, err = NewRegistry(workspace, Options{TTL: time.Hour})
+	require.NoError(t, err)
+	r.now = func() time.Time { return now }
+	got, err := r.Get(a.ID)
+	require.NoError(t, err)
+	assert.Equal(t, a.Path, got.Path)
+	assert.Len(t, r.List("agent:main:chat1", ""), 1)
+	assert.Len(t, r.List("", "biz-1"), 1)
+	assert.Empty(t, r.List("agent:main:other", ""))
+	assert.Empty(t, r.List("agent:main:chat1", "biz-2"))
+
+	now = now.Add(2 * time.Hour)
+	_, err = r.Get(a.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	removed, err := r.Cleanup(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	assert.NoFileExists(t, path)
+}
+
+func TestRegisterRejectsFilesOutsideWorkspace(t *testing.T) {
+	r, err := NewRegistry(t.TempDir(), Options{})
+	require.NoError(t, err)
+
+	outside := filepath.Join(t.TempDir(), "secret.txt")
+	require.NoError(t, os.WriteFile(outside, []byte("x"), 0o600))
+	_, err = r.Register(t.Context(), outside, "", "")
+	assert.Error(t, err)
+	_, err = r.Register(t.Context(), "../secret.txt", "", "")
+	assert.Error(t, err)
+}
+
+func TestDeleteSession(t *testing.T) {
+	workspace := t.TempDir()
+	r, err := NewRegistry(workspace, Options{})
+	require.NoError(t, err)
+
+	for _, name := range []string{"a.csv", "b.csv", "c.csv"} {
+		require.NoError(t, os.WriteFile(filepath.Join(workspace, name), []byte("x"), 0o644))
+	}
+	_, err = r.Register(t.Context(), "a.csv", "agent:main:chat1", "")
+	require.NoError(t, err)
+	_, err = r.Register(t.Context(), "b.csv", "agent:main:chat1", "")
+	require.NoError(t, err)
+	other, err := r.Register(t.Context(), "c.csv", "agent:main:chat2", "")
+	require.NoError(t, err)
+
+	removed, err := r.DeleteSession(t.Context(), "agent:main:chat1")
+	require.NoError(t, err)
+	assert.Equal(t, 2, removed)
+	assert.NoFileExists(t, filepath.Join(workspace, "a.csv"))
+	assert.NoFileExists(t, filepath.Join(workspace, "b.csv"))
+	assert.Empty(t, r.List("agent:main:chat1", ""))
+
+	r, err = NewRegistry(workspace, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, []Artifact{other}, r.List("", ""))
+}
@@ -0,0 +1,177 @@
+// Package audit records operator-facing audit trails in the workspace.
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logrotate"
+)
+
+// SkillInvocation is a single recorded skill script execution.
+type SkillInvocation struct {
+	Timestamp   time.Time `json:"timestamp"`
+	Skill       string    `json:"skill"`
+	ArgsHash    string    `json:"args_hash"`
+	BusinessID  string    `json:"business_id,omitempty"`
+	Channel     string    `json:"channel,omitempty"`
+	ChatID      string    `json:"chat_id,omitempty"`
+	ExitCode    int       `json:"exit_code"`
+	DurationMS  int64     `json:"duration_ms"`
+	OutputBytes int       `json:"output_bytes"`
+}
+
+// SkillQuery filters skill invocations. Zero-valued fields match everything.
+type SkillQuery struct {
+	Skill      string
+	BusinessID string
+	Since      time.Time
+	Until      time.Time
+	Limit      int // 0 means DefaultQueryLimit
+}
+
+// DefaultQueryLimit caps query results when no limit is given.
+const DefaultQueryLimit = 100
+
+// Log is an append-only JSONL audit log stored under <workspace>/audit.
+// Rotated files are kept next to it and still searched by queries.
+type Log struct {
+	mu        sync.Mutex
+	skillFile string
+	rotation  logrotate.Options
+	writer    *logrotate.Writer
+}
+
+// NewLog creates an audit log for the given workspace.
+func NewLog(workspace string) *Log {
+	return &Log{
+		skillFile: filepath.Join(workspace, "audit", "skills.jsonl"),
+	}
+}
+
+// SetRotation sets how the skill log is rotated. Call it before the first
+// RecordSkill.
+func (l *Log) SetRotation(opts logrotate.Options) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rotation = opts
+}
+
+// RecordSkill appends a skill invocation to the audit log.
+func (l *Log) RecordSkill(inv SkillInvocation) error {
+	if inv.Timestamp.IsZero() {
+		inv.Timestamp = time.Now().UTC()
+	}
+
+	data, err := json.Marshal(inv)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.writer == nil {
+		w, err := logrotate.Open(l.skillFile, l.rotation)
+		if err != nil {
+			return fmt.Errorf("failed to open audit log: %w", err)
+		}
+		l.writer = w
+	}
+
+	if _, err := l.writer.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+	return nil
+}
+
+// QuerySkills returns matching invocations, newest first.
+func (l *Log) QuerySkills(q SkillQuery) ([]SkillInvocation, error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultQueryLimit
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	files, err := logrotate.Files(l.skillFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit logs: %w", err)
+	}
+
+	var matches []SkillInvocation
+	for _, path := range files {
+		matches, err = scanSkills(path, q, limit, matches)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(matches) > limit {
+		matches = matches[len(matches)-limit:]
+	}
+
+	// Reverse to newest first
+	for i, j := 0, len(matches)-1; i < j; i, j = i+1, j-1 {
+		matches[i], matches[j] = matches[j], matches[i]
+	}
+	if matches == nil {
+		matches = []SkillInvocation{}
+	}
+	return matches, nil
+}
+
+// scanSkills appends the matching invocations in path to matches, keeping
+// only the most recent window to bound memory.
+func scanSkills(path string, q SkillQuery, limit int, matches []SkillInvocation) ([]SkillInvocation, error) {
+	r, err := logrotate.OpenReader(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return matches, nil // rotated away since it was listed
+		}
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer r.Close()
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var inv SkillInvocation
+		if err := json.Unmarshal(scanner.Bytes(), &inv); err != nil {
+			continue // skip partial or corrupt lines
+		}
+		if !q.matches(inv) {
+			continue
+		}
+		matches = append(matches, inv)
+		if len(matches) > limit*2 {
+			matches = matches[len(matches)-limit:]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return matches, nil
+}
+
+func (q SkillQuery) matches(inv SkillInvocation) bool {
+	if q.Skill != "" && inv.Skill != q.Skill {
+		return false
+	}
+	if q.BusinessID != "" && inv.BusinessID != q.BusinessID {
+		return false
+	}
+	if !q.Since.IsZero() && inv.Timestamp.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && inv.Timestamp.After(q.Until) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,81 @@
+package audit
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/logrotate"
+)
+
+func TestLog_RecordAndQuerySkills(t *testing.T) {
+	dir, err := os.MkdirTemp("", "audit-test-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	log := NewLog(dir)
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	entries := []SkillInvocation{
+		{Timestamp: base, Skill: "oluto", BusinessID: "biz-1", ExitCode: 0},
+		{Timestamp: base.Add(time.Minute), Skill: "weather", BusinessID: "biz-1", ExitCode: 1},
+		{Timestamp: base.Add(2 * time.Minute), Skill: "oluto", BusinessID: "biz-2", ExitCode: 0},
+		{Timestamp: base.Add(3 * time.Minute), Skill: "oluto", BusinessID: "biz-1", ExitCode: 2},
+	}
+	for _, e := range entries {
+		require.NoError(t, log.RecordSkill(e))
+	}
+
+	all, err := log.QuerySkills(SkillQuery{})
+	require.NoError(t, err)
+	require.Len(t, all, 4)
+	assert.Equal(t, 2, all[0].ExitCode, "results should be newest first")
+
+	oluto, err := log.QuerySkills(SkillQuery{Skill: "oluto", BusinessID: "biz-1"})
+	require.NoError(t, err)
+	require.Len(t, oluto, 2)
+
+	since, err := log.QuerySkills(SkillQuery{Since: base.Add(90 * time.Second)})
+	require.NoError(t, err)
+	assert.Len(t, since, 2)
+
+	limited, err := log.QuerySkills(SkillQuery{Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, limited, 1)
+	assert.Equal(t, base.Add(3*time.Minute), limited[0].Timestamp)
+}
+
+func TestLog_QueryMissingFile(t *testing.T) {
+	dir, err := os.MkdirTemp("", "audit-test-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	result, err := NewLog(dir).QuerySkills(SkillQuery{})
+	require.NoError(t, err)
+	assert.Empty(t, result)
+}
+
+func TestLog_QuerySpansRotatedFiles(t *testing.T) {
+	log := NewLog(t.TempDir())
+	log.SetRotation(logrotate.Options{MaxBytes: 200, Compress: true})
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 6; i++ {
+		require.NoError(t, log.RecordSkill(SkillInvocation{
+			Timestamp: base.Add(time.Duration(i) * time.Minute), Skill: "oluto", ExitCode: i,
+		}))
+	}
+
+	backups, err := logrotate.Backups(log.skillFile)
+	require.NoError(t, err)
+	require.NotEmpty(t, backups)
+
+	all, err := log.QuerySkills(SkillQuery{})
+	require.NoError(t, err)
+	require.Len(t, all, 6)
+	assert.Equal(t, 5, all[0].ExitCode)
+	assert.Equal(t, 0, all[5].ExitCode)
+}
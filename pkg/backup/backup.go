@@ -0,0 +1,374 @@
+// Package backup exports a picoclaw installation (config, state, sessions,
+// skills and selected media) to a single tar.zst archive and imports it on
+// another device.
+//
+// Secrets in the config are never written in clear text. Without a
+// passphrase they are masked and import keeps the target's existing values;
+// with one they are encrypted (AES-GCM, key derived with PBKDF2) and restored
+// on import with the same passphrase.
+package backup
+
+import (
+	"archive/tar"
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/sipeed/picoclaw/pkg/media"
+)
+
+// FormatVersion is the archive layout version written to the manifest.
+const FormatVersion = 1
+
+const (
+	manifestName    = "manifest.json"
+	configName      = "config.json"
+	workspacePrefix = "workspace/"
+)
+
+// Manifest describes an archive.
+type Manifest struct {
+	Version      int       `json:"version"`
+	CreatedAt    time.Time `json:"created_at"`
+	Release      string    `json:"release,omitempty"` // picoclaw version that wrote it
+	Secrets      string    `json:"secrets"`           // "masked" or "encrypted"
+	Salt         []byte    `json:"salt,omitempty"`    // key derivation salt when encrypted
+	Files        int       `json:"files"`
+	MediaSkipped int       `json:"media_skipped,omitempty"`
+	KeyOmitted   bool      `json:"key_omitted,omitempty"` // media key left out of a masked archive
+}
+
+// ExportOptions configures an export.
+type ExportOptions struct {
+	ConfigPath string
+	Workspace  string
+	Release    string
+	Passphrase string // encrypts secrets instead of masking them
+	NoMedia    bool
+	MediaSince time.Time // only media modified after this; zero for all
+	// Substitutes maps workspace paths ("state/transcripts.db") to files
+	// archived in their place, such as consistent copies of live SQLite
+	// databases. The -wal and -shm files of a substituted path are left out.
+	Substitutes map[string]string
+}
+
+// skipDirs are workspace directories that are device-local and not exported.
+var skipDirs = map[string]bool{
+	"logs":    true,
+	"debug":   true,
+	"crashes": true,
+	"wire":    true,
+}
+
+// Export writes an archive of the installation to w.
+func Export(w io.Writer, opts ExportOptions) (Manifest, error) {
+	manifest := Manifest{
+		Version:   FormatVersion,
+		CreatedAt: time.Now().UTC(),
+		Release:   opts.Release,
+		Secrets:   secretsMasked,
+	}
+
+	rawConfig, err := os.ReadFile(opts.ConfigPath)
+	if err != nil {
+		return manifest, fmt.Errorf("failed to read config: %w", err)
+	}
+	var cfg map[string]any
+	if err := json.Unmarshal(rawConfig, &cfg); err != nil {
+		return manifest, fmt.Errorf("failed to parse config: %w", err)
+	}
+	var seal func(string) (string, error)
+	if opts.Passphrase != "" {
+		manifest.Secrets = secretsEncrypted
+		if manifest.Salt, err = newSalt(); err != nil {
+			return manifest, err
+		}
+		seal = sealer(opts.Passphrase, manifest.Salt)
+	}
+	if err := protectSecrets(cfg, seal); err != nil {
+		return manifest, err
+	}
+	configData, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return manifest, err
+	}
+
+	zw, err := zstd.NewWriter(w)
+	if err != nil {
+		return manifest, err
+	}
+	tw := tar.NewWriter(zw)
+
+	// The config goes first so import knows where the workspace goes, and
+	// the manifest last so it can count the files.
+	if err := addBytes(tw, configName, configData); err != nil {
+		return manifest, err
+	}
+	err = filepath.WalkDir(opts.Workspace, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(opts.Workspace, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		top, _, _ := strings.Cut(rel, "/")
+		if d.IsDir() {
+			if skipDirs[top] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || strings.HasSuffix(rel, ".tmp") || strings.HasPrefix(d.Name(), ".upload-") {
+			return nil
+		}
+		if _, ok := opts.Substitutes[strings.TrimSuffix(strings.TrimSuffix(rel, "-wal"), "-shm")]; ok &&
+			(strings.HasSuffix(rel, "-wal") || strings.HasSuffix(rel, "-shm")) {
+			return nil
+		}
+		if sub, ok := opts.Substitutes[rel]; ok {
+			p = sub
+		}
+		info, err := os.Stat(p)
+		if err != nil {
+			return err
+		}
+		if top == "media" && (opts.NoMedia || info.ModTime().Before(opts.MediaSince)) {
+			manifest.MediaSkipped++
+			return nil
+		}
+		if rel == media.KeyFile {
+			// The media key is a secret like those in the config: it is only
+			// exported sealed with the passphrase.
+			if seal == nil {
+				manifest.KeyOmitted = true
+				return nil
+			}
+			if err := addSealedFile(tw, workspacePrefix+rel, p, seal); err != nil {
+				return fmt.Errorf("failed to add %s: %w", rel, err)
+			}
+			manifest.Files++
+			return nil
+		}
+		if err := addFile(tw, workspacePrefix+rel, p, info); err != nil {
+			return fmt.Errorf("failed to add %s: %w", rel, err)
+		}
+		manifest.Files++
+		return nil
+	})
+	if err != nil {
+		return manifest, err
+	}
+
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return manifest, err
+	}
+	if err := addBytes(tw, manifestName, manifestData); err != nil {
+		return manifest, err
+	}
+	if err := tw.Close(); err != nil {
+		return manifest, err
+	}
+	return manifest, zw.Close()
+}
+
+// ImportOptions configures an import.
+type ImportOptions struct {
+	ConfigPath string
+	// Workspace returns where to extract the workspace, given the imported
+	// config (whose workspace path may not suit this device).
+	Workspace  func(config []byte) (string, error)
+	Passphrase string
+	Force      bool // overwrite a non-empty workspace
+}
+
+// Import restores an archive read from r, decrypting it with the passphrase
+// if it was written through Encrypt. The existing config is kept as
+// <config>.bak.
+func Import(r io.Reader, opts ImportOptions) (Manifest, error) {
+	var manifest Manifest
+
+	br := bufio.NewReader(r)
+	if head, _ := br.Peek(len(encryptedMagic)); IsEncrypted(head) {
+		plain, err := Decrypt(br, opts.Passphrase)
+		if err != nil {
+			return manifest, err
+		}
+		r = plain
+	} else {
+		r = br
+	}
+	zr, err := zstd.NewReader(r)
+	if err != nil {
+		return manifest, err
+	}
+	defer zr.Close()
+
+	// Files are extracted to a staging directory next to the workspace and
+	// only moved into place once the whole archive has been read.
+	var configData, manifestData, sealedKey []byte
+	var workspace, staging string
+	defer func() {
+		if staging != "" {
+			os.RemoveAll(staging)
+		}
+	}()
+	tr := tar.NewReader(zr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return manifest, fmt.Errorf("failed to read archive: %w", err)
+		}
+		switch {
+		case hdr.Name == manifestName:
+			manifestData, err = io.ReadAll(tr)
+		case hdr.Name == configName:
+			if configData, err = io.ReadAll(tr); err != nil {
+				break
+			}
+			if workspace, err = opts.Workspace(configData); err != nil {
+				break
+			}
+			if err = os.MkdirAll(filepath.Dir(workspace), 0o755); err != nil {
+				break
+			}
+			staging, err = os.MkdirTemp(filepath.Dir(workspace), ".import-*")
+		case hdr.Name == workspacePrefix+media.KeyFile:
+			sealedKey, err = io.ReadAll(tr)
+		case strings.HasPrefix(hdr.Name, workspacePrefix) && hdr.Typeflag == tar.TypeReg:
+			if staging == "" {
+				return manifest, errors.New("invalid archive: workspace files before config")
+			}
+			err = extractFile(tr, staging, strings.TrimPrefix(hdr.Name, workspacePrefix), hdr)
+		}
+		if err != nil {
+			return manifest, err
+		}
+	}
+	if manifestData == nil || configData == nil {
+		return manifest, errors.New("not a picoclaw archive: missing manifest or config")
+	}
+	if err := json.Unmarshal(manifestData, &manifest); err != nil {
+		return manifest, fmt.Errorf("invalid manifest: %w", err)
+	}
+	if manifest.Version > FormatVersion {
+		return manifest, fmt.Errorf("archive format %d is newer than supported (%d)", manifest.Version, FormatVersion)
+	}
+
+	configData, err = restoreSecrets(configData, opts.ConfigPath, manifest, opts.Passphrase)
+	if err != nil {
+		return manifest, err
+	}
+	if sealedKey != nil {
+		if err := restoreSealedFile(staging, media.KeyFile, sealedKey, manifest, opts.Passphrase); err != nil {
+			return manifest, err
+		}
+	}
+
+	if entries, err := os.ReadDir(workspace); err == nil && len(entries) > 0 {
+		if !opts.Force {
+			return manifest, fmt.Errorf("workspace %s is not empty (use --force to replace it)", workspace)
+		}
+		if err := os.RemoveAll(workspace); err != nil {
+			return manifest, err
+		}
+	}
+	if err := os.Rename(staging, workspace); err != nil {
+		return manifest, fmt.Errorf("failed to move workspace into place: %w", err)
+	}
+	staging = ""
+
+	if err := os.MkdirAll(filepath.Dir(opts.ConfigPath), 0o755); err != nil {
+		return manifest, err
+	}
+	if _, err := os.Stat(opts.ConfigPath); err == nil {
+		if err := os.Rename(opts.ConfigPath, opts.ConfigPath+".bak"); err != nil {
+			return manifest, err
+		}
+	}
+	if err := os.WriteFile(opts.ConfigPath, configData, 0o600); err != nil {
+		return manifest, fmt.Errorf("failed to write config: %w", err)
+	}
+	return manifest, nil
+}
+
+func addFile(tw *tar.Writer, name, src string, info fs.FileInfo) error {
+	hdr, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	// Copy exactly the size in the header in case the file is growing.
+	_, err = io.CopyN(tw, f, hdr.Size)
+	return err
+}
+
+// addSealedFile adds the file at src with its contents sealed.
+func addSealedFile(tw *tar.Writer, name, src string, seal func(string) (string, error)) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	sealed, err := seal(string(data))
+	if err != nil {
+		return err
+	}
+	return addBytes(tw, name, []byte(sealed))
+}
+
+func addBytes(tw *tar.Writer, name string, data []byte) error {
+	hdr := &tar.Header{Name: name, Mode: 0o600, Size: int64(len(data)), ModTime: time.Now()}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+// extractFile writes an archive entry below dir, rejecting names that would
+// escape it.
+func extractFile(r io.Reader, dir, name string, hdr *tar.Header) error {
+	clean := path.Clean(name)
+	if clean == "." || path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return fmt.Errorf("unsafe path in archive: %s", hdr.Name)
+	}
+	dst := filepath.Join(dir, filepath.FromSlash(clean))
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, fs.FileMode(hdr.Mode)&0o777|0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(dst, hdr.ModTime, hdr.ModTime)
+}
@@ -0,0 +1,208 @@
+package backup
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testConfig = `{
+  "agents": {"defaults": {"model": "gpt-4o", "max_tokens": 4096}},
+  "model_list": [{"model_name": "gpt-4o", "api_key": "sk-live"}],
+  "gateway": {"jwt_secret": "s3cret", "paired_tokens": ["pc_a"]},
+  "channels": {"telegram": {"token": "123:abc", "enabled": true}}
+}`
+
+const testMediaKey = "6d656469612d6b6579\n"
+
+func writeFile(t *testing.T, path, content string, mtime time.Time) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	require.NoError(t, os.Chtimes(path, mtime, mtime))
+}
+
+func setupSource(t *testing.T) ExportOptions {
+	t.Helper()
+	home := t.TempDir()
+	workspace := filepath.Join(home, "workspace")
+	now := time.Now()
+	writeFile(t, filepath.Join(home, "config.json"), testConfig, now)
+	writeFile(t, filepath.Join(workspace, "sessions", "main.json"), `{"messages":[]}`, now)
+	writeFile(t, filepath.Join(workspace, "skills", "oluto", "SKILL.md"), "# oluto", now)
+	writeFile(t, filepath.Join(workspace, "media", "new_receipt.jpg"), "new", now)
+	writeFile(t, filepath.Join(workspace, "media", "old_receipt.jpg"), "old", now.AddDate(0, 0, -90))
+	writeFile(t, filepath.Join(workspace, "logs", "picoclaw.log"), "log", now)
+	writeFile(t, filepath.Join(workspace, "state", "media.key"), testMediaKey, now)
+	return ExportOptions{ConfigPath: filepath.Join(home, "config.json"), Workspace: workspace}
+}
+
+func importTo(t *testing.T, archive []byte, passphrase string, existingConfig string) (ImportOptions, string) {
+	t.Helper()
+	home := t.TempDir()
+	workspace := filepath.Join(home, "workspace")
+	opts := ImportOptions{
+		ConfigPath: filepath.Join(home, "config.json"),
+		Workspace:  func([]byte) (string, error) { return workspace, nil },
+		Passphrase: passphrase,
+	}
+	if existingConfig != "" {
+		writeFile(t, opts.ConfigPath, existingConfig, time.Now())
+	}
+	_, err := Import(bytes.NewReader(archive), opts)
+	require.NoError(t, err)
+	return opts, workspace
+}
+
+func readConfig(t *testing.T, path string) map[string]any {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var cfg map[string]any
+	require.NoError(t, json.Unmarshal(data, &cfg))
+	return cfg
+}
+
+func TestExportImportMasksSecrets(t *testing.T) {
+	src := setupSource(t)
+	src.MediaSince = time.Now().AddDate(0, 0, -30)
+
+	var archive bytes.Buffer
+	manifest, err := Export(&archive, src)
+	require.NoError(t, err)
+	assert.Equal(t, 3, manifest.Files)
+	assert.Equal(t, 1, manifest.MediaSkipped)
+	assert.True(t, manifest.KeyOmitted)
+	assert.NotContains(t, archive.String(), "sk-live")
+
+	// Masked secrets are filled from the target's config where it has them.
+	opts, workspace := importTo(t, archive.Bytes(), "", `{"model_list": [{"api_key": "sk-target"}]}`)
+	cfg := readConfig(t, opts.ConfigPath)
+	assert.Equal(t, "sk-target", cfg["model_list"].([]any)[0].(map[string]any)["api_key"])
+	assert.Equal(t, "", cfg["gateway"].(map[string]any)["jwt_secret"])
+	assert.Equal(t, 4096.0, cfg["agents"].(map[string]any)["defaults"].(map[string]any)["max_tokens"])
+	assert.FileExists(t, opts.ConfigPath+".bak")
+
+	assert.FileExists(t, filepath.Join(workspace, "sessions", "main.json"))
+	assert.FileExists(t, filepath.Join(workspace, "skills", "oluto", "SKILL.md"))
+	assert.FileExists(t, filepath.Join(workspace, "media", "new_receipt.jpg"))
+	assert.NoFileExists(t, filepath.Join(workspace, "media", "old_receipt.jpg"))
+	assert.NoDirExists(t, filepath.Join(workspace, "logs"))
+	assert.NoFileExists(t, filepath.Join(workspace, "state", "media.key"))
+}
+
+func TestExportImportWithPassphrase(t *testing.T) {
+	src := setupSource(t)
+	src.Passphrase = "correct horse"
+
+	var archive bytes.Buffer
+	_, err := Export(&archive, src)
+	require.NoError(t, err)
+	assert.NotContains(t, archive.String(), "s3cret")
+	assert.NotContains(t, archive.String(), testMediaKey)
+
+	_, err = Import(bytes.NewReader(archive.Bytes()), ImportOptions{
+		ConfigPath: filepath.Join(t.TempDir(), "config.json"),
+		Workspace:  func([]byte) (string, error) { return filepath.Join(t.TempDir(), "ws"), nil },
+		Passphrase: "wrong",
+	})
+	assert.ErrorContains(t, err, "wrong passphrase")
+
+	opts, workspace := importTo(t, archive.Bytes(), "correct horse", "")
+	cfg := readConfig(t, opts.ConfigPath)
+	assert.Equal(t, "s3cret", cfg["gateway"].(map[string]any)["jwt_secret"])
+	assert.Equal(t, []any{"pc_a"}, cfg["gateway"].(map[string]any)["paired_tokens"])
+	assert.Equal(t, "123:abc", cfg["channels"].(map[string]any)["telegram"].(map[string]any)["token"])
+
+	key, err := os.ReadFile(filepath.Join(workspace, "state", "media.key"))
+	require.NoError(t, err)
+	assert.Equal(t, testMediaKey, string(key))
+}
+
+func TestImportRefusesNonEmptyWorkspace(t *testing.T) {
+	var archive bytes.Buffer
+	_, err := Export(&archive, setupSource(t))
+	require.NoError(t, err)
+
+	home := t.TempDir()
+	workspace := filepath.Join(home, "workspace")
+	writeFile(t, filepath.Join(workspace, "keep.txt"), "x", time.Now())
+	_, err = Import(bytes.NewReader(archive.Bytes()), ImportOptions{
+		ConfigPath: filepath.Join(home, "config.json"),
+		Workspace:  func([]byte) (string, error) { return workspace, nil },
+	})
+	assert.ErrorContains(t, err, "not empty")
+	assert.FileExists(t, filepath.Join(workspace, "keep.txt"))
+	assert.NoFileExists(t, filepath.Join(home, "config.json"))
+}
+
+func TestEncryptedArchive(t *testing.T) {
+	src := setupSource(t)
+	src.Passphrase = "correct horse"
+	src.NoMedia = true
+	// A consistent copy stands in for a live database and its WAL
+	writeFile(t, filepath.Join(src.Workspace, "state", "transcripts.db"), "live", time.Now())
+	writeFile(t, filepath.Join(src.Workspace, "state", "transcripts.db-wal"), "wal", time.Now())
+	copyPath := filepath.Join(t.TempDir(), "copy.db")
+	writeFile(t, copyPath, "consistent", time.Now())
+	src.Substitutes = map[string]string{"state/transcripts.db": copyPath}
+
+	var archive bytes.Buffer
+	w, err := Encrypt(&archive, src.Passphrase)
+	require.NoError(t, err)
+	_, err = Export(w, src)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	assert.True(t, IsEncrypted(archive.Bytes()))
+	assert.NotContains(t, archive.String(), "oluto")
+
+	_, err = Import(bytes.NewReader(archive.Bytes()), ImportOptions{
+		ConfigPath: filepath.Join(t.TempDir(), "config.json"),
+		Workspace:  func([]byte) (string, error) { return filepath.Join(t.TempDir(), "ws"), nil },
+	})
+	assert.ErrorContains(t, err, "passphrase is required")
+
+	_, workspace := importTo(t, archive.Bytes(), "correct horse", "")
+	db, err := os.ReadFile(filepath.Join(workspace, "state", "transcripts.db"))
+	require.NoError(t, err)
+	assert.Equal(t, "consistent", string(db))
+	assert.NoFileExists(t, filepath.Join(workspace, "state", "transcripts.db-wal"))
+	assert.FileExists(t, filepath.Join(workspace, "skills", "oluto", "SKILL.md"))
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	for _, size := range []int{0, 1, encryptChunkSize, encryptChunkSize + 1, 3 * encryptChunkSize} {
+		plain := bytes.Repeat([]byte("x"), size)
+		var sealed bytes.Buffer
+		w, err := Encrypt(&sealed, "pw")
+		require.NoError(t, err)
+		_, err = w.Write(plain)
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+
+		r, err := Decrypt(bytes.NewReader(sealed.Bytes()), "pw")
+		require.NoError(t, err)
+		got, err := io.ReadAll(r)
+		require.NoError(t, err, "size %d", size)
+		assert.Equal(t, plain, got, "size %d", size)
+
+		if size >= encryptChunkSize {
+			// Dropping the last chunk must not pass for a shorter archive
+			r, err = Decrypt(bytes.NewReader(sealed.Bytes()[:sealed.Len()-100]), "pw")
+			require.NoError(t, err)
+			_, err = io.ReadAll(r)
+			assert.ErrorIs(t, err, ErrWrongPassphrase, "size %d", size)
+		}
+		r, err = Decrypt(bytes.NewReader(sealed.Bytes()), "wrong")
+		require.NoError(t, err)
+		_, err = io.ReadAll(r)
+		assert.ErrorIs(t, err, ErrWrongPassphrase)
+	}
+}
@@ -0,0 +1,172 @@
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Archives are encrypted whole as a stream of AES-GCM sealed chunks after a
+// header of encryptedMagic and the key derivation salt. Each chunk's nonce
+// is a random prefix, the chunk number and a flag set on the last chunk, so
+// chunks can't be reordered, dropped or truncated without failing to open.
+const (
+	encryptedMagic   = "PICOCLAW-ENC1\n"
+	encryptChunkSize = 64 << 10
+	noncePrefixSize  = 7
+)
+
+// ErrWrongPassphrase is returned when an encrypted archive doesn't open
+// with the passphrase given.
+var ErrWrongPassphrase = errors.New("wrong passphrase or damaged archive")
+
+// IsEncrypted reports whether data starts like an archive written through
+// Encrypt.
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(encryptedMagic))
+}
+
+// Encrypt returns a writer that encrypts what is written to it with
+// passphrase and writes it to w. Close must be called to write the last
+// chunk; it does not close w.
+func Encrypt(w io.Writer, passphrase string) (io.WriteCloser, error) {
+	if passphrase == "" {
+		return nil, errors.New("a passphrase is required to encrypt")
+	}
+	salt, err := newSalt()
+	if err != nil {
+		return nil, err
+	}
+	aead, err := deriveAEAD(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	prefix := make([]byte, noncePrefixSize)
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	header := append(append([]byte(encryptedMagic), salt...), prefix...)
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &encryptWriter{w: w, aead: aead, prefix: prefix}, nil
+}
+
+type encryptWriter struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	prefix []byte
+	buf    []byte
+	n      uint32
+	closed bool
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	if e.closed {
+		return 0, errors.New("write to closed encrypter")
+	}
+	e.buf = append(e.buf, p...)
+	// Keep at least one byte back, so the last chunk is never empty unless
+	// the whole stream is.
+	for len(e.buf) > encryptChunkSize {
+		if err := e.seal(e.buf[:encryptChunkSize], false); err != nil {
+			return 0, err
+		}
+		e.buf = e.buf[encryptChunkSize:]
+	}
+	return len(p), nil
+}
+
+func (e *encryptWriter) Close() error {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	return e.seal(e.buf, true)
+}
+
+func (e *encryptWriter) seal(chunk []byte, last bool) error {
+	if e.n == ^uint32(0) {
+		return errors.New("archive too large to encrypt")
+	}
+	_, err := e.w.Write(e.aead.Seal(nil, chunkNonce(e.prefix, e.n, last), chunk, nil))
+	e.n++
+	return err
+}
+
+// Decrypt returns a reader of the plaintext of r, an archive written
+// through Encrypt.
+func Decrypt(r io.Reader, passphrase string) (io.Reader, error) {
+	header := make([]byte, len(encryptedMagic)+16+noncePrefixSize)
+	if _, err := io.ReadFull(r, header); err != nil || !IsEncrypted(header) {
+		return nil, errors.New("not an encrypted picoclaw archive")
+	}
+	if passphrase == "" {
+		return nil, errors.New("archive is encrypted: a passphrase is required")
+	}
+	salt := header[len(encryptedMagic) : len(encryptedMagic)+16]
+	aead, err := deriveAEAD(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptReader{
+		r:      bufio.NewReader(r),
+		aead:   aead,
+		prefix: header[len(encryptedMagic)+16:],
+		chunk:  make([]byte, encryptChunkSize+aead.Overhead()),
+	}, nil
+}
+
+type decryptReader struct {
+	r      *bufio.Reader
+	aead   cipher.AEAD
+	prefix []byte
+	chunk  []byte
+	plain  []byte
+	n      uint32
+	done   bool
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.plain) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		n, err := io.ReadFull(d.r, d.chunk)
+		switch {
+		case err == io.ErrUnexpectedEOF || err == io.EOF:
+			d.done = true
+		case err != nil:
+			return 0, err
+		default:
+			// A full chunk is the last one if nothing follows it
+			if _, err := d.r.Peek(1); err == io.EOF {
+				d.done = true
+			}
+		}
+		plain, err := d.aead.Open(d.chunk[:0:0], chunkNonce(d.prefix, d.n, d.done), d.chunk[:n], nil)
+		if err != nil {
+			return 0, ErrWrongPassphrase
+		}
+		d.plain = plain
+		d.n++
+	}
+	n := copy(p, d.plain)
+	d.plain = d.plain[n:]
+	return n, nil
+}
+
+func chunkNonce(prefix []byte, n uint32, last bool) []byte {
+	nonce := make([]byte, noncePrefixSize+5)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[noncePrefixSize:], n)
+	if last {
+		nonce[len(nonce)-1] = 1
+	}
+	return nonce
+}
@@ -0,0 +1,250 @@
+package backup
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	secretsMasked    = "masked"
+	secretsEncrypted = "encrypted"
+
+	// maskedValue replaces secrets in archives exported without a passphrase.
+	maskedValue = "__MASKED__"
+	// encryptedPrefix marks secrets sealed with the export passphrase.
+	encryptedPrefix = "enc:v1:"
+
+	pbkdf2Iterations = 600_000
+)
+
+// secretNames are config keys that hold secrets regardless of suffix.
+var secretNames = map[string]bool{
+	"token":         true,
+	"password":      true,
+	"passphrase":    true,
+	"dsn":           true,
+	"paired_tokens": true,
+	"access_key_id": true,
+	"webhook_url":   true,
+}
+
+// isSecret reports whether a config key holds a credential.
+func isSecret(key string) bool {
+	key = strings.ToLower(key)
+	if secretNames[key] {
+		return true
+	}
+	for _, suffix := range []string{"_key", "_secret", "_token", "_password"} {
+		if strings.HasSuffix(key, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// protectSecrets replaces every non-empty secret string in cfg, sealing it
+// when seal is set and masking it otherwise.
+func protectSecrets(v any, seal func(string) (string, error)) error {
+	protect := func(s string) (string, error) {
+		if s == "" {
+			return s, nil
+		}
+		if seal == nil {
+			return maskedValue, nil
+		}
+		return seal(s)
+	}
+	return walkSecrets(v, protect)
+}
+
+// walkSecrets applies fn to every string held under a secret key.
+func walkSecrets(v any, fn func(string) (string, error)) error {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, child := range v {
+			if !isSecret(k) {
+				if err := walkSecrets(child, fn); err != nil {
+					return err
+				}
+				continue
+			}
+			switch child := child.(type) {
+			case string:
+				s, err := fn(child)
+				if err != nil {
+					return err
+				}
+				v[k] = s
+			case []any:
+				for i, item := range child {
+					if s, ok := item.(string); ok {
+						sealed, err := fn(s)
+						if err != nil {
+							return err
+						}
+						child[i] = sealed
+					}
+				}
+			}
+		}
+	case []any:
+		for _, child := range v {
+			if err := walkSecrets(child, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// restoreSecrets decrypts sealed secrets, or fills masked ones from the
+// config currently at configPath (empty if it has none).
+func restoreSecrets(configData []byte, configPath string, manifest Manifest, passphrase string) ([]byte, error) {
+	var cfg map[string]any
+	if err := json.Unmarshal(configData, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid config in archive: %w", err)
+	}
+
+	switch manifest.Secrets {
+	case secretsEncrypted:
+		if passphrase == "" {
+			return nil, errors.New("archive secrets are encrypted: a passphrase is required")
+		}
+		open := opener(passphrase, manifest.Salt)
+		if err := walkSecrets(cfg, func(s string) (string, error) {
+			if !strings.HasPrefix(s, encryptedPrefix) {
+				return s, nil
+			}
+			return open(s)
+		}); err != nil {
+			return nil, err
+		}
+	default:
+		var existing map[string]any
+		if data, err := os.ReadFile(configPath); err == nil {
+			json.Unmarshal(data, &existing)
+		}
+		fillMasked(cfg, existing)
+	}
+	return json.MarshalIndent(cfg, "", "  ")
+}
+
+// restoreSealedFile opens a file exported with addSealedFile and writes it
+// below dir.
+func restoreSealedFile(dir, name string, sealed []byte, manifest Manifest, passphrase string) error {
+	if manifest.Secrets != secretsEncrypted || passphrase == "" {
+		return fmt.Errorf("%s is encrypted: a passphrase is required", name)
+	}
+	data, err := opener(passphrase, manifest.Salt)(string(sealed))
+	if err != nil {
+		return err
+	}
+	dst := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(dst, []byte(data), 0o600)
+}
+
+// fillMasked replaces masked values in v with the value at the same place in
+// existing, or "" when there is none.
+func fillMasked(v, existing any) {
+	switch v := v.(type) {
+	case map[string]any:
+		old, _ := existing.(map[string]any)
+		for k, child := range v {
+			if child == maskedValue {
+				s, _ := old[k].(string)
+				v[k] = s
+				continue
+			}
+			fillMasked(child, old[k])
+		}
+	case []any:
+		old, _ := existing.([]any)
+		for i, child := range v {
+			var prev any
+			if i < len(old) {
+				prev = old[i]
+			}
+			if child == maskedValue {
+				s, _ := prev.(string)
+				v[i] = s
+				continue
+			}
+			fillMasked(child, prev)
+		}
+	}
+}
+
+func newSalt() ([]byte, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	return salt, nil
+}
+
+func deriveAEAD(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, pbkdf2Iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealer returns a function that encrypts secrets with the passphrase. The
+// key is derived once, on first use.
+func sealer(passphrase string, salt []byte) func(string) (string, error) {
+	var aead cipher.AEAD
+	return func(s string) (string, error) {
+		if aead == nil {
+			var err error
+			if aead, err = deriveAEAD(passphrase, salt); err != nil {
+				return "", err
+			}
+		}
+		nonce := make([]byte, aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return "", err
+		}
+		sealed := aead.Seal(nonce, nonce, []byte(s), nil)
+		return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+	}
+}
+
+// opener returns the inverse of sealer.
+func opener(passphrase string, salt []byte) func(string) (string, error) {
+	var aead cipher.AEAD
+	return func(s string) (string, error) {
+		if aead == nil {
+			var err error
+			if aead, err = deriveAEAD(passphrase, salt); err != nil {
+				return "", err
+			}
+		}
+		data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(s, encryptedPrefix))
+		if err != nil || len(data) < aead.NonceSize() {
+			return "", errors.New("invalid encrypted secret in archive")
+		}
+		plain, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+		if err != nil {
+			return "", errors.New("wrong passphrase")
+		}
+		return string(plain), nil
+	}
+}
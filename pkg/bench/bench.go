@@ -0,0 +1,323 @@
+// Package bench measures the gateway on the device it runs on. It serves the
+// webhook API in-process against a mock provider, replays synthetic requests
+// (text, multipart uploads and concurrent text) and reports latency,
+// allocations and peak RSS, so releases can be compared on the same board.
+package bench
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/agent"
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/health"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// Scenario names.
+const (
+	ScenarioText       = "text"
+	ScenarioMultipart  = "multipart"
+	ScenarioConcurrent = "concurrent"
+)
+
+// Options configures a run.
+type Options struct {
+	Requests    int           // requests per scenario
+	Concurrency int           // parallel clients in the concurrent scenario
+	Latency     time.Duration // simulated provider latency
+	UploadBytes int           // size of the file in multipart requests
+}
+
+// DefaultOptions returns the options of `picoclaw bench` without flags.
+func DefaultOptions() Options {
+	return Options{
+		Requests:    50,
+		Concurrency: 4,
+		UploadBytes: 1 << 20,
+	}
+}
+
+// Result summarizes one scenario. Allocations are counted for the whole
+// process, so they include the client side of each request.
+type Result struct {
+	Scenario       string        `json:"scenario"`
+	Requests       int           `json:"requests"`
+	Errors         int           `json:"errors"`
+	P50            time.Duration `json:"p50_ns"`
+	P95            time.Duration `json:"p95_ns"`
+	AllocsPerReq   uint64        `json:"allocs_per_request"`
+	BytesPerReq    uint64        `json:"bytes_per_request"`
+	RequestsPerSec float64       `json:"requests_per_second"`
+}
+
+// Report is the outcome of a run.
+type Report struct {
+	GoVersion string   `json:"go_version"`
+	GOARCH    string   `json:"goarch"`
+	Results   []Result `json:"results"`
+	PeakRSSKB int64    `json:"peak_rss_kb,omitempty"` // 0 where the OS does not report it
+}
+
+// Run serves the webhook API with a throwaway workspace in a temporary
+// directory and replays every scenario against it.
+func Run(ctx context.Context, opts Options) (*Report, error) {
+	if opts.Requests <= 0 || opts.Concurrency <= 0 {
+		return nil, errors.New("requests and concurrency must be positive")
+	}
+	workspace, err := os.MkdirTemp("", "picoclaw-bench-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create workspace: %w", err)
+	}
+	defer os.RemoveAll(workspace)
+
+	cfg := config.DefaultConfig()
+	cfg.Agents.Defaults.Workspace = workspace
+	msgBus := bus.NewMessageBus()
+	al := agent.NewAgentLoop(cfg, msgBus, &mockProvider{latency: opts.Latency})
+
+	// Nothing delivers outbound messages, such as summarization notices, so
+	// drain them to keep the agent from blocking on a full bus.
+	drainCtx, stopDrain := context.WithCancel(ctx)
+	defer stopDrain()
+	go func() {
+		for {
+			if _, ok := msgBus.SubscribeOutbound(drainCtx); !ok {
+				return
+			}
+		}
+	}()
+
+	server := health.NewServer("127.0.0.1", 0, health.WithAgentLoop(al))
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen: %w", err)
+	}
+	httpServer := &http.Server{Handler: server.Handler()}
+	go httpServer.Serve(ln)
+	defer httpServer.Close()
+
+	c := &client{
+		http: &http.Client{Timeout: 2 * time.Minute},
+		url:  "http://" + ln.Addr().String() + "/webhook",
+	}
+	upload := syntheticUpload(opts.UploadBytes)
+
+	report := &Report{GoVersion: runtime.Version(), GOARCH: runtime.GOARCH}
+	scenarios := []struct {
+		name    string
+		workers int
+		send    func(ctx context.Context, session string) error
+	}{
+		{ScenarioText, 1, c.sendText},
+		{ScenarioMultipart, 1, func(ctx context.Context, session string) error {
+			return c.sendMultipart(ctx, session, upload)
+		}},
+		{ScenarioConcurrent, opts.Concurrency, c.sendText},
+	}
+	for _, sc := range scenarios {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		report.Results = append(report.Results, measure(ctx, sc.name, opts.Requests, sc.workers, sc.send))
+	}
+	report.PeakRSSKB = peakRSSKB()
+	return report, nil
+}
+
+// measure sends n requests from workers parallel clients, each with its own
+// session, and summarizes them.
+func measure(
+	ctx context.Context,
+	name string,
+	n, workers int,
+	send func(ctx context.Context, session string) error,
+) Result {
+	var (
+		mu        sync.Mutex
+		latencies = make([]time.Duration, 0, n)
+		errs      int
+		next      = make(chan struct{})
+		wg        sync.WaitGroup
+	)
+
+	runtime.GC()
+	var before runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			session := fmt.Sprintf("pc_bench_%s_%d", name, w)
+			for range next {
+				t := time.Now()
+				err := send(ctx, session)
+				elapsed := time.Since(t)
+
+				mu.Lock()
+				latencies = append(latencies, elapsed)
+				if err != nil {
+					errs++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for range n {
+		next <- struct{}{}
+	}
+	close(next)
+	wg.Wait()
+
+	elapsed := time.Since(start)
+	var after runtime.MemStats
+	runtime.ReadMemStats(&after)
+
+	slices.Sort(latencies)
+	return Result{
+		Scenario:       name,
+		Requests:       n,
+		Errors:         errs,
+		P50:            percentile(latencies, 0.50),
+		P95:            percentile(latencies, 0.95),
+		AllocsPerReq:   (after.Mallocs - before.Mallocs) / uint64(n),
+		BytesPerReq:    (after.TotalAlloc - before.TotalAlloc) / uint64(n),
+		RequestsPerSec: float64(n) / elapsed.Seconds(),
+	}
+}
+
+// percentile returns the q-th percentile of sorted durations.
+func percentile(sorted []time.Duration, q float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(q*float64(len(sorted)))) - 1
+	return sorted[max(i, 0)]
+}
+
+// client posts synthetic webhook requests. The session is sent as the bearer
+// token, which the gateway accepts when pairing is off and turns into a
+// session of its own.
+type client struct {
+	http *http.Client
+	url  string
+}
+
+func (c *client) sendText(ctx context.Context, session string) error {
+	body := strings.NewReader(`{"message":"How much did I spend on fuel last month?"}`)
+	return c.post(ctx, session, "application/json", body)
+}
+
+func (c *client) sendMultipart(ctx context.Context, session string, upload []byte) error {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("message", "Process the attached receipt")
+	part, err := mw.CreateFormFile("file", "receipt.jpg")
+	if err != nil {
+		return err
+	}
+	part.Write(upload)
+	if err := mw.Close(); err != nil {
+		return err
+	}
+	return c.post(ctx, session, mw.FormDataContentType(), &body)
+}
+
+func (c *client) post(ctx context.Context, session, contentType string, body io.Reader) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", "Bearer "+session)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// syntheticUpload returns n bytes starting with a JPEG header, so the upload
+// is saved like a receipt photo.
+func syntheticUpload(n int) []byte {
+	data := make([]byte, max(n, 4))
+	copy(data, []byte{0xff, 0xd8, 0xff, 0xe0})
+	for i := 4; i < len(data); i++ {
+		data[i] = byte(i * 31)
+	}
+	return data
+}
+
+// peakRSSKB returns the process's peak resident set size on Linux, and 0
+// elsewhere.
+func peakRSSKB() int64 {
+	f, err := os.Open("/proc/self/status")
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		value, ok := strings.CutPrefix(scanner.Text(), "VmHWM:")
+		if !ok {
+			continue
+		}
+		kb, _ := strconv.ParseInt(strings.TrimSuffix(strings.TrimSpace(value), " kB"), 10, 64)
+		return kb
+	}
+	return 0
+}
+
+// mockProvider answers every prompt after latency, without calling tools.
+type mockProvider struct {
+	latency time.Duration
+}
+
+func (p *mockProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	if p.latency > 0 {
+		select {
+		case <-time.After(p.latency):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return &providers.LLMResponse{
+		Content:      "You spent 142.50 on fuel last month across 3 receipts.",
+		FinishReason: "stop",
+		Usage:        &providers.UsageInfo{PromptTokens: 900, CompletionTokens: 20, TotalTokens: 920},
+	}, nil
+}
+
+func (p *mockProvider) GetDefaultModel() string {
+	return "bench-model"
+}
@@ -0,0 +1,43 @@
+package bench
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunReportsEveryScenario(t *testing.T) {
+	opts := Options{Requests: 4, Concurrency: 2, UploadBytes: 64 << 10}
+	report, err := Run(context.Background(), opts)
+	require.NoError(t, err)
+
+	require.Len(t, report.Results, 3)
+	for i, name := range []string{ScenarioText, ScenarioMultipart, ScenarioConcurrent} {
+		r := report.Results[i]
+		assert.Equal(t, name, r.Scenario)
+		assert.Equal(t, 4, r.Requests)
+		assert.Zero(t, r.Errors, name)
+		assert.Positive(t, r.P50, name)
+		assert.GreaterOrEqual(t, r.P95, r.P50, name)
+		assert.Positive(t, r.AllocsPerReq, name)
+	}
+}
+
+func TestRunRejectsEmptyCorpus(t *testing.T) {
+	_, err := Run(context.Background(), Options{Requests: 0, Concurrency: 1})
+	assert.Error(t, err)
+}
+
+func TestPercentile(t *testing.T) {
+	var sorted []time.Duration
+	for i := 1; i <= 100; i++ {
+		sorted = append(sorted, time.Duration(i)*time.Millisecond)
+	}
+	assert.Equal(t, 50*time.Millisecond, percentile(sorted, 0.50))
+	assert.Equal(t, 95*time.Millisecond, percentile(sorted, 0.95))
+	assert.Equal(t, time.Millisecond, percentile(sorted[:1], 0.95))
+	assert.Zero(t, percentile(nil, 0.5))
+}
@@ -0,0 +1,49 @@
+// Package blob stores uploads and generated files in an S3-compatible object
+// store (AWS S3, MinIO) instead of device flash.
+package blob
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Store is a flat key/value store for files. Keys use "/" separators.
+type Store interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	// PresignGet returns a URL that downloads key without credentials until
+	// expiry.
+	PresignGet(key string, expiry time.Duration) (string, error)
+}
+
+// PutFile uploads the file at path to key.
+func PutFile(ctx context.Context, store Store, key, path, contentType string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return store.Put(ctx, key, f, info.Size(), contentType)
+}
+
+// ValidKey reports whether key is a relative, clean "/" path usable by every
+// store.
+func ValidKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return false
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+	}
+	return true
+}
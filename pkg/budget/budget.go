@@ -0,0 +1,341 @@
+// Package budget keeps what LLM calls cost per day and per month, in total
+// and per business, and tells the agent loop how close each is to its
+// budget: past the warning threshold the owner is told, past the budget the
+// loop switches to a cheaper model, and past the ceiling it refuses requests
+// until the budget resets.
+//
+// Spend is kept in <workspace>/state/spend.json, so it survives restarts.
+package budget
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
+
+const (
+	dateFormat  = "2006-01-02"
+	monthFormat = "2006-01"
+	// keepDays covers the current and the previous month.
+	keepDays = 62
+	// saveInterval limits how often spend is written, to spare SD cards.
+	saveInterval = time.Minute
+
+	// DefaultWarnPercent is used when Options leaves WarnPercent zero.
+	DefaultWarnPercent = 80
+)
+
+// ErrExceeded is returned for requests refused because spend is past the
+// ceiling of a budget.
+var ErrExceeded = errors.New("spend budget exceeded")
+
+// Limits are the USD budgets of one scope. A zero limit is no budget.
+type Limits struct {
+	Daily   float64
+	Monthly float64
+}
+
+// Options configure a Budget. See config.BudgetConfig.
+type Options struct {
+	Global     Limits
+	Businesses map[string]Limits // by business ID
+	// WarnPercent and StopPercent are percentages of a budget: the owner is
+	// warned past the first, and requests are refused past the second. A
+	// zero StopPercent never refuses requests.
+	WarnPercent   float64
+	StopPercent   float64
+	Prices        map[string]usage.TokenPrice // by model; calls to other models cost nothing
+	FallbackModel string                      // named in notifications
+	Notify        func(message string)
+}
+
+// Level is how far spend is into a budget.
+type Level int
+
+const (
+	LevelOK       Level = iota
+	LevelWarn           // past WarnPercent
+	LevelFallback       // past the budget
+	LevelStop           // past StopPercent
+)
+
+// Status is the state of the budget closest to its limit.
+type Status struct {
+	Level    Level
+	Business string // "" for the global budget
+	Period   string // "daily" or "monthly"
+	Spent    float64
+	Limit    float64
+}
+
+func (s Status) String() string {
+	scope := "the " + s.Period + " budget"
+	if s.Business != "" {
+		scope += " of business " + s.Business
+	}
+	return fmt.Sprintf("$%.2f of %s of $%.2f spent", s.Spent, scope, s.Limit)
+}
+
+// ledger is the file format of spend.json.
+type ledger struct {
+	Days     map[string]map[string]float64 `json:"days"`     // by date, then business ("" for all)
+	Notified map[string]Level              `json:"notified"` // highest level told per budget period
+}
+
+// Budget tracks spend against the configured limits. It is safe for
+// concurrent use.
+type Budget struct {
+	path string
+	opts Options
+	now  func() time.Time
+
+	mu        sync.Mutex
+	ledger    ledger
+	dirty     bool
+	lastSaved time.Time
+}
+
+// New loads the spend recorded in workspace.
+func New(workspace string, opts Options) (*Budget, error) {
+	if opts.WarnPercent <= 0 {
+		opts.WarnPercent = DefaultWarnPercent
+	}
+	if opts.StopPercent > 0 && opts.StopPercent < 100 {
+		return nil, fmt.Errorf("stop percent %v is below 100", opts.StopPercent)
+	}
+	b := &Budget{
+		path: filepath.Join(workspace, "state", "spend.json"),
+		opts: opts,
+		now:  time.Now,
+		ledger: ledger{
+			Days:     map[string]map[string]float64{},
+			Notified: map[string]Level{},
+		},
+	}
+	data, err := os.ReadFile(b.path)
+	if os.IsNotExist(err) {
+		return b, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spend: %w", err)
+	}
+	if err := json.Unmarshal(data, &b.ledger); err != nil {
+		return nil, fmt.Errorf("failed to parse spend: %w", err)
+	}
+	if b.ledger.Days == nil {
+		b.ledger.Days = map[string]map[string]float64{}
+	}
+	if b.ledger.Notified == nil {
+		b.ledger.Notified = map[string]Level{}
+	}
+	return b, nil
+}
+
+// Check returns the status of the global budgets and those of businessID
+// that is furthest along.
+func (b *Budget) Check(businessID string) Status {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var worst Status
+	for _, s := range b.statuses(b.now(), businessID) {
+		if s.Level > worst.Level || (s.Level == worst.Level && s.ratio() > worst.ratio()) {
+			worst = s
+		}
+	}
+	return worst
+}
+
+func (s Status) ratio() float64 {
+	if s.Limit == 0 {
+		return 0
+	}
+	return s.Spent / s.Limit
+}
+
+// Record adds the cost of a call to model made for businessID, and tells
+// the owner about each budget that crossed a threshold.
+func (b *Budget) Record(businessID, model string, prompt, completion int) {
+	price, ok := b.opts.Prices[model]
+	if !ok {
+		return
+	}
+	cost := price.Cost(int64(prompt), int64(completion))
+	if cost <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	now := b.now()
+	date := now.Format(dateFormat)
+	day, ok := b.ledger.Days[date]
+	if !ok {
+		day = map[string]float64{}
+		b.ledger.Days[date] = day
+		b.prune(now)
+	}
+	day[""] += cost
+	if businessID != "" {
+		day[businessID] += cost
+	}
+	var messages []string
+	for _, s := range b.statuses(now, businessID) {
+		key := s.Period + ":" + periodKey(now, s.Period) + ":" + s.Business
+		if s.Level > b.ledger.Notified[key] {
+			b.ledger.Notified[key] = s.Level
+			messages = append(messages, b.message(s))
+		}
+	}
+	b.dirty = true
+	if len(messages) > 0 || now.Sub(b.lastSaved) >= saveInterval {
+		if err := b.saveLocked(); err != nil {
+			logger.WarnCF("budget", "Failed to save spend", map[string]any{"error": err.Error()})
+		}
+	}
+	b.mu.Unlock()
+
+	for _, m := range messages {
+		logger.WarnCF("budget", m, nil)
+		if b.opts.Notify != nil {
+			b.opts.Notify(m)
+		}
+	}
+}
+
+// Spent returns the spend of businessID ("" for all) today and this month.
+func (b *Budget) Spent(businessID string) (day, month float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	return b.spent(now, "daily", businessID), b.spent(now, "monthly", businessID)
+}
+
+// statuses returns the status of every budget that applies to businessID.
+// b.mu must be held.
+func (b *Budget) statuses(now time.Time, businessID string) []Status {
+	var out []Status
+	add := func(business string, limits Limits) {
+		for _, p := range []struct {
+			period string
+			limit  float64
+		}{{"daily", limits.Daily}, {"monthly", limits.Monthly}} {
+			if p.limit <= 0 {
+				continue
+			}
+			s := Status{Business: business, Period: p.period, Spent: b.spent(now, p.period, business), Limit: p.limit}
+			s.Level = b.level(s.ratio())
+			out = append(out, s)
+		}
+	}
+	add("", b.opts.Global)
+	if businessID != "" {
+		if limits, ok := b.opts.Businesses[businessID]; ok {
+			add(businessID, limits)
+		}
+	}
+	return out
+}
+
+func (b *Budget) level(ratio float64) Level {
+	switch {
+	case b.opts.StopPercent > 0 && ratio*100 >= b.opts.StopPercent:
+		return LevelStop
+	case ratio >= 1:
+		return LevelFallback
+	case ratio*100 >= b.opts.WarnPercent:
+		return LevelWarn
+	}
+	return LevelOK
+}
+
+// spent sums the spend of business over the day or month of now. b.mu
+// must be held.
+func (b *Budget) spent(now time.Time, period, business string) float64 {
+	if period == "daily" {
+		return b.ledger.Days[now.Format(dateFormat)][business]
+	}
+	month := now.Format(monthFormat)
+	var total float64
+	for date, day := range b.ledger.Days {
+		if strings.HasPrefix(date, month) {
+			total += day[business]
+		}
+	}
+	return total
+}
+
+func periodKey(now time.Time, period string) string {
+	if period == "daily" {
+		return now.Format(dateFormat)
+	}
+	return now.Format(monthFormat)
+}
+
+func (b *Budget) message(s Status) string {
+	switch s.Level {
+	case LevelStop:
+		return fmt.Sprintf("⛔ Spend budget: %s (%.0f%%). Requests are refused until it resets.", s, s.ratio()*100)
+	case LevelFallback:
+		if b.opts.FallbackModel != "" {
+			return fmt.Sprintf("🔻 Spend budget: %s. Switching to %s until it resets.", s, b.opts.FallbackModel)
+		}
+		return fmt.Sprintf("🔻 Spend budget: %s. The budget is used up.", s)
+	}
+	return fmt.Sprintf("⚠️ Spend budget: %s (%.0f%%).", s, s.ratio()*100)
+}
+
+// prune drops days and notifications older than keepDays. b.mu must be
+// held.
+func (b *Budget) prune(now time.Time) {
+	cutoff := now.AddDate(0, 0, -keepDays).Format(dateFormat)
+	for date := range b.ledger.Days {
+		if date < cutoff {
+			delete(b.ledger.Days, date)
+		}
+	}
+	for key := range b.ledger.Notified {
+		// Keys are "period:date-or-month:business"
+		_, rest, _ := strings.Cut(key, ":")
+		when, _, _ := strings.Cut(rest, ":")
+		if when < cutoff[:min(len(when), len(cutoff))] {
+			delete(b.ledger.Notified, key)
+		}
+	}
+}
+
+// Save writes pending spend to disk.
+func (b *Budget) Save() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.saveLocked()
+}
+
+func (b *Budget) saveLocked() error {
+	if !b.dirty {
+		return nil
+	}
+	data, err := json.MarshalIndent(b.ledger, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(b.path), 0o755); err != nil {
+		return err
+	}
+	tmp := b.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, b.path); err != nil {
+		return err
+	}
+	b.dirty = false
+	b.lastSaved = b.now()
+	return nil
+}
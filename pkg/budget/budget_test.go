@@ -0,0 +1,104 @@
+package budget
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
+
+// A million prompt tokens of "paid" cost $1.
+var testPrices = map[string]usage.TokenPrice{"paid": {Prompt: 1}}
+
+func newTestBudget(t *testing.T, workspace string, opts Options, now *time.Time) (*Budget, *[]string) {
+	t.Helper()
+	var notices []string
+	opts.Prices = testPrices
+	opts.Notify = func(m string) { notices = append(notices, m) }
+	b, err := New(workspace, opts)
+	require.NoError(t, err)
+	b.now = func() time.Time { return *now }
+	return b, &notices
+}
+
+func TestBudgetLevels(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.Local)
+	b, notices := newTestBudget(t, t.TempDir(), Options{
+		Global:        Limits{Daily: 10},
+		StopPercent:   150,
+		FallbackModel: "cheap",
+	}, &now)
+
+	assert.Equal(t, LevelOK, b.Check("").Level)
+	b.Record("", "paid", 7_000_000, 0)
+	assert.Equal(t, LevelOK, b.Check("").Level)
+	assert.Empty(t, *notices)
+
+	b.Record("", "paid", 1_000_000, 0)
+	status := b.Check("")
+	assert.Equal(t, LevelWarn, status.Level)
+	assert.Equal(t, "$8.00 of the daily budget of $10.00 spent", status.String())
+	b.Record("", "paid", 500_000, 0)
+	require.Len(t, *notices, 1, "warned once per level")
+
+	b.Record("", "paid", 2_000_000, 0)
+	assert.Equal(t, LevelFallback, b.Check("").Level)
+	require.Len(t, *notices, 2)
+	assert.Contains(t, (*notices)[1], "Switching to cheap")
+
+	b.Record("", "paid", 5_000_000, 0)
+	assert.Equal(t, LevelStop, b.Check("").Level)
+	require.Len(t, *notices, 3)
+	assert.Contains(t, (*notices)[2], "refused")
+
+	b.Record("", "unpriced", 100_000_000, 0)
+	day, _ := b.Spent("")
+	assert.InDelta(t, 15.5, day, 1e-9)
+
+	// A new day starts over
+	now = now.AddDate(0, 0, 1)
+	assert.Equal(t, LevelOK, b.Check("").Level)
+}
+
+func TestBudgetPerBusinessAndMonth(t *testing.T) {
+	workspace := t.TempDir()
+	now := time.Date(2026, 10, 1, 9, 0, 0, 0, time.Local)
+	opts := Options{
+		Global:     Limits{Monthly: 100},
+		Businesses: map[string]Limits{"biz-1": {Daily: 2}},
+	}
+	b, notices := newTestBudget(t, workspace, opts, &now)
+
+	b.Record("biz-1", "paid", 2_000_000, 0)
+	assert.Equal(t, LevelFallback, b.Check("biz-1").Level)
+	assert.Equal(t, "biz-1", b.Check("biz-1").Business)
+	assert.Equal(t, LevelOK, b.Check("biz-2").Level)
+	assert.Equal(t, LevelOK, b.Check("").Level)
+	require.Len(t, *notices, 1)
+	assert.True(t, strings.Contains((*notices)[0], "business biz-1"), (*notices)[0])
+
+	for range 40 {
+		now = now.Add(12 * time.Hour)
+		b.Record("biz-2", "paid", 2_000_000, 0)
+	}
+	// 82 spent this month: the global monthly budget warns
+	assert.Equal(t, LevelWarn, b.Check("biz-2").Level)
+	_, month := b.Spent("")
+	assert.InDelta(t, 82, month, 1e-9)
+	require.NoError(t, b.Save())
+
+	// Spend and notices survive a restart
+	reloaded, notices := newTestBudget(t, workspace, opts, &now)
+	assert.Equal(t, LevelWarn, reloaded.Check("").Level)
+	reloaded.Record("biz-2", "paid", 1_000_000, 0)
+	assert.Empty(t, *notices)
+}
+
+func TestNewRejectsLowStop(t *testing.T) {
+	_, err := New(t.TempDir(), Options{StopPercent: 90})
+	assert.Error(t, err)
+}
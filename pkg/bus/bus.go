@@ -2,15 +2,28 @@ package bus
 
 import (
 	"context"
+	"errors"
+	"slices"
 	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/logger"
 )
 
+// transportRetry is how long the bus waits after a transport error before
+// receiving again.
+const transportRetry = time.Second
+
 type MessageBus struct {
 	inbound  chan InboundMessage
 	outbound chan OutboundMessage
 	handlers map[string]MessageHandler
 	closed   bool
 	mu       sync.RWMutex
+
+	transport Transport
+	local     []string // channels this gateway runs, when routed through transport
 }
 
 func NewMessageBus() *MessageBus {
@@ -21,12 +34,36 @@ func NewMessageBus() *MessageBus {
 	}
 }
 
+// UseTransport routes messages through t, shared with other gateways, until
+// ctx is done. Inbound channel messages go to whichever gateway takes them
+// first, and outbound messages for a channel this gateway doesn't run in
+// channels go to one that does. Messages of internal channels stay local.
+func (mb *MessageBus) UseTransport(ctx context.Context, t Transport, channels []string) {
+	mb.mu.Lock()
+	mb.transport = t
+	mb.local = slices.Clone(channels)
+	mb.mu.Unlock()
+
+	go mb.receiveInbound(ctx, t)
+	if len(channels) > 0 {
+		go mb.receiveOutbound(ctx, t, channels)
+	}
+}
+
 func (mb *MessageBus) PublishInbound(msg InboundMessage) {
 	mb.mu.RLock()
 	defer mb.mu.RUnlock()
 	if mb.closed {
 		return
 	}
+	if mb.transport != nil && !constants.IsInternalChannel(msg.Channel) {
+		err := mb.transport.SendInbound(context.Background(), msg)
+		if err == nil {
+			return
+		}
+		logger.WarnCF("bus", "Routing inbound message failed; handling it here",
+			map[string]any{"channel": msg.Channel, "error": err.Error()})
+	}
 	mb.inbound <- msg
 }
 
@@ -45,6 +82,14 @@ func (mb *MessageBus) PublishOutbound(msg OutboundMessage) {
 	if mb.closed {
 		return
 	}
+	if mb.transport != nil && !constants.IsInternalChannel(msg.Channel) && !slices.Contains(mb.local, msg.Channel) {
+		err := mb.transport.SendOutbound(context.Background(), msg)
+		if err == nil {
+			return
+		}
+		logger.WarnCF("bus", "Routing outbound message failed",
+			map[string]any{"channel": msg.Channel, "error": err.Error()})
+	}
 	mb.outbound <- msg
 }
 
@@ -57,6 +102,81 @@ func (mb *MessageBus) SubscribeOutbound(ctx context.Context) (OutboundMessage, b
 	}
 }
 
+// receiveInbound moves inbound messages from t to this gateway's agent, one
+// at a time, so a busy gateway leaves them to the others.
+func (mb *MessageBus) receiveInbound(ctx context.Context, t Transport) {
+	for ctx.Err() == nil {
+		msg, err := t.ReceiveInbound(ctx)
+		if err != nil {
+			if !errors.Is(err, ErrNoMessage) && ctx.Err() == nil {
+				logger.WarnCF("bus", "Receiving inbound messages failed", map[string]any{"error": err.Error()})
+				sleepCtx(ctx, transportRetry)
+			}
+			continue
+		}
+		if !mb.deliver(ctx, func() bool {
+			select {
+			case mb.inbound <- msg:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}) {
+			// Leave the message to another gateway.
+			if err := t.SendInbound(context.Background(), msg); err != nil {
+				logger.WarnCF("bus", "Requeueing inbound message failed", map[string]any{"error": err.Error()})
+			}
+			return
+		}
+	}
+}
+
+// receiveOutbound moves outbound messages for channels from t to this
+// gateway's channels.
+func (mb *MessageBus) receiveOutbound(ctx context.Context, t Transport, channels []string) {
+	for ctx.Err() == nil {
+		msg, err := t.ReceiveOutbound(ctx, channels)
+		if err != nil {
+			if !errors.Is(err, ErrNoMessage) && ctx.Err() == nil {
+				logger.WarnCF("bus", "Receiving outbound messages failed", map[string]any{"error": err.Error()})
+				sleepCtx(ctx, transportRetry)
+			}
+			continue
+		}
+		if !mb.deliver(ctx, func() bool {
+			select {
+			case mb.outbound <- msg:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}) {
+			if err := t.SendOutbound(context.Background(), msg); err != nil {
+				logger.WarnCF("bus", "Requeueing outbound message failed", map[string]any{"error": err.Error()})
+			}
+			return
+		}
+	}
+}
+
+// deliver runs send, which waits for room in a local queue, unless the bus
+// is closed. It reports whether the message was queued.
+func (mb *MessageBus) deliver(ctx context.Context, send func() bool) bool {
+	mb.mu.RLock()
+	defer mb.mu.RUnlock()
+	if mb.closed || ctx.Err() != nil {
+		return false
+	}
+	return send()
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
+
 func (mb *MessageBus) RegisterHandler(channel string, handler MessageHandler) {
 	mb.mu.Lock()
 	defer mb.mu.Unlock()
@@ -0,0 +1,99 @@
+package bus
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memTransport is a Transport shared by the buses of one test, like Redis
+// shared by gateways.
+type memTransport struct {
+	mu       sync.Mutex
+	inbound  chan InboundMessage
+	outbound map[string]chan OutboundMessage
+}
+
+func newMemTransport() *memTransport {
+	return &memTransport{inbound: make(chan InboundMessage, 10), outbound: make(map[string]chan OutboundMessage)}
+}
+
+func (t *memTransport) queue(channel string) chan OutboundMessage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.outbound[channel] == nil {
+		t.outbound[channel] = make(chan OutboundMessage, 10)
+	}
+	return t.outbound[channel]
+}
+
+func (t *memTransport) SendInbound(_ context.Context, msg InboundMessage) error {
+	t.inbound <- msg
+	return nil
+}
+
+func (t *memTransport) SendOutbound(_ context.Context, msg OutboundMessage) error {
+	t.queue(msg.Channel) <- msg
+	return nil
+}
+
+func (t *memTransport) ReceiveInbound(ctx context.Context) (InboundMessage, error) {
+	select {
+	case msg := <-t.inbound:
+		return msg, nil
+	case <-time.After(10 * time.Millisecond):
+		return InboundMessage{}, ErrNoMessage
+	}
+}
+
+func (t *memTransport) ReceiveOutbound(ctx context.Context, channels []string) (OutboundMessage, error) {
+	for _, ch := range channels {
+		select {
+		case msg := <-t.queue(ch):
+			return msg, nil
+		default:
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+	return OutboundMessage{}, ErrNoMessage
+}
+
+func (t *memTransport) Close() error { return nil }
+
+func TestTransportRoutesBetweenBuses(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	transport := newMemTransport()
+
+	// Gateway a runs Telegram, gateway b runs nothing but can take work.
+	a, b := NewMessageBus(), NewMessageBus()
+	a.UseTransport(ctx, transport, []string{"telegram"})
+	b.UseTransport(ctx, transport, nil)
+
+	a.PublishInbound(InboundMessage{Channel: "telegram", ChatID: "42", Content: "hi"})
+	var got InboundMessage
+	select {
+	case got = <-a.inbound:
+	case got = <-b.inbound:
+	case <-ctx.Done():
+		t.Fatal("the inbound message reached neither gateway")
+	}
+	assert.Equal(t, "hi", got.Content)
+
+	// b's reply to Telegram is delivered by a, which runs it.
+	b.PublishOutbound(OutboundMessage{Channel: "telegram", ChatID: "42", Content: "hello"})
+	out, ok := a.SubscribeOutbound(ctx)
+	require.True(t, ok)
+	assert.Equal(t, "hello", out.Content)
+
+	// a delivers replies for its own channels itself, and internal messages
+	// never leave the gateway.
+	a.PublishOutbound(OutboundMessage{Channel: "telegram", ChatID: "42", Content: "local"})
+	assert.Len(t, a.outbound, 1)
+	b.PublishInbound(InboundMessage{Channel: "system", Content: "subagent done"})
+	assert.Len(t, b.inbound, 1)
+}
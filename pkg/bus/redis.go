@@ -0,0 +1,295 @@
+package bus
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// redisPoll is how long one receive waits on Redis for a message.
+	redisPoll = 2 * time.Second
+	// redisTimeout bounds every other Redis command.
+	redisTimeout = 10 * time.Second
+	// redisMaxQueued is how many messages a queue keeps when no gateway
+	// takes them; older ones are dropped.
+	redisMaxQueued = 10000
+)
+
+// RedisTransport routes bus messages through Redis lists: <prefix>:inbound
+// for inbound messages and <prefix>:outbound:<channel> for each channel's
+// deliveries. Lists rather than pub/sub, so that each message is handled by
+// one gateway and waits while none is listening.
+//
+// It speaks the Redis protocol itself and needs no client library. The
+// receive methods each use their own connection and must not be called
+// concurrently with themselves.
+type RedisTransport struct {
+	url    *url.URL
+	prefix string
+
+	mu   sync.Mutex // guards send, and the connections for Close
+	send *redisConn
+	in   *redisConn
+	out  *redisConn
+}
+
+// NewRedisTransport connects to the Redis server at rawURL
+// (redis://[user:password@]host[:port][/db], or rediss:// for TLS) and
+// checks that it answers. prefix namespaces the keys; it defaults to
+// "picoclaw".
+func NewRedisTransport(ctx context.Context, rawURL, prefix string) (*RedisTransport, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "redis" && u.Scheme != "rediss" || u.Host == "" {
+		return nil, fmt.Errorf("invalid Redis URL %q: expected redis://host:port", rawURL)
+	}
+	if prefix == "" {
+		prefix = "picoclaw"
+	}
+	t := &RedisTransport{url: u, prefix: prefix}
+	t.send, err = dialRedis(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := t.send.do(redisTimeout, "PING"); err != nil {
+		t.send.Close()
+		return nil, fmt.Errorf("redis ping: %w", err)
+	}
+	return t, nil
+}
+
+func (t *RedisTransport) inboundKey() string {
+	return t.prefix + ":inbound"
+}
+
+func (t *RedisTransport) outboundKey(channel string) string {
+	return t.prefix + ":outbound:" + channel
+}
+
+func (t *RedisTransport) SendInbound(ctx context.Context, msg InboundMessage) error {
+	return t.push(ctx, t.inboundKey(), msg)
+}
+
+func (t *RedisTransport) SendOutbound(ctx context.Context, msg OutboundMessage) error {
+	return t.push(ctx, t.outboundKey(msg.Channel), msg)
+}
+
+func (t *RedisTransport) ReceiveInbound(ctx context.Context) (InboundMessage, error) {
+	var msg InboundMessage
+	_, err := t.pop(ctx, &t.in, []string{t.inboundKey()}, &msg)
+	return msg, err
+}
+
+func (t *RedisTransport) ReceiveOutbound(ctx context.Context, channels []string) (OutboundMessage, error) {
+	keys := make([]string, len(channels))
+	for i, ch := range channels {
+		keys[i] = t.outboundKey(ch)
+	}
+	var msg OutboundMessage
+	_, err := t.pop(ctx, &t.out, keys, &msg)
+	return msg, err
+}
+
+// push adds v to the head of the list at key, dropping the oldest entries
+// beyond redisMaxQueued.
+func (t *RedisTransport) push(ctx context.Context, key string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for attempt := 0; ; attempt++ {
+		if t.send == nil {
+			if t.send, err = dialRedis(ctx, t.url); err != nil {
+				return err
+			}
+		}
+		if _, err = t.send.do(redisTimeout, "LPUSH", key, string(data)); err == nil {
+			_, err = t.send.do(redisTimeout, "LTRIM", key, "0", strconv.Itoa(redisMaxQueued-1))
+		}
+		if err == nil || attempt > 0 || isRedisError(err) {
+			break
+		}
+		// The connection may have gone stale while idle; redial once.
+		t.send.Close()
+		t.send = nil
+	}
+	return err
+}
+
+// pop takes the oldest entry of the first non-empty list in keys into v,
+// waiting up to redisPoll, over the connection in *conn.
+func (t *RedisTransport) pop(ctx context.Context, conn **redisConn, keys []string, v any) (string, error) {
+	c := *conn
+	if c == nil {
+		var err error
+		if c, err = dialRedis(ctx, t.url); err != nil {
+			return "", err
+		}
+		t.setConn(conn, c)
+	}
+	args := append([]string{"BRPOP"}, keys...)
+	args = append(args, strconv.Itoa(int(redisPoll/time.Second)))
+	reply, err := c.do(redisPoll+redisTimeout, args...)
+	if err != nil {
+		if !isRedisError(err) {
+			c.Close()
+			t.setConn(conn, nil)
+		}
+		return "", err
+	}
+	pair, ok := reply.([]any)
+	if !ok || len(pair) != 2 {
+		return "", ErrNoMessage
+	}
+	key, _ := pair[0].(string)
+	data, _ := pair[1].(string)
+	if err := json.Unmarshal([]byte(data), v); err != nil {
+		return "", fmt.Errorf("bad message in %s: %w", key, err)
+	}
+	return key, nil
+}
+
+// setConn replaces a receive connection, which Close may be reading.
+func (t *RedisTransport) setConn(conn **redisConn, c *redisConn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	*conn = c
+}
+
+// Close closes the transport's connections.
+func (t *RedisTransport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, c := range []*redisConn{t.send, t.in, t.out} {
+		if c != nil {
+			c.Close()
+		}
+	}
+	return nil
+}
+
+// redisError is an error reply from the server.
+type redisError string
+
+func (e redisError) Error() string { return string(e) }
+
+func isRedisError(err error) bool {
+	var re redisError
+	return errors.As(err, &re)
+}
+
+// redisConn is one connection speaking RESP, the Redis protocol.
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// dialRedis connects to the server in u, authenticates and selects the
+// database in its path.
+func dialRedis(ctx context.Context, u *url.URL) (*redisConn, error) {
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	dialer := &net.Dialer{Timeout: redisTimeout, KeepAlive: 30 * time.Second}
+	var nc net.Conn
+	var err error
+	if u.Scheme == "rediss" {
+		nc, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: u.Hostname()}}).
+			DialContext(ctx, "tcp", host)
+	} else {
+		nc, err = dialer.DialContext(ctx, "tcp", host)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("connecting to Redis: %w", err)
+	}
+	c := &redisConn{Conn: nc, r: bufio.NewReader(nc)}
+
+	if password, ok := u.User.Password(); ok {
+		args := []string{"AUTH", password}
+		if user := u.User.Username(); user != "" {
+			args = []string{"AUTH", user, password}
+		}
+		if _, err := c.do(redisTimeout, args...); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("redis auth: %w", err)
+		}
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" && db != "0" {
+		if _, err := c.do(redisTimeout, "SELECT", db); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("redis select %s: %w", db, err)
+		}
+	}
+	return c, nil
+}
+
+// do sends a command and reads its reply within timeout. Replies are
+// strings, int64s, nil or []any; an error reply is a redisError.
+func (c *redisConn) do(timeout time.Duration, args ...string) (any, error) {
+	c.SetDeadline(time.Now().Add(timeout))
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if _, err := io.WriteString(c.Conn, b.String()); err != nil {
+		return nil, err
+	}
+	return c.read()
+}
+
+func (c *redisConn) read() (any, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("empty Redis reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = c.read(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("unexpected Redis reply %q", line)
+	}
+}
@@ -0,0 +1,131 @@
+package bus
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRedis serves the few list commands RedisTransport uses.
+type fakeRedis struct {
+	mu       sync.Mutex
+	lists    map[string][]string
+	commands []string
+}
+
+func startFakeRedis(t *testing.T) (*fakeRedis, string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+	f := &fakeRedis{lists: make(map[string][]string)}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(c)
+		}
+	}()
+	return f, "redis://:secret@" + ln.Addr().String() + "/2"
+}
+
+func (f *fakeRedis) serve(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		args := make([]string, n)
+		for i := range args {
+			r.ReadString('\n')
+			arg, _ := r.ReadString('\n')
+			args[i] = strings.TrimSuffix(arg, "\r\n")
+		}
+		fmt.Fprint(c, f.do(args))
+	}
+}
+
+func (f *fakeRedis) do(args []string) string {
+	f.mu.Lock()
+	f.commands = append(f.commands, args[0])
+	f.mu.Unlock()
+	switch args[0] {
+	case "AUTH":
+		if args[1] != "secret" {
+			return "-WRONGPASS invalid password\r\n"
+		}
+		return "+OK\r\n"
+	case "PING":
+		return "+PONG\r\n"
+	case "SELECT", "LTRIM":
+		return "+OK\r\n"
+	case "LPUSH":
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.lists[args[1]] = append([]string{args[2]}, f.lists[args[1]]...)
+		return fmt.Sprintf(":%d\r\n", len(f.lists[args[1]]))
+	case "BRPOP":
+		keys := args[1 : len(args)-1]
+		for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+			f.mu.Lock()
+			for _, k := range keys {
+				if l := f.lists[k]; len(l) > 0 {
+					v := l[len(l)-1]
+					f.lists[k] = l[:len(l)-1]
+					f.mu.Unlock()
+					return fmt.Sprintf("*2\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n", len(k), k, len(v), v)
+				}
+			}
+			f.mu.Unlock()
+		}
+		return "*-1\r\n"
+	default:
+		return "-ERR unknown command\r\n"
+	}
+}
+
+func TestRedisTransport(t *testing.T) {
+	f, url := startFakeRedis(t)
+	ctx := context.Background()
+
+	_, err := NewRedisTransport(ctx, "http://localhost:6379", "")
+	assert.ErrorContains(t, err, "invalid Redis URL")
+	_, err = NewRedisTransport(ctx, strings.Replace(url, "secret", "wrong", 1), "")
+	assert.ErrorContains(t, err, "WRONGPASS")
+
+	tr, err := NewRedisTransport(ctx, url, "test")
+	require.NoError(t, err)
+	defer tr.Close()
+
+	require.NoError(t, tr.SendInbound(ctx, InboundMessage{Channel: "line", ChatID: "u1", Content: "first"}))
+	require.NoError(t, tr.SendInbound(ctx, InboundMessage{Channel: "line", ChatID: "u1", Content: "second"}))
+	require.NoError(t, tr.SendOutbound(ctx, OutboundMessage{Channel: "telegram", ChatID: "42", Content: "reply"}))
+	f.mu.Lock()
+	assert.Len(t, f.lists["test:inbound"], 2)
+	assert.Len(t, f.lists["test:outbound:telegram"], 1)
+	assert.Contains(t, f.commands, "SELECT")
+	f.mu.Unlock()
+
+	in, err := tr.ReceiveInbound(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "first", in.Content, "oldest first")
+
+	_, err = tr.ReceiveOutbound(ctx, []string{"discord"})
+	assert.ErrorIs(t, err, ErrNoMessage)
+	out, err := tr.ReceiveOutbound(ctx, []string{"discord", "telegram"})
+	require.NoError(t, err)
+	assert.Equal(t, OutboundMessage{Channel: "telegram", ChatID: "42", Content: "reply"}, out)
+}
@@ -1,5 +1,10 @@
 package bus
 
+import (
+	"context"
+	"errors"
+)
+
 type InboundMessage struct {
 	Channel    string            `json:"channel"`
 	SenderID   string            `json:"sender_id"`
@@ -14,6 +19,34 @@ type OutboundMessage struct {
 	Channel string `json:"channel"`
 	ChatID  string `json:"chat_id"`
 	Content string `json:"content"`
+	// Notification marks a message the agent sends on its own rather than
+	// in reply, which the channel manager may batch with others.
+	Notification bool `json:"notification,omitempty"`
+	// QuickReplies are replies the user can pick instead of typing one;
+	// channels show them as buttons and send a pick back as a message.
+	QuickReplies []string `json:"quick_replies,omitempty"`
 }
 
 type MessageHandler func(InboundMessage) error
+
+// ErrNoMessage is returned by a Transport's receive methods when no message
+// arrived in time; the caller simply asks again.
+var ErrNoMessage = errors.New("no message")
+
+// Transport carries messages between gateways that share a bus, so that a
+// gateway behind a load balancer can hand work to the others and deliver
+// replies through channels it doesn't run. Each message is taken by one
+// gateway only.
+type Transport interface {
+	// SendInbound queues msg for whichever gateway receives it first.
+	SendInbound(ctx context.Context, msg InboundMessage) error
+	// SendOutbound queues msg for a gateway running msg.Channel.
+	SendOutbound(ctx context.Context, msg OutboundMessage) error
+	// ReceiveInbound waits a while for the next inbound message, and returns
+	// ErrNoMessage if none came.
+	ReceiveInbound(ctx context.Context) (InboundMessage, error)
+	// ReceiveOutbound waits a while for the next outbound message for one of
+	// channels, and returns ErrNoMessage if none came.
+	ReceiveOutbound(ctx context.Context, channels []string) (OutboundMessage, error)
+	Close() error
+}
@@ -0,0 +1,151 @@
+// Package categorize puts expenses in categories with rules instead of a
+// model: "anything from Shell is Fuel". Rules come from the config and from
+// what users teach picoclaw in chat, and the first one an expense matches
+// decides its category, so the same expense is always filed the same way.
+package categorize
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// Expense is what rules are matched against.
+type Expense struct {
+	Vendor string
+	Amount string // e.g. "42.10"; a rule with amount bounds never matches an unknown amount
+	Text   string // the receipt's text or the transaction's description
+}
+
+// patterns caches compiled merchant patterns, which are matched against
+// every receipt.
+var patterns sync.Map // string -> *regexp.Regexp
+
+// Match returns the first of rules that e matches.
+func Match(rules []config.CategoryRule, e Expense) (config.CategoryRule, bool) {
+	for _, r := range rules {
+		if matches(r, e) {
+			return r, true
+		}
+	}
+	return config.CategoryRule{}, false
+}
+
+func matches(r config.CategoryRule, e Expense) bool {
+	if r.Merchant != "" {
+		re, err := pattern(r.Merchant)
+		if err != nil || !re.MatchString(e.Vendor) {
+			return false
+		}
+	}
+	if r.Keyword != "" && !strings.Contains(strings.ToLower(e.Text), strings.ToLower(r.Keyword)) {
+		return false
+	}
+	if r.MinAmount > 0 || r.MaxAmount > 0 {
+		amount, err := strconv.ParseFloat(strings.TrimPrefix(e.Amount, "-"), 64)
+		if err != nil || r.MinAmount > 0 && amount < r.MinAmount || r.MaxAmount > 0 && amount > r.MaxAmount {
+			return false
+		}
+	}
+	return true
+}
+
+func pattern(expr string) (*regexp.Regexp, error) {
+	if re, ok := patterns.Load(expr); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile("(?i)" + expr)
+	if err != nil {
+		return nil, err
+	}
+	patterns.Store(expr, re)
+	return re, nil
+}
+
+// MerchantRule is the rule for "always categorize <merchant> as
+// <category>": the merchant's name as a whole word, anywhere in the vendor.
+func MerchantRule(merchant, category string) config.CategoryRule {
+	return config.CategoryRule{
+		Merchant: `\b` + regexp.QuoteMeta(strings.TrimSpace(merchant)) + `\b`,
+		Category: strings.TrimSpace(category),
+	}
+}
+
+// Check reports what is wrong with a rule, if anything.
+func Check(r config.CategoryRule) error {
+	if strings.TrimSpace(r.Category) == "" {
+		return errors.New("a rule needs a category")
+	}
+	if r.Merchant == "" && r.Keyword == "" && r.MinAmount == 0 && r.MaxAmount == 0 {
+		return fmt.Errorf("the rule for %s matches everything; give a merchant, keyword or amount", r.Category)
+	}
+	if _, err := pattern(r.Merchant); err != nil {
+		return fmt.Errorf("merchant %q is not a regular expression: %w", r.Merchant, err)
+	}
+	if r.MinAmount < 0 || r.MaxAmount < 0 || r.MaxAmount > 0 && r.MaxAmount < r.MinAmount {
+		return fmt.Errorf("the amounts of the rule for %s are not a range", r.Category)
+	}
+	return nil
+}
+
+// Validate checks the rules in the receipts config.
+func Validate(cfg config.ReceiptsConfig) error {
+	for i, r := range cfg.Rules {
+		if err := Check(r); err != nil {
+			return fmt.Errorf("rule %d: %w", i+1, err)
+		}
+	}
+	for id, b := range cfg.Businesses {
+		for i, r := range b.Rules {
+			if err := Check(r); err != nil {
+				return fmt.Errorf("rule %d of business %s: %w", i+1, id, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Describe says in a line what a rule matches, for listing rules.
+func Describe(r config.CategoryRule) string {
+	var parts []string
+	if r.Merchant != "" {
+		merchant := r.Merchant
+		if inner, ok := strings.CutPrefix(merchant, `\b`); ok && strings.HasSuffix(inner, `\b`) {
+			// A rule made by MerchantRule reads as the name it was made from.
+			name := strings.TrimSuffix(inner, `\b`)
+			if regexp.QuoteMeta(unquote(name)) == name {
+				merchant = unquote(name)
+			}
+		}
+		parts = append(parts, "merchant "+merchant)
+	}
+	if r.Keyword != "" {
+		parts = append(parts, fmt.Sprintf("text with %q", r.Keyword))
+	}
+	switch {
+	case r.MinAmount > 0 && r.MaxAmount > 0:
+		parts = append(parts, fmt.Sprintf("amount %.2f to %.2f", r.MinAmount, r.MaxAmount))
+	case r.MinAmount > 0:
+		parts = append(parts, fmt.Sprintf("amount from %.2f", r.MinAmount))
+	case r.MaxAmount > 0:
+		parts = append(parts, fmt.Sprintf("amount up to %.2f", r.MaxAmount))
+	}
+	return strings.Join(parts, ", ") + " → " + r.Category
+}
+
+// unquote undoes regexp.QuoteMeta.
+func unquote(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
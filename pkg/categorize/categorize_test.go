@@ -0,0 +1,65 @@
+package categorize
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestMatch(t *testing.T) {
+	rules := []config.CategoryRule{
+		MerchantRule("Shell", "Fuel"),
+		{Keyword: "parking", MaxAmount: 20, Category: "Parking"},
+		{Merchant: `^Amazon`, MinAmount: 500, Category: "Equipment"},
+		{Merchant: `^Amazon`, Category: "Office Supplies"},
+	}
+	tests := []struct {
+		name     string
+		expense  Expense
+		category string
+	}{
+		{"merchant word", Expense{Vendor: "SHELL Canada #123", Amount: "60.00"}, "Fuel"},
+		{"merchant inside a word", Expense{Vendor: "Seashells Gifts", Amount: "60.00"}, ""},
+		{"keyword under the limit", Expense{Vendor: "City Lot", Amount: "12.00", Text: "Parking 2h"}, "Parking"},
+		{"keyword over the limit", Expense{Vendor: "City Lot", Amount: "45.00", Text: "Parking day"}, ""},
+		{"first rule wins", Expense{Vendor: "Amazon.ca", Amount: "899.99"}, "Equipment"},
+		{"later rule", Expense{Vendor: "Amazon.ca", Amount: "19.99"}, "Office Supplies"},
+		{"unknown amount", Expense{Vendor: "Amazon.ca"}, "Office Supplies"},
+		{"no rule", Expense{Vendor: "Corner Cafe", Amount: "12.50"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, ok := Match(rules, tt.expense)
+			assert.Equal(t, tt.category != "", ok)
+			assert.Equal(t, tt.category, rule.Category)
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	assert.NoError(t, Check(MerchantRule("Tim Hortons", "Meals")))
+	assert.ErrorContains(t, Check(config.CategoryRule{Merchant: "Shell"}), "needs a category")
+	assert.ErrorContains(t, Check(config.CategoryRule{Category: "Fuel"}), "matches everything")
+	assert.ErrorContains(t, Check(config.CategoryRule{Merchant: "(", Category: "Fuel"}), "not a regular expression")
+	assert.ErrorContains(t, Check(config.CategoryRule{MinAmount: 50, MaxAmount: 10, Category: "Fuel"}),
+		"not a range")
+
+	err := Validate(config.ReceiptsConfig{
+		Businesses: map[string]config.BusinessReceiptsConfig{
+			"biz-1": {Rules: []config.CategoryRule{MerchantRule("Shell", "Fuel"), {Keyword: "tip"}}},
+		},
+	})
+	assert.ErrorContains(t, err, "rule 2 of business biz-1")
+}
+
+func TestDescribe(t *testing.T) {
+	assert.Equal(t, "merchant A&W → Meals", Describe(MerchantRule("A&W", "Meals")))
+	assert.Equal(t, "merchant Home Depot (Store #1) → Supplies",
+		Describe(MerchantRule("Home Depot (Store #1)", "Supplies")))
+	assert.Equal(t, `merchant ^Amazon, text with "prime", amount 10.00 to 20.00 → Subscriptions`,
+		Describe(config.CategoryRule{
+			Merchant: "^Amazon", Keyword: "prime", MinAmount: 10, MaxAmount: 20, Category: "Subscriptions",
+		}))
+}
@@ -16,6 +16,24 @@ type Channel interface {
 	IsAllowed(senderID string) bool
 }
 
+// QuickReplyChannel is a Channel that shows the quick replies of outbound
+// messages as buttons. Other channels get them listed in the text.
+type QuickReplyChannel interface {
+	Channel
+	SupportsQuickReplies() bool
+}
+
+// appendQuickReplies lists the quick replies of msg after its content, for
+// channels without buttons.
+func appendQuickReplies(msg bus.OutboundMessage) bus.OutboundMessage {
+	if len(msg.QuickReplies) == 0 {
+		return msg
+	}
+	msg.Content = strings.TrimRight(msg.Content, "\n") + "\n\n→ " + strings.Join(msg.QuickReplies, " | ")
+	msg.QuickReplies = nil
+	return msg
+}
+
 type BaseChannel struct {
 	config    any
 	bus       *bus.MessageBus
@@ -1,6 +1,10 @@
 package channels
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+)
 
 func TestBaseChannelIsAllowed(t *testing.T) {
 	tests := []struct {
@@ -50,3 +54,17 @@ func TestBaseChannelIsAllowed(t *testing.T) {
 		})
 	}
 }
+
+func TestAppendQuickReplies(t *testing.T) {
+	msg := appendQuickReplies(bus.OutboundMessage{Content: "Pay it now?\n", QuickReplies: []string{"Yes", "No"}})
+	if msg.Content != "Pay it now?\n\n→ Yes | No" {
+		t.Errorf("content = %q", msg.Content)
+	}
+	if msg.QuickReplies != nil {
+		t.Errorf("quick replies = %q, want none", msg.QuickReplies)
+	}
+
+	if msg := appendQuickReplies(bus.OutboundMessage{Content: "Done"}); msg.Content != "Done" {
+		t.Errorf("content without replies = %q", msg.Content)
+	}
+}
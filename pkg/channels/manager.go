@@ -10,6 +10,7 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/config"
@@ -18,11 +19,12 @@ import (
 )
 
 type Manager struct {
-	channels     map[string]Channel
-	bus          *bus.MessageBus
-	config       *config.Config
-	dispatchTask *asyncTask
-	mu           sync.RWMutex
+	channels      map[string]Channel
+	bus           *bus.MessageBus
+	config        *config.Config
+	dispatchTask  *asyncTask
+	notifications *notificationBatcher // nil unless notifications are batched
+	mu            sync.RWMutex
 }
 
 type asyncTask struct {
@@ -35,6 +37,10 @@ func NewManager(cfg *config.Config, messageBus *bus.MessageBus) (*Manager, error
 		bus:      messageBus,
 		config:   cfg,
 	}
+	if cfg.Notifications.Enabled && cfg.Notifications.BatchSeconds > 0 {
+		window := time.Duration(cfg.Notifications.BatchSeconds) * time.Second
+		m.notifications = newNotificationBatcher(window, m.send)
+	}
 
 	if err := m.initChannels(); err != nil {
 		return nil, err
@@ -202,6 +208,19 @@ func (m *Manager) initChannels() error {
 		}
 	}
 
+	if m.config.Channels.Voice.Enabled {
+		logger.DebugC("channels", "Attempting to initialize voice channel")
+		voiceChannel, err := NewVoiceChannel(m.config.Channels.Voice, m.config.WorkspacePath(), m.bus)
+		if err != nil {
+			logger.ErrorCF("channels", "Failed to initialize voice channel", map[string]any{
+				"error": err.Error(),
+			})
+		} else {
+			m.channels["voice"] = voiceChannel
+			logger.InfoC("channels", "Voice channel enabled successfully")
+		}
+	}
+
 	logger.InfoCF("channels", "Channel initialization completed", map[string]any{
 		"enabled_channels": len(m.channels),
 	})
@@ -242,6 +261,10 @@ func (m *Manager) StartAll(ctx context.Context) error {
 }
 
 func (m *Manager) StopAll(ctx context.Context) error {
+	if m.notifications != nil {
+		m.notifications.flushAll(ctx)
+	}
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -287,27 +310,39 @@ func (m *Manager) dispatchOutbound(ctx context.Context) {
 				continue
 			}
 
-			m.mu.RLock()
-			channel, exists := m.channels[msg.Channel]
-			m.mu.RUnlock()
-
-			if !exists {
-				logger.WarnCF("channels", "Unknown channel for outbound message", map[string]any{
-					"channel": msg.Channel,
-				})
+			if msg.Notification && m.notifications != nil {
+				m.notifications.add(msg)
 				continue
 			}
-
-			if err := channel.Send(ctx, msg); err != nil {
-				logger.ErrorCF("channels", "Error sending message to channel", map[string]any{
-					"channel": msg.Channel,
-					"error":   err.Error(),
-				})
-			}
+			m.send(ctx, msg)
 		}
 	}
 }
 
+// send delivers msg through its channel.
+func (m *Manager) send(ctx context.Context, msg bus.OutboundMessage) {
+	m.mu.RLock()
+	channel, exists := m.channels[msg.Channel]
+	m.mu.RUnlock()
+
+	if !exists {
+		logger.WarnCF("channels", "Unknown channel for outbound message", map[string]any{
+			"channel": msg.Channel,
+		})
+		return
+	}
+
+	if qc, ok := channel.(QuickReplyChannel); !ok || !qc.SupportsQuickReplies() {
+		msg = appendQuickReplies(msg)
+	}
+	if err := channel.Send(ctx, msg); err != nil {
+		logger.ErrorCF("channels", "Error sending message to channel", map[string]any{
+			"channel": msg.Channel,
+			"error":   err.Error(),
+		})
+	}
+}
+
 func (m *Manager) GetChannel(name string) (Channel, bool) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -0,0 +1,112 @@
+package channels
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	// maxBatchItems is how many notifications a batch lists before it only
+	// counts the rest.
+	maxBatchItems = 20
+	// maxBatchItemLen cuts each notification in a batch, so the batch stays
+	// one readable message.
+	maxBatchItemLen = 300
+)
+
+// notificationBatcher holds the notifications for a chat from the first one
+// until the window ends, and sends them as one message.
+type notificationBatcher struct {
+	window time.Duration
+	send   func(ctx context.Context, msg bus.OutboundMessage)
+
+	mu      sync.Mutex
+	pending map[string]*notificationBatch // by channel and chat ID
+}
+
+type notificationBatch struct {
+	channel  string
+	chatID   string
+	contents []string
+	timer    *time.Timer
+}
+
+func newNotificationBatcher(
+	window time.Duration,
+	send func(ctx context.Context, msg bus.OutboundMessage),
+) *notificationBatcher {
+	return &notificationBatcher{
+		window:  window,
+		send:    send,
+		pending: make(map[string]*notificationBatch),
+	}
+}
+
+// add holds msg until its chat's window ends, starting the window if msg is
+// the first notification for the chat.
+func (b *notificationBatcher) add(msg bus.OutboundMessage) {
+	key := msg.Channel + "\x00" + msg.ChatID
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	batch, ok := b.pending[key]
+	if !ok {
+		batch = &notificationBatch{channel: msg.Channel, chatID: msg.ChatID}
+		batch.timer = time.AfterFunc(b.window, func() { b.flush(context.Background(), key) })
+		b.pending[key] = batch
+	}
+	batch.contents = append(batch.contents, msg.Content)
+}
+
+// flush sends the notifications held for key, if any.
+func (b *notificationBatcher) flush(ctx context.Context, key string) {
+	b.mu.Lock()
+	batch, ok := b.pending[key]
+	delete(b.pending, key)
+	b.mu.Unlock()
+	if !ok {
+		return
+	}
+	batch.timer.Stop()
+	b.send(ctx, bus.OutboundMessage{
+		Channel: batch.channel,
+		ChatID:  batch.chatID,
+		Content: formatBatch(batch.contents),
+	})
+}
+
+// flushAll sends every held notification now, such as on shutdown.
+func (b *notificationBatcher) flushAll(ctx context.Context) {
+	b.mu.Lock()
+	keys := make([]string, 0, len(b.pending))
+	for key := range b.pending {
+		keys = append(keys, key)
+	}
+	b.mu.Unlock()
+	for _, key := range keys {
+		b.flush(ctx, key)
+	}
+}
+
+// formatBatch renders held notifications as one message. A single one is
+// sent as it is.
+func formatBatch(contents []string) string {
+	if len(contents) == 1 {
+		return contents[0]
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "🔔 %d notifications:", len(contents))
+	for i, content := range contents {
+		if i == maxBatchItems {
+			fmt.Fprintf(&sb, "\n\n…and %d more", len(contents)-maxBatchItems)
+			break
+		}
+		sb.WriteString("\n\n• " + utils.Truncate(strings.TrimSpace(content), maxBatchItemLen))
+	}
+	return sb.String()
+}
@@ -0,0 +1,92 @@
+package channels
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+)
+
+func TestNotificationBatcher(t *testing.T) {
+	var mu sync.Mutex
+	var sent []bus.OutboundMessage
+	b := newNotificationBatcher(50*time.Millisecond, func(_ context.Context, msg bus.OutboundMessage) {
+		mu.Lock()
+		defer mu.Unlock()
+		sent = append(sent, msg)
+	})
+
+	for i := 1; i <= 3; i++ {
+		b.add(bus.OutboundMessage{Channel: "telegram", ChatID: "42", Content: fmt.Sprintf("Receipt %d categorized", i)})
+	}
+	b.add(bus.OutboundMessage{Channel: "telegram", ChatID: "7", Content: "Backup finished"})
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mu.Lock()
+		n := len(sent)
+		mu.Unlock()
+		if n == 2 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(sent) != 2 {
+		t.Fatalf("sent %d messages, want 2: %+v", len(sent), sent)
+	}
+	for _, msg := range sent {
+		switch msg.ChatID {
+		case "42":
+			want := "🔔 3 notifications:\n\n• Receipt 1 categorized\n\n• Receipt 2 categorized\n\n• Receipt 3 categorized"
+			if msg.Content != want {
+				t.Errorf("batch = %q, want %q", msg.Content, want)
+			}
+		case "7":
+			if msg.Content != "Backup finished" {
+				t.Errorf("single notification = %q", msg.Content)
+			}
+		default:
+			t.Errorf("unexpected chat %q", msg.ChatID)
+		}
+	}
+}
+
+func TestNotificationBatcher_FlushAll(t *testing.T) {
+	var sent []bus.OutboundMessage
+	b := newNotificationBatcher(time.Hour, func(_ context.Context, msg bus.OutboundMessage) {
+		sent = append(sent, msg)
+	})
+	b.add(bus.OutboundMessage{Channel: "telegram", ChatID: "42", Content: "one"})
+	b.flushAll(context.Background())
+	if len(sent) != 1 || sent[0].Content != "one" {
+		t.Fatalf("flushAll sent %+v", sent)
+	}
+	b.flushAll(context.Background())
+	if len(sent) != 1 {
+		t.Errorf("second flushAll sent again: %+v", sent)
+	}
+}
+
+func TestFormatBatch_CapsItems(t *testing.T) {
+	contents := make([]string, maxBatchItems+5)
+	for i := range contents {
+		contents[i] = strings.Repeat("x", maxBatchItemLen*2)
+	}
+	out := formatBatch(contents)
+	if !strings.HasPrefix(out, fmt.Sprintf("🔔 %d notifications:", len(contents))) {
+		t.Errorf("header missing: %q", out[:40])
+	}
+	if strings.Count(out, "• ") != maxBatchItems {
+		t.Errorf("listed %d items, want %d", strings.Count(out, "• "), maxBatchItems)
+	}
+	if !strings.HasSuffix(out, "…and 5 more") {
+		t.Errorf("missing remainder count: %q", out[len(out)-40:])
+	}
+}
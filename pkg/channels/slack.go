@@ -126,6 +126,9 @@ func (c *SlackChannel) Send(ctx context.Context, msg bus.OutboundMessage) error
 	if threadTS != "" {
 		opts = append(opts, slack.MsgOptionTS(threadTS))
 	}
+	if len(msg.QuickReplies) > 0 {
+		opts = append(opts, slack.MsgOptionBlocks(quickReplyBlocks(msg.Content, msg.QuickReplies)...))
+	}
 
 	_, _, err := c.api.PostMessageContext(ctx, channelID, opts...)
 	if err != nil {
@@ -163,9 +166,7 @@ func (c *SlackChannel) eventLoop() {
 			case socketmode.EventTypeSlashCommand:
 				c.handleSlashCommand(event)
 			case socketmode.EventTypeInteractive:
-				if event.Request != nil {
-					c.socketClient.Ack(*event.Request)
-				}
+				c.handleInteractive(event)
 			}
 		}
 	}
@@ -409,6 +410,119 @@ func (c *SlackChannel) handleSlashCommand(event socketmode.Event) {
 	c.HandleMessage(senderID, chatID, content, nil, metadata)
 }
 
+// SupportsQuickReplies reports that quick replies are shown as buttons.
+func (c *SlackChannel) SupportsQuickReplies() bool {
+	return true
+}
+
+const (
+	// quickReplyBlockID identifies the block of quick reply buttons.
+	quickReplyBlockID = "quick_replies"
+	// maxSectionText is how much text Slack takes in one section block.
+	maxSectionText = 3000
+)
+
+// quickReplyBlocks lays out content as section blocks followed by a button
+// per reply. Blocks replace the text of a message, so content is repeated.
+func quickReplyBlocks(content string, replies []string) []slack.Block {
+	var blocks []slack.Block
+	for text := []rune(content); len(text) > 0; {
+		n := min(len(text), maxSectionText)
+		section := slack.NewTextBlockObject(slack.MarkdownType, string(text[:n]), false, false)
+		blocks = append(blocks, slack.NewSectionBlock(section, nil, nil))
+		text = text[n:]
+	}
+	buttons := make([]slack.BlockElement, len(replies))
+	for i, reply := range replies {
+		label := slack.NewTextBlockObject(slack.PlainTextType, reply, true, false)
+		buttons[i] = slack.NewButtonBlockElement(fmt.Sprintf("quick_reply_%d", i), reply, label)
+	}
+	return append(blocks, slack.NewActionBlock(quickReplyBlockID, buttons...))
+}
+
+// handleInteractive takes a clicked quick reply as a message from the user
+// who clicked it, and replaces the buttons with the reply so it is only
+// picked once.
+func (c *SlackChannel) handleInteractive(event socketmode.Event) {
+	if event.Request != nil {
+		c.socketClient.Ack(*event.Request)
+	}
+
+	callback, ok := event.Data.(slack.InteractionCallback)
+	if !ok || callback.Type != slack.InteractionTypeBlockActions {
+		return
+	}
+	var reply string
+	for _, action := range callback.ActionCallback.BlockActions {
+		if action.BlockID == quickReplyBlockID {
+			reply = action.Value
+			break
+		}
+	}
+	if reply == "" {
+		return
+	}
+
+	senderID := callback.User.ID
+	if !c.IsAllowed(senderID) {
+		logger.DebugCF("slack", "Quick reply rejected by allowlist", map[string]any{
+			"user_id": senderID,
+		})
+		return
+	}
+
+	channelID := callback.Container.ChannelID
+	if channelID == "" {
+		channelID = callback.Channel.ID
+	}
+	threadTS := callback.Container.ThreadTs
+	if threadTS == "" {
+		threadTS = callback.Message.ThreadTimestamp
+	}
+	chatID := channelID
+	if threadTS != "" {
+		chatID = channelID + "/" + threadTS
+	}
+
+	var blocks []slack.Block
+	for _, block := range callback.Message.Blocks.BlockSet {
+		if block.ID() != quickReplyBlockID {
+			blocks = append(blocks, block)
+		}
+	}
+	picked := slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("<@%s> replied: %s", senderID, reply), false, false)
+	blocks = append(blocks, slack.NewContextBlock("", picked))
+	_, _, _, err := c.api.UpdateMessageContext(c.ctx, channelID, callback.Container.MessageTs,
+		slack.MsgOptionText(callback.Message.Text, false),
+		slack.MsgOptionBlocks(blocks...),
+	)
+	if err != nil {
+		logger.DebugCF("slack", "Failed to remove quick replies", map[string]any{
+			"error": err.Error(),
+		})
+	}
+
+	peerKind := "channel"
+	peerID := channelID
+	if strings.HasPrefix(channelID, "D") {
+		peerKind = "direct"
+		peerID = senderID
+	}
+
+	metadata := map[string]string{
+		"message_ts":  callback.Container.MessageTs,
+		"channel_id":  channelID,
+		"thread_ts":   threadTS,
+		"platform":    "slack",
+		"quick_reply": "true",
+		"peer_kind":   peerKind,
+		"peer_id":     peerID,
+		"team_id":     c.teamID,
+	}
+
+	c.HandleMessage(senderID, chatID, reply, nil, metadata)
+}
+
 func (c *SlackChannel) downloadSlackFile(file slack.File) string {
 	downloadURL := file.URLPrivateDownload
 	if downloadURL == "" {
@@ -1,8 +1,11 @@
 package channels
 
 import (
+	"strings"
 	"testing"
 
+	"github.com/slack-go/slack"
+
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/config"
 )
@@ -172,3 +175,22 @@ func TestSlackChannelIsAllowed(t *testing.T) {
 		}
 	})
 }
+
+func TestQuickReplyBlocks(t *testing.T) {
+	content := strings.Repeat("a", maxSectionText+10)
+	blocks := quickReplyBlocks(content, []string{"Yes", "No"})
+	if len(blocks) != 3 {
+		t.Fatalf("blocks = %d, want 2 sections and the buttons", len(blocks))
+	}
+	first := blocks[0].(*slack.SectionBlock)
+	if len(first.Text.Text) != maxSectionText {
+		t.Errorf("first section has %d characters", len(first.Text.Text))
+	}
+	actions, ok := blocks[2].(*slack.ActionBlock)
+	if !ok || actions.ID() != quickReplyBlockID || len(actions.Elements.ElementSet) != 2 {
+		t.Fatalf("last block = %#v", blocks[2])
+	}
+	if button := actions.Elements.ElementSet[1].(*slack.ButtonBlockElement); button.Value != "No" {
+		t.Errorf("second button value = %q", button.Value)
+	}
+}
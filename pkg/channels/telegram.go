@@ -3,8 +3,6 @@ package channels
 import (
 	"context"
 	"fmt"
-	"net/http"
-	"net/url"
 	"os"
 	"regexp"
 	"strings"
@@ -18,6 +16,7 @@ import (
 
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/httpclient"
 	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/utils"
 	"github.com/sipeed/picoclaw/pkg/voice"
@@ -48,24 +47,12 @@ func NewTelegramChannel(cfg *config.Config, bus *bus.MessageBus) (*TelegramChann
 	var opts []telego.BotOption
 	telegramCfg := cfg.Channels.Telegram
 
-	if telegramCfg.Proxy != "" {
-		proxyURL, parseErr := url.Parse(telegramCfg.Proxy)
-		if parseErr != nil {
-			return nil, fmt.Errorf("invalid proxy URL %q: %w", telegramCfg.Proxy, parseErr)
-		}
-		opts = append(opts, telego.WithHTTPClient(&http.Client{
-			Transport: &http.Transport{
-				Proxy: http.ProxyURL(proxyURL),
-			},
-		}))
-	} else if os.Getenv("HTTP_PROXY") != "" || os.Getenv("HTTPS_PROXY") != "" {
-		// Use environment proxy if configured
-		opts = append(opts, telego.WithHTTPClient(&http.Client{
-			Transport: &http.Transport{
-				Proxy: http.ProxyFromEnvironment,
-			},
-		}))
+	// The shared client honors the network proxy; a channel proxy overrides it.
+	client, err := httpclient.NewWithProxy(0, telegramCfg.Proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL %q: %w", telegramCfg.Proxy, err)
 	}
+	opts = append(opts, telego.WithHTTPClient(client))
 
 	bot, err := telego.NewBot(telegramCfg.Token, opts...)
 	if err != nil {
@@ -125,6 +112,10 @@ func (c *TelegramChannel) Start(ctx context.Context) error {
 		return c.handleMessage(ctx, &message)
 	}, th.AnyMessage())
 
+	bh.HandleCallbackQuery(func(ctx *th.Context, query telego.CallbackQuery) error {
+		return c.handleQuickReply(ctx, query)
+	}, th.AnyCallbackQueryWithMessage(), th.CallbackDataPrefix(quickReplyData))
+
 	c.setRunning(true)
 	logger.InfoCF("telegram", "Telegram bot connected", map[string]any{
 		"username": c.bot.Username(),
@@ -165,12 +156,14 @@ func (c *TelegramChannel) Send(ctx context.Context, msg bus.OutboundMessage) err
 	}
 
 	htmlContent := markdownToTelegramHTML(msg.Content)
+	keyboard := quickReplyKeyboard(msg.QuickReplies)
 
 	// Try to edit placeholder
 	if pID, ok := c.placeholders.Load(msg.ChatID); ok {
 		c.placeholders.Delete(msg.ChatID)
 		editMsg := tu.EditMessageText(tu.ID(chatID), pID.(int), htmlContent)
 		editMsg.ParseMode = telego.ModeHTML
+		editMsg.ReplyMarkup = keyboard
 
 		if _, err = c.bot.EditMessageText(ctx, editMsg); err == nil {
 			return nil
@@ -180,6 +173,9 @@ func (c *TelegramChannel) Send(ctx context.Context, msg bus.OutboundMessage) err
 
 	tgMsg := tu.Message(tu.ID(chatID), htmlContent)
 	tgMsg.ParseMode = telego.ModeHTML
+	if keyboard != nil {
+		tgMsg.ReplyMarkup = keyboard
+	}
 
 	if _, err = c.bot.SendMessage(ctx, tgMsg); err != nil {
 		logger.ErrorCF("telegram", "HTML parse failed, falling back to plain text", map[string]any{
@@ -193,6 +189,70 @@ func (c *TelegramChannel) Send(ctx context.Context, msg bus.OutboundMessage) err
 	return nil
 }
 
+// SupportsQuickReplies reports that quick replies are shown as an inline
+// keyboard.
+func (c *TelegramChannel) SupportsQuickReplies() bool {
+	return true
+}
+
+// quickReplyData prefixes the callback data of quick reply buttons; the
+// rest is the reply.
+const quickReplyData = "qr:"
+
+// quickReplyKeyboard returns an inline keyboard with a button per reply, two
+// to a row when they are short, or nil without replies.
+func quickReplyKeyboard(replies []string) *telego.InlineKeyboardMarkup {
+	if len(replies) == 0 {
+		return nil
+	}
+	buttons := make([]telego.InlineKeyboardButton, len(replies))
+	cols := 2
+	for i, reply := range replies {
+		buttons[i] = tu.InlineKeyboardButton(reply).WithCallbackData(quickReplyData + reply)
+		if len(reply) > 20 {
+			cols = 1
+		}
+	}
+	return tu.InlineKeyboard(tu.InlineKeyboardCols(cols, buttons...)...)
+}
+
+// handleQuickReply takes a tapped quick reply as a message from the user who
+// tapped it, and removes the keyboard so it is only picked once.
+func (c *TelegramChannel) handleQuickReply(ctx context.Context, query telego.CallbackQuery) error {
+	if err := c.bot.AnswerCallbackQuery(ctx, tu.CallbackQuery(query.ID)); err != nil {
+		logger.DebugCF("telegram", "Failed to answer callback query", map[string]any{
+			"error": err.Error(),
+		})
+	}
+
+	senderID := fmt.Sprintf("%d", query.From.ID)
+	if query.From.Username != "" {
+		senderID = fmt.Sprintf("%d|%s", query.From.ID, query.From.Username)
+	}
+	if !c.IsAllowed(senderID) {
+		return nil
+	}
+
+	chat := query.Message.GetChat()
+	messageID := query.Message.GetMessageID()
+	_, err := c.bot.EditMessageReplyMarkup(ctx, &telego.EditMessageReplyMarkupParams{
+		ChatID:    tu.ID(chat.ID),
+		MessageID: messageID,
+	})
+	if err != nil {
+		logger.DebugCF("telegram", "Failed to remove quick replies", map[string]any{
+			"error": err.Error(),
+		})
+	}
+
+	return c.handleMessage(ctx, &telego.Message{
+		MessageID: messageID,
+		From:      &query.From,
+		Chat:      chat,
+		Text:      strings.TrimPrefix(query.Data, quickReplyData),
+	})
+}
+
 func (c *TelegramChannel) handleMessage(ctx context.Context, message *telego.Message) error {
 	if message == nil {
 		return fmt.Errorf("message is nil")
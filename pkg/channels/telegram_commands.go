@@ -2,12 +2,14 @@ package channels
 
 import (
 	"context"
-	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/mymmrac/telego"
 
 	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/i18n"
+	"github.com/sipeed/picoclaw/pkg/locale"
 )
 
 type TelegramCommander interface {
@@ -37,12 +39,18 @@ func commandArgs(text string) string {
 	return strings.TrimSpace(parts[1])
 }
 
+// language is the language to answer message in: its sender's, else the
+// default one.
+func (c *cmd) language(message telego.Message) string {
+	userID := ""
+	if message.From != nil {
+		userID = strconv.FormatInt(message.From.ID, 10)
+	}
+	return locale.NewResolver(c.config.Locale).Language("", userID)
+}
+
 func (c *cmd) Help(ctx context.Context, message telego.Message) error {
-	msg := `/start - Start the bot
-/help - Show this help message
-/show [model|channel] - Show current configuration
-/list [models|channels] - List available options
-	`
+	msg := i18n.T(c.language(message), "telegram.help")
 	_, err := c.bot.SendMessage(ctx, &telego.SendMessageParams{
 		ChatID: telego.ChatID{ID: message.Chat.ID},
 		Text:   msg,
@@ -56,7 +64,7 @@ func (c *cmd) Help(ctx context.Context, message telego.Message) error {
 func (c *cmd) Start(ctx context.Context, message telego.Message) error {
 	_, err := c.bot.SendMessage(ctx, &telego.SendMessageParams{
 		ChatID: telego.ChatID{ID: message.Chat.ID},
-		Text:   "Hello! I am PicoClaw 🦞",
+		Text:   i18n.T(c.language(message), "telegram.start"),
 		ReplyParameters: &telego.ReplyParameters{
 			MessageID: message.MessageID,
 		},
@@ -65,11 +73,12 @@ func (c *cmd) Start(ctx context.Context, message telego.Message) error {
 }
 
 func (c *cmd) Show(ctx context.Context, message telego.Message) error {
+	lang := c.language(message)
 	args := commandArgs(message.Text)
 	if args == "" {
 		_, err := c.bot.SendMessage(ctx, &telego.SendMessageParams{
 			ChatID: telego.ChatID{ID: message.Chat.ID},
-			Text:   "Usage: /show [model|channel]",
+			Text:   i18n.T(lang, "telegram.show.usage"),
 			ReplyParameters: &telego.ReplyParameters{
 				MessageID: message.MessageID,
 			},
@@ -80,13 +89,13 @@ func (c *cmd) Show(ctx context.Context, message telego.Message) error {
 	var response string
 	switch args {
 	case "model":
-		response = fmt.Sprintf("Current Model: %s (Provider: %s)",
+		response = i18n.T(lang, "telegram.show.model",
 			c.config.Agents.Defaults.Model,
 			c.config.Agents.Defaults.Provider)
 	case "channel":
-		response = "Current Channel: telegram"
+		response = i18n.T(lang, "telegram.show.channel")
 	default:
-		response = fmt.Sprintf("Unknown parameter: %s. Try 'model' or 'channel'.", args)
+		response = i18n.T(lang, "telegram.show.unknown", args)
 	}
 
 	_, err := c.bot.SendMessage(ctx, &telego.SendMessageParams{
@@ -100,11 +109,12 @@ func (c *cmd) Show(ctx context.Context, message telego.Message) error {
 }
 
 func (c *cmd) List(ctx context.Context, message telego.Message) error {
+	lang := c.language(message)
 	args := commandArgs(message.Text)
 	if args == "" {
 		_, err := c.bot.SendMessage(ctx, &telego.SendMessageParams{
 			ChatID: telego.ChatID{ID: message.Chat.ID},
-			Text:   "Usage: /list [models|channels]",
+			Text:   i18n.T(lang, "telegram.list.usage"),
 			ReplyParameters: &telego.ReplyParameters{
 				MessageID: message.MessageID,
 			},
@@ -117,10 +127,9 @@ func (c *cmd) List(ctx context.Context, message telego.Message) error {
 	case "models":
 		provider := c.config.Agents.Defaults.Provider
 		if provider == "" {
-			provider = "configured default"
+			provider = i18n.T(lang, "telegram.list.default")
 		}
-		response = fmt.Sprintf("Configured Model: %s\nProvider: %s\n\nTo change models, update config.yaml",
-			c.config.Agents.Defaults.Model, provider)
+		response = i18n.T(lang, "telegram.list.models", c.config.Agents.Defaults.Model, provider)
 
 	case "channels":
 		var enabled []string
@@ -139,10 +148,10 @@ func (c *cmd) List(ctx context.Context, message telego.Message) error {
 		if c.config.Channels.Slack.Enabled {
 			enabled = append(enabled, "slack")
 		}
-		response = fmt.Sprintf("Enabled Channels:\n- %s", strings.Join(enabled, "\n- "))
+		response = i18n.T(lang, "telegram.list.channels", strings.Join(enabled, "\n- "))
 
 	default:
-		response = fmt.Sprintf("Unknown parameter: %s. Try 'models' or 'channels'.", args)
+		response = i18n.T(lang, "telegram.list.unknown", args)
 	}
 
 	_, err := c.bot.SendMessage(ctx, &telego.SendMessageParams{
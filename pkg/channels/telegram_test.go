@@ -0,0 +1,22 @@
+package channels
+
+import "testing"
+
+func TestQuickReplyKeyboard(t *testing.T) {
+	if quickReplyKeyboard(nil) != nil {
+		t.Error("keyboard without replies should be nil")
+	}
+
+	keyboard := quickReplyKeyboard([]string{"Yes", "No", "Later"})
+	if rows := keyboard.InlineKeyboard; len(rows) != 2 || len(rows[0]) != 2 {
+		t.Fatalf("short replies rows = %v, want two to a row", rows)
+	}
+	if data := keyboard.InlineKeyboard[1][0].CallbackData; data != "qr:Later" {
+		t.Errorf("callback data = %q", data)
+	}
+
+	keyboard = quickReplyKeyboard([]string{"Yes", "Send it to the accountant first"})
+	if rows := keyboard.InlineKeyboard; len(rows) != 2 {
+		t.Errorf("long replies rows = %v, want one to a row", rows)
+	}
+}
@@ -0,0 +1,149 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/voice"
+)
+
+const (
+	VoiceTriggerWakeWord   = "wake_word"
+	VoiceTriggerPushToTalk = "push_to_talk"
+	VoiceTriggerBoth       = "both"
+
+	// voiceSenderID is who speaks to the device; there is a single chat.
+	voiceSenderID   = "local"
+	voiceRestartGap = 5 * time.Second
+)
+
+// VoiceChannel talks with whoever is in the room: it listens on the device's
+// microphone for the wake word or the push-to-talk key, transcribes what is
+// said next and speaks the reply.
+type VoiceChannel struct {
+	*BaseChannel
+	config      config.VoiceConfig
+	workspace   string
+	transcriber voice.Transcriber
+	frontend    *voice.Frontend
+	cancel      context.CancelFunc
+	speakMu     sync.Mutex
+}
+
+func NewVoiceChannel(cfg config.VoiceConfig, workspace string, bus *bus.MessageBus) (*VoiceChannel, error) {
+	switch cfg.Trigger {
+	case "", VoiceTriggerWakeWord, VoiceTriggerPushToTalk, VoiceTriggerBoth:
+	default:
+		return nil, fmt.Errorf("unknown voice trigger %q (valid: wake_word, push_to_talk, both)", cfg.Trigger)
+	}
+	if cfg.Trigger != "" && cfg.Trigger != VoiceTriggerWakeWord && cfg.PushToTalkDevice == "" {
+		return nil, errors.New("push to talk needs push_to_talk_device")
+	}
+	base := NewBaseChannel("voice", cfg, bus, nil)
+
+	return &VoiceChannel{
+		BaseChannel: base,
+		config:      cfg,
+		workspace:   workspace,
+	}, nil
+}
+
+// SetTranscriber sets the speech recogniser; the channel can't start without
+// one.
+func (c *VoiceChannel) SetTranscriber(transcriber voice.Transcriber) {
+	c.transcriber = transcriber
+}
+
+func (c *VoiceChannel) Start(ctx context.Context) error {
+	logger.InfoC("voice", "Starting voice channel")
+
+	if c.transcriber == nil {
+		return errors.New("voice channel needs transcription (set a Groq API key)")
+	}
+	opts := voice.FrontendOptions{
+		CaptureCommand: c.config.CaptureCommand,
+		Transcriber:    c.transcriber,
+		Silence:        time.Duration(c.config.SilenceMS) * time.Millisecond,
+		MaxUtterance:   time.Duration(c.config.MaxSeconds) * time.Second,
+		OnWake: func() {
+			logger.DebugC("voice", "Listening")
+		},
+		OnText: func(text string) {
+			logger.InfoCF("voice", "Heard request", map[string]any{"text": text})
+			c.HandleMessage(voiceSenderID, voiceSenderID, text, nil, map[string]string{"source": "voice"})
+		},
+	}
+	if c.config.Trigger != VoiceTriggerPushToTalk {
+		wakeWord, err := voice.LoadWakeWord(voice.WakeWordDir(c.workspace), c.config.WakeWordThreshold)
+		if err != nil {
+			return err
+		}
+		opts.WakeWord = wakeWord
+	}
+	if c.config.Trigger == VoiceTriggerPushToTalk || c.config.Trigger == VoiceTriggerBoth {
+		opts.PushToTalkDevice = c.config.PushToTalkDevice
+		opts.PushToTalkKey = uint16(c.config.PushToTalkKey)
+	}
+	frontend, err := voice.NewFrontend(opts)
+	if err != nil {
+		return err
+	}
+	c.frontend = frontend
+
+	runCtx, cancel := context.WithCancel(ctx)
+	c.cancel = cancel
+	c.setRunning(true)
+	go c.run(runCtx)
+
+	logger.InfoC("voice", "Voice channel listening")
+	return nil
+}
+
+// run keeps the frontend going, restarting audio capture when it fails, for
+// example while a USB microphone is replugged.
+func (c *VoiceChannel) run(ctx context.Context) {
+	for {
+		err := c.frontend.Run(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		logger.ErrorCF("voice", "Voice frontend stopped, restarting", map[string]any{"error": err.Error()})
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(voiceRestartGap):
+		}
+	}
+}
+
+func (c *VoiceChannel) Stop(ctx context.Context) error {
+	logger.InfoC("voice", "Stopping voice channel")
+
+	if c.cancel != nil {
+		c.cancel()
+	}
+
+	c.setRunning(false)
+	logger.InfoC("voice", "Voice channel stopped")
+	return nil
+}
+
+// Send speaks msg, with the microphone muted so that the device doesn't
+// answer itself.
+func (c *VoiceChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	if !c.IsRunning() {
+		return fmt.Errorf("voice channel not running")
+	}
+
+	c.speakMu.Lock()
+	defer c.speakMu.Unlock()
+	c.frontend.Mute(true)
+	defer c.frontend.Mute(false)
+	return voice.Speak(ctx, c.config.SpeakCommand, msg.Content)
+}
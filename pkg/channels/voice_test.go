@@ -0,0 +1,44 @@
+package channels
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/voice"
+)
+
+type nopTranscriber struct{}
+
+func (nopTranscriber) Transcribe(ctx context.Context, path string) (*voice.TranscriptionResponse, error) {
+	return &voice.TranscriptionResponse{}, nil
+}
+
+func TestNewVoiceChannelValidatesTrigger(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+
+	_, err := NewVoiceChannel(config.VoiceConfig{Trigger: "clap"}, t.TempDir(), msgBus)
+	assert.Error(t, err)
+
+	_, err = NewVoiceChannel(config.VoiceConfig{Trigger: VoiceTriggerPushToTalk}, t.TempDir(), msgBus)
+	assert.Error(t, err, "push to talk needs a device")
+
+	ch, err := NewVoiceChannel(config.VoiceConfig{Trigger: VoiceTriggerWakeWord}, t.TempDir(), msgBus)
+	require.NoError(t, err)
+	assert.Equal(t, "voice", ch.Name())
+}
+
+func TestVoiceChannelStartNeedsSetup(t *testing.T) {
+	ch, err := NewVoiceChannel(config.VoiceConfig{Trigger: VoiceTriggerWakeWord}, t.TempDir(), bus.NewMessageBus())
+	require.NoError(t, err)
+
+	assert.ErrorContains(t, ch.Start(context.Background()), "transcription")
+
+	ch.SetTranscriber(nopTranscriber{})
+	assert.ErrorContains(t, ch.Start(context.Background()), "picoclaw voice enroll")
+	assert.False(t, ch.IsRunning())
+}
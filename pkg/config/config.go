@@ -6,8 +6,11 @@ import (
 	"os"
 	"path/filepath"
 	"sync/atomic"
+	"time"
 
 	"github.com/caarlos0/env/v11"
+
+	"github.com/sipeed/picoclaw/pkg/logrotate"
 )
 
 // rrCounter is a global counter for round-robin load balancing across models.
@@ -47,16 +50,53 @@ func (f *FlexibleStringSlice) UnmarshalJSON(data []byte) error {
 }
 
 type Config struct {
-	Agents    AgentsConfig    `json:"agents"`
-	Bindings  []AgentBinding  `json:"bindings,omitempty"`
-	Session   SessionConfig   `json:"session,omitempty"`
-	Channels  ChannelsConfig  `json:"channels"`
-	Providers ProvidersConfig `json:"providers,omitempty"`
-	ModelList []ModelConfig   `json:"model_list"` // New model-centric provider configuration
-	Gateway   GatewayConfig   `json:"gateway"`
-	Tools     ToolsConfig     `json:"tools"`
-	Heartbeat HeartbeatConfig `json:"heartbeat"`
-	Devices   DevicesConfig   `json:"devices"`
+	Profile        string               `json:"profile,omitempty"         env:"PICOCLAW_PROFILE"`         // e.g. "low_memory" or "low_memory,offline"
+	MemoryLimitMB  int                  `json:"memory_limit_mb,omitempty" env:"PICOCLAW_MEMORY_LIMIT_MB"` // soft Go heap limit, like GOMEMLIMIT
+	Agents         AgentsConfig         `json:"agents"`
+	Bindings       []AgentBinding       `json:"bindings,omitempty"`
+	Session        SessionConfig        `json:"session,omitempty"`
+	Channels       ChannelsConfig       `json:"channels"`
+	Providers      ProvidersConfig      `json:"providers,omitempty"`
+	ModelList      []ModelConfig        `json:"model_list"` // New model-centric provider configuration
+	Gateway        GatewayConfig        `json:"gateway"`
+	Tools          ToolsConfig          `json:"tools"`
+	Heartbeat      HeartbeatConfig      `json:"heartbeat"`
+	Devices        DevicesConfig        `json:"devices"`
+	Logging        LoggingConfig        `json:"logging"`
+	Telemetry      TelemetryConfig      `json:"telemetry"`
+	WireLog        WireLogConfig        `json:"wire_log"`
+	ErrorReporting ErrorReportingConfig `json:"error_reporting"`
+	Digest         DigestConfig         `json:"digest"`
+	Budget         BudgetConfig         `json:"budget"`
+	Alerts         AlertsConfig         `json:"alerts"`
+	Notifications  NotificationsConfig  `json:"notifications"`
+	InputFilter    InputFilterConfig    `json:"input_filter"`
+	Workflows      WorkflowsConfig      `json:"workflows"`
+	Hardware       HardwareConfig       `json:"hardware"`
+	Resources      ResourcesConfig      `json:"resources"`
+	Media          MediaConfig          `json:"media"`
+	Storage        StorageConfig        `json:"storage"`
+	Snapshots      SnapshotsConfig      `json:"snapshots"`
+	Transcripts    TranscriptsConfig    `json:"transcripts"`
+	Documents      DocumentsConfig      `json:"documents"`
+	Artifacts      ArtifactsConfig      `json:"artifacts"`
+	EventLog       EventLogConfig       `json:"event_log"`
+	LLMCache       LLMCacheConfig       `json:"llm_cache"`
+	Experiment     ExperimentConfig     `json:"experiment"`
+	Network        NetworkConfig        `json:"network"`
+	Update         UpdateConfig         `json:"update"`
+	LedgerForge    LedgerForgeConfig    `json:"ledgerforge"`
+	Receipts       ReceiptsConfig       `json:"receipts"`
+	Locale         LocaleConfig         `json:"locale"`
+	Bus            BusConfig            `json:"bus"`
+	NATS           NATSConfig           `json:"nats"`
+	Subscriptions  SubscriptionsConfig  `json:"subscriptions"`
+	MCP            MCPConfig            `json:"mcp"`
+	Federation     FederationConfig     `json:"federation"`
+	Provision      ProvisionConfig      `json:"provision"`
+	MDNS           MDNSConfig           `json:"mdns"`
+	PortMapping    PortMappingConfig    `json:"port_mapping"`
+	Tunnel         TunnelConfig         `json:"tunnel"`
 }
 
 // MarshalJSON implements custom JSON marshaling for Config
@@ -77,7 +117,7 @@ func (c Config) MarshalJSON() ([]byte, error) {
 	}
 
 	// Only include session if not empty
-	if c.Session.DMScope != "" || len(c.Session.IdentityLinks) > 0 {
+	if c.Session.DMScope != "" || len(c.Session.IdentityLinks) > 0 || c.Session.Handoff != "" {
 		aux.Session = &c.Session
 	}
 
@@ -161,9 +201,15 @@ type AgentBinding struct {
 	Match   BindingMatch `json:"match"`
 }
 
+// SessionConfig decides which conversations share a session. Handoff lets
+// a chat user and an API user (JWT subject) link their accounts with /link:
+// "unify" gives them one session across channels, "link" keeps a session per
+// channel but tells the agent what was said on the other one, and "off" (the
+// default) turns /link off.
 type SessionConfig struct {
 	DMScope       string              `json:"dm_scope,omitempty"`
 	IdentityLinks map[string][]string `json:"identity_links,omitempty"`
+	Handoff       string              `json:"handoff,omitempty"        env:"PICOCLAW_SESSION_HANDOFF"`
 }
 
 type AgentDefaults struct {
@@ -177,6 +223,9 @@ type AgentDefaults struct {
 	MaxTokens           int      `json:"max_tokens"                      env:"PICOCLAW_AGENTS_DEFAULTS_MAX_TOKENS"`
 	Temperature         *float64 `json:"temperature,omitempty"           env:"PICOCLAW_AGENTS_DEFAULTS_TEMPERATURE"`
 	MaxToolIterations   int      `json:"max_tool_iterations"             env:"PICOCLAW_AGENTS_DEFAULTS_MAX_TOOL_ITERATIONS"`
+	MaxHistoryMessages  int      `json:"max_history_messages"            env:"PICOCLAW_AGENTS_DEFAULTS_MAX_HISTORY_MESSAGES"` // summarize beyond this
+	MaxParallelTools    int      `json:"max_parallel_tools"              env:"PICOCLAW_AGENTS_DEFAULTS_MAX_PARALLEL_TOOLS"`   // tool calls of one response run at once
+	TokenizerFile       string   `json:"tokenizer_file,omitempty"        env:"PICOCLAW_AGENTS_DEFAULTS_TOKENIZER_FILE"`       // tiktoken vocabulary for exact counts
 }
 
 type ChannelsConfig struct {
@@ -192,6 +241,7 @@ type ChannelsConfig struct {
 	OneBot   OneBotConfig   `json:"onebot"`
 	WeCom    WeComConfig    `json:"wecom"`
 	WeComApp WeComAppConfig `json:"wecom_app"`
+	Voice    VoiceConfig    `json:"voice"`
 }
 
 type WhatsAppConfig struct {
@@ -296,9 +346,604 @@ type WeComAppConfig struct {
 	ReplyTimeout   int                 `json:"reply_timeout"    env:"PICOCLAW_CHANNELS_WECOM_APP_REPLY_TIMEOUT"`
 }
 
+// VoiceConfig is the local microphone and speaker channel. The wake word is
+// recorded with picoclaw voice enroll; replies are spoken by SpeakCommand.
+type VoiceConfig struct {
+	Enabled           bool    `json:"enabled"             env:"PICOCLAW_CHANNELS_VOICE_ENABLED"`
+	Trigger           string  `json:"trigger"             env:"PICOCLAW_CHANNELS_VOICE_TRIGGER"` // wake_word, push_to_talk or both
+	CaptureCommand    string  `json:"capture_command"     env:"PICOCLAW_CHANNELS_VOICE_CAPTURE_COMMAND"`
+	SpeakCommand      string  `json:"speak_command"       env:"PICOCLAW_CHANNELS_VOICE_SPEAK_COMMAND"`
+	WakeWordThreshold float64 `json:"wake_word_threshold" env:"PICOCLAW_CHANNELS_VOICE_WAKE_WORD_THRESHOLD"` // 0: from the recordings
+	PushToTalkDevice  string  `json:"push_to_talk_device" env:"PICOCLAW_CHANNELS_VOICE_PUSH_TO_TALK_DEVICE"`
+	PushToTalkKey     int     `json:"push_to_talk_key"    env:"PICOCLAW_CHANNELS_VOICE_PUSH_TO_TALK_KEY"` // 0: any key
+	SilenceMS         int     `json:"silence_ms"          env:"PICOCLAW_CHANNELS_VOICE_SILENCE_MS"`
+	MaxSeconds        int     `json:"max_seconds"         env:"PICOCLAW_CHANNELS_VOICE_MAX_SECONDS"`
+}
+
 type HeartbeatConfig struct {
 	Enabled  bool `json:"enabled"  env:"PICOCLAW_HEARTBEAT_ENABLED"`
 	Interval int  `json:"interval" env:"PICOCLAW_HEARTBEAT_INTERVAL"` // minutes, min 5
+
+	// Businesses overrides the schedule per business ID. Businesses not
+	// listed run every Interval minutes with the HEARTBEAT.md tasks.
+	Businesses map[string]BusinessHeartbeatConfig `json:"businesses,omitempty"`
+
+	// Fallbacks are chats tried in order when a business's heartbeat report
+	// cannot be delivered to the chat its users last wrote from.
+	Fallbacks []HeartbeatTarget `json:"fallbacks,omitempty"`
+}
+
+// HeartbeatTarget is a chat heartbeat reports can be delivered to.
+type HeartbeatTarget struct {
+	Channel string `json:"channel"`
+	ChatID  string `json:"chat_id"`
+}
+
+// BusinessHeartbeatConfig is one business's heartbeat schedule. Chat users
+// can also turn their business's heartbeat on and off with /heartbeat.
+type BusinessHeartbeatConfig struct {
+	Enabled  *bool  `json:"enabled,omitempty"`
+	Interval int    `json:"interval,omitempty"` // minutes, min 5; 0 uses the global interval
+	Window   string `json:"window,omitempty"`   // e.g. "Mon-Fri 09:00-17:00"
+	Timezone string `json:"timezone,omitempty"` // IANA name for Window; empty follows locale
+	Prompt   string `json:"prompt,omitempty"`   // replaces the HEARTBEAT.md tasks
+	Skill    string `json:"skill,omitempty"`    // skill the heartbeat should use
+}
+
+// DigestConfig schedules the daily usage digest. Without a channel and chat
+// ID it goes to the last chat the agent talked to.
+type DigestConfig struct {
+	Enabled bool                  `json:"enabled"           env:"PICOCLAW_DIGEST_ENABLED"`
+	Time    string                `json:"time"              env:"PICOCLAW_DIGEST_TIME"` // HH:MM, local time
+	Channel string                `json:"channel,omitempty" env:"PICOCLAW_DIGEST_CHANNEL"`
+	ChatID  string                `json:"chat_id,omitempty" env:"PICOCLAW_DIGEST_CHAT_ID"`
+	Prices  map[string]TokenPrice `json:"prices,omitempty"` // by model
+}
+
+// BudgetConfig caps what LLM calls cost per day and per month, in USD at
+// the prices in digest.prices, for all traffic and for each business in
+// Businesses. A zero limit is no budget. Past WarnPercent of a budget the
+// owner is told (in Channel/ChatID, or the last active chat); past the
+// budget requests use FallbackModel, a model_name in model_list, and past
+// StopPercent they are refused until the budget resets. A zero StopPercent
+// never refuses requests.
+type BudgetConfig struct {
+	Enabled       bool                      `json:"enabled"              env:"PICOCLAW_BUDGET_ENABLED"`
+	DailyUSD      float64                   `json:"daily_usd"            env:"PICOCLAW_BUDGET_DAILY_USD"`
+	MonthlyUSD    float64                   `json:"monthly_usd"          env:"PICOCLAW_BUDGET_MONTHLY_USD"`
+	WarnPercent   float64                   `json:"warn_percent"         env:"PICOCLAW_BUDGET_WARN_PERCENT"`
+	StopPercent   float64                   `json:"stop_percent"         env:"PICOCLAW_BUDGET_STOP_PERCENT"`
+	FallbackModel string                    `json:"fallback_model"       env:"PICOCLAW_BUDGET_FALLBACK_MODEL"`
+	Channel       string                    `json:"channel,omitempty"    env:"PICOCLAW_BUDGET_CHANNEL"`
+	ChatID        string                    `json:"chat_id,omitempty"    env:"PICOCLAW_BUDGET_CHAT_ID"`
+	Businesses    map[string]BusinessBudget `json:"businesses,omitempty"`
+}
+
+// BusinessBudget is the budget of one business, in USD.
+type BusinessBudget struct {
+	DailyUSD   float64 `json:"daily_usd"`
+	MonthlyUSD float64 `json:"monthly_usd"`
+}
+
+// NotificationsConfig batches the messages the agent sends on its own, such
+// as skill events, cron deliveries and heartbeat results. Those that reach a
+// chat within BatchSeconds of the first are sent together as one message.
+type NotificationsConfig struct {
+	Enabled      bool `json:"enabled"       env:"PICOCLAW_NOTIFICATIONS_ENABLED"`
+	BatchSeconds int  `json:"batch_seconds" env:"PICOCLAW_NOTIFICATIONS_BATCH_SECONDS"`
+}
+
+// InputFilterConfig screens messages from group chats before they reach the
+// model. A message matching a word or pattern has the match stripped, or is
+// refused, according to Action; Channels overrides Action per channel with
+// "strip", "refuse" or "off".
+type InputFilterConfig struct {
+	Enabled  bool              `json:"enabled"            env:"PICOCLAW_INPUT_FILTER_ENABLED"`
+	Action   string            `json:"action"             env:"PICOCLAW_INPUT_FILTER_ACTION"`
+	Words    []string          `json:"words,omitempty"`
+	Patterns []string          `json:"patterns,omitempty"`
+	Channels map[string]string `json:"channels,omitempty"`
+}
+
+// WorkflowsConfig turns on the workflow engine, which runs the YAML
+// workflows under <workspace>/workflows on their events and schedules.
+type WorkflowsConfig struct {
+	Enabled bool `json:"enabled" env:"PICOCLAW_WORKFLOWS_ENABLED"`
+}
+
+// AlertsConfig sets the failure alert rules. A zero threshold disables its
+// rule. Alerts go to the channel and chat (falling back to the last active
+// chat) and, if set, are also posted to WebhookURL.
+type AlertsConfig struct {
+	Enabled             bool    `json:"enabled"               env:"PICOCLAW_ALERTS_ENABLED"`
+	WebhookFailures     int     `json:"webhook_failures"      env:"PICOCLAW_ALERTS_WEBHOOK_FAILURES"`
+	ProviderDownMinutes int     `json:"provider_down_minutes" env:"PICOCLAW_ALERTS_PROVIDER_DOWN_MINUTES"`
+	DiskUsagePercent    float64 `json:"disk_usage_percent"    env:"PICOCLAW_ALERTS_DISK_USAGE_PERCENT"`
+	CooldownMinutes     int     `json:"cooldown_minutes"      env:"PICOCLAW_ALERTS_COOLDOWN_MINUTES"`
+	Channel             string  `json:"channel,omitempty"     env:"PICOCLAW_ALERTS_CHANNEL"`
+	ChatID              string  `json:"chat_id,omitempty"     env:"PICOCLAW_ALERTS_CHAT_ID"`
+	WebhookURL          string  `json:"webhook_url,omitempty" env:"PICOCLAW_ALERTS_WEBHOOK_URL"`
+}
+
+// HardwareConfig sets the board health checks, which read CPU temperature,
+// throttling, undervoltage and eMMC wear from sysfs where the board exposes
+// them. A failing check marks the gateway degraded in /ready without taking it
+// out of service, and raises an alert when alerts are enabled. A zero
+// threshold disables its check.
+type HardwareConfig struct {
+	Enabled         bool    `json:"enabled"           env:"PICOCLAW_HARDWARE_ENABLED"`
+	MaxTemperatureC float64 `json:"max_temperature_c" env:"PICOCLAW_HARDWARE_MAX_TEMPERATURE_C"`
+}
+
+// ResourcesConfig sets the disk and memory checks, which mark the gateway
+// degraded in /ready while there is still room to clean up: free space on
+// the workspace filesystem, and the process's resident memory against its
+// limit (GOMEMLIMIT or memory_limit_mb, else the cgroup's or the device's
+// memory). A zero threshold disables its check.
+type ResourcesConfig struct {
+	MinDiskFreePercent float64 `json:"min_disk_free_percent" env:"PICOCLAW_RESOURCES_MIN_DISK_FREE_PERCENT"`
+	MinDiskFreeMB      int64   `json:"min_disk_free_mb"      env:"PICOCLAW_RESOURCES_MIN_DISK_FREE_MB"`
+	MaxMemoryPercent   float64 `json:"max_memory_percent"    env:"PICOCLAW_RESOURCES_MAX_MEMORY_PERCENT"`
+}
+
+// TokenPrice is the USD cost per million tokens, used to estimate spend.
+type TokenPrice struct {
+	Prompt     float64 `json:"prompt"`
+	Completion float64 `json:"completion"`
+}
+
+// LoggingConfig controls log verbosity and output format. Modules maps a log
+// component (e.g. "agent", "channels", "cron") to a level that overrides Level.
+type LoggingConfig struct {
+	Level    string            `json:"level"             env:"PICOCLAW_LOGGING_LEVEL"`
+	Format   string            `json:"format"            env:"PICOCLAW_LOGGING_FORMAT"` // text or json
+	Modules  map[string]string `json:"modules,omitempty"`
+	File     string            `json:"file,omitempty"    env:"PICOCLAW_LOGGING_FILE"` // relative paths are under the workspace
+	Rotation LogRotationConfig `json:"rotation"`
+}
+
+// LogRotationConfig bounds the disk used by log files written to the
+// workspace (log file, skill audit log, wire log, event log).
+type LogRotationConfig struct {
+	MaxSizeMB           int  `json:"max_size_mb"           env:"PICOCLAW_LOGGING_ROTATION_MAX_SIZE_MB"`
+	RotateIntervalHours int  `json:"rotate_interval_hours" env:"PICOCLAW_LOGGING_ROTATION_ROTATE_INTERVAL_HOURS"`
+	MaxBackups          int  `json:"max_backups"           env:"PICOCLAW_LOGGING_ROTATION_MAX_BACKUPS"`
+	MaxAgeDays          int  `json:"max_age_days"          env:"PICOCLAW_LOGGING_ROTATION_MAX_AGE_DAYS"`
+	Compress            bool `json:"compress"              env:"PICOCLAW_LOGGING_ROTATION_COMPRESS"`
+}
+
+// Options converts the config to logrotate options.
+func (c LogRotationConfig) Options() logrotate.Options {
+	return logrotate.Options{
+		MaxBytes:   int64(c.MaxSizeMB) << 20,
+		Interval:   time.Duration(c.RotateIntervalHours) * time.Hour,
+		MaxBackups: c.MaxBackups,
+		MaxAge:     time.Duration(c.MaxAgeDays) * 24 * time.Hour,
+		Compress:   c.Compress,
+	}
+}
+
+// TelemetryConfig configures OTLP/HTTP export of gateway metrics and traces.
+type TelemetryConfig struct {
+	Enabled               bool              `json:"enabled"                       env:"PICOCLAW_TELEMETRY_ENABLED"`
+	Endpoint              string            `json:"endpoint"                      env:"PICOCLAW_TELEMETRY_ENDPOINT"` // e.g. http://collector:4318
+	Headers               map[string]string `json:"headers,omitempty"`
+	SampleRatio           float64           `json:"sample_ratio"                  env:"PICOCLAW_TELEMETRY_SAMPLE_RATIO"`
+	ExportIntervalSeconds int               `json:"export_interval_seconds"       env:"PICOCLAW_TELEMETRY_EXPORT_INTERVAL_SECONDS"`
+	ServiceName           string            `json:"service_name,omitempty"        env:"PICOCLAW_TELEMETRY_SERVICE_NAME"`
+	ResourceAttributes    map[string]string `json:"resource_attributes,omitempty"`
+}
+
+// WireLogConfig configures the on-disk log of webhook requests, chat messages
+// and agent responses. Redaction lists extend the built-in defaults.
+type WireLogConfig struct {
+	Enabled        bool     `json:"enabled"                   env:"PICOCLAW_WIRE_LOG_ENABLED"`
+	MaxFileSizeKB  int      `json:"max_file_size_kb"          env:"PICOCLAW_WIRE_LOG_MAX_FILE_SIZE_KB"`
+	MaxFiles       int      `json:"max_files"                 env:"PICOCLAW_WIRE_LOG_MAX_FILES"`
+	MaxBodyBytes   int      `json:"max_body_bytes"            env:"PICOCLAW_WIRE_LOG_MAX_BODY_BYTES"`
+	RedactHeaders  []string `json:"redact_headers,omitempty"`
+	RedactPatterns []string `json:"redact_patterns,omitempty"`
+}
+
+// MediaConfig sets the retention policy for uploaded files in
+// <workspace>/media. A zero limit disables it. With Encrypt, uploads are
+// stored encrypted with the key in <workspace>/state/media.key.
+type MediaConfig struct {
+	MaxAgeDays             int  `json:"max_age_days"             env:"PICOCLAW_MEDIA_MAX_AGE_DAYS"`
+	MaxSizeMB              int  `json:"max_size_mb"              env:"PICOCLAW_MEDIA_MAX_SIZE_MB"`
+	CleanupIntervalMinutes int  `json:"cleanup_interval_minutes" env:"PICOCLAW_MEDIA_CLEANUP_INTERVAL_MINUTES"`
+	Encrypt                bool `json:"encrypt"                  env:"PICOCLAW_MEDIA_ENCRYPT"`
+}
+
+// StorageConfig selects where uploads are kept. With backend "s3" every
+// upload is also stored in the bucket and clients get presigned download URLs.
+// Uploads that would exceed a quota are rejected; a zero quota disables it.
+type StorageConfig struct {
+	Backend          string                `json:"backend"            env:"PICOCLAW_STORAGE_BACKEND"` // "local" or "s3"
+	PresignMinutes   int                   `json:"presign_minutes"    env:"PICOCLAW_STORAGE_PRESIGN_MINUTES"`
+	WorkspaceQuotaMB int                   `json:"workspace_quota_mb" env:"PICOCLAW_STORAGE_WORKSPACE_QUOTA_MB"`
+	BusinessQuotaMB  int                   `json:"business_quota_mb"  env:"PICOCLAW_STORAGE_BUSINESS_QUOTA_MB"`
+	S3               S3StorageConfig       `json:"s3"`
+	Postgres         PostgresStorageConfig `json:"postgres"`
+}
+
+// S3StorageConfig configures an S3-compatible bucket (AWS S3, MinIO).
+type S3StorageConfig struct {
+	Endpoint        string `json:"endpoint"          env:"PICOCLAW_STORAGE_S3_ENDPOINT"`
+	Region          string `json:"region"            env:"PICOCLAW_STORAGE_S3_REGION"`
+	Bucket          string `json:"bucket"            env:"PICOCLAW_STORAGE_S3_BUCKET"`
+	AccessKeyID     string `json:"access_key_id"     env:"PICOCLAW_STORAGE_S3_ACCESS_KEY_ID"`
+	SecretAccessKey string `json:"secret_access_key" env:"PICOCLAW_STORAGE_S3_SECRET_ACCESS_KEY"`
+	PathStyle       bool   `json:"path_style"        env:"PICOCLAW_STORAGE_S3_PATH_STYLE"`
+	Prefix          string `json:"prefix,omitempty"  env:"PICOCLAW_STORAGE_S3_PREFIX"`
+}
+
+// SnapshotsConfig uploads an encrypted backup of config, state, sessions
+// and the SQLite databases to Target ("s3" or "webdav") every day at Time,
+// keeping the newest Keep. Passphrase encrypts the whole archive; restore
+// one with picoclaw workspace import and the same passphrase.
+type SnapshotsConfig struct {
+	Enabled    bool                 `json:"enabled"    env:"PICOCLAW_SNAPSHOTS_ENABLED"`
+	Time       string               `json:"time"       env:"PICOCLAW_SNAPSHOTS_TIME"` // "HH:MM", local time
+	Passphrase string               `json:"passphrase" env:"PICOCLAW_SNAPSHOTS_PASSPHRASE"`
+	Keep       int                  `json:"keep"       env:"PICOCLAW_SNAPSHOTS_KEEP"`
+	Media      bool                 `json:"media"      env:"PICOCLAW_SNAPSHOTS_MEDIA"`
+	Target     string               `json:"target"     env:"PICOCLAW_SNAPSHOTS_TARGET"` // "s3" or "webdav"
+	S3         SnapshotS3Config     `json:"s3"`
+	WebDAV     SnapshotWebDAVConfig `json:"webdav"`
+}
+
+// SnapshotS3Config is the bucket snapshots go to, under "snapshots/" below
+// Prefix.
+type SnapshotS3Config struct {
+	Endpoint        string `json:"endpoint"          env:"PICOCLAW_SNAPSHOTS_S3_ENDPOINT"`
+	Region          string `json:"region"            env:"PICOCLAW_SNAPSHOTS_S3_REGION"`
+	Bucket          string `json:"bucket"            env:"PICOCLAW_SNAPSHOTS_S3_BUCKET"`
+	AccessKeyID     string `json:"access_key_id"     env:"PICOCLAW_SNAPSHOTS_S3_ACCESS_KEY_ID"`
+	SecretAccessKey string `json:"secret_access_key" env:"PICOCLAW_SNAPSHOTS_S3_SECRET_ACCESS_KEY"`
+	PathStyle       bool   `json:"path_style"        env:"PICOCLAW_SNAPSHOTS_S3_PATH_STYLE"`
+	Prefix          string `json:"prefix,omitempty"  env:"PICOCLAW_SNAPSHOTS_S3_PREFIX"`
+}
+
+// SnapshotWebDAVConfig is the WebDAV collection snapshots go to, such as a
+// Nextcloud folder.
+type SnapshotWebDAVConfig struct {
+	URL      string `json:"url"      env:"PICOCLAW_SNAPSHOTS_WEBDAV_URL"`
+	Username string `json:"username" env:"PICOCLAW_SNAPSHOTS_WEBDAV_USERNAME"`
+	Password string `json:"password" env:"PICOCLAW_SNAPSHOTS_WEBDAV_PASSWORD"`
+}
+
+// PostgresStorageConfig moves sessions and state from the workspace to a
+// Postgres database when DSN is set. Namespace separates deployments that
+// share a database.
+//
+// With LeaderElection, gateways sharing a namespace elect a leader that
+// alone runs heartbeat and cron jobs; InstanceID defaults to the hostname
+// and process ID.
+type PostgresStorageConfig struct {
+	DSN            string `json:"dsn"                   env:"PICOCLAW_STORAGE_POSTGRES_DSN"`
+	Namespace      string `json:"namespace"             env:"PICOCLAW_STORAGE_POSTGRES_NAMESPACE"`
+	LeaderElection bool   `json:"leader_election"       env:"PICOCLAW_STORAGE_POSTGRES_LEADER_ELECTION"`
+	LeaseSeconds   int    `json:"lease_seconds"         env:"PICOCLAW_STORAGE_POSTGRES_LEASE_SECONDS"`
+	InstanceID     string `json:"instance_id,omitempty" env:"PICOCLAW_STORAGE_POSTGRES_INSTANCE_ID"`
+}
+
+// TranscriptsConfig records every conversation turn in a searchable SQLite
+// database at <workspace>/state/transcripts.db.
+type TranscriptsConfig struct {
+	Enabled bool `json:"enabled" env:"PICOCLAW_TRANSCRIPTS_ENABLED"`
+}
+
+// DocumentsConfig turns on the document index. Documents added with
+// picoclaw index or POST /documents are split into chunks of about
+// ChunkSize characters, embedded with Model, a model_name in model_list
+// whose provider serves embeddings, and searched by the search_documents
+// tool.
+type DocumentsConfig struct {
+	Enabled      bool   `json:"enabled"       env:"PICOCLAW_DOCUMENTS_ENABLED"`
+	Model        string `json:"model"         env:"PICOCLAW_DOCUMENTS_MODEL"`
+	ChunkSize    int    `json:"chunk_size"    env:"PICOCLAW_DOCUMENTS_CHUNK_SIZE"`
+	ChunkOverlap int    `json:"chunk_overlap" env:"PICOCLAW_DOCUMENTS_CHUNK_OVERLAP"`
+}
+
+// ArtifactsConfig sets how long files registered by skills stay available.
+// A zero TTL keeps them until removed.
+type ArtifactsConfig struct {
+	TTLHours               int `json:"ttl_hours"                env:"PICOCLAW_ARTIFACTS_TTL_HOURS"`
+	CleanupIntervalMinutes int `json:"cleanup_interval_minutes" env:"PICOCLAW_ARTIFACTS_CLEANUP_INTERVAL_MINUTES"`
+}
+
+// BusConfig shares the message bus between gateways behind a load balancer.
+// With a Redis URL, inbound channel messages are handled by whichever
+// gateway takes them first, and replies are delivered by a gateway running
+// their channel. Prefix namespaces the Redis keys of a deployment.
+type BusConfig struct {
+	RedisURL string `json:"redis_url"        env:"PICOCLAW_BUS_REDIS_URL"`
+	Prefix   string `json:"prefix,omitempty" env:"PICOCLAW_BUS_PREFIX"`
+}
+
+// NATSConfig answers agent requests arriving on Subject of the NATS server
+// at URL. Gateways in the same QueueGroup share the requests; with an empty
+// group every gateway answers each one.
+type NATSConfig struct {
+	URL        string `json:"url"         env:"PICOCLAW_NATS_URL"`
+	Subject    string `json:"subject"     env:"PICOCLAW_NATS_SUBJECT"`
+	QueueGroup string `json:"queue_group" env:"PICOCLAW_NATS_QUEUE_GROUP"`
+}
+
+// SubscriptionsConfig posts events to URLs as they happen (outbound
+// webhooks). Deliveries are signed with Secret unless an endpoint has its
+// own, and are retried MaxRetries times before going to the dead-letter
+// file.
+type SubscriptionsConfig struct {
+	Secret     string               `json:"secret"      env:"PICOCLAW_SUBSCRIPTIONS_SECRET"`
+	MaxRetries int                  `json:"max_retries" env:"PICOCLAW_SUBSCRIPTIONS_MAX_RETRIES"`
+	Endpoints  []SubscriptionConfig `json:"endpoints"`
+}
+
+// SubscriptionConfig sends the listed event types ("*" for all) to URL.
+type SubscriptionConfig struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Secret string   `json:"secret,omitempty"`
+}
+
+// MCPConfig offers the default agent's tools and skills to Model Context
+// Protocol clients. picoclaw mcp serves them on stdio; Serve also serves
+// them at /mcp on the gateway. Tools limits the tools offered, all when
+// empty. The agent can in turn call the tools of Servers.
+type MCPConfig struct {
+	Serve   bool                `json:"serve"   env:"PICOCLAW_MCP_SERVE"`
+	Tools   FlexibleStringSlice `json:"tools"   env:"PICOCLAW_MCP_TOOLS"`
+	Servers []MCPServerConfig   `json:"servers"`
+}
+
+// MCPServerConfig is an external MCP server whose tools the agent can call,
+// as mcp_<name>_<tool>. The server is started with Command, Args and Env and
+// spoken to over stdio, or reached at URL with Headers over streamable HTTP.
+// Tools limits the tools registered, all when empty; those in Confirm ("*"
+// for all) only run with confirm: true, once the user has agreed. Calls time
+// out after TimeoutSeconds.
+type MCPServerConfig struct {
+	Name           string            `json:"name"`
+	Command        string            `json:"command,omitempty"`
+	Args           []string          `json:"args,omitempty"`
+	Env            map[string]string `json:"env,omitempty"`
+	URL            string            `json:"url,omitempty"`
+	Headers        map[string]string `json:"headers,omitempty"`
+	Tools          []string          `json:"tools,omitempty"`
+	Confirm        []string          `json:"confirm,omitempty"`
+	TimeoutSeconds int               `json:"timeout_seconds,omitempty"`
+}
+
+// FederationConfig delegates requests to other picoclaw instances, such as
+// heavy jobs from a home device to a server. Requests meeting a rule are
+// sent to the rule's peer, and its answer goes back on the original channel;
+// if the peer fails, the request is answered locally. Name identifies this
+// instance to its peers, the hostname when empty.
+type FederationConfig struct {
+	Name  string           `json:"name"  env:"PICOCLAW_FEDERATION_NAME"`
+	Peers []FederationPeer `json:"peers"`
+	Rules []FederationRule `json:"rules"`
+}
+
+// FederationPeer is an instance requests can be delegated to, at URL, with a
+// token paired with it. Delegated requests time out after TimeoutSeconds.
+type FederationPeer struct {
+	Name           string `json:"name"`
+	URL            string `json:"url"`
+	Token          string `json:"token"`
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty"`
+}
+
+// FederationRule delegates to Peer the requests that meet all the conditions
+// it sets: at least MinChars characters, attachments if Media, any of
+// Keywords (case-insensitive), and arriving on one of Channels. The first
+// matching rule applies.
+type FederationRule struct {
+	Peer     string   `json:"peer"`
+	MinChars int      `json:"min_chars,omitempty"`
+	Media    bool     `json:"media,omitempty"`
+	Keywords []string `json:"keywords,omitempty"`
+	Channels []string `json:"channels,omitempty"`
+}
+
+// ProvisionConfig keeps answering provisioning commands on serial Devices,
+// such as the board's console, a USB gadget port or a Bluetooth LE UART
+// bridge's TTY, so Wi-Fi and the config can be changed without a network.
+// A new config restarts the gateway. Networks are joined with nmcli, or added
+// to the wpa_supplicant config at WPASupplicant.
+type ProvisionConfig struct {
+	Devices       []string `json:"devices"        env:"PICOCLAW_PROVISION_DEVICES"`
+	Baud          int      `json:"baud"           env:"PICOCLAW_PROVISION_BAUD"`
+	WPASupplicant string   `json:"wpa_supplicant" env:"PICOCLAW_PROVISION_WPA_SUPPLICANT"`
+}
+
+// MDNSConfig advertises the gateway on the LAN as a _picoclaw._tcp service
+// over mDNS, with TXT records for the version and whether a pairing code is
+// open, so apps find devices without being given an address. Name is the
+// instance name shown to users; it defaults to "PicoClaw on <hostname>".
+type MDNSConfig struct {
+	Enabled bool   `json:"enabled" env:"PICOCLAW_MDNS_ENABLED"`
+	Name    string `json:"name"    env:"PICOCLAW_MDNS_NAME"`
+}
+
+// PortMappingConfig asks the home router to forward ExternalPort to the
+// gateway's port with NAT-PMP or UPnP, for remote access without manual port
+// forwarding. The mapping is leased for LifetimeSeconds and renewed. Zero
+// ExternalPort means the gateway's port.
+type PortMappingConfig struct {
+	Enabled         bool `json:"enabled"          env:"PICOCLAW_PORT_MAPPING_ENABLED"`
+	ExternalPort    int  `json:"external_port"    env:"PICOCLAW_PORT_MAPPING_EXTERNAL_PORT"`
+	LifetimeSeconds int  `json:"lifetime_seconds" env:"PICOCLAW_PORT_MAPPING_LIFETIME_SECONDS"`
+}
+
+// TunnelConfig makes the gateway reachable from anywhere over a tunnel the
+// system runs. Mode "tailscale" logs tailscaled in with AuthKey, as Hostname,
+// if it isn't logged in; mode "wireguard" brings up WireGuardConfig with
+// wg-quick if Interface (by default named after the config file) has no
+// address. BindOnly makes the gateway listen on the tunnel address alone.
+type TunnelConfig struct {
+	Mode            string `json:"mode"               env:"PICOCLAW_TUNNEL_MODE"`
+	Interface       string `json:"interface"          env:"PICOCLAW_TUNNEL_INTERFACE"`
+	AuthKey         string `json:"auth_key,omitempty" env:"PICOCLAW_TUNNEL_AUTH_KEY"`
+	Hostname        string `json:"hostname"           env:"PICOCLAW_TUNNEL_HOSTNAME"`
+	WireGuardConfig string `json:"wireguard_config"   env:"PICOCLAW_TUNNEL_WIREGUARD_CONFIG"`
+	BindOnly        bool   `json:"bind_only"          env:"PICOCLAW_TUNNEL_BIND_ONLY"`
+}
+
+// EventLogConfig records agent activity in <workspace>/events/events.jsonl,
+// rotated per logging.rotation. Sync fsyncs every event. RecordContent adds
+// the text of requests and responses, which replay needs.
+type EventLogConfig struct {
+	Enabled       bool        `json:"enabled"        env:"PICOCLAW_EVENT_LOG_ENABLED"`
+	Sync          bool        `json:"sync"           env:"PICOCLAW_EVENT_LOG_SYNC"`
+	RecordContent bool        `json:"record_content" env:"PICOCLAW_EVENT_LOG_RECORD_CONTENT"`
+	Kafka         KafkaConfig `json:"kafka"`
+}
+
+// KafkaConfig streams the event log to Topic on the Kafka cluster reached
+// through Brokers (host:port), checking for new events every
+// IntervalSeconds. Username and Password sign in with SASL/PLAIN.
+type KafkaConfig struct {
+	Brokers         FlexibleStringSlice `json:"brokers"          env:"PICOCLAW_EVENT_LOG_KAFKA_BROKERS"`
+	Topic           string              `json:"topic"            env:"PICOCLAW_EVENT_LOG_KAFKA_TOPIC"`
+	TLS             bool                `json:"tls"              env:"PICOCLAW_EVENT_LOG_KAFKA_TLS"`
+	Username        string              `json:"username"         env:"PICOCLAW_EVENT_LOG_KAFKA_USERNAME"`
+	Password        string              `json:"password"         env:"PICOCLAW_EVENT_LOG_KAFKA_PASSWORD"`
+	IntervalSeconds int                 `json:"interval_seconds" env:"PICOCLAW_EVENT_LOG_KAFKA_INTERVAL_SECONDS"`
+}
+
+// LLMCacheConfig replays model answers to repeated prompts, such as daily
+// heartbeat questions, for TTLMinutes instead of calling the provider again.
+type LLMCacheConfig struct {
+	Enabled    bool `json:"enabled"     env:"PICOCLAW_LLM_CACHE_ENABLED"`
+	TTLMinutes int  `json:"ttl_minutes" env:"PICOCLAW_LLM_CACHE_TTL_MINUTES"`
+	MaxEntries int  `json:"max_entries" env:"PICOCLAW_LLM_CACHE_MAX_ENTRIES"`
+}
+
+// ExperimentConfig tries an alternative model or system prompt on part of
+// the traffic. Sessions of the listed businesses and Percent of the others,
+// picked by session key so that a conversation keeps its variant, get Model
+// (a model_name in model_list; empty keeps the agent's) and Prompt appended
+// to the system prompt. Everyone else is "control"; the daily digest compares
+// the two.
+type ExperimentConfig struct {
+	Enabled    bool     `json:"enabled"              env:"PICOCLAW_EXPERIMENT_ENABLED"`
+	Name       string   `json:"name"                 env:"PICOCLAW_EXPERIMENT_NAME"`
+	Percent    float64  `json:"percent"              env:"PICOCLAW_EXPERIMENT_PERCENT"`
+	Businesses []string `json:"businesses,omitempty" env:"PICOCLAW_EXPERIMENT_BUSINESSES"`
+	Model      string   `json:"model,omitempty"      env:"PICOCLAW_EXPERIMENT_MODEL"`
+	Prompt     string   `json:"prompt,omitempty"     env:"PICOCLAW_EXPERIMENT_PROMPT"`
+}
+
+// NetworkConfig tunes the HTTP client shared by providers, channels and
+// tools. An empty Proxy (http://, https:// or socks5://) falls back to the
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables; a provider or
+// channel proxy overrides it. Offline refuses connections outside the local
+// network, whatever the proxy settings.
+type NetworkConfig struct {
+	Proxy                  string `json:"proxy,omitempty"           env:"PICOCLAW_NETWORK_PROXY"`
+	MaxConnsPerHost        int    `json:"max_conns_per_host"        env:"PICOCLAW_NETWORK_MAX_CONNS_PER_HOST"`
+	MaxIdleConnsPerHost    int    `json:"max_idle_conns_per_host"   env:"PICOCLAW_NETWORK_MAX_IDLE_CONNS_PER_HOST"`
+	IdleConnTimeoutSeconds int    `json:"idle_conn_timeout_seconds" env:"PICOCLAW_NETWORK_IDLE_CONN_TIMEOUT_SECONDS"`
+	Offline                bool   `json:"offline"                   env:"PICOCLAW_NETWORK_OFFLINE"`
+}
+
+// UpdateConfig points picoclaw update at a signed release manifest.
+// Releases are only installed if the manifest is signed with PublicKey, a
+// base64 Ed25519 key. With Auto set, the gateway checks every
+// CheckIntervalHours and restarts itself into a newer release.
+//
+// With Notify set, the gateway instead only watches FeedURL, a release feed
+// in the GitHub releases API format, as often, and tells the owner about a
+// newer release on Channel and ChatID (by default the last active chat).
+type UpdateConfig struct {
+	ManifestURL        string `json:"manifest_url,omitempty" env:"PICOCLAW_UPDATE_MANIFEST_URL"`
+	PublicKey          string `json:"public_key,omitempty"   env:"PICOCLAW_UPDATE_PUBLIC_KEY"`
+	Auto               bool   `json:"auto"                   env:"PICOCLAW_UPDATE_AUTO"`
+	CheckIntervalHours int    `json:"check_interval_hours"   env:"PICOCLAW_UPDATE_CHECK_INTERVAL_HOURS"`
+	Notify             bool   `json:"notify"                 env:"PICOCLAW_UPDATE_NOTIFY"`
+	FeedURL            string `json:"feed_url"               env:"PICOCLAW_UPDATE_FEED_URL"`
+	Channel            string `json:"channel,omitempty"      env:"PICOCLAW_UPDATE_CHANNEL"`
+	ChatID             string `json:"chat_id,omitempty"      env:"PICOCLAW_UPDATE_CHAT_ID"`
+}
+
+// LedgerForgeConfig points the built-in ledgerforge tool at the accounting
+// API. Calls act as the user of the request, so there are no credentials
+// here; with no BaseURL the tool is not offered. SigningSecret, shared with
+// LedgerForge, signs every request, and CallbackPath turns on the events
+// picoclaw posts about receipts and postings.
+type LedgerForgeConfig struct {
+	BaseURL        string `json:"base_url,omitempty"       env:"PICOCLAW_LEDGERFORGE_BASE_URL"`
+	TimeoutSeconds int    `json:"timeout_seconds"          env:"PICOCLAW_LEDGERFORGE_TIMEOUT_SECONDS"`
+	MaxRetries     int    `json:"max_retries"              env:"PICOCLAW_LEDGERFORGE_MAX_RETRIES"`
+	SigningSecret  string `json:"signing_secret,omitempty" env:"PICOCLAW_LEDGERFORGE_SIGNING_SECRET"`
+	CallbackPath   string `json:"callback_path,omitempty"  env:"PICOCLAW_LEDGERFORGE_CALLBACK_PATH"`
+}
+
+// ReceiptsConfig controls how receipts are turned into draft expenses.
+// Receipts whose vendor, total or date were read with a confidence below
+// ReviewThreshold (0 to 1) wait in a review queue until someone confirms or
+// corrects them; 0 creates every draft it can straight away. Rules
+// categorize the expenses of every business, and Businesses adds rules for
+// single businesses, checked first.
+type ReceiptsConfig struct {
+	ReviewThreshold float64                           `json:"review_threshold" env:"PICOCLAW_RECEIPTS_REVIEW_THRESHOLD"`
+	Rules           []CategoryRule                    `json:"rules,omitempty"`
+	Businesses      map[string]BusinessReceiptsConfig `json:"businesses,omitempty"`
+}
+
+// BusinessReceiptsConfig holds the receipt settings of one business.
+type BusinessReceiptsConfig struct {
+	Rules []CategoryRule `json:"rules,omitempty"`
+}
+
+// CategoryRule puts the expenses it matches in Category without asking the
+// model. Merchant is a regular expression matched against the vendor,
+// ignoring case; Keyword is looked for in the receipt's text; MinAmount and
+// MaxAmount bound the total. A rule matches when everything it sets does.
+type CategoryRule struct {
+	Merchant  string  `json:"merchant,omitempty"`
+	Keyword   string  `json:"keyword,omitempty"`
+	MinAmount float64 `json:"min_amount,omitempty"`
+	MaxAmount float64 `json:"max_amount,omitempty"`
+	Category  string  `json:"category"`
+}
+
+// LocaleConfig sets the locale, currency and date format businesses work in,
+// used in prompts, receipt reading and reports. Empty fields follow from the
+// locale, so "de-DE" alone means EUR and DD.MM.YYYY; with nothing set,
+// businesses are Canadian (en-CA, CAD). Language picks the language of
+// picoclaw's own messages; Users sets it for single users by user ID.
+// Timezone is the IANA zone schedules and reminders run in, with
+// UserTimezones setting it for single users; empty is the server's zone.
+type LocaleConfig struct {
+	Locale        string                          `json:"locale,omitempty"         env:"PICOCLAW_LOCALE"`
+	Currency      string                          `json:"currency,omitempty"       env:"PICOCLAW_LOCALE_CURRENCY"`
+	DateFormat    string                          `json:"date_format,omitempty"    env:"PICOCLAW_LOCALE_DATE_FORMAT"`
+	Language      string                          `json:"language,omitempty"       env:"PICOCLAW_LOCALE_LANGUAGE"`
+	Timezone      string                          `json:"timezone,omitempty"       env:"PICOCLAW_LOCALE_TIMEZONE"`
+	Businesses    map[string]BusinessLocaleConfig `json:"businesses,omitempty"`
+	Users         map[string]string               `json:"users,omitempty"`
+	UserTimezones map[string]string               `json:"user_timezones,omitempty"`
+}
+
+// BusinessLocaleConfig overrides the global locale settings for one business.
+type BusinessLocaleConfig struct {
+	Locale     string `json:"locale,omitempty"`
+	Currency   string `json:"currency,omitempty"`
+	DateFormat string `json:"date_format,omitempty"`
+	Language   string `json:"language,omitempty"`
+	Timezone   string `json:"timezone,omitempty"`
+}
+
+// ErrorReportingConfig sends panics and high-severity errors to a Sentry or
+// GlitchTip project.
+type ErrorReportingConfig struct {
+	Enabled     bool   `json:"enabled"     env:"PICOCLAW_ERROR_REPORTING_ENABLED"`
+	DSN         string `json:"dsn"         env:"PICOCLAW_ERROR_REPORTING_DSN"`
+	Environment string `json:"environment" env:"PICOCLAW_ERROR_REPORTING_ENVIRONMENT"`
 }
 
 type DevicesConfig struct {
@@ -413,6 +1058,61 @@ type GatewayConfig struct {
 	RequirePairing bool     `json:"require_pairing" env:"PICOCLAW_GATEWAY_REQUIRE_PAIRING"`
 	PairedTokens   []string `json:"paired_tokens,omitempty"`
 	JWTSecret      string   `json:"jwt_secret,omitempty" env:"PICOCLAW_GATEWAY_JWT_SECRET"`
+	Pprof          bool     `json:"pprof"           env:"PICOCLAW_GATEWAY_PPROF"` // admin-only /debug/pprof and /debug/runtime
+
+	// Agent runs beyond MaxConcurrentRequests wait in a queue of at most
+	// MaxQueuedRequests; zero concurrency means no limit.
+	MaxConcurrentRequests int `json:"max_concurrent_requests" env:"PICOCLAW_GATEWAY_MAX_CONCURRENT_REQUESTS"`
+	MaxQueuedRequests     int `json:"max_queued_requests"     env:"PICOCLAW_GATEWAY_MAX_QUEUED_REQUESTS"`
+
+	// Webhook uploads are streamed to disk; files larger than MaxUploadMB are
+	// rejected. Zero disables the limit.
+	MaxUploadMB int `json:"max_upload_mb" env:"PICOCLAW_GATEWAY_MAX_UPLOAD_MB"`
+
+	// Dashboard serves the web UI at /ui/.
+	Dashboard bool `json:"dashboard" env:"PICOCLAW_GATEWAY_DASHBOARD"`
+
+	// GraphQL serves the API at /graphql as well as the REST routes.
+	GraphQL bool `json:"graphql" env:"PICOCLAW_GATEWAY_GRAPHQL"`
+
+	// PairedDevices names the devices whose tokens are in PairedTokens.
+	PairedDevices []PairedDevice `json:"paired_devices,omitempty"`
+
+	// Listeners are further addresses the gateway serves on besides
+	// host:port, each with its own routes and middleware.
+	Listeners []ListenerConfig `json:"listeners,omitempty"`
+}
+
+// ListenerConfig is an extra address for the gateway's API, such as a
+// loopback port for admin tools, a TLS port for clients or a unix socket for
+// local scripts. Requests still need the usual tokens.
+type ListenerConfig struct {
+	Name     string `json:"name,omitempty"`
+	Address  string `json:"address"` // host:port, or unix:/path/to.sock
+	CertFile string `json:"cert_file,omitempty"`
+	KeyFile  string `json:"key_file,omitempty"`
+
+	// Routes are the path prefixes served, such as /admin or /webhook; empty
+	// serves every route.
+	Routes []string `json:"routes,omitempty"`
+	// Middleware wraps the routes, outermost first: any of local_only,
+	// access_log and hsts.
+	Middleware []string `json:"middleware,omitempty"`
+	// AllowFrom limits TCP clients to these IPs or CIDR ranges.
+	AllowFrom []string `json:"allow_from,omitempty"`
+}
+
+// PairedDevice describes the device holding a paired token, identified by
+// the token's SHA-256 hash.
+type PairedDevice struct {
+	TokenHash string    `json:"token_hash"`
+	Name      string    `json:"name,omitempty"`
+	PairedAt  time.Time `json:"paired_at"`
+
+	// Scope is "observer" for a read-only token, which can read status,
+	// usage, history and artifacts but not send messages or run tools.
+	// Empty means full access.
+	Scope string `json:"scope,omitempty"`
 }
 
 type BraveConfig struct {
@@ -432,10 +1132,40 @@ type PerplexityConfig struct {
 	MaxResults int    `json:"max_results" env:"PICOCLAW_TOOLS_WEB_PERPLEXITY_MAX_RESULTS"`
 }
 
+type BingConfig struct {
+	Enabled    bool   `json:"enabled"     env:"PICOCLAW_TOOLS_WEB_BING_ENABLED"`
+	APIKey     string `json:"api_key"     env:"PICOCLAW_TOOLS_WEB_BING_API_KEY"`
+	MaxResults int    `json:"max_results" env:"PICOCLAW_TOOLS_WEB_BING_MAX_RESULTS"`
+}
+
+type SearxNGConfig struct {
+	Enabled    bool   `json:"enabled"     env:"PICOCLAW_TOOLS_WEB_SEARXNG_ENABLED"`
+	BaseURL    string `json:"base_url"    env:"PICOCLAW_TOOLS_WEB_SEARXNG_BASE_URL"`
+	MaxResults int    `json:"max_results" env:"PICOCLAW_TOOLS_WEB_SEARXNG_MAX_RESULTS"`
+}
+
 type WebToolsConfig struct {
-	Brave      BraveConfig      `json:"brave"`
-	DuckDuckGo DuckDuckGoConfig `json:"duckduckgo"`
-	Perplexity PerplexityConfig `json:"perplexity"`
+	Backend         string           `json:"backend,omitempty"           env:"PICOCLAW_TOOLS_WEB_BACKEND"`
+	MaxSnippetChars int              `json:"max_snippet_chars"           env:"PICOCLAW_TOOLS_WEB_MAX_SNIPPET_CHARS"`
+	AllowDomains    []string         `json:"allow_domains,omitempty"     env:"PICOCLAW_TOOLS_WEB_ALLOW_DOMAINS"`
+	DenyDomains     []string         `json:"deny_domains,omitempty"      env:"PICOCLAW_TOOLS_WEB_DENY_DOMAINS"`
+	Businesses      []string         `json:"businesses,omitempty"        env:"PICOCLAW_TOOLS_WEB_BUSINESSES"`
+	Brave           BraveConfig      `json:"brave"`
+	Bing            BingConfig       `json:"bing"`
+	SearxNG         SearxNGConfig    `json:"searxng"`
+	DuckDuckGo      DuckDuckGoConfig `json:"duckduckgo"`
+	Perplexity      PerplexityConfig `json:"perplexity"`
+}
+
+type FetchURLConfig struct {
+	Enabled         bool     `json:"enabled"           env:"PICOCLAW_TOOLS_FETCH_URL_ENABLED"`
+	AllowURLs       []string `json:"allow_urls"        env:"PICOCLAW_TOOLS_FETCH_URL_ALLOW_URLS"`
+	AllowPrivate    bool     `json:"allow_private"     env:"PICOCLAW_TOOLS_FETCH_URL_ALLOW_PRIVATE"`
+	MaxBytes        int64    `json:"max_bytes"         env:"PICOCLAW_TOOLS_FETCH_URL_MAX_BYTES"`
+	MaxChars        int      `json:"max_chars"         env:"PICOCLAW_TOOLS_FETCH_URL_MAX_CHARS"`
+	TimeoutSeconds  int      `json:"timeout_seconds"   env:"PICOCLAW_TOOLS_FETCH_URL_TIMEOUT_SECONDS"`
+	CacheTTLSeconds int      `json:"cache_ttl_seconds" env:"PICOCLAW_TOOLS_FETCH_URL_CACHE_TTL_SECONDS"` // 0 disables caching
+	CacheEntries    int      `json:"cache_entries"     env:"PICOCLAW_TOOLS_FETCH_URL_CACHE_ENTRIES"`
 }
 
 type CronToolsConfig struct {
@@ -448,16 +1178,69 @@ type ExecConfig struct {
 }
 
 type ToolsConfig struct {
-	Web    WebToolsConfig    `json:"web"`
-	Cron   CronToolsConfig   `json:"cron"`
-	Exec   ExecConfig        `json:"exec"`
-	Skills SkillsToolsConfig `json:"skills"`
+	Web      WebToolsConfig    `json:"web"`
+	FetchURL FetchURLConfig    `json:"fetch_url"`
+	Cron     CronToolsConfig   `json:"cron"`
+	Exec     ExecConfig        `json:"exec"`
+	Skills   SkillsToolsConfig `json:"skills"`
+	GPIO     GPIOConfig        `json:"gpio"`
+	Camera   CameraConfig      `json:"camera"`
+	Approval ApprovalConfig    `json:"approval"`
+}
+
+// ApprovalConfig makes the agent ask before calling the listed tools. A
+// gated call is held and the run stops with a confirmation prompt to the
+// chat or API client that asked, or to the owner chat in Channel and To if
+// set; the call runs on /approve and is dropped on /reject or after
+// TimeoutMinutes. Only ledgerforge calls that change data are gated, not
+// GET requests.
+type ApprovalConfig struct {
+	Enabled        bool     `json:"enabled"         env:"PICOCLAW_TOOLS_APPROVAL_ENABLED"`
+	Tools          []string `json:"tools"           env:"PICOCLAW_TOOLS_APPROVAL_TOOLS"`
+	TimeoutMinutes int      `json:"timeout_minutes" env:"PICOCLAW_TOOLS_APPROVAL_TIMEOUT_MINUTES"`
+	Channel        string   `json:"channel"         env:"PICOCLAW_TOOLS_APPROVAL_CHANNEL"`
+	To             string   `json:"to"              env:"PICOCLAW_TOOLS_APPROVAL_TO"`
+}
+
+// CameraConfig enables the capture_photo tool. Backend is "libcamera" for a
+// camera module on the CSI connector or "v4l2" for a USB webcam on Device;
+// empty uses libcamera when its tools are installed.
+type CameraConfig struct {
+	Enabled bool   `json:"enabled" env:"PICOCLAW_TOOLS_CAMERA_ENABLED"`
+	Backend string `json:"backend" env:"PICOCLAW_TOOLS_CAMERA_BACKEND"`
+	Device  string `json:"device"  env:"PICOCLAW_TOOLS_CAMERA_DEVICE"`
+	Width   int    `json:"width"   env:"PICOCLAW_TOOLS_CAMERA_WIDTH"`
+	Height  int    `json:"height"  env:"PICOCLAW_TOOLS_CAMERA_HEIGHT"`
+}
+
+// GPIOConfig enables the gpio tool. The agent can only use the pins listed,
+// and can only drive those marked as outputs.
+type GPIOConfig struct {
+	Enabled bool            `json:"enabled" env:"PICOCLAW_TOOLS_GPIO_ENABLED"`
+	Pins    []GPIOPinConfig `json:"pins,omitempty"`
+}
+
+// GPIOPinConfig is a pin the agent may use: a line of a GPIO chip, or an LED
+// class device under /sys/class/leds.
+type GPIOPinConfig struct {
+	Name        string `json:"name"`                  // what the agent calls it, e.g. "receipt_led"
+	Description string `json:"description,omitempty"` // e.g. "buzzer by the door"
+	Chip        string `json:"chip,omitempty"`        // e.g. "gpiochip0"
+	Line        int    `json:"line,omitempty"`        // line offset on Chip, e.g. 17 for GPIO17 on a Raspberry Pi
+	LED         string `json:"led,omitempty"`         // an LED class device instead of a chip line, e.g. "ACT"
+	Output      bool   `json:"output"`
+	ActiveLow   bool   `json:"active_low,omitempty"` // on drives the line low, as for many relay boards
 }
 
 type SkillsToolsConfig struct {
 	Registries            SkillsRegistriesConfig `json:"registries"`
 	MaxConcurrentSearches int                    `json:"max_concurrent_searches" env:"PICOCLAW_SKILLS_MAX_CONCURRENT_SEARCHES"`
 	SearchCache           SearchCacheConfig      `json:"search_cache"`
+	// MaxPromptSkills is how many skills, picked by relevance to the request,
+	// the system prompt describes in full; others are listed by name. 0 means all.
+	MaxPromptSkills int `json:"max_prompt_skills" env:"PICOCLAW_SKILLS_MAX_PROMPT_SKILLS"`
+	// Businesses maps business_id to its enabled skill names; "*" applies to unlisted businesses.
+	Businesses map[string][]string `json:"businesses,omitempty"`
 }
 
 type SearchCacheConfig struct {
@@ -492,6 +1275,18 @@ func LoadConfig(path string) (*Config, error) {
 		return nil, err
 	}
 
+	// A profile only changes defaults, so it is applied before the file.
+	var profile struct {
+		Profile string `json:"profile"`
+	}
+	json.Unmarshal(data, &profile) // a malformed file is reported below
+	if name := os.Getenv("PICOCLAW_PROFILE"); name != "" {
+		profile.Profile = name
+	}
+	if err := cfg.applyProfile(profile.Profile); err != nil {
+		return nil, err
+	}
+
 	if err := json.Unmarshal(data, cfg); err != nil {
 		return nil, err
 	}
@@ -531,6 +1326,10 @@ func (c *Config) WorkspacePath() string {
 	return expandHome(c.Agents.Defaults.Workspace)
 }
 
+func (c *Config) TokenizerPath() string {
+	return expandHome(c.Agents.Defaults.TokenizerFile)
+}
+
 func (c *Config) GetAPIKey() string {
 	if c.Providers.OpenRouter.APIKey != "" {
 		return c.Providers.OpenRouter.APIKey
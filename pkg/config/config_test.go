@@ -252,6 +252,9 @@ func TestDefaultConfig_Gateway(t *testing.T) {
 	if cfg.Gateway.Port == 0 {
 		t.Error("Gateway port should have default value")
 	}
+	if cfg.Gateway.MaxConcurrentRequests != 0 || cfg.Gateway.MaxQueuedRequests != 0 {
+		t.Error("Gateway should not limit concurrent requests by default")
+	}
 }
 
 // TestDefaultConfig_Providers verifies provider structure
@@ -392,3 +395,67 @@ func TestLoadConfig_OpenAIWebSearchCanBeDisabled(t *testing.T) {
 		t.Fatal("OpenAI codex web search should be false when disabled in config file")
 	}
 }
+
+func TestLoadConfig_LowMemoryProfile(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.json")
+	data := `{"profile":"low_memory","gateway":{"max_queued_requests":2}}`
+	if err := os.WriteFile(configPath, []byte(data), 0o600); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig() error: %v", err)
+	}
+	if cfg.Gateway.MaxConcurrentRequests != 1 {
+		t.Errorf("MaxConcurrentRequests = %d, want 1", cfg.Gateway.MaxConcurrentRequests)
+	}
+	if cfg.Agents.Defaults.MaxHistoryMessages != 10 {
+		t.Errorf("MaxHistoryMessages = %d, want 10", cfg.Agents.Defaults.MaxHistoryMessages)
+	}
+	if cfg.MemoryLimitMB == 0 {
+		t.Error("MemoryLimitMB should be set by the profile")
+	}
+	if cfg.Gateway.MaxQueuedRequests != 2 {
+		t.Errorf("MaxQueuedRequests = %d, want the file's 2 over the profile", cfg.Gateway.MaxQueuedRequests)
+	}
+}
+
+func TestLoadConfig_OfflineProfile(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.json")
+	data := `{"profile":"low_memory,offline"}`
+	if err := os.WriteFile(configPath, []byte(data), 0o600); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig() error: %v", err)
+	}
+	if !cfg.Network.Offline {
+		t.Error("Network.Offline should be set by the offline profile")
+	}
+	if cfg.Tools.Web.DuckDuckGo.Enabled {
+		t.Error("DuckDuckGo search should be disabled offline")
+	}
+	if _, err := cfg.GetModelConfig(cfg.Agents.Defaults.Model); err != nil {
+		t.Errorf("offline default model %q is not in the default model_list: %v", cfg.Agents.Defaults.Model, err)
+	}
+	if cfg.Gateway.MaxConcurrentRequests != 1 {
+		t.Errorf("MaxConcurrentRequests = %d, want low_memory's 1 as well", cfg.Gateway.MaxConcurrentRequests)
+	}
+}
+
+func TestLoadConfig_UnknownProfile(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(configPath, []byte(`{"profile":"tiny"}`), 0o600); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	if _, err := LoadConfig(configPath); err == nil {
+		t.Fatal("LoadConfig() should reject an unknown profile")
+	}
+}
@@ -17,6 +17,8 @@ func DefaultConfig() *Config {
 				MaxTokens:           8192,
 				Temperature:         nil, // nil means use provider default
 				MaxToolIterations:   20,
+				MaxHistoryMessages:  20,
+				MaxParallelTools:    4,
 			},
 		},
 		Bindings: []AgentBinding{},
@@ -113,6 +115,14 @@ func DefaultConfig() *Config {
 				AllowFrom:      FlexibleStringSlice{},
 				ReplyTimeout:   5,
 			},
+			Voice: VoiceConfig{
+				Enabled:        false,
+				Trigger:        "wake_word",
+				CaptureCommand: "arecord -q -t raw -f S16_LE -c 1 -r 16000",
+				SpeakCommand:   "espeak-ng --stdin",
+				SilenceMS:      800,
+				MaxSeconds:     15,
+			},
 		},
 		Providers: ProvidersConfig{
 			OpenAI: OpenAIProviderConfig{WebSearch: true},
@@ -262,18 +272,48 @@ func DefaultConfig() *Config {
 				APIBase:   "http://localhost:8000/v1",
 				APIKey:    "",
 			},
+
+			// llama.cpp llama-server (local) - https://github.com/ggml-org/llama.cpp
+			{
+				ModelName: "llamacpp",
+				Model:     "llamacpp/default",
+				APIBase:   "http://localhost:8080/v1",
+			},
+		},
+		Provision: ProvisionConfig{
+			Baud:          115200,
+			WPASupplicant: "/etc/wpa_supplicant.conf",
+		},
+		MDNS: MDNSConfig{
+			Enabled: true,
+		},
+		PortMapping: PortMappingConfig{
+			LifetimeSeconds: 3600,
 		},
 		Gateway: GatewayConfig{
-			Host: "0.0.0.0",
-			Port: 18790,
+			Host:        "0.0.0.0",
+			Port:        18790,
+			MaxUploadMB: 20,
+			Dashboard:   true,
 		},
 		Tools: ToolsConfig{
 			Web: WebToolsConfig{
+				MaxSnippetChars: 300,
 				Brave: BraveConfig{
 					Enabled:    false,
 					APIKey:     "",
 					MaxResults: 5,
 				},
+				Bing: BingConfig{
+					Enabled:    false,
+					APIKey:     "",
+					MaxResults: 5,
+				},
+				SearxNG: SearxNGConfig{
+					Enabled:    false,
+					BaseURL:    "",
+					MaxResults: 5,
+				},
 				DuckDuckGo: DuckDuckGoConfig{
 					Enabled:    true,
 					MaxResults: 5,
@@ -284,6 +324,16 @@ func DefaultConfig() *Config {
 					MaxResults: 5,
 				},
 			},
+			FetchURL: FetchURLConfig{
+				Enabled:         true,
+				AllowURLs:       []string{},
+				AllowPrivate:    false,
+				MaxBytes:        1 << 20,
+				MaxChars:        50000,
+				TimeoutSeconds:  30,
+				CacheTTLSeconds: 300,
+				CacheEntries:    64,
+			},
 			Cron: CronToolsConfig{
 				ExecTimeoutMinutes: 5,
 			},
@@ -298,11 +348,21 @@ func DefaultConfig() *Config {
 					},
 				},
 				MaxConcurrentSearches: 2,
+				MaxPromptSkills:       8,
 				SearchCache: SearchCacheConfig{
 					MaxSize:    50,
 					TTLSeconds: 300,
 				},
 			},
+			Camera: CameraConfig{
+				Device: "/dev/video0",
+				Width:  1920,
+				Height: 1080,
+			},
+			Approval: ApprovalConfig{
+				Tools:          []string{"ledgerforge", "post_transaction", "send_payment"},
+				TimeoutMinutes: 10,
+			},
 		},
 		Heartbeat: HeartbeatConfig{
 			Enabled:  true,
@@ -312,5 +372,120 @@ func DefaultConfig() *Config {
 			Enabled:    false,
 			MonitorUSB: true,
 		},
+		Logging: LoggingConfig{
+			Level:  "info",
+			Format: "text",
+			Rotation: LogRotationConfig{
+				MaxSizeMB:  10,
+				MaxBackups: 5,
+				MaxAgeDays: 30,
+				Compress:   true,
+			},
+		},
+		Telemetry: TelemetryConfig{
+			Enabled:               false,
+			SampleRatio:           1.0,
+			ExportIntervalSeconds: 60,
+		},
+		WireLog: WireLogConfig{
+			Enabled:       false,
+			MaxFileSizeKB: 1024,
+			MaxFiles:      5,
+			MaxBodyBytes:  16384,
+		},
+		Digest: DigestConfig{
+			Time: "21:00",
+		},
+		Budget: BudgetConfig{
+			WarnPercent: 80,
+			StopPercent: 150,
+		},
+		Notifications: NotificationsConfig{
+			BatchSeconds: 60,
+		},
+		InputFilter: InputFilterConfig{
+			Action: "strip",
+		},
+		Alerts: AlertsConfig{
+			WebhookFailures:     5,
+			ProviderDownMinutes: 10,
+			DiskUsagePercent:    90,
+			CooldownMinutes:     60,
+		},
+		Hardware: HardwareConfig{
+			Enabled:         true,
+			MaxTemperatureC: 80,
+		},
+		Resources: ResourcesConfig{
+			MinDiskFreePercent: 10,
+			MinDiskFreeMB:      200,
+			MaxMemoryPercent:   90,
+		},
+		Media: MediaConfig{
+			MaxAgeDays:             90,
+			MaxSizeMB:              1024,
+			CleanupIntervalMinutes: 60,
+		},
+		Storage: StorageConfig{
+			Backend:        "local",
+			PresignMinutes: 60,
+			Postgres: PostgresStorageConfig{
+				Namespace:    "default",
+				LeaseSeconds: 30,
+			},
+		},
+		Snapshots: SnapshotsConfig{
+			Time:   "03:00",
+			Keep:   7,
+			Target: "s3",
+		},
+		Transcripts: TranscriptsConfig{
+			Enabled: true,
+		},
+		Documents: DocumentsConfig{
+			ChunkSize:    1200,
+			ChunkOverlap: 200,
+		},
+		Artifacts: ArtifactsConfig{
+			TTLHours:               168,
+			CleanupIntervalMinutes: 60,
+		},
+		EventLog: EventLogConfig{
+			Enabled: true,
+			Kafka: KafkaConfig{
+				Topic:           "picoclaw.events",
+				IntervalSeconds: 5,
+			},
+		},
+		LLMCache: LLMCacheConfig{
+			TTLMinutes: 1440,
+			MaxEntries: 256,
+		},
+		Experiment: ExperimentConfig{
+			Name: "variant",
+		},
+		Network: NetworkConfig{
+			MaxConnsPerHost:        8,
+			MaxIdleConnsPerHost:    4,
+			IdleConnTimeoutSeconds: 90,
+		},
+		Update: UpdateConfig{
+			CheckIntervalHours: 24,
+			FeedURL:            "https://api.github.com/repos/sipeed/picoclaw/releases/latest",
+		},
+		LedgerForge: LedgerForgeConfig{
+			TimeoutSeconds: 30,
+			MaxRetries:     3,
+		},
+		Receipts: ReceiptsConfig{
+			ReviewThreshold: 0.6,
+		},
+		NATS: NATSConfig{
+			Subject:    "picoclaw.requests",
+			QueueGroup: "picoclaw",
+		},
+		Subscriptions: SubscriptionsConfig{
+			MaxRetries: 5,
+		},
 	}
 }
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ProfileLowMemory tunes defaults for Sipeed-class boards with less than 1GB
+// of RAM.
+const ProfileLowMemory = "low_memory"
+
+// ProfileOffline keeps picoclaw working without internet: the default model
+// is served on this device and connections outside the local network are
+// refused.
+const ProfileOffline = "offline"
+
+// offlineModel is the default model_list entry ProfileOffline selects.
+const offlineModel = "llama3"
+
+// applyProfile replaces the defaults in c with those of the named profiles,
+// separated by commas. It runs before the config file is read, so explicit
+// settings still win.
+func (c *Config) applyProfile(names string) error {
+	if names == "" {
+		return nil
+	}
+	for _, name := range strings.Split(names, ",") {
+		switch strings.TrimSpace(name) {
+		case ProfileLowMemory:
+			c.MemoryLimitMB = 192
+			c.Agents.Defaults.MaxHistoryMessages = 10
+			c.Agents.Defaults.MaxParallelTools = 1
+			c.LLMCache.Enabled = false
+			c.Gateway.MaxConcurrentRequests = 1
+			c.Gateway.MaxQueuedRequests = 4
+		case ProfileOffline:
+			c.Network.Offline = true
+			c.Agents.Defaults.Model = offlineModel
+			c.Tools.Web.DuckDuckGo.Enabled = false
+			c.Tools.Skills.Registries.ClawHub.Enabled = false
+		default:
+			return fmt.Errorf("unknown config profile %q", name)
+		}
+	}
+	c.Profile = names
+	return nil
+}
@@ -7,6 +7,7 @@ var internalChannels = map[string]struct{}{
 	"cli":      {},
 	"system":   {},
 	"subagent": {},
+	"workflow": {},
 }
 
 // IsInternalChannel returns true if the channel is an internal channel.
@@ -14,3 +15,19 @@ func IsInternalChannel(channel string) bool {
 	_, found := internalChannels[channel]
 	return found
 }
+
+// requestChannels are APIs whose callers receive the response as the reply
+// to their request, so it is not also sent out through the message bus.
+var requestChannels = map[string]struct{}{
+	"api":        {},
+	"nats":       {},
+	"federation": {},
+	"workflow":   {},
+}
+
+// IsRequestChannel returns true if responses on the channel are returned to
+// the caller rather than sent.
+func IsRequestChannel(channel string) bool {
+	_, found := requestChannels[channel]
+	return found
+}
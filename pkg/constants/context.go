@@ -8,6 +8,33 @@ const (
 	ContextKeyJWTToken contextKey = "jwt_token"
 	// ContextKeyUserID stores the authenticated user's ID.
 	ContextKeyUserID contextKey = "user_id"
+	// ContextKeySenderID stores the chat sender ID of the message being processed.
+	ContextKeySenderID contextKey = "sender_id"
 	// ContextKeyBusinessID stores the requested business ID.
 	ContextKeyBusinessID contextKey = "business_id"
+	// ContextKeyDebug stores a bool that turns session debug mode on or off.
+	ContextKeyDebug contextKey = "debug"
+	// ContextKeyRequestID stores the correlation ID of the request being handled.
+	ContextKeyRequestID contextKey = "request_id"
+	// ContextKeySessionKey stores the key of the session being processed.
+	ContextKeySessionKey contextKey = "session_key"
+	// ContextKeyChannel and ContextKeyChatID store the chat a tool call's
+	// run answers to.
+	ContextKeyChannel contextKey = "channel"
+	ContextKeyChatID  contextKey = "chat_id"
+	// ContextKeyVariant stores the model experiment variant serving the request.
+	ContextKeyVariant contextKey = "variant"
+	// ContextKeyClient stores the client an API request came from, as sent in
+	// the X-Client header, such as "ios/2.3.1".
+	ContextKeyClient contextKey = "client"
+)
+
+const (
+	// RequestIDHeader carries the request ID on inbound API calls and outbound
+	// provider requests.
+	RequestIDHeader = "X-Request-ID"
+	// ClientHeader names the app and version an API request came from.
+	ClientHeader = "X-Client"
+	// RequestIDEnv passes the request ID to skill scripts.
+	RequestIDEnv = "PICOCLAW_REQUEST_ID"
 )
@@ -0,0 +1,133 @@
+// Package crash turns recovered panics into crash reports: the stack is
+// logged, written to <workspace>/crashes, counted as a metric and forwarded
+// to error reporting.
+//
+// Reports are only written to disk after Init; logging, metrics and error
+// reporting work without it.
+package crash
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/reporting"
+	"github.com/sipeed/picoclaw/pkg/telemetry"
+)
+
+// maxReports is the number of crash reports kept on disk.
+const maxReports = 50
+
+var (
+	dir     atomic.Pointer[string]
+	version atomic.Pointer[string]
+)
+
+// Init enables crash report files under <workspace>/crashes. release is
+// recorded in each report.
+func Init(workspace, release string) error {
+	d := filepath.Join(workspace, "crashes")
+	if err := os.MkdirAll(d, 0o755); err != nil {
+		return fmt.Errorf("failed to create crash report directory: %w", err)
+	}
+	dir.Store(&d)
+	version.Store(&release)
+	return nil
+}
+
+// Report handles a value recovered from a panic in component and returns the
+// crash report ID (empty if no file was written). It must be called from the
+// deferred function that recovered, so the stack still shows where the panic
+// happened.
+func Report(component string, recovered any, extra map[string]any) string {
+	stack := debug.Stack()
+	telemetry.AddCounter("picoclaw.crashes", 1, telemetry.String("component", component))
+
+	id := writeReport(component, recovered, extra, stack)
+	fields := map[string]any{"panic": fmt.Sprint(recovered), "stack": string(stack)}
+	if id != "" {
+		fields["crash_id"] = id
+	}
+	for k, v := range extra {
+		fields[k] = v
+	}
+	logger.ErrorCF(component, "Recovered from panic", fields)
+
+	reporting.CapturePanic(component, recovered)
+	return id
+}
+
+// Recover reports a panic in the calling goroutine and re-panics, like
+// reporting.Recover but also writing a crash report. Use it as the first
+// deferred call in long-running goroutines:
+//
+//	defer crash.Recover("agent")
+func Recover(component string) {
+	if recovered := recover(); recovered != nil {
+		Report(component, recovered, nil)
+		panic(recovered)
+	}
+}
+
+func writeReport(component string, recovered any, extra map[string]any, stack []byte) string {
+	d := dir.Load()
+	if d == nil {
+		return ""
+	}
+
+	now := time.Now().UTC()
+	id := fmt.Sprintf("crash-%s-%s", now.Format("20060102T150405.000"), safeName(component))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "time: %s\n", now.Format(time.RFC3339Nano))
+	fmt.Fprintf(&b, "component: %s\n", component)
+	if v := version.Load(); v != nil && *v != "" {
+		fmt.Fprintf(&b, "version: %s\n", *v)
+	}
+	fmt.Fprintf(&b, "go: %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	keys := make([]string, 0, len(extra))
+	for k := range extra {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%s: %v\n", k, extra[k])
+	}
+	fmt.Fprintf(&b, "\npanic: %v\n\n%s", recovered, stack)
+
+	if err := os.WriteFile(filepath.Join(*d, id+".log"), []byte(b.String()), 0o600); err != nil {
+		logger.ErrorCF("crash", "Failed to write crash report", map[string]any{"error": err.Error()})
+		return ""
+	}
+	prune(*d)
+	return id
+}
+
+// prune removes the oldest reports beyond maxReports. Report names sort
+// chronologically.
+func prune(d string) {
+	reports, err := filepath.Glob(filepath.Join(d, "crash-*.log"))
+	if err != nil || len(reports) <= maxReports {
+		return
+	}
+	sort.Strings(reports)
+	for _, path := range reports[:len(reports)-maxReports] {
+		os.Remove(path)
+	}
+}
+
+func safeName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, s)
+}
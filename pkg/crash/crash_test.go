@@ -0,0 +1,48 @@
+package crash
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportWritesCrashFile(t *testing.T) {
+	workspace := t.TempDir()
+	require.NoError(t, Init(workspace, "v1.2.3"))
+	defer dir.Store(nil)
+
+	var id string
+	func() {
+		defer func() {
+			id = Report("agent", recover(), map[string]any{"session_key": "s1"})
+		}()
+		panic("boom")
+	}()
+	require.NotEmpty(t, id)
+
+	data, err := os.ReadFile(filepath.Join(workspace, "crashes", id+".log"))
+	require.NoError(t, err)
+	report := string(data)
+	assert.Contains(t, report, "component: agent")
+	assert.Contains(t, report, "version: v1.2.3")
+	assert.Contains(t, report, "session_key: s1")
+	assert.Contains(t, report, "panic: boom")
+	assert.Contains(t, report, "TestReportWritesCrashFile", "stack points at the panic site")
+}
+
+func TestPruneKeepsNewestReports(t *testing.T) {
+	d := t.TempDir()
+	for i := 0; i < maxReports+3; i++ {
+		require.NoError(t, os.WriteFile(filepath.Join(d, fmt.Sprintf("crash-%03d.log", i)), nil, 0o600))
+	}
+	prune(d)
+
+	reports, err := filepath.Glob(filepath.Join(d, "crash-*.log"))
+	require.NoError(t, err)
+	assert.Len(t, reports, maxReports)
+	assert.NotContains(t, reports, filepath.Join(d, "crash-000.log"))
+}
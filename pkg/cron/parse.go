@@ -0,0 +1,92 @@
+package cron
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	relativeTime = regexp.MustCompile(`^in (\d+) ?(minutes?|mins?|m|hours?|hrs?|h|days?|d)$`)
+	localTime    = regexp.MustCompile(`^(?:(today|tomorrow|(?:next )?(?:monday|tuesday|wednesday|thursday|friday|` +
+		`saturday|sunday|mon|tues|tue|wed|thurs|thur|thu|fri|sat|sun)|\d{4}-\d{2}-\d{2})(?: |t))?` +
+		`(?:at )?(\d{1,2})(?:[:h](\d{2}))? ?(am|pm)?$`)
+)
+
+// ParseTime reads when a one-time job should run from text such as
+// "tomorrow at 9", "friday 14:30", "9pm", "in 20 minutes" or
+// "2026-03-02 09:00". Times of day are in the location of now, and a bare
+// time that has already passed today means tomorrow.
+func ParseTime(text string, now time.Time) (time.Time, error) {
+	s := strings.ToLower(strings.Join(strings.Fields(text), " "))
+	s = strings.NewReplacer("noon", "12:00", "midnight", "0:00").Replace(s)
+
+	if m := relativeTime.FindStringSubmatch(s); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		switch m[2][0] {
+		case 'm':
+			return now.Add(time.Duration(n) * time.Minute), nil
+		case 'h':
+			return now.Add(time.Duration(n) * time.Hour), nil
+		default:
+			return now.AddDate(0, 0, n), nil
+		}
+	}
+
+	m := localTime.FindStringSubmatch(s)
+	if m == nil {
+		return time.Time{}, fmt.Errorf("cannot read time %q; use e.g. \"tomorrow at 9\" or \"2026-03-02 14:30\"",
+			text)
+	}
+	hour, _ := strconv.Atoi(m[2])
+	minute, _ := strconv.Atoi(m[3])
+	switch {
+	case m[4] != "" && (hour < 1 || hour > 12):
+		return time.Time{}, fmt.Errorf("hour %d is not on a 12-hour clock", hour)
+	case m[4] == "am" && hour == 12:
+		hour = 0
+	case m[4] == "pm" && hour < 12:
+		hour += 12
+	}
+	if hour > 23 || minute > 59 {
+		return time.Time{}, fmt.Errorf("%q is not a time of day", text)
+	}
+
+	loc := now.Location()
+	at := func(day time.Time) time.Time {
+		return time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, loc)
+	}
+	day := m[1]
+	switch {
+	case day == "" || day == "today":
+		t := at(now)
+		if day == "" && !t.After(now) {
+			t = at(now.AddDate(0, 0, 1))
+		}
+		return t, nil
+	case day == "tomorrow":
+		return at(now.AddDate(0, 0, 1)), nil
+	case day[0] >= '0' && day[0] <= '9':
+		d, err := time.ParseInLocation(time.DateOnly, day, loc)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid date %q", day)
+		}
+		return at(d), nil
+	}
+
+	next := strings.HasPrefix(day, "next ")
+	weekday := weekdays[strings.TrimPrefix(day, "next ")[:3]]
+	ahead := (int(weekday) - int(now.Weekday()) + 7) % 7
+	t := at(now.AddDate(0, 0, ahead))
+	if ahead == 0 && (next || !t.After(now)) {
+		t = at(now.AddDate(0, 0, 7))
+	}
+	return t, nil
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
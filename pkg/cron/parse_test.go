@@ -0,0 +1,66 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("no tzdata: %v", err)
+	}
+	// Wednesday afternoon, Berlin time
+	now := time.Date(2026, 3, 4, 15, 0, 0, 0, berlin)
+
+	tests := []struct {
+		text string
+		want time.Time
+	}{
+		{"tomorrow at 9", time.Date(2026, 3, 5, 9, 0, 0, 0, berlin)},
+		{"Tomorrow 9:30pm", time.Date(2026, 3, 5, 21, 30, 0, 0, berlin)},
+		{"today at 17:45", time.Date(2026, 3, 4, 17, 45, 0, 0, berlin)},
+		{"9am", time.Date(2026, 3, 5, 9, 0, 0, 0, berlin)},
+		{"at 16h30", time.Date(2026, 3, 4, 16, 30, 0, 0, berlin)},
+		{"friday at noon", time.Date(2026, 3, 6, 12, 0, 0, 0, berlin)},
+		{"wed 10:00", time.Date(2026, 3, 11, 10, 0, 0, 0, berlin)},
+		{"next wednesday at 16", time.Date(2026, 3, 11, 16, 0, 0, 0, berlin)},
+		{"2026-03-30 09:00", time.Date(2026, 3, 30, 9, 0, 0, 0, berlin)},
+		{"2026-03-30T12am", time.Date(2026, 3, 30, 0, 0, 0, 0, berlin)},
+		{"in 20 minutes", now.Add(20 * time.Minute)},
+		{"in 2 days", time.Date(2026, 3, 6, 15, 0, 0, 0, berlin)},
+	}
+	for _, tt := range tests {
+		got, err := ParseTime(tt.text, now)
+		if err != nil {
+			t.Errorf("%q: %v", tt.text, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("%q: got %s, want %s", tt.text, got, tt.want)
+		}
+	}
+
+	for _, text := range []string{"", "tomorrow", "25:00", "13pm", "someday at 9", "2026-02-30 09:00"} {
+		if _, err := ParseTime(text, now); err == nil {
+			t.Errorf("%q: expected an error", text)
+		}
+	}
+}
+
+func TestParseTime_KeepsWallClockAcrossDST(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("no tzdata: %v", err)
+	}
+	// Clocks go forward on the night of March 29, 2026
+	now := time.Date(2026, 3, 28, 20, 0, 0, 0, berlin)
+
+	got, err := ParseTime("tomorrow at 9", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Hour() != 9 || got.Sub(now) != 12*time.Hour {
+		t.Errorf("got %s, want 09:00 CEST, 12 hours ahead", got)
+	}
+}
@@ -5,13 +5,27 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/adhocore/gronx"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/reporting"
+)
+
+const (
+	// PayloadKindSkill marks jobs that run a scheduled skill declared in its manifest.
+	PayloadKindSkill = "skill"
+	// SkillJobPrefix prefixes the IDs of jobs synced from skill manifests.
+	SkillJobPrefix = "skill-"
+	// PayloadKindWorkflow marks jobs that start a workflow on its schedule.
+	PayloadKindWorkflow = "workflow"
+	// WorkflowJobPrefix prefixes the IDs of jobs synced from workflows.
+	WorkflowJobPrefix = "workflow-"
 )
 
 type CronSchedule struct {
@@ -23,12 +37,14 @@ type CronSchedule struct {
 }
 
 type CronPayload struct {
-	Kind    string `json:"kind"`
-	Message string `json:"message"`
-	Command string `json:"command,omitempty"`
-	Deliver bool   `json:"deliver"`
-	Channel string `json:"channel,omitempty"`
-	To      string `json:"to,omitempty"`
+	Kind     string `json:"kind"`
+	Message  string `json:"message"`
+	Command  string `json:"command,omitempty"`
+	Deliver  bool   `json:"deliver"`
+	Channel  string `json:"channel,omitempty"`
+	To       string `json:"to,omitempty"`
+	Skill    string `json:"skill,omitempty"`
+	Workflow string `json:"workflow,omitempty"`
 }
 
 type CronJobState struct {
@@ -65,6 +81,7 @@ type CronService struct {
 	running   bool
 	stopChan  chan struct{}
 	gronx     *gronx.Gronx
+	isLeader  func() bool
 }
 
 func NewCronService(storePath string, onJob JobHandler) *CronService {
@@ -78,6 +95,15 @@ func NewCronService(storePath string, onJob JobHandler) *CronService {
 	return cs
 }
 
+// SetLeaderCheck makes the service run due jobs only while isLeader returns
+// true, so that of several gateways sharing state only the leader runs them.
+// Jobs that fall due on a follower wait until it becomes leader.
+func (cs *CronService) SetLeaderCheck(isLeader func() bool) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.isLeader = isLeader
+}
+
 func (cs *CronService) Start() error {
 	cs.mu.Lock()
 	defer cs.mu.Unlock()
@@ -134,7 +160,7 @@ func (cs *CronService) runLoop(stopChan chan struct{}) {
 func (cs *CronService) checkJobs() {
 	cs.mu.Lock()
 
-	if !cs.running {
+	if !cs.running || (cs.isLeader != nil && !cs.isLeader()) {
 		cs.mu.Unlock()
 		return
 	}
@@ -162,7 +188,8 @@ func (cs *CronService) checkJobs() {
 	}
 
 	if err := cs.saveStoreUnsafe(); err != nil {
-		log.Printf("[cron] failed to save store: %v", err)
+		logger.ErrorCF("cron", "Failed to save store", map[string]any{"error": err.Error()})
+		reporting.CaptureError("cron", err, map[string]any{"store": cs.storePath})
 	}
 
 	cs.mu.Unlock()
@@ -209,7 +236,7 @@ func (cs *CronService) executeJobByID(jobID string) {
 		}
 	}
 	if job == nil {
-		log.Printf("[cron] job %s disappeared before state update", jobID)
+		logger.WarnCF("cron", "Job disappeared before state update", map[string]any{"job_id": jobID})
 		return
 	}
 
@@ -238,7 +265,8 @@ func (cs *CronService) executeJobByID(jobID string) {
 	}
 
 	if err := cs.saveStoreUnsafe(); err != nil {
-		log.Printf("[cron] failed to save store: %v", err)
+		logger.ErrorCF("cron", "Failed to save store", map[string]any{"error": err.Error()})
+		reporting.CaptureError("cron", err, map[string]any{"store": cs.storePath})
 	}
 }
 
@@ -263,11 +291,20 @@ func (cs *CronService) computeNextRun(schedule *CronSchedule, nowMS int64) *int6
 			return nil
 		}
 
-		// Use gronx to calculate next run time
-		now := time.UnixMilli(nowMS)
-		nextTime, err := gronx.NextTickAfter(schedule.Expr, now, false)
+		loc := time.Local
+		if schedule.TZ != "" {
+			var err error
+			if loc, err = time.LoadLocation(schedule.TZ); err != nil {
+				logger.ErrorCF("cron", "Unknown schedule timezone",
+					map[string]any{"expr": schedule.Expr, "tz": schedule.TZ, "error": err.Error()})
+				return nil
+			}
+		}
+
+		nextTime, err := nextTickIn(schedule.Expr, time.UnixMilli(nowMS), loc)
 		if err != nil {
-			log.Printf("[cron] failed to compute next run for expr '%s': %v", schedule.Expr, err)
+			logger.ErrorCF("cron", "Failed to compute next run",
+				map[string]any{"expr": schedule.Expr, "error": err.Error()})
 			return nil
 		}
 
@@ -278,6 +315,42 @@ func (cs *CronService) computeNextRun(schedule *CronSchedule, nowMS int64) *int6
 	return nil
 }
 
+// nextTickIn returns the first time after now that expr matches on the wall
+// clock of loc. Matching wall time rather than instants keeps "0 9 * * *" at
+// 9:00 across DST changes: a time skipped when clocks go forward runs at the
+// jump, and a time repeated when they go back runs once.
+func nextTickIn(expr string, now time.Time, loc *time.Location) (time.Time, error) {
+	// gronx matches the fields of the time it is given, so walk the wall
+	// clock as if it were UTC, where no hour is skipped or repeated.
+	wall := wallClock(now.In(loc))
+	// A wall time in the repeated hour may map to its first, past occurrence;
+	// move on until a tick is in the future. A day of minutely ticks is plenty.
+	for range 24 * 60 {
+		next, err := gronx.NextTickAfter(expr, wall, false)
+		if err != nil {
+			return time.Time{}, err
+		}
+		t := time.Date(next.Year(), next.Month(), next.Day(), next.Hour(), next.Minute(), next.Second(), 0, loc)
+		if got := wallClock(t); !got.Equal(next) {
+			// next was skipped by clocks going forward; run at the jump.
+			start, end := t.ZoneBounds()
+			if t = start; got.Before(next) {
+				t = end
+			}
+		}
+		if t.After(now) {
+			return t, nil
+		}
+		wall = next
+	}
+	return time.Time{}, fmt.Errorf("no run of %q after %s", expr, now.Format(time.RFC3339))
+}
+
+// wallClock returns the date and time of day of t as a UTC time.
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
 func (cs *CronService) recomputeNextRuns() {
 	now := time.Now().UnixMilli()
 	for i := range cs.store.Jobs {
@@ -350,6 +423,12 @@ func (cs *CronService) AddJob(
 	deliver bool,
 	channel, to string,
 ) (*CronJob, error) {
+	if schedule.TZ != "" {
+		if _, err := time.LoadLocation(schedule.TZ); err != nil {
+			return nil, fmt.Errorf("invalid timezone %q: %w", schedule.TZ, err)
+		}
+	}
+
 	cs.mu.Lock()
 	defer cs.mu.Unlock()
 
@@ -400,6 +479,53 @@ func (cs *CronService) UpdateJob(job *CronJob) error {
 	return fmt.Errorf("job not found")
 }
 
+// SyncJobs replaces all jobs whose ID starts with idPrefix with the given jobs.
+// Run state is kept for jobs whose schedule is unchanged. This is used for jobs
+// declared outside the store, such as skill manifests.
+func (cs *CronService) SyncJobs(idPrefix string, jobs []CronJob) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	now := time.Now().UnixMilli()
+	existing := make(map[string]CronJob)
+	kept := make([]CronJob, 0, len(cs.store.Jobs)+len(jobs))
+	for _, job := range cs.store.Jobs {
+		if strings.HasPrefix(job.ID, idPrefix) {
+			existing[job.ID] = job
+		} else {
+			kept = append(kept, job)
+		}
+	}
+
+	for _, job := range jobs {
+		if !strings.HasPrefix(job.ID, idPrefix) {
+			return fmt.Errorf("job %s does not match prefix %s", job.ID, idPrefix)
+		}
+		if old, ok := existing[job.ID]; ok && sameSchedule(old.Schedule, job.Schedule) {
+			job.State = old.State
+			job.CreatedAtMS = old.CreatedAtMS
+		} else {
+			job.State = CronJobState{}
+			job.CreatedAtMS = now
+		}
+		job.UpdatedAtMS = now
+		if job.Enabled && job.State.NextRunAtMS == nil {
+			job.State.NextRunAtMS = cs.computeNextRun(&job.Schedule, now)
+		}
+		kept = append(kept, job)
+	}
+
+	cs.store.Jobs = kept
+	return cs.saveStoreUnsafe()
+}
+
+func sameSchedule(a, b CronSchedule) bool {
+	eq := func(x, y *int64) bool {
+		return (x == nil && y == nil) || (x != nil && y != nil && *x == *y)
+	}
+	return a.Kind == b.Kind && a.Expr == b.Expr && a.TZ == b.TZ && eq(a.AtMS, b.AtMS) && eq(a.EveryMS, b.EveryMS)
+}
+
 func (cs *CronService) RemoveJob(jobID string) bool {
 	cs.mu.Lock()
 	defer cs.mu.Unlock()
@@ -420,7 +546,8 @@ func (cs *CronService) removeJobUnsafe(jobID string) bool {
 
 	if removed {
 		if err := cs.saveStoreUnsafe(); err != nil {
-			log.Printf("[cron] failed to save store after remove: %v", err)
+			logger.ErrorCF("cron", "Failed to save store after remove", map[string]any{"error": err.Error()})
+			reporting.CaptureError("cron", err, map[string]any{"store": cs.storePath})
 		}
 	}
 
@@ -444,7 +571,8 @@ func (cs *CronService) EnableJob(jobID string, enabled bool) *CronJob {
 			}
 
 			if err := cs.saveStoreUnsafe(); err != nil {
-				log.Printf("[cron] failed to save store after enable: %v", err)
+				logger.ErrorCF("cron", "Failed to save store after enable", map[string]any{"error": err.Error()})
+				reporting.CaptureError("cron", err, map[string]any{"store": cs.storePath})
 			}
 			return job
 		}
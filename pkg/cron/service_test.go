@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"testing"
+	"time"
 )
 
 func TestSaveStore_FilePermissions(t *testing.T) {
@@ -33,6 +34,142 @@ func TestSaveStore_FilePermissions(t *testing.T) {
 	}
 }
 
+func TestSyncJobs_ReplacesPrefixedJobs(t *testing.T) {
+	cs := NewCronService(filepath.Join(t.TempDir(), "jobs.json"), nil)
+
+	manual, err := cs.AddJob("manual", CronSchedule{Kind: "every", EveryMS: int64Ptr(60000)}, "hi", false, "cli", "direct")
+	if err != nil {
+		t.Fatalf("AddJob failed: %v", err)
+	}
+
+	nightly := CronJob{
+		ID:       SkillJobPrefix + "bank-sync",
+		Name:     "bank-sync",
+		Enabled:  true,
+		Schedule: CronSchedule{Kind: "cron", Expr: "0 2 * * *"},
+		Payload:  CronPayload{Kind: PayloadKindSkill, Skill: "bank-sync"},
+	}
+	stale := nightly
+	stale.ID = SkillJobPrefix + "removed"
+
+	if err := cs.SyncJobs(SkillJobPrefix, []CronJob{nightly, stale}); err != nil {
+		t.Fatalf("SyncJobs failed: %v", err)
+	}
+	first := cs.ListJobs(true)
+	if len(first) != 3 {
+		t.Fatalf("Expected 3 jobs, got %d", len(first))
+	}
+
+	if err := cs.SyncJobs(SkillJobPrefix, []CronJob{nightly}); err != nil {
+		t.Fatalf("SyncJobs failed: %v", err)
+	}
+	jobs := cs.ListJobs(true)
+	if len(jobs) != 2 {
+		t.Fatalf("Expected stale skill job to be removed, got %d jobs", len(jobs))
+	}
+	if jobs[0].ID != manual.ID {
+		t.Errorf("Expected manual job to be kept, got %s", jobs[0].ID)
+	}
+	if jobs[1].State.NextRunAtMS == nil || *jobs[1].State.NextRunAtMS != *first[1].State.NextRunAtMS {
+		t.Errorf("Expected unchanged schedule to keep its next run")
+	}
+
+	if err := cs.SyncJobs(SkillJobPrefix, []CronJob{{ID: "other"}}); err == nil {
+		t.Errorf("Expected error for job outside prefix")
+	}
+}
+
+func TestCheckJobs_FollowerWaits(t *testing.T) {
+	var ran []string
+	cs := NewCronService(filepath.Join(t.TempDir(), "jobs.json"), func(job *CronJob) (string, error) {
+		ran = append(ran, job.ID)
+		return "", nil
+	})
+	job, err := cs.AddJob("due", CronSchedule{Kind: "every", EveryMS: int64Ptr(60000)}, "hi", false, "cli", "direct")
+	if err != nil {
+		t.Fatalf("AddJob failed: %v", err)
+	}
+	leader := false
+	cs.SetLeaderCheck(func() bool { return leader })
+	cs.mu.Lock()
+	cs.running = true // run checkJobs by hand, without the ticker
+	past := int64(1)
+	cs.store.Jobs[0].State.NextRunAtMS = &past
+	cs.mu.Unlock()
+
+	cs.checkJobs()
+	if len(ran) != 0 {
+		t.Fatalf("Expected follower not to run jobs, ran %v", ran)
+	}
+
+	leader = true
+	cs.checkJobs()
+	if len(ran) != 1 || ran[0] != job.ID {
+		t.Errorf("Expected leader to run the due job once, ran %v", ran)
+	}
+}
+
 func int64Ptr(v int64) *int64 {
 	return &v
 }
+
+func TestNextTickIn_FollowsWallClockAcrossDST(t *testing.T) {
+	toronto, err := time.LoadLocation("America/Toronto")
+	if err != nil {
+		t.Skipf("no tzdata: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		expr string
+		now  time.Time
+		want time.Time
+	}{
+		{
+			"daily before spring forward",
+			"0 9 * * *",
+			time.Date(2026, 3, 7, 10, 0, 0, 0, toronto),
+			time.Date(2026, 3, 8, 9, 0, 0, 0, toronto),
+		},
+		{
+			"skipped time runs after the jump",
+			"30 2 * * *",
+			time.Date(2026, 3, 8, 1, 0, 0, 0, toronto),
+			time.Date(2026, 3, 8, 3, 0, 0, 0, toronto),
+		},
+		{
+			"repeated time runs once",
+			"30 1 * * *",
+			time.Date(2026, 11, 1, 1, 31, 0, 0, toronto), // first 01:31, EDT
+			time.Date(2026, 11, 2, 1, 30, 0, 0, toronto),
+		},
+	}
+	for _, tt := range tests {
+		got, err := nextTickIn(tt.expr, tt.now, toronto)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAddJob_SchedulesCronInItsTimezone(t *testing.T) {
+	cs := NewCronService(filepath.Join(t.TempDir(), "jobs.json"), nil)
+
+	job, err := cs.AddJob("tokyo", CronSchedule{Kind: "cron", Expr: "0 9 * * *", TZ: "Asia/Tokyo"},
+		"hi", true, "cli", "direct")
+	if err != nil {
+		t.Fatalf("AddJob failed: %v", err)
+	}
+	next := time.UnixMilli(*job.State.NextRunAtMS).UTC()
+	if next.Hour() != 0 || next.Minute() != 0 { // 09:00 JST is 00:00 UTC
+		t.Errorf("next run at %s, want 00:00 UTC", next)
+	}
+
+	if _, err := cs.AddJob("bad", CronSchedule{Kind: "cron", Expr: "0 9 * * *", TZ: "Mars/Base"},
+		"hi", true, "cli", "direct"); err == nil {
+		t.Error("expected an error for an unknown timezone")
+	}
+}
@@ -0,0 +1,395 @@
+// Package documents keeps an embedding index of the business documents the
+// agent answers from, such as contracts, price lists and policies.
+//
+// Each document is split into overlapping chunks, and each chunk is embedded
+// through the provider's Embedder. The index of a business lives in
+// <workspace>/memory/businesses/<id>/documents/index.json, next to its
+// memory; documents added without a business go to
+// <workspace>/memory/documents/index.json and are searched for every
+// business. Chunks keep their text, so a document can be embedded again
+// when the model changes.
+package documents
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	// DefaultChunkSize and DefaultChunkOverlap are used when Options leaves
+	// them zero, in characters.
+	DefaultChunkSize    = 1200
+	DefaultChunkOverlap = 200
+	// DefaultSearchLimit caps search results when no limit is given.
+	DefaultSearchLimit = 4
+	// MaxDocumentBytes bounds the text of one document.
+	MaxDocumentBytes = 4 << 20
+	// embedBatch is how many chunks are embedded per provider request.
+	embedBatch = 64
+)
+
+var (
+	// ErrNotFound is returned when a document is not in the index.
+	ErrNotFound = errors.New("document not found")
+	// ErrNotText is returned by Text for binary data.
+	ErrNotText = errors.New("not a text document")
+)
+
+// Options configure a Store. See config.DocumentsConfig.
+type Options struct {
+	ChunkSize    int
+	ChunkOverlap int
+}
+
+// Info describes an indexed document.
+type Info struct {
+	Name      string    `json:"name"`
+	SHA256    string    `json:"sha256"`
+	Model     string    `json:"model"`
+	Chunks    int       `json:"chunks"`
+	IndexedAt time.Time `json:"indexed_at"`
+	// Unchanged is set by Add when the document was already indexed with
+	// the same content and model.
+	Unchanged bool `json:"unchanged,omitempty"`
+}
+
+// Match is a chunk found by Search.
+type Match struct {
+	Document string  `json:"document"`
+	Text     string  `json:"text"`
+	Score    float64 `json:"score"`
+}
+
+type chunk struct {
+	Text   string    `json:"text"`
+	Vector []float32 `json:"vector"`
+}
+
+type document struct {
+	Name      string    `json:"name"`
+	SHA256    string    `json:"sha256"`
+	Model     string    `json:"model"`
+	IndexedAt time.Time `json:"indexed_at"`
+	Chunks    []chunk   `json:"chunks"`
+}
+
+func (d *document) info() Info {
+	return Info{Name: d.Name, SHA256: d.SHA256, Model: d.Model, Chunks: len(d.Chunks), IndexedAt: d.IndexedAt}
+}
+
+type index struct {
+	Documents []*document `json:"documents"`
+
+	modTime time.Time
+}
+
+// Store is the document index of a workspace. It is safe for concurrent
+// use, and picks up changes made by other processes, such as picoclaw
+// index while the gateway runs.
+type Store struct {
+	workspace string
+	provider  providers.LLMProvider
+	model     string
+	size      int
+	overlap   int
+
+	mu      sync.Mutex
+	indexes map[string]*index // by business ID
+}
+
+// New creates the Store of workspace, embedding with model through p.
+func New(workspace string, p providers.LLMProvider, model string, opts Options) *Store {
+	size := opts.ChunkSize
+	if size <= 0 {
+		size = DefaultChunkSize
+	}
+	overlap := opts.ChunkOverlap
+	if overlap < 0 || overlap >= size {
+		overlap = min(DefaultChunkOverlap, size/2)
+	}
+	return &Store{
+		workspace: workspace,
+		provider:  p,
+		model:     model,
+		size:      size,
+		overlap:   overlap,
+		indexes:   make(map[string]*index),
+	}
+}
+
+// Text returns data as text, or ErrNotText if it is not UTF-8 text.
+func Text(data []byte) (string, error) {
+	if !utf8.Valid(data) || bytes.IndexByte(data, 0) >= 0 {
+		return "", ErrNotText
+	}
+	return strings.TrimPrefix(string(data), "\ufeff"), nil
+}
+
+// Add indexes text under name for businessID, replacing the document of
+// that name. A document with the same content and model is left alone.
+func (s *Store) Add(ctx context.Context, businessID, name, text string) (Info, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return Info{}, errors.New("document name is required")
+	}
+	if len(text) > MaxDocumentBytes {
+		return Info{}, fmt.Errorf("document is larger than %d MB", MaxDocumentBytes>>20)
+	}
+	sum := sha256.Sum256([]byte(text))
+	hash := hex.EncodeToString(sum[:])
+
+	s.mu.Lock()
+	idx, err := s.load(businessID)
+	if err == nil {
+		if i := idx.find(name); i >= 0 && idx.Documents[i].SHA256 == hash && idx.Documents[i].Model == s.model {
+			info := idx.Documents[i].info()
+			info.Unchanged = true
+			s.mu.Unlock()
+			return info, nil
+		}
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return Info{}, err
+	}
+
+	// Embed without the lock; it takes a provider round trip per batch
+	texts := Split(text, s.size, s.overlap)
+	if len(texts) == 0 {
+		return Info{}, errors.New("document has no text")
+	}
+	doc := &document{Name: name, SHA256: hash, Model: s.model, Chunks: make([]chunk, 0, len(texts))}
+	for start := 0; start < len(texts); start += embedBatch {
+		batch := texts[start:min(start+embedBatch, len(texts))]
+		vectors, err := providers.Embed(ctx, s.provider, batch, s.model)
+		if err != nil {
+			return Info{}, fmt.Errorf("embedding %s: %w", name, err)
+		}
+		if len(vectors) != len(batch) {
+			return Info{}, fmt.Errorf("embedding %s: got %d vectors for %d chunks", name, len(vectors), len(batch))
+		}
+		for i, v := range vectors {
+			doc.Chunks = append(doc.Chunks, chunk{Text: batch[i], Vector: normalize(v)})
+		}
+	}
+	doc.IndexedAt = time.Now().UTC()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	idx, err = s.load(businessID)
+	if err != nil {
+		return Info{}, err
+	}
+	if i := idx.find(name); i >= 0 {
+		idx.Documents[i] = doc
+	} else {
+		idx.Documents = append(idx.Documents, doc)
+	}
+	if err := s.save(businessID, idx); err != nil {
+		return Info{}, err
+	}
+	return doc.info(), nil
+}
+
+// Remove deletes the document called name from businessID's index.
+func (s *Store) Remove(businessID, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	idx, err := s.load(businessID)
+	if err != nil {
+		return err
+	}
+	i := idx.find(name)
+	if i < 0 {
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	idx.Documents = slices.Delete(idx.Documents, i, i+1)
+	return s.save(businessID, idx)
+}
+
+// List returns the documents of businessID's index, by name.
+func (s *Store) List(businessID string) ([]Info, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	idx, err := s.load(businessID)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]Info, 0, len(idx.Documents))
+	for _, d := range idx.Documents {
+		infos = append(infos, d.info())
+	}
+	slices.SortFunc(infos, func(a, b Info) int { return strings.Compare(a.Name, b.Name) })
+	return infos, nil
+}
+
+// Search returns the limit chunks closest to query from businessID's
+// documents and the shared ones, best first. Documents embedded with
+// another model are skipped until they are indexed again.
+func (s *Store) Search(ctx context.Context, businessID, query string, limit int) ([]Match, error) {
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+	vectors, err := providers.Embed(ctx, s.provider, []string{query}, s.model)
+	if err != nil {
+		return nil, fmt.Errorf("embedding query: %w", err)
+	}
+	if len(vectors) != 1 {
+		return nil, fmt.Errorf("embedding query: got %d vectors", len(vectors))
+	}
+	q := normalize(vectors[0])
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	scopes := []string{""}
+	if businessID != "" {
+		scopes = append(scopes, businessID)
+	}
+	var matches []Match
+	stale := 0
+	for _, scope := range scopes {
+		idx, err := s.load(scope)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range idx.Documents {
+			if d.Model != s.model {
+				stale++
+				continue
+			}
+			for _, c := range d.Chunks {
+				if len(c.Vector) != len(q) {
+					continue
+				}
+				matches = append(matches, Match{Document: d.Name, Text: c.Text, Score: dot(q, c.Vector)})
+			}
+		}
+	}
+	if stale > 0 {
+		logger.WarnCF("documents", "Skipping documents embedded with another model; index them again",
+			map[string]any{"documents": stale, "model": s.model})
+	}
+	slices.SortStableFunc(matches, func(a, b Match) int {
+		switch {
+		case a.Score > b.Score:
+			return -1
+		case a.Score < b.Score:
+			return 1
+		}
+		return 0
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
+
+func (idx *index) find(name string) int {
+	return slices.IndexFunc(idx.Documents, func(d *document) bool { return d.Name == name })
+}
+
+// path returns the index file of businessID.
+func (s *Store) path(businessID string) string {
+	if businessID == "" {
+		return filepath.Join(s.workspace, "memory", "documents", "index.json")
+	}
+	return filepath.Join(s.workspace, "memory", "businesses", utils.BusinessDirName(businessID),
+		"documents", "index.json")
+}
+
+// load returns businessID's index, reading it again if the file changed.
+// s.mu must be held.
+func (s *Store) load(businessID string) (*index, error) {
+	path := s.path(businessID)
+	fi, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		idx := &index{}
+		s.indexes[businessID] = idx
+		return idx, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if idx, ok := s.indexes[businessID]; ok && idx.modTime.Equal(fi.ModTime()) {
+		return idx, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	idx := &index{}
+	if err := json.Unmarshal(data, idx); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	idx.modTime = fi.ModTime()
+	s.indexes[businessID] = idx
+	return idx, nil
+}
+
+// save writes idx as businessID's index. s.mu must be held.
+func (s *Store) save(businessID string, idx *index) error {
+	path := s.path(businessID)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if fi, err := os.Stat(path); err == nil {
+		idx.modTime = fi.ModTime()
+	}
+	s.indexes[businessID] = idx
+	return nil
+}
+
+// normalize scales v to unit length, so a dot product is the cosine
+// similarity.
+func normalize(v []float32) []float32 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return v
+	}
+	norm := float32(1 / math.Sqrt(sum))
+	out := make([]float32, len(v))
+	for i, x := range v {
+		out[i] = x * norm
+	}
+	return out
+}
+
+func dot(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
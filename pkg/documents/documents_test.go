@@ -0,0 +1,137 @@
+package documents
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// letterEmbedder embeds text as its letter counts, so texts sharing words
+// score high.
+type letterEmbedder struct {
+	calls int
+}
+
+func (e *letterEmbedder) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	options map[string]any,
+) (*providers.LLMResponse, error) {
+	return &providers.LLMResponse{}, nil
+}
+
+func (e *letterEmbedder) GetDefaultModel() string { return "" }
+
+func (e *letterEmbedder) Embed(ctx context.Context, texts []string, model string) ([][]float32, error) {
+	e.calls++
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		v := make([]float32, 26)
+		for _, r := range strings.ToLower(text) {
+			if r >= 'a' && r <= 'z' {
+				v[r-'a']++
+			}
+		}
+		vectors[i] = v
+	}
+	return vectors, nil
+}
+
+func TestSplit(t *testing.T) {
+	text := "one two three four five six seven eight nine ten"
+	chunks := Split(text, 14, 6)
+	require.NotEmpty(t, chunks)
+	assert.Equal(t, "one two three", chunks[0])
+	assert.Equal(t, "three four", chunks[1])
+	assert.Equal(t, "ten", chunks[len(chunks)-1][len(chunks[len(chunks)-1])-3:])
+	for _, c := range chunks {
+		assert.LessOrEqual(t, len(c), 14, c)
+	}
+
+	assert.Equal(t, []string{"supercalifragilistic", "x"}, Split("supercalifragilistic x", 5, 2))
+	assert.Empty(t, Split("  \n ", 10, 2))
+	assert.Equal(t, []string{"line one\nline two"}, Split("line one\nline two\n", 100, 10))
+}
+
+func TestStore(t *testing.T) {
+	embedder := &letterEmbedder{}
+	workspace := t.TempDir()
+	s := New(workspace, embedder, "letters", Options{ChunkSize: 25, ChunkOverlap: 0})
+	ctx := context.Background()
+
+	info, err := s.Add(ctx, "biz-1", "prices.md", "Widgets cost 12 dollars.\nGizmos cost 30 dollars.")
+	require.NoError(t, err)
+	assert.Equal(t, 2, info.Chunks)
+	_, err = s.Add(ctx, "", "policy.txt", "Refunds are accepted within thirty days.")
+	require.NoError(t, err)
+	_, err = s.Add(ctx, "biz-2", "other.txt", "Widgets cost 99 dollars at the other business.")
+	require.NoError(t, err)
+
+	calls := embedder.calls
+	info, err = s.Add(ctx, "biz-1", "prices.md", "Widgets cost 12 dollars.\nGizmos cost 30 dollars.")
+	require.NoError(t, err)
+	assert.True(t, info.Unchanged)
+	assert.Equal(t, calls, embedder.calls, "unchanged documents are not embedded again")
+
+	matches, err := s.Search(ctx, "biz-1", "gizmos cost", 10)
+	require.NoError(t, err)
+	require.Len(t, matches, 4)
+	assert.Equal(t, "Gizmos cost 30 dollars.", matches[0].Text)
+	for _, m := range matches {
+		assert.NotEqual(t, "other.txt", m.Document, "another business's documents are never searched")
+	}
+
+	// A second store sees the same files, like the gateway after picoclaw index
+	other := New(workspace, embedder, "letters", Options{})
+	infos, err := other.List("biz-1")
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+	assert.Equal(t, "prices.md", infos[0].Name)
+	require.NoError(t, other.Remove("biz-1", "prices.md"))
+	assert.ErrorIs(t, other.Remove("biz-1", "prices.md"), ErrNotFound)
+	matches, err = s.Search(ctx, "biz-1", "gizmos", 10)
+	require.NoError(t, err)
+	require.Len(t, matches, 2)
+	assert.Equal(t, "policy.txt", matches[0].Document)
+
+	// Documents embedded with another model are skipped
+	renamed := New(workspace, embedder, "letters-v2", Options{})
+	matches, err = renamed.Search(ctx, "", "refunds", 10)
+	require.NoError(t, err)
+	assert.Empty(t, matches)
+}
+
+func TestStoreWithoutEmbeddings(t *testing.T) {
+	s := New(t.TempDir(), &unsupportedProvider{}, "m", Options{})
+	_, err := s.Add(context.Background(), "", "a.txt", "text")
+	assert.ErrorIs(t, err, providers.ErrEmbeddingsUnsupported)
+}
+
+type unsupportedProvider struct{}
+
+func (p *unsupportedProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	options map[string]any,
+) (*providers.LLMResponse, error) {
+	return &providers.LLMResponse{}, nil
+}
+
+func (p *unsupportedProvider) GetDefaultModel() string { return "" }
+
+func TestText(t *testing.T) {
+	text, err := Text([]byte("\ufeffhello"))
+	require.NoError(t, err)
+	assert.Equal(t, "hello", text)
+	_, err = Text([]byte{0x89, 'P', 'N', 'G', 0})
+	assert.ErrorIs(t, err, ErrNotText)
+}
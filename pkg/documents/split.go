@@ -0,0 +1,39 @@
+package documents
+
+import (
+	"regexp"
+	"strings"
+)
+
+// wordRe matches a word and the whitespace after it, so chunks keep the
+// document's line breaks.
+var wordRe = regexp.MustCompile(`\S+\s*`)
+
+// Split cuts text into chunks of about size characters at word
+// boundaries. Each chunk starts with the last overlap characters of the one
+// before, so a sentence cut in two is whole in one of them. A word longer
+// than size is a chunk of its own.
+func Split(text string, size, overlap int) []string {
+	words := wordRe.FindAllString(text, -1)
+	var chunks []string
+	start := 0
+	for start < len(words) {
+		end, n := start, 0
+		for end < len(words) && (end == start || n+len(words[end]) <= size) {
+			n += len(words[end])
+			end++
+		}
+		chunks = append(chunks, strings.TrimSpace(strings.Join(words[start:end], "")))
+		if end == len(words) {
+			break
+		}
+		// Step back over whole words for the overlap, but always move on
+		next, back := end, 0
+		for next-1 > start && back+len(words[next-1]) <= overlap {
+			back += len(words[next-1])
+			next--
+		}
+		start = next
+	}
+	return chunks
+}
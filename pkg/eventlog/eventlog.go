@@ -0,0 +1,273 @@
+// Package eventlog is an append-only JSONL stream of agent activity: requests
+// received, provider calls, tool calls, responses sent and transactions
+// created. It is the record that metrics, audit queries, replay and exports
+// read from, rather than each keeping its own.
+//
+// Events are written to <workspace>/events/events.jsonl and rotated like the
+// other workspace logs. Every event carries a sequence number that keeps
+// increasing across rotations and restarts, so a consumer can remember the
+// last one it processed and resume from there with Read.
+package eventlog
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/logrotate"
+)
+
+// Event types.
+const (
+	TypeRequestReceived = "request_received"
+	TypeProviderCall    = "provider_call"
+	TypeToolCalled      = "tool_called"
+	TypeResponseSent    = "response_sent"
+)
+
+// TypeSessionDeleted is the receipt of a session deleted on request; its
+// data counts what was removed. The session's other events are gone by then.
+const TypeSessionDeleted = "session_deleted"
+
+// TypeTransactionCreated records a transaction picoclaw created in
+// LedgerForge; its data has the transaction's id, date, vendor, amount,
+// currency and category.
+const TypeTransactionCreated = "transaction_created"
+
+// Event is one line of the log.
+type Event struct {
+	Seq        uint64         `json:"seq"`
+	Time       time.Time      `json:"time"`
+	Type       string         `json:"type"`
+	RequestID  string         `json:"request_id,omitempty"`
+	AgentID    string         `json:"agent_id,omitempty"`
+	SessionKey string         `json:"session_key,omitempty"`
+	Channel    string         `json:"channel,omitempty"`
+	ChatID     string         `json:"chat_id,omitempty"`
+	BusinessID string         `json:"business_id,omitempty"`
+	Data       map[string]any `json:"data,omitempty"`
+}
+
+// Options configures a Log.
+type Options struct {
+	Rotation logrotate.Options
+	Sync     bool // fsync after every event, at the cost of flash wear
+}
+
+// Log appends events to the workspace event log.
+type Log struct {
+	path string
+	opts Options
+
+	mu     sync.Mutex
+	writer *logrotate.Writer
+	seq    uint64
+}
+
+// Open opens the event log of workspace and recovers the last sequence
+// number. A line left incomplete by a crash is terminated so the next event
+// starts on a line of its own; readers skip it.
+func Open(workspace string, opts Options) (*Log, error) {
+	l := &Log{
+		path: filepath.Join(workspace, "events", "events.jsonl"),
+		opts: opts,
+	}
+	files, err := logrotate.Files(l.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list event logs: %w", err)
+	}
+	// The newest file with an event holds the last sequence number.
+	for i := len(files) - 1; i >= 0 && l.seq == 0; i-- {
+		err := scan(files[i], func(e Event) error {
+			l.seq = max(l.seq, e.Seq)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	w, err := logrotate.Open(l.path, opts.Rotation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open event log: %w", err)
+	}
+	if partial, err := endsWithPartialLine(l.path); err != nil {
+		w.Close()
+		return nil, err
+	} else if partial {
+		if _, err := w.Write([]byte("\n")); err != nil {
+			w.Close()
+			return nil, fmt.Errorf("failed to repair event log: %w", err)
+		}
+	}
+	l.writer = w
+	return l, nil
+}
+
+// Append assigns the event the next sequence number, fills in the time if
+// unset, and writes it.
+func (l *Log) Append(e Event) (uint64, error) {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e.Seq = l.seq + 1
+	data, err := json.Marshal(e)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal event: %w", err)
+	}
+	if _, err := l.writer.Write(append(data, '\n')); err != nil {
+		return 0, fmt.Errorf("failed to write event: %w", err)
+	}
+	if l.opts.Sync {
+		if err := l.writer.Sync(); err != nil {
+			return 0, fmt.Errorf("failed to sync event log: %w", err)
+		}
+	}
+	l.seq = e.Seq
+	return e.Seq, nil
+}
+
+// AppendContext is Append with the request ID, business ID and session key
+// taken from ctx when the event does not set them.
+func (l *Log) AppendContext(ctx context.Context, e Event) (uint64, error) {
+	if e.RequestID == "" {
+		e.RequestID, _ = ctx.Value(constants.ContextKeyRequestID).(string)
+	}
+	if e.BusinessID == "" {
+		e.BusinessID, _ = ctx.Value(constants.ContextKeyBusinessID).(string)
+	}
+	if e.SessionKey == "" {
+		e.SessionKey, _ = ctx.Value(constants.ContextKeySessionKey).(string)
+	}
+	return l.Append(e)
+}
+
+// LastSeq returns the sequence number of the last event written.
+func (l *Log) LastSeq() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.seq
+}
+
+// Read calls fn for every event after sequence number since, oldest first,
+// including rotated files that still exist. It stops at the first error fn
+// returns.
+func (l *Log) Read(since uint64, fn func(Event) error) error {
+	files, err := logrotate.Files(l.path)
+	if err != nil {
+		return fmt.Errorf("failed to list event logs: %w", err)
+	}
+	for _, path := range files {
+		err := scan(path, func(e Event) error {
+			if e.Seq <= since {
+				return nil
+			}
+			return fn(e)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete rewrites the log without the events match selects, rotated files
+// included, and returns how many it removed. The other events keep their
+// sequence numbers. It is meant for privacy requests, not routine pruning.
+func (l *Log) Delete(match func(Event) bool) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	files, err := logrotate.Files(l.path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list event logs: %w", err)
+	}
+	// The writer reopens the current file on the next Append.
+	if err := l.writer.Close(); err != nil {
+		return 0, fmt.Errorf("failed to close event log: %w", err)
+	}
+	removed := 0
+	for _, path := range files {
+		n, err := rewrite(path, match)
+		removed += n
+		if err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
+
+// Close closes the current file.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.writer.Close()
+}
+
+// scan calls fn for each well-formed event in path.
+func scan(path string, fn func(Event) error) error {
+	r, err := logrotate.OpenReader(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil // rotated away since it was listed
+		}
+		return fmt.Errorf("failed to open event log: %w", err)
+	}
+	defer r.Close()
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil || e.Seq == 0 {
+			continue // skip partial or corrupt lines
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read event log: %w", err)
+	}
+	return nil
+}
+
+// rewrite replaces the file at path with a copy that lacks the events match
+// selects, and returns how many those were. Lines that aren't events are
+// kept.
+func rewrite(path string, match func(Event) bool) (int, error) {
+	return logrotate.DropLines(path, func(line []byte) bool {
+		var e Event
+		return json.Unmarshal(line, &e) == nil && e.Seq != 0 && match(e)
+	})
+}
+
+// endsWithPartialLine reports whether the file at path is non-empty and does
+// not end with a newline.
+func endsWithPartialLine(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.Size() == 0 {
+		return false, err
+	}
+	last := make([]byte, 1)
+	if _, err := f.ReadAt(last, info.Size()-1); err != nil && err != io.EOF {
+		return false, err
+	}
+	return last[0] != '\n', nil
+}
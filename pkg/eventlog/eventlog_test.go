@@ -0,0 +1,156 @@
+package eventlog
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/logrotate"
+)
+
+func readAll(t *testing.T, l *Log, since uint64) []Event {
+	t.Helper()
+	var events []Event
+	require.NoError(t, l.Read(since, func(e Event) error {
+		events = append(events, e)
+		return nil
+	}))
+	return events
+}
+
+func TestAppendAndRead(t *testing.T) {
+	l, err := Open(t.TempDir(), Options{})
+	require.NoError(t, err)
+	defer l.Close()
+
+	for _, typ := range []string{TypeRequestReceived, TypeProviderCall, TypeResponseSent} {
+		_, err := l.Append(Event{Type: typ, Channel: "telegram"})
+		require.NoError(t, err)
+	}
+	assert.Equal(t, uint64(3), l.LastSeq())
+
+	events := readAll(t, l, 0)
+	require.Len(t, events, 3)
+	assert.Equal(t, uint64(1), events[0].Seq)
+	assert.Equal(t, TypeRequestReceived, events[0].Type)
+	assert.False(t, events[0].Time.IsZero())
+
+	events = readAll(t, l, 2)
+	require.Len(t, events, 1)
+	assert.Equal(t, TypeResponseSent, events[0].Type)
+}
+
+func TestAppendContext(t *testing.T) {
+	l, err := Open(t.TempDir(), Options{Sync: true})
+	require.NoError(t, err)
+	defer l.Close()
+
+	ctx := context.WithValue(context.Background(), constants.ContextKeyRequestID, "req-1")
+	ctx = context.WithValue(ctx, constants.ContextKeySessionKey, "telegram:42")
+	_, err = l.AppendContext(ctx, Event{Type: TypeToolCalled, Data: map[string]any{"tool": "exec"}})
+	require.NoError(t, err)
+
+	events := readAll(t, l, 0)
+	require.Len(t, events, 1)
+	assert.Equal(t, "req-1", events[0].RequestID)
+	assert.Equal(t, "telegram:42", events[0].SessionKey)
+	assert.Equal(t, "exec", events[0].Data["tool"])
+}
+
+func TestSequenceSurvivesReopenAndRotation(t *testing.T) {
+	dir := t.TempDir()
+	l, err := Open(dir, Options{Rotation: logrotate.Options{MaxBytes: 200}})
+	require.NoError(t, err)
+	for range 10 {
+		_, err := l.Append(Event{Type: TypeProviderCall})
+		require.NoError(t, err)
+	}
+	require.NoError(t, l.Close())
+
+	l, err = Open(dir, Options{Rotation: logrotate.Options{MaxBytes: 200}})
+	require.NoError(t, err)
+	defer l.Close()
+	assert.Equal(t, uint64(10), l.LastSeq())
+
+	seq, err := l.Append(Event{Type: TypeProviderCall})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(11), seq)
+
+	events := readAll(t, l, 0)
+	require.Len(t, events, 11)
+	for i, e := range events {
+		assert.Equal(t, uint64(i+1), e.Seq)
+	}
+}
+
+func TestOpenRepairsPartialLine(t *testing.T) {
+	dir := t.TempDir()
+	l, err := Open(dir, Options{})
+	require.NoError(t, err)
+	_, err = l.Append(Event{Type: TypeRequestReceived})
+	require.NoError(t, err)
+	require.NoError(t, l.Close())
+
+	// Simulate a crash in the middle of writing the second event.
+	path := filepath.Join(dir, "events", "events.jsonl")
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"seq":2,"type":"provi`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	l, err = Open(dir, Options{})
+	require.NoError(t, err)
+	defer l.Close()
+	assert.Equal(t, uint64(1), l.LastSeq())
+
+	_, err = l.Append(Event{Type: TypeResponseSent})
+	require.NoError(t, err)
+
+	events := readAll(t, l, 0)
+	require.Len(t, events, 2)
+	assert.Equal(t, TypeResponseSent, events[1].Type)
+	assert.Equal(t, uint64(2), events[1].Seq)
+}
+
+func TestDelete(t *testing.T) {
+	dir := t.TempDir()
+	opts := Options{Rotation: logrotate.Options{MaxBytes: 300, Compress: true}}
+	l, err := Open(dir, opts)
+	require.NoError(t, err)
+	defer l.Close()
+
+	for i := range 12 {
+		session := "agent:main:telegram:1"
+		if i%3 == 0 {
+			session = "agent:main:telegram:2"
+		}
+		_, err := l.Append(Event{Type: TypeProviderCall, SessionKey: session})
+		require.NoError(t, err)
+	}
+	backups, err := logrotate.Backups(filepath.Join(dir, "events", "events.jsonl"))
+	require.NoError(t, err)
+	require.NotEmpty(t, backups, "the test needs rotated files")
+
+	removed, err := l.Delete(func(e Event) bool { return e.SessionKey == "agent:main:telegram:1" })
+	require.NoError(t, err)
+	assert.Equal(t, 8, removed)
+
+	events := readAll(t, l, 0)
+	require.Len(t, events, 4)
+	for i, e := range events {
+		assert.Equal(t, "agent:main:telegram:2", e.SessionKey)
+		assert.Equal(t, uint64(i*3+1), e.Seq)
+	}
+
+	// Appending continues the sequence in the reopened file.
+	seq, err := l.Append(Event{Type: TypeSessionDeleted})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(13), seq)
+	assert.Len(t, readAll(t, l, 0), 5)
+}
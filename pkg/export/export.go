@@ -0,0 +1,193 @@
+// Package export compiles the transactions picoclaw created in LedgerForge
+// from the event log, and writes them as CSV or OFX for other accounting
+// tools or for reconciling against LedgerForge.
+package export
+
+import (
+	"cmp"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/eventlog"
+)
+
+// Transaction is one transaction picoclaw created.
+type Transaction struct {
+	ID          string    `json:"transaction_id"`
+	Date        string    `json:"date"` // YYYY-MM-DD
+	Vendor      string    `json:"vendor"`
+	Description string    `json:"description,omitempty"`
+	Amount      string    `json:"amount"` // negative for expenses, as LedgerForge stores them
+	Currency    string    `json:"currency"`
+	Category    string    `json:"category,omitempty"`
+	GSTAmount   string    `json:"gst_amount,omitempty"`
+	PSTAmount   string    `json:"pst_amount,omitempty"`
+	Source      string    `json:"source"` // what created it, e.g. "receipt"
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Transactions returns the transactions created for businessID whose date
+// is from from to to (YYYY-MM-DD, inclusive; "" leaves that end open),
+// ordered by date. A transaction recorded more than once, like a receipt
+// uploaded again, is listed once, as last recorded.
+func Transactions(l *eventlog.Log, businessID, from, to string) ([]Transaction, error) {
+	byID := make(map[string]Transaction)
+	err := l.Read(0, func(e eventlog.Event) error {
+		if e.Type != eventlog.TypeTransactionCreated || e.BusinessID != businessID {
+			return nil
+		}
+		tx := Transaction{
+			ID:          str(e.Data, "transaction_id"),
+			Date:        str(e.Data, "date"),
+			Vendor:      str(e.Data, "vendor"),
+			Description: str(e.Data, "description"),
+			Amount:      str(e.Data, "amount"),
+			Currency:    str(e.Data, "currency"),
+			Category:    str(e.Data, "category"),
+			GSTAmount:   str(e.Data, "gst_amount"),
+			PSTAmount:   str(e.Data, "pst_amount"),
+			Source:      str(e.Data, "source"),
+			CreatedAt:   e.Time,
+		}
+		if tx.ID == "" || from != "" && tx.Date < from || to != "" && tx.Date > to {
+			return nil
+		}
+		byID[tx.ID] = tx
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	txs := make([]Transaction, 0, len(byID))
+	for _, tx := range byID {
+		txs = append(txs, tx)
+	}
+	slices.SortFunc(txs, func(a, b Transaction) int {
+		return cmp.Or(strings.Compare(a.Date, b.Date), a.CreatedAt.Compare(b.CreatedAt), strings.Compare(a.ID, b.ID))
+	})
+	return txs, nil
+}
+
+func str(data map[string]any, key string) string {
+	s, _ := data[key].(string)
+	return s
+}
+
+// csvHeader names the columns WriteCSV writes.
+var csvHeader = []string{
+	"date", "transaction_id", "vendor", "description", "amount", "currency",
+	"category", "gst_amount", "pst_amount", "source",
+}
+
+// WriteCSV writes txs as CSV with a header row.
+func WriteCSV(w io.Writer, txs []Transaction) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, tx := range txs {
+		err := cw.Write([]string{
+			tx.Date, tx.ID, tx.Vendor, tx.Description, tx.Amount, tx.Currency,
+			tx.Category, tx.GSTAmount, tx.PSTAmount, tx.Source,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Statement is what an OFX file says about the account its transactions
+// belong to.
+type Statement struct {
+	BusinessID string
+	Currency   string // the statement's default currency
+	From, To   string // YYYY-MM-DD; empty ends are taken from the transactions
+	Now        time.Time
+}
+
+// WriteOFX writes txs as an OFX 2.2 bank statement of the business, which
+// accounting tools import like a download from a bank. Each transaction's
+// LedgerForge id is its FITID, so importing an overlapping period again
+// doesn't duplicate anything.
+func WriteOFX(w io.Writer, st Statement, txs []Transaction) error {
+	from, to := st.From, st.To
+	if len(txs) > 0 {
+		from = cmp.Or(from, txs[0].Date)
+		to = cmp.Or(to, txs[len(txs)-1].Date)
+	}
+	from = cmp.Or(from, st.Now.Format(time.DateOnly))
+	to = cmp.Or(to, st.Now.Format(time.DateOnly))
+
+	b := &strings.Builder{}
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="no"?>` + "\n")
+	b.WriteString(`<?OFX OFXHEADER="200" VERSION="220" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>` + "\n")
+	b.WriteString("<OFX>\n")
+	b.WriteString("<SIGNONMSGSRSV1><SONRS><STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS>")
+	fmt.Fprintf(b, "<DTSERVER>%s</DTSERVER><LANGUAGE>ENG</LANGUAGE></SONRS></SIGNONMSGSRSV1>\n",
+		st.Now.UTC().Format("20060102150405"))
+	b.WriteString("<BANKMSGSRSV1><STMTTRNRS><TRNUID>0</TRNUID>")
+	b.WriteString("<STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS>\n<STMTRS>")
+	fmt.Fprintf(b, "<CURDEF>%s</CURDEF>\n", escape(st.Currency))
+	fmt.Fprintf(b, "<BANKACCTFROM><BANKID>picoclaw</BANKID><ACCTID>%s</ACCTID><ACCTTYPE>CHECKING</ACCTTYPE>"+
+		"</BANKACCTFROM>\n", escape(st.BusinessID))
+	fmt.Fprintf(b, "<BANKTRANLIST><DTSTART>%s</DTSTART><DTEND>%s</DTEND>\n", ofxDate(from), ofxDate(to))
+	for _, tx := range txs {
+		trnType := "DEBIT"
+		if !strings.HasPrefix(tx.Amount, "-") {
+			trnType = "CREDIT"
+		}
+		b.WriteString("<STMTTRN>")
+		fmt.Fprintf(b, "<TRNTYPE>%s</TRNTYPE><DTPOSTED>%s</DTPOSTED><TRNAMT>%s</TRNAMT><FITID>%s</FITID>",
+			trnType, ofxDate(tx.Date), escape(tx.Amount), escape(tx.ID))
+		fmt.Fprintf(b, "<NAME>%s</NAME>", escape(truncate(tx.Vendor, 32)))
+		memo := nonEmpty(tx.Category, tx.Description)
+		if tx.Currency != "" && tx.Currency != st.Currency {
+			// No exchange rate is known, so the amount is only flagged.
+			memo = append(memo, "in "+tx.Currency)
+		}
+		if len(memo) > 0 {
+			fmt.Fprintf(b, "<MEMO>%s</MEMO>", escape(truncate(strings.Join(memo, " - "), 255)))
+		}
+		b.WriteString("</STMTTRN>\n")
+	}
+	b.WriteString("</BANKTRANLIST>\n</STMTRS></STMTTRNRS></BANKMSGSRSV1>\n</OFX>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// ofxDate turns YYYY-MM-DD into OFX's YYYYMMDD.
+func ofxDate(date string) string {
+	return strings.ReplaceAll(date, "-", "")
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// truncate shortens s to at most n runes, the length OFX allows a field.
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
+
+func nonEmpty(values ...string) []string {
+	var out []string
+	for _, v := range values {
+		if v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
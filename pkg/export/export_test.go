@@ -0,0 +1,90 @@
+package export
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/eventlog"
+)
+
+func created(businessID, id, date, vendor, amount string) eventlog.Event {
+	return eventlog.Event{
+		Type:       eventlog.TypeTransactionCreated,
+		BusinessID: businessID,
+		Data: map[string]any{
+			"transaction_id": id,
+			"source":         "receipt",
+			"date":           date,
+			"vendor":         vendor,
+			"description":    "Receipt capture - " + vendor,
+			"amount":         amount,
+			"currency":       "CAD",
+			"category":       "Meals",
+		},
+	}
+}
+
+func TestTransactions(t *testing.T) {
+	l, err := eventlog.Open(t.TempDir(), eventlog.Options{})
+	require.NoError(t, err)
+	defer l.Close()
+	for _, e := range []eventlog.Event{
+		created("biz-1", "tx-2", "2026-03-05", "Corner Cafe", "-12.50"),
+		created("biz-1", "tx-1", "2026-03-02", "Shell", "-60.00"),
+		{Type: eventlog.TypeToolCalled, BusinessID: "biz-1"},
+		created("biz-2", "tx-3", "2026-03-03", "Other Co", "-1.00"),
+		created("biz-1", "tx-4", "2026-04-01", "Later Ltd", "-5.00"),
+		created("biz-1", "tx-2", "2026-03-05", "Corner Cafe Inc", "-12.50"),
+	} {
+		_, err := l.Append(e)
+		require.NoError(t, err)
+	}
+
+	txs, err := Transactions(l, "biz-1", "2026-03-01", "2026-03-31")
+	require.NoError(t, err)
+	require.Len(t, txs, 2)
+	assert.Equal(t, "tx-1", txs[0].ID)
+	assert.Equal(t, "tx-2", txs[1].ID)
+	assert.Equal(t, "Corner Cafe Inc", txs[1].Vendor, "the last record of a transaction wins")
+
+	txs, err = Transactions(l, "biz-1", "", "")
+	require.NoError(t, err)
+	assert.Len(t, txs, 3)
+}
+
+func TestWriteCSV(t *testing.T) {
+	var b strings.Builder
+	require.NoError(t, WriteCSV(&b, []Transaction{{
+		ID: "tx-1", Date: "2026-03-02", Vendor: "Smith, Jones & Co", Amount: "-60.00", Currency: "CAD",
+		Category: "Fuel", GSTAmount: "2.86", PSTAmount: "0.00", Source: "receipt",
+	}}))
+	assert.Equal(t, "date,transaction_id,vendor,description,amount,currency,category,gst_amount,pst_amount,source\n"+
+		"2026-03-02,tx-1,\"Smith, Jones & Co\",,-60.00,CAD,Fuel,2.86,0.00,receipt\n", b.String())
+}
+
+func TestWriteOFX(t *testing.T) {
+	var b strings.Builder
+	require.NoError(t, WriteOFX(&b, Statement{
+		BusinessID: "biz-1",
+		Currency:   "CAD",
+		From:       "2026-03-01",
+		Now:        time.Date(2026, 4, 1, 12, 0, 0, 0, time.UTC),
+	}, []Transaction{
+		{ID: "tx-1", Date: "2026-03-02", Vendor: "A&W", Amount: "-9.99", Currency: "CAD", Category: "Meals"},
+		{ID: "tx-2", Date: "2026-03-05", Vendor: "Refund Co", Amount: "15.00", Currency: "USD"},
+	}))
+	out := b.String()
+	assert.Contains(t, out, `<?OFX OFXHEADER="200" VERSION="220"`)
+	assert.Contains(t, out, "<DTSERVER>20260401120000</DTSERVER>")
+	assert.Contains(t, out, "<CURDEF>CAD</CURDEF>")
+	assert.Contains(t, out, "<ACCTID>biz-1</ACCTID>")
+	assert.Contains(t, out, "<DTSTART>20260301</DTSTART><DTEND>20260305</DTEND>")
+	assert.Contains(t, out, "<STMTTRN><TRNTYPE>DEBIT</TRNTYPE><DTPOSTED>20260302</DTPOSTED><TRNAMT>-9.99</TRNAMT>"+
+		"<FITID>tx-1</FITID><NAME>A&amp;W</NAME><MEMO>Meals</MEMO></STMTTRN>")
+	assert.Contains(t, out, "<TRNTYPE>CREDIT</TRNTYPE>")
+	assert.Contains(t, out, "<MEMO>in USD</MEMO>")
+}
@@ -0,0 +1,217 @@
+// Package federation delegates requests to other picoclaw instances, such as
+// heavy jobs from a home device to a beefier server. Rules pick the requests
+// to delegate and the peer to send them to; the peer answers them through
+// its POST /federation/delegate endpoint.
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/httpclient"
+)
+
+const (
+	// Channel is the channel delegated requests are processed on. Requests
+	// arriving on it are never delegated again.
+	Channel = "federation"
+	// DelegatePath is where an instance accepts delegated requests.
+	DelegatePath = "/federation/delegate"
+
+	// defaultTimeout bounds a delegated request when its peer sets no
+	// timeout. It leaves room for the peer's own two minute limit.
+	defaultTimeout = 150 * time.Second
+)
+
+// Request is a request to delegate. Session identifies the conversation on
+// the origin, so follow-ups share the peer's history; UserToken is the
+// caller's LedgerForge token, for the peer's tools to call as.
+type Request struct {
+	Session    string
+	Message    string
+	BusinessID string
+	UserToken  string
+	Media      []string // local paths of the attachments
+}
+
+// Router matches requests against the rules and delegates them to peers.
+type Router struct {
+	origin string
+	peers  map[string]config.FederationPeer
+	rules  []config.FederationRule
+	client *http.Client
+}
+
+// New builds a router for cfg. origin identifies this instance to its peers.
+// Every rule must name a configured peer.
+func New(cfg config.FederationConfig, origin string) (*Router, error) {
+	r := &Router{
+		origin: origin,
+		peers:  make(map[string]config.FederationPeer, len(cfg.Peers)),
+		rules:  cfg.Rules,
+		// Requests are bounded by their peer's timeout instead.
+		client: httpclient.New(0),
+	}
+	for _, peer := range cfg.Peers {
+		if peer.Name == "" || peer.URL == "" {
+			return nil, fmt.Errorf("federation peer %q: name and url are required", peer.Name)
+		}
+		r.peers[peer.Name] = peer
+	}
+	for i, rule := range cfg.Rules {
+		if _, ok := r.peers[rule.Peer]; !ok {
+			return nil, fmt.Errorf("federation rule %d: unknown peer %q", i+1, rule.Peer)
+		}
+	}
+	return r, nil
+}
+
+// Route returns the peer of the first rule a request on channel with content
+// and media attachments meets. Requests on Channel are never routed.
+func (r *Router) Route(channel, content string, media int) (config.FederationPeer, bool) {
+	if channel == Channel {
+		return config.FederationPeer{}, false
+	}
+	for _, rule := range r.rules {
+		if matches(rule, channel, content, media) {
+			return r.peers[rule.Peer], true
+		}
+	}
+	return config.FederationPeer{}, false
+}
+
+func matches(rule config.FederationRule, channel, content string, media int) bool {
+	if rule.MinChars > 0 && utf8.RuneCountInString(content) < rule.MinChars {
+		return false
+	}
+	if rule.Media && media == 0 {
+		return false
+	}
+	if len(rule.Channels) > 0 && !contains(rule.Channels, channel) {
+		return false
+	}
+	if len(rule.Keywords) == 0 {
+		return true
+	}
+	lower := strings.ToLower(content)
+	for _, keyword := range rule.Keywords {
+		if keyword != "" && strings.Contains(lower, strings.ToLower(keyword)) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// delegateResponse is the peer's answer, shaped like a webhook response.
+type delegateResponse struct {
+	Response *string `json:"response"`
+	Error    *string `json:"error"`
+}
+
+// Delegate sends req to peer and returns its answer. The attachments are
+// uploaded with the request.
+func (r *Router) Delegate(ctx context.Context, peer config.FederationPeer, req Request) (string, error) {
+	timeout := defaultTimeout
+	if peer.TimeoutSeconds > 0 {
+		timeout = time.Duration(peer.TimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// The body is streamed, so attachments are not buffered in memory.
+	body, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		pw.CloseWithError(r.writeForm(mw, req))
+	}()
+	defer body.Close()
+
+	url := strings.TrimRight(peer.URL, "/") + DelegatePath
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, body)
+	if err != nil {
+		return "", fmt.Errorf("federation peer %s: %w", peer.Name, err)
+	}
+	httpReq.Header.Set("Content-Type", mw.FormDataContentType())
+	httpReq.Header.Set("Authorization", "Bearer "+peer.Token)
+	if requestID, _ := ctx.Value(constants.ContextKeyRequestID).(string); requestID != "" {
+		httpReq.Header.Set(constants.RequestIDHeader, requestID)
+	}
+
+	resp, err := r.client.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("federation peer %s: %w", peer.Name, err)
+	}
+	defer resp.Body.Close()
+	var out delegateResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&out); err != nil {
+		return "", fmt.Errorf("federation peer %s: %s: invalid response", peer.Name, resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK || out.Response == nil {
+		msg := resp.Status
+		if out.Error != nil {
+			msg = *out.Error
+		}
+		return "", fmt.Errorf("federation peer %s: %s", peer.Name, msg)
+	}
+	return *out.Response, nil
+}
+
+// writeForm writes req as a multipart form. The business precedes the files,
+// which the peer saves for it.
+func (r *Router) writeForm(mw *multipart.Writer, req Request) error {
+	fields := [][2]string{
+		{"origin", r.origin},
+		{"session", req.Session},
+		{"message", req.Message},
+		{"business_id", req.BusinessID},
+		{"user_token", req.UserToken},
+	}
+	for _, field := range fields {
+		if field[1] == "" {
+			continue
+		}
+		if err := mw.WriteField(field[0], field[1]); err != nil {
+			return err
+		}
+	}
+	for _, path := range req.Media {
+		if err := writeFile(mw, path); err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+func writeFile(mw *multipart.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	part, err := mw.CreateFormFile("file", filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(part, f)
+	return err
+}
@@ -0,0 +1,79 @@
+package federation
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestRoute(t *testing.T) {
+	router, err := New(config.FederationConfig{
+		Peers: []config.FederationPeer{
+			{Name: "server", URL: "http://server"},
+			{Name: "gpu", URL: "http://gpu"},
+		},
+		Rules: []config.FederationRule{
+			{Peer: "gpu", Media: true, Channels: []string{"telegram"}},
+			{Peer: "server", Keywords: []string{"Report"}},
+			{Peer: "server", MinChars: 20},
+		},
+	}, "home")
+	require.NoError(t, err)
+
+	tests := []struct {
+		channel, content string
+		media            int
+		peer             string
+	}{
+		{"telegram", "scan this", 1, "gpu"},
+		{"slack", "scan this", 1, ""},
+		{"slack", "monthly report please", 0, "server"},
+		{"slack", "a request of more than twenty characters", 0, "server"},
+		{"slack", "hi", 0, ""},
+		{Channel, "monthly report please", 0, ""},
+	}
+	for _, tt := range tests {
+		peer, ok := router.Route(tt.channel, tt.content, tt.media)
+		assert.Equal(t, tt.peer != "", ok, tt.content)
+		assert.Equal(t, tt.peer, peer.Name, tt.content)
+	}
+
+	_, err = New(config.FederationConfig{Rules: []config.FederationRule{{Peer: "nope"}}}, "home")
+	assert.ErrorContains(t, err, `unknown peer "nope"`)
+}
+
+func TestDelegate(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, DelegatePath, r.URL.Path)
+		assert.Equal(t, "Bearer pc_token", r.Header.Get("Authorization"))
+		assert.Equal(t, "home", r.FormValue("origin"))
+		assert.Equal(t, "agent:main:main", r.FormValue("session"))
+		assert.Equal(t, "biz-1", r.FormValue("business_id"))
+		if r.FormValue("message") == "fail" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"response": null, "error": "picoclaw is in maintenance mode"}`))
+			return
+		}
+		w.Write([]byte(`{"response": "answered: ` + r.FormValue("message") + `"}`))
+	}))
+	defer srv.Close()
+
+	peer := config.FederationPeer{Name: "server", URL: srv.URL + "/", Token: "pc_token"}
+	router, err := New(config.FederationConfig{Peers: []config.FederationPeer{peer}}, "home")
+	require.NoError(t, err)
+
+	req := Request{Session: "agent:main:main", Message: "hello", BusinessID: "biz-1"}
+	response, err := router.Delegate(context.Background(), peer, req)
+	require.NoError(t, err)
+	assert.Equal(t, "answered: hello", response)
+
+	req.Message = "fail"
+	_, err = router.Delegate(context.Background(), peer, req)
+	assert.EqualError(t, err, "federation peer server: picoclaw is in maintenance mode")
+}
@@ -0,0 +1,506 @@
+// Package graphql runs GraphQL requests against a schema of Go resolvers.
+//
+// It implements the parts of the language clients use day to day:
+// queries, mutations and subscriptions, aliases, arguments and variables,
+// fragments, @skip and @include, and __typename. There is no introspection;
+// servers publish their schema as SDL instead. Types are only checked as far
+// as field selection goes: resolvers validate their own arguments.
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// Schema is the root types of an API. Mutation and Subscription may be nil.
+type Schema struct {
+	Query        *Object
+	Mutation     *Object
+	Subscription *Object
+}
+
+// Object is an object type.
+type Object struct {
+	Name   string
+	Fields map[string]*Field
+}
+
+// Resolver returns the value of a field of source, the value of the
+// enclosing object. Subscription resolvers return a <-chan any, each
+// value of which is sent to the subscriber as a result.
+type Resolver func(ctx context.Context, source any, args Args) (any, error)
+
+// Field is a field of an object type.
+type Field struct {
+	// Resolve may be nil to read the source's JSON property named by the
+	// snake_cased field name, so most Go structs need no resolvers.
+	Resolve Resolver
+	// Type is the object type of the value, or of its elements if it is a
+	// slice; nil for scalars, which are returned as their JSON encoding.
+	Type *Object
+}
+
+// Request is a GraphQL request, as POSTed by clients.
+type Request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+// Response is the result of a request.
+type Response struct {
+	Data   any     `json:"data"`
+	Errors []Error `json:"errors,omitempty"`
+}
+
+// Error is a request error, or a field error at Path.
+type Error struct {
+	Message string `json:"message"`
+	Path    []any  `json:"path,omitempty"`
+}
+
+// ErrorResponse returns a response for a request that could not be run.
+func ErrorResponse(err error) *Response {
+	return &Response{Errors: []Error{{Message: err.Error()}}}
+}
+
+// Operation is a request ready to run.
+type Operation struct {
+	// Type is "query", "mutation" or "subscription".
+	Type string
+
+	root      *Object
+	sel       []*selection
+	fragments map[string]*fragment
+	vars      map[string]any
+}
+
+// Prepare parses a request, picks the operation to run and checks its
+// selections against the schema.
+func (s *Schema) Prepare(req Request) (*Operation, error) {
+	doc, err := parse(req.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	var op *operation
+	for _, o := range doc.operations {
+		if req.OperationName == "" || o.name == req.OperationName {
+			if op != nil {
+				return nil, errors.New("operationName is required for a document with several operations")
+			}
+			op = o
+		}
+	}
+	if op == nil {
+		return nil, fmt.Errorf("unknown operation %q", req.OperationName)
+	}
+
+	root := s.Query
+	switch op.kind {
+	case "mutation":
+		root = s.Mutation
+	case "subscription":
+		root = s.Subscription
+	}
+	if root == nil {
+		return nil, fmt.Errorf("schema does not support %ss", op.kind)
+	}
+
+	vars := make(map[string]any, len(op.vars))
+	for _, v := range op.vars {
+		value, ok := req.Variables[v.name]
+		if !ok && v.hasDef {
+			value, ok = v.def, true
+		}
+		if v.required && (!ok || value == nil) {
+			return nil, fmt.Errorf("variable $%s of type %s is required", v.name, v.typ)
+		}
+		vars[v.name] = value
+	}
+
+	o := &Operation{Type: op.kind, root: root, sel: op.sel, fragments: doc.fragments, vars: vars}
+	if err := o.validate(root, op.sel, map[string]bool{}); err != nil {
+		return nil, err
+	}
+	if op.kind == "subscription" && len(o.collect(root, op.sel)) != 1 {
+		return nil, errors.New("a subscription must select exactly one field")
+	}
+	return o, nil
+}
+
+// validate checks that the fields selected exist and that objects, and
+// only objects, have selection sets. inFragments guards against cycles.
+func (o *Operation) validate(t *Object, sels []*selection, inFragments map[string]bool) error {
+	for _, sel := range sels {
+		switch {
+		case sel.spread != "":
+			f := o.fragments[sel.spread]
+			if f == nil {
+				return fmt.Errorf("unknown fragment %q", sel.spread)
+			}
+			if inFragments[f.name] {
+				return fmt.Errorf("fragment %q spreads itself", f.name)
+			}
+			if f.on != t.Name {
+				return fmt.Errorf("fragment %q on %s cannot be spread in %s", f.name, f.on, t.Name)
+			}
+			inFragments[f.name] = true
+			err := o.validate(t, f.sel, inFragments)
+			delete(inFragments, f.name)
+			if err != nil {
+				return err
+			}
+		case sel.inline:
+			if sel.on != "" && sel.on != t.Name {
+				return fmt.Errorf("inline fragment on %s cannot be used in %s", sel.on, t.Name)
+			}
+			if err := o.validate(t, sel.sel, inFragments); err != nil {
+				return err
+			}
+		case sel.name == "__typename":
+			if sel.sel != nil {
+				return errors.New("field \"__typename\" cannot have a selection set")
+			}
+		default:
+			f := t.Fields[sel.name]
+			if f == nil {
+				return fmt.Errorf("cannot query field %q on type %s", sel.name, t.Name)
+			}
+			switch {
+			case f.Type != nil && sel.sel == nil:
+				return fmt.Errorf("field %q of type %s must have a selection of subfields", sel.name, f.Type.Name)
+			case f.Type == nil && sel.sel != nil:
+				return fmt.Errorf("field %q is a scalar and cannot have a selection set", sel.name)
+			case f.Type != nil:
+				if err := o.validate(f.Type, sel.sel, inFragments); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Execute runs a query or mutation. Field errors are reported in the
+// response alongside the data that could be resolved. Fields are resolved
+// one after another, in selection order.
+func (o *Operation) Execute(ctx context.Context) *Response {
+	if o.Type == "subscription" {
+		return ErrorResponse(errors.New("subscriptions must be run with Subscribe"))
+	}
+	e := &executor{op: o}
+	data := e.object(ctx, o.root, nil, o.sel, nil)
+	return &Response{Data: data, Errors: e.errors}
+}
+
+// Subscribe starts a subscription and returns its results, closed when the
+// resolver's channel is closed or ctx is done.
+func (o *Operation) Subscribe(ctx context.Context) (<-chan *Response, error) {
+	if o.Type != "subscription" {
+		return nil, fmt.Errorf("%s must be run with Execute", o.Type)
+	}
+	fields := o.collect(o.root, o.sel)
+	sel := fields[0]
+	args, err := o.args(sel.args)
+	if err != nil {
+		return nil, err
+	}
+	f := o.root.Fields[sel.name]
+	if f == nil || f.Resolve == nil {
+		return nil, fmt.Errorf("cannot subscribe to %q", sel.name)
+	}
+	value, err := f.Resolve(ctx, nil, args)
+	if err != nil {
+		return nil, err
+	}
+	source, ok := value.(<-chan any)
+	if !ok {
+		return nil, fmt.Errorf("subscription field %q did not return a channel", sel.name)
+	}
+
+	out := make(chan *Response)
+	go func() {
+		defer close(out)
+		for {
+			var item any
+			select {
+			case <-ctx.Done():
+				return
+			case item, ok = <-source:
+				if !ok {
+					return
+				}
+			}
+			e := &executor{op: o}
+			data := newOrderedMap()
+			data.set(sel.key(), e.complete(ctx, f.Type, item, sel.sel, []any{sel.key()}))
+			select {
+			case out <- &Response{Data: data, Errors: e.errors}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// collect flattens fragments and applies @skip and @include, returning the
+// fields of sels in order. Fields with the same response key are merged.
+func (o *Operation) collect(t *Object, sels []*selection) []*selection {
+	var fields []*selection
+	byKey := map[string]*selection{}
+	var walk func(sels []*selection)
+	walk = func(sels []*selection) {
+		for _, sel := range sels {
+			if !o.included(sel) {
+				continue
+			}
+			switch {
+			case sel.spread != "":
+				walk(o.fragments[sel.spread].sel)
+			case sel.inline:
+				if sel.on == "" || sel.on == t.Name {
+					walk(sel.sel)
+				}
+			default:
+				if prev := byKey[sel.key()]; prev != nil {
+					prev.sel = append(append([]*selection{}, prev.sel...), sel.sel...)
+					continue
+				}
+				copied := *sel
+				byKey[sel.key()] = &copied
+				fields = append(fields, &copied)
+			}
+		}
+	}
+	walk(sels)
+	return fields
+}
+
+func (o *Operation) included(sel *selection) bool {
+	for _, d := range sel.directives {
+		if d.name != "skip" && d.name != "include" {
+			continue
+		}
+		v, _ := o.value(d.args["if"])
+		if on, _ := v.(bool); on == (d.name == "skip") {
+			return false
+		}
+	}
+	return true
+}
+
+// args substitutes variables in a field's arguments.
+func (o *Operation) args(raw map[string]any) (Args, error) {
+	args := make(Args, len(raw))
+	for name, v := range raw {
+		value, err := o.value(v)
+		if err != nil {
+			return nil, err
+		}
+		args[name] = value
+	}
+	return args, nil
+}
+
+func (o *Operation) value(v any) (any, error) {
+	switch v := v.(type) {
+	case variable:
+		value, ok := o.vars[string(v)]
+		if !ok {
+			return nil, fmt.Errorf("variable $%s is not defined", v)
+		}
+		return value, nil
+	case enum:
+		return string(v), nil
+	case []any:
+		list := make([]any, len(v))
+		for i, item := range v {
+			var err error
+			if list[i], err = o.value(item); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	case map[string]any:
+		obj := make(map[string]any, len(v))
+		for k, item := range v {
+			var err error
+			if obj[k], err = o.value(item); err != nil {
+				return nil, err
+			}
+		}
+		return obj, nil
+	}
+	return v, nil
+}
+
+type executor struct {
+	op     *Operation
+	errors []Error
+}
+
+func (e *executor) fail(path []any, err error) {
+	e.errors = append(e.errors, Error{Message: err.Error(), Path: append([]any{}, path...)})
+}
+
+// object resolves the selected fields of source, an object of type t.
+func (e *executor) object(ctx context.Context, t *Object, source any, sels []*selection, path []any) *orderedMap {
+	result := newOrderedMap()
+	var props map[string]any // source's JSON properties, for default resolvers
+	for _, sel := range e.op.collect(t, sels) {
+		fieldPath := append(path[:len(path):len(path)], sel.key())
+		if sel.name == "__typename" {
+			result.set(sel.key(), t.Name)
+			continue
+		}
+		f := t.Fields[sel.name]
+		var value any
+		var err error
+		if f.Resolve != nil {
+			var args Args
+			if args, err = e.op.args(sel.args); err == nil {
+				value, err = f.Resolve(ctx, source, args)
+			}
+		} else {
+			if props == nil {
+				props, err = properties(source)
+			}
+			value = props[snakeCase(sel.name)]
+		}
+		if err != nil {
+			e.fail(fieldPath, err)
+			result.set(sel.key(), nil)
+			continue
+		}
+		result.set(sel.key(), e.complete(ctx, f.Type, value, sel.sel, fieldPath))
+	}
+	return result
+}
+
+// complete shapes a resolved value: objects are narrowed to their selected
+// fields, lists element by element.
+func (e *executor) complete(ctx context.Context, t *Object, value any, sels []*selection, path []any) any {
+	if t == nil || value == nil {
+		return value
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Interface:
+		if rv.IsNil() {
+			return nil
+		}
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return []any{}
+		}
+		list := make([]any, rv.Len())
+		for i := range list {
+			list[i] = e.complete(ctx, t, rv.Index(i).Interface(), sels, append(path[:len(path):len(path)], i))
+		}
+		return list
+	}
+	return e.object(ctx, t, value, sels, path)
+}
+
+// properties returns the JSON properties of v.
+func properties(v any) (map[string]any, error) {
+	if m, ok := v.(map[string]any); ok {
+		return m, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]any
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("%T is not an object", v)
+	}
+	return m, nil
+}
+
+// snakeCase maps a GraphQL field name (pairedAt) to a JSON property name
+// (paired_at).
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Args are the arguments of a field, with variables substituted.
+type Args map[string]any
+
+// String returns a string argument, or "" if it is absent or not a string.
+func (a Args) String(name string) string {
+	s, _ := a[name].(string)
+	return s
+}
+
+// Int returns an integer argument, or def if it is absent or null.
+func (a Args) Int(name string, def int) (int, error) {
+	switch v := a[name].(type) {
+	case nil:
+		return def, nil
+	case int:
+		return v, nil
+	case float64: // from JSON variables
+		if v == math.Trunc(v) && math.Abs(v) <= math.MaxInt32 {
+			return int(v), nil
+		}
+	}
+	return 0, fmt.Errorf("argument %q must be an integer", name)
+}
+
+// orderedMap is a JSON object that keeps fields in selection order, as
+// GraphQL requires.
+type orderedMap struct {
+	keys   []string
+	values map[string]any
+}
+
+func newOrderedMap() *orderedMap {
+	return &orderedMap{values: map[string]any{}}
+}
+
+func (m *orderedMap) set(key string, value any) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+func (m *orderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range m.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(k)
+		buf.Write(key)
+		buf.WriteByte(':')
+		value, err := json.Marshal(m.values[k])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
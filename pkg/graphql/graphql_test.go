@@ -0,0 +1,171 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type book struct {
+	Title     string    `json:"title"`
+	PageCount int       `json:"page_count"`
+	Published time.Time `json:"published"`
+	Author    *author   `json:"author,omitempty"`
+}
+
+type author struct {
+	Name string `json:"name"`
+}
+
+func testSchema() *Schema {
+	authorType := &Object{Name: "Author", Fields: map[string]*Field{"name": {}}}
+	bookType := &Object{Name: "Book", Fields: map[string]*Field{
+		"title":     {},
+		"pageCount": {},
+		"published": {},
+		"author":    {Type: authorType},
+		"shout": {Resolve: func(_ context.Context, source any, _ Args) (any, error) {
+			return source.(book).Title + "!", nil
+		}},
+		"broken": {Resolve: func(context.Context, any, Args) (any, error) {
+			return nil, errors.New("out of ink")
+		}},
+	}}
+	books := []book{
+		{Title: "Dune", PageCount: 412, Published: time.Date(1965, 8, 1, 0, 0, 0, 0, time.UTC),
+			Author: &author{Name: "Frank Herbert"}},
+		{Title: "Emma", PageCount: 474},
+	}
+	return &Schema{
+		Query: &Object{Name: "Query", Fields: map[string]*Field{
+			"books": {Type: bookType, Resolve: func(_ context.Context, _ any, args Args) (any, error) {
+				n, err := args.Int("first", len(books))
+				if err != nil {
+					return nil, err
+				}
+				return books[:min(n, len(books))], nil
+			}},
+		}},
+		Mutation: &Object{Name: "Mutation", Fields: map[string]*Field{
+			"echo": {Resolve: func(_ context.Context, _ any, args Args) (any, error) {
+				return args["value"], nil
+			}},
+		}},
+		Subscription: &Object{Name: "Subscription", Fields: map[string]*Field{
+			"countdown": {Resolve: func(_ context.Context, _ any, args Args) (any, error) {
+				n, _ := args.Int("from", 3)
+				ch := make(chan any, n)
+				for i := n; i > 0; i-- {
+					ch <- i
+				}
+				close(ch)
+				return (<-chan any)(ch), nil
+			}},
+		}},
+	}
+}
+
+func run(t *testing.T, req Request) string {
+	t.Helper()
+	op, err := testSchema().Prepare(req)
+	require.NoError(t, err)
+	data, err := json.Marshal(op.Execute(context.Background()))
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestExecute(t *testing.T) {
+	got := run(t, Request{Query: `
+		# the first book, with everything
+		query Books($n: Int = 1) {
+			books(first: $n) {
+				__typename
+				name: title
+				...details
+				author { name }
+				... on Book @skip(if: false) { shout }
+				pageCount @include(if: false)
+			}
+		}
+		fragment details on Book { published, pageCount }
+	`})
+	assert.JSONEq(t, `{"data":{"books":[{
+		"__typename":"Book","name":"Dune","published":"1965-08-01T00:00:00Z","pageCount":412,
+		"author":{"name":"Frank Herbert"},"shout":"Dune!"
+	}]}}`, got)
+
+	// Field errors null the field and are reported with their path.
+	got = run(t, Request{Query: `{ books { title broken author { name } } }`})
+	assert.JSONEq(t, `{
+		"data":{"books":[
+			{"title":"Dune","broken":null,"author":{"name":"Frank Herbert"}},
+			{"title":"Emma","broken":null,"author":null}
+		]},
+		"errors":[
+			{"message":"out of ink","path":["books",0,"broken"]},
+			{"message":"out of ink","path":["books",1,"broken"]}
+		]
+	}`, got)
+
+	got = run(t, Request{
+		Query:         `query A { books { title } } mutation B($v: [String!]!) { echo(value: $v) }`,
+		OperationName: "B",
+		Variables:     map[string]any{"v": []any{"a", "é"}},
+	})
+	assert.JSONEq(t, `{"data":{"echo":["a","é"]}}`, got)
+
+	got = run(t, Request{Query: `mutation { echo(value: {s: "a\"bA", n: -1.5e1, e: RED, l: [1, null]}) }`})
+	assert.JSONEq(t, `{"data":{"echo":{"s":"a\"bA","n":-15,"e":"RED","l":[1,null]}}}`, got)
+
+	got = run(t, Request{Query: "mutation { echo(value: \"\"\"\n    two\n      lines\n  \"\"\") }"})
+	assert.JSONEq(t, `{"data":{"echo":"two\n  lines"}}`, got)
+}
+
+func TestPrepareErrors(t *testing.T) {
+	for query, want := range map[string]string{
+		`{ books { title `:                                        "unexpected end",
+		`{ authors { name } }`:                                    `cannot query field "authors" on type Query`,
+		`{ books }`:                                               "must have a selection of subfields",
+		`{ books { title { x } } }`:                               "scalar",
+		`{ books { ...missing } }`:                                `unknown fragment "missing"`,
+		`{ books { ...a } } fragment a on Book { ...a }`:          "spreads itself",
+		`query Q($n: Int!) { books(first: $n) { title } }`:        "variable $n of type Int! is required",
+		`query A { books { title } } query B { books { title } }`: "operationName is required",
+		`subscription { countdown countdown2: countdown }`:        "exactly one field",
+		`{ books { title } } fragment on on Book { title }`:       "cannot be named",
+	} {
+		_, err := testSchema().Prepare(Request{Query: query})
+		if assert.Error(t, err, query) {
+			assert.Contains(t, err.Error(), want, query)
+		}
+	}
+
+	op, err := testSchema().Prepare(Request{Query: `{ books(first: "two") { title } }`})
+	require.NoError(t, err)
+	resp := op.Execute(context.Background())
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, `argument "first" must be an integer`, resp.Errors[0].Message)
+}
+
+func TestSubscribe(t *testing.T) {
+	op, err := testSchema().Prepare(Request{
+		Query:     `subscription Count($from: Int) { n: countdown(from: $from) }`,
+		Variables: map[string]any{"from": float64(2)},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "subscription", op.Type)
+
+	results, err := op.Subscribe(context.Background())
+	require.NoError(t, err)
+	var got []string
+	for r := range results {
+		data, _ := json.Marshal(r)
+		got = append(got, string(data))
+	}
+	assert.Equal(t, []string{`{"data":{"n":2}}`, `{"data":{"n":1}}`}, got)
+}
@@ -0,0 +1,209 @@
+// Package hardware checks the health of the board picoclaw runs on: CPU
+// temperature, thermal throttling, undervoltage and eMMC wear, read from sysfs
+// where the board exposes them. An overheating or underpowered single-board
+// computer slows down without any error, so these conditions are reported in
+// /ready and as alerts instead of surfacing as a sluggish agent.
+package hardware
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Check names.
+const (
+	CheckTemperature  = "cpu_temperature"
+	CheckThrottling   = "cpu_throttling"
+	CheckUndervoltage = "undervoltage"
+	CheckDiskWear     = "disk_wear"
+)
+
+// Bits of the Raspberry Pi firmware's throttled state.
+const (
+	throttledUndervoltage = 1 << 0
+	throttledFreqCapped   = 1 << 1
+	throttledNow          = 1 << 2
+	throttledSoftTemp     = 1 << 3
+)
+
+// wornLifeTime is the eMMC life time estimate from which a device is reported
+// worn: 0x09 means 80-90% of its rated erase cycles are used.
+const wornLifeTime = 0x09
+
+// sysRoot is where sysfs is mounted; tests point it at a fake tree.
+var sysRoot = "/sys"
+
+// Check is a hardware check. Run reports whether the hardware is fine and
+// describes its state.
+type Check struct {
+	Name string
+	Run  func() (bool, string)
+}
+
+// Options sets the check thresholds. A zero threshold disables its check.
+type Options struct {
+	MaxTemperature float64 // °C, the hottest thermal zone
+}
+
+// Checks returns the checks this board supports, leaving out those whose
+// sysfs files don't exist.
+func Checks(opts Options) []Check {
+	var checks []Check
+	if opts.MaxTemperature > 0 && len(glob("class/thermal/thermal_zone*/temp")) > 0 {
+		checks = append(checks, Check{CheckTemperature, func() (bool, string) {
+			return checkTemperature(opts.MaxTemperature)
+		}})
+	}
+	_, firmware := firmwareThrottled()
+	if firmware || len(cpuCoolingDevices()) > 0 {
+		checks = append(checks, Check{CheckThrottling, checkThrottling})
+	}
+	if firmware || len(glob("class/hwmon/hwmon*/in*_lcrit_alarm")) > 0 {
+		checks = append(checks, Check{CheckUndervoltage, checkUndervoltage})
+	}
+	if len(glob("block/mmcblk*/device/life_time")) > 0 {
+		checks = append(checks, Check{CheckDiskWear, checkDiskWear})
+	}
+	return checks
+}
+
+// checkTemperature compares the hottest thermal zone with limit.
+func checkTemperature(limit float64) (bool, string) {
+	hottest, found := 0.0, false
+	for _, path := range glob("class/thermal/thermal_zone*/temp") {
+		milli, err := readInt(path)
+		if err != nil || milli <= 0 {
+			continue // zones without a sensor read as errors or zero
+		}
+		if c := float64(milli) / 1000; !found || c > hottest {
+			hottest, found = c, true
+		}
+	}
+	if !found {
+		return true, "no temperature reading"
+	}
+	if hottest >= limit {
+		return false, fmt.Sprintf("CPU at %.1f°C (limit %.0f°C)", hottest, limit)
+	}
+	return true, fmt.Sprintf("%.1f°C", hottest)
+}
+
+// checkThrottling reports whether the CPU clock is being held down, by the
+// Raspberry Pi firmware or by a thermal cooling device acting on the CPU.
+func checkThrottling() (bool, string) {
+	if state, ok := firmwareThrottled(); ok {
+		switch {
+		case state&throttledNow != 0:
+			return false, "CPU throttled by the firmware"
+		case state&throttledFreqCapped != 0:
+			return false, "CPU frequency capped by the firmware"
+		case state&throttledSoftTemp != 0:
+			return false, "CPU soft temperature limit active"
+		}
+		return true, ""
+	}
+	for _, dev := range cpuCoolingDevices() {
+		cur, err := readInt(filepath.Join(dev, "cur_state"))
+		if err != nil || cur == 0 {
+			continue
+		}
+		maxState, _ := readInt(filepath.Join(dev, "max_state"))
+		kind, _ := readString(filepath.Join(dev, "type"))
+		return false, fmt.Sprintf("CPU frequency limited by %s (state %d of %d)", kind, cur, maxState)
+	}
+	return true, ""
+}
+
+// checkUndervoltage reports whether the supply voltage is below what the
+// board needs, which the firmware answers by throttling and which can
+// corrupt the SD card.
+func checkUndervoltage() (bool, string) {
+	if state, ok := firmwareThrottled(); ok {
+		if state&throttledUndervoltage != 0 {
+			return false, "undervoltage detected, check the power supply"
+		}
+		return true, ""
+	}
+	for _, path := range glob("class/hwmon/hwmon*/in*_lcrit_alarm") {
+		if alarm, err := readInt(path); err == nil && alarm != 0 {
+			return false, "undervoltage detected, check the power supply"
+		}
+	}
+	return true, ""
+}
+
+// checkDiskWear reads the eMMC life time estimates, in 10% steps of the rated
+// erase cycles, and the pre-EOL state, which turns from normal once the
+// spare blocks run low.
+func checkDiskWear() (bool, string) {
+	for _, path := range glob("block/mmcblk*/device/life_time") {
+		dev := filepath.Dir(path)
+		name := filepath.Base(filepath.Dir(dev))
+		if eol, err := readHex(filepath.Join(dev, "pre_eol_info")); err == nil && eol >= 2 {
+			return false, fmt.Sprintf("%s is running out of spare blocks", name)
+		}
+		line, err := readString(path)
+		if err != nil {
+			continue
+		}
+		for _, field := range strings.Fields(line) {
+			est, err := strconv.ParseUint(strings.TrimPrefix(field, "0x"), 16, 8)
+			switch {
+			case err != nil || est < wornLifeTime:
+			case est > 0x0A:
+				return false, fmt.Sprintf("%s is past its rated life", name)
+			default:
+				return false, fmt.Sprintf("%s has used %d-%d%% of its rated life", name, (est-1)*10, est*10)
+			}
+		}
+	}
+	return true, ""
+}
+
+// firmwareThrottled returns the Raspberry Pi firmware's throttled state, if
+// the firmware driver exposes it.
+func firmwareThrottled() (uint64, bool) {
+	state, err := readHex(filepath.Join(sysRoot, "devices/platform/soc/soc:firmware/get_throttled"))
+	return state, err == nil
+}
+
+// cpuCoolingDevices returns the thermal cooling devices that throttle the
+// CPU clock, such as cpufreq-cpu0 or thermal-cpufreq-0.
+func cpuCoolingDevices() []string {
+	var devs []string
+	for _, path := range glob("class/thermal/cooling_device*/type") {
+		if kind, err := readString(path); err == nil && strings.Contains(kind, "cpufreq") {
+			devs = append(devs, filepath.Dir(path))
+		}
+	}
+	return devs
+}
+
+func glob(pattern string) []string {
+	paths, _ := filepath.Glob(filepath.Join(sysRoot, pattern))
+	return paths
+}
+
+func readString(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	return strings.TrimSpace(string(data)), err
+}
+
+func readInt(path string) (int64, error) {
+	s, err := readString(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+func readHex(path string) (uint64, error) {
+	s, err := readString(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimPrefix(s, "0x"), 16, 64)
+}
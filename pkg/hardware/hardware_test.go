@@ -0,0 +1,126 @@
+package hardware
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSys points sysRoot at a temporary tree holding files, by path relative
+// to /sys.
+func fakeSys(t *testing.T, files map[string]string) {
+	t.Helper()
+	root := t.TempDir()
+	for path, content := range files {
+		full := filepath.Join(root, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(full), 0o755))
+		require.NoError(t, os.WriteFile(full, []byte(content+"\n"), 0o644))
+	}
+	old := sysRoot
+	sysRoot = root
+	t.Cleanup(func() { sysRoot = old })
+}
+
+func checkNames(checks []Check) []string {
+	var names []string
+	for _, c := range checks {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+func TestChecksOnlyWhatTheBoardExposes(t *testing.T) {
+	fakeSys(t, map[string]string{})
+	assert.Empty(t, Checks(Options{MaxTemperature: 80}))
+
+	fakeSys(t, map[string]string{
+		"class/thermal/thermal_zone0/temp":                "45000",
+		"class/thermal/cooling_device0/type":              "fan",
+		"devices/platform/soc/soc:firmware/get_throttled": "0",
+		"block/mmcblk0/device/life_time":                  "0x01 0x01",
+	})
+	assert.Equal(t, []string{CheckTemperature, CheckThrottling, CheckUndervoltage, CheckDiskWear},
+		checkNames(Checks(Options{MaxTemperature: 80})))
+	assert.NotContains(t, checkNames(Checks(Options{})), CheckTemperature, "a zero limit disables the check")
+}
+
+func TestTemperature(t *testing.T) {
+	fakeSys(t, map[string]string{
+		"class/thermal/thermal_zone0/temp": "62500",
+		"class/thermal/thermal_zone1/temp": "84300",
+		"class/thermal/thermal_zone2/temp": "0", // no sensor
+	})
+	ok, msg := checkTemperature(80)
+	assert.False(t, ok)
+	assert.Equal(t, "CPU at 84.3°C (limit 80°C)", msg)
+
+	ok, msg = checkTemperature(90)
+	assert.True(t, ok)
+	assert.Equal(t, "84.3°C", msg)
+}
+
+func TestFirmwareThrottling(t *testing.T) {
+	// 0x50000: throttled and undervolted since boot, but not now.
+	fakeSys(t, map[string]string{"devices/platform/soc/soc:firmware/get_throttled": "50000"})
+	ok, _ := checkThrottling()
+	assert.True(t, ok, "past events don't count")
+	ok, _ = checkUndervoltage()
+	assert.True(t, ok)
+
+	fakeSys(t, map[string]string{"devices/platform/soc/soc:firmware/get_throttled": "50005"})
+	ok, msg := checkThrottling()
+	assert.False(t, ok)
+	assert.Equal(t, "CPU throttled by the firmware", msg)
+	ok, msg = checkUndervoltage()
+	assert.False(t, ok)
+	assert.Contains(t, msg, "power supply")
+}
+
+func TestCoolingDeviceThrottling(t *testing.T) {
+	fakeSys(t, map[string]string{
+		"class/thermal/cooling_device0/type":      "pwm-fan",
+		"class/thermal/cooling_device0/cur_state": "3",
+		"class/thermal/cooling_device1/type":      "thermal-cpufreq-0",
+		"class/thermal/cooling_device1/cur_state": "0",
+		"class/thermal/cooling_device1/max_state": "5",
+	})
+	ok, _ := checkThrottling()
+	assert.True(t, ok, "a spinning fan is not throttling")
+
+	state := filepath.Join(sysRoot, "class/thermal/cooling_device1/cur_state")
+	require.NoError(t, os.WriteFile(state, []byte("2\n"), 0o644))
+	ok, msg := checkThrottling()
+	assert.False(t, ok)
+	assert.Equal(t, "CPU frequency limited by thermal-cpufreq-0 (state 2 of 5)", msg)
+}
+
+func TestHwmonUndervoltage(t *testing.T) {
+	fakeSys(t, map[string]string{"class/hwmon/hwmon1/in0_lcrit_alarm": "1"})
+	ok, _ := checkUndervoltage()
+	assert.False(t, ok)
+}
+
+func TestDiskWear(t *testing.T) {
+	tests := []struct {
+		lifeTime, preEOL string
+		ok               bool
+		msg              string
+	}{
+		{"0x02 0x03", "0x01", true, ""},
+		{"0x02 0x09", "0x01", false, "mmcblk0 has used 80-90% of its rated life"},
+		{"0x0B 0x01", "0x01", false, "mmcblk0 is past its rated life"},
+		{"0x01 0x01", "0x03", false, "mmcblk0 is running out of spare blocks"},
+	}
+	for _, tt := range tests {
+		fakeSys(t, map[string]string{
+			"block/mmcblk0/device/life_time":    tt.lifeTime,
+			"block/mmcblk0/device/pre_eol_info": tt.preEOL,
+		})
+		ok, msg := checkDiskWear()
+		assert.Equal(t, tt.ok, ok, tt.lifeTime)
+		assert.Equal(t, tt.msg, msg, tt.lifeTime)
+	}
+}
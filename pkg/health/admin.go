@@ -0,0 +1,460 @@
+package health
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/i18n"
+	"github.com/sipeed/picoclaw/pkg/subscriptions"
+)
+
+const (
+	// pairRequestTTL is how long a pairing request waits for approval.
+	pairRequestTTL = 10 * time.Minute
+	// maxPairRequests bounds pending requests, since anyone who can reach
+	// the gateway can make one.
+	maxPairRequests = 16
+	// deviceIDLength is the number of token hash digits that identify a
+	// device in the admin API.
+	deviceIDLength = 12
+)
+
+// Device is a paired device, as listed by GET /admin/devices.
+type Device struct {
+	ID       string    `json:"id"`
+	Name     string    `json:"name,omitempty"`
+	PairedAt time.Time `json:"paired_at,omitempty"`
+	Scope    string    `json:"scope,omitempty"` // ObserverRole for a read-only token
+}
+
+// PairRequest is a device waiting for an admin to let it pair. Code is shown
+// on both the device and the admin console, to check they are the same
+// request before approving it.
+type PairRequest struct {
+	Code       string    `json:"code"`
+	Name       string    `json:"name"`
+	RemoteAddr string    `json:"remote_addr"`
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+type pairRequest struct {
+	PairRequest
+	secret string // the device polls with it
+	token  string // set on approval, until the device collects it
+}
+
+// WithPairedDevices names the paired tokens in the admin API.
+func WithPairedDevices(devices []config.PairedDevice) ServerOption {
+	return func(s *Server) {
+		for _, d := range devices {
+			s.devices[d.TokenHash] = d
+		}
+	}
+}
+
+func (s *Server) registerAdmin(mux *http.ServeMux) {
+	mux.HandleFunc("POST /pair/requests", traced("POST /pair/requests", s.createPairRequestHandler))
+	mux.HandleFunc("GET /pair/requests/{id}", traced("GET /pair/requests/{id}", s.pollPairRequestHandler))
+	// The token in the path is the credential, so guest calls are kept out
+	// of the wire log.
+	mux.HandleFunc("GET /guest/{token}", traced("GET /guest/{token}", s.guestInfoHandler))
+	mux.HandleFunc("POST /guest/{token}", traced("POST /guest/{token}", s.guestWebhookHandler))
+
+	admin := func(route string, h http.HandlerFunc) {
+		mux.HandleFunc(route, traced(route, s.requireAdmin(h)))
+	}
+	admin("GET /admin/pairing/requests", s.listPairRequestsHandler)
+	admin("POST /admin/pairing/requests/{code}/approve", s.approvePairRequestHandler)
+	admin("DELETE /admin/pairing/requests/{code}", s.denyPairRequestHandler)
+	admin("GET /admin/devices", s.listDevicesHandler)
+	admin("PATCH /admin/devices/{id}", s.renameDeviceHandler)
+	admin("DELETE /admin/devices/{id}", s.revokeDeviceHandler)
+	admin("POST /admin/guest-links", s.createGuestLinkHandler)
+	admin("GET /admin/guest-links", s.listGuestLinksHandler)
+	admin("DELETE /admin/guest-links/{id}", s.revokeGuestLinkHandler)
+	admin("GET /admin/maintenance", s.maintenanceHandler)
+	admin("PUT /admin/maintenance", s.setMaintenanceHandler)
+	admin("POST /admin/reload", s.reloadHandler)
+	admin("POST /admin/replay/{id}", s.replayHandler)
+	admin("DELETE /admin/sessions/{key}", s.deleteSessionHandler)
+	admin("GET /admin/workflows", s.requireWorkflows(s.listWorkflowsHandler))
+	admin("POST /admin/workflows/{name}/run", s.requireWorkflows(s.startWorkflowHandler))
+	admin("GET /admin/workflows/runs/{id}", s.requireWorkflows(s.workflowRunHandler))
+	admin("POST /admin/workflows/runs/{id}/approve", s.requireWorkflows(s.decideWorkflowHandler(true)))
+	admin("POST /admin/workflows/runs/{id}/reject", s.requireWorkflows(s.decideWorkflowHandler(false)))
+	admin("POST /admin/workflows/runs/{id}/resume", s.requireWorkflows(s.resumeWorkflowHandler))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]any{"error": msg})
+}
+
+// createPairRequestHandler registers a device asking to pair. It answers
+// with the secret the device polls with and the code to show its user.
+func (s *Server) createPairRequestHandler(w http.ResponseWriter, r *http.Request) {
+	lang := s.language(r.Context(), r, "")
+	var body struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<10)).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, i18n.T(lang, "api.invalid_body"))
+		return
+	}
+	name := strings.TrimSpace(body.Name)
+	if name == "" || len(name) > 64 {
+		writeError(w, http.StatusBadRequest, i18n.T(lang, "pair.name_required"))
+		return
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	s.expirePairRequests(now)
+	if len(s.pairRequests) >= maxPairRequests {
+		s.mu.Unlock()
+		writeError(w, http.StatusTooManyRequests, i18n.T(lang, "pair.too_many_requests"))
+		return
+	}
+	req := &pairRequest{
+		PairRequest: PairRequest{
+			Code:       s.newRequestCode(),
+			Name:       name,
+			RemoteAddr: r.RemoteAddr,
+			CreatedAt:  now,
+			ExpiresAt:  now.Add(pairRequestTTL),
+		},
+		secret: randomHex(16),
+	}
+	s.pairRequests[req.Code] = req
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusAccepted, map[string]any{
+		"request_id": req.secret,
+		"code":       req.Code,
+		"expires_at": req.ExpiresAt,
+	})
+}
+
+// pollPairRequestHandler tells a device whether its request was approved,
+// and hands over the token once.
+func (s *Server) pollPairRequestHandler(w http.ResponseWriter, r *http.Request) {
+	secret := r.PathValue("id")
+	s.mu.Lock()
+	s.expirePairRequests(time.Now())
+	var req *pairRequest
+	for _, pending := range s.pairRequests {
+		if pending.secret == secret {
+			req = pending
+			break
+		}
+	}
+	if req == nil {
+		s.mu.Unlock()
+		writeError(w, http.StatusNotFound, i18n.T(s.language(r.Context(), r, ""), "pair.request_gone"))
+		return
+	}
+	token := req.token
+	if token != "" {
+		delete(s.pairRequests, req.Code)
+	}
+	s.mu.Unlock()
+
+	if token == "" {
+		writeJSON(w, http.StatusOK, map[string]any{"status": "pending", "code": req.Code})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"status": "approved", "token": token})
+}
+
+func (s *Server) listPairRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.expirePairRequests(time.Now())
+	requests := make([]PairRequest, 0, len(s.pairRequests))
+	for _, req := range s.pairRequests {
+		if req.token == "" {
+			requests = append(requests, req.PairRequest)
+		}
+	}
+	s.mu.Unlock()
+	sort.Slice(requests, func(i, j int) bool { return requests[i].CreatedAt.Before(requests[j].CreatedAt) })
+	writeJSON(w, http.StatusOK, map[string]any{"requests": requests, "count": len(requests)})
+}
+
+// approvePairRequestHandler lets a device pair. A JSON body of
+// {"scope": "observer"} gives it a read-only token.
+func (s *Server) approvePairRequestHandler(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Scope string `json:"scope"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<10)).Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+	}
+	if body.Scope != "" && body.Scope != ObserverRole {
+		writeError(w, http.StatusBadRequest, "scope must be empty or "+ObserverRole)
+		return
+	}
+
+	s.mu.Lock()
+	s.expirePairRequests(time.Now())
+	req, ok := s.pairRequests[r.PathValue("code")]
+	if !ok || req.token != "" {
+		s.mu.Unlock()
+		writeError(w, http.StatusNotFound, "no pending pairing request with this code")
+		return
+	}
+	token, tokenHash := generateBearerToken()
+	req.token = token
+	device := config.PairedDevice{TokenHash: tokenHash, Name: req.Name, PairedAt: time.Now().UTC(), Scope: body.Scope}
+	s.pairedTokens[tokenHash] = true
+	s.devices[tokenHash] = device
+	s.mu.Unlock()
+
+	s.persistDevice(device)
+	publishPairing(tokenHash, device)
+	writeJSON(w, http.StatusOK, deviceFor(tokenHash, device))
+}
+
+func (s *Server) denyPairRequestHandler(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	_, ok := s.pairRequests[r.PathValue("code")]
+	delete(s.pairRequests, r.PathValue("code"))
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "no pending pairing request with this code")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"denied": true})
+}
+
+// expirePairRequests drops requests past their expiry. The caller holds mu.
+func (s *Server) expirePairRequests(now time.Time) {
+	for code, req := range s.pairRequests {
+		if now.After(req.ExpiresAt) {
+			delete(s.pairRequests, code)
+		}
+	}
+}
+
+// newRequestCode returns a short code not used by a pending request. The
+// caller holds mu.
+func (s *Server) newRequestCode() string {
+	const alphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789" // no 0/O or 1/I
+	for {
+		b := make([]byte, 6)
+		rand.Read(b)
+		for i := range b {
+			b[i] = alphabet[int(b[i])%len(alphabet)]
+		}
+		if _, taken := s.pairRequests[string(b)]; !taken {
+			return string(b)
+		}
+	}
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func deviceFor(tokenHash string, d config.PairedDevice) Device {
+	return Device{ID: tokenHash[:deviceIDLength], Name: d.Name, PairedAt: d.PairedAt, Scope: d.Scope}
+}
+
+// publishPairing tells subscribers a device was paired.
+func publishPairing(tokenHash string, d config.PairedDevice) {
+	subscriptions.Publish(subscriptions.Event{
+		ID:   "pairing-" + tokenHash[:deviceIDLength],
+		Type: subscriptions.EventPairingCreated,
+		Data: deviceFor(tokenHash, d),
+	})
+}
+
+func (s *Server) listDevicesHandler(w http.ResponseWriter, r *http.Request) {
+	devices := s.listDevices()
+	writeJSON(w, http.StatusOK, map[string]any{"devices": devices, "count": len(devices)})
+}
+
+// listDevices returns the paired devices, oldest first.
+func (s *Server) listDevices() []Device {
+	s.mu.RLock()
+	devices := make([]Device, 0, len(s.pairedTokens))
+	for hash := range s.pairedTokens {
+		devices = append(devices, deviceFor(hash, s.devices[hash]))
+	}
+	s.mu.RUnlock()
+	sort.Slice(devices, func(i, j int) bool {
+		if !devices[i].PairedAt.Equal(devices[j].PairedAt) {
+			return devices[i].PairedAt.Before(devices[j].PairedAt)
+		}
+		return devices[i].ID < devices[j].ID
+	})
+	return devices
+}
+
+// findDevice returns the token hash of the device with id. The caller holds
+// mu.
+func (s *Server) findDevice(id string) (string, bool) {
+	if len(id) != deviceIDLength {
+		return "", false
+	}
+	for hash := range s.pairedTokens {
+		if strings.HasPrefix(hash, id) {
+			return hash, true
+		}
+	}
+	return "", false
+}
+
+func (s *Server) renameDeviceHandler(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<10)).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	name := strings.TrimSpace(body.Name)
+	if len(name) > 64 {
+		writeError(w, http.StatusBadRequest, "name is at most 64 bytes")
+		return
+	}
+
+	s.mu.Lock()
+	hash, ok := s.findDevice(r.PathValue("id"))
+	if !ok {
+		s.mu.Unlock()
+		writeError(w, http.StatusNotFound, "no paired device with this id")
+		return
+	}
+	device := s.devices[hash]
+	device.TokenHash = hash
+	device.Name = name
+	s.devices[hash] = device
+	s.mu.Unlock()
+
+	s.persistDevice(device)
+	writeJSON(w, http.StatusOK, deviceFor(hash, device))
+}
+
+// revokeDeviceHandler unpairs a device; its token stops working at once.
+func (s *Server) revokeDeviceHandler(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	hash, ok := s.findDevice(r.PathValue("id"))
+	if ok {
+		delete(s.pairedTokens, hash)
+		delete(s.devices, hash)
+	}
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "no paired device with this id")
+		return
+	}
+
+	s.updateGatewayConfig(func(g *config.GatewayConfig) {
+		tokens := g.PairedTokens[:0]
+		for _, t := range g.PairedTokens {
+			if t != hash {
+				tokens = append(tokens, t)
+			}
+		}
+		g.PairedTokens = tokens
+		devices := g.PairedDevices[:0]
+		for _, d := range g.PairedDevices {
+			if d.TokenHash != hash {
+				devices = append(devices, d)
+			}
+		}
+		g.PairedDevices = devices
+	})
+	writeJSON(w, http.StatusOK, map[string]any{"revoked": true})
+}
+
+func (s *Server) maintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{"enabled": s.agentLoop.InMaintenance()})
+}
+
+func (s *Server) setMaintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<10)).Decode(&body); err != nil || body.Enabled == nil {
+		writeError(w, http.StatusBadRequest, `expected {"enabled": true|false}`)
+		return
+	}
+	s.agentLoop.SetMaintenance(*body.Enabled)
+	writeJSON(w, http.StatusOK, map[string]any{"enabled": *body.Enabled})
+}
+
+// reloadHandler checks the config file and restarts the gateway to apply
+// it. An invalid file is reported and the gateway keeps running.
+func (s *Server) reloadHandler(w http.ResponseWriter, r *http.Request) {
+	if s.shutdown == nil || s.configPath == "" {
+		writeError(w, http.StatusNotImplemented, "this server cannot reload its config")
+		return
+	}
+	if _, err := config.LoadConfig(s.configPath); err != nil {
+		writeError(w, http.StatusBadRequest, "config is invalid: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]any{"status": "reloading"})
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+	go s.shutdown(true)
+}
+
+// persistDevice saves a paired device's token and name to the config file.
+func (s *Server) persistDevice(device config.PairedDevice) {
+	s.updateGatewayConfig(func(g *config.GatewayConfig) {
+		found := false
+		for _, t := range g.PairedTokens {
+			found = found || t == device.TokenHash
+		}
+		if !found {
+			g.PairedTokens = append(g.PairedTokens, device.TokenHash)
+		}
+		for i, d := range g.PairedDevices {
+			if d.TokenHash == device.TokenHash {
+				g.PairedDevices[i] = device
+				return
+			}
+		}
+		g.PairedDevices = append(g.PairedDevices, device)
+	})
+}
+
+// updateGatewayConfig applies fn to the gateway section of the config file.
+// Without a config path there is nothing to save.
+func (s *Server) updateGatewayConfig(fn func(g *config.GatewayConfig)) error {
+	if s.configPath == "" {
+		return nil
+	}
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+	cfg, err := config.LoadConfig(s.configPath)
+	if err != nil {
+		return err
+	}
+	fn(&cfg.Gateway)
+	if err := config.SaveConfig(s.configPath, cfg); err != nil {
+		return errors.Join(errors.New("failed to save config"), err)
+	}
+	return nil
+}
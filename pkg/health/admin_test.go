@@ -0,0 +1,161 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func adminRequest(s *Server, method, path, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	s.server.Handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAdminRequiresAdminToken(t *testing.T) {
+	// Pairing is optional, so the webhook is open, but admin routes are not.
+	s, _ := newUploadServer(t, WithPairing(false, nil, ""))
+	for _, route := range [][2]string{
+		{http.MethodGet, "/admin/devices"},
+		{http.MethodGet, "/admin/pairing/requests"},
+		{http.MethodPut, "/admin/maintenance"},
+		{http.MethodPost, "/admin/reload"},
+		{http.MethodPost, "/admin/replay/abc"},
+	} {
+		rec := adminRequest(s, route[0], route[1], "", "{}")
+		assert.Equal(t, http.StatusUnauthorized, rec.Code, route[1])
+	}
+}
+
+func TestAdminRenameAndRevokeDevice(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, config.SaveConfig(configPath, config.DefaultConfig()))
+	admin, adminHash := generateBearerToken()
+	phone, phoneHash := generateBearerToken()
+	s, _ := newUploadServer(t,
+		WithPairing(true, []string{adminHash, phoneHash}, configPath),
+		WithPairedDevices([]config.PairedDevice{{TokenHash: phoneHash, Name: "phone"}}))
+
+	rec := adminRequest(s, http.MethodGet, "/admin/devices", admin, "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var list struct {
+		Devices []Device `json:"devices"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	require.Len(t, list.Devices, 2)
+
+	id := phoneHash[:deviceIDLength]
+	rec = adminRequest(s, http.MethodPatch, "/admin/devices/"+id, admin, `{"name":"work phone"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = adminRequest(s, http.MethodDelete, "/admin/devices/"+id, admin, "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	rec = adminRequest(s, http.MethodGet, "/admin/devices", phone, "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code, "revoked token still works")
+
+	cfg, err := config.LoadConfig(configPath)
+	require.NoError(t, err)
+	assert.NotContains(t, cfg.Gateway.PairedTokens, phoneHash)
+	for _, d := range cfg.Gateway.PairedDevices {
+		assert.NotEqual(t, phoneHash, d.TokenHash)
+	}
+
+	rec = adminRequest(s, http.MethodDelete, "/admin/devices/"+id, admin, "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestAdminApprovesPairRequest(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, config.SaveConfig(configPath, config.DefaultConfig()))
+	admin, adminHash := generateBearerToken()
+	s, _ := newUploadServer(t, WithPairing(true, []string{adminHash}, configPath))
+
+	rec := adminRequest(s, http.MethodPost, "/pair/requests", "", `{"name":"tablet"}`)
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+	var created struct {
+		RequestID string `json:"request_id"`
+		Code      string `json:"code"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	require.Len(t, created.Code, 6)
+
+	rec = adminRequest(s, http.MethodGet, "/pair/requests/"+created.RequestID, "", "")
+	assert.Contains(t, rec.Body.String(), `"pending"`)
+	rec = adminRequest(s, http.MethodGet, "/admin/pairing/requests", admin, "")
+	assert.Contains(t, rec.Body.String(), created.Code)
+
+	// The public code is not enough to collect the token.
+	rec = adminRequest(s, http.MethodGet, "/pair/requests/"+created.Code, "", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = adminRequest(s, http.MethodPost, "/admin/pairing/requests/"+created.Code+"/approve", admin, "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = adminRequest(s, http.MethodGet, "/pair/requests/"+created.RequestID, "", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var approved struct {
+		Status string `json:"status"`
+		Token  string `json:"token"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &approved))
+	assert.Equal(t, "approved", approved.Status)
+	rec = adminRequest(s, http.MethodGet, "/admin/devices", approved.Token, "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = adminRequest(s, http.MethodGet, "/pair/requests/"+created.RequestID, "", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code, "token is handed over once")
+
+	cfg, err := config.LoadConfig(configPath)
+	require.NoError(t, err)
+	require.Len(t, cfg.Gateway.PairedDevices, 1)
+	assert.Equal(t, "tablet", cfg.Gateway.PairedDevices[0].Name)
+	assert.Contains(t, cfg.Gateway.PairedTokens, cfg.Gateway.PairedDevices[0].TokenHash)
+}
+
+func TestAdminMaintenanceRejectsWebhook(t *testing.T) {
+	admin, adminHash := generateBearerToken()
+	s, _ := newUploadServer(t, WithPairing(false, []string{adminHash}, ""))
+
+	rec := adminRequest(s, http.MethodPut, "/admin/maintenance", admin, `{"enabled":true}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = adminRequest(s, http.MethodPost, "/webhook", admin, `{"message":"hi"}`)
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code, rec.Body.String())
+	assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+
+	rec = adminRequest(s, http.MethodPut, "/admin/maintenance", admin, `{"enabled":false}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	rec = adminRequest(s, http.MethodPost, "/webhook", admin, `{"message":"hi"}`)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+}
+
+func TestAdminReloadRejectsInvalidConfig(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(configPath, []byte("{not json"), 0o600))
+	admin, adminHash := generateBearerToken()
+	restarted := make(chan bool, 1)
+	s, _ := newUploadServer(t,
+		WithPairing(true, []string{adminHash}, configPath),
+		WithShutdown(func(restart bool) { restarted <- restart }))
+
+	rec := adminRequest(s, http.MethodPost, "/admin/reload", admin, "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+
+	require.NoError(t, config.SaveConfig(configPath, config.DefaultConfig()))
+	rec = adminRequest(s, http.MethodPost, "/admin/reload", admin, "")
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+	assert.True(t, <-restarted)
+}
@@ -0,0 +1,94 @@
+package health
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/sipeed/picoclaw/pkg/artifact"
+)
+
+// WithArtifacts serves registered artifacts under /artifacts.
+func WithArtifacts(r *artifact.Registry) ServerOption {
+	return func(s *Server) {
+		s.artifacts = r
+	}
+}
+
+// artifactListHandler lists unexpired artifacts, optionally for one session.
+func (s *Server) artifactListHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if !s.isAuthorized(r) {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]any{"error": "unauthorized: invalid or missing bearer token"})
+		return
+	}
+
+	query := r.URL.Query()
+	artifacts := s.artifacts.List(query.Get("session"), query.Get("business_id"))
+	json.NewEncoder(w).Encode(map[string]any{
+		"artifacts": artifacts,
+		"count":     len(artifacts),
+	})
+}
+
+// artifactDownloadHandler serves an artifact by ID: a redirect to a presigned
+// URL when it was copied to the blob store, the local file otherwise.
+func (s *Server) artifactDownloadHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.isAuthorized(r) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]any{"error": "unauthorized: invalid or missing bearer token"})
+		return
+	}
+
+	a, err := s.artifacts.Get(r.PathValue("id"))
+	if errors.Is(err, artifact.ErrNotFound) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]any{"error": "artifact not found"})
+		return
+	}
+
+	if a.BlobKey != "" && s.blobStore != nil {
+		url, err := s.blobStore.PresignGet(a.BlobKey, s.presignExpiry)
+		if err == nil {
+			http.Redirect(w, r, url, http.StatusFound)
+			return
+		}
+	}
+
+	f, err := s.openLocalFile(a.Path)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]any{"error": "artifact file is no longer available"})
+		return
+	}
+	defer f.Close()
+	w.Header().Set("Content-Type", a.MIME)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", a.Name))
+	http.ServeContent(w, r, a.Name, a.CreatedAt, f)
+}
+
+// openLocalFile opens a file for download, decrypting it when it is media
+// encrypted at rest.
+func (s *Server) openLocalFile(path string) (io.ReadSeekCloser, error) {
+	if s.media != nil && s.media.Cipher() != nil && s.media.Cipher().IsEncrypted(path) {
+		data, err := s.media.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return nopCloser{bytes.NewReader(data)}, nil
+	}
+	return os.Open(path)
+}
+
+type nopCloser struct{ io.ReadSeeker }
+
+func (nopCloser) Close() error { return nil }
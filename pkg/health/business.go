@@ -0,0 +1,74 @@
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/agent"
+	"github.com/sipeed/picoclaw/pkg/ledgerforge"
+)
+
+// BusinessResponse is the caller's active business and the businesses they
+// can switch to.
+type BusinessResponse struct {
+	BusinessID string                 `json:"business_id,omitempty"`
+	Businesses []ledgerforge.Business `json:"businesses"`
+}
+
+// businessHandler shows the business the caller's session is bound to, the
+// one webhook requests without a business_id use.
+func (s *Server) businessHandler(w http.ResponseWriter, r *http.Request) {
+	sessionKey, ctx, err := s.authenticateUser(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized: "+err.Error())
+		return
+	}
+	businesses, err := s.agentLoop.Businesses(ctx)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	if businesses == nil {
+		businesses = []ledgerforge.Business{}
+	}
+	writeJSON(w, http.StatusOK, BusinessResponse{
+		BusinessID: s.agentLoop.ActiveBusiness(sessionKey),
+		Businesses: businesses,
+	})
+}
+
+// switchBusinessHandler binds the caller's session to a business, like
+// /business switch in chat.
+func (s *Server) switchBusinessHandler(w http.ResponseWriter, r *http.Request) {
+	sessionKey, ctx, err := s.authenticateUser(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized: "+err.Error())
+		return
+	}
+	if s.denyObserver(w, r) {
+		return
+	}
+	var body struct {
+		BusinessID string `json:"business_id"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<10)).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if strings.TrimSpace(body.BusinessID) == "" {
+		writeError(w, http.StatusBadRequest, "business_id is required")
+		return
+	}
+
+	b, err := s.agentLoop.SwitchBusiness(ctx, sessionKey, strings.TrimSpace(body.BusinessID))
+	switch {
+	case errors.Is(err, agent.ErrUnknownBusiness):
+		writeError(w, http.StatusNotFound, err.Error())
+	case err != nil:
+		writeError(w, http.StatusBadGateway, err.Error())
+	default:
+		writeJSON(w, http.StatusOK, b)
+	}
+}
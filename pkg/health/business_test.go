@@ -0,0 +1,45 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/agent"
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestSwitchBusiness(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Agents.Defaults.Workspace = t.TempDir()
+	stateDir := filepath.Join(cfg.Agents.Defaults.Workspace, "state")
+	require.NoError(t, os.MkdirAll(stateDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(stateDir, "state.json"),
+		[]byte(`{"active_auth":{"biz-1":{"channel":"api"},"biz-2":{"channel":"api"}}}`), 0o644))
+	token, tokenHash := generateBearerToken()
+	al := agent.NewAgentLoop(cfg, bus.NewMessageBus(), echoProvider{})
+	s := NewServer("127.0.0.1", 0, WithAgentLoop(al), WithPairing(true, []string{tokenHash}, ""))
+
+	rec := adminRequest(s, http.MethodGet, "/business", "", "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = adminRequest(s, http.MethodPut, "/business", token, `{"business_id":"biz-3"}`)
+	assert.Equal(t, http.StatusNotFound, rec.Code, rec.Body.String())
+
+	rec = adminRequest(s, http.MethodPut, "/business", token, `{"business_id":"biz-2"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = adminRequest(s, http.MethodGet, "/business", token, "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var resp BusinessResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, "biz-2", resp.BusinessID)
+	assert.Len(t, resp.Businesses, 2)
+	assert.Equal(t, "biz-2", al.ActiveBusiness("api:"+tokenHash[:8]))
+}
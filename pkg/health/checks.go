@@ -0,0 +1,124 @@
+package health
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// checkHistorySize is the number of results kept per check.
+	checkHistorySize = 20
+	// flapTransitions is the number of status changes within the history
+	// window that marks a check as flapping.
+	flapTransitions = 4
+
+	// statusWarn is the status of a failing warning check.
+	statusWarn = "warn"
+)
+
+// CheckResult is one evaluation of a readiness check.
+type CheckResult struct {
+	Status    string    `json:"status"`
+	Message   string    `json:"message,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// RegisterWarningCheck adds a check like RegisterCheck, except that while it
+// fails its status is "warn": /ready still answers 200 but reports the
+// gateway degraded, and Healthy stays true. It suits conditions a restart
+// can't fix, such as an overheating board.
+func (s *Server) RegisterWarningCheck(name string, checkFn func() (bool, string)) {
+	s.mu.Lock()
+	s.warnOnly[name] = true
+	s.mu.Unlock()
+
+	s.RegisterCheck(name, checkFn)
+}
+
+// RunChecks re-evaluates all registered checks every interval until ctx is
+// done, building the history used for flap detection.
+func (s *Server) RunChecks(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.mu.RLock()
+			fns := make(map[string]func() (bool, string), len(s.checkFns))
+			for name, fn := range s.checkFns {
+				fns[name] = fn
+			}
+			s.mu.RUnlock()
+
+			for name, fn := range fns {
+				s.evaluateCheck(name, fn)
+			}
+		}
+	}
+}
+
+// Healthy reports whether the server is ready and no check is failing, the
+// same condition under which /ready answers 200. Warning checks don't count.
+func (s *Server) Healthy() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if !s.ready {
+		return false
+	}
+	for _, check := range s.checks {
+		if check.Status == "fail" {
+			return false
+		}
+	}
+	return true
+}
+
+// evaluateCheck runs checkFn without holding the lock and records the result.
+func (s *Server) evaluateCheck(name string, checkFn func() (bool, string)) {
+	ok, msg := checkFn()
+	status := statusString(ok)
+	s.mu.RLock()
+	if !ok && s.warnOnly[name] {
+		status = statusWarn
+	}
+	s.mu.RUnlock()
+	s.recordCheck(name, CheckResult{
+		Status:    status,
+		Message:   msg,
+		Timestamp: time.Now(),
+	})
+}
+
+func (s *Server) recordCheck(name string, result CheckResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	history := append(s.history[name], result)
+	if len(history) > checkHistorySize {
+		history = history[len(history)-checkHistorySize:]
+	}
+	s.history[name] = history
+
+	s.checks[name] = Check{
+		Name:      name,
+		Status:    result.Status,
+		Message:   result.Message,
+		Timestamp: result.Timestamp,
+		Flapping:  isFlapping(history),
+	}
+}
+
+// isFlapping reports whether the status changed often enough within the
+// history window to be an intermittent problem rather than a steady state.
+func isFlapping(history []CheckResult) bool {
+	transitions := 0
+	for i := 1; i < len(history); i++ {
+		if history[i].Status != history[i-1].Status {
+			transitions++
+		}
+	}
+	return transitions >= flapTransitions
+}
@@ -0,0 +1,75 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getReady(t *testing.T, s *Server, query string) (int, StatusResponse) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	s.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ready"+query, nil))
+	var resp StatusResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	return rec.Code, resp
+}
+
+func TestCheckHistoryAndFlapping(t *testing.T) {
+	s := NewServer("127.0.0.1", 0)
+	s.SetReady(true)
+
+	up := true
+	s.RegisterCheck("provider", func() (bool, string) { return up, "" })
+
+	// Steady failures are a hard failure, not flapping.
+	up = false
+	for i := 0; i < 3; i++ {
+		s.evaluateCheck("provider", s.checkFns["provider"])
+	}
+	code, resp := getReady(t, s, "")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.False(t, resp.Checks["provider"].Flapping)
+	assert.Empty(t, resp.Checks["provider"].History, "history only with verbose=1")
+
+	for i := 0; i < flapTransitions; i++ {
+		up = !up
+		s.evaluateCheck("provider", s.checkFns["provider"])
+	}
+	code, resp = getReady(t, s, "?verbose=1")
+	check := resp.Checks["provider"]
+	assert.True(t, check.Flapping)
+	assert.Equal(t, statusString(up), check.Status)
+	assert.Len(t, check.History, 1+3+flapTransitions)
+	assert.Equal(t, http.StatusServiceUnavailable, code, "a flapping check still reports its latest status")
+
+	for i := 0; i < checkHistorySize; i++ {
+		s.evaluateCheck("provider", s.checkFns["provider"])
+	}
+	_, resp = getReady(t, s, "?verbose=1")
+	assert.Len(t, resp.Checks["provider"].History, checkHistorySize)
+	assert.False(t, resp.Checks["provider"].Flapping, "stable again once transitions leave the window")
+}
+
+func TestWarningCheckDegrades(t *testing.T) {
+	s := NewServer("127.0.0.1", 0)
+	s.SetReady(true)
+
+	hot := true
+	s.RegisterWarningCheck("hardware:temperature", func() (bool, string) { return !hot, "CPU at 84.0°C" })
+	code, resp := getReady(t, s, "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "degraded", resp.Status)
+	assert.Equal(t, "warn", resp.Checks["hardware:temperature"].Status)
+	assert.True(t, s.Healthy(), "a warning doesn't fail the watchdog")
+
+	hot = false
+	s.evaluateCheck("hardware:temperature", s.checkFns["hardware:temperature"])
+	code, resp = getReady(t, s, "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ready", resp.Status)
+}
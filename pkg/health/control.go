@@ -0,0 +1,169 @@
+package health
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/update"
+)
+
+// PairingStatus is the response of the control socket's /pairing endpoint.
+type PairingStatus struct {
+	Code            string `json:"code,omitempty"` // empty once used
+	Address         string `json:"address"`        // host:port of the API
+	ExternalAddress string `json:"external_address,omitempty"`
+	PairedDevices   int    `json:"paired_devices"`
+	RequirePairing  bool   `json:"require_pairing"`
+}
+
+// DaemonStatus is the response of the control socket's /status endpoint.
+type DaemonStatus struct {
+	PID             int              `json:"pid"`
+	Version         string           `json:"version,omitempty"`
+	StartedAt       time.Time        `json:"started_at"`
+	Uptime          string           `json:"uptime"`
+	Ready           bool             `json:"ready"`
+	ExternalAddress string           `json:"external_address,omitempty"` // mapped on the router
+	PairedDevices   int              `json:"paired_devices"`
+	Running         int              `json:"running"` // agent runs holding a worker
+	Queued          int              `json:"queued"`  // agent runs waiting for one
+	Checks          map[string]Check `json:"checks,omitempty"`
+	LastErrors      []logger.Entry   `json:"last_errors,omitempty"`
+
+	Release *update.ReleaseStatus `json:"release,omitempty"` // with a release check
+}
+
+// WithVersion sets the version reported by the control socket's /status.
+func WithVersion(v string) ServerOption {
+	return func(s *Server) {
+		s.version = v
+	}
+}
+
+// WithShutdown lets the control socket's /stop and /restart ask the gateway
+// to shut down; fn is called once the response is sent, with restart set
+// for /restart.
+func WithShutdown(fn func(restart bool)) ServerOption {
+	return func(s *Server) {
+		s.shutdown = fn
+	}
+}
+
+// ServeControl serves local control endpoints on a unix socket at path until
+// Stop. The socket is only accessible to the gateway's user, so the
+// endpoints need no token; they are not served on the network address.
+func (s *Server) ServeControl(path string) error {
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		conn.Close()
+		return fmt.Errorf("control socket %s is in use by another gateway", path)
+	}
+	os.Remove(path) // left behind by a gateway that did not stop cleanly
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("failed to listen on control socket: %w", err)
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		ln.Close()
+		return fmt.Errorf("failed to restrict control socket: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /pairing", s.pairingStatusHandler)
+	mux.HandleFunc("POST /pairing", s.newPairingCodeHandler)
+	mux.HandleFunc("GET /status", s.daemonStatusHandler)
+	mux.HandleFunc("POST /stop", s.shutdownHandler(false))
+	mux.HandleFunc("POST /restart", s.shutdownHandler(true))
+	mux.HandleFunc("POST /replay/{id}", s.replayHandler)
+
+	srv := &http.Server{Handler: recoverPanics(mux), ReadTimeout: 5 * time.Second}
+	s.mu.Lock()
+	s.control = srv
+	s.mu.Unlock()
+	return srv.Serve(ln)
+}
+
+func (s *Server) pairingStatus() PairingStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	status := PairingStatus{
+		Address:         s.server.Addr,
+		ExternalAddress: s.externalAddr,
+		PairedDevices:   len(s.pairedTokens),
+		RequirePairing:  s.requirePairing,
+	}
+	if !s.pairingUsed {
+		status.Code = s.pairingCode
+	}
+	return status
+}
+
+func (s *Server) pairingStatusHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.pairingStatus())
+}
+
+// newPairingCodeHandler replaces the pairing code, so another device can pair
+// once the current code is used.
+func (s *Server) newPairingCodeHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if s.agentLoop == nil {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]any{"error": "pairing is not available without the agent API"})
+		return
+	}
+	s.GenerateNewPairingCode()
+	json.NewEncoder(w).Encode(s.pairingStatus())
+}
+
+func (s *Server) daemonStatusHandler(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	status := DaemonStatus{
+		PID:             os.Getpid(),
+		Version:         s.version,
+		StartedAt:       s.startTime,
+		Uptime:          time.Since(s.startTime).Round(time.Second).String(),
+		ExternalAddress: s.externalAddr,
+		PairedDevices:   len(s.pairedTokens),
+		Checks:          make(map[string]Check, len(s.checks)),
+	}
+	for name, check := range s.checks {
+		status.Checks[name] = check
+	}
+	s.mu.RUnlock()
+	status.Ready = s.Healthy()
+	status.Running = s.workPool.Running()
+	status.Queued = s.workPool.Queued()
+	status.LastErrors = logger.RecentErrors()
+	status.Release = s.releaseStatus()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// shutdownHandler answers before asking the gateway to stop, since stopping
+// closes this socket.
+func (s *Server) shutdownHandler(restart bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if s.shutdown == nil {
+			w.WriteHeader(http.StatusNotImplemented)
+			json.NewEncoder(w).Encode(map[string]any{"error": "this server cannot be stopped remotely"})
+			return
+		}
+		action := "stopping"
+		if restart {
+			action = "restarting"
+		}
+		json.NewEncoder(w).Encode(map[string]any{"status": action, "pid": os.Getpid()})
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		go s.shutdown(restart)
+	}
+}
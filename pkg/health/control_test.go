@@ -0,0 +1,125 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestControlSocketReportsPairing(t *testing.T) {
+	s, _ := newUploadServer(t)
+	path := filepath.Join(t.TempDir(), "picoclaw.sock")
+	errCh := make(chan error, 1)
+	go func() { errCh <- s.ServeControl(path) }()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+	status := func(method string) PairingStatus {
+		t.Helper()
+		var resp *http.Response
+		require.Eventually(t, func() bool {
+			req, _ := http.NewRequest(method, "http://picoclaw/pairing", nil)
+			var err error
+			resp, err = client.Do(req)
+			return err == nil
+		}, 2*time.Second, 10*time.Millisecond)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var st PairingStatus
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&st))
+		return st
+	}
+
+	st := status(http.MethodGet)
+	assert.Equal(t, s.GetPairingCode(), st.Code)
+	assert.Equal(t, "127.0.0.1:0", st.Address)
+	assert.Zero(t, st.PairedDevices)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	// Pairing uses up the code until a new one is issued.
+	req := httptest.NewRequest(http.MethodPost, "/pair", nil)
+	req.Header.Set("X-Pairing-Code", st.Code)
+	rec := httptest.NewRecorder()
+	s.pairHandler(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	st = status(http.MethodGet)
+	assert.Empty(t, st.Code)
+	assert.Equal(t, 1, st.PairedDevices)
+
+	st = status(http.MethodPost)
+	assert.Len(t, st.Code, 6)
+	assert.Equal(t, s.GetPairingCode(), st.Code)
+
+	require.NoError(t, s.Stop(context.Background()))
+	assert.ErrorIs(t, <-errCh, http.ErrServerClosed)
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err), "socket removed on stop")
+}
+
+func TestControlStatusAndShutdown(t *testing.T) {
+	restarts := make(chan bool, 1)
+	s, _ := newUploadServer(t, WithVersion("1.2.3"), WithShutdown(func(restart bool) { restarts <- restart }))
+	s.SetReady(true)
+	s.RegisterCheck("channel:telegram", func() (bool, string) { return false, "channel not running" })
+
+	rec := httptest.NewRecorder()
+	s.daemonStatusHandler(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var st DaemonStatus
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &st))
+	assert.Equal(t, os.Getpid(), st.PID)
+	assert.Equal(t, "1.2.3", st.Version)
+	assert.False(t, st.Ready, "a failing check makes the gateway not ready")
+	assert.Equal(t, "fail", st.Checks["channel:telegram"].Status)
+	assert.Empty(t, st.ExternalAddress)
+
+	s.SetExternalAddress("198.51.100.4:18790")
+	rec = httptest.NewRecorder()
+	s.daemonStatusHandler(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &st))
+	assert.Equal(t, "198.51.100.4:18790", st.ExternalAddress)
+	assert.Equal(t, "198.51.100.4:18790", s.pairingStatus().ExternalAddress)
+
+	rec = httptest.NewRecorder()
+	s.shutdownHandler(true)(rec, httptest.NewRequest(http.MethodPost, "/restart", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "restarting")
+	select {
+	case restart := <-restarts:
+		assert.True(t, restart)
+	case <-time.After(time.Second):
+		t.Fatal("shutdown callback not called")
+	}
+
+	s, _ = newUploadServer(t)
+	rec = httptest.NewRecorder()
+	s.shutdownHandler(false)(rec, httptest.NewRequest(http.MethodPost, "/stop", nil))
+	assert.Equal(t, http.StatusNotImplemented, rec.Code)
+}
+
+func TestPairingCodeFromSetup(t *testing.T) {
+	s, _ := newUploadServer(t, WithPairing(true, nil, ""), WithPairingCode("123456"))
+	assert.Equal(t, "123456", s.GetPairingCode())
+
+	req := httptest.NewRequest(http.MethodPost, "/pair", nil)
+	req.Header.Set("X-Pairing-Code", "123456")
+	rec := httptest.NewRecorder()
+	s.pairHandler(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
@@ -0,0 +1,96 @@
+package health
+
+import (
+	"embed"
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
+
+// dashboardDays is how many days of usage the dashboard shows.
+const dashboardDays = 7
+
+//go:embed ui
+var uiFiles embed.FS
+
+// DashboardSummary is the response of GET /ui/api/summary.
+type DashboardSummary struct {
+	Version        string           `json:"version,omitempty"`
+	Model          string           `json:"model"`
+	Uptime         string           `json:"uptime"`
+	Ready          bool             `json:"ready"`
+	Checks         map[string]Check `json:"checks"`
+	PairedDevices  int              `json:"paired_devices"`
+	RequirePairing bool             `json:"require_pairing"`
+	Running        int              `json:"running"`
+	Queued         int              `json:"queued"`
+	Maintenance    bool             `json:"maintenance"`
+	Usage          []usage.DayStats `json:"usage,omitempty"` // oldest first
+}
+
+// WithDashboard serves the web dashboard at /ui/. The page itself is public;
+// its data and chat box use the same bearer token as the webhook.
+func WithDashboard(enabled bool) ServerOption {
+	return func(s *Server) {
+		s.dashboard = enabled
+	}
+}
+
+// WithUsage shows t's daily counters on the dashboard.
+func WithUsage(t *usage.Tracker) ServerOption {
+	return func(s *Server) {
+		s.usage = t
+	}
+}
+
+func (s *Server) registerDashboard(mux *http.ServeMux) {
+	files, _ := fs.Sub(uiFiles, "ui")
+	static := http.StripPrefix("/ui/", http.FileServerFS(files))
+	mux.Handle("GET /ui/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Content-Security-Policy", "default-src 'self'; frame-ancestors 'none'")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		static.ServeHTTP(w, r)
+	}))
+	mux.Handle("GET /ui", http.RedirectHandler("/ui/", http.StatusMovedPermanently))
+	mux.HandleFunc("GET /ui/api/summary", traced("GET /ui/api/summary", s.dashboardSummaryHandler))
+}
+
+func (s *Server) dashboardSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if !s.isAuthorized(r) {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]any{"error": "unauthorized: invalid or missing bearer token"})
+		return
+	}
+
+	s.mu.RLock()
+	summary := DashboardSummary{
+		Version:        s.version,
+		Model:          s.model,
+		Uptime:         time.Since(s.startTime).Round(time.Second).String(),
+		Checks:         make(map[string]Check, len(s.checks)),
+		PairedDevices:  len(s.pairedTokens),
+		RequirePairing: s.requirePairing,
+	}
+	for name, check := range s.checks {
+		summary.Checks[name] = check
+	}
+	s.mu.RUnlock()
+	summary.Ready = s.Healthy()
+	summary.Running = s.workPool.Running()
+	summary.Queued = s.workPool.Queued()
+	summary.Maintenance = s.agentLoop.InMaintenance()
+	if s.usage != nil {
+		now := time.Now()
+		for i := dashboardDays - 1; i >= 0; i-- {
+			summary.Usage = append(summary.Usage, s.usage.Day(now.AddDate(0, 0, -i)))
+		}
+	}
+
+	json.NewEncoder(w).Encode(summary)
+}
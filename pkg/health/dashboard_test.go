@@ -0,0 +1,69 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
+
+func TestDashboardServesUI(t *testing.T) {
+	s, _ := newUploadServer(t, WithDashboard(true))
+
+	rec := httptest.NewRecorder()
+	s.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ui", nil))
+	assert.Equal(t, http.StatusMovedPermanently, rec.Code)
+	assert.Equal(t, "/ui/", rec.Header().Get("Location"))
+
+	for path, contentType := range map[string]string{
+		"/ui/":       "text/html",
+		"/ui/app.js": "javascript",
+	} {
+		rec = httptest.NewRecorder()
+		s.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		require.Equal(t, http.StatusOK, rec.Code, path)
+		assert.Contains(t, rec.Header().Get("Content-Type"), contentType, path)
+		assert.NotEmpty(t, rec.Header().Get("Content-Security-Policy"), path)
+	}
+
+	disabled, _ := newUploadServer(t)
+	rec = httptest.NewRecorder()
+	disabled.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ui/", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestDashboardSummary(t *testing.T) {
+	token, tokenHash := generateBearerToken()
+	tracker, err := usage.NewTracker(t.TempDir())
+	require.NoError(t, err)
+	s, _ := newUploadServer(t,
+		WithDashboard(true),
+		WithPairing(true, []string{tokenHash}, ""),
+		WithModel("test-model"),
+		WithUsage(tracker))
+	s.SetReady(true)
+
+	rec := httptest.NewRecorder()
+	s.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ui/api/summary", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/ui/api/summary", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec = httptest.NewRecorder()
+	s.server.Handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var summary DashboardSummary
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &summary))
+	assert.True(t, summary.Ready)
+	assert.Equal(t, "test-model", summary.Model)
+	assert.Equal(t, 1, summary.PairedDevices)
+	assert.True(t, summary.RequirePairing)
+	require.Len(t, summary.Usage, dashboardDays)
+	assert.Less(t, summary.Usage[0].Date, summary.Usage[dashboardDays-1].Date, "oldest day first")
+}
@@ -0,0 +1,211 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/documents"
+	"github.com/sipeed/picoclaw/pkg/ledgerforge"
+)
+
+// maxDocumentBatch is the most files one POST /documents takes.
+const maxDocumentBatch = 20
+
+// WithDocuments enables POST /documents, which adds documents to the index
+// the search_documents tool searches, with GET /documents and
+// DELETE /documents/{name...} to list and remove them.
+func WithDocuments(store *documents.Store) ServerOption {
+	return func(s *Server) {
+		s.documents = store
+	}
+}
+
+// documentUpload is the JSON form of POST /documents, for text that is not
+// in a file.
+type documentUpload struct {
+	Name       string `json:"name"`
+	Content    string `json:"content"`
+	BusinessID string `json:"business_id"`
+}
+
+// documentsHandler indexes text documents for the caller's business. The
+// multipart form has the documents in "file" and an optional "business_id";
+// a JSON body has "name", "content" and "business_id". Without business_id,
+// the business the caller's session is bound to is used. Only API tokens
+// may add shared documents, which every business's searches see.
+func (s *Server) documentsHandler(w http.ResponseWriter, r *http.Request) {
+	sessionKey, ctx, err := s.authenticateUser(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized: "+err.Error())
+		return
+	}
+	if s.denyObserver(w, r) {
+		return
+	}
+
+	var uploads []documentUpload
+	var requested string
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/json" {
+		var body documentUpload
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, documents.MaxDocumentBytes+maxFormField)).
+			Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		uploads, requested = []documentUpload{body}, body.BusinessID
+	} else {
+		r.Body = http.MaxBytesReader(w, r.Body, documents.MaxDocumentBytes*maxDocumentBatch+maxFormField)
+		if err := r.ParseMultipartForm(maxFormField); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				writeError(w, http.StatusRequestEntityTooLarge, errUploadTooLarge.Error())
+				return
+			}
+			writeError(w, http.StatusBadRequest, "failed to parse multipart form")
+			return
+		}
+		defer r.MultipartForm.RemoveAll()
+		files := r.MultipartForm.File["file"]
+		if len(files) > maxDocumentBatch {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("at most %d files can be sent at once", maxDocumentBatch))
+			return
+		}
+		for _, fh := range files {
+			if fh.Size > documents.MaxDocumentBytes {
+				writeError(w, http.StatusRequestEntityTooLarge, fh.Filename+": "+errUploadTooLarge.Error())
+				return
+			}
+			f, err := fh.Open()
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "failed to read "+fh.Filename)
+				return
+			}
+			data, err := io.ReadAll(f)
+			f.Close()
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "failed to read "+fh.Filename)
+				return
+			}
+			text, err := documents.Text(data)
+			if err != nil {
+				writeError(w, http.StatusUnsupportedMediaType, fh.Filename+": "+err.Error())
+				return
+			}
+			uploads = append(uploads, documentUpload{Name: fh.Filename, Content: text})
+		}
+		requested = r.FormValue("business_id")
+	}
+	if len(uploads) == 0 {
+		writeError(w, http.StatusBadRequest, "at least one file is required")
+		return
+	}
+	for _, u := range uploads {
+		if strings.TrimSpace(u.Name) == "" || strings.TrimSpace(u.Content) == "" {
+			writeError(w, http.StatusBadRequest, "each document needs a name and some text")
+			return
+		}
+	}
+
+	businessID, status, err := s.documentBusiness(ctx, sessionKey, requested)
+	if err != nil {
+		writeError(w, status, err.Error())
+		return
+	}
+	infos := make([]documents.Info, 0, len(uploads))
+	for _, u := range uploads {
+		info, err := s.documents.Add(ctx, businessID, u.Name, u.Content)
+		if err != nil {
+			writeError(w, http.StatusBadGateway, err.Error())
+			return
+		}
+		infos = append(infos, info)
+	}
+	writeJSON(w, http.StatusCreated, map[string]any{
+		"business_id": businessID,
+		"documents":   infos,
+	})
+}
+
+// documentListHandler lists the indexed documents of a business, or the
+// shared ones.
+func (s *Server) documentListHandler(w http.ResponseWriter, r *http.Request) {
+	sessionKey, ctx, err := s.authenticateUser(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized: "+err.Error())
+		return
+	}
+	businessID, status, err := s.documentBusiness(ctx, sessionKey, r.URL.Query().Get("business_id"))
+	if err != nil {
+		writeError(w, status, err.Error())
+		return
+	}
+	infos, err := s.documents.List(businessID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"business_id": businessID,
+		"documents":   infos,
+	})
+}
+
+// documentDeleteHandler removes a document from the index.
+func (s *Server) documentDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	sessionKey, ctx, err := s.authenticateUser(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized: "+err.Error())
+		return
+	}
+	if s.denyObserver(w, r) {
+		return
+	}
+	businessID, status, err := s.documentBusiness(ctx, sessionKey, r.URL.Query().Get("business_id"))
+	if err != nil {
+		writeError(w, status, err.Error())
+		return
+	}
+	err = s.documents.Remove(businessID, r.PathValue("name"))
+	switch {
+	case errors.Is(err, documents.ErrNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// documentBusiness returns the business whose documents a request works
+// on: the one it names, or the one its session is bound to. LedgerForge
+// users must name one of their own businesses; API tokens may also work on
+// the shared documents.
+func (s *Server) documentBusiness(ctx context.Context, sessionKey, requested string) (string, int, error) {
+	businessID := strings.TrimSpace(requested)
+	active := s.agentLoop.ActiveBusiness(sessionKey)
+	if businessID == "" {
+		businessID = active
+	}
+	if ledgerforge.Token(ctx) == "" || (businessID != "" && businessID == active) {
+		return businessID, 0, nil
+	}
+	if businessID == "" {
+		return "", http.StatusBadRequest, errors.New("business_id is required; no business is selected for this session")
+	}
+	businesses, err := s.agentLoop.Businesses(ctx)
+	if err != nil {
+		return "", http.StatusBadGateway, err
+	}
+	if !slices.ContainsFunc(businesses, func(b ledgerforge.Business) bool { return b.ID == businessID }) {
+		return "", http.StatusForbidden, errors.New("not a business of this user: " + businessID)
+	}
+	return businessID, 0, nil
+}
@@ -0,0 +1,92 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/documents"
+)
+
+// lengthEmbedder embeds each text as its length.
+type lengthEmbedder struct{ echoProvider }
+
+func (lengthEmbedder) Embed(ctx context.Context, texts []string, model string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = []float32{float32(len(text)), 1}
+	}
+	return vectors, nil
+}
+
+func TestDocuments(t *testing.T) {
+	token, tokenHash := generateBearerToken()
+	store := documents.New(t.TempDir(), lengthEmbedder{}, "test-embed", documents.Options{})
+	s, _ := newUploadServer(t,
+		WithPairing(true, []string{tokenHash}, ""),
+		WithJWTAuth("secret"),
+		WithDocuments(store))
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, LedgerForgeClaims{Sub: "u1"}).
+		SignedString([]byte("secret"))
+	require.NoError(t, err)
+
+	upload := func(bearer string, parts ...[2]string) *httptest.ResponseRecorder {
+		req := multipartRequest(t, parts...)
+		req.URL.Path = "/documents"
+		req.Header.Set("Authorization", "Bearer "+bearer)
+		rec := httptest.NewRecorder()
+		s.server.Handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := upload(token, [2]string{"file:prices.md", "Widgets: $12"}, [2]string{"file:policy.txt", "No refunds."})
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var resp struct {
+		BusinessID string           `json:"business_id"`
+		Documents  []documents.Info `json:"documents"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Empty(t, resp.BusinessID, "API tokens add shared documents")
+	require.Len(t, resp.Documents, 2)
+	assert.Equal(t, 1, resp.Documents[0].Chunks)
+
+	rec = upload(token, [2]string{"file:logo.png", "\x89PNG\r\n\x1a\n\x00"})
+	assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+	rec = upload(signed, [2]string{"file:notes.txt", "hello"})
+	assert.Equal(t, http.StatusBadRequest, rec.Code, "users need a business")
+	rec = upload(signed, [2]string{"business_id", "biz-9"}, [2]string{"file:notes.txt", "hello"})
+	assert.Equal(t, http.StatusForbidden, rec.Code, "users can only index their own businesses")
+
+	rec = adminRequest(s, http.MethodPost, "/documents?business_id=biz-1", token, "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	req := httptest.NewRequest(http.MethodPost, "/documents",
+		strings.NewReader(`{"name":"terms.txt","content":"Net 30.","business_id":"biz-1"}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	rec = httptest.NewRecorder()
+	s.server.Handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	rec = adminRequest(s, http.MethodGet, "/documents", token, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Len(t, resp.Documents, 2)
+	assert.Equal(t, "policy.txt", resp.Documents[0].Name)
+
+	matches, err := store.Search(context.Background(), "biz-1", "Net 30.", 1)
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	assert.Equal(t, "terms.txt", matches[0].Document)
+
+	rec = adminRequest(s, http.MethodDelete, "/documents/policy.txt", token, "")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	rec = adminRequest(s, http.MethodDelete, "/documents/policy.txt", token, "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,89 @@
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/sipeed/picoclaw/pkg/eventlog"
+)
+
+// defaultEventLimit caps how many events one /events request returns.
+const defaultEventLimit = 500
+
+// errEventLimit stops reading the event log once enough events are collected.
+var errEventLimit = errors.New("event limit reached")
+
+// WithEventLog serves the agent event log under /events.
+func WithEventLog(l *eventlog.Log) ServerOption {
+	return func(s *Server) {
+		s.events = l
+	}
+}
+
+// eventsHandler returns events after a sequence number, oldest first.
+//
+// Query parameters: since (sequence number, default 0), type, client (the
+// X-Client of API requests), limit. The response's next is the since to pass
+// to continue reading.
+func (s *Server) eventsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if !s.isAuthorized(r) {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]any{"error": "unauthorized: invalid or missing bearer token"})
+		return
+	}
+
+	params := r.URL.Query()
+	var since uint64
+	if v := params.Get("since"); v != "" {
+		var err error
+		if since, err = strconv.ParseUint(v, 10, 64); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]any{"error": "invalid since: expected a sequence number"})
+			return
+		}
+	}
+	limit := defaultEventLimit
+	if v := params.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]any{"error": "invalid limit"})
+			return
+		}
+		limit = min(n, defaultEventLimit)
+	}
+	eventType := params.Get("type")
+	client := params.Get("client")
+
+	events := []eventlog.Event{}
+	next := since
+	err := s.events.Read(since, func(e eventlog.Event) error {
+		next = e.Seq
+		if eventType != "" && e.Type != eventType {
+			return nil
+		}
+		if client != "" && e.Data["client"] != client {
+			return nil
+		}
+		events = append(events, e)
+		if len(events) >= limit {
+			return errEventLimit
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errEventLimit) {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]any{"error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{
+		"events": events,
+		"count":  len(events),
+		"next":   next,
+	})
+}
@@ -0,0 +1,102 @@
+package health
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/export"
+	"github.com/sipeed/picoclaw/pkg/ledgerforge"
+)
+
+// exportHandler serves the transactions picoclaw created for a business, as
+// recorded in the event log, for import into other accounting tools.
+//
+// Query parameters: business (default: the business the caller's session
+// is bound to), from and to (YYYY-MM-DD, inclusive, on the transaction
+// date), format (csv, the default, or ofx). A caller signed in with a JWT
+// can only export the businesses they belong to.
+func (s *Server) exportHandler(w http.ResponseWriter, r *http.Request) {
+	sessionKey, ctx, err := s.authenticateUser(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized: "+err.Error())
+		return
+	}
+
+	params := r.URL.Query()
+	businessID := strings.TrimSpace(params.Get("business"))
+	if businessID == "" {
+		businessID = s.agentLoop.ActiveBusiness(sessionKey)
+	}
+	if businessID == "" {
+		writeError(w, http.StatusBadRequest, "business is required; no business is selected for this session")
+		return
+	}
+	from, to := params.Get("from"), params.Get("to")
+	for _, date := range []string{from, to} {
+		if _, err := time.Parse(time.DateOnly, date); date != "" && err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid date %q: expected YYYY-MM-DD", date))
+			return
+		}
+	}
+	if from != "" && to != "" && to < from {
+		writeError(w, http.StatusBadRequest, "to is before from")
+		return
+	}
+	format := strings.ToLower(params.Get("format"))
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "ofx" {
+		writeError(w, http.StatusBadRequest, "format must be csv or ofx")
+		return
+	}
+
+	if token, _ := ctx.Value(constants.ContextKeyJWTToken).(string); token != "" {
+		businesses, err := s.agentLoop.Businesses(ctx)
+		if err != nil {
+			writeError(w, http.StatusBadGateway, err.Error())
+			return
+		}
+		if !slices.ContainsFunc(businesses, func(b ledgerforge.Business) bool { return b.ID == businessID }) {
+			writeError(w, http.StatusForbidden, "you don't belong to business "+businessID)
+			return
+		}
+	}
+
+	txs, err := export.Transactions(s.events, businessID, from, to)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var buf bytes.Buffer
+	name := "transactions-" + businessID
+	if from != "" || to != "" {
+		name += "-" + from + "_" + to
+	}
+	if format == "ofx" {
+		err = export.WriteOFX(&buf, export.Statement{
+			BusinessID: businessID,
+			Currency:   s.locales.For(businessID).Currency,
+			From:       from,
+			To:         to,
+			Now:        time.Now(),
+		}, txs)
+		w.Header().Set("Content-Type", "application/x-ofx")
+	} else {
+		err = export.WriteCSV(&buf, txs)
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+"."+format))
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
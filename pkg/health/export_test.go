@@ -0,0 +1,63 @@
+package health
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/eventlog"
+)
+
+func TestExportTransactions(t *testing.T) {
+	events, err := eventlog.Open(t.TempDir(), eventlog.Options{})
+	require.NoError(t, err)
+	defer events.Close()
+	for _, e := range []eventlog.Event{
+		{Type: eventlog.TypeTransactionCreated, BusinessID: "biz-1", Data: map[string]any{
+			"transaction_id": "tx-1", "date": "2026-03-02", "vendor": "Corner Cafe", "amount": "-12.50",
+			"currency": "CAD", "category": "Meals", "source": "receipt",
+		}},
+		{Type: eventlog.TypeTransactionCreated, BusinessID: "biz-1", Data: map[string]any{
+			"transaction_id": "tx-2", "date": "2026-04-10", "vendor": "Shell", "amount": "-60.00",
+			"currency": "CAD", "category": "Fuel", "source": "receipt",
+		}},
+	} {
+		_, err := events.Append(e)
+		require.NoError(t, err)
+	}
+
+	token, tokenHash := generateBearerToken()
+	s, _ := newUploadServer(t, WithPairing(true, []string{tokenHash}, ""), WithEventLog(events))
+	get := func(bearer, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/export/transactions?"+query, nil)
+		req.Header.Set("Authorization", "Bearer "+bearer)
+		rec := httptest.NewRecorder()
+		s.exportHandler(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, get("wrong", "business=biz-1").Code)
+	assert.Equal(t, http.StatusBadRequest, get(token, "").Code, "no business")
+	assert.Equal(t, http.StatusBadRequest, get(token, "business=biz-1&from=03/01/2026").Code)
+	assert.Equal(t, http.StatusBadRequest, get(token, "business=biz-1&from=2026-04-01&to=2026-03-01").Code)
+	assert.Equal(t, http.StatusBadRequest, get(token, "business=biz-1&format=qif").Code)
+
+	rec := get(token, "business=biz-1&from=2026-03-01&to=2026-03-31")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "text/csv; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="transactions-biz-1-2026-03-01_2026-03-31.csv"`,
+		rec.Header().Get("Content-Disposition"))
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.Equal(t, "2026-03-02,tx-1,Corner Cafe,,-12.50,CAD,Meals,,,receipt", lines[1])
+
+	rec = get(token, "business=biz-1&format=ofx")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "application/x-ofx", rec.Header().Get("Content-Type"))
+	assert.Equal(t, 2, strings.Count(rec.Body.String(), "<STMTTRN>"))
+	assert.Contains(t, rec.Body.String(), "<FITID>tx-2</FITID>")
+}
@@ -0,0 +1,86 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/agent"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/i18n"
+	"github.com/sipeed/picoclaw/pkg/wirelog"
+	"github.com/sipeed/picoclaw/pkg/workpool"
+)
+
+// federationHandler answers a request another instance delegated, sent as a
+// multipart webhook form that also names the origin instance and its session.
+// Peers authenticate with a token paired with this instance.
+func (s *Server) federationHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	requestID := r.Header.Get(constants.RequestIDHeader)
+	if requestID == "" || len(requestID) > 128 {
+		requestID = wirelog.NewID()
+	}
+	w.Header().Set(constants.RequestIDHeader, requestID)
+	ctx := context.WithValue(r.Context(), constants.ContextKeyRequestID, requestID)
+
+	lang := s.language(ctx, r, "")
+	if !s.isAdmin(r) {
+		writeWebhookError(w, http.StatusUnauthorized, i18n.T(lang, "api.unauthorized", "admin token required"))
+		return
+	}
+	form, status, err := s.readMultipart(r, lang)
+	if err != nil {
+		writeWebhookError(w, status, err.Error())
+		return
+	}
+	if form.businessID != "" {
+		lang = s.language(ctx, r, form.businessID)
+	}
+	if form.origin == "" || form.session == "" {
+		writeWebhookError(w, http.StatusBadRequest, i18n.T(lang, "federation.origin_required"))
+		return
+	}
+	if strings.TrimSpace(form.message) == "" && len(form.mediaPaths) == 0 {
+		writeWebhookError(w, http.StatusBadRequest, i18n.T(lang, "webhook.message_required"))
+		return
+	}
+
+	if form.businessID != "" {
+		ctx = context.WithValue(ctx, constants.ContextKeyBusinessID, form.businessID)
+	}
+	if form.userToken != "" {
+		ctx = context.WithValue(ctx, constants.ContextKeyJWTToken, form.userToken)
+	}
+	ctx, cancel := context.WithTimeout(ctx, 120*time.Second)
+	defer cancel()
+
+	response, err := s.agentLoop.ProcessDelegated(ctx, form.origin, form.session, form.message, form.mediaPaths...)
+	if errors.Is(err, agent.ErrMaintenance) {
+		w.Header().Set("Retry-After", "60")
+		writeWebhookError(w, http.StatusServiceUnavailable, i18n.T(lang, "webhook.maintenance"))
+		return
+	}
+	if errors.Is(err, workpool.ErrQueueFull) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(s.workPool.RetryAfter().Seconds())))
+		writeWebhookError(w, http.StatusTooManyRequests, i18n.T(lang, "webhook.busy"))
+		return
+	}
+	if err != nil {
+		writeWebhookError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	model := s.model
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(WebhookResponse{
+		Response: &response,
+		Model:    &model,
+		Files:    form.storedFiles,
+	})
+}
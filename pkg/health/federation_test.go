@@ -0,0 +1,47 @@
+package health
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/federation"
+)
+
+func TestFederationDelegate(t *testing.T) {
+	token, tokenHash := generateBearerToken()
+	s, workspace := newUploadServer(t, WithPairing(true, []string{tokenHash}, ""))
+	peer := httptest.NewServer(s.server.Handler)
+	defer peer.Close()
+
+	cfg := config.FederationConfig{
+		Peers: []config.FederationPeer{{Name: "server", URL: peer.URL, Token: token}},
+		Rules: []config.FederationRule{{Peer: "server"}},
+	}
+	router, err := federation.New(cfg, "home")
+	require.NoError(t, err)
+
+	attachment := filepath.Join(t.TempDir(), "receipt.jpg")
+	require.NoError(t, os.WriteFile(attachment, []byte("jpeg bytes"), 0o600))
+	response, err := router.Delegate(context.Background(), cfg.Peers[0], federation.Request{
+		Session: "agent:main:main",
+		Message: "Process the attached receipt",
+		Media:   []string{attachment},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "done", response)
+
+	matches, err := filepath.Glob(filepath.Join(workspace, "media", "*_receipt.jpg"))
+	require.NoError(t, err)
+	assert.Len(t, matches, 1)
+
+	cfg.Peers[0].Token = "pc_wrong"
+	_, err = router.Delegate(context.Background(), cfg.Peers[0], federation.Request{Session: "s", Message: "hi"})
+	assert.ErrorContains(t, err, "unauthorized")
+}
@@ -0,0 +1,290 @@
+package health
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/blob"
+	"github.com/sipeed/picoclaw/pkg/i18n"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/media"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// uploadKeyPrefix is where webhook uploads are stored in the blob store.
+const uploadKeyPrefix = "uploads/"
+
+const (
+	// defaultMaxUpload is the largest file a webhook call may upload.
+	defaultMaxUpload = 20 << 20
+	// maxFormField bounds the text fields of a multipart webhook call.
+	maxFormField = 1 << 20
+	// maxParallelStores bounds the blob store copies of one webhook call.
+	maxParallelStores = 4
+)
+
+var errUploadTooLarge = errors.New("uploaded file is too large")
+
+// StoredFile is an upload copied to the blob store.
+type StoredFile struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+	URL  string `json:"url,omitempty"` // presigned download URL
+}
+
+// WithBlobStore copies webhook uploads to store and returns download URLs
+// valid for presignExpiry.
+func WithBlobStore(store blob.Store, presignExpiry time.Duration) ServerOption {
+	return func(s *Server) {
+		s.blobStore = store
+		s.presignExpiry = presignExpiry
+	}
+}
+
+// WithMediaLibrary saves webhook uploads through the media library, which
+// reuses the stored copy when the same file is uploaded again.
+func WithMediaLibrary(l *media.Library) ServerOption {
+	return func(s *Server) {
+		s.media = l
+	}
+}
+
+// WithMaxUploadSize rejects uploaded files larger than n bytes. Zero removes
+// the limit.
+func WithMaxUploadSize(n int64) ServerOption {
+	return func(s *Server) {
+		s.maxUpload = n
+	}
+}
+
+// multipartForm is the content of a multipart webhook or delegated call.
+type multipartForm struct {
+	message     string
+	businessID  string
+	debug       string
+	origin      string // instance that delegated the call
+	session     string // origin's session
+	userToken   string // caller's LedgerForge token, passed on by the origin
+	mediaPaths  []string
+	storedFiles []StoredFile
+}
+
+// readMultipart streams a multipart webhook body: text fields are read into
+// memory and each file is copied straight into the workspace media, without
+// buffering it or writing a temporary copy first. Since a file is saved for
+// the business named so far, business_id must precede the files. Copies to
+// the blob store run in the background while later files arrive. On error it
+// also returns the HTTP status to answer with and an error written in lang.
+func (s *Server) readMultipart(r *http.Request, lang string) (*multipartForm, int, error) {
+	invalidForm := errors.New(i18n.T(lang, "upload.invalid_form"))
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, http.StatusBadRequest, invalidForm
+	}
+	workspace := s.agentLoop.DefaultWorkspace()
+	form := &multipartForm{}
+	seen := make(map[string]bool)
+
+	var (
+		wg      sync.WaitGroup
+		sem     = make(chan struct{}, maxParallelStores)
+		pending []*pendingStore // in upload order
+	)
+	defer wg.Wait()
+
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			wg.Wait()
+			for _, p := range pending {
+				if p.file != nil {
+					form.storedFiles = append(form.storedFiles, *p.file)
+				}
+			}
+			return form, 0, nil
+		}
+		if err != nil {
+			return nil, http.StatusBadRequest, invalidForm
+		}
+
+		if part.FileName() == "" {
+			name := part.FormName()
+			value, err := io.ReadAll(io.LimitReader(part, maxFormField+1))
+			part.Close()
+			if err != nil {
+				return nil, http.StatusBadRequest, invalidForm
+			}
+			if len(value) > maxFormField {
+				return nil, http.StatusRequestEntityTooLarge, errors.New(i18n.T(lang, "upload.field_too_large", name))
+			}
+			if seen[name] {
+				continue // the first value wins, as with FormValue
+			}
+			seen[name] = true
+			switch name {
+			case "message":
+				form.message = string(value)
+			case "business_id":
+				if len(form.mediaPaths) > 0 {
+					return nil, http.StatusBadRequest, errors.New(i18n.T(lang, "upload.business_first"))
+				}
+				form.businessID = string(value)
+			case "debug":
+				form.debug = string(value)
+			case "origin":
+				form.origin = string(value)
+			case "session":
+				form.session = string(value)
+			case "user_token":
+				form.userToken = string(value)
+			}
+			continue
+		}
+
+		localPath, deduped, err := s.saveUpload(part, part.FileName(), form.businessID, workspace)
+		part.Close()
+		if errors.Is(err, errUploadTooLarge) {
+			return nil, http.StatusRequestEntityTooLarge, errors.New(i18n.T(lang, "upload.too_large"))
+		}
+		if err != nil {
+			return nil, http.StatusInsufficientStorage, err
+		}
+		if localPath == "" {
+			continue
+		}
+		form.mediaPaths = append(form.mediaPaths, localPath)
+		if s.blobStore != nil {
+			p := &pendingStore{}
+			pending = append(pending, p)
+			name, contentType := part.FileName(), part.Header.Get("Content-Type")
+			wg.Add(1)
+			sem <- struct{}{}
+			go func() {
+				defer wg.Done()
+				defer func() { <-sem }()
+				p.file = s.storeUpload(r.Context(), name, localPath, contentType, deduped)
+			}()
+		}
+	}
+}
+
+// pendingStore is a blob store copy in progress; file is set when it is done
+// and nil if it failed.
+type pendingStore struct {
+	file *StoredFile
+}
+
+// limitedReader fails once more than n bytes have been read.
+type limitedReader struct {
+	r        io.Reader
+	n        int64
+	exceeded bool
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		l.exceeded = true
+		return n, errUploadTooLarge
+	}
+	return n, err
+}
+
+// saveUpload saves an uploaded file for businessID and returns its local path
+// (empty if it could not be saved) and whether an identical stored file was
+// reused. Only quota and size errors are returned; other failures are logged.
+func (s *Server) saveUpload(src io.Reader, filename, businessID, workspace string) (string, bool, error) {
+	limited := &limitedReader{r: src, n: s.maxUpload}
+	if s.maxUpload > 0 {
+		src = limited
+	}
+	var (
+		path    string
+		deduped bool
+		err     error
+	)
+	if s.media == nil {
+		path = utils.SaveUploadedFile(src, filename, workspace)
+	} else {
+		path, deduped, err = s.media.Save(src, filename, businessID)
+	}
+	if limited.exceeded {
+		return "", false, errUploadTooLarge
+	}
+	if errors.Is(err, media.ErrQuotaExceeded) {
+		return "", false, err
+	}
+	if err != nil {
+		logger.ErrorCF("webhook", "Failed to save uploaded file", map[string]any{"error": err.Error()})
+		return "", false, nil
+	}
+	return path, deduped, nil
+}
+
+// putUpload uploads the plaintext of a saved upload, so downloads from the
+// blob store work when media is encrypted at rest.
+func (s *Server) putUpload(ctx context.Context, key, localPath, contentType string) error {
+	if s.media == nil || s.media.Cipher() == nil {
+		return blob.PutFile(ctx, s.blobStore, key, localPath, contentType)
+	}
+	data, err := s.media.ReadFile(localPath)
+	if err != nil {
+		return err
+	}
+	return s.blobStore.Put(ctx, key, bytes.NewReader(data), int64(len(data)), contentType)
+}
+
+// storeUpload copies a saved upload to the blob store, unless it is a
+// duplicate that was stored before. The local copy stays in place for the
+// agent and skills to read.
+func (s *Server) storeUpload(ctx context.Context, name, localPath, contentType string, deduped bool) *StoredFile {
+	key := uploadKeyPrefix + filepath.Base(localPath)
+	if !deduped {
+		if err := s.putUpload(ctx, key, localPath, contentType); err != nil {
+			logger.WarnCF("storage", "Failed to store upload", map[string]any{"key": key, "error": err.Error()})
+			return nil
+		}
+	}
+	file := &StoredFile{Name: name, Key: key}
+	if url, err := s.blobStore.PresignGet(key, s.presignExpiry); err == nil {
+		file.URL = url
+	}
+	return file
+}
+
+// fileDownloadHandler redirects to a presigned URL for a stored file.
+func (s *Server) fileDownloadHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.isAuthorized(r) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]any{"error": "unauthorized: invalid or missing bearer token"})
+		return
+	}
+
+	key := r.PathValue("key")
+	if !blob.ValidKey(key) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]any{"error": "invalid file key"})
+		return
+	}
+	url, err := s.blobStore.PresignGet(key, s.presignExpiry)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]any{"error": err.Error()})
+		return
+	}
+	http.Redirect(w, r, url, http.StatusFound)
+}
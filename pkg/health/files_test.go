@@ -0,0 +1,63 @@
+package health
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memStore map[string]string
+
+func (m memStore) Put(_ context.Context, key string, r io.Reader, _ int64, _ string) error {
+	data, err := io.ReadAll(r)
+	m[key] = string(data)
+	return err
+}
+
+func (m memStore) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader(m[key])), nil
+}
+
+func (m memStore) Delete(_ context.Context, key string) error {
+	delete(m, key)
+	return nil
+}
+
+func (m memStore) PresignGet(key string, expiry time.Duration) (string, error) {
+	return "https://bucket.example.com/" + key + "?expires=" + expiry.String(), nil
+}
+
+func TestStoreUploadAndDownloadRedirect(t *testing.T) {
+	store := memStore{}
+	s := NewServer("127.0.0.1", 0, WithBlobStore(store, time.Hour))
+
+	local := filepath.Join(t.TempDir(), "1a2b3c4d_receipt.jpg")
+	require.NoError(t, os.WriteFile(local, []byte("jpeg"), 0o600))
+	stored := s.storeUpload(t.Context(), "receipt.jpg", local, "image/jpeg", false)
+	require.NotNil(t, stored)
+	assert.Equal(t, "uploads/1a2b3c4d_receipt.jpg", stored.Key)
+	assert.Equal(t, "jpeg", store[stored.Key])
+	assert.Equal(t, "https://bucket.example.com/uploads/1a2b3c4d_receipt.jpg?expires=1h0m0s", stored.URL)
+
+	req := httptest.NewRequest(http.MethodGet, "/files/"+stored.Key, nil)
+	req.SetPathValue("key", stored.Key)
+	rec := httptest.NewRecorder()
+	s.fileDownloadHandler(rec, req)
+	assert.Equal(t, http.StatusFound, rec.Code)
+	assert.Equal(t, stored.URL, rec.Header().Get("Location"))
+
+	req = httptest.NewRequest(http.MethodGet, "/files/x", nil)
+	req.SetPathValue("key", "../secret")
+	rec = httptest.NewRecorder()
+	s.fileDownloadHandler(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
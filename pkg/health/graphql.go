@@ -0,0 +1,483 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/agent"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/graphql"
+	"github.com/sipeed/picoclaw/pkg/i18n"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/transcript"
+	"github.com/sipeed/picoclaw/pkg/usage"
+	"github.com/sipeed/picoclaw/pkg/wirelog"
+	"github.com/sipeed/picoclaw/pkg/workpool"
+)
+
+// graphqlSchema describes POST /graphql. It is served at GET /graphql/schema
+// for client code generators, as the endpoint has no introspection.
+const graphqlSchema = `# Time is an RFC 3339 timestamp.
+scalar Time
+# JSON is any JSON value.
+scalar JSON
+
+type Query {
+  # Sessions of an agent (default: the default agent), most recent first.
+  sessions(agentId: String): [Session!]!
+  # Messages of a session, oldest first.
+  history(sessionKey: String!, agentId: String): [Message!]!
+  # Recorded turns matching the filters, as GET /history/search.
+  searchHistory(text: String, businessId: String, channel: String, sessionKey: String,
+    since: Time, until: Time, limit: Int): [Turn!]!
+  # Paired devices. Needs admin credentials.
+  devices: [Device!]!
+  # Daily usage counters, oldest first.
+  usage(days: Int = 7): [UsageDay!]!
+}
+
+type Mutation {
+  # Sends a message in the caller's session, as POST /webhook.
+  sendMessage(message: String!, businessId: String): MessageResult!
+}
+
+type Subscription {
+  # Sends a message and streams the agent's progress, ending with a
+  # "response" or "error" event.
+  sendMessage(message: String!, businessId: String): MessageEvent!
+}
+
+type Session {
+  key: String!
+  messages: Int!
+  created: Time!
+  updated: Time!
+}
+
+type Message {
+  role: String!
+  content: String!
+  toolCallId: String
+  toolCalls: [ToolCall!]
+}
+
+type ToolCall {
+  id: String!
+  name: String!
+  arguments: String # JSON
+}
+
+type Turn {
+  id: Int!
+  time: Time!
+  agentId: String
+  sessionKey: String
+  channel: String
+  chatId: String
+  businessId: String
+  message: String!
+  response: String!
+  snippet: String
+  toolCalls: [TurnToolCall!]
+}
+
+type TurnToolCall {
+  name: String!
+  arguments: String # JSON
+  result: String
+  error: String
+}
+
+type Device {
+  id: String!
+  name: String
+  pairedAt: Time
+}
+
+type UsageDay {
+  date: String!
+  requests: JSON # by channel
+  tokens: JSON # by model
+  receipts: Int!
+  errors: JSON # by component
+  skills: JSON # by skill
+}
+
+type MessageResult {
+  response: String!
+  model: String!
+  requestId: String!
+}
+
+type MessageEvent {
+  # tool_call, message, response or error
+  type: String!
+  tool: String
+  content: String
+}
+`
+
+// maxUsageDays bounds the usage query.
+const maxUsageDays = 90
+
+// MessageEvent is a step of a sendMessage subscription.
+type MessageEvent struct {
+	Type    string `json:"type"`
+	Tool    string `json:"tool,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+// graphqlRequestKey carries the HTTP request to resolvers, which check
+// its credentials.
+type graphqlRequestKey struct{}
+
+// WithGraphQL serves the GraphQL API at /graphql. Fields use the same
+// credentials as the REST routes they mirror.
+func WithGraphQL(enabled bool) ServerOption {
+	return func(s *Server) {
+		s.graphQL = enabled
+	}
+}
+
+func (s *Server) registerGraphQL(mux *http.ServeMux) {
+	s.graphqlSchema = s.newGraphQLSchema()
+	mux.HandleFunc("POST /graphql", traced("POST /graphql", s.graphqlHandler))
+	mux.HandleFunc("GET /graphql", traced("GET /graphql", s.graphqlHandler))
+	mux.HandleFunc("GET /graphql/schema", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprint(w, graphqlSchema)
+	})
+}
+
+// graphqlHandler runs a GraphQL request: a JSON body for POST, or query,
+// operationName and variables parameters for GET, which only runs queries.
+// Subscriptions are streamed as server-sent "next" events followed by a
+// "complete" event, and need Accept: text/event-stream.
+func (s *Server) graphqlHandler(w http.ResponseWriter, r *http.Request) {
+	var req graphql.Request
+	if r.Method == http.MethodGet {
+		params := r.URL.Query()
+		req.Query = params.Get("query")
+		req.OperationName = params.Get("operationName")
+		if v := params.Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				writeJSON(w, http.StatusBadRequest, graphql.ErrorResponse(errors.New("invalid variables")))
+				return
+			}
+		}
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		lang := s.language(r.Context(), r, "")
+		writeJSON(w, http.StatusBadRequest, graphql.ErrorResponse(errors.New(i18n.T(lang, "api.invalid_body"))))
+		return
+	}
+
+	op, err := s.graphqlSchema.Prepare(req)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, graphql.ErrorResponse(err))
+		return
+	}
+	if r.Method == http.MethodGet && op.Type != "query" {
+		w.Header().Set("Allow", http.MethodPost)
+		writeJSON(w, http.StatusMethodNotAllowed,
+			graphql.ErrorResponse(fmt.Errorf("a %s must be sent with POST", op.Type)))
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), graphqlRequestKey{}, r)
+	if op.Type != "subscription" {
+		writeJSON(w, http.StatusOK, op.Execute(ctx))
+		return
+	}
+
+	if !strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		writeJSON(w, http.StatusNotAcceptable,
+			graphql.ErrorResponse(errors.New("subscriptions need Accept: text/event-stream")))
+		return
+	}
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	rc.Flush()
+
+	results, err := op.Subscribe(ctx)
+	if err != nil {
+		writeGraphQLEvent(w, "next", graphql.ErrorResponse(err))
+	} else {
+		for result := range results {
+			if writeGraphQLEvent(w, "next", result) != nil {
+				return
+			}
+			rc.Flush()
+		}
+	}
+	writeGraphQLEvent(w, "complete", nil)
+	rc.Flush()
+}
+
+func writeGraphQLEvent(w http.ResponseWriter, event string, resp *graphql.Response) error {
+	data := []byte("")
+	if resp != nil {
+		var err error
+		if data, err = json.Marshal(resp); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	return err
+}
+
+// newGraphQLSchema builds the resolvers of graphqlSchema.
+func (s *Server) newGraphQLSchema() *graphql.Schema {
+	toolCall := &graphql.Object{Name: "ToolCall", Fields: map[string]*graphql.Field{
+		"id": {},
+		"name": {Resolve: func(_ context.Context, source any, _ graphql.Args) (any, error) {
+			tc := source.(providers.ToolCall)
+			if tc.Function != nil && tc.Function.Name != "" {
+				return tc.Function.Name, nil
+			}
+			return tc.Name, nil
+		}},
+		"arguments": {Resolve: func(_ context.Context, source any, _ graphql.Args) (any, error) {
+			tc := source.(providers.ToolCall)
+			if tc.Function != nil {
+				return tc.Function.Arguments, nil
+			}
+			args, err := json.Marshal(tc.Arguments)
+			return string(args), err
+		}},
+	}}
+	message := &graphql.Object{Name: "Message", Fields: map[string]*graphql.Field{
+		"role":       {},
+		"content":    {},
+		"toolCallId": {},
+		"toolCalls": {Type: toolCall, Resolve: func(_ context.Context, source any, _ graphql.Args) (any, error) {
+			return source.(providers.Message).ToolCalls, nil
+		}},
+	}}
+	turnToolCall := &graphql.Object{Name: "TurnToolCall", Fields: map[string]*graphql.Field{
+		"name": {}, "arguments": {}, "result": {}, "error": {},
+	}}
+	turn := &graphql.Object{Name: "Turn", Fields: map[string]*graphql.Field{
+		"id": {}, "time": {}, "agentId": {}, "sessionKey": {}, "channel": {}, "chatId": {},
+		"businessId": {}, "message": {}, "response": {}, "snippet": {},
+		"toolCalls": {Type: turnToolCall},
+	}}
+	session := &graphql.Object{Name: "Session", Fields: map[string]*graphql.Field{
+		"key": {}, "messages": {}, "created": {}, "updated": {},
+	}}
+	device := &graphql.Object{Name: "Device", Fields: map[string]*graphql.Field{
+		"id": {}, "name": {}, "pairedAt": {},
+	}}
+	usageDay := &graphql.Object{Name: "UsageDay", Fields: map[string]*graphql.Field{
+		"date": {}, "requests": {}, "tokens": {}, "receipts": {}, "errors": {}, "skills": {},
+	}}
+	messageResult := &graphql.Object{Name: "MessageResult", Fields: map[string]*graphql.Field{
+		"response": {}, "model": {}, "requestId": {},
+	}}
+	messageEvent := &graphql.Object{Name: "MessageEvent", Fields: map[string]*graphql.Field{
+		"type": {}, "tool": {}, "content": {},
+	}}
+
+	return &graphql.Schema{
+		Query: &graphql.Object{Name: "Query", Fields: map[string]*graphql.Field{
+			"sessions":      {Type: session, Resolve: s.resolveSessions},
+			"history":       {Type: message, Resolve: s.resolveHistory},
+			"searchHistory": {Type: turn, Resolve: s.resolveSearchHistory},
+			"devices":       {Type: device, Resolve: s.resolveDevices},
+			"usage":         {Type: usageDay, Resolve: s.resolveUsage},
+		}},
+		Mutation: &graphql.Object{Name: "Mutation", Fields: map[string]*graphql.Field{
+			"sendMessage": {Type: messageResult, Resolve: s.resolveSendMessage},
+		}},
+		Subscription: &graphql.Object{Name: "Subscription", Fields: map[string]*graphql.Field{
+			"sendMessage": {Type: messageEvent, Resolve: s.resolveMessageStream},
+		}},
+	}
+}
+
+var errGraphQLUnauthorized = errors.New("unauthorized: invalid or missing bearer token")
+
+// graphqlRequest returns the HTTP request of a resolver's context.
+func graphqlRequest(ctx context.Context) *http.Request {
+	return ctx.Value(graphqlRequestKey{}).(*http.Request)
+}
+
+func (s *Server) resolveSessions(ctx context.Context, _ any, args graphql.Args) (any, error) {
+	if !s.isAuthorized(graphqlRequest(ctx)) {
+		return nil, errGraphQLUnauthorized
+	}
+	return s.agentLoop.Sessions(args.String("agentId"))
+}
+
+func (s *Server) resolveHistory(ctx context.Context, _ any, args graphql.Args) (any, error) {
+	if !s.isAuthorized(graphqlRequest(ctx)) {
+		return nil, errGraphQLUnauthorized
+	}
+	key := args.String("sessionKey")
+	if key == "" {
+		return nil, errors.New("sessionKey is required")
+	}
+	return s.agentLoop.SessionHistory(args.String("agentId"), key)
+}
+
+func (s *Server) resolveSearchHistory(ctx context.Context, _ any, args graphql.Args) (any, error) {
+	if !s.isAuthorized(graphqlRequest(ctx)) {
+		return nil, errGraphQLUnauthorized
+	}
+	q := transcript.Query{
+		Text:       args.String("text"),
+		BusinessID: args.String("businessId"),
+		Channel:    args.String("channel"),
+		SessionKey: args.String("sessionKey"),
+	}
+	var err error
+	if v := args.String("since"); v != "" {
+		if q.Since, err = time.Parse(time.RFC3339, v); err != nil {
+			return nil, errors.New("invalid since: expected RFC 3339 timestamp")
+		}
+	}
+	if v := args.String("until"); v != "" {
+		if q.Until, err = time.Parse(time.RFC3339, v); err != nil {
+			return nil, errors.New("invalid until: expected RFC 3339 timestamp")
+		}
+	}
+	if q.Limit, err = args.Int("limit", 0); err != nil || q.Limit < 0 {
+		return nil, errors.New("invalid limit")
+	}
+	return s.agentLoop.SearchTranscripts(ctx, q)
+}
+
+func (s *Server) resolveDevices(ctx context.Context, _ any, _ graphql.Args) (any, error) {
+	if !s.isAdmin(graphqlRequest(ctx)) {
+		return nil, errors.New("unauthorized: admin credentials required")
+	}
+	return s.listDevices(), nil
+}
+
+func (s *Server) resolveUsage(ctx context.Context, _ any, args graphql.Args) (any, error) {
+	if !s.isAuthorized(graphqlRequest(ctx)) {
+		return nil, errGraphQLUnauthorized
+	}
+	days, err := args.Int("days", dashboardDays)
+	if err != nil || days < 1 || days > maxUsageDays {
+		return nil, fmt.Errorf("days must be between 1 and %d", maxUsageDays)
+	}
+	stats := []usage.DayStats{}
+	if s.usage != nil {
+		now := time.Now()
+		for i := days - 1; i >= 0; i-- {
+			stats = append(stats, s.usage.Day(now.AddDate(0, 0, -i)))
+		}
+	}
+	return stats, nil
+}
+
+// graphqlMessage is a sendMessage call ready to run.
+type graphqlMessage struct {
+	ctx        context.Context
+	message    string
+	sessionKey string
+	requestID  string
+	lang       string
+}
+
+// prepareMessage authenticates a sendMessage call like a webhook call.
+func (s *Server) prepareMessage(ctx context.Context, args graphql.Args) (*graphqlMessage, error) {
+	r := graphqlRequest(ctx)
+	requestID := r.Header.Get(constants.RequestIDHeader)
+	if requestID == "" || len(requestID) > 128 {
+		requestID = wirelog.NewID()
+	}
+	r = r.WithContext(context.WithValue(ctx, constants.ContextKeyRequestID, requestID))
+
+	sessionKey, userCtx, err := s.authenticateUser(r)
+	if err != nil {
+		return nil, errors.New(i18n.T(s.language(r.Context(), r, ""), "api.unauthorized", err))
+	}
+	businessID := args.String("businessId")
+	lang := s.language(userCtx, r, businessID)
+	if s.isObserver(r) {
+		return nil, errors.New(i18n.T(lang, "api.read_only"))
+	}
+	message := args.String("message")
+	if strings.TrimSpace(message) == "" {
+		return nil, errors.New(i18n.T(lang, "api.message_required"))
+	}
+	if businessID != "" {
+		userCtx = context.WithValue(userCtx, constants.ContextKeyBusinessID, businessID)
+	}
+	return &graphqlMessage{
+		ctx:        userCtx,
+		message:    message,
+		sessionKey: sessionKey,
+		requestID:  requestID,
+		lang:       lang,
+	}, nil
+}
+
+// send runs the agent on m, in the same session as the caller's webhook
+// calls.
+func (s *Server) send(ctx context.Context, m *graphqlMessage) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 120*time.Second)
+	defer cancel()
+	response, err := s.agentLoop.ProcessDirectWithChannel(ctx, m.message, m.sessionKey, "api", "mobile-client")
+	switch {
+	case errors.Is(err, agent.ErrMaintenance):
+		return "", errors.New(i18n.T(m.lang, "webhook.maintenance"))
+	case errors.Is(err, workpool.ErrQueueFull):
+		return "", errors.New(i18n.T(m.lang, "webhook.busy"))
+	}
+	return response, err
+}
+
+func (s *Server) resolveSendMessage(ctx context.Context, _ any, args graphql.Args) (any, error) {
+	m, err := s.prepareMessage(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	response, err := s.send(m.ctx, m)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"response": response, "model": s.model, "request_id": m.requestID}, nil
+}
+
+// resolveMessageStream runs a sendMessage subscription. Progress events
+// are dropped rather than holding up the agent if the client reads slowly;
+// the final event is always sent.
+func (s *Server) resolveMessageStream(ctx context.Context, _ any, args graphql.Args) (any, error) {
+	m, err := s.prepareMessage(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan any, 64)
+	progress := func(p agent.Progress) {
+		select {
+		case events <- MessageEvent{Type: p.Type, Tool: p.Tool, Content: p.Content}:
+		default:
+		}
+	}
+	go func() {
+		defer close(events)
+		final := MessageEvent{Type: "response"}
+		response, err := s.send(agent.WithProgress(m.ctx, progress), m)
+		if err != nil {
+			final = MessageEvent{Type: "error", Content: err.Error()}
+		} else {
+			final.Content = response
+		}
+		select {
+		case events <- final:
+		case <-ctx.Done():
+		}
+	}()
+	return (<-chan any)(events), nil
+}
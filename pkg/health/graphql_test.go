@@ -0,0 +1,168 @@
+package health
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/agent"
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// toolProvider asks for a tool once, then answers.
+type toolProvider struct{}
+
+func (toolProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	if messages[len(messages)-1].Role == "tool" {
+		return &providers.LLMResponse{Content: "done"}, nil
+	}
+	return &providers.LLMResponse{
+		Content: "Let me look.",
+		ToolCalls: []providers.ToolCall{
+			{ID: "call-1", Name: "list_dir", Arguments: map[string]any{"path": "."}},
+		},
+	}, nil
+}
+
+func (toolProvider) GetDefaultModel() string {
+	return "test-model"
+}
+
+func graphqlCall(t *testing.T, s *Server, token, query string, vars map[string]any) (int, map[string]any) {
+	t.Helper()
+	body, _ := json.Marshal(map[string]any{"query": query, "variables": vars})
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body)))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	s.server.Handler.ServeHTTP(rec, req)
+	var resp map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp), rec.Body.String())
+	return rec.Code, resp
+}
+
+func TestGraphQL(t *testing.T) {
+	token, tokenHash := generateBearerToken()
+	s, _ := newUploadServer(t,
+		WithGraphQL(true),
+		WithPairing(true, []string{tokenHash}, ""),
+		WithPairedDevices([]config.PairedDevice{{TokenHash: tokenHash, Name: "Till 1"}}),
+		WithModel("test-model"))
+
+	status, resp := graphqlCall(t, s, token,
+		`mutation Send($m: String!) { sendMessage(message: $m) { response model } }`,
+		map[string]any{"m": "hello"})
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, map[string]any{"sendMessage": map[string]any{"response": "done", "model": "test-model"}},
+		resp["data"], resp)
+
+	_, resp = graphqlCall(t, s, token, `{
+		sessions { key messages }
+		devices { name }
+		usage(days: 2) { date }
+	}`, nil)
+	assert.Nil(t, resp["errors"])
+	data := resp["data"].(map[string]any)
+	sessions := data["sessions"].([]any)
+	require.Len(t, sessions, 1)
+	session := sessions[0].(map[string]any)
+	assert.Equal(t, float64(2), session["messages"])
+	assert.Equal(t, []any{map[string]any{"name": "Till 1"}}, data["devices"])
+	assert.Empty(t, data["usage"], "no usage tracker")
+
+	_, resp = graphqlCall(t, s, token, `query H($key: String!) { history(sessionKey: $key) { role content } }`,
+		map[string]any{"key": session["key"]})
+	assert.Equal(t, map[string]any{"history": []any{
+		map[string]any{"role": "user", "content": "hello"},
+		map[string]any{"role": "assistant", "content": "done"},
+	}}, resp["data"])
+
+	// Fields check credentials one by one.
+	status, resp = graphqlCall(t, s, "", `{ sessions { key } }`, nil)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, map[string]any{"sessions": nil}, resp["data"])
+	assert.Contains(t, resp["errors"].([]any)[0].(map[string]any)["message"], "unauthorized")
+
+	status, resp = graphqlCall(t, s, token, `{ sessions { nope } }`, nil)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Contains(t, resp["errors"].([]any)[0].(map[string]any)["message"], `cannot query field "nope"`)
+
+	// GET runs queries only.
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet,
+		"/graphql?query="+url.QueryEscape(`mutation { sendMessage(message: "hi") { response } }`), nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	s.server.Handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+
+	rec = httptest.NewRecorder()
+	s.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/graphql/schema", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "type Subscription {")
+
+	disabled, _ := newUploadServer(t)
+	rec = httptest.NewRecorder()
+	disabled.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/graphql/schema", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestGraphQLSubscriptionStreamsProgress(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Agents.Defaults.Workspace = t.TempDir()
+	al := agent.NewAgentLoop(cfg, bus.NewMessageBus(), toolProvider{})
+	s := NewServer("127.0.0.1", 0, WithAgentLoop(al), WithGraphQL(true))
+
+	body := `{"query": "subscription { sendMessage(message: \"hi\") { type tool content } }"}`
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	s.server.Handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotAcceptable, rec.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(body))
+	req.Header.Set("Accept", "text/event-stream")
+	rec = httptest.NewRecorder()
+	s.server.Handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/event-stream", rec.Header().Get("Content-Type"))
+
+	var events []string
+	var data []map[string]any
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if event, ok := strings.CutPrefix(line, "event: "); ok {
+			events = append(events, event)
+		}
+		if payload, ok := strings.CutPrefix(line, "data: "); ok && payload != "" {
+			var resp struct {
+				Data struct {
+					SendMessage map[string]any `json:"sendMessage"`
+				} `json:"data"`
+			}
+			require.NoError(t, json.Unmarshal([]byte(payload), &resp))
+			data = append(data, resp.Data.SendMessage)
+		}
+	}
+	assert.Equal(t, []string{"next", "next", "next", "complete"}, events)
+	require.Len(t, data, 3)
+	assert.Equal(t, map[string]any{"type": "message", "tool": nil, "content": "Let me look."}, data[0])
+	assert.Equal(t, map[string]any{"type": "tool_call", "tool": "list_dir", "content": nil}, data[1])
+	assert.Equal(t, map[string]any{"type": "response", "tool": nil, "content": "done"}, data[2])
+}
@@ -0,0 +1,198 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/i18n"
+)
+
+// Guest link scopes.
+const (
+	GuestScopeUpload = "upload" // POST files only, such as receipts
+	GuestScopeChat   = "chat"   // talk to the agent like a paired device
+)
+
+const (
+	// defaultGuestLinkTTL is how long a guest link works unless the admin
+	// asks for another duration.
+	defaultGuestLinkTTL = 24 * time.Hour
+	// maxGuestLinkTTL bounds guest links, so they can't become permanent
+	// pairings in disguise.
+	maxGuestLinkTTL = 7 * 24 * time.Hour
+	// maxGuestLinks bounds the guest links active at once.
+	maxGuestLinks = 64
+	// guestTokenPrefix tells guest tokens apart from device tokens.
+	guestTokenPrefix = "pcg_"
+)
+
+// GuestLink is a short-lived URL that lets someone use the webhook without
+// pairing a device. The token in the URL is its only credential; it is
+// shown once, when the link is created.
+type GuestLink struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Scope      string    `json:"scope"`
+	BusinessID string    `json:"business_id,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// createGuestLinkHandler mints a guest link from a JSON body of name, scope
+// (upload or chat, default upload), hours (default 24, at most 168) and
+// business_id, the business the guest's messages are for.
+func (s *Server) createGuestLinkHandler(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Name       string `json:"name"`
+		Scope      string `json:"scope"`
+		Hours      int    `json:"hours"`
+		BusinessID string `json:"business_id"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<10)).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	name := strings.TrimSpace(body.Name)
+	if name == "" || len(name) > 64 {
+		writeError(w, http.StatusBadRequest, "name is required, at most 64 bytes")
+		return
+	}
+	scope := body.Scope
+	if scope == "" {
+		scope = GuestScopeUpload
+	}
+	if scope != GuestScopeUpload && scope != GuestScopeChat {
+		writeError(w, http.StatusBadRequest, "scope must be "+GuestScopeUpload+" or "+GuestScopeChat)
+		return
+	}
+	ttl := defaultGuestLinkTTL
+	if body.Hours != 0 {
+		ttl = time.Duration(body.Hours) * time.Hour
+	}
+	if ttl <= 0 || ttl > maxGuestLinkTTL {
+		writeError(w, http.StatusBadRequest, "hours must be between 1 and 168")
+		return
+	}
+
+	token := guestTokenPrefix + randomHex(32)
+	hash := hashToken(token)
+	now := time.Now().UTC()
+	link := &GuestLink{
+		ID:         hash[:deviceIDLength],
+		Name:       name,
+		Scope:      scope,
+		BusinessID: strings.TrimSpace(body.BusinessID),
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(ttl),
+	}
+	s.mu.Lock()
+	s.expireGuestLinks(now)
+	if len(s.guestLinks) >= maxGuestLinks {
+		s.mu.Unlock()
+		writeError(w, http.StatusTooManyRequests, "too many active guest links; revoke some first")
+		return
+	}
+	s.guestLinks[hash] = link
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusCreated, struct {
+		*GuestLink
+		Token string `json:"token"`
+		URL   string `json:"url"`
+	}{link, token, guestURL(r, token)})
+}
+
+func (s *Server) listGuestLinksHandler(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.expireGuestLinks(time.Now())
+	links := make([]GuestLink, 0, len(s.guestLinks))
+	for _, link := range s.guestLinks {
+		links = append(links, *link)
+	}
+	s.mu.Unlock()
+	sort.Slice(links, func(i, j int) bool { return links[i].CreatedAt.Before(links[j].CreatedAt) })
+	writeJSON(w, http.StatusOK, map[string]any{"links": links, "count": len(links)})
+}
+
+// revokeGuestLinkHandler ends a guest link before it expires.
+func (s *Server) revokeGuestLinkHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	s.mu.Lock()
+	found := false
+	for hash, link := range s.guestLinks {
+		if link.ID == id {
+			delete(s.guestLinks, hash)
+			found = true
+		}
+	}
+	s.mu.Unlock()
+	if !found {
+		writeError(w, http.StatusNotFound, "no guest link with this id")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"revoked": true})
+}
+
+// guestInfoHandler tells a guest what their link allows and until when.
+func (s *Server) guestInfoHandler(w http.ResponseWriter, r *http.Request) {
+	link, ok := s.guestLink(r.PathValue("token"))
+	if !ok {
+		writeError(w, http.StatusUnauthorized, i18n.T(s.language(r.Context(), r, ""), "guest.link_invalid"))
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"name":       link.Name,
+		"scope":      link.Scope,
+		"expires_at": link.ExpiresAt,
+	})
+}
+
+// guestWebhookHandler takes a webhook call made with a guest link. Each link
+// has its own session, and its business is fixed by the link.
+func (s *Server) guestWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	r = startWebhook(w, r)
+	link, ok := s.guestLink(r.PathValue("token"))
+	if !ok {
+		writeWebhookError(w, http.StatusUnauthorized, i18n.T(s.language(r.Context(), r, ""), "guest.link_invalid"))
+		return
+	}
+	lang := s.language(r.Context(), r, link.BusinessID)
+	s.serveWebhook(w, r, "guest:"+link.ID, r.Context(), lang, link)
+}
+
+// guestLink returns a copy of the active guest link with token.
+func (s *Server) guestLink(token string) (*GuestLink, bool) {
+	if !strings.HasPrefix(token, guestTokenPrefix) {
+		return nil, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expireGuestLinks(time.Now())
+	link, ok := s.guestLinks[hashToken(token)]
+	if !ok {
+		return nil, false
+	}
+	cp := *link
+	return &cp, true
+}
+
+// expireGuestLinks drops links past their expiry. The caller holds mu.
+func (s *Server) expireGuestLinks(now time.Time) {
+	for hash, link := range s.guestLinks {
+		if now.After(link.ExpiresAt) {
+			delete(s.guestLinks, hash)
+		}
+	}
+}
+
+// guestURL is the URL a guest posts to, on the host the admin reached.
+func guestURL(r *http.Request, token string) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + "/guest/" + token
+}
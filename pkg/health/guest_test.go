@@ -0,0 +1,82 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createGuestLink mints a guest link with body and returns its token.
+func createGuestLink(t *testing.T, s *Server, admin, body string) (string, GuestLink) {
+	t.Helper()
+	rec := adminRequest(s, http.MethodPost, "/admin/guest-links", admin, body)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var created struct {
+		GuestLink
+		Token string `json:"token"`
+		URL   string `json:"url"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	assert.True(t, strings.HasSuffix(created.URL, "/guest/"+created.Token), created.URL)
+	return created.Token, created.GuestLink
+}
+
+func TestGuestLinkUploadOnly(t *testing.T) {
+	admin, adminHash := generateBearerToken()
+	s, _ := newUploadServer(t, WithPairing(true, []string{adminHash}, ""))
+
+	rec := adminRequest(s, http.MethodPost, "/admin/guest-links", "", `{"name":"Sam"}`)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	rec = adminRequest(s, http.MethodPost, "/admin/guest-links", admin, `{"name":"Sam","hours":200}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	token, link := createGuestLink(t, s, admin, `{"name":"Sam","business_id":"biz-1"}`)
+	assert.Equal(t, GuestScopeUpload, link.Scope)
+	assert.WithinDuration(t, time.Now().Add(defaultGuestLinkTTL), link.ExpiresAt, time.Minute)
+
+	rec = adminRequest(s, http.MethodPost, "/guest/"+token, "", `{"message":"what's our revenue?"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+
+	req := multipartRequest(t, [2]string{"file:receipt.jpg", "jpeg bytes"})
+	req.URL.Path = "/guest/" + token
+	rec = httptest.NewRecorder()
+	s.server.Handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	// The guest token is no bearer token for anything else.
+	for _, route := range []string{"/debug/sessions", "/admin/devices"} {
+		rec = adminRequest(s, http.MethodGet, route, token, "")
+		assert.Equal(t, http.StatusUnauthorized, rec.Code, route)
+	}
+}
+
+func TestGuestLinkRevokeAndExpiry(t *testing.T) {
+	admin, adminHash := generateBearerToken()
+	s, _ := newUploadServer(t, WithPairing(true, []string{adminHash}, ""))
+
+	token, link := createGuestLink(t, s, admin, `{"name":"Sam","scope":"chat","hours":1}`)
+	rec := adminRequest(s, http.MethodGet, "/guest/"+token, "", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	rec = adminRequest(s, http.MethodPost, "/guest/"+token, "", `{"message":"hi"}`)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = adminRequest(s, http.MethodDelete, "/admin/guest-links/"+link.ID, admin, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	rec = adminRequest(s, http.MethodPost, "/guest/"+token, "", `{"message":"hi"}`)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	token, _ = createGuestLink(t, s, admin, `{"name":"Alex"}`)
+	s.mu.Lock()
+	s.guestLinks[hashToken(token)].ExpiresAt = time.Now().Add(-time.Second)
+	s.mu.Unlock()
+	rec = adminRequest(s, http.MethodGet, "/guest/"+token, "", "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	rec = adminRequest(s, http.MethodGet, "/admin/guest-links", admin, "")
+	assert.Contains(t, rec.Body.String(), `"count":0`)
+}
@@ -0,0 +1,67 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/transcript"
+)
+
+// historySearchHandler searches recorded conversation turns.
+//
+// Query parameters: q (keywords), business_id, channel, session, since and
+// until (RFC 3339), limit.
+func (s *Server) historySearchHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if !s.isAuthorized(r) {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]any{"error": "unauthorized: invalid or missing bearer token"})
+		return
+	}
+
+	params := r.URL.Query()
+	q := transcript.Query{
+		Text:       params.Get("q"),
+		BusinessID: params.Get("business_id"),
+		Channel:    params.Get("channel"),
+		SessionKey: params.Get("session"),
+	}
+
+	var err error
+	if v := params.Get("since"); v != "" {
+		if q.Since, err = time.Parse(time.RFC3339, v); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]any{"error": "invalid since: expected RFC 3339 timestamp"})
+			return
+		}
+	}
+	if v := params.Get("until"); v != "" {
+		if q.Until, err = time.Parse(time.RFC3339, v); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]any{"error": "invalid until: expected RFC 3339 timestamp"})
+			return
+		}
+	}
+	if v := params.Get("limit"); v != "" {
+		if q.Limit, err = strconv.Atoi(v); err != nil || q.Limit < 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]any{"error": "invalid limit"})
+			return
+		}
+	}
+
+	results, err := s.agentLoop.SearchTranscripts(r.Context(), q)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]any{"error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{
+		"results": results,
+		"count":   len(results),
+	})
+}
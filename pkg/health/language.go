@@ -0,0 +1,32 @@
+package health
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/i18n"
+	"github.com/sipeed/picoclaw/pkg/locale"
+)
+
+// WithLocales answers webhook and pairing calls in the caller's language.
+// Without it they are answered in English unless the client asks otherwise.
+func WithLocales(r *locale.Resolver) ServerOption {
+	return func(s *Server) {
+		s.locales = r
+	}
+}
+
+// language picks the language of a response: the language configured for
+// the user in ctx, else the first supported one in the Accept-Language
+// header, else the business's.
+func (s *Server) language(ctx context.Context, r *http.Request, businessID string) string {
+	userID, _ := ctx.Value(constants.ContextKeyUserID).(string)
+	if lang := s.locales.UserLanguage(userID); lang != "" {
+		return lang
+	}
+	if lang := i18n.FromAcceptLanguage(r.Header.Get("Accept-Language")); lang != "" {
+		return lang
+	}
+	return s.locales.Language(businessID, "")
+}
@@ -0,0 +1,270 @@
+package health
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// Listener middleware names.
+const (
+	MiddlewareLocalOnly = "local_only" // loopback and unix socket clients only
+	MiddlewareAccessLog = "access_log" // logs every request
+	MiddlewareHSTS      = "hsts"       // tells browsers to use HTTPS only
+)
+
+const unixPrefix = "unix:"
+
+// WithListeners sets the extra addresses ServeListeners serves on.
+func WithListeners(listeners []config.ListenerConfig) ServerOption {
+	return func(s *Server) {
+		s.listenerCfgs = listeners
+	}
+}
+
+// ServeListeners starts serving the configured extra listeners and returns
+// their addresses. A listener that can't be set up, for an invalid config or
+// an address in use, is an error and none are served. Stop closes them.
+func (s *Server) ServeListeners() ([]string, error) {
+	type pending struct {
+		name              string
+		ln                net.Listener
+		server            *http.Server
+		certFile, keyFile string
+	}
+	var started []pending
+	closeAll := func() {
+		for _, p := range started {
+			p.ln.Close()
+		}
+	}
+
+	var addrs []string
+	for i, cfg := range s.listenerCfgs {
+		name := cfg.Name
+		if name == "" {
+			name = fmt.Sprintf("listener %d", i+1)
+		}
+		handler, err := s.listenerHandler(name, cfg)
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		ln, err := listen(cfg.Address)
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		server := &http.Server{
+			Handler:      handler,
+			ReadTimeout:  s.server.ReadTimeout,
+			WriteTimeout: s.server.WriteTimeout,
+		}
+		started = append(started, pending{name, ln, server, cfg.CertFile, cfg.KeyFile})
+		addrs = append(addrs, listenerURL(cfg, ln))
+	}
+
+	s.mu.Lock()
+	for _, p := range started {
+		s.listeners = append(s.listeners, p.server)
+	}
+	s.mu.Unlock()
+	for _, p := range started {
+		go func() {
+			var err error
+			if p.certFile != "" {
+				err = p.server.ServeTLS(p.ln, p.certFile, p.keyFile)
+			} else {
+				err = p.server.Serve(p.ln)
+			}
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.ErrorCF("health", "Listener failed", map[string]any{"listener": p.name, "error": err.Error()})
+			}
+		}()
+	}
+	return addrs, nil
+}
+
+// stopListeners shuts down the servers started by ServeListeners.
+func (s *Server) stopListeners(ctx context.Context) {
+	s.mu.Lock()
+	listeners := s.listeners
+	s.listeners = nil
+	s.mu.Unlock()
+	for _, server := range listeners {
+		server.Shutdown(ctx)
+	}
+}
+
+// listenerHandler builds a listener's handler: the server's routes limited
+// to cfg.Routes, wrapped in cfg's middleware.
+func (s *Server) listenerHandler(name string, cfg config.ListenerConfig) (http.Handler, error) {
+	if cfg.Address == "" {
+		return nil, errors.New("address is required")
+	}
+	if (cfg.CertFile == "") != (cfg.KeyFile == "") {
+		return nil, errors.New("TLS needs both cert_file and key_file")
+	}
+	unix := strings.HasPrefix(cfg.Address, unixPrefix)
+	if unix && cfg.CertFile != "" {
+		return nil, errors.New("TLS is not supported on a unix socket")
+	}
+	if cfg.CertFile != "" {
+		if _, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile); err != nil {
+			return nil, err
+		}
+	}
+
+	handler := s.server.Handler
+	if len(cfg.Routes) > 0 {
+		handler = routesOnly(cfg.Routes, handler)
+	}
+	if len(cfg.AllowFrom) > 0 && !unix {
+		allowed, err := parseAllowFrom(cfg.AllowFrom)
+		if err != nil {
+			return nil, err
+		}
+		handler = clientsOnly(allowed, handler)
+	}
+	for i := len(cfg.Middleware) - 1; i >= 0; i-- {
+		switch cfg.Middleware[i] {
+		case MiddlewareLocalOnly:
+			if !unix {
+				handler = clientsOnly([]*net.IPNet{
+					{IP: net.IPv4(127, 0, 0, 0), Mask: net.CIDRMask(8, 32)},
+					{IP: net.IPv6loopback, Mask: net.CIDRMask(128, 128)},
+				}, handler)
+			}
+		case MiddlewareAccessLog:
+			handler = accessLog(name, handler)
+		case MiddlewareHSTS:
+			handler = hsts(handler)
+		default:
+			return nil, fmt.Errorf("unknown middleware %q (valid: local_only, access_log, hsts)", cfg.Middleware[i])
+		}
+	}
+	return handler, nil
+}
+
+// listen opens a TCP address or, with the unix: prefix, a unix socket that
+// only the gateway's user and group can connect to.
+func listen(address string) (net.Listener, error) {
+	path, unix := strings.CutPrefix(address, unixPrefix)
+	if !unix {
+		return net.Listen("tcp", address)
+	}
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("socket %s is in use", path)
+	}
+	os.Remove(path) // left behind by a gateway that did not stop cleanly
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0o660); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+// listenerURL describes where a listener is reached, with the port it got
+// for port 0.
+func listenerURL(cfg config.ListenerConfig, ln net.Listener) string {
+	switch {
+	case strings.HasPrefix(cfg.Address, unixPrefix):
+		return cfg.Address
+	case cfg.CertFile != "":
+		return "https://" + ln.Addr().String()
+	default:
+		return "http://" + ln.Addr().String()
+	}
+}
+
+// routesOnly serves the requests whose path is one of prefixes or below it,
+// and 404 for the rest.
+func routesOnly(prefixes []string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, prefix := range prefixes {
+			prefix = strings.TrimSuffix(prefix, "/")
+			if r.URL.Path == prefix || strings.HasPrefix(r.URL.Path, prefix+"/") {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		writeError(w, http.StatusNotFound, "not found")
+	})
+}
+
+func parseAllowFrom(entries []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, entry := range entries {
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid allow_from entry %q", entry)
+			}
+			bits := 8 * len(ip.To16())
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allow_from entry %q", entry)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// clientsOnly rejects requests from outside allowed with 403.
+func clientsOnly(allowed []*net.IPNet, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if ip := net.ParseIP(host); err == nil && ip != nil {
+			for _, n := range allowed {
+				if n.Contains(ip) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+		}
+		writeError(w, http.StatusForbidden, "forbidden")
+	})
+}
+
+func accessLog(listener string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(rec, r)
+		logger.InfoCF("health", "Request", map[string]any{
+			"listener": listener,
+			"method":   r.Method,
+			"path":     r.URL.Path,
+			"status":   rec.status,
+			"duration": time.Since(start).Round(time.Millisecond).String(),
+			"remote":   r.RemoteAddr,
+		})
+	})
+}
+
+func hsts(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Strict-Transport-Security", "max-age=31536000")
+		next.ServeHTTP(w, r)
+	})
+}
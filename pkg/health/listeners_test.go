@@ -0,0 +1,105 @@
+package health
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestServeListeners(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "picoclaw.sock")
+	s := NewServer("127.0.0.1", 0, WithListeners([]config.ListenerConfig{
+		{Name: "admin", Address: "127.0.0.1:0", Routes: []string{"/ready"}, Middleware: []string{"local_only"}},
+		{Name: "tools", Address: "unix:" + sock},
+	}))
+	s.SetReady(true)
+	addrs, err := s.ServeListeners()
+	require.NoError(t, err)
+	defer s.Stop(context.Background())
+	require.Len(t, addrs, 2)
+	assert.Equal(t, "unix:"+sock, addrs[1])
+
+	resp, err := http.Get(addrs[0] + "/ready")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = http.Get(addrs[0] + "/health")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode, "not in the listener's routes")
+
+	unixClient := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", sock)
+		},
+	}}
+	resp, err = unixClient.Get("http://picoclaw/health")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	require.NoError(t, s.Stop(context.Background()))
+	_, err = unixClient.Get("http://picoclaw/health")
+	assert.Error(t, err, "Stop closes the listeners")
+}
+
+func TestServeListenersRejectsBadConfig(t *testing.T) {
+	for name, cfg := range map[string]config.ListenerConfig{
+		"no address":         {},
+		"unknown middleware": {Address: "127.0.0.1:0", Middleware: []string{"gzip"}},
+		"half TLS":           {Address: "127.0.0.1:0", CertFile: "cert.pem"},
+		"missing cert":       {Address: "127.0.0.1:0", CertFile: "missing.pem", KeyFile: "missing.key"},
+		"bad allow_from":     {Address: "127.0.0.1:0", AllowFrom: []string{"10.0.0.0/33"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := NewServer("127.0.0.1", 0, WithListeners([]config.ListenerConfig{cfg}))
+			_, err := s.ServeListeners()
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestListenerMiddleware(t *testing.T) {
+	s := NewServer("127.0.0.1", 0)
+	s.SetReady(true)
+	handler, err := s.listenerHandler("clients", config.ListenerConfig{
+		Address:    "0.0.0.0:8443",
+		Middleware: []string{"hsts", "access_log"},
+		AllowFrom:  []string{"192.168.1.0/24", "10.0.0.5"},
+	})
+	require.NoError(t, err)
+
+	get := func(remote string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/health", nil)
+		req.RemoteAddr = remote
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	rec := get("192.168.1.20:5000")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, strings.HasPrefix(rec.Header().Get("Strict-Transport-Security"), "max-age="))
+	assert.Equal(t, http.StatusOK, get("10.0.0.5:5000").Code)
+	assert.Equal(t, http.StatusForbidden, get("10.0.0.6:5000").Code)
+
+	local, err := s.listenerHandler("admin", config.ListenerConfig{
+		Address:    "0.0.0.0:9090",
+		Middleware: []string{"local_only"},
+	})
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	req.RemoteAddr = "192.168.1.20:5000"
+	rec = httptest.NewRecorder()
+	local.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
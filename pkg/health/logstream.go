@@ -0,0 +1,102 @@
+package health
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	// logStreamBuffer is the number of entries queued per client before
+	// entries are dropped for a slow reader.
+	logStreamBuffer = 256
+	// logStreamKeepAlive is how often a comment is sent on an idle stream so
+	// proxies don't close it.
+	logStreamKeepAlive = 15 * time.Second
+)
+
+// logStreamHandler tails the daemon log as server-sent events, one JSON
+// logger.Entry per event. Supported query parameters: level (minimum level,
+// default debug), module (comma-separated components) and access_token, for
+// clients like EventSource that cannot set an Authorization header.
+func (s *Server) logStreamHandler(w http.ResponseWriter, r *http.Request) {
+	if token := r.URL.Query().Get("access_token"); token != "" && r.Header.Get("Authorization") == "" {
+		r = r.Clone(r.Context())
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	if !s.isAdmin(r) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]any{"error": "unauthorized: admin credentials required"})
+		return
+	}
+
+	minLevel := logger.DEBUG
+	if v := r.URL.Query().Get("level"); v != "" {
+		level, err := logger.ParseLevel(v)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]any{"error": err.Error()})
+			return
+		}
+		minLevel = level
+	}
+	modules := map[string]bool{}
+	for _, m := range strings.Split(r.URL.Query().Get("module"), ",") {
+		if m = strings.TrimSpace(m); m != "" {
+			modules[m] = true
+		}
+	}
+
+	// The stream outlives the server's write timeout.
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && err != http.ErrNotSupported {
+		return
+	}
+
+	entries, unsubscribe := logger.Subscribe(logStreamBuffer)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	rc.Flush()
+
+	keepAlive := time.NewTicker(logStreamKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.streamsDone:
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			rc.Flush()
+		case entry := <-entries:
+			if level, _ := logger.ParseLevel(entry.Level); level < minLevel {
+				continue
+			}
+			if len(modules) > 0 && !modules[entry.Component] {
+				continue
+			}
+			data, err := json.Marshal(entry)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+				return
+			}
+			rc.Flush()
+		}
+	}
+}
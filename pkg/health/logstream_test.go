@@ -0,0 +1,58 @@
+package health
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+func TestLogStreamFiltersEntries(t *testing.T) {
+	token, tokenHash := generateBearerToken()
+	s := NewServer("127.0.0.1", 0, WithPairing(false, []string{tokenHash}, ""))
+	srv := httptest.NewServer(s.server.Handler)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/admin/logs/stream")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, "no anonymous access even without pairing")
+
+	resp, err = http.Get(srv.URL + "/admin/logs/stream?level=warn&module=agent&access_token=" + token)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	logger.WarnCF("tools", "other module", nil)
+	logger.InfoCF("agent", "below level", nil)
+	logger.WarnCF("agent", "wanted", map[string]any{"session": "s1"})
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+	select {
+	case line := <-lines:
+		require.True(t, strings.HasPrefix(line, "data: "), line)
+		var entry logger.Entry
+		require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &entry))
+		assert.Equal(t, "wanted", entry.Message)
+		assert.Equal(t, "WARN", entry.Level)
+		assert.Equal(t, "agent", entry.Component)
+		assert.Equal(t, "s1", entry.Fields["session"])
+	case <-time.After(2 * time.Second):
+		t.Fatal("no event received")
+	}
+}
@@ -0,0 +1,41 @@
+package health
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/sipeed/picoclaw/pkg/mcp"
+)
+
+// WithMCP serves srv's Model Context Protocol transports at /mcp. Tools run
+// with the gateway's privileges, so callers need admin credentials; a
+// caller's JWT is passed on to tools that call LedgerForge.
+func WithMCP(srv *mcp.Server) ServerOption {
+	return func(s *Server) {
+		s.mcp = srv
+	}
+}
+
+func (s *Server) registerMCP(mux *http.ServeMux) {
+	h := s.mcp.Handler("/mcp")
+	serve := s.requireAdmin(func(w http.ResponseWriter, r *http.Request) {
+		_, userCtx, err := s.authenticateUser(r)
+		if err != nil {
+			writeError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		// HTTP+SSE streams end with the server, like log streams.
+		ctx, cancel := context.WithCancel(userCtx)
+		defer cancel()
+		go func() {
+			select {
+			case <-s.streamsDone:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+	mux.HandleFunc("/mcp", traced("/mcp", serve))
+	mux.HandleFunc("/mcp/", traced("/mcp/", serve))
+}
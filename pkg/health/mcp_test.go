@@ -0,0 +1,37 @@
+package health
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/mcp"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+func TestMCPNeedsAdmin(t *testing.T) {
+	token, tokenHash := generateBearerToken()
+	s, _ := newUploadServer(t,
+		WithPairing(false, []string{tokenHash}, ""),
+		WithMCP(mcp.NewServer("picoclaw", "test", tools.NewToolRegistry())))
+
+	post := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/mcp",
+			strings.NewReader(`{"jsonrpc": "2.0", "id": 1, "method": "tools/list"}`))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		s.server.Handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, post("").Code)
+	rec := post(token)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"jsonrpc": "2.0", "id": 1, "result": {"tools": []}}`, rec.Body.String())
+}
@@ -0,0 +1,38 @@
+package health
+
+import (
+	"net/http"
+	"strings"
+)
+
+// ObserverRole is the device scope, and the JWT role, of read-only
+// credentials: they can read status, usage, history, events and artifacts,
+// but not send messages, change sessions or run tools.
+const ObserverRole = "observer"
+
+// isObserver reports whether the request carries read-only credentials: a
+// pc_ token paired with the observer scope, or a JWT with the observer role.
+func (s *Server) isObserver(r *http.Request) bool {
+	token := s.extractRawToken(r)
+	if token == "" {
+		return false
+	}
+	if s.jwtSecret != "" && !strings.HasPrefix(token, "pc_") {
+		claims, err := s.validateJWT(token)
+		return err == nil && claims.Role == ObserverRole
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.devices[hashToken(token)].Scope == ObserverRole
+}
+
+// denyObserver answers 403 to read-only credentials on a route that changes
+// something, and reports whether it did.
+func (s *Server) denyObserver(w http.ResponseWriter, r *http.Request) bool {
+	if !s.isObserver(r) {
+		return false
+	}
+	writeError(w, http.StatusForbidden, "forbidden: read-only observer token")
+	return true
+}
@@ -0,0 +1,79 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestObserverTokenIsReadOnly(t *testing.T) {
+	observer, observerHash := generateBearerToken()
+	s, _ := newUploadServer(t,
+		WithPairing(true, []string{observerHash}, ""),
+		WithPairedDevices([]config.PairedDevice{{TokenHash: observerHash, Name: "accountant", Scope: ObserverRole}}))
+
+	for _, route := range []string{"/debug/sessions", "/audit/skills", "/history/search?q=x"} {
+		rec := adminRequest(s, http.MethodGet, route, observer, "")
+		assert.NotEqual(t, http.StatusUnauthorized, rec.Code, route)
+		assert.NotEqual(t, http.StatusForbidden, rec.Code, route)
+	}
+
+	rec := adminRequest(s, http.MethodPost, "/webhook", observer, `{"message":"pay the rent"}`)
+	assert.Equal(t, http.StatusForbidden, rec.Code, rec.Body.String())
+	rec = adminRequest(s, http.MethodPut, "/business", observer, `{"business_id":"acme"}`)
+	assert.Equal(t, http.StatusForbidden, rec.Code, rec.Body.String())
+	rec = adminRequest(s, http.MethodGet, "/admin/devices", observer, "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code, "observer token has admin access")
+	rec = adminRequest(s, http.MethodDelete, "/admin/sessions/agent:main:s1", observer, "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code, "observer token can delete sessions")
+
+	rec = adminRequest(s, http.MethodPost, "/rpc", observer, `{"jsonrpc":"2.0","method":"chat.send",`+
+		`"params":{"message":"hi"},"id":1}`)
+	var resp rpcResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.NotNil(t, resp.Error)
+	assert.Equal(t, rpcReadOnly, resp.Error.Code)
+}
+
+func TestAdminApprovesObserver(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, config.SaveConfig(configPath, config.DefaultConfig()))
+	admin, adminHash := generateBearerToken()
+	s, _ := newUploadServer(t, WithPairing(true, []string{adminHash}, configPath))
+
+	rec := adminRequest(s, http.MethodPost, "/pair/requests", "", `{"name":"accountant"}`)
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+	var created struct {
+		RequestID string `json:"request_id"`
+		Code      string `json:"code"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+
+	approve := "/admin/pairing/requests/" + created.Code + "/approve"
+	rec = adminRequest(s, http.MethodPost, approve, admin, `{"scope":"owner"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = adminRequest(s, http.MethodPost, approve, admin, `{"scope":"observer"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var device Device
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &device))
+	assert.Equal(t, ObserverRole, device.Scope)
+
+	rec = adminRequest(s, http.MethodGet, "/pair/requests/"+created.RequestID, "", "")
+	var approved struct {
+		Token string `json:"token"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &approved))
+	rec = adminRequest(s, http.MethodPost, "/webhook", approved.Token, `{"message":"hi"}`)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	cfg, err := config.LoadConfig(configPath)
+	require.NoError(t, err)
+	require.Len(t, cfg.Gateway.PairedDevices, 1)
+	assert.Equal(t, ObserverRole, cfg.Gateway.PairedDevices[0].Scope)
+}
@@ -0,0 +1,114 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// AdminRole is the JWT role allowed to use admin endpoints.
+const AdminRole = "admin"
+
+// RuntimeStats is the response of GET /debug/runtime.
+type RuntimeStats struct {
+	Uptime        string  `json:"uptime"`
+	GoVersion     string  `json:"go_version"`
+	NumCPU        int     `json:"num_cpu"`
+	GOMAXPROCS    int     `json:"gomaxprocs"`
+	Goroutines    int     `json:"goroutines"`
+	HeapAlloc     uint64  `json:"heap_alloc_bytes"`
+	HeapInuse     uint64  `json:"heap_inuse_bytes"`
+	HeapIdle      uint64  `json:"heap_idle_bytes"`
+	HeapReleased  uint64  `json:"heap_released_bytes"`
+	HeapObjects   uint64  `json:"heap_objects"`
+	StackInuse    uint64  `json:"stack_inuse_bytes"`
+	Sys           uint64  `json:"sys_bytes"`
+	TotalAlloc    uint64  `json:"total_alloc_bytes"`
+	NumGC         uint32  `json:"num_gc"`
+	LastGC        string  `json:"last_gc,omitempty"`
+	PauseTotal    string  `json:"gc_pause_total"`
+	GCCPUFraction float64 `json:"gc_cpu_fraction"`
+}
+
+// WithPprof exposes net/http/pprof under /debug/pprof/ and runtime stats at
+// /debug/runtime, both restricted to admin credentials.
+func WithPprof(enabled bool) ServerOption {
+	return func(s *Server) {
+		s.pprof = enabled
+	}
+}
+
+func (s *Server) registerPprof(mux *http.ServeMux) {
+	mux.HandleFunc("GET /debug/pprof/", s.requireAdmin(pprof.Index))
+	mux.HandleFunc("GET /debug/pprof/cmdline", s.requireAdmin(pprof.Cmdline))
+	mux.HandleFunc("GET /debug/pprof/profile", s.requireAdmin(pprof.Profile))
+	mux.HandleFunc("GET /debug/pprof/symbol", s.requireAdmin(pprof.Symbol))
+	mux.HandleFunc("POST /debug/pprof/symbol", s.requireAdmin(pprof.Symbol))
+	mux.HandleFunc("GET /debug/pprof/trace", s.requireAdmin(pprof.Trace))
+	mux.HandleFunc("GET /debug/runtime", s.requireAdmin(s.runtimeHandler))
+}
+
+// requireAdmin rejects requests without admin credentials.
+func (s *Server) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.isAdmin(r) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]any{"error": "unauthorized: admin credentials required"})
+			return
+		}
+		next(w, r)
+	}
+}
+
+// isAdmin checks for operator credentials: a paired pc_ token other than an
+// observer's, or a JWT with the admin role. Unlike isAuthorized it never allows anonymous access, even
+// when pairing is not required.
+func (s *Server) isAdmin(r *http.Request) bool {
+	token := s.extractRawToken(r)
+	if token == "" {
+		return false
+	}
+	if s.jwtSecret != "" && !strings.HasPrefix(token, "pc_") {
+		claims, err := s.validateJWT(token)
+		return err == nil && claims.Role == AdminRole
+	}
+
+	hash := hashToken(token)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.pairedTokens[hash] && s.devices[hash].Scope != ObserverRole
+}
+
+func (s *Server) runtimeHandler(w http.ResponseWriter, r *http.Request) {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	stats := RuntimeStats{
+		Uptime:        time.Since(s.startTime).String(),
+		GoVersion:     runtime.Version(),
+		NumCPU:        runtime.NumCPU(),
+		GOMAXPROCS:    runtime.GOMAXPROCS(0),
+		Goroutines:    runtime.NumGoroutine(),
+		HeapAlloc:     m.HeapAlloc,
+		HeapInuse:     m.HeapInuse,
+		HeapIdle:      m.HeapIdle,
+		HeapReleased:  m.HeapReleased,
+		HeapObjects:   m.HeapObjects,
+		StackInuse:    m.StackInuse,
+		Sys:           m.Sys,
+		TotalAlloc:    m.TotalAlloc,
+		NumGC:         m.NumGC,
+		PauseTotal:    time.Duration(m.PauseTotalNs).String(),
+		GCCPUFraction: m.GCCPUFraction,
+	}
+	if m.LastGC > 0 {
+		stats.LastGC = time.Unix(0, int64(m.LastGC)).UTC().Format(time.RFC3339)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
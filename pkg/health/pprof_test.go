@@ -0,0 +1,74 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPprofRequiresAdmin(t *testing.T) {
+	token, tokenHash := generateBearerToken()
+	s := NewServer("127.0.0.1", 0,
+		WithPprof(true),
+		WithPairing(false, []string{tokenHash}, ""),
+		WithJWTAuth("secret"))
+
+	sign := func(role string) string {
+		jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, LedgerForgeClaims{Sub: "u1", Role: role})
+		signed, err := jwtToken.SignedString([]byte("secret"))
+		require.NoError(t, err)
+		return signed
+	}
+
+	tests := []struct {
+		name   string
+		bearer string
+		want   int
+	}{
+		{"anonymous", "", http.StatusUnauthorized},
+		{"unknown token", "pc_unknown", http.StatusUnauthorized},
+		{"non-admin JWT", sign("user"), http.StatusUnauthorized},
+		{"admin JWT", sign(AdminRole), http.StatusOK},
+		{"paired token", token, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/debug/runtime", nil)
+			if tt.bearer != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.bearer)
+			}
+			rec := httptest.NewRecorder()
+			s.server.Handler.ServeHTTP(rec, req)
+			assert.Equal(t, tt.want, rec.Code)
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/debug/runtime", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	s.server.Handler.ServeHTTP(rec, req)
+	var stats RuntimeStats
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &stats))
+	assert.Positive(t, stats.Goroutines)
+	assert.Positive(t, stats.HeapAlloc)
+
+	req = httptest.NewRequest(http.MethodGet, "/debug/pprof/goroutine?debug=1", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec = httptest.NewRecorder()
+	s.server.Handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "goroutine profile")
+}
+
+func TestPprofDisabledByDefault(t *testing.T) {
+	s := NewServer("127.0.0.1", 0)
+	req := httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil)
+	rec := httptest.NewRecorder()
+	s.server.Handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
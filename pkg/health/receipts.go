@@ -0,0 +1,320 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/ledgerforge"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/receipts"
+)
+
+const (
+	// receiptTimeout bounds one receipt's OCR, categorization and upload.
+	receiptTimeout = 2 * time.Minute
+	// maxReceiptBatch is the most receipts one POST /receipts takes.
+	maxReceiptBatch = 20
+	// receiptWorkers is how many receipts of a batch are processed at once.
+	receiptWorkers = 4
+)
+
+// receiptTypes are the content types POST /receipts accepts, as detected
+// from the file's first bytes.
+var receiptTypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+}
+
+// WithReceipts enables POST /receipts, which processes receipts with p
+// instead of the agent, and the /receipts/review endpoints for the
+// receipts p parks for review.
+func WithReceipts(p *receipts.Pipeline) ServerOption {
+	return func(s *Server) {
+		s.receipts = p
+	}
+}
+
+// receiptHandler reads receipt images or PDFs into draft expenses for the
+// caller's business. The form has the receipts in "file" and optional
+// "date" (YYYY-MM-DD, used when a receipt's date can't be read) and
+// "business_id" fields; without business_id, the business the caller's
+// session is bound to is used.
+//
+// One file gets its result as the whole response. Several files, or one
+// with "batch" set to true, are processed independently, receiptWorkers at
+// a time, and the response lists each file's result.
+func (s *Server) receiptHandler(w http.ResponseWriter, r *http.Request) {
+	sessionKey, ctx, err := s.authenticateUser(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized: "+err.Error())
+		return
+	}
+	if s.denyObserver(w, r) {
+		return
+	}
+	if ledgerforge.Token(ctx) == "" {
+		writeError(w, http.StatusForbidden, "receipts need a signed-in user; use a JWT instead of an API token")
+		return
+	}
+
+	if s.maxUpload > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, s.maxUpload*maxReceiptBatch+maxFormField)
+	}
+	if err := r.ParseMultipartForm(maxFormField); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, errUploadTooLarge.Error())
+			return
+		}
+		writeError(w, http.StatusBadRequest, "failed to parse multipart form")
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	files := r.MultipartForm.File["file"]
+	if len(files) == 0 {
+		writeError(w, http.StatusBadRequest, "at least one file is required")
+		return
+	}
+	if len(files) > maxReceiptBatch {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("at most %d files can be sent at once", maxReceiptBatch))
+		return
+	}
+	batch := len(files) > 1
+	if v := r.FormValue("batch"); v != "" {
+		if batch, err = strconv.ParseBool(v); err != nil || (!batch && len(files) > 1) {
+			writeError(w, http.StatusBadRequest, "batch must be true or false, and true for several files")
+			return
+		}
+	}
+
+	dateHint := strings.TrimSpace(r.FormValue("date"))
+	if dateHint != "" {
+		if _, err := time.Parse(time.DateOnly, dateHint); err != nil {
+			writeError(w, http.StatusBadRequest, "date must be YYYY-MM-DD")
+			return
+		}
+	}
+	businessID := strings.TrimSpace(r.FormValue("business_id"))
+	if businessID == "" {
+		businessID = s.agentLoop.ActiveBusiness(sessionKey)
+	}
+	if businessID == "" {
+		writeError(w, http.StatusBadRequest, "business_id is required; no business is selected for this session")
+		return
+	}
+	ctx = context.WithValue(ctx, constants.ContextKeyBusinessID, businessID)
+
+	if !batch {
+		outcome := s.processReceipt(ctx, files[0], dateHint)
+		if outcome.Error != "" {
+			writeError(w, outcome.Status, outcome.Error)
+			return
+		}
+		writeJSON(w, outcome.Status, outcome.Result)
+		return
+	}
+
+	resp := receiptBatch{Results: make([]receiptOutcome, len(files))}
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, receiptWorkers)
+	for i, fh := range files {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			resp.Results[i] = s.processReceipt(ctx, fh, dateHint)
+		}()
+	}
+	wg.Wait()
+	for _, o := range resp.Results {
+		switch {
+		case o.Error != "":
+			resp.Failed++
+		case o.Result.Transaction != nil:
+			resp.Created++
+		case o.Result.ReviewID != "":
+			resp.Queued++
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// receiptBatch is the response to a batch upload.
+type receiptBatch struct {
+	Results []receiptOutcome `json:"results"` // in the order the files were sent
+	Created int              `json:"created"` // files a draft was created for
+	Queued  int              `json:"queued"`  // files waiting for review
+	Failed  int              `json:"failed"`  // files with an error
+}
+
+// receiptOutcome is what became of one uploaded receipt.
+type receiptOutcome struct {
+	Filename string           `json:"filename"`
+	Status   int              `json:"status"` // the HTTP status the file would get on its own
+	Result   *receipts.Result `json:"result,omitempty"`
+	Error    string           `json:"error,omitempty"`
+}
+
+// processReceipt checks and processes one uploaded receipt for the business
+// in ctx.
+func (s *Server) processReceipt(ctx context.Context, fh *multipart.FileHeader, dateHint string) receiptOutcome {
+	out := receiptOutcome{Filename: filepath.Base(fh.Filename)}
+	fail := func(status int, msg string) receiptOutcome {
+		out.Status, out.Error = status, msg
+		return out
+	}
+
+	if s.maxUpload > 0 && fh.Size > s.maxUpload {
+		return fail(http.StatusRequestEntityTooLarge, errUploadTooLarge.Error())
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return fail(http.StatusBadRequest, "failed to read file")
+	}
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return fail(http.StatusBadRequest, "failed to read file")
+	}
+	if !isReceiptFile(fh.Filename, data) {
+		return fail(http.StatusUnsupportedMediaType, "receipts must be JPEG, PNG, GIF, WebP, HEIC or PDF files")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, receiptTimeout)
+	defer cancel()
+	res, err := s.receipts.Process(ctx, receipts.Receipt{
+		Filename: out.Filename,
+		Data:     data,
+		DateHint: dateHint,
+	})
+	if err != nil {
+		logger.WarnCF("health", "Receipt processing failed", map[string]any{
+			"business_id": ledgerforge.BusinessID(ctx),
+			"filename":    out.Filename,
+			"error":       err.Error(),
+		})
+		return fail(http.StatusBadGateway, err.Error())
+	}
+
+	out.Status, out.Result = http.StatusOK, res
+	switch {
+	case res.Transaction != nil:
+		out.Status = http.StatusCreated
+	case res.ReviewID != "":
+		out.Status = http.StatusAccepted
+	}
+	return out
+}
+
+// reviewContext authenticates a request to the review queue and scopes it
+// to the business in the business_id query parameter, or else the one the
+// caller's session is bound to. It writes the error response and returns
+// false if the request can't go on.
+func (s *Server) reviewContext(w http.ResponseWriter, r *http.Request) (context.Context, bool) {
+	sessionKey, ctx, err := s.authenticateUser(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized: "+err.Error())
+		return nil, false
+	}
+	if ledgerforge.Token(ctx) == "" {
+		writeError(w, http.StatusForbidden, "receipts need a signed-in user; use a JWT instead of an API token")
+		return nil, false
+	}
+	businessID := strings.TrimSpace(r.URL.Query().Get("business_id"))
+	if businessID == "" {
+		businessID = s.agentLoop.ActiveBusiness(sessionKey)
+	}
+	if businessID == "" {
+		writeError(w, http.StatusBadRequest, "business_id is required; no business is selected for this session")
+		return nil, false
+	}
+	return context.WithValue(ctx, constants.ContextKeyBusinessID, businessID), true
+}
+
+// reviewListHandler lists the receipts waiting for review in a business.
+func (s *Server) reviewListHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, ok := s.reviewContext(w, r)
+	if !ok {
+		return
+	}
+	reviews, err := s.receipts.Reviews(ctx)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"reviews": reviews})
+}
+
+// reviewConfirmHandler creates the draft for a receipt under review, with
+// the corrections in the JSON body applied.
+func (s *Server) reviewConfirmHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, ok := s.reviewContext(w, r)
+	if !ok || s.denyObserver(w, r) {
+		return
+	}
+	var c receipts.Correction
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxFormField)).Decode(&c); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, receiptTimeout)
+	defer cancel()
+	res, err := s.receipts.Confirm(ctx, r.PathValue("id"), c)
+	switch {
+	case errors.Is(err, receipts.ErrReviewNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, receipts.ErrInvalidCorrection):
+		writeError(w, http.StatusBadRequest, err.Error())
+	case err != nil:
+		writeError(w, http.StatusBadGateway, err.Error())
+	default:
+		writeJSON(w, http.StatusCreated, res)
+	}
+}
+
+// reviewDiscardHandler drops a receipt under review without a draft.
+func (s *Server) reviewDiscardHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, ok := s.reviewContext(w, r)
+	if !ok || s.denyObserver(w, r) {
+		return
+	}
+	err := s.receipts.Discard(ctx, r.PathValue("id"))
+	switch {
+	case errors.Is(err, receipts.ErrReviewNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// isReceiptFile reports whether data is an image or PDF LedgerForge can
+// read. HEIC, which iPhones save photos as, can't be sniffed, so it is
+// recognized by its extension.
+func isReceiptFile(filename string, data []byte) bool {
+	contentType, _, _ := strings.Cut(http.DetectContentType(data), ";")
+	if receiptTypes[contentType] {
+		return true
+	}
+	ext := strings.ToLower(filepath.Ext(filename))
+	return contentType == "application/octet-stream" && (ext == ".heic" || ext == ".heif")
+}
@@ -0,0 +1,216 @@
+package health
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/ledgerforge"
+	"github.com/sipeed/picoclaw/pkg/receipts"
+)
+
+func TestReceiptsCreatesDraft(t *testing.T) {
+	lf := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/businesses/biz-1/receipts/extract-ocr":
+			io.WriteString(w, `{"success":true,"data":{"ocr_data":{"vendor":"Corner Cafe","amount":"12.50",`+
+				`"date":"2026-03-02"}}}`)
+		case "/api/v1/businesses/biz-1/transactions":
+			io.WriteString(w, `{"id":"tx-1","status":"draft"}`)
+		case "/api/v1/businesses/biz-1/transactions/suggest-category",
+			"/api/v1/businesses/biz-1/transactions/tx-1/receipts":
+			io.WriteString(w, `{}`)
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+	}))
+	defer lf.Close()
+
+	token, tokenHash := generateBearerToken()
+	s, _ := newUploadServer(t,
+		WithPairing(true, []string{tokenHash}, ""),
+		WithJWTAuth("secret"),
+		WithReceipts(receipts.New(ledgerforge.New(ledgerforge.Options{BaseURL: lf.URL}), nil)))
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, LedgerForgeClaims{Sub: "u1"}).
+		SignedString([]byte("secret"))
+	require.NoError(t, err)
+
+	png := "\x89PNG\r\n\x1a\n receipt"
+	post := func(bearer string, parts ...[2]string) *httptest.ResponseRecorder {
+		req := multipartRequest(t, parts...)
+		req.URL.Path = "/receipts"
+		req.Header.Set("Authorization", "Bearer "+bearer)
+		rec := httptest.NewRecorder()
+		s.receiptHandler(rec, req)
+		return rec
+	}
+
+	rec := post(token, [2]string{"business_id", "biz-1"}, [2]string{"file:r.png", png})
+	assert.Equal(t, http.StatusForbidden, rec.Code, "API tokens can't act for a LedgerForge user")
+
+	rec = post(signed, [2]string{"business_id", "biz-1"}, [2]string{"file:notes.txt", "hello"})
+	assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+
+	rec = post(signed, [2]string{"file:r.png", png})
+	assert.Equal(t, http.StatusBadRequest, rec.Code, "no business given or selected")
+
+	rec = post(signed, [2]string{"business_id", "biz-1"}, [2]string{"date", "2026-03-01"},
+		[2]string{"file:r.png", png})
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var res receipts.Result
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Equal(t, "Corner Cafe", res.Vendor)
+	assert.Equal(t, "12.50", res.Amount)
+	assert.Equal(t, "2026-03-02", res.Date, "the receipt's own date beats the hint")
+	require.NotNil(t, res.Transaction)
+	assert.Equal(t, "tx-1", res.Transaction.ID)
+}
+
+func TestReceiptsBatchReportsEachFile(t *testing.T) {
+	lf := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/businesses/biz-1/receipts/extract-ocr":
+			f, _, err := r.FormFile("file")
+			require.NoError(t, err)
+			data, _ := io.ReadAll(f)
+			if strings.Contains(string(data), "blurry") {
+				io.WriteString(w, `{"success":true,"data":{"ocr_data":{"vendor":"Blurry Shop"}}}`)
+				return
+			}
+			io.WriteString(w, `{"success":true,"data":{"ocr_data":{"vendor":"Corner Cafe","amount":"12.50",`+
+				`"date":"2026-03-02"}}}`)
+		case "/api/v1/businesses/biz-1/transactions":
+			io.WriteString(w, `{"id":"tx-1","status":"draft"}`)
+		default:
+			io.WriteString(w, `{}`)
+		}
+	}))
+	defer lf.Close()
+
+	s, _ := newUploadServer(t,
+		WithJWTAuth("secret"),
+		WithReceipts(receipts.New(ledgerforge.New(ledgerforge.Options{BaseURL: lf.URL}), nil)))
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, LedgerForgeClaims{Sub: "u1"}).
+		SignedString([]byte("secret"))
+	require.NoError(t, err)
+	post := func(parts ...[2]string) *httptest.ResponseRecorder {
+		req := multipartRequest(t, parts...)
+		req.URL.Path = "/receipts"
+		req.Header.Set("Authorization", "Bearer "+signed)
+		rec := httptest.NewRecorder()
+		s.receiptHandler(rec, req)
+		return rec
+	}
+
+	png := "\x89PNG\r\n\x1a\n receipt"
+	rec := post([2]string{"business_id", "biz-1"},
+		[2]string{"file:a.png", png},
+		[2]string{"file:notes.txt", "hello"},
+		[2]string{"file:b.png", png + " blurry"})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var batch receiptBatch
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &batch))
+	require.Len(t, batch.Results, 3)
+	assert.Equal(t, 1, batch.Created)
+	assert.Equal(t, 1, batch.Failed)
+
+	assert.Equal(t, "a.png", batch.Results[0].Filename)
+	assert.Equal(t, http.StatusCreated, batch.Results[0].Status)
+	require.NotNil(t, batch.Results[0].Result)
+	assert.Equal(t, "Corner Cafe", batch.Results[0].Result.Vendor)
+
+	assert.Equal(t, http.StatusUnsupportedMediaType, batch.Results[1].Status)
+	assert.Contains(t, batch.Results[1].Error, "JPEG")
+
+	assert.Equal(t, http.StatusOK, batch.Results[2].Status, "no total read, so no draft")
+	require.NotNil(t, batch.Results[2].Result)
+	assert.Nil(t, batch.Results[2].Result.Transaction)
+
+	rec = post([2]string{"business_id", "biz-1"}, [2]string{"batch", "true"}, [2]string{"file:a.png", png})
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &batch))
+	assert.Len(t, batch.Results, 1)
+
+	rec = post([2]string{"business_id", "biz-1"}, [2]string{"batch", "false"},
+		[2]string{"file:a.png", png}, [2]string{"file:b.png", png})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestReceiptReviewQueue(t *testing.T) {
+	var drafts int
+	lf := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/businesses/biz-1/receipts/extract-ocr":
+			io.WriteString(w, `{"success":true,"data":{"ocr_data":{"vendor":"Corner Cafe","amount":"12.50",`+
+				`"date":"2026-03-02","field_confidence":{"amount":0.3}}}}`)
+		case "/api/v1/businesses/biz-1/transactions":
+			drafts++
+			io.WriteString(w, `{"id":"tx-1","status":"draft"}`)
+		default:
+			io.WriteString(w, `{}`)
+		}
+	}))
+	defer lf.Close()
+
+	pipeline := receipts.New(ledgerforge.New(ledgerforge.Options{BaseURL: lf.URL}), nil)
+	pipeline.SetReview(receipts.NewReviewQueue(t.TempDir()), 0.6)
+	s, _ := newUploadServer(t, WithJWTAuth("secret"), WithReceipts(pipeline))
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, LedgerForgeClaims{Sub: "u1"}).
+		SignedString([]byte("secret"))
+	require.NoError(t, err)
+	call := func(handler http.HandlerFunc, method, target, id, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.SetPathValue("id", id)
+		req.Header.Set("Authorization", "Bearer "+signed)
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	req := multipartRequest(t, [2]string{"business_id", "biz-1"}, [2]string{"file:r.png", "\x89PNG\r\n\x1a\n receipt"})
+	req.URL.Path = "/receipts"
+	req.Header.Set("Authorization", "Bearer "+signed)
+	rec := httptest.NewRecorder()
+	s.receiptHandler(rec, req)
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+	var res receipts.Result
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	require.NotEmpty(t, res.ReviewID)
+	assert.Zero(t, drafts)
+
+	rec = call(s.reviewListHandler, http.MethodGet, "/receipts/review?business_id=biz-1", "", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var list struct {
+		Reviews []receipts.Review `json:"reviews"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	require.Len(t, list.Reviews, 1)
+	assert.Equal(t, []string{"amount"}, list.Reviews[0].Unsure)
+
+	rec = call(s.reviewConfirmHandler, http.MethodPost, "/receipts/review/x?business_id=biz-1", res.ReviewID,
+		`{"amount":"twelve"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = call(s.reviewConfirmHandler, http.MethodPost, "/receipts/review/x?business_id=biz-2", res.ReviewID,
+		`{"amount":"12.05"}`)
+	assert.Equal(t, http.StatusNotFound, rec.Code, "reviews stay with their business")
+
+	rec = call(s.reviewConfirmHandler, http.MethodPost, "/receipts/review/x?business_id=biz-1", res.ReviewID,
+		`{"amount":"12.05"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Equal(t, "12.05", res.Amount)
+	assert.Equal(t, 1, drafts)
+
+	rec = call(s.reviewDiscardHandler, http.MethodDelete, "/receipts/review/x?business_id=biz-1", res.ReviewID, "")
+	assert.Equal(t, http.StatusNotFound, rec.Code, "a confirmed receipt leaves the queue")
+	rec = call(s.reviewDiscardHandler, http.MethodDelete, "/receipts/review/x?business_id=biz-1", "..", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
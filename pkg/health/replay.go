@@ -0,0 +1,47 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/agent"
+)
+
+// replayTimeout bounds a replay, like a webhook request.
+const replayTimeout = 120 * time.Second
+
+// replayHandler runs a past request from the event log again and answers
+// with the comparison. The optional body {"live": true} runs tools and
+// continues the original session instead of a dry run.
+func (s *Server) replayHandler(w http.ResponseWriter, r *http.Request) {
+	if s.agentLoop == nil {
+		writeError(w, http.StatusNotImplemented, "replay is not available without the agent API")
+		return
+	}
+	var body struct {
+		Live bool `json:"live"`
+	}
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<10)).Decode(&body)
+	if err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, `expected {"live": true|false} or no body`)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), replayTimeout)
+	defer cancel()
+	result, err := s.agentLoop.Replay(ctx, r.PathValue("id"), body.Live)
+	switch {
+	case errors.Is(err, agent.ErrReplayNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, agent.ErrReplayNoContent):
+		writeError(w, http.StatusConflict, err.Error())
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	default:
+		writeJSON(w, http.StatusOK, result)
+	}
+}
@@ -0,0 +1,94 @@
+package health
+
+import (
+	"fmt"
+	"math"
+	"runtime/debug"
+
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// Resource check names.
+const (
+	CheckDiskSpace = "disk_space"
+	CheckMemory    = "memory"
+)
+
+// ResourceOptions sets the thresholds of the resource checks. A zero
+// threshold disables its check.
+type ResourceOptions struct {
+	DiskPath           string  // a path on the filesystem to watch, the workspace
+	MinDiskFreePercent float64 // of the filesystem holding DiskPath
+	MinDiskFreeMB      int64
+	MaxMemoryPercent   float64 // process RSS as a percent of the memory limit
+}
+
+// RegisterResourceChecks registers warning checks that mark the gateway
+// degraded while the device runs low on disk space or memory, before it
+// actually runs out. It returns the names of the checks registered, leaving
+// out those this platform can't measure.
+func (s *Server) RegisterResourceChecks(opts ResourceOptions) []string {
+	var names []string
+	if opts.DiskPath != "" && (opts.MinDiskFreePercent > 0 || opts.MinDiskFreeMB > 0) {
+		if _, _, err := utils.DiskSpace(opts.DiskPath); err == nil {
+			s.RegisterWarningCheck(CheckDiskSpace, func() (bool, string) {
+				return checkDiskSpace(opts.DiskPath, opts.MinDiskFreePercent, opts.MinDiskFreeMB)
+			})
+			names = append(names, CheckDiskSpace)
+		}
+	}
+	if opts.MaxMemoryPercent > 0 {
+		if _, err := processRSS(); err == nil && memoryLimit() > 0 {
+			s.RegisterWarningCheck(CheckMemory, func() (bool, string) {
+				return checkMemory(opts.MaxMemoryPercent)
+			})
+			names = append(names, CheckMemory)
+		}
+	}
+	return names
+}
+
+// checkDiskSpace fails when the filesystem holding path has less than
+// minPercent or minMB free, whichever is reached first.
+func checkDiskSpace(path string, minPercent float64, minMB int64) (bool, string) {
+	free, total, err := utils.DiskSpace(path)
+	if err != nil || total == 0 {
+		return true, "free space unavailable"
+	}
+	percent := 100 * float64(free) / float64(total)
+	freeMB := int64(free >> 20)
+	msg := fmt.Sprintf("%d MB (%.0f%%) free on %s", freeMB, percent, path)
+	if minPercent > 0 && percent < minPercent {
+		return false, fmt.Sprintf("%s, minimum %.0f%%", msg, minPercent)
+	}
+	if minMB > 0 && freeMB < minMB {
+		return false, fmt.Sprintf("%s, minimum %d MB", msg, minMB)
+	}
+	return true, msg
+}
+
+// checkMemory fails when the process's resident memory exceeds maxPercent of
+// its memory limit.
+func checkMemory(maxPercent float64) (bool, string) {
+	rss, err := processRSS()
+	limit := memoryLimit()
+	if err != nil || limit == 0 {
+		return true, "memory use unavailable"
+	}
+	percent := 100 * float64(rss) / float64(limit)
+	msg := fmt.Sprintf("%d MB resident of a %d MB limit (%.0f%%)", rss>>20, limit>>20, percent)
+	if percent >= maxPercent {
+		return false, fmt.Sprintf("%s, maximum %.0f%%", msg, maxPercent)
+	}
+	return true, msg
+}
+
+// memoryLimit returns the soft heap limit set by GOMEMLIMIT or
+// memory_limit_mb, or without one the memory the process may use at all: its
+// cgroup's limit or else the device's memory. 0 means unknown.
+func memoryLimit() uint64 {
+	if limit := debug.SetMemoryLimit(-1); limit > 0 && limit != math.MaxInt64 {
+		return uint64(limit)
+	}
+	return systemMemory()
+}
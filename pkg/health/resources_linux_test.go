@@ -0,0 +1,60 @@
+package health
+
+import (
+	"math"
+	"net/http"
+	"runtime/debug"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResourceChecks(t *testing.T) {
+	dir := t.TempDir()
+	s := NewServer("127.0.0.1", 0)
+	s.SetReady(true)
+
+	names := s.RegisterResourceChecks(ResourceOptions{DiskPath: dir, MinDiskFreeMB: 1, MaxMemoryPercent: 100})
+	assert.Equal(t, []string{CheckDiskSpace, CheckMemory}, names)
+	code, resp := getReady(t, s, "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ready", resp.Status)
+
+	assert.Empty(t, NewServer("127.0.0.1", 0).RegisterResourceChecks(ResourceOptions{DiskPath: dir}),
+		"zero thresholds disable the checks")
+}
+
+func TestCheckDiskSpace(t *testing.T) {
+	dir := t.TempDir()
+
+	ok, msg := checkDiskSpace(dir, 0, math.MaxInt64>>20)
+	assert.False(t, ok)
+	assert.Contains(t, msg, "minimum")
+
+	ok, _ = checkDiskSpace(dir, 100.1, 0)
+	assert.False(t, ok, "no filesystem is more than 100% free")
+
+	ok, msg = checkDiskSpace(dir, 0.0001, 1)
+	assert.True(t, ok)
+	assert.Contains(t, msg, "free on "+dir)
+}
+
+func TestCheckMemory(t *testing.T) {
+	rss, err := processRSS()
+	require.NoError(t, err)
+	assert.Positive(t, rss)
+
+	prev := debug.SetMemoryLimit(int64(rss)) // resident memory is now at the limit
+	defer debug.SetMemoryLimit(prev)
+	assert.Equal(t, rss, memoryLimit())
+
+	ok, msg := checkMemory(50)
+	assert.False(t, ok)
+	assert.Contains(t, msg, "maximum 50%")
+
+	debug.SetMemoryLimit(math.MaxInt64)
+	assert.Positive(t, memoryLimit(), "falls back to the cgroup or device memory")
+	ok, _ = checkMemory(100)
+	assert.True(t, ok)
+}
@@ -0,0 +1,17 @@
+//go:build !linux
+
+package health
+
+import "errors"
+
+var errResourcesUnsupported = errors.New("resource checks are only supported on Linux")
+
+// processRSS is a stub for non-Linux platforms.
+func processRSS() (uint64, error) {
+	return 0, errResourcesUnsupported
+}
+
+// systemMemory is a stub for non-Linux platforms.
+func systemMemory() uint64 {
+	return 0
+}
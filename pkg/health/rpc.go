@@ -0,0 +1,301 @@
+package health
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/agent"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/i18n"
+	"github.com/sipeed/picoclaw/pkg/tools"
+	"github.com/sipeed/picoclaw/pkg/wirelog"
+	"github.com/sipeed/picoclaw/pkg/workpool"
+)
+
+// maxRPCBody bounds a JSON-RPC request or batch.
+const maxRPCBody = 1 << 20
+
+// JSON-RPC error codes: the standard ones, then picoclaw's, from the range
+// the spec leaves to servers.
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcInternalError  = -32603
+
+	rpcUnauthorized  = -32001
+	rpcUnavailable   = -32002 // maintenance or a full queue; data has retry_after
+	rpcPairingFailed = -32003 // data has the HTTP status POST /pair would answer
+	rpcReadOnly      = -32004 // an observer token called a method that changes something
+)
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"` // absent for notifications
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+// rpcMethod runs a call. r carries the caller's credentials and headers.
+type rpcMethod func(s *Server, r *http.Request, params json.RawMessage) (any, *rpcError)
+
+var rpcMethods = map[string]rpcMethod{
+	"chat.send":     (*Server).rpcChatSend,
+	"session.reset": (*Server).rpcSessionReset,
+	"health.status": (*Server).rpcHealthStatus,
+	"pair.create":   (*Server).rpcPairCreate,
+}
+
+// rpcHandler serves JSON-RPC 2.0 calls, singly or in a batch. The calls of
+// a batch run concurrently; notifications run but get no response, and a
+// batch of only notifications is answered with 204.
+func (s *Server) rpcHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRPCBody+1))
+	if err != nil || len(body) > maxRPCBody {
+		writeJSON(w, http.StatusOK, rpcFailure(nil, rpcInvalidRequest, "request too large"))
+		return
+	}
+	body = bytes.TrimSpace(body)
+
+	if len(body) == 0 || body[0] != '[' {
+		resp := s.rpcCall(r, body)
+		if resp == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+
+	var batch []json.RawMessage
+	if err := json.Unmarshal(body, &batch); err != nil {
+		writeJSON(w, http.StatusOK, rpcFailure(nil, rpcParseError, "parse error"))
+		return
+	}
+	if len(batch) == 0 {
+		writeJSON(w, http.StatusOK, rpcFailure(nil, rpcInvalidRequest, "empty batch"))
+		return
+	}
+	responses := make([]*rpcResponse, len(batch))
+	var wg sync.WaitGroup
+	for i, call := range batch {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			responses[i] = s.rpcCall(r, call)
+		}()
+	}
+	wg.Wait()
+
+	out := make([]*rpcResponse, 0, len(responses))
+	for _, resp := range responses {
+		if resp != nil {
+			out = append(out, resp)
+		}
+	}
+	if len(out) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// rpcCall runs one call and returns its response, or nil for a
+// notification.
+func (s *Server) rpcCall(r *http.Request, raw json.RawMessage) *rpcResponse {
+	var req rpcRequest
+	if err := json.Unmarshal(raw, &req); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) || len(raw) == 0 {
+			return rpcFailure(nil, rpcParseError, "parse error")
+		}
+		return rpcFailure(nil, rpcInvalidRequest, "invalid request")
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		return rpcFailure(req.ID, rpcInvalidRequest, "invalid request")
+	}
+
+	var result any
+	var rpcErr *rpcError
+	if method, ok := rpcMethods[req.Method]; ok {
+		result, rpcErr = method(s, r, req.Params)
+	} else {
+		rpcErr = &rpcError{Code: rpcMethodNotFound, Message: "method not found: " + req.Method}
+	}
+	if req.ID == nil {
+		return nil
+	}
+	if rpcErr != nil {
+		return &rpcResponse{JSONRPC: "2.0", Error: rpcErr, ID: req.ID}
+	}
+	return &rpcResponse{JSONRPC: "2.0", Result: result, ID: req.ID}
+}
+
+func rpcFailure(id json.RawMessage, code int, msg string) *rpcResponse {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	return &rpcResponse{JSONRPC: "2.0", Error: &rpcError{Code: code, Message: msg}, ID: id}
+}
+
+// decodeParams reads by-name params into v. Absent params leave v as is.
+func decodeParams(params json.RawMessage, v any) *rpcError {
+	if len(params) == 0 || string(params) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return &rpcError{Code: rpcInvalidParams, Message: "invalid params: expected an object"}
+	}
+	return nil
+}
+
+// rpcUser authenticates a call like a webhook call and returns the
+// caller's session key, context and language. Observer tokens are refused,
+// since the methods that use it change the caller's session.
+func (s *Server) rpcUser(r *http.Request, businessID string) (string, context.Context, string, *rpcError) {
+	sessionKey, userCtx, err := s.authenticateUser(r)
+	if err != nil {
+		lang := s.language(r.Context(), r, "")
+		return "", nil, "", &rpcError{Code: rpcUnauthorized, Message: i18n.T(lang, "api.unauthorized", err)}
+	}
+	if s.isObserver(r) {
+		return "", nil, "", &rpcError{Code: rpcReadOnly, Message: i18n.T(s.language(userCtx, r, ""), "api.read_only")}
+	}
+	if businessID != "" {
+		userCtx = context.WithValue(userCtx, constants.ContextKeyBusinessID, businessID)
+	}
+	return sessionKey, userCtx, s.language(userCtx, r, businessID), nil
+}
+
+// rpcChatSend sends a message in the caller's session, like POST /webhook.
+// Params: message, business_id.
+func (s *Server) rpcChatSend(r *http.Request, params json.RawMessage) (any, *rpcError) {
+	var p struct {
+		Message    string `json:"message"`
+		BusinessID string `json:"business_id"`
+	}
+	if rpcErr := decodeParams(params, &p); rpcErr != nil {
+		return nil, rpcErr
+	}
+
+	requestID := r.Header.Get(constants.RequestIDHeader)
+	if requestID == "" || len(requestID) > 128 {
+		requestID = wirelog.NewID()
+	}
+	r = r.WithContext(context.WithValue(r.Context(), constants.ContextKeyRequestID, requestID))
+	sessionKey, userCtx, lang, rpcErr := s.rpcUser(r, p.BusinessID)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+	if strings.TrimSpace(p.Message) == "" {
+		return nil, &rpcError{Code: rpcInvalidParams, Message: i18n.T(lang, "api.message_required")}
+	}
+
+	ctx, cancel := context.WithTimeout(tools.WithQuickReplies(userCtx), 120*time.Second)
+	defer cancel()
+	response, err := s.agentLoop.ProcessDirectWithChannel(ctx, p.Message, sessionKey, "api", "mobile-client")
+	switch {
+	case errors.Is(err, agent.ErrMaintenance):
+		return nil, &rpcError{
+			Code:    rpcUnavailable,
+			Message: i18n.T(lang, "webhook.maintenance"),
+			Data:    map[string]any{"retry_after": 60},
+		}
+	case errors.Is(err, workpool.ErrQueueFull):
+		return nil, &rpcError{
+			Code:    rpcUnavailable,
+			Message: i18n.T(lang, "webhook.busy"),
+			Data:    map[string]any{"retry_after": int(s.workPool.RetryAfter().Seconds())},
+		}
+	case err != nil:
+		return nil, &rpcError{Code: rpcInternalError, Message: err.Error()}
+	}
+	result := map[string]any{"response": response, "model": s.model, "request_id": requestID}
+	if replies := tools.QuickReplies(ctx); len(replies) > 0 {
+		result["suggestions"] = replies
+	}
+	return result, nil
+}
+
+// rpcSessionReset clears the caller's conversation history.
+// Params: business_id, to reset the conversation about one business.
+func (s *Server) rpcSessionReset(r *http.Request, params json.RawMessage) (any, *rpcError) {
+	var p struct {
+		BusinessID string `json:"business_id"`
+	}
+	if rpcErr := decodeParams(params, &p); rpcErr != nil {
+		return nil, rpcErr
+	}
+	sessionKey, userCtx, _, rpcErr := s.rpcUser(r, p.BusinessID)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+	if _, err := s.agentLoop.ResetSession(userCtx, sessionKey, "api", "mobile-client"); err != nil {
+		return nil, &rpcError{Code: rpcInternalError, Message: err.Error()}
+	}
+	return map[string]any{"reset": true}, nil
+}
+
+// rpcHealthStatus reports readiness and checks, like GET /ready.
+func (s *Server) rpcHealthStatus(r *http.Request, _ json.RawMessage) (any, *rpcError) {
+	s.mu.RLock()
+	checks := make(map[string]Check, len(s.checks))
+	for name, check := range s.checks {
+		checks[name] = check
+	}
+	s.mu.RUnlock()
+
+	status := "not ready"
+	if s.Healthy() {
+		status = "ready"
+	}
+	return StatusResponse{
+		Status: status,
+		Uptime: time.Since(s.startTime).String(),
+		Paired: s.isAuthorized(r) || s.HasPairedClients(),
+		Checks: checks,
+	}, nil
+}
+
+// rpcPairCreate redeems the pairing code, like POST /pair.
+// Params: code, device_name.
+func (s *Server) rpcPairCreate(r *http.Request, params json.RawMessage) (any, *rpcError) {
+	var p struct {
+		Code       string `json:"code"`
+		DeviceName string `json:"device_name"`
+	}
+	if rpcErr := decodeParams(params, &p); rpcErr != nil {
+		return nil, rpcErr
+	}
+	lang := s.language(r.Context(), r, "")
+	token, status, errKey := s.pair(p.Code, p.DeviceName)
+	if errKey != "" {
+		return nil, &rpcError{
+			Code:    rpcPairingFailed,
+			Message: i18n.T(lang, errKey),
+			Data:    map[string]any{"status": status},
+		}
+	}
+	return map[string]any{"paired": true, "token": token}, nil
+}
@@ -0,0 +1,103 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func rpcPost(t *testing.T, s *Server, token, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	s.server.Handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRPC(t *testing.T) {
+	s, _ := newUploadServer(t, WithPairing(true, nil, ""), WithModel("test-model"))
+	s.SetReady(true)
+
+	// Pairing hands out a token, once.
+	rec := rpcPost(t, s, "", `{"jsonrpc": "2.0", "method": "pair.create", "params": {"code": "nope"}, "id": 1}`)
+	assert.JSONEq(t, `{"jsonrpc": "2.0", "id": 1,
+		"error": {"code": -32003, "message": "invalid pairing code", "data": {"status": 403}}}`, rec.Body.String())
+
+	body, _ := json.Marshal(map[string]any{
+		"jsonrpc": "2.0", "method": "pair.create", "id": "p",
+		"params": map[string]any{"code": s.GetPairingCode(), "device_name": "Kiosk"},
+	})
+	rec = rpcPost(t, s, "", string(body))
+	var paired struct {
+		Result struct {
+			Token string `json:"token"`
+		} `json:"result"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &paired))
+	token := paired.Result.Token
+	require.True(t, strings.HasPrefix(token, "pc_"), rec.Body.String())
+
+	rec = rpcPost(t, s, "", `{"jsonrpc": "2.0", "method": "chat.send", "params": {"message": "hi"}, "id": 2}`)
+	assert.Contains(t, rec.Body.String(), `"code":-32001`)
+
+	// A batch answers its calls in order and skips notifications.
+	rec = rpcPost(t, s, token, `[
+		{"jsonrpc": "2.0", "method": "chat.send", "params": {"message": "hello"}, "id": 1},
+		{"jsonrpc": "2.0", "method": "health.status", "id": 2},
+		{"jsonrpc": "2.0", "method": "session.reset"},
+		{"jsonrpc": "2.0", "method": "nope", "id": 3},
+		{"jsonrpc": "2.0", "method": "chat.send", "params": ["hello"], "id": 4},
+		{"method": "health.status", "id": 5},
+		42
+	]`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var batch []map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &batch), rec.Body.String())
+	require.Len(t, batch, 6)
+	assert.Equal(t, float64(1), batch[0]["id"])
+	result := batch[0]["result"].(map[string]any)
+	assert.Equal(t, "done", result["response"])
+	assert.Equal(t, "test-model", result["model"])
+	assert.NotEmpty(t, result["request_id"])
+	status := batch[1]["result"].(map[string]any)
+	assert.Equal(t, "ready", status["status"])
+	assert.Equal(t, true, status["paired"])
+	for i, code := range map[int]float64{2: -32601, 3: -32602, 4: -32600, 5: -32600} {
+		assert.Equal(t, code, batch[i]["error"].(map[string]any)["code"], i)
+	}
+	assert.Nil(t, batch[5]["id"])
+
+	rec = rpcPost(t, s, token, `{"jsonrpc": "2.0", "method": "session.reset"}`)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	rec = rpcPost(t, s, "", `{"jsonrpc": "2.0", "method"`)
+	assert.JSONEq(t, `{"jsonrpc": "2.0", "id": null, "error": {"code": -32700, "message": "parse error"}}`,
+		rec.Body.String())
+	rec = rpcPost(t, s, "", `[]`)
+	assert.Contains(t, rec.Body.String(), `"code":-32600`)
+}
+
+func TestRPCSessionReset(t *testing.T) {
+	s, _ := newUploadServer(t)
+
+	rec := rpcPost(t, s, "", `{"jsonrpc": "2.0", "method": "chat.send", "params": {"message": "hello"}, "id": 1}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	sessions, err := s.agentLoop.Sessions("")
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, 2, sessions[0].Messages)
+
+	rec = rpcPost(t, s, "", `{"jsonrpc": "2.0", "method": "session.reset", "id": 2}`)
+	assert.JSONEq(t, `{"jsonrpc": "2.0", "id": 2, "result": {"reset": true}}`, rec.Body.String())
+	history, err := s.agentLoop.SessionHistory("", sessions[0].Key)
+	require.NoError(t, err)
+	assert.Empty(t, history)
+}
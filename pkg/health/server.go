@@ -1,24 +1,47 @@
 package health
 
 import (
+	"bytes"
 	"context"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math/big"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/sipeed/picoclaw/pkg/agent"
+	"github.com/sipeed/picoclaw/pkg/alerts"
+	"github.com/sipeed/picoclaw/pkg/artifact"
+	"github.com/sipeed/picoclaw/pkg/audit"
+	"github.com/sipeed/picoclaw/pkg/blob"
+	"github.com/sipeed/picoclaw/pkg/budget"
 	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/constants"
-	"github.com/sipeed/picoclaw/pkg/utils"
+	"github.com/sipeed/picoclaw/pkg/crash"
+	"github.com/sipeed/picoclaw/pkg/documents"
+	"github.com/sipeed/picoclaw/pkg/eventlog"
+	"github.com/sipeed/picoclaw/pkg/graphql"
+	"github.com/sipeed/picoclaw/pkg/i18n"
+	"github.com/sipeed/picoclaw/pkg/locale"
+	"github.com/sipeed/picoclaw/pkg/mcp"
+	"github.com/sipeed/picoclaw/pkg/media"
+	"github.com/sipeed/picoclaw/pkg/receipts"
+	"github.com/sipeed/picoclaw/pkg/telemetry"
+	"github.com/sipeed/picoclaw/pkg/tools"
+	"github.com/sipeed/picoclaw/pkg/update"
+	"github.com/sipeed/picoclaw/pkg/usage"
+	"github.com/sipeed/picoclaw/pkg/wirelog"
+	"github.com/sipeed/picoclaw/pkg/workflow"
+	"github.com/sipeed/picoclaw/pkg/workpool"
 )
 
 // LedgerForgeClaims represents the JWT claims from LedgerForge auth tokens.
@@ -35,24 +58,61 @@ type Server struct {
 	mu        sync.RWMutex
 	ready     bool
 	checks    map[string]Check
+	checkFns  map[string]func() (bool, string)
+	warnOnly  map[string]bool // checks that degrade rather than fail readiness
+	history   map[string][]CheckResult
 	startTime time.Time
 
 	// API layer fields
 	agentLoop      *agent.AgentLoop
 	requirePairing bool
 	pairedTokens   map[string]bool // token hash -> true
+	devices        map[string]config.PairedDevice
+	pairRequests   map[string]*pairRequest // by code
+	guestLinks     map[string]*GuestLink   // by token hash
+	configMu       sync.Mutex              // serializes config file updates
 	pairingCode    string
 	pairingUsed    bool
+	externalAddr   string // host:port mapped on the router, if any
 	configPath     string
 	model          string
 	jwtSecret      string
+	wireLog        *wirelog.Log
+	pprof          bool
+	blobStore      blob.Store
+	media          *media.Library
+	artifacts      *artifact.Registry
+	events         *eventlog.Log
+	workPool       *workpool.Pool
+	presignExpiry  time.Duration
+	maxUpload      int64
+	control        *http.Server // unix socket server started by ServeControl
+	listenerCfgs   []config.ListenerConfig
+	listeners      []*http.Server // started by ServeListeners
+	version        string
+	releases       *update.ReleaseChecker
+	shutdown       func(restart bool)
+	dashboard      bool
+	usage          *usage.Tracker
+	receipts       *receipts.Pipeline
+	documents      *documents.Store
+	workflows      *workflow.Engine
+	locales        *locale.Resolver
+	graphQL        bool
+	graphqlSchema  *graphql.Schema
+	mcp            *mcp.Server
+
+	streamsDone chan struct{} // closed by Stop to end log streams
+	stopOnce    sync.Once
 }
 
 type Check struct {
-	Name      string    `json:"name"`
-	Status    string    `json:"status"`
-	Message   string    `json:"message,omitempty"`
-	Timestamp time.Time `json:"timestamp"`
+	Name      string        `json:"name"`
+	Status    string        `json:"status"`
+	Message   string        `json:"message,omitempty"`
+	Timestamp time.Time     `json:"timestamp"`
+	Flapping  bool          `json:"flapping,omitempty"`
+	History   []CheckResult `json:"history,omitempty"` // only with /ready?verbose=1
 }
 
 type StatusResponse struct {
@@ -65,12 +125,20 @@ type StatusResponse struct {
 type WebhookRequest struct {
 	Message    string `json:"message"`
 	BusinessID string `json:"business_id,omitempty"`
+	Debug      *bool  `json:"debug,omitempty"` // turns session debug mode on or off
 }
 
 type WebhookResponse struct {
-	Response *string `json:"response"`
-	Model    *string `json:"model"`
-	Error    *string `json:"error"`
+	Response *string      `json:"response"`
+	Model    *string      `json:"model"`
+	Error    *string      `json:"error"`
+	Files    []StoredFile `json:"files,omitempty"` // uploads copied to the blob store
+	// Approvals are the tool calls of the session waiting for the user to
+	// send "/approve <id>" or "/reject <id>".
+	Approvals []agent.PendingApproval `json:"approvals,omitempty"`
+	// Suggestions are replies the agent offers; a client can show them as
+	// buttons that send the reply as the next message.
+	Suggestions []string `json:"suggestions,omitempty"`
 }
 
 // ServerOption configures the health server.
@@ -94,6 +162,14 @@ func WithPairing(require bool, tokenHashes []string, configPath string) ServerOp
 	}
 }
 
+// WithPairingCode makes code the first pairing code instead of a random one,
+// such as the code the setup wizard showed.
+func WithPairingCode(code string) ServerOption {
+	return func(s *Server) {
+		s.pairingCode = code
+	}
+}
+
 // WithModel sets the model name returned in webhook responses.
 func WithModel(model string) ServerOption {
 	return func(s *Server) {
@@ -108,12 +184,35 @@ func WithJWTAuth(secret string) ServerOption {
 	}
 }
 
+// WithWireLog records webhook requests and responses to the wire log.
+func WithWireLog(l *wirelog.Log) ServerOption {
+	return func(s *Server) {
+		s.wireLog = l
+	}
+}
+
+// WithWorkPool answers webhook calls rejected by the agent's work pool with
+// 429 and a Retry-After estimated from p's queue latency.
+func WithWorkPool(p *workpool.Pool) ServerOption {
+	return func(s *Server) {
+		s.workPool = p
+	}
+}
+
 func NewServer(host string, port int, opts ...ServerOption) *Server {
 	s := &Server{
 		ready:        false,
 		checks:       make(map[string]Check),
+		checkFns:     make(map[string]func() (bool, string)),
+		warnOnly:     make(map[string]bool),
+		history:      make(map[string][]CheckResult),
 		startTime:    time.Now(),
 		pairedTokens: make(map[string]bool),
+		devices:      make(map[string]config.PairedDevice),
+		pairRequests: make(map[string]*pairRequest),
+		guestLinks:   make(map[string]*GuestLink),
+		streamsDone:  make(chan struct{}),
+		maxUpload:    defaultMaxUpload,
 	}
 
 	for _, opt := range opts {
@@ -121,7 +220,7 @@ func NewServer(host string, port int, opts ...ServerOption) *Server {
 	}
 
 	// Generate pairing code if agent loop is enabled
-	if s.agentLoop != nil {
+	if s.agentLoop != nil && s.pairingCode == "" {
 		s.pairingCode = generatePairingCode()
 	}
 
@@ -130,19 +229,78 @@ func NewServer(host string, port int, opts ...ServerOption) *Server {
 	mux.HandleFunc("/ready", s.readyHandler)
 
 	if s.agentLoop != nil {
-		mux.HandleFunc("POST /webhook", s.webhookHandler)
-		mux.HandleFunc("POST /pair", s.pairHandler)
+		webhook := s.webhookHandler
+		if s.wireLog != nil {
+			webhook = s.wireLog.Handler("webhook", webhook)
+		}
+		mux.HandleFunc("POST /webhook", traced("POST /webhook", webhook))
+		rpc := s.rpcHandler
+		if s.wireLog != nil {
+			rpc = s.wireLog.Handler("rpc", rpc)
+		}
+		mux.HandleFunc("POST /rpc", traced("POST /rpc", rpc))
+		mux.HandleFunc("POST /federation/delegate", traced("POST /federation/delegate", s.federationHandler))
+		mux.HandleFunc("POST /pair", traced("POST /pair", s.pairHandler))
+		mux.HandleFunc("GET /business", traced("GET /business", s.businessHandler))
+		mux.HandleFunc("PUT /business", traced("PUT /business", s.switchBusinessHandler))
+		mux.HandleFunc("GET /audit/skills", traced("GET /audit/skills", s.skillAuditHandler))
+		mux.HandleFunc("GET /history/search", traced("GET /history/search", s.historySearchHandler))
+		mux.HandleFunc("GET /debug/sessions", traced("GET /debug/sessions", s.debugSessionsHandler))
+		mux.HandleFunc("GET /debug/sessions/{key}", traced("GET /debug/sessions/{key}", s.debugExportHandler))
+		if s.blobStore != nil {
+			mux.HandleFunc("GET /files/{key...}", traced("GET /files/{key...}", s.fileDownloadHandler))
+		}
+		if s.artifacts != nil {
+			mux.HandleFunc("GET /artifacts", traced("GET /artifacts", s.artifactListHandler))
+			mux.HandleFunc("GET /artifacts/{id}", traced("GET /artifacts/{id}", s.artifactDownloadHandler))
+		}
+		if s.receipts != nil {
+			mux.HandleFunc("POST /receipts", traced("POST /receipts", s.receiptHandler))
+			mux.HandleFunc("GET /receipts/review", traced("GET /receipts/review", s.reviewListHandler))
+			mux.HandleFunc("POST /receipts/review/{id}",
+				traced("POST /receipts/review/{id}", s.reviewConfirmHandler))
+			mux.HandleFunc("DELETE /receipts/review/{id}",
+				traced("DELETE /receipts/review/{id}", s.reviewDiscardHandler))
+		}
+		if s.documents != nil {
+			mux.HandleFunc("POST /documents", traced("POST /documents", s.documentsHandler))
+			mux.HandleFunc("GET /documents", traced("GET /documents", s.documentListHandler))
+			mux.HandleFunc("DELETE /documents/{name...}",
+				traced("DELETE /documents/{name...}", s.documentDeleteHandler))
+		}
+		if s.events != nil {
+			mux.HandleFunc("GET /events", traced("GET /events", s.eventsHandler))
+			mux.HandleFunc("GET /export/transactions", traced("GET /export/transactions", s.exportHandler))
+		}
+		if s.dashboard {
+			s.registerDashboard(mux)
+		}
+		if s.graphQL {
+			s.registerGraphQL(mux)
+		}
+		if s.mcp != nil {
+			s.registerMCP(mux)
+		}
+		s.registerAdmin(mux)
+	}
+
+	mux.HandleFunc("GET /admin/logs/stream", traced("GET /admin/logs/stream", s.logStreamHandler))
+	mux.HandleFunc("GET /version", traced("GET /version", s.requireAdmin(s.versionHandler)))
+
+	if s.pprof {
+		s.registerPprof(mux)
 	}
 
 	writeTimeout := 5 * time.Second
-	if s.agentLoop != nil {
+	if s.agentLoop != nil || s.pprof {
+		// Long enough for agent replies and 30s CPU profiles.
 		writeTimeout = 150 * time.Second
 	}
 
 	addr := fmt.Sprintf("%s:%d", host, port)
 	s.server = &http.Server{
 		Addr:         addr,
-		Handler:      mux,
+		Handler:      recoverPanics(mux),
 		ReadTimeout:  5 * time.Second,
 		WriteTimeout: writeTimeout,
 	}
@@ -150,6 +308,100 @@ func NewServer(host string, port int, opts ...ServerOption) *Server {
 	return s
 }
 
+// statusRecorder captures the response status for telemetry.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// recoverPanics turns a panicking handler into a crash report and a 500 JSON
+// response instead of a dropped connection.
+func recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+			id := crash.Report("health", recovered, map[string]any{
+				"method": r.Method,
+				"path":   r.URL.Path,
+			})
+			body := map[string]any{"error": "internal server error"}
+			if id != "" {
+				body["crash_id"] = id
+			}
+			// If the handler already started the response this cannot change
+			// the status, but the connection is still closed cleanly.
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(body)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// traced wraps an API handler with a server span and request metrics, and
+// passes the client named in X-Client on to the agent.
+func traced(route string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		attrs := []telemetry.Attr{telemetry.String("http.route", route)}
+		client := clientTag(r.Header.Get(constants.ClientHeader))
+		if client != "" {
+			attrs = append(attrs, telemetry.String("client", client))
+		}
+		ctx, span := telemetry.StartSpan(r.Context(), route, telemetry.SpanKindServer, attrs...)
+		if client != "" {
+			ctx = context.WithValue(ctx, constants.ContextKeyClient, client)
+		}
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+
+		next(rec, r.WithContext(ctx))
+
+		status := telemetry.Int("http.status_code", rec.status)
+		span.SetAttributes(status)
+		if rec.status >= http.StatusInternalServerError {
+			span.RecordError(fmt.Errorf("%s", http.StatusText(rec.status)))
+		}
+		span.End()
+		telemetry.AddCounter("picoclaw.http.requests", 1, append(attrs, status)...)
+		telemetry.RecordDuration("picoclaw.http.duration", time.Since(start), telemetry.String("http.route", route))
+	}
+}
+
+// maxClientTag bounds the X-Client value kept with a request, since every
+// distinct value becomes its own metric series.
+const maxClientTag = 64
+
+// clientTag cleans up an X-Client value: control characters are dropped and
+// it is cut to maxClientTag bytes.
+func clientTag(header string) string {
+	tag := strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(header))
+	if len(tag) > maxClientTag {
+		tag = strings.ToValidUTF8(tag[:maxClientTag], "")
+	}
+	return strings.TrimSpace(tag)
+}
+
 // GetPairingCode returns the one-time pairing code.
 func (s *Server) GetPairingCode() string {
 	s.mu.RLock()
@@ -160,6 +412,12 @@ func (s *Server) GetPairingCode() string {
 	return s.pairingCode
 }
 
+// Handler returns the server's routes, for serving them on a listener other
+// than the configured address.
+func (s *Server) Handler() http.Handler {
+	return s.server.Handler
+}
+
 func (s *Server) Start() error {
 	s.mu.Lock()
 	s.ready = true
@@ -181,6 +439,8 @@ func (s *Server) StartContext(ctx context.Context) error {
 	case err := <-errCh:
 		return err
 	case <-ctx.Done():
+		s.stopOnce.Do(func() { close(s.streamsDone) })
+		s.stopListeners(context.Background())
 		return s.server.Shutdown(context.Background())
 	}
 }
@@ -188,7 +448,13 @@ func (s *Server) StartContext(ctx context.Context) error {
 func (s *Server) Stop(ctx context.Context) error {
 	s.mu.Lock()
 	s.ready = false
+	control := s.control
 	s.mu.Unlock()
+	s.stopOnce.Do(func() { close(s.streamsDone) })
+	if control != nil {
+		control.Shutdown(ctx)
+	}
+	s.stopListeners(ctx)
 	return s.server.Shutdown(ctx)
 }
 
@@ -198,17 +464,22 @@ func (s *Server) SetReady(ready bool) {
 	s.mu.Unlock()
 }
 
+// SetExternalAddress sets the host:port the router forwards to the gateway,
+// reported by the control socket, or clears it when the mapping is lost.
+func (s *Server) SetExternalAddress(addr string) {
+	s.mu.Lock()
+	s.externalAddr = addr
+	s.mu.Unlock()
+}
+
+// RegisterCheck adds a readiness check and evaluates it once. Use RunChecks
+// to re-evaluate registered checks periodically.
 func (s *Server) RegisterCheck(name string, checkFn func() (bool, string)) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	s.checkFns[name] = checkFn
+	s.mu.Unlock()
 
-	status, msg := checkFn()
-	s.checks[name] = Check{
-		Name:      name,
-		Status:    statusString(status),
-		Message:   msg,
-		Timestamp: time.Now(),
-	}
+	s.evaluateCheck(name, checkFn)
 }
 
 func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) {
@@ -237,10 +508,15 @@ func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) {
 func (s *Server) readyHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
+	verbose := r.URL.Query().Get("verbose") == "1"
+
 	s.mu.RLock()
 	ready := s.ready
 	checks := make(map[string]Check)
 	for k, v := range s.checks {
+		if verbose {
+			v.History = append([]CheckResult(nil), s.history[k]...)
+		}
 		checks[k] = v
 	}
 	s.mu.RUnlock()
@@ -254,6 +530,7 @@ func (s *Server) readyHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	status := "ready"
 	for _, check := range checks {
 		if check.Status == "fail" {
 			w.WriteHeader(http.StatusServiceUnavailable)
@@ -263,101 +540,137 @@ func (s *Server) readyHandler(w http.ResponseWriter, r *http.Request) {
 			})
 			return
 		}
+		if check.Status == statusWarn {
+			status = "degraded"
+		}
 	}
 
 	w.WriteHeader(http.StatusOK)
 	uptime := time.Since(s.startTime)
 	json.NewEncoder(w).Encode(StatusResponse{
-		Status: "ready",
+		Status: status,
 		Uptime: uptime.String(),
 		Checks: checks,
 	})
 }
 
-func (s *Server) webhookHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
-	// Try JWT auth first if configured, fall back to pc_ token auth
-	var sessionKey string
-	var userCtx context.Context
+// authenticateUser checks a user request's credentials and returns the
+// caller's session key and a context carrying their identity. It tries JWT
+// auth first if configured, and falls back to pc_ token auth.
+func (s *Server) authenticateUser(r *http.Request) (string, context.Context, error) {
 	rawToken := s.extractRawToken(r)
-
 	if s.jwtSecret != "" && rawToken != "" && !strings.HasPrefix(rawToken, "pc_") {
 		claims, err := s.validateJWT(rawToken)
 		if err != nil {
-			w.WriteHeader(http.StatusUnauthorized)
-			errMsg := "unauthorized: " + err.Error()
-			json.NewEncoder(w).Encode(WebhookResponse{Error: &errMsg})
-			return
+			return "", nil, err
 		}
-		sessionKey = "user:" + claims.Sub
 		// Store JWT and user context for skill script passthrough
-		userCtx = context.WithValue(r.Context(), constants.ContextKeyJWTToken, rawToken)
-		userCtx = context.WithValue(userCtx, constants.ContextKeyUserID, claims.Sub)
-	} else {
-		// Legacy pc_ token auth
-		if !s.isAuthorized(r) {
-			w.WriteHeader(http.StatusUnauthorized)
-			errMsg := "unauthorized: invalid or missing bearer token"
-			json.NewEncoder(w).Encode(WebhookResponse{Error: &errMsg})
-			return
-		}
-		tokenHash := s.extractTokenHash(r)
-		sessionKey = "api:" + tokenHash[:8]
-		userCtx = r.Context()
+		ctx := context.WithValue(r.Context(), constants.ContextKeyJWTToken, rawToken)
+		ctx = context.WithValue(ctx, constants.ContextKeyUserID, claims.Sub)
+		return "user:" + claims.Sub, ctx, nil
+	}
+
+	// Legacy pc_ token auth
+	if !s.isAuthorized(r) {
+		return "", nil, errors.New("invalid or missing bearer token")
+	}
+	return "api:" + s.extractTokenHash(r)[:8], r.Context(), nil
+}
+
+func (s *Server) webhookHandler(w http.ResponseWriter, r *http.Request) {
+	r = startWebhook(w, r)
+	sessionKey, userCtx, err := s.authenticateUser(r)
+	if err != nil {
+		lang := s.language(r.Context(), r, "")
+		writeWebhookError(w, http.StatusUnauthorized, i18n.T(lang, "api.unauthorized", err))
+		return
+	}
+	lang := s.language(userCtx, r, "")
+	if s.isObserver(r) {
+		writeWebhookError(w, http.StatusForbidden, i18n.T(lang, "api.read_only"))
+		return
 	}
+	s.serveWebhook(w, r, sessionKey, userCtx, lang, nil)
+}
 
+// startWebhook sets up the response to a webhook call and reuses the
+// caller's request ID, so the request can be traced across the client, the
+// agent, skills and providers.
+func startWebhook(w http.ResponseWriter, r *http.Request) *http.Request {
+	w.Header().Set("Content-Type", "application/json")
+	requestID := r.Header.Get(constants.RequestIDHeader)
+	if requestID == "" || len(requestID) > 128 {
+		requestID = wirelog.NewID()
+	}
+	w.Header().Set(constants.RequestIDHeader, requestID)
+	return r.WithContext(context.WithValue(r.Context(), constants.ContextKeyRequestID, requestID))
+}
+
+// serveWebhook runs an authenticated webhook call in sessionKey's session.
+// A guest link, if given, fixes the business and may limit the call to
+// uploads.
+func (s *Server) serveWebhook(
+	w http.ResponseWriter,
+	r *http.Request,
+	sessionKey string,
+	userCtx context.Context,
+	lang string,
+	guest *GuestLink,
+) {
 	var message string
 	var businessID string
+	var debug *bool
 	var mediaPaths []string
+	var storedFiles []StoredFile
 
 	contentType := r.Header.Get("Content-Type")
 	if strings.HasPrefix(contentType, "multipart/form-data") {
-		// Multipart form: message + optional files (max 20MB)
-		if err := r.ParseMultipartForm(20 << 20); err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			errMsg := "failed to parse multipart form"
-			json.NewEncoder(w).Encode(WebhookResponse{Error: &errMsg})
+		// Multipart form: message + optional files, saved to workspace/media/
+		// as they arrive so the agent's read_file tool can access them
+		form, status, err := s.readMultipart(r, lang)
+		if err != nil {
+			writeWebhookError(w, status, err.Error())
 			return
 		}
-		message = r.FormValue("message")
-		businessID = r.FormValue("business_id")
-
-		// Save uploaded files to workspace/media/ so the agent's read_file tool can access them
-		workspace := s.agentLoop.DefaultWorkspace()
-
-		if r.MultipartForm != nil && r.MultipartForm.File != nil {
-			for _, fhs := range r.MultipartForm.File {
-				for _, fh := range fhs {
-					file, err := fh.Open()
-					if err != nil {
-						continue
-					}
-					localPath := utils.SaveUploadedFile(file, fh.Filename, workspace)
-					file.Close()
-					if localPath != "" {
-						mediaPaths = append(mediaPaths, localPath)
-					}
-				}
+		message = form.message
+		businessID = form.businessID
+		mediaPaths = form.mediaPaths
+		storedFiles = form.storedFiles
+		if form.debug != "" {
+			on, err := strconv.ParseBool(form.debug)
+			if err != nil {
+				writeWebhookError(w, http.StatusBadRequest, i18n.T(lang, "webhook.invalid_debug"))
+				return
 			}
+			debug = &on
 		}
 	} else {
 		// JSON body (existing path)
 		var req WebhookRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			errMsg := "invalid request body"
-			json.NewEncoder(w).Encode(WebhookResponse{Error: &errMsg})
+			writeWebhookError(w, http.StatusBadRequest, i18n.T(lang, "api.invalid_body"))
 			return
 		}
 		message = req.Message
 		businessID = req.BusinessID
+		debug = req.Debug
+	}
+	if guest != nil {
+		businessID, debug = guest.BusinessID, nil
+		if guest.Scope == GuestScopeUpload {
+			if len(mediaPaths) == 0 {
+				writeWebhookError(w, http.StatusBadRequest, i18n.T(lang, "guest.files_only"))
+				return
+			}
+			message = ""
+		}
+	}
+	if businessID != "" {
+		lang = s.language(userCtx, r, businessID)
 	}
 
 	if strings.TrimSpace(message) == "" && len(mediaPaths) == 0 {
-		w.WriteHeader(http.StatusBadRequest)
-		errMsg := "message or file is required"
-		json.NewEncoder(w).Encode(WebhookResponse{Error: &errMsg})
+		writeWebhookError(w, http.StatusBadRequest, i18n.T(lang, "webhook.message_required"))
 		return
 	}
 
@@ -371,28 +684,53 @@ func (s *Server) webhookHandler(w http.ResponseWriter, r *http.Request) {
 	if businessID != "" {
 		userCtx = context.WithValue(userCtx, constants.ContextKeyBusinessID, businessID)
 	}
+	if debug != nil {
+		userCtx = context.WithValue(userCtx, constants.ContextKeyDebug, *debug)
+	}
 
-	ctx, cancel := context.WithTimeout(userCtx, 120*time.Second)
+	ctx, cancel := context.WithTimeout(tools.WithQuickReplies(userCtx), 120*time.Second)
 	defer cancel()
 
 	response, err := s.agentLoop.ProcessDirectWithChannel(
 		ctx, message, sessionKey, "api", "mobile-client", mediaPaths...,
 	)
+	alerts.RecordWebhook(err == nil || errors.Is(err, agent.ErrMaintenance))
+	if errors.Is(err, agent.ErrMaintenance) {
+		w.Header().Set("Retry-After", "60")
+		writeWebhookError(w, http.StatusServiceUnavailable, i18n.T(lang, "webhook.maintenance"))
+		return
+	}
+	if errors.Is(err, workpool.ErrQueueFull) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(s.workPool.RetryAfter().Seconds())))
+		writeWebhookError(w, http.StatusTooManyRequests, i18n.T(lang, "webhook.busy"))
+		return
+	}
+	if errors.Is(err, budget.ErrExceeded) {
+		writeWebhookError(w, http.StatusTooManyRequests, err.Error())
+		return
+	}
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		errMsg := err.Error()
-		json.NewEncoder(w).Encode(WebhookResponse{Error: &errMsg})
+		writeWebhookError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 	model := s.model
 	json.NewEncoder(w).Encode(WebhookResponse{
-		Response: &response,
-		Model:    &model,
+		Response:    &response,
+		Model:       &model,
+		Files:       storedFiles,
+		Approvals:   s.agentLoop.PendingApprovals(sessionKey),
+		Suggestions: tools.QuickReplies(ctx),
 	})
 }
 
+// writeWebhookError answers a webhook or pairing call with status and msg.
+func writeWebhookError(w http.ResponseWriter, status int, msg string) {
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(WebhookResponse{Error: &msg})
+}
+
 // validateJWT validates a LedgerForge JWT token and returns its claims.
 func (s *Server) validateJWT(tokenString string) (*LedgerForgeClaims, error) {
 	claims := &LedgerForgeClaims{}
@@ -426,51 +764,163 @@ func (s *Server) extractRawToken(r *http.Request) string {
 func (s *Server) pairHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	code := r.Header.Get("X-Pairing-Code")
-	if code == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		errMsg := "X-Pairing-Code header is required"
-		json.NewEncoder(w).Encode(WebhookResponse{Error: &errMsg})
+	lang := s.language(r.Context(), r, "")
+	token, status, errKey := s.pair(r.Header.Get("X-Pairing-Code"), r.Header.Get("X-Device-Name"))
+	if errKey != "" {
+		writeWebhookError(w, status, i18n.T(lang, errKey))
 		return
 	}
 
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]any{
+		"paired":  true,
+		"token":   token,
+		"message": i18n.T(lang, "pair.success"),
+		"error":   nil,
+	})
+}
+
+// pair redeems the one-time pairing code for a device token. On failure it
+// returns the HTTP status and the i18n key of the error.
+func (s *Server) pair(code, deviceName string) (string, int, string) {
+	if code == "" {
+		return "", http.StatusBadRequest, "pair.code_required"
+	}
+
 	s.mu.Lock()
 	if s.pairingUsed {
 		s.mu.Unlock()
-		w.WriteHeader(http.StatusGone)
-		errMsg := "pairing code already used"
-		json.NewEncoder(w).Encode(WebhookResponse{Error: &errMsg})
-		return
+		return "", http.StatusGone, "pair.code_used"
 	}
 
 	if code != s.pairingCode {
 		s.mu.Unlock()
-		w.WriteHeader(http.StatusForbidden)
-		errMsg := "invalid pairing code"
-		json.NewEncoder(w).Encode(WebhookResponse{Error: &errMsg})
-		return
+		return "", http.StatusForbidden, "pair.code_invalid"
 	}
 
 	// Generate bearer token
 	token, tokenHash := generateBearerToken()
+	device := config.PairedDevice{
+		TokenHash: tokenHash,
+		Name:      strings.TrimSpace(deviceName),
+		PairedAt:  time.Now().UTC(),
+	}
 	s.pairedTokens[tokenHash] = true
+	s.devices[tokenHash] = device
 	s.pairingUsed = true
 	s.mu.Unlock()
 
-	// Persist the token hash to config
-	if s.configPath != "" {
-		s.persistTokenHash(tokenHash)
+	s.persistDevice(device)
+	publishPairing(tokenHash, device)
+	return token, http.StatusOK, ""
+}
+
+// skillAuditHandler answers operator queries over recorded skill invocations.
+// Supported query parameters: skill, business_id, agent_id, since, until (RFC 3339), limit.
+func (s *Server) skillAuditHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if !s.isAuthorized(r) {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]any{"error": "unauthorized: invalid or missing bearer token"})
+		return
+	}
+
+	params := r.URL.Query()
+	q := audit.SkillQuery{
+		Skill:      params.Get("skill"),
+		BusinessID: params.Get("business_id"),
+	}
+
+	var err error
+	if v := params.Get("since"); v != "" {
+		if q.Since, err = time.Parse(time.RFC3339, v); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]any{"error": "invalid since: expected RFC 3339 timestamp"})
+			return
+		}
+	}
+	if v := params.Get("until"); v != "" {
+		if q.Until, err = time.Parse(time.RFC3339, v); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]any{"error": "invalid until: expected RFC 3339 timestamp"})
+			return
+		}
+	}
+	if v := params.Get("limit"); v != "" {
+		if q.Limit, err = strconv.Atoi(v); err != nil || q.Limit < 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]any{"error": "invalid limit"})
+			return
+		}
+	}
+
+	entries, err := s.agentLoop.QuerySkillAudit(params.Get("agent_id"), q)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]any{"error": err.Error()})
+		return
 	}
 
-	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]any{
-		"paired":  true,
-		"token":   token,
-		"message": "paired successfully",
-		"error":   nil,
+		"entries": entries,
+		"count":   len(entries),
 	})
 }
 
+// debugSessionsHandler lists sessions with a debug trace.
+// Supported query parameters: agent_id.
+func (s *Server) debugSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if !s.isAuthorized(r) {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]any{"error": "unauthorized: invalid or missing bearer token"})
+		return
+	}
+
+	sessions, err := s.agentLoop.DebugSessions(r.URL.Query().Get("agent_id"))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]any{"error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{
+		"sessions": sessions,
+		"count":    len(sessions),
+	})
+}
+
+// debugExportHandler downloads a session's debug trace as JSONL.
+// Supported query parameters: agent_id.
+func (s *Server) debugExportHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.isAuthorized(r) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]any{"error": "unauthorized: invalid or missing bearer token"})
+		return
+	}
+
+	// Buffer the trace so a read error can still be reported as JSON.
+	var buf bytes.Buffer
+	err := s.agentLoop.ExportSessionDebug(r.URL.Query().Get("agent_id"), r.PathValue("key"), &buf)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		status := http.StatusInternalServerError
+		if errors.Is(err, os.ErrNotExist) {
+			status = http.StatusNotFound
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]any{"error": err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", `attachment; filename="debug.jsonl"`)
+	w.Write(buf.Bytes())
+}
+
 // isAuthorized checks if the request has a valid bearer token.
 func (s *Server) isAuthorized(r *http.Request) bool {
 	// If no pairing required and no tokens exist, skip auth
@@ -503,24 +953,6 @@ func (s *Server) extractTokenHash(r *http.Request) string {
 	return hashToken(token)
 }
 
-// persistTokenHash saves the token hash to the config file.
-func (s *Server) persistTokenHash(tokenHash string) {
-	cfg, err := config.LoadConfig(s.configPath)
-	if err != nil {
-		return
-	}
-
-	// Add the new token hash if not already present
-	for _, existing := range cfg.Gateway.PairedTokens {
-		if existing == tokenHash {
-			return
-		}
-	}
-	cfg.Gateway.PairedTokens = append(cfg.Gateway.PairedTokens, tokenHash)
-
-	config.SaveConfig(s.configPath, cfg)
-}
-
 func generatePairingCode() string {
 	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
 	if err != nil {
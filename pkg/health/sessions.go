@@ -0,0 +1,24 @@
+package health
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/sipeed/picoclaw/pkg/agent"
+)
+
+// deleteSessionHandler deletes everything kept about a session, for privacy
+// requests, and answers with the deletion receipt. Any session can be named,
+// so it is an admin route.
+// Supported query parameters: agent_id.
+func (s *Server) deleteSessionHandler(w http.ResponseWriter, r *http.Request) {
+	receipt, err := s.agentLoop.DeleteSession(r.Context(), r.URL.Query().Get("agent_id"), r.PathValue("key"))
+	switch {
+	case errors.Is(err, agent.ErrSessionNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	default:
+		writeJSON(w, http.StatusOK, receipt)
+	}
+}
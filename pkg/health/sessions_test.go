@@ -0,0 +1,34 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/agent"
+)
+
+func TestDeleteSession(t *testing.T) {
+	token, tokenHash := generateBearerToken()
+	s, _ := newUploadServer(t, WithPairing(true, []string{tokenHash}, ""))
+	_, err := s.agentLoop.ProcessDirect(context.Background(), "hello", "agent:main:s1")
+	require.NoError(t, err)
+
+	rec := adminRequest(s, http.MethodDelete, "/admin/sessions/agent:main:s1", "", "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = adminRequest(s, http.MethodDelete, "/admin/sessions/agent:main:s1", token, "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var receipt agent.DeletionReceipt
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &receipt))
+	assert.Equal(t, "agent:main:s1", receipt.SessionKey)
+	assert.Equal(t, 2, receipt.Messages)
+	assert.NotEmpty(t, receipt.ReceiptID)
+
+	rec = adminRequest(s, http.MethodDelete, "/admin/sessions/agent:main:s1", token, "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,331 @@
+// PicoClaw dashboard. Talks to the gateway's own API with the bearer token
+// kept in localStorage; every value from the API is inserted as text.
+"use strict";
+
+const tokenKey = "picoclaw.token";
+const refreshMs = 10000;
+const pollMs = 3000;
+
+const $ = (id) => document.getElementById(id);
+
+function token() {
+  return localStorage.getItem(tokenKey) || "";
+}
+
+async function api(method, path, body, headers = {}) {
+  const opts = { method, headers: { ...headers } };
+  if (token()) {
+    opts.headers.Authorization = "Bearer " + token();
+  }
+  if (body !== undefined) {
+    opts.headers["Content-Type"] = "application/json";
+    opts.body = JSON.stringify(body);
+  }
+  const resp = await fetch(path, opts);
+  const data = await resp.json().catch(() => ({}));
+  if (!resp.ok) {
+    const err = new Error(data.error || resp.statusText);
+    err.status = resp.status;
+    throw err;
+  }
+  return data;
+}
+
+function el(tag, className, text) {
+  const node = document.createElement(tag);
+  if (className) node.className = className;
+  if (text !== undefined) node.textContent = text;
+  return node;
+}
+
+function showLogin(message) {
+  $("dashboard").hidden = true;
+  $("logout").hidden = true;
+  $("login").hidden = false;
+  $("login-error").textContent = message || "";
+  $("status").textContent = "locked";
+  $("status").className = "badge";
+}
+
+function showDashboard() {
+  $("login").hidden = true;
+  $("dashboard").hidden = false;
+  $("logout").hidden = !token();
+}
+
+function sum(values) {
+  return Object.values(values || {}).reduce((a, b) => a + b, 0);
+}
+
+function renderSummary(s) {
+  $("status").textContent = s.maintenance ? "maintenance" : s.ready ? "healthy" : "degraded";
+  $("status").className = "badge " + (s.ready && !s.maintenance ? "ok" : "fail");
+  $("meta").textContent = [s.version, s.model, "up " + s.uptime].filter(Boolean).join(" · ");
+
+  const checks = $("checks");
+  checks.replaceChildren();
+  const names = Object.keys(s.checks || {}).sort();
+  if (names.length === 0) {
+    checks.append(el("li", "muted", "No checks registered"));
+  }
+  for (const name of names) {
+    const c = s.checks[name];
+    const li = el("li");
+    const badge = c.status === "ok" || c.status === "warn" ? c.status : "fail";
+    li.append(el("span", "badge " + badge, c.status), " " + name);
+    if (c.message) li.append(el("span", "muted", " — " + c.message));
+    checks.append(li);
+  }
+  $("queue").textContent = `${s.running} running, ${s.queued} queued`;
+
+  const devices = s.paired_devices === 1 ? "1 paired device" : `${s.paired_devices} paired devices`;
+  $("devices").textContent = devices + (s.require_pairing ? " · pairing required" : " · pairing optional");
+
+  const usage = $("usage");
+  usage.replaceChildren();
+  for (const day of (s.usage || []).slice().reverse()) {
+    const tokens = Object.values(day.tokens || {}).reduce((a, t) => a + t.prompt + t.completion, 0);
+    const tr = el("tr");
+    for (const v of [day.date, sum(day.requests), tokens, day.receipts, sum(day.errors)]) {
+      tr.append(el("td", "", String(v)));
+    }
+    usage.append(tr);
+  }
+  if (!s.usage) {
+    const tr = el("tr");
+    const td = el("td", "muted", "Usage tracking is not available");
+    td.colSpan = 5;
+    tr.append(td);
+    usage.append(tr);
+  }
+}
+
+async function loadHistory() {
+  const list = $("history");
+  try {
+    const data = await api("GET", "/history/search?limit=10");
+    list.replaceChildren();
+    if (!data.results || data.results.length === 0) {
+      list.append(el("li", "muted", "No conversations yet"));
+    }
+    for (const turn of data.results || []) {
+      const li = el("li");
+      const when = new Date(turn.time).toLocaleString();
+      li.append(el("div", "muted turn-time", `${when} · ${turn.channel || "?"}`));
+      li.append(el("div", "turn-text", "› " + turn.message));
+      li.append(el("div", "turn-text muted", truncate(turn.response, 300)));
+      list.append(li);
+    }
+  } catch (err) {
+    list.replaceChildren(el("li", "muted", "History unavailable: " + err.message));
+  }
+}
+
+function truncate(text, n) {
+  text = text || "";
+  return text.length > n ? text.slice(0, n) + "…" : text;
+}
+
+// loadAdmin fills the admin card, or hides it when the token is not an
+// admin's.
+async function loadAdmin() {
+  let devices, requests, maintenance;
+  try {
+    [devices, requests, maintenance] = await Promise.all([
+      api("GET", "/admin/devices"),
+      api("GET", "/admin/pairing/requests"),
+      api("GET", "/admin/maintenance"),
+    ]);
+  } catch (err) {
+    $("admin").hidden = true;
+    return;
+  }
+  $("admin").hidden = false;
+  $("maintenance").checked = maintenance.enabled;
+
+  const pending = $("pair-requests");
+  pending.replaceChildren();
+  if (requests.requests.length === 0) {
+    pending.append(el("li", "muted", "No pending requests"));
+  }
+  for (const req of requests.requests) {
+    const li = el("li");
+    li.append(el("span", "code", req.code), ` ${req.name} `, el("span", "muted", req.remote_addr));
+    li.append(
+      adminButton("Approve", "", () => api("POST", `/admin/pairing/requests/${req.code}/approve`)),
+      adminButton("Deny", "secondary", () => api("DELETE", `/admin/pairing/requests/${req.code}`)),
+    );
+    pending.append(li);
+  }
+
+  const list = $("device-list");
+  list.replaceChildren();
+  for (const d of devices.devices) {
+    const tr = el("tr");
+    const paired = d.paired_at && !d.paired_at.startsWith("0001") ? new Date(d.paired_at).toLocaleString() : "—";
+    tr.append(el("td", d.name ? "" : "muted", d.name || "unnamed"), el("td", "code", d.id), el("td", "", paired));
+    const actions = el("td");
+    actions.append(
+      adminButton("Rename", "secondary", () => {
+        const name = prompt("Device name", d.name || "");
+        return name === null ? null : api("PATCH", `/admin/devices/${d.id}`, { name });
+      }),
+      adminButton("Revoke", "", () =>
+        confirm(`Revoke ${d.name || d.id}? Its token stops working immediately.`)
+          ? api("DELETE", `/admin/devices/${d.id}`)
+          : null,
+      ),
+    );
+    tr.append(actions);
+    list.append(tr);
+  }
+}
+
+// adminButton runs action and reloads the admin card. An action returning
+// null was cancelled by the user.
+function adminButton(label, className, action) {
+  const button = el("button", "small " + className, label);
+  button.addEventListener("click", async () => {
+    button.disabled = true;
+    try {
+      const pending = action();
+      if (pending === null) return;
+      await pending;
+      $("admin-message").textContent = "";
+    } catch (err) {
+      $("admin-message").textContent = err.message;
+    } finally {
+      button.disabled = false;
+    }
+    loadAdmin();
+  });
+  return button;
+}
+
+async function refresh() {
+  try {
+    renderSummary(await api("GET", "/ui/api/summary"));
+    showDashboard();
+    loadAdmin();
+  } catch (err) {
+    if (err.status === 401) {
+      showLogin(token() ? "The saved token was rejected." : "");
+      return;
+    }
+    $("status").textContent = "unreachable";
+    $("status").className = "badge fail";
+  }
+}
+
+$("pair-form").addEventListener("submit", async (e) => {
+  e.preventDefault();
+  try {
+    const data = await api("POST", "/pair", undefined, {
+      "X-Pairing-Code": $("pair-code").value.trim(),
+      "X-Device-Name": "Web dashboard",
+    });
+    localStorage.setItem(tokenKey, data.token);
+    $("pair-code").value = "";
+    await start();
+  } catch (err) {
+    $("login-error").textContent = err.message;
+  }
+});
+
+$("token-form").addEventListener("submit", async (e) => {
+  e.preventDefault();
+  localStorage.setItem(tokenKey, $("token").value.trim());
+  $("token").value = "";
+  await start();
+});
+
+$("request-form").addEventListener("submit", async (e) => {
+  e.preventDefault();
+  const status = $("request-status");
+  const button = e.target.querySelector("button");
+  try {
+    const req = await api("POST", "/pair/requests", { name: $("request-name").value.trim() });
+    button.disabled = true;
+    status.replaceChildren("Waiting for an admin to approve code ", el("span", "code", req.code), "…");
+    pollRequest(req.request_id, button);
+  } catch (err) {
+    status.textContent = err.message;
+  }
+});
+
+async function pollRequest(id, button) {
+  const status = $("request-status");
+  try {
+    const data = await api("GET", `/pair/requests/${id}`);
+    if (data.status !== "approved") {
+      setTimeout(() => pollRequest(id, button), pollMs);
+      return;
+    }
+    localStorage.setItem(tokenKey, data.token);
+    status.textContent = "";
+    $("request-name").value = "";
+    await start();
+  } catch (err) {
+    status.textContent = err.message;
+  }
+  button.disabled = false;
+}
+
+$("maintenance").addEventListener("change", async (e) => {
+  try {
+    await api("PUT", "/admin/maintenance", { enabled: e.target.checked });
+    $("admin-message").textContent = e.target.checked ? "Messages are being turned away." : "";
+  } catch (err) {
+    $("admin-message").textContent = err.message;
+  }
+  refresh();
+});
+
+$("reload").addEventListener("click", async () => {
+  if (!confirm("Restart the gateway with the current config file?")) return;
+  try {
+    await api("POST", "/admin/reload");
+    $("admin-message").textContent = "Reloading…";
+    setTimeout(refresh, pollMs);
+  } catch (err) {
+    $("admin-message").textContent = err.message;
+  }
+});
+
+$("logout").addEventListener("click", () => {
+  localStorage.removeItem(tokenKey);
+  $("admin").hidden = true;
+  showLogin("");
+});
+
+$("chat-form").addEventListener("submit", async (e) => {
+  e.preventDefault();
+  const input = $("chat-input");
+  const message = input.value.trim();
+  if (!message) return;
+  const log = $("chat-log");
+  const button = e.target.querySelector("button");
+  log.append(el("div", "msg user", message));
+  input.value = "";
+  button.disabled = true;
+  try {
+    const data = await api("POST", "/webhook", { message });
+    log.append(el("div", "msg agent", data.response || ""));
+    loadHistory();
+  } catch (err) {
+    log.append(el("div", "msg error", err.message));
+  } finally {
+    button.disabled = false;
+    log.scrollTop = log.scrollHeight;
+  }
+});
+
+async function start() {
+  await refresh();
+  if (!$("dashboard").hidden) loadHistory();
+}
+
+start();
+setInterval(() => {
+  if (!$("dashboard").hidden) refresh();
+}, refreshMs);
@@ -0,0 +1,36 @@
+package health
+
+import (
+	"net/http"
+
+	"github.com/sipeed/picoclaw/pkg/update"
+)
+
+// VersionResponse is the response of GET /version.
+type VersionResponse struct {
+	Version string                `json:"version"`
+	Release *update.ReleaseStatus `json:"release,omitempty"` // with a release check
+}
+
+// WithReleaseCheck reports the release checker's last result in /version
+// and the control socket's /status.
+func WithReleaseCheck(c *update.ReleaseChecker) ServerOption {
+	return func(s *Server) {
+		s.releases = c
+	}
+}
+
+func (s *Server) releaseStatus() *update.ReleaseStatus {
+	if s.releases == nil {
+		return nil
+	}
+	status := s.releases.Status()
+	return &status
+}
+
+// versionHandler reports the running version and, with a release check,
+// the latest release. It is for operators: an outdated version tells an
+// attacker which fixes the device lacks.
+func (s *Server) versionHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, VersionResponse{Version: s.version, Release: s.releaseStatus()})
+}
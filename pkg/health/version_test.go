@@ -0,0 +1,47 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/update"
+)
+
+func TestVersion(t *testing.T) {
+	feed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"tag_name": "v1.4.0", "html_url": "https://example.com/v1.4.0"}`))
+	}))
+	defer feed.Close()
+	releases := update.NewReleaseChecker(feed.URL, "v1.3.0")
+	_, err := releases.Check(context.Background())
+	require.NoError(t, err)
+
+	admin, adminHash := generateBearerToken()
+	s, _ := newUploadServer(t, WithPairing(false, []string{adminHash}, ""),
+		WithVersion("v1.3.0"), WithReleaseCheck(releases))
+
+	rec := adminRequest(s, http.MethodGet, "/version", "", "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code, "the version is for operators")
+
+	rec = adminRequest(s, http.MethodGet, "/version", admin, "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var resp VersionResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, "v1.3.0", resp.Version)
+	require.NotNil(t, resp.Release)
+	assert.True(t, resp.Release.Available)
+	assert.Equal(t, "v1.4.0", resp.Release.Latest)
+
+	rec = httptest.NewRecorder()
+	s.daemonStatusHandler(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+	var st DaemonStatus
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &st))
+	require.NotNil(t, st.Release)
+	assert.Equal(t, "https://example.com/v1.4.0", st.Release.URL)
+}
@@ -0,0 +1,249 @@
+package health
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/agent"
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/locale"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/workpool"
+)
+
+// blockingProvider answers once release is closed.
+type blockingProvider struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (p *blockingProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	p.started <- struct{}{}
+	<-p.release
+	return &providers.LLMResponse{Content: "done"}, nil
+}
+
+func (p *blockingProvider) GetDefaultModel() string {
+	return "test-model"
+}
+
+// echoProvider answers every prompt at once.
+type echoProvider struct{}
+
+func (echoProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	return &providers.LLMResponse{Content: "done"}, nil
+}
+
+func (echoProvider) GetDefaultModel() string {
+	return "test-model"
+}
+
+// multipartRequest builds a webhook call with the given fields and files, in
+// order. A field whose name starts with "file:" is sent as a file.
+func multipartRequest(t *testing.T, parts ...[2]string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, p := range parts {
+		if name, ok := strings.CutPrefix(p[0], "file:"); ok {
+			fw, err := mw.CreateFormFile("file", name)
+			require.NoError(t, err)
+			fw.Write([]byte(p[1]))
+			continue
+		}
+		require.NoError(t, mw.WriteField(p[0], p[1]))
+	}
+	require.NoError(t, mw.Close())
+	req := httptest.NewRequest(http.MethodPost, "/webhook", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func newUploadServer(t *testing.T, opts ...ServerOption) (*Server, string) {
+	t.Helper()
+	cfg := config.DefaultConfig()
+	cfg.Agents.Defaults.Workspace = t.TempDir()
+	al := agent.NewAgentLoop(cfg, bus.NewMessageBus(), echoProvider{})
+	return NewServer("127.0.0.1", 0, append([]ServerOption{WithAgentLoop(al)}, opts...)...),
+		cfg.Agents.Defaults.Workspace
+}
+
+func TestWebhookStreamsUploadsToMedia(t *testing.T) {
+	s, workspace := newUploadServer(t)
+	rec := httptest.NewRecorder()
+	s.webhookHandler(rec, multipartRequest(t,
+		[2]string{"message", "Process the attached receipt"},
+		[2]string{"business_id", "biz-1"},
+		[2]string{"file:receipt.jpg", "jpeg bytes"},
+	))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	matches, err := filepath.Glob(filepath.Join(workspace, "media", "*_receipt.jpg"))
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	data, err := os.ReadFile(matches[0])
+	require.NoError(t, err)
+	assert.Equal(t, "jpeg bytes", string(data))
+}
+
+func TestWebhookRejectsOversizedUpload(t *testing.T) {
+	s, workspace := newUploadServer(t, WithMaxUploadSize(8))
+	rec := httptest.NewRecorder()
+	s.webhookHandler(rec, multipartRequest(t,
+		[2]string{"file:receipt.jpg", "more than eight bytes"},
+	))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+
+	matches, _ := filepath.Glob(filepath.Join(workspace, "media", "*"))
+	assert.Empty(t, matches, "a rejected upload must not be left behind")
+}
+
+func TestWebhookRequiresBusinessIDBeforeFiles(t *testing.T) {
+	s, _ := newUploadServer(t)
+	rec := httptest.NewRecorder()
+	s.webhookHandler(rec, multipartRequest(t,
+		[2]string{"file:receipt.jpg", "jpeg bytes"},
+		[2]string{"business_id", "biz-1"},
+	))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "business_id must precede")
+}
+
+func TestWebhookAnswersInCallersLanguage(t *testing.T) {
+	s, _ := newUploadServer(t, WithLocales(locale.NewResolver(config.LocaleConfig{
+		Businesses: map[string]config.BusinessLocaleConfig{"biz-berlin": {Locale: "de-DE"}},
+	})))
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader("{"))
+	req.Header.Set("Accept-Language", "fr-CA,fr;q=0.9")
+	s.webhookHandler(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "corps de requête invalide")
+
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(`{"business_id":"biz-berlin"}`))
+	s.webhookHandler(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Nachricht oder Datei erforderlich")
+}
+
+// syncStore is a memStore safe for the concurrent copies of one webhook call.
+type syncStore struct {
+	mu sync.Mutex
+	memStore
+}
+
+func (s *syncStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.memStore.Put(ctx, key, bytes.NewReader(data), size, contentType)
+}
+
+func TestWebhookStoresUploadsInOrder(t *testing.T) {
+	store := &syncStore{memStore: memStore{}}
+	s, _ := newUploadServer(t, WithBlobStore(store, time.Hour))
+
+	parts := [][2]string{{"message", "Process these receipts"}}
+	for i := range 6 {
+		parts = append(parts, [2]string{fmt.Sprintf("file:receipt-%d.jpg", i), fmt.Sprintf("jpeg %d", i)})
+	}
+	rec := httptest.NewRecorder()
+	s.webhookHandler(rec, multipartRequest(t, parts...))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var resp struct {
+		Files []StoredFile `json:"files"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Len(t, resp.Files, 6)
+	for i, f := range resp.Files {
+		assert.Equal(t, fmt.Sprintf("receipt-%d.jpg", i), f.Name)
+		assert.Equal(t, fmt.Sprintf("jpeg %d", i), store.memStore[f.Key])
+	}
+}
+
+func TestWebhookReturns429WhenQueueFull(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Agents.Defaults.Workspace = t.TempDir()
+	provider := &blockingProvider{started: make(chan struct{}, 1), release: make(chan struct{})}
+	al := agent.NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+	pool := workpool.New(1, 0)
+	al.SetWorkPool(pool)
+	s := NewServer("127.0.0.1", 0, WithAgentLoop(al), WithWorkPool(pool))
+
+	post := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(`{"message":"hi"}`))
+		req.Header.Set("Content-Type", "application/json")
+		s.webhookHandler(rec, req)
+		return rec
+	}
+
+	first := make(chan *httptest.ResponseRecorder)
+	go func() { first <- post() }()
+	select {
+	case <-provider.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("first request did not reach the provider")
+	}
+
+	rec := post()
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+
+	close(provider.release)
+	require.Equal(t, http.StatusOK, (<-first).Code)
+}
+
+func TestTracedPassesClient(t *testing.T) {
+	var got any
+	h := traced("POST /webhook", func(w http.ResponseWriter, r *http.Request) {
+		got = r.Context().Value(constants.ContextKeyClient)
+	})
+	req := httptest.NewRequest(http.MethodPost, "/webhook", nil)
+	req.Header.Set(constants.ClientHeader, " ios/2.3.1 (iPhone15,2)\n")
+	h(httptest.NewRecorder(), req)
+	assert.Equal(t, "ios/2.3.1 (iPhone15,2)", got)
+
+	req.Header.Set(constants.ClientHeader, strings.Repeat("x", 200))
+	h(httptest.NewRecorder(), req)
+	assert.Len(t, got, maxClientTag)
+
+	req.Header.Del(constants.ClientHeader)
+	h(httptest.NewRecorder(), req)
+	assert.Nil(t, got)
+}
@@ -0,0 +1,110 @@
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/sipeed/picoclaw/pkg/workflow"
+)
+
+// defaultWorkflowRuns is how many runs GET /admin/workflows lists without a
+// limit.
+const defaultWorkflowRuns = 50
+
+// WithWorkflows enables the /admin/workflows endpoints, which list e's
+// workflows and runs, start runs, and approve, reject or resume them.
+func WithWorkflows(e *workflow.Engine) ServerOption {
+	return func(s *Server) {
+		s.workflows = e
+	}
+}
+
+// requireWorkflows answers 501 when the workflow engine is off.
+func (s *Server) requireWorkflows(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.workflows == nil {
+			writeError(w, http.StatusNotImplemented, "workflows are not enabled")
+			return
+		}
+		h(w, r)
+	}
+}
+
+// listWorkflowsHandler lists the workflows and the most recent runs,
+// ?limit=N of them.
+func (s *Server) listWorkflowsHandler(w http.ResponseWriter, r *http.Request) {
+	limit := defaultWorkflowRuns
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, "limit must be a non-negative number")
+			return
+		}
+		limit = n
+	}
+	runs, err := s.workflows.Runs(limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	workflows := s.workflows.Workflows()
+	writeJSON(w, http.StatusOK, map[string]any{
+		"workflows": workflows,
+		"runs":      runs,
+		"count":     len(workflows),
+	})
+}
+
+// workflowRunHandler returns one run with its step results.
+func (s *Server) workflowRunHandler(w http.ResponseWriter, r *http.Request) {
+	run, err := s.workflows.Get(r.PathValue("id"))
+	writeWorkflowRun(w, http.StatusOK, run, err)
+}
+
+// startWorkflowHandler starts a run of the named workflow. The optional
+// body gives the business and the data the run sees as its event.
+func (s *Server) startWorkflowHandler(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		BusinessID string         `json:"business_id"`
+		Event      map[string]any `json:"event"`
+	}
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&body)
+	if err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, `expected {"business_id": "...", "event": {...}} or no body`)
+		return
+	}
+	run, err := s.workflows.Start(r.PathValue("name"), workflow.TriggerManual, body.BusinessID, body.Event)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusAccepted, run)
+}
+
+// decideWorkflowHandler approves or rejects a run waiting for approval.
+func (s *Server) decideWorkflowHandler(approve bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		run, err := s.workflows.Decide(r.PathValue("id"), approve, "admin")
+		writeWorkflowRun(w, http.StatusOK, run, err)
+	}
+}
+
+// resumeWorkflowHandler retries a failed run from the step that failed.
+func (s *Server) resumeWorkflowHandler(w http.ResponseWriter, r *http.Request) {
+	run, err := s.workflows.Resume(r.PathValue("id"))
+	writeWorkflowRun(w, http.StatusOK, run, err)
+}
+
+func writeWorkflowRun(w http.ResponseWriter, status int, run *workflow.Run, err error) {
+	switch {
+	case errors.Is(err, workflow.ErrRunNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case err != nil:
+		writeError(w, http.StatusConflict, err.Error())
+	default:
+		writeJSON(w, status, run)
+	}
+}
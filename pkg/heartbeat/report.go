@@ -0,0 +1,264 @@
+package heartbeat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/i18n"
+	"github.com/sipeed/picoclaw/pkg/ledgerforge"
+	"github.com/sipeed/picoclaw/pkg/locale"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/state"
+)
+
+// defaultReportTemplate is used for channels without a template of their
+// own. It is plain text, which every channel can show.
+const defaultReportTemplate = `{{t "heartbeat.salutation" .Greeting .BusinessName}} ` +
+	`{{t "heartbeat.intro" .Date}}
+
+{{.Message}}
+{{- if .Figures}}
+
+{{table .Figures}}
+{{- end}}
+`
+
+// markdownReportTemplate is the default for channels that render Markdown.
+const markdownReportTemplate = `*{{t "heartbeat.salutation" .Greeting .BusinessName}}* ` +
+	`{{t "heartbeat.intro" .Date}}
+
+{{.Message}}
+{{- if .Figures}}
+
+` + "```" + `
+{{table .Figures}}
+` + "```" + `
+{{- end}}
+`
+
+var markdownChannels = map[string]bool{"telegram": true, "discord": true, "slack": true}
+
+// Report is what a heartbeat report template is executed with. Templates
+// can also call t with a message key of the i18n catalog and its arguments
+// to write it in the report's language.
+type Report struct {
+	Greeting     string // "Good morning", "Good afternoon" or "Good evening"
+	BusinessID   string
+	BusinessName string // the business ID when the name is unknown
+	Language     string // language of the business, e.g. "fr"
+	Time         time.Time
+	Date         string // Time as a long date in Language, e.g. "Monday, March 31"; set by Render
+	Channel      string
+	Message      string   // the agent's heartbeat response
+	Figures      []Figure // empty without LedgerForge
+}
+
+// Figure is one row of the report's figures table.
+type Figure struct {
+	Label string
+	Value string
+}
+
+// Target is a chat a report can be delivered to.
+type Target struct {
+	Channel string
+	ChatID  string
+}
+
+// ReporterOptions configures a Reporter.
+type ReporterOptions struct {
+	// Workspace holds templates/heartbeat.tmpl and per-channel variants such
+	// as templates/heartbeat.telegram.tmpl, which replace the built-in ones.
+	Workspace string
+	// Send delivers content to a chat and reports whether it got there.
+	Send func(ctx context.Context, channel, chatID, content string) error
+	// LedgerForge, if set, supplies the business name and figures.
+	LedgerForge *ledgerforge.Client
+	// Locales picks each business's language and, if configured, formats
+	// the figures' amounts in its currency; otherwise they are shown as
+	// LedgerForge sends them.
+	Locales *locale.Resolver
+	// Fallbacks are tried in order when the business's own chat fails.
+	Fallbacks []Target
+	// State, if set, adds the last chat the agent talked to as a final
+	// fallback.
+	State *state.Manager
+}
+
+// Reporter formats business heartbeat results and delivers them.
+type Reporter struct {
+	opts ReporterOptions
+	now  func() time.Time
+}
+
+// NewReporter creates a reporter.
+func NewReporter(opts ReporterOptions) *Reporter {
+	return &Reporter{opts: opts, now: time.Now}
+}
+
+// Deliver sends message, formatted for businessID, to the chat in auth. If
+// that fails it tries each fallback in turn, formatting the report again for
+// each channel, until one send succeeds.
+func (r *Reporter) Deliver(ctx context.Context, businessID string, auth state.AuthEntry, message string) error {
+	now := r.now().In(r.opts.Locales.For(businessID).Location())
+	lang := r.opts.Locales.Language(businessID, "")
+	report := Report{
+		Greeting:     greeting(lang, now),
+		BusinessID:   businessID,
+		BusinessName: businessID,
+		Language:     lang,
+		Time:         now,
+		Message:      strings.TrimSpace(message),
+	}
+	if r.opts.LedgerForge != nil && auth.JWTToken != "" {
+		r.addLedgerFigures(ledgerforge.WithAuth(ctx, auth.JWTToken, businessID), &report)
+	}
+
+	var errs error
+	for _, target := range r.targets(auth) {
+		report.Channel = target.Channel
+		content, err := r.Render(report)
+		if err != nil {
+			return err
+		}
+		err = r.opts.Send(ctx, target.Channel, target.ChatID, content)
+		if err == nil {
+			logger.InfoCF("heartbeat", "Delivered heartbeat report",
+				map[string]any{"business_id": businessID, "channel": target.Channel})
+			return nil
+		}
+		logger.WarnCF("heartbeat", "Heartbeat report delivery failed",
+			map[string]any{"business_id": businessID, "channel": target.Channel, "error": err.Error()})
+		errs = errors.Join(errs, fmt.Errorf("%s:%s: %w", target.Channel, target.ChatID, err))
+	}
+	if errs == nil {
+		return fmt.Errorf("no chat to deliver the heartbeat report of business %s to", businessID)
+	}
+	return errs
+}
+
+// targets lists the chats to try, in order, without duplicates or
+// internal channels.
+func (r *Reporter) targets(auth state.AuthEntry) []Target {
+	candidates := append([]Target{{auth.Channel, auth.ChatID}}, r.opts.Fallbacks...)
+	if r.opts.State != nil {
+		if channel, chatID, ok := strings.Cut(r.opts.State.GetLastChannel(), ":"); ok {
+			candidates = append(candidates, Target{channel, chatID})
+		}
+	}
+
+	seen := make(map[Target]bool)
+	var targets []Target
+	for _, t := range candidates {
+		if t.Channel == "" || t.ChatID == "" || constants.IsInternalChannel(t.Channel) || seen[t] {
+			continue
+		}
+		seen[t] = true
+		targets = append(targets, t)
+	}
+	return targets
+}
+
+// Render executes the template for report.Channel: the workspace's variant
+// for the channel, else the workspace's heartbeat.tmpl, else a built-in one.
+func (r *Reporter) Render(report Report) (string, error) {
+	if report.Date == "" {
+		report.Date = i18n.LongDate(report.Language, report.Time)
+	}
+	text := r.template(report.Channel)
+	tmpl, err := template.New("heartbeat").Funcs(template.FuncMap{
+		"table": figureTable,
+		"t": func(key string, args ...any) string {
+			return i18n.T(report.Language, key, args...)
+		},
+	}).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid heartbeat report template: %w", err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, report); err != nil {
+		return "", fmt.Errorf("executing heartbeat report template: %w", err)
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+func (r *Reporter) template(channel string) string {
+	if r.opts.Workspace != "" {
+		dir := filepath.Join(r.opts.Workspace, "templates")
+		for _, name := range []string{"heartbeat." + channel + ".tmpl", "heartbeat.tmpl"} {
+			if data, err := os.ReadFile(filepath.Join(dir, name)); err == nil {
+				return string(data)
+			}
+		}
+	}
+	if markdownChannels[channel] {
+		return markdownReportTemplate
+	}
+	return defaultReportTemplate
+}
+
+// addLedgerFigures fills in the business name and dashboard figures. The
+// report goes out without them if LedgerForge cannot be reached.
+func (r *Reporter) addLedgerFigures(ctx context.Context, report *Report) {
+	if businesses, err := r.opts.LedgerForge.Businesses(ctx); err == nil {
+		for _, b := range businesses {
+			if b.ID == report.BusinessID && b.Name != "" {
+				report.BusinessName = b.Name
+			}
+		}
+	}
+	summary, err := r.opts.LedgerForge.Summary(ctx)
+	if err != nil {
+		logger.WarnCF("heartbeat", "Heartbeat report has no figures",
+			map[string]any{"business_id": report.BusinessID, "error": err.Error()})
+		return
+	}
+	amount := func(s string) string { return s }
+	if r.opts.Locales.Configured() {
+		amount = r.opts.Locales.For(report.BusinessID).FormatAmountString
+	}
+	label := func(key string) string { return i18n.T(report.Language, "heartbeat."+key) }
+	report.Figures = []Figure{
+		{label("revenue"), amount(summary.TotalRevenue)},
+		{label("expenses"), amount(summary.TotalExpenses)},
+		{label("safe_to_spend"), amount(summary.SafeToSpend)},
+		{label("tax_reserved"), amount(summary.TaxReserved)},
+		{label("receivables"), amount(summary.OutstandingReceivables)},
+		{label("payables"), amount(summary.OutstandingPayables)},
+		{label("needs_review"), strconv.Itoa(summary.ExceptionsCount)},
+	}
+}
+
+// figureTable lays figures out in two aligned columns.
+func figureTable(figures []Figure) string {
+	width := 0
+	for _, f := range figures {
+		width = max(width, utf8.RuneCountInString(f.Label))
+	}
+	lines := make([]string, len(figures))
+	for i, f := range figures {
+		pad := strings.Repeat(" ", width-utf8.RuneCountInString(f.Label))
+		lines[i] = f.Label + pad + "  " + f.Value
+	}
+	return strings.Join(lines, "\n")
+}
+
+func greeting(lang string, t time.Time) string {
+	switch h := t.Hour(); {
+	case h < 12:
+		return i18n.T(lang, "heartbeat.morning")
+	case h < 18:
+		return i18n.T(lang, "heartbeat.afternoon")
+	default:
+		return i18n.T(lang, "heartbeat.evening")
+	}
+}
@@ -0,0 +1,188 @@
+package heartbeat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/ledgerforge"
+	"github.com/sipeed/picoclaw/pkg/locale"
+	"github.com/sipeed/picoclaw/pkg/state"
+)
+
+func TestRender_UsesChannelTemplates(t *testing.T) {
+	workspace := t.TempDir()
+	r := NewReporter(ReporterOptions{Workspace: workspace})
+	report := Report{
+		Greeting:     "Good morning",
+		BusinessID:   "biz-1",
+		BusinessName: "Acme",
+		Time:         time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC),
+		Message:      "Two invoices are overdue.",
+		Figures:      []Figure{{"Revenue", "1200.00"}, {"Safe to spend", "300.00"}},
+	}
+
+	report.Channel = "whatsapp"
+	got, err := r.Render(report)
+	if err != nil {
+		t.Fatalf("Render() error: %v", err)
+	}
+	want := "Good morning, Acme! Here is your heartbeat for Monday, March 2.\n\n" +
+		"Two invoices are overdue.\n\n" +
+		"Revenue        1200.00\n" +
+		"Safe to spend  300.00"
+	if got != want {
+		t.Errorf("plain report:\n%s\nwant:\n%s", got, want)
+	}
+
+	report.Channel = "telegram"
+	if got, _ = r.Render(report); !strings.HasPrefix(got, "*Good morning, Acme!*") || !strings.Contains(got, "```") {
+		t.Errorf("telegram report should use the Markdown template, got:\n%s", got)
+	}
+
+	dir := filepath.Join(workspace, "templates")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, text := range map[string]string{
+		"heartbeat.tmpl":     "{{.BusinessName}}: {{.Message}}",
+		"heartbeat.sms.tmpl": "{{.BusinessID}} via sms",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for channel, want := range map[string]string{
+		"telegram": "Acme: Two invoices are overdue.",
+		"sms":      "biz-1 via sms",
+	} {
+		report.Channel = channel
+		if got, err := r.Render(report); err != nil || got != want {
+			t.Errorf("Render(%s) = %q, %v; want %q", channel, got, err, want)
+		}
+	}
+}
+
+func TestDeliver_FallsBackToOtherChats(t *testing.T) {
+	tmpDir := t.TempDir()
+	sm := state.NewManager(tmpDir)
+	if err := sm.SetLastChannel("discord:last"); err != nil {
+		t.Fatal(err)
+	}
+
+	var tried []string
+	r := NewReporter(ReporterOptions{
+		Send: func(_ context.Context, channel, chatID, content string) error {
+			tried = append(tried, channel+":"+chatID)
+			if channel == "discord" {
+				if !strings.HasPrefix(content, "*Good ") {
+					t.Errorf("discord report should use the Markdown template, got:\n%s", content)
+				}
+				return nil
+			}
+			return errors.New("channel down")
+		},
+		Fallbacks: []Target{{"telegram", "123"}, {"cli", "direct"}, {"slack", "C1"}},
+		State:     sm,
+	})
+
+	auth := state.AuthEntry{Channel: "telegram", ChatID: "123"}
+	if err := r.Deliver(context.Background(), "biz-1", auth, "Cash is low."); err != nil {
+		t.Fatalf("Deliver() error: %v", err)
+	}
+	want := []string{"telegram:123", "slack:C1", "discord:last"}
+	if !reflect.DeepEqual(tried, want) {
+		t.Errorf("tried %v, want %v", tried, want)
+	}
+
+	r.opts.State = nil
+	tried = nil
+	err := r.Deliver(context.Background(), "biz-1", auth, "Cash is low.")
+	if err == nil || !strings.Contains(err.Error(), "slack:C1: channel down") {
+		t.Errorf("Deliver() error = %v, want the failures of every chat", err)
+	}
+}
+
+func TestDeliver_AddsLedgerForgeFigures(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer jwt" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v1/businesses":
+			fmt.Fprint(w, `[{"id":"other","name":"Other"},{"id":"biz-1","name":"Acme Bakery"}]`)
+		case "/api/v1/businesses/biz-1/transactions/summary":
+			fmt.Fprint(w, `{"total_revenue":"5000.00","safe_to_spend":"1200.50","exceptions_count":3}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	var sent string
+	r := NewReporter(ReporterOptions{
+		Send: func(_ context.Context, _, _, content string) error {
+			sent = content
+			return nil
+		},
+		LedgerForge: ledgerforge.New(ledgerforge.Options{BaseURL: srv.URL, MaxRetries: 0}),
+	})
+	auth := state.AuthEntry{JWTToken: "jwt", Channel: "whatsapp", ChatID: "555"}
+	if err := r.Deliver(context.Background(), "biz-1", auth, "All good."); err != nil {
+		t.Fatalf("Deliver() error: %v", err)
+	}
+	for _, want := range []string{
+		", Acme Bakery!", "Revenue        5000.00", "Safe to spend  1200.50", "Needs review   3",
+	} {
+		if !strings.Contains(sent, want) {
+			t.Errorf("report missing %q:\n%s", want, sent)
+		}
+	}
+}
+
+func TestDeliver_WritesReportInBusinessLocale(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/businesses/biz-1/transactions/summary" {
+			fmt.Fprint(w, `{"total_revenue":"5000.00","safe_to_spend":"1200.50","exceptions_count":3}`)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	var sent string
+	r := NewReporter(ReporterOptions{
+		Send: func(_ context.Context, _, _, content string) error {
+			sent = content
+			return nil
+		},
+		LedgerForge: ledgerforge.New(ledgerforge.Options{BaseURL: srv.URL, MaxRetries: 0}),
+		Locales: locale.NewResolver(config.LocaleConfig{
+			Businesses: map[string]config.BusinessLocaleConfig{"biz-1": {Locale: "de-DE"}},
+		}),
+	})
+	// No timezone is configured, so the report uses the server's
+	r.now = func() time.Time { return time.Date(2026, 10, 15, 9, 0, 0, 0, time.Local) }
+	auth := state.AuthEntry{JWTToken: "jwt", Channel: "whatsapp", ChatID: "555"}
+	if err := r.Deliver(context.Background(), "biz-1", auth, "All good."); err != nil {
+		t.Fatalf("Deliver() error: %v", err)
+	}
+	for _, want := range []string{
+		"Guten Morgen, biz-1! Hier ist Ihr Heartbeat für", "Einnahmen          5.000,00 €",
+		"Verfügbar          1.200,50 €", "Zu prüfen          3",
+	} {
+		if !strings.Contains(sent, want) {
+			t.Errorf("report missing %q:\n%s", want, sent)
+		}
+	}
+}
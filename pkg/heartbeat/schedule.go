@@ -0,0 +1,144 @@
+package heartbeat
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// Schedule is when and what a business's heartbeat runs.
+type Schedule struct {
+	Enabled  bool
+	Interval time.Duration // zero uses the service interval
+	Window   *Window       // nil means any time
+	Prompt   string        // replaces the HEARTBEAT.md tasks
+	Skill    string
+	Location *time.Location // the business's zone, for the window and prompt time
+}
+
+// Window is the part of the week heartbeats may run in, such as business
+// hours.
+type Window struct {
+	Days       [7]bool // indexed by time.Weekday
+	Start, End int     // minutes since midnight; End may be before Start to span midnight
+	Location   *time.Location
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// ParseSchedule checks a business's heartbeat config and turns it into a
+// Schedule. loc is the business's zone, used unless c sets its own; nil is
+// local time.
+func ParseSchedule(c config.BusinessHeartbeatConfig, loc *time.Location) (Schedule, error) {
+	if loc == nil {
+		loc = time.Local
+	}
+	s := Schedule{
+		Enabled:  c.Enabled == nil || *c.Enabled,
+		Prompt:   strings.TrimSpace(c.Prompt),
+		Skill:    strings.TrimSpace(c.Skill),
+		Location: loc,
+	}
+	if c.Interval > 0 {
+		s.Interval = time.Duration(max(c.Interval, minIntervalMinutes)) * time.Minute
+	}
+	if c.Timezone != "" {
+		var err error
+		if s.Location, err = time.LoadLocation(c.Timezone); err != nil {
+			return s, fmt.Errorf("invalid timezone %q: %w", c.Timezone, err)
+		}
+	}
+	if c.Window != "" {
+		w, err := ParseWindow(c.Window, s.Location)
+		if err != nil {
+			return s, err
+		}
+		s.Window = w
+	}
+	return s, nil
+}
+
+// ParseWindow parses "09:00-17:00" or "Mon-Fri 09:00-17:00". Days are a
+// range or a comma-separated list, such as "Mon,Wed,Fri".
+func ParseWindow(spec string, loc *time.Location) (*Window, error) {
+	w := &Window{Location: loc}
+	fields := strings.Fields(spec)
+	switch len(fields) {
+	case 1:
+		for d := range w.Days {
+			w.Days[d] = true
+		}
+	case 2:
+		if err := w.parseDays(fields[0]); err != nil {
+			return nil, fmt.Errorf("invalid window %q: %w", spec, err)
+		}
+	default:
+		return nil, fmt.Errorf("invalid window %q: expected [days] HH:MM-HH:MM", spec)
+	}
+
+	start, end, ok := strings.Cut(fields[len(fields)-1], "-")
+	if !ok {
+		return nil, fmt.Errorf("invalid window %q: expected HH:MM-HH:MM", spec)
+	}
+	var err error
+	if w.Start, err = parseClock(start); err != nil {
+		return nil, fmt.Errorf("invalid window %q: %w", spec, err)
+	}
+	if w.End, err = parseClock(end); err != nil {
+		return nil, fmt.Errorf("invalid window %q: %w", spec, err)
+	}
+	if w.Start == w.End {
+		return nil, fmt.Errorf("invalid window %q: start and end are the same", spec)
+	}
+	return w, nil
+}
+
+func (w *Window) parseDays(spec string) error {
+	for _, part := range strings.Split(strings.ToLower(spec), ",") {
+		from, to, isRange := strings.Cut(part, "-")
+		first, ok := weekdays[from]
+		if !ok {
+			return fmt.Errorf("unknown day %q", from)
+		}
+		last := first
+		if isRange {
+			if last, ok = weekdays[to]; !ok {
+				return fmt.Errorf("unknown day %q", to)
+			}
+		}
+		for d := first; ; d = (d + 1) % 7 {
+			w.Days[d] = true
+			if d == last {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Contains reports whether t falls inside the window. A window spanning
+// midnight belongs to the day it starts on.
+func (w *Window) Contains(t time.Time) bool {
+	t = t.In(w.Location)
+	minute := t.Hour()*60 + t.Minute()
+	if w.Start < w.End {
+		return w.Days[t.Weekday()] && minute >= w.Start && minute < w.End
+	}
+	if minute >= w.Start {
+		return w.Days[t.Weekday()]
+	}
+	return minute < w.End && w.Days[(t.Weekday()+6)%7]
+}
@@ -0,0 +1,95 @@
+package heartbeat
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestParseWindow(t *testing.T) {
+	// 2026-03-02 is a Monday.
+	at := func(day int, clock string) time.Time {
+		tm, _ := time.Parse("15:04", clock)
+		return time.Date(2026, 3, day, tm.Hour(), tm.Minute(), 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		spec string
+		at   time.Time
+		want bool
+	}{
+		{"09:00-17:00", at(1, "10:00"), true},
+		{"09:00-17:00", at(1, "17:00"), false},
+		{"Mon-Fri 09:00-17:00", at(2, "09:00"), true},
+		{"Mon-Fri 09:00-17:00", at(7, "12:00"), false},
+		{"Mon,Wed 09:00-17:00", at(4, "12:00"), true},
+		{"Fri-Mon 09:00-17:00", at(1, "12:00"), true},
+		{"Fri-Mon 09:00-17:00", at(3, "12:00"), false},
+		// Overnight windows belong to the day they start on.
+		{"Fri 22:00-06:00", at(6, "23:00"), true},
+		{"Fri 22:00-06:00", at(7, "05:00"), true},
+		{"Fri 22:00-06:00", at(6, "05:00"), false},
+	}
+	for _, tt := range tests {
+		w, err := ParseWindow(tt.spec, time.UTC)
+		if err != nil {
+			t.Fatalf("ParseWindow(%q): %v", tt.spec, err)
+		}
+		if got := w.Contains(tt.at); got != tt.want {
+			t.Errorf("%q contains %s = %v, want %v", tt.spec, tt.at.Format("Mon 15:04"), got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"9-5", "Someday 09:00-17:00", "09:00-09:00", "Mon 09:00 17:00"} {
+		if _, err := ParseWindow(bad, time.UTC); err == nil {
+			t.Errorf("ParseWindow(%q) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestParseSchedule(t *testing.T) {
+	off := false
+	sched, err := ParseSchedule(config.BusinessHeartbeatConfig{
+		Enabled:  &off,
+		Interval: 2,
+		Window:   "Mon-Fri 09:00-17:00",
+		Timezone: "America/Toronto",
+		Skill:    "oluto",
+	}, time.UTC)
+	if err != nil {
+		t.Fatalf("ParseSchedule: %v", err)
+	}
+	if sched.Enabled {
+		t.Error("Expected schedule to be disabled")
+	}
+	if sched.Interval != minIntervalMinutes*time.Minute {
+		t.Errorf("Expected interval raised to the minimum, got %v", sched.Interval)
+	}
+	if sched.Window == nil || sched.Window.Location.String() != "America/Toronto" {
+		t.Errorf("Expected window in America/Toronto, got %+v", sched.Window)
+	}
+
+	bad := config.BusinessHeartbeatConfig{Window: "09:00-17:00", Timezone: "Mars/Base"}
+	if _, err := ParseSchedule(bad, nil); err == nil {
+		t.Error("Expected an error for an unknown timezone")
+	}
+}
+
+func TestParseSchedule_UsesBusinessZone(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("no tzdata: %v", err)
+	}
+	sched, err := ParseSchedule(config.BusinessHeartbeatConfig{Window: "09:00-17:00"}, berlin)
+	if err != nil {
+		t.Fatalf("ParseSchedule: %v", err)
+	}
+	if sched.Location != berlin || sched.Window.Location != berlin {
+		t.Errorf("Expected schedule and window in Europe/Berlin, got %v and %v", sched.Location, sched.Window.Location)
+	}
+	// 08:30 UTC is 09:30 in Berlin in winter
+	if !sched.Window.Contains(time.Date(2026, 1, 14, 8, 30, 0, 0, time.UTC)) {
+		t.Error("Expected 08:30 UTC inside a 09:00-17:00 Berlin window")
+	}
+}
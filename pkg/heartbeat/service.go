@@ -10,12 +10,14 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/locale"
 	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/state"
 	"github.com/sipeed/picoclaw/pkg/tools"
@@ -24,6 +26,8 @@ import (
 const (
 	minIntervalMinutes     = 5
 	defaultIntervalMinutes = 30
+	// businessTick is how often the service checks which businesses are due.
+	businessTick = time.Minute
 )
 
 // HeartbeatHandler is the function type for handling heartbeat.
@@ -31,6 +35,10 @@ const (
 // channel and chatID are derived from the last active user channel.
 type HeartbeatHandler func(prompt, channel, chatID string) *tools.ToolResult
 
+// BusinessHandler runs the heartbeat of one business, with the auth its
+// users last sent. The entry's channel and chat ID say where results go.
+type BusinessHandler func(businessID, prompt string, auth state.AuthEntry) *tools.ToolResult
+
 // HeartbeatService manages periodic heartbeat checks
 type HeartbeatService struct {
 	workspace string
@@ -39,8 +47,14 @@ type HeartbeatService struct {
 	handler   HeartbeatHandler
 	interval  time.Duration
 	enabled   bool
+	isLeader  func() bool
 	mu        sync.RWMutex
 	stopChan  chan struct{}
+
+	businessHandler BusinessHandler
+	schedules       map[string]Schedule // by business ID
+	locales         *locale.Resolver
+	lastRun         map[string]time.Time // by business ID; "" for the handler
 }
 
 // NewHeartbeatService creates a new heartbeat service
@@ -59,9 +73,18 @@ func NewHeartbeatService(workspace string, intervalMinutes int, enabled bool) *H
 		interval:  time.Duration(intervalMinutes) * time.Minute,
 		enabled:   enabled,
 		state:     state.NewManager(workspace),
+		lastRun:   make(map[string]time.Time),
 	}
 }
 
+// SetStateManager replaces the workspace state the service reads the last
+// active channel from, so it can share one manager with the gateway.
+func (hs *HeartbeatService) SetStateManager(m *state.Manager) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	hs.state = m
+}
+
 // SetBus sets the message bus for delivering heartbeat results.
 func (hs *HeartbeatService) SetBus(msgBus *bus.MessageBus) {
 	hs.mu.Lock()
@@ -76,6 +99,39 @@ func (hs *HeartbeatService) SetHandler(handler HeartbeatHandler) {
 	hs.handler = handler
 }
 
+// SetBusinessHandler runs a heartbeat for each business in the workspace
+// state's active auth, each on its own schedule. The handler set with
+// SetHandler still runs, every interval, while no business is active.
+func (hs *HeartbeatService) SetBusinessHandler(handler BusinessHandler) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	hs.businessHandler = handler
+}
+
+// SetSchedules sets per-business schedules. Businesses without one run
+// every interval with the HEARTBEAT.md tasks.
+func (hs *HeartbeatService) SetSchedules(schedules map[string]Schedule) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	hs.schedules = schedules
+}
+
+// SetLocales sets the resolver that gives businesses without a schedule
+// their zone.
+func (hs *HeartbeatService) SetLocales(r *locale.Resolver) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	hs.locales = r
+}
+
+// SetLeaderCheck makes the service skip heartbeats while isLeader returns
+// false, so that of several gateways sharing state only the leader runs them.
+func (hs *HeartbeatService) SetLeaderCheck(isLeader func() bool) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	hs.isLeader = isLeader
+}
+
 // Start begins the heartbeat service
 func (hs *HeartbeatService) Start() error {
 	hs.mu.Lock()
@@ -92,7 +148,11 @@ func (hs *HeartbeatService) Start() error {
 	}
 
 	hs.stopChan = make(chan struct{})
-	go hs.runLoop(hs.stopChan)
+	tick := hs.interval
+	if hs.businessHandler != nil {
+		tick = businessTick
+	}
+	go hs.runLoop(hs.stopChan, tick)
 
 	logger.InfoCF("heartbeat", "Heartbeat service started", map[string]any{
 		"interval_minutes": hs.interval.Minutes(),
@@ -123,8 +183,8 @@ func (hs *HeartbeatService) IsRunning() bool {
 }
 
 // runLoop runs the heartbeat ticker
-func (hs *HeartbeatService) runLoop(stopChan chan struct{}) {
-	ticker := time.NewTicker(hs.interval)
+func (hs *HeartbeatService) runLoop(stopChan chan struct{}, tick time.Duration) {
+	ticker := time.NewTicker(tick)
 	defer ticker.Stop()
 
 	// Run first heartbeat after initial delay
@@ -147,16 +207,34 @@ func (hs *HeartbeatService) executeHeartbeat() {
 	hs.mu.RLock()
 	enabled := hs.enabled
 	handler := hs.handler
+	businessHandler := hs.businessHandler
+	isLeader := hs.isLeader
 	if !hs.enabled || hs.stopChan == nil {
 		hs.mu.RUnlock()
 		return
 	}
 	hs.mu.RUnlock()
 
+	if isLeader != nil && !isLeader() {
+		logger.DebugC("heartbeat", "Skipping heartbeat, not the leader")
+		return
+	}
+
 	if !enabled {
 		return
 	}
 
+	now := time.Now()
+	if businessHandler != nil {
+		if active := hs.state.GetActiveAuth(); len(active) > 0 {
+			hs.executeBusinessHeartbeats(now, businessHandler, active)
+			return
+		}
+		if !hs.due("", hs.interval, now) {
+			return
+		}
+	}
+
 	logger.DebugC("heartbeat", "Executing heartbeat")
 
 	prompt := hs.buildPrompt()
@@ -215,8 +293,106 @@ func (hs *HeartbeatService) executeHeartbeat() {
 	hs.logInfo("Heartbeat completed: %s", result.ForLLM)
 }
 
+// executeBusinessHeartbeats runs the heartbeat of each active business that
+// is due, inside its window and not turned off.
+func (hs *HeartbeatService) executeBusinessHeartbeats(
+	now time.Time, handler BusinessHandler, active map[string]state.AuthEntry,
+) {
+	ids := make([]string, 0, len(active))
+	for id := range active {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	tasks := hs.readTasks()
+	for _, id := range ids {
+		sched := hs.scheduleFor(id)
+		if !sched.Enabled || (sched.Window != nil && !sched.Window.Contains(now)) {
+			continue
+		}
+		interval := sched.Interval
+		if interval == 0 {
+			interval = hs.interval
+		}
+		if !hs.due(id, interval, now) {
+			continue
+		}
+
+		businessTasks := tasks
+		if sched.Prompt != "" {
+			businessTasks = sched.Prompt
+		}
+		if sched.Skill != "" {
+			businessTasks += fmt.Sprintf("\n\nUse the %s skill for this check.", sched.Skill)
+		}
+		if strings.TrimSpace(businessTasks) == "" {
+			continue
+		}
+
+		entry := active[id]
+		if entry.Channel == "" || entry.ChatID == "" {
+			entry.Channel, entry.ChatID = hs.parseLastChannel(hs.state.GetLastChannel())
+		}
+		if entry.Channel == "" || entry.ChatID == "" {
+			entry.Channel, entry.ChatID = "cli", "direct"
+		}
+
+		logger.DebugCF("heartbeat", "Executing business heartbeat", map[string]any{"business_id": id})
+		result := handler(id, formatPrompt(now.In(sched.Location), businessTasks), entry)
+		switch {
+		case result == nil:
+		case result.IsError:
+			hs.logError("Heartbeat error for business %s: %s", id, result.ForLLM)
+		default:
+			hs.logInfo("Heartbeat completed for business %s", id)
+		}
+	}
+}
+
+// scheduleFor returns a business's schedule, with any /heartbeat on or off
+// applied.
+func (hs *HeartbeatService) scheduleFor(businessID string) Schedule {
+	hs.mu.RLock()
+	sched, ok := hs.schedules[businessID]
+	locales := hs.locales
+	hs.mu.RUnlock()
+	if !ok {
+		sched = Schedule{Enabled: true}
+	}
+	if sched.Location == nil {
+		sched.Location = locales.For(businessID).Location()
+	}
+	if enabled, set := hs.state.BusinessHeartbeat(businessID); set {
+		sched.Enabled = enabled
+	}
+	return sched
+}
+
+// due reports whether interval has passed since key last ran, and if so
+// records that it runs now. Half a tick of slack keeps ticks that arrive a
+// little early from delaying a run by a whole tick.
+func (hs *HeartbeatService) due(key string, interval time.Duration, now time.Time) bool {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	if last, ok := hs.lastRun[key]; ok && now.Sub(last) < interval-businessTick/2 {
+		return false
+	}
+	hs.lastRun[key] = now
+	return true
+}
+
 // buildPrompt builds the heartbeat prompt from HEARTBEAT.md
 func (hs *HeartbeatService) buildPrompt() string {
+	tasks := hs.readTasks()
+	if tasks == "" {
+		return ""
+	}
+	return formatPrompt(time.Now(), tasks)
+}
+
+// readTasks returns the contents of HEARTBEAT.md, creating a template if it
+// does not exist.
+func (hs *HeartbeatService) readTasks() string {
 	heartbeatPath := filepath.Join(hs.workspace, "HEARTBEAT.md")
 
 	data, err := os.ReadFile(heartbeatPath)
@@ -229,12 +405,10 @@ func (hs *HeartbeatService) buildPrompt() string {
 		return ""
 	}
 
-	content := string(data)
-	if len(content) == 0 {
-		return ""
-	}
+	return string(data)
+}
 
-	now := time.Now().Format("2006-01-02 15:04:05")
+func formatPrompt(now time.Time, tasks string) string {
 	return fmt.Sprintf(`# Heartbeat Check
 
 Current time: %s
@@ -244,7 +418,7 @@ Review the following tasks and execute any necessary actions using available ski
 If there is nothing that requires attention, respond ONLY with: HEARTBEAT_OK
 
 %s
-`, now, content)
+`, now.Format("2006-01-02 15:04:05"), tasks)
 }
 
 // createDefaultHeartbeatTemplate creates the default HEARTBEAT.md file
@@ -308,9 +482,10 @@ func (hs *HeartbeatService) sendResponse(response string) {
 	}
 
 	msgBus.PublishOutbound(bus.OutboundMessage{
-		Channel: platform,
-		ChatID:  userID,
-		Content: response,
+		Channel:      platform,
+		ChatID:       userID,
+		Content:      response,
+		Notification: true,
 	})
 
 	hs.logInfo("Heartbeat result sent to %s", platform)
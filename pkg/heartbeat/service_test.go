@@ -3,9 +3,12 @@ package heartbeat
 import (
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/sipeed/picoclaw/pkg/state"
 	"github.com/sipeed/picoclaw/pkg/tools"
 )
 
@@ -180,6 +183,32 @@ func TestExecuteHeartbeat_NilResult(t *testing.T) {
 	hs.executeHeartbeat()
 }
 
+func TestExecuteHeartbeat_FollowerSkips(t *testing.T) {
+	tmpDir := t.TempDir()
+	hs := NewHeartbeatService(tmpDir, 30, true)
+	hs.stopChan = make(chan struct{}) // Enable for testing
+
+	calls := 0
+	hs.SetHandler(func(prompt, channel, chatID string) *tools.ToolResult {
+		calls++
+		return tools.SilentResult("ok")
+	})
+	os.WriteFile(filepath.Join(tmpDir, "HEARTBEAT.md"), []byte("Test task"), 0o644)
+
+	leader := false
+	hs.SetLeaderCheck(func() bool { return leader })
+	hs.executeHeartbeat()
+	if calls != 0 {
+		t.Errorf("Expected follower to skip heartbeat, handler called %d times", calls)
+	}
+
+	leader = true
+	hs.executeHeartbeat()
+	if calls != 1 {
+		t.Errorf("Expected leader to run heartbeat once, handler called %d times", calls)
+	}
+}
+
 // TestLogPath verifies heartbeat log is written to workspace directory
 func TestLogPath(t *testing.T) {
 	tmpDir, err := os.MkdirTemp("", "heartbeat-test-*")
@@ -219,3 +248,60 @@ func TestHeartbeatFilePath(t *testing.T) {
 		t.Errorf("Expected HEARTBEAT.md at %s, but it doesn't exist", expectedPath)
 	}
 }
+
+func TestExecuteBusinessHeartbeats_FollowSchedules(t *testing.T) {
+	tmpDir := t.TempDir()
+	hs := NewHeartbeatService(tmpDir, 30, true)
+	os.WriteFile(filepath.Join(tmpDir, "HEARTBEAT.md"), []byte("Check cash"), 0o644)
+	for _, id := range []string{"biz-hourly", "biz-default", "biz-paused", "biz-office"} {
+		hs.state.SetBusinessAuth(id, "jwt-"+id, "telegram", "chat-"+id)
+	}
+	hs.state.SetBusinessHeartbeat("biz-paused", false)
+	office, _ := ParseWindow("Mon-Fri 09:00-17:00", time.UTC)
+	hs.SetSchedules(map[string]Schedule{
+		"biz-hourly": {Enabled: true, Interval: time.Hour, Prompt: "Report overdue invoices", Skill: "oluto"},
+		"biz-office": {Enabled: true, Window: office},
+	})
+
+	var ran []string
+	prompts := map[string]string{}
+	handler := func(businessID, prompt string, auth state.AuthEntry) *tools.ToolResult {
+		ran = append(ran, businessID)
+		prompts[businessID] = prompt
+		if auth.JWTToken != "jwt-"+businessID || auth.ChatID != "chat-"+businessID {
+			t.Errorf("Unexpected auth for %s: %+v", businessID, auth)
+		}
+		return tools.SilentResult("ok")
+	}
+	run := func(now time.Time) []string {
+		ran = nil
+		hs.executeBusinessHeartbeats(now, handler, hs.state.GetActiveAuth())
+		return ran
+	}
+
+	// Saturday: the office business is outside its window.
+	sat := time.Date(2026, 3, 7, 10, 0, 0, 0, time.UTC)
+	if got := run(sat); !reflect.DeepEqual(got, []string{"biz-default", "biz-hourly"}) {
+		t.Errorf("First run: got %v", got)
+	}
+	if !strings.Contains(prompts["biz-hourly"], "Report overdue invoices") ||
+		!strings.Contains(prompts["biz-hourly"], "oluto skill") {
+		t.Errorf("Expected the business prompt and skill, got %q", prompts["biz-hourly"])
+	}
+	if !strings.Contains(prompts["biz-default"], "Check cash") {
+		t.Errorf("Expected the HEARTBEAT.md tasks, got %q", prompts["biz-default"])
+	}
+
+	if got := run(sat.Add(30 * time.Minute)); !reflect.DeepEqual(got, []string{"biz-default"}) {
+		t.Errorf("After 30 minutes: got %v", got)
+	}
+	if got := run(sat.Add(time.Hour)); !reflect.DeepEqual(got, []string{"biz-default", "biz-hourly"}) {
+		t.Errorf("After an hour: got %v", got)
+	}
+
+	// Monday morning the office business runs as soon as its window opens.
+	mon := time.Date(2026, 3, 9, 9, 0, 0, 0, time.UTC)
+	if got := run(mon); !reflect.DeepEqual(got, []string{"biz-default", "biz-hourly", "biz-office"}) {
+		t.Errorf("Monday: got %v", got)
+	}
+}
@@ -0,0 +1,170 @@
+// Package httpclient is the shared transport for outbound HTTP: LLM
+// providers, channels, skills registries and tools. Sharing one transport
+// keeps connections (and their TLS sessions) alive between calls instead of
+// every client opening its own, and applies one proxy and per-host
+// connection limit everywhere.
+//
+// Clients returned by New pick up later calls to Configure, so packages can
+// create them at init time.
+package httpclient
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Options tunes the shared transport.
+type Options struct {
+	// Proxy is an http://, https:// or socks5:// URL. Empty uses the
+	// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+	Proxy               string
+	MaxConnsPerHost     int // zero means no limit
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration
+	// LocalOnly refuses connections to addresses outside loopback, private
+	// and link-local ranges, and ignores any proxy, so nothing reaches the
+	// internet.
+	LocalOnly bool
+}
+
+// DefaultOptions are used until Configure is called.
+var DefaultOptions = Options{
+	MaxConnsPerHost:     8,
+	MaxIdleConnsPerHost: 4,
+	IdleConnTimeout:     90 * time.Second,
+}
+
+var (
+	current atomic.Pointer[http.Transport]
+	options atomic.Pointer[Options]
+
+	// proxied holds one transport per explicit proxy URL, for clients
+	// configured with their own proxy.
+	mu      sync.Mutex
+	proxied = map[string]*http.Transport{}
+)
+
+func init() {
+	t, _ := newTransport(DefaultOptions)
+	current.Store(t)
+	opts := DefaultOptions
+	options.Store(&opts)
+}
+
+// Configure replaces the shared transport. Idle connections of the previous
+// one are closed; requests in flight finish on it.
+func Configure(opts Options) error {
+	t, err := newTransport(opts)
+	if err != nil {
+		return err
+	}
+	old := current.Swap(t)
+	options.Store(&opts)
+	old.CloseIdleConnections()
+
+	mu.Lock()
+	for k, pt := range proxied {
+		pt.CloseIdleConnections()
+		delete(proxied, k)
+	}
+	mu.Unlock()
+	return nil
+}
+
+// Transport returns a RoundTripper that sends requests over the shared
+// transport.
+func Transport() http.RoundTripper {
+	return sharedTransport{}
+}
+
+// New returns a client using the shared transport. A zero timeout means
+// none; callers then bound requests with their context.
+func New(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: sharedTransport{}}
+}
+
+// NewWithProxy is New with requests sent through proxy instead of the shared
+// proxy setting. An empty proxy is the same as New.
+func NewWithProxy(timeout time.Duration, proxy string) (*http.Client, error) {
+	if proxy == "" {
+		return New(timeout), nil
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	t, ok := proxied[proxy]
+	if !ok {
+		opts := *options.Load()
+		opts.Proxy = proxy
+		var err error
+		if t, err = newTransport(opts); err != nil {
+			return nil, err
+		}
+		proxied[proxy] = t
+	}
+	return &http.Client{Timeout: timeout, Transport: t}, nil
+}
+
+type sharedTransport struct{}
+
+func (sharedTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	return current.Load().RoundTrip(r)
+}
+
+func newTransport(opts Options) (*http.Transport, error) {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	proxy := http.ProxyFromEnvironment
+	if opts.LocalOnly {
+		dialer.Control = refuseRemote
+		proxy = nil
+	} else if opts.Proxy != "" {
+		u, err := url.Parse(opts.Proxy)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q", opts.Proxy)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+		}
+		proxy = http.ProxyURL(u)
+	}
+	return &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxConnsPerHost:       opts.MaxConnsPerHost,
+		MaxIdleConnsPerHost:   opts.MaxIdleConnsPerHost,
+		IdleConnTimeout:       opts.IdleConnTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}, nil
+}
+
+// ErrOffline is returned for connections LocalOnly refuses.
+var ErrOffline = errors.New("offline mode: only local network addresses are allowed")
+
+// refuseRemote is a net.Dialer Control function that fails dials to
+// addresses outside the local network. It runs after name resolution, so
+// it sees the address actually dialed.
+func refuseRemote(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast()) {
+		return fmt.Errorf("%w: %s", ErrOffline, host)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package httpclient
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func get(t *testing.T, c *http.Client, url string) string {
+	t.Helper()
+	resp, err := c.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestClientsShareConnections(t *testing.T) {
+	var conns atomic.Int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	for range 3 {
+		assert.Equal(t, "ok", get(t, New(0), srv.URL))
+	}
+	assert.Equal(t, int32(1), conns.Load())
+}
+
+func TestConfigureProxy(t *testing.T) {
+	t.Cleanup(func() { Configure(DefaultOptions) })
+
+	var proxied atomic.Int32
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied.Add(1)
+		io.WriteString(w, "via proxy "+r.URL.Host)
+	}))
+	defer proxy.Close()
+
+	c := New(0) // created before Configure
+	require.NoError(t, Configure(Options{Proxy: proxy.URL}))
+	assert.Equal(t, "via proxy example.invalid", get(t, c, "http://example.invalid/"))
+	assert.Equal(t, int32(1), proxied.Load())
+}
+
+func TestNewWithProxy(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "provider proxy")
+	}))
+	defer proxy.Close()
+
+	c, err := NewWithProxy(0, proxy.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "provider proxy", get(t, c, "http://example.invalid/"))
+
+	again, err := NewWithProxy(0, proxy.URL)
+	require.NoError(t, err)
+	assert.Same(t, c.Transport, again.Transport)
+}
+
+func TestInvalidProxy(t *testing.T) {
+	assert.Error(t, Configure(Options{Proxy: "ftp://proxy:21"}))
+	_, err := NewWithProxy(0, "not a url")
+	assert.Error(t, err)
+}
+
+func TestLocalOnlyRefusesRemoteAddresses(t *testing.T) {
+	t.Cleanup(func() { Configure(DefaultOptions) })
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	require.NoError(t, Configure(Options{LocalOnly: true, Proxy: "http://proxy.example.com:3128"}))
+	assert.Equal(t, "ok", get(t, New(0), srv.URL), "loopback is allowed and the proxy ignored")
+
+	_, err := New(0).Get("http://203.0.113.1/")
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrOffline)
+}
@@ -0,0 +1,573 @@
+package i18n
+
+// catalog maps each language to its messages by key. Every key must be in
+// English; the other languages should have them all, which the tests check.
+var catalog = map[string]map[string]string{
+	"en": {
+		// Chat replies
+		"chat.maintenance": "PicoClaw is down for maintenance. Please try again later.",
+		"chat.error":       "Error processing message: %v",
+		"chat.filtered":    "Your message wasn't sent to the assistant because it contains content this chat doesn't allow.",
+
+		// Chat commands
+		"cmd.show.usage":        "Usage: /show [model|channel|agents]",
+		"cmd.show.unknown":      "Unknown show target: %s",
+		"cmd.list.usage":        "Usage: /list [models|channels|agents]",
+		"cmd.list.unknown":      "Unknown list target: %s",
+		"cmd.list.models":       "Available models: configured in config.json per agent",
+		"cmd.switch.usage":      "Usage: /switch [model|channel] to <name>",
+		"cmd.switch.unknown":    "Unknown switch target: %s",
+		"cmd.switch.model":      "Switched model from %s to %s",
+		"cmd.switch.channel":    "Switched target channel to %s",
+		"cmd.channel_not_found": "Channel '%s' not found or not enabled",
+		"cmd.no_default_agent":  "No default agent configured",
+		"cmd.no_channels":       "No channels enabled",
+		"cmd.no_channel_mgr":    "Channel manager not initialized",
+		"cmd.current_model":     "Current model: %s",
+		"cmd.current_channel":   "Current channel: %s",
+		"cmd.agents":            "Registered agents: %s",
+		"cmd.channels":          "Enabled channels: %s",
+		"cmd.debug.usage":       "Usage: /debug [on|off|status]",
+		"cmd.debug.on":          "Debug mode on for this session. Full prompts and tool IO are written to %s",
+		"cmd.debug.off":         "Debug mode off for this session.",
+		"cmd.debug.is_on":       "Debug mode is on for session %s",
+		"cmd.debug.is_off":      "Debug mode is off for session %s",
+
+		"forget.usage": "Usage: /forget [confirm]",
+		"forget.confirm": "This deletes this conversation's history and summary, its search history, its files, " +
+			"its event records, its message log and the notes it saved to memory. It can't be undone. " +
+			"Send /forget confirm to go ahead.",
+		"forget.nothing": "There is nothing to forget in this conversation.",
+		"forget.failed":  "Failed to delete this conversation: %v",
+		"forget.done": "Forgotten: %d messages, %d searchable turns, %d files and %d event records " +
+			"deleted (receipt %s).",
+
+		"business.usage":         "Usage: /business [list|switch <id>]",
+		"business.none_selected": "No business selected. Use /business list and /business switch <id>.",
+		"business.current":       "Receipts in this chat post to business %s.",
+		"business.list_failed":   "Failed to list businesses: %v",
+		"business.none_found":    "No businesses found.",
+		"business.list":          "Businesses:",
+		"business.active":        " (active)",
+		"business.not_found":     "Business %s not found. Use /business list to see your businesses.",
+		"business.switch_failed": "Failed to switch business: %v",
+		"business.switched":      "Switched to %s. Receipts in this chat now post to it.",
+
+		"review.usage": "Usage: /review [list|confirm <id> [vendor=... amount=... date=... category=...]|" +
+			"discard <id>]",
+		"review.unavailable":    "Receipt review is not turned on for this gateway.",
+		"review.no_business":    "This chat is not linked to a business, so it has no receipts to review.",
+		"review.list_failed":    "Failed to list the receipts waiting for review: %v",
+		"review.none":           "No receipts are waiting for review.",
+		"review.list":           "Receipts waiting for review:",
+		"review.check":          "check",
+		"review.sign_in":        "Confirming a receipt needs a LedgerForge sign-in, which this chat doesn't have yet.",
+		"review.not_found":      "Receipt %s is not waiting for review. Use /review to see the queue.",
+		"review.confirm_failed": "Failed to confirm receipt %s: %v",
+		"review.confirmed":      "Receipt %s confirmed: draft %s for %s %s from %s.",
+		"review.discard_failed": "Failed to discard receipt %s: %v",
+		"review.discarded":      "Receipt %s discarded; no draft was created.",
+
+		"categorize.usage":         "Usage: /categorize [list|<merchant> as <category>|forget <n>]",
+		"categorize.no_business":   "This chat is not linked to a business, so it has no category rules.",
+		"categorize.none":          "This business has no category rules.",
+		"categorize.list":          "Category rules, tried in this order:",
+		"categorize.configured":    " (configured)",
+		"categorize.save_failed":   "Failed to save the rule: %v",
+		"categorize.saved":         "Saved: receipts from %s are now categorized as %s.",
+		"categorize.forget_failed": "Failed to forget the rule: %v",
+		"categorize.forgot":        "Rule %d forgotten.",
+
+		"approval.request": "This needs your approval first:\n%s\n\n" +
+			"Reply with the /approve or /reject command shown. Requests expire after %d minutes.",
+		"approval.owner_request": "A request from %s needs your approval:\n%s\n\n" +
+			"Reply with the /approve or /reject command shown. Requests expire after %d minutes.",
+		"approval.waiting_owner": "This needs the owner's approval. I've asked them and will carry on once they decide.",
+		"approval.unavailable":   "Tool approval is not turned on for this gateway.",
+		"approval.usage":         "Usage: /approve [<id>] or /reject [<id>]",
+		"approval.none":          "Nothing is waiting for your approval.",
+		"approval.choose":        "Several requests are waiting; add the ID of the one you mean:",
+		"approval.not_found":     "Request %s is not waiting for your approval. It may have expired.",
+		"approval.approved":      "Approved request %s; the answer went to the chat that asked.",
+		"approval.rejected":      "Rejected request %s; the chat that asked was told.",
+
+		"workflow.usage":         "Usage: /workflow [list|runs|run <name>|approve <id>|reject <id>|resume <id>]",
+		"workflow.unavailable":   "Workflows are not turned on for this gateway.",
+		"workflow.none":          "No workflows are defined.",
+		"workflow.list":          "Workflows:",
+		"workflow.runs":          "Recent workflow runs:",
+		"workflow.no_runs":       "This business has no workflow runs yet.",
+		"workflow.started":       "Started workflow %s (run %s).",
+		"workflow.not_found":     "Workflow run %s not found. Use /workflow runs to see the recent runs.",
+		"workflow.decided":       "Workflow run %s is now %s.",
+		"workflow.failed_action": "Workflow action failed: %v",
+		"workflow.approval": "Workflow %s needs your approval:\n%s\n\n" +
+			"Reply /workflow approve %[3]s or /workflow reject %[3]s.",
+		"workflow.failed": "Workflow %s failed (run %s): %s\nFix the cause and reply /workflow resume %[2]s.",
+
+		"link.usage":       "Usage: /link [<code>|off]",
+		"link.unavailable": "Linking your chat and app conversations is not turned on for this gateway.",
+		"link.unsupported": "Only a direct chat or the app can be linked.",
+		"link.failed":      "Failed to link: %v",
+		"link.code":        "Send /link %s from the app or your other chat within %d minutes to link them.",
+		"link.invalid":     "That link code is wrong or has expired. Send /link to get a new one.",
+		"link.same_side": "Send the code from the other side: the app if you got it in a chat, " +
+			"or a chat if you got it in the app.",
+		"link.linked":     "Linked. Your conversation now carries over between this chat and the app.",
+		"link.not_linked": "Nothing is linked here.",
+		"link.unlinked":   "Unlinked. Chat and app conversations are separate again.",
+
+		"heartbeat.usage":         "Usage: /heartbeat [on|off|status]",
+		"heartbeat.unavailable":   "Heartbeat settings are not available",
+		"heartbeat.no_business":   "This chat is not linked to a business, so it has no heartbeat to change.",
+		"heartbeat.save_failed":   "Failed to save the heartbeat setting: %v",
+		"heartbeat.turned_on":     "Heartbeat turned on for business %s.",
+		"heartbeat.turned_off":    "Heartbeat turned off for business %s.",
+		"heartbeat.gateway_off":   "Heartbeats are turned off for this gateway.",
+		"heartbeat.is_off":        "Heartbeat is off for business %s.",
+		"heartbeat.is_on":         "Heartbeat is on for business %s, every %d minutes",
+		"heartbeat.during":        " during %s",
+		"heartbeat.using_skill":   ", using the %s skill",
+		"heartbeat.end":           ".",
+		"heartbeat.morning":       "Good morning",
+		"heartbeat.afternoon":     "Good afternoon",
+		"heartbeat.evening":       "Good evening",
+		"heartbeat.salutation":    "%s, %s!",
+		"heartbeat.intro":         "Here is your heartbeat for %s.",
+		"heartbeat.revenue":       "Revenue",
+		"heartbeat.expenses":      "Expenses",
+		"heartbeat.safe_to_spend": "Safe to spend",
+		"heartbeat.tax_reserved":  "Tax reserved",
+		"heartbeat.receivables":   "Receivables",
+		"heartbeat.payables":      "Payables",
+		"heartbeat.needs_review":  "Needs review",
+
+		"telegram.help": "/start - Start the bot\n" +
+			"/help - Show this help message\n" +
+			"/show [model|channel] - Show current configuration\n" +
+			"/list [models|channels] - List available options\n" +
+			"/status - Show agent and storage status",
+		"telegram.start":         "Hello! I am PicoClaw 🦞",
+		"telegram.show.usage":    "Usage: /show [model|channel]",
+		"telegram.show.model":    "Current Model: %s (Provider: %s)",
+		"telegram.show.channel":  "Current Channel: telegram",
+		"telegram.show.unknown":  "Unknown parameter: %s. Try 'model' or 'channel'.",
+		"telegram.list.usage":    "Usage: /list [models|channels]",
+		"telegram.list.models":   "Configured Model: %s\nProvider: %s\n\nTo change models, update config.yaml",
+		"telegram.list.default":  "configured default",
+		"telegram.list.channels": "Enabled Channels:\n- %s",
+		"telegram.list.unknown":  "Unknown parameter: %s. Try 'models' or 'channels'.",
+
+		// API errors
+		"api.unauthorized":         "unauthorized: %v",
+		"api.invalid_body":         "invalid request body",
+		"webhook.invalid_debug":    "invalid debug flag",
+		"webhook.message_required": "message or file is required",
+		"guest.files_only":         "this link only accepts file uploads",
+		"guest.link_invalid":       "this guest link is invalid or has expired",
+		"nats.message_required":    "message is required",
+		"api.message_required":     "message is required",
+		"api.read_only":            "read-only observer tokens cannot do this",
+		"webhook.maintenance":      "picoclaw is in maintenance mode",
+		"webhook.busy":             "too many requests in progress, try again later",
+		"upload.invalid_form":      "failed to parse multipart form",
+		"upload.field_too_large":   "form field %q is too large",
+		"upload.business_first":    "business_id must precede uploaded files",
+		"upload.too_large":         "uploaded file is too large",
+
+		// Federation
+		"federation.origin_required": "origin and session are required",
+
+		// Pairing
+		"pair.code_required":     "X-Pairing-Code header is required",
+		"pair.code_used":         "pairing code already used",
+		"pair.code_invalid":      "invalid pairing code",
+		"pair.success":           "paired successfully",
+		"pair.name_required":     "name is required and at most 64 bytes",
+		"pair.too_many_requests": "too many pending pairing requests",
+		"pair.request_gone":      "pairing request was denied or has expired",
+	},
+	"fr": {
+		"chat.maintenance": "PicoClaw est en maintenance. Veuillez réessayer plus tard.",
+		"chat.error":       "Erreur lors du traitement du message : %v",
+		"chat.filtered": "Votre message n'a pas été transmis à l'assistant, car il contient " +
+			"du contenu interdit dans ce groupe.",
+
+		"cmd.show.usage":        "Utilisation : /show [model|channel|agents]",
+		"cmd.show.unknown":      "Élément inconnu pour /show : %s",
+		"cmd.list.usage":        "Utilisation : /list [models|channels|agents]",
+		"cmd.list.unknown":      "Élément inconnu pour /list : %s",
+		"cmd.list.models":       "Modèles disponibles : configurés par agent dans config.json",
+		"cmd.switch.usage":      "Utilisation : /switch [model|channel] to <nom>",
+		"cmd.switch.unknown":    "Élément inconnu pour /switch : %s",
+		"cmd.switch.model":      "Modèle changé de %s à %s",
+		"cmd.switch.channel":    "Canal cible changé pour %s",
+		"cmd.channel_not_found": "Canal « %s » introuvable ou non activé",
+		"cmd.no_default_agent":  "Aucun agent par défaut n'est configuré",
+		"cmd.no_channels":       "Aucun canal activé",
+		"cmd.no_channel_mgr":    "Le gestionnaire de canaux n'est pas initialisé",
+		"cmd.current_model":     "Modèle actuel : %s",
+		"cmd.current_channel":   "Canal actuel : %s",
+		"cmd.agents":            "Agents enregistrés : %s",
+		"cmd.channels":          "Canaux activés : %s",
+		"cmd.debug.usage":       "Utilisation : /debug [on|off|status]",
+		"cmd.debug.on": "Mode débogage activé pour cette session. Les prompts complets et les entrées et " +
+			"sorties des outils sont écrits dans %s",
+		"cmd.debug.off":    "Mode débogage désactivé pour cette session.",
+		"cmd.debug.is_on":  "Le mode débogage est activé pour la session %s",
+		"cmd.debug.is_off": "Le mode débogage est désactivé pour la session %s",
+
+		"forget.usage": "Utilisation : /forget [confirm]",
+		"forget.confirm": "Cela supprime l'historique et le résumé de cette conversation, son historique de " +
+			"recherche, ses fichiers, ses événements, son journal des messages et les notes qu'elle a " +
+			"enregistrées en mémoire. C'est irréversible. Envoyez /forget confirm pour continuer.",
+		"forget.nothing": "Il n'y a rien à oublier dans cette conversation.",
+		"forget.failed":  "Impossible de supprimer cette conversation : %v",
+		"forget.done": "Oublié : %d messages, %d échanges consultables, %d fichiers et %d événements " +
+			"supprimés (reçu %s).",
+
+		"business.usage":         "Utilisation : /business [list|switch <id>]",
+		"business.none_selected": "Aucune entreprise sélectionnée. Utilisez /business list et /business switch <id>.",
+		"business.current":       "Les reçus de cette conversation sont envoyés à l'entreprise %s.",
+		"business.list_failed":   "Impossible de lister les entreprises : %v",
+		"business.none_found":    "Aucune entreprise trouvée.",
+		"business.list":          "Entreprises :",
+		"business.active":        " (active)",
+		"business.not_found": "Entreprise %s introuvable. Utilisez /business list pour voir vos " +
+			"entreprises.",
+		"business.switch_failed": "Impossible de changer d'entreprise : %v",
+		"business.switched":      "Entreprise active : %s. Les reçus de cette conversation y sont désormais envoyés.",
+
+		"review.usage": "Utilisation : /review [list|confirm <id> [vendor=... amount=... date=... " +
+			"category=...]|discard <id>]",
+		"review.unavailable": "La vérification des reçus n'est pas activée sur cette passerelle.",
+		"review.no_business": "Cette conversation n'est liée à aucune entreprise, il n'y a donc aucun reçu " +
+			"à vérifier.",
+		"review.list_failed": "Impossible de lister les reçus à vérifier : %v",
+		"review.none":        "Aucun reçu n'attend de vérification.",
+		"review.list":        "Reçus à vérifier :",
+		"review.check":       "à vérifier",
+		"review.sign_in": "Confirmer un reçu nécessite une connexion LedgerForge, que cette conversation " +
+			"n'a pas encore.",
+		"review.not_found":      "Le reçu %s n'attend pas de vérification. Utilisez /review pour voir la file.",
+		"review.confirm_failed": "Impossible de confirmer le reçu %s : %v",
+		"review.confirmed":      "Reçu %s confirmé : brouillon %s de %s %s chez %s.",
+		"review.discard_failed": "Impossible d'écarter le reçu %s : %v",
+		"review.discarded":      "Reçu %s écarté ; aucun brouillon n'a été créé.",
+
+		"categorize.usage": "Utilisation : /categorize [list|<commerçant> as <catégorie>|forget <n>]",
+		"categorize.no_business": "Cette conversation n'est liée à aucune entreprise, il n'y a donc aucune " +
+			"règle de catégorie.",
+		"categorize.none":          "Cette entreprise n'a aucune règle de catégorie.",
+		"categorize.list":          "Règles de catégorie, appliquées dans cet ordre :",
+		"categorize.configured":    " (configurée)",
+		"categorize.save_failed":   "Impossible d'enregistrer la règle : %v",
+		"categorize.saved":         "Enregistré : les reçus de %s sont désormais classés dans %s.",
+		"categorize.forget_failed": "Impossible d'oublier la règle : %v",
+		"categorize.forgot":        "Règle %d oubliée.",
+
+		"approval.request": "Cette action nécessite d'abord votre accord :\n%s\n\n" +
+			"Répondez avec la commande /approve ou /reject indiquée. Les demandes expirent après %d minutes.",
+		"approval.owner_request": "Une demande de %s nécessite votre accord :\n%s\n\n" +
+			"Répondez avec la commande /approve ou /reject indiquée. Les demandes expirent après %d minutes.",
+		"approval.waiting_owner": "Cette action nécessite l'accord du propriétaire. Je le lui ai demandé et " +
+			"je continuerai dès sa décision.",
+		"approval.unavailable": "L'approbation des outils n'est pas activée sur cette passerelle.",
+		"approval.usage":       "Utilisation : /approve [<id>] ou /reject [<id>]",
+		"approval.none":        "Rien n'attend votre accord.",
+		"approval.choose":      "Plusieurs demandes sont en attente ; ajoutez l'identifiant de celle que vous visez :",
+		"approval.not_found":   "La demande %s n'attend pas votre accord. Elle a peut-être expiré.",
+		"approval.approved":    "Demande %s approuvée ; la réponse a été envoyée à la conversation d'origine.",
+		"approval.rejected":    "Demande %s refusée ; la conversation d'origine a été prévenue.",
+
+		"workflow.usage": "Utilisation : /workflow [list|runs|run <nom>|approve <id>|reject <id>|" +
+			"resume <id>]",
+		"workflow.unavailable": "Les workflows ne sont pas activés sur cette passerelle.",
+		"workflow.none":        "Aucun workflow n'est défini.",
+		"workflow.list":        "Workflows :",
+		"workflow.runs":        "Exécutions récentes :",
+		"workflow.no_runs":     "Cette entreprise n'a encore aucune exécution de workflow.",
+		"workflow.started":     "Workflow %s démarré (exécution %s).",
+		"workflow.not_found": "Exécution %s introuvable. Utilisez /workflow runs pour voir les exécutions " +
+			"récentes.",
+		"workflow.decided":       "L'exécution %s est maintenant %s.",
+		"workflow.failed_action": "L'action sur le workflow a échoué : %v",
+		"workflow.approval": "Le workflow %s attend votre accord :\n%s\n\n" +
+			"Répondez /workflow approve %[3]s ou /workflow reject %[3]s.",
+		"workflow.failed": "Le workflow %s a échoué (exécution %s) : %s\n" +
+			"Corrigez la cause et répondez /workflow resume %[2]s.",
+
+		"link.usage": "Utilisation : /link [<code>|off]",
+		"link.unavailable": "La liaison des conversations du chat et de l'application " +
+			"n'est pas activée sur cette passerelle.",
+		"link.unsupported": "Seuls un chat privé ou l'application peuvent être liés.",
+		"link.failed":      "Échec de la liaison : %v",
+		"link.code":        "Envoyez /link %s depuis l'application ou votre autre chat dans les %d minutes pour les lier.",
+		"link.invalid":     "Ce code de liaison est erroné ou a expiré. Envoyez /link pour en obtenir un nouveau.",
+		"link.same_side": "Envoyez le code depuis l'autre côté : l'application si vous l'avez reçu dans un chat, " +
+			"ou un chat si vous l'avez reçu dans l'application.",
+		"link.linked":     "Lié. Votre conversation se poursuit désormais entre ce chat et l'application.",
+		"link.not_linked": "Rien n'est lié ici.",
+		"link.unlinked":   "Liaison supprimée. Les conversations du chat et de l'application sont de nouveau séparées.",
+
+		"heartbeat.usage":       "Utilisation : /heartbeat [on|off|status]",
+		"heartbeat.unavailable": "Les réglages du bilan ne sont pas disponibles",
+		"heartbeat.no_business": "Cette conversation n'est liée à aucune entreprise, il n'y a donc pas de bilan " +
+			"à modifier.",
+		"heartbeat.save_failed":   "Impossible d'enregistrer le réglage du bilan : %v",
+		"heartbeat.turned_on":     "Bilan activé pour l'entreprise %s.",
+		"heartbeat.turned_off":    "Bilan désactivé pour l'entreprise %s.",
+		"heartbeat.gateway_off":   "Les bilans sont désactivés sur cette passerelle.",
+		"heartbeat.is_off":        "Le bilan est désactivé pour l'entreprise %s.",
+		"heartbeat.is_on":         "Le bilan est activé pour l'entreprise %s, toutes les %d minutes",
+		"heartbeat.during":        " pendant %s",
+		"heartbeat.using_skill":   ", avec la compétence %s",
+		"heartbeat.end":           ".",
+		"heartbeat.morning":       "Bonjour",
+		"heartbeat.afternoon":     "Bon après-midi",
+		"heartbeat.evening":       "Bonsoir",
+		"heartbeat.salutation":    "%s, %s !",
+		"heartbeat.intro":         "Voici votre bilan du %s.",
+		"heartbeat.revenue":       "Revenus",
+		"heartbeat.expenses":      "Dépenses",
+		"heartbeat.safe_to_spend": "Disponible",
+		"heartbeat.tax_reserved":  "Taxes réservées",
+		"heartbeat.receivables":   "À recevoir",
+		"heartbeat.payables":      "À payer",
+		"heartbeat.needs_review":  "À vérifier",
+
+		"telegram.help": "/start - Démarrer le bot\n" +
+			"/help - Afficher cette aide\n" +
+			"/show [model|channel] - Afficher la configuration actuelle\n" +
+			"/list [models|channels] - Lister les options disponibles\n" +
+			"/status - Afficher l'état de l'agent et du stockage",
+		"telegram.start":         "Bonjour ! Je suis PicoClaw 🦞",
+		"telegram.show.usage":    "Utilisation : /show [model|channel]",
+		"telegram.show.model":    "Modèle actuel : %s (fournisseur : %s)",
+		"telegram.show.channel":  "Canal actuel : telegram",
+		"telegram.show.unknown":  "Paramètre inconnu : %s. Essayez « model » ou « channel ».",
+		"telegram.list.usage":    "Utilisation : /list [models|channels]",
+		"telegram.list.models":   "Modèle configuré : %s\nFournisseur : %s\n\nPour changer de modèle, modifiez config.yaml",
+		"telegram.list.default":  "par défaut",
+		"telegram.list.channels": "Canaux activés :\n- %s",
+		"telegram.list.unknown":  "Paramètre inconnu : %s. Essayez « models » ou « channels ».",
+
+		"api.unauthorized":         "non autorisé : %v",
+		"api.invalid_body":         "corps de requête invalide",
+		"webhook.invalid_debug":    "indicateur debug invalide",
+		"webhook.message_required": "un message ou un fichier est requis",
+		"guest.files_only":         "ce lien n'accepte que l'envoi de fichiers",
+		"guest.link_invalid":       "ce lien invité est invalide ou a expiré",
+		"nats.message_required":    "un message est requis",
+		"api.message_required":     "un message est requis",
+		"api.read_only":            "un jeton d'observation en lecture seule ne peut pas faire cela",
+		"webhook.maintenance":      "picoclaw est en maintenance",
+		"webhook.busy":             "trop de requêtes en cours, réessayez plus tard",
+		"upload.invalid_form":      "impossible de lire le formulaire multipart",
+		"upload.field_too_large":   "le champ %q est trop grand",
+		"upload.business_first":    "business_id doit précéder les fichiers envoyés",
+		"upload.too_large":         "le fichier envoyé est trop volumineux",
+
+		// Federation
+		"federation.origin_required": "origin et session sont requis",
+
+		"pair.code_required":     "l'en-tête X-Pairing-Code est requis",
+		"pair.code_used":         "code d'appairage déjà utilisé",
+		"pair.code_invalid":      "code d'appairage invalide",
+		"pair.success":           "appairage réussi",
+		"pair.name_required":     "le nom est requis et fait au plus 64 octets",
+		"pair.too_many_requests": "trop de demandes d'appairage en attente",
+		"pair.request_gone":      "la demande d'appairage a été refusée ou a expiré",
+	},
+	"de": {
+		"chat.maintenance": "PicoClaw wird gerade gewartet. Bitte versuchen Sie es später erneut.",
+		"chat.error":       "Fehler bei der Verarbeitung der Nachricht: %v",
+		"chat.filtered": "Ihre Nachricht wurde nicht an den Assistenten weitergeleitet, da sie " +
+			"in diesem Chat nicht erlaubte Inhalte enthält.",
+
+		"cmd.show.usage":        "Verwendung: /show [model|channel|agents]",
+		"cmd.show.unknown":      "Unbekanntes Ziel für /show: %s",
+		"cmd.list.usage":        "Verwendung: /list [models|channels|agents]",
+		"cmd.list.unknown":      "Unbekanntes Ziel für /list: %s",
+		"cmd.list.models":       "Verfügbare Modelle: pro Agent in config.json konfiguriert",
+		"cmd.switch.usage":      "Verwendung: /switch [model|channel] to <Name>",
+		"cmd.switch.unknown":    "Unbekanntes Ziel für /switch: %s",
+		"cmd.switch.model":      "Modell von %s zu %s gewechselt",
+		"cmd.switch.channel":    "Zielkanal zu %s gewechselt",
+		"cmd.channel_not_found": "Kanal „%s“ nicht gefunden oder nicht aktiviert",
+		"cmd.no_default_agent":  "Kein Standard-Agent konfiguriert",
+		"cmd.no_channels":       "Keine Kanäle aktiviert",
+		"cmd.no_channel_mgr":    "Kanalverwaltung nicht initialisiert",
+		"cmd.current_model":     "Aktuelles Modell: %s",
+		"cmd.current_channel":   "Aktueller Kanal: %s",
+		"cmd.agents":            "Registrierte Agenten: %s",
+		"cmd.channels":          "Aktivierte Kanäle: %s",
+		"cmd.debug.usage":       "Verwendung: /debug [on|off|status]",
+		"cmd.debug.on": "Debug-Modus für diese Sitzung aktiviert. Vollständige Prompts und Tool-Ein- und " +
+			"-Ausgaben werden nach %s geschrieben",
+		"cmd.debug.off":    "Debug-Modus für diese Sitzung deaktiviert.",
+		"cmd.debug.is_on":  "Debug-Modus ist für Sitzung %s aktiviert",
+		"cmd.debug.is_off": "Debug-Modus ist für Sitzung %s deaktiviert",
+
+		"forget.usage": "Verwendung: /forget [confirm]",
+		"forget.confirm": "Dies löscht Verlauf und Zusammenfassung dieser Unterhaltung, ihren Suchverlauf, " +
+			"ihre Dateien, Ereignisse, ihr Nachrichtenprotokoll und die Notizen, die sie im Gedächtnis " +
+			"gespeichert hat. Das lässt sich nicht rückgängig machen. Sende /forget confirm, um fortzufahren.",
+		"forget.nothing": "In dieser Unterhaltung gibt es nichts zu vergessen.",
+		"forget.failed":  "Diese Unterhaltung konnte nicht gelöscht werden: %v",
+		"forget.done": "Vergessen: %d Nachrichten, %d durchsuchbare Gesprächsrunden, %d Dateien und " +
+			"%d Ereignisse gelöscht (Beleg %s).",
+
+		"business.usage":         "Verwendung: /business [list|switch <id>]",
+		"business.none_selected": "Kein Unternehmen ausgewählt. Verwenden Sie /business list und /business switch <id>.",
+		"business.current":       "Belege aus diesem Chat gehen an das Unternehmen %s.",
+		"business.list_failed":   "Unternehmen konnten nicht aufgelistet werden: %v",
+		"business.none_found":    "Keine Unternehmen gefunden.",
+		"business.list":          "Unternehmen:",
+		"business.active":        " (aktiv)",
+		"business.not_found": "Unternehmen %s nicht gefunden. Mit /business list sehen Sie Ihre " +
+			"Unternehmen.",
+		"business.switch_failed": "Unternehmen konnte nicht gewechselt werden: %v",
+		"business.switched":      "Zu %s gewechselt. Belege aus diesem Chat gehen jetzt dorthin.",
+
+		"review.usage": "Verwendung: /review [list|confirm <id> [vendor=... amount=... date=... " +
+			"category=...]|discard <id>]",
+		"review.unavailable": "Die Belegprüfung ist für dieses Gateway nicht aktiviert.",
+		"review.no_business": "Dieser Chat ist mit keinem Unternehmen verknüpft und hat daher keine Belege " +
+			"zu prüfen.",
+		"review.list_failed": "Die zu prüfenden Belege konnten nicht aufgelistet werden: %v",
+		"review.none":        "Keine Belege warten auf Prüfung.",
+		"review.list":        "Belege, die auf Prüfung warten:",
+		"review.check":       "prüfen",
+		"review.sign_in": "Zum Bestätigen eines Belegs ist eine LedgerForge-Anmeldung nötig, die dieser " +
+			"Chat noch nicht hat.",
+		"review.not_found":      "Beleg %s wartet nicht auf Prüfung. Mit /review sehen Sie die Warteschlange.",
+		"review.confirm_failed": "Beleg %s konnte nicht bestätigt werden: %v",
+		"review.confirmed":      "Beleg %s bestätigt: Entwurf %s über %s %s von %s.",
+		"review.discard_failed": "Beleg %s konnte nicht verworfen werden: %v",
+		"review.discarded":      "Beleg %s verworfen; es wurde kein Entwurf erstellt.",
+
+		"categorize.usage": "Verwendung: /categorize [list|<Händler> as <Kategorie>|forget <n>]",
+		"categorize.no_business": "Dieser Chat ist mit keinem Unternehmen verknüpft und hat daher keine " +
+			"Kategorieregeln.",
+		"categorize.none":          "Dieses Unternehmen hat keine Kategorieregeln.",
+		"categorize.list":          "Kategorieregeln, in dieser Reihenfolge angewendet:",
+		"categorize.configured":    " (konfiguriert)",
+		"categorize.save_failed":   "Die Regel konnte nicht gespeichert werden: %v",
+		"categorize.saved":         "Gespeichert: Belege von %s werden jetzt als %s kategorisiert.",
+		"categorize.forget_failed": "Die Regel konnte nicht entfernt werden: %v",
+		"categorize.forgot":        "Regel %d entfernt.",
+
+		"approval.request": "Dafür brauche ich zuerst Ihre Freigabe:\n%s\n\n" +
+			"Antworten Sie mit dem angezeigten /approve- oder /reject-Befehl. Anfragen verfallen nach %d Minuten.",
+		"approval.owner_request": "Eine Anfrage von %s braucht Ihre Freigabe:\n%s\n\n" +
+			"Antworten Sie mit dem angezeigten /approve- oder /reject-Befehl. Anfragen verfallen nach %d Minuten.",
+		"approval.waiting_owner": "Dafür ist die Freigabe der Inhaberin oder des Inhabers nötig. Ich habe " +
+			"darum gebeten und mache weiter, sobald entschieden ist.",
+		"approval.unavailable": "Die Freigabe von Werkzeugen ist für dieses Gateway nicht aktiviert.",
+		"approval.usage":       "Verwendung: /approve [<ID>] oder /reject [<ID>]",
+		"approval.none":        "Nichts wartet auf Ihre Freigabe.",
+		"approval.choose":      "Mehrere Anfragen warten; geben Sie die ID der gemeinten an:",
+		"approval.not_found":   "Anfrage %s wartet nicht auf Ihre Freigabe. Vielleicht ist sie abgelaufen.",
+		"approval.approved":    "Anfrage %s freigegeben; die Antwort ging an den anfragenden Chat.",
+		"approval.rejected":    "Anfrage %s abgelehnt; der anfragende Chat wurde informiert.",
+
+		"workflow.usage": "Verwendung: /workflow [list|runs|run <Name>|approve <ID>|reject <ID>|" +
+			"resume <ID>]",
+		"workflow.unavailable":   "Workflows sind für dieses Gateway nicht aktiviert.",
+		"workflow.none":          "Es sind keine Workflows definiert.",
+		"workflow.list":          "Workflows:",
+		"workflow.runs":          "Letzte Workflow-Läufe:",
+		"workflow.no_runs":       "Dieses Unternehmen hat noch keine Workflow-Läufe.",
+		"workflow.started":       "Workflow %s gestartet (Lauf %s).",
+		"workflow.not_found":     "Workflow-Lauf %s nicht gefunden. Mit /workflow runs sehen Sie die letzten Läufe.",
+		"workflow.decided":       "Workflow-Lauf %s ist jetzt %s.",
+		"workflow.failed_action": "Workflow-Aktion fehlgeschlagen: %v",
+		"workflow.approval": "Workflow %s braucht Ihre Freigabe:\n%s\n\n" +
+			"Antworten Sie mit /workflow approve %[3]s oder /workflow reject %[3]s.",
+		"workflow.failed": "Workflow %s ist fehlgeschlagen (Lauf %s): %s\n" +
+			"Beheben Sie die Ursache und antworten Sie mit /workflow resume %[2]s.",
+
+		"link.usage":       "Verwendung: /link [<Code>|off]",
+		"link.unavailable": "Das Verknüpfen von Chat- und App-Unterhaltungen ist auf diesem Gateway nicht aktiviert.",
+		"link.unsupported": "Nur ein Direktchat oder die App kann verknüpft werden.",
+		"link.failed":      "Verknüpfen fehlgeschlagen: %v",
+		"link.code": "Sende /link %s innerhalb von %d Minuten aus der App oder deinem anderen Chat, " +
+			"um sie zu verknüpfen.",
+		"link.invalid": "Dieser Verknüpfungscode ist falsch oder abgelaufen. Sende /link für einen neuen.",
+		"link.same_side": "Sende den Code von der anderen Seite: aus der App, wenn du ihn im Chat bekommen hast, " +
+			"oder aus einem Chat, wenn du ihn in der App bekommen hast.",
+		"link.linked":     "Verknüpft. Deine Unterhaltung geht jetzt zwischen diesem Chat und der App weiter.",
+		"link.not_linked": "Hier ist nichts verknüpft.",
+		"link.unlinked":   "Verknüpfung aufgehoben. Chat- und App-Unterhaltungen sind wieder getrennt.",
+
+		"heartbeat.usage":         "Verwendung: /heartbeat [on|off|status]",
+		"heartbeat.unavailable":   "Heartbeat-Einstellungen sind nicht verfügbar",
+		"heartbeat.no_business":   "Dieser Chat ist mit keinem Unternehmen verknüpft und hat daher keinen Heartbeat.",
+		"heartbeat.save_failed":   "Die Heartbeat-Einstellung konnte nicht gespeichert werden: %v",
+		"heartbeat.turned_on":     "Heartbeat für Unternehmen %s aktiviert.",
+		"heartbeat.turned_off":    "Heartbeat für Unternehmen %s deaktiviert.",
+		"heartbeat.gateway_off":   "Heartbeats sind auf diesem Gateway deaktiviert.",
+		"heartbeat.is_off":        "Heartbeat ist für Unternehmen %s deaktiviert.",
+		"heartbeat.is_on":         "Heartbeat ist für Unternehmen %s aktiviert, alle %d Minuten",
+		"heartbeat.during":        " während %s",
+		"heartbeat.using_skill":   ", mit dem Skill %s",
+		"heartbeat.end":           ".",
+		"heartbeat.morning":       "Guten Morgen",
+		"heartbeat.afternoon":     "Guten Tag",
+		"heartbeat.evening":       "Guten Abend",
+		"heartbeat.salutation":    "%s, %s!",
+		"heartbeat.intro":         "Hier ist Ihr Heartbeat für %s.",
+		"heartbeat.revenue":       "Einnahmen",
+		"heartbeat.expenses":      "Ausgaben",
+		"heartbeat.safe_to_spend": "Verfügbar",
+		"heartbeat.tax_reserved":  "Steuerrücklage",
+		"heartbeat.receivables":   "Forderungen",
+		"heartbeat.payables":      "Verbindlichkeiten",
+		"heartbeat.needs_review":  "Zu prüfen",
+
+		"telegram.help": "/start - Bot starten\n" +
+			"/help - Diese Hilfe anzeigen\n" +
+			"/show [model|channel] - Aktuelle Konfiguration anzeigen\n" +
+			"/list [models|channels] - Verfügbare Optionen auflisten\n" +
+			"/status - Agent- und Speicherstatus anzeigen",
+		"telegram.start":        "Hallo! Ich bin PicoClaw 🦞",
+		"telegram.show.usage":   "Verwendung: /show [model|channel]",
+		"telegram.show.model":   "Aktuelles Modell: %s (Anbieter: %s)",
+		"telegram.show.channel": "Aktueller Kanal: telegram",
+		"telegram.show.unknown": "Unbekannter Parameter: %s. Versuchen Sie „model“ oder „channel“.",
+		"telegram.list.usage":   "Verwendung: /list [models|channels]",
+		"telegram.list.models": "Konfiguriertes Modell: %s\nAnbieter: %s\n\n" +
+			"Um das Modell zu ändern, bearbeiten Sie config.yaml",
+		"telegram.list.default":  "Standard",
+		"telegram.list.channels": "Aktivierte Kanäle:\n- %s",
+		"telegram.list.unknown":  "Unbekannter Parameter: %s. Versuchen Sie „models“ oder „channels“.",
+
+		"api.unauthorized":         "nicht autorisiert: %v",
+		"api.invalid_body":         "ungültiger Request-Body",
+		"webhook.invalid_debug":    "ungültiges debug-Flag",
+		"webhook.message_required": "Nachricht oder Datei erforderlich",
+		"guest.files_only":         "dieser Link nimmt nur Datei-Uploads an",
+		"guest.link_invalid":       "dieser Gastlink ist ungültig oder abgelaufen",
+		"nats.message_required":    "Nachricht erforderlich",
+		"api.message_required":     "Nachricht erforderlich",
+		"api.read_only":            "schreibgeschützte Beobachter-Tokens können das nicht",
+		"webhook.maintenance":      "picoclaw ist im Wartungsmodus",
+		"webhook.busy":             "zu viele laufende Anfragen, bitte später erneut versuchen",
+		"upload.invalid_form":      "Multipart-Formular konnte nicht gelesen werden",
+		"upload.field_too_large":   "Formularfeld %q ist zu groß",
+		"upload.business_first":    "business_id muss vor den hochgeladenen Dateien stehen",
+		"upload.too_large":         "hochgeladene Datei ist zu groß",
+
+		// Federation
+		"federation.origin_required": "origin und session sind erforderlich",
+
+		"pair.code_required":     "X-Pairing-Code-Header ist erforderlich",
+		"pair.code_used":         "Kopplungscode wurde bereits verwendet",
+		"pair.code_invalid":      "ungültiger Kopplungscode",
+		"pair.success":           "erfolgreich gekoppelt",
+		"pair.name_required":     "Name ist erforderlich und höchstens 64 Bytes lang",
+		"pair.too_many_requests": "zu viele offene Kopplungsanfragen",
+		"pair.request_gone":      "Kopplungsanfrage wurde abgelehnt oder ist abgelaufen",
+	},
+}
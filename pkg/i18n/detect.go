@@ -0,0 +1,119 @@
+package i18n
+
+import (
+	"strings"
+	"unicode"
+)
+
+// detectable are the languages Detect recognizes, with their most common
+// short words. A word may belong to several languages; it then counts for
+// each of them.
+var detectable = map[string][]string{
+	"en": {
+		"the", "and", "is", "are", "was", "what", "how", "my", "you", "to", "of", "for", "this", "that", "with",
+		"it", "i", "can", "please", "thanks", "hello", "hi", "do", "did", "have", "much", "me", "from", "in",
+	},
+	"fr": {
+		"le", "la", "les", "et", "est", "un", "une", "des", "du", "je", "tu", "vous", "nous", "pour", "avec",
+		"que", "qui", "quoi", "comment", "combien", "mon", "ma", "mes", "pas", "ce", "cette", "bonjour", "merci",
+		"salut", "sur", "dans", "au", "aux", "il", "elle", "peux", "est-ce", "c'est", "j'ai", "s'il", "plaît",
+	},
+	"de": {
+		"der", "die", "das", "und", "ist", "ein", "eine", "ich", "du", "sie", "wir", "nicht", "mit", "für",
+		"was", "wie", "viel", "mein", "meine", "bitte", "danke", "hallo", "auf", "den", "dem", "zu", "von",
+		"habe", "hast", "kannst", "können", "noch", "auch",
+	},
+	"es": {
+		"el", "la", "los", "las", "y", "es", "un", "una", "yo", "tú", "usted", "nosotros", "para", "con",
+		"que", "qué", "cómo", "cuánto", "cuántos", "mi", "mis", "no", "por", "favor", "hola", "gracias",
+		"del", "al", "en", "está", "puedes", "tengo",
+	},
+	"it": {
+		"il", "lo", "la", "gli", "le", "e", "è", "un", "una", "io", "tu", "noi", "per", "con", "che",
+		"come", "quanto", "mio", "mia", "non", "ciao", "grazie", "della", "del", "nel", "sono", "puoi", "ho",
+	},
+	"pt": {
+		"o", "a", "os", "as", "e", "é", "um", "uma", "eu", "você", "nós", "para", "com", "que", "como",
+		"quanto", "meu", "minha", "não", "olá", "obrigado", "obrigada", "do", "da", "no", "na", "está",
+		"pode", "tenho",
+	},
+	"nl": {
+		"de", "het", "een", "en", "is", "ik", "jij", "je", "wij", "niet", "met", "voor", "wat", "hoe",
+		"hoeveel", "mijn", "alsjeblieft", "bedankt", "dank", "hallo", "van", "op", "kun", "heb", "ook",
+	},
+}
+
+// detectableLetters are letters only some of the languages use, each giving
+// its languages one more point.
+var detectableLetters = map[rune][]string{
+	'ß': {"de"}, 'ä': {"de"}, 'ö': {"de"}, 'ü': {"de"},
+	'ç': {"fr", "pt"}, 'œ': {"fr"}, 'ê': {"fr", "pt"}, 'è': {"fr", "it"}, 'à': {"fr", "it", "pt"},
+	'ñ': {"es"}, '¿': {"es"}, '¡': {"es"},
+	'ã': {"pt"}, 'õ': {"pt"},
+}
+
+// languageNames are the English names of the detectable languages, for
+// prompts.
+var languageNames = map[string]string{
+	"en": "English",
+	"fr": "French",
+	"de": "German",
+	"es": "Spanish",
+	"it": "Italian",
+	"pt": "Portuguese",
+	"nl": "Dutch",
+}
+
+var detectWords map[string][]string
+
+func init() {
+	detectWords = make(map[string][]string)
+	for lang, words := range detectable {
+		for _, w := range words {
+			detectWords[w] = append(detectWords[w], lang)
+		}
+	}
+}
+
+// Detect guesses the language of text from its common words and letters,
+// and returns its code, such as "fr", or "" if text is too short or too
+// mixed to tell. It recognizes more languages than have a catalog.
+func Detect(text string) string {
+	scores := map[string]int{}
+	text = strings.ToLower(text)
+	for _, word := range strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\'' && r != '-'
+	}) {
+		for _, lang := range detectWords[strings.Trim(word, "'-")] {
+			scores[lang]++
+		}
+	}
+	for _, r := range text {
+		for _, lang := range detectableLetters[r] {
+			scores[lang]++
+		}
+	}
+
+	best, bestScore, runnerUp := "", 0, 0
+	for lang, score := range scores {
+		switch {
+		case score > bestScore:
+			best, bestScore, runnerUp = lang, score, bestScore
+		case score > runnerUp:
+			runnerUp = score
+		}
+	}
+	if bestScore == 0 || bestScore == runnerUp {
+		return ""
+	}
+	return best
+}
+
+// LanguageName returns the English name of a language code Detect returns
+// or a catalog language, or the code itself if it has none.
+func LanguageName(lang string) string {
+	if name, ok := languageNames[lang]; ok {
+		return name
+	}
+	return lang
+}
@@ -0,0 +1,84 @@
+// Package i18n holds the catalog of user-facing messages picoclaw sends
+// itself, such as command replies, API errors and heartbeat reports, in each
+// supported language. The agent's own replies are not translated here; the
+// model writes those.
+package i18n
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Default is the language used when none is chosen, and for messages a
+// language lacks.
+const Default = "en"
+
+// Supported lists the languages with a catalog.
+var Supported = []string{"en", "fr", "de"}
+
+// Match returns the supported language of a BCP 47 tag such as "fr-CA", or
+// "" if it is not supported.
+func Match(tag string) string {
+	lang, _, _ := strings.Cut(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"), "-")
+	lang = strings.ToLower(lang)
+	if _, ok := catalog[lang]; ok {
+		return lang
+	}
+	return ""
+}
+
+// FromAcceptLanguage returns the first supported language of an
+// Accept-Language header, or "". Quality values are not weighed; clients
+// list their languages in order of preference anyway.
+func FromAcceptLanguage(header string) string {
+	for _, part := range strings.Split(header, ",") {
+		tag, _, _ := strings.Cut(part, ";")
+		if lang := Match(tag); lang != "" {
+			return lang
+		}
+	}
+	return ""
+}
+
+// T returns the message key in lang, formatted with args like fmt.Sprintf.
+// Messages missing from lang are taken from English, and unknown keys are
+// returned as they are so a missing message is visible rather than empty.
+func T(lang, key string, args ...any) string {
+	msg, ok := catalog[Match(lang)][key]
+	if !ok {
+		if msg, ok = catalog[Default][key]; !ok {
+			return key
+		}
+	}
+	if len(args) == 0 {
+		return msg
+	}
+	return fmt.Sprintf(msg, args...)
+}
+
+// LongDate writes the weekday, day and month of t in lang, such as
+// "Monday, March 31", "lundi 31 mars" or "Montag, 31. März".
+func LongDate(lang string, t time.Time) string {
+	switch Match(lang) {
+	case "fr":
+		return fmt.Sprintf("%s %d %s", frenchDays[t.Weekday()], t.Day(), frenchMonths[t.Month()-1])
+	case "de":
+		return fmt.Sprintf("%s, %d. %s", germanDays[t.Weekday()], t.Day(), germanMonths[t.Month()-1])
+	default:
+		return t.Format("Monday, January 2")
+	}
+}
+
+var (
+	frenchDays   = []string{"dimanche", "lundi", "mardi", "mercredi", "jeudi", "vendredi", "samedi"}
+	frenchMonths = []string{
+		"janvier", "février", "mars", "avril", "mai", "juin",
+		"juillet", "août", "septembre", "octobre", "novembre", "décembre",
+	}
+	germanDays   = []string{"Sonntag", "Montag", "Dienstag", "Mittwoch", "Donnerstag", "Freitag", "Samstag"}
+	germanMonths = []string{
+		"Januar", "Februar", "März", "April", "Mai", "Juni",
+		"Juli", "August", "September", "Oktober", "November", "Dezember",
+	}
+)
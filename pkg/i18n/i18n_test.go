@@ -0,0 +1,78 @@
+package i18n
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var verb = regexp.MustCompile(`%[-+# 0-9.]*[a-zA-Z%]`)
+
+func TestCatalogIsComplete(t *testing.T) {
+	for _, lang := range Supported {
+		messages, ok := catalog[lang]
+		if !assert.True(t, ok, "no catalog for %s", lang) {
+			continue
+		}
+		for key, en := range catalog[Default] {
+			msg, ok := messages[key]
+			if assert.True(t, ok, "%s lacks %s", lang, key) {
+				assert.Equal(t, verb.FindAllString(en, -1), verb.FindAllString(msg, -1),
+					"%s %s must take the same arguments as in English", lang, key)
+			}
+		}
+		for key := range messages {
+			_, ok := catalog[Default][key]
+			assert.True(t, ok, "%s has %s, which English lacks", lang, key)
+		}
+	}
+}
+
+func TestT(t *testing.T) {
+	assert.Equal(t, "Heartbeat turned on for business b1.", T("en", "heartbeat.turned_on", "b1"))
+	assert.Equal(t, "Bilan activé pour l'entreprise b1.", T("fr-CA", "heartbeat.turned_on", "b1"))
+	assert.Equal(t, "Heartbeat für Unternehmen b1 aktiviert.", T("de", "heartbeat.turned_on", "b1"))
+	assert.Equal(t, "Heartbeat turned on for business b1.", T("es", "heartbeat.turned_on", "b1"))
+	assert.Equal(t, "no.such.key", T("fr", "no.such.key"))
+}
+
+func TestFromAcceptLanguage(t *testing.T) {
+	assert.Equal(t, "fr", FromAcceptLanguage("fr-CA,fr;q=0.9,en;q=0.8"))
+	assert.Equal(t, "de", FromAcceptLanguage("es-ES, de;q=0.5"))
+	assert.Equal(t, "", FromAcceptLanguage("es, *"))
+	assert.Equal(t, "", FromAcceptLanguage(""))
+}
+
+func TestLongDate(t *testing.T) {
+	d := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	assert.Equal(t, "Monday, March 2", LongDate("en", d))
+	assert.Equal(t, "lundi 2 mars", LongDate("fr", d))
+	assert.Equal(t, "Montag, 2. März", LongDate("de-AT", d))
+	assert.Equal(t, "Monday, March 2", LongDate("", d))
+}
+
+func TestDetect(t *testing.T) {
+	for text, want := range map[string]string{
+		"How much did I spend on fuel this month?":     "en",
+		"Combien j'ai dépensé en essence ce mois-ci ?": "fr",
+		"Bonjour": "fr",
+		"Wie viel habe ich diesen Monat für Benzin bezahlt?": "de",
+		"¿Cuánto gasté en gasolina este mes?":                "es",
+		"Quanto ho speso per la benzina questo mese?":        "it",
+		"Quanto eu gastei com gasolina este mês?":            "pt",
+		"Hoeveel heb ik deze maand aan benzine uitgegeven?":  "nl",
+		"ok":    "",
+		"42.50": "",
+		"":      "",
+		"la":    "",
+	} {
+		assert.Equal(t, want, Detect(text), text)
+	}
+}
+
+func TestLanguageName(t *testing.T) {
+	assert.Equal(t, "French", LanguageName("fr"))
+	assert.Equal(t, "xx", LanguageName("xx"))
+}
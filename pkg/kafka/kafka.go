@@ -0,0 +1,249 @@
+// Package kafka streams the event log to a Kafka topic, so activity can be
+// loaded into a data warehouse for analytics and compliance.
+//
+// Each event becomes one record whose value is a Record, keyed by session
+// (or business) so a conversation's events stay in order on one partition.
+// The streamer remembers the last event it delivered and resumes from there
+// after a restart or an outage. Delivery is at least once: consumers should
+// drop records whose device_id and seq they have already seen.
+//
+// It speaks the Kafka protocol itself and needs no client library.
+package kafka
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/eventlog"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// SchemaVersion is the version of Record. It changes only when a field is
+// removed or changes meaning; new fields may be added within a version.
+const SchemaVersion = 1
+
+// maxBatch is the most events sent in one produce request.
+const maxBatch = 500
+
+// Record is the value of each Kafka record, as JSON.
+type Record struct {
+	SchemaVersion int            `json:"schema_version"`
+	DeviceID      string         `json:"device_id"` // the installation that recorded it
+	Seq           uint64         `json:"seq"`
+	Time          time.Time      `json:"time"`
+	Type          string         `json:"type"`
+	RequestID     string         `json:"request_id,omitempty"`
+	AgentID       string         `json:"agent_id,omitempty"`
+	SessionKey    string         `json:"session_key,omitempty"`
+	Channel       string         `json:"channel,omitempty"`
+	ChatID        string         `json:"chat_id,omitempty"`
+	BusinessID    string         `json:"business_id,omitempty"`
+	Data          map[string]any `json:"data,omitempty"`
+}
+
+// Streamer sends new events of an event log to a Kafka topic.
+type Streamer struct {
+	log        *eventlog.Log
+	cfg        config.KafkaConfig
+	deviceID   string
+	cursorPath string
+	dialer     dialer
+
+	// Connections, by broker address, and the topic's partitions; dropped
+	// after any error.
+	conns map[string]*brokerConn
+	parts []partition
+}
+
+// New creates a streamer for l, whose progress is kept in
+// <workspace>/state/kafka_seq.
+func New(l *eventlog.Log, workspace, deviceID string, cfg config.KafkaConfig) (*Streamer, error) {
+	if len(cfg.Brokers) == 0 || cfg.Topic == "" {
+		return nil, errors.New("kafka needs brokers and a topic")
+	}
+	if cfg.IntervalSeconds <= 0 {
+		cfg.IntervalSeconds = 5
+	}
+	return &Streamer{
+		log:        l,
+		cfg:        cfg,
+		deviceID:   deviceID,
+		cursorPath: filepath.Join(workspace, "state", "kafka_seq"),
+		dialer:     dialer{tls: cfg.TLS, username: cfg.Username, password: cfg.Password},
+		conns:      make(map[string]*brokerConn),
+	}, nil
+}
+
+// Run sends new events every interval until ctx is done. Failures are
+// logged and retried on the next round.
+func (s *Streamer) Run(ctx context.Context) {
+	defer s.reset()
+	ticker := time.NewTicker(time.Duration(s.cfg.IntervalSeconds) * time.Second)
+	defer ticker.Stop()
+	for {
+		if _, err := s.Flush(ctx); err != nil {
+			logger.WarnCF("kafka", "Streaming events to Kafka failed", map[string]any{"error": err.Error()})
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Flush sends the events recorded since the last call and returns how many
+// it sent.
+func (s *Streamer) Flush(ctx context.Context) (int, error) {
+	since := s.cursor()
+	if since > s.log.LastSeq() {
+		since = 0 // the event log was started over
+	}
+	sent := 0
+	for {
+		var batch []eventlog.Event
+		err := s.log.Read(since, func(e eventlog.Event) error {
+			batch = append(batch, e)
+			if len(batch) == maxBatch {
+				return errBatchFull
+			}
+			return nil
+		})
+		if err != nil && err != errBatchFull {
+			return sent, err
+		}
+		if len(batch) == 0 {
+			return sent, nil
+		}
+		if err := s.send(ctx, batch); err != nil {
+			s.reset()
+			return sent, err
+		}
+		since = batch[len(batch)-1].Seq
+		sent += len(batch)
+		if err := s.saveCursor(since); err != nil {
+			return sent, err
+		}
+	}
+}
+
+var errBatchFull = errors.New("batch full")
+
+// send produces events to the partitions of their keys.
+func (s *Streamer) send(ctx context.Context, events []eventlog.Event) error {
+	if s.parts == nil {
+		c, err := s.bootstrap(ctx)
+		if err != nil {
+			return err
+		}
+		if s.parts, err = c.metadata(s.cfg.Topic); err != nil {
+			return err
+		}
+	}
+
+	byPartition := make(map[int][]record)
+	for _, e := range events {
+		value, err := json.Marshal(Record{
+			SchemaVersion: SchemaVersion,
+			DeviceID:      s.deviceID,
+			Seq:           e.Seq,
+			Time:          e.Time,
+			Type:          e.Type,
+			RequestID:     e.RequestID,
+			AgentID:       e.AgentID,
+			SessionKey:    e.SessionKey,
+			Channel:       e.Channel,
+			ChatID:        e.ChatID,
+			BusinessID:    e.BusinessID,
+			Data:          e.Data,
+		})
+		if err != nil {
+			return fmt.Errorf("encoding event %d: %w", e.Seq, err)
+		}
+		var key []byte
+		i := int(e.Seq % uint64(len(s.parts)))
+		if k := cmp.Or(e.SessionKey, e.BusinessID); k != "" {
+			key = []byte(k)
+			h := fnv.New32a()
+			h.Write(key)
+			i = int(h.Sum32() % uint32(len(s.parts)))
+		}
+		byPartition[i] = append(byPartition[i], record{key: key, value: value, time: e.Time})
+	}
+
+	for i, records := range byPartition {
+		p := s.parts[i]
+		c, err := s.conn(ctx, p.leader)
+		if err != nil {
+			return err
+		}
+		if err := c.produce(s.cfg.Topic, p.id, records); err != nil {
+			return fmt.Errorf("producing to %s/%d: %w", s.cfg.Topic, p.id, err)
+		}
+	}
+	return nil
+}
+
+// bootstrap connects to the first configured broker that answers.
+func (s *Streamer) bootstrap(ctx context.Context) (*brokerConn, error) {
+	var err error
+	for _, addr := range s.cfg.Brokers {
+		var c *brokerConn
+		if c, err = s.conn(ctx, addr); err == nil {
+			return c, nil
+		}
+	}
+	return nil, err
+}
+
+func (s *Streamer) conn(ctx context.Context, addr string) (*brokerConn, error) {
+	if c := s.conns[addr]; c != nil {
+		return c, nil
+	}
+	c, err := s.dialer.dial(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	s.conns[addr] = c
+	return c, nil
+}
+
+// reset closes the connections and forgets the partitions, which may have
+// moved.
+func (s *Streamer) reset() {
+	for addr, c := range s.conns {
+		c.Close()
+		delete(s.conns, addr)
+	}
+	s.parts = nil
+}
+
+func (s *Streamer) cursor() uint64 {
+	data, err := os.ReadFile(s.cursorPath)
+	if err != nil {
+		return 0
+	}
+	seq, _ := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	return seq
+}
+
+func (s *Streamer) saveCursor(seq uint64) error {
+	if err := os.MkdirAll(filepath.Dir(s.cursorPath), 0o755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	tmp := s.cursorPath + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.FormatUint(seq, 10)+"\n"), 0o644); err != nil {
+		return fmt.Errorf("failed to save Kafka progress: %w", err)
+	}
+	return os.Rename(tmp, s.cursorPath)
+}
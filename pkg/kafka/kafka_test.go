@@ -0,0 +1,238 @@
+package kafka
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/eventlog"
+)
+
+// fakeBroker is a one-node cluster whose topic has two partitions.
+type fakeBroker struct {
+	addr string
+
+	mu       sync.Mutex
+	produced map[int32][]Record
+	keys     map[int32][]string
+	down     bool
+}
+
+func startFakeBroker(t *testing.T) *fakeBroker {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+	b := &fakeBroker{addr: ln.Addr().String(), produced: make(map[int32][]Record), keys: make(map[int32][]string)}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go b.serve(t, c)
+		}
+	}()
+	return b
+}
+
+func (b *fakeBroker) serve(t *testing.T, c net.Conn) {
+	defer c.Close()
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(c, size[:]); err != nil {
+			return
+		}
+		req := make([]byte, binary.BigEndian.Uint32(size[:]))
+		if _, err := io.ReadFull(c, req); err != nil {
+			return
+		}
+		d := decoder{buf: req}
+		apiKey, _ := d.int16(), d.int16()
+		cid := d.int32()
+		d.string() // client id
+
+		var e encoder
+		e.int32(0)
+		e.int32(cid)
+		switch apiKey {
+		case apiMetadata:
+			host, port, _ := net.SplitHostPort(b.addr)
+			p, _ := strconv.Atoi(port)
+			e.int32(1)
+			e.int32(7)
+			e.string(host)
+			e.int32(int32(p))
+			e.int16(-1)
+			e.int32(7)
+			e.int32(1)
+			e.int16(0)
+			e.string("picoclaw.events")
+			e.buf = append(e.buf, 0)
+			e.int32(2)
+			for id := range int32(2) {
+				e.int16(0)
+				e.int32(id)
+				e.int32(7)
+				e.int32(1)
+				e.int32(7)
+				e.int32(1)
+				e.int32(7)
+			}
+		case apiProduce:
+			b.mu.Lock()
+			down := b.down
+			b.mu.Unlock()
+			if down {
+				return
+			}
+			d.int16()
+			d.int16()
+			d.int32()
+			d.int32()
+			topic := d.string()
+			d.int32()
+			partition := d.int32()
+			batch := d.take(int(d.int32()))
+			require.NoError(t, d.err)
+			b.record(t, partition, batch)
+			e.int32(1)
+			e.string(topic)
+			e.int32(1)
+			e.int32(partition)
+			e.int16(0)
+			e.int64(0)
+			e.int64(-1)
+			e.int32(0)
+		}
+		binary.BigEndian.PutUint32(e.buf, uint32(len(e.buf)-4))
+		c.Write(e.buf)
+	}
+}
+
+// record decodes a record batch, checking its CRC.
+func (b *fakeBroker) record(t *testing.T, partition int32, batch []byte) {
+	d := decoder{buf: batch}
+	d.int64()
+	assert.Equal(t, len(batch)-12, int(d.int32()), "batch length")
+	d.int32()
+	assert.Equal(t, byte(2), d.take(1)[0], "magic")
+	crc := uint32(d.int32())
+	assert.Equal(t, crc32.Checksum(d.buf, castagnoli), crc, "crc")
+	d.take(2 + 4 + 8 + 8 + 8 + 2 + 4)
+	n := d.int32()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	buf := d.buf
+	varint := func() int64 {
+		v, k := binary.Varint(buf)
+		buf = buf[k:]
+		return v
+	}
+	for range n {
+		varint() // length
+		buf = buf[1:]
+		varint()
+		varint()
+		key := ""
+		if k := varint(); k >= 0 {
+			key, buf = string(buf[:k]), buf[k:]
+		}
+		v := varint()
+		var rec Record
+		assert.NoError(t, json.Unmarshal(buf[:v], &rec))
+		buf = buf[v:]
+		varint()
+		b.produced[partition] = append(b.produced[partition], rec)
+		b.keys[partition] = append(b.keys[partition], key)
+	}
+}
+
+func (b *fakeBroker) all() []Record {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var out []Record
+	for _, recs := range b.produced {
+		out = append(out, recs...)
+	}
+	return out
+}
+
+func TestStreamer(t *testing.T) {
+	b := startFakeBroker(t)
+	workspace := t.TempDir()
+	l, err := eventlog.Open(workspace, eventlog.Options{})
+	require.NoError(t, err)
+	defer l.Close()
+
+	_, err = New(l, workspace, "dev-1", config.KafkaConfig{Topic: "picoclaw.events"})
+	assert.Error(t, err, "brokers are required")
+
+	s, err := New(l, workspace, "dev-1", config.KafkaConfig{
+		Brokers: config.FlexibleStringSlice{"127.0.0.1:1", b.addr},
+		Topic:   "picoclaw.events",
+	})
+	require.NoError(t, err)
+
+	for _, key := range []string{"telegram:1", "telegram:1", "api:2"} {
+		_, err := l.Append(eventlog.Event{
+			Type:       eventlog.TypeRequestReceived,
+			SessionKey: key,
+			Data:       map[string]any{"content": "hi"},
+		})
+		require.NoError(t, err)
+	}
+	_, err = l.Append(eventlog.Event{Type: eventlog.TypeTransactionCreated, BusinessID: "biz-1"})
+	require.NoError(t, err)
+
+	n, err := s.Flush(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, 4, n)
+	recs := b.all()
+	require.Len(t, recs, 4)
+	for _, rec := range recs {
+		assert.Equal(t, SchemaVersion, rec.SchemaVersion)
+		assert.Equal(t, "dev-1", rec.DeviceID)
+	}
+	// A session's events are on one partition, in order.
+	for p, keys := range b.keys {
+		for i, key := range keys {
+			if key == "telegram:1" {
+				assert.Equal(t, uint64(i+1), b.produced[p][i].Seq)
+			}
+		}
+	}
+
+	// Nothing is sent twice, and progress survives a restart.
+	n, err = s.Flush(t.Context())
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	_, err = l.Append(eventlog.Event{Type: eventlog.TypeResponseSent, SessionKey: "api:2"})
+	require.NoError(t, err)
+	s, err = New(l, workspace, "dev-1",
+		config.KafkaConfig{Brokers: config.FlexibleStringSlice{b.addr}, Topic: "picoclaw.events"})
+	require.NoError(t, err)
+
+	// While the broker fails, events wait.
+	b.mu.Lock()
+	b.down = true
+	b.mu.Unlock()
+	_, err = s.Flush(t.Context())
+	assert.Error(t, err)
+	b.mu.Lock()
+	b.down = false
+	b.mu.Unlock()
+	n, err = s.Flush(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Len(t, b.all(), 5)
+}
@@ -0,0 +1,351 @@
+package kafka
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Kafka API keys and the versions used, old enough for any broker since
+// 0.11 and still supported by 4.x.
+const (
+	apiProduce          = 0
+	apiMetadata         = 3
+	apiSaslHandshake    = 17
+	apiSaslAuthenticate = 36
+
+	produceVersion  = 3
+	metadataVersion = 1
+)
+
+// timeout bounds connecting and each request.
+const timeout = 30 * time.Second
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// brokerError is an error code returned by a broker.
+type brokerError int16
+
+func (e brokerError) Error() string {
+	switch e {
+	case 3:
+		return "unknown topic or partition"
+	case 6:
+		return "not leader for partition"
+	case 7:
+		return "request timed out"
+	case 29:
+		return "topic authorization failed"
+	case 58:
+		return "SASL authentication failed"
+	}
+	return "Kafka error " + strconv.Itoa(int(e))
+}
+
+// dialer connects to brokers with the cluster's TLS and SASL settings.
+type dialer struct {
+	tls                bool
+	username, password string // SASL/PLAIN, if username is set
+}
+
+// brokerConn is one connection to a broker.
+type brokerConn struct {
+	nc  net.Conn
+	r   *bufio.Reader
+	mu  sync.Mutex
+	cid int32
+}
+
+func (d dialer) dial(ctx context.Context, addr string) (*brokerConn, error) {
+	nd := &net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}
+	var nc net.Conn
+	var err error
+	if d.tls {
+		host, _, _ := net.SplitHostPort(addr)
+		nc, err = (&tls.Dialer{NetDialer: nd, Config: &tls.Config{ServerName: host}}).DialContext(ctx, "tcp", addr)
+	} else {
+		nc, err = nd.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("connecting to Kafka broker %s: %w", addr, err)
+	}
+	c := &brokerConn{nc: nc, r: bufio.NewReader(nc)}
+	if d.username != "" {
+		if err := c.authenticate(d.username, d.password); err != nil {
+			nc.Close()
+			return nil, fmt.Errorf("kafka broker %s: %w", addr, err)
+		}
+	}
+	return c, nil
+}
+
+// authenticate signs in with SASL/PLAIN.
+func (c *brokerConn) authenticate(username, password string) error {
+	var e encoder
+	e.string("PLAIN")
+	resp, err := c.roundTrip(apiSaslHandshake, 1, e.buf)
+	if err != nil {
+		return err
+	}
+	d := decoder{buf: resp}
+	if code := d.int16(); code != 0 {
+		return fmt.Errorf("SASL/PLAIN not enabled: %w", brokerError(code))
+	}
+
+	e = encoder{}
+	e.bytes([]byte("\x00" + username + "\x00" + password))
+	if resp, err = c.roundTrip(apiSaslAuthenticate, 0, e.buf); err != nil {
+		return err
+	}
+	d = decoder{buf: resp}
+	if code := d.int16(); code != 0 {
+		if msg := d.nullableString(); msg != "" {
+			return errors.New(msg)
+		}
+		return brokerError(code)
+	}
+	return d.err
+}
+
+// roundTrip sends a request and returns the body of its response.
+func (c *brokerConn) roundTrip(apiKey, version int16, body []byte) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cid++
+	var e encoder
+	e.int32(0) // size, set below
+	e.int16(apiKey)
+	e.int16(version)
+	e.int32(c.cid)
+	e.string("picoclaw")
+	e.buf = append(e.buf, body...)
+	binary.BigEndian.PutUint32(e.buf, uint32(len(e.buf)-4))
+
+	c.nc.SetDeadline(time.Now().Add(timeout))
+	if _, err := c.nc.Write(e.buf); err != nil {
+		return nil, err
+	}
+	var header [8]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		return nil, err
+	}
+	size := int32(binary.BigEndian.Uint32(header[:4]))
+	if size < 4 || size > 64<<20 {
+		return nil, fmt.Errorf("bad Kafka response size %d", size)
+	}
+	if cid := int32(binary.BigEndian.Uint32(header[4:])); cid != c.cid {
+		return nil, fmt.Errorf("kafka response for request %d, expected %d", cid, c.cid)
+	}
+	resp := make([]byte, size-4)
+	_, err := io.ReadFull(c.r, resp)
+	return resp, err
+}
+
+func (c *brokerConn) Close() error {
+	return c.nc.Close()
+}
+
+// partition is a topic partition and the broker leading it.
+type partition struct {
+	id     int32
+	leader string // host:port
+}
+
+// metadata returns the partitions of topic.
+func (c *brokerConn) metadata(topic string) ([]partition, error) {
+	var e encoder
+	e.int32(1)
+	e.string(topic)
+	resp, err := c.roundTrip(apiMetadata, metadataVersion, e.buf)
+	if err != nil {
+		return nil, err
+	}
+
+	d := decoder{buf: resp}
+	brokers := make(map[int32]string)
+	for n := d.int32(); n > 0 && d.err == nil; n-- {
+		id := d.int32()
+		host := d.string()
+		port := d.int32()
+		d.nullableString() // rack
+		brokers[id] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+	d.int32() // controller
+	var parts []partition
+	for n := d.int32(); n > 0 && d.err == nil; n-- {
+		code := d.int16()
+		name := d.string()
+		d.bool() // internal
+		for m := d.int32(); m > 0 && d.err == nil; m-- {
+			d.int16() // partition error, e.g. leader not available
+			p := partition{id: d.int32(), leader: brokers[d.int32()]}
+			d.int32Array() // replicas
+			d.int32Array() // in-sync replicas
+			if name == topic && p.leader != "" {
+				parts = append(parts, p)
+			}
+		}
+		if name == topic && code != 0 {
+			return nil, fmt.Errorf("topic %s: %w", topic, brokerError(code))
+		}
+	}
+	if d.err != nil {
+		return nil, fmt.Errorf("bad Kafka metadata response: %w", d.err)
+	}
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("topic %s has no partition with a leader", topic)
+	}
+	return parts, nil
+}
+
+// record is one Kafka record.
+type record struct {
+	key, value []byte
+	time       time.Time
+}
+
+// produce writes records to a partition of topic, waiting for all in-sync
+// replicas to have them.
+func (c *brokerConn) produce(topic string, partition int32, records []record) error {
+	var e encoder
+	e.int16(-1) // no transactional id
+	e.int16(-1) // acks: all
+	e.int32(int32(timeout / time.Millisecond))
+	e.int32(1)
+	e.string(topic)
+	e.int32(1)
+	e.int32(partition)
+	e.bytes(recordBatch(records))
+	resp, err := c.roundTrip(apiProduce, produceVersion, e.buf)
+	if err != nil {
+		return err
+	}
+
+	d := decoder{buf: resp}
+	for n := d.int32(); n > 0 && d.err == nil; n-- {
+		d.string()
+		for m := d.int32(); m > 0 && d.err == nil; m-- {
+			d.int32()
+			if code := d.int16(); code != 0 {
+				return brokerError(code)
+			}
+			d.int64() // base offset
+			d.int64() // log append time
+		}
+	}
+	return d.err
+}
+
+// recordBatch encodes records as a v2 record batch, uncompressed.
+func recordBatch(records []record) []byte {
+	base := records[0].time.UnixMilli()
+	maxTime := base
+	var body []byte
+	for i, r := range records {
+		ts := r.time.UnixMilli()
+		maxTime = max(maxTime, ts)
+		var rec []byte
+		rec = append(rec, 0) // attributes
+		rec = binary.AppendVarint(rec, ts-base)
+		rec = binary.AppendVarint(rec, int64(i))
+		if r.key == nil {
+			rec = binary.AppendVarint(rec, -1)
+		} else {
+			rec = binary.AppendVarint(rec, int64(len(r.key)))
+			rec = append(rec, r.key...)
+		}
+		rec = binary.AppendVarint(rec, int64(len(r.value)))
+		rec = append(rec, r.value...)
+		rec = binary.AppendVarint(rec, 0) // headers
+		body = binary.AppendVarint(body, int64(len(rec)))
+		body = append(body, rec...)
+	}
+
+	// The CRC covers everything from the attributes on.
+	var tail encoder
+	tail.int16(0) // attributes: no compression
+	tail.int32(int32(len(records) - 1))
+	tail.int64(base)
+	tail.int64(maxTime)
+	tail.int64(-1) // producer id
+	tail.int16(-1) // producer epoch
+	tail.int32(-1) // base sequence
+	tail.int32(int32(len(records)))
+	tail.buf = append(tail.buf, body...)
+
+	var e encoder
+	e.int64(0)                                // base offset
+	e.int32(int32(4 + 1 + 4 + len(tail.buf))) // batch length
+	e.int32(-1)                               // partition leader epoch
+	e.buf = append(e.buf, 2)                  // magic
+	e.int32(int32(crc32.Checksum(tail.buf, castagnoli)))
+	return append(e.buf, tail.buf...)
+}
+
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) int16(v int16) { e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(v)) }
+func (e *encoder) int32(v int32) { e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(v)) }
+func (e *encoder) int64(v int64) { e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(v)) }
+
+func (e *encoder) string(s string) {
+	e.int16(int16(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *encoder) bytes(b []byte) {
+	e.int32(int32(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+// decoder reads a response, remembering the first error.
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) take(n int) []byte {
+	if d.err != nil || n < 0 || n > len(d.buf) {
+		if d.err == nil {
+			d.err = io.ErrUnexpectedEOF
+		}
+		return make([]byte, max(n, 8))
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *decoder) bool() bool   { return d.take(1)[0] != 0 }
+func (d *decoder) int16() int16 { return int16(binary.BigEndian.Uint16(d.take(2))) }
+func (d *decoder) int32() int32 { return int32(binary.BigEndian.Uint32(d.take(4))) }
+func (d *decoder) int64() int64 { return int64(binary.BigEndian.Uint64(d.take(8))) }
+
+func (d *decoder) string() string {
+	return string(d.take(int(d.int16())))
+}
+
+func (d *decoder) nullableString() string {
+	n := d.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(d.take(int(n)))
+}
+
+func (d *decoder) int32Array() {
+	for n := d.int32(); n > 0 && d.err == nil; n-- {
+		d.int32()
+	}
+}
@@ -0,0 +1,117 @@
+// Package leader elects one of several gateways sharing a database to run
+// the work that must happen once, such as heartbeat and scheduled jobs,
+// while every instance keeps serving webhooks and channels.
+//
+// The leader holds a lease that it renews well before it expires. If the
+// leader stops or loses the database, the lease expires and another
+// instance takes it over.
+package leader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// Lease is a lock with an expiry shared by the instances, such as a row in
+// Postgres.
+type Lease interface {
+	// Acquire takes or extends the lease for holder until ttl from now and
+	// reports whether holder has it.
+	Acquire(ctx context.Context, holder string, ttl time.Duration) (bool, error)
+	// Release gives up the lease if holder has it.
+	Release(ctx context.Context, holder string) error
+}
+
+// Elector campaigns for a lease on behalf of one instance.
+type Elector struct {
+	lease  Lease
+	id     string
+	ttl    time.Duration
+	leader atomic.Bool
+}
+
+// New returns an Elector for the instance id. The lease is held for ttl at
+// a time and renewed every third of it.
+func New(lease Lease, id string, ttl time.Duration) *Elector {
+	return &Elector{lease: lease, id: id, ttl: ttl}
+}
+
+// InstanceID returns an ID for this process that differs between hosts and
+// restarts: the hostname and process ID.
+func InstanceID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "picoclaw"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// ID returns the instance ID the elector campaigns as.
+func (e *Elector) ID() string {
+	return e.id
+}
+
+// IsLeader reports whether this instance holds the lease. A nil Elector,
+// used when there is no election, is always the leader.
+func (e *Elector) IsLeader() bool {
+	if e == nil {
+		return true
+	}
+	return e.leader.Load()
+}
+
+// Run campaigns until ctx is done, then releases the lease if it holds it.
+func (e *Elector) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+	for {
+		e.campaign(ctx)
+		select {
+		case <-ctx.Done():
+			e.Resign()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// campaign acquires or renews the lease once. Any error gives up leadership
+// at once: the lease may expire before the next renewal, and two leaders
+// are worse than none for a moment.
+func (e *Elector) campaign(ctx context.Context) {
+	attemptCtx, cancel := context.WithTimeout(ctx, e.ttl/3)
+	defer cancel()
+	ok, err := e.lease.Acquire(attemptCtx, e.id, e.ttl)
+	if err != nil {
+		if ctx.Err() == nil {
+			logger.WarnCF("leader", "Lease renewal failed", map[string]any{"error": err.Error()})
+		}
+		ok = false
+	}
+	if was := e.leader.Swap(ok); was != ok {
+		if ok {
+			logger.InfoCF("leader", "Became leader", map[string]any{"instance": e.id})
+		} else {
+			logger.InfoCF("leader", "No longer leader", map[string]any{"instance": e.id})
+		}
+	}
+}
+
+// Resign gives up leadership and releases the lease, so another instance
+// takes over without waiting for it to expire. Call it after stopping the
+// work only the leader does.
+func (e *Elector) Resign() {
+	if e == nil || !e.leader.Swap(false) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := e.lease.Release(ctx, e.id); err != nil {
+		logger.WarnCF("leader", "Failed to release lease", map[string]any{"error": err.Error()})
+	}
+}
@@ -0,0 +1,87 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// memLease is a Lease in memory.
+type memLease struct {
+	mu      sync.Mutex
+	holder  string
+	expires time.Time
+	fail    bool
+}
+
+func (l *memLease) Acquire(_ context.Context, holder string, ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.fail {
+		return false, errors.New("database unreachable")
+	}
+	if l.holder == holder || l.holder == "" || time.Now().After(l.expires) {
+		l.holder, l.expires = holder, time.Now().Add(ttl)
+		return true, nil
+	}
+	return false, nil
+}
+
+func (l *memLease) Release(_ context.Context, holder string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.holder == holder {
+		l.holder = ""
+	}
+	return nil
+}
+
+func (l *memLease) setFail(fail bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.fail = fail
+}
+
+func TestNilElectorIsLeader(t *testing.T) {
+	var e *Elector
+	assert.True(t, e.IsLeader())
+}
+
+func TestOneLeaderAndFailover(t *testing.T) {
+	lease := &memLease{}
+	a := New(lease, "a", 30*time.Millisecond)
+	b := New(lease, "b", 30*time.Millisecond)
+
+	ctxA, stopA := context.WithCancel(context.Background())
+	defer stopA()
+	go a.Run(ctxA)
+	assert.Eventually(t, a.IsLeader, time.Second, 5*time.Millisecond)
+
+	ctxB, stopB := context.WithCancel(context.Background())
+	defer stopB()
+	go b.Run(ctxB)
+	time.Sleep(50 * time.Millisecond)
+	assert.True(t, a.IsLeader())
+	assert.False(t, b.IsLeader(), "only one instance leads")
+
+	stopA()
+	assert.Eventually(t, func() bool { return !a.IsLeader() && b.IsLeader() }, time.Second, 5*time.Millisecond)
+}
+
+func TestLeadershipDroppedOnError(t *testing.T) {
+	lease := &memLease{}
+	e := New(lease, "a", 30*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go e.Run(ctx)
+	assert.Eventually(t, e.IsLeader, time.Second, 5*time.Millisecond)
+
+	lease.setFail(true)
+	assert.Eventually(t, func() bool { return !e.IsLeader() }, time.Second, 5*time.Millisecond)
+	lease.setFail(false)
+	assert.Eventually(t, e.IsLeader, time.Second, 5*time.Millisecond)
+}
@@ -0,0 +1,173 @@
+package ledgerforge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Business is a business the user owns.
+type Business struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Province  string    `json:"province,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Summary is a business's dashboard: cash position, tax and receivables.
+// Amounts are decimal strings, as LedgerForge sends them.
+type Summary struct {
+	TotalRevenue           string         `json:"total_revenue"`
+	TotalExpenses          string         `json:"total_expenses"`
+	TaxReserved            string         `json:"tax_reserved"`
+	SafeToSpend            string         `json:"safe_to_spend"`
+	OutstandingReceivables string         `json:"outstanding_receivables"`
+	OutstandingPayables    string         `json:"outstanding_payables"`
+	ExceptionsCount        int            `json:"exceptions_count"`
+	TransactionsCount      int            `json:"transactions_count"`
+	StatusCounts           map[string]int `json:"status_counts"`
+}
+
+// Businesses lists the businesses of the user in ctx.
+func (c *Client) Businesses(ctx context.Context) ([]Business, error) {
+	var businesses []Business
+	if err := c.Do(ctx, http.MethodGet, "/api/v1/businesses", nil, &businesses); err != nil {
+		return nil, err
+	}
+	return businesses, nil
+}
+
+// Summary returns the dashboard of the business in ctx.
+func (c *Client) Summary(ctx context.Context) (*Summary, error) {
+	path, err := BusinessPath(ctx, "/transactions/summary")
+	if err != nil {
+		return nil, err
+	}
+	var s Summary
+	if err := c.Do(ctx, http.MethodGet, path, nil, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// OCRData is what LedgerForge reads from a receipt image or PDF. Amounts
+// are as printed, and may be missing. Confidence is how sure OCR is of what
+// it read, from 0 to 1, and FieldConfidence the same by field ("vendor",
+// "amount", "date"); zero means it wasn't reported.
+type OCRData struct {
+	RawText         string             `json:"raw_text"`
+	Vendor          string             `json:"vendor"`
+	Amount          FlexString         `json:"amount"`
+	Date            string             `json:"date"`
+	TaxAmounts      *TaxAmounts        `json:"tax_amounts"`
+	Confidence      float64            `json:"confidence"`
+	FieldConfidence map[string]float64 `json:"field_confidence"`
+}
+
+// TaxAmounts are the sales taxes on a receipt.
+type TaxAmounts struct {
+	GST FlexString `json:"gst"`
+	PST FlexString `json:"pst"`
+}
+
+// FlexString accepts a JSON string or number, as OCR output has either.
+type FlexString string
+
+func (f *FlexString) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*f = FlexString(s)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return err
+	}
+	*f = FlexString(n.String())
+	return nil
+}
+
+// Transaction is a LedgerForge transaction. Expenses have negative amounts.
+type Transaction struct {
+	ID                  string  `json:"id,omitempty"`
+	VendorName          string  `json:"vendor_name"`
+	Amount              string  `json:"amount"`
+	Currency            string  `json:"currency,omitempty"`
+	Description         string  `json:"description,omitempty"`
+	TransactionDate     string  `json:"transaction_date"`
+	Category            string  `json:"category,omitempty"`
+	Classification      string  `json:"classification,omitempty"`
+	Status              string  `json:"status,omitempty"`
+	GSTAmount           string  `json:"gst_amount,omitempty"`
+	PSTAmount           string  `json:"pst_amount,omitempty"`
+	SourceDevice        string  `json:"source_device,omitempty"`
+	AISuggestedCategory string  `json:"ai_suggested_category,omitempty"`
+	AIConfidence        float64 `json:"ai_confidence,omitempty"`
+}
+
+// CategorySuggestion is LedgerForge's guess at a transaction's category.
+type CategorySuggestion struct {
+	Category   string  `json:"category"`
+	Confidence float64 `json:"confidence"`
+}
+
+// ExtractReceipt reads a receipt with LedgerForge's OCR, without saving it.
+func (c *Client) ExtractReceipt(ctx context.Context, filename string, data []byte) (*OCRData, error) {
+	path, err := BusinessPath(ctx, "/receipts/extract-ocr")
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Success bool `json:"success"`
+		Data    struct {
+			OCRData OCRData `json:"ocr_data"`
+		} `json:"data"`
+	}
+	if err := c.Upload(ctx, path, filename, data, nil, &resp); err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, errors.New("ledgerforge: could not read the receipt")
+	}
+	return &resp.Data.OCRData, nil
+}
+
+// SuggestCategory asks LedgerForge to categorize a purchase.
+func (c *Client) SuggestCategory(ctx context.Context, vendor, amount string) (*CategorySuggestion, error) {
+	path, err := BusinessPath(ctx, "/transactions/suggest-category")
+	if err != nil {
+		return nil, err
+	}
+	var s CategorySuggestion
+	body := map[string]string{"vendor_name": vendor, "amount": amount}
+	if err := c.Do(ctx, http.MethodPost, path, body, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// CreateTransaction creates a transaction, which LedgerForge saves as a
+// draft for review.
+func (c *Client) CreateTransaction(ctx context.Context, t Transaction) (*Transaction, error) {
+	path, err := BusinessPath(ctx, "/transactions")
+	if err != nil {
+		return nil, err
+	}
+	var created Transaction
+	if err := c.Do(ctx, http.MethodPost, path, t, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// AttachReceipt stores a receipt file with a transaction.
+func (c *Client) AttachReceipt(ctx context.Context, transactionID, filename string, data []byte) error {
+	path, err := BusinessPath(ctx, "/transactions/"+url.PathEscape(transactionID)+"/receipts")
+	if err != nil {
+		return err
+	}
+	return c.Upload(ctx, path, filename, data, nil, nil)
+}
@@ -0,0 +1,70 @@
+package ledgerforge
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Types of events picoclaw reports to LedgerForge.
+const (
+	EventTransactionPosted = "transaction.posted"
+	EventReceiptProcessed  = "receipt.processed"
+)
+
+// Event tells LedgerForge about something picoclaw did in a business.
+type Event struct {
+	// ID names the occurrence, not the attempt: the same receipt or
+	// posting always has the same ID. It is also the Idempotency-Key.
+	ID         string    `json:"id"`
+	Type       string    `json:"type"`
+	BusinessID string    `json:"business_id"`
+	CreatedAt  time.Time `json:"created_at"`
+	Data       any       `json:"data"`
+}
+
+// TransactionPosted is the data of a transaction.posted event.
+type TransactionPosted struct {
+	TransactionID string `json:"transaction_id"`
+	Status        string `json:"status"`
+}
+
+// ReceiptProcessed is the data of a receipt.processed event: what was read
+// from a receipt and the draft created for it.
+type ReceiptProcessed struct {
+	TransactionID string `json:"transaction_id"`
+	Filename      string `json:"filename"`
+	SHA256        string `json:"sha256"`
+	Size          int    `json:"size"`
+	Vendor        string `json:"vendor"`
+	Amount        string `json:"amount"`
+	Currency      string `json:"currency"`
+	Date          string `json:"date"`
+	Category      string `json:"category,omitempty"`
+	Attached      bool   `json:"attached"` // whether the file was attached to the transaction
+}
+
+// Notifies reports whether Notify sends events, which needs a callback
+// path.
+func (c *Client) Notifies() bool {
+	return c != nil && c.callbackPath != ""
+}
+
+// Notify posts e to the callback path of the business in ctx, filling in
+// its business and time. It does nothing unless a callback path is
+// configured. Failed posts are retried like other calls with an
+// idempotency key.
+func (c *Client) Notify(ctx context.Context, e Event) error {
+	if !c.Notifies() {
+		return nil
+	}
+	path, err := BusinessPath(ctx, c.callbackPath)
+	if err != nil {
+		return err
+	}
+	e.BusinessID = BusinessID(ctx)
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = c.nowFunc().UTC()
+	}
+	return c.Do(WithIdempotencyKey(ctx, e.ID), http.MethodPost, path, e, nil)
+}
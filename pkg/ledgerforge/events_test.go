@@ -0,0 +1,52 @@
+package ledgerforge
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotifyPostsEventToCallbackPath(t *testing.T) {
+	var got Event
+	var key string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/businesses/biz-1/integrations/picoclaw/events", r.URL.Path)
+		key = r.Header.Get(IdempotencyHeader)
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+	c := New(Options{BaseURL: srv.URL, CallbackPath: "integrations/picoclaw/events/"})
+	c.nowFunc = func() time.Time { return time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC) }
+
+	ctx := WithAuth(context.Background(), "jwt-1", "biz-1")
+	err := c.Notify(ctx, Event{
+		ID:   "transaction-posted-tx-1",
+		Type: EventTransactionPosted,
+		Data: TransactionPosted{TransactionID: "tx-1", Status: "posted"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "transaction-posted-tx-1", key)
+	assert.Equal(t, "transaction-posted-tx-1", got.ID)
+	assert.Equal(t, EventTransactionPosted, got.Type)
+	assert.Equal(t, "biz-1", got.BusinessID)
+	assert.Equal(t, "2026-03-02T09:00:00Z", got.CreatedAt.Format(time.RFC3339))
+	assert.Equal(t, map[string]any{"transaction_id": "tx-1", "status": "posted"}, got.Data)
+}
+
+func TestNotifyIsOffWithoutCallbackPath(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("no request expected")
+	})
+	assert.False(t, c.Notifies())
+	assert.NoError(t, c.Notify(WithAuth(context.Background(), "jwt-1", "biz-1"), Event{ID: "e1"}))
+
+	var none *Client
+	assert.False(t, none.Notifies())
+}
@@ -0,0 +1,358 @@
+// Package ledgerforge is a client for the LedgerForge accounting API, shared
+// by built-in tools and anything else that talks to it. Calls carry the JWT
+// and business of the request being handled, taken from its context, so the
+// agent can only see and change what that user can.
+package ledgerforge
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/httpclient"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	defaultTimeout    = 30 * time.Second
+	defaultMaxRetries = 3
+	// maxRetryWait is the longest Retry-After honored; a longer one fails
+	// the call instead of holding up the agent.
+	maxRetryWait    = 30 * time.Second
+	maxResponseSize = 4 << 20
+	// logBodyChars is how much of each request and response body debug
+	// logging shows.
+	logBodyChars = 500
+)
+
+var (
+	// ErrNoToken is returned for calls made outside an authenticated
+	// request.
+	ErrNoToken = errors.New("ledgerforge: no user token in context")
+	// ErrNoBusiness is returned for business calls made outside a request
+	// scoped to a business.
+	ErrNoBusiness = errors.New("ledgerforge: no business in context")
+)
+
+// APIError is a non-2xx answer from LedgerForge.
+type APIError struct {
+	Status  int
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("ledgerforge: HTTP %d: %s", e.Status, e.Message)
+}
+
+// Headers of signed requests. See Sign.
+const (
+	SignatureHeader   = "X-Picoclaw-Signature"
+	TimestampHeader   = "X-Picoclaw-Timestamp"
+	IdempotencyHeader = "Idempotency-Key"
+)
+
+// Options configures a Client. Zero values use defaults.
+type Options struct {
+	BaseURL    string // e.g. "https://api.oluto.app"
+	Timeout    time.Duration
+	MaxRetries int
+	// SigningSecret, if set, signs every request so LedgerForge can check
+	// it came from picoclaw.
+	SigningSecret string
+	// CallbackPath is where Notify posts events, relative to the business,
+	// e.g. "/integrations/picoclaw/events". Empty turns events off.
+	CallbackPath string
+}
+
+// Client calls LedgerForge. It is safe for concurrent use.
+type Client struct {
+	baseURL      string
+	client       *http.Client
+	maxRetries   int
+	backoff      time.Duration // first retry delay, doubled for each retry
+	secret       []byte
+	callbackPath string
+	nowFunc      func() time.Time // for testing
+}
+
+// New creates a client for the API at opts.BaseURL.
+func New(opts Options) *Client {
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+	if opts.MaxRetries < 0 {
+		opts.MaxRetries = 0
+	} else if opts.MaxRetries == 0 {
+		opts.MaxRetries = defaultMaxRetries
+	}
+	c := &Client{
+		baseURL:    strings.TrimRight(opts.BaseURL, "/"),
+		client:     httpclient.New(opts.Timeout),
+		maxRetries: opts.MaxRetries,
+		backoff:    500 * time.Millisecond,
+		nowFunc:    time.Now,
+	}
+	if opts.SigningSecret != "" {
+		c.secret = []byte(opts.SigningSecret)
+	}
+	if opts.CallbackPath != "" {
+		c.callbackPath = "/" + strings.Trim(opts.CallbackPath, "/")
+	}
+	return c
+}
+
+// WithAuth returns a context whose calls act as the user with token, scoped
+// to businessID. Requests from the API already carry both.
+func WithAuth(ctx context.Context, token, businessID string) context.Context {
+	ctx = context.WithValue(ctx, constants.ContextKeyJWTToken, token)
+	return context.WithValue(ctx, constants.ContextKeyBusinessID, businessID)
+}
+
+// Token returns the user's JWT carried by ctx.
+func Token(ctx context.Context) string {
+	token, _ := ctx.Value(constants.ContextKeyJWTToken).(string)
+	return token
+}
+
+// BusinessID returns the business ctx is scoped to.
+func BusinessID(ctx context.Context) string {
+	id, _ := ctx.Value(constants.ContextKeyBusinessID).(string)
+	return id
+}
+
+type idempotencyKey struct{}
+
+// WithIdempotencyKey returns a context whose POST and PATCH calls send key
+// as their Idempotency-Key. A key names one operation, so LedgerForge can
+// answer a repeat of it without doing it twice; calls with one are retried
+// like GET. Without one, each call gets a random key, kept across its own
+// retries.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKey{}, key)
+}
+
+// Sign returns the signature of a request: "v1=" and the hex HMAC-SHA256,
+// keyed with secret, of the Unix timestamp, method, path with query,
+// idempotency key and body, each followed by a newline except the body.
+// LedgerForge recomputes it to check a request's origin, and should reject
+// timestamps more than a few minutes old.
+func Sign(secret []byte, timestamp int64, method, path, key string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "%d\n%s\n%s\n%s\n", timestamp, method, path, key)
+	mac.Write(body)
+	return "v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// BusinessPath returns the API path of the context's business followed by
+// suffix, e.g. "/transactions/summary".
+func BusinessPath(ctx context.Context, suffix string) (string, error) {
+	id := BusinessID(ctx)
+	if id == "" {
+		return "", ErrNoBusiness
+	}
+	return "/api/v1/businesses/" + url.PathEscape(id) + suffix, nil
+}
+
+// Do sends body as JSON to path, relative to the base URL, and decodes the
+// response into out unless it is nil. GET, PUT and DELETE, and calls with
+// an idempotency key from WithIdempotencyKey, are retried after network
+// errors and 5xx answers. Any method is retried after 429, which
+// LedgerForge answers before doing any work.
+func (c *Client) Do(ctx context.Context, method, path string, body, out any) error {
+	var payload []byte
+	contentType := ""
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("ledgerforge: encoding request: %w", err)
+		}
+		contentType = "application/json"
+	}
+	return c.do(ctx, method, path, contentType, payload, out)
+}
+
+// Upload posts a file as the multipart field "file", with fields as further
+// form values, and decodes the response into out unless it is nil.
+func (c *Client) Upload(
+	ctx context.Context,
+	path, filename string,
+	data []byte,
+	fields map[string]string,
+	out any,
+) error {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for name, value := range fields {
+		if err := mw.WriteField(name, value); err != nil {
+			return fmt.Errorf("ledgerforge: encoding request: %w", err)
+		}
+	}
+	fw, err := mw.CreateFormFile("file", filename)
+	if err != nil {
+		return fmt.Errorf("ledgerforge: encoding request: %w", err)
+	}
+	fw.Write(data)
+	if err := mw.Close(); err != nil {
+		return fmt.Errorf("ledgerforge: encoding request: %w", err)
+	}
+	return c.do(ctx, http.MethodPost, path, mw.FormDataContentType(), buf.Bytes(), out)
+}
+
+// do sends payload with the retries described on Do.
+func (c *Client) do(ctx context.Context, method, path, contentType string, payload []byte, out any) error {
+	token := Token(ctx)
+	if token == "" {
+		return ErrNoToken
+	}
+	key, keyed := ctx.Value(idempotencyKey{}).(string)
+	if method == http.MethodPost || method == http.MethodPatch {
+		if !keyed || key == "" {
+			key, keyed = rand.Text(), false
+		}
+	} else {
+		key, keyed = "", false
+	}
+
+	for attempt := 0; ; attempt++ {
+		status, data, retryAfter, err := c.send(ctx, method, path, token, key, contentType, payload)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		retryable := status == http.StatusTooManyRequests ||
+			((idempotent(method) || keyed) && (err != nil || status >= http.StatusInternalServerError))
+		wait := c.backoff << attempt
+		if retryAfter > 0 {
+			wait = retryAfter
+		}
+		if !retryable || attempt >= c.maxRetries || wait > maxRetryWait {
+			if err != nil {
+				return fmt.Errorf("ledgerforge: %s %s: %w", method, path, err)
+			}
+			if status < 200 || status >= 300 {
+				return &APIError{Status: status, Message: errorMessage(data)}
+			}
+			if out == nil || len(data) == 0 {
+				return nil
+			}
+			if err := json.Unmarshal(data, out); err != nil {
+				return fmt.Errorf("ledgerforge: decoding %s %s: %w", method, path, err)
+			}
+			return nil
+		}
+
+		fields := map[string]any{"method": method, "path": path, "attempt": attempt + 1, "wait": wait.String()}
+		if err != nil {
+			fields["error"] = err.Error()
+		} else {
+			fields["status"] = status
+		}
+		logger.WarnCF("ledgerforge", "Retrying LedgerForge call", fields)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// send makes one attempt. retryAfter is the server's Retry-After, if any.
+func (c *Client) send(
+	ctx context.Context, method, path, token, key, contentType string, payload []byte,
+) (status int, data []byte, retryAfter time.Duration, err error) {
+	var reqBody io.Reader
+	if payload != nil {
+		reqBody = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reqBody)
+	if err != nil {
+		return 0, nil, 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if requestID, ok := ctx.Value(constants.ContextKeyRequestID).(string); ok && requestID != "" {
+		req.Header.Set(constants.RequestIDHeader, requestID)
+	}
+	if key != "" {
+		req.Header.Set(IdempotencyHeader, key)
+	}
+	if c.secret != nil {
+		timestamp := c.nowFunc().Unix()
+		req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+		req.Header.Set(SignatureHeader, Sign(c.secret, timestamp, method, path, key, payload))
+	}
+
+	start := time.Now()
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, nil, 0, err
+	}
+	defer resp.Body.Close()
+	data, err = io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return 0, nil, 0, err
+	}
+	if secs, convErr := strconv.Atoi(resp.Header.Get("Retry-After")); convErr == nil && secs > 0 {
+		retryAfter = time.Duration(secs) * time.Second
+	}
+
+	logged := utils.Truncate(string(payload), logBodyChars)
+	if strings.HasPrefix(contentType, "multipart/") {
+		logged = fmt.Sprintf("(%d bytes of form data)", len(payload))
+	}
+	logger.DebugCF("ledgerforge", "LedgerForge call", map[string]any{
+		"method":      method,
+		"path":        path,
+		"status":      resp.StatusCode,
+		"duration_ms": time.Since(start).Milliseconds(),
+		"request":     logged,
+		"response":    utils.Truncate(string(data), logBodyChars),
+	})
+	return resp.StatusCode, data, retryAfter, nil
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// errorMessage extracts LedgerForge's error text, which comes in one of
+// several fields depending on the endpoint.
+func errorMessage(data []byte) string {
+	var body struct {
+		Error   string `json:"error"`
+		Message string `json:"message"`
+		Detail  string `json:"detail"`
+	}
+	if json.Unmarshal(data, &body) == nil {
+		for _, msg := range []string{body.Error, body.Message, body.Detail} {
+			if msg != "" {
+				return msg
+			}
+		}
+	}
+	if text := strings.TrimSpace(string(data)); text != "" {
+		return utils.Truncate(text, logBodyChars)
+	}
+	return "no details"
+}
@@ -0,0 +1,155 @@
+package ledgerforge
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClient(t *testing.T, h http.HandlerFunc) *Client {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	c := New(Options{BaseURL: srv.URL + "/"})
+	c.backoff = time.Millisecond
+	return c
+}
+
+func TestSummarySendsUserTokenAndBusiness(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer jwt-1", r.Header.Get("Authorization"))
+		assert.Equal(t, "/api/v1/businesses/biz-1/transactions/summary", r.URL.Path)
+		io.WriteString(w, `{"safe_to_spend":"1200.50","exceptions_count":2,"status_counts":{"posted":7}}`)
+	})
+
+	s, err := c.Summary(WithAuth(context.Background(), "jwt-1", "biz-1"))
+	require.NoError(t, err)
+	assert.Equal(t, "1200.50", s.SafeToSpend)
+	assert.Equal(t, 2, s.ExceptionsCount)
+	assert.Equal(t, 7, s.StatusCounts["posted"])
+}
+
+func TestDoRequiresAuth(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("no request expected")
+	})
+
+	_, err := c.Businesses(context.Background())
+	assert.ErrorIs(t, err, ErrNoToken)
+	_, err = c.Summary(WithAuth(context.Background(), "jwt-1", ""))
+	assert.ErrorIs(t, err, ErrNoBusiness)
+}
+
+func TestDoRetriesServerErrorsOnlyWhenIdempotent(t *testing.T) {
+	var calls atomic.Int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		io.WriteString(w, `[{"id":"biz-1","name":"Acme"}]`)
+	})
+	ctx := WithAuth(context.Background(), "jwt-1", "biz-1")
+
+	businesses, err := c.Businesses(ctx)
+	require.NoError(t, err)
+	require.Len(t, businesses, 1)
+	assert.Equal(t, "Acme", businesses[0].Name)
+	assert.EqualValues(t, 3, calls.Load())
+
+	calls.Store(0)
+	err = c.Do(ctx, http.MethodPost, "/api/v1/businesses", map[string]string{"name": "Acme"}, nil)
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusBadGateway, apiErr.Status)
+	assert.EqualValues(t, 1, calls.Load(), "POST must not be repeated after a server error")
+}
+
+func TestDoRetriesRateLimitedPosts(t *testing.T) {
+	var calls atomic.Int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, `{"id":"tx-1"}`)
+	})
+
+	var out struct {
+		ID string `json:"id"`
+	}
+	err := c.Do(WithAuth(context.Background(), "jwt-1", "biz-1"), http.MethodPost, "/api/v1/x", map[string]any{}, &out)
+	require.NoError(t, err)
+	assert.Equal(t, "tx-1", out.ID)
+	assert.EqualValues(t, 2, calls.Load())
+}
+
+func TestDoReportsAPIErrorMessage(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		io.WriteString(w, `{"detail":"amount must be positive"}`)
+	})
+
+	err := c.Do(WithAuth(context.Background(), "jwt-1", "biz-1"), http.MethodPost, "/api/v1/x", nil, nil)
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, "amount must be positive", apiErr.Message)
+}
+
+func TestDoSignsRequestsAndKeepsIdempotencyKeyAcrossRetries(t *testing.T) {
+	var calls atomic.Int32
+	var keys []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		key := r.Header.Get(IdempotencyHeader)
+		keys = append(keys, key)
+		assert.Equal(t, "1772442000", r.Header.Get(TimestampHeader))
+		assert.Equal(t, Sign([]byte("s3cret"), 1772442000, r.Method, r.URL.RequestURI(), key, body),
+			r.Header.Get(SignatureHeader))
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		io.WriteString(w, `{"id":"tx-1"}`)
+	}))
+	defer srv.Close()
+	c := New(Options{BaseURL: srv.URL, SigningSecret: "s3cret"})
+	c.backoff = time.Millisecond
+	c.nowFunc = func() time.Time { return time.Unix(1772442000, 0) }
+
+	ctx := WithIdempotencyKey(WithAuth(context.Background(), "jwt-1", "biz-1"), "receipt-abc-draft")
+	_, err := c.CreateTransaction(ctx, Transaction{VendorName: "Cafe", Amount: "-12.50"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"receipt-abc-draft", "receipt-abc-draft"}, keys, "keyed POSTs are retried with the same key")
+
+	keys = nil
+	_, err = c.Summary(WithAuth(context.Background(), "jwt-1", "biz-1"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{""}, keys, "GETs carry no idempotency key but are still signed")
+}
+
+func TestSignCoversEveryPart(t *testing.T) {
+	secret := []byte("s3cret")
+	base := Sign(secret, 1, "POST", "/a", "k", []byte("{}"))
+	assert.Regexp(t, `^v1=[0-9a-f]{64}$`, base)
+	for _, other := range []string{
+		Sign([]byte("other"), 1, "POST", "/a", "k", []byte("{}")),
+		Sign(secret, 2, "POST", "/a", "k", []byte("{}")),
+		Sign(secret, 1, "PATCH", "/a", "k", []byte("{}")),
+		Sign(secret, 1, "POST", "/b", "k", []byte("{}")),
+		Sign(secret, 1, "POST", "/a", "j", []byte("{}")),
+		Sign(secret, 1, "POST", "/a", "k", []byte("[]")),
+	} {
+		assert.NotEqual(t, base, other)
+	}
+}
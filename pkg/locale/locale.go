@@ -0,0 +1,408 @@
+// Package locale resolves each business's locale, currency and date format
+// and formats and reads amounts and dates with them, so a Canadian business
+// sees $1,234.56 and 03/31/2026 while a German one sees 1.234,56 € and
+// 31.03.2026.
+package locale
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/i18n"
+)
+
+// Defaults used when nothing is configured, matching LedgerForge's
+// Canadian bookkeeping.
+const (
+	DefaultLocale   = "en-CA"
+	DefaultCurrency = "CAD"
+)
+
+// Settings are one business's locale settings.
+type Settings struct {
+	Locale     string // BCP 47 tag, e.g. "de-DE"
+	Currency   string // ISO 4217 code, e.g. "EUR"
+	DateFormat string // YYYY, MM and DD with separators, e.g. "DD.MM.YYYY"
+	Language   string // language of picoclaw's own messages, one of i18n.Supported
+	Timezone   string // IANA zone schedules run in, e.g. "Europe/Berlin"; empty is the server's
+}
+
+// Resolver picks the settings of a business from the configuration.
+type Resolver struct {
+	cfg config.LocaleConfig
+}
+
+// NewResolver creates a resolver for cfg.
+func NewResolver(cfg config.LocaleConfig) *Resolver {
+	return &Resolver{cfg: cfg}
+}
+
+// Configured reports whether any locale settings are configured. A nil
+// resolver has none.
+func (r *Resolver) Configured() bool {
+	return r != nil && (r.cfg.Locale != "" || r.cfg.Currency != "" || r.cfg.DateFormat != "" ||
+		r.cfg.Language != "" || r.cfg.Timezone != "" || len(r.cfg.Businesses) > 0 || len(r.cfg.Users) > 0 ||
+		len(r.cfg.UserTimezones) > 0)
+}
+
+// For returns the settings of businessID. Fields the business doesn't set
+// follow from its own locale if it sets one, then from the global settings,
+// then from the global locale. A nil resolver returns the defaults.
+func (r *Resolver) For(businessID string) Settings {
+	var global config.LocaleConfig
+	var biz config.BusinessLocaleConfig
+	if r != nil {
+		global = r.cfg
+		biz = r.cfg.Businesses[businessID]
+	}
+	s := Settings{Locale: first(biz.Locale, global.Locale, DefaultLocale)}
+	if biz.Locale != "" {
+		s.Currency = first(biz.Currency, regionCurrency(biz.Locale))
+		s.DateFormat = first(biz.DateFormat, localeDateFormat(biz.Locale))
+		s.Language = i18n.Match(first(biz.Language, biz.Locale))
+	}
+	s.Currency = strings.ToUpper(first(s.Currency, biz.Currency, global.Currency, regionCurrency(s.Locale),
+		DefaultCurrency))
+	s.DateFormat = first(s.DateFormat, biz.DateFormat, global.DateFormat, localeDateFormat(s.Locale))
+	s.Language = first(s.Language, i18n.Match(first(biz.Language, global.Language, s.Locale)), i18n.Default)
+	s.Timezone = first(biz.Timezone, global.Timezone)
+	return s
+}
+
+// Location returns the zone of s.Timezone, or the server's zone if it is
+// empty or unknown. Validate rejects unknown zones at startup.
+func (s Settings) Location() *time.Location {
+	return location(s.Timezone)
+}
+
+// UserLanguage returns the language configured for userID, or "" if there
+// is none. Chat sender IDs like "123|name" also match a setting for "123".
+func (r *Resolver) UserLanguage(userID string) string {
+	if r == nil || userID == "" {
+		return ""
+	}
+	lang, ok := r.cfg.Users[userID]
+	if !ok {
+		id, _, _ := strings.Cut(userID, "|")
+		lang = r.cfg.Users[id]
+	}
+	return i18n.Match(lang)
+}
+
+// UserTimezone returns the zone configured for userID, or "" if there is
+// none. Like UserLanguage, "123|name" also matches a setting for "123".
+func (r *Resolver) UserTimezone(userID string) string {
+	if r == nil || userID == "" {
+		return ""
+	}
+	tz, ok := r.cfg.UserTimezones[userID]
+	if !ok {
+		id, _, _ := strings.Cut(userID, "|")
+		tz = r.cfg.UserTimezones[id]
+	}
+	return strings.TrimSpace(tz)
+}
+
+// Location returns the zone of userID working in businessID: the user's
+// own, else the business's. Either ID may be empty.
+func (r *Resolver) Location(businessID, userID string) *time.Location {
+	return location(first(r.UserTimezone(userID), r.For(businessID).Timezone))
+}
+
+func location(name string) *time.Location {
+	if name == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// Language returns the language to write to userID in about businessID:
+// the user's own, else the business's. Either ID may be empty.
+func (r *Resolver) Language(businessID, userID string) string {
+	return first(r.UserLanguage(userID), r.For(businessID).Language)
+}
+
+// Validate checks the configured currencies, date formats, languages and
+// timezones.
+func Validate(cfg config.LocaleConfig) error {
+	check := func(where, currency, dateFormat, language, timezone string) error {
+		if currency != "" && !currencyCode.MatchString(strings.ToUpper(currency)) {
+			return fmt.Errorf("%s: currency %q is not a three-letter ISO 4217 code", where, currency)
+		}
+		if dateFormat != "" && dateOrder(dateFormat) == "" {
+			return fmt.Errorf("%s: date format %q must contain YYYY, MM and DD", where, dateFormat)
+		}
+		if language != "" && i18n.Match(language) == "" {
+			return fmt.Errorf("%s: language %q is not one of %s", where, language,
+				strings.Join(i18n.Supported, ", "))
+		}
+		if timezone != "" {
+			if _, err := time.LoadLocation(timezone); err != nil {
+				return fmt.Errorf("%s: timezone %q is not an IANA zone such as Europe/Berlin", where, timezone)
+			}
+		}
+		return nil
+	}
+	if err := check("locale", cfg.Currency, cfg.DateFormat, cfg.Language, cfg.Timezone); err != nil {
+		return err
+	}
+	for id, b := range cfg.Businesses {
+		if err := check("locale of business "+id, b.Currency, b.DateFormat, b.Language, b.Timezone); err != nil {
+			return err
+		}
+	}
+	for id, lang := range cfg.Users {
+		if err := check("locale of user "+id, "", "", lang, ""); err != nil {
+			return err
+		}
+	}
+	for id, tz := range cfg.UserTimezones {
+		if err := check("locale of user "+id, "", "", "", tz); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+func first(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// split returns the lowercase language and uppercase region of a locale.
+func split(locale string) (string, string) {
+	lang, region, _ := strings.Cut(strings.ReplaceAll(locale, "_", "-"), "-")
+	if i := strings.LastIndex(region, "-"); i >= 0 { // zh-Hant-TW
+		region = region[i+1:]
+	}
+	return strings.ToLower(lang), strings.ToUpper(region)
+}
+
+var regionCurrencies = map[string]string{
+	"CA": "CAD", "US": "USD", "GB": "GBP", "CH": "CHF", "AU": "AUD", "NZ": "NZD", "IN": "INR",
+	"NG": "NGN", "GH": "GHS", "KE": "KES", "ZA": "ZAR", "JP": "JPY", "CN": "CNY", "MX": "MXN",
+	"BR": "BRL", "SE": "SEK", "NO": "NOK", "DK": "DKK", "PL": "PLN", "SG": "SGD",
+	"DE": "EUR", "FR": "EUR", "ES": "EUR", "IT": "EUR", "NL": "EUR", "BE": "EUR", "AT": "EUR",
+	"IE": "EUR", "PT": "EUR", "FI": "EUR", "GR": "EUR", "LU": "EUR",
+}
+
+func regionCurrency(locale string) string {
+	_, region := split(locale)
+	return regionCurrencies[region]
+}
+
+// dateFormats are the usual numeric date formats, by locale and then by
+// language.
+var dateFormats = map[string]string{
+	"en-US": "MM/DD/YYYY", "en-CA": "MM/DD/YYYY", "fr-CA": "YYYY-MM-DD", "de-CH": "DD.MM.YYYY",
+	"en": "DD/MM/YYYY", "fr": "DD/MM/YYYY", "es": "DD/MM/YYYY", "it": "DD/MM/YYYY", "pt": "DD/MM/YYYY",
+	"de": "DD.MM.YYYY", "nl": "DD-MM-YYYY", "pl": "DD.MM.YYYY", "sv": "YYYY-MM-DD",
+	"ja": "YYYY/MM/DD", "zh": "YYYY/MM/DD", "ko": "YYYY.MM.DD",
+}
+
+func localeDateFormat(locale string) string {
+	lang, region := split(locale)
+	return first(dateFormats[lang+"-"+region], dateFormats[lang], "YYYY-MM-DD")
+}
+
+// dateOrder returns "MDY", "DMY" or "YMD" for a date format, or "" if it
+// lacks one of YYYY, MM and DD.
+func dateOrder(format string) string {
+	idx := map[byte]int{
+		'Y': strings.Index(format, "YYYY"),
+		'M': strings.Index(format, "MM"),
+		'D': strings.Index(format, "DD"),
+	}
+	for _, i := range idx {
+		if i < 0 {
+			return ""
+		}
+	}
+	order := []byte("YMD")
+	sort.Slice(order, func(i, j int) bool { return idx[order[i]] < idx[order[j]] })
+	return string(order)
+}
+
+// FormatDate writes t in the date format.
+func (s Settings) FormatDate(t time.Time) string {
+	r := strings.NewReplacer("YYYY", "2006", "MM", "01", "DD", "02")
+	return t.Format(r.Replace(s.DateFormat))
+}
+
+var numericDate = regexp.MustCompile(`^(\d{1,4})[./\-\s](\d{1,2})[./\-\s](\d{2,4})$`)
+
+// ParseDate reads a numeric date such as "03/02/2026", taking the day and
+// month in the order of the date format, so the same text is 2 March for a
+// Canadian business and 3 February for a German one. Dates starting with
+// the year are always read year, month, day.
+func (s Settings) ParseDate(text string) (time.Time, bool) {
+	m := numericDate.FindStringSubmatch(strings.TrimSpace(text))
+	if m == nil {
+		return time.Time{}, false
+	}
+	a, _ := strconv.Atoi(m[1])
+	b, _ := strconv.Atoi(m[2])
+	c, _ := strconv.Atoi(m[3])
+	var year, month, day int
+	switch order := dateOrder(s.DateFormat); {
+	case len(m[1]) == 4:
+		year, month, day = a, b, c
+	case order == "DMY":
+		day, month, year = a, b, c
+	default: // MDY, and YMD formats given a date without a leading year
+		month, day, year = a, b, c
+	}
+	if len(m[3]) == 2 && len(m[1]) != 4 {
+		year += 2000
+	}
+	t := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	if month < 1 || month > 12 || t.Day() != day || t.Year() != year {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// numberFormat is how a language writes numbers.
+type numberFormat struct {
+	decimal, group string
+	suffix         bool // the currency symbol follows the amount
+}
+
+var numberFormats = map[string]numberFormat{
+	"de": {",", ".", true}, "es": {",", ".", true}, "it": {",", ".", true}, "pt": {",", ".", true},
+	"fr": {",", " ", true}, "sv": {",", " ", true}, "nb": {",", " ", true}, "fi": {",", " ", true},
+	"pl": {",", " ", true}, "da": {",", ".", true}, "nl": {",", ".", false}, "de-CH": {".", "'", false},
+}
+
+func (s Settings) numberFormat() numberFormat {
+	lang, region := split(s.Locale)
+	if f, ok := numberFormats[lang+"-"+region]; ok {
+		return f
+	}
+	if f, ok := numberFormats[lang]; ok {
+		return f
+	}
+	return numberFormat{".", ",", false}
+}
+
+var currencySymbols = map[string]string{
+	"CAD": "$", "USD": "$", "AUD": "$", "NZD": "$", "MXN": "$", "SGD": "$", "EUR": "€", "GBP": "£",
+	"JPY": "¥", "CNY": "¥", "INR": "₹", "NGN": "₦", "GHS": "GH₵", "KES": "KSh", "ZAR": "R",
+	"BRL": "R$", "CHF": "CHF", "SEK": "kr", "NOK": "kr", "DKK": "kr", "PLN": "zł",
+}
+
+// Symbol returns the currency's symbol. Dollars of another country than the
+// locale's are marked with it, as in "US$" for a Canadian business.
+func (s Settings) Symbol() string {
+	sym, ok := currencySymbols[s.Currency]
+	if !ok {
+		return s.Currency
+	}
+	if _, region := split(s.Locale); sym == "$" && regionCurrency(s.Locale) != s.Currency && region != "" {
+		return s.Currency[:2] + "$"
+	}
+	return sym
+}
+
+// decimals is the number of minor units the currency is written with.
+func (s Settings) decimals() int {
+	switch s.Currency {
+	case "JPY", "KRW":
+		return 0
+	}
+	return 2
+}
+
+// FormatAmount writes v in the currency, e.g. "$1,234.56" or "1.234,56 €".
+func (s Settings) FormatAmount(v float64) string {
+	f := s.numberFormat()
+	digits := strconv.FormatFloat(math.Abs(v), 'f', s.decimals(), 64)
+	whole, frac, _ := strings.Cut(digits, ".")
+	var b strings.Builder
+	for i, c := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteString(f.group)
+		}
+		b.WriteRune(c)
+	}
+	if frac != "" {
+		b.WriteString(f.decimal + frac)
+	}
+	amount := s.Symbol() + b.String()
+	if f.suffix {
+		amount = b.String() + " " + s.Symbol()
+	}
+	if v < 0 && math.Round(math.Abs(v)*math.Pow10(s.decimals())) != 0 {
+		amount = "-" + amount
+	}
+	return amount
+}
+
+// FormatAmountString formats an amount in API form, like "1234.5", and
+// returns other text unchanged.
+func (s Settings) FormatAmountString(amount string) string {
+	v, err := strconv.ParseFloat(strings.TrimSpace(amount), 64)
+	if err != nil {
+		return amount
+	}
+	return s.FormatAmount(v)
+}
+
+// ParseAmount reads an amount written with either decimal separator, such
+// as "1,234.56", "1.234,56" or "12,50", into API form ("1234.56"). The last
+// separator is the decimal one, unless it is the only separator and three
+// digits follow it, as in "1,234".
+func ParseAmount(text string) (string, bool) {
+	text = strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' || r == '.' || r == ',' {
+			return r
+		}
+		return -1
+	}, text)
+	whole, frac := text, ""
+	if i := strings.LastIndexAny(text, ".,"); i >= 0 {
+		sep := text[i : i+1]
+		if strings.Count(text, sep) == 1 && (len(text)-i-1 != 3 || strings.ContainsAny(text[:i], ".,")) {
+			whole, frac = text[:i], text[i+1:]
+		}
+	}
+	whole = strings.NewReplacer(".", "", ",", "").Replace(whole)
+	if whole == "" && frac == "" {
+		return "", false
+	}
+	v, err := strconv.ParseFloat("0"+whole+"."+frac+"0", 64)
+	if err != nil {
+		return "", false
+	}
+	return strconv.FormatFloat(v, 'f', 2, 64), true
+}
+
+// PromptSection tells the model how to write amounts and dates, and the
+// business's local time if it has a timezone.
+func (s Settings) PromptSection(now time.Time) string {
+	section := fmt.Sprintf("# Locale\n\nThis business uses the %s locale. Write amounts in %s, like %s, "+
+		"and dates as %s, like %s. Read numeric dates in receipts and documents in the same order.",
+		s.Locale, s.Currency, s.FormatAmount(1234.56), s.DateFormat, s.FormatDate(now))
+	if s.Timezone != "" {
+		local := now.In(s.Location())
+		section += fmt.Sprintf(" Its timezone is %s, where it is now %s; times users mention are in this zone.",
+			s.Timezone, local.Format("2006-01-02 15:04 (Monday)"))
+	}
+	return section
+}
@@ -0,0 +1,135 @@
+package locale
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestResolverFor(t *testing.T) {
+	r := NewResolver(config.LocaleConfig{
+		Locale: "en-CA",
+		Businesses: map[string]config.BusinessLocaleConfig{
+			"berlin": {Locale: "de-DE"},
+			"zurich": {Locale: "de-CH", DateFormat: "YYYY-MM-DD"},
+			"export": {Currency: "usd"},
+			"tokyo":  {Locale: "ja-JP", Timezone: "Asia/Tokyo"},
+		},
+	})
+	assert.Equal(t, Settings{"en-CA", "CAD", "MM/DD/YYYY", "en", ""}, r.For("toronto"))
+	assert.Equal(t, Settings{"de-DE", "EUR", "DD.MM.YYYY", "de", ""}, r.For("berlin"))
+	assert.Equal(t, Settings{"de-CH", "CHF", "YYYY-MM-DD", "de", ""}, r.For("zurich"))
+	assert.Equal(t, Settings{"en-CA", "USD", "MM/DD/YYYY", "en", ""}, r.For("export"))
+	assert.Equal(t, Settings{"ja-JP", "JPY", "YYYY/MM/DD", "en", "Asia/Tokyo"}, r.For("tokyo"))
+
+	var none *Resolver
+	assert.False(t, none.Configured())
+	assert.Equal(t, Settings{"en-CA", "CAD", "MM/DD/YYYY", "en", ""}, none.For("any"))
+}
+
+func TestResolverLanguage(t *testing.T) {
+	r := NewResolver(config.LocaleConfig{
+		Locale:     "fr-CA",
+		Businesses: map[string]config.BusinessLocaleConfig{"berlin": {Locale: "de-DE"}, "intl": {Language: "en"}},
+		Users:      map[string]string{"42": "de", "u-1": "en-GB"},
+	})
+	assert.Equal(t, "fr", r.Language("", ""))
+	assert.Equal(t, "de", r.Language("berlin", ""))
+	assert.Equal(t, "en", r.Language("intl", ""))
+	assert.Equal(t, "de", r.Language("intl", "42|alice"), "a user's language beats the business's")
+	assert.Equal(t, "en", r.Language("berlin", "u-1"))
+	assert.Equal(t, "", r.UserLanguage("someone"))
+
+	var none *Resolver
+	assert.Equal(t, "en", none.Language("berlin", "42"))
+}
+
+func TestResolverLocation(t *testing.T) {
+	r := NewResolver(config.LocaleConfig{
+		Timezone:      "America/Toronto",
+		Businesses:    map[string]config.BusinessLocaleConfig{"berlin": {Timezone: "Europe/Berlin"}},
+		UserTimezones: map[string]string{"42": "Asia/Tokyo"},
+	})
+	assert.Equal(t, "America/Toronto", r.Location("", "").String())
+	assert.Equal(t, "Europe/Berlin", r.Location("berlin", "7").String())
+	assert.Equal(t, "Asia/Tokyo", r.Location("berlin", "42|alice").String(), "a user's zone beats the business's")
+
+	var none *Resolver
+	assert.Equal(t, time.Local, none.Location("berlin", "42"))
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Validate(config.LocaleConfig{Currency: "eur", DateFormat: "DD.MM.YYYY"}))
+	assert.ErrorContains(t, Validate(config.LocaleConfig{Currency: "euro"}), "ISO 4217")
+	assert.ErrorContains(t, Validate(config.LocaleConfig{
+		Businesses: map[string]config.BusinessLocaleConfig{"b1": {DateFormat: "DD/MM"}},
+	}), "business b1")
+	assert.ErrorContains(t, Validate(config.LocaleConfig{Users: map[string]string{"u1": "es"}}), "en, fr, de")
+	assert.ErrorContains(t, Validate(config.LocaleConfig{
+		Businesses: map[string]config.BusinessLocaleConfig{"b1": {Timezone: "Mars/Base"}},
+	}), "IANA")
+	assert.ErrorContains(t, Validate(config.LocaleConfig{UserTimezones: map[string]string{"u1": "EST5"}}), "user u1")
+}
+
+func TestFormatAmount(t *testing.T) {
+	tests := []struct {
+		settings Settings
+		amount   float64
+		want     string
+	}{
+		{Settings{Locale: "en-CA", Currency: "CAD"}, 1234.56, "$1,234.56"},
+		{Settings{Locale: "en-CA", Currency: "USD"}, -19, "-US$19.00"},
+		{Settings{Locale: "de-DE", Currency: "EUR"}, 1234.5, "1.234,50 €"},
+		{Settings{Locale: "fr-CA", Currency: "CAD"}, 1234567.891, "1 234 567,89 $"},
+		{Settings{Locale: "ja-JP", Currency: "JPY"}, 1500, "¥1,500"},
+		{Settings{Locale: "en-CA", Currency: "CAD"}, -0.001, "$0.00"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, tt.settings.FormatAmount(tt.amount), "%v %v", tt.settings, tt.amount)
+	}
+	assert.Equal(t, "n/a", Settings{Locale: "en-CA", Currency: "CAD"}.FormatAmountString("n/a"))
+}
+
+func TestParseDate(t *testing.T) {
+	canadian := Settings{DateFormat: "MM/DD/YYYY"}
+	german := Settings{DateFormat: "DD.MM.YYYY"}
+
+	got, ok := canadian.ParseDate("03/02/2026")
+	assert.True(t, ok)
+	assert.Equal(t, "2026-03-02", got.Format(time.DateOnly))
+
+	got, ok = german.ParseDate("03.02.26")
+	assert.True(t, ok)
+	assert.Equal(t, "2026-02-03", got.Format(time.DateOnly))
+
+	got, ok = german.ParseDate("2026-03-31")
+	assert.True(t, ok)
+	assert.Equal(t, "2026-03-31", got.Format(time.DateOnly))
+
+	_, ok = canadian.ParseDate("31/03/2026")
+	assert.False(t, ok)
+	_, ok = canadian.ParseDate("March 3, 2026")
+	assert.False(t, ok)
+
+	assert.Equal(t, "31.03.2026", german.FormatDate(time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestParseAmount(t *testing.T) {
+	for text, want := range map[string]string{
+		"$1,234.56":  "1234.56",
+		"1.234,56 €": "1234.56",
+		"12,50":      "12.50",
+		"1,234":      "1234.00",
+		"1.234.567":  "1234567.00",
+		"CA$26.91":   "26.91",
+	} {
+		got, ok := ParseAmount(text)
+		assert.True(t, ok, text)
+		assert.Equal(t, want, got, text)
+	}
+	_, ok := ParseAmount("total")
+	assert.False(t, ok)
+}
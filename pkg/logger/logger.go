@@ -1,14 +1,18 @@
 package logger
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"os"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logrotate"
 )
 
 type LogLevel int
@@ -21,6 +25,17 @@ const (
 	FATAL
 )
 
+// Format selects how log lines are written to stderr.
+type Format string
+
+const (
+	FormatText Format = "text"
+	FormatJSON Format = "json"
+)
+
+// levelFatal is the slog level used for FATAL entries.
+const levelFatal = slog.Level(12)
+
 var (
 	logLevelNames = map[LogLevel]string{
 		DEBUG: "DEBUG",
@@ -30,31 +45,51 @@ var (
 		FATAL: "FATAL",
 	}
 
-	currentLevel = INFO
-	logger       *Logger
-	once         sync.Once
-	mu           sync.RWMutex
+	currentLevel            = INFO
+	currentFormat           = FormatText
+	moduleLevels            = map[string]LogLevel{}
+	output        io.Writer = os.Stderr
+	logger        *Logger
+	once          sync.Once
+	mu            sync.RWMutex
 )
 
 type Logger struct {
-	file *os.File
-}
-
-type LogEntry struct {
-	Level     string         `json:"level"`
-	Timestamp string         `json:"timestamp"`
-	Component string         `json:"component,omitempty"`
-	Message   string         `json:"message"`
-	Fields    map[string]any `json:"fields,omitempty"`
-	Caller    string         `json:"caller,omitempty"`
+	file    io.WriteCloser
+	handler *componentHandler
 }
 
 func init() {
 	once.Do(func() {
 		logger = &Logger{}
+		rebuild()
 	})
 }
 
+// ParseLevel parses a level name such as "debug" or "WARN".
+func ParseLevel(s string) (LogLevel, error) {
+	for level, name := range logLevelNames {
+		if strings.EqualFold(s, name) {
+			return level, nil
+		}
+	}
+	if strings.EqualFold(s, "warning") {
+		return WARN, nil
+	}
+	return INFO, fmt.Errorf("unknown log level %q", s)
+}
+
+// ParseFormat parses "text" or "json".
+func ParseFormat(s string) (Format, error) {
+	switch Format(strings.ToLower(s)) {
+	case FormatText:
+		return FormatText, nil
+	case FormatJSON:
+		return FormatJSON, nil
+	}
+	return FormatText, fmt.Errorf("unknown log format %q", s)
+}
+
 func SetLevel(level LogLevel) {
 	mu.Lock()
 	defer mu.Unlock()
@@ -67,13 +102,38 @@ func GetLevel() LogLevel {
 	return currentLevel
 }
 
+// SetFormat switches stderr output between text and JSON lines.
+func SetFormat(format Format) {
+	mu.Lock()
+	defer mu.Unlock()
+	currentFormat = format
+	rebuild()
+}
+
+// SetModuleLevels replaces the per-component level overrides. A component
+// with an override is logged at that level regardless of the global level.
+func SetModuleLevels(levels map[string]LogLevel) {
+	mu.Lock()
+	defer mu.Unlock()
+	moduleLevels = make(map[string]LogLevel, len(levels))
+	for component, level := range levels {
+		moduleLevels[component] = level
+	}
+}
+
 func EnableFileLogging(filePath string) error {
+	return EnableRotatingFileLogging(filePath, logrotate.Options{})
+}
+
+// EnableRotatingFileLogging writes JSON lines to filePath in addition to
+// stderr, rotating the file according to opts.
+func EnableRotatingFileLogging(filePath string, opts logrotate.Options) error {
 	mu.Lock()
 	defer mu.Unlock()
 
-	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	file, err := logrotate.Open(filePath, opts)
 	if err != nil {
-		return fmt.Errorf("failed to open log file: %w", err)
+		return err
 	}
 
 	if logger.file != nil {
@@ -81,7 +141,7 @@ func EnableFileLogging(filePath string) error {
 	}
 
 	logger.file = file
-	log.Println("File logging enabled:", filePath)
+	rebuild()
 	return nil
 }
 
@@ -92,70 +152,165 @@ func DisableFileLogging() {
 	if logger.file != nil {
 		logger.file.Close()
 		logger.file = nil
-		log.Println("File logging disabled")
+		rebuild()
 	}
 }
 
-func logMessage(level LogLevel, component string, message string, fields map[string]any) {
-	if level < currentLevel {
-		return
+// rebuild recreates the slog handlers after an output change and installs
+// them as the slog default, so direct slog and log.Printf callers share the
+// same format and filtering. Callers must hold mu.
+func rebuild() {
+	opts := &slog.HandlerOptions{
+		Level:       slog.LevelDebug, // filtering is done by componentHandler
+		ReplaceAttr: replaceLevel,
 	}
 
-	entry := LogEntry{
-		Level:     logLevelNames[level],
-		Timestamp: time.Now().UTC().Format(time.RFC3339),
-		Component: component,
-		Message:   message,
-		Fields:    fields,
+	var handlers []slog.Handler
+	if currentFormat == FormatJSON {
+		handlers = append(handlers, slog.NewJSONHandler(output, opts))
+	} else {
+		handlers = append(handlers, slog.NewTextHandler(output, opts))
 	}
-
-	if pc, file, line, ok := runtime.Caller(2); ok {
-		fn := runtime.FuncForPC(pc)
-		if fn != nil {
-			entry.Caller = fmt.Sprintf("%s:%d (%s)", file, line, fn.Name())
-		}
+	if logger.file != nil {
+		handlers = append(handlers, slog.NewJSONHandler(logger.file, &slog.HandlerOptions{
+			Level:       slog.LevelDebug,
+			AddSource:   true,
+			ReplaceAttr: replaceLevel,
+		}))
 	}
 
-	if logger.file != nil {
-		jsonData, err := json.Marshal(entry)
-		if err == nil {
-			logger.file.WriteString(string(jsonData) + "\n")
+	logger.handler = &componentHandler{handlers: handlers}
+	slog.SetDefault(slog.New(logger.handler))
+}
+
+func replaceLevel(_ []string, a slog.Attr) slog.Attr {
+	if a.Key == slog.LevelKey {
+		if level, ok := a.Value.Any().(slog.Level); ok && level >= levelFatal {
+			a.Value = slog.StringValue(logLevelNames[FATAL])
 		}
 	}
+	return a
+}
 
-	var fieldStr string
-	if len(fields) > 0 {
-		fieldStr = " " + formatFields(fields)
+func toSlogLevel(level LogLevel) slog.Level {
+	switch level {
+	case DEBUG:
+		return slog.LevelDebug
+	case WARN:
+		return slog.LevelWarn
+	case ERROR:
+		return slog.LevelError
+	case FATAL:
+		return levelFatal
+	default:
+		return slog.LevelInfo
 	}
+}
 
-	logLine := fmt.Sprintf("[%s] [%s]%s %s%s",
-		entry.Timestamp,
-		logLevelNames[level],
-		formatComponent(component),
-		message,
-		fieldStr,
-	)
+// levelFor returns the effective level for a component. Callers must hold mu.
+func levelFor(component string) LogLevel {
+	if level, ok := moduleLevels[component]; ok {
+		return level
+	}
+	return currentLevel
+}
 
-	log.Println(logLine)
+// componentHandler fans records out to the configured handlers, applying the
+// global level or the override for the record's "component" attribute.
+type componentHandler struct {
+	handlers  []slog.Handler
+	component string
+	attrs     []slog.Attr // bound with WithAttrs, for subscribers
+}
 
-	if level == FATAL {
-		os.Exit(1)
+func (h *componentHandler) Enabled(_ context.Context, level slog.Level) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	lowest := currentLevel
+	for _, l := range moduleLevels {
+		lowest = min(lowest, l)
 	}
+	return level >= toSlogLevel(lowest)
 }
 
-func formatComponent(component string) string {
+func (h *componentHandler) Handle(ctx context.Context, r slog.Record) error {
+	component := h.component
 	if component == "" {
-		return ""
+		r.Attrs(func(a slog.Attr) bool {
+			if a.Key == "component" {
+				component = a.Value.String()
+				return false
+			}
+			return true
+		})
+	}
+
+	mu.RLock()
+	enabled := r.Level >= toSlogLevel(levelFor(component))
+	mu.RUnlock()
+	if !enabled {
+		return nil
+	}
+	publish(h, component, r)
+
+	var firstErr error
+	for _, handler := range h.handlers {
+		if err := handler.Handle(ctx, r.Clone()); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (h *componentHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	next := &componentHandler{component: h.component, attrs: append(h.attrs[:len(h.attrs):len(h.attrs)], attrs...)}
+	for _, a := range attrs {
+		if a.Key == "component" {
+			next.component = a.Value.String()
+		}
+	}
+	for _, handler := range h.handlers {
+		next.handlers = append(next.handlers, handler.WithAttrs(attrs))
 	}
-	return fmt.Sprintf(" %s:", component)
+	return next
 }
 
-func formatFields(fields map[string]any) string {
-	var parts []string
-	for k, v := range fields {
-		parts = append(parts, fmt.Sprintf("%s=%v", k, v))
+func (h *componentHandler) WithGroup(name string) slog.Handler {
+	next := &componentHandler{component: h.component, attrs: h.attrs}
+	for _, handler := range h.handlers {
+		next.handlers = append(next.handlers, handler.WithGroup(name))
+	}
+	return next
+}
+
+func logMessage(level LogLevel, component string, message string, fields map[string]any) {
+	mu.RLock()
+	enabled := level >= levelFor(component)
+	handler := logger.handler
+	mu.RUnlock()
+
+	if enabled {
+		var pcs [1]uintptr
+		runtime.Callers(3, pcs[:]) // skip Callers, logMessage and the public helper
+
+		r := slog.NewRecord(time.Now(), toSlogLevel(level), message, pcs[0])
+		if component != "" {
+			r.AddAttrs(slog.String("component", component))
+		}
+		keys := make([]string, 0, len(fields))
+		for k := range fields {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			r.AddAttrs(slog.Any(k, fields[k]))
+		}
+		_ = handler.Handle(context.Background(), r)
+	}
+
+	if level == FATAL {
+		os.Exit(1)
 	}
-	return fmt.Sprintf("{%s}", strings.Join(parts, ", "))
 }
 
 func Debug(message string) {
@@ -1,6 +1,10 @@
 package logger
 
 import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
 	"testing"
 )
 
@@ -137,3 +141,105 @@ func TestLoggerHelperFunctions(t *testing.T) {
 	DebugC("test", "Debug with component")
 	WarnF("Warning with fields", map[string]any{"key": "value"})
 }
+
+func captureOutput(t *testing.T, format Format) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+
+	mu.Lock()
+	prevOutput, prevFormat := output, currentFormat
+	output, currentFormat = &buf, format
+	rebuild()
+	mu.Unlock()
+
+	initialLevel := GetLevel()
+	t.Cleanup(func() {
+		SetLevel(initialLevel)
+		SetModuleLevels(nil)
+		mu.Lock()
+		output, currentFormat = prevOutput, prevFormat
+		rebuild()
+		mu.Unlock()
+	})
+	return &buf
+}
+
+func TestJSONFormat(t *testing.T) {
+	buf := captureOutput(t, FormatJSON)
+	SetLevel(INFO)
+
+	InfoCF("agent", "Processing message", map[string]any{"chat_id": "42", "count": 3})
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("output is not JSON: %v (%q)", err, buf.String())
+	}
+	if entry["level"] != "INFO" || entry["msg"] != "Processing message" {
+		t.Errorf("unexpected entry: %v", entry)
+	}
+	if entry["component"] != "agent" || entry["chat_id"] != "42" || entry["count"] != float64(3) {
+		t.Errorf("missing structured fields: %v", entry)
+	}
+}
+
+func TestModuleLevelOverrides(t *testing.T) {
+	buf := captureOutput(t, FormatText)
+	SetLevel(WARN)
+	SetModuleLevels(map[string]LogLevel{"channels": DEBUG, "cron": ERROR})
+
+	DebugC("channels", "channel debug")
+	InfoC("agent", "agent info")
+	WarnC("cron", "cron warning")
+	ErrorC("cron", "cron error")
+	slog.Debug("slog debug", "component", "channels")
+	slog.Info("slog info")
+
+	out := buf.String()
+	for _, want := range []string{"channel debug", "cron error", "slog debug"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in output:\n%s", want, out)
+		}
+	}
+	for _, unwanted := range []string{"agent info", "cron warning", "slog info"} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("did not expect %q in output:\n%s", unwanted, out)
+		}
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for input, want := range map[string]LogLevel{"debug": DEBUG, "INFO": INFO, "warning": WARN, "Error": ERROR} {
+		got, err := ParseLevel(input)
+		if err != nil || got != want {
+			t.Errorf("ParseLevel(%q) = %v, %v; want %v", input, got, err, want)
+		}
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("expected error for unknown level")
+	}
+	if _, err := ParseFormat("yaml"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
+
+func TestRecentErrorsKeepsLastErrors(t *testing.T) {
+	captureOutput(t, FormatText)
+	WarnC("agent", "just a warning")
+	for i := 0; i < recentErrorsSize+2; i++ {
+		ErrorCF("agent", "provider failed", map[string]any{"attempt": i})
+	}
+
+	recent := RecentErrors()
+	if len(recent) != recentErrorsSize {
+		t.Fatalf("RecentErrors() returned %d entries, want %d", len(recent), recentErrorsSize)
+	}
+	last := recent[len(recent)-1]
+	if last.Component != "agent" || last.Level != "ERROR" || last.Fields["attempt"] != int64(recentErrorsSize+1) {
+		t.Errorf("last entry = %+v, want the final error", last)
+	}
+	for _, e := range recent {
+		if e.Message != "provider failed" {
+			t.Errorf("unexpected entry %+v", e)
+		}
+	}
+}
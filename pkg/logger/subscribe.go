@@ -0,0 +1,114 @@
+package logger
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Entry is a log record as delivered to subscribers.
+type Entry struct {
+	Time      time.Time      `json:"time"`
+	Level     string         `json:"level"`
+	Component string         `json:"component,omitempty"`
+	Message   string         `json:"msg"`
+	Fields    map[string]any `json:"fields,omitempty"`
+}
+
+// recentErrorsSize is the number of error entries RecentErrors keeps.
+const recentErrorsSize = 10
+
+var (
+	subMu       sync.RWMutex
+	subscribers = map[chan Entry]struct{}{}
+
+	errMu        sync.Mutex
+	recentErrors []Entry
+)
+
+// Subscribe returns a channel receiving every record that passes the level
+// filters, and a function that ends the subscription. A subscriber that does
+// not keep up loses entries rather than blocking logging.
+func Subscribe(buffer int) (<-chan Entry, func()) {
+	ch := make(chan Entry, buffer)
+	subMu.Lock()
+	subscribers[ch] = struct{}{}
+	subMu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			subMu.Lock()
+			delete(subscribers, ch)
+			subMu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// RecentErrors returns the last error and fatal entries, oldest first.
+func RecentErrors() []Entry {
+	errMu.Lock()
+	defer errMu.Unlock()
+	return append([]Entry(nil), recentErrors...)
+}
+
+func publish(h *componentHandler, component string, r slog.Record) {
+	isError := r.Level >= slog.LevelError
+	subMu.RLock()
+	defer subMu.RUnlock()
+	if len(subscribers) == 0 && !isError {
+		return
+	}
+
+	entry := Entry{
+		Time:      r.Time,
+		Level:     slogLevelName(r.Level),
+		Component: component,
+		Message:   r.Message,
+	}
+	addField := func(a slog.Attr) bool {
+		if a.Key == "component" {
+			return true
+		}
+		if entry.Fields == nil {
+			entry.Fields = map[string]any{}
+		}
+		entry.Fields[a.Key] = a.Value.Resolve().Any()
+		return true
+	}
+	for _, a := range h.attrs {
+		addField(a)
+	}
+	r.Attrs(addField)
+
+	if isError {
+		errMu.Lock()
+		recentErrors = append(recentErrors, entry)
+		if len(recentErrors) > recentErrorsSize {
+			recentErrors = recentErrors[len(recentErrors)-recentErrorsSize:]
+		}
+		errMu.Unlock()
+	}
+	for ch := range subscribers {
+		select {
+		case ch <- entry:
+		default:
+		}
+	}
+}
+
+func slogLevelName(level slog.Level) string {
+	switch {
+	case level >= levelFatal:
+		return logLevelNames[FATAL]
+	case level >= slog.LevelError:
+		return logLevelNames[ERROR]
+	case level >= slog.LevelWarn:
+		return logLevelNames[WARN]
+	case level >= slog.LevelInfo:
+		return logLevelNames[INFO]
+	default:
+		return logLevelNames[DEBUG]
+	}
+}
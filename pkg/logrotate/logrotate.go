@@ -0,0 +1,368 @@
+// Package logrotate provides a file writer that rotates by size and age,
+// optionally gzips rotated files, and prunes old ones, so logs kept in the
+// workspace cannot fill up the SD card of an embedded device.
+package logrotate
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// timeFormat names rotated files; it sorts lexically in time order.
+const timeFormat = "20060102T150405.000000000"
+
+// Options controls when files rotate and how many are kept.
+// Zero values disable the corresponding limit.
+type Options struct {
+	MaxBytes   int64         // rotate before the file would exceed this size
+	Interval   time.Duration // rotate once the current file is this old
+	MaxBackups int           // rotated files kept
+	MaxAge     time.Duration // rotated files older than this are deleted
+	Compress   bool          // gzip rotated files
+}
+
+// Writer appends to path and rotates it to path-<timestamp><ext>[.gz].
+// It is safe for concurrent use.
+type Writer struct {
+	path string
+	opts Options
+
+	mu      sync.Mutex
+	file    *os.File
+	size    int64
+	created time.Time
+}
+
+// Open opens (or creates) path for appending with the given rotation options.
+func Open(path string, opts Options) (*Writer, error) {
+	w := &Writer{path: path, opts: opts}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Path returns the path of the file currently written to.
+func (w *Writer) Path() string {
+	return w.path
+}
+
+// Write appends p, rotating first if it would exceed MaxBytes or the file
+// is older than Interval. A single write is never split across files.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			return 0, err
+		}
+	}
+	if w.size > 0 && w.shouldRotate(int64(len(p))) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Rotate closes the current file, moves it aside and starts a new one.
+func (w *Writer) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			return err
+		}
+	}
+	return w.rotate()
+}
+
+// Sync flushes the current file to stable storage.
+func (w *Writer) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	return w.file.Sync()
+}
+
+// Close closes the current file.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+func (w *Writer) shouldRotate(next int64) bool {
+	if w.opts.MaxBytes > 0 && w.size+next > w.opts.MaxBytes {
+		return true
+	}
+	return w.opts.Interval > 0 && time.Since(w.created) >= w.opts.Interval
+}
+
+func (w *Writer) open() error {
+	if err := os.MkdirAll(filepath.Dir(w.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create log directory: %w", err)
+	}
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+	w.file = f
+	w.size = info.Size()
+	w.created = time.Now()
+	if w.size > 0 {
+		// The creation time is not portable; the last write is close enough
+		// to keep an existing file from being rotated straight away.
+		w.created = info.ModTime()
+	}
+	return nil
+}
+
+func (w *Writer) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return fmt.Errorf("failed to close log file: %w", err)
+	}
+	w.file = nil
+
+	ext := filepath.Ext(w.path)
+	base := strings.TrimSuffix(w.path, ext)
+	stamp := time.Now().UTC()
+	// Keep names strictly increasing even if the clock steps backwards, so
+	// lexical order stays the rotation order.
+	if backups, _ := Backups(w.path); len(backups) > 0 {
+		last, _ := time.Parse(timeFormat, backupStamp(base, ext, backups[len(backups)-1]))
+		if !stamp.After(last) {
+			stamp = last.Add(time.Nanosecond)
+		}
+	}
+	backup := fmt.Sprintf("%s-%s%s", base, stamp.Format(timeFormat), ext)
+	if err := os.Rename(w.path, backup); err != nil {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+
+	if w.opts.Compress {
+		// A failed compression leaves the plain backup in place; readers
+		// handle both forms.
+		compressFile(backup)
+	}
+	w.prune()
+	return w.open()
+}
+
+// prune removes backups beyond MaxBackups or older than MaxAge.
+func (w *Writer) prune() {
+	backups, err := Backups(w.path)
+	if err != nil {
+		return
+	}
+	cutoff := time.Time{}
+	if w.opts.MaxAge > 0 {
+		cutoff = time.Now().Add(-w.opts.MaxAge)
+	}
+	keep := len(backups)
+	if w.opts.MaxBackups > 0 && keep > w.opts.MaxBackups {
+		keep = w.opts.MaxBackups
+	}
+	for i, path := range backups {
+		expired := false
+		if !cutoff.IsZero() {
+			if info, err := os.Stat(path); err == nil && info.ModTime().Before(cutoff) {
+				expired = true
+			}
+		}
+		if i < len(backups)-keep || expired {
+			os.Remove(path)
+		}
+	}
+}
+
+// Backups returns the rotated files for path, oldest first.
+func Backups(path string) ([]string, error) {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	matches, err := filepath.Glob(base + "-*")
+	if err != nil {
+		return nil, err
+	}
+	var backups []string
+	for _, m := range matches {
+		if stamp := backupStamp(base, ext, m); stamp != "" {
+			backups = append(backups, m)
+		}
+	}
+	sort.Strings(backups)
+	return backups, nil
+}
+
+// backupStamp returns the timestamp part of a backup name, or "" if name is
+// not a backup of base+ext.
+func backupStamp(base, ext, name string) string {
+	name = strings.TrimSuffix(name, ".gz")
+	if !strings.HasSuffix(name, ext) || !strings.HasPrefix(name, base+"-") {
+		return ""
+	}
+	stamp := strings.TrimSuffix(strings.TrimPrefix(name, base+"-"), ext)
+	if _, err := time.Parse(timeFormat, stamp); err != nil {
+		return ""
+	}
+	return stamp
+}
+
+// Files returns the backups followed by the current file, oldest first.
+func Files(path string) ([]string, error) {
+	files, err := Backups(path)
+	if err != nil {
+		return nil, err
+	}
+	if fileExists(path) {
+		files = append(files, path)
+	}
+	return files, nil
+}
+
+// OpenReader opens a current or rotated log file, decompressing .gz backups.
+func OpenReader(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(path, ".gz") {
+		return f, nil
+	}
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{gz, multiCloser{gz, f}}, nil
+}
+
+// DropLines rewrites a current or rotated log file without the lines drop
+// selects, compressing it again if it was, and returns how many were
+// dropped. A file that no longer exists drops nothing.
+func DropLines(path string, drop func(line []byte) bool) (int, error) {
+	r, err := OpenReader(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	var kept bytes.Buffer
+	dropped := 0
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		if drop(scanner.Bytes()) {
+			dropped++
+			continue
+		}
+		kept.Write(scanner.Bytes())
+		kept.WriteByte('\n')
+	}
+	err = scanner.Err()
+	r.Close()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if dropped == 0 {
+		return 0, nil
+	}
+
+	data := kept.Bytes()
+	if strings.HasSuffix(path, ".gz") {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		gz.Write(data)
+		if err := gz.Close(); err != nil {
+			return 0, fmt.Errorf("failed to compress %s: %w", path, err)
+		}
+		data = buf.Bytes()
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return 0, fmt.Errorf("failed to rewrite %s: %w", path, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return 0, fmt.Errorf("failed to rewrite %s: %w", path, err)
+	}
+	return dropped, nil
+}
+
+type multiCloser []io.Closer
+
+func (m multiCloser) Close() error {
+	var first error
+	for _, c := range m {
+		if err := c.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		gz.Close()
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+	return os.Remove(path)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,109 @@
+package logrotate
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readAll(t *testing.T, path string) string {
+	t.Helper()
+	r, err := OpenReader(path)
+	require.NoError(t, err)
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestWriterRotatesBySizeAndKeepsBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "app.jsonl")
+	w, err := Open(path, Options{MaxBytes: 100, MaxBackups: 2})
+	require.NoError(t, err)
+	defer w.Close()
+
+	for i := 0; i < 10; i++ {
+		_, err := w.Write([]byte(strings.Repeat(string(rune('a'+i)), 59) + "\n"))
+		require.NoError(t, err)
+	}
+
+	backups, err := Backups(path)
+	require.NoError(t, err)
+	require.Len(t, backups, 2)
+	assert.Equal(t, strings.Repeat("i", 59)+"\n", readAll(t, backups[1]), "newest backup last")
+	assert.Equal(t, strings.Repeat("j", 59)+"\n", readAll(t, path))
+
+	files, err := Files(path)
+	require.NoError(t, err)
+	assert.Equal(t, append(backups, path), files)
+}
+
+func TestWriterCompressesBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	w, err := Open(path, Options{Compress: true})
+	require.NoError(t, err)
+	defer w.Close()
+
+	_, err = w.Write([]byte("first\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Rotate())
+	_, err = w.Write([]byte("second\n"))
+	require.NoError(t, err)
+
+	backups, err := Backups(path)
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+	assert.True(t, strings.HasSuffix(backups[0], ".jsonl.gz"))
+	assert.Equal(t, "first\n", readAll(t, backups[0]))
+	assert.Equal(t, "second\n", readAll(t, path))
+}
+
+func TestWriterRotatesByInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	require.NoError(t, os.WriteFile(path, []byte("old\n"), 0o600))
+	old := time.Now().Add(-2 * time.Hour)
+	require.NoError(t, os.Chtimes(path, old, old))
+
+	w, err := Open(path, Options{Interval: time.Hour})
+	require.NoError(t, err)
+	defer w.Close()
+
+	_, err = w.Write([]byte("new\n"))
+	require.NoError(t, err)
+
+	backups, err := Backups(path)
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+	assert.Equal(t, "old\n", readAll(t, backups[0]))
+	assert.Equal(t, "new\n", readAll(t, path))
+}
+
+func TestPruneRemovesExpiredBackups(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	stale := filepath.Join(dir, "app-20200101T000000.000000000.log")
+	require.NoError(t, os.WriteFile(stale, []byte("stale\n"), 0o600))
+	old := time.Now().Add(-48 * time.Hour)
+	require.NoError(t, os.Chtimes(stale, old, old))
+	unrelated := filepath.Join(dir, "app-notes.log")
+	require.NoError(t, os.WriteFile(unrelated, nil, 0o600))
+
+	w, err := Open(path, Options{MaxAge: 24 * time.Hour})
+	require.NoError(t, err)
+	defer w.Close()
+	_, err = w.Write([]byte("x\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Rotate())
+
+	backups, err := Backups(path)
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+	assert.NotEqual(t, stale, backups[0])
+	assert.FileExists(t, unrelated, "files that are not backups are left alone")
+}
@@ -0,0 +1,215 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// defaultCallTimeout bounds a call to an external server's tool when its
+// config sets no timeout.
+const defaultCallTimeout = 60 * time.Second
+
+// incoming is a message from a server: a response to one of our requests,
+// or a request or notification of its own.
+type incoming struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+// transport carries messages to a server and back.
+type transport interface {
+	// roundTrip sends a request and waits for its response.
+	roundTrip(ctx context.Context, req *request) (*incoming, error)
+	// notify sends a notification.
+	notify(ctx context.Context, req *request) error
+	close() error
+}
+
+// Client calls the tools of an external MCP server.
+type Client struct {
+	name      string
+	transport transport
+	timeout   time.Duration
+	allowed   map[string]bool // nil allows every tool
+	confirm   map[string]bool // "*" for every tool
+	nextID    atomic.Int64
+}
+
+// RemoteTool describes a tool of an external server.
+type RemoteTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"inputSchema"`
+}
+
+// Connect starts or reaches the server of cfg and initializes the session,
+// introducing itself as picoclaw version.
+func Connect(ctx context.Context, cfg config.MCPServerConfig, version string) (*Client, error) {
+	var t transport
+	var err error
+	switch {
+	case cfg.Command != "":
+		t, err = startStdio(cfg.Name, cfg.Command, cfg.Args, cfg.Env)
+	case cfg.URL != "":
+		t = newHTTPTransport(cfg.URL, cfg.Headers)
+	default:
+		err = errors.New("command or url is required")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("mcp server %s: %w", cfg.Name, err)
+	}
+
+	c := &Client{name: cfg.Name, transport: t, timeout: defaultCallTimeout, confirm: map[string]bool{}}
+	if cfg.TimeoutSeconds > 0 {
+		c.timeout = time.Duration(cfg.TimeoutSeconds) * time.Second
+	}
+	if len(cfg.Tools) > 0 {
+		c.allowed = make(map[string]bool, len(cfg.Tools))
+		for _, name := range cfg.Tools {
+			c.allowed[name] = true
+		}
+	}
+	for _, name := range cfg.Confirm {
+		c.confirm[name] = true
+	}
+	var init struct {
+		ProtocolVersion string `json:"protocolVersion"`
+		ServerInfo      struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"serverInfo"`
+	}
+	err = c.call(ctx, "initialize", map[string]any{
+		"protocolVersion": ProtocolVersion,
+		"capabilities":    map[string]any{},
+		"clientInfo":      map[string]any{"name": "picoclaw", "version": version},
+	}, &init)
+	if err == nil {
+		err = t.notify(ctx, &request{JSONRPC: "2.0", Method: "notifications/initialized"})
+	}
+	if err != nil {
+		t.close()
+		return nil, fmt.Errorf("mcp server %s: initialize: %w", cfg.Name, err)
+	}
+	logger.InfoCF("mcp", "Connected to MCP server", map[string]any{
+		"server":           cfg.Name,
+		"server_name":      init.ServerInfo.Name,
+		"server_version":   init.ServerInfo.Version,
+		"protocol_version": init.ProtocolVersion,
+	})
+	return c, nil
+}
+
+// Name returns the server's name from the config.
+func (c *Client) Name() string {
+	return c.name
+}
+
+// Close ends the session, stopping a server started by Connect.
+func (c *Client) Close() error {
+	return c.transport.close()
+}
+
+// call sends a request and decodes its result into out.
+func (c *Client) call(ctx context.Context, method string, params, out any) error {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	id := json.RawMessage(fmt.Sprint(c.nextID.Add(1)))
+	resp, err := c.transport.roundTrip(ctx, &request{JSONRPC: "2.0", Method: method, Params: raw, ID: id})
+	if err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(resp.Result, out)
+}
+
+// ListTools returns the server's tools, following pagination.
+func (c *Client) ListTools(ctx context.Context) ([]RemoteTool, error) {
+	var all []RemoteTool
+	cursor := ""
+	for {
+		params := map[string]any{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		var page struct {
+			Tools      []RemoteTool `json:"tools"`
+			NextCursor string       `json:"nextCursor"`
+		}
+		if err := c.call(ctx, "tools/list", params, &page); err != nil {
+			return nil, err
+		}
+		all = append(all, page.Tools...)
+		if page.NextCursor == "" || page.NextCursor == cursor {
+			return all, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// CallResult is the outcome of a tool call. Text joins the text of its
+// content; other content is described in brackets.
+type CallResult struct {
+	Text    string
+	IsError bool
+}
+
+// CallTool runs a tool of the server.
+func (c *Client) CallTool(ctx context.Context, name string, args map[string]any) (*CallResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	var result struct {
+		Content []struct {
+			Type     string `json:"type"`
+			Text     string `json:"text"`
+			MimeType string `json:"mimeType"`
+			Resource struct {
+				URI  string `json:"uri"`
+				Text string `json:"text"`
+			} `json:"resource"`
+		} `json:"content"`
+		IsError bool `json:"isError"`
+	}
+	if err := c.call(ctx, "tools/call", map[string]any{"name": name, "arguments": args}, &result); err != nil {
+		return nil, err
+	}
+
+	var text []byte
+	for i, item := range result.Content {
+		if i > 0 {
+			text = append(text, '\n')
+		}
+		switch {
+		case item.Type == "text":
+			text = append(text, item.Text...)
+		case item.Type == "resource" && item.Resource.Text != "":
+			text = append(text, item.Resource.Text...)
+		case item.Type == "resource":
+			text = fmt.Appendf(text, "[resource %s]", item.Resource.URI)
+		default:
+			text = fmt.Appendf(text, "[%s %s]", item.Type, item.MimeType)
+		}
+	}
+	return &CallResult{Text: string(text), IsError: result.IsError}, nil
+}
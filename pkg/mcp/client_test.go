@@ -0,0 +1,111 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// TestMain runs the test binary as an MCP server over stdio when asked, so
+// the stdio transport can start a real process.
+func TestMain(m *testing.M) {
+	if os.Getenv("PICOCLAW_TEST_MCP_SERVER") == "1" {
+		newTestServer().ServeStdio(context.Background(), os.Stdin, os.Stdout)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func TestClientStdio(t *testing.T) {
+	exe, err := os.Executable()
+	require.NoError(t, err)
+	ctx := context.Background()
+	client, err := Connect(ctx, config.MCPServerConfig{
+		Name:    "local",
+		Command: exe,
+		Env:     map[string]string{"PICOCLAW_TEST_MCP_SERVER": "1"},
+		Confirm: []string{"*"},
+	}, "test")
+	require.NoError(t, err)
+	defer client.Close()
+
+	remote, err := client.Tools(ctx)
+	require.NoError(t, err)
+	require.Len(t, remote, 1)
+	tool := remote[0]
+	assert.Equal(t, "mcp_local_echo", tool.Name())
+	assert.Contains(t, tool.Description(), "confirm with the user")
+	assert.Contains(t, tool.Parameters()["properties"], "confirm")
+	assert.NotContains(t, remote[0].remote.InputSchema["properties"], "confirm")
+
+	result := tool.Execute(ctx, map[string]any{"text": "hi"})
+	assert.True(t, result.IsError)
+	assert.Contains(t, result.ForLLM, "requires confirm: true")
+
+	result = tool.Execute(ctx, map[string]any{"text": "hi", "confirm": true})
+	assert.False(t, result.IsError, result.ForLLM)
+	assert.Equal(t, "hi", result.ForLLM)
+
+	result = tool.Execute(ctx, map[string]any{"confirm": true})
+	assert.True(t, result.IsError)
+	assert.Equal(t, "text is required", result.ForLLM)
+}
+
+func TestClientHTTP(t *testing.T) {
+	srv := httptest.NewServer(newTestServer().Handler("/mcp"))
+	defer srv.Close()
+	ctx := context.Background()
+
+	client, err := Connect(ctx, config.MCPServerConfig{
+		Name:  "remote box",
+		URL:   srv.URL + "/mcp",
+		Tools: []string{"echo", "nope"},
+	}, "test")
+	require.NoError(t, err)
+	defer client.Close()
+
+	remote, err := client.Tools(ctx)
+	require.NoError(t, err)
+	require.Len(t, remote, 1)
+	assert.Equal(t, "mcp_remote_box_echo", remote[0].Name())
+	result := remote[0].Execute(ctx, map[string]any{"text": "over http"})
+	assert.Equal(t, "over http", result.ForLLM)
+
+	_, err = client.CallTool(ctx, "exec", nil)
+	assert.ErrorContains(t, err, "unknown tool: exec")
+
+	_, err = Connect(ctx, config.MCPServerConfig{Name: "empty"}, "test")
+	assert.ErrorContains(t, err, "command or url is required")
+}
+
+func TestClientHTTPEventStream(t *testing.T) {
+	// A server answering in an event stream, after a message of its own.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) == `{"jsonrpc":"2.0","method":"notifications/initialized"}` {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		assert.Equal(t, "application/json, text/event-stream", r.Header.Get("Accept"))
+		if r.Header.Get("Mcp-Session-Id") == "" {
+			w.Header().Set("Mcp-Session-Id", "s1")
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "event: message\ndata: {\"jsonrpc\": \"2.0\", \"method\": \"notifications/progress\"}\n\n")
+		fmt.Fprint(w, "event: message\ndata: {\"jsonrpc\": \"2.0\", \"id\": 1,\ndata: \"result\": {}}\n\n")
+	}))
+	defer srv.Close()
+
+	client, err := Connect(context.Background(), config.MCPServerConfig{Name: "sse", URL: srv.URL}, "test")
+	require.NoError(t, err)
+	assert.Equal(t, "s1", client.transport.(*httpTransport).sessionID)
+}
@@ -0,0 +1,154 @@
+package mcp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	// maxBody bounds a message posted over HTTP.
+	maxBody = 1 << 20
+	// sseKeepAlive is how often an idle HTTP+SSE stream gets a comment, so
+	// proxies keep it open.
+	sseKeepAlive = 30 * time.Second
+	// sseBuffer is how many responses an HTTP+SSE stream holds before the
+	// calls answering more wait for the client.
+	sseBuffer = 16
+)
+
+// sseStream is an open HTTP+SSE stream.
+type sseStream struct {
+	ctx       context.Context // done when the stream closes
+	responses chan []byte
+}
+
+// Handler serves the HTTP transports under prefix:
+//
+//   - POST prefix takes a message and answers it in the response body, as
+//     in the streamable HTTP transport.
+//   - GET prefix/sse opens an HTTP+SSE stream. Its first "endpoint" event
+//     names the URL to post messages to; their responses are sent on the
+//     stream as "message" events.
+//
+// The caller authenticates requests.
+func (s *Server) Handler(prefix string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+prefix, s.postHandler)
+	mux.HandleFunc("GET "+prefix+"/sse", func(w http.ResponseWriter, r *http.Request) {
+		s.sseHandler(w, r, prefix+"/messages")
+	})
+	mux.HandleFunc("POST "+prefix+"/messages", s.messagesHandler)
+	return mux
+}
+
+func readBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBody+1))
+	if err != nil || len(body) > maxBody {
+		http.Error(w, "request too large", http.StatusRequestEntityTooLarge)
+		return nil, false
+	}
+	return body, true
+}
+
+// postHandler answers a message in the response body. Notifications and
+// responses are acknowledged with 202.
+func (s *Server) postHandler(w http.ResponseWriter, r *http.Request) {
+	body, ok := readBody(w, r)
+	if !ok {
+		return
+	}
+	out := s.Handle(r.Context(), body)
+	if out == nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(out)
+}
+
+// sseHandler streams the responses to the messages posted to endpoint with
+// the stream's session ID.
+func (s *Server) sseHandler(w http.ResponseWriter, r *http.Request, endpoint string) {
+	// The stream outlives the server's write timeout.
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && err != http.ErrNotSupported {
+		return
+	}
+
+	id := newSessionID()
+	stream := &sseStream{ctx: r.Context(), responses: make(chan []byte, sseBuffer)}
+	s.mu.Lock()
+	s.sessions[id] = stream
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.sessions, id)
+		s.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "event: endpoint\ndata: %s?session_id=%s\n\n", endpoint, id)
+	rc.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			rc.Flush()
+		case out := <-stream.responses:
+			if _, err := fmt.Fprintf(w, "event: message\ndata: %s\n\n", out); err != nil {
+				return
+			}
+			rc.Flush()
+		}
+	}
+}
+
+// messagesHandler takes a message for an HTTP+SSE stream and acknowledges
+// it with 202. The message is handled in the background, for as long as the
+// stream is open, and its response is sent on the stream.
+func (s *Server) messagesHandler(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	stream, ok := s.sessions[r.URL.Query().Get("session_id")]
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, "unknown session", http.StatusNotFound)
+		return
+	}
+	body, ok := readBody(w, r)
+	if !ok {
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+
+	go func() {
+		out := s.Handle(stream.ctx, body)
+		if out == nil {
+			return
+		}
+		select {
+		case stream.responses <- out:
+		case <-stream.ctx.Done():
+		}
+	}()
+}
+
+func newSessionID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,375 @@
+// Package mcp serves an agent's tools and skills over the Model Context
+// Protocol, so external agents such as Claude Desktop can call them
+// directly. Tools are offered as MCP tools and skills as MCP prompts.
+//
+// The server speaks JSON-RPC 2.0 over stdio (ServeStdio) and HTTP
+// (Handler), both the streamable HTTP transport and the older HTTP+SSE
+// one.
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/skills"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+const (
+	// ProtocolVersion is the newest protocol revision the server speaks.
+	ProtocolVersion = "2025-03-26"
+	// Channel is the channel tools are run on.
+	Channel = "mcp"
+)
+
+// supportedVersions are the protocol revisions the server accepts, newest
+// first.
+var supportedVersions = []string{ProtocolVersion, "2024-11-05"}
+
+// JSON-RPC error codes.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"` // absent for notifications
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Skills lists and loads skills, like skills.SkillsLoader.
+type Skills interface {
+	ListSkills() []skills.SkillInfo
+	LoadSkill(name string) (string, bool)
+}
+
+// Server answers MCP requests with the tools of a registry and, optionally,
+// a set of skills.
+type Server struct {
+	name    string
+	version string
+	tools   *tools.ToolRegistry
+	skills  Skills
+	allowed map[string]bool // nil allows every tool
+
+	mu       sync.Mutex
+	sessions map[string]*sseStream // by session ID
+}
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithSkills offers skills as prompts.
+func WithSkills(sk Skills) Option {
+	return func(s *Server) {
+		s.skills = sk
+	}
+}
+
+// WithTools limits the tools offered to names. An empty list offers all.
+func WithTools(names []string) Option {
+	return func(s *Server) {
+		if len(names) == 0 {
+			return
+		}
+		s.allowed = make(map[string]bool, len(names))
+		for _, name := range names {
+			s.allowed[name] = true
+		}
+	}
+}
+
+// NewServer creates a server that introduces itself as name and version and
+// offers the tools of registry.
+func NewServer(name, version string, registry *tools.ToolRegistry, opts ...Option) *Server {
+	s := &Server{
+		name:     name,
+		version:  version,
+		tools:    registry,
+		sessions: make(map[string]*sseStream),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Handle answers a JSON-RPC message, a single call or a batch, and returns
+// the response body, or nil when the message needs no response: a
+// notification, a client's response, or a batch of them.
+func (s *Server) Handle(ctx context.Context, body []byte) []byte {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 || body[0] != '[' {
+		resp := s.call(ctx, body)
+		if resp == nil {
+			return nil
+		}
+		out, _ := json.Marshal(resp)
+		return out
+	}
+
+	var batch []json.RawMessage
+	if err := json.Unmarshal(body, &batch); err != nil {
+		out, _ := json.Marshal(failure(nil, codeParseError, "parse error"))
+		return out
+	}
+	if len(batch) == 0 {
+		out, _ := json.Marshal(failure(nil, codeInvalidRequest, "empty batch"))
+		return out
+	}
+	responses := make([]*response, len(batch))
+	var wg sync.WaitGroup
+	for i, msg := range batch {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			responses[i] = s.call(ctx, msg)
+		}()
+	}
+	wg.Wait()
+
+	out := make([]*response, 0, len(responses))
+	for _, resp := range responses {
+		if resp != nil {
+			out = append(out, resp)
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	data, _ := json.Marshal(out)
+	return data
+}
+
+// call answers one message and returns its response, or nil for a
+// notification or a response from the client.
+func (s *Server) call(ctx context.Context, raw json.RawMessage) *response {
+	var req request
+	if err := json.Unmarshal(raw, &req); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) || len(raw) == 0 {
+			return failure(nil, codeParseError, "parse error")
+		}
+		return failure(nil, codeInvalidRequest, "invalid request")
+	}
+	if req.JSONRPC != "2.0" {
+		return failure(req.ID, codeInvalidRequest, "invalid request")
+	}
+	if req.Method == "" {
+		// A response to a request of ours; the server sends none.
+		return nil
+	}
+
+	var result any
+	var rpcErr *rpcError
+	switch req.Method {
+	case "initialize":
+		result, rpcErr = s.initialize(req.Params)
+	case "ping":
+		result = struct{}{}
+	case "tools/list":
+		result = map[string]any{"tools": s.listTools()}
+	case "tools/call":
+		result, rpcErr = s.callTool(ctx, req.Params)
+	case "prompts/list":
+		result = map[string]any{"prompts": s.listPrompts()}
+	case "prompts/get":
+		result, rpcErr = s.getPrompt(req.Params)
+	default:
+		rpcErr = &rpcError{Code: codeMethodNotFound, Message: "method not found: " + req.Method}
+	}
+	if req.ID == nil {
+		return nil
+	}
+	if rpcErr != nil {
+		return &response{JSONRPC: "2.0", Error: rpcErr, ID: req.ID}
+	}
+	return &response{JSONRPC: "2.0", Result: result, ID: req.ID}
+}
+
+func failure(id json.RawMessage, code int, msg string) *response {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	return &response{JSONRPC: "2.0", Error: &rpcError{Code: code, Message: msg}, ID: id}
+}
+
+// decodeParams reads params into v. Absent params leave v as is.
+func decodeParams(params json.RawMessage, v any) *rpcError {
+	if len(params) == 0 || string(params) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return &rpcError{Code: codeInvalidParams, Message: "invalid params: " + err.Error()}
+	}
+	return nil
+}
+
+// initialize agrees on the protocol revision: the client's if the server
+// speaks it, otherwise the server's newest.
+func (s *Server) initialize(params json.RawMessage) (any, *rpcError) {
+	var p struct {
+		ProtocolVersion string `json:"protocolVersion"`
+		ClientInfo      struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"clientInfo"`
+	}
+	if rpcErr := decodeParams(params, &p); rpcErr != nil {
+		return nil, rpcErr
+	}
+	version := ProtocolVersion
+	if slices.Contains(supportedVersions, p.ProtocolVersion) {
+		version = p.ProtocolVersion
+	}
+	logger.InfoCF("mcp", "Client connected", map[string]any{
+		"client":           p.ClientInfo.Name,
+		"client_version":   p.ClientInfo.Version,
+		"protocol_version": version,
+	})
+
+	capabilities := map[string]any{"tools": map[string]any{}}
+	if s.skills != nil {
+		capabilities["prompts"] = map[string]any{}
+	}
+	return map[string]any{
+		"protocolVersion": version,
+		"capabilities":    capabilities,
+		"serverInfo":      map[string]any{"name": s.name, "version": s.version},
+	}, nil
+}
+
+type toolInfo struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"inputSchema"`
+}
+
+// listTools describes the tools offered, sorted by name.
+func (s *Server) listTools() []toolInfo {
+	names := s.tools.List()
+	sort.Strings(names)
+	out := make([]toolInfo, 0, len(names))
+	for _, name := range names {
+		tool, ok := s.tools.Get(name)
+		if !ok || !s.offers(name) {
+			continue
+		}
+		schema := tool.Parameters()
+		if schema == nil {
+			schema = map[string]any{"type": "object"}
+		}
+		out = append(out, toolInfo{Name: name, Description: tool.Description(), InputSchema: schema})
+	}
+	return out
+}
+
+func (s *Server) offers(tool string) bool {
+	return s.allowed == nil || s.allowed[tool]
+}
+
+type content struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// callTool runs a tool, scoped to _meta.business_id if given. A tool that
+// fails answers a result with isError set, so the calling model sees what
+// went wrong; only an unknown tool or malformed arguments are protocol
+// errors.
+func (s *Server) callTool(ctx context.Context, params json.RawMessage) (any, *rpcError) {
+	var p struct {
+		Name      string         `json:"name"`
+		Arguments map[string]any `json:"arguments"`
+		Meta      struct {
+			BusinessID string `json:"business_id"`
+		} `json:"_meta"`
+	}
+	if rpcErr := decodeParams(params, &p); rpcErr != nil {
+		return nil, rpcErr
+	}
+	if _, ok := s.tools.Get(p.Name); !ok || !s.offers(p.Name) {
+		return nil, &rpcError{Code: codeInvalidParams, Message: "unknown tool: " + p.Name}
+	}
+	if p.Arguments == nil {
+		p.Arguments = map[string]any{}
+	}
+	if p.Meta.BusinessID != "" {
+		ctx = context.WithValue(ctx, constants.ContextKeyBusinessID, p.Meta.BusinessID)
+	}
+
+	result := s.tools.ExecuteWithContext(ctx, p.Name, p.Arguments, Channel, "client", nil)
+	return map[string]any{
+		"content": []content{{Type: "text", Text: result.ForLLM}},
+		"isError": result.IsError,
+	}, nil
+}
+
+type promptInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// listPrompts describes the skills offered.
+func (s *Server) listPrompts() []promptInfo {
+	out := []promptInfo{}
+	if s.skills == nil {
+		return out
+	}
+	for _, skill := range s.skills.ListSkills() {
+		out = append(out, promptInfo{Name: skill.Name, Description: skill.Description})
+	}
+	return out
+}
+
+// getPrompt returns a skill's instructions as a user message.
+func (s *Server) getPrompt(params json.RawMessage) (any, *rpcError) {
+	var p struct {
+		Name string `json:"name"`
+	}
+	if rpcErr := decodeParams(params, &p); rpcErr != nil {
+		return nil, rpcErr
+	}
+	if s.skills == nil {
+		return nil, &rpcError{Code: codeInvalidParams, Message: "unknown prompt: " + p.Name}
+	}
+	var description string
+	for _, skill := range s.skills.ListSkills() {
+		if skill.Name == p.Name {
+			description = skill.Description
+		}
+	}
+	text, ok := s.skills.LoadSkill(p.Name)
+	if !ok {
+		return nil, &rpcError{Code: codeInvalidParams, Message: "unknown prompt: " + p.Name}
+	}
+	return map[string]any{
+		"description": description,
+		"messages": []map[string]any{
+			{"role": "user", "content": content{Type: "text", Text: text}},
+		},
+	}, nil
+}
@@ -0,0 +1,214 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/skills"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// echoTool answers with its text argument, or fails without one.
+type echoTool struct{ name string }
+
+func (t echoTool) Name() string        { return t.name }
+func (t echoTool) Description() string { return "Echoes text" }
+func (t echoTool) Parameters() map[string]any {
+	return map[string]any{
+		"type":       "object",
+		"properties": map[string]any{"text": map[string]any{"type": "string"}},
+	}
+}
+
+func (t echoTool) Execute(ctx context.Context, args map[string]any) *tools.ToolResult {
+	text, _ := args["text"].(string)
+	if text == "" {
+		return tools.ErrorResult("text is required")
+	}
+	return tools.NewToolResult(text)
+}
+
+type fakeSkills map[string]string
+
+func (f fakeSkills) ListSkills() []skills.SkillInfo {
+	return []skills.SkillInfo{{Name: "receipts", Description: "Files receipts"}}
+}
+
+func (f fakeSkills) LoadSkill(name string) (string, bool) {
+	text, ok := f[name]
+	return text, ok
+}
+
+func newTestServer() *Server {
+	registry := tools.NewToolRegistry()
+	registry.Register(echoTool{name: "echo"})
+	registry.Register(echoTool{name: "exec"})
+	return NewServer("picoclaw", "1.0", registry,
+		WithSkills(fakeSkills{"receipts": "Read the receipt."}),
+		WithTools([]string{"echo"}))
+}
+
+func handle(t *testing.T, s *Server, body string) map[string]any {
+	t.Helper()
+	out := s.Handle(context.Background(), []byte(body))
+	require.NotNil(t, out)
+	var resp map[string]any
+	require.NoError(t, json.Unmarshal(out, &resp), string(out))
+	return resp
+}
+
+func TestHandle(t *testing.T) {
+	s := newTestServer()
+
+	resp := handle(t, s, `{"jsonrpc": "2.0", "id": 1, "method": "initialize",
+		"params": {"protocolVersion": "2024-11-05", "clientInfo": {"name": "test"}}}`)
+	assert.Equal(t, map[string]any{
+		"protocolVersion": "2024-11-05",
+		"capabilities":    map[string]any{"tools": map[string]any{}, "prompts": map[string]any{}},
+		"serverInfo":      map[string]any{"name": "picoclaw", "version": "1.0"},
+	}, resp["result"])
+	resp = handle(t, s, `{"jsonrpc": "2.0", "id": 1, "method": "initialize", "params": {"protocolVersion": "1999"}}`)
+	assert.Equal(t, ProtocolVersion, resp["result"].(map[string]any)["protocolVersion"])
+
+	assert.Nil(t, s.Handle(context.Background(), []byte(`{"jsonrpc": "2.0", "method": "notifications/initialized"}`)))
+
+	resp = handle(t, s, `{"jsonrpc": "2.0", "id": "l", "method": "tools/list"}`)
+	listed := resp["result"].(map[string]any)["tools"].([]any)
+	require.Len(t, listed, 1, "exec is not offered")
+	assert.Equal(t, "echo", listed[0].(map[string]any)["name"])
+	assert.NotNil(t, listed[0].(map[string]any)["inputSchema"])
+
+	resp = handle(t, s, `{"jsonrpc": "2.0", "id": 2, "method": "tools/call",
+		"params": {"name": "echo", "arguments": {"text": "hi"}}}`)
+	assert.Equal(t, map[string]any{
+		"content": []any{map[string]any{"type": "text", "text": "hi"}},
+		"isError": false,
+	}, resp["result"])
+	resp = handle(t, s, `{"jsonrpc": "2.0", "id": 3, "method": "tools/call", "params": {"name": "echo"}}`)
+	assert.Equal(t, true, resp["result"].(map[string]any)["isError"])
+	resp = handle(t, s, `{"jsonrpc": "2.0", "id": 4, "method": "tools/call", "params": {"name": "exec"}}`)
+	assert.Equal(t, float64(codeInvalidParams), resp["error"].(map[string]any)["code"])
+
+	resp = handle(t, s, `{"jsonrpc": "2.0", "id": 5, "method": "prompts/list"}`)
+	assert.Equal(t, []any{map[string]any{"name": "receipts", "description": "Files receipts"}},
+		resp["result"].(map[string]any)["prompts"])
+	resp = handle(t, s, `{"jsonrpc": "2.0", "id": 6, "method": "prompts/get", "params": {"name": "receipts"}}`)
+	assert.Equal(t, map[string]any{
+		"description": "Files receipts",
+		"messages": []any{map[string]any{
+			"role":    "user",
+			"content": map[string]any{"type": "text", "text": "Read the receipt."},
+		}},
+	}, resp["result"])
+
+	resp = handle(t, s, `{"jsonrpc": "2.0", "id": 7, "method": "resources/list"}`)
+	assert.Equal(t, float64(codeMethodNotFound), resp["error"].(map[string]any)["code"])
+	resp = handle(t, s, `{"jsonrpc": "2.0", "id"`)
+	assert.Equal(t, float64(codeParseError), resp["error"].(map[string]any)["code"])
+
+	out := s.Handle(context.Background(), []byte(`[
+		{"jsonrpc": "2.0", "id": 1, "method": "ping"},
+		{"jsonrpc": "2.0", "method": "notifications/initialized"},
+		{"jsonrpc": "2.0", "id": 2, "method": "tools/list"}
+	]`))
+	var batch []map[string]any
+	require.NoError(t, json.Unmarshal(out, &batch))
+	require.Len(t, batch, 2)
+	assert.Equal(t, map[string]any{}, batch[0]["result"])
+	assert.Equal(t, float64(2), batch[1]["id"])
+}
+
+func TestServeStdio(t *testing.T) {
+	s := newTestServer()
+	in := strings.NewReader(`{"jsonrpc": "2.0", "id": 1, "method": "ping"}` + "\n\n" +
+		`{"jsonrpc": "2.0", "method": "notifications/initialized"}` + "\n" +
+		`{"jsonrpc": "2.0", "id": 2, "method": "tools/call", "params": {"name": "echo", "arguments": {"text": "hi"}}}`)
+	var out strings.Builder
+	require.NoError(t, s.ServeStdio(context.Background(), in, &out))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+	ids := map[float64]bool{}
+	for _, line := range lines {
+		var resp map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &resp))
+		ids[resp["id"].(float64)] = true
+	}
+	assert.Equal(t, map[float64]bool{1: true, 2: true}, ids)
+}
+
+func TestHandler(t *testing.T) {
+	srv := httptest.NewServer(newTestServer().Handler("/mcp"))
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/mcp", "application/json",
+		strings.NewReader(`{"jsonrpc": "2.0", "id": 1, "method": "ping"}`))
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	assert.JSONEq(t, `{"jsonrpc": "2.0", "id": 1, "result": {}}`, string(body))
+
+	resp, err = http.Post(srv.URL+"/mcp", "application/json",
+		strings.NewReader(`{"jsonrpc": "2.0", "method": "notifications/initialized"}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	resp, err = http.Post(srv.URL+"/mcp/messages?session_id=nope", "application/json", strings.NewReader(`{}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// HTTP+SSE: the stream names the endpoint, then carries the responses.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/mcp/sse", nil)
+	stream, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer stream.Body.Close()
+	assert.Equal(t, "text/event-stream", stream.Header.Get("Content-Type"))
+	events := bufio.NewReader(stream.Body)
+	next := func() (string, string) {
+		var event, data string
+		for {
+			line, err := events.ReadString('\n')
+			require.NoError(t, err)
+			line = strings.TrimSuffix(line, "\n")
+			if line == "" && event != "" {
+				return event, data
+			}
+			if v, ok := strings.CutPrefix(line, "event: "); ok {
+				event = v
+			}
+			if v, ok := strings.CutPrefix(line, "data: "); ok {
+				data = v
+			}
+		}
+	}
+
+	event, endpoint := next()
+	require.Equal(t, "endpoint", event)
+	require.True(t, strings.HasPrefix(endpoint, "/mcp/messages?session_id="), endpoint)
+	resp, err = http.Post(srv.URL+endpoint, "application/json",
+		strings.NewReader(`{"jsonrpc": "2.0", "id": 9, "method": "tools/call",
+			"params": {"name": "echo", "arguments": {"text": "over sse"}}}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	event, data := next()
+	assert.Equal(t, "message", event)
+	assert.JSONEq(t, `{"jsonrpc": "2.0", "id": 9,
+		"result": {"content": [{"type": "text", "text": "over sse"}], "isError": false}}`, data)
+}
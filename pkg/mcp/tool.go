@@ -0,0 +1,111 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"regexp"
+
+	"github.com/sipeed/picoclaw/pkg/tools"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// maxToolResultChars bounds the result of an external tool sent to the
+// model.
+const maxToolResultChars = 20000
+
+// invalidNameChars are those model APIs don't accept in tool names.
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// Tool is a tool of an external server, offered to the agent as
+// mcp_<server>_<tool>. A tool that needs confirmation only runs with
+// confirm: true, so the model has to ask the user first.
+type Tool struct {
+	client  *Client
+	remote  RemoteTool
+	name    string
+	confirm bool
+}
+
+// ToolName is the name a tool of server is registered under.
+func ToolName(server, tool string) string {
+	name := invalidNameChars.ReplaceAllString("mcp_"+server+"_"+tool, "_")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+// Tools lists the server's tools that its config allows, ready to register.
+func (c *Client) Tools(ctx context.Context) ([]*Tool, error) {
+	remote, err := c.ListTools(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*Tool, 0, len(remote))
+	for _, rt := range remote {
+		if c.allowed != nil && !c.allowed[rt.Name] {
+			continue
+		}
+		out = append(out, &Tool{
+			client:  c,
+			remote:  rt,
+			name:    ToolName(c.name, rt.Name),
+			confirm: c.confirm["*"] || c.confirm[rt.Name],
+		})
+	}
+	return out, nil
+}
+
+func (t *Tool) Name() string {
+	return t.name
+}
+
+func (t *Tool) Description() string {
+	desc := fmt.Sprintf("[%s MCP server] %s", t.client.name, t.remote.Description)
+	if t.confirm {
+		desc += " Requires confirm: true; confirm with the user before calling it."
+	}
+	return desc
+}
+
+func (t *Tool) Parameters() map[string]any {
+	params := maps.Clone(t.remote.InputSchema)
+	if params == nil {
+		params = map[string]any{"type": "object"}
+	}
+	if t.confirm {
+		props, _ := params["properties"].(map[string]any)
+		props = maps.Clone(props)
+		if props == nil {
+			props = map[string]any{}
+		}
+		props["confirm"] = map[string]any{
+			"type":        "boolean",
+			"description": "Must be true, once the user has agreed to this call.",
+		}
+		params["properties"] = props
+	}
+	return params
+}
+
+func (t *Tool) Execute(ctx context.Context, args map[string]any) *tools.ToolResult {
+	if t.confirm {
+		if confirmed, _ := args["confirm"].(bool); !confirmed {
+			return tools.ErrorResult(fmt.Sprintf(
+				"%s requires confirm: true. Please confirm with the user before calling it.", t.name))
+		}
+		args = maps.Clone(args)
+		delete(args, "confirm")
+	}
+
+	result, err := t.client.CallTool(ctx, t.remote.Name, args)
+	if err != nil {
+		return tools.ErrorResult(fmt.Sprintf("MCP server %s: %v", t.client.name, err)).WithError(err)
+	}
+	text := utils.Truncate(result.Text, maxToolResultChars)
+	if result.IsError {
+		return tools.ErrorResult(text)
+	}
+	return tools.SilentResult(text)
+}
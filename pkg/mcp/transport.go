@@ -0,0 +1,319 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/httpclient"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// closeTimeout bounds closing a session: how long a server started over
+// stdio gets to exit before it is killed, or how long ending an HTTP session
+// may take.
+const closeTimeout = 5 * time.Second
+
+// errClosed fails the calls waiting on a server that has exited.
+var errClosed = errors.New("server closed the connection")
+
+// stdioTransport speaks to a server it started over the server's stdin and
+// stdout. The server's stderr is logged.
+type stdioTransport struct {
+	name  string
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	pending map[string]chan *incoming // by request ID
+	done    chan struct{}             // closed when stdout ends
+}
+
+func startStdio(name, command string, args []string, env map[string]string) (*stdioTransport, error) {
+	cmd := exec.Command(command, args...)
+	cmd.Env = os.Environ()
+	for k, v := range env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	t := &stdioTransport{
+		name:    name,
+		cmd:     cmd,
+		stdin:   stdin,
+		pending: make(map[string]chan *incoming),
+		done:    make(chan struct{}),
+	}
+	go t.readLoop(stdout)
+	go func() {
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			logger.DebugCF("mcp", scanner.Text(), map[string]any{"server": name})
+		}
+	}()
+	return t, nil
+}
+
+// readLoop routes the server's responses to the calls waiting for them and
+// answers its requests.
+func (t *stdioTransport) readLoop(stdout io.Reader) {
+	defer close(t.done)
+	reader := bufio.NewReader(stdout)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			t.dispatch(line)
+		}
+		if err != nil {
+			logger.WarnCF("mcp", "MCP server exited", map[string]any{"server": t.name})
+			return
+		}
+	}
+}
+
+func (t *stdioTransport) dispatch(line []byte) {
+	var msg incoming
+	if err := json.Unmarshal(line, &msg); err != nil {
+		logger.WarnCF("mcp", "Invalid message from MCP server", map[string]any{
+			"server": t.name,
+			"error":  err.Error(),
+		})
+		return
+	}
+	switch {
+	case msg.Method != "" && msg.ID != nil:
+		// The client offers no capabilities, so it only answers pings.
+		resp := &response{JSONRPC: "2.0", Result: struct{}{}, ID: msg.ID}
+		if msg.Method != "ping" {
+			resp = failure(msg.ID, codeMethodNotFound, "method not found: "+msg.Method)
+		}
+		out, _ := json.Marshal(resp)
+		t.write(out)
+	case msg.Method != "":
+		// A notification.
+	default:
+		t.mu.Lock()
+		ch, ok := t.pending[string(msg.ID)]
+		delete(t.pending, string(msg.ID))
+		t.mu.Unlock()
+		if ok {
+			ch <- &msg
+		}
+	}
+}
+
+func (t *stdioTransport) write(msg []byte) error {
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	_, err := t.stdin.Write(append(msg, '\n'))
+	return err
+}
+
+func (t *stdioTransport) roundTrip(ctx context.Context, req *request) (*incoming, error) {
+	out, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan *incoming, 1)
+	t.mu.Lock()
+	t.pending[string(req.ID)] = ch
+	t.mu.Unlock()
+	defer func() {
+		t.mu.Lock()
+		delete(t.pending, string(req.ID))
+		t.mu.Unlock()
+	}()
+
+	if err := t.write(out); err != nil {
+		return nil, err
+	}
+	select {
+	case resp := <-ch:
+		return resp, nil
+	case <-t.done:
+		return nil, errClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (t *stdioTransport) notify(_ context.Context, req *request) error {
+	out, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	return t.write(out)
+}
+
+// close ends the server's input, which asks it to exit, and kills it if it
+// is still running after that.
+func (t *stdioTransport) close() error {
+	t.stdin.Close()
+	select {
+	case <-t.done:
+	case <-time.After(closeTimeout):
+		t.cmd.Process.Kill()
+	}
+	t.cmd.Wait()
+	return nil
+}
+
+// httpTransport speaks to a server over the streamable HTTP transport: each
+// message is posted, and a response comes back as JSON or as an event of a
+// server-sent event stream.
+type httpTransport struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+
+	mu        sync.Mutex
+	sessionID string // Mcp-Session-Id assigned by the server
+}
+
+func newHTTPTransport(url string, headers map[string]string) *httpTransport {
+	// Calls are bounded by their context rather than a client timeout.
+	return &httpTransport{url: url, headers: headers, client: httpclient.New(0)}
+}
+
+func (t *httpTransport) post(ctx context.Context, req *request) (*http.Response, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "application/json, text/event-stream")
+	for k, v := range t.headers {
+		httpReq.Header.Set(k, v)
+	}
+	t.mu.Lock()
+	if t.sessionID != "" {
+		httpReq.Header.Set("Mcp-Session-Id", t.sessionID)
+	}
+	t.mu.Unlock()
+
+	resp, err := t.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if id := resp.Header.Get("Mcp-Session-Id"); id != "" {
+		t.mu.Lock()
+		t.sessionID = id
+		t.mu.Unlock()
+	}
+	return resp, nil
+}
+
+func (t *httpTransport) roundTrip(ctx context.Context, req *request) (*incoming, error) {
+	resp, err := t.post(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/event-stream" {
+		var msg incoming
+		if err := json.NewDecoder(io.LimitReader(resp.Body, maxBody)).Decode(&msg); err != nil {
+			return nil, fmt.Errorf("invalid response: %w", err)
+		}
+		return &msg, nil
+	}
+
+	// The stream may carry the server's own messages before the response.
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), maxBody)
+	var data []byte
+	for scanner.Scan() {
+		line := scanner.Text()
+		if v, ok := strings.CutPrefix(line, "data:"); ok {
+			if len(data) > 0 {
+				data = append(data, '\n')
+			}
+			data = append(data, strings.TrimPrefix(v, " ")...)
+			continue
+		}
+		if line != "" || len(data) == 0 {
+			continue
+		}
+		var msg incoming
+		if err := json.Unmarshal(data, &msg); err == nil && msg.Method == "" &&
+			bytes.Equal(msg.ID, req.ID) {
+			return &msg, nil
+		}
+		data = data[:0]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, errors.New("stream ended without a response")
+}
+
+func (t *httpTransport) notify(ctx context.Context, req *request) error {
+	resp, err := t.post(ctx, req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// close ends the server's session, if it assigned one.
+func (t *httpTransport) close() error {
+	t.mu.Lock()
+	id := t.sessionID
+	t.mu.Unlock()
+	if id == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, t.url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Mcp-Session-Id", id)
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
@@ -0,0 +1,342 @@
+// Package mdns advertises the gateway on the local network as a
+// _picoclaw._tcp service, over multicast DNS (RFC 6762) with DNS-based
+// service discovery (RFC 6763), so apps find devices without being given an
+// IP address. It answers queries itself rather than relying on Avahi or
+// Bonjour, which many boards lack.
+package mdns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	// ServiceType is the DNS-SD service type the gateway is advertised as.
+	ServiceType = "_picoclaw._tcp"
+
+	port        = 5353
+	servicesPTR = "_services._dns-sd._udp.local."
+
+	// Host records are short-lived, since addresses change; the others
+	// follow RFC 6762's recommendation of 75 minutes.
+	hostTTL    = 120
+	serviceTTL = 4500
+
+	// refreshInterval is how often the TXT records are checked for changes,
+	// which are announced.
+	refreshInterval = 10 * time.Second
+	maxPacket       = 9000
+)
+
+// cacheFlush is set in the class of records only this responder owns.
+const cacheFlush = dnsmessage.Class(1 << 15)
+
+var group = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: port}
+
+// Service describes the advertised service. TXT is called for each answer,
+// so its records can follow state such as pairing availability.
+type Service struct {
+	Instance string // such as "PicoClaw on kitchen-pi"
+	Host     string // host name, without ".local"
+	Port     int
+	TXT      func() []string
+}
+
+// Responder answers mDNS queries for a service.
+type Responder struct {
+	svc      Service
+	service  dnsmessage.Name // _picoclaw._tcp.local.
+	instance dnsmessage.Name // <instance>._picoclaw._tcp.local.
+	host     dnsmessage.Name // <host>.local.
+	addrs    func() []net.IP // the host's IPv4 addresses
+}
+
+// New returns a responder for svc. Dots in the instance and host names are
+// replaced, since they would split the names into labels.
+func New(svc Service) (*Responder, error) {
+	svc.Instance = strings.ReplaceAll(svc.Instance, ".", "-")
+	svc.Host = strings.ReplaceAll(svc.Host, ".", "-")
+	if svc.Instance == "" || svc.Host == "" {
+		return nil, errors.New("mdns: instance and host names are required")
+	}
+	r := &Responder{svc: svc, addrs: interfaceAddrs}
+	var err error
+	if r.service, err = dnsmessage.NewName(ServiceType + ".local."); err != nil {
+		return nil, err
+	}
+	if r.instance, err = dnsmessage.NewName(svc.Instance + "." + ServiceType + ".local."); err != nil {
+		return nil, err
+	}
+	if r.host, err = dnsmessage.NewName(svc.Host + ".local."); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Run answers queries until ctx is done. The service is announced when Run
+// starts and when its TXT records change, and withdrawn when it returns.
+func (r *Responder) Run(ctx context.Context) error {
+	conn, err := net.ListenMulticastUDP("udp4", nil, group)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	go func() {
+		<-ctx.Done()
+		// Goodbye packets tell caches to drop the records at once.
+		if msg, err := r.announcement(true); err == nil {
+			conn.WriteToUDP(msg, group)
+		}
+		conn.Close()
+	}()
+	go r.announce(ctx, conn)
+
+	buf := make([]byte, maxPacket)
+	for {
+		n, src, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		resp, err := r.answer(buf[:n], src.Port != port)
+		if err != nil || resp == nil {
+			continue
+		}
+		// Legacy unicast queries, not sent from the mDNS port, are answered
+		// to the sender; others to the group.
+		dst := group
+		if src.Port != port {
+			dst = src
+		}
+		if _, err := conn.WriteToUDP(resp, dst); err != nil {
+			logger.DebugCF("mdns", "Failed to send mDNS response", map[string]any{"error": err.Error()})
+		}
+	}
+}
+
+// announce sends the records twice a second apart, as RFC 6762 asks, then
+// again whenever the TXT records change.
+func (r *Responder) announce(ctx context.Context, conn *net.UDPConn) {
+	send := func() {
+		if msg, err := r.announcement(false); err == nil {
+			conn.WriteToUDP(msg, group)
+		}
+	}
+	send()
+	last := r.svc.TXT()
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(time.Second):
+		send()
+	}
+
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if txt := r.svc.TXT(); !slices.Equal(txt, last) {
+				last = txt
+				send()
+			}
+		}
+	}
+}
+
+// announcement is an unsolicited response with all the service's records,
+// or a goodbye withdrawing them.
+func (r *Responder) announcement(goodbye bool) ([]byte, error) {
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{Response: true, Authoritative: true})
+	b.EnableCompression()
+	if err := b.StartAnswers(); err != nil {
+		return nil, err
+	}
+	if _, err := r.addRecords(&b, dnsmessage.TypePTR, r.service, goodbye, true); err != nil {
+		return nil, err
+	}
+	return b.Finish()
+}
+
+// answer builds the response to the query in packet, or nil when none of its
+// questions is about this service. A legacy unicast response echoes the
+// query's ID and questions, and has no cache-flush bits.
+func (r *Responder) answer(packet []byte, legacy bool) ([]byte, error) {
+	var p dnsmessage.Parser
+	header, err := p.Start(packet)
+	if err != nil || header.Response {
+		return nil, err
+	}
+	questions, err := p.AllQuestions()
+	if err != nil {
+		return nil, err
+	}
+
+	respHeader := dnsmessage.Header{Response: true, Authoritative: true}
+	if legacy {
+		respHeader.ID = header.ID
+	}
+	b := dnsmessage.NewBuilder(nil, respHeader)
+	b.EnableCompression()
+	if legacy {
+		if err := b.StartQuestions(); err != nil {
+			return nil, err
+		}
+		for _, q := range questions {
+			q.Class &^= 1 << 15 // the unicast-response bit
+			if err := b.Question(q); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := b.StartAnswers(); err != nil {
+		return nil, err
+	}
+	answers := 0
+	for _, q := range questions {
+		n, err := r.addRecords(&b, q.Type, q.Name, false, !legacy)
+		if err != nil {
+			return nil, err
+		}
+		answers += n
+	}
+	if answers == 0 {
+		return nil, nil
+	}
+	return b.Finish()
+}
+
+// addRecords adds the records answering a question of type typ about name.
+// A browser's PTR question is also given the instance's SRV and TXT and the
+// host's addresses, so it resolves the service in one round trip. A goodbye
+// has TTLs of 0; flush sets the cache-flush bit of the unique records. It
+// returns the number of records added.
+func (r *Responder) addRecords(b *dnsmessage.Builder, typ dnsmessage.Type, name dnsmessage.Name,
+	goodbye, flush bool,
+) (int, error) {
+	rs := records{b: b, flush: flush, goodbye: goodbye}
+	all := typ == dnsmessage.TypeALL
+	switch {
+	case name.String() == servicesPTR:
+		if all || typ == dnsmessage.TypePTR {
+			rs.ptr(name, r.service)
+		}
+	case equalNames(name, r.service):
+		if all || typ == dnsmessage.TypePTR {
+			rs.ptr(r.service, r.instance)
+			rs.srv(r.instance, r.host, r.svc.Port)
+			rs.txt(r.instance, r.svc.TXT())
+			rs.a(r.host, r.addrs())
+		}
+	case equalNames(name, r.instance):
+		if all || typ == dnsmessage.TypeSRV {
+			rs.srv(r.instance, r.host, r.svc.Port)
+		}
+		if all || typ == dnsmessage.TypeTXT {
+			rs.txt(r.instance, r.svc.TXT())
+		}
+		if all || typ == dnsmessage.TypeSRV {
+			rs.a(r.host, r.addrs())
+		}
+	case equalNames(name, r.host):
+		if all || typ == dnsmessage.TypeA {
+			rs.a(r.host, r.addrs())
+		}
+	}
+	return rs.n, rs.err
+}
+
+// records adds resource records to a message, counting them and keeping the
+// first error.
+type records struct {
+	b       *dnsmessage.Builder
+	flush   bool
+	goodbye bool
+	n       int
+	err     error
+}
+
+func (rs *records) header(name dnsmessage.Name, ttl uint32, unique bool) dnsmessage.ResourceHeader {
+	rs.n++
+	h := dnsmessage.ResourceHeader{Name: name, Class: dnsmessage.ClassINET, TTL: ttl}
+	if rs.goodbye {
+		h.TTL = 0
+	}
+	if unique && rs.flush {
+		h.Class |= cacheFlush
+	}
+	return h
+}
+
+func (rs *records) ptr(name, ptr dnsmessage.Name) {
+	if rs.err == nil {
+		rs.err = rs.b.PTRResource(rs.header(name, serviceTTL, false), dnsmessage.PTRResource{PTR: ptr})
+	}
+}
+
+func (rs *records) srv(name, target dnsmessage.Name, port int) {
+	if rs.err == nil {
+		rs.err = rs.b.SRVResource(rs.header(name, hostTTL, true),
+			dnsmessage.SRVResource{Port: uint16(port), Target: target})
+	}
+}
+
+func (rs *records) txt(name dnsmessage.Name, txt []string) {
+	if len(txt) == 0 {
+		txt = []string{""} // a TXT record holds at least one string
+	}
+	if rs.err == nil {
+		rs.err = rs.b.TXTResource(rs.header(name, serviceTTL, true), dnsmessage.TXTResource{TXT: txt})
+	}
+}
+
+func (rs *records) a(name dnsmessage.Name, ips []net.IP) {
+	for _, ip := range ips {
+		if rs.err == nil {
+			rs.err = rs.b.AResource(rs.header(name, hostTTL, true), dnsmessage.AResource{A: [4]byte(ip.To4())})
+		}
+	}
+}
+
+// equalNames compares DNS names case-insensitively.
+func equalNames(a, b dnsmessage.Name) bool {
+	return strings.EqualFold(a.String(), b.String())
+}
+
+// interfaceAddrs returns the IPv4 addresses of the up, non-loopback
+// interfaces.
+func interfaceAddrs() []net.IP {
+	var ips []net.IP
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			if ipNet, ok := a.(*net.IPNet); ok && ipNet.IP.To4() != nil && !ipNet.IP.IsLinkLocalUnicast() {
+				ips = append(ips, ipNet.IP.To4())
+			}
+		}
+	}
+	return ips
+}
@@ -0,0 +1,120 @@
+package mdns
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func newTestResponder(t *testing.T) *Responder {
+	t.Helper()
+	r, err := New(Service{
+		Instance: "PicoClaw on kitchen.pi",
+		Host:     "kitchen.pi",
+		Port:     18790,
+		TXT:      func() []string { return []string{"version=1.0", "pairing=open"} },
+	})
+	require.NoError(t, err)
+	r.addrs = func() []net.IP { return []net.IP{net.IPv4(192, 168, 1, 20)} }
+	return r
+}
+
+func query(t *testing.T, id uint16, name string, typ dnsmessage.Type) []byte {
+	t.Helper()
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: id})
+	require.NoError(t, b.StartQuestions())
+	require.NoError(t, b.Question(dnsmessage.Question{
+		Name:  dnsmessage.MustNewName(name),
+		Type:  typ,
+		Class: dnsmessage.ClassINET,
+	}))
+	msg, err := b.Finish()
+	require.NoError(t, err)
+	return msg
+}
+
+func parse(t *testing.T, packet []byte) dnsmessage.Message {
+	t.Helper()
+	var msg dnsmessage.Message
+	require.NoError(t, msg.Unpack(packet))
+	return msg
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(Service{Instance: "", Host: "pi"})
+	assert.Error(t, err)
+}
+
+func TestAnswerBrowse(t *testing.T) {
+	r := newTestResponder(t)
+	resp, err := r.answer(query(t, 0, "_picoclaw._tcp.local.", dnsmessage.TypePTR), false)
+	require.NoError(t, err)
+	msg := parse(t, resp)
+
+	assert.True(t, msg.Header.Response)
+	assert.Zero(t, msg.Header.ID)
+	assert.Empty(t, msg.Questions)
+	require.Len(t, msg.Answers, 4)
+
+	ptr := msg.Answers[0].Body.(*dnsmessage.PTRResource)
+	assert.Equal(t, "PicoClaw on kitchen-pi._picoclaw._tcp.local.", ptr.PTR.String())
+	assert.Equal(t, dnsmessage.ClassINET, msg.Answers[0].Header.Class, "shared records have no cache-flush bit")
+
+	srv := msg.Answers[1].Body.(*dnsmessage.SRVResource)
+	assert.Equal(t, uint16(18790), srv.Port)
+	assert.Equal(t, "kitchen-pi.local.", srv.Target.String())
+	assert.Equal(t, dnsmessage.ClassINET|cacheFlush, msg.Answers[1].Header.Class)
+
+	txt := msg.Answers[2].Body.(*dnsmessage.TXTResource)
+	assert.Equal(t, []string{"version=1.0", "pairing=open"}, txt.TXT)
+
+	a := msg.Answers[3].Body.(*dnsmessage.AResource)
+	assert.Equal(t, [4]byte{192, 168, 1, 20}, a.A)
+	assert.Equal(t, uint32(hostTTL), msg.Answers[3].Header.TTL)
+}
+
+func TestAnswerHost(t *testing.T) {
+	r := newTestResponder(t)
+	resp, err := r.answer(query(t, 0, "KITCHEN-PI.local.", dnsmessage.TypeA), false)
+	require.NoError(t, err)
+	msg := parse(t, resp)
+	require.Len(t, msg.Answers, 1)
+	assert.Equal(t, dnsmessage.TypeA, msg.Answers[0].Header.Type)
+}
+
+func TestAnswerLegacyUnicast(t *testing.T) {
+	r := newTestResponder(t)
+	resp, err := r.answer(query(t, 42, "PicoClaw on kitchen-pi._picoclaw._tcp.local.", dnsmessage.TypeTXT), true)
+	require.NoError(t, err)
+	msg := parse(t, resp)
+
+	assert.Equal(t, uint16(42), msg.Header.ID)
+	require.Len(t, msg.Questions, 1)
+	require.Len(t, msg.Answers, 1)
+	assert.Equal(t, dnsmessage.ClassINET, msg.Answers[0].Header.Class, "legacy answers have no cache-flush bit")
+}
+
+func TestAnswerIgnoresOthers(t *testing.T) {
+	r := newTestResponder(t)
+	resp, err := r.answer(query(t, 0, "_airplay._tcp.local.", dnsmessage.TypePTR), false)
+	require.NoError(t, err)
+	assert.Nil(t, resp)
+
+	resp, err = r.answer(query(t, 0, "kitchen-pi.local.", dnsmessage.TypeAAAA), false)
+	require.NoError(t, err)
+	assert.Nil(t, resp, "no records of the asked type")
+}
+
+func TestAnnouncementGoodbye(t *testing.T) {
+	r := newTestResponder(t)
+	packet, err := r.announcement(true)
+	require.NoError(t, err)
+	msg := parse(t, packet)
+	require.Len(t, msg.Answers, 4)
+	for _, rr := range msg.Answers {
+		assert.Zero(t, rr.Header.TTL)
+	}
+}
@@ -0,0 +1,224 @@
+package media
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/telemetry"
+)
+
+// KeyFile is where the workspace media key is kept, relative to the
+// workspace. Without it encrypted media cannot be read.
+const KeyFile = "state/media.key"
+
+// encryptedMagic starts every file encrypted at rest. It is also the
+// additional data of the seal, so a plain file that happens to start with it
+// fails to decrypt instead of being misread.
+var encryptedMagic = []byte("PCENC\x00v1")
+
+// Cipher encrypts media files at rest with AES-256-GCM. Files are sealed as
+// a whole: magic, nonce, then ciphertext and tag.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// LoadOrCreateKey returns the cipher for workspace, generating and saving a
+// new key on first use.
+func LoadOrCreateKey(workspace string) (*Cipher, error) {
+	path := filepath.Join(workspace, filepath.FromSlash(KeyFile))
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("failed to generate media key: %w", err)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, err
+		}
+		// O_EXCL so two processes starting together agree on one key.
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if os.IsExist(err) {
+			return LoadOrCreateKey(workspace)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to save media key: %w", err)
+		}
+		_, err = f.WriteString(hex.EncodeToString(key) + "\n")
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to save media key: %w", err)
+		}
+		logger.InfoCF("media", "Generated media encryption key", map[string]any{"path": path})
+		return NewCipher(key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read media key: %w", err)
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid media key in %s", path)
+	}
+	return NewCipher(key)
+}
+
+// NewCipher returns a cipher for a 32-byte key.
+func NewCipher(key []byte) (*Cipher, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("media key must be 32 bytes, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// Encrypt seals plaintext.
+func (c *Cipher) Encrypt(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(encryptedMagic)+len(nonce)+len(plaintext)+c.aead.Overhead())
+	out = append(out, encryptedMagic...)
+	out = append(out, nonce...)
+	return c.aead.Seal(out, nonce, plaintext, encryptedMagic), nil
+}
+
+// Decrypt opens data sealed by Encrypt. Data without the magic header is
+// returned unchanged, so files stored before encryption was enabled stay
+// readable.
+func (c *Cipher) Decrypt(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, encryptedMagic) {
+		return data, nil
+	}
+	data = data[len(encryptedMagic):]
+	if len(data) < c.aead.NonceSize() {
+		return nil, errors.New("encrypted file is truncated")
+	}
+	nonce, sealed := data[:c.aead.NonceSize()], data[c.aead.NonceSize():]
+	plain, err := c.aead.Open(nil, nonce, sealed, encryptedMagic)
+	if err != nil {
+		return nil, errors.New("failed to decrypt file: wrong media key or corrupted file")
+	}
+	return plain, nil
+}
+
+// IsEncrypted reports whether the file at path is encrypted at rest.
+func (c *Cipher) IsEncrypted(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	head := make([]byte, len(encryptedMagic))
+	if _, err := io.ReadFull(f, head); err != nil {
+		return false
+	}
+	return bytes.Equal(head, encryptedMagic)
+}
+
+// ReadFile returns the plaintext of the file at path.
+func (c *Cipher) ReadFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return c.Decrypt(data)
+}
+
+// EncryptFile encrypts the file at path in place. Files that are already
+// encrypted are left alone.
+func (c *Cipher) EncryptFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if bytes.HasPrefix(data, encryptedMagic) {
+		return nil
+	}
+	sealed, err := c.Encrypt(data)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, sealed, 0o600); err != nil {
+		return err
+	}
+	// Keep the modification time so retention still sees the upload's age.
+	if err := os.Chtimes(tmp, info.ModTime(), info.ModTime()); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// EncryptExisting encrypts the files already in the media directory that are
+// still stored in clear text, and returns how many it encrypted.
+func (l *Library) EncryptExisting() (int, error) {
+	if l.opts.Cipher == nil {
+		return 0, nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	count := 0
+	err := filepath.WalkDir(l.dir, func(path string, d fs.DirEntry, err error) error {
+		if os.IsNotExist(err) {
+			return filepath.SkipDir
+		}
+		if err != nil || d.IsDir() || !d.Type().IsRegular() {
+			return err
+		}
+		if strings.HasPrefix(d.Name(), ".upload-") || strings.HasSuffix(d.Name(), ".tmp") ||
+			l.opts.Cipher.IsEncrypted(path) {
+			return nil
+		}
+		if err := l.opts.Cipher.EncryptFile(path); err != nil {
+			return fmt.Errorf("failed to encrypt %s: %w", d.Name(), err)
+		}
+		count++
+		return nil
+	})
+	if count > 0 {
+		telemetry.AddCounter("picoclaw.media.encrypted_existing", int64(count))
+		logger.InfoCF("media", "Encrypted existing media files", map[string]any{"count": count})
+	}
+	return count, err
+}
+
+// ReadFile returns the plaintext of a stored file, decrypting it when it is
+// encrypted at rest.
+func (l *Library) ReadFile(path string) ([]byte, error) {
+	if l.opts.Cipher == nil {
+		return os.ReadFile(path)
+	}
+	return l.opts.Cipher.ReadFile(path)
+}
+
+// Cipher returns the cipher media is encrypted with, or nil when encryption
+// is disabled.
+func (l *Library) Cipher() *Cipher {
+	return l.opts.Cipher
+}
@@ -0,0 +1,85 @@
+package media
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveEncryptsUploads(t *testing.T) {
+	workspace := t.TempDir()
+	c, err := LoadOrCreateKey(workspace)
+	require.NoError(t, err)
+	l, err := NewLibrary(workspace, Options{Cipher: c})
+	require.NoError(t, err)
+
+	receipt := "RECEIPT total 42.00 card ending 4242"
+	path, _, err := l.Save(strings.NewReader(receipt), "receipt.txt", "biz-1")
+	require.NoError(t, err)
+
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(raw), "4242")
+	assert.True(t, c.IsEncrypted(path))
+
+	plain, err := l.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, receipt, string(plain))
+
+	// Deduplication compares plaintext, so the same receipt is still found.
+	again, deduped, err := l.Save(strings.NewReader(receipt), "receipt.txt", "biz-2")
+	require.NoError(t, err)
+	assert.True(t, deduped)
+	assert.Equal(t, path, again)
+
+	// The key is reused on restart.
+	reloaded, err := LoadOrCreateKey(workspace)
+	require.NoError(t, err)
+	plain, err = reloaded.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, receipt, string(plain))
+
+	other, err := NewCipher(make([]byte, 32))
+	require.NoError(t, err)
+	_, err = other.ReadFile(path)
+	assert.ErrorContains(t, err, "wrong media key")
+}
+
+func TestEncryptExisting(t *testing.T) {
+	workspace := t.TempDir()
+	old := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+	path := filepath.Join(workspace, "media", "old.jpg")
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o700))
+	require.NoError(t, os.WriteFile(path, []byte("plain"), 0o600))
+	require.NoError(t, os.Chtimes(path, old, old))
+
+	c, err := NewCipher(make([]byte, 32))
+	require.NoError(t, err)
+	// Files stored before encryption was enabled stay readable.
+	plain, err := c.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "plain", string(plain))
+
+	l, err := NewLibrary(workspace, Options{Cipher: c})
+	require.NoError(t, err)
+	n, err := l.EncryptExisting()
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.True(t, c.IsEncrypted(path))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.True(t, info.ModTime().Equal(old), "retention still sees the original age")
+
+	n, err = l.EncryptExisting()
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	plain, err = l.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "plain", string(plain))
+}
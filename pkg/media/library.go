@@ -0,0 +1,212 @@
+// Package media stores uploaded files under <workspace>/media and enforces a
+// retention policy on them.
+//
+// Uploads are content-addressed: a file with the same contents as an earlier
+// upload (a retried request, a receipt sent twice) reuses the stored copy and
+// adds a reference to it. Files can also be held per business, for example
+// while a transaction that references a receipt is still unposted. Cleanup
+// never removes held files or files with live references.
+//
+// Uploads can be encrypted at rest with a per-workspace key (see Cipher);
+// ReadFile returns their plaintext.
+package media
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/telemetry"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// Options configures retention, quotas and encryption. A zero limit
+// disables it.
+type Options struct {
+	MaxAge         time.Duration // drop references (and unindexed files) older than this
+	MaxBytes       int64         // remove the least recently used files while the total is larger
+	WorkspaceQuota int64         // reject uploads that would take the whole workspace past this
+	BusinessQuota  int64         // reject uploads that would take one business's uploads past this
+	Cipher         *Cipher       // encrypt new uploads at rest; nil stores them in clear text
+}
+
+// entry is a stored upload in the content index.
+type entry struct {
+	Path string               `json:"path"` // media-relative
+	Size int64                `json:"size"`
+	Refs map[string]time.Time `json:"refs"` // business ID ("" for none) -> last upload
+}
+
+// lastUsed returns the most recent reference time.
+func (e *entry) lastUsed() time.Time {
+	var last time.Time
+	for _, t := range e.Refs {
+		if t.After(last) {
+			last = t
+		}
+	}
+	return last
+}
+
+// Library manages the files in <workspace>/media.
+type Library struct {
+	workspace string
+	dir       string
+	holdsPath string
+	indexPath string
+	opts      Options
+	now       func() time.Time
+
+	mu    sync.Mutex
+	holds map[string]map[string]bool // business ID -> media-relative paths
+	index map[string]*entry          // sha256 -> stored upload
+}
+
+// NewLibrary loads the holds and content index for workspace.
+func NewLibrary(workspace string, opts Options) (*Library, error) {
+	l := &Library{
+		workspace: workspace,
+		dir:       filepath.Join(workspace, "media"),
+		holdsPath: filepath.Join(workspace, "state", "media_holds.json"),
+		indexPath: filepath.Join(workspace, "state", "media_index.json"),
+		opts:      opts,
+		now:       time.Now,
+		holds:     map[string]map[string]bool{},
+		index:     map[string]*entry{},
+	}
+
+	var saved map[string][]string
+	if err := readJSON(l.holdsPath, &saved); err != nil {
+		return nil, fmt.Errorf("failed to load media holds: %w", err)
+	}
+	for businessID, paths := range saved {
+		l.holds[businessID] = map[string]bool{}
+		for _, p := range paths {
+			l.holds[businessID][p] = true
+		}
+	}
+	if err := readJSON(l.indexPath, &l.index); err != nil {
+		return nil, fmt.Errorf("failed to load media index: %w", err)
+	}
+	return l, nil
+}
+
+// Dir returns the media directory.
+func (l *Library) Dir() string {
+	return l.dir
+}
+
+// Save stores an upload for businessID and returns its path. If a file with
+// the same contents is already stored, the new copy is discarded and the
+// existing path returned with deduped set. Uploads that would exceed a quota
+// fail with ErrQuotaExceeded.
+func (l *Library) Save(src io.Reader, filename, businessID string) (path string, deduped bool, err error) {
+	if err := os.MkdirAll(l.dir, 0o700); err != nil {
+		return "", false, fmt.Errorf("failed to create media directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(l.dir, ".upload-*")
+	if err != nil {
+		return "", false, err
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), src)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to write upload: %w", err)
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e := l.index[sum]
+	if e != nil {
+		if _, err := os.Stat(filepath.Join(l.dir, filepath.FromSlash(e.Path))); err != nil {
+			e = nil // removed behind the index's back; store it again
+		}
+	}
+	if err := l.checkQuota(businessID, size, e); err != nil {
+		return "", false, err
+	}
+
+	now := l.now()
+	if e != nil {
+		e.Refs[businessID] = now
+		telemetry.AddCounter("picoclaw.media.dedup_hits", 1)
+		telemetry.AddCounter("picoclaw.media.dedup_bytes", size)
+		logger.InfoCF("media", "Reusing stored upload", map[string]any{
+			"path": e.Path, "business_id": businessID, "bytes": size,
+		})
+		return filepath.Join(l.dir, filepath.FromSlash(e.Path)), true, l.saveIndex()
+	}
+
+	if l.opts.Cipher != nil {
+		if err := l.opts.Cipher.EncryptFile(tmp.Name()); err != nil {
+			return "", false, fmt.Errorf("failed to encrypt upload: %w", err)
+		}
+	}
+	name := uuid.New().String()[:8] + "_" + utils.SanitizeFilename(filename)
+	path = filepath.Join(l.dir, name)
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", false, fmt.Errorf("failed to store upload: %w", err)
+	}
+	l.index[sum] = &entry{Path: name, Size: size, Refs: map[string]time.Time{businessID: now}}
+	return path, false, l.saveIndex()
+}
+
+// saveIndex persists the content index. Callers must hold l.mu.
+func (l *Library) saveIndex() error {
+	return writeJSON(l.indexPath, l.index)
+}
+
+// saveHolds persists the holds. Callers must hold l.mu.
+func (l *Library) saveHolds() error {
+	saved := make(map[string][]string, len(l.holds))
+	for businessID, paths := range l.holds {
+		for p := range paths {
+			saved[businessID] = append(saved[businessID], p)
+		}
+		sort.Strings(saved[businessID])
+	}
+	return writeJSON(l.holdsPath, saved)
+}
+
+func readJSON(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func writeJSON(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(path), err)
+	}
+	return os.Rename(tmp, path)
+}
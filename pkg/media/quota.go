@@ -0,0 +1,168 @@
+package media
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/telemetry"
+)
+
+// ErrQuotaExceeded is returned by Save when an upload would take the
+// workspace or its business over quota.
+var ErrQuotaExceeded = errors.New("storage quota exceeded")
+
+// Usage is the storage used by a workspace and by each business's uploads.
+// A zero quota means unlimited.
+type Usage struct {
+	WorkspaceBytes int64
+	WorkspaceQuota int64
+	Businesses     map[string]int64 // bytes of uploads referenced by each business
+	BusinessQuota  int64
+}
+
+// Usage measures the workspace and the uploads referenced by each business.
+func (l *Library) Usage() (Usage, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	total, err := l.workspaceBytes()
+	if err != nil {
+		return Usage{}, err
+	}
+	u := Usage{
+		WorkspaceBytes: total,
+		WorkspaceQuota: l.opts.WorkspaceQuota,
+		Businesses:     map[string]int64{},
+		BusinessQuota:  l.opts.BusinessQuota,
+	}
+	for _, e := range l.index {
+		for businessID := range e.Refs {
+			if businessID != "" {
+				u.Businesses[businessID] += e.Size
+			}
+		}
+	}
+	return u, nil
+}
+
+// Format renders the usage as chat lines. With a business ID only that
+// business is listed, otherwise the largest businesses are.
+func (u Usage) Format(businessID string) string {
+	lines := []string{"Storage: " + formatQuota(u.WorkspaceBytes, u.WorkspaceQuota)}
+	if businessID != "" {
+		lines = append(lines, fmt.Sprintf("Business %s: %s", businessID, formatQuota(u.Businesses[businessID], u.BusinessQuota)))
+		return strings.Join(lines, "\n")
+	}
+
+	ids := make([]string, 0, len(u.Businesses))
+	for id := range u.Businesses {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if u.Businesses[ids[i]] != u.Businesses[ids[j]] {
+			return u.Businesses[ids[i]] > u.Businesses[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+	if len(ids) > 5 {
+		ids = ids[:5]
+	}
+	if len(ids) > 0 {
+		lines = append(lines, "By business:")
+	}
+	for _, id := range ids {
+		lines = append(lines, fmt.Sprintf("  %s: %s", id, formatQuota(u.Businesses[id], u.BusinessQuota)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// checkQuota returns an ErrQuotaExceeded error if storing size more bytes
+// for businessID would exceed a quota. existing is the index entry of an
+// identical stored upload, if any: it costs the workspace nothing and only
+// counts against businessID if the business does not reference it yet.
+// Callers must hold l.mu.
+func (l *Library) checkQuota(businessID string, size int64, existing *entry) error {
+	if l.opts.WorkspaceQuota > 0 && existing == nil {
+		used, err := l.workspaceBytes()
+		if err != nil {
+			return err
+		}
+		if used+size > l.opts.WorkspaceQuota {
+			return l.rejectUpload("workspace", "workspace", used, size, l.opts.WorkspaceQuota)
+		}
+	}
+	if l.opts.BusinessQuota > 0 && businessID != "" {
+		if existing != nil {
+			if _, ok := existing.Refs[businessID]; ok {
+				return nil
+			}
+		}
+		var used int64
+		for _, e := range l.index {
+			if _, ok := e.Refs[businessID]; ok {
+				used += e.Size
+			}
+		}
+		if used+size > l.opts.BusinessQuota {
+			return l.rejectUpload("business", "business "+businessID, used, size, l.opts.BusinessQuota)
+		}
+	}
+	return nil
+}
+
+func (l *Library) rejectUpload(scope, name string, used, size, quota int64) error {
+	telemetry.AddCounter("picoclaw.media.quota_rejections", 1, telemetry.String("scope", scope))
+	logger.WarnCF("media", "Upload rejected by storage quota", map[string]any{
+		"scope": name, "used_bytes": used, "upload_bytes": size, "quota_bytes": quota,
+	})
+	return fmt.Errorf("%w: %s uses %s of %s, upload is %s",
+		ErrQuotaExceeded, name, formatBytes(used), formatBytes(quota), formatBytes(size))
+}
+
+// workspaceBytes sums the size of every file in the workspace, except
+// uploads that Save is still writing.
+func (l *Library) workspaceBytes() (int64, error) {
+	var total int64
+	err := filepath.WalkDir(l.workspace, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".upload-") {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			total += info.Size()
+		}
+		return nil
+	})
+	return total, err
+}
+
+func formatQuota(used, quota int64) string {
+	if quota <= 0 {
+		return formatBytes(used)
+	}
+	return fmt.Sprintf("%s of %s (%d%%)", formatBytes(used), formatBytes(quota), used*100/quota)
+}
+
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
@@ -0,0 +1,53 @@
+package media
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveEnforcesQuotas(t *testing.T) {
+	workspace := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(workspace, "notes.md"), make([]byte, 4000), 0o600))
+	l, err := NewLibrary(workspace, Options{WorkspaceQuota: 10000, BusinessQuota: 3000})
+	require.NoError(t, err)
+
+	_, _, err = l.Save(strings.NewReader(strings.Repeat("a", 2500)), "a.jpg", "biz-1")
+	require.NoError(t, err)
+
+	_, _, err = l.Save(strings.NewReader(strings.Repeat("b", 1000)), "b.jpg", "biz-1")
+	assert.ErrorIs(t, err, ErrQuotaExceeded, "business quota")
+
+	// Re-uploading a file the business already has costs nothing.
+	_, deduped, err := l.Save(strings.NewReader(strings.Repeat("a", 2500)), "a.jpg", "biz-1")
+	require.NoError(t, err)
+	assert.True(t, deduped)
+
+	_, _, err = l.Save(strings.NewReader(strings.Repeat("c", 3500)), "c.jpg", "biz-2")
+	assert.ErrorIs(t, err, ErrQuotaExceeded, "workspace quota")
+
+	entries, err := os.ReadDir(l.Dir())
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "rejected uploads are not kept")
+
+	usage, err := l.Usage()
+	require.NoError(t, err)
+	assert.InDelta(t, 6500, usage.WorkspaceBytes, 500, "files plus the media index")
+	assert.Equal(t, map[string]int64{"biz-1": 2500}, usage.Businesses)
+}
+
+func TestUsageFormat(t *testing.T) {
+	u := Usage{
+		WorkspaceBytes: 300 << 20,
+		WorkspaceQuota: 1 << 30,
+		Businesses:     map[string]int64{"biz-1": 5 << 20, "biz-2": 20 << 20},
+		BusinessQuota:  100 << 20,
+	}
+	assert.Equal(t, "Storage: 300.0 MB of 1.0 GB (29%)\nBusiness biz-1: 5.0 MB of 100.0 MB (5%)", u.Format("biz-1"))
+	assert.Equal(t, "Storage: 300.0 MB of 1.0 GB (29%)\nBy business:\n  biz-2: 20.0 MB of 100.0 MB (20%)\n  biz-1: 5.0 MB of 100.0 MB (5%)", u.Format(""))
+	assert.Equal(t, "Storage: 300.0 MB", Usage{WorkspaceBytes: 300 << 20}.Format(""))
+}
@@ -0,0 +1,216 @@
+package media
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/telemetry"
+)
+
+// CleanupResult summarizes one cleanup run.
+type CleanupResult struct {
+	Files int
+	Bytes int64
+	Kept  int // files left in place
+	Held  int // of which held
+}
+
+// Hold protects a media file from cleanup on behalf of businessID.
+func (l *Library) Hold(businessID, path string) error {
+	rel, err := l.relPath(path)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.holds[businessID] == nil {
+		l.holds[businessID] = map[string]bool{}
+	}
+	l.holds[businessID][rel] = true
+	return l.saveHolds()
+}
+
+// Release removes businessID's hold on a media file.
+func (l *Library) Release(businessID, path string) error {
+	rel, err := l.relPath(path)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.holds[businessID], rel)
+	if len(l.holds[businessID]) == 0 {
+		delete(l.holds, businessID)
+	}
+	return l.saveHolds()
+}
+
+// Run cleans up immediately and then every interval until ctx is done.
+func (l *Library) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := l.Cleanup(); err != nil {
+			logger.WarnCF("media", "Media cleanup failed", map[string]any{"error": err.Error()})
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+type mediaFile struct {
+	rel      string
+	size     int64
+	lastUsed time.Time
+	hash     string // content index key, empty for files saved elsewhere
+}
+
+// Cleanup drops references older than MaxAge and removes files left without
+// references (or, for files not in the content index, older than MaxAge).
+// It then removes the least recently used files until the total size is
+// within MaxBytes. Held files are never removed but count towards the total.
+func (l *Library) Cleanup() (CleanupResult, error) {
+	var result CleanupResult
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	byPath := make(map[string]string, len(l.index))
+	for hash, e := range l.index {
+		byPath[e.Path] = hash
+	}
+
+	var files []mediaFile
+	err := filepath.WalkDir(l.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == l.dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".upload-") && time.Since(info.ModTime()) < time.Hour {
+			return nil // still being written by Save
+		}
+		rel, _ := filepath.Rel(l.dir, path)
+		f := mediaFile{rel: filepath.ToSlash(rel), size: info.Size(), lastUsed: info.ModTime()}
+		if hash, ok := byPath[f.rel]; ok {
+			f.hash = hash
+			f.lastUsed = l.index[hash].lastUsed()
+			delete(byPath, f.rel)
+		}
+		files = append(files, f)
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+	// Index entries whose file is gone.
+	indexChanged := len(byPath) > 0
+	for _, hash := range byPath {
+		delete(l.index, hash)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].lastUsed.Before(files[j].lastUsed) })
+
+	held := map[string]bool{}
+	for _, paths := range l.holds {
+		for p := range paths {
+			held[p] = true
+		}
+	}
+
+	var total int64
+	for _, f := range files {
+		total += f.size
+	}
+	now := l.now()
+	for _, f := range files {
+		if held[f.rel] {
+			result.Held++
+			result.Kept++
+			continue
+		}
+
+		var expired bool
+		if f.hash != "" {
+			e := l.index[f.hash]
+			if l.opts.MaxAge > 0 {
+				for businessID, t := range e.Refs {
+					if now.Sub(t) > l.opts.MaxAge {
+						delete(e.Refs, businessID)
+						indexChanged = true
+					}
+				}
+			}
+			expired = len(e.Refs) == 0
+		} else {
+			expired = l.opts.MaxAge > 0 && now.Sub(f.lastUsed) > l.opts.MaxAge
+		}
+		oversize := l.opts.MaxBytes > 0 && total > l.opts.MaxBytes
+		if !expired && !oversize {
+			result.Kept++
+			continue
+		}
+
+		if err := os.Remove(filepath.Join(l.dir, filepath.FromSlash(f.rel))); err != nil {
+			logger.WarnCF("media", "Failed to remove media file", map[string]any{"path": f.rel, "error": err.Error()})
+			result.Kept++
+			continue
+		}
+		if f.hash != "" {
+			delete(l.index, f.hash)
+			indexChanged = true
+		}
+		total -= f.size
+		result.Files++
+		result.Bytes += f.size
+	}
+
+	if indexChanged {
+		if err := l.saveIndex(); err != nil {
+			logger.WarnCF("media", "Failed to save media index", map[string]any{"error": err.Error()})
+		}
+	}
+	if result.Files > 0 {
+		telemetry.AddCounter("picoclaw.media.deleted_files", int64(result.Files))
+		telemetry.AddCounter("picoclaw.media.reclaimed_bytes", result.Bytes)
+		logger.InfoCF("media", "Media cleanup removed files", map[string]any{
+			"files":       result.Files,
+			"bytes":       result.Bytes,
+			"kept":        result.Kept,
+			"held":        result.Held,
+			"total_bytes": total,
+		})
+	}
+	return result, nil
+}
+
+// relPath resolves path (absolute, or relative to the media directory) to a
+// media-relative slash path, rejecting paths outside the media directory.
+func (l *Library) relPath(path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(l.dir, path)
+	}
+	rel, err := filepath.Rel(l.dir, filepath.Clean(path))
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is not in the media directory", path)
+	}
+	return filepath.ToSlash(rel), nil
+}
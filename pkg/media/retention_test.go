@@ -0,0 +1,102 @@
+package media
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeMedia(t *testing.T, workspace, name string, size int, age time.Duration) string {
+	t.Helper()
+	path := filepath.Join(workspace, "media", name)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o700))
+	require.NoError(t, os.WriteFile(path, make([]byte, size), 0o600))
+	mtime := time.Now().Add(-age)
+	require.NoError(t, os.Chtimes(path, mtime, mtime))
+	return path
+}
+
+func TestCleanupAppliesAgeAndSizeLimits(t *testing.T) {
+	workspace := t.TempDir()
+	old := writeMedia(t, workspace, "a_old.jpg", 10, 48*time.Hour)
+	heldOld := writeMedia(t, workspace, "b_held.jpg", 10, 72*time.Hour)
+	mid := writeMedia(t, workspace, "c_mid.jpg", 100, 3*time.Hour)
+	recent := writeMedia(t, workspace, "d_recent.jpg", 100, time.Hour)
+
+	r, err := NewLibrary(workspace, Options{MaxAge: 24 * time.Hour, MaxBytes: 150})
+	require.NoError(t, err)
+	require.NoError(t, r.Hold("biz-1", heldOld))
+
+	result, err := r.Cleanup()
+	require.NoError(t, err)
+	assert.Equal(t, CleanupResult{Files: 2, Bytes: 110, Kept: 2, Held: 1}, result)
+	assert.NoFileExists(t, old)
+	assert.NoFileExists(t, mid, "oldest unheld file goes first when over the size limit")
+	assert.FileExists(t, heldOld)
+	assert.FileExists(t, recent)
+
+	// Holds survive a restart; releasing makes the file eligible again.
+	r, err = NewLibrary(workspace, Options{MaxAge: 24 * time.Hour})
+	require.NoError(t, err)
+	_, err = r.Cleanup()
+	require.NoError(t, err)
+	assert.FileExists(t, heldOld)
+
+	require.NoError(t, r.Release("biz-1", "b_held.jpg"))
+	_, err = r.Cleanup()
+	require.NoError(t, err)
+	assert.NoFileExists(t, heldOld)
+}
+
+func TestHoldRejectsPathsOutsideMedia(t *testing.T) {
+	r, err := NewLibrary(t.TempDir(), Options{})
+	require.NoError(t, err)
+	assert.Error(t, r.Hold("biz-1", "../config.json"))
+	assert.Error(t, r.Hold("biz-1", "/etc/passwd"))
+}
+
+func TestSaveDeduplicatesAndKeepsReferencedFiles(t *testing.T) {
+	workspace := t.TempDir()
+	now := time.Now()
+	l, err := NewLibrary(workspace, Options{MaxAge: 24 * time.Hour})
+	require.NoError(t, err)
+	l.now = func() time.Time { return now }
+
+	first, deduped, err := l.Save(strings.NewReader("receipt"), "receipt.jpg", "biz-1")
+	require.NoError(t, err)
+	assert.False(t, deduped)
+
+	now = now.Add(20 * time.Hour)
+	second, deduped, err := l.Save(strings.NewReader("receipt"), "copy.jpg", "biz-2")
+	require.NoError(t, err)
+	assert.True(t, deduped)
+	assert.Equal(t, first, second)
+
+	entries, err := os.ReadDir(filepath.Join(workspace, "media"))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "duplicate upload is not stored twice")
+
+	// biz-1's reference expires, biz-2's keeps the file.
+	now = now.Add(10 * time.Hour)
+	_, err = l.Cleanup()
+	require.NoError(t, err)
+	assert.FileExists(t, first)
+
+	// The index survives a restart.
+	l, err = NewLibrary(workspace, Options{MaxAge: 24 * time.Hour})
+	require.NoError(t, err)
+	l.now = func() time.Time { return now.Add(20 * time.Hour) }
+	result, err := l.Cleanup()
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Files)
+	assert.NoFileExists(t, first)
+
+	_, deduped, err = l.Save(strings.NewReader("receipt"), "receipt.jpg", "biz-1")
+	require.NoError(t, err)
+	assert.False(t, deduped, "removed file is stored again")
+}
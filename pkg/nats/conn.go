@@ -0,0 +1,198 @@
+package nats
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// timeout bounds connecting and each write.
+const timeout = 10 * time.Second
+
+// Msg is a message delivered on a subscription.
+type Msg struct {
+	Subject string
+	Reply   string // where the sender expects a response, or ""
+	Data    []byte
+}
+
+// conn is a client connection speaking the NATS text protocol, with at most
+// one subscription.
+type conn struct {
+	nc net.Conn
+	r  *bufio.Reader
+
+	mu sync.Mutex // serializes writes
+}
+
+// dial connects to the server at rawURL (nats://[user:password@]host[:port],
+// or tls:// to require TLS) and completes the handshake. A URL with only a
+// user name sends it as a token.
+func dial(ctx context.Context, rawURL, name string) (*conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "nats" && u.Scheme != "tls" || u.Host == "" {
+		return nil, fmt.Errorf("invalid NATS URL %q: expected nats://host:port", rawURL)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "4222")
+	}
+	nc, err := (&net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}).DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to NATS: %w", err)
+	}
+	c := &conn{nc: nc, r: bufio.NewReader(nc)}
+	if err := c.handshake(u, name); err != nil {
+		c.nc.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// handshake reads the server's INFO, upgrades to TLS if either side wants
+// it, and sends CONNECT, waiting for the PONG that confirms it was accepted.
+func (c *conn) handshake(u *url.URL, name string) error {
+	c.nc.SetDeadline(time.Now().Add(timeout))
+	defer c.nc.SetDeadline(time.Time{})
+
+	line, err := c.readLine()
+	if err != nil {
+		return fmt.Errorf("reading NATS server info: %w", err)
+	}
+	op, args, _ := strings.Cut(line, " ")
+	if !strings.EqualFold(op, "INFO") {
+		return fmt.Errorf("unexpected NATS greeting %q", line)
+	}
+	var info struct {
+		TLSRequired bool `json:"tls_required"`
+	}
+	if err := json.Unmarshal([]byte(args), &info); err != nil {
+		return fmt.Errorf("bad NATS server info: %w", err)
+	}
+	if info.TLSRequired || u.Scheme == "tls" {
+		tc := tls.Client(c.nc, &tls.Config{ServerName: u.Hostname()})
+		if err := tc.Handshake(); err != nil {
+			return fmt.Errorf("NATS TLS handshake: %w", err)
+		}
+		c.nc, c.r = tc, bufio.NewReader(tc)
+	}
+
+	opts := map[string]any{
+		"verbose":  false,
+		"pedantic": false,
+		"name":     name,
+		"lang":     "go",
+		"protocol": 1,
+	}
+	if password, ok := u.User.Password(); ok {
+		opts["user"], opts["pass"] = u.User.Username(), password
+	} else if u.User != nil {
+		opts["auth_token"] = u.User.Username()
+	}
+	data, _ := json.Marshal(opts)
+	if err := c.write("CONNECT " + string(data) + "\r\nPING\r\n"); err != nil {
+		return err
+	}
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return fmt.Errorf("connecting to NATS: %w", err)
+		}
+		switch op, args, _ := strings.Cut(line, " "); strings.ToUpper(op) {
+		case "PONG":
+			return nil
+		case "-ERR":
+			return fmt.Errorf("NATS: %s", strings.Trim(args, "'"))
+		}
+	}
+}
+
+// subscribe subscribes to subject, as a member of queue if it isn't empty,
+// so each message goes to one member.
+func (c *conn) subscribe(subject, queue string) error {
+	if queue != "" {
+		return c.write(fmt.Sprintf("SUB %s %s 1\r\n", subject, queue))
+	}
+	return c.write(fmt.Sprintf("SUB %s 1\r\n", subject))
+}
+
+// publish sends data to subject.
+func (c *conn) publish(subject string, data []byte) error {
+	return c.write(fmt.Sprintf("PUB %s %d\r\n%s\r\n", subject, len(data), data))
+}
+
+// next returns the next message, answering the server's pings meanwhile.
+func (c *conn) next() (Msg, error) {
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return Msg{}, err
+		}
+		op, args, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(op) {
+		case "MSG":
+			return c.readMsg(strings.Fields(args))
+		case "PING":
+			if err := c.write("PONG\r\n"); err != nil {
+				return Msg{}, err
+			}
+		case "-ERR":
+			return Msg{}, fmt.Errorf("NATS: %s", strings.Trim(args, "'"))
+		}
+	}
+}
+
+// readMsg reads the payload of MSG <subject> <sid> [reply-to] <#bytes>.
+func (c *conn) readMsg(args []string) (Msg, error) {
+	if len(args) != 3 && len(args) != 4 {
+		return Msg{}, fmt.Errorf("bad NATS message header %q", strings.Join(args, " "))
+	}
+	n, err := strconv.Atoi(args[len(args)-1])
+	if err != nil || n < 0 {
+		return Msg{}, fmt.Errorf("bad NATS message size %q", args[len(args)-1])
+	}
+	msg := Msg{Subject: args[0]}
+	if len(args) == 4 {
+		msg.Reply = args[2]
+	}
+	buf := make([]byte, n+2)
+	if _, err := io.ReadFull(c.r, buf); err != nil {
+		return Msg{}, err
+	}
+	msg.Data = buf[:n]
+	return msg, nil
+}
+
+func (c *conn) readLine() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return "", errors.New("empty NATS protocol line")
+	}
+	return line, nil
+}
+
+func (c *conn) write(s string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.nc.SetWriteDeadline(time.Now().Add(timeout))
+	_, err := io.WriteString(c.nc, s)
+	return err
+}
+
+func (c *conn) Close() error {
+	return c.nc.Close()
+}
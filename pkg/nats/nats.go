@@ -0,0 +1,175 @@
+// Package nats answers agent requests arriving on a NATS subject, so
+// picoclaw can serve NATS-based edge deployments without exposing HTTP.
+// Each request is answered on its reply subject (NATS request-reply).
+//
+// It speaks the NATS protocol itself and needs no client library.
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/agent"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/i18n"
+	"github.com/sipeed/picoclaw/pkg/locale"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/wirelog"
+	"github.com/sipeed/picoclaw/pkg/workpool"
+)
+
+const (
+	// Channel is the channel requests are processed on.
+	Channel = "nats"
+	// requestTimeout bounds processing one request, like the webhook API.
+	requestTimeout = 120 * time.Second
+	// reconnectDelay is how long to wait before reconnecting.
+	reconnectDelay = 5 * time.Second
+)
+
+// Request is the JSON body of a request. A body that isn't JSON is taken as
+// the message itself.
+type Request struct {
+	Message    string `json:"message"`
+	SessionID  string `json:"session_id,omitempty"` // keeps a conversation; default "default"
+	BusinessID string `json:"business_id,omitempty"`
+	RequestID  string `json:"request_id,omitempty"`
+	Language   string `json:"language,omitempty"` // of error messages
+}
+
+// Response is the JSON body of a reply.
+type Response struct {
+	Response  string `json:"response,omitempty"`
+	Model     string `json:"model,omitempty"`
+	Error     string `json:"error,omitempty"`
+	RequestID string `json:"request_id"`
+}
+
+// Processor runs a message through the agent, like AgentLoop does.
+type Processor interface {
+	ProcessDirectWithChannel(ctx context.Context, content, sessionKey, channel, chatID string,
+		media ...string) (string, error)
+}
+
+// Responder answers requests with a Processor.
+type Responder struct {
+	agent   Processor
+	model   string
+	locales *locale.Resolver
+}
+
+// NewResponder creates a responder that reports model in its replies and
+// writes errors in the business's language from locales, which may be nil.
+func NewResponder(agent Processor, model string, locales *locale.Resolver) *Responder {
+	return &Responder{agent: agent, model: model, locales: locales}
+}
+
+// Handle processes one request and returns the reply body.
+func (r *Responder) Handle(ctx context.Context, data []byte) []byte {
+	var req Request
+	if err := json.Unmarshal(data, &req); err != nil {
+		req = Request{Message: string(data)}
+	}
+	if req.RequestID == "" || len(req.RequestID) > 128 {
+		req.RequestID = wirelog.NewID()
+	}
+	lang := i18n.Match(req.Language)
+	if lang == "" {
+		lang = r.locales.Language(req.BusinessID, "")
+	}
+	reply := func(resp Response) []byte {
+		resp.RequestID = req.RequestID
+		out, _ := json.Marshal(resp)
+		return out
+	}
+	if strings.TrimSpace(req.Message) == "" {
+		return reply(Response{Error: i18n.T(lang, "nats.message_required")})
+	}
+
+	ctx = context.WithValue(ctx, constants.ContextKeyRequestID, req.RequestID)
+	if req.BusinessID != "" {
+		ctx = context.WithValue(ctx, constants.ContextKeyBusinessID, req.BusinessID)
+	}
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	sessionID := req.SessionID
+	if sessionID == "" {
+		sessionID = "default"
+	}
+	response, err := r.agent.ProcessDirectWithChannel(ctx, req.Message, Channel+":"+sessionID, Channel, sessionID)
+	switch {
+	case errors.Is(err, agent.ErrMaintenance):
+		return reply(Response{Error: i18n.T(lang, "webhook.maintenance")})
+	case errors.Is(err, workpool.ErrQueueFull):
+		return reply(Response{Error: i18n.T(lang, "webhook.busy")})
+	case err != nil:
+		return reply(Response{Error: err.Error()})
+	}
+	return reply(Response{Response: response, Model: r.model})
+}
+
+// Serve subscribes to subject on the server at rawURL, in queue group queue
+// if it isn't empty so that gateways share the requests, and answers each
+// request with handle until ctx is done. It reconnects whenever the
+// connection drops; only a failure to connect the first time is returned.
+func Serve(ctx context.Context, rawURL, subject, queue string, handle func(context.Context, []byte) []byte) error {
+	c, err := dial(ctx, rawURL, "picoclaw")
+	if err == nil {
+		err = c.subscribe(subject, queue)
+	}
+	if err != nil {
+		return err
+	}
+	go func() {
+		for {
+			err := receive(ctx, c, handle)
+			c.Close()
+			if ctx.Err() != nil {
+				return
+			}
+			logger.WarnCF("nats", "NATS connection lost; reconnecting", map[string]any{"error": err.Error()})
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(reconnectDelay):
+				}
+				if c, err = dial(ctx, rawURL, "picoclaw"); err == nil {
+					if err = c.subscribe(subject, queue); err == nil {
+						break
+					}
+					c.Close()
+				}
+				logger.WarnCF("nats", "Reconnecting to NATS failed", map[string]any{"error": err.Error()})
+			}
+		}
+	}()
+	return nil
+}
+
+// receive handles the messages on c, each in its own goroutine, until the
+// connection fails or ctx is done.
+func receive(ctx context.Context, c *conn, handle func(context.Context, []byte) []byte) error {
+	stop := context.AfterFunc(ctx, func() { c.Close() })
+	defer stop()
+	for {
+		msg, err := c.next()
+		if err != nil {
+			return err
+		}
+		if msg.Reply == "" {
+			logger.WarnCF("nats", "Ignoring NATS message without a reply subject",
+				map[string]any{"subject": msg.Subject})
+			continue
+		}
+		go func() {
+			if err := c.publish(msg.Reply, handle(ctx, msg.Data)); err != nil {
+				logger.WarnCF("nats", "Publishing NATS reply failed", map[string]any{"error": err.Error()})
+			}
+		}()
+	}
+}
@@ -0,0 +1,133 @@
+package nats
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/agent"
+	"github.com/sipeed/picoclaw/pkg/constants"
+)
+
+type fakeAgent struct {
+	err                 error
+	content, sessionKey string
+	businessID          string
+}
+
+func (f *fakeAgent) ProcessDirectWithChannel(ctx context.Context, content, sessionKey, channel, chatID string,
+	media ...string,
+) (string, error) {
+	f.content, f.sessionKey = content, sessionKey
+	f.businessID, _ = ctx.Value(constants.ContextKeyBusinessID).(string)
+	return "done: " + content, f.err
+}
+
+func decode(t *testing.T, data []byte) Response {
+	var resp Response
+	require.NoError(t, json.Unmarshal(data, &resp))
+	return resp
+}
+
+func TestResponderHandle(t *testing.T) {
+	a := &fakeAgent{}
+	r := NewResponder(a, "gpt-test", nil)
+
+	resp := decode(t, r.Handle(context.Background(),
+		[]byte(`{"message":"balance?","session_id":"pump-7","business_id":"biz-1","request_id":"req-1"}`)))
+	assert.Equal(t, Response{Response: "done: balance?", Model: "gpt-test", RequestID: "req-1"}, resp)
+	assert.Equal(t, "nats:pump-7", a.sessionKey)
+	assert.Equal(t, "biz-1", a.businessID)
+
+	resp = decode(t, r.Handle(context.Background(), []byte("plain text")))
+	assert.Equal(t, "done: plain text", resp.Response)
+	assert.Equal(t, "nats:default", a.sessionKey)
+	assert.NotEmpty(t, resp.RequestID)
+
+	resp = decode(t, r.Handle(context.Background(), []byte(`{"message":" "}`)))
+	assert.Equal(t, "message is required", resp.Error)
+
+	a.err = agent.ErrMaintenance
+	resp = decode(t, r.Handle(context.Background(), []byte(`{"message":"hi","language":"fr"}`)))
+	assert.Equal(t, "picoclaw est en maintenance", resp.Error)
+	a.err = errors.New("provider down")
+	resp = decode(t, r.Handle(context.Background(), []byte("hi")))
+	assert.Equal(t, "provider down", resp.Error)
+	assert.Empty(t, resp.Response)
+}
+
+func TestServe(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	type published struct{ subject, data string }
+	subs := make(chan string, 1)
+	pubs := make(chan published, 1)
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		r := bufio.NewReader(c)
+		fmt.Fprint(c, "INFO {\"server_id\":\"test\"}\r\n")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			fields := strings.Fields(line)
+			switch fields[0] {
+			case "CONNECT":
+				if !strings.Contains(line, `"auth_token":"s3cret"`) {
+					fmt.Fprint(c, "-ERR 'Authorization Violation'\r\n")
+					return
+				}
+			case "PING":
+				fmt.Fprint(c, "PONG\r\n")
+			case "SUB":
+				subs <- strings.TrimSpace(line)
+				// A message without a reply subject is ignored.
+				fmt.Fprint(c, "PING\r\nMSG picoclaw.requests 1 5\r\nnoise\r\n")
+				fmt.Fprint(c, "MSG picoclaw.requests 1 _INBOX.abc 5\r\nhello\r\n")
+			case "PUB":
+				var n int
+				fmt.Sscan(fields[2], &n)
+				buf := make([]byte, n+2)
+				r.Read(buf)
+				pubs <- published{fields[1], string(buf[:n])}
+			}
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	handle := func(_ context.Context, data []byte) []byte { return append([]byte("re: "), data...) }
+
+	err = Serve(ctx, "http://"+ln.Addr().String(), "picoclaw.requests", "", handle)
+	assert.ErrorContains(t, err, "invalid NATS URL")
+	require.NoError(t, Serve(ctx, "nats://s3cret@"+ln.Addr().String(), "picoclaw.requests", "picoclaw", handle))
+
+	select {
+	case sub := <-subs:
+		assert.Equal(t, "SUB picoclaw.requests picoclaw 1", sub)
+	case <-ctx.Done():
+		t.Fatal("no subscription")
+	}
+	select {
+	case pub := <-pubs:
+		assert.Equal(t, published{"_INBOX.abc", "re: hello"}, pub)
+	case <-ctx.Done():
+		t.Fatal("no reply")
+	}
+}
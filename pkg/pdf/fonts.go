@@ -0,0 +1,116 @@
+package pdf
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// font is one of the standard PDF fonts, which every reader has built in.
+type font int
+
+const (
+	regular font = iota
+	bold
+	mono
+)
+
+// resourceName is the font's name in the page resources.
+func (f font) resourceName() string {
+	return [...]string{"F1", "F2", "F3"}[f]
+}
+
+// baseFont is the font's PostScript name.
+func (f font) baseFont() string {
+	return [...]string{"Helvetica", "Helvetica-Bold", "Courier"}[f]
+}
+
+// Glyph widths of the printable ASCII characters, in 1/1000 of the font
+// size, from the Adobe font metrics of the standard fonts.
+var (
+	helveticaWidths = [95]int{
+		278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278, // space to /
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, // 0 to 9
+		278, 278, 584, 584, 584, 556, 1015, // : to @
+		667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, // A to M
+		722, 778, 667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, // N to Z
+		278, 278, 278, 469, 556, 333, // [ to `
+		556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, // a to m
+		556, 556, 556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, // n to z
+		334, 260, 334, 584, // { to ~
+	}
+	helveticaBoldWidths = [95]int{
+		278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556,
+		333, 333, 584, 584, 584, 611, 975,
+		722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833,
+		722, 778, 667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611,
+		333, 278, 333, 584, 556, 333,
+		556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889,
+		611, 611, 611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500,
+		389, 280, 389, 584,
+	}
+)
+
+// width returns the width of encoded text at size points.
+func (f font) width(text []byte, size float64) float64 {
+	total := 0
+	for _, c := range text {
+		total += f.glyphWidth(c)
+	}
+	return float64(total) * size / 1000
+}
+
+func (f font) glyphWidth(c byte) int {
+	if f == mono {
+		return 600
+	}
+	widths := &helveticaWidths
+	if f == bold {
+		widths = &helveticaBoldWidths
+	}
+	switch {
+	case c >= 32 && c <= 126:
+		return widths[c-32]
+	case c == 0x95: // bullet
+		return 350
+	case c == 0x85, c == 0x97, c == 0x89: // ellipsis, em dash, per mille
+		return 1000
+	case c >= 0x91 && c <= 0x94: // curly quotes
+		return 333
+	default: // accented letters are about as wide as digits
+		return widths['0'-32]
+	}
+}
+
+// winAnsi maps the characters of the Windows-1252 range 0x80-0x9F, which
+// differs from Latin-1, to their codes.
+var winAnsi = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87, 'ˆ': 0x88,
+	'‰': 0x89, 'Š': 0x8A, '‹': 0x8B, 'Œ': 0x8C, 'Ž': 0x8E, '‘': 0x91, '’': 0x92, '“': 0x93,
+	'”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '˜': 0x98, '™': 0x99, 'š': 0x9A, '›': 0x9B,
+	'œ': 0x9C, 'ž': 0x9E, 'Ÿ': 0x9F,
+}
+
+// encode converts s to WinAnsiEncoding, the encoding of the standard fonts.
+// Emoji and other symbols are dropped and characters the fonts lack become
+// '?'.
+func encode(s string) []byte {
+	out := make([]byte, 0, len(s))
+	for len(s) > 0 {
+		r, size := utf8.DecodeRuneInString(s)
+		s = s[size:]
+		switch {
+		case r == '\t':
+			out = append(out, "    "...)
+		case r >= 32 && r <= 126, r >= 0xA0 && r <= 0xFF:
+			out = append(out, byte(r))
+		case winAnsi[r] != 0:
+			out = append(out, winAnsi[r])
+		case r < 32, unicode.Is(unicode.So, r), unicode.Is(unicode.Sk, r), unicode.Is(unicode.Mn, r),
+			unicode.Is(unicode.Cf, r), unicode.Is(unicode.Variation_Selector, r):
+		default:
+			out = append(out, '?')
+		}
+	}
+	return out
+}
@@ -0,0 +1,463 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	margin      = 56.0
+	footerSpace = 24.0 // kept free above the bottom margin for the footer
+	bodySize    = 10.5
+	tableSize   = 9.5
+	codeSize    = 9.0
+	cellPadding = 4.0
+	leading     = 1.4 // line height as a multiple of the font size
+)
+
+// numericCell matches amounts, percentages and counts, which tables align
+// right.
+var numericCell = regexp.MustCompile(`^[-+(]?\s*(?:[A-Z]{2,3}\s?)?[$€£¥]?\s*[-+]?[\d,]*\.?\d+\s*%?\)?(?:\s?[A-Z]{3})?$`)
+
+// piece is part of a word in one font, as in "**Total**:".
+type piece struct {
+	text []byte
+	font font
+}
+
+type word []piece
+
+func (w word) width(size float64) float64 {
+	total := 0.0
+	for _, p := range w {
+		total += p.font.width(p.text, size)
+	}
+	return total
+}
+
+// layout places blocks on pages, writing each page's content stream.
+type layout struct {
+	opts  Options
+	pages []*bytes.Buffer
+	page  *bytes.Buffer
+	y     float64 // top of the free space on the page
+}
+
+func newLayout(opts Options) *layout {
+	l := &layout{opts: opts}
+	l.newPage()
+	return l
+}
+
+func (l *layout) top() float64    { return l.opts.PageSize.Height - margin }
+func (l *layout) bottom() float64 { return margin + footerSpace }
+func (l *layout) width() float64  { return l.opts.PageSize.Width - 2*margin }
+
+func (l *layout) newPage() {
+	l.page = new(bytes.Buffer)
+	l.pages = append(l.pages, l.page)
+	l.y = l.top()
+}
+
+// ensure starts a new page unless height fits below the cursor. A block
+// taller than a page starts at the top and overflows.
+func (l *layout) ensure(height float64) {
+	if l.y-height < l.bottom() && l.y < l.top() {
+		l.newPage()
+	}
+}
+
+// space moves the cursor down, except at the top of a page.
+func (l *layout) space(h float64) {
+	if l.y < l.top() {
+		l.y -= h
+	}
+}
+
+func (l *layout) text(x, baseline float64, f font, size float64, text []byte) {
+	fmt.Fprintf(l.page, "BT /%s %.2f Tf %.2f %.2f Td (%s) Tj ET\n", f.resourceName(), size, x, baseline, escape(text))
+}
+
+func (l *layout) line(x1, y1, x2, y2, gray, width float64) {
+	fmt.Fprintf(l.page, "%.2f G %.2f w %.2f %.2f m %.2f %.2f l S 0 G\n", gray, width, x1, y1, x2, y2)
+}
+
+func (l *layout) fill(x, y, w, h, gray float64) {
+	fmt.Fprintf(l.page, "%.2f g %.2f %.2f %.2f %.2f re f 0 g\n", gray, x, y, w, h)
+}
+
+func (l *layout) render(blocks []block) {
+	if l.opts.Title != "" && (len(blocks) == 0 || blocks[0].kind != heading || blocks[0].level != 1) {
+		l.heading(block{kind: heading, level: 1, text: l.opts.Title})
+	}
+	for i, b := range blocks {
+		switch b.kind {
+		case heading:
+			l.heading(b)
+		case paragraph:
+			l.paragraph(parseInline(b.text, regular), margin, bodySize)
+			l.space(6)
+		case listItem:
+			l.listItem(b)
+			if i+1 == len(blocks) || blocks[i+1].kind != listItem {
+				l.space(6)
+			}
+		case quote:
+			l.quote(b)
+		case code:
+			l.code(b)
+		case table:
+			l.table(b)
+		case rule:
+			l.ensure(12)
+			l.y -= 6
+			l.line(margin, l.y, margin+l.width(), l.y, 0.7, 0.75)
+			l.y -= 8
+		}
+	}
+}
+
+func (l *layout) heading(b block) {
+	size := [...]float64{18, 14, 12, 11}[min(b.level, 4)-1]
+	l.space(size * 0.6)
+	// Keep a heading with the first lines that follow it.
+	l.ensure(size*leading + 2*bodySize*leading)
+	l.paragraph(parseInline(b.text, bold), margin, size)
+	if b.level == 1 {
+		l.line(margin, l.y+2, margin+l.width(), l.y+2, 0.6, 0.75)
+		l.y -= 4
+	}
+	l.y -= 2
+}
+
+func (l *layout) listItem(b block) {
+	x := margin + float64(b.level)*14
+	marker := encode(b.marker)
+	indent := max(14, regular.width(marker, bodySize)+5)
+	l.ensure(bodySize * leading)
+	l.text(x, l.baseline(bodySize), regular, bodySize, marker)
+	l.paragraph(parseInline(b.text, regular), x+indent, bodySize)
+	l.y -= 2
+}
+
+func (l *layout) quote(b block) {
+	start := l.y
+	startPage := len(l.pages)
+	fmt.Fprint(l.page, "0.35 g\n")
+	l.paragraph(parseInline(b.text, regular), margin+12, bodySize)
+	fmt.Fprint(l.page, "0 g\n")
+	if len(l.pages) == startPage {
+		l.line(margin+3, start, margin+3, l.y, 0.7, 2)
+	}
+	l.space(6)
+}
+
+func (l *layout) code(b block) {
+	lead := codeSize * 1.3
+	perLine := max(1, int((l.width()-12)/mono.width([]byte{' '}, codeSize)))
+	l.space(2)
+	for _, raw := range b.lines {
+		text := encode(raw)
+		for first := true; first || len(text) > 0; first = false {
+			chunk := text[:min(len(text), perLine)]
+			text = text[len(chunk):]
+			l.ensure(lead)
+			l.fill(margin, l.y-lead, l.width(), lead, 0.95)
+			l.text(margin+6, l.y-codeSize, mono, codeSize, chunk)
+			l.y -= lead
+		}
+	}
+	l.space(8)
+}
+
+// baseline returns the baseline of a line of text at size at the cursor.
+func (l *layout) baseline(size float64) float64 {
+	return l.y - size*1.05
+}
+
+// paragraph wraps spans to the space right of x and draws them.
+func (l *layout) paragraph(spans []span, x, size float64) {
+	for _, ln := range wrap(words(spans), size, l.opts.PageSize.Width-margin-x) {
+		l.ensure(size * leading)
+		l.drawLine(ln, x, l.baseline(size), size)
+		l.y -= size * leading
+	}
+}
+
+func (l *layout) drawLine(ln []word, x, baseline, size float64) {
+	space := regular.width([]byte{' '}, size)
+	for _, w := range ln {
+		for _, p := range w {
+			l.text(x, baseline, p.font, size, p.text)
+			x += p.font.width(p.text, size)
+		}
+		x += space
+	}
+}
+
+// tableLayout is a table's cells split into words, with the column widths
+// and alignment.
+type tableLayout struct {
+	cells  [][][]word // row, column, words
+	widths []float64
+	align  []alignment
+}
+
+func (l *layout) table(b block) {
+	t := l.measureTable(b)
+	l.space(2)
+	for r := range t.cells {
+		// Repeat the header at the top of each page the table continues on.
+		if _, height := t.row(r); r > 0 && l.y-height < l.bottom() {
+			l.newPage()
+			l.tableRow(t, 0)
+		}
+		l.tableRow(t, r)
+	}
+	l.space(10)
+}
+
+func (l *layout) measureTable(b block) *tableLayout {
+	cols := 0
+	for _, row := range b.rows {
+		cols = max(cols, len(row))
+	}
+	t := &tableLayout{cells: make([][][]word, len(b.rows)), align: make([]alignment, cols)}
+	natural := make([]float64, cols)
+	widest := make([]float64, cols)
+	space := regular.width([]byte{' '}, tableSize)
+	for r, row := range b.rows {
+		base := regular
+		if r == 0 {
+			base = bold
+		}
+		t.cells[r] = make([][]word, cols)
+		for c := 0; c < cols && c < len(row); c++ {
+			ws := words(parseInline(row[c], base))
+			t.cells[r][c] = ws
+			natural[c] = max(natural[c], lineWidth(ws, tableSize, space)+2*cellPadding)
+			for _, w := range ws {
+				widest[c] = max(widest[c], w.width(tableSize)+2*cellPadding)
+			}
+		}
+	}
+	t.widths = columnWidths(natural, widest, l.width())
+	for c := range t.align {
+		if c < len(b.align) {
+			t.align[c] = b.align[c]
+		}
+		if t.align[c] == alignDefault && numericColumn(b.rows, c) {
+			t.align[c] = alignRight
+		}
+	}
+	return t
+}
+
+// row wraps row r's cells to their columns and returns its lines and height.
+func (t *tableLayout) row(r int) ([][][]word, float64) {
+	lines := make([][][]word, len(t.widths))
+	height := 1
+	for c, w := range t.widths {
+		lines[c] = wrap(t.cells[r][c], tableSize, w-2*cellPadding)
+		height = max(height, len(lines[c]))
+	}
+	return lines, float64(height)*tableSize*leading + 2*cellPadding
+}
+
+func (l *layout) tableRow(t *tableLayout, r int) {
+	lines, height := t.row(r)
+	l.ensure(height)
+	total := sum(t.widths)
+	if r == 0 {
+		l.fill(margin, l.y-height, total, height, 0.92)
+	}
+	space := regular.width([]byte{' '}, tableSize)
+	x := margin
+	for c, w := range t.widths {
+		for k, ln := range lines[c] {
+			lx := x + cellPadding
+			switch lw := lineWidth(ln, tableSize, space); t.align[c] {
+			case alignRight:
+				lx = x + w - cellPadding - lw
+			case alignCenter:
+				lx = x + (w-lw)/2
+			}
+			baseline := l.y - cellPadding - tableSize*0.9 - float64(k)*tableSize*leading
+			l.drawLine(ln, lx, baseline, tableSize)
+		}
+		x += w
+	}
+	l.y -= height
+	if r == 0 {
+		l.line(margin, l.y, margin+total, l.y, 0.4, 0.75)
+	} else {
+		l.line(margin, l.y, margin+total, l.y, 0.8, 0.5)
+	}
+}
+
+// columnWidths fits a table into available width: columns get their natural
+// width when it all fits, otherwise their widest word plus a share of the
+// rest in proportion to how much more they need.
+func columnWidths(natural, widest []float64, available float64) []float64 {
+	widths := make([]float64, len(natural))
+	if sum(natural) <= available {
+		copy(widths, natural)
+		return widths
+	}
+	minimum := make([]float64, len(natural))
+	for c := range natural {
+		minimum[c] = min(widest[c], natural[c], available/2)
+	}
+	if total := sum(minimum); total >= available {
+		for c := range minimum {
+			widths[c] = minimum[c] * available / total
+		}
+		return widths
+	}
+	extra := available - sum(minimum)
+	need := sum(natural) - sum(minimum)
+	for c := range natural {
+		widths[c] = minimum[c] + extra*(natural[c]-minimum[c])/need
+	}
+	return widths
+}
+
+func numericColumn(rows [][]string, c int) bool {
+	found := false
+	for _, row := range rows[1:] {
+		if c >= len(row) || row[c] == "" {
+			continue
+		}
+		if !numericCell.MatchString(strings.Trim(row[c], "*")) {
+			return false
+		}
+		found = true
+	}
+	return found
+}
+
+// finish adds the footers and returns the page content streams.
+func (l *layout) finish() [][]byte {
+	pages := make([][]byte, len(l.pages))
+	title := encode(l.opts.Title)
+	for i, p := range l.pages {
+		l.page = p
+		fmt.Fprint(p, "0.45 g\n")
+		baseline := margin - 8
+		if len(title) > 0 {
+			l.text(margin, baseline, regular, 8, title)
+		}
+		num := encode(fmt.Sprintf("Page %d of %d", i+1, len(l.pages)))
+		l.text(margin+l.width()-regular.width(num, 8), baseline, regular, 8, num)
+		fmt.Fprint(p, "0 g\n")
+		pages[i] = p.Bytes()
+	}
+	return pages
+}
+
+// words splits spans into words, keeping a word's pieces together when its
+// font changes mid-word.
+func words(spans []span) []word {
+	var out []word
+	var cur word
+	for _, s := range spans {
+		text := encode(s.text)
+		start := 0
+		for i := 0; i <= len(text); i++ {
+			if i < len(text) && text[i] != ' ' {
+				continue
+			}
+			if i > start {
+				cur = append(cur, piece{text: text[start:i], font: s.font})
+			}
+			if i < len(text) && len(cur) > 0 {
+				out = append(out, cur)
+				cur = nil
+			}
+			start = i + 1
+		}
+	}
+	if len(cur) > 0 {
+		out = append(out, cur)
+	}
+	return out
+}
+
+// wrap breaks words into lines no wider than width, splitting words that
+// are wider on their own.
+func wrap(ws []word, size, width float64) [][]word {
+	space := regular.width([]byte{' '}, size)
+	var lines [][]word
+	var cur []word
+	curWidth := 0.0
+	for _, w := range ws {
+		for _, part := range splitWord(w, size, width) {
+			pw := part.width(size)
+			if len(cur) > 0 && curWidth+space+pw > width {
+				lines = append(lines, cur)
+				cur, curWidth = nil, 0
+			}
+			if len(cur) > 0 {
+				curWidth += space
+			}
+			cur = append(cur, part)
+			curWidth += pw
+		}
+	}
+	if len(cur) > 0 {
+		lines = append(lines, cur)
+	}
+	return lines
+}
+
+// splitWord breaks a word wider than width into pieces that fit.
+func splitWord(w word, size, width float64) []word {
+	if w.width(size) <= width {
+		return []word{w}
+	}
+	var parts []word
+	var cur word
+	curWidth := 0.0
+	for _, p := range w {
+		start := 0
+		for i, c := range p.text {
+			cw := float64(p.font.glyphWidth(c)) * size / 1000
+			if curWidth+cw > width && (curWidth > 0 || i > start) {
+				if i > start {
+					cur = append(cur, piece{text: p.text[start:i], font: p.font})
+				}
+				parts = append(parts, cur)
+				cur, curWidth, start = nil, 0, i
+			}
+			curWidth += cw
+		}
+		if start < len(p.text) {
+			cur = append(cur, piece{text: p.text[start:], font: p.font})
+		}
+	}
+	if len(cur) > 0 {
+		parts = append(parts, cur)
+	}
+	return parts
+}
+
+func lineWidth(ws []word, size, space float64) float64 {
+	total := 0.0
+	for i, w := range ws {
+		if i > 0 {
+			total += space
+		}
+		total += w.width(size)
+	}
+	return total
+}
+
+func sum(values []float64) float64 {
+	total := 0.0
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
@@ -0,0 +1,228 @@
+package pdf
+
+import (
+	"regexp"
+	"strings"
+)
+
+type blockKind int
+
+const (
+	paragraph blockKind = iota
+	heading
+	listItem
+	table
+	code
+	rule
+	quote
+)
+
+type alignment int
+
+const (
+	alignDefault alignment = iota
+	alignLeft
+	alignRight
+	alignCenter
+)
+
+// block is a top-level Markdown element.
+type block struct {
+	kind   blockKind
+	level  int    // heading level, or list nesting depth from 0
+	marker string // list bullet or number
+	text   string // paragraph, heading, list item and quote text
+	lines  []string
+	rows   [][]string // table rows, header first
+	align  []alignment
+}
+
+var (
+	headingLine   = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	ruleLine      = regexp.MustCompile(`^\s*(?:(?:\*\s*){3,}|(?:-\s*){3,}|(?:_\s*){3,})$`)
+	listLine      = regexp.MustCompile(`^(\s*)([-*+]|\d{1,3}[.)])\s+(.*)$`)
+	quoteLine     = regexp.MustCompile(`^\s*>\s?(.*)$`)
+	tableDivider  = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+	markdownLink  = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	emphasis      = regexp.MustCompile(`(^|[\s(])\*([^*\s][^*]*?)\*`)
+	fenceLine     = regexp.MustCompile("^\\s*(```|~~~)")
+	checkboxStart = regexp.MustCompile(`^\[([ xX])\]\s+`)
+)
+
+// parseBlocks splits markdown into blocks. It covers what agents write in
+// reports, not all of CommonMark.
+func parseBlocks(markdown string) []block {
+	lines := strings.Split(strings.ReplaceAll(markdown, "\r\n", "\n"), "\n")
+	var blocks []block
+	var para []string
+	flush := func() {
+		if len(para) > 0 {
+			blocks = append(blocks, block{kind: paragraph, text: strings.Join(para, " ")})
+			para = nil
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			flush()
+
+		case fenceLine.MatchString(line):
+			flush()
+			fence := fenceLine.FindStringSubmatch(line)[1]
+			b := block{kind: code}
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), fence); i++ {
+				b.lines = append(b.lines, lines[i])
+			}
+			blocks = append(blocks, b)
+
+		case headingLine.MatchString(trimmed):
+			flush()
+			m := headingLine.FindStringSubmatch(trimmed)
+			blocks = append(blocks, block{kind: heading, level: len(m[1]), text: m[2]})
+
+		case ruleLine.MatchString(line):
+			flush()
+			blocks = append(blocks, block{kind: rule})
+
+		case strings.HasPrefix(trimmed, "|") && i+1 < len(lines) && tableDivider.MatchString(lines[i+1]) &&
+			strings.Contains(lines[i+1], "-"):
+			flush()
+			b := block{kind: table, rows: [][]string{splitRow(trimmed)}}
+			for _, cell := range splitRow(strings.TrimSpace(lines[i+1])) {
+				b.align = append(b.align, cellAlignment(cell))
+			}
+			for i += 2; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), "|"); i++ {
+				b.rows = append(b.rows, splitRow(strings.TrimSpace(lines[i])))
+			}
+			i--
+			blocks = append(blocks, b)
+
+		case listLine.MatchString(line):
+			flush()
+			m := listLine.FindStringSubmatch(line)
+			marker := m[2]
+			if strings.ContainsAny(marker, "-*+") {
+				marker = "•"
+			}
+			text := m[3]
+			if c := checkboxStart.FindStringSubmatch(text); c != nil {
+				marker = "[ ]"
+				if c[1] != " " {
+					marker = "[x]"
+				}
+				text = text[len(c[0]):]
+			}
+			// Indented lines that don't start a new item continue this one.
+			for i+1 < len(lines) && strings.HasPrefix(lines[i+1], "  ") &&
+				strings.TrimSpace(lines[i+1]) != "" && !listLine.MatchString(lines[i+1]) {
+				i++
+				text += " " + strings.TrimSpace(lines[i])
+			}
+			blocks = append(blocks, block{kind: listItem, level: indentLevel(m[1]), marker: marker, text: text})
+
+		case quoteLine.MatchString(line):
+			flush()
+			var text []string
+			for ; i < len(lines) && quoteLine.MatchString(lines[i]); i++ {
+				text = append(text, quoteLine.FindStringSubmatch(lines[i])[1])
+			}
+			i--
+			blocks = append(blocks, block{kind: quote, text: strings.Join(text, " ")})
+
+		default:
+			para = append(para, trimmed)
+		}
+	}
+	flush()
+	return blocks
+}
+
+func indentLevel(indent string) int {
+	width := len(strings.ReplaceAll(indent, "\t", "    "))
+	return min(width/2, 4)
+}
+
+// splitRow splits a table row into its cells. Escaped pipes stay in the
+// cell.
+func splitRow(row string) []string {
+	row = strings.TrimSuffix(strings.TrimPrefix(row, "|"), "|")
+	row = strings.ReplaceAll(row, `\|`, "\x00")
+	cells := strings.Split(row, "|")
+	for i, c := range cells {
+		cells[i] = strings.TrimSpace(strings.ReplaceAll(c, "\x00", "|"))
+	}
+	return cells
+}
+
+func cellAlignment(divider string) alignment {
+	left, right := strings.HasPrefix(divider, ":"), strings.HasSuffix(divider, ":")
+	switch {
+	case left && right:
+		return alignCenter
+	case right:
+		return alignRight
+	case left:
+		return alignLeft
+	default:
+		return alignDefault
+	}
+}
+
+// span is a run of text in one font.
+type span struct {
+	text string
+	font font
+}
+
+// parseInline splits text into runs of regular, **bold** and `code` text.
+// Links become "text (url)" and *emphasis* markers are dropped, since the
+// standard fonts used have no italics.
+func parseInline(text string, base font) []span {
+	text = markdownLink.ReplaceAllStringFunc(text, func(link string) string {
+		m := markdownLink.FindStringSubmatch(link)
+		if m[1] == m[2] {
+			return m[1]
+		}
+		return m[1] + " (" + m[2] + ")"
+	})
+	text = emphasis.ReplaceAllString(text, "$1$2")
+
+	var spans []span
+	var cur strings.Builder
+	boldOn, codeOn := false, false
+	emit := func() {
+		if cur.Len() == 0 {
+			return
+		}
+		f := base
+		switch {
+		case codeOn:
+			f = mono
+		case boldOn:
+			f = bold
+		}
+		spans = append(spans, span{cur.String(), f})
+		cur.Reset()
+	}
+	for i := 0; i < len(text); i++ {
+		switch {
+		case text[i] == '`':
+			emit()
+			codeOn = !codeOn
+		case !codeOn && (strings.HasPrefix(text[i:], "**") || strings.HasPrefix(text[i:], "__")):
+			emit()
+			boldOn = !boldOn
+			i++
+		case !codeOn && text[i] == '\\' && i+1 < len(text) && strings.IndexByte("\\`*_[]()#|-", text[i+1]) >= 0:
+			i++
+			cur.WriteByte(text[i])
+		default:
+			cur.WriteByte(text[i])
+		}
+	}
+	emit()
+	return spans
+}
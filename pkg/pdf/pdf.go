@@ -0,0 +1,108 @@
+// Package pdf renders Markdown reports, such as monthly expense reports and
+// invoices, to PDF without external tools. It supports headings,
+// paragraphs with bold and code spans, lists, tables, code blocks and rules.
+// Text uses the standard PDF fonts (Helvetica and Courier), so documents
+// need no embedded font files, at the cost of covering only Western
+// European characters.
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"time"
+)
+
+// PageSize is a page's width and height in points.
+type PageSize struct {
+	Width, Height float64
+}
+
+var (
+	Letter = PageSize{612, 792}
+	A4     = PageSize{595.28, 841.89}
+)
+
+// Options configures a document.
+type Options struct {
+	Title    string    // shown in the footer and the document properties
+	PageSize PageSize  // default Letter
+	Created  time.Time // default now
+}
+
+// Render lays out markdown and returns the PDF and its number of pages.
+func Render(markdown string, opts Options) ([]byte, int) {
+	if opts.PageSize.Width <= 0 || opts.PageSize.Height <= 0 {
+		opts.PageSize = Letter
+	}
+	if opts.Created.IsZero() {
+		opts.Created = time.Now()
+	}
+	l := newLayout(opts)
+	l.render(parseBlocks(markdown))
+	pages := l.finish()
+	return write(pages, opts), len(pages)
+}
+
+// write serializes the page content streams into a PDF file.
+func write(pages [][]byte, opts Options) []byte {
+	var buf bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	// Objects 1-6 are the catalog, page tree, fonts and info; each page
+	// then takes a page object and a content stream.
+	const firstPage = 7
+	kids := new(bytes.Buffer)
+	for i := range pages {
+		fmt.Fprintf(kids, "%d 0 R ", firstPage+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", bytes.TrimSpace(kids.Bytes()), len(pages)))
+	for _, f := range []font{regular, bold, mono} {
+		object(fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>",
+			f.baseFont()))
+	}
+	object(fmt.Sprintf("<< /Title (%s) /Producer (picoclaw) /CreationDate (D:%s) >>",
+		escape(encode(opts.Title)), opts.Created.UTC().Format("20060102150405Z")))
+
+	resources := "<< /Font << /F1 3 0 R /F2 4 0 R /F3 5 0 R >> >>"
+	for i, content := range pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources %s /Contents %d 0 R >>",
+			opts.PageSize.Width, opts.PageSize.Height, resources, firstPage+2*i+1))
+
+		var z bytes.Buffer
+		zw := zlib.NewWriter(&z)
+		zw.Write(content)
+		zw.Close()
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n", len(offsets), z.Len())
+		buf.Write(z.Bytes())
+		buf.WriteString("\nendstream\nendobj\n")
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Info 6 0 R >>\nstartxref\n%d\n%%%%EOF\n",
+		len(offsets)+1, xref)
+	return buf.Bytes()
+}
+
+// escape makes encoded text safe inside a PDF string literal.
+func escape(text []byte) []byte {
+	out := make([]byte, 0, len(text))
+	for _, c := range text {
+		if c == '(' || c == ')' || c == '\\' {
+			out = append(out, '\\')
+		}
+		out = append(out, c)
+	}
+	return out
+}
@@ -0,0 +1,103 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pageTexts checks that data is a well-formed PDF and returns the
+// decompressed content stream of each page.
+func pageTexts(t *testing.T, data []byte) []string {
+	t.Helper()
+	require.True(t, bytes.HasPrefix(data, []byte("%PDF-1.4\n")))
+	require.True(t, bytes.HasSuffix(data, []byte("%%EOF\n")))
+
+	m := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(data)
+	require.NotNil(t, m)
+	xref, _ := strconv.Atoi(string(m[1]))
+	require.True(t, bytes.HasPrefix(data[xref:], []byte("xref\n0 ")))
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(data[xref:], -1)
+	for i, e := range entries {
+		off, _ := strconv.Atoi(string(e[1]))
+		assert.True(t, bytes.HasPrefix(data[off:], fmt.Appendf(nil, "%d 0 obj\n", i+1)), "xref entry %d", i+1)
+	}
+
+	var pages []string
+	streams := regexp.MustCompile(`(?s)/Length (\d+) /Filter /FlateDecode >>\nstream\n`)
+	for _, loc := range streams.FindAllSubmatchIndex(data, -1) {
+		n, _ := strconv.Atoi(string(data[loc[2]:loc[3]]))
+		zr, err := zlib.NewReader(bytes.NewReader(data[loc[1] : loc[1]+n]))
+		require.NoError(t, err)
+		content, err := io.ReadAll(zr)
+		require.NoError(t, err)
+		pages = append(pages, string(content))
+	}
+	return pages
+}
+
+func TestRenderReport(t *testing.T) {
+	md := "# March Expenses\n\n" +
+		"Spending was **12% lower** than in February. See [the ledger](https://example.com/l).\n\n" +
+		"| Category | Amount |\n|---|---|\n| Meals (client) | $1,200.50 |\n| Travel | $88.00 |\n\n" +
+		"- Two receipts need review\n- Café invoice is due\n\n" +
+		"```\nsafe_to_spend = 300\n```\n"
+	data, pages := Render(md, Options{Title: "Acme — March", Created: time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)})
+	assert.Equal(t, 1, pages)
+	assert.Contains(t, string(data), "/Title (Acme \x97 March)")
+	assert.Contains(t, string(data), "/CreationDate (D:20260331000000Z)")
+
+	texts := pageTexts(t, data)
+	require.Len(t, texts, 1)
+	for _, want := range []string{
+		"/F2 18.00 Tf", "(March) Tj", // the document's own title heading
+		"/F2 10.50 Tf", "(lower) Tj", "(\\(https://example.com/l\\).) Tj",
+		"(Meals) Tj", "($1,200.50) Tj", "(\x95) Tj", "(Caf\xe9) Tj", "/F3 9.00 Tf",
+		"(Page 1 of 1) Tj",
+	} {
+		assert.Contains(t, texts[0], want)
+	}
+	assert.Equal(t, 1, strings.Count(texts[0], "(March) Tj"),
+		"the title is not repeated when the content has its own heading")
+}
+
+func TestRenderRepeatsTableHeaderOnNewPages(t *testing.T) {
+	var md strings.Builder
+	md.WriteString("| Date | Vendor | Amount |\n|:--|---|--:|\n")
+	for i := range 120 {
+		fmt.Fprintf(&md, "| 2026-03-%02d | Vendor %d | %d.00 |\n", i%28+1, i, i)
+	}
+	data, pages := Render(md.String(), Options{Title: "Ledger", PageSize: A4})
+	require.Greater(t, pages, 1)
+	assert.Contains(t, string(data), "/MediaBox [0 0 595.28 841.89]")
+
+	texts := pageTexts(t, data)
+	require.Len(t, texts, pages)
+	for i, text := range texts {
+		assert.Contains(t, text, "(Vendor) Tj", "page %d", i+1)
+		assert.Contains(t, text, fmt.Sprintf("(Page %d of %d) Tj", i+1, pages))
+	}
+	assert.Contains(t, texts[len(texts)-1], "(119.00) Tj")
+}
+
+func TestWrapSplitsLongWords(t *testing.T) {
+	lines := wrap(words([]span{{strings.Repeat("x", 200), regular}}), 10, 100)
+	require.Greater(t, len(lines), 1)
+	for _, ln := range lines {
+		assert.LessOrEqual(t, lineWidth(ln, 10, 0), 100.0)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	assert.Equal(t, []byte("Total: \x80 5 \x97 ok "), encode("Total: € 5 — ok 📎"))
+	assert.Equal(t, []byte("Gr\xfc\xdfe ?"), encode("Grüße 日"))
+}
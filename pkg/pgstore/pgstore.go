@@ -0,0 +1,243 @@
+// Package pgstore keeps sessions and workspace state in Postgres instead of
+// JSON files in the workspace, for picoclaw running as a shared service
+// rather than on a single device.
+//
+// Sessions live in picoclaw_sessions and state in picoclaw_state. Both are
+// keyed by a namespace, so several deployments (or agents) can share one
+// database. picoclaw_leases holds the leases gateways sharing a namespace
+// elect a leader with. The tables are created on Open.
+package pgstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib" // registers the "pgx" driver
+
+	"github.com/sipeed/picoclaw/pkg/session"
+	"github.com/sipeed/picoclaw/pkg/state"
+)
+
+// queryTimeout bounds each load or save, since the stores are called without
+// a context.
+const queryTimeout = 10 * time.Second
+
+const schema = `
+CREATE TABLE IF NOT EXISTS picoclaw_sessions (
+	namespace  TEXT NOT NULL,
+	key        TEXT NOT NULL,
+	data       JSONB NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (namespace, key)
+);
+CREATE TABLE IF NOT EXISTS picoclaw_state (
+	namespace  TEXT PRIMARY KEY,
+	data       JSONB NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE TABLE IF NOT EXISTS picoclaw_leases (
+	name       TEXT PRIMARY KEY,
+	holder     TEXT NOT NULL,
+	expires_at TIMESTAMPTZ NOT NULL
+);
+`
+
+// DB is a connection pool to the picoclaw database.
+type DB struct {
+	db *sql.DB
+}
+
+// Open connects to the database at dsn (a postgres:// URL or key=value
+// string) and creates the tables if needed.
+func Open(ctx context.Context, dsn string) (*DB, error) {
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create tables: %w", err)
+	}
+	return &DB{db: db}, nil
+}
+
+// Close closes the pool.
+func (d *DB) Close() error {
+	return d.db.Close()
+}
+
+// Sessions returns the session store for namespace.
+func (d *DB) Sessions(namespace string) *SessionStore {
+	return &SessionStore{db: d.db, namespace: namespace}
+}
+
+// State returns the state store for namespace.
+func (d *DB) State(namespace string) *StateStore {
+	return &StateStore{db: d.db, namespace: namespace}
+}
+
+// Lease returns the named lease, such as "<namespace>/leader".
+func (d *DB) Lease(name string) *Lease {
+	return &Lease{db: d.db, name: name}
+}
+
+// SessionStore implements session.Store.
+type SessionStore struct {
+	db        *sql.DB
+	namespace string
+}
+
+var _ session.Store = (*SessionStore)(nil)
+
+func (s *SessionStore) Load() ([]*session.Session, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT data FROM picoclaw_sessions WHERE namespace = $1`, s.namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []*session.Session
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var sess session.Session
+		if err := json.Unmarshal(data, &sess); err != nil {
+			continue
+		}
+		sessions = append(sessions, &sess)
+	}
+	return sessions, rows.Err()
+}
+
+func (s *SessionStore) Save(sess *session.Session) error {
+	if sess.Key == "" {
+		return errors.New("session key is empty")
+	}
+	data, err := json.Marshal(sess)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO picoclaw_sessions (namespace, key, data, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (namespace, key) DO UPDATE SET data = EXCLUDED.data, updated_at = EXCLUDED.updated_at`,
+		s.namespace, sess.Key, string(data), time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	return nil
+}
+
+func (s *SessionStore) Delete(key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx,
+		`DELETE FROM picoclaw_sessions WHERE namespace = $1 AND key = $2`, s.namespace, key)
+	if err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	return nil
+}
+
+// StateStore implements state.Store.
+type StateStore struct {
+	db        *sql.DB
+	namespace string
+}
+
+var _ state.Store = (*StateStore)(nil)
+
+func (s *StateStore) Load() (*state.State, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	var data []byte
+	err := s.db.QueryRowContext(ctx,
+		`SELECT data FROM picoclaw_state WHERE namespace = $1`, s.namespace).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load state: %w", err)
+	}
+	var st state.State
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal state: %w", err)
+	}
+	return &st, nil
+}
+
+func (s *StateStore) Save(st *state.State) error {
+	data, err := json.Marshal(st)
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO picoclaw_state (namespace, data, updated_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (namespace) DO UPDATE SET data = EXCLUDED.data, updated_at = EXCLUDED.updated_at`,
+		s.namespace, string(data), time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+	return nil
+}
+
+// Lease is a named lock held by one holder until it expires. Expiry is
+// decided by the database's clock, so holders need not agree on the time.
+type Lease struct {
+	db   *sql.DB
+	name string
+}
+
+// Acquire takes the lease for holder, or extends it if holder already has
+// it, until ttl from now. It reports whether holder has the lease.
+func (l *Lease) Acquire(ctx context.Context, holder string, ttl time.Duration) (bool, error) {
+	var current string
+	err := l.db.QueryRowContext(ctx, `
+		INSERT INTO picoclaw_leases (name, holder, expires_at)
+		VALUES ($1, $2, now() + $3::bigint * interval '1 millisecond')
+		ON CONFLICT (name) DO UPDATE SET holder = EXCLUDED.holder, expires_at = EXCLUDED.expires_at
+		WHERE picoclaw_leases.holder = EXCLUDED.holder OR picoclaw_leases.expires_at < now()
+		RETURNING holder`,
+		l.name, holder, ttl.Milliseconds()).Scan(&current)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil // held by someone else
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire lease %s: %w", l.name, err)
+	}
+	return current == holder, nil
+}
+
+// Release gives up the lease if holder has it, so another holder can take
+// it without waiting for it to expire.
+func (l *Lease) Release(ctx context.Context, holder string) error {
+	_, err := l.db.ExecContext(ctx,
+		`DELETE FROM picoclaw_leases WHERE name = $1 AND holder = $2`, l.name, holder)
+	if err != nil {
+		return fmt.Errorf("failed to release lease %s: %w", l.name, err)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package pgstore
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/session"
+	"github.com/sipeed/picoclaw/pkg/state"
+)
+
+// openTestDB connects to PICOCLAW_TEST_POSTGRES_DSN, skipping the test when
+// it is not set.
+func openTestDB(t *testing.T) *DB {
+	t.Helper()
+	dsn := os.Getenv("PICOCLAW_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("PICOCLAW_TEST_POSTGRES_DSN not set")
+	}
+	db, err := Open(context.Background(), dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestSessionsRoundTrip(t *testing.T) {
+	db := openTestDB(t)
+	namespace := fmt.Sprintf("test-%d/main", time.Now().UnixNano())
+
+	sm := session.NewSessionManagerWithStore(db.Sessions(namespace))
+	sm.AddMessage("telegram:42", "user", "hello")
+	sm.SetSummary("telegram:42", "greeting")
+	require.NoError(t, sm.Save("telegram:42"))
+	sm.AddMessage("telegram:42", "assistant", "hi")
+	require.NoError(t, sm.Save("telegram:42"))
+
+	reloaded := session.NewSessionManagerWithStore(db.Sessions(namespace))
+	history := reloaded.GetHistory("telegram:42")
+	require.Len(t, history, 2)
+	assert.Equal(t, "hi", history[1].Content)
+	assert.Equal(t, "greeting", reloaded.GetSummary("telegram:42"))
+
+	other := session.NewSessionManagerWithStore(db.Sessions(namespace + "-other"))
+	assert.Empty(t, other.GetHistory("telegram:42"), "namespaces are separate")
+}
+
+func TestStateRoundTrip(t *testing.T) {
+	db := openTestDB(t)
+	namespace := fmt.Sprintf("test-%d", time.Now().UnixNano())
+
+	sm := state.NewManagerWithStore(db.State(namespace))
+	assert.Empty(t, sm.GetLastChannel())
+	require.NoError(t, sm.SetLastChannel("telegram:42"))
+	require.NoError(t, sm.SetBusinessAuth("biz-1", "jwt", "telegram", "42"))
+
+	reloaded := state.NewManagerWithStore(db.State(namespace))
+	assert.Equal(t, "telegram:42", reloaded.GetLastChannel())
+	assert.Equal(t, "jwt", reloaded.GetActiveAuth()["biz-1"].JWTToken)
+}
+
+func TestLeaseHeldByOneHolder(t *testing.T) {
+	db := openTestDB(t)
+	lease := db.Lease(fmt.Sprintf("test-%d/leader", time.Now().UnixNano()))
+	ctx := context.Background()
+
+	ok, err := lease.Acquire(ctx, "a", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = lease.Acquire(ctx, "b", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok, "b must wait while a holds the lease")
+	ok, err = lease.Acquire(ctx, "a", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok, "a renews its own lease")
+
+	require.NoError(t, lease.Release(ctx, "a"))
+	ok, err = lease.Acquire(ctx, "b", 10*time.Millisecond)
+	require.NoError(t, err)
+	assert.True(t, ok, "b takes a released lease")
+
+	time.Sleep(50 * time.Millisecond)
+	ok, err = lease.Acquire(ctx, "a", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok, "a takes an expired lease")
+}
@@ -0,0 +1,54 @@
+package portmap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// rtfGateway marks routes through a gateway in /proc/net/route.
+const rtfGateway = 0x2
+
+// defaultGateway returns the gateway of the IPv4 default route.
+func defaultGateway() (net.IP, error) {
+	f, err := os.Open("/proc/net/route")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseRoutes(f)
+}
+
+// parseRoutes reads the default gateway from a /proc/net/route table, whose
+// addresses are hex in host (little-endian) byte order.
+func parseRoutes(r io.Reader) (net.IP, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Scan() // the header
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || fields[1] != "00000000" {
+			continue
+		}
+		flags, err := strconv.ParseUint(fields[3], 16, 32)
+		if err != nil || flags&rtfGateway == 0 {
+			continue
+		}
+		raw, err := hex.DecodeString(fields[2])
+		if err != nil || len(raw) != 4 {
+			continue
+		}
+		ip := make(net.IP, 4)
+		binary.BigEndian.PutUint32(ip, binary.LittleEndian.Uint32(raw))
+		return ip, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, errors.New("no default route")
+}
@@ -0,0 +1,21 @@
+package portmap
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRoutes(t *testing.T) {
+	table := "Iface\tDestination\tGateway \tFlags\tRefCnt\tUse\tMetric\tMask\t\tMTU\tWindow\tIRTT\n" +
+		"wlan0\t0001A8C0\t00000000\t0001\t0\t0\t600\t00FFFFFF\t0\t0\t0\n" +
+		"wlan0\t00000000\t0101A8C0\t0003\t0\t0\t600\t00000000\t0\t0\t0\n"
+	ip, err := parseRoutes(strings.NewReader(table))
+	require.NoError(t, err)
+	assert.Equal(t, "192.168.1.1", ip.String())
+
+	_, err = parseRoutes(strings.NewReader(strings.SplitAfter(table, "\n")[0]))
+	assert.Error(t, err)
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package portmap
+
+import (
+	"errors"
+	"net"
+)
+
+// defaultGateway is only implemented on Linux; elsewhere ports are mapped
+// with UPnP alone.
+func defaultGateway() (net.IP, error) {
+	return nil, errors.New("finding the default gateway is only supported on Linux")
+}
@@ -0,0 +1,123 @@
+package portmap
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	natPMPPort = 5351
+
+	natPMPOpExternalAddress = 0
+	natPMPOpMapTCP          = 2
+
+	// natPMPTries requests are sent, 250ms apart and then doubling, as RFC
+	// 6886 asks, before the router is taken not to speak NAT-PMP.
+	natPMPTries = 4
+)
+
+// natPMPResults are the result codes of RFC 6886, section 3.5.
+var natPMPResults = map[uint16]string{
+	1: "unsupported version",
+	2: "not authorized or refused",
+	3: "network failure",
+	4: "out of resources",
+	5: "unsupported opcode",
+}
+
+// natPMP maps ports with the NAT-PMP server on the default gateway.
+type natPMP struct {
+	gateway net.IP
+	port    int // natPMPPort when zero
+}
+
+func (n *natPMP) name() string { return "NAT-PMP" }
+
+func (n *natPMP) mapPort(ctx context.Context, internal, external int, lifetime time.Duration) (*Mapping, error) {
+	resp, err := n.request(ctx, natPMPOpExternalAddress, nil, 12)
+	if err != nil {
+		return nil, err
+	}
+	ip := net.IP(resp[8:12])
+
+	req := make([]byte, 10)
+	binary.BigEndian.PutUint16(req[2:], uint16(internal))
+	binary.BigEndian.PutUint16(req[4:], uint16(external))
+	binary.BigEndian.PutUint32(req[6:], uint32(lifetime/time.Second))
+	resp, err = n.request(ctx, natPMPOpMapTCP, req, 16)
+	if err != nil {
+		return nil, err
+	}
+	return &Mapping{
+		Protocol:     n.name(),
+		ExternalIP:   ip,
+		ExternalPort: int(binary.BigEndian.Uint16(resp[10:])),
+		InternalPort: int(binary.BigEndian.Uint16(resp[8:])),
+		Lifetime:     time.Duration(binary.BigEndian.Uint32(resp[12:])) * time.Second,
+	}, nil
+}
+
+// unmapPort deletes the mapping by requesting it with a lifetime of zero.
+func (n *natPMP) unmapPort(ctx context.Context, m *Mapping) error {
+	req := make([]byte, 10)
+	binary.BigEndian.PutUint16(req[2:], uint16(m.InternalPort))
+	_, err := n.request(ctx, natPMPOpMapTCP, req, 16)
+	return err
+}
+
+// request sends the opcode op with body, which follows the version and
+// opcode, and returns the response once it is at least size bytes long and
+// reports success.
+func (n *natPMP) request(ctx context.Context, op byte, body []byte, size int) ([]byte, error) {
+	port := n.port
+	if port == 0 {
+		port = natPMPPort
+	}
+	conn, err := net.DialUDP("udp4", nil, &net.UDPAddr{IP: n.gateway, Port: port})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.SetReadDeadline(time.Now()) })
+	defer stop()
+
+	req := append([]byte{0, op}, body...)
+	buf := make([]byte, 16)
+	timeout := 250 * time.Millisecond
+	for range natPMPTries {
+		if _, err := conn.Write(req); err != nil {
+			return nil, err
+		}
+		conn.SetReadDeadline(time.Now().Add(timeout))
+		for {
+			read, err := conn.Read(buf)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				break // retransmit, waiting twice as long
+			}
+			// Stray packets, such as address change announcements, are
+			// skipped.
+			if read < 4 || buf[0] != 0 || buf[1] != op|0x80 {
+				continue
+			}
+			if result := binary.BigEndian.Uint16(buf[2:]); result != 0 {
+				if msg, ok := natPMPResults[result]; ok {
+					return nil, errors.New(msg)
+				}
+				return nil, fmt.Errorf("result code %d", result)
+			}
+			if read < size {
+				continue
+			}
+			return buf[:read], nil
+		}
+		timeout *= 2
+	}
+	return nil, errors.New("no response from " + n.gateway.String())
+}
@@ -0,0 +1,150 @@
+// Package portmap asks the home router to forward a port to the gateway, with
+// NAT-PMP (RFC 6886) or, when the router doesn't speak it, UPnP IGD, so the
+// gateway can be reached from outside without configuring port forwarding by
+// hand. Mappings are leased, renewed at half their lifetime and deleted when
+// the gateway stops.
+package portmap
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// retryInterval is how long to wait before trying again after the router
+// refused or failed to map the port.
+const retryInterval = time.Minute
+
+// minRenewal bounds how often a mapping is renewed, whatever lease the
+// router grants.
+const minRenewal = 30 * time.Second
+
+// Mapping is a port mapped on the router.
+type Mapping struct {
+	Protocol     string // "NAT-PMP" or "UPnP"
+	ExternalIP   net.IP
+	ExternalPort int
+	InternalPort int
+	Lifetime     time.Duration
+}
+
+// Address returns the external host:port clients outside the LAN use.
+func (m *Mapping) Address() string {
+	return net.JoinHostPort(m.ExternalIP.String(), strconv.Itoa(m.ExternalPort))
+}
+
+// mapper maps TCP ports with one protocol.
+type mapper interface {
+	name() string
+	mapPort(ctx context.Context, internal, external int, lifetime time.Duration) (*Mapping, error)
+	unmapPort(ctx context.Context, m *Mapping) error
+}
+
+// Options configures Run.
+type Options struct {
+	InternalPort int           // the gateway's port
+	ExternalPort int           // the port requested on the router; zero means InternalPort
+	Lifetime     time.Duration // the lease requested; zero means an hour
+
+	// OnChange is called with the external address when the port is mapped
+	// or the address changes, and with "" when the mapping is lost.
+	OnChange func(addr string)
+}
+
+// Run keeps the port mapped until ctx is done, then deletes the mapping.
+func Run(ctx context.Context, opts Options) {
+	var mappers []mapper
+	if gw, err := defaultGateway(); err == nil {
+		mappers = append(mappers, &natPMP{gateway: gw})
+	} else {
+		logger.DebugCF("portmap", "No default gateway for NAT-PMP", map[string]any{"error": err.Error()})
+	}
+	mappers = append(mappers, &upnp{client: http.Client{Timeout: 10 * time.Second}})
+	run(ctx, mappers, opts)
+}
+
+func run(ctx context.Context, mappers []mapper, opts Options) {
+	if opts.ExternalPort == 0 {
+		opts.ExternalPort = opts.InternalPort
+	}
+	if opts.Lifetime <= 0 {
+		opts.Lifetime = time.Hour // a NAT-PMP lifetime of zero deletes the mapping
+	}
+	if opts.OnChange == nil {
+		opts.OnChange = func(string) {}
+	}
+
+	var current *Mapping
+	var m mapper
+	defer func() {
+		if current == nil {
+			return
+		}
+		unmapCtx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		if err := m.unmapPort(unmapCtx, current); err != nil {
+			logger.WarnCF("portmap", "Failed to delete port mapping", map[string]any{"error": err.Error()})
+		}
+	}()
+
+	for {
+		mapping, used, err := mapPort(ctx, mappers, m, opts)
+		wait := retryInterval
+		switch {
+		case err != nil:
+			if ctx.Err() != nil {
+				return
+			}
+			logger.WarnCF("portmap", "Port mapping failed", map[string]any{"error": err.Error()})
+			if current != nil {
+				current, m = nil, nil
+				opts.OnChange("")
+			}
+		default:
+			if current == nil || current.Address() != mapping.Address() {
+				logger.InfoCF("portmap", "Port mapped", map[string]any{
+					"protocol": mapping.Protocol,
+					"external": mapping.Address(),
+					"lifetime": mapping.Lifetime.String(),
+				})
+				opts.OnChange(mapping.Address())
+			}
+			current, m = mapping, used
+			wait = max(mapping.Lifetime/2, minRenewal)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// mapPort maps the port with prefer, the mapper that last succeeded, if it
+// still can, and otherwise with the first of mappers that does.
+func mapPort(ctx context.Context, mappers []mapper, prefer mapper, opts Options) (*Mapping, mapper, error) {
+	var errs []error
+	if prefer != nil {
+		mapping, err := prefer.mapPort(ctx, opts.InternalPort, opts.ExternalPort, opts.Lifetime)
+		if err == nil {
+			return mapping, prefer, nil
+		}
+		errs = append(errs, errors.New(prefer.name()+": "+err.Error()))
+	}
+	for _, m := range mappers {
+		if m == prefer {
+			continue
+		}
+		mapping, err := m.mapPort(ctx, opts.InternalPort, opts.ExternalPort, opts.Lifetime)
+		if err == nil {
+			return mapping, m, nil
+		}
+		errs = append(errs, errors.New(m.name()+": "+err.Error()))
+	}
+	return nil, nil, errors.Join(errs...)
+}
@@ -0,0 +1,217 @@
+package portmap
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeNATPMP answers NAT-PMP requests on a local port like a router with the
+// external address 203.0.113.7, recording the requests.
+func fakeNATPMP(t *testing.T, result uint16) (*natPMP, func() [][]byte) {
+	t.Helper()
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	var mu sync.Mutex
+	var requests [][]byte
+	go func() {
+		buf := make([]byte, 64)
+		for {
+			n, src, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			req := append([]byte(nil), buf[:n]...)
+			mu.Lock()
+			requests = append(requests, req)
+			mu.Unlock()
+
+			resp := make([]byte, 16)
+			resp[1] = req[1] | 0x80
+			binary.BigEndian.PutUint16(resp[2:], result)
+			switch req[1] {
+			case natPMPOpExternalAddress:
+				copy(resp[8:], net.IPv4(203, 0, 113, 7).To4())
+				resp = resp[:12]
+			case natPMPOpMapTCP:
+				copy(resp[8:12], req[4:8])                   // internal and external ports
+				copy(resp[12:], req[8:12])                   // lifetime
+				binary.BigEndian.PutUint16(resp[10:], 40000) // the port the router chose
+				if binary.BigEndian.Uint32(req[8:]) == 0 {   // a deletion
+					binary.BigEndian.PutUint16(resp[10:], 0)
+				}
+			}
+			conn.WriteToUDP(resp, src)
+		}
+	}()
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+	return &natPMP{gateway: net.IPv4(127, 0, 0, 1), port: port}, func() [][]byte {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(requests)
+	}
+}
+
+func TestNATPMP(t *testing.T) {
+	n, requests := fakeNATPMP(t, 0)
+	m, err := n.mapPort(context.Background(), 18790, 18790, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, "NAT-PMP", m.Protocol)
+	assert.Equal(t, "203.0.113.7:40000", m.Address())
+	assert.Equal(t, 18790, m.InternalPort)
+	assert.Equal(t, time.Hour, m.Lifetime)
+
+	require.NoError(t, n.unmapPort(context.Background(), m))
+	require.Len(t, requests(), 3)
+	deletion := requests()[2]
+	assert.Equal(t, uint16(18790), binary.BigEndian.Uint16(deletion[4:]))
+	assert.Zero(t, binary.BigEndian.Uint32(deletion[8:]), "deleted with a lifetime of zero")
+}
+
+func TestNATPMPRefused(t *testing.T) {
+	n, _ := fakeNATPMP(t, 2)
+	_, err := n.mapPort(context.Background(), 18790, 18790, time.Hour)
+	assert.EqualError(t, err, "not authorized or refused")
+}
+
+// fakeIGD serves a UPnP Internet Gateway Device whose connection service
+// only accepts permanent leases, recording the SOAP actions called.
+func fakeIGD(t *testing.T) (*upnp, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var actions []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /desc.xml", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `<?xml version="1.0"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0">
+  <device>
+    <serviceList><service><serviceType>urn:schemas-upnp-org:service:Layer3Forwarding:1</serviceType></service></serviceList>
+    <deviceList><device><deviceList><device>
+      <serviceList><service>
+        <serviceType>urn:schemas-upnp-org:service:WANIPConnection:1</serviceType>
+        <controlURL>/ctl/IPConn</controlURL>
+      </service></serviceList>
+    </device></deviceList></device></deviceList>
+  </device>
+</root>`)
+	})
+	mux.HandleFunc("POST /ctl/IPConn", func(w http.ResponseWriter, r *http.Request) {
+		action := r.Header.Get("SOAPAction")
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		actions = append(actions, action)
+		mu.Unlock()
+		switch {
+		case strings.HasSuffix(action, `#AddPortMapping"`) && xmlValue(body, "NewLeaseDuration") != "0":
+			w.WriteHeader(http.StatusInternalServerError)
+			io.WriteString(w, `<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body><s:Fault>
+<detail><UPnPError xmlns="urn:schemas-upnp-org:control-1-0"><errorCode>725</errorCode>
+<errorDescription>OnlyPermanentLeasesSupported</errorDescription></UPnPError></detail></s:Fault></s:Body></s:Envelope>`)
+		case strings.HasSuffix(action, `#GetExternalIPAddress"`):
+			io.WriteString(w, `<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body>
+<u:GetExternalIPAddressResponse xmlns:u="urn:schemas-upnp-org:service:WANIPConnection:1">
+<NewExternalIPAddress>198.51.100.4</NewExternalIPAddress></u:GetExternalIPAddressResponse></s:Body></s:Envelope>`)
+		default:
+			io.WriteString(w, `<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body/></s:Envelope>`)
+		}
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return &upnp{location: srv.URL + "/desc.xml"}, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(actions)
+	}
+}
+
+func TestUPnP(t *testing.T) {
+	u, actions := fakeIGD(t)
+	m, err := u.mapPort(context.Background(), 18790, 443, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, "UPnP", m.Protocol)
+	assert.Equal(t, "198.51.100.4:443", m.Address())
+	assert.Equal(t, time.Hour, m.Lifetime, "renewed as requested, though the lease is permanent")
+
+	require.NoError(t, u.unmapPort(context.Background(), m))
+	prefix := `"urn:schemas-upnp-org:service:WANIPConnection:1#`
+	assert.Equal(t, []string{
+		prefix + `AddPortMapping"`,
+		prefix + `AddPortMapping"`,
+		prefix + `GetExternalIPAddress"`,
+		prefix + `DeletePortMapping"`,
+	}, actions())
+}
+
+// fakeMapper fails while failing is set.
+type fakeMapper struct {
+	mu       sync.Mutex
+	failing  bool
+	ip       net.IP
+	unmapped bool
+}
+
+func (f *fakeMapper) name() string { return "fake" }
+
+func (f *fakeMapper) mapPort(ctx context.Context, internal, external int, lifetime time.Duration) (*Mapping, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failing {
+		return nil, errors.New("refused")
+	}
+	return &Mapping{
+		Protocol:     "fake",
+		ExternalIP:   f.ip,
+		ExternalPort: external,
+		InternalPort: internal,
+		Lifetime:     lifetime,
+	}, nil
+}
+
+func (f *fakeMapper) unmapPort(ctx context.Context, m *Mapping) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.unmapped = true
+	return nil
+}
+
+func TestRun(t *testing.T) {
+	failing := &fakeMapper{failing: true}
+	working := &fakeMapper{ip: net.IPv4(198, 51, 100, 4)}
+	changes := make(chan string, 4)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		run(ctx, []mapper{failing, working}, Options{
+			InternalPort: 18790,
+			Lifetime:     time.Hour,
+			OnChange:     func(addr string) { changes <- addr },
+		})
+		close(done)
+	}()
+
+	select {
+	case addr := <-changes:
+		assert.Equal(t, "198.51.100.4:18790", addr, "the external port defaults to the internal one")
+	case <-time.After(time.Second):
+		t.Fatal("the port was not mapped")
+	}
+	cancel()
+	<-done
+	assert.True(t, working.unmapped, "the mapping is deleted on stop")
+	assert.False(t, failing.unmapped)
+}
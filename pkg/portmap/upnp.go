@@ -0,0 +1,312 @@
+package portmap
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ssdpTimeout = 3 * time.Second
+
+	// errOnlyPermanentLeases is the UPnP error of routers that refuse leases
+	// other than zero, which never expire.
+	errOnlyPermanentLeases = "725"
+
+	mappingDescription = "picoclaw"
+)
+
+var ssdpGroup = &net.UDPAddr{IP: net.IPv4(239, 255, 255, 250), Port: 1900}
+
+// upnp maps ports with the WANIPConnection or WANPPPConnection service of a
+// UPnP Internet Gateway Device.
+type upnp struct {
+	location string // the device's description URL; found with SSDP when empty
+	client   http.Client
+
+	controlURL  string // found from the description on first use
+	serviceType string
+}
+
+func (u *upnp) name() string { return "UPnP" }
+
+func (u *upnp) mapPort(ctx context.Context, internal, external int, lifetime time.Duration) (*Mapping, error) {
+	if err := u.discover(ctx); err != nil {
+		return nil, err
+	}
+	localIP, err := u.localIP()
+	if err != nil {
+		return nil, err
+	}
+	add := func(lease time.Duration) error {
+		_, err := u.call(ctx, "AddPortMapping", [][2]string{
+			{"NewRemoteHost", ""},
+			{"NewExternalPort", strconv.Itoa(external)},
+			{"NewProtocol", "TCP"},
+			{"NewInternalPort", strconv.Itoa(internal)},
+			{"NewInternalClient", localIP},
+			{"NewEnabled", "1"},
+			{"NewPortMappingDescription", mappingDescription},
+			{"NewLeaseDuration", strconv.Itoa(int(lease / time.Second))},
+		})
+		return err
+	}
+	err = add(lifetime)
+	var soapErr *upnpError
+	if errors.As(err, &soapErr) && soapErr.code == errOnlyPermanentLeases {
+		// The mapping is still renewed, so it is deleted when the gateway
+		// stops as usual.
+		err = add(0)
+	}
+	if err != nil {
+		u.controlURL = "" // found again next time, in case the router changed
+		return nil, err
+	}
+
+	resp, err := u.call(ctx, "GetExternalIPAddress", nil)
+	if err != nil {
+		return nil, err
+	}
+	ip := net.ParseIP(xmlValue(resp, "NewExternalIPAddress"))
+	if ip == nil {
+		return nil, errors.New("router reported no external address")
+	}
+	return &Mapping{
+		Protocol:     u.name(),
+		ExternalIP:   ip,
+		ExternalPort: external,
+		InternalPort: internal,
+		Lifetime:     lifetime,
+	}, nil
+}
+
+func (u *upnp) unmapPort(ctx context.Context, m *Mapping) error {
+	_, err := u.call(ctx, "DeletePortMapping", [][2]string{
+		{"NewRemoteHost", ""},
+		{"NewExternalPort", strconv.Itoa(m.ExternalPort)},
+		{"NewProtocol", "TCP"},
+	})
+	return err
+}
+
+// discover finds the connection service's control URL.
+func (u *upnp) discover(ctx context.Context) error {
+	if u.controlURL != "" {
+		return nil
+	}
+	location := u.location
+	if location == "" {
+		var err error
+		if location, err = ssdpSearch(ctx); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("device description: %s", resp.Status)
+	}
+	var desc upnpDescription
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&desc); err != nil {
+		return fmt.Errorf("device description: %w", err)
+	}
+	svc := desc.Device.connectionService()
+	if svc == nil {
+		return errors.New("the router has no WAN connection service")
+	}
+	base := location
+	if desc.URLBase != "" {
+		base = desc.URLBase
+	}
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return err
+	}
+	control, err := baseURL.Parse(svc.ControlURL)
+	if err != nil {
+		return err
+	}
+	u.controlURL, u.serviceType = control.String(), svc.ServiceType
+	return nil
+}
+
+type upnpDescription struct {
+	URLBase string     `xml:"URLBase"`
+	Device  upnpDevice `xml:"device"`
+}
+
+type upnpDevice struct {
+	Services []upnpService `xml:"serviceList>service"`
+	Devices  []upnpDevice  `xml:"deviceList>device"`
+}
+
+type upnpService struct {
+	ServiceType string `xml:"serviceType"`
+	ControlURL  string `xml:"controlURL"`
+}
+
+// connectionService returns the first WAN connection service of the device
+// or its embedded devices.
+func (d *upnpDevice) connectionService() *upnpService {
+	for i, svc := range d.Services {
+		if strings.HasPrefix(svc.ServiceType, "urn:schemas-upnp-org:service:WANIPConnection:") ||
+			strings.HasPrefix(svc.ServiceType, "urn:schemas-upnp-org:service:WANPPPConnection:") {
+			return &d.Services[i]
+		}
+	}
+	for i := range d.Devices {
+		if svc := d.Devices[i].connectionService(); svc != nil {
+			return svc
+		}
+	}
+	return nil
+}
+
+// upnpError is a SOAP fault, such as 718 when the port is mapped to another
+// host.
+type upnpError struct {
+	code string
+	desc string
+}
+
+func (e *upnpError) Error() string {
+	return "UPnP error " + e.code + ": " + e.desc
+}
+
+// call invokes action on the connection service and returns the response.
+func (u *upnp) call(ctx context.Context, action string, args [][2]string) ([]byte, error) {
+	var body bytes.Buffer
+	body.WriteString(`<?xml version="1.0"?>` +
+		`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" ` +
+		`s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body>`)
+	fmt.Fprintf(&body, `<u:%s xmlns:u="%s">`, action, u.serviceType)
+	for _, arg := range args {
+		body.WriteString("<" + arg[0] + ">")
+		xml.EscapeText(&body, []byte(arg[1]))
+		body.WriteString("</" + arg[0] + ">")
+	}
+	fmt.Fprintf(&body, `</u:%s></s:Body></s:Envelope>`, action)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.controlURL, &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	req.Header.Set("SOAPAction", `"`+u.serviceType+"#"+action+`"`)
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		if code := xmlValue(data, "errorCode"); code != "" {
+			return nil, &upnpError{code: code, desc: xmlValue(data, "errorDescription")}
+		}
+		return nil, fmt.Errorf("%s: %s", action, resp.Status)
+	}
+	return data, nil
+}
+
+// localIP returns the address the router reaches this host at.
+func (u *upnp) localIP() (string, error) {
+	control, err := url.Parse(u.controlURL)
+	if err != nil {
+		return "", err
+	}
+	port := control.Port()
+	if port == "" {
+		port = "80"
+	}
+	conn, err := net.Dial("udp4", net.JoinHostPort(control.Hostname(), port))
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP.String(), nil
+}
+
+// xmlValue returns the text of the first element named name in data.
+func xmlValue(data []byte, name string) string {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return ""
+		}
+		if start, ok := tok.(xml.StartElement); ok && start.Name.Local == name {
+			var value string
+			if dec.DecodeElement(&value, &start) != nil {
+				return ""
+			}
+			return strings.TrimSpace(value)
+		}
+	}
+}
+
+// ssdpSearch finds an Internet Gateway Device on the LAN and returns the URL
+// of its description.
+func ssdpSearch(ctx context.Context) (string, error) {
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.SetReadDeadline(time.Now()) })
+	defer stop()
+
+	for _, st := range []string{
+		"urn:schemas-upnp-org:device:InternetGatewayDevice:1",
+		"urn:schemas-upnp-org:device:InternetGatewayDevice:2",
+	} {
+		msg := "M-SEARCH * HTTP/1.1\r\n" +
+			"HOST: 239.255.255.250:1900\r\n" +
+			"ST: " + st + "\r\n" +
+			"MAN: \"ssdp:discover\"\r\n" +
+			"MX: 2\r\n\r\n"
+		if _, err := conn.WriteToUDP([]byte(msg), ssdpGroup); err != nil {
+			return "", err
+		}
+	}
+
+	conn.SetReadDeadline(time.Now().Add(ssdpTimeout))
+	buf := make([]byte, 2048)
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return "", ctx.Err()
+			}
+			return "", errors.New("no UPnP gateway found")
+		}
+		resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(buf[:n])), nil)
+		if err != nil {
+			continue
+		}
+		resp.Body.Close()
+		if location := resp.Header.Get("Location"); location != "" {
+			return location, nil
+		}
+	}
+}
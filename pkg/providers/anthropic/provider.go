@@ -4,12 +4,14 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"strings"
 
 	"github.com/anthropics/anthropic-sdk-go"
 	"github.com/anthropics/anthropic-sdk-go/option"
 
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/httpclient"
+	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/providers/protocoltypes"
 )
 
@@ -40,6 +42,7 @@ func NewProviderWithBaseURL(token, apiBase string) *Provider {
 	client := anthropic.NewClient(
 		option.WithAuthToken(token),
 		option.WithBaseURL(baseURL),
+		option.WithHTTPClient(httpclient.New(0)),
 	)
 	return &Provider{
 		client:  &client,
@@ -79,6 +82,9 @@ func (p *Provider) Chat(
 		}
 		opts = append(opts, option.WithAuthToken(tok))
 	}
+	if requestID, ok := ctx.Value(constants.ContextKeyRequestID).(string); ok && requestID != "" {
+		opts = append(opts, option.WithHeader(constants.RequestIDHeader, requestID))
+	}
 
 	params, err := buildParams(messages, tools, model, options)
 	if err != nil {
@@ -211,7 +217,8 @@ func parseResponse(resp *anthropic.Message) *LLMResponse {
 			tu := block.AsToolUse()
 			var args map[string]any
 			if err := json.Unmarshal(tu.Input, &args); err != nil {
-				log.Printf("anthropic: failed to decode tool call input for %q: %v", tu.Name, err)
+				logger.WarnCF("anthropic", "Failed to decode tool call input",
+					map[string]any{"tool": tu.Name, "error": err.Error()})
 				args = map[string]any{"raw": string(tu.Input)}
 			}
 			toolCalls = append(toolCalls, ToolCall{
@@ -13,6 +13,8 @@ import (
 	"time"
 
 	"github.com/sipeed/picoclaw/pkg/auth"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/httpclient"
 	"github.com/sipeed/picoclaw/pkg/logger"
 )
 
@@ -36,9 +38,7 @@ type AntigravityProvider struct {
 func NewAntigravityProvider() *AntigravityProvider {
 	return &AntigravityProvider{
 		tokenSource: createAntigravityTokenSource(),
-		httpClient: &http.Client{
-			Timeout: 120 * time.Second,
-		},
+		httpClient:  httpclient.New(120 * time.Second),
 	}
 }
 
@@ -108,6 +108,9 @@ func (p *AntigravityProvider) Chat(
 	req.Header.Set("User-Agent", fmt.Sprintf("antigravity/%s linux/amd64", antigravityVersion))
 	req.Header.Set("X-Goog-Api-Client", antigravityXGoogClient)
 	req.Header.Set("Client-Metadata", string(clientMetadata))
+	if requestID, ok := ctx.Value(constants.ContextKeyRequestID).(string); ok && requestID != "" {
+		req.Header.Set(constants.RequestIDHeader, requestID)
+	}
 
 	resp, err := p.httpClient.Do(req)
 	if err != nil {
@@ -691,7 +694,7 @@ func FetchAntigravityProjectID(accessToken string) (string, error) {
 	req.Header.Set("User-Agent", antigravityUserAgent)
 	req.Header.Set("X-Goog-Api-Client", antigravityXGoogClient)
 
-	client := &http.Client{Timeout: 15 * time.Second}
+	client := httpclient.New(15 * time.Second)
 	resp, err := client.Do(req)
 	if err != nil {
 		return "", err
@@ -732,7 +735,7 @@ func FetchAntigravityModels(accessToken, projectID string) ([]AntigravityModelIn
 	req.Header.Set("User-Agent", antigravityUserAgent)
 	req.Header.Set("X-Goog-Api-Client", antigravityXGoogClient)
 
-	client := &http.Client{Timeout: 15 * time.Second}
+	client := httpclient.New(15 * time.Second)
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,159 @@
+package providers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/telemetry"
+)
+
+// promptTimestamp matches the current time the agent writes into its system
+// prompt, e.g. "2026-03-02 15:04 (Monday)".
+var promptTimestamp = regexp.MustCompile(`\d{4}-\d{2}-\d{2} \d{2}:\d{2}( \(\w+\))?`)
+
+// CachingProvider replays the provider's answer to a request identical to one
+// seen within the TTL instead of calling the model again. Requests are
+// compared by model, options, tool definitions and the normalized messages:
+// whitespace is collapsed and timestamps in system messages are ignored, so
+// the same question asked on another day still matches. Answers given for
+// one business are only replayed to the same business.
+//
+// Only final answers are cached. Responses that call tools always go to the
+// model, so tools run with arguments for the current request and the answer
+// is cached against their fresh results.
+type CachingProvider struct {
+	LLMProvider
+	ttl        time.Duration
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	nowFunc func() time.Time // for testing
+}
+
+type cacheEntry struct {
+	response LLMResponse
+	expires  time.Time
+}
+
+// NewCachingProvider wraps p with a cache of at most maxEntries responses,
+// each kept for ttl.
+func NewCachingProvider(p LLMProvider, ttl time.Duration, maxEntries int) *CachingProvider {
+	return &CachingProvider{
+		LLMProvider: p,
+		ttl:         ttl,
+		maxEntries:  max(maxEntries, 1),
+		entries:     make(map[string]cacheEntry),
+		nowFunc:     time.Now,
+	}
+}
+
+// CountTokens uses the wrapped provider's counter, which embedding the
+// interface would hide.
+func (c *CachingProvider) CountTokens(messages []Message, tools []ToolDefinition, model string) int {
+	return CountTokens(c.LLMProvider, messages, tools, model)
+}
+
+// Embed uses the wrapped provider's embeddings, uncached.
+func (c *CachingProvider) Embed(ctx context.Context, texts []string, model string) ([][]float32, error) {
+	return Embed(ctx, c.LLMProvider, texts, model)
+}
+
+func (c *CachingProvider) Chat(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (*LLMResponse, error) {
+	key, err := CacheKey(model, messages, tools, options)
+	if err != nil {
+		return c.LLMProvider.Chat(ctx, messages, tools, model, options)
+	}
+	// Never replay one business's answer to another
+	if businessID, _ := ctx.Value(constants.ContextKeyBusinessID).(string); businessID != "" {
+		key = businessID + ":" + key
+	}
+
+	if resp, ok := c.get(key); ok {
+		telemetry.AddCounter("picoclaw.llm.cache", 1, telemetry.String("llm.model", model), telemetry.String("result", "hit"))
+		return resp, nil
+	}
+	telemetry.AddCounter("picoclaw.llm.cache", 1, telemetry.String("llm.model", model), telemetry.String("result", "miss"))
+
+	resp, err := c.LLMProvider.Chat(ctx, messages, tools, model, options)
+	if err != nil || resp == nil || len(resp.ToolCalls) > 0 {
+		return resp, err
+	}
+	c.put(key, *resp)
+	return resp, nil
+}
+
+// get returns a copy of the cached response without usage, since replaying
+// it spends no tokens.
+func (c *CachingProvider) get(key string) (*LLMResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !c.nowFunc().Before(e.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	resp := e.response
+	resp.Usage = nil
+	return &resp, true
+}
+
+func (c *CachingProvider) put(key string, resp LLMResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.nowFunc()
+	if len(c.entries) >= c.maxEntries {
+		// Drop expired entries, then the one closest to expiring.
+		var oldest string
+		for k, e := range c.entries {
+			if !now.Before(e.expires) {
+				delete(c.entries, k)
+			} else if oldest == "" || e.expires.Before(c.entries[oldest].expires) {
+				oldest = k
+			}
+		}
+		if len(c.entries) >= c.maxEntries {
+			delete(c.entries, oldest)
+		}
+	}
+	c.entries[key] = cacheEntry{response: resp, expires: now.Add(c.ttl)}
+}
+
+// CacheKey hashes a chat request after normalizing its messages.
+func CacheKey(model string, messages []Message, tools []ToolDefinition, options map[string]any) (string, error) {
+	normalized := make([]Message, len(messages))
+	for i, m := range messages {
+		if m.Role == "system" {
+			m.Content = promptTimestamp.ReplaceAllString(m.Content, "")
+		}
+		m.Content = strings.Join(strings.Fields(m.Content), " ")
+		normalized[i] = m
+	}
+	data, err := json.Marshal(struct {
+		Model    string           `json:"model"`
+		Messages []Message        `json:"messages"`
+		Tools    []ToolDefinition `json:"tools"`
+		Options  map[string]any   `json:"options"`
+	}{model, normalized, tools, options})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
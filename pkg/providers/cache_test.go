@@ -0,0 +1,147 @@
+package providers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/constants"
+)
+
+type countingProvider struct {
+	calls    int
+	response LLMResponse
+}
+
+func (p *countingProvider) Chat(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (*LLMResponse, error) {
+	p.calls++
+	resp := p.response
+	return &resp, nil
+}
+
+func (p *countingProvider) GetDefaultModel() string { return "test-model" }
+
+func newTestCache(p LLMProvider, now time.Time) (*CachingProvider, *time.Time) {
+	current := now
+	c := NewCachingProvider(p, time.Hour, 2)
+	c.nowFunc = func() time.Time { return current }
+	return c, &current
+}
+
+func promptAt(ts, question string) []Message {
+	return []Message{
+		{Role: "system", Content: "## Current Time\n" + ts + "\n\nYou are helpful."},
+		{Role: "user", Content: question},
+	}
+}
+
+func TestCachingProvider_ReplaysIdenticalPrompt(t *testing.T) {
+	inner := &countingProvider{response: LLMResponse{
+		Content: "Office, Travel",
+		Usage:   &UsageInfo{PromptTokens: 100, CompletionTokens: 5},
+	}}
+	now := time.Now()
+	c, current := newTestCache(inner, now)
+	ctx := context.Background()
+
+	if _, err := c.Chat(ctx, promptAt("2026-03-02 09:00 (Monday)", "list my expense categories"), nil, "m", nil); err != nil {
+		t.Fatal(err)
+	}
+	// A different time and extra whitespace still match.
+	*current = now.Add(30 * time.Minute)
+	resp, err := c.Chat(ctx, promptAt("2026-03-02 09:30 (Monday)", "list my   expense categories "), nil, "m", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inner.calls != 1 {
+		t.Fatalf("expected 1 provider call, got %d", inner.calls)
+	}
+	if resp.Content != "Office, Travel" || resp.Usage != nil {
+		t.Errorf("unexpected cached response: %+v", resp)
+	}
+
+	// A different model or question misses.
+	c.Chat(ctx, promptAt("2026-03-02 09:30 (Monday)", "list my expense categories"), nil, "other", nil)
+	c.Chat(ctx, promptAt("2026-03-02 09:30 (Monday)", "list my vendors"), nil, "m", nil)
+	if inner.calls != 3 {
+		t.Errorf("expected 3 provider calls, got %d", inner.calls)
+	}
+}
+
+func TestCachingProvider_SeparatesBusinesses(t *testing.T) {
+	inner := &countingProvider{response: LLMResponse{Content: "Revenue is $5,000"}}
+	c, _ := newTestCache(inner, time.Now())
+	question := promptAt("2026-03-02 09:00 (Monday)", "what is my revenue?")
+
+	bizA := context.WithValue(context.Background(), constants.ContextKeyBusinessID, "biz-a")
+	bizB := context.WithValue(context.Background(), constants.ContextKeyBusinessID, "biz-b")
+	c.Chat(bizA, question, nil, "m", nil)
+	c.Chat(bizB, question, nil, "m", nil)
+	if inner.calls != 2 {
+		t.Fatalf("business B got business A's cached answer: %d provider calls", inner.calls)
+	}
+	c.Chat(bizA, question, nil, "m", nil)
+	if inner.calls != 2 {
+		t.Errorf("expected business A's answer to be replayed to it, got %d provider calls", inner.calls)
+	}
+}
+
+func TestCachingProvider_Expires(t *testing.T) {
+	inner := &countingProvider{response: LLMResponse{Content: "ok"}}
+	now := time.Now()
+	c, current := newTestCache(inner, now)
+	messages := promptAt("2026-03-02 09:00 (Monday)", "hi")
+
+	c.Chat(context.Background(), messages, nil, "m", nil)
+	*current = now.Add(time.Hour)
+	c.Chat(context.Background(), messages, nil, "m", nil)
+	if inner.calls != 2 {
+		t.Errorf("expected expired entry to miss, got %d calls", inner.calls)
+	}
+}
+
+func TestCachingProvider_SkipsToolCalls(t *testing.T) {
+	inner := &countingProvider{response: LLMResponse{
+		ToolCalls: []ToolCall{{ID: "1", Name: "list_categories"}},
+	}}
+	c, _ := newTestCache(inner, time.Now())
+	messages := promptAt("2026-03-02 09:00 (Monday)", "list my expense categories")
+
+	c.Chat(context.Background(), messages, nil, "m", nil)
+	c.Chat(context.Background(), messages, nil, "m", nil)
+	if inner.calls != 2 {
+		t.Errorf("tool call responses must not be cached, got %d calls", inner.calls)
+	}
+}
+
+func TestCachingProvider_TimestampsInUserMessagesMatter(t *testing.T) {
+	a, _ := CacheKey("m", []Message{{Role: "user", Content: "sales on 2026-03-01 10:00"}}, nil, nil)
+	b, _ := CacheKey("m", []Message{{Role: "user", Content: "sales on 2026-03-02 10:00"}}, nil, nil)
+	if a == b {
+		t.Error("user timestamps must be part of the key")
+	}
+}
+
+func TestCachingProvider_EvictsWhenFull(t *testing.T) {
+	inner := &countingProvider{response: LLMResponse{Content: "ok"}}
+	now := time.Now()
+	c, current := newTestCache(inner, now)
+
+	for i, q := range []string{"a", "b", "c"} {
+		*current = now.Add(time.Duration(i) * time.Minute)
+		c.Chat(context.Background(), promptAt("", q), nil, "m", nil)
+	}
+	if len(c.entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(c.entries))
+	}
+	c.Chat(context.Background(), promptAt("", "a"), nil, "m", nil)
+	if inner.calls != 4 {
+		t.Errorf("expected the oldest entry to be evicted, got %d calls", inner.calls)
+	}
+}
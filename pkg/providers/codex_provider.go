@@ -12,6 +12,8 @@ import (
 	"github.com/openai/openai-go/v3/responses"
 
 	"github.com/sipeed/picoclaw/pkg/auth"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/httpclient"
 	"github.com/sipeed/picoclaw/pkg/logger"
 )
 
@@ -35,6 +37,7 @@ func NewCodexProvider(token, accountID string) *CodexProvider {
 		option.WithAPIKey(token),
 		option.WithHeader("originator", "codex_cli_rs"),
 		option.WithHeader("OpenAI-Beta", "responses=experimental"),
+		option.WithHTTPClient(httpclient.New(0)),
 	}
 	if accountID != "" {
 		opts = append(opts, option.WithHeader("Chatgpt-Account-Id", accountID))
@@ -95,6 +98,10 @@ func (p *CodexProvider) Chat(
 		)
 	}
 
+	if requestID, ok := ctx.Value(constants.ContextKeyRequestID).(string); ok && requestID != "" {
+		opts = append(opts, option.WithHeader(constants.RequestIDHeader, requestID))
+	}
+
 	params := buildCodexParams(messages, tools, resolvedModel, options, p.enableWebSearch)
 
 	stream := p.client.Responses.NewStreaming(ctx, params, opts...)
@@ -0,0 +1,25 @@
+package providers
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrEmbeddingsUnsupported is returned by Embed for providers that can't
+// embed text.
+var ErrEmbeddingsUnsupported = errors.New("provider does not support embeddings")
+
+// Embedder is implemented by providers that turn text into embedding
+// vectors, such as the OpenAI-compatible HTTP providers.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string, model string) ([][]float32, error)
+}
+
+// Embed returns the embedding of each text from p, or
+// ErrEmbeddingsUnsupported if p does not implement Embedder.
+func Embed(ctx context.Context, p LLMProvider, texts []string, model string) ([][]float32, error) {
+	if e, ok := p.(Embedder); ok {
+		return e.Embed(ctx, texts, model)
+	}
+	return nil, ErrEmbeddingsUnsupported
+}
@@ -53,7 +53,8 @@ func ExtractProtocol(model string) (protocol, modelID string) {
 
 // CreateProviderFromConfig creates a provider based on the ModelConfig.
 // It uses the protocol prefix in the Model field to determine which provider to create.
-// Supported protocols: openai, anthropic, antigravity, claude-cli, codex-cli, github-copilot
+// Supported protocols: openai, anthropic, antigravity, claude-cli, codex-cli, github-copilot,
+// and the OpenAI-compatible vendors including local ollama and llamacpp servers.
 // Returns the provider, the model ID (without protocol prefix), and any error.
 func CreateProviderFromConfig(cfg *config.ModelConfig) (LLMProvider, string, error) {
 	if cfg == nil {
@@ -88,9 +89,10 @@ func CreateProviderFromConfig(cfg *config.ModelConfig) (LLMProvider, string, err
 
 	case "openrouter", "groq", "zhipu", "gemini", "nvidia",
 		"ollama", "moonshot", "shengsuanyun", "deepseek", "cerebras",
-		"volcengine", "vllm", "qwen":
-		// All other OpenAI-compatible HTTP providers
-		if cfg.APIKey == "" && cfg.APIBase == "" {
+		"volcengine", "vllm", "qwen", "llamacpp":
+		// All other OpenAI-compatible HTTP providers. Servers on this device
+		// need no key.
+		if cfg.APIKey == "" && cfg.APIBase == "" && !IsLocalProtocol(protocol) {
 			return nil, "", fmt.Errorf("api_key or api_base is required for HTTP-based protocol %q", protocol)
 		}
 		apiBase := cfg.APIBase
@@ -155,6 +157,16 @@ func CreateProviderFromConfig(cfg *config.ModelConfig) (LLMProvider, string, err
 	}
 }
 
+// IsLocalProtocol reports whether protocol's server runs on this device by
+// default: Ollama, llama.cpp's llama-server or vLLM.
+func IsLocalProtocol(protocol string) bool {
+	switch protocol {
+	case "ollama", "llamacpp", "vllm":
+		return true
+	}
+	return false
+}
+
 // getDefaultAPIBase returns the default API base URL for a given protocol.
 func getDefaultAPIBase(protocol string) string {
 	switch protocol {
@@ -186,6 +198,8 @@ func getDefaultAPIBase(protocol string) string {
 		return "https://dashscope.aliyuncs.com/compatible-mode/v1"
 	case "vllm":
 		return "http://localhost:8000/v1"
+	case "llamacpp":
+		return "http://localhost:8080/v1"
 	default:
 		return ""
 	}
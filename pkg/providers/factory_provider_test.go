@@ -108,6 +108,7 @@ func TestCreateProviderFromConfig_DefaultAPIBase(t *testing.T) {
 		{"vllm", "vllm"},
 		{"deepseek", "deepseek"},
 		{"ollama", "ollama"},
+		{"llamacpp", "llamacpp"},
 	}
 
 	for _, tt := range tests {
@@ -216,6 +217,18 @@ func TestCreateProviderFromConfig_MissingAPIKey(t *testing.T) {
 	}
 }
 
+func TestCreateProviderFromConfig_LocalWithoutAPIKey(t *testing.T) {
+	for _, model := range []string{"ollama/llama3", "llamacpp/qwen2.5-3b"} {
+		provider, _, err := CreateProviderFromConfig(&config.ModelConfig{ModelName: "local", Model: model})
+		if err != nil {
+			t.Fatalf("CreateProviderFromConfig(%s) error = %v", model, err)
+		}
+		if _, ok := provider.(*HTTPProvider); !ok {
+			t.Fatalf("expected *HTTPProvider for %s, got %T", model, provider)
+		}
+	}
+}
+
 func TestCreateProviderFromConfig_UnknownProtocol(t *testing.T) {
 	cfg := &config.ModelConfig{
 		ModelName: "test-unknown",
@@ -38,6 +38,10 @@ func (p *HTTPProvider) Chat(
 	return p.delegate.Chat(ctx, messages, tools, model, options)
 }
 
+func (p *HTTPProvider) Embed(ctx context.Context, texts []string, model string) ([][]float32, error) {
+	return p.delegate.Embed(ctx, texts, model)
+}
+
 func (p *HTTPProvider) GetDefaultModel() string {
 	return ""
 }
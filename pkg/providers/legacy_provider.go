@@ -16,8 +16,12 @@ import (
 // The old providers config is automatically converted to model_list during config loading.
 // Returns the provider, the model ID to use, and any error.
 func CreateProvider(cfg *config.Config) (LLMProvider, string, error) {
-	model := cfg.Agents.Defaults.Model
+	return CreateProviderForModel(cfg, cfg.Agents.Defaults.Model)
+}
 
+// CreateProviderForModel creates the provider of model, a model_name in
+// model_list, and returns it with the model ID to use.
+func CreateProviderForModel(cfg *config.Config, model string) (LLMProvider, string, error) {
 	// Ensure model_list is populated (should be done by LoadConfig, but handle edge cases)
 	if len(cfg.ModelList) == 0 && cfg.HasProvidersConfig() {
 		cfg.ModelList = config.ConvertProvidersToModelList(cfg)
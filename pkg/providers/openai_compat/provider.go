@@ -6,12 +6,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
-	"net/url"
 	"strings"
 	"time"
 
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/httpclient"
+	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/providers/protocoltypes"
 )
 
@@ -39,19 +40,10 @@ func NewProvider(apiKey, apiBase, proxy string) *Provider {
 }
 
 func NewProviderWithMaxTokensField(apiKey, apiBase, proxy, maxTokensField string) *Provider {
-	client := &http.Client{
-		Timeout: 120 * time.Second,
-	}
-
-	if proxy != "" {
-		parsed, err := url.Parse(proxy)
-		if err == nil {
-			client.Transport = &http.Transport{
-				Proxy: http.ProxyURL(parsed),
-			}
-		} else {
-			log.Printf("openai_compat: invalid proxy URL %q: %v", proxy, err)
-		}
+	client, err := httpclient.NewWithProxy(120*time.Second, proxy)
+	if err != nil {
+		logger.WarnCF("openai_compat", "Invalid proxy URL", map[string]any{"proxy": proxy, "error": err.Error()})
+		client = httpclient.New(120 * time.Second)
 	}
 
 	return &Provider{
@@ -125,6 +117,9 @@ func (p *Provider) Chat(
 	if p.apiKey != "" {
 		req.Header.Set("Authorization", "Bearer "+p.apiKey)
 	}
+	if requestID, ok := ctx.Value(constants.ContextKeyRequestID).(string); ok && requestID != "" {
+		req.Header.Set(constants.RequestIDHeader, requestID)
+	}
 
 	resp, err := p.httpClient.Do(req)
 	if err != nil {
@@ -195,7 +190,8 @@ func parseResponse(body []byte) (*LLMResponse, error) {
 			name = tc.Function.Name
 			if tc.Function.Arguments != "" {
 				if err := json.Unmarshal([]byte(tc.Function.Arguments), &arguments); err != nil {
-					log.Printf("openai_compat: failed to decode tool call arguments for %q: %v", name, err)
+					logger.WarnCF("openai_compat", "Failed to decode tool call arguments",
+						map[string]any{"tool": name, "error": err.Error()})
 					arguments["raw"] = tc.Function.Arguments
 				}
 			}
@@ -0,0 +1,47 @@
+package providers
+
+import (
+	"encoding/json"
+
+	"github.com/sipeed/picoclaw/pkg/tokenizer"
+)
+
+// TokenCounter is implemented by providers that count the prompt tokens of a
+// request for their model without calling their API.
+type TokenCounter interface {
+	CountTokens(messages []Message, tools []ToolDefinition, model string) int
+}
+
+// CountTokens returns the prompt tokens of a request to p: p's own count if
+// it implements TokenCounter, and a local estimate otherwise. It never makes
+// a request.
+func CountTokens(p LLMProvider, messages []Message, tools []ToolDefinition, model string) int {
+	if counter, ok := p.(TokenCounter); ok {
+		return counter.CountTokens(messages, tools, model)
+	}
+	return EstimateTokens(messages, tools)
+}
+
+// EstimateTokens counts the prompt tokens of messages and tools with the
+// local tokenizer, including the framing chat formats add to each message.
+func EstimateTokens(messages []Message, tools []ToolDefinition) int {
+	n := 3 // the reply is primed with the assistant role
+	for _, m := range messages {
+		n += 4 + tokenizer.Count(m.Content)
+		for _, tc := range m.ToolCalls {
+			name, args := tc.Name, ""
+			if tc.Function != nil {
+				name, args = tc.Function.Name, tc.Function.Arguments
+			} else if len(tc.Arguments) > 0 {
+				data, _ := json.Marshal(tc.Arguments)
+				args = string(data)
+			}
+			n += 3 + tokenizer.Count(name) + tokenizer.Count(args)
+		}
+	}
+	if len(tools) > 0 {
+		data, _ := json.Marshal(tools)
+		n += tokenizer.Count(string(data))
+	}
+	return n
+}
@@ -0,0 +1,60 @@
+package providers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// tokenCountingProvider reports a fixed token count.
+type tokenCountingProvider struct {
+	stubProvider
+}
+
+func (tokenCountingProvider) CountTokens(messages []Message, tools []ToolDefinition, model string) int {
+	return 42
+}
+
+type stubProvider struct{}
+
+func (stubProvider) Chat(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (*LLMResponse, error) {
+	return &LLMResponse{Content: "ok"}, nil
+}
+
+func (stubProvider) GetDefaultModel() string { return "stub" }
+
+func TestCountTokensPrefersProviderCounter(t *testing.T) {
+	messages := []Message{{Role: "user", Content: "How much did I spend on fuel?"}}
+
+	assert.Equal(t, 42, CountTokens(tokenCountingProvider{}, messages, nil, "stub"))
+	assert.Equal(t, 42, CountTokens(NewCachingProvider(tokenCountingProvider{}, time.Minute, 1), messages, nil, "stub"))
+	assert.Equal(t, EstimateTokens(messages, nil), CountTokens(stubProvider{}, messages, nil, "stub"))
+}
+
+func TestEstimateTokensCountsToolsAndCalls(t *testing.T) {
+	base := EstimateTokens([]Message{{Role: "user", Content: "hi"}}, nil)
+	assert.Positive(t, base)
+
+	withCall := EstimateTokens([]Message{
+		{Role: "user", Content: "hi"},
+		{Role: "assistant", ToolCalls: []ToolCall{{
+			ID:       "1",
+			Function: &FunctionCall{Name: "exec", Arguments: `{"command":"ls -la"}`},
+		}}},
+	}, nil)
+	assert.Greater(t, withCall, base+4)
+
+	tools := []ToolDefinition{{
+		Type:     "function",
+		Function: ToolFunctionDefinition{Name: "exec", Description: "Run a shell command"},
+	}}
+	assert.Greater(t, EstimateTokens([]Message{{Role: "user", Content: "hi"}}, tools), base)
+}
@@ -0,0 +1,238 @@
+// Package provision sets up a headless board over a serial line: the board's
+// serial console, a USB gadget port, or the TTY of a Bluetooth LE UART bridge.
+// A phone or laptop sends newline-delimited JSON commands carrying Wi-Fi
+// credentials and a config, so the board needs no network to be set up.
+//
+// Each command is an object with a "cmd" and an optional "id", which the
+// response echoes:
+//
+//	{"id": 1, "cmd": "info"}
+//	{"id": 2, "cmd": "wifi", "ssid": "home", "password": "correct horse"}
+//	{"id": 3, "cmd": "config", "config": {...}}
+//
+// Once the board has a config, a config command must also carry "token", a
+// full-access paired token of the config it replaces.
+//
+// Responses have "ok", and "error" when it is false.
+package provision
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// maxLine bounds a command, which may carry a whole config.
+const maxLine = 1 << 20
+
+// Options configures a Provisioner.
+type Options struct {
+	ConfigPath string // where a received config is written
+	Version    string // reported by info
+	DeviceID   string // reported by info
+	WiFi       WiFi   // joins networks; nil refuses wifi commands
+}
+
+// Provisioner answers provisioning commands.
+type Provisioner struct {
+	opts Options
+
+	mu         sync.Mutex // serializes config writes
+	configured chan struct{}
+	once       sync.Once
+}
+
+// New returns a provisioner for opts.
+func New(opts Options) *Provisioner {
+	return &Provisioner{opts: opts, configured: make(chan struct{})}
+}
+
+// Configured is closed once a config has been written.
+func (p *Provisioner) Configured() <-chan struct{} {
+	return p.configured
+}
+
+type command struct {
+	ID       json.RawMessage `json:"id,omitempty"`
+	Cmd      string          `json:"cmd"`
+	SSID     string          `json:"ssid,omitempty"`
+	Password string          `json:"password,omitempty"`
+	Config   json.RawMessage `json:"config,omitempty"`
+	Token    string          `json:"token,omitempty"` // replaces an existing config
+}
+
+// Info describes the device, for the app to show which board it reached and
+// where to find it on the network.
+type Info struct {
+	Name       string   `json:"name"`
+	Version    string   `json:"version,omitempty"`
+	DeviceID   string   `json:"device_id,omitempty"`
+	Configured bool     `json:"configured"`
+	Addresses  []string `json:"addresses"`
+}
+
+type response struct {
+	ID    json.RawMessage `json:"id,omitempty"`
+	OK    bool            `json:"ok"`
+	Error string          `json:"error,omitempty"`
+	Info  *Info           `json:"info,omitempty"`
+}
+
+// Serve answers the commands read from rw until it ends or ctx is done.
+func (p *Provisioner) Serve(ctx context.Context, rw io.ReadWriter) error {
+	scanner := bufio.NewScanner(rw)
+	scanner.Buffer(make([]byte, 64*1024), maxLine)
+	for scanner.Scan() {
+		if ctx.Err() != nil {
+			return nil
+		}
+		line := scanner.Bytes()
+		if len(line) == 0 || line[0] != '{' {
+			continue // line noise, or a console echoing a prompt
+		}
+		out, _ := json.Marshal(p.handle(ctx, line))
+		if _, err := rw.Write(append(out, '\n')); err != nil {
+			return err
+		}
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	return scanner.Err()
+}
+
+func (p *Provisioner) handle(ctx context.Context, line []byte) *response {
+	var cmd command
+	if err := json.Unmarshal(line, &cmd); err != nil {
+		return &response{Error: "invalid command: " + err.Error()}
+	}
+	resp := &response{ID: cmd.ID}
+	var err error
+	switch cmd.Cmd {
+	case "info":
+		resp.Info = p.info()
+	case "wifi":
+		err = p.joinWiFi(ctx, cmd.SSID, cmd.Password)
+	case "config":
+		err = p.writeConfig(cmd.Config, cmd.Token)
+	default:
+		err = fmt.Errorf("unknown command %q", cmd.Cmd)
+	}
+	if err != nil {
+		resp.Error = err.Error()
+		logger.WarnCF("provision", "Provisioning command failed", map[string]any{
+			"cmd":   cmd.Cmd,
+			"error": err.Error(),
+		})
+		return resp
+	}
+	resp.OK = true
+	return resp
+}
+
+func (p *Provisioner) info() *Info {
+	info := &Info{
+		Name:      "picoclaw",
+		Version:   p.opts.Version,
+		DeviceID:  p.opts.DeviceID,
+		Addresses: []string{},
+	}
+	_, err := os.Stat(p.opts.ConfigPath)
+	info.Configured = err == nil
+	addrs, _ := net.InterfaceAddrs()
+	for _, a := range addrs {
+		if ipNet, ok := a.(*net.IPNet); ok && !ipNet.IP.IsLoopback() && !ipNet.IP.IsLinkLocalUnicast() {
+			info.Addresses = append(info.Addresses, ipNet.IP.String())
+		}
+	}
+	return info
+}
+
+func (p *Provisioner) joinWiFi(ctx context.Context, ssid, password string) error {
+	if p.opts.WiFi == nil {
+		return errors.New("wifi is not supported on this device")
+	}
+	if ssid == "" {
+		return errors.New("ssid is required")
+	}
+	if err := p.opts.WiFi.Join(ctx, ssid, password); err != nil {
+		return err
+	}
+	logger.InfoCF("provision", "Joined Wi-Fi network", map[string]any{"ssid": ssid})
+	return nil
+}
+
+// writeConfig validates raw as the gateway would load it and writes it. An
+// existing config is only replaced for a holder of one of its paired tokens.
+func (p *Provisioner) writeConfig(raw json.RawMessage, token string) error {
+	if len(raw) == 0 {
+		return errors.New("config is required")
+	}
+	cfg := config.DefaultConfig()
+	if err := json.Unmarshal(raw, cfg); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	if len(cfg.ModelList) == 0 && cfg.HasProvidersConfig() {
+		cfg.ModelList = config.ConvertProvidersToModelList(cfg)
+	}
+	if err := cfg.ValidateModelList(); err != nil {
+		return err
+	}
+	if _, _, err := providers.CreateProvider(cfg); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.authorize(token); err != nil {
+		return err
+	}
+	if err := config.SaveConfig(p.opts.ConfigPath, cfg); err != nil {
+		return err
+	}
+	logger.InfoCF("provision", "Config written", map[string]any{"path": p.opts.ConfigPath})
+	p.once.Do(func() { close(p.configured) })
+	return nil
+}
+
+// authorize checks that token may replace the config at ConfigPath: any
+// token while there is none, and otherwise a paired token that isn't
+// observer-scoped.
+func (p *Provisioner) authorize(token string) error {
+	if _, err := os.Stat(p.opts.ConfigPath); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	current, err := config.LoadConfig(p.opts.ConfigPath)
+	if err != nil {
+		return fmt.Errorf("failed to load the current config: %w", err)
+	}
+	if token == "" {
+		return errors.New("picoclaw is already configured; send a paired token to replace its config")
+	}
+	sum := sha256.Sum256([]byte(token))
+	hash := hex.EncodeToString(sum[:])
+	for _, device := range current.Gateway.PairedDevices {
+		if device.TokenHash == hash && device.Scope == "observer" {
+			return errors.New("an observer token can't replace the config")
+		}
+	}
+	for _, paired := range current.Gateway.PairedTokens {
+		if subtle.ConstantTimeCompare([]byte(paired), []byte(hash)) == 1 {
+			return nil
+		}
+	}
+	return errors.New("token is not paired with this device")
+}
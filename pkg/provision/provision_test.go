@@ -0,0 +1,156 @@
+package provision
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+type fakeWiFi struct {
+	joined []string
+}
+
+func (f *fakeWiFi) Join(_ context.Context, ssid, password string) error {
+	if password == "wrong" {
+		return errors.New("secrets were required, but not provided")
+	}
+	f.joined = append(f.joined, ssid)
+	return nil
+}
+
+// conn feeds commands to Serve and collects its responses.
+type conn struct {
+	in  *strings.Reader
+	out bytes.Buffer
+}
+
+func (c *conn) Read(p []byte) (int, error)  { return c.in.Read(p) }
+func (c *conn) Write(p []byte) (int, error) { return c.out.Write(p) }
+
+func serve(t *testing.T, p *Provisioner, lines ...string) []map[string]any {
+	t.Helper()
+	c := &conn{in: strings.NewReader(strings.Join(lines, "\n") + "\n")}
+	require.NoError(t, p.Serve(context.Background(), c))
+	var responses []map[string]any
+	dec := json.NewDecoder(&c.out)
+	for dec.More() {
+		var resp map[string]any
+		require.NoError(t, dec.Decode(&resp))
+		responses = append(responses, resp)
+	}
+	return responses
+}
+
+func TestServe(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	wifi := &fakeWiFi{}
+	p := New(Options{ConfigPath: path, Version: "1.2.3", DeviceID: "dev-1", WiFi: wifi})
+
+	responses := serve(t, p,
+		"login: ",
+		`{"id": 1, "cmd": "info"}`,
+		`{"id": 2, "cmd": "wifi", "ssid": "home", "password": "secret"}`,
+		`{"id": 3, "cmd": "wifi", "ssid": "home", "password": "wrong"}`,
+		`{"id": 4, "cmd": "config", "config": {"agents": {"defaults": {"model": "missing"}}}}`,
+		`{"id": 5, "cmd": "reboot"}`,
+		`{not json`,
+	)
+	require.Len(t, responses, 6)
+
+	info := responses[0]["info"].(map[string]any)
+	assert.Equal(t, float64(1), responses[0]["id"])
+	assert.Equal(t, true, responses[0]["ok"])
+	assert.Equal(t, "dev-1", info["device_id"])
+	assert.Equal(t, false, info["configured"])
+
+	assert.Equal(t, true, responses[1]["ok"])
+	assert.Equal(t, []string{"home"}, wifi.joined)
+	assert.Equal(t, "secrets were required, but not provided", responses[2]["error"])
+
+	assert.Equal(t, false, responses[3]["ok"])
+	assert.Contains(t, responses[3]["error"], `model "missing" not found in model_list`)
+	assert.NoFileExists(t, path)
+
+	assert.Equal(t, `unknown command "reboot"`, responses[4]["error"])
+	assert.Contains(t, responses[5]["error"], "invalid command")
+
+	responses = serve(t, p, `{"id": 6, "cmd": "config", "config": {"agents": {"defaults": {"model": "gpt"}}, `+
+		`"model_list": [{"model_name": "gpt", "model": "openai/gpt-5.2", "api_key": "sk-test"}]}}`)
+	require.Len(t, responses, 1)
+	assert.Equal(t, true, responses[0]["ok"], responses[0]["error"])
+	select {
+	case <-p.Configured():
+	default:
+		t.Fatal("not configured after a config was written")
+	}
+	cfg, err := config.LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, "sk-test", cfg.ModelList[0].APIKey)
+}
+
+func TestServeReplacesConfigOnlyWithPairedToken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	sum := sha256.Sum256([]byte("pc_full"))
+	observer := sha256.Sum256([]byte("pc_observer"))
+	cfg := config.DefaultConfig()
+	cfg.Gateway.PairedTokens = []string{hex.EncodeToString(sum[:]), hex.EncodeToString(observer[:])}
+	cfg.Gateway.PairedDevices = []config.PairedDevice{{TokenHash: hex.EncodeToString(observer[:]), Scope: "observer"}}
+	require.NoError(t, config.SaveConfig(path, cfg))
+
+	newConfig := `"config": {"agents": {"defaults": {"model": "gpt"}}, ` +
+		`"model_list": [{"model_name": "gpt", "model": "openai/gpt-5.2", "api_key": "sk-new"}]}`
+	p := New(Options{ConfigPath: path})
+	responses := serve(t, p,
+		`{"id": 1, "cmd": "config", `+newConfig+`}`,
+		`{"id": 2, "cmd": "config", "token": "pc_wrong", `+newConfig+`}`,
+		`{"id": 3, "cmd": "config", "token": "pc_observer", `+newConfig+`}`,
+	)
+	require.Len(t, responses, 3)
+	assert.Contains(t, responses[0]["error"], "already configured")
+	assert.Equal(t, "token is not paired with this device", responses[1]["error"])
+	assert.Equal(t, "an observer token can't replace the config", responses[2]["error"])
+	current, err := config.LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, cfg.Gateway.PairedTokens, current.Gateway.PairedTokens)
+	assert.NotEqual(t, "sk-new", current.ModelList[0].APIKey)
+
+	responses = serve(t, p, `{"id": 4, "cmd": "config", "token": "pc_full", `+newConfig+`}`)
+	assert.Equal(t, true, responses[0]["ok"], responses[0]["error"])
+	current, err = config.LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, "sk-new", current.ModelList[0].APIKey)
+}
+
+func TestServeWithoutWiFi(t *testing.T) {
+	p := New(Options{ConfigPath: filepath.Join(t.TempDir(), "config.json")})
+	responses := serve(t, p, `{"cmd": "wifi", "ssid": "home"}`)
+	assert.Equal(t, "wifi is not supported on this device", responses[0]["error"])
+}
+
+func TestNetworkBlock(t *testing.T) {
+	key := strings.Repeat("ab", 32)
+	assert.Equal(t, "\nnetwork={\n\tssid=686f6d65\n\tpsk=\"secret12\"\n}\n", networkBlock("home", "secret12"))
+	assert.Equal(t, "\nnetwork={\n\tssid=636166c3a9\n\tkey_mgmt=NONE\n}\n", networkBlock("café", ""))
+	assert.Equal(t, "\nnetwork={\n\tssid=615c22620a\n\tpsk="+key+"\n}\n", networkBlock("a\\\"b\n", key))
+
+	assert.NoError(t, validateCredentials("a\\\"b\n", key))
+	assert.NoError(t, validateCredentials("home", strings.ToUpper(key)))
+	assert.ErrorContains(t, validateCredentials("", ""), "ssid must be 1 to 32 bytes")
+	assert.ErrorContains(t, validateCredentials(strings.Repeat("x", 33), ""), "ssid must be 1 to 32 bytes")
+	assert.ErrorContains(t, validateCredentials("home", "short"), "8 to 63 characters")
+	assert.ErrorContains(t, validateCredentials("home", strings.Repeat("x", 64)), "8 to 63 characters")
+	assert.ErrorContains(t, validateCredentials("home", "pass\"word"), "printable ASCII")
+	assert.ErrorContains(t, validateCredentials("home", "pässword"), "printable ASCII")
+	assert.ErrorContains(t, SystemWiFi{}.Join(context.Background(), "home", "short"), "8 to 63 characters")
+}
@@ -0,0 +1,48 @@
+package provision
+
+import (
+	"context"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// reopenDelay is how long ServeSerial waits before reopening a device that
+// failed or went away, as a USB gadget port does while unplugged.
+const reopenDelay = 5 * time.Second
+
+// ServeSerial answers commands on the serial device at baud until ctx is
+// done, reopening the device when it fails.
+func (p *Provisioner) ServeSerial(ctx context.Context, device string, baud int) {
+	for {
+		port, err := openSerial(device, baud)
+		if err == nil {
+			logger.InfoCF("provision", "Serving provisioning", map[string]any{"device": device, "baud": baud})
+			done := make(chan struct{})
+			go func() {
+				select {
+				case <-ctx.Done():
+					port.Close() // unblocks the read
+				case <-done:
+				}
+			}()
+			err = p.Serve(ctx, port)
+			close(done)
+			port.Close()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			logger.WarnCF("provision", "Provisioning device failed", map[string]any{
+				"device": device,
+				"error":  err.Error(),
+			})
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(reopenDelay):
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package provision
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// cbaud masks the baud rate bits of c_cflag (CBAUD | CBAUDEX), which the
+// syscall package does not define.
+const cbaud = 0o10017
+
+var baudRates = map[int]uint32{
+	9600:   syscall.B9600,
+	19200:  syscall.B19200,
+	38400:  syscall.B38400,
+	57600:  syscall.B57600,
+	115200: syscall.B115200,
+	230400: syscall.B230400,
+	460800: syscall.B460800,
+	921600: syscall.B921600,
+}
+
+// openSerial opens device in raw mode at baud, 8N1. The device is opened
+// non-blocking, so closing it unblocks a pending read.
+func openSerial(device string, baud int) (io.ReadWriteCloser, error) {
+	speed, ok := baudRates[baud]
+	if !ok {
+		return nil, fmt.Errorf("unsupported baud rate %d", baud)
+	}
+	f, err := os.OpenFile(device, os.O_RDWR|syscall.O_NOCTTY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := f.SyscallConn()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	var ioctlErr error
+	err = conn.Control(func(fd uintptr) {
+		var t syscall.Termios
+		if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, syscall.TCGETS,
+			uintptr(unsafe.Pointer(&t))); errno != 0 {
+			ioctlErr = errno
+			return
+		}
+		makeRaw(&t, speed)
+		if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, syscall.TCSETS,
+			uintptr(unsafe.Pointer(&t))); errno != 0 {
+			ioctlErr = errno
+		}
+	})
+	if err == nil {
+		err = ioctlErr
+	}
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s is not a serial device: %w", device, err)
+	}
+	return f, nil
+}
+
+// makeRaw sets t to raw 8N1 at speed, as cfmakeraw does, with reads
+// returning as soon as a byte arrives.
+func makeRaw(t *syscall.Termios, speed uint32) {
+	t.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP |
+		syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	t.Oflag &^= syscall.OPOST
+	t.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	t.Cflag &^= syscall.CSIZE | syscall.PARENB | syscall.CSTOPB | cbaud
+	t.Cflag |= syscall.CS8 | syscall.CREAD | syscall.CLOCAL | speed
+	t.Ispeed = speed
+	t.Ospeed = speed
+	t.Cc[syscall.VMIN] = 1
+	t.Cc[syscall.VTIME] = 0
+}
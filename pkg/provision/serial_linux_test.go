@@ -0,0 +1,64 @@
+package provision
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// openPTY returns the master of a new pseudo-terminal and its slave's path,
+// which stands in for a serial device.
+func openPTY(t *testing.T) (*os.File, string) {
+	t.Helper()
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		t.Skipf("no pseudo-terminals: %v", err)
+	}
+	t.Cleanup(func() { master.Close() })
+	var unlock int32
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, master.Fd(), syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock)))
+	require.Zero(t, errno)
+	var n uint32
+	_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, master.Fd(), syscall.TIOCGPTN, uintptr(unsafe.Pointer(&n)))
+	require.Zero(t, errno)
+	return master, fmt.Sprintf("/dev/pts/%d", n)
+}
+
+func TestServeSerial(t *testing.T) {
+	master, device := openPTY(t)
+	p := New(Options{ConfigPath: filepath.Join(t.TempDir(), "config.json"), DeviceID: "dev-1"})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		p.ServeSerial(ctx, device, 115200)
+		close(done)
+	}()
+
+	_, err := master.WriteString(`{"id": 1, "cmd": "info"}` + "\n")
+	require.NoError(t, err)
+	// The command is echoed if it arrives before the device is made raw.
+	reader := bufio.NewReader(master)
+	line := ""
+	for !strings.HasPrefix(line, `{"id":1`) {
+		line, err = reader.ReadString('\n')
+		require.NoError(t, err)
+	}
+	assert.Contains(t, line, `"device_id":"dev-1"`)
+
+	cancel()
+	<-done
+
+	_, err = openSerial(device, 1234)
+	assert.ErrorContains(t, err, "unsupported baud rate 1234")
+	_, err = openSerial(os.DevNull, 115200)
+	assert.ErrorContains(t, err, "is not a serial device")
+}
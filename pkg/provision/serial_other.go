@@ -0,0 +1,13 @@
+//go:build !linux
+
+package provision
+
+import (
+	"errors"
+	"io"
+)
+
+// openSerial is a stub for non-Linux platforms.
+func openSerial(device string, baud int) (io.ReadWriteCloser, error) {
+	return nil, errors.New("serial provisioning is only supported on Linux")
+}
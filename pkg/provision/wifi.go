@@ -0,0 +1,107 @@
+package provision
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// WiFi joins wireless networks.
+type WiFi interface {
+	// Join connects to the network ssid and keeps it for later boots. An
+	// empty password joins an open network.
+	Join(ctx context.Context, ssid, password string) error
+}
+
+// SystemWiFi joins networks with NetworkManager when nmcli is installed, and
+// otherwise by adding them to the wpa_supplicant config at WPASupplicant,
+// the usual setup of Sipeed boards.
+type SystemWiFi struct {
+	WPASupplicant string
+}
+
+func (w SystemWiFi) Join(ctx context.Context, ssid, password string) error {
+	if err := validateCredentials(ssid, password); err != nil {
+		return err
+	}
+	if _, err := exec.LookPath("nmcli"); err == nil {
+		args := []string{"device", "wifi", "connect", ssid}
+		if password != "" {
+			args = append(args, "password", password)
+		}
+		return run(ctx, "nmcli", args...)
+	}
+
+	if w.WPASupplicant == "" {
+		return errors.New("neither nmcli nor a wpa_supplicant config is available")
+	}
+	f, err := os.OpenFile(w.WPASupplicant, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(networkBlock(ssid, password))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	// A running wpa_supplicant picks the network up at once; otherwise it
+	// is joined at the next boot.
+	if _, err := exec.LookPath("wpa_cli"); err == nil {
+		return run(ctx, "wpa_cli", "reconfigure")
+	}
+	return nil
+}
+
+// validateCredentials checks ssid and password as WPA defines them: an SSID
+// of 1 to 32 bytes, and a passphrase of 8 to 63 printable ASCII characters
+// or a raw key of 64 hex digits.
+func validateCredentials(ssid, password string) error {
+	if len(ssid) == 0 || len(ssid) > 32 {
+		return errors.New("ssid must be 1 to 32 bytes")
+	}
+	if password == "" || isRawKey(password) {
+		return nil
+	}
+	if len(password) < 8 || len(password) > 63 {
+		return errors.New("password must be 8 to 63 characters, or 64 hex digits")
+	}
+	for _, c := range []byte(password) {
+		if c < ' ' || c > '~' || c == '"' {
+			return errors.New("password must be printable ASCII without quotes")
+		}
+	}
+	return nil
+}
+
+// isRawKey reports whether password is a 256-bit PSK in hex.
+func isRawKey(password string) bool {
+	_, err := hex.DecodeString(password)
+	return len(password) == 64 && err == nil
+}
+
+// networkBlock is a wpa_supplicant network entry for ssid. The SSID is
+// written in hex, so any bytes in it survive.
+func networkBlock(ssid, password string) string {
+	auth := "\tkey_mgmt=NONE\n"
+	switch {
+	case isRawKey(password):
+		auth = "\tpsk=" + strings.ToLower(password) + "\n"
+	case password != "":
+		auth = "\tpsk=\"" + password + "\"\n"
+	}
+	return "\nnetwork={\n\tssid=" + hex.EncodeToString([]byte(ssid)) + "\n" + auth + "}\n"
+}
+
+func run(ctx context.Context, name string, args ...string) error {
+	out, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %w: %s", name, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
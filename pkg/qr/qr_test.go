@@ -0,0 +1,119 @@
+package qr
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReedSolomonMatchesReferenceExample(t *testing.T) {
+	// "HELLO WORLD" at 1-M, from the worked example in the standard's tutorials.
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	assert.Equal(t, want, rsRemainder(data, rsGenerator(10)))
+}
+
+func TestEncodePicksSmallestVersion(t *testing.T) {
+	c, err := Encode("hello")
+	require.NoError(t, err)
+	assert.Equal(t, 21, c.Size())
+
+	c, err = Encode(strings.Repeat("x", 100))
+	require.NoError(t, err)
+	assert.Equal(t, 17+4*6, c.Size())
+
+	_, err = Encode(strings.Repeat("x", 214))
+	assert.Error(t, err)
+}
+
+// readFormat reads the first copy of the format information.
+func readFormat(c *Code) int {
+	bits := 0
+	set := func(i int, dark bool) {
+		if dark {
+			bits |= 1 << i
+		}
+	}
+	for i := 0; i <= 5; i++ {
+		set(i, c.Dark(8, i))
+	}
+	set(6, c.Dark(8, 7))
+	set(7, c.Dark(8, 8))
+	set(8, c.Dark(7, 8))
+	for i := 9; i < 15; i++ {
+		set(i, c.Dark(14-i, 8))
+	}
+	return bits ^ 0x5412
+}
+
+func TestEncodeRoundTripsCodewords(t *testing.T) {
+	for _, text := range []string{"picoclaw://pair?addr=192.168.1.20:18790&code=123456", strings.Repeat("z", 180)} {
+		c, err := Encode(text)
+		require.NoError(t, err)
+
+		format := readFormat(c)
+		assert.Equal(t, 0, format>>13, "level M")
+		mask := format >> 10 & 7
+
+		// Unmask and read the codewords back in placement order.
+		c.applyMask(mask)
+		var bits bitBuffer
+		for right := c.size - 1; right >= 1; right -= 2 {
+			if right == 6 {
+				right = 5
+			}
+			for vert := 0; vert < c.size; vert++ {
+				for j := 0; j < 2; j++ {
+					x, y := right-j, vert
+					if (right+1)&2 == 0 {
+						y = c.size - 1 - vert
+					}
+					if !c.function[y][x] {
+						bits = append(bits, c.modules[y][x])
+					}
+				}
+			}
+		}
+		version := (c.size - 17) / 4
+		l := versions[version]
+		codewords := bits.bytes()[:l.dataCodewords()+(l.blocks1+l.blocks2)*l.ecPerBlock]
+
+		blocks := make([][]byte, l.blocks1+l.blocks2)
+		for i := 0; i < max(l.data1, l.data2); i++ {
+			for b := range blocks {
+				if i < l.data1 || b >= l.blocks1 {
+					blocks[b] = append(blocks[b], codewords[0])
+					codewords = codewords[1:]
+				}
+			}
+		}
+		var data []byte
+		for _, b := range blocks {
+			data = append(data, b...)
+		}
+		require.Equal(t, byte(0x40|len(text)>>4), data[0])
+		got := make([]byte, len(text))
+		for i := range got {
+			got[i] = data[1+i]<<4 | data[2+i]>>4
+		}
+		assert.Equal(t, text, string(got))
+	}
+}
+
+func TestTerminalRendersTwoRowsPerLine(t *testing.T) {
+	c, err := Encode("hi")
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSuffix(c.Terminal(), "\n"), "\n")
+	assert.Len(t, lines, (c.Size()+8+1)/2)
+}
+
+func TestFormatBitsMatchStandardTable(t *testing.T) {
+	// Level M format strings for masks 0 and 5, most significant bit first.
+	for mask, want := range map[int]int{0: 0b101010000010010, 5: 0b100000011001110} {
+		c := newCode(1)
+		c.drawFormatBits(mask)
+		assert.Equal(t, want, readFormat(c)^0x5412, "mask %d", mask)
+	}
+}
@@ -0,0 +1,571 @@
+// Package receipts turns an uploaded receipt into a draft LedgerForge
+// expense with a fixed pipeline: LedgerForge's OCR, rules that pick the
+// vendor, total, date and taxes out of the OCR output, the business's
+// category rules or else a category suggestion, and a draft transaction with the receipt attached. No model
+// decides what to do, so it is faster than asking the agent and the same
+// receipt always gives the same result.
+package receipts
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/categorize"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/eventlog"
+	"github.com/sipeed/picoclaw/pkg/ledgerforge"
+	"github.com/sipeed/picoclaw/pkg/locale"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/subscriptions"
+)
+
+// Receipt is an uploaded receipt image or PDF.
+type Receipt struct {
+	Filename string
+	Data     []byte
+	DateHint string // YYYY-MM-DD, used when no date can be read from the receipt
+}
+
+// Result is what was read from a receipt and the draft created for it.
+type Result struct {
+	Vendor      string                   `json:"vendor"`
+	Amount      string                   `json:"amount"` // the total paid, e.g. "42.10"
+	Currency    string                   `json:"currency"`
+	Date        string                   `json:"date,omitempty"` // YYYY-MM-DD
+	GSTAmount   string                   `json:"gst_amount"`
+	PSTAmount   string                   `json:"pst_amount"`
+	Category    string                   `json:"category,omitempty"`
+	Transaction *ledgerforge.Transaction `json:"transaction,omitempty"` // nil if no draft was created
+	Warnings    []string                 `json:"warnings,omitempty"`
+	Confidence  map[string]float64       `json:"confidence,omitempty"` // of the vendor, amount and date, 0 to 1
+	ReviewID    string                   `json:"review_id,omitempty"`  // set if the receipt waits for review
+}
+
+// Pipeline processes receipts against LedgerForge.
+type Pipeline struct {
+	client  *ledgerforge.Client
+	locales *locale.Resolver // nil: every business uses the default locale
+	nowFunc func() time.Time // for testing
+
+	review    *ReviewQueue // nil: every receipt with a total gets a draft
+	threshold float64
+
+	rules  func(businessID string) []config.CategoryRule // nil: LedgerForge suggests every category
+	events *eventlog.Log                                 // nil: drafts are not recorded locally
+}
+
+// New creates a pipeline that uses client. Receipts are read in the locale
+// locales gives their business, which may be nil.
+func New(client *ledgerforge.Client, locales *locale.Resolver) *Pipeline {
+	return &Pipeline{client: client, locales: locales, nowFunc: time.Now}
+}
+
+// SetReview parks receipts whose vendor, total or date were read with a
+// confidence below threshold in q, instead of creating their draft, until
+// someone confirms or corrects them.
+func (p *Pipeline) SetReview(q *ReviewQueue, threshold float64) {
+	p.review = q
+	p.threshold = threshold
+}
+
+// SetCategoryRules categorizes receipts with the first of rules(business)
+// they match, without asking LedgerForge for a suggestion.
+func (p *Pipeline) SetCategoryRules(rules func(businessID string) []config.CategoryRule) {
+	p.rules = rules
+}
+
+// SetEventLog records every draft the pipeline creates in l, where
+// transaction exports read them from.
+func (p *Pipeline) SetEventLog(l *eventlog.Log) {
+	p.events = l
+}
+
+// Process reads r and, if its total could be read, creates a draft expense
+// for the business in ctx. ctx must carry the user's JWT and business, as
+// for any LedgerForge call. With a review queue, a receipt read with low
+// confidence is parked in it instead, and the result has its ReviewID.
+//
+// The draft and its attachment are created with idempotency keys derived
+// from the file, so LedgerForge can recognize a receipt uploaded again, and
+// a receipt.processed event is sent once the draft exists.
+func (p *Pipeline) Process(ctx context.Context, r Receipt) (*Result, error) {
+	ocr, err := p.client.ExtractReceipt(ctx, r.Filename, r.Data)
+	if err != nil {
+		return nil, fmt.Errorf("reading receipt: %w", err)
+	}
+	res := extractFields(ocr, p.locales.For(ledgerforge.BusinessID(ctx)))
+	res.Confidence = fieldConfidence(ocr, res)
+	if res.Date == "" && r.DateHint != "" {
+		res.Date = r.DateHint
+		res.Confidence["date"] = 1
+	}
+	if res.Amount == "" && p.review == nil {
+		res.Warnings = append(res.Warnings, "The total could not be read, so no draft was created.")
+		return res, nil
+	}
+
+	var suggestion float64
+	if rule, ok := p.matchRule(ctx, ocr, res); ok {
+		res.Category = rule.Category
+	} else if res.Amount != "" {
+		if s, err := p.client.SuggestCategory(ctx, res.Vendor, res.Amount); err != nil {
+			logger.WarnCF("receipts", "Category suggestion failed", map[string]any{"error": err.Error()})
+			res.Warnings = append(res.Warnings, "No category could be suggested.")
+		} else if s.Category != "" {
+			res.Category = s.Category
+			suggestion = s.Confidence
+		}
+	}
+
+	if p.review != nil {
+		if unsure := unsureFields(res, p.threshold); len(unsure) > 0 {
+			return p.park(ctx, r, res, unsure, suggestion)
+		}
+	}
+	if res.Date == "" {
+		res.Date = p.nowFunc().Format(time.DateOnly)
+		res.Warnings = append(res.Warnings, "The date could not be read; today's date was used.")
+	}
+	if err := p.post(ctx, r, res, suggestion); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Reviews lists the receipts waiting for review in the business in ctx.
+func (p *Pipeline) Reviews(ctx context.Context) ([]Review, error) {
+	if p.review == nil {
+		return []Review{}, nil
+	}
+	return p.review.List(ledgerforge.BusinessID(ctx))
+}
+
+// Confirm applies c to a receipt under review in the business in ctx,
+// creates its draft as Process would have, and takes it off the queue.
+// It returns ErrReviewNotFound for an unknown id, and ErrInvalidCorrection
+// if a correction can't be read or the vendor, total or date is still
+// missing.
+func (p *Pipeline) Confirm(ctx context.Context, id string, c Correction) (*Result, error) {
+	if p.review == nil {
+		return nil, ErrReviewNotFound
+	}
+	businessID := ledgerforge.BusinessID(ctx)
+	rv, data, err := p.review.get(businessID, id)
+	if err != nil {
+		return nil, err
+	}
+	res := rv.Fields
+	if err := applyCorrection(&res, c, p.locales.For(businessID)); err != nil {
+		return nil, err
+	}
+	res.ReviewID = ""
+	res.Warnings = nil
+
+	if err := p.post(ctx, Receipt{Filename: rv.Filename, Data: data}, &res, rv.SuggestionConfidence); err != nil {
+		return nil, err
+	}
+	if err := p.review.remove(businessID, id); err != nil {
+		// The draft's idempotency key keeps a second confirmation from
+		// creating another one.
+		logger.WarnCF("receipts", "Removing confirmed review failed", map[string]any{"id": id, "error": err.Error()})
+	}
+	return &res, nil
+}
+
+// Discard takes a receipt off the review queue of the business in ctx
+// without creating a draft.
+func (p *Pipeline) Discard(ctx context.Context, id string) error {
+	if p.review == nil {
+		return ErrReviewNotFound
+	}
+	return p.review.remove(ledgerforge.BusinessID(ctx), id)
+}
+
+// matchRule returns the category rule of the business in ctx that res
+// matches, if any.
+func (p *Pipeline) matchRule(ctx context.Context, ocr *ledgerforge.OCRData, res *Result) (config.CategoryRule, bool) {
+	if p.rules == nil {
+		return config.CategoryRule{}, false
+	}
+	return categorize.Match(p.rules(ledgerforge.BusinessID(ctx)), categorize.Expense{
+		Vendor: res.Vendor,
+		Amount: res.Amount,
+		Text:   ocr.RawText,
+	})
+}
+
+// park adds r to the review queue for the business in ctx.
+func (p *Pipeline) park(
+	ctx context.Context,
+	r Receipt,
+	res *Result,
+	unsure []string,
+	suggestion float64,
+) (*Result, error) {
+	sum := sha256.Sum256(r.Data)
+	rv := &Review{
+		ID:                   hex.EncodeToString(sum[:8]),
+		BusinessID:           ledgerforge.BusinessID(ctx),
+		Filename:             r.Filename,
+		Unsure:               unsure,
+		SuggestionConfidence: suggestion,
+		CreatedAt:            p.nowFunc().UTC(),
+	}
+	res.ReviewID = rv.ID
+	res.Warnings = append(res.Warnings, fmt.Sprintf(
+		"The %s could not be read reliably, so the receipt is waiting for review.", strings.Join(unsure, ", ")))
+	rv.Fields = *res
+	if err := p.review.add(rv, r.Data); err != nil {
+		return nil, fmt.Errorf("queueing receipt for review: %w", err)
+	}
+	return res, nil
+}
+
+// post creates the draft for res with r attached, and sends the
+// receipt.processed event to LedgerForge and receipt.parsed to subscribers.
+func (p *Pipeline) post(ctx context.Context, r Receipt, res *Result, suggestion float64) error {
+	draft := ledgerforge.Transaction{
+		VendorName:      res.Vendor,
+		Amount:          "-" + res.Amount, // LedgerForge stores expenses as negative amounts
+		Currency:        res.Currency,
+		Description:     "Receipt capture - " + res.Vendor,
+		TransactionDate: res.Date,
+		Classification:  "business_expense",
+		GSTAmount:       res.GSTAmount,
+		PSTAmount:       res.PSTAmount,
+		Category:        res.Category,
+	}
+	if suggestion > 0 {
+		draft.AISuggestedCategory = res.Category
+		draft.AIConfidence = suggestion
+	}
+
+	sum := sha256.Sum256(r.Data)
+	digest := hex.EncodeToString(sum[:])
+	key := "receipt-" + digest[:32]
+
+	created, err := p.client.CreateTransaction(ledgerforge.WithIdempotencyKey(ctx, key+"-draft"), draft)
+	if err != nil {
+		return fmt.Errorf("creating draft: %w", err)
+	}
+	res.Transaction = created
+	p.recordCreated(ctx, created.ID, draft)
+	attached := true
+	err = p.client.AttachReceipt(ledgerforge.WithIdempotencyKey(ctx, key+"-attach"), created.ID, r.Filename, r.Data)
+	if err != nil {
+		logger.WarnCF("receipts", "Attaching receipt failed",
+			map[string]any{"transaction_id": created.ID, "error": err.Error()})
+		res.Warnings = append(res.Warnings, "The draft was created, but the receipt could not be attached to it.")
+		attached = false
+	}
+
+	processed := ledgerforge.ReceiptProcessed{
+		TransactionID: created.ID,
+		Filename:      r.Filename,
+		SHA256:        digest,
+		Size:          len(r.Data),
+		Vendor:        res.Vendor,
+		Amount:        res.Amount,
+		Currency:      res.Currency,
+		Date:          res.Date,
+		Category:      res.Category,
+		Attached:      attached,
+	}
+	subscriptions.Publish(subscriptions.Event{
+		ID:         key,
+		Type:       subscriptions.EventReceiptParsed,
+		BusinessID: ledgerforge.BusinessID(ctx),
+		Data:       processed,
+	})
+	err = p.client.Notify(ctx, ledgerforge.Event{ID: key, Type: ledgerforge.EventReceiptProcessed, Data: processed})
+	if err != nil {
+		logger.WarnCF("receipts", "Receipt event failed",
+			map[string]any{"transaction_id": created.ID, "error": err.Error()})
+	}
+	return nil
+}
+
+// recordCreated adds the draft created as id to the event log.
+func (p *Pipeline) recordCreated(ctx context.Context, id string, draft ledgerforge.Transaction) {
+	if p.events == nil {
+		return
+	}
+	_, err := p.events.AppendContext(ctx, eventlog.Event{
+		Type: eventlog.TypeTransactionCreated,
+		Data: map[string]any{
+			"transaction_id": id,
+			"source":         "receipt",
+			"date":           draft.TransactionDate,
+			"vendor":         draft.VendorName,
+			"description":    draft.Description,
+			"amount":         draft.Amount,
+			"currency":       draft.Currency,
+			"category":       draft.Category,
+			"gst_amount":     draft.GSTAmount,
+			"pst_amount":     draft.PSTAmount,
+		},
+	})
+	if err != nil {
+		logger.WarnCF("receipts", "Failed to record draft", map[string]any{"transaction_id": id, "error": err.Error()})
+	}
+}
+
+// applyCorrection overwrites the fields of res that c sets, in the
+// business's locale, and checks that nothing a draft needs is missing.
+func applyCorrection(res *Result, c Correction, settings locale.Settings) error {
+	if v := strings.TrimSpace(c.Vendor); v != "" {
+		res.Vendor = v
+	}
+	if c.Amount != "" {
+		amount, ok := locale.ParseAmount(c.Amount)
+		if !ok || money(amount) == "" {
+			return fmt.Errorf("%w: %q is not an amount", ErrInvalidCorrection, c.Amount)
+		}
+		res.Amount = money(amount)
+	}
+	if c.Currency != "" {
+		res.Currency = strings.ToUpper(strings.TrimSpace(c.Currency))
+	}
+	if c.Date != "" {
+		date := c.Date
+		if _, err := time.Parse(time.DateOnly, date); err != nil {
+			date = normalizeDate(date, settings)
+		}
+		if date == "" {
+			return fmt.Errorf("%w: %q is not a date", ErrInvalidCorrection, c.Date)
+		}
+		res.Date = date
+	}
+	if c.Category != "" {
+		res.Category = strings.TrimSpace(c.Category)
+	}
+	read := fieldConfidence(&ledgerforge.OCRData{}, res)
+	for _, f := range keyFields {
+		if read[f] == 0 {
+			return fmt.Errorf("%w: the %s is missing", ErrInvalidCorrection, f)
+		}
+	}
+	return nil
+}
+
+var (
+	// amountPattern matches amounts with cents in either notation, like
+	// $19.00, CA$26.91, 1,234.56 and 1.234,56 €.
+	amountPattern = regexp.MustCompile(`(\d[\d.,]*[.,]\d{2})\b`)
+	// currencyMention matches currencies named on a receipt, as codes or
+	// as country-marked dollars like US$.
+	currencyMention = regexp.MustCompile(`\b(USD|CAD|EUR|GBP|AUD|NZD|CHF|JPY)\b|\b(US|CA|AU|NZ) ?\$`)
+	conversion      = regexp.MustCompile(`(?i)exchange.rate|converted|conversion`)
+	totalLine       = regexp.MustCompile(`(?i)^\s*(total|summe|gesamt)`)
+	taxLine         = regexp.MustCompile(`(?i)^\s*(tax|gst|hst)`)
+	markdownImage   = regexp.MustCompile(`!\[[^\]]*\]\([^)]*\)`)
+	companySuffix   = regexp.MustCompile(`(?i)(ltd|llc|inc|corp|co\.|pte|limited|gmbh|plc)`)
+	receiptHeading  = regexp.MustCompile(
+		`(?i)^(receipt|invoice|order|bill|payment|date|total|subtotal|tax|amount|qty|item|description|price|` +
+			`charged|transaction|#|---|img)`)
+	textDate = regexp.MustCompile(`(?i)\b(jan|feb|mar|apr|may|jun|jul|aug|sep|oct|nov|dec)[a-z]*\.?\s+` +
+		`(\d{1,2}),?\s+(\d{4})`)
+)
+
+// dateLayouts are the formats of written-out OCR dates; numeric dates are
+// read in the business's date order.
+var dateLayouts = []string{"January 2, 2006", "Jan 2, 2006"}
+
+// extractFields picks the receipt's fields out of the OCR output. OCR often
+// reports the subtotal as the amount and misses dates and taxes, so the raw
+// text is checked for them too.
+func extractFields(ocr *ledgerforge.OCRData, settings locale.Settings) *Result {
+	res := &Result{
+		Vendor:    vendorName(ocr),
+		Currency:  settings.Currency,
+		GSTAmount: taxOrZero(taxAmount(ocr, "gst")),
+		PSTAmount: taxOrZero(taxAmount(ocr, "pst")),
+	}
+	lines := strings.Split(ocr.RawText, "\n")
+
+	// Prefer the TOTAL line over the OCR amount, falling back to any line
+	// mentioning a total.
+	amount := firstAmount(lines, totalLine.MatchString)
+	if amount == "" {
+		amount = money(string(ocr.Amount))
+	}
+	if amount == "" {
+		amount = firstAmount(lines, func(l string) bool { return strings.Contains(strings.ToLower(l), "total") })
+	}
+	// A foreign-currency purchase charged in the business's currency states
+	// the amount charged.
+	if foreignCurrency(ocr.RawText, settings.Currency) {
+		markers := localMarkers(settings)
+		local := firstAmount(lines, func(l string) bool {
+			for _, m := range markers {
+				if strings.Contains(l, m) {
+					return true
+				}
+			}
+			return false
+		})
+		if local != "" {
+			amount = local
+		}
+	}
+	res.Amount = amount
+
+	if res.GSTAmount == "0.00" {
+		if tax := firstAmount(lines, taxLine.MatchString); tax != "" {
+			res.GSTAmount = tax
+		}
+	}
+
+	res.Date = normalizeDate(ocr.Date, settings)
+	if res.Date == "" {
+		if m := textDate.FindString(ocr.RawText); m != "" {
+			res.Date = normalizeDate(m, settings)
+		}
+	}
+	return res
+}
+
+// foreignCurrency reports whether text mentions a currency other than the
+// business's own, or a conversion.
+func foreignCurrency(text, currency string) bool {
+	if conversion.MatchString(text) {
+		return true
+	}
+	for _, m := range currencyMention.FindAllStringSubmatch(text, -1) {
+		code := m[1]
+		if code == "" {
+			code = m[2] + "D"
+		}
+		if code != currency {
+			return true
+		}
+	}
+	return false
+}
+
+// localMarkers are what marks an amount in the business's currency: its
+// code, and CA$ for Canadian dollars or the symbol of other currencies.
+func localMarkers(s locale.Settings) []string {
+	if sym := s.Symbol(); !strings.HasSuffix(sym, "$") {
+		return []string{s.Currency, sym}
+	}
+	return []string{s.Currency, s.Currency[:2] + "$"}
+}
+
+func taxOrZero(s ledgerforge.FlexString) string {
+	if m := money(string(s)); m != "" {
+		return m
+	}
+	return "0.00"
+}
+
+func taxAmount(ocr *ledgerforge.OCRData, kind string) ledgerforge.FlexString {
+	if ocr.TaxAmounts == nil {
+		return ""
+	}
+	if kind == "gst" {
+		return ocr.TaxAmounts.GST
+	}
+	return ocr.TaxAmounts.PST
+}
+
+// firstAmount returns the last amount on the first line that match accepts
+// and that has one.
+func firstAmount(lines []string, match func(string) bool) string {
+	for _, l := range lines {
+		if !match(l) {
+			continue
+		}
+		if all := amountPattern.FindAllString(l, -1); len(all) > 0 {
+			amount, _ := locale.ParseAmount(all[len(all)-1])
+			return money(amount)
+		}
+	}
+	return ""
+}
+
+// money normalizes an amount to two decimals, or returns "" for a missing
+// or zero amount.
+func money(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' || r == '.' {
+			return r
+		}
+		return -1
+	}, s)
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v <= 0 {
+		return ""
+	}
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+func normalizeDate(s string, settings locale.Settings) string {
+	if t, ok := settings.ParseDate(s); ok {
+		return t.Format(time.DateOnly)
+	}
+	s = strings.Join(strings.Fields(strings.ReplaceAll(s, ".", "")), " ")
+	if s == "" {
+		return ""
+	}
+	if !strings.Contains(s, ",") && textDate.MatchString(s) {
+		// "March 2 2026" -> "March 2, 2026"
+		if i := strings.LastIndex(s, " "); i > 0 {
+			s = s[:i] + "," + s[i:]
+		}
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Format(time.DateOnly)
+		}
+	}
+	// Month names longer than the short form but not the full one, like
+	// "Sept".
+	if m := textDate.FindStringSubmatch(s); m != nil {
+		if t, err := time.Parse("Jan 2 2006", m[1]+" "+m[2]+" "+m[3]); err == nil {
+			return t.Format(time.DateOnly)
+		}
+	}
+	return ""
+}
+
+// vendorName cleans up the OCR vendor, or finds one in the raw text when
+// OCR returned nothing useful.
+func vendorName(ocr *ledgerforge.OCRData) string {
+	vendor := cleanLine(ocr.Vendor)
+	if len(vendor) >= 3 && !strings.EqualFold(vendor, "unknown") && !strings.EqualFold(vendor, "receipt") {
+		return vendor
+	}
+	var lines []string
+	for _, l := range strings.Split(ocr.RawText, "\n") {
+		if l = cleanLine(l); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	for _, l := range lines {
+		if companySuffix.MatchString(l) && len(l) < 60 {
+			return l
+		}
+	}
+	for _, l := range lines {
+		if !receiptHeading.MatchString(l) {
+			if len(l) >= 3 && len(l) < 60 {
+				return l
+			}
+			break
+		}
+	}
+	return "Unknown"
+}
+
+func cleanLine(s string) string {
+	s = markdownImage.ReplaceAllString(s, "")
+	s = strings.TrimLeft(strings.TrimSpace(s), "#")
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,231 @@
+package receipts
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/eventlog"
+	"github.com/sipeed/picoclaw/pkg/ledgerforge"
+	"github.com/sipeed/picoclaw/pkg/locale"
+)
+
+func TestExtractFields(t *testing.T) {
+	canadian := locale.NewResolver(config.LocaleConfig{}).For("")
+	german := locale.NewResolver(config.LocaleConfig{Locale: "de-DE"}).For("")
+	tests := []struct {
+		name     string
+		ocr      ledgerforge.OCRData
+		settings locale.Settings
+		want     Result
+	}{
+		{
+			name: "total line beats the OCR subtotal",
+			ocr: ledgerforge.OCRData{
+				Vendor:     "# Corner Cafe",
+				Amount:     "20.00",
+				Date:       "03/02/2026",
+				RawText:    "Corner Cafe\nSubtotal $20.00\nGST $1.00\nTOTAL $21.00",
+				TaxAmounts: &ledgerforge.TaxAmounts{GST: "1.00"},
+			},
+			settings: canadian,
+			want: Result{Vendor: "Corner Cafe", Amount: "21.00", Currency: "CAD", Date: "2026-03-02",
+				GSTAmount: "1.00", PSTAmount: "0.00"},
+		},
+		{
+			name: "USD charge uses the CAD amount",
+			ocr: ledgerforge.OCRData{
+				Vendor:  "Unknown",
+				Amount:  "19",
+				RawText: "![logo](x.png)\nAcme Software Inc.\nMarch 5, 2026\nTotal US$19.00\nCharged CA$26.91 (USD conversion)",
+			},
+			settings: canadian,
+			want: Result{Vendor: "Acme Software Inc.", Amount: "26.91", Currency: "CAD", Date: "2026-03-05",
+				GSTAmount: "0.00", PSTAmount: "0.00"},
+		},
+		{
+			name: "tax from raw text and no total",
+			ocr: ledgerforge.OCRData{
+				RawText: "Receipt\nHardware Depot\nHST $2.60\nSept 9 2026",
+			},
+			settings: canadian,
+			want: Result{Vendor: "Hardware Depot", Currency: "CAD", Date: "2026-09-09", GSTAmount: "2.60",
+				PSTAmount: "0.00"},
+		},
+		{
+			name: "German receipt reads day first and decimal commas",
+			ocr: ledgerforge.OCRData{
+				Vendor:  "Bäckerei Schmidt GmbH",
+				Date:    "03.02.2026",
+				RawText: "Bäckerei Schmidt GmbH\nZwischensumme 1.150,00 €\nSumme 1.234,56 €",
+			},
+			settings: german,
+			want: Result{Vendor: "Bäckerei Schmidt GmbH", Amount: "1234.56", Currency: "EUR", Date: "2026-02-03",
+				GSTAmount: "0.00", PSTAmount: "0.00"},
+		},
+		{
+			name: "USD charge on a German card uses the EUR amount",
+			ocr: ledgerforge.OCRData{
+				Vendor:  "Acme Software Inc.",
+				RawText: "Total US$19.00\nBelastet 17,45 EUR",
+			},
+			settings: german,
+			want: Result{Vendor: "Acme Software Inc.", Amount: "17.45", Currency: "EUR", GSTAmount: "0.00",
+				PSTAmount: "0.00"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := extractFields(&tt.ocr, tt.settings)
+			assert.Equal(t, tt.want, *got)
+		})
+	}
+}
+
+func TestProcessCreatesDraftWithReceipt(t *testing.T) {
+	var created ledgerforge.Transaction
+	var attached bool
+	var draftKey string
+	var event struct {
+		ID   string                       `json:"id"`
+		Type string                       `json:"type"`
+		Data ledgerforge.ReceiptProcessed `json:"data"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer jwt-1", r.Header.Get("Authorization"))
+		switch r.URL.Path {
+		case "/api/v1/businesses/biz-1/receipts/extract-ocr":
+			io.WriteString(w, `{"success":true,"data":{"ocr_data":{"vendor":"Corner Cafe","amount":"12.50",`+
+				`"raw_text":"Corner Cafe\nTotal $12.50"}}}`)
+		case "/api/v1/businesses/biz-1/transactions/suggest-category":
+			io.WriteString(w, `{"category":"Meals and Entertainment","confidence":0.9}`)
+		case "/api/v1/businesses/biz-1/transactions":
+			draftKey = r.Header.Get(ledgerforge.IdempotencyHeader)
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&created))
+			io.WriteString(w, `{"id":"tx-1","status":"draft","amount":"-12.50"}`)
+		case "/api/v1/businesses/biz-1/transactions/tx-1/receipts":
+			f, _, err := r.FormFile("file")
+			require.NoError(t, err)
+			data, _ := io.ReadAll(f)
+			assert.Equal(t, "image-bytes", string(data))
+			attached = true
+		case "/api/v1/businesses/biz-1/events":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&event))
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	p := New(ledgerforge.New(ledgerforge.Options{BaseURL: srv.URL, CallbackPath: "/events"}), nil)
+	p.nowFunc = func() time.Time { return time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC) }
+	events, err := eventlog.Open(t.TempDir(), eventlog.Options{})
+	require.NoError(t, err)
+	defer events.Close()
+	p.SetEventLog(events)
+	ctx := ledgerforge.WithAuth(context.Background(), "jwt-1", "biz-1")
+
+	res, err := p.Process(ctx, Receipt{Filename: "r.jpg", Data: []byte("image-bytes"), DateHint: "2026-03-07"})
+	require.NoError(t, err)
+	assert.Equal(t, "2026-03-07", res.Date)
+	assert.Equal(t, "Meals and Entertainment", res.Category)
+	assert.Empty(t, res.Warnings)
+	require.NotNil(t, res.Transaction)
+	assert.Equal(t, "tx-1", res.Transaction.ID)
+	assert.True(t, attached)
+
+	assert.Equal(t, "-12.50", created.Amount)
+	assert.Equal(t, "business_expense", created.Classification)
+	assert.Equal(t, "Receipt capture - Corner Cafe", created.Description)
+	assert.Equal(t, "2026-03-07", created.TransactionDate)
+
+	assert.Equal(t, ledgerforge.EventReceiptProcessed, event.Type)
+	assert.Regexp(t, `^receipt-[0-9a-f]{32}$`, event.ID)
+	assert.Equal(t, event.ID+"-draft", draftKey, "the same receipt always makes the same draft key")
+	assert.Equal(t, ledgerforge.ReceiptProcessed{
+		TransactionID: "tx-1",
+		Filename:      "r.jpg",
+		SHA256:        "2c8648d103e3dd7ad87660da0f126a1443b6d21ac1bd3ec000c5e24e2373a90c",
+		Size:          11,
+		Vendor:        "Corner Cafe",
+		Amount:        "12.50",
+		Currency:      "CAD",
+		Date:          "2026-03-07",
+		Category:      "Meals and Entertainment",
+		Attached:      true,
+	}, event.Data)
+
+	var recorded []eventlog.Event
+	require.NoError(t, events.Read(0, func(e eventlog.Event) error {
+		recorded = append(recorded, e)
+		return nil
+	}))
+	require.Len(t, recorded, 1)
+	assert.Equal(t, eventlog.TypeTransactionCreated, recorded[0].Type)
+	assert.Equal(t, "biz-1", recorded[0].BusinessID)
+	assert.Equal(t, "tx-1", recorded[0].Data["transaction_id"])
+	assert.Equal(t, "-12.50", recorded[0].Data["amount"])
+	assert.Equal(t, "2026-03-07", recorded[0].Data["date"])
+}
+
+func TestProcessSkipsDraftWithoutTotal(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/businesses/biz-1/receipts/extract-ocr" {
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+		io.WriteString(w, `{"success":true,"data":{"ocr_data":{"vendor":"Blurry Shop","amount":0}}}`)
+	}))
+	defer srv.Close()
+
+	p := New(ledgerforge.New(ledgerforge.Options{BaseURL: srv.URL}), nil)
+	res, err := p.Process(ledgerforge.WithAuth(context.Background(), "jwt-1", "biz-1"),
+		Receipt{Filename: "r.png", Data: []byte("x")})
+	require.NoError(t, err)
+	assert.Nil(t, res.Transaction)
+	assert.Equal(t, "Blurry Shop", res.Vendor)
+	assert.Len(t, res.Warnings, 1)
+}
+
+func TestProcessCategorizesWithRules(t *testing.T) {
+	var draft ledgerforge.Transaction
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/businesses/biz-1/receipts/extract-ocr":
+			io.WriteString(w, `{"success":true,"data":{"ocr_data":{"vendor":"Shell Canada","amount":"60.00",`+
+				`"date":"2026-03-02","raw_text":"Shell Canada\nRegular 40.1L\nTotal $60.00"}}}`)
+		case "/api/v1/businesses/biz-1/transactions":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&draft))
+			io.WriteString(w, `{"id":"tx-1","status":"draft"}`)
+		case "/api/v1/businesses/biz-1/transactions/tx-1/receipts":
+			io.WriteString(w, `{}`)
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	p := New(ledgerforge.New(ledgerforge.Options{BaseURL: srv.URL}), nil)
+	p.SetCategoryRules(func(businessID string) []config.CategoryRule {
+		assert.Equal(t, "biz-1", businessID)
+		return []config.CategoryRule{
+			{Merchant: "Esso", Category: "Travel"},
+			{Merchant: "Shell", Keyword: "regular", Category: "Fuel"},
+		}
+	})
+	res, err := p.Process(ledgerforge.WithAuth(context.Background(), "jwt-1", "biz-1"),
+		Receipt{Filename: "r.jpg", Data: []byte("image-bytes")})
+	require.NoError(t, err)
+	assert.Equal(t, "Fuel", res.Category)
+	assert.Equal(t, "Fuel", draft.Category)
+	assert.Empty(t, draft.AISuggestedCategory, "a rule is not an AI suggestion")
+}
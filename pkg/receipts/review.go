@@ -0,0 +1,218 @@
+package receipts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/ledgerforge"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// keyFields are the fields a draft can't be trusted without. A receipt
+// with any of them read below the review threshold waits for review.
+var keyFields = []string{"vendor", "amount", "date"}
+
+// reviewID matches the IDs the queue hands out, so an ID from a request
+// can't name a file outside it.
+var reviewID = regexp.MustCompile(`^[0-9a-f]{16}$`)
+
+var (
+	// ErrReviewNotFound is returned for a review that doesn't exist in the
+	// business, or was already confirmed or discarded.
+	ErrReviewNotFound = errors.New("receipt review not found")
+	// ErrInvalidCorrection is returned when a confirmed receipt still lacks
+	// a field, or a correction can't be read.
+	ErrInvalidCorrection = errors.New("invalid correction")
+)
+
+// Review is a receipt waiting for a person to confirm or correct what was
+// read from it before its draft is created.
+type Review struct {
+	ID                   string    `json:"id"`
+	BusinessID           string    `json:"business_id"`
+	Filename             string    `json:"filename"`
+	Fields               Result    `json:"fields"` // what was read, with its confidence
+	Unsure               []string  `json:"unsure"` // the key fields read below the threshold
+	SuggestionConfidence float64   `json:"suggestion_confidence,omitempty"`
+	CreatedAt            time.Time `json:"created_at"`
+}
+
+// Correction changes what was read from a receipt under review. Empty
+// fields keep what was read.
+type Correction struct {
+	Vendor   string `json:"vendor,omitempty"`
+	Amount   string `json:"amount,omitempty"`
+	Currency string `json:"currency,omitempty"`
+	Date     string `json:"date,omitempty"` // YYYY-MM-DD or the business's date format
+	Category string `json:"category,omitempty"`
+}
+
+// ReviewQueue keeps receipts under review on disk, one directory per
+// business, each as <id>.json and the file itself as <id>.receipt.
+type ReviewQueue struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewReviewQueue creates a queue stored in dir, typically
+// <workspace>/receipts/review.
+func NewReviewQueue(dir string) *ReviewQueue {
+	return &ReviewQueue{dir: dir}
+}
+
+// List returns the receipts under review for a business, oldest first.
+func (q *ReviewQueue) List(businessID string) ([]Review, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	paths, err := filepath.Glob(filepath.Join(q.businessDir(businessID), "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	reviews := []Review{}
+	for _, path := range paths {
+		rv, err := readReview(path)
+		if err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, *rv)
+	}
+	sort.Slice(reviews, func(i, j int) bool { return reviews[i].CreatedAt.Before(reviews[j].CreatedAt) })
+	return reviews, nil
+}
+
+// add stores rv and the receipt's file, replacing a review of the same
+// receipt.
+func (q *ReviewQueue) add(rv *Review, data []byte) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	dir := q.businessDir(rv.BusinessID)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("creating review directory: %w", err)
+	}
+	meta, err := json.MarshalIndent(rv, "", "  ")
+	if err != nil {
+		return err
+	}
+	// The file goes first, so a review on disk always has its receipt.
+	if err := writeFile(filepath.Join(dir, rv.ID+".receipt"), data); err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(dir, rv.ID+".json"), meta)
+}
+
+// get returns a business's review and its receipt's file.
+func (q *ReviewQueue) get(businessID, id string) (*Review, []byte, error) {
+	if !reviewID.MatchString(id) {
+		return nil, nil, ErrReviewNotFound
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	base := filepath.Join(q.businessDir(businessID), id)
+	rv, err := readReview(base + ".json")
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, ErrReviewNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := os.ReadFile(base + ".receipt")
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading receipt under review: %w", err)
+	}
+	return rv, data, nil
+}
+
+// remove deletes a business's review and its receipt's file.
+func (q *ReviewQueue) remove(businessID, id string) error {
+	if !reviewID.MatchString(id) {
+		return ErrReviewNotFound
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	base := filepath.Join(q.businessDir(businessID), id)
+	if err := os.Remove(base + ".json"); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return ErrReviewNotFound
+		}
+		return err
+	}
+	if err := os.Remove(base + ".receipt"); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (q *ReviewQueue) businessDir(businessID string) string {
+	return filepath.Join(q.dir, utils.BusinessDirName(businessID))
+}
+
+func readReview(path string) (*Review, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rv Review
+	if err := json.Unmarshal(data, &rv); err != nil {
+		return nil, fmt.Errorf("reading review %s: %w", filepath.Base(path), err)
+	}
+	return &rv, nil
+}
+
+// writeFile replaces path through a temporary file, so a crash never leaves
+// half a review.
+func writeFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("writing %s: %w", filepath.Base(path), err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("writing %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
+
+// unsureFields returns the key fields of res read below threshold.
+func unsureFields(res *Result, threshold float64) []string {
+	var unsure []string
+	for _, f := range keyFields {
+		if res.Confidence[f] < threshold {
+			unsure = append(unsure, f)
+		}
+	}
+	return unsure
+}
+
+// fieldConfidence is how sure the pipeline is of each key field of res:
+// what OCR reported for the field, else overall, else full confidence.
+// Fields that weren't read at all have none.
+func fieldConfidence(ocr *ledgerforge.OCRData, res *Result) map[string]float64 {
+	conf := make(map[string]float64, len(keyFields))
+	values := map[string]string{"vendor": res.Vendor, "amount": res.Amount, "date": res.Date}
+	for _, f := range keyFields {
+		c := ocr.FieldConfidence[f]
+		if c == 0 {
+			c = ocr.Confidence
+		}
+		if c == 0 {
+			c = 1
+		}
+		if v := values[f]; v == "" || f == "vendor" && strings.EqualFold(v, "Unknown") {
+			c = 0
+		}
+		conf[f] = c
+	}
+	return conf
+}
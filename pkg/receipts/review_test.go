@@ -0,0 +1,119 @@
+package receipts
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/ledgerforge"
+)
+
+// reviewServer is a LedgerForge whose OCR is unsure of the vendor, and
+// that records the drafts created.
+func reviewServer(t *testing.T, drafts *[]ledgerforge.Transaction) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/businesses/biz-1/receipts/extract-ocr":
+			io.WriteString(w, `{"success":true,"data":{"ocr_data":{"vendor":"C0rner Cafe","amount":"12.50",`+
+				`"date":"2026-03-02","confidence":0.9,"field_confidence":{"vendor":0.4}}}}`)
+		case "/api/v1/businesses/biz-1/transactions/suggest-category":
+			io.WriteString(w, `{"category":"Meals and Entertainment","confidence":0.8}`)
+		case "/api/v1/businesses/biz-1/transactions":
+			var tx ledgerforge.Transaction
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&tx))
+			*drafts = append(*drafts, tx)
+			io.WriteString(w, `{"id":"tx-1","status":"draft"}`)
+		case "/api/v1/businesses/biz-1/transactions/tx-1/receipts":
+			io.WriteString(w, `{}`)
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestProcessParksUnsureReceiptForReview(t *testing.T) {
+	var drafts []ledgerforge.Transaction
+	srv := reviewServer(t, &drafts)
+	p := New(ledgerforge.New(ledgerforge.Options{BaseURL: srv.URL}), nil)
+	p.SetReview(NewReviewQueue(filepath.Join(t.TempDir(), "review")), 0.6)
+	ctx := ledgerforge.WithAuth(context.Background(), "jwt-1", "biz-1")
+
+	res, err := p.Process(ctx, Receipt{Filename: "r.jpg", Data: []byte("image-bytes")})
+	require.NoError(t, err)
+	assert.Nil(t, res.Transaction)
+	assert.Equal(t, "2c8648d103e3dd7a", res.ReviewID)
+	assert.Equal(t, map[string]float64{"vendor": 0.4, "amount": 0.9, "date": 0.9}, res.Confidence)
+	assert.Empty(t, drafts, "nothing reaches LedgerForge before review")
+
+	reviews, err := p.Reviews(ctx)
+	require.NoError(t, err)
+	require.Len(t, reviews, 1)
+	assert.Equal(t, []string{"vendor"}, reviews[0].Unsure)
+	assert.Equal(t, "C0rner Cafe", reviews[0].Fields.Vendor)
+	assert.Equal(t, "Meals and Entertainment", reviews[0].Fields.Category)
+
+	other, err := p.Reviews(ledgerforge.WithAuth(context.Background(), "jwt-2", "biz-2"))
+	require.NoError(t, err)
+	assert.Empty(t, other, "reviews stay with their business")
+
+	_, err = p.Confirm(ctx, res.ReviewID, Correction{Amount: "abc"})
+	assert.ErrorIs(t, err, ErrInvalidCorrection)
+
+	confirmed, err := p.Confirm(ctx, res.ReviewID, Correction{Vendor: "Corner Cafe", Date: "2026-03-03"})
+	require.NoError(t, err)
+	require.NotNil(t, confirmed.Transaction)
+	assert.Empty(t, confirmed.ReviewID)
+	require.Len(t, drafts, 1)
+	assert.Equal(t, "Corner Cafe", drafts[0].VendorName)
+	assert.Equal(t, "-12.50", drafts[0].Amount)
+	assert.Equal(t, "2026-03-03", drafts[0].TransactionDate)
+	assert.Equal(t, "Meals and Entertainment", drafts[0].Category)
+	assert.Equal(t, 0.8, drafts[0].AIConfidence)
+
+	reviews, err = p.Reviews(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, reviews)
+	_, err = p.Confirm(ctx, res.ReviewID, Correction{})
+	assert.ErrorIs(t, err, ErrReviewNotFound)
+}
+
+func TestDiscardDropsReview(t *testing.T) {
+	var drafts []ledgerforge.Transaction
+	srv := reviewServer(t, &drafts)
+	p := New(ledgerforge.New(ledgerforge.Options{BaseURL: srv.URL}), nil)
+	p.SetReview(NewReviewQueue(t.TempDir()), 0.6)
+	ctx := ledgerforge.WithAuth(context.Background(), "jwt-1", "biz-1")
+
+	res, err := p.Process(ctx, Receipt{Filename: "r.jpg", Data: []byte("image-bytes")})
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, p.Discard(ctx, "../../etc"), ErrReviewNotFound)
+	assert.ErrorIs(t, p.Discard(ledgerforge.WithAuth(ctx, "jwt-2", "biz-2"), res.ReviewID), ErrReviewNotFound)
+	require.NoError(t, p.Discard(ctx, res.ReviewID))
+	assert.ErrorIs(t, p.Discard(ctx, res.ReviewID), ErrReviewNotFound)
+	assert.Empty(t, drafts)
+}
+
+func TestProcessPostsConfidentReceipt(t *testing.T) {
+	var drafts []ledgerforge.Transaction
+	srv := reviewServer(t, &drafts)
+	p := New(ledgerforge.New(ledgerforge.Options{BaseURL: srv.URL}), nil)
+	p.SetReview(NewReviewQueue(t.TempDir()), 0.3)
+
+	res, err := p.Process(ledgerforge.WithAuth(context.Background(), "jwt-1", "biz-1"),
+		Receipt{Filename: "r.jpg", Data: []byte("image-bytes")})
+	require.NoError(t, err)
+	assert.NotNil(t, res.Transaction)
+	assert.Empty(t, res.ReviewID)
+	assert.Len(t, drafts, 1)
+}
@@ -0,0 +1,364 @@
+// Package reporting sends panics and high-severity errors to a Sentry
+// compatible service (Sentry, GlitchTip) using the envelope HTTP API.
+//
+// Capture functions send nothing to Sentry until Init is called with a DSN.
+// Captured errors and panics are also published to event subscribers as
+// error.occurred.
+package reporting
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/httpclient"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/subscriptions"
+)
+
+const (
+	// queueSize bounds events waiting to be sent; extra events are dropped.
+	queueSize = 64
+	// throttleWindow suppresses repeats of the same error.
+	throttleWindow = time.Minute
+)
+
+// Levels understood by Sentry.
+const (
+	LevelError = "error"
+	LevelFatal = "fatal"
+)
+
+// Config configures the reporter.
+type Config struct {
+	DSN         string
+	Environment string
+	Release     string // picoclaw version
+	DeviceID    string
+}
+
+type reporter struct {
+	cfg      Config
+	endpoint string
+	authKey  string
+	client   *http.Client
+	tags     map[string]string
+	queue    chan []byte
+	pending  sync.WaitGroup
+
+	mu       sync.Mutex
+	lastSeen map[string]time.Time
+}
+
+var active atomic.Pointer[reporter]
+
+// Init enables reporting to the project identified by cfg.DSN.
+func Init(cfg Config) error {
+	endpoint, key, err := parseDSN(cfg.DSN)
+	if err != nil {
+		return err
+	}
+
+	r := &reporter{
+		cfg:      cfg,
+		endpoint: endpoint,
+		authKey:  key,
+		client:   httpclient.New(10 * time.Second),
+		queue:    make(chan []byte, queueSize),
+		lastSeen: make(map[string]time.Time),
+		tags: map[string]string{
+			"device_id": cfg.DeviceID,
+			"version":   cfg.Release,
+			"os":        runtime.GOOS,
+			"arch":      runtime.GOARCH,
+		},
+	}
+	go r.run()
+	active.Store(r)
+
+	logger.InfoCF("reporting", "Error reporting enabled",
+		map[string]any{"endpoint": endpoint, "device_id": cfg.DeviceID})
+	return nil
+}
+
+// parseDSN turns scheme://key@host/path/project into the envelope endpoint
+// and public key.
+func parseDSN(dsn string) (string, string, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid DSN: %w", err)
+	}
+	if u.User == nil || u.User.Username() == "" {
+		return "", "", fmt.Errorf("invalid DSN: missing public key")
+	}
+	path := strings.TrimSuffix(u.Path, "/")
+	idx := strings.LastIndex(path, "/")
+	projectID := path[idx+1:]
+	if projectID == "" {
+		return "", "", fmt.Errorf("invalid DSN: missing project ID")
+	}
+	endpoint := fmt.Sprintf("%s://%s%s/api/%s/envelope/", u.Scheme, u.Host, path[:idx], projectID)
+	return endpoint, u.User.Username(), nil
+}
+
+// CaptureError reports err from component with optional extra context.
+func CaptureError(component string, err error, extra map[string]any) {
+	if err == nil {
+		return
+	}
+	publish(component, LevelError, err.Error(), extra)
+	r := active.Load()
+	if r == nil {
+		return
+	}
+	if r.throttled(component + ":" + err.Error()) {
+		return
+	}
+	r.enqueue(r.event(LevelError, component, fmt.Sprintf("%T", err), err.Error(), extra, 4))
+}
+
+// CapturePanic reports a recovered panic value and waits briefly for it to
+// be sent, since the process may be about to exit.
+func CapturePanic(component string, recovered any) {
+	if recovered == nil {
+		return
+	}
+	publish(component, LevelFatal, fmt.Sprint(recovered), nil)
+	r := active.Load()
+	if r == nil {
+		return
+	}
+	r.enqueue(r.event(LevelFatal, component, "panic", fmt.Sprint(recovered), nil, 4))
+	Flush(5 * time.Second)
+}
+
+// publish sends an error.occurred event to subscribers.
+func publish(component, level, message string, extra map[string]any) {
+	subscriptions.Publish(subscriptions.Event{
+		Type: subscriptions.EventErrorOccurred,
+		Data: map[string]any{
+			"component": component,
+			"level":     level,
+			"message":   message,
+			"extra":     extra,
+		},
+	})
+}
+
+// Recover reports a panic in the calling goroutine and re-panics. Use it as
+// the first deferred call in long-running goroutines:
+//
+//	defer reporting.Recover("agent")
+func Recover(component string) {
+	if recovered := recover(); recovered != nil {
+		CapturePanic(component, recovered)
+		panic(recovered)
+	}
+}
+
+// Flush waits up to timeout for queued events to be sent.
+func Flush(timeout time.Duration) {
+	r := active.Load()
+	if r == nil {
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		r.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+	}
+}
+
+func (r *reporter) throttled(fingerprint string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	if len(r.lastSeen) > 1000 {
+		r.lastSeen = make(map[string]time.Time)
+	}
+	if last, ok := r.lastSeen[fingerprint]; ok && now.Sub(last) < throttleWindow {
+		return true
+	}
+	r.lastSeen[fingerprint] = now
+	return false
+}
+
+type frame struct {
+	Function string `json:"function"`
+	Module   string `json:"module,omitempty"`
+	Filename string `json:"filename"`
+	AbsPath  string `json:"abs_path"`
+	Lineno   int    `json:"lineno"`
+	InApp    bool   `json:"in_app"`
+}
+
+type exception struct {
+	Type       string `json:"type"`
+	Value      string `json:"value"`
+	Stacktrace struct {
+		Frames []frame `json:"frames"`
+	} `json:"stacktrace"`
+}
+
+type event struct {
+	EventID     string            `json:"event_id"`
+	Timestamp   string            `json:"timestamp"`
+	Level       string            `json:"level"`
+	Platform    string            `json:"platform"`
+	Logger      string            `json:"logger"`
+	Release     string            `json:"release,omitempty"`
+	Environment string            `json:"environment,omitempty"`
+	ServerName  string            `json:"server_name,omitempty"`
+	Tags        map[string]string `json:"tags"`
+	Extra       map[string]any    `json:"extra,omitempty"`
+	Exception   struct {
+		Values []exception `json:"values"`
+	} `json:"exception"`
+}
+
+func (r *reporter) event(level, component, errType, message string, extra map[string]any, skip int) *event {
+	id := make([]byte, 16)
+	rand.Read(id)
+
+	ev := &event{
+		EventID:     hex.EncodeToString(id),
+		Timestamp:   time.Now().UTC().Format(time.RFC3339),
+		Level:       level,
+		Platform:    "go",
+		Logger:      component,
+		Environment: r.cfg.Environment,
+		Tags:        map[string]string{"component": component},
+		Extra:       extra,
+	}
+	if r.cfg.Release != "" {
+		ev.Release = "picoclaw@" + r.cfg.Release
+	}
+	ev.ServerName, _ = os.Hostname()
+	for k, v := range r.tags {
+		if v != "" {
+			ev.Tags[k] = v
+		}
+	}
+
+	exc := exception{Type: errType, Value: message}
+	exc.Stacktrace.Frames = stackFrames(skip)
+	ev.Exception.Values = []exception{exc}
+	return ev
+}
+
+// stackFrames returns the stack starting skip frames above runtime.Callers,
+// oldest frame first as Sentry expects.
+func stackFrames(skip int) []frame {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(skip, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var out []frame
+	for {
+		f, more := frames.Next()
+		module, function := splitFunction(f.Function)
+		out = append(out, frame{
+			Function: function,
+			Module:   module,
+			Filename: fileName(f.File),
+			AbsPath:  f.File,
+			Lineno:   f.Line,
+			InApp:    strings.HasPrefix(module, "github.com/sipeed/picoclaw"),
+		})
+		if !more {
+			break
+		}
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out
+}
+
+func splitFunction(name string) (string, string) {
+	slash := strings.LastIndex(name, "/")
+	dot := strings.Index(name[slash+1:], ".")
+	if dot < 0 {
+		return "", name
+	}
+	return name[:slash+1+dot], name[slash+2+dot:]
+}
+
+func fileName(path string) string {
+	if idx := strings.Index(path, "/pkg/"); idx >= 0 {
+		return path[idx+1:]
+	}
+	if idx := strings.Index(path, "/cmd/"); idx >= 0 {
+		return path[idx+1:]
+	}
+	return path
+}
+
+func (r *reporter) enqueue(ev *event) {
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+	header, _ := json.Marshal(map[string]string{
+		"event_id": ev.EventID,
+		"sent_at":  ev.Timestamp,
+	})
+
+	var envelope bytes.Buffer
+	envelope.Write(header)
+	envelope.WriteString("\n")
+	fmt.Fprintf(&envelope, `{"type":"event","length":%d}`, len(payload))
+	envelope.WriteString("\n")
+	envelope.Write(payload)
+	envelope.WriteString("\n")
+
+	r.pending.Add(1)
+	select {
+	case r.queue <- envelope.Bytes():
+	default:
+		r.pending.Done()
+	}
+}
+
+func (r *reporter) run() {
+	for envelope := range r.queue {
+		if err := r.send(envelope); err != nil {
+			logger.WarnCF("reporting", "Failed to send error report", map[string]any{"error": err.Error()})
+		}
+		r.pending.Done()
+	}
+}
+
+func (r *reporter) send(envelope []byte) error {
+	req, err := http.NewRequest(http.MethodPost, r.endpoint, bytes.NewReader(envelope))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-sentry-envelope")
+	req.Header.Set("X-Sentry-Auth", fmt.Sprintf(
+		"Sentry sentry_version=7, sentry_client=picoclaw/%s, sentry_key=%s", r.cfg.Release, r.authKey))
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("server returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package reporting
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sentryServer struct {
+	mu     sync.Mutex
+	paths  []string
+	auth   []string
+	events []map[string]any
+}
+
+func newSentryServer(t *testing.T) (*sentryServer, *httptest.Server) {
+	s := &sentryServer{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		scanner := bufio.NewScanner(bytes.NewReader(body))
+		var lines [][]byte
+		for scanner.Scan() {
+			lines = append(lines, append([]byte(nil), scanner.Bytes()...))
+		}
+		require.Len(t, lines, 3, "envelope must have header, item header and payload")
+
+		var ev map[string]any
+		require.NoError(t, json.Unmarshal(lines[2], &ev))
+
+		s.mu.Lock()
+		s.paths = append(s.paths, r.URL.Path)
+		s.auth = append(s.auth, r.Header.Get("X-Sentry-Auth"))
+		s.events = append(s.events, ev)
+		s.mu.Unlock()
+	}))
+	t.Cleanup(func() {
+		active.Store(nil)
+		srv.Close()
+	})
+	return s, srv
+}
+
+func TestParseDSN(t *testing.T) {
+	endpoint, key, err := parseDSN("https://abc123@o1.ingest.sentry.io/4505")
+	require.NoError(t, err)
+	assert.Equal(t, "https://o1.ingest.sentry.io/api/4505/envelope/", endpoint)
+	assert.Equal(t, "abc123", key)
+
+	endpoint, _, err = parseDSN("https://abc@glitchtip.example.com/prefix/7/")
+	require.NoError(t, err)
+	assert.Equal(t, "https://glitchtip.example.com/prefix/api/7/envelope/", endpoint)
+
+	_, _, err = parseDSN("https://glitchtip.example.com/7")
+	assert.Error(t, err)
+	_, _, err = parseDSN("https://abc@glitchtip.example.com/")
+	assert.Error(t, err)
+}
+
+func TestCaptureErrorTagsAndThrottle(t *testing.T) {
+	s, srv := newSentryServer(t)
+	dsn := strings.Replace(srv.URL, "http://", "http://pubkey@", 1) + "/42"
+	require.NoError(t, Init(Config{DSN: dsn, Release: "1.2.3", DeviceID: "dev-1", Environment: "field"}))
+
+	err := errors.New("state save failed")
+	CaptureError("state", err, map[string]any{"file": "state.json"})
+	CaptureError("state", err, nil) // throttled duplicate
+	Flush(5 * time.Second)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	require.Len(t, s.events, 1)
+	assert.Equal(t, "/api/42/envelope/", s.paths[0])
+	assert.Contains(t, s.auth[0], "sentry_key=pubkey")
+
+	ev := s.events[0]
+	assert.Equal(t, "error", ev["level"])
+	assert.Equal(t, "picoclaw@1.2.3", ev["release"])
+	assert.Equal(t, "field", ev["environment"])
+	tags := ev["tags"].(map[string]any)
+	assert.Equal(t, "dev-1", tags["device_id"])
+	assert.Equal(t, "1.2.3", tags["version"])
+	assert.Equal(t, "state", tags["component"])
+
+	exc := ev["exception"].(map[string]any)["values"].([]any)[0].(map[string]any)
+	assert.Equal(t, "state save failed", exc["value"])
+	frames := exc["stacktrace"].(map[string]any)["frames"].([]any)
+	last := frames[len(frames)-1].(map[string]any)
+	assert.Equal(t, "TestCaptureErrorTagsAndThrottle", last["function"])
+}
+
+func TestRecoverReportsAndRepanics(t *testing.T) {
+	s, srv := newSentryServer(t)
+	dsn := strings.Replace(srv.URL, "http://", "http://pubkey@", 1) + "/1"
+	require.NoError(t, Init(Config{DSN: dsn}))
+
+	assert.PanicsWithValue(t, "boom", func() {
+		defer Recover("agent")
+		panic("boom")
+	})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	require.Len(t, s.events, 1)
+	assert.Equal(t, "fatal", s.events[0]["level"])
+}
+
+func TestDisabledIsNoop(t *testing.T) {
+	active.Store(nil)
+	CaptureError("agent", errors.New("ignored"), nil)
+	CapturePanic("agent", "ignored")
+	Flush(time.Millisecond)
+}
@@ -0,0 +1,82 @@
+// Package sdnotify implements the systemd service notification protocol, so
+// the gateway can run as a Type=notify unit with a watchdog.
+//
+// Outside systemd NOTIFY_SOCKET is unset and every call is a no-op.
+package sdnotify
+
+import (
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// States understood by systemd.
+const (
+	Ready    = "READY=1"
+	Stopping = "STOPPING=1"
+	Watchdog = "WATCHDOG=1"
+)
+
+// Notify sends state to the service manager. It reports whether a manager
+// was listening; without NOTIFY_SOCKET it returns false and no error.
+func Notify(state string) (bool, error) {
+	path := os.Getenv("NOTIFY_SOCKET")
+	if path == "" {
+		return false, nil
+	}
+	if path[0] == '@' {
+		path = "\x00" + path[1:] // abstract namespace
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Status sends a free-form status line, shown by systemctl status.
+func Status(msg string) (bool, error) {
+	return Notify("STATUS=" + msg)
+}
+
+// WatchdogInterval returns the unit's WatchdogSec, or zero when the watchdog
+// is disabled or meant for another process.
+func WatchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// RunWatchdog sends a heartbeat at half the watchdog interval while healthy
+// reports true, until ctx is done. When healthy stays false for a whole
+// interval, systemd considers the service hung and restarts it. It returns
+// at once if the watchdog is disabled.
+func RunWatchdog(ctx context.Context, healthy func() bool) {
+	interval := WatchdogInterval()
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if healthy() {
+				Notify(Watchdog)
+			}
+		}
+	}
+}
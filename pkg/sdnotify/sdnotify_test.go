@@ -0,0 +1,96 @@
+package sdnotify
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func listen(t *testing.T) *net.UnixConn {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	t.Setenv("NOTIFY_SOCKET", path)
+	return conn
+}
+
+func read(t *testing.T, conn *net.UnixConn) string {
+	t.Helper()
+	buf := make([]byte, 256)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, err := conn.Read(buf)
+	require.NoError(t, err)
+	return string(buf[:n])
+}
+
+func TestNotifyWithoutSystemd(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	ok, err := Notify(Ready)
+	assert.False(t, ok)
+	assert.NoError(t, err)
+}
+
+func TestNotifySendsState(t *testing.T) {
+	conn := listen(t)
+
+	ok, err := Notify(Ready)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "READY=1", read(t, conn))
+
+	_, err = Status("serving 2 channels")
+	require.NoError(t, err)
+	assert.Equal(t, "STATUS=serving 2 channels", read(t, conn))
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	t.Setenv("WATCHDOG_USEC", "")
+	assert.Zero(t, WatchdogInterval())
+
+	t.Setenv("WATCHDOG_USEC", "30000000")
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+	assert.Equal(t, 30*time.Second, WatchdogInterval())
+
+	t.Setenv("WATCHDOG_PID", "1")
+	assert.Zero(t, WatchdogInterval(), "meant for another process")
+}
+
+func TestRunWatchdogOnlyWhileHealthy(t *testing.T) {
+	conn := listen(t)
+	t.Setenv("WATCHDOG_USEC", "20000")
+	t.Setenv("WATCHDOG_PID", "")
+
+	var healthy atomic.Bool
+	healthy.Store(true)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		RunWatchdog(ctx, healthy.Load)
+		close(done)
+	}()
+
+	assert.Equal(t, "WATCHDOG=1", read(t, conn))
+
+	// Heartbeats come every 10ms; once unhealthy, at most one already in
+	// flight arrives and then none for several intervals.
+	healthy.Store(false)
+	buf := make([]byte, 64)
+	conn.SetReadDeadline(time.Now().Add(15 * time.Millisecond))
+	conn.Read(buf)
+	conn.SetReadDeadline(time.Now().Add(60 * time.Millisecond))
+	_, err := conn.Read(buf)
+	assert.Error(t, err, "no heartbeat while unhealthy")
+
+	cancel()
+	<-done
+}
@@ -1,13 +1,11 @@
 package session
 
 import (
-	"encoding/json"
-	"os"
-	"path/filepath"
-	"strings"
+	"sort"
 	"sync"
 	"time"
 
+	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/providers"
 )
 
@@ -15,6 +13,7 @@ type Session struct {
 	Key      string              `json:"key"`
 	Messages []providers.Message `json:"messages"`
 	Summary  string              `json:"summary,omitempty"`
+	Debug    bool                `json:"debug,omitempty"` // verbose tracing for this session only
 	Created  time.Time           `json:"created"`
 	Updated  time.Time           `json:"updated"`
 }
@@ -22,18 +21,34 @@ type Session struct {
 type SessionManager struct {
 	sessions map[string]*Session
 	mu       sync.RWMutex
-	storage  string
+	store    Store
 }
 
+// NewSessionManager keeps sessions as JSON files in the storage directory,
+// or only in memory when storage is empty.
 func NewSessionManager(storage string) *SessionManager {
+	if storage == "" {
+		return NewSessionManagerWithStore(nil)
+	}
+	return NewSessionManagerWithStore(NewFileStore(storage))
+}
+
+// NewSessionManagerWithStore loads the sessions in store and saves changes
+// back to it. A nil store keeps sessions in memory only.
+func NewSessionManagerWithStore(store Store) *SessionManager {
 	sm := &SessionManager{
 		sessions: make(map[string]*Session),
-		storage:  storage,
+		store:    store,
 	}
 
-	if storage != "" {
-		os.MkdirAll(storage, 0o755)
-		sm.loadSessions()
+	if store != nil {
+		sessions, err := store.Load()
+		if err != nil {
+			logger.WarnCF("session", "Failed to load sessions", map[string]any{"error": err.Error()})
+		}
+		for _, session := range sessions {
+			sm.sessions[session.Key] = session
+		}
 	}
 
 	return sm
@@ -100,6 +115,37 @@ func (sm *SessionManager) GetHistory(key string) []providers.Message {
 	return history
 }
 
+// Info describes a session without its messages.
+type Info struct {
+	Key      string    `json:"key"`
+	Messages int       `json:"messages"`
+	Created  time.Time `json:"created"`
+	Updated  time.Time `json:"updated"`
+}
+
+// List describes the sessions, most recently updated first.
+func (sm *SessionManager) List() []Info {
+	sm.mu.RLock()
+	infos := make([]Info, 0, len(sm.sessions))
+	for _, session := range sm.sessions {
+		infos = append(infos, Info{
+			Key:      session.Key,
+			Messages: len(session.Messages),
+			Created:  session.Created,
+			Updated:  session.Updated,
+		})
+	}
+	sm.mu.RUnlock()
+
+	sort.Slice(infos, func(i, j int) bool {
+		if !infos[i].Updated.Equal(infos[j].Updated) {
+			return infos[i].Updated.After(infos[j].Updated)
+		}
+		return infos[i].Key < infos[j].Key
+	})
+	return infos
+}
+
 func (sm *SessionManager) GetSummary(key string) string {
 	sm.mu.RLock()
 	defer sm.mu.RUnlock()
@@ -122,6 +168,34 @@ func (sm *SessionManager) SetSummary(key string, summary string) {
 	}
 }
 
+// SetDebug turns per-session debug tracing on or off, creating the session
+// if needed.
+func (sm *SessionManager) SetDebug(key string, on bool) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	session, ok := sm.sessions[key]
+	if !ok {
+		session = &Session{
+			Key:      key,
+			Messages: []providers.Message{},
+			Created:  time.Now(),
+		}
+		sm.sessions[key] = session
+	}
+	session.Debug = on
+	session.Updated = time.Now()
+}
+
+// IsDebug reports whether debug tracing is on for the session.
+func (sm *SessionManager) IsDebug(key string) bool {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	session, ok := sm.sessions[key]
+	return ok && session.Debug
+}
+
 func (sm *SessionManager) TruncateHistory(key string, keepLast int) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
@@ -145,31 +219,12 @@ func (sm *SessionManager) TruncateHistory(key string, keepLast int) {
 	session.Updated = time.Now()
 }
 
-// sanitizeFilename converts a session key into a cross-platform safe filename.
-// Session keys use "channel:chatID" (e.g. "telegram:123456") but ':' is the
-// volume separator on Windows, so filepath.Base would misinterpret the key.
-// We replace it with '_'. The original key is preserved inside the JSON file,
-// so loadSessions still maps back to the right in-memory key.
-func sanitizeFilename(key string) string {
-	return strings.ReplaceAll(key, ":", "_")
-}
-
 func (sm *SessionManager) Save(key string) error {
-	if sm.storage == "" {
+	if sm.store == nil {
 		return nil
 	}
 
-	filename := sanitizeFilename(key)
-
-	// filepath.IsLocal rejects empty names, "..", absolute paths, and
-	// OS-reserved device names (NUL, COM1 … on Windows).
-	// The extra checks reject "." and any directory separators so that
-	// the session file is always written directly inside sm.storage.
-	if filename == "." || !filepath.IsLocal(filename) || strings.ContainsAny(filename, `/\`) {
-		return os.ErrInvalid
-	}
-
-	// Snapshot under read lock, then perform slow file I/O after unlock.
+	// Snapshot under read lock, then let the store do slow I/O after unlock.
 	sm.mu.RLock()
 	stored, ok := sm.sessions[key]
 	if !ok {
@@ -180,6 +235,7 @@ func (sm *SessionManager) Save(key string) error {
 	snapshot := Session{
 		Key:     stored.Key,
 		Summary: stored.Summary,
+		Debug:   stored.Debug,
 		Created: stored.Created,
 		Updated: stored.Updated,
 	}
@@ -191,78 +247,21 @@ func (sm *SessionManager) Save(key string) error {
 	}
 	sm.mu.RUnlock()
 
-	data, err := json.MarshalIndent(snapshot, "", "  ")
-	if err != nil {
-		return err
-	}
-
-	sessionPath := filepath.Join(sm.storage, filename+".json")
-	tmpFile, err := os.CreateTemp(sm.storage, "session-*.tmp")
-	if err != nil {
-		return err
-	}
-
-	tmpPath := tmpFile.Name()
-	cleanup := true
-	defer func() {
-		if cleanup {
-			_ = os.Remove(tmpPath)
-		}
-	}()
-
-	if _, err := tmpFile.Write(data); err != nil {
-		_ = tmpFile.Close()
-		return err
-	}
-	if err := tmpFile.Chmod(0o644); err != nil {
-		_ = tmpFile.Close()
-		return err
-	}
-	if err := tmpFile.Sync(); err != nil {
-		_ = tmpFile.Close()
-		return err
-	}
-	if err := tmpFile.Close(); err != nil {
-		return err
-	}
-
-	if err := os.Rename(tmpPath, sessionPath); err != nil {
-		return err
-	}
-	cleanup = false
-	return nil
+	return sm.store.Save(&snapshot)
 }
 
-func (sm *SessionManager) loadSessions() error {
-	files, err := os.ReadDir(sm.storage)
-	if err != nil {
-		return err
-	}
-
-	for _, file := range files {
-		if file.IsDir() {
-			continue
-		}
-
-		if filepath.Ext(file.Name()) != ".json" {
-			continue
-		}
-
-		sessionPath := filepath.Join(sm.storage, file.Name())
-		data, err := os.ReadFile(sessionPath)
-		if err != nil {
-			continue
-		}
-
-		var session Session
-		if err := json.Unmarshal(data, &session); err != nil {
-			continue
-		}
+// Delete removes a session from memory and from the store, and reports
+// whether it existed.
+func (sm *SessionManager) Delete(key string) (bool, error) {
+	sm.mu.Lock()
+	_, ok := sm.sessions[key]
+	delete(sm.sessions, key)
+	sm.mu.Unlock()
 
-		sm.sessions[session.Key] = &session
+	if sm.store == nil {
+		return ok, nil
 	}
-
-	return nil
+	return ok, sm.store.Delete(key)
 }
 
 // SetHistory updates the messages of a session.
@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestSanitizeFilename(t *testing.T) {
@@ -72,3 +73,72 @@ func TestSave_RejectsPathTraversal(t *testing.T) {
 		}
 	}
 }
+
+func TestSetDebug_PersistsAcrossReload(t *testing.T) {
+	tmpDir := t.TempDir()
+	sm := NewSessionManager(tmpDir)
+
+	key := "telegram:123456"
+	if sm.IsDebug(key) {
+		t.Fatal("debug should be off for a new session")
+	}
+	sm.SetDebug(key, true)
+	if err := sm.Save(key); err != nil {
+		t.Fatalf("Save(%q) failed: %v", key, err)
+	}
+
+	sm2 := NewSessionManager(tmpDir)
+	if !sm2.IsDebug(key) {
+		t.Fatal("debug should survive a reload")
+	}
+	sm2.SetDebug(key, false)
+	if sm2.IsDebug(key) {
+		t.Fatal("debug should be off after SetDebug(false)")
+	}
+}
+
+func TestList(t *testing.T) {
+	sm := NewSessionManager("")
+	sm.AddMessage("api:old", "user", "hello")
+	sm.AddMessage("api:old", "assistant", "hi")
+	time.Sleep(time.Millisecond)
+	sm.AddMessage("telegram:1", "user", "receipt")
+
+	infos := sm.List()
+	if len(infos) != 2 {
+		t.Fatalf("List() returned %d sessions, want 2", len(infos))
+	}
+	if infos[0].Key != "telegram:1" || infos[1].Key != "api:old" {
+		t.Errorf("List() order = %q, %q; want most recent first", infos[0].Key, infos[1].Key)
+	}
+	if infos[1].Messages != 2 {
+		t.Errorf("api:old has %d messages, want 2", infos[1].Messages)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	tmpDir := t.TempDir()
+	sm := NewSessionManager(tmpDir)
+
+	key := "telegram:123456"
+	sm.AddMessage(key, "user", "hello")
+	if err := sm.Save(key); err != nil {
+		t.Fatalf("Save(%q) failed: %v", key, err)
+	}
+
+	if ok, err := sm.Delete(key); err != nil || !ok {
+		t.Fatalf("Delete(%q) = %v, %v; want true, nil", key, ok, err)
+	}
+	if history := sm.GetHistory(key); len(history) != 0 {
+		t.Errorf("expected no history after Delete, got %d messages", len(history))
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "telegram_123456.json")); !os.IsNotExist(err) {
+		t.Errorf("expected the session file to be removed, stat error: %v", err)
+	}
+	if history := NewSessionManager(tmpDir).GetHistory(key); len(history) != 0 {
+		t.Errorf("expected the session to stay deleted after reload, got %d messages", len(history))
+	}
+	if ok, err := sm.Delete(key); err != nil || ok {
+		t.Errorf("Delete of a missing session = %v, %v; want false, nil", ok, err)
+	}
+}
@@ -0,0 +1,137 @@
+package session
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Store persists sessions for a SessionManager. The manager keeps every
+// session in memory; the store is read once at startup and written on Save.
+type Store interface {
+	// Load returns all stored sessions.
+	Load() ([]*Session, error)
+	// Save writes one session, replacing any stored copy.
+	Save(session *Session) error
+	// Delete removes a stored session. A missing one is not an error.
+	Delete(key string) error
+}
+
+// FileStore keeps each session as a JSON file in a directory.
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore returns a store in dir, creating it if needed.
+func NewFileStore(dir string) *FileStore {
+	os.MkdirAll(dir, 0o755)
+	return &FileStore{dir: dir}
+}
+
+// sanitizeFilename converts a session key into a cross-platform safe filename.
+// Session keys use "channel:chatID" (e.g. "telegram:123456") but ':' is the
+// volume separator on Windows, so filepath.Base would misinterpret the key.
+// We replace it with '_'. The original key is preserved inside the JSON file,
+// so Load still maps back to the right in-memory key.
+func sanitizeFilename(key string) string {
+	return strings.ReplaceAll(key, ":", "_")
+}
+
+func (fs *FileStore) Save(session *Session) error {
+	filename := sanitizeFilename(session.Key)
+
+	// filepath.IsLocal rejects empty names, "..", absolute paths, and
+	// OS-reserved device names (NUL, COM1 … on Windows).
+	// The extra checks reject "." and any directory separators so that
+	// the session file is always written directly inside fs.dir.
+	if filename == "." || !filepath.IsLocal(filename) || strings.ContainsAny(filename, `/\`) {
+		return os.ErrInvalid
+	}
+
+	data, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	sessionPath := filepath.Join(fs.dir, filename+".json")
+	tmpFile, err := os.CreateTemp(fs.dir, "session-*.tmp")
+	if err != nil {
+		return err
+	}
+
+	tmpPath := tmpFile.Name()
+	cleanup := true
+	defer func() {
+		if cleanup {
+			_ = os.Remove(tmpPath)
+		}
+	}()
+
+	if _, err := tmpFile.Write(data); err != nil {
+		_ = tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Chmod(0o644); err != nil {
+		_ = tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Sync(); err != nil {
+		_ = tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpPath, sessionPath); err != nil {
+		return err
+	}
+	cleanup = false
+	return nil
+}
+
+func (fs *FileStore) Delete(key string) error {
+	filename := sanitizeFilename(key)
+	if filename == "." || !filepath.IsLocal(filename) || strings.ContainsAny(filename, `/\`) {
+		return os.ErrInvalid
+	}
+	err := os.Remove(filepath.Join(fs.dir, filename+".json"))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (fs *FileStore) Load() ([]*Session, error) {
+	files, err := os.ReadDir(fs.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions []*Session
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+
+		if filepath.Ext(file.Name()) != ".json" {
+			continue
+		}
+
+		sessionPath := filepath.Join(fs.dir, file.Name())
+		data, err := os.ReadFile(sessionPath)
+		if err != nil {
+			continue
+		}
+
+		var session Session
+		if err := json.Unmarshal(data, &session); err != nil {
+			continue
+		}
+
+		sessions = append(sessions, &session)
+	}
+
+	return sessions, nil
+}
@@ -0,0 +1,271 @@
+// Package setup serves the first-boot wizard at /setup. On a device with no
+// config file it asks for the model provider, its API key and the owner's
+// chat channel in a browser, then writes a validated config, so a fresh
+// device needs neither SSH nor hand-edited JSON. The form is only accepted
+// with the one-time setup code printed on the device's console.
+package setup
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	_ "embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+const (
+	// maxForm bounds the wizard's form.
+	maxForm = 64 << 10
+	// maxCodeAttempts is how many wrong setup codes the wizard takes before
+	// it refuses every submission until it restarts with a new code.
+	maxCodeAttempts = 10
+	// codeAlphabet leaves out letters and digits that are easily confused.
+	codeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+)
+
+//go:embed setup.html
+var page string
+
+var pageTmpl = template.Must(template.New("setup").Parse(page))
+
+// channels are the owner channels the wizard offers.
+var channels = []string{"telegram", "discord", "slack"}
+
+// Wizard writes the config file at path from the answers to its form. Its
+// pairing code is the one the gateway should accept once it runs with the
+// new config.
+type Wizard struct {
+	path      string
+	code      string
+	setupCode string
+
+	mu       sync.Mutex
+	attempts int           // wrong setup codes so far
+	done     chan struct{} // closed once the config is written
+}
+
+// New returns a wizard that writes the config file at path.
+func New(path string) *Wizard {
+	return &Wizard{path: path, code: pairingCode(), setupCode: setupCode(), done: make(chan struct{})}
+}
+
+// SetupCode returns the code the form must be submitted with. Print it
+// where only someone with the device at hand sees it, like its console.
+func (w *Wizard) SetupCode() string {
+	return w.setupCode
+}
+
+// PairingCode returns the code clients pair with once the gateway restarts.
+func (w *Wizard) PairingCode() string {
+	return w.code
+}
+
+// Done is closed once the config is written.
+func (w *Wizard) Done() <-chan struct{} {
+	return w.done
+}
+
+// Handler serves the wizard at /setup, redirects / to it and answers /health
+// with status "setup".
+func (w *Wizard) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /setup", func(rw http.ResponseWriter, r *http.Request) {
+		w.render(rw, http.StatusOK, view{Form: url.Values{"setup_code": {r.URL.Query().Get("code")}}})
+	})
+	mux.HandleFunc("POST /setup", w.submit)
+	mux.HandleFunc("GET /health", func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(rw, `{"status":"setup"}`)
+	})
+	mux.HandleFunc("GET /{$}", func(rw http.ResponseWriter, r *http.Request) {
+		http.Redirect(rw, r, "/setup", http.StatusFound)
+	})
+	return mux
+}
+
+// view is what the page shows: the form with the values entered so far and
+// an error, or the pairing code once the config is written.
+type view struct {
+	Models   []config.ModelConfig
+	Channels []string
+	Form     url.Values
+	Error    string
+	Code     string
+	Path     string
+}
+
+func (w *Wizard) render(rw http.ResponseWriter, status int, v view) {
+	for _, model := range config.DefaultConfig().ModelList {
+		if offered(model) {
+			v.Models = append(v.Models, model)
+		}
+	}
+	v.Channels = channels
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	rw.WriteHeader(status)
+	if err := pageTmpl.Execute(rw, v); err != nil {
+		logger.WarnCF("setup", "Failed to render setup page", map[string]any{"error": err.Error()})
+	}
+}
+
+func (w *Wizard) submit(rw http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(rw, r.Body, maxForm)
+	if err := r.ParseForm(); err != nil {
+		w.render(rw, http.StatusBadRequest, view{Form: url.Values{}, Error: "invalid form"})
+		return
+	}
+	form := r.PostForm
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.attempts >= maxCodeAttempts {
+		w.render(rw, http.StatusTooManyRequests, view{Form: form,
+			Error: "too many wrong setup codes; restart picoclaw for a new one"})
+		return
+	}
+	if !w.validSetupCode(form.Get("setup_code")) {
+		w.attempts++
+		logger.WarnCF("setup", "Setup form sent with a wrong setup code",
+			map[string]any{"remote_addr": r.RemoteAddr, "attempts": w.attempts})
+		w.render(rw, http.StatusForbidden, view{Form: form, Error: "wrong setup code"})
+		return
+	}
+	// The wizard only ever writes a config where there is none.
+	if _, err := os.Stat(w.path); !errors.Is(err, os.ErrNotExist) {
+		w.render(rw, http.StatusConflict, view{Form: form, Error: "picoclaw is already set up"})
+		return
+	}
+	cfg, err := Build(form)
+	if err != nil {
+		w.render(rw, http.StatusBadRequest, view{Form: form, Error: err.Error()})
+		return
+	}
+	if err := config.SaveConfig(w.path, cfg); err != nil {
+		w.render(rw, http.StatusInternalServerError, view{Form: form, Error: err.Error()})
+		return
+	}
+	logger.InfoCF("setup", "Config written by the setup wizard", map[string]any{
+		"path":    w.path,
+		"model":   cfg.Agents.Defaults.Model,
+		"channel": form.Get("channel"),
+	})
+	w.render(rw, http.StatusOK, view{Code: w.code, Path: w.path})
+	close(w.done)
+}
+
+// Build returns the default config with the wizard's answers applied: the
+// model to use and its API key and base, and the chat channel the owner
+// talks to picoclaw on. Pairing is required, so only paired clients can
+// call the API. The config is validated as the gateway would.
+func Build(form url.Values) (*config.Config, error) {
+	cfg := config.DefaultConfig()
+
+	name := form.Get("model")
+	var model *config.ModelConfig
+	for i := range cfg.ModelList {
+		if cfg.ModelList[i].ModelName == name && offered(cfg.ModelList[i]) {
+			model = &cfg.ModelList[i]
+			break
+		}
+	}
+	if model == nil {
+		return nil, errors.New("choose a model")
+	}
+	if key := strings.TrimSpace(form.Get("api_key")); key != "" {
+		model.APIKey = key
+	}
+	if base := strings.TrimSpace(form.Get("api_base")); base != "" {
+		model.APIBase = base
+	}
+	protocol, _ := providers.ExtractProtocol(model.Model)
+	if model.APIKey == "" && !providers.IsLocalProtocol(protocol) {
+		return nil, errors.New("the API key is required")
+	}
+	cfg.Agents.Defaults.Model = name
+
+	token := strings.TrimSpace(form.Get("channel_token"))
+	var owner config.FlexibleStringSlice
+	if id := strings.TrimSpace(form.Get("owner_id")); id != "" {
+		owner = config.FlexibleStringSlice{id}
+	}
+	channel := form.Get("channel")
+	if channel != "" && token == "" {
+		return nil, fmt.Errorf("the %s bot token is required", channel)
+	}
+	switch channel {
+	case "":
+	case "telegram":
+		cfg.Channels.Telegram = config.TelegramConfig{Enabled: true, Token: token, AllowFrom: owner}
+	case "discord":
+		cfg.Channels.Discord = config.DiscordConfig{Enabled: true, Token: token, AllowFrom: owner}
+	case "slack":
+		appToken := strings.TrimSpace(form.Get("slack_app_token"))
+		if appToken == "" {
+			return nil, errors.New("the slack app token is required")
+		}
+		cfg.Channels.Slack = config.SlackConfig{Enabled: true, BotToken: token, AppToken: appToken, AllowFrom: owner}
+	default:
+		return nil, fmt.Errorf("unknown channel %q", channel)
+	}
+	cfg.Gateway.RequirePairing = true
+
+	if err := cfg.ValidateModelList(); err != nil {
+		return nil, err
+	}
+	if _, _, err := providers.CreateProvider(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// offered reports whether the wizard offers model. Models signed in to with
+// OAuth are left to picoclaw auth login.
+func offered(model config.ModelConfig) bool {
+	return model.AuthMethod == ""
+}
+
+// validSetupCode reports whether code is the wizard's setup code, ignoring
+// case, spaces and dashes.
+func (w *Wizard) validSetupCode(code string) bool {
+	code = strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(code))
+	want := strings.ReplaceAll(w.setupCode, "-", "")
+	return subtle.ConstantTimeCompare([]byte(code), []byte(want)) == 1
+}
+
+// setupCode returns a random code of eight letters and digits, as XXXX-XXXX.
+func setupCode() string {
+	b := make([]byte, 8)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(codeAlphabet))))
+		if err != nil {
+			panic(err) // crypto/rand doesn't fail on supported platforms
+		}
+		b[i] = codeAlphabet[n.Int64()]
+	}
+	return string(b[:4]) + "-" + string(b[4:])
+}
+
+// pairingCode returns a random six-digit code, like the gateway's.
+func pairingCode() string {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "000000"
+	}
+	return fmt.Sprintf("%06d", n.Int64())
+}
@@ -0,0 +1,125 @@
+package setup
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestBuild(t *testing.T) {
+	cfg, err := Build(url.Values{
+		"model":         {"gpt-5.2"},
+		"api_key":       {" sk-test "},
+		"channel":       {"telegram"},
+		"channel_token": {"123:abc"},
+		"owner_id":      {"42"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "gpt-5.2", cfg.Agents.Defaults.Model)
+	model, err := cfg.GetModelConfig("gpt-5.2")
+	require.NoError(t, err)
+	assert.Equal(t, "sk-test", model.APIKey)
+	assert.True(t, cfg.Channels.Telegram.Enabled)
+	assert.Equal(t, "123:abc", cfg.Channels.Telegram.Token)
+	assert.Equal(t, config.FlexibleStringSlice{"42"}, cfg.Channels.Telegram.AllowFrom)
+	assert.True(t, cfg.Gateway.RequirePairing)
+
+	tests := []struct {
+		form url.Values
+		err  string
+	}{
+		{url.Values{"model": {"nope"}}, "choose a model"},
+		{url.Values{"model": {"gemini-flash"}}, "choose a model"},
+		{url.Values{"model": {"gpt-5.2"}}, "the API key is required"},
+		{url.Values{"model": {"gpt-5.2"}, "api_key": {"k"}, "channel": {"discord"}}, "discord bot token is required"},
+		{url.Values{"model": {"gpt-5.2"}, "api_key": {"k"}, "channel": {"slack"}, "channel_token": {"x"}},
+			"slack app token is required"},
+		{url.Values{"model": {"gpt-5.2"}, "api_key": {"k"}, "channel": {"irc"}, "channel_token": {"x"}},
+			`unknown channel "irc"`},
+	}
+	for _, tt := range tests {
+		_, err := Build(tt.form)
+		assert.ErrorContains(t, err, tt.err, tt.form.Encode())
+	}
+}
+
+func TestWizard(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	wizard := New(path)
+	assert.Len(t, wizard.PairingCode(), 6)
+	h := wizard.Handler()
+
+	serve := func(method, target string, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve(http.MethodGet, "/", nil)
+	assert.Equal(t, http.StatusFound, rec.Code)
+	rec = serve(http.MethodGet, "/setup", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `<option value="claude-sonnet-4.6">`)
+	assert.NotContains(t, rec.Body.String(), `<option value="gemini-flash">`)
+
+	rec = serve(http.MethodGet, "/setup?code=ABCD-2345", nil)
+	assert.Contains(t, rec.Body.String(), `value="ABCD-2345"`)
+
+	form := url.Values{"model": {"gpt-5.2"}, "api_key": {"sk-test"}}
+	rec = serve(http.MethodPost, "/setup", form)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Body.String(), "wrong setup code")
+	assert.NoFileExists(t, path)
+
+	code := strings.ToLower(strings.ReplaceAll(wizard.SetupCode(), "-", " "))
+	rec = serve(http.MethodPost, "/setup", url.Values{"model": {"gpt-5.2"}, "setup_code": {code}})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "the API key is required")
+	assert.NoFileExists(t, path)
+
+	form.Set("setup_code", wizard.SetupCode())
+	rec = serve(http.MethodPost, "/setup", form)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), wizard.PairingCode())
+	select {
+	case <-wizard.Done():
+	default:
+		t.Fatal("wizard not done after writing the config")
+	}
+	cfg, err := config.LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, "gpt-5.2", cfg.Agents.Defaults.Model)
+
+	rec = serve(http.MethodPost, "/setup", form)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+}
+
+func TestWizard_LocksAfterWrongCodes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	wizard := New(path)
+	h := wizard.Handler()
+	serve := func(code string) int {
+		form := url.Values{"model": {"gpt-5.2"}, "api_key": {"sk-test"}, "setup_code": {code}}
+		req := httptest.NewRequest(http.MethodPost, "/setup", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	for range maxCodeAttempts {
+		assert.Equal(t, http.StatusForbidden, serve("WRONG-CODE"))
+	}
+	assert.Equal(t, http.StatusTooManyRequests, serve(wizard.SetupCode()))
+	assert.NoFileExists(t, path)
+}
@@ -0,0 +1,46 @@
+package skills
+
+// DefaultBusinessKey is the matrix entry applied to businesses without their own entry.
+const DefaultBusinessKey = "*"
+
+// EnablementMatrix maps business IDs to the skill names enabled for them.
+//
+// A business listed in the matrix only sees its own skills. Businesses without
+// an entry fall back to the "*" entry when present, otherwise to all skills.
+// Once the matrix has entries, runs without a business ID (CLI, direct chat
+// channels) only get the skills of the "*" entry.
+type EnablementMatrix map[string][]string
+
+// Allowed reports whether skill may be used by businessID.
+func (m EnablementMatrix) Allowed(businessID, skill string) bool {
+	if len(m) == 0 {
+		return true
+	}
+	enabled, ok := m[businessID]
+	if !ok || businessID == "" {
+		enabled, ok = m[DefaultBusinessKey]
+		if !ok {
+			return businessID != ""
+		}
+	}
+	for _, name := range enabled {
+		if name == skill || name == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter returns the subset of skills enabled for businessID.
+func (m EnablementMatrix) Filter(businessID string, all []SkillInfo) []SkillInfo {
+	if len(m) == 0 {
+		return all
+	}
+	filtered := make([]SkillInfo, 0, len(all))
+	for _, s := range all {
+		if m.Allowed(businessID, s.Name) {
+			filtered = append(filtered, s)
+		}
+	}
+	return filtered
+}
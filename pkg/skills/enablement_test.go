@@ -0,0 +1,39 @@
+package skills
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnablementMatrix_Allowed(t *testing.T) {
+	matrix := EnablementMatrix{
+		"biz-books": {"oluto"},
+		"biz-trial": {"oluto", "experimental"},
+		"*":         {"oluto", "weather"},
+	}
+
+	assert.True(t, matrix.Allowed("biz-books", "oluto"))
+	assert.False(t, matrix.Allowed("biz-books", "experimental"))
+	assert.True(t, matrix.Allowed("biz-trial", "experimental"))
+	assert.True(t, matrix.Allowed("biz-other", "weather"), "unlisted business uses default entry")
+	assert.False(t, matrix.Allowed("biz-other", "experimental"))
+	assert.True(t, matrix.Allowed("", "weather"), "runs without a business use the default entry")
+	assert.False(t, matrix.Allowed("", "experimental"))
+	assert.False(t, EnablementMatrix{"biz-books": {"oluto"}}.Allowed("", "oluto"),
+		"runs without a business get nothing without a default entry")
+
+	assert.True(t, EnablementMatrix(nil).Allowed("biz-books", "anything"))
+	assert.True(t, EnablementMatrix{"biz-books": {"oluto"}}.Allowed("biz-other", "anything"))
+}
+
+func TestEnablementMatrix_Filter(t *testing.T) {
+	all := []SkillInfo{{Name: "oluto"}, {Name: "experimental"}, {Name: "weather"}}
+	matrix := EnablementMatrix{"biz-books": {"oluto"}}
+
+	filtered := matrix.Filter("biz-books", all)
+	assert.Len(t, filtered, 1)
+	assert.Equal(t, "oluto", filtered[0].Name)
+	assert.Empty(t, matrix.Filter("", all))
+	assert.Len(t, EnablementMatrix(nil).Filter("", all), 3)
+}
@@ -0,0 +1,199 @@
+package skills
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventFileEnv is the environment variable that tells a skill script where to
+// append progress and follow-up events.
+const EventFileEnv = "PICOCLAW_EVENT_FILE"
+
+// Event types that hold or release an uploaded file (Message is its path) for
+// the invocation's business instead of being sent to the chat.
+const (
+	EventMediaHold    = "media_hold"
+	EventMediaRelease = "media_release"
+)
+
+// EventArtifact registers a file the skill created (Message is its path) as
+// an artifact and tells the chat its ID.
+const EventArtifact = "artifact"
+
+// eventRouteTTL is how long an invocation may keep emitting events.
+const eventRouteTTL = 24 * time.Hour
+
+// EventRoute records where events from one skill invocation are delivered.
+type EventRoute struct {
+	Skill      string    `json:"skill"`
+	Channel    string    `json:"channel"`
+	ChatID     string    `json:"chat_id"`
+	BusinessID string    `json:"business_id,omitempty"`
+	SessionKey string    `json:"session_key,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	Offset     int64     `json:"offset"` // bytes of the event file already delivered
+}
+
+// Event is a message emitted by a running skill.
+//
+// Skills append one event per line to $PICOCLAW_EVENT_FILE, either as JSON
+// ({"type": "progress", "message": "Imported 40 of 120 rows"}) or plain text.
+type Event struct {
+	Type    string     `json:"type"`
+	Message string     `json:"message"`
+	Route   EventRoute `json:"-"`
+}
+
+// EventQueue is a file-based queue of skill events under <workspace>/skill_events.
+// Each invocation gets <id>.route.json (delivery target) and <id>.jsonl (events).
+type EventQueue struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewEventQueue creates an event queue for the given workspace.
+func NewEventQueue(workspace string) *EventQueue {
+	return &EventQueue{dir: filepath.Join(workspace, "skill_events")}
+}
+
+// Open registers a route for a new invocation and returns the event file path
+// the skill should append to.
+func (q *EventQueue) Open(route EventRoute) (string, error) {
+	if err := os.MkdirAll(q.dir, 0o700); err != nil {
+		return "", fmt.Errorf("failed to create event directory: %w", err)
+	}
+
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate event id: %w", err)
+	}
+	id := hex.EncodeToString(b)
+
+	route.CreatedAt = time.Now().UTC()
+	route.Offset = 0
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if err := q.writeRoute(id, route); err != nil {
+		return "", err
+	}
+	eventsPath := filepath.Join(q.dir, id+".jsonl")
+	if err := os.WriteFile(eventsPath, nil, 0o600); err != nil {
+		return "", fmt.Errorf("failed to create event file: %w", err)
+	}
+	return eventsPath, nil
+}
+
+// Poll returns events appended since the last poll and removes expired invocations.
+func (q *EventQueue) Poll() ([]Event, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	routeFiles, err := filepath.Glob(filepath.Join(q.dir, "*.route.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var events []Event
+	for _, routeFile := range routeFiles {
+		id := strings.TrimSuffix(filepath.Base(routeFile), ".route.json")
+		route, err := q.readRoute(id)
+		if err != nil {
+			continue
+		}
+
+		newEvents, offset, err := q.readEvents(id, route)
+		if err != nil && !os.IsNotExist(err) {
+			continue
+		}
+		events = append(events, newEvents...)
+
+		if time.Since(route.CreatedAt) > eventRouteTTL && len(newEvents) == 0 {
+			os.Remove(routeFile)
+			os.Remove(filepath.Join(q.dir, id+".jsonl"))
+			continue
+		}
+		if offset != route.Offset {
+			route.Offset = offset
+			q.writeRoute(id, route)
+		}
+	}
+	return events, nil
+}
+
+// readEvents reads complete lines after the route's offset.
+func (q *EventQueue) readEvents(id string, route EventRoute) ([]Event, int64, error) {
+	f, err := os.Open(filepath.Join(q.dir, id+".jsonl"))
+	if err != nil {
+		return nil, route.Offset, err
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(route.Offset, io.SeekStart); err != nil {
+		return nil, route.Offset, err
+	}
+	data, err := io.ReadAll(io.LimitReader(f, 1<<20))
+	if err != nil {
+		return nil, route.Offset, err
+	}
+
+	// Only consume complete lines; a partial line is picked up on the next poll
+	end := bytes.LastIndexByte(data, '\n')
+	if end < 0 {
+		return nil, route.Offset, nil
+	}
+
+	var events []Event
+	for _, line := range strings.Split(string(data[:end]), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		event := Event{Type: "message", Message: line}
+		if strings.HasPrefix(line, "{") {
+			var parsed Event
+			if err := json.Unmarshal([]byte(line), &parsed); err == nil && parsed.Message != "" {
+				event = parsed
+				if event.Type == "" {
+					event.Type = "message"
+				}
+			}
+		}
+		event.Route = route
+		events = append(events, event)
+	}
+	return events, route.Offset + int64(end) + 1, nil
+}
+
+func (q *EventQueue) readRoute(id string) (EventRoute, error) {
+	var route EventRoute
+	data, err := os.ReadFile(filepath.Join(q.dir, id+".route.json"))
+	if err != nil {
+		return route, err
+	}
+	err = json.Unmarshal(data, &route)
+	return route, err
+}
+
+func (q *EventQueue) writeRoute(id string, route EventRoute) error {
+	data, err := json.Marshal(route)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event route: %w", err)
+	}
+	path := filepath.Join(q.dir, id+".route.json")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write event route: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,50 @@
+package skills
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventQueue_OpenAndPoll(t *testing.T) {
+	q := NewEventQueue(t.TempDir())
+
+	path, err := q.Open(EventRoute{Skill: "oluto", Channel: "telegram", ChatID: "42"})
+	require.NoError(t, err)
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	require.NoError(t, err)
+	_, err = f.WriteString("{\"type\":\"progress\",\"message\":\"Imported 40 of 120 rows\"}\nDone\npartial")
+	require.NoError(t, err)
+
+	events, err := q.Poll()
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, "progress", events[0].Type)
+	assert.Equal(t, "Imported 40 of 120 rows", events[0].Message)
+	assert.Equal(t, "message", events[1].Type)
+	assert.Equal(t, "Done", events[1].Message)
+	assert.Equal(t, "42", events[1].Route.ChatID)
+
+	// Delivered events are not repeated; the partial line completes later
+	_, err = f.WriteString(" line\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	events, err = q.Poll()
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "partial line", events[0].Message)
+
+	events, err = q.Poll()
+	require.NoError(t, err)
+	assert.Empty(t, events)
+}
+
+func TestEventQueue_PollEmpty(t *testing.T) {
+	events, err := NewEventQueue(t.TempDir()).Poll()
+	require.NoError(t, err)
+	assert.Empty(t, events)
+}
@@ -9,6 +9,8 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/sipeed/picoclaw/pkg/logger"
 )
@@ -21,15 +23,34 @@ const (
 )
 
 type SkillMetadata struct {
-	Name        string `json:"name"`
-	Description string `json:"description"`
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Schedule    *SkillSchedule `json:"schedule,omitempty"`
+}
+
+// SkillSchedule is a recurring run declared in a skill manifest, e.g.
+//
+//	schedule: "0 2 * * *"
+//	schedule_command: scripts/bank-sync.sh
+//	schedule_channel: telegram
+//	schedule_to: "123456"
+//
+// Command runs from the skill directory; Prompt runs as an agent turn instead.
+// Channel and To override the business's last active chat.
+type SkillSchedule struct {
+	Cron    string `json:"cron"`
+	Command string `json:"command,omitempty"`
+	Prompt  string `json:"prompt,omitempty"`
+	Channel string `json:"channel,omitempty"`
+	To      string `json:"to,omitempty"`
 }
 
 type SkillInfo struct {
-	Name        string `json:"name"`
-	Path        string `json:"path"`
-	Source      string `json:"source"`
-	Description string `json:"description"`
+	Name        string         `json:"name"`
+	Path        string         `json:"path"`
+	Source      string         `json:"source"`
+	Description string         `json:"description"`
+	Schedule    *SkillSchedule `json:"schedule,omitempty"`
 }
 
 func (info SkillInfo) validate() error {
@@ -58,6 +79,16 @@ type SkillsLoader struct {
 	workspaceSkills string // workspace skills (项目级别)
 	globalSkills    string // 全局 skills (~/.picoclaw/skills)
 	builtinSkills   string // 内置 skills
+
+	mu        sync.Mutex
+	metaCache map[string]cachedMetadata // by SKILL.md path
+}
+
+// cachedMetadata is a parsed manifest, reused until the file changes.
+type cachedMetadata struct {
+	modTime time.Time
+	size    int64
+	meta    *SkillMetadata
 }
 
 func NewSkillsLoader(workspace string, globalSkills string, builtinSkills string) *SkillsLoader {
@@ -66,6 +97,7 @@ func NewSkillsLoader(workspace string, globalSkills string, builtinSkills string
 		workspaceSkills: filepath.Join(workspace, "skills"),
 		globalSkills:    globalSkills, // ~/.picoclaw/skills
 		builtinSkills:   builtinSkills,
+		metaCache:       make(map[string]cachedMetadata),
 	}
 }
 
@@ -87,6 +119,7 @@ func (sl *SkillsLoader) ListSkills() []SkillInfo {
 						if metadata != nil {
 							info.Description = metadata.Description
 							info.Name = metadata.Name
+							info.Schedule = metadata.Schedule
 						}
 						if err := info.validate(); err != nil {
 							slog.Warn("invalid skill from workspace", "name", info.Name, "error", err)
@@ -127,6 +160,7 @@ func (sl *SkillsLoader) ListSkills() []SkillInfo {
 						if metadata != nil {
 							info.Description = metadata.Description
 							info.Name = metadata.Name
+							info.Schedule = metadata.Schedule
 						}
 						if err := info.validate(); err != nil {
 							slog.Warn("invalid skill from global", "name", info.Name, "error", err)
@@ -166,6 +200,7 @@ func (sl *SkillsLoader) ListSkills() []SkillInfo {
 						if metadata != nil {
 							info.Description = metadata.Description
 							info.Name = metadata.Name
+							info.Schedule = metadata.Schedule
 						}
 						if err := info.validate(); err != nil {
 							slog.Warn("invalid skill from builtin", "name", info.Name, "error", err)
@@ -226,7 +261,42 @@ func (sl *SkillsLoader) LoadSkillsForContext(skillNames []string) string {
 }
 
 func (sl *SkillsLoader) BuildSkillsSummary() string {
-	allSkills := sl.ListSkills()
+	return formatSkillsSummary(sl.ListSkills())
+}
+
+// BuildSkillsSummaryForBusiness builds the summary limited to skills the matrix enables for businessID.
+func (sl *SkillsLoader) BuildSkillsSummaryForBusiness(businessID string, matrix EnablementMatrix) string {
+	return formatSkillsSummary(matrix.Filter(businessID, sl.ListSkills()))
+}
+
+// BuildSkillsSummaryForRequest is BuildSkillsSummaryForBusiness with only the
+// limit skills most relevant to query described in full; the rest are listed
+// by name and location. A limit of zero describes every skill.
+func (sl *SkillsLoader) BuildSkillsSummaryForRequest(
+	businessID string,
+	matrix EnablementMatrix,
+	query string,
+	limit int,
+) string {
+	relevant, others := SelectRelevant(matrix.Filter(businessID, sl.ListSkills()), query, limit)
+	summary := formatSkillsSummary(relevant)
+	if len(others) == 0 {
+		return summary
+	}
+
+	lines := []string{"<other_skills>"}
+	for _, s := range others {
+		lines = append(lines, fmt.Sprintf("  <skill name=\"%s\" location=\"%s\"/>",
+			escapeXML(s.Name), escapeXML(s.Path)))
+	}
+	lines = append(lines, "</other_skills>")
+	if summary == "" {
+		return strings.Join(lines, "\n")
+	}
+	return summary + "\n" + strings.Join(lines, "\n")
+}
+
+func formatSkillsSummary(allSkills []SkillInfo) string {
 	if len(allSkills) == 0 {
 		return ""
 	}
@@ -250,7 +320,35 @@ func (sl *SkillsLoader) BuildSkillsSummary() string {
 	return strings.Join(lines, "\n")
 }
 
+// getSkillMetadata returns the manifest of the skill at skillPath, parsing
+// the file only when it changed since the last call.
 func (sl *SkillsLoader) getSkillMetadata(skillPath string) *SkillMetadata {
+	info, err := os.Stat(skillPath)
+	if err == nil {
+		sl.mu.Lock()
+		cached, ok := sl.metaCache[skillPath]
+		sl.mu.Unlock()
+		if ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+			meta := *cached.meta
+			return &meta
+		}
+	}
+
+	meta := sl.parseSkillMetadata(skillPath)
+	if meta != nil && info != nil {
+		sl.mu.Lock()
+		if sl.metaCache == nil {
+			sl.metaCache = make(map[string]cachedMetadata)
+		}
+		sl.metaCache[skillPath] = cachedMetadata{modTime: info.ModTime(), size: info.Size(), meta: meta}
+		sl.mu.Unlock()
+		copied := *meta
+		return &copied
+	}
+	return meta
+}
+
+func (sl *SkillsLoader) parseSkillMetadata(skillPath string) *SkillMetadata {
 	content, err := os.ReadFile(skillPath)
 	if err != nil {
 		logger.WarnCF("skills", "Failed to read skill metadata",
@@ -269,23 +367,35 @@ func (sl *SkillsLoader) getSkillMetadata(skillPath string) *SkillMetadata {
 	}
 
 	// Try JSON first (for backward compatibility)
-	var jsonMeta struct {
-		Name        string `json:"name"`
-		Description string `json:"description"`
-	}
+	var jsonMeta SkillMetadata
 	if err := json.Unmarshal([]byte(frontmatter), &jsonMeta); err == nil {
-		return &SkillMetadata{
-			Name:        jsonMeta.Name,
-			Description: jsonMeta.Description,
+		if jsonMeta.Schedule != nil && !jsonMeta.Schedule.valid() {
+			jsonMeta.Schedule = nil
 		}
+		return &jsonMeta
 	}
 
 	// Fall back to simple YAML parsing
 	yamlMeta := sl.parseSimpleYAML(frontmatter)
-	return &SkillMetadata{
+	meta := &SkillMetadata{
 		Name:        yamlMeta["name"],
 		Description: yamlMeta["description"],
 	}
+	if schedule := (&SkillSchedule{
+		Cron:    yamlMeta["schedule"],
+		Command: yamlMeta["schedule_command"],
+		Prompt:  yamlMeta["schedule_prompt"],
+		Channel: yamlMeta["schedule_channel"],
+		To:      yamlMeta["schedule_to"],
+	}); schedule.valid() {
+		meta.Schedule = schedule
+	}
+	return meta
+}
+
+// valid reports whether the schedule has an expression and something to run.
+func (s *SkillSchedule) valid() bool {
+	return s.Cron != "" && (s.Command != "" || s.Prompt != "")
 }
 
 // parseSimpleYAML parses simple key: value YAML format
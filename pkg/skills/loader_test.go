@@ -1,6 +1,8 @@
 package skills
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -195,3 +197,69 @@ func TestStripFrontmatter(t *testing.T) {
 		})
 	}
 }
+
+func TestListSkills_Schedule(t *testing.T) {
+	workspace := t.TempDir()
+	writeSkill := func(name, frontmatter string) {
+		dir := filepath.Join(workspace, "skills", name)
+		assert.NoError(t, os.MkdirAll(dir, 0o755))
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "SKILL.md"), []byte("---\n"+frontmatter+"---\n\n# Body"), 0o644))
+	}
+	writeSkill("bank-sync", "name: bank-sync\ndescription: Nightly bank feed sync\n"+
+		"schedule: \"0 2 * * *\"\nschedule_command: scripts/sync.sh\nschedule_channel: telegram\n")
+	writeSkill("no-action", "name: no-action\ndescription: Schedule without a command\nschedule: \"0 2 * * *\"\n")
+
+	sl := NewSkillsLoader(workspace, "", "")
+	byName := map[string]SkillInfo{}
+	for _, s := range sl.ListSkills() {
+		byName[s.Name] = s
+	}
+
+	sync := byName["bank-sync"]
+	if assert.NotNil(t, sync.Schedule) {
+		assert.Equal(t, "0 2 * * *", sync.Schedule.Cron)
+		assert.Equal(t, "scripts/sync.sh", sync.Schedule.Command)
+		assert.Equal(t, "telegram", sync.Schedule.Channel)
+	}
+	assert.Nil(t, byName["no-action"].Schedule, "schedule without command or prompt is ignored")
+}
+
+func TestListSkills_ReloadsChangedManifest(t *testing.T) {
+	workspace := t.TempDir()
+	dir := filepath.Join(workspace, "skills", "invoices")
+	assert.NoError(t, os.MkdirAll(dir, 0o755))
+	path := filepath.Join(dir, "SKILL.md")
+	assert.NoError(t, os.WriteFile(path, []byte("---\nname: invoices\ndescription: Old\n---\n"), 0o644))
+
+	sl := NewSkillsLoader(workspace, "", "")
+	assert.Equal(t, "Old", sl.ListSkills()[0].Description)
+	assert.Equal(t, "Old", sl.ListSkills()[0].Description)
+
+	assert.NoError(t, os.WriteFile(path, []byte("---\nname: invoices\ndescription: Create and send invoices\n---\n"), 0o644))
+	assert.Equal(t, "Create and send invoices", sl.ListSkills()[0].Description)
+}
+
+func TestBuildSkillsSummaryForRequest(t *testing.T) {
+	workspace := t.TempDir()
+	for name, desc := range map[string]string{
+		"expense-tracker": "Record and categorize business expenses",
+		"invoices":        "Create and send customer invoices",
+		"bank-sync":       "Import bank transactions",
+	} {
+		dir := filepath.Join(workspace, "skills", name)
+		assert.NoError(t, os.MkdirAll(dir, 0o755))
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "SKILL.md"),
+			[]byte("---\nname: "+name+"\ndescription: "+desc+"\n---\n"), 0o644))
+	}
+	sl := NewSkillsLoader(workspace, "", "")
+
+	summary := sl.BuildSkillsSummaryForRequest("", nil, "list my expense categories", 1)
+	assert.Contains(t, summary, "<description>Record and categorize business expenses</description>")
+	assert.NotContains(t, summary, "Create and send customer invoices")
+	assert.Contains(t, summary, `<skill name="invoices"`)
+	assert.Contains(t, summary, `<skill name="bank-sync"`)
+
+	all := sl.BuildSkillsSummaryForRequest("", nil, "list my expense categories", 0)
+	assert.Contains(t, all, "Create and send customer invoices")
+	assert.NotContains(t, all, "<other_skills>")
+}
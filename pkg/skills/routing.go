@@ -0,0 +1,93 @@
+package skills
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// stopWords are common words that say nothing about which skill a request
+// needs.
+var stopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "with": true, "from": true, "this": true,
+	"that": true, "what": true, "when": true, "where": true, "which": true, "how": true,
+	"can": true, "you": true, "your": true, "please": true, "are": true, "was": true,
+	"all": true, "any": true, "use": true, "into": true, "about": true, "have": true,
+	"show": true, "list": true, "get": true, "give": true, "tell": true,
+}
+
+// SelectRelevant splits skills into at most limit relevant to query, best
+// match first, and the others in their original order. A skill is relevant
+// when words of the query appear in its name or description; words match
+// when they share a stem of at least four letters, so "expenses" finds an
+// "expense-tracker" skill. With a limit of zero, or no more skills than the
+// limit, every skill is relevant.
+func SelectRelevant(all []SkillInfo, query string, limit int) (relevant, others []SkillInfo) {
+	if limit <= 0 || len(all) <= limit {
+		return all, nil
+	}
+
+	queryWords := words(query)
+	scores := make([]int, len(all))
+	for i, s := range all {
+		scores[i] = matchScore(queryWords, words(s.Name+" "+s.Description))
+	}
+
+	order := make([]int, len(all))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return scores[order[a]] > scores[order[b]] })
+
+	picked := make(map[int]bool)
+	for _, i := range order {
+		if len(picked) == limit || scores[i] == 0 {
+			break
+		}
+		picked[i] = true
+		relevant = append(relevant, all[i])
+	}
+	for i, s := range all {
+		if !picked[i] {
+			others = append(others, s)
+		}
+	}
+	return relevant, others
+}
+
+// matchScore counts the query words found among the skill's words.
+func matchScore(query, skill []string) int {
+	score := 0
+	for _, q := range query {
+		for _, w := range skill {
+			if q == w || sameStem(q, w) {
+				score++
+				break
+			}
+		}
+	}
+	return score
+}
+
+func sameStem(a, b string) bool {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n >= 4 && (n == len(a) || n == len(b) || n >= min(len(a), len(b))-2)
+}
+
+// words splits s into lowercase words of three or more letters, without stop
+// words.
+func words(s string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	out := fields[:0]
+	for _, f := range fields {
+		if len(f) >= 3 && !stopWords[f] {
+			out = append(out, f)
+		}
+	}
+	return out
+}
@@ -0,0 +1,75 @@
+package skills
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func skillNames(skills []SkillInfo) []string {
+	names := make([]string, 0, len(skills))
+	for _, s := range skills {
+		names = append(names, s.Name)
+	}
+	return names
+}
+
+func TestSelectRelevant(t *testing.T) {
+	all := []SkillInfo{
+		{Name: "bank-sync", Description: "Import bank transactions from the bank feed"},
+		{Name: "invoices", Description: "Create and send customer invoices"},
+		{Name: "expense-tracker", Description: "Record and categorize business expenses"},
+		{Name: "weather", Description: "Current weather forecast"},
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		limit    int
+		relevant []string
+		others   []string
+	}{
+		{
+			name:     "stem match",
+			query:    "What did I spend on expenses by category?",
+			limit:    2,
+			relevant: []string{"expense-tracker"},
+			others:   []string{"bank-sync", "invoices", "weather"},
+		},
+		{
+			name:     "best match first",
+			query:    "send the invoice for the bank transactions",
+			limit:    1,
+			relevant: []string{"bank-sync"},
+			others:   []string{"invoices", "expense-tracker", "weather"},
+		},
+		{
+			name:     "nothing matches",
+			query:    "hello there",
+			limit:    2,
+			relevant: []string{},
+			others:   []string{"bank-sync", "invoices", "expense-tracker", "weather"},
+		},
+		{
+			name:     "within limit",
+			query:    "hello",
+			limit:    4,
+			relevant: []string{"bank-sync", "invoices", "expense-tracker", "weather"},
+			others:   []string{},
+		},
+		{
+			name:     "no limit",
+			query:    "hello",
+			limit:    0,
+			relevant: []string{"bank-sync", "invoices", "expense-tracker", "weather"},
+			others:   []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			relevant, others := SelectRelevant(all, tt.query, tt.limit)
+			assert.Equal(t, tt.relevant, skillNames(relevant))
+			assert.Equal(t, tt.others, skillNames(others))
+		})
+	}
+}
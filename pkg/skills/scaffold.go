@@ -0,0 +1,303 @@
+package skills
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// ScaffoldLanguages lists the entrypoint languages supported by Scaffold.
+var ScaffoldLanguages = []string{"bash", "python", "node"}
+
+type scaffoldFile struct {
+	path string // relative to the skill directory; [[.Name]] is expanded
+	mode os.FileMode
+	body string
+}
+
+// Scaffold creates a new skill named name under skillsDir with a manifest, an
+// entrypoint in lang that reads the skill env contract, and a sample test.
+// It returns the created file paths.
+func Scaffold(skillsDir, name, lang string) ([]string, error) {
+	if !namePattern.MatchString(name) || len(name) > MaxNameLength {
+		return nil, fmt.Errorf("invalid skill name %q: must be alphanumeric with hyphens", name)
+	}
+	files, ok := scaffoldTemplates[lang]
+	if !ok {
+		return nil, fmt.Errorf("unsupported language %q (supported: %s)", lang, strings.Join(ScaffoldLanguages, ", "))
+	}
+
+	skillDir := filepath.Join(skillsDir, name)
+	if _, err := os.Stat(skillDir); err == nil {
+		return nil, fmt.Errorf("skill directory already exists: %s", skillDir)
+	}
+
+	data := struct {
+		Name       string
+		Lang       string
+		Entrypoint string
+		TestCmd    string
+	}{
+		Name: name,
+		Lang: lang,
+	}
+	switch lang {
+	case "bash":
+		data.Entrypoint = "scripts/" + name + ".sh"
+		data.TestCmd = "sh tests/test_" + name + ".sh"
+	case "python":
+		data.Entrypoint = "scripts/" + name + ".py"
+		data.TestCmd = "python3 -m unittest discover -s tests"
+	case "node":
+		data.Entrypoint = "scripts/" + name + ".js"
+		data.TestCmd = "node --test tests/"
+	}
+
+	all := append([]scaffoldFile{{path: "SKILL.md", mode: 0o644, body: manifestTemplate}}, files...)
+
+	var created []string
+	for _, f := range all {
+		relPath, err := render(f.path, data)
+		if err != nil {
+			return created, err
+		}
+		content, err := render(f.body, data)
+		if err != nil {
+			return created, err
+		}
+
+		path := filepath.Join(skillDir, relPath)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return created, fmt.Errorf("failed to create directory: %w", err)
+		}
+		if err := os.WriteFile(path, []byte(content), f.mode); err != nil {
+			return created, fmt.Errorf("failed to write %s: %w", relPath, err)
+		}
+		created = append(created, path)
+	}
+	return created, nil
+}
+
+func render(text string, data any) (string, error) {
+	tmpl, err := template.New("scaffold").Delims("[[", "]]").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+const manifestTemplate = `---
+name: [[.Name]]
+description: TODO describe what [[.Name]] does and when the agent should use it
+---
+
+# [[.Name]]
+
+TODO: explain when to use this skill.
+
+## Usage
+
+Run the entrypoint with the exec tool:
+
+    [[if eq .Lang "bash"]]sh[[else if eq .Lang "python"]]python3[[else]]node[[end]] {skill_dir}/[[.Entrypoint]] <args>
+
+## Environment
+
+The gateway provides these variables to skill scripts:
+
+| Variable | Description |
+|----------|-------------|
+| ` + "`OLUTO_JWT_TOKEN`" + ` | JWT of the requesting user (only for authenticated requests) |
+| ` + "`OLUTO_BUSINESS_ID`" + ` | Business the request is scoped to |
+| ` + "`PICOCLAW_WORKSPACE`" + ` | Agent workspace directory |
+| ` + "`PICOCLAW_EVENT_FILE`" + ` | Append lines here to send progress messages to the chat |
+
+## Testing
+
+    cd {skill_dir} && [[.TestCmd]]
+`
+
+var scaffoldTemplates = map[string][]scaffoldFile{
+	"bash": {
+		{path: "scripts/[[.Name]].sh", mode: 0o755, body: `#!/bin/sh
+# [[.Name]] skill entrypoint.
+set -eu
+
+: "${OLUTO_JWT_TOKEN:=}"
+: "${OLUTO_BUSINESS_ID:=}"
+: "${PICOCLAW_WORKSPACE:=$(pwd)}"
+: "${PICOCLAW_EVENT_FILE:=}"
+
+emit() {
+	if [ -n "$PICOCLAW_EVENT_FILE" ]; then
+		printf '%s\n' "$1" >> "$PICOCLAW_EVENT_FILE"
+	fi
+}
+
+if [ -z "$OLUTO_BUSINESS_ID" ]; then
+	echo "error: OLUTO_BUSINESS_ID is not set" >&2
+	exit 2
+fi
+
+emit "[[.Name]]: starting"
+
+# TODO: implement the skill. Authenticate API calls with:
+#   curl -H "Authorization: Bearer $OLUTO_JWT_TOKEN" ...
+echo "[[.Name]] ran for business $OLUTO_BUSINESS_ID with args: $*"
+`},
+		{path: "tests/test_[[.Name]].sh", mode: 0o755, body: `#!/bin/sh
+# Run from the skill directory: sh tests/test_[[.Name]].sh
+set -eu
+
+out=$(OLUTO_BUSINESS_ID=test-business sh scripts/[[.Name]].sh sample)
+case "$out" in
+	*"test-business"*) echo "ok" ;;
+	*) echo "unexpected output: $out" >&2; exit 1 ;;
+esac
+
+if OLUTO_BUSINESS_ID= sh scripts/[[.Name]].sh >/dev/null 2>&1; then
+	echo "expected failure without OLUTO_BUSINESS_ID" >&2
+	exit 1
+fi
+`},
+	},
+	"python": {
+		{path: "scripts/[[.Name]].py", mode: 0o755, body: `#!/usr/bin/env python3
+"""[[.Name]] skill entrypoint."""
+
+import os
+import sys
+
+
+def emit(message):
+    """Send a progress message to the chat that invoked the skill."""
+    path = os.environ.get("PICOCLAW_EVENT_FILE")
+    if path:
+        with open(path, "a", encoding="utf-8") as f:
+            f.write(message + "\n")
+
+
+def main(argv):
+    jwt_token = os.environ.get("OLUTO_JWT_TOKEN", "")
+    business_id = os.environ.get("OLUTO_BUSINESS_ID", "")
+    workspace = os.environ.get("PICOCLAW_WORKSPACE", os.getcwd())
+
+    if not business_id:
+        print("error: OLUTO_BUSINESS_ID is not set", file=sys.stderr)
+        return 2
+
+    emit("[[.Name]]: starting")
+
+    # TODO: implement the skill. Authenticate API calls with:
+    #   headers={"Authorization": "Bearer " + jwt_token}
+    _ = (jwt_token, workspace)
+    print("[[.Name]] ran for business %s with args: %s" % (business_id, " ".join(argv)))
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main(sys.argv[1:]))
+`},
+		{path: "tests/test_[[.Name]].py", mode: 0o644, body: `import os
+import subprocess
+import sys
+import unittest
+
+SCRIPT = os.path.join(os.path.dirname(__file__), "..", "scripts", "[[.Name]].py")
+
+
+def run(env):
+    return subprocess.run(
+        [sys.executable, SCRIPT, "sample"],
+        env={**os.environ, **env},
+        capture_output=True,
+        text=True,
+    )
+
+
+class SkillTest(unittest.TestCase):
+    def test_runs_for_business(self):
+        result = run({"OLUTO_BUSINESS_ID": "test-business"})
+        self.assertEqual(result.returncode, 0, result.stderr)
+        self.assertIn("test-business", result.stdout)
+
+    def test_requires_business(self):
+        result = run({"OLUTO_BUSINESS_ID": ""})
+        self.assertNotEqual(result.returncode, 0)
+
+
+if __name__ == "__main__":
+    unittest.main()
+`},
+	},
+	"node": {
+		{path: "scripts/[[.Name]].js", mode: 0o755, body: `#!/usr/bin/env node
+// [[.Name]] skill entrypoint.
+"use strict";
+
+const fs = require("fs");
+
+function emit(message) {
+  const path = process.env.PICOCLAW_EVENT_FILE;
+  if (path) {
+    fs.appendFileSync(path, message + "\n");
+  }
+}
+
+function main(argv) {
+  const jwtToken = process.env.OLUTO_JWT_TOKEN || "";
+  const businessId = process.env.OLUTO_BUSINESS_ID || "";
+  const workspace = process.env.PICOCLAW_WORKSPACE || process.cwd();
+
+  if (!businessId) {
+    console.error("error: OLUTO_BUSINESS_ID is not set");
+    return 2;
+  }
+
+  emit("[[.Name]]: starting");
+
+  // TODO: implement the skill. Authenticate API calls with:
+  //   headers: { Authorization: "Bearer " + jwtToken }
+  void jwtToken;
+  void workspace;
+  console.log("[[.Name]] ran for business " + businessId + " with args: " + argv.join(" "));
+  return 0;
+}
+
+process.exitCode = main(process.argv.slice(2));
+`},
+		{path: "tests/[[.Name]].test.js", mode: 0o644, body: `"use strict";
+
+const test = require("node:test");
+const assert = require("node:assert");
+const path = require("path");
+const { spawnSync } = require("child_process");
+
+const script = path.join(__dirname, "..", "scripts", "[[.Name]].js");
+
+function run(env) {
+  return spawnSync(process.execPath, [script, "sample"], {
+    env: { ...process.env, ...env },
+    encoding: "utf8",
+  });
+}
+
+test("runs for business", () => {
+  const result = run({ OLUTO_BUSINESS_ID: "test-business" });
+  assert.strictEqual(result.status, 0, result.stderr);
+  assert.match(result.stdout, /test-business/);
+});
+
+test("requires business", () => {
+  const result = run({ OLUTO_BUSINESS_ID: "" });
+  assert.notStrictEqual(result.status, 0);
+});
+`},
+	},
+}
@@ -0,0 +1,72 @@
+package skills
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScaffold_CreatesLoadableSkill(t *testing.T) {
+	for _, lang := range ScaffoldLanguages {
+		t.Run(lang, func(t *testing.T) {
+			workspace := t.TempDir()
+			created, err := Scaffold(filepath.Join(workspace, "skills"), "invoice-export", lang)
+			require.NoError(t, err)
+			assert.Len(t, created, 3)
+
+			loaded := NewSkillsLoader(workspace, "", "").ListSkills()
+			require.Len(t, loaded, 1)
+			assert.Equal(t, "invoice-export", loaded[0].Name)
+
+			manifest, err := os.ReadFile(filepath.Join(workspace, "skills", "invoice-export", "SKILL.md"))
+			require.NoError(t, err)
+			assert.Contains(t, string(manifest), "OLUTO_BUSINESS_ID")
+		})
+	}
+}
+
+func TestScaffold_GeneratedTestsPass(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("generated scripts target POSIX shells")
+	}
+	cases := map[string][]string{
+		"bash":   {"sh", "tests/test_sample.sh"},
+		"python": {"python3", "-m", "unittest", "discover", "-s", "tests"},
+		"node":   {"node", "--test", "tests/"},
+	}
+	for lang, command := range cases {
+		t.Run(lang, func(t *testing.T) {
+			if _, err := exec.LookPath(command[0]); err != nil {
+				t.Skipf("%s not available", command[0])
+			}
+			skillsDir := t.TempDir()
+			_, err := Scaffold(skillsDir, "sample", lang)
+			require.NoError(t, err)
+
+			cmd := exec.Command(command[0], command[1:]...)
+			cmd.Dir = filepath.Join(skillsDir, "sample")
+			out, err := cmd.CombinedOutput()
+			assert.NoError(t, err, string(out))
+		})
+	}
+}
+
+func TestScaffold_Rejects(t *testing.T) {
+	skillsDir := t.TempDir()
+
+	_, err := Scaffold(skillsDir, "Bad Name", "bash")
+	assert.Error(t, err)
+
+	_, err = Scaffold(skillsDir, "ok", "ruby")
+	assert.ErrorContains(t, err, "unsupported language")
+
+	_, err = Scaffold(skillsDir, "ok", "bash")
+	require.NoError(t, err)
+	_, err = Scaffold(skillsDir, "ok", "bash")
+	assert.ErrorContains(t, err, "already exists")
+}
@@ -0,0 +1,235 @@
+// Package snapshot takes nightly encrypted backups of a picoclaw
+// installation and uploads them to S3 or WebDAV, so losing the device (or
+// its SD card) doesn't lose paired devices, sessions and history.
+//
+// A snapshot is a backup archive (see package backup) encrypted whole with
+// the configured passphrase. SQLite databases are copied with VACUUM INTO
+// first, so the gateway can keep writing to them while the snapshot is
+// taken. Restore one with picoclaw workspace import and the passphrase.
+package snapshot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite" // registers the "sqlite" driver
+
+	"github.com/sipeed/picoclaw/pkg/backup"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	namePrefix = "picoclaw-"
+	nameSuffix = ".tar.zst.enc"
+	// DefaultKeep is how many snapshots are kept when Options leaves Keep
+	// zero.
+	DefaultKeep = 7
+)
+
+// Target is where snapshots are uploaded.
+type Target interface {
+	Put(ctx context.Context, name string, r io.Reader, size int64) error
+	// List returns the names of the files in the target.
+	List(ctx context.Context) ([]string, error)
+	Delete(ctx context.Context, name string) error
+}
+
+// Options configures a Snapshotter.
+type Options struct {
+	At         string // local time of day, "HH:MM"
+	Passphrase string
+	Keep       int  // snapshots kept in the target; older ones are deleted
+	Media      bool // include uploaded media
+	ConfigPath string
+	Workspace  string
+	Release    string
+}
+
+// Snapshotter takes a snapshot once a day.
+type Snapshotter struct {
+	target Target
+	opts   Options
+	hour   int
+	minute int
+	now    func() time.Time
+}
+
+// New creates a Snapshotter uploading to target.
+func New(target Target, opts Options) (*Snapshotter, error) {
+	at, err := time.Parse("15:04", opts.At)
+	if err != nil {
+		return nil, fmt.Errorf("invalid snapshot time %q: expected HH:MM", opts.At)
+	}
+	if opts.Passphrase == "" {
+		return nil, errors.New("snapshots require a passphrase")
+	}
+	if opts.Keep <= 0 {
+		opts.Keep = DefaultKeep
+	}
+	return &Snapshotter{target: target, opts: opts, hour: at.Hour(), minute: at.Minute(), now: time.Now}, nil
+}
+
+// Run takes a snapshot at the configured time every day until ctx is done.
+func (s *Snapshotter) Run(ctx context.Context) {
+	for {
+		next := s.nextRun(s.now())
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		name, err := s.Take(ctx)
+		if err != nil {
+			logger.WarnCF("snapshot", "Failed to take snapshot", map[string]any{"error": err.Error()})
+			continue
+		}
+		logger.InfoCF("snapshot", "Snapshot uploaded", map[string]any{"name": name})
+	}
+}
+
+// Take uploads a snapshot now, deletes those beyond the retention count and
+// returns the name of the new one.
+func (s *Snapshotter) Take(ctx context.Context) (string, error) {
+	tmpDir, err := os.MkdirTemp("", "picoclaw-snapshot-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	substitutes, err := copyDatabases(ctx, s.opts.Workspace, tmpDir)
+	if err != nil {
+		return "", err
+	}
+	archive, err := os.Create(filepath.Join(tmpDir, "snapshot"))
+	if err != nil {
+		return "", err
+	}
+	defer archive.Close()
+	enc, err := backup.Encrypt(archive, s.opts.Passphrase)
+	if err != nil {
+		return "", err
+	}
+	if _, err := backup.Export(enc, backup.ExportOptions{
+		ConfigPath:  s.opts.ConfigPath,
+		Workspace:   s.opts.Workspace,
+		Release:     s.opts.Release,
+		Passphrase:  s.opts.Passphrase,
+		NoMedia:     !s.opts.Media,
+		Substitutes: substitutes,
+	}); err != nil {
+		return "", err
+	}
+	if err := enc.Close(); err != nil {
+		return "", err
+	}
+	size, err := archive.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", err
+	}
+	if _, err := archive.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	name := namePrefix + s.now().UTC().Format("20060102-150405") + nameSuffix
+	if err := s.target.Put(ctx, name, archive, size); err != nil {
+		return "", fmt.Errorf("uploading %s: %w", name, err)
+	}
+	if err := s.prune(ctx); err != nil {
+		logger.WarnCF("snapshot", "Failed to delete old snapshots", map[string]any{"error": err.Error()})
+	}
+	return name, nil
+}
+
+// prune deletes all but the newest Keep snapshots. Names sort by time.
+func (s *Snapshotter) prune(ctx context.Context) error {
+	names, err := s.target.List(ctx)
+	if err != nil {
+		return err
+	}
+	names = slices.DeleteFunc(names, func(name string) bool {
+		return !strings.HasPrefix(name, namePrefix) || !strings.HasSuffix(name, nameSuffix)
+	})
+	slices.Sort(names)
+	for len(names) > s.opts.Keep {
+		if err := s.target.Delete(ctx, names[0]); err != nil {
+			return err
+		}
+		names = names[1:]
+	}
+	return nil
+}
+
+func (s *Snapshotter) nextRun(now time.Time) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), s.hour, s.minute, 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// sqliteMagic starts every SQLite database file.
+const sqliteMagic = "SQLite format 3\x00"
+
+// copyDatabases copies the SQLite databases in workspace to dir with
+// VACUUM INTO, and returns them as backup substitutes.
+func copyDatabases(ctx context.Context, workspace, dir string) (map[string]string, error) {
+	substitutes := make(map[string]string)
+	err := filepath.WalkDir(workspace, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == "media" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || !strings.HasSuffix(d.Name(), ".db") || !isSQLite(p) {
+			return nil
+		}
+		rel, err := filepath.Rel(workspace, p)
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(dir, fmt.Sprintf("%d.db", len(substitutes)))
+		if err := vacuumInto(ctx, p, dst); err != nil {
+			return fmt.Errorf("copying %s: %w", rel, err)
+		}
+		substitutes[filepath.ToSlash(rel)] = dst
+		return nil
+	})
+	return substitutes, err
+}
+
+func isSQLite(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	head := make([]byte, len(sqliteMagic))
+	_, err = io.ReadFull(f, head)
+	return err == nil && string(head) == sqliteMagic
+}
+
+func vacuumInto(ctx context.Context, src, dst string) error {
+	db, err := sql.Open("sqlite", "file:"+src+"?_pragma=busy_timeout(5000)")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	_, err = db.ExecContext(ctx, "VACUUM INTO ?", dst)
+	return err
+}
@@ -0,0 +1,205 @@
+package snapshot
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/backup"
+)
+
+type memTarget struct {
+	files map[string][]byte
+}
+
+func (m *memTarget) Put(ctx context.Context, name string, r io.Reader, size int64) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) != size {
+		return fmt.Errorf("size %d, read %d", size, len(data))
+	}
+	m.files[name] = data
+	return nil
+}
+
+func (m *memTarget) List(ctx context.Context) ([]string, error) {
+	var names []string
+	for name := range m.files {
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func (m *memTarget) Delete(ctx context.Context, name string) error {
+	delete(m.files, name)
+	return nil
+}
+
+func setupInstall(t *testing.T) (configPath, workspace string) {
+	t.Helper()
+	dir := t.TempDir()
+	workspace = filepath.Join(dir, "workspace")
+	configPath = filepath.Join(dir, "config.json")
+	require.NoError(t, os.WriteFile(configPath,
+		[]byte(`{"agents":{"defaults":{"workspace":"`+workspace+`"}}}`), 0o600))
+	require.NoError(t, os.MkdirAll(filepath.Join(workspace, "state"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(workspace, "state", "state.json"), []byte(`{"paired":1}`), 0o600))
+
+	// Leave the database open in WAL mode, as the gateway does
+	db, err := sql.Open("sqlite", "file:"+filepath.Join(workspace, "state", "history.db")+
+		"?_pragma=journal_mode(WAL)")
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	_, err = db.Exec("CREATE TABLE messages (text TEXT); INSERT INTO messages VALUES ('hello')")
+	require.NoError(t, err)
+	return configPath, workspace
+}
+
+func TestTakeUploadsEncryptedSnapshot(t *testing.T) {
+	configPath, workspace := setupInstall(t)
+	target := &memTarget{files: map[string][]byte{}}
+	s, err := New(target, Options{At: "03:00", Passphrase: "hunter2", ConfigPath: configPath, Workspace: workspace})
+	require.NoError(t, err)
+
+	name, err := s.Take(context.Background())
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(name, "picoclaw-") && strings.HasSuffix(name, ".tar.zst.enc"), name)
+	data := target.files[name]
+	require.True(t, backup.IsEncrypted(data))
+
+	restored := filepath.Join(t.TempDir(), "restored")
+	_, err = backup.Import(bytes.NewReader(data), backup.ImportOptions{
+		ConfigPath: filepath.Join(t.TempDir(), "config.json"),
+		Workspace:  func([]byte) (string, error) { return restored, nil },
+		Passphrase: "hunter2",
+	})
+	require.NoError(t, err)
+
+	state, err := os.ReadFile(filepath.Join(restored, "state", "state.json"))
+	require.NoError(t, err)
+	assert.Equal(t, `{"paired":1}`, string(state))
+	db, err := sql.Open("sqlite", "file:"+filepath.Join(restored, "state", "history.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	var text string
+	require.NoError(t, db.QueryRow("SELECT text FROM messages").Scan(&text))
+	assert.Equal(t, "hello", text)
+	_, err = os.Stat(filepath.Join(restored, "state", "history.db-wal"))
+	assert.True(t, os.IsNotExist(err), "WAL file restored")
+
+	_, err = backup.Import(bytes.NewReader(data), backup.ImportOptions{
+		ConfigPath: filepath.Join(t.TempDir(), "config.json"),
+		Workspace:  func([]byte) (string, error) { return filepath.Join(t.TempDir(), "w"), nil },
+		Passphrase: "wrong",
+	})
+	assert.ErrorIs(t, err, backup.ErrWrongPassphrase)
+}
+
+func TestTakeKeepsNewest(t *testing.T) {
+	configPath, workspace := setupInstall(t)
+	target := &memTarget{files: map[string][]byte{"notes.txt": nil}}
+	s, err := New(target, Options{
+		At: "03:00", Passphrase: "p", Keep: 2, ConfigPath: configPath, Workspace: workspace,
+	})
+	require.NoError(t, err)
+
+	day := time.Date(2026, 3, 1, 3, 0, 0, 0, time.UTC)
+	var names []string
+	for i := range 4 {
+		s.now = func() time.Time { return day.AddDate(0, 0, i) }
+		name, err := s.Take(context.Background())
+		require.NoError(t, err)
+		names = append(names, name)
+	}
+
+	got, _ := target.List(context.Background())
+	slices.Sort(got)
+	assert.Equal(t, []string{"notes.txt", names[2], names[3]}, got)
+	assert.Equal(t, "picoclaw-20260304-030000.tar.zst.enc", names[3])
+}
+
+func TestNewValidates(t *testing.T) {
+	_, err := New(&memTarget{}, Options{At: "3am", Passphrase: "p"})
+	assert.Error(t, err)
+	_, err = New(&memTarget{}, Options{At: "03:00"})
+	assert.Error(t, err)
+}
+
+func TestWebDAVTarget(t *testing.T) {
+	var mu sync.Mutex
+	files := map[string][]byte{}
+	collection := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "bob" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case "MKCOL":
+			if collection {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			collection = true
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodPut:
+			if !collection {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+			files[r.URL.Path], _ = io.ReadAll(r.Body)
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodDelete:
+			delete(files, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		case "PROPFIND":
+			assert.Equal(t, "1", r.Header.Get("Depth"))
+			w.WriteHeader(http.StatusMultiStatus)
+			io.WriteString(w, `<?xml version="1.0"?><d:multistatus xmlns:d="DAV:">`)
+			io.WriteString(w, `<d:response><d:href>/dav/backups/</d:href></d:response>`)
+			for p := range files {
+				io.WriteString(w, `<d:response><d:href>`+strings.ReplaceAll(p, " ", "%20")+`</d:href></d:response>`)
+			}
+			io.WriteString(w, `</d:multistatus>`)
+		}
+	}))
+	defer srv.Close()
+
+	target, err := NewWebDAVTarget(srv.URL+"/dav/backups", "bob", "secret")
+	require.NoError(t, err)
+	ctx := context.Background()
+	require.NoError(t, target.Put(ctx, "a.enc", strings.NewReader("one"), 3))
+	require.NoError(t, target.Put(ctx, "b.enc", strings.NewReader("two"), 3))
+	assert.Equal(t, []byte("one"), files["/dav/backups/a.enc"])
+
+	names, err := target.List(ctx)
+	require.NoError(t, err)
+	slices.Sort(names)
+	assert.Equal(t, []string{"a.enc", "b.enc"}, names)
+
+	require.NoError(t, target.Delete(ctx, "a.enc"))
+	names, err = target.List(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b.enc"}, names)
+
+	_, err = NewWebDAVTarget("ftp://example.com", "", "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,189 @@
+package snapshot
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/blob"
+)
+
+// S3Target uploads snapshots to an S3 bucket, under "snapshots/" below
+// the configured prefix.
+type S3Target struct {
+	store *blob.S3Store
+}
+
+// NewS3Target creates a target in the bucket of store.
+func NewS3Target(store *blob.S3Store) *S3Target {
+	return &S3Target{store: store}
+}
+
+const s3Dir = "snapshots/"
+
+func (t *S3Target) Put(ctx context.Context, name string, r io.Reader, size int64) error {
+	return t.store.Put(ctx, s3Dir+name, r, size, "application/octet-stream")
+}
+
+func (t *S3Target) List(ctx context.Context) ([]string, error) {
+	keys, err := t.store.List(ctx, s3Dir)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(keys))
+	for _, key := range keys {
+		names = append(names, strings.TrimPrefix(key, s3Dir))
+	}
+	return names, nil
+}
+
+func (t *S3Target) Delete(ctx context.Context, name string) error {
+	return t.store.Delete(ctx, s3Dir+name)
+}
+
+// WebDAVTarget uploads snapshots to a WebDAV collection, such as a
+// Nextcloud folder, with basic auth.
+type WebDAVTarget struct {
+	base     *url.URL
+	username string
+	password string
+	client   *http.Client
+}
+
+// NewWebDAVTarget creates a target for the collection at rawURL, which is
+// created on the first upload if it doesn't exist.
+func NewWebDAVTarget(rawURL, username, password string) (*WebDAVTarget, error) {
+	base, err := url.Parse(rawURL)
+	if err != nil || base.Host == "" || (base.Scheme != "http" && base.Scheme != "https") {
+		return nil, fmt.Errorf("invalid webdav url %q", rawURL)
+	}
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+	base.RawPath = ""
+	return &WebDAVTarget{
+		base:     base,
+		username: username,
+		password: password,
+		client:   &http.Client{Timeout: 30 * time.Minute},
+	}, nil
+}
+
+func (t *WebDAVTarget) Put(ctx context.Context, name string, r io.Reader, size int64) error {
+	// 405 means the collection already exists
+	resp, err := t.do(ctx, "MKCOL", t.base.String(), nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusMethodNotAllowed {
+		return fmt.Errorf("webdav MKCOL: %s", resp.Status)
+	}
+
+	req, err := t.request(ctx, http.MethodPut, t.fileURL(name), r, nil)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err = t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webdav PUT: %s", resp.Status)
+	}
+	return nil
+}
+
+func (t *WebDAVTarget) List(ctx context.Context) ([]string, error) {
+	body := strings.NewReader(`<?xml version="1.0"?><propfind xmlns="DAV:"><prop><resourcetype/></prop></propfind>`)
+	resp, err := t.do(ctx, "PROPFIND", t.base.String(), body, map[string]string{
+		"Depth":        "1",
+		"Content-Type": "application/xml",
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, fmt.Errorf("webdav PROPFIND: %s", resp.Status)
+	}
+	var result struct {
+		Responses []struct {
+			Href string `xml:"href"`
+		} `xml:"response"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("webdav PROPFIND: %w", err)
+	}
+	var names []string
+	for _, r := range result.Responses {
+		href, err := url.Parse(r.Href)
+		if err != nil || strings.HasSuffix(href.Path, "/") {
+			continue // the collection itself, or a subcollection
+		}
+		names = append(names, path.Base(href.Path))
+	}
+	return names, nil
+}
+
+func (t *WebDAVTarget) Delete(ctx context.Context, name string) error {
+	resp, err := t.do(ctx, http.MethodDelete, t.fileURL(name), nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("webdav DELETE: %s", resp.Status)
+	}
+	return nil
+}
+
+func (t *WebDAVTarget) fileURL(name string) string {
+	u := *t.base
+	u.Path += name
+	return u.String()
+}
+
+func (t *WebDAVTarget) request(
+	ctx context.Context,
+	method, target string,
+	body io.Reader,
+	header map[string]string,
+) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	if t.username != "" || t.password != "" {
+		req.SetBasicAuth(t.username, t.password)
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	return req, nil
+}
+
+func (t *WebDAVTarget) do(
+	ctx context.Context,
+	method, target string,
+	body io.Reader,
+	header map[string]string,
+) (*http.Response, error) {
+	req, err := t.request(ctx, method, target, body, header)
+	if err != nil {
+		return nil, err
+	}
+	return t.client.Do(req)
+}
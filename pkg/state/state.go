@@ -1,13 +1,19 @@
 package state
 
 import (
-	"encoding/json"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/reporting"
 )
 
 // AuthEntry stores auth context for a specific business.
@@ -30,48 +36,56 @@ type State struct {
 	// ActiveAuth stores auth per business ID for heartbeat use
 	ActiveAuth map[string]AuthEntry `json:"active_auth,omitempty"`
 
+	// HeartbeatEnabled records /heartbeat on and off per business ID,
+	// overriding the configured schedule
+	HeartbeatEnabled map[string]bool `json:"heartbeat_enabled,omitempty"`
+
+	// ActiveBusiness records the business chosen with /business switch,
+	// keyed by session key or "channel:chat_id"
+	ActiveBusiness map[string]string `json:"active_business,omitempty"`
+
+	// CategoryRules are the rules users taught in chat, per business ID,
+	// newest first
+	CategoryRules map[string][]config.CategoryRule `json:"category_rules,omitempty"`
+
+	// LinkedUsers maps a chat ("channel:peer_id") linked with /link to the
+	// API user (JWT subject) it belongs to
+	LinkedUsers map[string]string `json:"linked_users,omitempty"`
+
+	// NotifiedRelease is the latest release the owner was told about
+	NotifiedRelease string `json:"notified_release,omitempty"`
+
 	// Timestamp is the last time this state was updated
 	Timestamp time.Time `json:"timestamp"`
 }
 
 // Manager manages persistent state with atomic saves.
 type Manager struct {
-	workspace string
-	state     *State
-	mu        sync.RWMutex
-	stateFile string
+	state *State
+	mu    sync.RWMutex
+	store Store
 }
 
-// NewManager creates a new state manager for the given workspace.
+// NewManager creates a new state manager for the given workspace, stored in
+// <workspace>/state/state.json.
 func NewManager(workspace string) *Manager {
-	stateDir := filepath.Join(workspace, "state")
-	stateFile := filepath.Join(stateDir, "state.json")
-	oldStateFile := filepath.Join(workspace, "state.json")
-
-	// Create state directory if it doesn't exist
-	os.MkdirAll(stateDir, 0o755)
+	return NewManagerWithStore(NewFileStore(workspace))
+}
 
+// NewManagerWithStore creates a state manager that loads from and saves to
+// store.
+func NewManagerWithStore(store Store) *Manager {
 	sm := &Manager{
-		workspace: workspace,
-		stateFile: stateFile,
-		state:     &State{},
-	}
-
-	// Try to load from new location first
-	if _, err := os.Stat(stateFile); os.IsNotExist(err) {
-		// New file doesn't exist, try migrating from old location
-		if data, err := os.ReadFile(oldStateFile); err == nil {
-			if err := json.Unmarshal(data, sm.state); err == nil {
-				// Migrate to new location
-				sm.saveAtomic()
-				log.Printf("[INFO] state: migrated state from %s to %s", oldStateFile, stateFile)
-			}
-		}
-	} else {
-		// Load from new location
-		sm.load()
+		store: store,
+		state: &State{},
+	}
+	loaded, err := store.Load()
+	if err != nil {
+		logger.WarnCF("state", "Failed to load state", map[string]any{"error": err.Error()})
+	}
+	if loaded != nil {
+		sm.state = loaded
 	}
-
 	return sm
 }
 
@@ -163,59 +177,212 @@ func (sm *Manager) GetActiveAuth() map[string]AuthEntry {
 	return result
 }
 
-// GetTimestamp returns the timestamp of the last state update.
-func (sm *Manager) GetTimestamp() time.Time {
+// SetBusinessHeartbeat turns heartbeats on or off for a business.
+func (sm *Manager) SetBusinessHeartbeat(businessID string, enabled bool) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if sm.state.HeartbeatEnabled == nil {
+		sm.state.HeartbeatEnabled = make(map[string]bool)
+	}
+	sm.state.HeartbeatEnabled[businessID] = enabled
+	sm.state.Timestamp = time.Now()
+
+	if err := sm.saveAtomic(); err != nil {
+		return fmt.Errorf("failed to save state atomically: %w", err)
+	}
+	return nil
+}
+
+// BusinessHeartbeat returns whether heartbeats were turned on or off for a
+// business. set is false if they never were, and the config applies.
+func (sm *Manager) BusinessHeartbeat(businessID string) (enabled, set bool) {
 	sm.mu.RLock()
 	defer sm.mu.RUnlock()
-	return sm.state.Timestamp
+	enabled, set = sm.state.HeartbeatEnabled[businessID]
+	return enabled, set
 }
 
-// saveAtomic performs an atomic save using temp file + rename.
-// This ensures that the state file is never corrupted:
-// 1. Write to a temp file
-// 2. Rename temp file to target (atomic on POSIX systems)
-// 3. If rename fails, cleanup the temp file
-//
-// Must be called with the lock held.
-func (sm *Manager) saveAtomic() error {
-	// Create temp file in the same directory as the target
-	tempFile := sm.stateFile + ".tmp"
+// SetActiveBusiness binds a conversation to a business.
+func (sm *Manager) SetActiveBusiness(conversation, businessID string) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
 
-	// Marshal state to JSON
-	data, err := json.MarshalIndent(sm.state, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal state: %w", err)
+	if sm.state.ActiveBusiness == nil {
+		sm.state.ActiveBusiness = make(map[string]string)
 	}
+	sm.state.ActiveBusiness[conversation] = businessID
+	sm.state.Timestamp = time.Now()
 
-	// Write to temp file
-	if err := os.WriteFile(tempFile, data, 0o644); err != nil {
-		return fmt.Errorf("failed to write temp file: %w", err)
+	if err := sm.saveAtomic(); err != nil {
+		return fmt.Errorf("failed to save state atomically: %w", err)
 	}
+	return nil
+}
+
+// ActiveBusiness returns the business a conversation is bound to, or "".
+func (sm *Manager) ActiveBusiness(conversation string) string {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return sm.state.ActiveBusiness[conversation]
+}
+
+// SetLinkedUser links a chat to an API user, or unlinks it if userID is
+// "".
+func (sm *Manager) SetLinkedUser(chat, userID string) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
 
-	// Atomic rename from temp to target
-	if err := os.Rename(tempFile, sm.stateFile); err != nil {
-		// Cleanup temp file if rename fails
-		os.Remove(tempFile)
-		return fmt.Errorf("failed to rename temp file: %w", err)
+	if userID == "" {
+		delete(sm.state.LinkedUsers, chat)
+	} else {
+		if sm.state.LinkedUsers == nil {
+			sm.state.LinkedUsers = make(map[string]string)
+		}
+		sm.state.LinkedUsers[chat] = userID
 	}
+	sm.state.Timestamp = time.Now()
 
+	if err := sm.saveAtomic(); err != nil {
+		return fmt.Errorf("failed to save state atomically: %w", err)
+	}
 	return nil
 }
 
-// load loads the state from disk.
-func (sm *Manager) load() error {
-	data, err := os.ReadFile(sm.stateFile)
-	if err != nil {
-		// File doesn't exist yet, that's OK
-		if os.IsNotExist(err) {
-			return nil
+// LinkedUser returns the API user a chat is linked to, or "".
+func (sm *Manager) LinkedUser(chat string) string {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return sm.state.LinkedUsers[chat]
+}
+
+// LinkedChats returns the chats linked to an API user, sorted.
+func (sm *Manager) LinkedChats(userID string) []string {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	var chats []string
+	for chat, id := range sm.state.LinkedUsers {
+		if id == userID {
+			chats = append(chats, chat)
+		}
+	}
+	slices.Sort(chats)
+	return chats
+}
+
+// SetNotifiedRelease records that the owner was told about version.
+func (sm *Manager) SetNotifiedRelease(version string) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	sm.state.NotifiedRelease = version
+	sm.state.Timestamp = time.Now()
+
+	if err := sm.saveAtomic(); err != nil {
+		return fmt.Errorf("failed to save state atomically: %w", err)
+	}
+	return nil
+}
+
+// NotifiedRelease returns the latest release the owner was told about.
+func (sm *Manager) NotifiedRelease() string {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return sm.state.NotifiedRelease
+}
+
+// AddCategoryRule teaches a business a categorization rule. It replaces an
+// earlier rule for the same merchant, keyword and amounts, and goes before
+// the others, so the latest thing a user said wins.
+func (sm *Manager) AddCategoryRule(businessID string, rule config.CategoryRule) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if sm.state.CategoryRules == nil {
+		sm.state.CategoryRules = make(map[string][]config.CategoryRule)
+	}
+	rules := []config.CategoryRule{rule}
+	for _, r := range sm.state.CategoryRules[businessID] {
+		same := r
+		same.Category = rule.Category
+		if same != rule {
+			rules = append(rules, r)
 		}
-		return fmt.Errorf("failed to read state file: %w", err)
 	}
+	sm.state.CategoryRules[businessID] = rules
+	sm.state.Timestamp = time.Now()
+
+	if err := sm.saveAtomic(); err != nil {
+		return fmt.Errorf("failed to save state atomically: %w", err)
+	}
+	return nil
+}
+
+// RemoveCategoryRule forgets the nth (from 0) rule a business was taught.
+func (sm *Manager) RemoveCategoryRule(businessID string, n int) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
 
-	if err := json.Unmarshal(data, sm.state); err != nil {
-		return fmt.Errorf("failed to unmarshal state: %w", err)
+	rules := sm.state.CategoryRules[businessID]
+	if n < 0 || n >= len(rules) {
+		return fmt.Errorf("business %s has no rule %d", businessID, n+1)
 	}
+	sm.state.CategoryRules[businessID] = append(rules[:n:n], rules[n+1:]...)
+	sm.state.Timestamp = time.Now()
 
+	if err := sm.saveAtomic(); err != nil {
+		return fmt.Errorf("failed to save state atomically: %w", err)
+	}
 	return nil
 }
+
+// CategoryRules returns the rules a business was taught, newest first.
+func (sm *Manager) CategoryRules(businessID string) []config.CategoryRule {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return slices.Clone(sm.state.CategoryRules[businessID])
+}
+
+// GetTimestamp returns the timestamp of the last state update.
+func (sm *Manager) GetTimestamp() time.Time {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return sm.state.Timestamp
+}
+
+// DeviceID returns a stable identifier for this installation, stored in
+// <workspace>/state/device_id and generated on first use.
+func DeviceID(workspace string) (string, error) {
+	path := filepath.Join(workspace, "state", "device_id")
+	if data, err := os.ReadFile(path); err == nil {
+		if id := strings.TrimSpace(string(data)); id != "" {
+			return id, nil
+		}
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate device id: %w", err)
+	}
+	id := hex.EncodeToString(b)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", fmt.Errorf("failed to create state directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(id+"\n"), 0o644); err != nil {
+		return "", fmt.Errorf("failed to write device id: %w", err)
+	}
+	return id, nil
+}
+
+// saveAtomic saves the state and reports failures, since lost state
+// silently breaks heartbeat and scheduled skill delivery.
+//
+// Must be called with the lock held.
+func (sm *Manager) saveAtomic() error {
+	err := sm.store.Save(sm.state)
+	if err != nil {
+		reporting.CaptureError("state", err, map[string]any{"store": fmt.Sprintf("%T", sm.store)})
+	}
+	return err
+}
@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
 )
 
 func TestAtomicSave(t *testing.T) {
@@ -214,3 +217,117 @@ func TestNewManager_EmptyWorkspace(t *testing.T) {
 		t.Error("Expected zero timestamp for new state")
 	}
 }
+
+func TestDeviceIDIsStable(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	id, err := DeviceID(tmpDir)
+	if err != nil {
+		t.Fatalf("DeviceID failed: %v", err)
+	}
+	if len(id) != 32 {
+		t.Errorf("Expected 32 hex chars, got %q", id)
+	}
+
+	again, err := DeviceID(tmpDir)
+	if err != nil {
+		t.Fatalf("DeviceID failed: %v", err)
+	}
+	if again != id {
+		t.Errorf("Expected stable device ID %q, got %q", id, again)
+	}
+
+	if _, err := os.Stat(filepath.Join(tmpDir, "state", "device_id")); err != nil {
+		t.Errorf("Expected device_id file: %v", err)
+	}
+}
+
+// memStore is a Store that keeps the last saved state in memory.
+type memStore struct {
+	saved *State
+	saves int
+}
+
+func (m *memStore) Load() (*State, error) { return m.saved, nil }
+
+func (m *memStore) Save(state *State) error {
+	copied := *state
+	m.saved = &copied
+	m.saves++
+	return nil
+}
+
+func TestNewManagerWithStore(t *testing.T) {
+	store := &memStore{}
+	sm := NewManagerWithStore(store)
+	if err := sm.SetLastChannel("telegram:42"); err != nil {
+		t.Fatalf("SetLastChannel failed: %v", err)
+	}
+	if store.saves != 1 {
+		t.Errorf("Expected 1 save, got %d", store.saves)
+	}
+
+	sm2 := NewManagerWithStore(store)
+	if sm2.GetLastChannel() != "telegram:42" {
+		t.Errorf("Expected channel 'telegram:42' from store, got '%s'", sm2.GetLastChannel())
+	}
+}
+
+func TestCategoryRules(t *testing.T) {
+	tmpDir := t.TempDir()
+	sm := NewManager(tmpDir)
+
+	shell := config.CategoryRule{Merchant: `\bShell\b`, Category: "Fuel"}
+	parking := config.CategoryRule{Keyword: "parking", Category: "Parking"}
+	for _, r := range []config.CategoryRule{shell, parking, {Merchant: `\bShell\b`, Category: "Travel"}} {
+		if err := sm.AddCategoryRule("biz-1", r); err != nil {
+			t.Fatalf("AddCategoryRule failed: %v", err)
+		}
+	}
+
+	// The Travel rule replaced the Fuel one and, being newest, comes first.
+	want := []config.CategoryRule{{Merchant: `\bShell\b`, Category: "Travel"}, parking}
+	if got := NewManager(tmpDir).CategoryRules("biz-1"); !slices.Equal(got, want) {
+		t.Errorf("Expected persisted rules %v, got %v", want, got)
+	}
+	if got := sm.CategoryRules("biz-2"); len(got) != 0 {
+		t.Errorf("Expected no rules for another business, got %v", got)
+	}
+
+	if err := sm.RemoveCategoryRule("biz-1", 0); err != nil {
+		t.Fatalf("RemoveCategoryRule failed: %v", err)
+	}
+	if got := sm.CategoryRules("biz-1"); !slices.Equal(got, []config.CategoryRule{parking}) {
+		t.Errorf("Expected only the parking rule left, got %v", got)
+	}
+	if err := sm.RemoveCategoryRule("biz-1", 1); err == nil {
+		t.Error("Expected removing a missing rule to fail")
+	}
+}
+
+func TestLinkedUsers(t *testing.T) {
+	tmpDir := t.TempDir()
+	sm := NewManager(tmpDir)
+
+	for _, chat := range []string{"telegram:42", "discord:7"} {
+		if err := sm.SetLinkedUser(chat, "user-1"); err != nil {
+			t.Fatalf("SetLinkedUser failed: %v", err)
+		}
+	}
+	if got := NewManager(tmpDir).LinkedUser("telegram:42"); got != "user-1" {
+		t.Errorf("Expected persisted link to user-1, got %q", got)
+	}
+	if got := sm.LinkedChats("user-1"); !slices.Equal(got, []string{"discord:7", "telegram:42"}) {
+		t.Errorf("Expected both chats linked, got %v", got)
+	}
+
+	if err := sm.SetLinkedUser("telegram:42", ""); err != nil {
+		t.Fatalf("SetLinkedUser failed: %v", err)
+	}
+	if got := sm.LinkedUser("telegram:42"); got != "" {
+		t.Errorf("Expected the chat unlinked, got %q", got)
+	}
+	if got := sm.LinkedChats("user-1"); !slices.Equal(got, []string{"discord:7"}) {
+		t.Errorf("Expected one chat left, got %v", got)
+	}
+}
@@ -0,0 +1,101 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// Store persists the state of a Manager.
+type Store interface {
+	// Load returns the stored state, or nil if none has been saved.
+	Load() (*State, error)
+	// Save replaces the stored state.
+	Save(state *State) error
+}
+
+// FileStore keeps the state in <workspace>/state/state.json.
+type FileStore struct {
+	stateFile    string
+	oldStateFile string
+}
+
+// NewFileStore returns the file store for workspace, creating its state
+// directory if needed.
+func NewFileStore(workspace string) *FileStore {
+	stateDir := filepath.Join(workspace, "state")
+
+	// Create state directory if it doesn't exist
+	os.MkdirAll(stateDir, 0o755)
+
+	return &FileStore{
+		stateFile:    filepath.Join(stateDir, "state.json"),
+		oldStateFile: filepath.Join(workspace, "state.json"),
+	}
+}
+
+// Load reads the state file, migrating it from the workspace root where
+// older versions kept it.
+func (fs *FileStore) Load() (*State, error) {
+	if _, err := os.Stat(fs.stateFile); os.IsNotExist(err) {
+		// New file doesn't exist, try migrating from old location
+		data, err := os.ReadFile(fs.oldStateFile)
+		if err != nil {
+			return nil, nil
+		}
+		var state State
+		if err := json.Unmarshal(data, &state); err != nil {
+			return nil, nil
+		}
+		// Migrate to new location
+		if err := fs.Save(&state); err == nil {
+			logger.InfoCF("state", "Migrated state file",
+				map[string]any{"from": fs.oldStateFile, "to": fs.stateFile})
+		}
+		return &state, nil
+	}
+
+	data, err := os.ReadFile(fs.stateFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal state: %w", err)
+	}
+	return &state, nil
+}
+
+// Save performs an atomic save using temp file + rename.
+// This ensures that the state file is never corrupted:
+// 1. Write to a temp file
+// 2. Rename temp file to target (atomic on POSIX systems)
+// 3. If rename fails, cleanup the temp file
+func (fs *FileStore) Save(state *State) error {
+	// Create temp file in the same directory as the target
+	tempFile := fs.stateFile + ".tmp"
+
+	// Marshal state to JSON
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+
+	// Write to temp file
+	if err := os.WriteFile(tempFile, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+
+	// Atomic rename from temp to target
+	if err := os.Rename(tempFile, fs.stateFile); err != nil {
+		// Cleanup temp file if rename fails
+		os.Remove(tempFile)
+		return fmt.Errorf("failed to rename temp file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,302 @@
+// Package subscriptions posts events to the URLs subscribed to them, so
+// integrators hear about activity as it happens instead of polling.
+//
+// Deliveries are signed like LedgerForge requests (see ledgerforge.Sign),
+// with the event ID as the idempotency key. A failed delivery is retried
+// with exponential backoff; one that still fails is appended to
+// <workspace>/subscriptions/dead_letter.jsonl for inspection or replay.
+package subscriptions
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/httpclient"
+	"github.com/sipeed/picoclaw/pkg/ledgerforge"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/wirelog"
+)
+
+// Event types.
+const (
+	EventResponseCompleted = "response.completed"
+	EventReceiptParsed     = "receipt.parsed"
+	EventPairingCreated    = "pairing.created"
+	EventErrorOccurred     = "error.occurred"
+)
+
+// Types lists the event types that can be subscribed to.
+var Types = []string{EventResponseCompleted, EventReceiptParsed, EventPairingCreated, EventErrorOccurred}
+
+// EventHeader names the event type of a delivery.
+const EventHeader = "X-Picoclaw-Event"
+
+// queueSize is how many events wait for each endpoint; more are written to
+// the dead-letter file.
+const queueSize = 256
+
+// Event is the JSON body of a delivery.
+type Event struct {
+	// ID names the occurrence, not the delivery attempt. It is also the
+	// Idempotency-Key.
+	ID         string    `json:"id"`
+	Type       string    `json:"type"`
+	BusinessID string    `json:"business_id,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	Data       any       `json:"data"`
+}
+
+// DeadLetter is a line of the dead-letter file: an event that could not be
+// delivered to URL.
+type DeadLetter struct {
+	URL      string    `json:"url"`
+	Event    Event     `json:"event"`
+	Attempts int       `json:"attempts"`
+	Error    string    `json:"error"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+type endpoint struct {
+	url    string
+	events []string
+	secret []byte
+	queue  chan Event
+}
+
+func (ep *endpoint) wants(eventType string) bool {
+	return slices.Contains(ep.events, "*") || slices.Contains(ep.events, eventType)
+}
+
+// Dispatcher delivers events to the configured endpoints.
+type Dispatcher struct {
+	endpoints  []*endpoint
+	maxRetries int
+	backoff    time.Duration // first retry delay, doubled for each retry
+	client     *http.Client
+	deadLetter string
+
+	mu sync.Mutex // serializes dead-letter writes
+}
+
+var active atomic.Pointer[Dispatcher]
+
+// New creates a dispatcher for cfg, checking its URLs and event types.
+func New(cfg config.SubscriptionsConfig, workspace string) (*Dispatcher, error) {
+	d := &Dispatcher{
+		maxRetries: max(cfg.MaxRetries, 0),
+		backoff:    time.Second,
+		client:     httpclient.New(30 * time.Second),
+		deadLetter: filepath.Join(workspace, "subscriptions", "dead_letter.jsonl"),
+	}
+	for i, sub := range cfg.Endpoints {
+		u, err := url.Parse(sub.URL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return nil, fmt.Errorf("subscription %d: invalid URL %q", i+1, sub.URL)
+		}
+		if len(sub.Events) == 0 {
+			return nil, fmt.Errorf("subscription %d: no events", i+1)
+		}
+		for _, t := range sub.Events {
+			if t != "*" && !slices.Contains(Types, t) {
+				return nil, fmt.Errorf("subscription %d: unknown event %q; expected one of %v or *", i+1, t, Types)
+			}
+		}
+		ep := &endpoint{url: sub.URL, events: sub.Events, queue: make(chan Event, queueSize)}
+		if secret := sub.Secret; secret != "" {
+			ep.secret = []byte(secret)
+		} else if cfg.Secret != "" {
+			ep.secret = []byte(cfg.Secret)
+		}
+		d.endpoints = append(d.endpoints, ep)
+	}
+	return d, nil
+}
+
+// Run makes d the target of Publish and delivers events until ctx is done.
+// Each endpoint gets its events in order.
+func (d *Dispatcher) Run(ctx context.Context) {
+	active.Store(d)
+	defer active.CompareAndSwap(d, nil)
+
+	var wg sync.WaitGroup
+	for _, ep := range d.endpoints {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case e := <-ep.queue:
+					d.deliver(ctx, ep, e)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Publish sends an event to the subscribers of its type, if a dispatcher
+// is running, and to the in-process listeners. It fills in the event's ID
+// and time if unset, and never blocks.
+func Publish(e Event) {
+	if d := active.Load(); d != nil {
+		d.Publish(e)
+	}
+	listeners.RLock()
+	fns := make([]func(Event), 0, len(listeners.fns))
+	for _, fn := range listeners.fns {
+		fns = append(fns, fn)
+	}
+	listeners.RUnlock()
+	for _, fn := range fns {
+		go fn(e)
+	}
+}
+
+var listeners struct {
+	sync.RWMutex
+	next int
+	fns  map[int]func(Event)
+}
+
+// Listen calls fn, in a goroutine of its own, for every published event,
+// whether or not a dispatcher is running. It returns a function that stops
+// the calls.
+func Listen(fn func(Event)) (stop func()) {
+	listeners.Lock()
+	defer listeners.Unlock()
+	if listeners.fns == nil {
+		listeners.fns = make(map[int]func(Event))
+	}
+	id := listeners.next
+	listeners.next++
+	listeners.fns[id] = fn
+	return func() {
+		listeners.Lock()
+		defer listeners.Unlock()
+		delete(listeners.fns, id)
+	}
+}
+
+// Publish queues e for the endpoints subscribed to its type.
+func (d *Dispatcher) Publish(e Event) {
+	if e.ID == "" {
+		e.ID = "evt-" + wirelog.NewID()
+	}
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now().UTC()
+	}
+	for _, ep := range d.endpoints {
+		if !ep.wants(e.Type) {
+			continue
+		}
+		select {
+		case ep.queue <- e:
+		default:
+			d.bury(ep, e, 0, "delivery queue full")
+		}
+	}
+}
+
+// deliver posts e to ep, retrying network errors, 408, 429 and 5xx
+// answers, and buries it if it can't be delivered.
+func (d *Dispatcher) deliver(ctx context.Context, ep *endpoint, e Event) {
+	body, err := json.Marshal(e)
+	if err != nil {
+		d.bury(ep, e, 0, err.Error())
+		return
+	}
+	delay := d.backoff
+	for attempt := 1; ; attempt++ {
+		retry, err := d.post(ctx, ep, e, body)
+		if err == nil {
+			return
+		}
+		if !retry || attempt > d.maxRetries {
+			d.bury(ep, e, attempt, err.Error())
+			return
+		}
+		select {
+		case <-ctx.Done():
+			d.bury(ep, e, attempt, err.Error())
+			return
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// post makes one delivery attempt and reports whether a failure is worth
+// retrying.
+func (d *Dispatcher) post(ctx context.Context, ep *endpoint, e Event, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ep.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, e.Type)
+	req.Header.Set(ledgerforge.IdempotencyHeader, e.ID)
+	if ep.secret != nil {
+		ts := time.Now().Unix()
+		req.Header.Set(ledgerforge.TimestampHeader, strconv.FormatInt(ts, 10))
+		req.Header.Set(ledgerforge.SignatureHeader,
+			ledgerforge.Sign(ep.secret, ts, http.MethodPost, req.URL.RequestURI(), e.ID, body))
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusRequestTimeout ||
+		resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("subscriber answered %s", resp.Status)
+}
+
+// bury appends an undeliverable event to the dead-letter file.
+func (d *Dispatcher) bury(ep *endpoint, e Event, attempts int, reason string) {
+	logger.WarnCF("subscriptions", "Event delivery failed", map[string]any{
+		"url": ep.url, "event": e.Type, "id": e.ID, "attempts": attempts, "error": reason,
+	})
+	line, err := json.Marshal(DeadLetter{
+		URL:      ep.url,
+		Event:    e,
+		Attempts: attempts,
+		Error:    reason,
+		FailedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(d.deadLetter), 0o755); err != nil {
+		logger.WarnCF("subscriptions", "Writing dead letter failed", map[string]any{"error": err.Error()})
+		return
+	}
+	f, err := os.OpenFile(d.deadLetter, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		logger.WarnCF("subscriptions", "Writing dead letter failed", map[string]any{"error": err.Error()})
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		logger.WarnCF("subscriptions", "Writing dead letter failed", map[string]any{"error": err.Error()})
+	}
+}
@@ -0,0 +1,139 @@
+package subscriptions
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/ledgerforge"
+)
+
+func TestNewValidates(t *testing.T) {
+	for _, sub := range []config.SubscriptionConfig{
+		{URL: "ftp://example.com", Events: []string{"*"}},
+		{URL: "https://example.com/hook"},
+		{URL: "https://example.com/hook", Events: []string{"response.done"}},
+	} {
+		_, err := New(config.SubscriptionsConfig{Endpoints: []config.SubscriptionConfig{sub}}, t.TempDir())
+		assert.Error(t, err, sub)
+	}
+}
+
+func TestDelivery(t *testing.T) {
+	var mu sync.Mutex
+	var received []Event
+	failures := 2
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if strings.HasSuffix(r.URL.Path, "/gone") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		ts, _ := strconv.ParseInt(r.Header.Get(ledgerforge.TimestampHeader), 10, 64)
+		want := ledgerforge.Sign([]byte("s3cret"), ts, http.MethodPost, r.URL.RequestURI(),
+			r.Header.Get(ledgerforge.IdempotencyHeader), body)
+		assert.Equal(t, want, r.Header.Get(ledgerforge.SignatureHeader))
+
+		mu.Lock()
+		defer mu.Unlock()
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var e Event
+		assert.NoError(t, json.Unmarshal(body, &e))
+		assert.Equal(t, e.Type, r.Header.Get(EventHeader))
+		received = append(received, e)
+	}))
+	defer srv.Close()
+
+	workspace := t.TempDir()
+	d, err := New(config.SubscriptionsConfig{
+		Secret:     "s3cret",
+		MaxRetries: 3,
+		Endpoints: []config.SubscriptionConfig{
+			{URL: srv.URL + "/receipts", Events: []string{EventReceiptParsed}},
+			{URL: srv.URL + "/gone", Events: []string{"*"}},
+		},
+	}, workspace)
+	require.NoError(t, err)
+	d.backoff = time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		d.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	require.Eventually(t, func() bool { return active.Load() == d }, time.Second, time.Millisecond)
+
+	Publish(Event{ID: "receipt-1", Type: EventReceiptParsed, BusinessID: "biz-1", Data: map[string]any{"vendor": "Esso"}})
+	Publish(Event{Type: EventPairingCreated})
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(received) == 1
+	}, 5*time.Second, 10*time.Millisecond, "delivered after two retries")
+	assert.Equal(t, "receipt-1", received[0].ID)
+	assert.Equal(t, "biz-1", received[0].BusinessID)
+	assert.False(t, received[0].CreatedAt.IsZero())
+
+	// A 404 is not retried; both events end up in the dead-letter file.
+	var letters []DeadLetter
+	require.Eventually(t, func() bool {
+		data, _ := os.ReadFile(filepath.Join(workspace, "subscriptions", "dead_letter.jsonl"))
+		letters = nil
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			var l DeadLetter
+			if json.Unmarshal([]byte(line), &l) == nil {
+				letters = append(letters, l)
+			}
+		}
+		return len(letters) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	for _, l := range letters {
+		assert.Equal(t, srv.URL+"/gone", l.URL)
+		assert.Equal(t, 1, l.Attempts)
+		assert.Contains(t, l.Error, "404")
+	}
+}
+
+func TestListen(t *testing.T) {
+	got := make(chan Event, 1)
+	stop := Listen(func(e Event) { got <- e })
+
+	Publish(Event{Type: EventPairingCreated, BusinessID: "biz-1"})
+	select {
+	case e := <-got:
+		assert.Equal(t, EventPairingCreated, e.Type)
+		assert.Equal(t, "biz-1", e.BusinessID)
+	case <-time.After(time.Second):
+		t.Fatal("listener was not called")
+	}
+
+	stop()
+	Publish(Event{Type: EventPairingCreated})
+	select {
+	case e := <-got:
+		t.Fatalf("stopped listener got %v", e)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
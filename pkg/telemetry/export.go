@@ -0,0 +1,331 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// OTLP JSON payload types. Only the fields picoclaw emits are modelled;
+// 64-bit integers are encoded as strings as required by the OTLP JSON mapping.
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type otlpNumberPoint struct {
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	TimeUnixNano      string         `json:"timeUnixNano"`
+	AsInt             string         `json:"asInt"`
+}
+
+type otlpHistogramPoint struct {
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	TimeUnixNano      string         `json:"timeUnixNano"`
+	Count             string         `json:"count"`
+	Sum               float64        `json:"sum"`
+	BucketCounts      []string       `json:"bucketCounts"`
+	ExplicitBounds    []float64      `json:"explicitBounds"`
+}
+
+type otlpSum struct {
+	DataPoints             []otlpNumberPoint `json:"dataPoints"`
+	AggregationTemporality int               `json:"aggregationTemporality"`
+	IsMonotonic            bool              `json:"isMonotonic"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpNumberPoint `json:"dataPoints"`
+}
+
+type otlpHistogram struct {
+	DataPoints             []otlpHistogramPoint `json:"dataPoints"`
+	AggregationTemporality int                  `json:"aggregationTemporality"`
+}
+
+type otlpMetric struct {
+	Name      string         `json:"name"`
+	Unit      string         `json:"unit,omitempty"`
+	Sum       *otlpSum       `json:"sum,omitempty"`
+	Gauge     *otlpGauge     `json:"gauge,omitempty"`
+	Histogram *otlpHistogram `json:"histogram,omitempty"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpMetricsRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpTracesRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+// aggregationCumulative is OTLP's AGGREGATION_TEMPORALITY_CUMULATIVE.
+const aggregationCumulative = 2
+
+// Flush exports the current metric values and all queued spans.
+func (e *Exporter) Flush(ctx context.Context) {
+	metrics, spans, dropped := e.snapshot()
+
+	if len(metrics) > 0 {
+		if err := e.post(ctx, "/v1/metrics", e.metricsRequest(metrics)); err != nil {
+			logger.WarnCF("telemetry", "Metrics export failed", map[string]any{"error": err.Error()})
+		}
+	}
+	if len(spans) > 0 {
+		if err := e.post(ctx, "/v1/traces", e.tracesRequest(spans)); err != nil {
+			logger.WarnCF("telemetry", "Trace export failed",
+				map[string]any{"error": err.Error(), "spans": len(spans)})
+		}
+	}
+	if dropped > 0 {
+		logger.WarnCF("telemetry", "Dropped spans, export queue full", map[string]any{"dropped": dropped})
+	}
+}
+
+func (e *Exporter) snapshot() ([]otlpMetric, []*Span, int) {
+	now := nanos(time.Now())
+	start := nanos(e.start)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	byName := make(map[string]*otlpMetric)
+	var order []string
+	metricFor := func(name string) *otlpMetric {
+		m, ok := byName[name]
+		if !ok {
+			m = &otlpMetric{Name: name}
+			byName[name] = m
+			order = append(order, name)
+		}
+		return m
+	}
+
+	for _, c := range e.counters {
+		m := metricFor(c.name)
+		if m.Sum == nil {
+			m.Sum = &otlpSum{AggregationTemporality: aggregationCumulative, IsMonotonic: true}
+		}
+		m.Sum.DataPoints = append(m.Sum.DataPoints, otlpNumberPoint{
+			Attributes:        encodeAttrs(c.attrs),
+			StartTimeUnixNano: start,
+			TimeUnixNano:      now,
+			AsInt:             strconv.FormatInt(c.value, 10),
+		})
+	}
+	for _, g := range e.gauges {
+		m := metricFor(g.name)
+		if m.Gauge == nil {
+			m.Gauge = &otlpGauge{}
+		}
+		m.Gauge.DataPoints = append(m.Gauge.DataPoints, otlpNumberPoint{
+			Attributes:        encodeAttrs(g.attrs),
+			StartTimeUnixNano: start,
+			TimeUnixNano:      now,
+			AsInt:             strconv.FormatInt(g.value, 10),
+		})
+	}
+	for _, h := range e.histograms {
+		m := metricFor(h.name)
+		m.Unit = "ms"
+		if m.Histogram == nil {
+			m.Histogram = &otlpHistogram{AggregationTemporality: aggregationCumulative}
+		}
+		buckets := make([]string, len(h.buckets))
+		for i, n := range h.buckets {
+			buckets[i] = strconv.FormatUint(n, 10)
+		}
+		m.Histogram.DataPoints = append(m.Histogram.DataPoints, otlpHistogramPoint{
+			Attributes:        encodeAttrs(h.attrs),
+			StartTimeUnixNano: start,
+			TimeUnixNano:      now,
+			Count:             strconv.FormatUint(h.count, 10),
+			Sum:               h.sum,
+			BucketCounts:      buckets,
+			ExplicitBounds:    durationBuckets,
+		})
+	}
+
+	metrics := make([]otlpMetric, 0, len(order))
+	for _, name := range order {
+		metrics = append(metrics, *byName[name])
+	}
+
+	spans := e.spans
+	e.spans = nil
+	dropped := e.dropped
+	e.dropped = 0
+	return metrics, spans, dropped
+}
+
+func (e *Exporter) metricsRequest(metrics []otlpMetric) otlpMetricsRequest {
+	return otlpMetricsRequest{ResourceMetrics: []otlpResourceMetrics{{
+		Resource: otlpResource{Attributes: encodeAttrs(e.resource)},
+		ScopeMetrics: []otlpScopeMetrics{{
+			Scope:   otlpScope{Name: "picoclaw", Version: e.cfg.ServiceVersion},
+			Metrics: metrics,
+		}},
+	}}}
+}
+
+func (e *Exporter) tracesRequest(spans []*Span) otlpTracesRequest {
+	encoded := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		s.mu.Lock()
+		span := otlpSpan{
+			TraceID:           hex.EncodeToString(s.traceID[:]),
+			SpanID:            hex.EncodeToString(s.spanID[:]),
+			Name:              s.name,
+			Kind:              s.kind,
+			StartTimeUnixNano: nanos(s.start),
+			EndTimeUnixNano:   nanos(s.end),
+			Attributes:        encodeAttrs(s.attrs),
+		}
+		if s.parentID != ([8]byte{}) {
+			span.ParentSpanID = hex.EncodeToString(s.parentID[:])
+		}
+		if s.errMsg != "" {
+			span.Status = otlpStatus{Code: 2, Message: s.errMsg}
+		}
+		s.mu.Unlock()
+		encoded = append(encoded, span)
+	}
+
+	return otlpTracesRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: encodeAttrs(e.resource)},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: "picoclaw", Version: e.cfg.ServiceVersion},
+			Spans: encoded,
+		}},
+	}}}
+}
+
+func (e *Exporter) post(ctx context.Context, path string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode payload: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.cfg.Endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+func encodeAttrs(attrs []Attr) []otlpKeyValue {
+	if len(attrs) == 0 {
+		return nil
+	}
+	kvs := make([]otlpKeyValue, 0, len(attrs))
+	for _, a := range attrs {
+		var v otlpAnyValue
+		switch val := a.Value.(type) {
+		case int64:
+			s := strconv.FormatInt(val, 10)
+			v.IntValue = &s
+		case float64:
+			v.DoubleValue = &val
+		case bool:
+			v.BoolValue = &val
+		default:
+			s := attrString(val)
+			v.StringValue = &s
+		}
+		kvs = append(kvs, otlpKeyValue{Key: a.Key, Value: v})
+	}
+	return kvs
+}
+
+func attrString(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprint(v)
+}
+
+func nanos(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
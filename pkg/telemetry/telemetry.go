@@ -0,0 +1,342 @@
+// Package telemetry records gateway metrics and traces and ships them to an
+// OpenTelemetry collector over OTLP/HTTP (JSON encoding).
+//
+// All recording functions are no-ops until Start is called, so instrumented
+// code pays almost nothing when telemetry is disabled.
+package telemetry
+
+import (
+	"context"
+	"crypto/rand"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/httpclient"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// maxQueuedSpans bounds memory used by finished spans between exports.
+const maxQueuedSpans = 2048
+
+// durationBuckets are the histogram bounds, in milliseconds, used for all
+// duration metrics.
+var durationBuckets = []float64{5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000, 30000, 60000}
+
+// Config configures the exporter.
+type Config struct {
+	Endpoint       string            // collector base URL, e.g. http://collector:4318
+	Headers        map[string]string // added to every export request
+	SampleRatio    float64           // fraction of root spans to keep, 0..1
+	Interval       time.Duration     // export interval
+	ServiceName    string
+	ServiceVersion string
+	Resource       map[string]string // extra resource attributes
+}
+
+// Attr is a key/value attribute on a metric point or span.
+type Attr struct {
+	Key   string
+	Value any
+}
+
+// String returns a string attribute.
+func String(key, value string) Attr { return Attr{Key: key, Value: value} }
+
+// Int returns an integer attribute.
+func Int(key string, value int) Attr { return Attr{Key: key, Value: int64(value)} }
+
+// Bool returns a boolean attribute.
+func Bool(key string, value bool) Attr { return Attr{Key: key, Value: value} }
+
+// Exporter accumulates metrics and spans and periodically exports them.
+type Exporter struct {
+	cfg      Config
+	client   *http.Client
+	resource []Attr
+	start    time.Time
+
+	mu         sync.Mutex
+	counters   map[string]*counter
+	gauges     map[string]*counter
+	histograms map[string]*histogram
+	spans      []*Span
+	dropped    int
+}
+
+var active atomic.Pointer[Exporter]
+
+// Start enables telemetry and exports every cfg.Interval until ctx is done.
+// Call Shutdown to flush pending data before exit.
+func Start(ctx context.Context, cfg Config) *Exporter {
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Minute
+	}
+	if cfg.ServiceName == "" {
+		cfg.ServiceName = "picoclaw"
+	}
+	cfg.Endpoint = strings.TrimRight(cfg.Endpoint, "/")
+
+	e := &Exporter{
+		cfg:        cfg,
+		client:     httpclient.New(10 * time.Second),
+		start:      time.Now(),
+		counters:   make(map[string]*counter),
+		gauges:     make(map[string]*counter),
+		histograms: make(map[string]*histogram),
+	}
+
+	e.resource = []Attr{
+		String("service.name", cfg.ServiceName),
+		String("service.version", cfg.ServiceVersion),
+	}
+	if host, err := os.Hostname(); err == nil {
+		e.resource = append(e.resource, String("host.name", host))
+	}
+	keys := make([]string, 0, len(cfg.Resource))
+	for k := range cfg.Resource {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		e.resource = append(e.resource, String(k, cfg.Resource[k]))
+	}
+
+	active.Store(e)
+
+	go func() {
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				e.Flush(ctx)
+			}
+		}
+	}()
+
+	logger.InfoCF("telemetry", "OTLP export enabled",
+		map[string]any{
+			"endpoint":     cfg.Endpoint,
+			"sample_ratio": cfg.SampleRatio,
+			"interval":     cfg.Interval.String(),
+		})
+	return e
+}
+
+// Shutdown disables recording and flushes pending metrics and spans.
+func (e *Exporter) Shutdown(ctx context.Context) {
+	active.CompareAndSwap(e, nil)
+	e.Flush(ctx)
+}
+
+// AddCounter adds value to a cumulative counter.
+func AddCounter(name string, value int64, attrs ...Attr) {
+	e := active.Load()
+	if e == nil {
+		return
+	}
+	key, sorted := seriesKey(name, attrs)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	c, ok := e.counters[key]
+	if !ok {
+		c = &counter{name: name, attrs: sorted}
+		e.counters[key] = c
+	}
+	c.value += value
+}
+
+// SetGauge sets the current value of a gauge, such as a queue depth.
+func SetGauge(name string, value int64, attrs ...Attr) {
+	e := active.Load()
+	if e == nil {
+		return
+	}
+	key, sorted := seriesKey(name, attrs)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	g, ok := e.gauges[key]
+	if !ok {
+		g = &counter{name: name, attrs: sorted}
+		e.gauges[key] = g
+	}
+	g.value = value
+}
+
+// RecordDuration records a duration, in milliseconds, into a histogram.
+func RecordDuration(name string, d time.Duration, attrs ...Attr) {
+	e := active.Load()
+	if e == nil {
+		return
+	}
+	key, sorted := seriesKey(name, attrs)
+	ms := float64(d) / float64(time.Millisecond)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	h, ok := e.histograms[key]
+	if !ok {
+		h = &histogram{name: name, attrs: sorted, buckets: make([]uint64, len(durationBuckets)+1)}
+		e.histograms[key] = h
+	}
+	h.count++
+	h.sum += ms
+	i := sort.SearchFloat64s(durationBuckets, ms)
+	h.buckets[i]++
+}
+
+type counter struct {
+	name  string
+	attrs []Attr
+	value int64
+}
+
+type histogram struct {
+	name    string
+	attrs   []Attr
+	count   uint64
+	sum     float64
+	buckets []uint64
+}
+
+func seriesKey(name string, attrs []Attr) (string, []Attr) {
+	sorted := append([]Attr(nil), attrs...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Key < sorted[j].Key })
+
+	var sb strings.Builder
+	sb.WriteString(name)
+	for _, a := range sorted {
+		sb.WriteByte('|')
+		sb.WriteString(a.Key)
+		sb.WriteByte('=')
+		sb.WriteString(attrString(a.Value))
+	}
+	return sb.String(), sorted
+}
+
+// Span is a timed operation in a trace. A nil *Span is valid and ignores all
+// calls, which is what StartSpan returns when telemetry is disabled.
+type Span struct {
+	exporter *Exporter
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte
+	sampled  bool
+	name     string
+	kind     int
+	start    time.Time
+	end      time.Time
+	attrs    []Attr
+	errMsg   string
+	ended    bool
+	mu       sync.Mutex
+}
+
+// Span kinds, as defined by OTLP.
+const (
+	SpanKindInternal = 1
+	SpanKindServer   = 2
+	SpanKindClient   = 3
+)
+
+type spanKey struct{}
+
+// StartSpan starts a span as a child of the span in ctx, if any. Root spans
+// are sampled according to Config.SampleRatio; children follow their root.
+func StartSpan(ctx context.Context, name string, kind int, attrs ...Attr) (context.Context, *Span) {
+	e := active.Load()
+	if e == nil {
+		return ctx, nil
+	}
+
+	s := &Span{
+		exporter: e,
+		name:     name,
+		kind:     kind,
+		start:    time.Now(),
+		attrs:    attrs,
+	}
+	rand.Read(s.spanID[:])
+	if parent, ok := ctx.Value(spanKey{}).(*Span); ok && parent != nil {
+		s.traceID = parent.traceID
+		s.parentID = parent.spanID
+		s.sampled = parent.sampled
+	} else {
+		rand.Read(s.traceID[:])
+		s.sampled = sampled(s.traceID, e.cfg.SampleRatio)
+	}
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// sampled makes a deterministic decision from the trace ID, so the same
+// trace is kept or dropped consistently.
+func sampled(traceID [16]byte, ratio float64) bool {
+	if ratio >= 1 {
+		return true
+	}
+	if ratio <= 0 {
+		return false
+	}
+	var v uint64
+	for _, b := range traceID[8:] {
+		v = v<<8 | uint64(b)
+	}
+	return float64(v) < ratio*math.MaxUint64
+}
+
+// SetAttributes adds attributes to the span.
+func (s *Span) SetAttributes(attrs ...Attr) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.attrs = append(s.attrs, attrs...)
+	s.mu.Unlock()
+}
+
+// RecordError marks the span as failed.
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.errMsg = err.Error()
+	s.mu.Unlock()
+}
+
+// End finishes the span and queues it for export if it was sampled.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mu.Unlock()
+
+	if !s.sampled {
+		return
+	}
+	e := s.exporter
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.spans) >= maxQueuedSpans {
+		e.dropped++
+		return
+	}
+	e.spans = append(e.spans, s)
+}
@@ -0,0 +1,131 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type collector struct {
+	mu       sync.Mutex
+	requests map[string][]map[string]any
+	headers  http.Header
+}
+
+func newCollector(t *testing.T) (*collector, *httptest.Server) {
+	c := &collector{requests: make(map[string][]map[string]any)}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var payload map[string]any
+		require.NoError(t, json.Unmarshal(body, &payload))
+		c.mu.Lock()
+		c.requests[r.URL.Path] = append(c.requests[r.URL.Path], payload)
+		c.headers = r.Header.Clone()
+		c.mu.Unlock()
+	}))
+	t.Cleanup(srv.Close)
+	return c, srv
+}
+
+func TestDisabledIsNoop(t *testing.T) {
+	AddCounter("picoclaw.test", 1)
+	SetGauge("picoclaw.test.depth", 1)
+	RecordDuration("picoclaw.test.duration", time.Second)
+	ctx, span := StartSpan(context.Background(), "noop", SpanKindInternal)
+	assert.Nil(t, span)
+	assert.NotNil(t, ctx)
+	span.SetAttributes(String("k", "v"))
+	span.RecordError(errors.New("ignored"))
+	span.End()
+}
+
+func TestExportMetricsAndTraces(t *testing.T) {
+	c, srv := newCollector(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	exp := Start(ctx, Config{
+		Endpoint:       srv.URL + "/",
+		Headers:        map[string]string{"Authorization": "Bearer secret"},
+		SampleRatio:    1,
+		Interval:       time.Hour,
+		ServiceVersion: "1.2.3",
+		Resource:       map[string]string{"deployment.environment": "test"},
+	})
+	defer exp.Shutdown(context.Background())
+
+	AddCounter("picoclaw.messages", 2, String("channel", "telegram"))
+	AddCounter("picoclaw.messages", 3, String("channel", "telegram"))
+	RecordDuration("picoclaw.llm.duration", 120*time.Millisecond, String("model", "m"))
+	SetGauge("picoclaw.queue.depth", 4)
+	SetGauge("picoclaw.queue.depth", 1)
+
+	rootCtx, root := StartSpan(context.Background(), "agent.run", SpanKindServer, String("channel", "telegram"))
+	_, child := StartSpan(rootCtx, "tool.execute", SpanKindInternal)
+	child.RecordError(errors.New("boom"))
+	child.End()
+	root.End()
+
+	exp.Flush(context.Background())
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	assert.Equal(t, "Bearer secret", c.headers.Get("Authorization"))
+	assert.Equal(t, "application/json", c.headers.Get("Content-Type"))
+
+	require.Len(t, c.requests["/v1/metrics"], 1)
+	rm := c.requests["/v1/metrics"][0]["resourceMetrics"].([]any)[0].(map[string]any)
+	attrs := rm["resource"].(map[string]any)["attributes"].([]any)
+	assert.Contains(t, attrs, map[string]any{"key": "service.version", "value": map[string]any{"stringValue": "1.2.3"}})
+	assert.Contains(t, attrs, map[string]any{"key": "deployment.environment", "value": map[string]any{"stringValue": "test"}})
+
+	metrics := rm["scopeMetrics"].([]any)[0].(map[string]any)["metrics"].([]any)
+	byName := map[string]map[string]any{}
+	for _, m := range metrics {
+		byName[m.(map[string]any)["name"].(string)] = m.(map[string]any)
+	}
+	sum := byName["picoclaw.messages"]["sum"].(map[string]any)
+	assert.Equal(t, "5", sum["dataPoints"].([]any)[0].(map[string]any)["asInt"])
+	gauge := byName["picoclaw.queue.depth"]["gauge"].(map[string]any)
+	assert.Equal(t, "1", gauge["dataPoints"].([]any)[0].(map[string]any)["asInt"])
+	hist := byName["picoclaw.llm.duration"]["histogram"].(map[string]any)
+	assert.Equal(t, "1", hist["dataPoints"].([]any)[0].(map[string]any)["count"])
+
+	require.Len(t, c.requests["/v1/traces"], 1)
+	spans := c.requests["/v1/traces"][0]["resourceSpans"].([]any)[0].(map[string]any)["scopeSpans"].([]any)[0].(map[string]any)["spans"].([]any)
+	require.Len(t, spans, 2)
+	childSpan, rootSpan := spans[0].(map[string]any), spans[1].(map[string]any)
+	assert.Equal(t, "tool.execute", childSpan["name"])
+	assert.Equal(t, rootSpan["traceId"], childSpan["traceId"])
+	assert.Equal(t, rootSpan["spanId"], childSpan["parentSpanId"])
+	assert.Equal(t, float64(2), childSpan["status"].(map[string]any)["code"])
+}
+
+func TestSampling(t *testing.T) {
+	_, srv := newCollector(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	exp := Start(ctx, Config{Endpoint: srv.URL, SampleRatio: 0, Interval: time.Hour})
+	defer exp.Shutdown(context.Background())
+
+	rootCtx, root := StartSpan(context.Background(), "dropped", SpanKindInternal)
+	_, child := StartSpan(rootCtx, "dropped.child", SpanKindInternal)
+	child.End()
+	root.End()
+
+	_, spans, _ := exp.snapshot()
+	assert.Empty(t, spans)
+
+	var id [16]byte
+	assert.True(t, sampled(id, 1))
+	assert.False(t, sampled(id, 0))
+}
@@ -0,0 +1,182 @@
+// Package tokenizer counts tokens locally, so context-window checks and cost
+// estimates don't need a round trip to the provider.
+//
+// Text is split like tiktoken's cl100k_base encoding. With a vocabulary file
+// in tiktoken's format (such as cl100k_base.tiktoken) each piece is then
+// byte-pair encoded and counts match tiktoken's. Without one, each piece's
+// token count is estimated from its length, which is close enough for
+// context-window checks and costs no memory.
+package tokenizer
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"unicode"
+	"unicode/utf8"
+)
+
+// piecePattern is cl100k_base's pre-tokenization without the `\s+(?!\S)`
+// alternative, which RE2 cannot express; split emulates it.
+var piecePattern = regexp.MustCompile(`^(?:` +
+	`(?i:'s|'t|'re|'ve|'m|'ll|'d)` +
+	`|[^\r\n\p{L}\p{N}]?\p{L}+` +
+	`|\p{N}{1,3}` +
+	`| ?[^\s\p{L}\p{N}]+[\r\n]*` +
+	`|\s*[\r\n]+` +
+	`|\s+)`)
+
+// Encoding is a byte-pair vocabulary. A nil *Encoding estimates counts.
+type Encoding struct {
+	ranks map[string]int
+}
+
+// Load reads a vocabulary file in tiktoken's format.
+func Load(path string) (*Encoding, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open tokenizer vocabulary: %w", err)
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Parse reads a vocabulary in tiktoken's format: one base64 token and its
+// rank per line.
+func Parse(r io.Reader) (*Encoding, error) {
+	ranks := make(map[string]int)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		token, rank, ok := strings.Cut(strings.TrimSpace(scanner.Text()), " ")
+		if !ok {
+			if token == "" {
+				continue
+			}
+			return nil, fmt.Errorf("tokenizer vocabulary line %d: expected token and rank", line)
+		}
+		raw, err := base64.StdEncoding.DecodeString(token)
+		if err != nil {
+			return nil, fmt.Errorf("tokenizer vocabulary line %d: %w", line, err)
+		}
+		n, err := strconv.Atoi(rank)
+		if err != nil {
+			return nil, fmt.Errorf("tokenizer vocabulary line %d: %w", line, err)
+		}
+		ranks[string(raw)] = n
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read tokenizer vocabulary: %w", err)
+	}
+	if len(ranks) == 0 {
+		return nil, fmt.Errorf("tokenizer vocabulary is empty")
+	}
+	return &Encoding{ranks: ranks}, nil
+}
+
+// Count returns the number of tokens in text.
+func (e *Encoding) Count(text string) int {
+	n := 0
+	split(text, func(piece string) {
+		if e == nil {
+			n += estimate(piece)
+		} else {
+			n += e.bytePairCount(piece)
+		}
+	})
+	return n
+}
+
+// bytePairCount merges the lowest-ranked adjacent pair of parts until no
+// pair is in the vocabulary, as tiktoken does, and returns the parts left.
+func (e *Encoding) bytePairCount(piece string) int {
+	if _, ok := e.ranks[piece]; ok {
+		return 1
+	}
+	// bounds[i] is where the i-th part starts; the last entry is the end.
+	bounds := make([]int, len(piece)+1)
+	for i := range bounds {
+		bounds[i] = i
+	}
+	for len(bounds) > 2 {
+		best, bestRank := -1, math.MaxInt
+		for i := 0; i+2 < len(bounds); i++ {
+			if rank, ok := e.ranks[piece[bounds[i]:bounds[i+2]]]; ok && rank < bestRank {
+				best, bestRank = i, rank
+			}
+		}
+		if best < 0 {
+			break
+		}
+		bounds = append(bounds[:best+1], bounds[best+2:]...)
+	}
+	return len(bounds) - 1
+}
+
+// estimate guesses the token count of a piece: common ASCII words are one
+// token up to about eight bytes with their leading space, and other scripts
+// take about one token per three bytes (one CJK character).
+func estimate(piece string) int {
+	perToken := 8
+	for i := 0; i < len(piece); i++ {
+		if piece[i] >= utf8.RuneSelf {
+			perToken = 3
+			break
+		}
+	}
+	return (len(piece) + perToken - 1) / perToken
+}
+
+// split calls fn with each pre-tokenization piece of text.
+func split(text string, fn func(piece string)) {
+	for len(text) > 0 {
+		loc := piecePattern.FindStringIndex(text)
+		if loc == nil {
+			// Unreachable, since the pattern matches any character.
+			_, size := utf8.DecodeRuneInString(text)
+			loc = []int{0, size}
+		}
+		end := loc[1]
+		// `\s+(?!\S)`: a run of spaces before a word leaves its last space
+		// to the word.
+		if end < len(text) && isSpaceRun(text[:end]) {
+			_, last := utf8.DecodeLastRuneInString(text[:end])
+			if end-last > 0 {
+				end -= last
+			}
+		}
+		fn(text[:end])
+		text = text[end:]
+	}
+}
+
+// isSpaceRun reports whether s is whitespace not ending in a line break.
+func isSpaceRun(s string) bool {
+	if strings.HasSuffix(s, "\n") || strings.HasSuffix(s, "\r") {
+		return false
+	}
+	for _, r := range s {
+		if !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+var defaultEncoding atomic.Pointer[Encoding]
+
+// SetDefault sets the vocabulary Count uses; nil returns to estimates.
+func SetDefault(e *Encoding) {
+	defaultEncoding.Store(e)
+}
+
+// Count returns the number of tokens in text with the default vocabulary.
+func Count(text string) int {
+	return defaultEncoding.Load().Count(text)
+}
@@ -0,0 +1,74 @@
+package tokenizer
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func pieces(text string) []string {
+	var out []string
+	split(text, func(p string) { out = append(out, p) })
+	return out
+}
+
+func TestSplitMatchesCl100kPreTokenization(t *testing.T) {
+	assert.Equal(t, []string{"Hello", " world", "!!"}, pieces("Hello world!!"))
+	assert.Equal(t, []string{"a", " ", " b"}, pieces("a  b"))
+	assert.Equal(t, []string{"don", "'t", " stop"}, pieces("don't stop"))
+	assert.Equal(t, []string{"123", "45", " ", "6"}, pieces("12345 6"))
+	assert.Equal(t, []string{"line", "\n", " ", " next"}, pieces("line\n  next"))
+	assert.Equal(t, []string{"end", "  "}, pieces("end  "))
+	assert.Equal(t, "收据总额", strings.Join(pieces("收据总额"), ""))
+}
+
+func vocab(tokens ...string) string {
+	var b strings.Builder
+	for rank, tok := range tokens {
+		fmt.Fprintf(&b, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(tok)), rank)
+	}
+	return b.String()
+}
+
+func TestEncodingCountsBytePairs(t *testing.T) {
+	enc, err := Parse(strings.NewReader(vocab("a", "b", "c", " ", "ab", "abc", " a")))
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, enc.Count("abc"))
+	assert.Equal(t, 2, enc.Count("abcab"))  // abc + ab
+	assert.Equal(t, 3, enc.Count("abc ab")) // abc, " a", b
+	assert.Equal(t, 0, enc.Count(""))
+}
+
+func TestLoadRejectsMalformedVocabulary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.tiktoken")
+	require.NoError(t, os.WriteFile(path, []byte("not-base64!! 1\n"), 0o600))
+	_, err := Load(path)
+	assert.Error(t, err)
+
+	_, err = Load(filepath.Join(t.TempDir(), "missing.tiktoken"))
+	assert.Error(t, err)
+}
+
+func TestEstimateWithoutVocabulary(t *testing.T) {
+	var enc *Encoding
+	assert.Equal(t, 0, enc.Count(""))
+	assert.Equal(t, 4, enc.Count("The receipt total is"))
+	assert.Equal(t, 4, enc.Count("收据总额"))
+}
+
+func TestDefaultEncoding(t *testing.T) {
+	t.Cleanup(func() { SetDefault(nil) })
+	assert.Equal(t, 2, Count("hello world"))
+
+	enc, err := Parse(strings.NewReader(vocab("h", "e", "l", "o", "he", "ll", "hell", "hello")))
+	require.NoError(t, err)
+	SetDefault(enc)
+	assert.Equal(t, 1, Count("hello"))
+}
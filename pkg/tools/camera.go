@@ -0,0 +1,168 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/media"
+)
+
+const (
+	CameraBackendLibcamera = "libcamera"
+	CameraBackendV4L2      = "v4l2"
+
+	captureTimeout = 30 * time.Second
+)
+
+// CapturePhotoTool takes a picture with the device's camera and stores it
+// like an uploaded photo, so that a receipt can be scanned without a phone.
+// Camera modules on the CSI connector are read with libcamera (rpicam-still
+// or libcamera-still), USB webcams through V4L2 (fswebcam or ffmpeg).
+type CapturePhotoTool struct {
+	cfg       config.CameraConfig
+	workspace string
+	library   *media.Library // nil: photos are written to <workspace>/media
+	nowFunc   func() time.Time
+}
+
+// NewCapturePhotoTool creates a capture_photo tool. library may be nil.
+func NewCapturePhotoTool(cfg config.CameraConfig, workspace string, library *media.Library) *CapturePhotoTool {
+	return &CapturePhotoTool{cfg: cfg, workspace: workspace, library: library, nowFunc: time.Now}
+}
+
+func (t *CapturePhotoTool) Name() string {
+	return "capture_photo"
+}
+
+func (t *CapturePhotoTool) Description() string {
+	return "Take a photo with the device's camera, e.g. when the user asks to scan a receipt held in front of it. " +
+		"Returns the path of the saved image for processing."
+}
+
+func (t *CapturePhotoTool) Parameters() map[string]any {
+	return map[string]any{
+		"type":       "object",
+		"properties": map[string]any{},
+	}
+}
+
+func (t *CapturePhotoTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	tmp, err := os.CreateTemp("", "picoclaw-photo-*.jpg")
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to create temp file: %v", err)).WithError(err)
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+	name, cmdArgs, err := t.command(tmp.Name())
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, captureTimeout)
+	defer cancel()
+	if out, err := exec.CommandContext(ctx, name, cmdArgs...).CombinedOutput(); err != nil {
+		return ErrorResult(fmt.Sprintf("%s failed: %v: %s", name, err, strings.TrimSpace(string(out)))).WithError(err)
+	}
+	info, err := os.Stat(tmp.Name())
+	if err != nil || info.Size() == 0 {
+		return ErrorResult(fmt.Sprintf("%s produced no image (is a camera connected?)", name))
+	}
+
+	path, err := t.save(ctx, tmp.Name())
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to save photo: %v", err)).WithError(err)
+	}
+	return &ToolResult{
+		ForLLM: fmt.Sprintf("Captured photo %s (%d bytes)\n"+
+			"IMPORTANT: This is a binary image. Do NOT use read_file on it. "+
+			"Use the exec tool to run the appropriate skill script for processing. "+
+			"For receipts, run: ~/.picoclaw/skills/oluto/scripts/oluto-receipt.sh %s", path, info.Size(), path),
+		ForUser: "📷 Photo taken",
+	}
+}
+
+// save stores the captured image in the media library, or the media
+// directory without one, and returns its path.
+func (t *CapturePhotoTool) save(ctx context.Context, tmp string) (string, error) {
+	filename := "photo-" + t.nowFunc().Format("20060102-150405") + ".jpg"
+	if t.library != nil {
+		f, err := os.Open(tmp)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		businessID, _ := ctx.Value(constants.ContextKeyBusinessID).(string)
+		path, _, err := t.library.Save(f, filename, businessID)
+		return path, err
+	}
+
+	dir := filepath.Join(t.workspace, "media")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(tmp)
+	if err != nil {
+		return "", err
+	}
+	path := uniquePath(filepath.Join(dir, filename))
+	return path, os.WriteFile(path, data, 0o600)
+}
+
+// command returns the program and arguments that capture a JPEG to out with
+// the configured backend, or the first one installed.
+func (t *CapturePhotoTool) command(out string) (string, []string, error) {
+	width, height := strconv.Itoa(t.cfg.Width), strconv.Itoa(t.cfg.Height)
+
+	backend := t.cfg.Backend
+	if backend == "" {
+		backend = CameraBackendV4L2
+		if lookPathAny("rpicam-still", "libcamera-still") != "" {
+			backend = CameraBackendLibcamera
+		}
+	}
+	switch backend {
+	case CameraBackendLibcamera:
+		name := lookPathAny("rpicam-still", "libcamera-still")
+		if name == "" {
+			return "", nil, errors.New("libcamera needs rpicam-still or libcamera-still installed")
+		}
+		// -t gives the sensor a second to settle exposure and white balance.
+		return name, []string{"-n", "-t", "1000", "--width", width, "--height", height, "-e", "jpg", "-o", out}, nil
+	case CameraBackendV4L2:
+		device := t.cfg.Device
+		if !strings.HasPrefix(device, "/dev/video") {
+			return "", nil, fmt.Errorf("invalid camera device %q (e.g. /dev/video0)", device)
+		}
+		if name := lookPathAny("fswebcam"); name != "" {
+			return name, []string{"-q", "-d", device, "-r", width + "x" + height, "--no-banner",
+				"-S", "10", "--jpeg", "90", out}, nil
+		}
+		if name := lookPathAny("ffmpeg"); name != "" {
+			return name, []string{"-hide_banner", "-loglevel", "error", "-f", "video4linux2",
+				"-video_size", width + "x" + height, "-i", device, "-ss", "1", "-frames:v", "1", "-q:v", "2",
+				"-y", out}, nil
+		}
+		return "", nil, errors.New("V4L2 capture needs fswebcam or ffmpeg installed")
+	default:
+		return "", nil, fmt.Errorf("unknown camera backend %q (valid: libcamera, v4l2)", backend)
+	}
+}
+
+// lookPathAny returns the first of names found on PATH.
+func lookPathAny(names ...string) string {
+	for _, name := range names {
+		if _, err := exec.LookPath(name); err == nil {
+			return name
+		}
+	}
+	return ""
+}
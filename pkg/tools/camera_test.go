@@ -0,0 +1,83 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/media"
+)
+
+// fakeCamera makes a script named name, which writes a fake JPEG to the path
+// after -o or its last argument, the only program on PATH.
+func fakeCamera(t *testing.T, name string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	dir := t.TempDir()
+	script := `#!/bin/sh
+for arg; do out="$arg"; done
+printf 'JPEG' > "$out"
+`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(script), 0o755))
+	t.Setenv("PATH", dir)
+}
+
+func testCameraConfig() config.CameraConfig {
+	return config.CameraConfig{Enabled: true, Device: "/dev/video0", Width: 1920, Height: 1080}
+}
+
+func TestCapturePhotoToLibrary(t *testing.T) {
+	fakeCamera(t, "rpicam-still")
+	workspace := t.TempDir()
+	library, err := media.NewLibrary(workspace, media.Options{})
+	require.NoError(t, err)
+
+	tool := NewCapturePhotoTool(testCameraConfig(), workspace, library)
+	result := tool.Execute(context.Background(), map[string]any{})
+	require.False(t, result.IsError, result.ForLLM)
+
+	path := strings.Fields(strings.TrimPrefix(result.ForLLM, "Captured photo "))[0]
+	assert.True(t, strings.HasPrefix(path, library.Dir()), "stored in the media library")
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "JPEG", string(data))
+	assert.Contains(t, result.ForLLM, "oluto-receipt.sh "+path)
+}
+
+func TestCapturePhotoCommand(t *testing.T) {
+	fakeCamera(t, "fswebcam")
+	tool := NewCapturePhotoTool(testCameraConfig(), t.TempDir(), nil)
+	name, args, err := tool.command("/tmp/out.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, "fswebcam", name, "V4L2 without libcamera installed")
+	assert.Equal(t, []string{"-q", "-d", "/dev/video0", "-r", "1920x1080", "--no-banner", "-S", "10",
+		"--jpeg", "90", "/tmp/out.jpg"}, args)
+
+	tool.cfg.Backend = CameraBackendLibcamera
+	_, _, err = tool.command("/tmp/out.jpg")
+	assert.ErrorContains(t, err, "rpicam-still")
+
+	tool.cfg.Backend = CameraBackendV4L2
+	tool.cfg.Device = "/etc/passwd"
+	_, _, err = tool.command("/tmp/out.jpg")
+	assert.ErrorContains(t, err, "invalid camera device")
+}
+
+func TestCapturePhotoWithoutLibrary(t *testing.T) {
+	fakeCamera(t, "ffmpeg")
+	workspace := t.TempDir()
+	tool := NewCapturePhotoTool(testCameraConfig(), workspace, nil)
+	result := tool.Execute(context.Background(), map[string]any{})
+	require.False(t, result.IsError, result.ForLLM)
+	matches, _ := filepath.Glob(filepath.Join(workspace, "media", "photo-*.jpg"))
+	assert.Len(t, matches, 1)
+}
@@ -0,0 +1,139 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/categorize"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/constants"
+)
+
+// CategoryRules stores the categorization rules of each business.
+type CategoryRules interface {
+	// CategoryRules returns every rule that applies to a business, in the
+	// order they are tried, starting with the learned ones.
+	CategoryRules(businessID string) []config.CategoryRule
+	// LearnedCategoryRules returns the rules the business was taught.
+	LearnedCategoryRules(businessID string) []config.CategoryRule
+	LearnCategoryRule(businessID string, rule config.CategoryRule) error
+	ForgetCategoryRule(businessID string, n int) error
+}
+
+// CategoryRulesTool lets the agent remember how a business wants expenses
+// categorized, as in "always categorize Shell as Fuel". The rules are
+// applied to receipts before any model is asked.
+type CategoryRulesTool struct {
+	rules CategoryRules
+}
+
+// NewCategoryRulesTool creates a category_rules tool backed by rules.
+func NewCategoryRulesTool(rules CategoryRules) *CategoryRulesTool {
+	return &CategoryRulesTool{rules: rules}
+}
+
+func (t *CategoryRulesTool) Name() string {
+	return "category_rules"
+}
+
+func (t *CategoryRulesTool) Description() string {
+	return "Remember, list or forget how this business categorizes expenses. Use 'add' when the user says " +
+		"something like 'always categorize Shell as Fuel' (merchant=Shell, category=Fuel); future receipts " +
+		"matching the rule get that category without a suggestion. Use the category names LedgerForge uses."
+}
+
+func (t *CategoryRulesTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"action": map[string]any{
+				"type":        "string",
+				"enum":        []string{"add", "list", "remove"},
+				"description": "add a rule, list the rules, or remove a learned rule",
+			},
+			"merchant": map[string]any{
+				"type":        "string",
+				"description": "For add: merchant name, matched as a whole word in the vendor",
+			},
+			"keyword": map[string]any{
+				"type":        "string",
+				"description": "For add: word or phrase the receipt text must contain",
+			},
+			"min_amount": map[string]any{
+				"type":        "number",
+				"description": "For add: smallest total the rule applies to",
+			},
+			"max_amount": map[string]any{
+				"type":        "number",
+				"description": "For add: largest total the rule applies to",
+			},
+			"category": map[string]any{
+				"type":        "string",
+				"description": "For add: the category to use",
+			},
+			"number": map[string]any{
+				"type":        "integer",
+				"description": "For remove: the number of the learned rule, as listed",
+				"minimum":     1.0,
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+func (t *CategoryRulesTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	businessID, _ := ctx.Value(constants.ContextKeyBusinessID).(string)
+	if businessID == "" {
+		return ErrorResult("no business is selected, so there are no category rules")
+	}
+
+	action, _ := args["action"].(string)
+	switch action {
+	case "add":
+		merchant, _ := args["merchant"].(string)
+		category, _ := args["category"].(string)
+		rule := config.CategoryRule{Category: strings.TrimSpace(category)}
+		if strings.TrimSpace(merchant) != "" {
+			rule = categorize.MerchantRule(merchant, category)
+		}
+		keyword, _ := args["keyword"].(string)
+		rule.Keyword = strings.TrimSpace(keyword)
+		rule.MinAmount, _ = args["min_amount"].(float64)
+		rule.MaxAmount, _ = args["max_amount"].(float64)
+		if err := t.rules.LearnCategoryRule(businessID, rule); err != nil {
+			return ErrorResult(fmt.Sprintf("rule not saved: %v", err))
+		}
+		return SilentResult("Saved: " + categorize.Describe(rule))
+
+	case "list":
+		learned := t.rules.LearnedCategoryRules(businessID)
+		all := t.rules.CategoryRules(businessID)
+		if len(all) == 0 {
+			return SilentResult("This business has no category rules.")
+		}
+		var b strings.Builder
+		b.WriteString("Category rules, tried in this order:\n")
+		for i, r := range all {
+			if i < len(learned) {
+				fmt.Fprintf(&b, "%d. %s\n", i+1, categorize.Describe(r))
+			} else {
+				fmt.Fprintf(&b, "-  %s (configured)\n", categorize.Describe(r))
+			}
+		}
+		return SilentResult(b.String())
+
+	case "remove":
+		n, ok := args["number"].(float64)
+		if !ok {
+			return ErrorResult("number is required for remove")
+		}
+		if err := t.rules.ForgetCategoryRule(businessID, int(n)-1); err != nil {
+			return ErrorResult(err.Error())
+		}
+		return SilentResult(fmt.Sprintf("Removed rule %d.", int(n)))
+
+	default:
+		return ErrorResult("action must be add, list or remove")
+	}
+}
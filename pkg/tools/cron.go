@@ -8,7 +8,9 @@ import (
 
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/constants"
 	"github.com/sipeed/picoclaw/pkg/cron"
+	"github.com/sipeed/picoclaw/pkg/locale"
 	"github.com/sipeed/picoclaw/pkg/utils"
 )
 
@@ -23,6 +25,7 @@ type CronTool struct {
 	executor    JobExecutor
 	msgBus      *bus.MessageBus
 	execTool    *ExecTool
+	locales     *locale.Resolver
 	channel     string
 	chatID      string
 	mu          sync.RWMutex
@@ -36,12 +39,16 @@ func NewCronTool(
 ) *CronTool {
 	execTool := NewExecToolWithConfig(workspace, restrict, config)
 	execTool.SetTimeout(execTimeout)
-	return &CronTool{
+	t := &CronTool{
 		cronService: cronService,
 		executor:    executor,
 		msgBus:      msgBus,
 		execTool:    execTool,
 	}
+	if config != nil {
+		t.locales = locale.NewResolver(config.Locale)
+	}
+	return t
 }
 
 // Name returns the tool name
@@ -51,7 +58,7 @@ func (t *CronTool) Name() string {
 
 // Description returns the tool description
 func (t *CronTool) Description() string {
-	return "Schedule reminders, tasks, or system commands. IMPORTANT: When user asks to be reminded or scheduled, you MUST call this tool. Use 'at_seconds' for one-time reminders (e.g., 'remind me in 10 minutes' → at_seconds=600). Use 'every_seconds' ONLY for recurring tasks (e.g., 'every 2 hours' → every_seconds=7200). Use 'cron_expr' for complex recurring schedules. Use 'command' to execute shell commands directly."
+	return "Schedule reminders, tasks, or system commands. IMPORTANT: When user asks to be reminded or scheduled, you MUST call this tool. Use 'at_seconds' for one-time reminders (e.g., 'remind me in 10 minutes' → at_seconds=600). Use 'at' for one-time reminders at a time of day (e.g., 'remind me tomorrow at 9' → at='tomorrow at 9'). Use 'every_seconds' ONLY for recurring tasks (e.g., 'every 2 hours' → every_seconds=7200). Use 'cron_expr' for complex recurring schedules. Use 'command' to execute shell commands directly."
 }
 
 // Parameters returns the tool parameters schema
@@ -76,13 +83,17 @@ func (t *CronTool) Parameters() map[string]any {
 				"type":        "integer",
 				"description": "One-time reminder: seconds from now when to trigger (e.g., 600 for 10 minutes later). Use this for one-time reminders like 'remind me in 10 minutes'.",
 			},
+			"at": map[string]any{
+				"type":        "string",
+				"description": "One-time reminder at a local time in the user's timezone, e.g. 'tomorrow at 9', 'friday 14:30', '9pm' or '2026-03-02 09:00'. Use this when the user names a time of day rather than a delay.",
+			},
 			"every_seconds": map[string]any{
 				"type":        "integer",
 				"description": "Recurring interval in seconds (e.g., 3600 for every hour). Use this ONLY for recurring tasks like 'every 2 hours' or 'daily reminder'.",
 			},
 			"cron_expr": map[string]any{
 				"type":        "string",
-				"description": "Cron expression for complex recurring schedules (e.g., '0 9 * * *' for daily at 9am). Use this for complex recurring schedules.",
+				"description": "Cron expression for complex recurring schedules (e.g., '0 9 * * *' for daily at 9am), in the user's timezone. Use this for complex recurring schedules.",
 			},
 			"job_id": map[string]any{
 				"type":        "string",
@@ -114,7 +125,7 @@ func (t *CronTool) Execute(ctx context.Context, args map[string]any) *ToolResult
 
 	switch action {
 	case "add":
-		return t.addJob(args)
+		return t.addJob(ctx, args)
 	case "list":
 		return t.listJobs()
 	case "remove":
@@ -128,7 +139,22 @@ func (t *CronTool) Execute(ctx context.Context, args map[string]any) *ToolResult
 	}
 }
 
-func (t *CronTool) addJob(args map[string]any) *ToolResult {
+// location returns the zone of the user and business of ctx, and its IANA
+// name, or "" if none is configured.
+func (t *CronTool) location(ctx context.Context) (*time.Location, string) {
+	businessID, _ := ctx.Value(constants.ContextKeyBusinessID).(string)
+	userID, _ := ctx.Value(constants.ContextKeySenderID).(string)
+	if userID == "" {
+		userID, _ = ctx.Value(constants.ContextKeyUserID).(string)
+	}
+	loc := t.locales.Location(businessID, userID)
+	if loc == time.Local {
+		return loc, ""
+	}
+	return loc, loc.String()
+}
+
+func (t *CronTool) addJob(ctx context.Context, args map[string]any) *ToolResult {
 	t.mu.RLock()
 	channel := t.channel
 	chatID := t.chatID
@@ -144,19 +170,33 @@ func (t *CronTool) addJob(args map[string]any) *ToolResult {
 	}
 
 	var schedule cron.CronSchedule
+	loc, tz := t.location(ctx)
 
-	// Check for at_seconds (one-time), every_seconds (recurring), or cron_expr
+	// Check for at_seconds or at (one-time), every_seconds (recurring), or cron_expr
 	atSeconds, hasAt := args["at_seconds"].(float64)
+	atText, hasAtText := args["at"].(string)
 	everySeconds, hasEvery := args["every_seconds"].(float64)
 	cronExpr, hasCron := args["cron_expr"].(string)
 
-	// Priority: at_seconds > every_seconds > cron_expr
+	// Priority: at_seconds > at > every_seconds > cron_expr
+	var runAt time.Time
 	if hasAt {
 		atMS := time.Now().UnixMilli() + int64(atSeconds)*1000
 		schedule = cron.CronSchedule{
 			Kind: "at",
 			AtMS: &atMS,
 		}
+	} else if hasAtText && atText != "" {
+		var err error
+		if runAt, err = cron.ParseTime(atText, time.Now().In(loc)); err != nil {
+			return ErrorResult(err.Error())
+		}
+		atMS := runAt.UnixMilli()
+		schedule = cron.CronSchedule{
+			Kind: "at",
+			AtMS: &atMS,
+			TZ:   tz,
+		}
 	} else if hasEvery {
 		everyMS := int64(everySeconds) * 1000
 		schedule = cron.CronSchedule{
@@ -167,9 +207,10 @@ func (t *CronTool) addJob(args map[string]any) *ToolResult {
 		schedule = cron.CronSchedule{
 			Kind: "cron",
 			Expr: cronExpr,
+			TZ:   tz,
 		}
 	} else {
-		return ErrorResult("one of at_seconds, every_seconds, or cron_expr is required")
+		return ErrorResult("one of at_seconds, at, every_seconds, or cron_expr is required")
 	}
 
 	// Read deliver parameter, default to true
@@ -208,6 +249,10 @@ func (t *CronTool) addJob(args map[string]any) *ToolResult {
 		t.cronService.UpdateJob(job)
 	}
 
+	if !runAt.IsZero() {
+		return SilentResult(fmt.Sprintf("Cron job added: %s (id: %s, runs %s)",
+			job.Name, job.ID, runAt.Format("Monday 2006-01-02 15:04 MST")))
+	}
 	return SilentResult(fmt.Sprintf("Cron job added: %s (id: %s)", job.Name, job.ID))
 }
 
@@ -225,6 +270,9 @@ func (t *CronTool) listJobs() *ToolResult {
 			scheduleInfo = fmt.Sprintf("every %ds", *j.Schedule.EveryMS/1000)
 		} else if j.Schedule.Kind == "cron" {
 			scheduleInfo = j.Schedule.Expr
+			if j.Schedule.TZ != "" {
+				scheduleInfo += " " + j.Schedule.TZ
+			}
 		} else if j.Schedule.Kind == "at" {
 			scheduleInfo = "one-time"
 		} else {
@@ -295,9 +343,10 @@ func (t *CronTool) ExecuteJob(ctx context.Context, job *cron.CronJob) string {
 		}
 
 		t.msgBus.PublishOutbound(bus.OutboundMessage{
-			Channel: channel,
-			ChatID:  chatID,
-			Content: output,
+			Channel:      channel,
+			ChatID:       chatID,
+			Content:      output,
+			Notification: true,
 		})
 		return "ok"
 	}
@@ -305,9 +354,10 @@ func (t *CronTool) ExecuteJob(ctx context.Context, job *cron.CronJob) string {
 	// If deliver=true, send message directly without agent processing
 	if job.Payload.Deliver {
 		t.msgBus.PublishOutbound(bus.OutboundMessage{
-			Channel: channel,
-			ChatID:  chatID,
-			Content: job.Payload.Message,
+			Channel:      channel,
+			ChatID:       chatID,
+			Content:      job.Payload.Message,
+			Notification: true,
 		})
 		return "ok"
 	}
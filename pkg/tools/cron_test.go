@@ -0,0 +1,53 @@
+package tools
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/cron"
+)
+
+func TestCronTool_SchedulesInUsersTimezone(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skipf("no tzdata: %v", err)
+	}
+	cfg := config.DefaultConfig()
+	cfg.Locale.Businesses = map[string]config.BusinessLocaleConfig{"biz-1": {Timezone: "Europe/Berlin"}}
+	cfg.Locale.UserTimezones = map[string]string{"42": "Asia/Tokyo"}
+
+	service := cron.NewCronService(filepath.Join(t.TempDir(), "jobs.json"), nil)
+	tool := NewCronTool(service, nil, nil, t.TempDir(), true, 0, cfg)
+	tool.SetContext("telegram", "42")
+
+	ctx := context.WithValue(context.Background(), constants.ContextKeyBusinessID, "biz-1")
+	result := tool.Execute(context.WithValue(ctx, constants.ContextKeySenderID, "42|alice"), map[string]any{
+		"action":  "add",
+		"message": "Call the accountant",
+		"at":      "tomorrow at 9",
+	})
+	require.False(t, result.IsError, result.ForLLM)
+
+	result = tool.Execute(ctx, map[string]any{
+		"action":    "add",
+		"message":   "Weekly review",
+		"cron_expr": "0 9 * * 1",
+	})
+	require.False(t, result.IsError, result.ForLLM)
+
+	jobs := service.ListJobs(true)
+	require.Len(t, jobs, 2)
+
+	reminder := time.UnixMilli(*jobs[0].Schedule.AtMS).In(tokyo)
+	assert.Equal(t, time.Now().In(tokyo).AddDate(0, 0, 1).Format(time.DateOnly), reminder.Format(time.DateOnly))
+	assert.Equal(t, "09:00", reminder.Format("15:04"))
+
+	assert.Equal(t, "Europe/Berlin", jobs[1].Schedule.TZ, "without a user zone the business's applies")
+}
@@ -67,7 +67,7 @@ func (t *EditFileTool) Execute(ctx context.Context, args map[string]any) *ToolRe
 		return ErrorResult("new_text is required")
 	}
 
-	resolvedPath, err := validatePath(path, t.allowedDir, t.restrict)
+	resolvedPath, err := validateBusinessPath(ctx, path, t.allowedDir, t.restrict)
 	if err != nil {
 		return ErrorResult(err.Error())
 	}
@@ -148,7 +148,7 @@ func (t *AppendFileTool) Execute(ctx context.Context, args map[string]any) *Tool
 		return ErrorResult("content is required")
 	}
 
-	resolvedPath, err := validatePath(path, t.workspace, t.restrict)
+	resolvedPath, err := validateBusinessPath(ctx, path, t.workspace, t.restrict)
 	if err != nil {
 		return ErrorResult(err.Error())
 	}
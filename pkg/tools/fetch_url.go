@@ -0,0 +1,408 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/constants"
+)
+
+// errBlockedAddress is returned when a fetch would connect to a non-public address.
+var errBlockedAddress = errors.New("destination address is not allowed")
+
+// FetchURLOptions configures the fetch_url tool.
+type FetchURLOptions struct {
+	// AllowURLs restricts fetches to these URL prefixes ("https://api.example.com/v1/")
+	// or bare hosts ("example.com", which also matches subdomains). Empty allows any public URL.
+	AllowURLs []string
+	// AllowPrivate permits loopback and private (RFC 1918 / ULA) destinations.
+	// Link-local and cloud metadata addresses are always blocked.
+	AllowPrivate bool
+	MaxBytes     int64
+	MaxChars     int
+	Timeout      time.Duration
+	CacheTTL     time.Duration
+	CacheEntries int
+}
+
+// FetchURLTool retrieves a URL and extracts readable content, enforcing an
+// allowlist, response size limits, SSRF protections, and a small TTL cache.
+type FetchURLTool struct {
+	opts   FetchURLOptions
+	client *http.Client
+	cache  *fetchCache
+}
+
+type fetchResult struct {
+	Status    int
+	Extractor string
+	Text      string
+	Clipped   bool // body exceeded MaxBytes
+}
+
+// NewFetchURLTool creates a fetch_url tool. Zero-valued limits fall back to defaults.
+func NewFetchURLTool(opts FetchURLOptions) *FetchURLTool {
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = 1 << 20
+	}
+	if opts.MaxChars <= 0 {
+		opts.MaxChars = 50000
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	if opts.CacheEntries <= 0 {
+		opts.CacheEntries = 64
+	}
+
+	t := &FetchURLTool{
+		opts:  opts,
+		cache: newFetchCache(opts.CacheEntries, opts.CacheTTL),
+	}
+
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		// Checking at connect time covers DNS rebinding and redirects to internal hosts.
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || !isAllowedIP(ip, opts.AllowPrivate) {
+				return fmt.Errorf("%w: %s", errBlockedAddress, host)
+			}
+			return nil
+		},
+	}
+
+	t.client = &http.Client{
+		Timeout: opts.Timeout,
+		Transport: &http.Transport{
+			Proxy:               nil, // a proxy would bypass the address checks
+			DialContext:         dialer.DialContext,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     30 * time.Second,
+			TLSHandshakeTimeout: 15 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return fmt.Errorf("stopped after 5 redirects")
+			}
+			if !t.isAllowedURL(req.URL) {
+				return fmt.Errorf("redirect to %s is not in the allowlist", req.URL.Redacted())
+			}
+			return nil
+		},
+	}
+
+	return t
+}
+
+func (t *FetchURLTool) Name() string {
+	return "fetch_url"
+}
+
+func (t *FetchURLTool) Description() string {
+	desc := "Fetch a URL and extract readable content (HTML to text, JSON pretty-printed). " +
+		"Use this for web pages, news, weather, or HTTP APIs instead of curl."
+	if len(t.opts.AllowURLs) > 0 {
+		desc += " Only these URLs are allowed: " + strings.Join(t.opts.AllowURLs, ", ")
+	}
+	return desc
+}
+
+func (t *FetchURLTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"url": map[string]any{
+				"type":        "string",
+				"description": "URL to fetch (http or https)",
+			},
+			"max_chars": map[string]any{
+				"type":        "integer",
+				"description": "Maximum characters of extracted text to return",
+				"minimum":     100.0,
+			},
+		},
+		"required": []string{"url"},
+	}
+}
+
+func (t *FetchURLTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	urlStr, ok := args["url"].(string)
+	if !ok || strings.TrimSpace(urlStr) == "" {
+		return ErrorResult("url is required")
+	}
+
+	parsedURL, err := url.Parse(strings.TrimSpace(urlStr))
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("invalid URL: %v", err))
+	}
+	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
+		return ErrorResult("only http/https URLs are allowed")
+	}
+	if parsedURL.Host == "" {
+		return ErrorResult("missing domain in URL")
+	}
+	if parsedURL.User != nil {
+		return ErrorResult("URLs with embedded credentials are not allowed")
+	}
+	if !t.isAllowedURL(parsedURL) {
+		return ErrorResult(fmt.Sprintf("URL %s is not in the fetch allowlist", parsedURL.Redacted()))
+	}
+
+	maxChars := t.opts.MaxChars
+	if mc, ok := args["max_chars"].(float64); ok && int(mc) >= 100 {
+		maxChars = min(int(mc), t.opts.MaxChars)
+	}
+
+	key := parsedURL.String()
+	// Cache per business, so one business's fetches never show up as
+	// cached for another
+	cacheKey := key
+	if businessID, _ := ctx.Value(constants.ContextKeyBusinessID).(string); businessID != "" {
+		cacheKey = businessID + " " + key
+	}
+	res, cached := t.cache.get(cacheKey)
+	if !cached {
+		res, err = t.fetch(ctx, parsedURL)
+		if err != nil {
+			if errors.Is(err, errBlockedAddress) {
+				return ErrorResult(fmt.Sprintf("fetch blocked: %v", err)).WithError(err)
+			}
+			return ErrorResult(fmt.Sprintf("request failed: %v", err)).WithError(err)
+		}
+		if res.Status < 400 {
+			t.cache.put(cacheKey, res)
+		}
+	}
+
+	text := res.Text
+	truncated := res.Clipped
+	if runes := []rune(text); len(runes) > maxChars {
+		text = string(runes[:maxChars])
+		truncated = true
+	}
+
+	resultJSON, _ := json.MarshalIndent(map[string]any{
+		"url":       key,
+		"status":    res.Status,
+		"extractor": res.Extractor,
+		"truncated": truncated,
+		"cached":    cached,
+		"length":    len(text),
+		"text":      text,
+	}, "", "  ")
+
+	return SilentResult(string(resultJSON))
+}
+
+func (t *FetchURLTool) fetch(ctx context.Context, u *url.URL) (fetchResult, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return fetchResult{}, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fetchResult{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, t.opts.MaxBytes+1))
+	if err != nil {
+		return fetchResult{}, fmt.Errorf("failed to read response: %w", err)
+	}
+	clipped := int64(len(body)) > t.opts.MaxBytes
+	if clipped {
+		body = body[:t.opts.MaxBytes]
+	}
+
+	text, extractor := extractContent(resp.Header.Get("Content-Type"), body)
+	return fetchResult{
+		Status:    resp.StatusCode,
+		Extractor: extractor,
+		Text:      text,
+		Clipped:   clipped,
+	}, nil
+}
+
+// extractContent converts a response body to readable text based on its content type.
+func extractContent(contentType string, body []byte) (string, string) {
+	if strings.Contains(contentType, "application/json") {
+		var jsonData any
+		if err := json.Unmarshal(body, &jsonData); err == nil {
+			formatted, _ := json.MarshalIndent(jsonData, "", "  ")
+			return string(formatted), "json"
+		}
+		return string(body), "raw"
+	}
+
+	if strings.Contains(contentType, "text/html") || len(body) > 0 &&
+		(strings.HasPrefix(string(body), "<!DOCTYPE") || strings.HasPrefix(strings.ToLower(string(body)), "<html")) {
+		return htmlToText(string(body)), "text"
+	}
+
+	return string(body), "raw"
+}
+
+// isAllowedURL checks a URL against the configured allowlist.
+func (t *FetchURLTool) isAllowedURL(u *url.URL) bool {
+	if len(t.opts.AllowURLs) == 0 {
+		return true
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, entry := range t.opts.AllowURLs {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if strings.Contains(entry, "://") {
+			if matchesURLPrefix(u, entry) {
+				return true
+			}
+			continue
+		}
+		if matchesDomain(host, normalizeDomains([]string{entry})) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesURLPrefix reports whether u is under the allowlist entry prefix:
+// the same scheme, host and port, and a path at or below the entry's, split
+// on whole segments so /v1 doesn't admit /v10 or /v1/../admin.
+func matchesURLPrefix(u *url.URL, prefix string) bool {
+	p, err := url.Parse(prefix)
+	if err != nil || p.Hostname() == "" {
+		return false
+	}
+	if !strings.EqualFold(u.Scheme, p.Scheme) || !strings.EqualFold(u.Hostname(), p.Hostname()) ||
+		u.Port() != p.Port() {
+		return false
+	}
+	dir := strings.TrimSuffix(p.Path, "/")
+	if dir == "" {
+		return true
+	}
+	clean := path.Clean("/" + u.Path)
+	return clean == dir || strings.HasPrefix(clean, dir+"/")
+}
+
+// metadataNets are cloud metadata endpoints that are always blocked.
+var metadataNets = []*net.IPNet{
+	mustParseCIDR("169.254.0.0/16"),     // link-local incl. 169.254.169.254
+	mustParseCIDR("fe80::/10"),          // IPv6 link-local
+	mustParseCIDR("fd00:ec2::254/128"),  // AWS IPv6 metadata
+	mustParseCIDR("100.100.100.200/32"), // Alibaba Cloud metadata
+}
+
+// privateNets are blocked unless AllowPrivate is set.
+var privateNets = []*net.IPNet{
+	mustParseCIDR("10.0.0.0/8"),
+	mustParseCIDR("172.16.0.0/12"),
+	mustParseCIDR("192.168.0.0/16"),
+	mustParseCIDR("100.64.0.0/10"), // carrier-grade NAT
+	mustParseCIDR("fc00::/7"),
+}
+
+// isAllowedIP reports whether a connection to ip is permitted.
+func isAllowedIP(ip net.IP, allowPrivate bool) bool {
+	if ip.IsUnspecified() || ip.IsMulticast() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
+		return false
+	}
+	for _, n := range metadataNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	if allowPrivate {
+		return true
+	}
+	if ip.IsLoopback() {
+		return false
+	}
+	for _, n := range privateNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+func mustParseCIDR(s string) *net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// fetchCache is a small TTL cache for fetch results, evicting the oldest entry when full.
+type fetchCache struct {
+	mu         sync.Mutex
+	entries    map[string]fetchCacheEntry
+	order      []string // insertion order: oldest first
+	maxEntries int
+	ttl        time.Duration
+}
+
+type fetchCacheEntry struct {
+	result    fetchResult
+	expiresAt time.Time
+}
+
+func newFetchCache(maxEntries int, ttl time.Duration) *fetchCache {
+	return &fetchCache{
+		entries:    make(map[string]fetchCacheEntry),
+		maxEntries: maxEntries,
+		ttl:        ttl,
+	}
+}
+
+func (c *fetchCache) get(key string) (fetchResult, bool) {
+	if c.ttl <= 0 {
+		return fetchResult{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return fetchResult{}, false
+	}
+	return entry.result, true
+}
+
+func (c *fetchCache) put(key string, result fetchResult) {
+	if c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, exists := c.entries[key]; !exists {
+		c.order = append(c.order, key)
+	}
+	c.entries[key] = fetchCacheEntry{result: result, expiresAt: time.Now().Add(c.ttl)}
+
+	for len(c.order) > c.maxEntries {
+		oldest := c.order[0]
+		c.order = c.order[1:]
+		delete(c.entries, oldest)
+	}
+}
@@ -0,0 +1,129 @@
+package tools
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFetchURLTool_Success(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><body><h1>Hello</h1><script>bad()</script></body></html>"))
+	}))
+	defer server.Close()
+
+	tool := NewFetchURLTool(FetchURLOptions{AllowPrivate: true})
+	result := tool.Execute(context.Background(), map[string]any{"url": server.URL})
+
+	if result.IsError {
+		t.Fatalf("Expected success, got error: %s", result.ForLLM)
+	}
+	if !strings.Contains(result.ForLLM, "Hello") || strings.Contains(result.ForLLM, "bad()") {
+		t.Errorf("Expected extracted text in ForLLM, got: %s", result.ForLLM)
+	}
+	if !result.Silent {
+		t.Errorf("Expected fetch result to be silent")
+	}
+}
+
+func TestFetchURLTool_BlocksLoopbackByDefault(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("secret"))
+	}))
+	defer server.Close()
+
+	tool := NewFetchURLTool(FetchURLOptions{})
+	result := tool.Execute(context.Background(), map[string]any{"url": server.URL})
+
+	if !result.IsError || !strings.Contains(result.ForLLM, "blocked") {
+		t.Errorf("Expected loopback fetch to be blocked, got: %s", result.ForLLM)
+	}
+}
+
+func TestFetchURLTool_Allowlist(t *testing.T) {
+	tool := NewFetchURLTool(FetchURLOptions{
+		AllowURLs: []string{"https://api.example.com/v1/", "docs.org"},
+	})
+
+	result := tool.Execute(context.Background(), map[string]any{"url": "https://evil.com/"})
+	if !result.IsError || !strings.Contains(result.ForLLM, "allowlist") {
+		t.Errorf("Expected allowlist rejection, got: %s", result.ForLLM)
+	}
+
+	cases := map[string]bool{
+		"https://api.example.com/v1/items":          true,
+		"https://api.example.com/v1":                true,
+		"https://api.example.com/v2/items":          false,
+		"https://api.example.com/v10/items":         false,
+		"https://api.example.com/v1/../admin":       false,
+		"http://api.example.com/v1/items":           false,
+		"https://api.example.com.evil.com/v1/items": false,
+		"https://api.example.com:8443/v1/items":     false,
+		"https://api.example.com@evil.com/v1/items": false,
+		"https://www.docs.org/page":                 true,
+		"https://notdocs.org/page":                  false,
+	}
+	for raw, want := range cases {
+		u, _ := url.Parse(raw)
+		if got := tool.isAllowedURL(u); got != want {
+			t.Errorf("isAllowedURL(%s) = %v, want %v", raw, got, want)
+		}
+	}
+}
+
+func TestFetchURLTool_CachesAndLimitsSize(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(strings.Repeat("x", 500)))
+	}))
+	defer server.Close()
+
+	tool := NewFetchURLTool(FetchURLOptions{AllowPrivate: true, MaxBytes: 200, CacheTTL: time.Minute})
+
+	first := tool.Execute(context.Background(), map[string]any{"url": server.URL})
+	second := tool.Execute(context.Background(), map[string]any{"url": server.URL})
+
+	if first.IsError || second.IsError {
+		t.Fatalf("Unexpected error: %s / %s", first.ForLLM, second.ForLLM)
+	}
+	if hits.Load() != 1 {
+		t.Errorf("Expected 1 upstream request, got %d", hits.Load())
+	}
+	if !strings.Contains(first.ForLLM, `"truncated": true`) || !strings.Contains(first.ForLLM, `"length": 200`) {
+		t.Errorf("Expected body clipped to 200 bytes, got: %s", first.ForLLM)
+	}
+	if !strings.Contains(second.ForLLM, `"cached": true`) {
+		t.Errorf("Expected second fetch to be served from cache, got: %s", second.ForLLM)
+	}
+}
+
+func TestIsAllowedIP(t *testing.T) {
+	cases := []struct {
+		ip           string
+		allowPrivate bool
+		want         bool
+	}{
+		{"93.184.216.34", false, true},
+		{"127.0.0.1", false, false},
+		{"127.0.0.1", true, true},
+		{"10.1.2.3", false, false},
+		{"192.168.1.1", true, true},
+		{"169.254.169.254", true, false},
+		{"fe80::1", true, false},
+		{"0.0.0.0", true, false},
+	}
+	for _, tc := range cases {
+		if got := isAllowedIP(net.ParseIP(tc.ip), tc.allowPrivate); got != tc.want {
+			t.Errorf("isAllowedIP(%s, %v) = %v, want %v", tc.ip, tc.allowPrivate, got, tc.want)
+		}
+	}
+}
@@ -2,13 +2,48 @@ package tools
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/utils"
 )
 
+// validateBusinessPath validates path like validatePath, and keeps a run
+// for one business out of other businesses' data: their memory, and the
+// session and state files, which hold every business's history and tokens.
+func validateBusinessPath(ctx context.Context, path, workspace string, restrict bool) (string, error) {
+	resolved, err := validatePath(path, workspace, restrict)
+	if err != nil {
+		return "", err
+	}
+	businessID, _ := ctx.Value(constants.ContextKeyBusinessID).(string)
+	if businessID == "" || workspace == "" {
+		return resolved, nil
+	}
+	absWorkspace, err := filepath.Abs(workspace)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve workspace path: %w", err)
+	}
+	rel, err := filepath.Rel(absWorkspace, resolved)
+	if err != nil || !filepath.IsLocal(rel) {
+		return resolved, nil
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	switch {
+	case parts[0] == "sessions" || parts[0] == "state":
+		return "", fmt.Errorf("access denied: %s/ holds other businesses' data", parts[0])
+	case len(parts) > 2 && parts[0] == "memory" && parts[1] == "businesses" &&
+		parts[2] != utils.BusinessDirName(businessID):
+		return "", errors.New("access denied: memory of another business")
+	}
+	return resolved, nil
+}
+
 // validatePath ensures the given path is within the workspace if restrict is true.
 func validatePath(path, workspace string, restrict bool) (string, error) {
 	if workspace == "" {
@@ -76,9 +111,17 @@ func isWithinWorkspace(candidate, workspace string) bool {
 	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(os.PathSeparator))
 }
 
+// FileDecrypter gives tools the plaintext of files that are encrypted at
+// rest, such as uploaded media.
+type FileDecrypter interface {
+	IsEncrypted(path string) bool
+	ReadFile(path string) ([]byte, error)
+}
+
 type ReadFileTool struct {
 	workspace string
 	restrict  bool
+	decrypter FileDecrypter
 }
 
 func NewReadFileTool(workspace string, restrict bool) *ReadFileTool {
@@ -112,12 +155,16 @@ func (t *ReadFileTool) Execute(ctx context.Context, args map[string]any) *ToolRe
 		return ErrorResult("path is required")
 	}
 
-	resolvedPath, err := validatePath(path, t.workspace, t.restrict)
+	resolvedPath, err := validateBusinessPath(ctx, path, t.workspace, t.restrict)
 	if err != nil {
 		return ErrorResult(err.Error())
 	}
 
-	content, err := os.ReadFile(resolvedPath)
+	readFile := os.ReadFile
+	if t.decrypter != nil {
+		readFile = t.decrypter.ReadFile
+	}
+	content, err := readFile(resolvedPath)
 	if err != nil {
 		return ErrorResult(fmt.Sprintf("failed to read file: %v", err))
 	}
@@ -136,6 +183,11 @@ func (t *ReadFileTool) Execute(ctx context.Context, args map[string]any) *ToolRe
 	return NewToolResult(string(content))
 }
 
+// SetDecrypter makes read_file return the plaintext of encrypted files.
+func (t *ReadFileTool) SetDecrypter(d FileDecrypter) {
+	t.decrypter = d
+}
+
 type WriteFileTool struct {
 	workspace string
 	restrict  bool
@@ -181,7 +233,7 @@ func (t *WriteFileTool) Execute(ctx context.Context, args map[string]any) *ToolR
 		return ErrorResult("content is required")
 	}
 
-	resolvedPath, err := validatePath(path, t.workspace, t.restrict)
+	resolvedPath, err := validateBusinessPath(ctx, path, t.workspace, t.restrict)
 	if err != nil {
 		return ErrorResult(err.Error())
 	}
@@ -234,7 +286,7 @@ func (t *ListDirTool) Execute(ctx context.Context, args map[string]any) *ToolRes
 		path = "."
 	}
 
-	resolvedPath, err := validatePath(path, t.workspace, t.restrict)
+	resolvedPath, err := validateBusinessPath(ctx, path, t.workspace, t.restrict)
 	if err != nil {
 		return ErrorResult(err.Error())
 	}
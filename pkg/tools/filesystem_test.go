@@ -6,6 +6,8 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/constants"
 )
 
 // TestFilesystemTool_ReadFile_Success verifies successful file reading
@@ -39,6 +41,50 @@ func TestFilesystemTool_ReadFile_Success(t *testing.T) {
 	}
 }
 
+// rot13Decrypter stands in for media encryption: files starting with "ENC:"
+// are "encrypted".
+type rot13Decrypter struct{}
+
+func (rot13Decrypter) IsEncrypted(path string) bool {
+	data, err := os.ReadFile(path)
+	return err == nil && strings.HasPrefix(string(data), "ENC:")
+}
+
+func (rot13Decrypter) ReadFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	plain := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return 'a' + (r-'a'+13)%26
+		case r >= 'A' && r <= 'Z':
+			return 'A' + (r-'A'+13)%26
+		}
+		return r
+	}, strings.TrimPrefix(string(data), "ENC:"))
+	return []byte(plain), nil
+}
+
+// TestFilesystemTool_ReadFile_Decrypts verifies encrypted files are returned as plaintext
+func TestFilesystemTool_ReadFile_Decrypts(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "receipt.txt")
+	os.WriteFile(testFile, []byte("ENC:gbgny 42"), 0o600)
+
+	tool := &ReadFileTool{}
+	tool.SetDecrypter(rot13Decrypter{})
+	result := tool.Execute(context.Background(), map[string]any{"path": testFile})
+
+	if result.IsError {
+		t.Fatalf("Expected success, got IsError=true: %s", result.ForLLM)
+	}
+	if result.ForLLM != "total 42" {
+		t.Errorf("Expected decrypted content 'total 42', got: %s", result.ForLLM)
+	}
+}
+
 // TestFilesystemTool_ReadFile_NotFound verifies error handling for missing file
 func TestFilesystemTool_ReadFile_NotFound(t *testing.T) {
 	tool := &ReadFileTool{}
@@ -279,3 +325,40 @@ func TestFilesystemTool_ReadFile_RejectsSymlinkEscape(t *testing.T) {
 		t.Fatalf("expected symlink escape error, got: %s", result.ForLLM)
 	}
 }
+
+// TestFilesystemTool_KeepsBusinessesApart verifies a business's run cannot
+// reach another business's memory or the shared session and state files
+func TestFilesystemTool_KeepsBusinessesApart(t *testing.T) {
+	workspace := t.TempDir()
+	for _, path := range []string{
+		"memory/businesses/biz-a/MEMORY.md",
+		"memory/businesses/biz-b/MEMORY.md",
+		"sessions/telegram_chat1.json",
+		"notes.txt",
+	} {
+		full := filepath.Join(workspace, path)
+		os.MkdirAll(filepath.Dir(full), 0o755)
+		os.WriteFile(full, []byte("secret of "+path), 0o644)
+	}
+
+	tool := NewReadFileTool(workspace, true)
+	ctx := context.WithValue(context.Background(), constants.ContextKeyBusinessID, "biz-a")
+	for path, allowed := range map[string]bool{
+		"memory/businesses/biz-a/MEMORY.md":                      true,
+		"notes.txt":                                              true,
+		"memory/businesses/biz-b/MEMORY.md":                      false,
+		"memory/businesses/biz-a/../biz-b/MEMORY.md":             false,
+		filepath.Join(workspace, "sessions/telegram_chat1.json"): false,
+	} {
+		result := tool.Execute(ctx, map[string]any{"path": path})
+		if result.IsError == allowed {
+			t.Errorf("read %s: allowed=%v, got IsError=%v: %s", path, allowed, result.IsError, result.ForLLM)
+		}
+	}
+
+	// Without a business, the workspace is open as before.
+	result := tool.Execute(context.Background(), map[string]any{"path": "memory/businesses/biz-b/MEMORY.md"})
+	if result.IsError {
+		t.Errorf("Expected read without a business to succeed: %s", result.ForLLM)
+	}
+}
@@ -0,0 +1,316 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+const (
+	// maxGPIOPulse bounds how long one call may hold a pin on or blink it,
+	// since the agent waits for the call.
+	maxGPIOPulse = 10 * time.Second
+	maxGPIOCount = 20
+)
+
+// ledRoot is where the kernel's LED class devices are; tests point it at a
+// fake tree.
+var ledRoot = "/sys/class/leds"
+
+// GPIOTool drives the GPIO lines and LEDs named in the config, such as an LED
+// to blink on a new receipt or a buzzer to sound when attention is needed.
+// Only configured pins can be used, and only output pins can be driven.
+type GPIOTool struct {
+	pins map[string]config.GPIOPinConfig
+
+	mu    sync.Mutex
+	lines map[string]gpioLine // output lines held open, by pin name
+}
+
+func NewGPIOTool(cfg config.GPIOConfig) *GPIOTool {
+	pins := make(map[string]config.GPIOPinConfig, len(cfg.Pins))
+	for _, pin := range cfg.Pins {
+		pins[pin.Name] = pin
+	}
+	return &GPIOTool{pins: pins, lines: make(map[string]gpioLine)}
+}
+
+func (t *GPIOTool) Name() string {
+	return "gpio"
+}
+
+func (t *GPIOTool) Description() string {
+	var names []string
+	for _, name := range t.pinNames() {
+		pin := t.pins[name]
+		desc := name
+		if pin.Description != "" {
+			desc += " (" + pin.Description + ")"
+		}
+		if !pin.Output {
+			desc += " [input]"
+		}
+		names = append(names, desc)
+	}
+	return "Control the device's GPIO pins, LEDs and relays. Actions: list (pins and their states), " +
+		"read (a pin's state), set (turn an output on or off), pulse (turn an output on for duration_ms, " +
+		"count times, e.g. to blink an LED or beep a buzzer). Pins: " + strings.Join(names, ", ") + "."
+}
+
+func (t *GPIOTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"action": map[string]any{
+				"type":        "string",
+				"enum":        []string{"list", "read", "set", "pulse"},
+				"description": "Action to perform",
+			},
+			"pin": map[string]any{
+				"type":        "string",
+				"enum":        t.pinNames(),
+				"description": "Pin name. Required for read/set/pulse.",
+			},
+			"on": map[string]any{
+				"type":        "boolean",
+				"description": "State to set. Required for set.",
+			},
+			"duration_ms": map[string]any{
+				"type":        "integer",
+				"description": "How long the pin stays on per pulse, and off between pulses. Default: 200.",
+			},
+			"count": map[string]any{
+				"type":        "integer",
+				"description": "Number of pulses (1-20). Default: 1.",
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+func (t *GPIOTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	action, ok := args["action"].(string)
+	if !ok {
+		return ErrorResult("action is required")
+	}
+	if action == "list" {
+		return t.list()
+	}
+
+	name, _ := args["pin"].(string)
+	pin, ok := t.pins[name]
+	if !ok {
+		return ErrorResult(fmt.Sprintf("unknown pin %q (configured: %s)", name, strings.Join(t.pinNames(), ", ")))
+	}
+
+	switch action {
+	case "read":
+		on, err := t.read(pin)
+		if err != nil {
+			return ErrorResult(fmt.Sprintf("failed to read %s: %v", name, err))
+		}
+		return SilentResult(fmt.Sprintf("%s is %s", name, onOff(on)))
+	case "set":
+		on, ok := args["on"].(bool)
+		if !ok {
+			return ErrorResult("on is required for set")
+		}
+		if !pin.Output {
+			return ErrorResult(fmt.Sprintf("%s is an input pin", name))
+		}
+		if err := t.write(pin, on); err != nil {
+			return ErrorResult(fmt.Sprintf("failed to set %s: %v", name, err))
+		}
+		return SilentResult(fmt.Sprintf("%s turned %s", name, onOff(on)))
+	case "pulse":
+		if !pin.Output {
+			return ErrorResult(fmt.Sprintf("%s is an input pin", name))
+		}
+		return t.pulse(ctx, pin, args)
+	default:
+		return ErrorResult(fmt.Sprintf("unknown action: %s (valid: list, read, set, pulse)", action))
+	}
+}
+
+// pulse turns pin on for duration_ms, count times with as long a gap, and
+// leaves it off.
+func (t *GPIOTool) pulse(ctx context.Context, pin config.GPIOPinConfig, args map[string]any) *ToolResult {
+	duration := 200 * time.Millisecond
+	if ms, ok := args["duration_ms"].(float64); ok && ms > 0 {
+		duration = time.Duration(ms) * time.Millisecond
+	}
+	count := 1
+	if c, ok := args["count"].(float64); ok && c >= 1 {
+		count = min(int(c), maxGPIOCount)
+	}
+	if time.Duration(2*count-1)*duration > maxGPIOPulse {
+		return ErrorResult(fmt.Sprintf("pulses would take longer than %s", maxGPIOPulse))
+	}
+
+	for i := range count {
+		if i > 0 {
+			if err := sleepCtx(ctx, duration); err != nil {
+				return ErrorResult("pulse cancelled")
+			}
+		}
+		if err := t.write(pin, true); err != nil {
+			return ErrorResult(fmt.Sprintf("failed to set %s: %v", pin.Name, err))
+		}
+		err := sleepCtx(ctx, duration)
+		if werr := t.write(pin, false); werr != nil {
+			return ErrorResult(fmt.Sprintf("failed to reset %s: %v", pin.Name, werr))
+		}
+		if err != nil {
+			return ErrorResult("pulse cancelled")
+		}
+	}
+	return SilentResult(fmt.Sprintf("%s pulsed %d time(s)", pin.Name, count))
+}
+
+func (t *GPIOTool) list() *ToolResult {
+	type pinInfo struct {
+		Name        string `json:"name"`
+		Description string `json:"description,omitempty"`
+		Output      bool   `json:"output"`
+		State       string `json:"state"`
+	}
+	pins := make([]pinInfo, 0, len(t.pins))
+	for _, name := range t.pinNames() {
+		pin := t.pins[name]
+		info := pinInfo{Name: name, Description: pin.Description, Output: pin.Output}
+		if on, err := t.read(pin); err != nil {
+			info.State = "error: " + err.Error()
+		} else {
+			info.State = onOff(on)
+		}
+		pins = append(pins, info)
+	}
+	result, _ := json.MarshalIndent(pins, "", "  ")
+	return SilentResult(string(result))
+}
+
+func (t *GPIOTool) read(pin config.GPIOPinConfig) (bool, error) {
+	if pin.LED != "" {
+		return readLED(pin.LED)
+	}
+	if !isValidGPIOChip(pin.Chip) {
+		return false, fmt.Errorf("invalid GPIO chip %q (e.g. \"gpiochip0\")", pin.Chip)
+	}
+	t.mu.Lock()
+	line, held := t.lines[pin.Name]
+	t.mu.Unlock()
+	if held {
+		return line.get()
+	}
+	// A line not yet driven is read as it is, without setting its direction.
+	return readGPIOLine(pin)
+}
+
+func (t *GPIOTool) write(pin config.GPIOPinConfig, on bool) error {
+	if pin.LED != "" {
+		return writeLED(pin.LED, on)
+	}
+	if !isValidGPIOChip(pin.Chip) {
+		return fmt.Errorf("invalid GPIO chip %q (e.g. \"gpiochip0\")", pin.Chip)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if line, ok := t.lines[pin.Name]; ok {
+		return line.set(on)
+	}
+	// The line is kept open so that it holds its value between calls; the
+	// kernel releases it when the gateway exits.
+	line, err := requestGPIOOutput(pin, on)
+	if err != nil {
+		return err
+	}
+	t.lines[pin.Name] = line
+	return nil
+}
+
+func (t *GPIOTool) pinNames() []string {
+	names := make([]string, 0, len(t.pins))
+	for name := range t.pins {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// readLED reports whether the LED class device name is lit.
+func readLED(name string) (bool, error) {
+	dir, err := ledDir(name)
+	if err != nil {
+		return false, err
+	}
+	brightness, err := os.ReadFile(filepath.Join(dir, "brightness"))
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(string(brightness)) != "0", nil
+}
+
+// writeLED lights the LED class device name at full brightness or turns it
+// off, first detaching any trigger (such as mmc0 activity) that would
+// override it.
+func writeLED(name string, on bool) error {
+	dir, err := ledDir(name)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "trigger"), []byte("none"), 0o644); err != nil &&
+		!os.IsNotExist(err) {
+		return err
+	}
+	value := "0"
+	if on {
+		value = "1"
+		if data, err := os.ReadFile(filepath.Join(dir, "max_brightness")); err == nil {
+			if n, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil && n > 0 {
+				value = strconv.Itoa(n)
+			}
+		}
+	}
+	return os.WriteFile(filepath.Join(dir, "brightness"), []byte(value), 0o644)
+}
+
+// ledDir returns the directory of the LED class device name, rejecting names
+// that would leave the LED class directory.
+func ledDir(name string) (string, error) {
+	if name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("invalid LED name %q", name)
+	}
+	return filepath.Join(ledRoot, name), nil
+}
+
+// isValidGPIOChip checks that a chip is named like gpiochip0 (prevents path
+// injection).
+func isValidGPIOChip(chip string) bool {
+	n, ok := strings.CutPrefix(chip, "gpiochip")
+	return ok && isValidBusID(n)
+}
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
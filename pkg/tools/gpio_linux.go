@@ -0,0 +1,142 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// GPIO character device ioctls and flags from <linux/gpio.h> (uAPI v2).
+const (
+	gpioV2GetLineIoctl       = 0xC250B407 // _IOWR(0xB4, 0x07, struct gpio_v2_line_request)
+	gpioV2LineGetValuesIoctl = 0xC010B40E // _IOWR(0xB4, 0x0E, struct gpio_v2_line_values)
+	gpioV2LineSetValuesIoctl = 0xC010B40F // _IOWR(0xB4, 0x0F, struct gpio_v2_line_values)
+
+	gpioV2LineFlagActiveLow = 1 << 1
+	gpioV2LineFlagOutput    = 1 << 3
+
+	gpioV2LineAttrIDOutputValues = 2
+)
+
+// gpioV2LineAttribute matches struct gpio_v2_line_attribute.
+type gpioV2LineAttribute struct {
+	id      uint32
+	padding uint32
+	value   uint64 // flags, values or debounce period, by id
+}
+
+// gpioV2LineConfigAttribute matches struct gpio_v2_line_config_attribute.
+type gpioV2LineConfigAttribute struct {
+	attr gpioV2LineAttribute
+	mask uint64
+}
+
+// gpioV2LineConfig matches struct gpio_v2_line_config.
+type gpioV2LineConfig struct {
+	flags    uint64
+	numAttrs uint32
+	padding  [5]uint32
+	attrs    [10]gpioV2LineConfigAttribute
+}
+
+// gpioV2LineRequest matches struct gpio_v2_line_request (592 bytes).
+type gpioV2LineRequest struct {
+	offsets         [64]uint32
+	consumer        [32]byte
+	config          gpioV2LineConfig
+	numLines        uint32
+	eventBufferSize uint32
+	padding         [5]uint32
+	fd              int32
+}
+
+// gpioV2LineValues matches struct gpio_v2_line_values.
+type gpioV2LineValues struct {
+	bits uint64
+	mask uint64
+}
+
+// gpioLine is a requested line, one bit of which is the pin.
+type gpioLine struct {
+	fd int
+}
+
+func (l gpioLine) get() (bool, error) {
+	values := gpioV2LineValues{mask: 1}
+	if err := gpioIoctl(l.fd, gpioV2LineGetValuesIoctl, unsafe.Pointer(&values)); err != nil {
+		return false, err
+	}
+	return values.bits&1 != 0, nil
+}
+
+func (l gpioLine) set(on bool) error {
+	values := gpioV2LineValues{mask: 1}
+	if on {
+		values.bits = 1
+	}
+	return gpioIoctl(l.fd, gpioV2LineSetValuesIoctl, unsafe.Pointer(&values))
+}
+
+// requestGPIOOutput claims pin's line as an output set to on.
+func requestGPIOOutput(pin config.GPIOPinConfig, on bool) (gpioLine, error) {
+	flags := uint64(gpioV2LineFlagOutput)
+	if pin.ActiveLow {
+		flags |= gpioV2LineFlagActiveLow
+	}
+	var initial uint64
+	if on {
+		initial = 1
+	}
+	return requestGPIOLine(pin, flags, &gpioV2LineConfigAttribute{
+		attr: gpioV2LineAttribute{id: gpioV2LineAttrIDOutputValues, value: initial},
+		mask: 1,
+	})
+}
+
+// readGPIOLine reads pin's line, leaving its direction as it is.
+func readGPIOLine(pin config.GPIOPinConfig) (bool, error) {
+	var flags uint64
+	if pin.ActiveLow {
+		flags |= gpioV2LineFlagActiveLow
+	}
+	line, err := requestGPIOLine(pin, flags, nil)
+	if err != nil {
+		return false, err
+	}
+	defer syscall.Close(line.fd)
+	return line.get()
+}
+
+func requestGPIOLine(pin config.GPIOPinConfig, flags uint64, attr *gpioV2LineConfigAttribute) (gpioLine, error) {
+	chip, err := os.Open("/dev/" + pin.Chip)
+	if err != nil {
+		return gpioLine{}, fmt.Errorf("%w (check permissions and the chip name)", err)
+	}
+	defer chip.Close()
+
+	req := gpioV2LineRequest{numLines: 1}
+	req.offsets[0] = uint32(pin.Line)
+	copy(req.consumer[:], "picoclaw")
+	req.config.flags = flags
+	if attr != nil {
+		req.config.attrs[0] = *attr
+		req.config.numAttrs = 1
+	}
+	if err := gpioIoctl(int(chip.Fd()), gpioV2GetLineIoctl, unsafe.Pointer(&req)); err != nil {
+		if err == syscall.EBUSY {
+			return gpioLine{}, fmt.Errorf("line %d of %s is in use by another driver or process", pin.Line, pin.Chip)
+		}
+		return gpioLine{}, fmt.Errorf("failed to request line %d of %s: %w", pin.Line, pin.Chip, err)
+	}
+	return gpioLine{fd: int(req.fd)}, nil
+}
+
+func gpioIoctl(fd int, req uintptr, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), req, uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+package tools
+
+import (
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGPIOStructsMatchKernelABI(t *testing.T) {
+	assert.Equal(t, uintptr(592), unsafe.Sizeof(gpioV2LineRequest{}))
+	assert.Equal(t, uintptr(272), unsafe.Sizeof(gpioV2LineConfig{}))
+	assert.Equal(t, uintptr(16), unsafe.Sizeof(gpioV2LineValues{}))
+}
@@ -0,0 +1,26 @@
+//go:build !linux
+
+package tools
+
+import (
+	"errors"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+var errGPIOUnsupported = errors.New("GPIO lines are only supported on Linux")
+
+// gpioLine is a stub for non-Linux platforms.
+type gpioLine struct{}
+
+func (l gpioLine) get() (bool, error) { return false, errGPIOUnsupported }
+
+func (l gpioLine) set(on bool) error { return errGPIOUnsupported }
+
+func requestGPIOOutput(pin config.GPIOPinConfig, on bool) (gpioLine, error) {
+	return gpioLine{}, errGPIOUnsupported
+}
+
+func readGPIOLine(pin config.GPIOPinConfig) (bool, error) {
+	return false, errGPIOUnsupported
+}
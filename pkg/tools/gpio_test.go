@@ -0,0 +1,92 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// fakeLED creates an LED class device under a temporary ledRoot.
+func fakeLED(t *testing.T, name string) string {
+	t.Helper()
+	root := t.TempDir()
+	old := ledRoot
+	ledRoot = root
+	t.Cleanup(func() { ledRoot = old })
+
+	dir := filepath.Join(root, name)
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "brightness"), []byte("0\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "max_brightness"), []byte("255\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "trigger"), []byte("none [mmc0]\n"), 0o644))
+	return dir
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func newTestGPIOTool() *GPIOTool {
+	return NewGPIOTool(config.GPIOConfig{Enabled: true, Pins: []config.GPIOPinConfig{
+		{Name: "status_led", Description: "green LED", LED: "ACT", Output: true},
+		{Name: "door", Chip: "gpiochip0", Line: 27},
+	}})
+}
+
+func TestGPIOSetLED(t *testing.T) {
+	dir := fakeLED(t, "ACT")
+	tool := newTestGPIOTool()
+
+	result := tool.Execute(context.Background(), map[string]any{"action": "set", "pin": "status_led", "on": true})
+	require.False(t, result.IsError, result.ForLLM)
+	assert.Equal(t, "255", readFile(t, filepath.Join(dir, "brightness")), "lit at full brightness")
+	assert.Equal(t, "none", readFile(t, filepath.Join(dir, "trigger")), "the activity trigger is detached")
+
+	result = tool.Execute(context.Background(), map[string]any{"action": "read", "pin": "status_led"})
+	assert.Equal(t, "status_led is on", result.ForLLM)
+
+	result = tool.Execute(context.Background(), map[string]any{
+		"action": "pulse", "pin": "status_led", "count": float64(2), "duration_ms": float64(1),
+	})
+	require.False(t, result.IsError, result.ForLLM)
+	assert.Equal(t, "0", readFile(t, filepath.Join(dir, "brightness")), "left off after pulsing")
+}
+
+func TestGPIOOnlyConfiguredOutputs(t *testing.T) {
+	fakeLED(t, "ACT")
+	tool := newTestGPIOTool()
+
+	result := tool.Execute(context.Background(), map[string]any{"action": "set", "pin": "relay", "on": true})
+	assert.True(t, result.IsError)
+	assert.Contains(t, result.ForLLM, `unknown pin "relay"`)
+
+	result = tool.Execute(context.Background(), map[string]any{"action": "set", "pin": "door", "on": true})
+	assert.True(t, result.IsError)
+	assert.Contains(t, result.ForLLM, "door is an input pin")
+
+	result = tool.Execute(context.Background(), map[string]any{
+		"action": "pulse", "pin": "status_led", "count": float64(20), "duration_ms": float64(1000),
+	})
+	assert.True(t, result.IsError, "pulses are bounded")
+}
+
+func TestGPIORejectsPathsOutsideSysfs(t *testing.T) {
+	tool := NewGPIOTool(config.GPIOConfig{Pins: []config.GPIOPinConfig{
+		{Name: "bad_led", LED: "../../../etc", Output: true},
+		{Name: "bad_chip", Chip: "../mem", Output: true},
+	}})
+	for _, pin := range []string{"bad_led", "bad_chip"} {
+		result := tool.Execute(context.Background(), map[string]any{"action": "set", "pin": pin, "on": true})
+		assert.True(t, result.IsError)
+		assert.Contains(t, result.ForLLM, "invalid", pin)
+	}
+}
@@ -0,0 +1,119 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/ledgerforge"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// maxLedgerForgeChars bounds how much of a response the LLM sees.
+const maxLedgerForgeChars = 20000
+
+// transactionPath matches the path of one transaction.
+var transactionPath = regexp.MustCompile(`^/transactions/([^/?]+)$`)
+
+// LedgerForgeTool calls the LedgerForge API as the requesting user, within
+// the business the request is scoped to.
+type LedgerForgeTool struct {
+	client *ledgerforge.Client
+}
+
+// NewLedgerForgeTool creates a ledgerforge tool that calls through client.
+func NewLedgerForgeTool(client *ledgerforge.Client) *LedgerForgeTool {
+	return &LedgerForgeTool{client: client}
+}
+
+func (t *LedgerForgeTool) Name() string {
+	return "ledgerforge"
+}
+
+func (t *LedgerForgeTool) Description() string {
+	return "Call the LedgerForge accounting API for the current business, as the current user. " +
+		"path is relative to the business, e.g. GET /transactions/summary for the dashboard or " +
+		"GET /invoices/overdue. Returns the JSON response."
+}
+
+func (t *LedgerForgeTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"method": map[string]any{
+				"type": "string",
+				"enum": []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+			},
+			"path": map[string]any{
+				"type":        "string",
+				"description": "Path under /api/v1/businesses/{business_id}, e.g. /transactions?status=posted",
+			},
+			"body": map[string]any{
+				"type":        "object",
+				"description": "Optional: JSON request body for POST, PUT and PATCH",
+			},
+		},
+		"required": []string{"method", "path"},
+	}
+}
+
+func (t *LedgerForgeTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	method, _ := args["method"].(string)
+	method = strings.ToUpper(method)
+	path, _ := args["path"].(string)
+	if !strings.HasPrefix(path, "/") || strings.Contains(path, "..") || strings.Contains(strings.ToLower(path), "%2e") {
+		return ErrorResult("path must start with / and stay within the business, e.g. /transactions/summary")
+	}
+	fullPath, err := ledgerforge.BusinessPath(ctx, path)
+	if err != nil {
+		return ErrorResult("LedgerForge calls need a request scoped to a business")
+	}
+
+	var resp json.RawMessage
+	if err := t.client.Do(ctx, method, fullPath, args["body"], &resp); err != nil {
+		if errors.Is(err, ledgerforge.ErrNoToken) {
+			return ErrorResult("LedgerForge calls need an authenticated user")
+		}
+		return ErrorResult(err.Error())
+	}
+	t.notifyPosted(ctx, method, path, args["body"])
+	if len(resp) == 0 {
+		return SilentResult(fmt.Sprintf("%s %s succeeded with no content.", method, path))
+	}
+	return SilentResult(utils.Truncate(string(resp), maxLedgerForgeChars))
+}
+
+// notifyPosted sends a transaction.posted event for each transaction a
+// successful call set to posted, one at a time or through bulk-status.
+func (t *LedgerForgeTool) notifyPosted(ctx context.Context, method, path string, body any) {
+	fields, _ := body.(map[string]any)
+	if !t.client.Notifies() || method == "GET" || method == "DELETE" || fields["status"] != "posted" {
+		return
+	}
+	var ids []string
+	if m := transactionPath.FindStringSubmatch(path); m != nil && m[1] != "bulk-status" {
+		ids = append(ids, m[1])
+	} else if path == "/transactions/bulk-status" {
+		list, _ := fields["transaction_ids"].([]any)
+		for _, id := range list {
+			if s, ok := id.(string); ok {
+				ids = append(ids, s)
+			}
+		}
+	}
+	for _, id := range ids {
+		err := t.client.Notify(ctx, ledgerforge.Event{
+			ID:   "transaction-posted-" + id,
+			Type: ledgerforge.EventTransactionPosted,
+			Data: ledgerforge.TransactionPosted{TransactionID: id, Status: "posted"},
+		})
+		if err != nil {
+			logger.WarnCF("ledgerforge", "Transaction event failed",
+				map[string]any{"transaction_id": id, "error": err.Error()})
+		}
+	}
+}
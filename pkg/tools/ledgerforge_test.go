@@ -0,0 +1,63 @@
+package tools
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sipeed/picoclaw/pkg/ledgerforge"
+)
+
+func TestLedgerForgeTool_ScopesPathToBusiness(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/businesses/biz-1/invoices/overdue", r.URL.Path)
+		io.WriteString(w, `[{"invoice_number":"INV-7"}]`)
+	}))
+	defer srv.Close()
+	tool := NewLedgerForgeTool(ledgerforge.New(ledgerforge.Options{BaseURL: srv.URL}))
+	ctx := ledgerforge.WithAuth(context.Background(), "jwt-1", "biz-1")
+
+	result := tool.Execute(ctx, map[string]any{"method": "GET", "path": "/invoices/overdue"})
+	assert.False(t, result.IsError, result.ForLLM)
+	assert.Contains(t, result.ForLLM, "INV-7")
+
+	result = tool.Execute(ctx, map[string]any{"method": "GET", "path": "/../other-biz/invoices"})
+	assert.True(t, result.IsError)
+
+	result = tool.Execute(context.Background(), map[string]any{"method": "GET", "path": "/invoices"})
+	assert.True(t, result.IsError)
+}
+
+func TestLedgerForgeTool_ReportsPostedTransactions(t *testing.T) {
+	var events []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/businesses/biz-1/events" {
+			events = append(events, r.Header.Get(ledgerforge.IdempotencyHeader))
+			return
+		}
+		io.WriteString(w, `{"status":"posted"}`)
+	}))
+	defer srv.Close()
+	tool := NewLedgerForgeTool(ledgerforge.New(ledgerforge.Options{BaseURL: srv.URL, CallbackPath: "/events"}))
+	ctx := ledgerforge.WithAuth(context.Background(), "jwt-1", "biz-1")
+
+	result := tool.Execute(ctx, map[string]any{
+		"method": "PATCH", "path": "/transactions/tx-1", "body": map[string]any{"status": "posted"},
+	})
+	assert.False(t, result.IsError, result.ForLLM)
+	result = tool.Execute(ctx, map[string]any{
+		"method": "PATCH", "path": "/transactions/bulk-status",
+		"body": map[string]any{"transaction_ids": []any{"tx-2", "tx-3"}, "status": "posted"},
+	})
+	assert.False(t, result.IsError, result.ForLLM)
+	result = tool.Execute(ctx, map[string]any{
+		"method": "PATCH", "path": "/transactions/tx-4", "body": map[string]any{"status": "void"},
+	})
+	assert.False(t, result.IsError, result.ForLLM)
+
+	assert.Equal(t, []string{"transaction-posted-tx-1", "transaction-posted-tx-2", "transaction-posted-tx-3"}, events)
+}
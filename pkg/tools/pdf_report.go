@@ -0,0 +1,154 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/artifact"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/pdf"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// PDFReportTool renders Markdown to a PDF in <workspace>/reports, or
+// reports/<business> for a business, and registers it as an artifact so it
+// can be downloaded from the artifact API.
+type PDFReportTool struct {
+	workspace string
+	artifacts *artifact.Registry // nil: the PDF is only saved
+	nowFunc   func() time.Time   // for testing
+}
+
+// NewPDFReportTool creates a pdf_report tool. artifacts may be nil.
+func NewPDFReportTool(workspace string, artifacts *artifact.Registry) *PDFReportTool {
+	return &PDFReportTool{workspace: workspace, artifacts: artifacts, nowFunc: time.Now}
+}
+
+func (t *PDFReportTool) Name() string {
+	return "pdf_report"
+}
+
+func (t *PDFReportTool) Description() string {
+	return "Render a report written in Markdown (headings, paragraphs, **bold**, lists and tables) to a PDF " +
+		"and send it to the user, e.g. a monthly expense report or an invoice. " +
+		"Put figures in Markdown tables; amounts are right-aligned."
+}
+
+func (t *PDFReportTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"title": map[string]any{
+				"type":        "string",
+				"description": "Report title, shown at the top and in the footer",
+			},
+			"content": map[string]any{
+				"type":        "string",
+				"description": "Report body in Markdown",
+			},
+			"filename": map[string]any{
+				"type":        "string",
+				"description": "Optional: file name, e.g. march-expenses.pdf (default: from the title and date)",
+			},
+			"page_size": map[string]any{
+				"type":        "string",
+				"description": "Optional: letter (default) or a4",
+				"enum":        []string{"letter", "a4"},
+			},
+		},
+		"required": []string{"title", "content"},
+	}
+}
+
+func (t *PDFReportTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	title, _ := args["title"].(string)
+	content, _ := args["content"].(string)
+	if strings.TrimSpace(title) == "" || strings.TrimSpace(content) == "" {
+		return ErrorResult("title and content are required")
+	}
+	opts := pdf.Options{Title: strings.TrimSpace(title), PageSize: pdf.Letter, Created: t.nowFunc()}
+	switch size, _ := args["page_size"].(string); strings.ToLower(size) {
+	case "", "letter":
+	case "a4":
+		opts.PageSize = pdf.A4
+	default:
+		return ErrorResult("page_size must be letter or a4")
+	}
+
+	dir := filepath.Join(t.workspace, "reports")
+	businessID, _ := ctx.Value(constants.ContextKeyBusinessID).(string)
+	if businessID != "" {
+		dir = filepath.Join(dir, utils.BusinessDirName(businessID))
+	}
+	name, _ := args["filename"].(string)
+	name = reportFileName(name, opts.Title, opts.Created)
+
+	data, pages := pdf.Render(content, opts)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return ErrorResult(fmt.Sprintf("failed to create reports directory: %v", err)).WithError(err)
+	}
+	path := uniquePath(filepath.Join(dir, name))
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return ErrorResult(fmt.Sprintf("failed to write report: %v", err)).WithError(err)
+	}
+	rel, _ := filepath.Rel(t.workspace, path)
+	summary := fmt.Sprintf("Created %s (%d pages, %d bytes)", rel, pages, len(data))
+
+	if t.artifacts == nil {
+		return &ToolResult{ForLLM: summary, ForUser: fmt.Sprintf("📎 %s is ready", filepath.Base(path))}
+	}
+	sessionKey, _ := ctx.Value(constants.ContextKeySessionKey).(string)
+	a, err := t.artifacts.Register(ctx, path, sessionKey, businessID)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("%s but could not register it for download: %v", summary, err)).WithError(err)
+	}
+	return &ToolResult{
+		ForLLM:  fmt.Sprintf("%s as artifact %s, downloadable at /artifacts/%s", summary, a.ID, a.ID),
+		ForUser: fmt.Sprintf("📎 %s is ready (artifact %s, %d bytes)", a.Name, a.ID, a.Size),
+	}
+}
+
+// reportFileName returns a safe PDF file name: the requested one, or one
+// made from the title and date.
+func reportFileName(requested, title string, now time.Time) string {
+	base := strings.TrimSuffix(filepath.Base(strings.TrimSpace(requested)), filepath.Ext(requested))
+	if requested == "" || base == "." || base == string(filepath.Separator) {
+		base = title + " " + now.Format(time.DateOnly)
+	}
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(base) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_' {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	slug := strings.TrimRight(b.String(), "-")
+	if len(slug) > 80 {
+		slug = strings.TrimRight(slug[:80], "-")
+	}
+	if slug == "" {
+		slug = "report-" + now.Format(time.DateOnly)
+	}
+	return slug + ".pdf"
+}
+
+// uniquePath returns path, or path with a counter before the extension if a
+// file already exists there.
+func uniquePath(path string) string {
+	ext := filepath.Ext(path)
+	stem := strings.TrimSuffix(path, ext)
+	for i := 2; ; i++ {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return path
+		}
+		path = fmt.Sprintf("%s-%d%s", stem, i, ext)
+	}
+}
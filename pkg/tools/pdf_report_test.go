@@ -0,0 +1,56 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/artifact"
+	"github.com/sipeed/picoclaw/pkg/constants"
+)
+
+func TestPDFReportTool_RegistersArtifact(t *testing.T) {
+	workspace := t.TempDir()
+	registry, err := artifact.NewRegistry(workspace, artifact.Options{})
+	require.NoError(t, err)
+	tool := NewPDFReportTool(workspace, registry)
+	tool.nowFunc = func() time.Time { return time.Date(2026, 3, 31, 9, 0, 0, 0, time.UTC) }
+
+	ctx := context.WithValue(context.Background(), constants.ContextKeyBusinessID, "biz-1")
+	ctx = context.WithValue(ctx, constants.ContextKeySessionKey, "agent:main:main")
+	args := map[string]any{
+		"title":   "March Expenses",
+		"content": "| Category | Amount |\n|---|---|\n| Meals | 120.00 |",
+	}
+	result := tool.Execute(ctx, args)
+	require.False(t, result.IsError, result.ForLLM)
+	assert.Contains(t, result.ForUser, "march-expenses-2026-03-31.pdf is ready (artifact art_")
+
+	path := filepath.Join(workspace, "reports", "biz-1", "march-expenses-2026-03-31.pdf")
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(data, []byte("%PDF-")))
+
+	listed := registry.List("agent:main:main", "biz-1")
+	require.Len(t, listed, 1)
+	assert.Equal(t, "application/pdf", listed[0].MIME)
+
+	// The same report again gets a new file instead of replacing the first.
+	result = tool.Execute(ctx, args)
+	require.False(t, result.IsError, result.ForLLM)
+	assert.FileExists(t, filepath.Join(workspace, "reports", "biz-1", "march-expenses-2026-03-31-2.pdf"))
+}
+
+func TestReportFileName(t *testing.T) {
+	now := time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, "invoice-42.pdf", reportFileName("Invoice #42.pdf", "ignored", now))
+	assert.Equal(t, "passwd.pdf", reportFileName("../../etc/passwd", "ignored", now))
+	assert.Equal(t, "q1-summary-2026-03-31.pdf", reportFileName("", "Q1 Summary", now))
+	assert.Equal(t, "report-2026-03-31.pdf", reportFileName("###.pdf", "ignored", now))
+}
@@ -0,0 +1,108 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+const (
+	// MaxQuickReplies is how many quick replies one answer can offer.
+	MaxQuickReplies = 6
+	// MaxQuickReplyBytes bounds each reply, so it fits Telegram's 64-byte
+	// callback data with a prefix.
+	MaxQuickReplyBytes = 60
+)
+
+type quickRepliesKey struct{}
+
+type quickReplySlot struct {
+	mu      sync.Mutex
+	replies []string
+}
+
+// WithQuickReplies returns ctx with room for the quick replies the agent
+// offers with its answer, which QuickReplies reads back. ctx is returned as
+// is if it already has room.
+func WithQuickReplies(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(quickRepliesKey{}).(*quickReplySlot); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, quickRepliesKey{}, &quickReplySlot{})
+}
+
+// QuickReplies returns the quick replies offered in the request of ctx.
+func QuickReplies(ctx context.Context) []string {
+	slot, ok := ctx.Value(quickRepliesKey{}).(*quickReplySlot)
+	if !ok {
+		return nil
+	}
+	slot.mu.Lock()
+	defer slot.mu.Unlock()
+	return slot.replies
+}
+
+// QuickRepliesTool lets the agent offer replies the user can pick with one
+// tap: buttons in Telegram and Slack, suggestions in the API response. A
+// picked reply comes back as the user's next message.
+type QuickRepliesTool struct{}
+
+func NewQuickRepliesTool() *QuickRepliesTool {
+	return &QuickRepliesTool{}
+}
+
+func (t *QuickRepliesTool) Name() string {
+	return "suggest_replies"
+}
+
+func (t *QuickRepliesTool) Description() string {
+	return "Offer the user a few short replies to pick from, shown as buttons under your answer. " +
+		"Use it when your answer ends in a question with a small set of answers, such as yes/no or a choice " +
+		"of accounts. The reply picked is sent back as the user's next message, so word each one as the user would."
+}
+
+func (t *QuickRepliesTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"replies": map[string]any{
+				"type":        "array",
+				"items":       map[string]any{"type": "string"},
+				"description": fmt.Sprintf("1 to %d replies, each a few words", MaxQuickReplies),
+			},
+		},
+		"required": []string{"replies"},
+	}
+}
+
+func (t *QuickRepliesTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	slot, ok := ctx.Value(quickRepliesKey{}).(*quickReplySlot)
+	if !ok {
+		return ErrorResult("quick replies can't be shown in this conversation; list the options in your answer")
+	}
+	raw, _ := args["replies"].([]any)
+	var replies []string
+	for _, r := range raw {
+		s, _ := r.(string)
+		s = strings.Join(strings.Fields(s), " ")
+		if s == "" {
+			continue
+		}
+		if len(s) > MaxQuickReplyBytes {
+			return ErrorResult(fmt.Sprintf("reply %q is too long; shorten it to a few words", s))
+		}
+		replies = append(replies, s)
+	}
+	if len(replies) == 0 {
+		return ErrorResult("replies is required")
+	}
+	if len(replies) > MaxQuickReplies {
+		return ErrorResult(fmt.Sprintf("at most %d replies can be offered", MaxQuickReplies))
+	}
+
+	slot.mu.Lock()
+	slot.replies = replies
+	slot.mu.Unlock()
+	return SilentResult("The replies will be shown under your answer. Don't repeat them in it.")
+}
@@ -0,0 +1,55 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestQuickRepliesTool_Execute(t *testing.T) {
+	tool := NewQuickRepliesTool()
+	ctx := WithQuickReplies(context.Background())
+
+	result := tool.Execute(ctx, map[string]any{"replies": []any{" Yes,  pay it ", "", "No"}})
+	if result.IsError || !result.Silent {
+		t.Fatalf("Execute() = %+v, want a silent result", result)
+	}
+	got := QuickReplies(ctx)
+	if len(got) != 2 || got[0] != "Yes, pay it" || got[1] != "No" {
+		t.Errorf("QuickReplies() = %q", got)
+	}
+
+	// A second call replaces the replies
+	tool.Execute(ctx, map[string]any{"replies": []any{"Later"}})
+	if got := QuickReplies(WithQuickReplies(ctx)); len(got) != 1 || got[0] != "Later" {
+		t.Errorf("QuickReplies() after second call = %q", got)
+	}
+}
+
+func TestQuickRepliesTool_Rejects(t *testing.T) {
+	tool := NewQuickRepliesTool()
+	ctx := WithQuickReplies(context.Background())
+
+	tests := []struct {
+		name    string
+		replies []any
+	}{
+		{"none", nil},
+		{"blank", []any{" "}},
+		{"too long", []any{strings.Repeat("a", MaxQuickReplyBytes+1)}},
+		{"too many", []any{"1", "2", "3", "4", "5", "6", "7"}},
+	}
+	for _, tt := range tests {
+		if result := tool.Execute(ctx, map[string]any{"replies": tt.replies}); !result.IsError {
+			t.Errorf("%s: Execute() = %+v, want an error", tt.name, result)
+		}
+	}
+	if got := QuickReplies(ctx); got != nil {
+		t.Errorf("QuickReplies() = %q, want none", got)
+	}
+
+	result := tool.Execute(context.Background(), map[string]any{"replies": []any{"Yes"}})
+	if !result.IsError {
+		t.Error("Execute() without WithQuickReplies should fail")
+	}
+}
@@ -6,8 +6,11 @@ import (
 	"sync"
 	"time"
 
+	"github.com/sipeed/picoclaw/pkg/constants"
 	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/telemetry"
+	"github.com/sipeed/picoclaw/pkg/utils"
 )
 
 type ToolRegistry struct {
@@ -67,6 +70,11 @@ func (r *ToolRegistry) ExecuteWithContext(
 	if contextualTool, ok := tool.(ContextualTool); ok && channel != "" && chatID != "" {
 		contextualTool.SetContext(channel, chatID)
 	}
+	// Tools that run concurrently for different chats read the chat from ctx
+	if channel != "" && chatID != "" {
+		ctx = context.WithValue(ctx, constants.ContextKeyChannel, channel)
+		ctx = context.WithValue(ctx, constants.ContextKeyChatID, chatID)
+	}
 
 	// If tool implements AsyncTool and callback is provided, set callback
 	if asyncTool, ok := tool.(AsyncTool); ok && asyncCallback != nil {
@@ -77,10 +85,20 @@ func (r *ToolRegistry) ExecuteWithContext(
 			})
 	}
 
+	ctx, span := telemetry.StartSpan(ctx, "tool.execute", telemetry.SpanKindInternal,
+		telemetry.String("tool.name", name))
 	start := time.Now()
 	result := tool.Execute(ctx, args)
 	duration := time.Since(start)
 
+	if result.IsError {
+		span.RecordError(fmt.Errorf("%s", utils.Truncate(result.ForLLM, 200)))
+	}
+	span.End()
+	telemetry.AddCounter("picoclaw.tool.calls", 1,
+		telemetry.String("tool.name", name), telemetry.Bool("error", result.IsError))
+	telemetry.RecordDuration("picoclaw.tool.duration", duration, telemetry.String("tool.name", name))
+
 	// Log based on result type
 	if result.IsError {
 		logger.ErrorCF("tool", "Tool execution failed",
@@ -0,0 +1,77 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/documents"
+)
+
+// SearchDocumentsTool lets the agent look up passages of the indexed
+// business documents. Searches made on behalf of a business see its
+// documents and the shared ones, never another business's.
+type SearchDocumentsTool struct {
+	store *documents.Store
+}
+
+// NewSearchDocumentsTool creates a search_documents tool backed by store.
+func NewSearchDocumentsTool(store *documents.Store) *SearchDocumentsTool {
+	return &SearchDocumentsTool{store: store}
+}
+
+func (t *SearchDocumentsTool) Name() string {
+	return "search_documents"
+}
+
+func (t *SearchDocumentsTool) Description() string {
+	return "Search the business's own documents (contracts, price lists, policies) for passages about a question. " +
+		"Use this before answering questions about prices, terms or policies, and quote the document you rely on."
+}
+
+func (t *SearchDocumentsTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"query": map[string]any{
+				"type":        "string",
+				"description": "The question or topic to look up, e.g. 'late payment penalty'",
+			},
+			"limit": map[string]any{
+				"type":        "integer",
+				"description": "Maximum number of passages (1-10, default 4)",
+				"minimum":     1.0,
+				"maximum":     10.0,
+			},
+		},
+		"required": []string{"query"},
+	}
+}
+
+func (t *SearchDocumentsTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	query, _ := args["query"].(string)
+	if strings.TrimSpace(query) == "" {
+		return ErrorResult("query is required")
+	}
+	limit := documents.DefaultSearchLimit
+	if v, ok := args["limit"].(float64); ok && v >= 1 {
+		limit = min(int(v), 10)
+	}
+	businessID, _ := ctx.Value(constants.ContextKeyBusinessID).(string)
+
+	matches, err := t.store.Search(ctx, businessID, query, limit)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("document search failed: %v", err))
+	}
+	if len(matches) == 0 {
+		return SilentResult("No documents are indexed.")
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Found %d passage(s):\n", len(matches))
+	for _, m := range matches {
+		fmt.Fprintf(&b, "\n[%s, score %.2f]\n%s\n", m.Document, m.Score, m.Text)
+	}
+	return SilentResult(b.String())
+}
@@ -0,0 +1,101 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/transcript"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// SearchHistoryTool lets the agent search past conversation turns. Searches
+// made on behalf of a business only see that business's turns.
+type SearchHistoryTool struct {
+	store *transcript.Store
+}
+
+// NewSearchHistoryTool creates a search_history tool backed by store.
+func NewSearchHistoryTool(store *transcript.Store) *SearchHistoryTool {
+	return &SearchHistoryTool{store: store}
+}
+
+func (t *SearchHistoryTool) Name() string {
+	return "search_history"
+}
+
+func (t *SearchHistoryTool) Description() string {
+	return "Search past conversations (messages, responses and tool output) by keywords and date range. " +
+		"Use this to find earlier receipts, transactions or answers, e.g. query 'hardware store receipt' with since/until for March."
+}
+
+func (t *SearchHistoryTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"query": map[string]any{
+				"type":        "string",
+				"description": "Keywords that must all appear in the turn; leave empty to list recent turns",
+			},
+			"since": map[string]any{
+				"type":        "string",
+				"description": "Optional: earliest date, YYYY-MM-DD",
+			},
+			"until": map[string]any{
+				"type":        "string",
+				"description": "Optional: latest date (inclusive), YYYY-MM-DD",
+			},
+			"limit": map[string]any{
+				"type":        "integer",
+				"description": "Maximum number of results (1-20, default 5)",
+				"minimum":     1.0,
+				"maximum":     20.0,
+			},
+		},
+	}
+}
+
+func (t *SearchHistoryTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	q := transcript.Query{Limit: 5}
+	q.Text, _ = args["query"].(string)
+	q.BusinessID, _ = ctx.Value(constants.ContextKeyBusinessID).(string)
+	if v, ok := args["limit"].(float64); ok && v >= 1 {
+		q.Limit = min(int(v), 20)
+	}
+	if v, _ := args["since"].(string); v != "" {
+		since, err := time.ParseInLocation(time.DateOnly, v, time.Local)
+		if err != nil {
+			return ErrorResult("since must be a date in YYYY-MM-DD format")
+		}
+		q.Since = since
+	}
+	if v, _ := args["until"].(string); v != "" {
+		until, err := time.ParseInLocation(time.DateOnly, v, time.Local)
+		if err != nil {
+			return ErrorResult("until must be a date in YYYY-MM-DD format")
+		}
+		q.Until = until.AddDate(0, 0, 1)
+	}
+
+	results, err := t.store.Search(ctx, q)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("history search failed: %v", err))
+	}
+	if len(results) == 0 {
+		return SilentResult("No matching conversations found.")
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Found %d matching turn(s):\n", len(results))
+	for _, r := range results {
+		fmt.Fprintf(&b, "\n[%s, %s]\n", r.Time.Local().Format("2006-01-02 15:04"), r.Channel)
+		if r.Snippet != "" {
+			fmt.Fprintf(&b, "Match: %s\n", r.Snippet)
+		}
+		fmt.Fprintf(&b, "User: %s\n", utils.Truncate(r.Message, 300))
+		fmt.Fprintf(&b, "Assistant: %s\n", utils.Truncate(r.Response, 500))
+	}
+	return SilentResult(b.String())
+}
@@ -3,17 +3,28 @@ package tools
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
 	"runtime"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
+	"github.com/sipeed/picoclaw/pkg/audit"
 	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/reporting"
+	"github.com/sipeed/picoclaw/pkg/skills"
+	"github.com/sipeed/picoclaw/pkg/usage"
 )
 
 type ExecTool struct {
@@ -22,8 +33,19 @@ type ExecTool struct {
 	denyPatterns        []*regexp.Regexp
 	allowPatterns       []*regexp.Regexp
 	restrictToWorkspace bool
+	auditLog            *audit.Log
+	skillMatrix         skills.EnablementMatrix
+	eventQueue          *skills.EventQueue
+	decrypter           FileDecrypter
 }
 
+// skillScriptPattern extracts the skill name from commands that run a script
+// inside a skills directory, e.g. ~/.picoclaw/skills/oluto/scripts/oluto-receipt.sh.
+var skillScriptPattern = regexp.MustCompile(`(?:^|[\s/"'])skills/([A-Za-z0-9][A-Za-z0-9_.-]*)/`)
+
+// skillNamePattern matches the name of a skill directory.
+var skillNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
 var defaultDenyPatterns = []*regexp.Regexp{
 	regexp.MustCompile(`\brm\s+-[rf]{1,2}\b`),
 	regexp.MustCompile(`\bdel\s+/[fq]\b`),
@@ -166,6 +188,22 @@ func (t *ExecTool) Execute(ctx context.Context, args map[string]any) *ToolResult
 		return ErrorResult(guardError)
 	}
 
+	if len(t.skillMatrix) > 0 {
+		businessID, _ := ctx.Value(constants.ContextKeyBusinessID).(string)
+		for _, name := range skillsInCommand(command, cwd) {
+			if !t.skillMatrix.Allowed(businessID, name) {
+				return ErrorResult(fmt.Sprintf("Skill %q is not enabled for this business", name))
+			}
+		}
+	}
+	skill := skillFromCommand(command)
+
+	runCommand, cleanup, err := t.decryptArgs(command)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+	defer cleanup()
+
 	// timeout == 0 means no timeout
 	var cmdCtx context.Context
 	var cancel context.CancelFunc
@@ -178,9 +216,9 @@ func (t *ExecTool) Execute(ctx context.Context, args map[string]any) *ToolResult
 
 	var cmd *exec.Cmd
 	if runtime.GOOS == "windows" {
-		cmd = exec.CommandContext(cmdCtx, "powershell", "-NoProfile", "-NonInteractive", "-Command", command)
+		cmd = exec.CommandContext(cmdCtx, "powershell", "-NoProfile", "-NonInteractive", "-Command", runCommand)
 	} else {
-		cmd = exec.CommandContext(cmdCtx, "sh", "-c", command)
+		cmd = exec.CommandContext(cmdCtx, "sh", "-c", runCommand)
 	}
 	if cwd != "" {
 		cmd.Dir = cwd
@@ -194,12 +232,35 @@ func (t *ExecTool) Execute(ctx context.Context, args map[string]any) *ToolResult
 		}
 	}
 
+	if requestID, ok := ctx.Value(constants.ContextKeyRequestID).(string); ok && requestID != "" {
+		if cmd.Env == nil {
+			cmd.Env = os.Environ()
+		}
+		cmd.Env = append(cmd.Env, constants.RequestIDEnv+"="+requestID)
+	}
+
+	if skill != "" && t.workingDir != "" {
+		if cmd.Env == nil {
+			cmd.Env = os.Environ()
+		}
+		cmd.Env = append(cmd.Env, "PICOCLAW_WORKSPACE="+t.workingDir)
+	}
+
+	// Give skill scripts a queue for progress and follow-up messages to the originating chat
+	if eventFile := t.openSkillEvents(ctx, skill); eventFile != "" {
+		if cmd.Env == nil {
+			cmd.Env = os.Environ()
+		}
+		cmd.Env = append(cmd.Env, skills.EventFileEnv+"="+eventFile)
+	}
+
 	prepareCommandForTermination(cmd)
 
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 
+	started := time.Now()
 	if err := cmd.Start(); err != nil {
 		return ErrorResult(fmt.Sprintf("failed to start command: %v", err))
 	}
@@ -209,7 +270,6 @@ func (t *ExecTool) Execute(ctx context.Context, args map[string]any) *ToolResult
 		done <- cmd.Wait()
 	}()
 
-	var err error
 	select {
 	case err = <-done:
 	case <-cmdCtx.Done():
@@ -224,6 +284,8 @@ func (t *ExecTool) Execute(ctx context.Context, args map[string]any) *ToolResult
 		}
 	}
 
+	t.recordSkillInvocation(ctx, command, err, time.Since(started), stdout.Len()+stderr.Len())
+
 	output := stdout.String()
 	if stderr.Len() > 0 {
 		output += "\nSTDERR:\n" + stderr.String()
@@ -303,6 +365,240 @@ func (t *ExecTool) guardCommand(command, cwd string) string {
 	return ""
 }
 
+// recordSkillInvocation writes an audit entry when the command ran a skill script.
+func (t *ExecTool) recordSkillInvocation(ctx context.Context, command string, err error, duration time.Duration, outputBytes int) {
+	skill := skillFromCommand(command)
+	if skill == "" {
+		return
+	}
+
+	exitCode := 0
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			exitCode = exitErr.ExitCode()
+		} else {
+			exitCode = -1
+		}
+	}
+
+	businessID, _ := ctx.Value(constants.ContextKeyBusinessID).(string)
+	channel, _ := ctx.Value(constants.ContextKeyChannel).(string)
+	chatID, _ := ctx.Value(constants.ContextKeyChatID).(string)
+
+	usage.RecordSkill(skill, exitCode != 0)
+	if exitCode != 0 {
+		reporting.CaptureError("skills", fmt.Errorf("skill %s exited with code %d", skill, exitCode),
+			map[string]any{
+				"skill":       skill,
+				"business_id": businessID,
+				"duration_ms": duration.Milliseconds(),
+			})
+	}
+
+	if t.auditLog == nil {
+		return
+	}
+	sum := sha256.Sum256([]byte(command))
+
+	if recErr := t.auditLog.RecordSkill(audit.SkillInvocation{
+		Skill:       skill,
+		ArgsHash:    hex.EncodeToString(sum[:]),
+		BusinessID:  businessID,
+		Channel:     channel,
+		ChatID:      chatID,
+		ExitCode:    exitCode,
+		DurationMS:  duration.Milliseconds(),
+		OutputBytes: outputBytes,
+	}); recErr != nil {
+		logger.WarnCF("tool", "Failed to record skill audit entry", map[string]any{
+			"skill": skill,
+			"error": recErr.Error(),
+		})
+	}
+}
+
+// openSkillEvents registers an event route for a skill invocation and returns
+// the event file path, or "" when events cannot be delivered.
+func (t *ExecTool) openSkillEvents(ctx context.Context, skill string) string {
+	if t.eventQueue == nil || skill == "" {
+		return ""
+	}
+	channel, _ := ctx.Value(constants.ContextKeyChannel).(string)
+	chatID, _ := ctx.Value(constants.ContextKeyChatID).(string)
+	if channel == "" || chatID == "" || constants.IsInternalChannel(channel) {
+		return ""
+	}
+
+	businessID, _ := ctx.Value(constants.ContextKeyBusinessID).(string)
+	sessionKey, _ := ctx.Value(constants.ContextKeySessionKey).(string)
+	path, err := t.eventQueue.Open(skills.EventRoute{
+		Skill:      skill,
+		Channel:    channel,
+		ChatID:     chatID,
+		BusinessID: businessID,
+		SessionKey: sessionKey,
+	})
+	if err != nil {
+		logger.WarnCF("tool", "Failed to open skill event queue", map[string]any{
+			"skill": skill,
+			"error": err.Error(),
+		})
+		return ""
+	}
+	return path
+}
+
+// skillFromCommand returns the skill name a command invokes, or "" if none.
+func skillFromCommand(command string) string {
+	m := skillScriptPattern.FindStringSubmatch(command)
+	if m == nil {
+		return ""
+	}
+	return m[1]
+}
+
+// skillsInCommand returns the skills whose directories a command runs in or
+// names. Paths are resolved from cwd, following cd, as the shell would.
+func skillsInCommand(command, cwd string) []string {
+	var names []string
+	add := func(path string) {
+		if name := skillDirName(path); name != "" && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	resolve := func(dir, token string) string {
+		if rest, ok := strings.CutPrefix(token, "~/"); ok {
+			if home, err := os.UserHomeDir(); err == nil {
+				token = filepath.Join(home, rest)
+			}
+		}
+		if filepath.IsAbs(token) {
+			return filepath.Clean(token)
+		}
+		return filepath.Join(dir, token)
+	}
+
+	dir := cwd
+	add(dir)
+	fields := strings.FieldsFunc(command, func(r rune) bool {
+		return unicode.IsSpace(r) || strings.ContainsRune(";&|()<>`", r)
+	})
+	for i := 0; i < len(fields); i++ {
+		token := strings.Trim(fields[i], `"'`)
+		if token == "cd" && i+1 < len(fields) {
+			i++
+			dir = resolve(dir, strings.Trim(fields[i], `"'`))
+			add(dir)
+			continue
+		}
+		add(resolve(dir, token))
+		if _, value, ok := strings.Cut(token, "="); ok {
+			add(resolve(dir, value))
+		}
+	}
+	for _, m := range skillScriptPattern.FindAllStringSubmatch(command, -1) {
+		add("skills/" + m[1] + "/")
+	}
+	return names
+}
+
+// skillDirName returns the skill whose directory holds path: the component
+// after the last "skills" in it, or "" if there is none.
+func skillDirName(path string) string {
+	parts := strings.Split(filepath.ToSlash(path), "/")
+	for i := len(parts) - 2; i >= 0; i-- {
+		if parts[i] == "skills" {
+			if skillNamePattern.MatchString(parts[i+1]) {
+				return parts[i+1]
+			}
+			return ""
+		}
+	}
+	return ""
+}
+
+// decryptArgs replaces each encrypted file named in command with a
+// plaintext copy in a private directory under the workspace, so scripts can
+// read uploads that are encrypted at rest. cleanup removes the copies.
+func (t *ExecTool) decryptArgs(command string) (string, func(), error) {
+	cleanup := func() {}
+	if t.decrypter == nil {
+		return command, cleanup, nil
+	}
+	var dir string
+	copies := make(map[string]bool)
+	for _, field := range strings.Fields(command) {
+		path := strings.Trim(field, `"'`)
+		if copies[path] || !filepath.IsAbs(path) || !t.decrypter.IsEncrypted(path) {
+			continue
+		}
+		if dir == "" {
+			var err error
+			if dir, err = t.decryptDir(); err != nil {
+				return "", cleanup, fmt.Errorf("failed to decrypt %s: %v", filepath.Base(path), err)
+			}
+			cleanup = func() { os.RemoveAll(dir) }
+		}
+		// One directory per copy keeps the file's name without two files
+		// of the same name overwriting each other.
+		plain := filepath.Join(dir, strconv.Itoa(len(copies)), filepath.Base(path))
+		copies[path] = true
+		if err := t.writePlaintext(path, plain); err != nil {
+			cleanup()
+			return "", func() {}, fmt.Errorf("failed to decrypt %s: %v", filepath.Base(path), err)
+		}
+		command = strings.ReplaceAll(command, path, plain)
+	}
+	return command, cleanup, nil
+}
+
+// decryptDir creates a directory only this process can read, under the
+// workspace rather than the shared system temp dir.
+func (t *ExecTool) decryptDir() (string, error) {
+	if t.workingDir == "" {
+		return "", errors.New("no workspace to hold the plaintext")
+	}
+	parent := filepath.Join(t.workingDir, ".decrypted")
+	if err := os.MkdirAll(parent, 0o700); err != nil {
+		return "", err
+	}
+	return os.MkdirTemp(parent, "run-*")
+}
+
+// writePlaintext writes the plaintext of the encrypted file path to plain.
+func (t *ExecTool) writePlaintext(path, plain string) error {
+	data, err := t.decrypter.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := os.Mkdir(filepath.Dir(plain), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(plain, data, 0o600)
+}
+
+// SetAuditLog enables recording of skill script invocations.
+func (t *ExecTool) SetAuditLog(log *audit.Log) {
+	t.auditLog = log
+}
+
+// SetEventQueue lets skill scripts emit asynchronous events via $PICOCLAW_EVENT_FILE.
+func (t *ExecTool) SetEventQueue(queue *skills.EventQueue) {
+	t.eventQueue = queue
+}
+
+// SetDecrypter gives commands plaintext copies of the encrypted files they
+// name.
+func (t *ExecTool) SetDecrypter(d FileDecrypter) {
+	t.decrypter = d
+}
+
+// SetSkillMatrix blocks skill scripts that are not enabled for the calling business.
+func (t *ExecTool) SetSkillMatrix(matrix skills.EnablementMatrix) {
+	t.skillMatrix = matrix
+}
+
 func (t *ExecTool) SetTimeout(timeout time.Duration) {
 	t.timeout = timeout
 }
@@ -7,6 +7,10 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/sipeed/picoclaw/pkg/audit"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/skills"
 )
 
 // TestShellTool_Success verifies successful command execution
@@ -272,3 +276,169 @@ func TestShellTool_RestrictToWorkspace(t *testing.T) {
 		)
 	}
 }
+
+// TestShellTool_RecordsSkillInvocation verifies skill scripts are written to the audit log
+func TestShellTool_RecordsSkillInvocation(t *testing.T) {
+	tmpDir := t.TempDir()
+	scriptDir := filepath.Join(tmpDir, "skills", "oluto", "scripts")
+	if err := os.MkdirAll(scriptDir, 0o755); err != nil {
+		t.Fatalf("failed to create script dir: %v", err)
+	}
+	script := filepath.Join(scriptDir, "post.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\necho posted\nexit 3\n"), 0o755); err != nil {
+		t.Fatalf("failed to write script: %v", err)
+	}
+
+	auditLog := audit.NewLog(tmpDir)
+	tool := NewExecTool(tmpDir, false)
+	tool.SetAuditLog(auditLog)
+
+	ctx := context.WithValue(context.Background(), constants.ContextKeyBusinessID, "biz-42")
+	ctx = context.WithValue(ctx, constants.ContextKeyChannel, "telegram")
+	ctx = context.WithValue(ctx, constants.ContextKeyChatID, "chat-7")
+	tool.Execute(ctx, map[string]any{"command": "sh " + script + " 12.50"})
+	tool.Execute(ctx, map[string]any{"command": "echo not a skill"})
+
+	entries, err := auditLog.QuerySkills(audit.SkillQuery{})
+	if err != nil {
+		t.Fatalf("QuerySkills failed: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 audit entry, got %d", len(entries))
+	}
+	e := entries[0]
+	if e.Skill != "oluto" || e.BusinessID != "biz-42" || e.ExitCode != 3 || e.OutputBytes != len("posted\n") {
+		t.Errorf("Unexpected audit entry: %+v", e)
+	}
+	if e.Channel != "telegram" || e.ChatID != "chat-7" {
+		t.Errorf("Expected the run's channel and chat, got %q %q", e.Channel, e.ChatID)
+	}
+	if len(e.ArgsHash) != 64 {
+		t.Errorf("Expected sha256 args hash, got %q", e.ArgsHash)
+	}
+}
+
+func TestSkillFromCommand(t *testing.T) {
+	cases := map[string]string{
+		"~/.picoclaw/skills/oluto/scripts/oluto-receipt.sh a.jpg": "oluto",
+		"bash \"/home/u/workspace/skills/weather/run.sh\"":        "weather",
+		"python3 skills/report-gen/main.py":                       "report-gen",
+		"ls skills":                                               "",
+		"echo hello":                                              "",
+	}
+	for cmd, want := range cases {
+		if got := skillFromCommand(cmd); got != want {
+			t.Errorf("skillFromCommand(%q) = %q, want %q", cmd, got, want)
+		}
+	}
+}
+
+// TestShellTool_SkillMatrixBlocksDisabledSkill verifies per-business skill enablement
+func TestShellTool_SkillMatrixBlocksDisabledSkill(t *testing.T) {
+	tool := NewExecTool("", false)
+	tool.SetSkillMatrix(skills.EnablementMatrix{"biz-books": {"oluto"}})
+
+	ctx := context.WithValue(context.Background(), constants.ContextKeyBusinessID, "biz-books")
+	result := tool.Execute(ctx, map[string]any{"command": "sh ~/.picoclaw/skills/experimental/run.sh"})
+	if !result.IsError || !strings.Contains(result.ForLLM, "not enabled") {
+		t.Errorf("Expected disabled skill to be blocked, got: %s", result.ForLLM)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"command": "echo skills/oluto/ ok"})
+	if result.IsError {
+		t.Errorf("Expected enabled skill to run, got: %s", result.ForLLM)
+	}
+}
+
+func TestShellTool_SkillMatrixResolvesScriptPaths(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, name := range []string{"oluto", "experimental"} {
+		if err := os.MkdirAll(filepath.Join(tmpDir, "skills", name, "scripts"), 0o755); err != nil {
+			t.Fatalf("failed to create skill dir: %v", err)
+		}
+	}
+	tool := NewExecTool(tmpDir, false)
+	tool.SetSkillMatrix(skills.EnablementMatrix{"biz-books": {"oluto"}})
+	ctx := context.WithValue(context.Background(), constants.ContextKeyBusinessID, "biz-books")
+
+	blocked := []map[string]any{
+		{"command": "cd skills/experimental && sh scripts/run.sh"},
+		{"command": "cd skills; sh experimental/scripts/run.sh"},
+		{"command": "sh scripts/run.sh", "working_dir": filepath.Join(tmpDir, "skills", "experimental")},
+		{"command": "S=skills/experimental/scripts/run.sh; sh $S"},
+		{"command": "sh skills/oluto/scripts/a.sh && sh skills/experimental/scripts/b.sh"},
+	}
+	for _, args := range blocked {
+		result := tool.Execute(ctx, args)
+		if !result.IsError || !strings.Contains(result.ForLLM, "not enabled") {
+			t.Errorf("Expected %v to be blocked, got: %s", args, result.ForLLM)
+		}
+	}
+
+	result := tool.Execute(ctx, map[string]any{"command": "cd skills/oluto && echo ok"})
+	if result.IsError {
+		t.Errorf("Expected enabled skill to run, got: %s", result.ForLLM)
+	}
+
+	result = tool.Execute(context.Background(), map[string]any{"command": "cd skills/oluto && echo ok"})
+	if !result.IsError || !strings.Contains(result.ForLLM, "not enabled") {
+		t.Errorf("Expected a run without a business to be blocked, got: %s", result.ForLLM)
+	}
+}
+
+// TestShellTool_SkillEventFile verifies skill scripts receive an event file routed to the calling chat
+func TestShellTool_DecryptsArgsWithSameName(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, dir := range []string{"a", "b"} {
+		os.MkdirAll(filepath.Join(tmpDir, dir), 0o755)
+	}
+	first := filepath.Join(tmpDir, "a", "receipt.txt")
+	second := filepath.Join(tmpDir, "b", "receipt.txt")
+	os.WriteFile(first, []byte("ENC:svefg"), 0o600)
+	os.WriteFile(second, []byte("ENC:frpbaq"), 0o600)
+
+	tool := NewExecTool(tmpDir, false)
+	tool.SetDecrypter(rot13Decrypter{})
+	result := tool.Execute(context.Background(), map[string]any{
+		"command": "cat " + first + " " + second + " && echo && echo " + first,
+	})
+	if result.IsError {
+		t.Fatalf("Expected success, got: %s", result.ForLLM)
+	}
+	if !strings.Contains(result.ForLLM, "firstsecond") {
+		t.Errorf("Expected both plaintexts, got: %s", result.ForLLM)
+	}
+	if !strings.Contains(result.ForLLM, filepath.Join(tmpDir, ".decrypted")) {
+		t.Errorf("Expected the copy under the workspace, got: %s", result.ForLLM)
+	}
+	if info, err := os.Stat(filepath.Join(tmpDir, ".decrypted")); err != nil || info.Mode().Perm() != 0o700 {
+		t.Errorf("Expected a private directory for the copies, got %v %v", info, err)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(tmpDir, ".decrypted")); len(entries) != 0 {
+		t.Errorf("Expected the copies removed, found %d", len(entries))
+	}
+}
+
+func TestShellTool_SkillEventFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	queue := skills.NewEventQueue(tmpDir)
+	tool := NewExecTool("", false)
+	tool.SetEventQueue(queue)
+
+	ctx := context.WithValue(context.Background(), constants.ContextKeyChannel, "telegram")
+	ctx = context.WithValue(ctx, constants.ContextKeyChatID, "chat-7")
+	result := tool.Execute(ctx, map[string]any{
+		"command": "echo 'halfway there' >> \"$PICOCLAW_EVENT_FILE\" # skills/oluto/run.sh",
+	})
+	if result.IsError {
+		t.Fatalf("Expected success, got: %s", result.ForLLM)
+	}
+
+	events, err := queue.Poll()
+	if err != nil {
+		t.Fatalf("Poll failed: %v", err)
+	}
+	if len(events) != 1 || events[0].Message != "halfway there" || events[0].Route.ChatID != "chat-7" {
+		t.Errorf("Unexpected events: %+v", events)
+	}
+}
@@ -10,6 +10,11 @@ import (
 	"regexp"
 	"strings"
 	"time"
+
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/httpclient"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/utils"
 )
 
 const (
@@ -20,32 +25,79 @@ type SearchProvider interface {
 	Search(ctx context.Context, query string, count int) (string, error)
 }
 
+// SearchHit is a single normalized search result.
+type SearchHit struct {
+	Title   string
+	URL     string
+	Snippet string
+}
+
+// HitSearchProvider is implemented by backends that return individual hits,
+// which lets WebSearchTool apply domain filtering and snippet limits uniformly.
+type HitSearchProvider interface {
+	SearchProvider
+	SearchHits(ctx context.Context, query string, count int) ([]SearchHit, error)
+}
+
+// formatSearchHits renders hits in the listing format shared by all backends.
+func formatSearchHits(query, via string, hits []SearchHit, count int) string {
+	if len(hits) == 0 {
+		return fmt.Sprintf("No results for: %s", query)
+	}
+
+	header := fmt.Sprintf("Results for: %s", query)
+	if via != "" {
+		header += fmt.Sprintf(" (via %s)", via)
+	}
+
+	lines := []string{header}
+	for i, item := range hits {
+		if i >= count {
+			break
+		}
+		lines = append(lines, fmt.Sprintf("%d. %s\n   %s", i+1, item.Title, item.URL))
+		if item.Snippet != "" {
+			lines = append(lines, fmt.Sprintf("   %s", item.Snippet))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
 type BraveSearchProvider struct {
 	apiKey string
 }
 
 func (p *BraveSearchProvider) Search(ctx context.Context, query string, count int) (string, error) {
+	hits, err := p.SearchHits(ctx, query, count)
+	if err != nil {
+		return "", err
+	}
+	return formatSearchHits(query, "", hits, count), nil
+}
+
+func (p *BraveSearchProvider) SearchHits(ctx context.Context, query string, count int) ([]SearchHit, error) {
 	searchURL := fmt.Sprintf("https://api.search.brave.com/res/v1/web/search?q=%s&count=%d",
 		url.QueryEscape(query), count)
 
 	req, err := http.NewRequestWithContext(ctx, "GET", searchURL, nil)
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("X-Subscription-Token", p.apiKey)
 
-	client := &http.Client{Timeout: 10 * time.Second}
+	client := httpclient.New(10 * time.Second)
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("request failed: %w", err)
+		return nil, fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
+		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
 	var searchResp struct {
@@ -61,57 +113,182 @@ func (p *BraveSearchProvider) Search(ctx context.Context, query string, count in
 	if err := json.Unmarshal(body, &searchResp); err != nil {
 		// Log error body for debugging
 		fmt.Printf("Brave API Error Body: %s\n", string(body))
-		return "", fmt.Errorf("failed to parse response: %w", err)
+		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
 
-	results := searchResp.Web.Results
-	if len(results) == 0 {
-		return fmt.Sprintf("No results for: %s", query), nil
+	hits := make([]SearchHit, 0, len(searchResp.Web.Results))
+	for _, item := range searchResp.Web.Results {
+		hits = append(hits, SearchHit{Title: item.Title, URL: item.URL, Snippet: item.Description})
 	}
+	return hits, nil
+}
 
-	var lines []string
-	lines = append(lines, fmt.Sprintf("Results for: %s", query))
-	for i, item := range results {
-		if i >= count {
-			break
-		}
-		lines = append(lines, fmt.Sprintf("%d. %s\n   %s", i+1, item.Title, item.URL))
-		if item.Description != "" {
-			lines = append(lines, fmt.Sprintf("   %s", item.Description))
-		}
+// SearxNGSearchProvider queries a self-hosted SearxNG instance via its JSON API.
+// The instance must have the "json" output format enabled in settings.yml.
+type SearxNGSearchProvider struct {
+	baseURL string
+}
+
+func (p *SearxNGSearchProvider) Search(ctx context.Context, query string, count int) (string, error) {
+	hits, err := p.SearchHits(ctx, query, count)
+	if err != nil {
+		return "", err
+	}
+	return formatSearchHits(query, "SearxNG", hits, count), nil
+}
+
+func (p *SearxNGSearchProvider) SearchHits(ctx context.Context, query string, count int) ([]SearchHit, error) {
+	searchURL := fmt.Sprintf("%s/search?q=%s&format=json",
+		strings.TrimRight(p.baseURL, "/"), url.QueryEscape(query))
+
+	req, err := http.NewRequestWithContext(ctx, "GET", searchURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+
+	client := httpclient.New(10 * time.Second)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("SearxNG error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	var searchResp struct {
+		Results []struct {
+			Title   string `json:"title"`
+			URL     string `json:"url"`
+			Content string `json:"content"`
+		} `json:"results"`
+	}
+
+	if err := json.Unmarshal(body, &searchResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	hits := make([]SearchHit, 0, len(searchResp.Results))
+	for _, item := range searchResp.Results {
+		hits = append(hits, SearchHit{Title: item.Title, URL: item.URL, Snippet: item.Content})
+	}
+	return hits, nil
+}
+
+// BingSearchProvider queries the Bing Web Search v7 API.
+type BingSearchProvider struct {
+	apiKey  string
+	baseURL string
+}
+
+func (p *BingSearchProvider) Search(ctx context.Context, query string, count int) (string, error) {
+	hits, err := p.SearchHits(ctx, query, count)
+	if err != nil {
+		return "", err
+	}
+	return formatSearchHits(query, "Bing", hits, count), nil
+}
+
+func (p *BingSearchProvider) SearchHits(ctx context.Context, query string, count int) ([]SearchHit, error) {
+	baseURL := p.baseURL
+	if baseURL == "" {
+		baseURL = "https://api.bing.microsoft.com/v7.0/search"
+	}
+	searchURL := fmt.Sprintf("%s?q=%s&count=%d", baseURL, url.QueryEscape(query), count)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", searchURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Ocp-Apim-Subscription-Key", p.apiKey)
+
+	client := httpclient.New(10 * time.Second)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
 	}
+	defer resp.Body.Close()
 
-	return strings.Join(lines, "\n"), nil
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Bing API error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	var searchResp struct {
+		WebPages struct {
+			Value []struct {
+				Name    string `json:"name"`
+				URL     string `json:"url"`
+				Snippet string `json:"snippet"`
+			} `json:"value"`
+		} `json:"webPages"`
+	}
+
+	if err := json.Unmarshal(body, &searchResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	hits := make([]SearchHit, 0, len(searchResp.WebPages.Value))
+	for _, item := range searchResp.WebPages.Value {
+		hits = append(hits, SearchHit{Title: item.Name, URL: item.URL, Snippet: item.Snippet})
+	}
+	return hits, nil
 }
 
 type DuckDuckGoSearchProvider struct{}
 
 func (p *DuckDuckGoSearchProvider) Search(ctx context.Context, query string, count int) (string, error) {
+	hits, err := p.SearchHits(ctx, query, count)
+	if err != nil {
+		return "", err
+	}
+	if len(hits) == 0 {
+		return fmt.Sprintf("No results found or extraction failed. Query: %s", query), nil
+	}
+	return formatSearchHits(query, "DuckDuckGo", hits, count), nil
+}
+
+func (p *DuckDuckGoSearchProvider) SearchHits(ctx context.Context, query string, count int) ([]SearchHit, error) {
 	searchURL := fmt.Sprintf("https://html.duckduckgo.com/html/?q=%s", url.QueryEscape(query))
 
 	req, err := http.NewRequestWithContext(ctx, "GET", searchURL, nil)
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("User-Agent", userAgent)
 
-	client := &http.Client{Timeout: 10 * time.Second}
+	client := httpclient.New(10 * time.Second)
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("request failed: %w", err)
+		return nil, fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
+		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
-	return p.extractResults(string(body), count, query)
+	return p.extractHits(string(body), count), nil
 }
 
-func (p *DuckDuckGoSearchProvider) extractResults(html string, count int, query string) (string, error) {
+func (p *DuckDuckGoSearchProvider) extractHits(html string, count int) []SearchHit {
 	// Simple regex based extraction for DDG HTML
 	// Strategy: Find all result containers or key anchors directly
 
@@ -122,24 +299,16 @@ func (p *DuckDuckGoSearchProvider) extractResults(html string, count int, query
 	matches := reLink.FindAllStringSubmatch(html, count+5)
 
 	if len(matches) == 0 {
-		return fmt.Sprintf("No results found or extraction failed. Query: %s", query), nil
+		return nil
 	}
 
-	var lines []string
-	lines = append(lines, fmt.Sprintf("Results for: %s (via DuckDuckGo)", query))
-
-	// Pre-compile snippet regex to run inside the loop
-	// We'll search for snippets relative to the link position or just globally if needed
-	// But simple global search for snippets might mismatch order.
-	// Since we only have the raw HTML string, let's just extract snippets globally and assume order matches (risky but simple for regex)
-	// Or better: Let's assume the snippet follows the link in the HTML
-
-	// A better regex approach: iterate through text and find matches in order
-	// But for now, let's grab all snippets too
+	// Snippets are extracted globally and assumed to follow the same order as
+	// the result links (simple, but good enough for DDG's HTML layout).
 	reSnippet := regexp.MustCompile(`<a class="result__snippet[^"]*".*?>([\s\S]*?)</a>`)
 	snippetMatches := reSnippet.FindAllStringSubmatch(html, count+5)
 
 	maxItems := min(len(matches), count)
+	hits := make([]SearchHit, 0, maxItems)
 
 	for i := 0; i < maxItems; i++ {
 		urlStr := matches[i][1]
@@ -156,19 +325,16 @@ func (p *DuckDuckGoSearchProvider) extractResults(html string, count int, query
 			}
 		}
 
-		lines = append(lines, fmt.Sprintf("%d. %s\n   %s", i+1, title, urlStr))
+		hit := SearchHit{Title: title, URL: urlStr}
 
 		// Attempt to attach snippet if available and index aligns
 		if i < len(snippetMatches) {
-			snippet := stripTags(snippetMatches[i][1])
-			snippet = strings.TrimSpace(snippet)
-			if snippet != "" {
-				lines = append(lines, fmt.Sprintf("   %s", snippet))
-			}
+			hit.Snippet = strings.TrimSpace(stripTags(snippetMatches[i][1]))
 		}
+		hits = append(hits, hit)
 	}
 
-	return strings.Join(lines, "\n"), nil
+	return hits
 }
 
 func stripTags(content string) string {
@@ -212,7 +378,7 @@ func (p *PerplexitySearchProvider) Search(ctx context.Context, query string, cou
 	req.Header.Set("Authorization", "Bearer "+p.apiKey)
 	req.Header.Set("User-Agent", userAgent)
 
-	client := &http.Client{Timeout: 30 * time.Second}
+	client := httpclient.New(30 * time.Second)
 	resp, err := client.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("request failed: %w", err)
@@ -248,48 +414,141 @@ func (p *PerplexitySearchProvider) Search(ctx context.Context, query string, cou
 }
 
 type WebSearchTool struct {
-	provider   SearchProvider
-	maxResults int
+	provider        SearchProvider
+	via             string
+	maxResults      int
+	maxSnippetChars int
+	allowDomains    []string
+	denyDomains     []string
+	businesses      map[string]bool
 }
 
 type WebSearchToolOptions struct {
+	// Backend pins a specific search backend ("brave", "bing", "searxng",
+	// "duckduckgo", "perplexity"). Empty selects the first enabled backend
+	// in priority order.
+	Backend string
+
 	BraveAPIKey          string
 	BraveMaxResults      int
 	BraveEnabled         bool
+	BingAPIKey           string
+	BingMaxResults       int
+	BingEnabled          bool
+	SearxNGBaseURL       string
+	SearxNGMaxResults    int
+	SearxNGEnabled       bool
 	DuckDuckGoMaxResults int
 	DuckDuckGoEnabled    bool
 	PerplexityAPIKey     string
 	PerplexityMaxResults int
 	PerplexityEnabled    bool
+
+	// MaxSnippetChars caps each result snippet (0 = unlimited).
+	MaxSnippetChars int
+	// AllowDomains, when non-empty, keeps only results from these domains (and subdomains).
+	AllowDomains []string
+	// DenyDomains drops results from these domains (and subdomains). Deny wins over allow.
+	DenyDomains []string
+	// Businesses restricts the tool to the listed business IDs, which also
+	// denies requests without one. Empty means all.
+	Businesses []string
 }
 
 func NewWebSearchTool(opts WebSearchToolOptions) *WebSearchTool {
+	type backend struct {
+		name       string
+		via        string
+		enabled    bool
+		provider   func() SearchProvider
+		maxResults int
+	}
+
+	// Priority: Perplexity > Brave > Bing > SearxNG > DuckDuckGo
+	backends := []backend{
+		{
+			name:       "perplexity",
+			via:        "Perplexity",
+			enabled:    opts.PerplexityEnabled && opts.PerplexityAPIKey != "",
+			provider:   func() SearchProvider { return &PerplexitySearchProvider{apiKey: opts.PerplexityAPIKey} },
+			maxResults: opts.PerplexityMaxResults,
+		},
+		{
+			name:       "brave",
+			via:        "",
+			enabled:    opts.BraveEnabled && opts.BraveAPIKey != "",
+			provider:   func() SearchProvider { return &BraveSearchProvider{apiKey: opts.BraveAPIKey} },
+			maxResults: opts.BraveMaxResults,
+		},
+		{
+			name:       "bing",
+			via:        "Bing",
+			enabled:    opts.BingEnabled && opts.BingAPIKey != "",
+			provider:   func() SearchProvider { return &BingSearchProvider{apiKey: opts.BingAPIKey} },
+			maxResults: opts.BingMaxResults,
+		},
+		{
+			name:       "searxng",
+			via:        "SearxNG",
+			enabled:    opts.SearxNGEnabled && opts.SearxNGBaseURL != "",
+			provider:   func() SearchProvider { return &SearxNGSearchProvider{baseURL: opts.SearxNGBaseURL} },
+			maxResults: opts.SearxNGMaxResults,
+		},
+		{
+			name:       "duckduckgo",
+			via:        "DuckDuckGo",
+			enabled:    opts.DuckDuckGoEnabled,
+			provider:   func() SearchProvider { return &DuckDuckGoSearchProvider{} },
+			maxResults: opts.DuckDuckGoMaxResults,
+		},
+	}
+
 	var provider SearchProvider
+	var via string
 	maxResults := 5
-
-	// Priority: Perplexity > Brave > DuckDuckGo
-	if opts.PerplexityEnabled && opts.PerplexityAPIKey != "" {
-		provider = &PerplexitySearchProvider{apiKey: opts.PerplexityAPIKey}
-		if opts.PerplexityMaxResults > 0 {
-			maxResults = opts.PerplexityMaxResults
+	pinned := strings.ToLower(strings.TrimSpace(opts.Backend))
+	filtered := len(opts.AllowDomains) > 0 || len(opts.DenyDomains) > 0
+	for _, b := range backends {
+		if pinned != "" && b.name != pinned {
+			continue
 		}
-	} else if opts.BraveEnabled && opts.BraveAPIKey != "" {
-		provider = &BraveSearchProvider{apiKey: opts.BraveAPIKey}
-		if opts.BraveMaxResults > 0 {
-			maxResults = opts.BraveMaxResults
+		if !b.enabled {
+			continue
 		}
-	} else if opts.DuckDuckGoEnabled {
-		provider = &DuckDuckGoSearchProvider{}
-		if opts.DuckDuckGoMaxResults > 0 {
-			maxResults = opts.DuckDuckGoMaxResults
+		p := b.provider()
+		if _, ok := p.(HitSearchProvider); !ok && filtered {
+			// Its answer has no hits to filter, so it would leak other domains.
+			logger.WarnCF("tool", "Search backend can't apply domain filters; skipping it",
+				map[string]any{"backend": b.name})
+			continue
 		}
-	} else {
+		provider = p
+		via = b.via
+		if b.maxResults > 0 {
+			maxResults = b.maxResults
+		}
+		break
+	}
+	if provider == nil {
 		return nil
 	}
 
+	var businesses map[string]bool
+	if len(opts.Businesses) > 0 {
+		businesses = make(map[string]bool, len(opts.Businesses))
+		for _, id := range opts.Businesses {
+			businesses[id] = true
+		}
+	}
+
 	return &WebSearchTool{
-		provider:   provider,
-		maxResults: maxResults,
+		provider:        provider,
+		via:             via,
+		maxResults:      maxResults,
+		maxSnippetChars: opts.MaxSnippetChars,
+		allowDomains:    normalizeDomains(opts.AllowDomains),
+		denyDomains:     normalizeDomains(opts.DenyDomains),
+		businesses:      businesses,
 	}
 }
 
@@ -326,6 +585,10 @@ func (t *WebSearchTool) Execute(ctx context.Context, args map[string]any) *ToolR
 		return ErrorResult("query is required")
 	}
 
+	if !t.enabledForBusiness(ctx) {
+		return ErrorResult("web search is not enabled for this business")
+	}
+
 	count := t.maxResults
 	if c, ok := args["count"].(float64); ok {
 		if int(c) > 0 && int(c) <= 10 {
@@ -333,9 +596,28 @@ func (t *WebSearchTool) Execute(ctx context.Context, args map[string]any) *ToolR
 		}
 	}
 
-	result, err := t.provider.Search(ctx, query, count)
-	if err != nil {
-		return ErrorResult(fmt.Sprintf("search failed: %v", err))
+	var result string
+	if hp, ok := t.provider.(HitSearchProvider); ok {
+		// Over-fetch when filtering so dropped hits don't leave the list short.
+		fetch := count
+		if len(t.allowDomains) > 0 || len(t.denyDomains) > 0 {
+			fetch = min(count*2, 20)
+		}
+		hits, err := hp.SearchHits(ctx, query, fetch)
+		if err != nil {
+			return ErrorResult(fmt.Sprintf("search failed: %v", err))
+		}
+		result = formatSearchHits(query, t.via, t.applyPolicy(hits), count)
+	} else {
+		var err error
+		result, err = t.provider.Search(ctx, query, count)
+		if err != nil {
+			return ErrorResult(fmt.Sprintf("search failed: %v", err))
+		}
+		// An answer has no snippets to cap, so cap it as a whole.
+		if t.maxSnippetChars > 0 {
+			result = utils.Truncate(result, t.maxSnippetChars*count)
+		}
 	}
 
 	return &ToolResult{
@@ -344,6 +626,68 @@ func (t *WebSearchTool) Execute(ctx context.Context, args map[string]any) *ToolR
 	}
 }
 
+// enabledForBusiness reports whether the tool may run for the business in ctx.
+// With a business list, requests without a business context (CLI, chat
+// channels) are denied, as they could be anyone's.
+func (t *WebSearchTool) enabledForBusiness(ctx context.Context) bool {
+	if len(t.businesses) == 0 {
+		return true
+	}
+	businessID, _ := ctx.Value(constants.ContextKeyBusinessID).(string)
+	return businessID != "" && t.businesses[businessID]
+}
+
+// applyPolicy drops hits outside the domain allow/deny lists and caps snippet length.
+func (t *WebSearchTool) applyPolicy(hits []SearchHit) []SearchHit {
+	filtered := make([]SearchHit, 0, len(hits))
+	for _, hit := range hits {
+		host := ""
+		if u, err := url.Parse(hit.URL); err == nil {
+			host = strings.ToLower(u.Hostname())
+		}
+		if matchesDomain(host, t.denyDomains) {
+			continue
+		}
+		if len(t.allowDomains) > 0 && !matchesDomain(host, t.allowDomains) {
+			continue
+		}
+		if t.maxSnippetChars > 0 {
+			hit.Snippet = utils.Truncate(hit.Snippet, t.maxSnippetChars)
+		}
+		filtered = append(filtered, hit)
+	}
+	return filtered
+}
+
+// normalizeDomains lowercases domains and strips schemes and leading dots.
+func normalizeDomains(domains []string) []string {
+	result := make([]string, 0, len(domains))
+	for _, d := range domains {
+		d = strings.ToLower(strings.TrimSpace(d))
+		d = strings.TrimPrefix(d, "https://")
+		d = strings.TrimPrefix(d, "http://")
+		d = strings.TrimPrefix(d, "*.")
+		d = strings.Trim(d, "./")
+		if d != "" {
+			result = append(result, d)
+		}
+	}
+	return result
+}
+
+// matchesDomain reports whether host equals one of domains or is a subdomain of one.
+func matchesDomain(host string, domains []string) bool {
+	if host == "" {
+		return false
+	}
+	for _, d := range domains {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
 type WebFetchTool struct {
 	maxChars int
 }
@@ -417,13 +761,8 @@ func (t *WebFetchTool) Execute(ctx context.Context, args map[string]any) *ToolRe
 	req.Header.Set("User-Agent", userAgent)
 
 	client := &http.Client{
-		Timeout: 60 * time.Second,
-		Transport: &http.Transport{
-			MaxIdleConns:        10,
-			IdleConnTimeout:     30 * time.Second,
-			DisableCompression:  false,
-			TLSHandshakeTimeout: 15 * time.Second,
-		},
+		Timeout:   60 * time.Second,
+		Transport: httpclient.Transport(),
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			if len(via) >= 5 {
 				return fmt.Errorf("stopped after 5 redirects")
@@ -443,28 +782,7 @@ func (t *WebFetchTool) Execute(ctx context.Context, args map[string]any) *ToolRe
 		return ErrorResult(fmt.Sprintf("failed to read response: %v", err))
 	}
 
-	contentType := resp.Header.Get("Content-Type")
-
-	var text, extractor string
-
-	if strings.Contains(contentType, "application/json") {
-		var jsonData any
-		if err := json.Unmarshal(body, &jsonData); err == nil {
-			formatted, _ := json.MarshalIndent(jsonData, "", "  ")
-			text = string(formatted)
-			extractor = "json"
-		} else {
-			text = string(body)
-			extractor = "raw"
-		}
-	} else if strings.Contains(contentType, "text/html") || len(body) > 0 &&
-		(strings.HasPrefix(string(body), "<!DOCTYPE") || strings.HasPrefix(strings.ToLower(string(body)), "<html")) {
-		text = t.extractText(string(body))
-		extractor = "text"
-	} else {
-		text = string(body)
-		extractor = "raw"
-	}
+	text, extractor := extractContent(resp.Header.Get("Content-Type"), body)
 
 	truncated := len(text) > maxChars
 	if truncated {
@@ -495,6 +813,11 @@ func (t *WebFetchTool) Execute(ctx context.Context, args map[string]any) *ToolRe
 }
 
 func (t *WebFetchTool) extractText(htmlContent string) string {
+	return htmlToText(htmlContent)
+}
+
+// htmlToText strips scripts, styles and tags from HTML and collapses whitespace.
+func htmlToText(htmlContent string) string {
 	re := regexp.MustCompile(`<script[\s\S]*?</script>`)
 	result := re.ReplaceAllLiteralString(htmlContent, "")
 	re = regexp.MustCompile(`<style[\s\S]*?</style>`)
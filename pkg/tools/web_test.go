@@ -7,6 +7,8 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/constants"
 )
 
 // TestWebTool_WebFetch_Success verifies successful URL fetching
@@ -333,3 +335,133 @@ func TestWebTool_WebFetch_MissingDomain(t *testing.T) {
 		t.Errorf("Expected domain error message, got ForLLM: %s", result.ForLLM)
 	}
 }
+
+// TestWebTool_WebSearch_SearxNG verifies SearxNG results are parsed and formatted
+func TestWebTool_WebSearch_SearxNG(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/search" || r.URL.Query().Get("format") != "json" {
+			t.Errorf("unexpected request: %s", r.URL.String())
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"results":[{"title":"Go","url":"https://go.dev/","content":"The Go language"}]}`))
+	}))
+	defer server.Close()
+
+	tool := NewWebSearchTool(WebSearchToolOptions{SearxNGEnabled: true, SearxNGBaseURL: server.URL})
+	if tool == nil {
+		t.Fatal("Expected SearxNG tool to be created")
+	}
+
+	result := tool.Execute(context.Background(), map[string]any{"query": "golang"})
+	if result.IsError {
+		t.Fatalf("Expected success, got error: %s", result.ForLLM)
+	}
+	if !strings.Contains(result.ForLLM, "https://go.dev/") || !strings.Contains(result.ForLLM, "via SearxNG") {
+		t.Errorf("Expected SearxNG result in output, got: %s", result.ForLLM)
+	}
+}
+
+// TestWebTool_WebSearch_BackendPinned verifies that an explicit backend overrides priority order
+func TestWebTool_WebSearch_BackendPinned(t *testing.T) {
+	tool := NewWebSearchTool(WebSearchToolOptions{
+		Backend:           "duckduckgo",
+		BraveEnabled:      true,
+		BraveAPIKey:       "key",
+		DuckDuckGoEnabled: true,
+	})
+	if tool == nil {
+		t.Fatal("Expected tool to be created")
+	}
+	if _, ok := tool.provider.(*DuckDuckGoSearchProvider); !ok {
+		t.Errorf("Expected DuckDuckGo provider, got %T", tool.provider)
+	}
+
+	// Pinned backend that is not configured yields no tool
+	tool = NewWebSearchTool(WebSearchToolOptions{Backend: "bing", DuckDuckGoEnabled: true})
+	if tool != nil {
+		t.Errorf("Expected nil tool when pinned backend is not configured")
+	}
+}
+
+// TestWebTool_WebSearch_DomainPolicy verifies allow/deny filtering and snippet caps
+func TestWebTool_WebSearch_DomainPolicy(t *testing.T) {
+	tool := &WebSearchTool{
+		maxSnippetChars: 10,
+		allowDomains:    normalizeDomains([]string{"example.com", "https://docs.org"}),
+		denyDomains:     normalizeDomains([]string{"*.ads.example.com"}),
+	}
+
+	hits := tool.applyPolicy([]SearchHit{
+		{Title: "a", URL: "https://example.com/page", Snippet: "short"},
+		{Title: "b", URL: "https://www.example.com/x", Snippet: "a very long snippet indeed"},
+		{Title: "c", URL: "https://ads.example.com/promo"},
+		{Title: "d", URL: "https://notexample.com/"},
+		{Title: "e", URL: "https://docs.org/guide"},
+	})
+
+	var titles []string
+	for _, h := range hits {
+		titles = append(titles, h.Title)
+	}
+	if got := strings.Join(titles, ","); got != "a,b,e" {
+		t.Errorf("Expected hits a,b,e, got %s", got)
+	}
+	if hits[1].Snippet != "a very ..." {
+		t.Errorf("Expected truncated snippet, got %q", hits[1].Snippet)
+	}
+}
+
+// TestWebTool_WebSearch_BusinessGate verifies per-business enablement
+func TestWebTool_WebSearch_BusinessGate(t *testing.T) {
+	tool := NewWebSearchTool(WebSearchToolOptions{
+		BraveEnabled: true,
+		BraveAPIKey:  "key",
+		Businesses:   []string{"biz-1"},
+	})
+
+	ctx := context.WithValue(context.Background(), constants.ContextKeyBusinessID, "biz-2")
+	result := tool.Execute(ctx, map[string]any{"query": "news"})
+	if !result.IsError || !strings.Contains(result.ForLLM, "not enabled") {
+		t.Errorf("Expected business gate error, got: %s", result.ForLLM)
+	}
+
+	if !tool.enabledForBusiness(context.WithValue(context.Background(), constants.ContextKeyBusinessID, "biz-1")) {
+		t.Errorf("Expected biz-1 to be enabled")
+	}
+	if tool.enabledForBusiness(context.Background()) {
+		t.Errorf("Expected requests without business context to be denied")
+	}
+	if !NewWebSearchTool(WebSearchToolOptions{BraveEnabled: true, BraveAPIKey: "key"}).
+		enabledForBusiness(context.Background()) {
+		t.Errorf("Expected requests without business context to be allowed without a business list")
+	}
+}
+
+// TestWebTool_WebSearch_DomainPolicySkipsPerplexity verifies that domain filters
+// fall through to a backend that can enforce them
+func TestWebTool_WebSearch_DomainPolicySkipsPerplexity(t *testing.T) {
+	opts := WebSearchToolOptions{
+		PerplexityEnabled: true,
+		PerplexityAPIKey:  "key",
+		DuckDuckGoEnabled: true,
+		AllowDomains:      []string{"example.com"},
+	}
+	tool := NewWebSearchTool(opts)
+	if tool == nil {
+		t.Fatal("Expected tool to be created")
+	}
+	if _, ok := tool.provider.(*DuckDuckGoSearchProvider); !ok {
+		t.Errorf("Expected DuckDuckGo provider, got %T", tool.provider)
+	}
+
+	opts.Backend = "perplexity"
+	if tool := NewWebSearchTool(opts); tool != nil {
+		t.Errorf("Expected nil tool when pinned backend can't filter domains")
+	}
+
+	opts.AllowDomains = nil
+	tool = NewWebSearchTool(opts)
+	if _, ok := tool.provider.(*PerplexitySearchProvider); !ok {
+		t.Errorf("Expected Perplexity provider without domain filters, got %T", tool.provider)
+	}
+}
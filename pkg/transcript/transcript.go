@@ -0,0 +1,283 @@
+// Package transcript keeps every conversation turn in a SQLite database with
+// a full-text index, so past messages, responses and tool output can be
+// searched ("the receipt from the hardware store in March").
+//
+// The database lives at <workspace>/state/transcripts.db. Turns are stored in
+// the turns table; turns_fts is an FTS5 index over its message, response and
+// tool_calls columns. Skills may open the file read-only and query it.
+package transcript
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+
+	_ "modernc.org/sqlite" // registers the "sqlite" driver
+)
+
+// DefaultSearchLimit caps search results when no limit is given.
+const DefaultSearchLimit = 20
+
+// maxToolResultChars bounds the tool output kept per call.
+const maxToolResultChars = 4000
+
+const schema = `
+CREATE TABLE IF NOT EXISTS turns (
+	id          INTEGER PRIMARY KEY,
+	time        INTEGER NOT NULL, -- unix milliseconds
+	agent_id    TEXT NOT NULL DEFAULT '',
+	session_key TEXT NOT NULL DEFAULT '',
+	channel     TEXT NOT NULL DEFAULT '',
+	chat_id     TEXT NOT NULL DEFAULT '',
+	business_id TEXT NOT NULL DEFAULT '',
+	message     TEXT NOT NULL DEFAULT '',
+	response    TEXT NOT NULL DEFAULT '',
+	tool_calls  TEXT NOT NULL DEFAULT '' -- JSON array
+);
+CREATE INDEX IF NOT EXISTS turns_business_time ON turns (business_id, time);
+CREATE INDEX IF NOT EXISTS turns_session_time ON turns (session_key, time);
+CREATE VIRTUAL TABLE IF NOT EXISTS turns_fts USING fts5 (
+	message, response, tool_calls,
+	content = 'turns', content_rowid = 'id'
+);
+`
+
+// ToolCall is a tool invocation made while answering a turn.
+type ToolCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments,omitempty"` // JSON
+	Result    string `json:"result,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Turn is one user message and the agent's response to it.
+type Turn struct {
+	ID         int64      `json:"id,omitempty"`
+	Time       time.Time  `json:"time"`
+	AgentID    string     `json:"agent_id,omitempty"`
+	SessionKey string     `json:"session_key,omitempty"`
+	Channel    string     `json:"channel,omitempty"`
+	ChatID     string     `json:"chat_id,omitempty"`
+	BusinessID string     `json:"business_id,omitempty"`
+	Message    string     `json:"message"`
+	Response   string     `json:"response"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+}
+
+// Query filters a search. Zero-valued fields match everything; without Text
+// the most recent turns are returned.
+type Query struct {
+	Text       string // words to match in messages, responses and tool calls
+	BusinessID string
+	Channel    string
+	SessionKey string
+	Since      time.Time
+	Until      time.Time
+	Limit      int // 0 means DefaultSearchLimit
+}
+
+// Result is a matching turn. Snippet highlights the match with [ and ].
+type Result struct {
+	Turn
+	Snippet string `json:"snippet,omitempty"`
+}
+
+// Store is a transcript database.
+type Store struct {
+	db *sql.DB
+}
+
+// Open opens (creating if needed) the transcript database for workspace.
+func Open(workspace string) (*Store, error) {
+	path := filepath.Join(workspace, "state", "transcripts.db")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open transcript database: %w", err)
+	}
+	// SQLite allows one writer; a single connection avoids lock errors.
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize transcript database: %w", err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Record stores a turn and indexes it for search.
+func (s *Store) Record(ctx context.Context, turn Turn) error {
+	if turn.Time.IsZero() {
+		turn.Time = time.Now()
+	}
+	var toolCalls string
+	if len(turn.ToolCalls) > 0 {
+		for i := range turn.ToolCalls {
+			if r := []rune(turn.ToolCalls[i].Result); len(r) > maxToolResultChars {
+				turn.ToolCalls[i].Result = string(r[:maxToolResultChars]) + "…"
+			}
+		}
+		data, err := json.Marshal(turn.ToolCalls)
+		if err != nil {
+			return fmt.Errorf("failed to marshal tool calls: %w", err)
+		}
+		toolCalls = string(data)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `INSERT INTO turns
+		(time, agent_id, session_key, channel, chat_id, business_id, message, response, tool_calls)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		turn.Time.UnixMilli(), turn.AgentID, turn.SessionKey, turn.Channel, turn.ChatID,
+		turn.BusinessID, turn.Message, turn.Response, toolCalls)
+	if err != nil {
+		return fmt.Errorf("failed to record turn: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO turns_fts (rowid, message, response, tool_calls) VALUES (?, ?, ?, ?)`,
+		id, turn.Message, turn.Response, toolCalls); err != nil {
+		return fmt.Errorf("failed to index turn: %w", err)
+	}
+	return tx.Commit()
+}
+
+// DeleteSession removes the turns of a session and their index entries, and
+// returns how many turns it removed.
+func (s *Store) DeleteSession(ctx context.Context, sessionKey string) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// turns_fts keeps no copy of the text, so its entries are removed with
+	// FTS5's delete command, which needs the indexed values.
+	if _, err := tx.ExecContext(ctx, `INSERT INTO turns_fts (turns_fts, rowid, message, response, tool_calls)
+		SELECT 'delete', id, message, response, tool_calls FROM turns WHERE session_key = ?`, sessionKey); err != nil {
+		return 0, fmt.Errorf("failed to unindex turns: %w", err)
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM turns WHERE session_key = ?`, sessionKey)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete turns: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(n), tx.Commit()
+}
+
+// Search returns turns matching q, best matches first when q.Text is set and
+// newest first otherwise.
+func (s *Store) Search(ctx context.Context, q Query) ([]Result, error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+
+	var where []string
+	var args []any
+	match := matchExpr(q.Text)
+	if match != "" {
+		where = append(where, "turns_fts MATCH ?")
+		args = append(args, match)
+	}
+	if q.BusinessID != "" {
+		where = append(where, "t.business_id = ?")
+		args = append(args, q.BusinessID)
+	}
+	if q.Channel != "" {
+		where = append(where, "t.channel = ?")
+		args = append(args, q.Channel)
+	}
+	if q.SessionKey != "" {
+		where = append(where, "t.session_key = ?")
+		args = append(args, q.SessionKey)
+	}
+	if !q.Since.IsZero() {
+		where = append(where, "t.time >= ?")
+		args = append(args, q.Since.UnixMilli())
+	}
+	if !q.Until.IsZero() {
+		where = append(where, "t.time < ?")
+		args = append(args, q.Until.UnixMilli())
+	}
+
+	query := `SELECT t.id, t.time, t.agent_id, t.session_key, t.channel, t.chat_id, t.business_id,
+		t.message, t.response, t.tool_calls, `
+	if match != "" {
+		query += `snippet(turns_fts, -1, '[', ']', '…', 16)
+		FROM turns_fts JOIN turns t ON t.id = turns_fts.rowid`
+	} else {
+		query += `''
+		FROM turns t`
+	}
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	if match != "" {
+		query += " ORDER BY bm25(turns_fts), t.time DESC"
+	} else {
+		query += " ORDER BY t.time DESC"
+	}
+	query += " LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search transcripts: %w", err)
+	}
+	defer rows.Close()
+
+	results := []Result{}
+	for rows.Next() {
+		var r Result
+		var millis int64
+		var toolCalls string
+		if err := rows.Scan(&r.ID, &millis, &r.AgentID, &r.SessionKey, &r.Channel, &r.ChatID, &r.BusinessID,
+			&r.Message, &r.Response, &toolCalls, &r.Snippet); err != nil {
+			return nil, err
+		}
+		r.Time = time.UnixMilli(millis)
+		if toolCalls != "" {
+			if err := json.Unmarshal([]byte(toolCalls), &r.ToolCalls); err != nil {
+				return nil, fmt.Errorf("turn %d has invalid tool calls: %w", r.ID, err)
+			}
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+// matchExpr turns free text into an FTS5 query that matches turns containing
+// every word (as a prefix), so user input cannot inject FTS syntax.
+func matchExpr(text string) string {
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	terms := make([]string, 0, len(words))
+	for _, w := range words {
+		terms = append(terms, `"`+w+`"*`)
+	}
+	return strings.Join(terms, " ")
+}
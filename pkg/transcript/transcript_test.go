@@ -0,0 +1,90 @@
+package transcript
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordAndSearch(t *testing.T) {
+	store, err := Open(t.TempDir())
+	require.NoError(t, err)
+	defer store.Close()
+
+	march := time.Date(2026, 3, 14, 10, 0, 0, 0, time.UTC)
+	turns := []Turn{
+		{
+			Time: march, BusinessID: "biz-1", Channel: "telegram",
+			Message:  "Process the attached receipt",
+			Response: "Recorded $42.10 expense at Acme Hardware Store.",
+			ToolCalls: []ToolCall{
+				{Name: "exec", Arguments: `{"command":"oluto receipt"}`, Result: "vendor: Acme Hardware"},
+			},
+		},
+		{Time: march.AddDate(0, 1, 0), BusinessID: "biz-1", Channel: "telegram", Message: "hardware store again?", Response: "No."},
+		{Time: march, BusinessID: "biz-2", Channel: "webhook", Message: "receipt from the hardware store", Response: "Done."},
+		{Time: march.Add(time.Hour), BusinessID: "biz-1", Channel: "telegram", Message: "What's the weather?", Response: "Sunny."},
+	}
+	for _, turn := range turns {
+		require.NoError(t, store.Record(t.Context(), turn))
+	}
+
+	results, err := store.Search(t.Context(), Query{
+		Text:       "receipt hardware",
+		BusinessID: "biz-1",
+		Since:      time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+		Until:      time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC),
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "Recorded $42.10 expense at Acme Hardware Store.", results[0].Response)
+	assert.Equal(t, march, results[0].Time.UTC())
+	assert.Equal(t, []ToolCall{turns[0].ToolCalls[0]}, results[0].ToolCalls)
+	assert.Contains(t, results[0].Snippet, "[")
+
+	// Tool output is searchable, and words match as prefixes.
+	results, err = store.Search(t.Context(), Query{Text: "acme"})
+	require.NoError(t, err)
+	assert.Len(t, results, 1)
+
+	// FTS syntax in the query is treated as plain words.
+	results, err = store.Search(t.Context(), Query{Text: `hardware" OR "weather`})
+	require.NoError(t, err)
+	assert.Empty(t, results)
+
+	// Without text, the newest turns come first.
+	results, err = store.Search(t.Context(), Query{BusinessID: "biz-1", Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "hardware store again?", results[0].Message)
+	assert.Equal(t, "What's the weather?", results[1].Message)
+}
+
+func TestDeleteSession(t *testing.T) {
+	store, err := Open(t.TempDir())
+	require.NoError(t, err)
+	defer store.Close()
+
+	for _, turn := range []Turn{
+		{SessionKey: "agent:main:telegram:1", Message: "my receipt from Acme", Response: "Recorded."},
+		{SessionKey: "agent:main:telegram:1", Message: "and another one", Response: "Recorded."},
+		{SessionKey: "agent:main:telegram:2", Message: "receipt from Acme", Response: "Recorded."},
+	} {
+		require.NoError(t, store.Record(t.Context(), turn))
+	}
+
+	n, err := store.DeleteSession(t.Context(), "agent:main:telegram:1")
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	results, err := store.Search(t.Context(), Query{Text: "acme"})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "agent:main:telegram:2", results[0].SessionKey)
+
+	n, err = store.DeleteSession(t.Context(), "agent:main:telegram:1")
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
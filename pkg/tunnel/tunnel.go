@@ -0,0 +1,161 @@
+// Package tunnel brings up the Tailscale or WireGuard tunnel the gateway is
+// reached through from outside the LAN, and finds the gateway's address on
+// it. The tunnel itself is run by the system, with tailscaled or the kernel's
+// WireGuard, which keeps picoclaw small: the package drives their command
+// line tools and waits for the tunnel's address.
+package tunnel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+const (
+	ModeTailscale = "tailscale"
+	ModeWireGuard = "wireguard"
+)
+
+// waitTimeout bounds how long Up waits for the tunnel to get an address.
+const waitTimeout = 30 * time.Second
+
+// pollInterval is how often the tunnel's address is checked while waiting.
+var pollInterval = time.Second
+
+// Tunnel is an established tunnel.
+type Tunnel struct {
+	Mode      string
+	Interface string // the network interface, where known
+	Address   net.IP // this host's address on the tunnel
+}
+
+// Up brings up the tunnel cfg describes, if it isn't up already, and returns
+// it once it has an address.
+func Up(ctx context.Context, cfg config.TunnelConfig) (*Tunnel, error) {
+	ctx, cancel := context.WithTimeout(ctx, waitTimeout)
+	defer cancel()
+	switch cfg.Mode {
+	case ModeTailscale:
+		return upTailscale(ctx, cfg)
+	case ModeWireGuard:
+		return upWireGuard(ctx, cfg)
+	default:
+		return nil, fmt.Errorf("unknown tunnel mode %q", cfg.Mode)
+	}
+}
+
+// upTailscale logs the node in to the tailnet with the auth key when it
+// isn't logged in, then asks tailscaled for its address.
+func upTailscale(ctx context.Context, cfg config.TunnelConfig) (*Tunnel, error) {
+	t := &Tunnel{Mode: ModeTailscale, Interface: cfg.Interface}
+	if t.Interface == "" {
+		t.Interface = "tailscale0"
+	}
+	ip, err := tailscaleIP(ctx)
+	if err != nil {
+		if cfg.AuthKey == "" {
+			return nil, fmt.Errorf("tailscale is not logged in and tunnel.auth_key is not set: %w", err)
+		}
+		args := []string{"up", "--authkey=" + cfg.AuthKey}
+		if cfg.Hostname != "" {
+			args = append(args, "--hostname="+cfg.Hostname)
+		}
+		if _, err := run(ctx, "tailscale", args...); err != nil {
+			return nil, err
+		}
+		ip, err = wait(ctx, tailscaleIP)
+		if err != nil {
+			return nil, err
+		}
+	}
+	t.Address = ip
+	return t, nil
+}
+
+func tailscaleIP(ctx context.Context) (net.IP, error) {
+	out, err := run(ctx, "tailscale", "ip", "-4")
+	if err != nil {
+		return nil, err
+	}
+	ip := net.ParseIP(strings.TrimSpace(strings.SplitN(out, "\n", 2)[0]))
+	if ip == nil {
+		return nil, errors.New("tailscale reported no IPv4 address")
+	}
+	return ip, nil
+}
+
+// upWireGuard runs wg-quick with the WireGuard config when its interface has
+// no address yet. wg-quick names the interface after the config file.
+func upWireGuard(ctx context.Context, cfg config.TunnelConfig) (*Tunnel, error) {
+	iface := cfg.Interface
+	if iface == "" && cfg.WireGuardConfig != "" {
+		iface = strings.TrimSuffix(filepath.Base(cfg.WireGuardConfig), ".conf")
+	}
+	if iface == "" {
+		return nil, errors.New("tunnel.interface or tunnel.wireguard_config is required")
+	}
+	t := &Tunnel{Mode: ModeWireGuard, Interface: iface}
+	addr := func(context.Context) (net.IP, error) { return InterfaceIP(iface) }
+	ip, err := addr(ctx)
+	if err != nil {
+		if cfg.WireGuardConfig == "" {
+			return nil, err
+		}
+		if _, err := run(ctx, "wg-quick", "up", cfg.WireGuardConfig); err != nil {
+			return nil, err
+		}
+		if ip, err = wait(ctx, addr); err != nil {
+			return nil, err
+		}
+	}
+	t.Address = ip
+	return t, nil
+}
+
+// InterfaceIP returns the first IPv4 address of the network interface name.
+func InterfaceIP(name string) (net.IP, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range addrs {
+		if ipNet, ok := a.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+			return ipNet.IP.To4(), nil
+		}
+	}
+	return nil, fmt.Errorf("interface %s has no IPv4 address", name)
+}
+
+// wait polls addr until it returns an address or ctx is done.
+func wait(ctx context.Context, addr func(context.Context) (net.IP, error)) (net.IP, error) {
+	for {
+		ip, err := addr(ctx)
+		if err == nil {
+			return ip, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("tunnel got no address: %w", err)
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+func run(ctx context.Context, name string, args ...string) (string, error) {
+	out, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%s %s: %w: %s", name, args[0], err, strings.TrimSpace(string(out)))
+	}
+	return string(out), nil
+}
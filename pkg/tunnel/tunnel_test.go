@@ -0,0 +1,84 @@
+package tunnel
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// fakeTool installs a shell script named name first in PATH, and returns the
+// file its invocations are logged to.
+func fakeTool(t *testing.T, name, script string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	dir := t.TempDir()
+	log := filepath.Join(dir, "calls")
+	body := "#!/bin/sh\necho \"$@\" >> " + log + "\n" + script + "\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(body), 0o755))
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return log
+}
+
+func calls(t *testing.T, log string) []string {
+	t.Helper()
+	data, err := os.ReadFile(log)
+	require.NoError(t, err)
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+func TestTailscale(t *testing.T) {
+	pollInterval = 10 * time.Millisecond
+	marker := filepath.Join(t.TempDir(), "up")
+	// tailscale ip fails until tailscale up has logged the node in.
+	log := fakeTool(t, "tailscale", `case "$1" in
+up) touch `+marker+` ;;
+ip) [ -f `+marker+` ] || { echo "NeedsLogin" >&2; exit 1; }; echo 100.101.102.103; echo fd7a:115c:a1e0::1 ;;
+esac`)
+
+	_, err := Up(context.Background(), config.TunnelConfig{Mode: ModeTailscale})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "auth_key")
+
+	tun, err := Up(context.Background(), config.TunnelConfig{Mode: ModeTailscale, AuthKey: "tskey-1", Hostname: "pi"})
+	require.NoError(t, err)
+	assert.Equal(t, "100.101.102.103", tun.Address.String())
+	assert.Equal(t, "tailscale0", tun.Interface)
+	assert.Equal(t, []string{"ip -4", "ip -4", "up --authkey=tskey-1 --hostname=pi", "ip -4"}, calls(t, log))
+
+	// Once logged in, the node isn't logged in again.
+	_, err = Up(context.Background(), config.TunnelConfig{Mode: ModeTailscale, AuthKey: "tskey-1"})
+	require.NoError(t, err)
+	assert.Len(t, calls(t, log), 5)
+}
+
+func TestWireGuard(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("relies on the interface name lo")
+	}
+	log := fakeTool(t, "wg-quick", `echo "wg-quick: config not found" >&2; exit 1`)
+
+	tun, err := Up(context.Background(), config.TunnelConfig{Mode: ModeWireGuard, Interface: "lo"})
+	require.NoError(t, err)
+	assert.Equal(t, "127.0.0.1", tun.Address.String())
+
+	_, err = Up(context.Background(), config.TunnelConfig{Mode: ModeWireGuard, WireGuardConfig: "/etc/wireguard/wg9.conf"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "config not found")
+	assert.Equal(t, []string{"up /etc/wireguard/wg9.conf"}, calls(t, log))
+
+	_, err = Up(context.Background(), config.TunnelConfig{Mode: ModeWireGuard})
+	assert.Error(t, err)
+	_, err = Up(context.Background(), config.TunnelConfig{Mode: "zerotier"})
+	assert.Error(t, err)
+}
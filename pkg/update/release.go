@@ -0,0 +1,189 @@
+package update
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/httpclient"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// maxHighlights is how many changelog lines a notification quotes.
+const maxHighlights = 5
+
+// Release is a published release, as read from a release feed.
+type Release struct {
+	Version   string    `json:"version"`
+	URL       string    `json:"url,omitempty"`
+	Published time.Time `json:"published,omitempty"`
+	Notes     string    `json:"-"`
+}
+
+// ReleaseStatus is the result of the last release check.
+type ReleaseStatus struct {
+	Current   string    `json:"current"`
+	Latest    string    `json:"latest,omitempty"`
+	Available bool      `json:"update_available"`
+	URL       string    `json:"url,omitempty"`
+	CheckedAt time.Time `json:"checked_at,omitzero"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// feedRelease is a release in the GitHub releases API format, which Gitea
+// and Forgejo share.
+type feedRelease struct {
+	TagName     string    `json:"tag_name"`
+	HTMLURL     string    `json:"html_url"`
+	Body        string    `json:"body"`
+	PublishedAt time.Time `json:"published_at"`
+	Draft       bool      `json:"draft"`
+	Prerelease  bool      `json:"prerelease"`
+}
+
+// ReleaseChecker watches a release feed for versions newer than the running
+// one. Unlike Updater it installs nothing, so it needs no signing key.
+type ReleaseChecker struct {
+	feedURL string
+	current string
+	fetch   func(ctx context.Context, url string, limit int64) ([]byte, error)
+
+	mu     sync.RWMutex
+	status ReleaseStatus
+}
+
+// NewReleaseChecker returns a checker of the feed at feedURL, either the
+// latest release or a list of releases, for a build of version current.
+func NewReleaseChecker(feedURL, current string) *ReleaseChecker {
+	u := &Updater{client: httpclient.New(0)}
+	return &ReleaseChecker{
+		feedURL: feedURL,
+		current: current,
+		fetch:   u.fetch,
+		status:  ReleaseStatus{Current: current},
+	}
+}
+
+// Status returns the result of the last check.
+func (c *ReleaseChecker) Status() ReleaseStatus {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.status
+}
+
+// Check fetches the feed and returns its latest stable release, recording
+// whether it is newer than the running version.
+func (c *ReleaseChecker) Check(ctx context.Context) (*Release, error) {
+	release, err := c.latest(ctx)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.status.CheckedAt = time.Now()
+	if err != nil {
+		c.status.Error = err.Error()
+		return nil, err
+	}
+	c.status = ReleaseStatus{
+		Current:   c.current,
+		Latest:    release.Version,
+		Available: Newer(release.Version, c.current),
+		URL:       release.URL,
+		CheckedAt: c.status.CheckedAt,
+	}
+	return release, nil
+}
+
+func (c *ReleaseChecker) latest(ctx context.Context) (*Release, error) {
+	data, err := c.fetch(ctx, c.feedURL, 4<<20)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch release feed: %w", err)
+	}
+	var releases []feedRelease
+	if strings.HasPrefix(strings.TrimSpace(string(data)), "[") {
+		err = json.Unmarshal(data, &releases)
+	} else {
+		var one feedRelease
+		err = json.Unmarshal(data, &one)
+		releases = []feedRelease{one}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse release feed: %w", err)
+	}
+	var best *feedRelease
+	for i, r := range releases {
+		if r.Draft || r.Prerelease || r.TagName == "" {
+			continue
+		}
+		if best == nil || Newer(r.TagName, best.TagName) {
+			best = &releases[i]
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("release feed has no stable release")
+	}
+	return &Release{
+		Version:   best.TagName,
+		URL:       best.HTMLURL,
+		Published: best.PublishedAt,
+		Notes:     best.Body,
+	}, nil
+}
+
+// Run checks the feed now and then every interval until ctx is done, and
+// calls available with each release newer than the running version.
+func (c *ReleaseChecker) Run(ctx context.Context, interval time.Duration, available func(*Release)) {
+	for {
+		release, err := c.Check(ctx)
+		switch {
+		case err != nil:
+			logger.WarnCF("update", "Release check failed", map[string]any{"error": err.Error()})
+		case Newer(release.Version, c.current):
+			available(release)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// Highlights returns the first list items of release notes in Markdown,
+// without their bullets, for a short notification.
+func Highlights(notes string) []string {
+	var out []string
+	for _, line := range strings.Split(notes, "\n") {
+		line = strings.TrimSpace(line)
+		item, ok := strings.CutPrefix(line, "- ")
+		if !ok {
+			item, ok = strings.CutPrefix(line, "* ")
+		}
+		if !ok || strings.TrimSpace(item) == "" {
+			continue
+		}
+		item = strings.TrimSpace(item)
+		if r := []rune(item); len(r) > 120 {
+			item = string(r[:119]) + "…"
+		}
+		out = append(out, item)
+		if len(out) == maxHighlights {
+			break
+		}
+	}
+	return out
+}
+
+// FormatNotice renders the notice sent to the owner about release.
+func FormatNotice(release *Release, current string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "🆕 picoclaw %s is available (this device runs %s)", release.Version, current)
+	for _, h := range Highlights(release.Notes) {
+		b.WriteString("\n• " + h)
+	}
+	if release.URL != "" {
+		b.WriteString("\n" + release.URL)
+	}
+	return b.String()
+}
@@ -0,0 +1,97 @@
+package update
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const releaseNotes = `## What's new
+
+- Faster receipt scanning
+* Telegram topics support
+
+Some prose that isn't a list item.
+- Fix the daily digest time zone
+`
+
+func serveFeed(t *testing.T, body string) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func TestReleaseCheckLatest(t *testing.T) {
+	url := serveFeed(t, `{"tag_name": "v1.4.0", "html_url": "https://example.com/v1.4.0",
+		"published_at": "2026-09-01T10:00:00Z", "body": "- Faster"}`)
+	c := NewReleaseChecker(url, "v1.3.2")
+	assert.Equal(t, ReleaseStatus{Current: "v1.3.2"}, c.Status(), "nothing checked yet")
+
+	release, err := c.Check(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "v1.4.0", release.Version)
+	assert.Equal(t, time.Date(2026, 9, 1, 10, 0, 0, 0, time.UTC), release.Published)
+
+	status := c.Status()
+	assert.True(t, status.Available)
+	assert.Equal(t, "v1.4.0", status.Latest)
+	assert.Equal(t, "https://example.com/v1.4.0", status.URL)
+	assert.False(t, status.CheckedAt.IsZero())
+
+	c = NewReleaseChecker(url, "v1.4.0")
+	_, err = c.Check(context.Background())
+	require.NoError(t, err)
+	assert.False(t, c.Status().Available, "already on the latest release")
+}
+
+func TestReleaseCheckList(t *testing.T) {
+	url := serveFeed(t, `[
+		{"tag_name": "v2.0.0-rc1", "prerelease": true},
+		{"tag_name": "v1.5.0", "draft": true},
+		{"tag_name": "v1.3.0"},
+		{"tag_name": "v1.4.1"}
+	]`)
+	release, err := NewReleaseChecker(url, "dev").Check(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "v1.4.1", release.Version, "drafts and prereleases are skipped")
+}
+
+func TestReleaseCheckFailure(t *testing.T) {
+	c := NewReleaseChecker(serveFeed(t, `{"message": "API rate limit exceeded"}`), "v1.3.0")
+	_, err := c.Check(context.Background())
+	require.Error(t, err)
+	assert.NotEmpty(t, c.Status().Error)
+	assert.False(t, c.Status().Available)
+}
+
+func TestReleaseCheckRun(t *testing.T) {
+	url := serveFeed(t, `{"tag_name": "v1.4.0"}`)
+	c := NewReleaseChecker(url, "v1.3.0")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	found := make(chan string, 1)
+	go c.Run(ctx, time.Hour, func(r *Release) { found <- r.Version })
+	select {
+	case v := <-found:
+		assert.Equal(t, "v1.4.0", v)
+	case <-time.After(time.Second):
+		t.Fatal("the release was not reported")
+	}
+}
+
+func TestFormatNotice(t *testing.T) {
+	notice := FormatNotice(&Release{Version: "v1.4.0", URL: "https://example.com/v1.4.0", Notes: releaseNotes}, "v1.3.0")
+	assert.Equal(t, "🆕 picoclaw v1.4.0 is available (this device runs v1.3.0)\n"+
+		"• Faster receipt scanning\n"+
+		"• Telegram topics support\n"+
+		"• Fix the daily digest time zone\n"+
+		"https://example.com/v1.4.0", notice)
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+
+package update
+
+import "os"
+
+// replace renames path over exe, which is atomic: exe is always either the
+// old or the new binary. The running process keeps the old one open.
+func replace(path, exe string) error {
+	return os.Rename(path, exe)
+}
@@ -0,0 +1,21 @@
+//go:build windows
+
+package update
+
+import "os"
+
+// replace moves the running exe aside, since Windows cannot overwrite it,
+// and renames path into its place. The old binary is left as exe + ".old"
+// and removed by the next update.
+func replace(path, exe string) error {
+	old := exe + ".old"
+	os.Remove(old)
+	if err := os.Rename(exe, old); err != nil {
+		return err
+	}
+	if err := os.Rename(path, exe); err != nil {
+		os.Rename(old, exe)
+		return err
+	}
+	return nil
+}
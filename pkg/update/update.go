@@ -0,0 +1,285 @@
+// Package update replaces the running binary with a signed release.
+//
+// A release is described by a manifest listing one binary per platform with
+// its SHA-256. The manifest is signed with Ed25519, and the base64 signature
+// is published next to it with a ".sig" suffix. A binary is only installed
+// if the manifest verifies against the configured public key and the
+// download matches its hash.
+package update
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/httpclient"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// maxBinarySize bounds downloads whose manifest entry gives no size.
+const maxBinarySize = 256 << 20
+
+// Binary is a release binary for one platform.
+type Binary struct {
+	URL    string `json:"url"`
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size,omitempty"`
+}
+
+// Manifest describes a release.
+type Manifest struct {
+	Version  string            `json:"version"`
+	Binaries map[string]Binary `json:"binaries"` // by Platform()
+}
+
+// Platform returns this build's key in Manifest.Binaries, such as
+// "linux/arm64".
+func Platform() string {
+	return runtime.GOOS + "/" + runtime.GOARCH
+}
+
+// Updater fetches and installs releases.
+type Updater struct {
+	manifestURL string
+	publicKey   ed25519.PublicKey
+	client      *http.Client
+}
+
+// New returns an Updater for the manifest at manifestURL, signed with the
+// base64 Ed25519 publicKey.
+func New(manifestURL, publicKey string) (*Updater, error) {
+	if manifestURL == "" {
+		return nil, fmt.Errorf("update.manifest_url is not set")
+	}
+	key, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("update.public_key must be a base64 Ed25519 public key")
+	}
+	return &Updater{
+		manifestURL: manifestURL,
+		publicKey:   ed25519.PublicKey(key),
+		client:      httpclient.New(0),
+	}, nil
+}
+
+// Check fetches the manifest and verifies its signature.
+func (u *Updater) Check(ctx context.Context) (*Manifest, error) {
+	data, err := u.fetch(ctx, u.manifestURL, 1<<20)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch release manifest: %w", err)
+	}
+	sigText, err := u.fetch(ctx, u.manifestURL+".sig", 4<<10)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch manifest signature: %w", err)
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sigText)))
+	if err != nil || !ed25519.Verify(u.publicKey, data, sig) {
+		return nil, fmt.Errorf("release manifest signature is invalid")
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse release manifest: %w", err)
+	}
+	if m.Version == "" {
+		return nil, fmt.Errorf("release manifest has no version")
+	}
+	return &m, nil
+}
+
+// Download fetches this platform's binary of m into a temporary file in
+// dir, checks its hash and makes it executable. The caller installs or
+// removes the file.
+func (u *Updater) Download(ctx context.Context, m *Manifest, dir string) (string, error) {
+	bin, ok := m.Binaries[Platform()]
+	if !ok {
+		return "", fmt.Errorf("release %s has no binary for %s", m.Version, Platform())
+	}
+	want, err := hex.DecodeString(bin.SHA256)
+	if err != nil || len(want) != sha256.Size {
+		return "", fmt.Errorf("release %s has an invalid sha256 for %s", m.Version, Platform())
+	}
+	limit := int64(maxBinarySize)
+	if bin.Size > 0 {
+		limit = bin.Size
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, bin.URL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to download %s: %w", bin.URL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download %s: %s", bin.URL, resp.Status)
+	}
+
+	f, err := os.CreateTemp(dir, ".picoclaw-update-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create update file: %w", err)
+	}
+	path := f.Name()
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, h), io.LimitReader(resp.Body, limit+1))
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	switch {
+	case err != nil:
+		err = fmt.Errorf("failed to download %s: %w", bin.URL, err)
+	case n > limit:
+		err = fmt.Errorf("download of %s is larger than %d bytes", bin.URL, limit)
+	case !bytes.Equal(h.Sum(nil), want):
+		err = fmt.Errorf("download of %s does not match the manifest's sha256", bin.URL)
+	default:
+		err = os.Chmod(path, 0o755)
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return path, nil
+}
+
+func (u *Updater) fetch(ctx context.Context, url string, limit int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, limit))
+}
+
+// Apply installs the release in the manifest over the binary at exe if it
+// is newer than current, or whatever its version if force is set. It
+// returns the manifest, and whether the binary was replaced.
+func (u *Updater) Apply(ctx context.Context, exe, current string, force bool) (*Manifest, bool, error) {
+	m, err := u.Check(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	if !force && !Newer(m.Version, current) {
+		return m, false, nil
+	}
+	path, err := u.Download(ctx, m, filepath.Dir(exe))
+	if err != nil {
+		return m, false, err
+	}
+	if err := replace(path, exe); err != nil {
+		os.Remove(path)
+		return m, false, fmt.Errorf("failed to replace %s: %w", exe, err)
+	}
+	return m, true, nil
+}
+
+// Run checks for a release every interval until ctx is done, installs it
+// over exe and calls installed with its version, typically to restart.
+func (u *Updater) Run(
+	ctx context.Context,
+	interval time.Duration,
+	exe, current string,
+	installed func(version string),
+) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		m, ok, err := u.Apply(ctx, exe, current, false)
+		if err != nil {
+			logger.WarnCF("update", "Update check failed", map[string]any{"error": err.Error()})
+			continue
+		}
+		if ok {
+			logger.InfoCF("update", "Installed update", map[string]any{"from": current, "to": m.Version})
+			installed(m.Version)
+			return
+		}
+	}
+}
+
+// Newer reports whether version is a later release than current. Versions
+// are compared as vMAJOR.MINOR.PATCH; a current version that does not parse,
+// such as "dev", is never updated automatically.
+func Newer(version, current string) bool {
+	v, ok := parseVersion(version)
+	c, okCurrent := parseVersion(current)
+	if !ok || !okCurrent {
+		return false
+	}
+	for i := range v {
+		if v[i] != c[i] {
+			return v[i] > c[i]
+		}
+	}
+	return false
+}
+
+func parseVersion(s string) ([3]int, bool) {
+	var out [3]int
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.IndexAny(s, "-+ "); i >= 0 {
+		s = s[:i]
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) == 0 || len(parts) > 3 {
+		return out, false
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return out, false
+		}
+		out[i] = n
+	}
+	return out, true
+}
+
+// GenerateKey returns a new base64 Ed25519 key pair for signing manifests.
+func GenerateKey() (publicKey, privateKey string, err error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	return base64.StdEncoding.EncodeToString(pub), base64.StdEncoding.EncodeToString(priv), nil
+}
+
+// Sign returns the base64 signature of manifest with a private key from
+// GenerateKey, to publish as the manifest's ".sig".
+func Sign(manifest []byte, privateKey string) (string, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(privateKey))
+	if err != nil || len(key) != ed25519.PrivateKeySize {
+		return "", fmt.Errorf("not a base64 Ed25519 private key")
+	}
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(ed25519.PrivateKey(key), manifest)), nil
+}
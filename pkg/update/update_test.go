@@ -0,0 +1,138 @@
+package update
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// release serves a signed manifest for binary at /manifest.json.
+func release(t *testing.T, version string, binary []byte, tamper func(m *Manifest)) (*httptest.Server, string) {
+	t.Helper()
+	pub, priv, err := GenerateKey()
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	sum := sha256.Sum256(binary)
+	m := Manifest{Version: version, Binaries: map[string]Binary{
+		Platform(): {URL: srv.URL + "/picoclaw", SHA256: hex.EncodeToString(sum[:])},
+	}}
+	data, err := json.Marshal(m)
+	require.NoError(t, err)
+	sig, err := Sign(data, priv)
+	require.NoError(t, err)
+	if tamper != nil {
+		tamper(&m)
+		data, _ = json.Marshal(m)
+	}
+
+	mux.HandleFunc("/manifest.json", func(w http.ResponseWriter, r *http.Request) { w.Write(data) })
+	mux.HandleFunc("/manifest.json.sig", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(sig)) })
+	mux.HandleFunc("/picoclaw", func(w http.ResponseWriter, r *http.Request) { w.Write(binary) })
+	return srv, pub
+}
+
+func installedBinary(t *testing.T) string {
+	t.Helper()
+	exe := filepath.Join(t.TempDir(), "picoclaw")
+	require.NoError(t, os.WriteFile(exe, []byte("old binary"), 0o755))
+	return exe
+}
+
+func TestApplyInstallsNewerRelease(t *testing.T) {
+	srv, pub := release(t, "v1.3.0", []byte("new binary"), nil)
+	u, err := New(srv.URL+"/manifest.json", pub)
+	require.NoError(t, err)
+	exe := installedBinary(t)
+
+	m, ok, err := u.Apply(context.Background(), exe, "v1.3.0", false)
+	require.NoError(t, err)
+	assert.False(t, ok, "already up to date")
+	assert.Equal(t, "v1.3.0", m.Version)
+
+	_, ok, err = u.Apply(context.Background(), exe, "v1.2.9", false)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	data, err := os.ReadFile(exe)
+	require.NoError(t, err)
+	assert.Equal(t, "new binary", string(data))
+	info, err := os.Stat(exe)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o755), info.Mode().Perm())
+
+	entries, err := os.ReadDir(filepath.Dir(exe))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "no temporary files left behind")
+}
+
+func TestApplyRejectsTamperedManifest(t *testing.T) {
+	srv, pub := release(t, "v2.0.0", []byte("evil binary"), func(m *Manifest) { m.Version = "v9.9.9" })
+	u, err := New(srv.URL+"/manifest.json", pub)
+	require.NoError(t, err)
+	exe := installedBinary(t)
+
+	_, ok, err := u.Apply(context.Background(), exe, "v1.0.0", false)
+	require.ErrorContains(t, err, "signature is invalid")
+	assert.False(t, ok)
+	data, _ := os.ReadFile(exe)
+	assert.Equal(t, "old binary", string(data))
+}
+
+func TestDownloadChecksHash(t *testing.T) {
+	srv, pub := release(t, "v2.0.0", []byte("real binary"), nil)
+	u, err := New(srv.URL+"/manifest.json", pub)
+	require.NoError(t, err)
+	m, err := u.Check(context.Background())
+	require.NoError(t, err)
+
+	b := m.Binaries[Platform()]
+	b.SHA256 = hex.EncodeToString(make([]byte, sha256.Size))
+	m.Binaries[Platform()] = b
+	dir := t.TempDir()
+	_, err = u.Download(context.Background(), m, dir)
+	require.ErrorContains(t, err, "does not match")
+	entries, _ := os.ReadDir(dir)
+	assert.Empty(t, entries)
+
+	delete(m.Binaries, Platform())
+	_, err = u.Download(context.Background(), m, dir)
+	assert.ErrorContains(t, err, "no binary for")
+}
+
+func TestNewRequiresKey(t *testing.T) {
+	_, err := New("https://example.com/manifest.json", "")
+	assert.Error(t, err)
+	_, err = New("", "AAAA")
+	assert.Error(t, err)
+}
+
+func TestNewer(t *testing.T) {
+	tests := []struct {
+		version, current string
+		want             bool
+	}{
+		{"v1.2.0", "v1.1.9", true},
+		{"v1.10.0", "v1.9.0", true},
+		{"v1.2.0", "v1.2.0", false},
+		{"v1.1.0", "v1.2.0", false},
+		{"2.0", "v1.9.9", true},
+		{"v1.2.1", "v1.2.0-rc1", true},
+		{"v1.2.0", "dev", false},
+		{"latest", "v1.0.0", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Newer(tt.version, tt.current), "%s over %s", tt.version, tt.current)
+	}
+}
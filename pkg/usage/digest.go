@@ -0,0 +1,252 @@
+package usage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// topSkillCount is how many skills the digest lists.
+const topSkillCount = 5
+
+// TokenPrice is the USD cost per million tokens of a model.
+type TokenPrice struct {
+	Prompt     float64
+	Completion float64
+}
+
+// Cost returns the USD cost of prompt and completion tokens.
+func (p TokenPrice) Cost(prompt, completion int64) float64 {
+	return float64(prompt)*p.Prompt/1e6 + float64(completion)*p.Completion/1e6
+}
+
+// DigestOptions configures the daily digest.
+type DigestOptions struct {
+	At     string                // local time of day, "HH:MM"
+	Prices map[string]TokenPrice // by model; models without a price are not costed
+	Send   func(content string) error
+	// Storage, if set, reports storage usage to append to the digest.
+	Storage func() string
+	// Release, if set, reports a newer release to append to the digest, or
+	// "" when there is none.
+	Release func() string
+}
+
+// Digest sends a summary of the day's usage once a day.
+type Digest struct {
+	tracker *Tracker
+	hour    int
+	minute  int
+	prices  map[string]TokenPrice
+	send    func(content string) error
+	storage func() string
+	release func() string
+	now     func() time.Time
+}
+
+// NewDigest creates a digest for tracker.
+func NewDigest(tracker *Tracker, opts DigestOptions) (*Digest, error) {
+	at, err := time.Parse("15:04", opts.At)
+	if err != nil {
+		return nil, fmt.Errorf("invalid digest time %q: expected HH:MM", opts.At)
+	}
+	if opts.Send == nil {
+		return nil, fmt.Errorf("digest has no delivery target")
+	}
+	return &Digest{
+		tracker: tracker,
+		hour:    at.Hour(),
+		minute:  at.Minute(),
+		prices:  opts.Prices,
+		send:    opts.Send,
+		storage: opts.Storage,
+		release: opts.Release,
+		now:     time.Now,
+	}, nil
+}
+
+// Run sends the digest at the configured time every day until ctx is done.
+func (d *Digest) Run(ctx context.Context) {
+	for {
+		next := d.nextRun(d.now())
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if err := d.SendNow(); err != nil {
+			logger.WarnCF("usage", "Failed to send daily digest", map[string]any{"error": err.Error()})
+		}
+	}
+}
+
+// SendNow sends the digest for today so far.
+func (d *Digest) SendNow() error {
+	if err := d.tracker.Save(); err != nil {
+		logger.WarnCF("usage", "Failed to save usage counters", map[string]any{"error": err.Error()})
+	}
+	content := FormatDigest(d.tracker.Day(d.now()), d.prices)
+	if d.storage != nil {
+		if storage := d.storage(); storage != "" {
+			content += "\n" + storage
+		}
+	}
+	if d.release != nil {
+		if release := d.release(); release != "" {
+			content += "\n" + release
+		}
+	}
+	return d.send(content)
+}
+
+func (d *Digest) nextRun(now time.Time) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), d.hour, d.minute, 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// FormatDigest renders a day's counters as a chat message.
+func FormatDigest(day DayStats, prices map[string]TokenPrice) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "📊 Daily digest for %s\n", day.Date)
+
+	fmt.Fprintf(&b, "Requests: %d%s\n", day.TotalRequests(), breakdown(day.Requests))
+
+	var prompt, completion int64
+	var cost float64
+	costed := false
+	for model, tokens := range day.Tokens {
+		prompt += tokens.Prompt
+		completion += tokens.Completion
+		if price, ok := prices[model]; ok {
+			cost += price.Cost(tokens.Prompt, tokens.Completion)
+			costed = true
+		}
+	}
+	fmt.Fprintf(&b, "Tokens: %d in / %d out", prompt, completion)
+	if costed {
+		fmt.Fprintf(&b, " (~$%.2f)", cost)
+	}
+	b.WriteString("\n")
+
+	fmt.Fprintf(&b, "Receipts processed: %d\n", day.Receipts)
+	fmt.Fprintf(&b, "Errors: %d%s\n", day.TotalErrors(), breakdown(day.Errors))
+
+	top := day.TopSkills(topSkillCount)
+	if len(top) == 0 {
+		b.WriteString("Top skills: none")
+	} else {
+		parts := make([]string, 0, len(top))
+		for _, name := range top {
+			c := day.Skills[name]
+			if c.Failures > 0 {
+				parts = append(parts, fmt.Sprintf("%s (%d, %d failed)", name, c.Runs, c.Failures))
+			} else {
+				parts = append(parts, fmt.Sprintf("%s (%d)", name, c.Runs))
+			}
+		}
+		b.WriteString("Top skills: " + strings.Join(parts, ", "))
+	}
+	if len(day.Clients) > 0 {
+		b.WriteString("\nClients: " + formatClients(day.Clients))
+	}
+	if len(day.Variants) > 0 {
+		b.WriteString("\nExperiment:" + formatVariants(day.Variants, prices))
+	}
+	return b.String()
+}
+
+// formatClients lists API clients by request count with their failures, so
+// errors clustering on one app version stand out.
+func formatClients(clients map[string]ClientCount) string {
+	names := make([]string, 0, len(clients))
+	for name := range clients {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := clients[names[i]], clients[names[j]]
+		if a.Requests != b.Requests {
+			return a.Requests > b.Requests
+		}
+		return names[i] < names[j]
+	})
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		c := clients[name]
+		if c.Failures > 0 {
+			parts = append(parts, fmt.Sprintf("%s %d (%d failed, %.0f%%)",
+				name, c.Requests, c.Failures, 100*float64(c.Failures)/float64(c.Requests)))
+		} else {
+			parts = append(parts, fmt.Sprintf("%s %d", name, c.Requests))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatVariants renders one line per experiment variant with its average
+// latency, cost per request and how often users corrected it, so that the
+// variant can be compared with the control.
+func formatVariants(variants map[string]VariantStats, prices map[string]TokenPrice) string {
+	names := make([]string, 0, len(variants))
+	for name := range variants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		v := variants[name]
+		fmt.Fprintf(&b, "\n  %s: %d requests", name, v.Requests)
+		if v.Requests == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, ", %.1fs avg", float64(v.LatencyMS)/float64(v.Requests)/1000)
+		var cost float64
+		costed := false
+		for model, tokens := range v.Tokens {
+			if price, ok := prices[model]; ok {
+				cost += price.Cost(tokens.Prompt, tokens.Completion)
+				costed = true
+			}
+		}
+		if costed {
+			fmt.Fprintf(&b, ", ~$%.4f/request", cost/float64(v.Requests))
+		}
+		fmt.Fprintf(&b, ", %.0f%% corrected", 100*float64(v.Corrections)/float64(v.Requests))
+		if v.Failures > 0 {
+			fmt.Fprintf(&b, ", %d failed", v.Failures)
+		}
+	}
+	return b.String()
+}
+
+// breakdown renders " (a 2, b 1)" ordered by count, or "" when empty.
+func breakdown(counts map[string]int64) string {
+	if len(counts) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s %d", k, counts[k]))
+	}
+	return " (" + strings.Join(parts, ", ") + ")"
+}
@@ -0,0 +1,374 @@
+// Package usage keeps per-day counters of requests, tokens, receipts, errors,
+// skill runs, model experiment variants and API clients in the workspace, for
+// the daily digest sent to the owner.
+//
+// Record functions are no-ops until Init is called.
+package usage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// dateFormat keys days in local time.
+	dateFormat = "2006-01-02"
+	// keepDays bounds how many days are kept on disk.
+	keepDays = 31
+	// saveInterval limits how often counters are written, to spare SD cards.
+	saveInterval = time.Minute
+)
+
+// TokenCount is the token usage of one model.
+type TokenCount struct {
+	Prompt     int64 `json:"prompt"`
+	Completion int64 `json:"completion"`
+}
+
+// SkillCount is the run count of one skill.
+type SkillCount struct {
+	Runs     int64 `json:"runs"`
+	Failures int64 `json:"failures"`
+}
+
+// VariantStats is the traffic served by one side of a model experiment.
+type VariantStats struct {
+	Requests    int64                 `json:"requests"`
+	Failures    int64                 `json:"failures"`
+	LatencyMS   int64                 `json:"latency_ms"`  // summed over requests
+	Corrections int64                 `json:"corrections"` // requests correcting the previous answer
+	Tokens      map[string]TokenCount `json:"tokens"`      // by model
+}
+
+// ClientCount is the API requests from one client, as named in X-Client.
+type ClientCount struct {
+	Requests int64 `json:"requests"`
+	Failures int64 `json:"failures"`
+}
+
+// DayStats holds the counters for one local day.
+type DayStats struct {
+	Date     string                  `json:"date"`
+	Requests map[string]int64        `json:"requests"` // by channel
+	Tokens   map[string]TokenCount   `json:"tokens"`   // by model
+	Receipts int64                   `json:"receipts"`
+	Errors   map[string]int64        `json:"errors"` // by component
+	Skills   map[string]SkillCount   `json:"skills"`
+	Variants map[string]VariantStats `json:"variants,omitempty"` // by experiment variant
+	Clients  map[string]ClientCount  `json:"clients,omitempty"`  // by X-Client
+}
+
+func newDayStats(date string) *DayStats {
+	return &DayStats{
+		Date:     date,
+		Requests: map[string]int64{},
+		Tokens:   map[string]TokenCount{},
+		Errors:   map[string]int64{},
+		Skills:   map[string]SkillCount{},
+		Variants: map[string]VariantStats{},
+		Clients:  map[string]ClientCount{},
+	}
+}
+
+// TotalRequests sums requests over all channels.
+func (d DayStats) TotalRequests() int64 {
+	return sumValues(d.Requests)
+}
+
+// TotalErrors sums errors over all components.
+func (d DayStats) TotalErrors() int64 {
+	return sumValues(d.Errors)
+}
+
+// TopSkills returns up to n skills ordered by run count.
+func (d DayStats) TopSkills(n int) []string {
+	names := make([]string, 0, len(d.Skills))
+	for name := range d.Skills {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := d.Skills[names[i]], d.Skills[names[j]]
+		if a.Runs != b.Runs {
+			return a.Runs > b.Runs
+		}
+		return names[i] < names[j]
+	})
+	if len(names) > n {
+		names = names[:n]
+	}
+	return names
+}
+
+func sumValues(m map[string]int64) int64 {
+	var total int64
+	for _, v := range m {
+		total += v
+	}
+	return total
+}
+
+// Tracker accumulates counters and persists them to
+// <workspace>/state/usage.json.
+type Tracker struct {
+	path string
+	now  func() time.Time
+
+	mu        sync.Mutex
+	days      map[string]*DayStats
+	dirty     bool
+	lastSaved time.Time
+}
+
+var active atomic.Pointer[Tracker]
+
+// Init loads the counters for workspace and makes the tracker the target of
+// the package-level Record functions.
+func Init(workspace string) (*Tracker, error) {
+	t, err := NewTracker(workspace)
+	if err != nil {
+		return nil, err
+	}
+	active.Store(t)
+	return t, nil
+}
+
+// NewTracker loads the counters stored in workspace.
+func NewTracker(workspace string) (*Tracker, error) {
+	t := &Tracker{
+		path: filepath.Join(workspace, "state", "usage.json"),
+		now:  time.Now,
+		days: map[string]*DayStats{},
+	}
+	data, err := os.ReadFile(t.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return t, nil
+		}
+		return nil, fmt.Errorf("failed to read usage: %w", err)
+	}
+	if err := json.Unmarshal(data, &t.days); err != nil {
+		return nil, fmt.Errorf("failed to parse usage: %w", err)
+	}
+	for date, day := range t.days {
+		fresh := newDayStats(date)
+		mergeInto(fresh, day)
+		t.days[date] = fresh
+	}
+	return t, nil
+}
+
+func mergeInto(dst, src *DayStats) {
+	for k, v := range src.Requests {
+		dst.Requests[k] += v
+	}
+	for k, v := range src.Tokens {
+		dst.Tokens[k] = v
+	}
+	dst.Receipts += src.Receipts
+	for k, v := range src.Errors {
+		dst.Errors[k] += v
+	}
+	for k, v := range src.Skills {
+		dst.Skills[k] = v
+	}
+	for k, v := range src.Variants {
+		tokens := make(map[string]TokenCount, len(v.Tokens))
+		for model, c := range v.Tokens {
+			tokens[model] = c
+		}
+		v.Tokens = tokens
+		dst.Variants[k] = v
+	}
+	for k, v := range src.Clients {
+		c := dst.Clients[k]
+		c.Requests += v.Requests
+		c.Failures += v.Failures
+		dst.Clients[k] = c
+	}
+}
+
+// Day returns a copy of the counters for the local day containing day.
+func (t *Tracker) Day(day time.Time) DayStats {
+	date := day.Format(dateFormat)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	out := newDayStats(date)
+	if stored, ok := t.days[date]; ok {
+		mergeInto(out, stored)
+	}
+	return *out
+}
+
+// update applies fn to today's counters and saves them if due.
+func (t *Tracker) update(fn func(*DayStats)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	date := now.Format(dateFormat)
+	day, ok := t.days[date]
+	if !ok {
+		day = newDayStats(date)
+		t.days[date] = day
+		t.prune(now)
+	}
+	fn(day)
+	t.dirty = true
+	if now.Sub(t.lastSaved) >= saveInterval {
+		t.saveLocked()
+	}
+}
+
+func (t *Tracker) prune(now time.Time) {
+	cutoff := now.AddDate(0, 0, -keepDays).Format(dateFormat)
+	for date := range t.days {
+		if date < cutoff {
+			delete(t.days, date)
+		}
+	}
+}
+
+// Save writes pending counters to disk.
+func (t *Tracker) Save() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.saveLocked()
+}
+
+func (t *Tracker) saveLocked() error {
+	if !t.dirty {
+		return nil
+	}
+	data, err := json.MarshalIndent(t.days, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(t.path), 0o755); err != nil {
+		return err
+	}
+	tmp := t.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, t.path); err != nil {
+		return err
+	}
+	t.dirty = false
+	t.lastSaved = t.now()
+	return nil
+}
+
+// RecordRequest counts a message received on channel.
+func RecordRequest(channel string) {
+	if t := active.Load(); t != nil {
+		t.update(func(d *DayStats) { d.Requests[channel]++ })
+	}
+}
+
+// RecordTokens counts LLM tokens used by model.
+func RecordTokens(model string, prompt, completion int) {
+	if t := active.Load(); t != nil {
+		t.update(func(d *DayStats) {
+			c := d.Tokens[model]
+			c.Prompt += int64(prompt)
+			c.Completion += int64(completion)
+			d.Tokens[model] = c
+		})
+	}
+}
+
+// RecordReceipt counts a message that came with an attached file.
+func RecordReceipt() {
+	if t := active.Load(); t != nil {
+		t.update(func(d *DayStats) { d.Receipts++ })
+	}
+}
+
+// RecordError counts an error in component.
+func RecordError(component string) {
+	if t := active.Load(); t != nil {
+		t.update(func(d *DayStats) { d.Errors[component]++ })
+	}
+}
+
+// RecordSkill counts a skill run.
+func RecordSkill(skill string, failed bool) {
+	if t := active.Load(); t != nil {
+		t.update(func(d *DayStats) {
+			c := d.Skills[skill]
+			c.Runs++
+			if failed {
+				c.Failures++
+			}
+			d.Skills[skill] = c
+		})
+	}
+}
+
+// RecordVariantRequest counts a request served by an experiment variant and
+// how long it took.
+func RecordVariantRequest(variant string, latency time.Duration, failed bool) {
+	if t := active.Load(); t != nil {
+		t.updateVariant(variant, func(v *VariantStats) {
+			v.Requests++
+			v.LatencyMS += latency.Milliseconds()
+			if failed {
+				v.Failures++
+			}
+		})
+	}
+}
+
+// RecordVariantTokens counts LLM tokens used by model for an experiment
+// variant.
+func RecordVariantTokens(variant, model string, prompt, completion int) {
+	if t := active.Load(); t != nil {
+		t.updateVariant(variant, func(v *VariantStats) {
+			c := v.Tokens[model]
+			c.Prompt += int64(prompt)
+			c.Completion += int64(completion)
+			v.Tokens[model] = c
+		})
+	}
+}
+
+// RecordCorrection counts a user correcting an answer from an experiment
+// variant.
+func RecordCorrection(variant string) {
+	if t := active.Load(); t != nil {
+		t.updateVariant(variant, func(v *VariantStats) { v.Corrections++ })
+	}
+}
+
+// RecordClientRequest counts an API request from client, the app and version
+// it named in X-Client.
+func RecordClientRequest(client string, failed bool) {
+	if t := active.Load(); t != nil {
+		t.update(func(d *DayStats) {
+			c := d.Clients[client]
+			c.Requests++
+			if failed {
+				c.Failures++
+			}
+			d.Clients[client] = c
+		})
+	}
+}
+
+func (t *Tracker) updateVariant(variant string, fn func(*VariantStats)) {
+	t.update(func(d *DayStats) {
+		v := d.Variants[variant]
+		if v.Tokens == nil {
+			v.Tokens = map[string]TokenCount{}
+		}
+		fn(&v)
+		d.Variants[variant] = v
+	})
+}
@@ -0,0 +1,143 @@
+package usage
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrackerCountsAndPersists(t *testing.T) {
+	workspace := t.TempDir()
+	tracker, err := Init(workspace)
+	require.NoError(t, err)
+	defer active.Store(nil)
+
+	RecordRequest("telegram")
+	RecordRequest("telegram")
+	RecordRequest("api")
+	RecordTokens("gpt-4o", 1000, 200)
+	RecordTokens("gpt-4o", 500, 100)
+	RecordReceipt()
+	RecordError("provider")
+	RecordSkill("oluto", false)
+	RecordSkill("oluto", true)
+	RecordSkill("weather", false)
+	RecordVariantRequest("concise", 1500*time.Millisecond, false)
+	RecordVariantTokens("concise", "gpt-4o-mini", 800, 50)
+	RecordCorrection("concise")
+	RecordClientRequest("ios/2.3.1", true)
+	RecordClientRequest("ios/2.3.1", false)
+	require.NoError(t, tracker.Save())
+
+	reloaded, err := NewTracker(workspace)
+	require.NoError(t, err)
+	day := reloaded.Day(time.Now())
+
+	assert.Equal(t, int64(3), day.TotalRequests())
+	assert.Equal(t, TokenCount{Prompt: 1500, Completion: 300}, day.Tokens["gpt-4o"])
+	assert.Equal(t, int64(1), day.Receipts)
+	assert.Equal(t, int64(1), day.TotalErrors())
+	assert.Equal(t, SkillCount{Runs: 2, Failures: 1}, day.Skills["oluto"])
+	assert.Equal(t, []string{"oluto", "weather"}, day.TopSkills(5))
+	assert.Equal(t, VariantStats{
+		Requests:    1,
+		LatencyMS:   1500,
+		Corrections: 1,
+		Tokens:      map[string]TokenCount{"gpt-4o-mini": {Prompt: 800, Completion: 50}},
+	}, day.Variants["concise"])
+	assert.Equal(t, ClientCount{Requests: 2, Failures: 1}, day.Clients["ios/2.3.1"])
+
+	empty := reloaded.Day(time.Now().AddDate(0, 0, -1))
+	assert.Zero(t, empty.TotalRequests())
+}
+
+func TestFormatDigest(t *testing.T) {
+	day := *newDayStats("2026-03-02")
+	day.Requests["telegram"] = 30
+	day.Requests["api"] = 12
+	day.Tokens["gpt-4o"] = TokenCount{Prompt: 1_000_000, Completion: 100_000}
+	day.Receipts = 5
+	day.Errors["provider"] = 2
+	day.Skills["oluto"] = SkillCount{Runs: 12, Failures: 1}
+	day.Skills["weather"] = SkillCount{Runs: 3}
+
+	out := FormatDigest(day, map[string]TokenPrice{"gpt-4o": {Prompt: 2.5, Completion: 10}})
+	assert.Contains(t, out, "2026-03-02")
+	assert.Contains(t, out, "Requests: 42 (telegram 30, api 12)")
+	assert.Contains(t, out, "Tokens: 1000000 in / 100000 out (~$3.50)")
+	assert.Contains(t, out, "Receipts processed: 5")
+	assert.Contains(t, out, "Errors: 2 (provider 2)")
+	assert.Contains(t, out, "Top skills: oluto (12, 1 failed), weather (3)")
+
+	assert.NotContains(t, FormatDigest(day, nil), "$", "no cost without prices")
+	assert.NotContains(t, out, "Experiment")
+	assert.NotContains(t, out, "Clients")
+}
+
+func TestFormatDigestClients(t *testing.T) {
+	day := *newDayStats("2026-03-02")
+	day.Clients["android/1.8.0"] = ClientCount{Requests: 20}
+	day.Clients["ios/2.3.1"] = ClientCount{Requests: 8, Failures: 6}
+
+	out := FormatDigest(day, nil)
+	assert.Contains(t, out, "\nClients: android/1.8.0 20, ios/2.3.1 8 (6 failed, 75%)")
+}
+
+func TestFormatDigestVariants(t *testing.T) {
+	day := *newDayStats("2026-03-02")
+	day.Variants["control"] = VariantStats{
+		Requests:    10,
+		LatencyMS:   25_000,
+		Corrections: 2,
+		Tokens:      map[string]TokenCount{"gpt-4o": {Prompt: 10_000, Completion: 1_000}},
+	}
+	day.Variants["mini"] = VariantStats{
+		Requests:  4,
+		Failures:  1,
+		LatencyMS: 4_000,
+		Tokens:    map[string]TokenCount{"gpt-4o-mini": {Prompt: 4_000, Completion: 400}},
+	}
+
+	out := FormatDigest(day, map[string]TokenPrice{"gpt-4o": {Prompt: 2.5, Completion: 10}})
+	assert.Contains(t, out, "\nExperiment:\n  control: 10 requests, 2.5s avg, ~$0.0035/request, 20% corrected\n"+
+		"  mini: 4 requests, 1.0s avg, 0% corrected, 1 failed")
+}
+
+func TestDigestNextRun(t *testing.T) {
+	d, err := NewDigest(&Tracker{days: map[string]*DayStats{}}, DigestOptions{
+		At:   "21:30",
+		Send: func(string) error { return nil },
+	})
+	require.NoError(t, err)
+
+	before := time.Date(2026, 3, 2, 20, 0, 0, 0, time.Local)
+	assert.Equal(t, time.Date(2026, 3, 2, 21, 30, 0, 0, time.Local), d.nextRun(before))
+	after := time.Date(2026, 3, 2, 21, 30, 0, 0, time.Local)
+	assert.Equal(t, time.Date(2026, 3, 3, 21, 30, 0, 0, time.Local), d.nextRun(after))
+
+	_, err = NewDigest(nil, DigestOptions{At: "9pm", Send: d.send})
+	assert.Error(t, err)
+}
+
+func TestDigestAppendsRelease(t *testing.T) {
+	tracker, err := NewTracker(t.TempDir())
+	require.NoError(t, err)
+	var sent string
+	release := ""
+	d, err := NewDigest(tracker, DigestOptions{
+		At:      "21:30",
+		Send:    func(content string) error { sent = content; return nil },
+		Release: func() string { return release },
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, d.SendNow())
+	assert.NotContains(t, sent, "Update")
+
+	release = "Update available: v1.4.0 (running v1.3.0)"
+	require.NoError(t, d.SendNow())
+	assert.True(t, strings.HasSuffix(sent, "\nUpdate available: v1.4.0 (running v1.3.0)"))
+}
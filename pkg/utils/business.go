@@ -0,0 +1,21 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+)
+
+// safeBusinessID matches business IDs usable as they are in session keys and
+// directory names.
+var safeBusinessID = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// BusinessDirName maps a business ID to a name that is safe as a path
+// element, hashing IDs that are not.
+func BusinessDirName(businessID string) string {
+	if safeBusinessID.MatchString(businessID) {
+		return businessID
+	}
+	sum := sha256.Sum256([]byte(businessID))
+	return "x-" + hex.EncodeToString(sum[:16])
+}
@@ -0,0 +1,13 @@
+package utils
+
+import "syscall"
+
+// DiskSpace returns the free and total bytes of the filesystem holding path.
+func DiskSpace(path string) (free, total uint64, err error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, 0, err
+	}
+	// Bavail rather than Bfree: space reserved for root is not usable by us.
+	return st.Bavail * uint64(st.Bsize), st.Blocks * uint64(st.Bsize), nil
+}
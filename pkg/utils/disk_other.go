@@ -0,0 +1,10 @@
+//go:build !linux
+
+package utils
+
+import "errors"
+
+// DiskSpace is a stub for non-Linux platforms.
+func DiskSpace(path string) (free, total uint64, err error) {
+	return 0, 0, errors.New("disk space is only supported on Linux")
+}
@@ -0,0 +1,262 @@
+package voice
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	chunkSamples = SampleRate / 50 // 20 ms
+	// listenTimeout is how long the frontend waits for speech after the
+	// wake word.
+	listenTimeout = 5 * time.Second
+	// minUtterance is the shortest recording worth transcribing.
+	minUtterance = 300 * time.Millisecond
+)
+
+// DefaultCaptureCommand records the default ALSA input in the format the
+// frontend reads.
+const DefaultCaptureCommand = "arecord -q -t raw -f S16_LE -c 1 -r 16000"
+
+// Transcriber turns a recording into text.
+type Transcriber interface {
+	Transcribe(ctx context.Context, audioFilePath string) (*TranscriptionResponse, error)
+}
+
+// FrontendOptions configures a Frontend.
+type FrontendOptions struct {
+	// CaptureCommand is a shell command writing 16-bit mono PCM at 16 kHz to
+	// stdout, such as DefaultCaptureCommand.
+	CaptureCommand string
+	WakeWord       *WakeWord // nil: push to talk only
+	// PushToTalkDevice is a Linux input device whose key PushToTalkKey (0
+	// for any) is held while speaking; empty: wake word only.
+	PushToTalkDevice string
+	PushToTalkKey    uint16
+	Transcriber      Transcriber
+	Silence          time.Duration // pause that ends an utterance after the wake word
+	MaxUtterance     time.Duration
+	OnWake           func() // the wake word or key was heard, e.g. to play a chime
+	OnText           func(text string)
+}
+
+// Frontend listens on the microphone for the wake word or the push-to-talk
+// key, records what is said next, and hands its transcription to OnText.
+type Frontend struct {
+	opts  FrontendOptions
+	muted atomic.Bool
+	key   atomic.Bool // push-to-talk key held
+}
+
+// NewFrontend returns a frontend; Run starts it.
+func NewFrontend(opts FrontendOptions) (*Frontend, error) {
+	if opts.WakeWord == nil && opts.PushToTalkDevice == "" {
+		return nil, errors.New("voice needs a wake word or a push-to-talk device")
+	}
+	if opts.Transcriber == nil {
+		return nil, errors.New("voice needs a transcriber")
+	}
+	if opts.CaptureCommand == "" {
+		opts.CaptureCommand = DefaultCaptureCommand
+	}
+	if opts.Silence <= 0 {
+		opts.Silence = 800 * time.Millisecond
+	}
+	if opts.MaxUtterance <= 0 {
+		opts.MaxUtterance = 15 * time.Second
+	}
+	return &Frontend{opts: opts}, nil
+}
+
+// Mute stops the frontend listening, for example while the device speaks,
+// so that it doesn't hear itself.
+func (f *Frontend) Mute(muted bool) {
+	f.muted.Store(muted)
+}
+
+// Run captures audio until ctx is done or the capture command exits.
+func (f *Frontend) Run(ctx context.Context) error {
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if f.opts.PushToTalkDevice != "" {
+		go func() {
+			err := watchKey(runCtx, f.opts.PushToTalkDevice, f.opts.PushToTalkKey, f.key.Store)
+			if err != nil && runCtx.Err() == nil {
+				logger.ErrorCF("voice", "Push-to-talk device failed", map[string]any{"error": err.Error()})
+			}
+		}()
+	}
+
+	cmd := exec.CommandContext(runCtx, "sh", "-c", f.opts.CaptureCommand)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start audio capture: %w", err)
+	}
+	go closeOnDone(runCtx, stdout)
+	err = f.listen(runCtx, bufio.NewReader(stdout))
+	cancel()
+	cmd.Wait()
+	if ctx.Err() != nil {
+		return nil
+	}
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return fmt.Errorf("audio capture stopped: %w: %s", err, msg)
+	}
+	return fmt.Errorf("audio capture stopped: %w", err)
+}
+
+// listen runs the state machine over the captured audio: waiting for the
+// trigger, then recording until the key is released or the speaker pauses.
+func (f *Frontend) listen(ctx context.Context, audio io.Reader) error {
+	chunk := make([]int16, chunkSamples)
+	var (
+		recording []int16
+		active    bool // recording an utterance
+		byKey     bool // started with the push-to-talk key
+		heard     bool // speech since the trigger
+		quiet     time.Duration
+		elapsed   time.Duration
+	)
+	const step = time.Second * chunkSamples / SampleRate
+
+	for {
+		if err := binary.Read(audio, binary.LittleEndian, chunk); err != nil {
+			return err
+		}
+		if f.muted.Load() {
+			active = false
+			if f.opts.WakeWord != nil {
+				f.opts.WakeWord.Reset()
+			}
+			continue
+		}
+
+		if !active {
+			switch {
+			case f.key.Load():
+				byKey = true
+			case f.opts.WakeWord != nil && f.opts.WakeWord.Feed(chunk):
+				byKey = false
+			default:
+				continue
+			}
+			active, heard, recording, quiet, elapsed = true, false, nil, 0, 0
+			if f.opts.OnWake != nil {
+				f.opts.OnWake()
+			}
+			if !byKey {
+				continue // the wake word itself isn't part of the request
+			}
+		}
+
+		recording = append(recording, chunk...)
+		elapsed += step
+		if rms(chunk) >= silenceRMS {
+			heard, quiet = true, 0
+		} else {
+			quiet += step
+		}
+
+		var done bool
+		switch {
+		case byKey:
+			done = !f.key.Load()
+		case !heard:
+			done = elapsed >= listenTimeout
+		default:
+			done = quiet >= f.opts.Silence
+		}
+		if done || elapsed >= f.opts.MaxUtterance {
+			active = false
+			if heard && elapsed >= minUtterance {
+				go f.transcribe(ctx, recording)
+			}
+		}
+	}
+}
+
+// transcribe sends a recording to the transcriber and passes on the text.
+func (f *Frontend) transcribe(ctx context.Context, samples []int16) {
+	tmp, err := os.CreateTemp("", "picoclaw-voice-*.wav")
+	if err != nil {
+		logger.ErrorCF("voice", "Failed to save recording", map[string]any{"error": err.Error()})
+		return
+	}
+	defer os.Remove(tmp.Name())
+	buf := bufio.NewWriter(tmp)
+	err = WriteWAV(buf, samples)
+	if err == nil {
+		err = buf.Flush()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		logger.ErrorCF("voice", "Failed to save recording", map[string]any{"error": err.Error()})
+		return
+	}
+
+	result, err := f.opts.Transcriber.Transcribe(ctx, tmp.Name())
+	if err != nil {
+		logger.ErrorCF("voice", "Failed to transcribe recording", map[string]any{"error": err.Error()})
+		return
+	}
+	if text := strings.TrimSpace(result.Text); text != "" {
+		f.opts.OnText(text)
+	}
+}
+
+// Record captures d of audio with command, as DefaultCaptureCommand does.
+func Record(ctx context.Context, command string, d time.Duration) ([]int16, error) {
+	if command == "" {
+		command = DefaultCaptureCommand
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start audio capture: %w", err)
+	}
+	go closeOnDone(ctx, stdout)
+
+	samples := make([]int16, int(d.Seconds()*SampleRate))
+	err = binary.Read(bufio.NewReader(stdout), binary.LittleEndian, samples)
+	cancel()
+	cmd.Wait()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("audio capture failed: %w: %s", err, msg)
+		}
+		return nil, fmt.Errorf("audio capture failed: %w", err)
+	}
+	return samples, nil
+}
+
+// closeOnDone closes the capture pipe when ctx ends, which unblocks a read
+// from it even if the shell left the recorder running.
+func closeOnDone(ctx context.Context, pipe io.Closer) {
+	<-ctx.Done()
+	pipe.Close()
+}
@@ -0,0 +1,116 @@
+package voice
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeTranscriber struct {
+	mu      sync.Mutex
+	lengths []int // samples in each recording
+}
+
+func (t *fakeTranscriber) Transcribe(ctx context.Context, path string) (*TranscriptionResponse, error) {
+	samples, err := ReadWAV(path)
+	if err != nil {
+		return nil, err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lengths = append(t.lengths, len(samples))
+	return &TranscriptionResponse{Text: " what's my balance "}, nil
+}
+
+func pcm(parts ...[]int16) *bytes.Reader {
+	var buf bytes.Buffer
+	for _, p := range parts {
+		binary.Write(&buf, binary.LittleEndian, p)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+func TestFrontendWakeWord(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	var recordings [][]int16
+	for _, tempo := range []float64{1, 0.9, 1.1} {
+		recordings = append(recordings, word(rng, tempo, 500, 1500, 900))
+	}
+	wakeWord, err := NewWakeWord(recordings, 0)
+	require.NoError(t, err)
+
+	transcriber := &fakeTranscriber{}
+	texts := make(chan string, 1)
+	f, err := NewFrontend(FrontendOptions{
+		WakeWord:    wakeWord,
+		Transcriber: transcriber,
+		OnText:      func(text string) { texts <- text },
+	})
+	require.NoError(t, err)
+
+	request := word(rng, 4, 700, 1200) // two seconds of "speech"
+	audio := pcm(silence(1), word(rng, 1, 500, 1500, 900), silence(0.2), request, silence(2))
+	f.listen(context.Background(), audio)
+
+	select {
+	case text := <-texts:
+		assert.Equal(t, "what's my balance", text)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no transcription")
+	}
+	transcriber.mu.Lock()
+	defer transcriber.mu.Unlock()
+	require.Len(t, transcriber.lengths, 1)
+	// The request and the pause that ended it, without the wake word.
+	assert.InDelta(t, len(request)+int(1.0*SampleRate), transcriber.lengths[0], 0.1*SampleRate)
+}
+
+func TestFrontendIgnoresSilenceAndMute(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	wakeWord, err := NewWakeWord([][]int16{word(rng, 1, 500, 1500, 900)}, 5)
+	require.NoError(t, err)
+
+	transcriber := &fakeTranscriber{}
+	f, err := NewFrontend(FrontendOptions{
+		WakeWord:    wakeWord,
+		Transcriber: transcriber,
+		OnText:      func(string) {},
+	})
+	require.NoError(t, err)
+
+	// Nothing said after the wake word.
+	f.listen(context.Background(), pcm(silence(0.5), word(rng, 1, 500, 1500, 900), silence(6)))
+	// The wake word while muted, as when the device says it itself.
+	f.Mute(true)
+	f.listen(context.Background(), pcm(silence(0.5), word(rng, 1, 500, 1500, 900), word(rng, 4, 700, 1200)))
+	time.Sleep(100 * time.Millisecond)
+
+	transcriber.mu.Lock()
+	defer transcriber.mu.Unlock()
+	assert.Empty(t, transcriber.lengths)
+}
+
+func TestNewFrontendNeedsTrigger(t *testing.T) {
+	_, err := NewFrontend(FrontendOptions{Transcriber: &fakeTranscriber{}})
+	assert.Error(t, err)
+
+	f, err := NewFrontend(FrontendOptions{PushToTalkDevice: "/dev/input/event0", Transcriber: &fakeTranscriber{}})
+	require.NoError(t, err)
+	assert.Equal(t, DefaultCaptureCommand, f.opts.CaptureCommand)
+}
+
+func TestRecord(t *testing.T) {
+	samples, err := Record(context.Background(), "head -c 32000 /dev/zero", 500*time.Millisecond)
+	require.NoError(t, err)
+	assert.Len(t, samples, SampleRate/2)
+
+	_, err = Record(context.Background(), "echo no microphone >&2; exit 1", time.Second)
+	assert.ErrorContains(t, err, "no microphone")
+}
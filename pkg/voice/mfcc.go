@@ -0,0 +1,180 @@
+package voice
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// SampleRate is the rate of the audio the frontend works on: 16-bit mono
+// PCM at 16 kHz, as speech recognisers expect.
+const SampleRate = 16000
+
+const (
+	frameLen   = 400 // 25 ms
+	frameHop   = 160 // 10 ms
+	fftSize    = 512
+	numFilters = 26
+	numCoeffs  = 13
+)
+
+// mfcc computes mel-frequency cepstral coefficients, the usual features for
+// comparing speech, one frame at a time.
+type mfcc struct {
+	window  []float64
+	filters [][]float64 // mel filterbank over the fftSize/2+1 power bins
+	dct     [][]float64
+}
+
+func newMFCC() *mfcc {
+	m := &mfcc{window: make([]float64, frameLen)}
+	for i := range m.window {
+		m.window[i] = 0.54 - 0.46*math.Cos(2*math.Pi*float64(i)/float64(frameLen-1))
+	}
+
+	mel := func(hz float64) float64 { return 2595 * math.Log10(1+hz/700) }
+	hz := func(mel float64) float64 { return 700 * (math.Pow(10, mel/2595) - 1) }
+	lo, hi := mel(20), mel(SampleRate/2)
+	bins := make([]int, numFilters+2)
+	for i := range bins {
+		f := hz(lo + (hi-lo)*float64(i)/float64(numFilters+1))
+		bins[i] = int(math.Floor((fftSize + 1) * f / SampleRate))
+	}
+	for j := 0; j < numFilters; j++ {
+		filter := make([]float64, fftSize/2+1)
+		for k := bins[j]; k < bins[j+1]; k++ {
+			filter[k] = float64(k-bins[j]) / float64(bins[j+1]-bins[j])
+		}
+		for k := bins[j+1]; k < bins[j+2]; k++ {
+			filter[k] = float64(bins[j+2]-k) / float64(bins[j+2]-bins[j+1])
+		}
+		m.filters = append(m.filters, filter)
+	}
+
+	for i := 0; i < numCoeffs; i++ {
+		row := make([]float64, numFilters)
+		for j := range row {
+			row[j] = math.Cos(math.Pi * float64(i) * (float64(j) + 0.5) / numFilters)
+		}
+		m.dct = append(m.dct, row)
+	}
+	return m
+}
+
+// frame returns the coefficients of frameLen samples.
+func (m *mfcc) frame(samples []int16) []float64 {
+	buf := make([]complex128, fftSize)
+	prev := 0.0
+	for i := 0; i < frameLen; i++ {
+		s := float64(samples[i]) / 32768
+		buf[i] = complex((s-0.97*prev)*m.window[i], 0) // pre-emphasis boosts the consonants
+		prev = s
+	}
+	fft(buf)
+
+	power := make([]float64, fftSize/2+1)
+	for k := range power {
+		a := cmplx.Abs(buf[k])
+		power[k] = a * a / fftSize
+	}
+	energies := make([]float64, numFilters)
+	for j, filter := range m.filters {
+		var e float64
+		for k, w := range filter {
+			e += w * power[k]
+		}
+		energies[j] = math.Log(math.Max(e, 1e-10))
+	}
+	coeffs := make([]float64, numCoeffs)
+	for i, row := range m.dct {
+		for j, w := range row {
+			coeffs[i] += w * energies[j]
+		}
+	}
+	return coeffs
+}
+
+// features returns the coefficients of samples, one frame every 10 ms.
+func (m *mfcc) features(samples []int16) [][]float64 {
+	var out [][]float64
+	for start := 0; start+frameLen <= len(samples); start += frameHop {
+		out = append(out, m.frame(samples[start:start+frameLen]))
+	}
+	return out
+}
+
+// fft transforms x in place; len(x) must be a power of two.
+func fft(x []complex128) {
+	n := len(x)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				a, b := x[start+k], w*x[start+k+size/2]
+				x[start+k], x[start+k+size/2] = a+b, a-b
+				w *= step
+			}
+		}
+	}
+}
+
+// normalize returns frames with their mean removed, which cancels out the
+// microphone's and the room's colouring of the sound.
+func normalize(frames [][]float64) [][]float64 {
+	mean := make([]float64, numCoeffs)
+	for _, f := range frames {
+		for i, c := range f {
+			mean[i] += c / float64(len(frames))
+		}
+	}
+	out := make([][]float64, len(frames))
+	for n, f := range frames {
+		out[n] = make([]float64, numCoeffs)
+		for i, c := range f {
+			out[n][i] = c - mean[i]
+		}
+	}
+	return out
+}
+
+// dtw returns the dynamic time warping distance between two feature
+// sequences, per step of the warping path, so that the same word said faster
+// or slower still matches.
+func dtw(a, b [][]float64) float64 {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 || n > 2*m || m > 2*n {
+		return math.Inf(1)
+	}
+	prev := make([]float64, m+1)
+	cur := make([]float64, m+1)
+	for j := 1; j <= m; j++ {
+		prev[j] = math.Inf(1)
+	}
+	for i := 1; i <= n; i++ {
+		cur[0] = math.Inf(1)
+		for j := 1; j <= m; j++ {
+			cur[j] = distance(a[i-1], b[j-1]) + min(prev[j], cur[j-1], prev[j-1])
+		}
+		prev, cur = cur, prev
+	}
+	return prev[m] / float64(n+m)
+}
+
+func distance(a, b []float64) float64 {
+	var sum float64
+	for i := range a {
+		d := a[i] - b[i]
+		sum += d * d
+	}
+	return math.Sqrt(sum)
+}
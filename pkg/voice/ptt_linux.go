@@ -0,0 +1,53 @@
+//go:build linux
+
+package voice
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"os"
+	"syscall"
+)
+
+const evKey = 0x01 // EV_KEY
+
+// inputEvent is struct input_event from <linux/input.h>.
+type inputEvent struct {
+	Time  syscall.Timeval
+	Type  uint16
+	Code  uint16
+	Value int32
+}
+
+// watchKey reports presses (true) and releases (false) of key, or of any key
+// when key is 0, on a Linux input device such as /dev/input/event0.
+func watchKey(ctx context.Context, device string, key uint16, held func(bool)) error {
+	f, err := os.Open(device)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		f.Close() // unblocks the read below
+	}()
+	return readKeyEvents(f, key, held)
+}
+
+func readKeyEvents(r io.Reader, key uint16, held func(bool)) error {
+	for {
+		var ev inputEvent
+		if err := binary.Read(r, binary.LittleEndian, &ev); err != nil {
+			return err
+		}
+		if ev.Type != evKey || (key != 0 && ev.Code != key) {
+			continue
+		}
+		switch ev.Value {
+		case 1:
+			held(true)
+		case 0:
+			held(false)
+		} // 2 is autorepeat
+	}
+}
@@ -0,0 +1,29 @@
+//go:build linux
+
+package voice
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadKeyEvents(t *testing.T) {
+	const keySpace = 57
+	var buf bytes.Buffer
+	for _, ev := range []inputEvent{
+		{Type: evKey, Code: keySpace, Value: 1},
+		{Type: evKey, Code: keySpace, Value: 2}, // autorepeat
+		{Type: 0, Code: 0, Value: 0},            // EV_SYN
+		{Type: evKey, Code: 30, Value: 1},       // another key
+		{Type: evKey, Code: keySpace, Value: 0},
+	} {
+		binary.Write(&buf, binary.LittleEndian, ev)
+	}
+
+	var held []bool
+	readKeyEvents(bytes.NewReader(buf.Bytes()), keySpace, func(h bool) { held = append(held, h) })
+	assert.Equal(t, []bool{true, false}, held)
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package voice
+
+import (
+	"context"
+	"errors"
+)
+
+func watchKey(ctx context.Context, device string, key uint16, held func(bool)) error {
+	return errors.New("push to talk is only supported on Linux")
+}
@@ -0,0 +1,56 @@
+package voice
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// DefaultSpeakCommand reads text on stdin aloud.
+const DefaultSpeakCommand = "espeak-ng --stdin"
+
+var (
+	reMarkdownLink = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	reURL          = regexp.MustCompile(`https?://\S+`)
+	reMarkup       = regexp.MustCompile("[*_`#>|~]+")
+	reSymbols      = regexp.MustCompile(`[\p{So}\p{Sk}\x{FE0F}\x{200D}]+`)
+	reSpaces       = regexp.MustCompile(`[ \t]+`)
+)
+
+// Speak reads text aloud with command, a shell command that takes the text
+// on stdin, and returns when it has finished.
+func Speak(ctx context.Context, command, text string) error {
+	text = Speakable(text)
+	if text == "" {
+		return nil
+	}
+	if command == "" {
+		command = DefaultSpeakCommand
+	}
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdin = strings.NewReader(text)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("speech failed: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// Speakable strips what a reply shows but shouldn't be read out: Markdown
+// markup, link targets and emoji.
+func Speakable(text string) string {
+	text = reMarkdownLink.ReplaceAllString(text, "$1")
+	text = reURL.ReplaceAllString(text, "")
+	text = reMarkup.ReplaceAllString(text, "")
+	text = reSymbols.ReplaceAllString(text, "")
+	lines := strings.Split(text, "\n")
+	out := lines[:0]
+	for _, line := range lines {
+		line = strings.TrimSpace(reSpaces.ReplaceAllString(line, " "))
+		if line != "" {
+			out = append(out, line)
+		}
+	}
+	return strings.Join(out, "\n")
+}
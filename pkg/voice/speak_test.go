@@ -0,0 +1,27 @@
+package voice
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpeakable(t *testing.T) {
+	in := "## Balance ✅\n\nYou have **$1,200** in [checking](https://bank.example/acct) 🎉\n\n" +
+		"See https://example.com for more."
+	assert.Equal(t, "Balance\nYou have $1,200 in checking\nSee for more.", Speakable(in))
+}
+
+func TestSpeak(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "spoken.txt")
+	require.NoError(t, Speak(context.Background(), "cat > "+out, "**Done** 👍"))
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, "Done", string(data))
+
+	assert.Error(t, Speak(context.Background(), "exit 1", "hello"))
+}
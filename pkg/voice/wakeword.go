@@ -0,0 +1,204 @@
+package voice
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// checkEvery is how many frames pass between comparisons, 50 ms.
+	checkEvery = 5
+	// silenceRMS is the level below which a window is not compared at all.
+	silenceRMS = 300
+	// autoThresholdMargin scales the largest distance between recordings of
+	// the wake word into the detection threshold.
+	autoThresholdMargin = 1.25
+)
+
+// WakeWord spots a wake word by comparing the last second or so of audio
+// with the owner's recordings of it. It is a template matcher on MFCC
+// features, which needs no trained model and runs comfortably on a Raspberry
+// Pi Zero, and it works best for the voice it was recorded with.
+type WakeWord struct {
+	templates [][][]float64 // normalised features of each recording
+	threshold float64
+	mfcc      *mfcc
+	maxLen    int
+
+	pending []int16     // samples not yet in a frame
+	frames  [][]float64 // features of the most recent frames
+	levels  []float64   // RMS of the same frames
+	since   int         // frames since the last comparison
+}
+
+// WakeWordDir returns where the wake word recordings of a workspace are kept.
+func WakeWordDir(workspace string) string {
+	return filepath.Join(workspace, "voice", "wakeword")
+}
+
+// LoadWakeWord loads the recordings of the wake word in dir, the *.wav files
+// picoclaw voice enroll makes. A threshold of 0 is derived from how much the
+// recordings differ from one another.
+func LoadWakeWord(dir string, threshold float64) (*WakeWord, error) {
+	paths, _ := filepath.Glob(filepath.Join(dir, "*.wav"))
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no wake word recordings in %s (run picoclaw voice enroll)", dir)
+	}
+	var recordings [][]int16
+	for _, path := range paths {
+		samples, err := ReadWAV(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+		}
+		recordings = append(recordings, samples)
+	}
+	return NewWakeWord(recordings, threshold)
+}
+
+// NewWakeWord returns a detector of the word spoken in recordings.
+func NewWakeWord(recordings [][]int16, threshold float64) (*WakeWord, error) {
+	w := &WakeWord{mfcc: newMFCC(), threshold: threshold}
+	for _, samples := range recordings {
+		frames := w.mfcc.features(TrimSilence(samples))
+		if len(frames) < 20 {
+			return nil, errors.New("a wake word recording is too short or silent")
+		}
+		w.templates = append(w.templates, normalize(frames))
+		w.maxLen = max(w.maxLen, len(frames))
+	}
+	if w.threshold <= 0 {
+		if len(w.templates) < 2 {
+			return nil, errors.New("at least two wake word recordings are needed to set the threshold")
+		}
+		w.threshold = autoThresholdMargin * w.Spread()
+	}
+	return w, nil
+}
+
+// Spread returns the largest distance between two recordings, how
+// differently the owner says the wake word.
+func (w *WakeWord) Spread() float64 {
+	var spread float64
+	for i := range w.templates {
+		for j := i + 1; j < len(w.templates); j++ {
+			spread = math.Max(spread, dtw(w.templates[i], w.templates[j]))
+		}
+	}
+	return spread
+}
+
+// Threshold returns the distance under which audio matches a recording.
+func (w *WakeWord) Threshold() float64 {
+	return w.threshold
+}
+
+// Feed adds audio and reports whether it ended with the wake word.
+func (w *WakeWord) Feed(samples []int16) bool {
+	w.pending = append(w.pending, samples...)
+	detected := false
+	for len(w.pending) >= frameLen {
+		frame := w.pending[:frameLen]
+		w.frames = append(w.frames, w.mfcc.frame(frame))
+		w.levels = append(w.levels, rms(frame))
+		if len(w.frames) > w.maxLen {
+			w.frames = w.frames[1:]
+			w.levels = w.levels[1:]
+		}
+		w.pending = w.pending[frameHop:]
+
+		w.since++
+		if w.since >= checkEvery && !detected {
+			w.since = 0
+			detected = w.matches()
+		}
+	}
+	if detected {
+		w.Reset()
+	}
+	return detected
+}
+
+// matches compares the end of the recent frames with each recording.
+func (w *WakeWord) matches() bool {
+	for _, template := range w.templates {
+		n := len(template)
+		if n > len(w.frames) {
+			continue
+		}
+		var level float64
+		for _, l := range w.levels[len(w.levels)-n:] {
+			level = math.Max(level, l)
+		}
+		if level < silenceRMS {
+			continue
+		}
+		if dtw(normalize(w.frames[len(w.frames)-n:]), template) < w.threshold {
+			return true
+		}
+	}
+	return false
+}
+
+// Reset forgets the audio heard so far.
+func (w *WakeWord) Reset() {
+	w.pending, w.frames, w.levels, w.since = nil, nil, nil, 0
+}
+
+// TrimSilence returns samples without the quiet before and after the speech
+// in them.
+func TrimSilence(samples []int16) []int16 {
+	var peak float64
+	for start := 0; start+frameLen <= len(samples); start += frameHop {
+		peak = math.Max(peak, rms(samples[start:start+frameLen]))
+	}
+	floor := math.Max(peak/10, silenceRMS)
+	first, last := -1, -1
+	for start := 0; start+frameLen <= len(samples); start += frameHop {
+		if rms(samples[start:start+frameLen]) >= floor {
+			if first < 0 {
+				first = start
+			}
+			last = start + frameLen
+		}
+	}
+	if first < 0 {
+		return nil
+	}
+	return samples[first:last]
+}
+
+func rms(samples []int16) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, s := range samples {
+		sum += float64(s) * float64(s)
+	}
+	return math.Sqrt(sum / float64(len(samples)))
+}
+
+// SaveRecording writes a wake word recording to dir as the next sample file.
+func SaveRecording(dir string, samples []int16) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	for i := 1; ; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("sample-%d.wav", i))
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if err := WriteWAV(f, samples); err != nil {
+			f.Close()
+			return "", err
+		}
+		return path, f.Close()
+	}
+}
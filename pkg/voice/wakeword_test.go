@@ -0,0 +1,112 @@
+package voice
+
+import (
+	"math"
+	"math/rand"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// word synthesises a "word" as a sequence of tones, stretched in time by
+// tempo, with a little noise from rng.
+func word(rng *rand.Rand, tempo float64, freqs ...float64) []int16 {
+	var out []int16
+	for _, f := range freqs {
+		n := int(0.25 * tempo * SampleRate)
+		for i := 0; i < n; i++ {
+			t := float64(i) / SampleRate
+			s := 8000*math.Sin(2*math.Pi*f*t) + 3000*math.Sin(2*math.Pi*2.5*f*t) + rng.NormFloat64()*200
+			out = append(out, int16(s))
+		}
+	}
+	return out
+}
+
+func silence(d float64) []int16 {
+	return make([]int16, int(d*SampleRate))
+}
+
+func feed(w *WakeWord, samples []int16) bool {
+	detected := false
+	for start := 0; start < len(samples); start += chunkSamples {
+		end := min(start+chunkSamples, len(samples))
+		if w.Feed(samples[start:end]) {
+			detected = true
+		}
+	}
+	return detected
+}
+
+func TestWakeWord(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	var recordings [][]int16
+	for _, tempo := range []float64{1, 0.9, 1.1} {
+		recordings = append(recordings, append(append(silence(0.3), word(rng, tempo, 500, 1500, 900)...), silence(0.3)...))
+	}
+	w, err := NewWakeWord(recordings, 0)
+	require.NoError(t, err)
+	assert.InDelta(t, autoThresholdMargin*w.Spread(), w.Threshold(), 1e-9)
+
+	t.Run("same word", func(t *testing.T) {
+		w.Reset()
+		audio := append(append(silence(1), word(rng, 1.05, 500, 1500, 900)...), silence(0.5)...)
+		assert.True(t, feed(w, audio))
+	})
+
+	t.Run("other word", func(t *testing.T) {
+		w.Reset()
+		audio := append(append(silence(1), word(rng, 1, 2500, 300, 3500)...), silence(0.5)...)
+		assert.False(t, feed(w, audio))
+	})
+
+	t.Run("silence", func(t *testing.T) {
+		w.Reset()
+		assert.False(t, feed(w, silence(3)))
+	})
+}
+
+func TestNewWakeWordErrors(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	_, err := NewWakeWord([][]int16{silence(1)}, 5)
+	assert.Error(t, err, "silent recording")
+
+	_, err = NewWakeWord([][]int16{word(rng, 1, 500, 1500, 900)}, 0)
+	assert.Error(t, err, "one recording can't set a threshold")
+
+	_, err = NewWakeWord([][]int16{word(rng, 1, 500, 1500, 900)}, 5)
+	assert.NoError(t, err)
+}
+
+func TestTrimSilence(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	speech := word(rng, 1, 500, 1500)
+	trimmed := TrimSilence(append(append(silence(0.5), speech...), silence(0.5)...))
+	assert.InDelta(t, len(speech), len(trimmed), 2*frameLen)
+	assert.Nil(t, TrimSilence(silence(1)))
+}
+
+func TestRecordingsRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	dir := filepath.Join(t.TempDir(), "wakeword")
+	for _, tempo := range []float64{1, 0.95} {
+		_, err := SaveRecording(dir, word(rng, tempo, 500, 1500, 900))
+		require.NoError(t, err)
+	}
+	path, err := SaveRecording(dir, silence(0.1))
+	require.NoError(t, err)
+	assert.Equal(t, "sample-3.wav", filepath.Base(path))
+
+	samples, err := ReadWAV(path)
+	require.NoError(t, err)
+	assert.Equal(t, silence(0.1), samples)
+
+	_, err = LoadWakeWord(dir, 0)
+	assert.Error(t, err, "the silent recording is rejected")
+
+	_, err = LoadWakeWord(t.TempDir(), 0)
+	assert.Error(t, err)
+}
@@ -0,0 +1,86 @@
+package voice
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// WriteWAV writes 16-bit mono samples at SampleRate as a WAV file.
+func WriteWAV(w io.Writer, samples []int16) error {
+	size := uint32(2 * len(samples))
+	header := []any{
+		[4]byte{'R', 'I', 'F', 'F'}, 36 + size, [4]byte{'W', 'A', 'V', 'E'},
+		[4]byte{'f', 'm', 't', ' '}, uint32(16),
+		uint16(1), uint16(1), uint32(SampleRate), uint32(2 * SampleRate), uint16(2), uint16(16), // PCM, mono
+		[4]byte{'d', 'a', 't', 'a'}, size,
+	}
+	for _, v := range header {
+		if err := binary.Write(w, binary.LittleEndian, v); err != nil {
+			return err
+		}
+	}
+	return binary.Write(w, binary.LittleEndian, samples)
+}
+
+// ReadWAV reads a 16-bit mono WAV file at SampleRate, as WriteWAV writes.
+func ReadWAV(path string) ([]int16, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var riff struct {
+		ID   [4]byte
+		Size uint32
+		Wave [4]byte
+	}
+	if err := binary.Read(f, binary.LittleEndian, &riff); err != nil || riff.ID != [4]byte{'R', 'I', 'F', 'F'} ||
+		riff.Wave != [4]byte{'W', 'A', 'V', 'E'} {
+		return nil, errors.New("not a WAV file")
+	}
+	hasFormat := false
+	for {
+		var chunk struct {
+			ID   [4]byte
+			Size uint32
+		}
+		if err := binary.Read(f, binary.LittleEndian, &chunk); err != nil {
+			return nil, errors.New("WAV file has no data")
+		}
+		switch chunk.ID {
+		case [4]byte{'f', 'm', 't', ' '}:
+			var format struct {
+				Format, Channels uint16
+				Rate, ByteRate   uint32
+				Align, Bits      uint16
+			}
+			if err := binary.Read(f, binary.LittleEndian, &format); err != nil {
+				return nil, err
+			}
+			if format.Format != 1 || format.Channels != 1 || format.Rate != SampleRate || format.Bits != 16 {
+				return nil, fmt.Errorf("WAV file must be 16-bit mono PCM at %d Hz", SampleRate)
+			}
+			if _, err := f.Seek(int64(chunk.Size)-16, io.SeekCurrent); err != nil {
+				return nil, err
+			}
+			hasFormat = true
+		case [4]byte{'d', 'a', 't', 'a'}:
+			if !hasFormat {
+				return nil, errors.New("WAV file has no format")
+			}
+			samples := make([]int16, chunk.Size/2)
+			if err := binary.Read(f, binary.LittleEndian, samples); err != nil {
+				return nil, err
+			}
+			return samples, nil
+		default:
+			if _, err := f.Seek(int64(chunk.Size+chunk.Size%2), io.SeekCurrent); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package wirelog
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// redactLookahead is how far past MaxBodyBytes a body is read, so a secret
+// that straddles the limit is still whole when Record redacts it and only
+// then cuts the body.
+const redactLookahead = 4096
+
+// responseCapture keeps the status and the first bytes of a response body.
+type responseCapture struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+	limit  int
+}
+
+func (c *responseCapture) WriteHeader(status int) {
+	c.status = status
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *responseCapture) Write(p []byte) (int, error) {
+	if room := c.limit - c.body.Len(); room > 0 {
+		c.body.Write(p[:min(len(p), room)])
+	}
+	return c.ResponseWriter.Write(p)
+}
+
+// Handler wraps next so each request and its response are recorded under
+// source. Multipart uploads are logged by size only.
+func (l *Log) Handler(source string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// The caller's request ID, which the handler reuses, pairs the
+		// entries with the request's events.
+		id := r.Header.Get(constants.RequestIDHeader)
+		if id == "" || len(id) > 128 {
+			id = NewID()
+			r.Header.Set(constants.RequestIDHeader, id)
+		}
+		start := time.Now()
+
+		var body string
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+			body = fmt.Sprintf("[multipart body, %d bytes]", r.ContentLength)
+		} else if r.Body != nil {
+			// Read past the limit so Record can redact before it cuts, then
+			// hand the handler the full, unconsumed body.
+			head, _ := io.ReadAll(io.LimitReader(r.Body, int64(l.opts.MaxBodyBytes+redactLookahead)))
+			body = string(head)
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(head), r.Body), r.Body}
+		}
+
+		l.record(Entry{
+			ID:        id,
+			Direction: DirectionRequest,
+			Source:    source,
+			Method:    r.Method,
+			Path:      r.URL.Path,
+			Headers:   l.redactor.Headers(r.Header),
+			Body:      body,
+		})
+
+		capture := &responseCapture{
+			ResponseWriter: w,
+			status:         http.StatusOK,
+			limit:          l.opts.MaxBodyBytes + redactLookahead,
+		}
+		next(capture, r)
+
+		l.record(Entry{
+			ID:         id,
+			Direction:  DirectionResponse,
+			Source:     source,
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			Status:     capture.status,
+			DurationMS: time.Since(start).Milliseconds(),
+			Body:       capture.body.String(),
+		})
+	}
+}
+
+func (l *Log) record(entry Entry) {
+	if err := l.Record(entry); err != nil {
+		logger.WarnCF("wirelog", "Failed to record wire log entry", map[string]any{"error": err.Error()})
+	}
+}
@@ -0,0 +1,88 @@
+package wirelog
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Redacted replaces header values and text matched by a redaction rule.
+const Redacted = "[REDACTED]"
+
+// DefaultRedactHeaders are always removed from logged requests.
+var DefaultRedactHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "Proxy-Authorization", "X-Api-Key"}
+
+// DefaultRedactPatterns mask credentials, contact details and money amounts in
+// logged bodies.
+var DefaultRedactPatterns = []string{
+	// Bearer tokens, JWTs and gateway pairing tokens
+	`(?i)bearer\s+[A-Za-z0-9._~+/=-]+`,
+	`\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`,
+	`\bpc_[A-Za-z0-9]{16,}`,
+	// Email addresses
+	`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`,
+	// Card and account numbers (13-19 digits, optionally grouped)
+	`\b\d(?:[ -]?\d){12,18}\b`,
+	// International phone numbers
+	`\+\d[\d\s().-]{7,}\d`,
+	// Money: currency symbol or code next to a number, or a decimal amount
+	`[$€£¥₦]\s?\d[\d,]*(?:\.\d+)?`,
+	`(?i)\b\d[\d,]*(?:\.\d+)?\s?(?:USD|CAD|EUR|GBP|NGN|KES|ZAR|GHS)\b`,
+	`(?i)\b(?:USD|CAD|EUR|GBP|NGN|KES|ZAR|GHS)\s?\d[\d,]*(?:\.\d+)?`,
+	`\b\d{1,3}(?:,\d{3})*\.\d{2}\b`,
+}
+
+// Redactor strips sensitive headers and masks sensitive text.
+type Redactor struct {
+	headers  map[string]bool
+	patterns []*regexp.Regexp
+}
+
+// NewRedactor builds a redactor from the defaults plus extra header names and
+// regular expressions.
+func NewRedactor(extraHeaders, extraPatterns []string) (*Redactor, error) {
+	r := &Redactor{headers: make(map[string]bool)}
+	for _, h := range append(append([]string{}, DefaultRedactHeaders...), extraHeaders...) {
+		r.headers[http.CanonicalHeaderKey(h)] = true
+	}
+	for _, p := range append(append([]string{}, DefaultRedactPatterns...), extraPatterns...) {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", p, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// Headers flattens h, replacing redacted header values.
+func (r *Redactor) Headers(h http.Header) map[string]string {
+	if len(h) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	out := make(map[string]string, len(h))
+	for _, k := range keys {
+		if r.headers[http.CanonicalHeaderKey(k)] {
+			out[k] = Redacted
+			continue
+		}
+		out[k] = r.Text(strings.Join(h[k], ", "))
+	}
+	return out
+}
+
+// Text masks every match of the redaction patterns in s.
+func (r *Redactor) Text(s string) string {
+	for _, re := range r.patterns {
+		s = re.ReplaceAllString(s, Redacted)
+	}
+	return s
+}
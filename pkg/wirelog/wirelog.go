@@ -0,0 +1,190 @@
+// Package wirelog records webhook requests, chat messages and agent responses
+// to a size-capped ring of JSONL files, with sensitive data redacted, so past
+// conversations can be inspected after the fact.
+package wirelog
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sipeed/picoclaw/pkg/logrotate"
+)
+
+// Directions recorded in Entry.Direction.
+const (
+	DirectionRequest  = "request"  // HTTP request received by the gateway
+	DirectionResponse = "response" // HTTP response returned by the gateway
+	DirectionInbound  = "inbound"  // chat message received from a channel
+	DirectionOutbound = "outbound" // agent reply sent to a channel
+)
+
+// Entry is one line of the wire log.
+type Entry struct {
+	Timestamp  time.Time         `json:"timestamp"`
+	ID         string            `json:"id"` // pairs a request with its response
+	Direction  string            `json:"direction"`
+	Source     string            `json:"source"` // "webhook" or channel name
+	Method     string            `json:"method,omitempty"`
+	Path       string            `json:"path,omitempty"`
+	ChatID     string            `json:"chat_id,omitempty"`
+	SessionKey string            `json:"session_key,omitempty"`
+	Status     int               `json:"status,omitempty"`
+	DurationMS int64             `json:"duration_ms,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Body       string            `json:"body"`
+	Truncated  bool              `json:"truncated,omitempty"`
+}
+
+// Options configures a Log.
+type Options struct {
+	MaxFileBytes   int64         // rotate after the current file reaches this size
+	MaxFiles       int           // number of files kept, including the current one
+	MaxAge         time.Duration // rotated files older than this are deleted
+	Compress       bool          // gzip rotated files
+	MaxBodyBytes   int           // bodies longer than this are truncated
+	RedactHeaders  []string      // in addition to DefaultRedactHeaders
+	RedactPatterns []string      // in addition to DefaultRedactPatterns
+}
+
+// Log is a ring buffer of JSONL files under <workspace>/wirelog: wire.jsonl is
+// written to and rotated to wire-<timestamp>.jsonl, with the oldest files
+// dropped once there are MaxFiles.
+type Log struct {
+	dir      string
+	opts     Options
+	redactor *Redactor
+
+	mu     sync.Mutex
+	writer *logrotate.Writer
+}
+
+// New creates a wire log for the given workspace.
+func New(workspace string, opts Options) (*Log, error) {
+	if opts.MaxFileBytes <= 0 {
+		opts.MaxFileBytes = 1 << 20
+	}
+	if opts.MaxFiles <= 0 {
+		opts.MaxFiles = 5
+	}
+	if opts.MaxBodyBytes <= 0 {
+		opts.MaxBodyBytes = 16 << 10
+	}
+	redactor, err := NewRedactor(opts.RedactHeaders, opts.RedactPatterns)
+	if err != nil {
+		return nil, err
+	}
+	return &Log{
+		dir:      filepath.Join(workspace, "wirelog"),
+		opts:     opts,
+		redactor: redactor,
+	}, nil
+}
+
+// Redactor returns the redactor applied to recorded entries.
+func (l *Log) Redactor() *Redactor {
+	return l.redactor
+}
+
+// MaxBodyBytes returns the body size limit for recorded entries.
+func (l *Log) MaxBodyBytes() int {
+	return l.opts.MaxBodyBytes
+}
+
+// NewID returns an identifier for correlating a request with its response.
+func NewID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Record redacts and truncates the entry body and appends it to the log.
+// Headers are expected to be redacted already (see Redactor.Headers).
+func (l *Log) Record(entry Entry) error {
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now().UTC()
+	}
+	entry.Body = l.redactor.Text(entry.Body)
+	if len(entry.Body) > l.opts.MaxBodyBytes {
+		cut := l.opts.MaxBodyBytes
+		for cut > 0 && !utf8.RuneStart(entry.Body[cut]) {
+			cut--
+		}
+		entry.Body = entry.Body[:cut]
+		entry.Truncated = true
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal wire log entry: %w", err)
+	}
+	data = append(data, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.writer == nil {
+		w, err := logrotate.Open(filepath.Join(l.dir, "wire.jsonl"), logrotate.Options{
+			MaxBytes:   l.opts.MaxFileBytes,
+			MaxBackups: max(l.opts.MaxFiles-1, 1),
+			MaxAge:     l.opts.MaxAge,
+			Compress:   l.opts.Compress,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to open wire log: %w", err)
+		}
+		l.writer = w
+	}
+	if _, err := l.writer.Write(data); err != nil {
+		return fmt.Errorf("failed to write wire log: %w", err)
+	}
+	return nil
+}
+
+// Delete removes the entries match selects from the current and rotated
+// files, and returns how many were removed.
+func (l *Log) Delete(match func(Entry) bool) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	files, err := logrotate.Files(filepath.Join(l.dir, "wire.jsonl"))
+	if err != nil {
+		return 0, fmt.Errorf("failed to list wire logs: %w", err)
+	}
+	// Record reopens the current file on the next entry.
+	if l.writer != nil {
+		if err := l.writer.Close(); err != nil {
+			return 0, fmt.Errorf("failed to close wire log: %w", err)
+		}
+		l.writer = nil
+	}
+	removed := 0
+	for _, path := range files {
+		n, err := logrotate.DropLines(path, func(line []byte) bool {
+			var e Entry
+			return json.Unmarshal(line, &e) == nil && match(e)
+		})
+		removed += n
+		if err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
+
+// Close closes the current log file.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.writer == nil {
+		return nil
+	}
+	err := l.writer.Close()
+	l.writer = nil
+	return err
+}
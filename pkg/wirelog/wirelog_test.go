@@ -0,0 +1,192 @@
+package wirelog
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readEntries(t *testing.T, path string) []Entry {
+	t.Helper()
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Entry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &e))
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func TestRedactor(t *testing.T) {
+	r, err := NewRedactor([]string{"X-Business-Token"}, []string{`INV-\d+`})
+	require.NoError(t, err)
+
+	headers := r.Headers(http.Header{
+		"Authorization":    {"Bearer abc"},
+		"X-Business-Token": {"secret"},
+		"Content-Type":     {"application/json"},
+	})
+	assert.Equal(t, Redacted, headers["Authorization"])
+	assert.Equal(t, Redacted, headers["X-Business-Token"])
+	assert.Equal(t, "application/json", headers["Content-Type"])
+
+	tests := []struct {
+		in      string
+		leak    string
+		survive string
+	}{
+		{"email jane.doe@example.com please", "jane.doe@example.com", "email"},
+		{"card 4111 1111 1111 1111 on file", "4111", "on file"},
+		{"paid $1,250.00 to vendor", "1,250", "to vendor"},
+		{"invoice total 980.50 USD", "980.50", "invoice total"},
+		{"call +1 (415) 555-0100 now", "555-0100", "now"},
+		{"token eyJhbGciOi.eyJzdWIiOi.c2lnbmF0dXJl", "eyJhbGciOi", "token"},
+		{"ref INV-20931 approved", "INV-20931", "approved"},
+	}
+	for _, tt := range tests {
+		out := r.Text(tt.in)
+		assert.NotContains(t, out, tt.leak, tt.in)
+		assert.Contains(t, out, tt.survive, tt.in)
+		assert.Contains(t, out, Redacted, tt.in)
+	}
+
+	_, err = NewRedactor(nil, []string{"("})
+	assert.Error(t, err)
+}
+
+func TestRecordRotatesWithinCap(t *testing.T) {
+	workspace := t.TempDir()
+	l, err := New(workspace, Options{MaxFileBytes: 400, MaxFiles: 3, MaxBodyBytes: 64})
+	require.NoError(t, err)
+	defer l.Close()
+
+	for i := 0; i < 40; i++ {
+		require.NoError(t, l.Record(Entry{ID: NewID(), Direction: DirectionInbound, Source: "telegram",
+			Body: strings.Repeat("x", 100)}))
+	}
+
+	dir := filepath.Join(workspace, "wirelog")
+	files, err := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	require.NoError(t, err)
+	assert.Len(t, files, 3)
+	for _, f := range files {
+		info, err := os.Stat(f)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, info.Size(), int64(400))
+	}
+
+	entries := readEntries(t, filepath.Join(dir, "wire.jsonl"))
+	require.NotEmpty(t, entries)
+	assert.True(t, entries[0].Truncated)
+	assert.Len(t, entries[0].Body, 64)
+}
+
+func TestHandlerRecordsRequestAndResponse(t *testing.T) {
+	workspace := t.TempDir()
+	l, err := New(workspace, Options{})
+	require.NoError(t, err)
+	defer l.Close()
+
+	var seenBody, seenID string
+	handler := l.Handler("webhook", func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		seenBody = string(b)
+		seenID = r.Header.Get("X-Request-ID")
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"response":"Sent invoice to bob@example.com"}`))
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook",
+		strings.NewReader(`{"message":"Invoice bob@example.com for $300"}`))
+	req.Header.Set("Authorization", "Bearer pc_secret")
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+
+	assert.Equal(t, `{"message":"Invoice bob@example.com for $300"}`, seenBody, "handler must see the full body")
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+
+	entries := readEntries(t, filepath.Join(workspace, "wirelog", "wire.jsonl"))
+	require.Len(t, entries, 2)
+	reqEntry, respEntry := entries[0], entries[1]
+
+	assert.Equal(t, DirectionRequest, reqEntry.Direction)
+	assert.Equal(t, reqEntry.ID, respEntry.ID)
+	assert.Equal(t, reqEntry.ID, seenID, "handler must reuse the entry ID as request ID")
+	assert.Equal(t, Redacted, reqEntry.Headers["Authorization"])
+	assert.NotContains(t, reqEntry.Body, "bob@example.com")
+	assert.NotContains(t, reqEntry.Body, "$300")
+
+	assert.Equal(t, DirectionResponse, respEntry.Direction)
+	assert.Equal(t, http.StatusAccepted, respEntry.Status)
+	assert.NotContains(t, respEntry.Body, "bob@example.com")
+	assert.Contains(t, respEntry.Body, "Sent invoice")
+}
+
+func TestHandlerRedactsSecretAcrossLimit(t *testing.T) {
+	workspace := t.TempDir()
+	l, err := New(workspace, Options{MaxBodyBytes: 32})
+	require.NoError(t, err)
+	defer l.Close()
+
+	// The address starts before the limit and ends after it.
+	body := strings.Repeat("a", 25) + " bob@example.com and more"
+	handler := l.Handler("webhook", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	})
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body)))
+
+	entries := readEntries(t, filepath.Join(workspace, "wirelog", "wire.jsonl"))
+	require.Len(t, entries, 2)
+	for _, e := range entries {
+		assert.NotContains(t, e.Body, "bob@", "%s body", e.Direction)
+		assert.True(t, e.Truncated, "%s body", e.Direction)
+	}
+}
+
+func TestDeleteAcrossRotatedFiles(t *testing.T) {
+	workspace := t.TempDir()
+	l, err := New(workspace, Options{MaxFileBytes: 300, MaxFiles: 10})
+	require.NoError(t, err)
+	defer l.Close()
+
+	for i := range 10 {
+		id := "keep"
+		if i%2 == 0 {
+			id = "forget"
+		}
+		require.NoError(t, l.Record(Entry{ID: id, Direction: DirectionInbound, Source: "telegram",
+			Body: strings.Repeat("x", 100)}))
+	}
+
+	removed, err := l.Delete(func(e Entry) bool { return e.ID == "forget" })
+	require.NoError(t, err)
+	assert.Equal(t, 5, removed)
+
+	require.NoError(t, l.Record(Entry{ID: "after", Direction: DirectionInbound, Source: "telegram"}))
+	files, err := filepath.Glob(filepath.Join(workspace, "wirelog", "*.jsonl"))
+	require.NoError(t, err)
+	assert.Greater(t, len(files), 1)
+	var ids []string
+	for _, f := range files {
+		for _, e := range readEntries(t, f) {
+			ids = append(ids, e.ID)
+		}
+	}
+	assert.NotContains(t, ids, "forget")
+	assert.Contains(t, ids, "after")
+	assert.Len(t, ids, 6)
+}
@@ -0,0 +1,126 @@
+package workflow
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// condition is a parsed step condition: a variable path, and optionally an
+// operator and the value to compare it with.
+type condition struct {
+	path  string
+	op    string
+	value string
+}
+
+// operators are the comparisons a condition may use, longest first so
+// ">=" is not read as ">".
+var operators = []string{">=", "<=", "==", "!=", ">", "<", " contains "}
+
+// parseCondition reads "path op value", such as "event.amount > 500" or
+// `steps.classify.category == "travel"`, or a bare path, which holds when
+// the variable is set and not empty, false, "false" or 0.
+func parseCondition(text string) (condition, error) {
+	text = strings.TrimSpace(text)
+	for _, op := range operators {
+		left, right, ok := strings.Cut(text, op)
+		if !ok {
+			continue
+		}
+		c := condition{path: strings.TrimSpace(left), op: strings.TrimSpace(op), value: strings.TrimSpace(right)}
+		if unquoted, err := strconv.Unquote(c.value); err == nil {
+			c.value = unquoted
+		}
+		if !validPath(c.path) {
+			return condition{}, fmt.Errorf("invalid condition %q: expected <variable> <op> <value>", text)
+		}
+		return c, nil
+	}
+	if !validPath(text) {
+		return condition{}, fmt.Errorf("invalid condition %q: expected <variable> <op> <value>", text)
+	}
+	return condition{path: text}, nil
+}
+
+func validPath(path string) bool {
+	return path != "" && !strings.ContainsAny(path, " \t\"'")
+}
+
+// Eval reports whether the condition text holds for the run's variables.
+// Values that both read as numbers, such as "542.10" and 500, compare as
+// numbers; others compare as text, and only with ==, != and contains.
+func Eval(text string, vars map[string]any) (bool, error) {
+	c, err := parseCondition(text)
+	if err != nil {
+		return false, err
+	}
+	v, found := lookup(vars, c.path)
+	if c.op == "" {
+		return found && truthy(v), nil
+	}
+	got := fmt.Sprint(v)
+	if !found {
+		got = ""
+	}
+	if c.op == "contains" {
+		return strings.Contains(strings.ToLower(got), strings.ToLower(c.value)), nil
+	}
+
+	a, aErr := strconv.ParseFloat(strings.TrimSpace(got), 64)
+	b, bErr := strconv.ParseFloat(c.value, 64)
+	if aErr == nil && bErr == nil {
+		switch c.op {
+		case "==":
+			return a == b, nil
+		case "!=":
+			return a != b, nil
+		case ">":
+			return a > b, nil
+		case ">=":
+			return a >= b, nil
+		case "<":
+			return a < b, nil
+		default:
+			return a <= b, nil
+		}
+	}
+	switch c.op {
+	case "==":
+		return got == c.value, nil
+	case "!=":
+		return got != c.value, nil
+	default:
+		return false, fmt.Errorf("%s is %q, which can't be compared with %s", c.path, got, c.op)
+	}
+}
+
+// lookup finds a dotted path, such as "steps.extract.total", in vars.
+func lookup(vars map[string]any, path string) (any, bool) {
+	var cur any = vars
+	for _, key := range strings.Split(path, ".") {
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if cur, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+func truthy(v any) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	case string:
+		return v != "" && v != "false" && v != "0"
+	default:
+		return true
+	}
+}
@@ -0,0 +1,455 @@
+package workflow
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/cron"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/subscriptions"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// VarsFileEnv is the environment variable that names the JSON file with a
+// run's variables, for command steps.
+const VarsFileEnv = "PICOCLAW_WORKFLOW_VARS"
+
+// maxResultOutput bounds the output kept in a step's result; the full
+// output is still available to later steps.
+const maxResultOutput = 2000
+
+// ErrNotWaiting is returned when deciding a run that isn't waiting for
+// approval.
+var ErrNotWaiting = errors.New("workflow run is not waiting for approval")
+
+// Runner carries out what a workflow asks of the agent.
+type Runner interface {
+	// Prompt runs an agent prompt in the run's own session and returns the
+	// reply.
+	Prompt(ctx context.Context, run *Run, prompt string) (string, error)
+	// Command runs a shell command in skill's directory, or the workspace
+	// when skill is empty, with VarsFileEnv set to varsFile.
+	Command(ctx context.Context, run *Run, skill, command, varsFile string) (string, error)
+	// Notify sends message to the workflow's chat.
+	Notify(ctx context.Context, wf *Workflow, run *Run, message string) error
+	// Report tells the workflow's chat that run stopped: it is waiting for
+	// approval of run.Pending, or it failed.
+	Report(ctx context.Context, wf *Workflow, run *Run)
+}
+
+// Engine starts workflow runs and carries them out, one goroutine per run.
+type Engine struct {
+	workflows map[string]*Workflow
+	runner    Runner
+	runs      store
+	varsDir   string
+
+	mu     sync.Mutex
+	ctx    context.Context
+	active map[string]bool // runs being executed
+	wg     sync.WaitGroup
+}
+
+// New loads the workflows under <workspace>/workflows. Runs are kept in its
+// runs subdirectory.
+func New(workspace string, runner Runner) (*Engine, error) {
+	dir := filepath.Join(workspace, "workflows")
+	workflows, err := Load(dir)
+	if err != nil {
+		return nil, err
+	}
+	e := &Engine{
+		workflows: make(map[string]*Workflow, len(workflows)),
+		runner:    runner,
+		runs:      store{dir: filepath.Join(dir, "runs")},
+		varsDir:   filepath.Join(dir, "vars"),
+		ctx:       context.Background(),
+		active:    make(map[string]bool),
+	}
+	for _, wf := range workflows {
+		e.workflows[wf.Name] = wf
+	}
+	return e, nil
+}
+
+// Workflows returns the loaded workflows by name.
+func (e *Engine) Workflows() []*Workflow {
+	workflows := make([]*Workflow, 0, len(e.workflows))
+	for _, wf := range e.workflows {
+		workflows = append(workflows, wf)
+	}
+	slices.SortFunc(workflows, func(a, b *Workflow) int { return strings.Compare(a.Name, b.Name) })
+	return workflows
+}
+
+// ScheduledJobs returns cron jobs for the workflows with a schedule. Pass
+// them to CronService.SyncJobs with cron.WorkflowJobPrefix.
+func (e *Engine) ScheduledJobs() []cron.CronJob {
+	var jobs []cron.CronJob
+	for _, wf := range e.Workflows() {
+		if wf.Trigger.Schedule == "" {
+			continue
+		}
+		jobs = append(jobs, cron.CronJob{
+			ID:       cron.WorkflowJobPrefix + wf.Name,
+			Name:     "workflow: " + wf.Name,
+			Enabled:  true,
+			Schedule: cron.CronSchedule{Kind: "cron", Expr: wf.Trigger.Schedule, TZ: wf.Trigger.TZ},
+			Payload:  cron.CronPayload{Kind: cron.PayloadKindWorkflow, Workflow: wf.Name},
+		})
+	}
+	return jobs
+}
+
+// Run resumes the runs a restart interrupted, starts workflows on their
+// trigger events, and carries out runs until ctx is done. It then waits for
+// the steps in progress; their runs resume on the next start.
+func (e *Engine) Run(ctx context.Context) {
+	e.mu.Lock()
+	e.ctx = ctx
+	e.mu.Unlock()
+
+	runs, err := e.runs.list()
+	if err != nil {
+		logger.WarnCF("workflow", "Failed to list workflow runs", map[string]any{"error": err.Error()})
+	}
+	for _, run := range runs {
+		if run.Status == StatusRunning {
+			logger.InfoCF("workflow", "Resuming workflow run",
+				map[string]any{"workflow": run.Workflow, "run_id": run.ID, "step": run.Step})
+			e.launch(run)
+		}
+	}
+
+	stop := subscriptions.Listen(e.handleEvent)
+	<-ctx.Done()
+	stop()
+	e.wg.Wait()
+}
+
+// handleEvent starts the workflows triggered by ev.
+func (e *Engine) handleEvent(ev subscriptions.Event) {
+	for _, wf := range e.Workflows() {
+		if wf.Trigger.Event != ev.Type {
+			continue
+		}
+		if _, err := e.Start(wf.Name, ev.Type, ev.BusinessID, eventVars(ev.Data)); err != nil {
+			logger.WarnCF("workflow", "Failed to start workflow",
+				map[string]any{"workflow": wf.Name, "event": ev.Type, "error": err.Error()})
+		}
+	}
+}
+
+// eventVars turns event data into the map templates and conditions read.
+func eventVars(data any) map[string]any {
+	vars := map[string]any{}
+	if b, err := json.Marshal(data); err == nil {
+		json.Unmarshal(b, &vars)
+	}
+	return vars
+}
+
+// Start begins a run of the named workflow for businessID, or the
+// workflow's own business when empty, and carries it out in the
+// background. It returns the new run as saved.
+func (e *Engine) Start(name, trigger, businessID string, event map[string]any) (*Run, error) {
+	wf, ok := e.workflows[name]
+	if !ok {
+		return nil, fmt.Errorf("no workflow named %q", name)
+	}
+	if businessID == "" {
+		businessID = wf.BusinessID
+	}
+	if event == nil {
+		event = map[string]any{}
+	}
+	now := time.Now().UTC()
+	run := &Run{
+		ID:         newRunID(),
+		Workflow:   name,
+		Trigger:    trigger,
+		Status:     StatusRunning,
+		BusinessID: businessID,
+		Vars: map[string]any{
+			"event":       event,
+			"steps":       map[string]any{},
+			"workflow":    name,
+			"business_id": businessID,
+		},
+		StartedAt: now,
+		UpdatedAt: now,
+	}
+	if err := e.runs.save(run); err != nil {
+		return nil, err
+	}
+	e.runs.prune()
+	logger.InfoCF("workflow", "Started workflow run",
+		map[string]any{"workflow": name, "run_id": run.ID, "trigger": trigger, "business_id": businessID})
+	saved := *run
+	e.launch(run)
+	return &saved, nil
+}
+
+// Get returns the saved run with id.
+func (e *Engine) Get(id string) (*Run, error) {
+	return e.runs.load(id)
+}
+
+// Runs returns up to limit saved runs, newest first; zero means all.
+func (e *Engine) Runs(limit int) ([]*Run, error) {
+	runs, err := e.runs.list()
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && len(runs) > limit {
+		runs = runs[:limit]
+	}
+	return runs, nil
+}
+
+// Decide approves or rejects the approval step run id is waiting at. An
+// approved run carries on in the background; a rejected one ends.
+func (e *Engine) Decide(id string, approve bool, by string) (*Run, error) {
+	e.mu.Lock()
+	if e.active[id] {
+		e.mu.Unlock()
+		return nil, ErrNotWaiting
+	}
+	run, err := e.runs.load(id)
+	if err != nil {
+		e.mu.Unlock()
+		return nil, err
+	}
+	wf, ok := e.workflows[run.Workflow]
+	if run.Status != StatusWaiting || !ok || run.Step >= len(wf.Steps) {
+		e.mu.Unlock()
+		return nil, ErrNotWaiting
+	}
+
+	result := StepResult{Step: wf.Steps[run.Step].ID, Status: StepRejected, Output: by, At: time.Now().UTC()}
+	run.Status = StatusRejected
+	if approve {
+		result.Status = StepApproved
+		run.Status = StatusRunning
+		run.Step++
+	}
+	run.Results = append(run.Results, result)
+	run.Pending = ""
+	run.UpdatedAt = result.At
+	err = e.runs.save(run)
+	e.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	logger.InfoCF("workflow", "Workflow approval decided",
+		map[string]any{"workflow": run.Workflow, "run_id": id, "approved": approve, "by": by})
+	saved := *run
+	if approve {
+		e.launch(run)
+	}
+	return &saved, nil
+}
+
+// Resume retries a failed run from the step that failed.
+func (e *Engine) Resume(id string) (*Run, error) {
+	e.mu.Lock()
+	run, err := e.runs.load(id)
+	if err == nil && (run.Status != StatusFailed || e.active[id]) {
+		err = fmt.Errorf("workflow run %s is %s, not failed", id, run.Status)
+	}
+	if err == nil {
+		run.Status, run.Error, run.UpdatedAt = StatusRunning, "", time.Now().UTC()
+		err = e.runs.save(run)
+	}
+	e.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	saved := *run
+	e.launch(run)
+	return &saved, nil
+}
+
+// launch carries out run in a goroutine, unless it is already running.
+func (e *Engine) launch(run *Run) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.active[run.ID] {
+		return
+	}
+	e.active[run.ID] = true
+	e.wg.Add(1)
+	ctx := e.ctx
+	go func() {
+		defer e.wg.Done()
+		e.execute(ctx, run)
+	}()
+}
+
+// execute runs run's steps from run.Step until it finishes, fails, stops
+// for approval, or ctx ends.
+func (e *Engine) execute(ctx context.Context, run *Run) {
+	wf, ok := e.workflows[run.Workflow]
+	if !ok {
+		run.Status, run.Error = StatusFailed, "workflow is no longer defined"
+	}
+	for ok && run.Status == StatusRunning && run.Step < len(wf.Steps) && ctx.Err() == nil {
+		step := wf.Steps[run.Step]
+		result := e.runStep(ctx, run, step)
+		if ctx.Err() != nil && result.Status == StepFailed {
+			// Interrupted by shutdown: leave the run to resume on restart.
+			break
+		}
+		run.UpdatedAt = result.At
+		switch result.Status {
+		case StepFailed:
+			run.Results = append(run.Results, result)
+			run.Status, run.Error = StatusFailed, fmt.Sprintf("step %s: %s", step.ID, result.Error)
+		case "":
+			run.Status = StatusWaiting
+		default:
+			run.Results = append(run.Results, result)
+			run.Step++
+		}
+		if run.Status == StatusRunning && run.Step < len(wf.Steps) {
+			if err := e.runs.save(run); err != nil {
+				logger.WarnCF("workflow", "Failed to save workflow run",
+					map[string]any{"run_id": run.ID, "error": err.Error()})
+			}
+		}
+	}
+	if ok && run.Status == StatusRunning && run.Step >= len(wf.Steps) {
+		run.Status = StatusDone
+		os.Remove(filepath.Join(e.varsDir, run.ID+".json"))
+	}
+
+	if err := e.runs.save(run); err != nil {
+		logger.WarnCF("workflow", "Failed to save workflow run", map[string]any{"run_id": run.ID, "error": err.Error()})
+	}
+
+	fields := map[string]any{"workflow": run.Workflow, "run_id": run.ID, "status": run.Status}
+	switch run.Status {
+	case StatusFailed:
+		fields["error"] = run.Error
+		logger.WarnCF("workflow", "Workflow run failed", fields)
+	default:
+		logger.InfoCF("workflow", "Workflow run stopped", fields)
+	}
+	// Report before the run can be decided, so nobody acts on a run before
+	// it is announced.
+	if ok && (run.Status == StatusFailed || run.Status == StatusWaiting) {
+		e.runner.Report(ctx, wf, run)
+	}
+
+	e.mu.Lock()
+	delete(e.active, run.ID)
+	e.mu.Unlock()
+}
+
+// runStep carries out one step. A result without a status means the run
+// waits for approval.
+func (e *Engine) runStep(ctx context.Context, run *Run, step Step) StepResult {
+	result := StepResult{Step: step.ID, Status: StepDone}
+	fail := func(err error) StepResult {
+		result.Status, result.Error, result.At = StepFailed, err.Error(), time.Now().UTC()
+		return result
+	}
+
+	if step.If != "" {
+		holds, err := Eval(step.If, run.Vars)
+		if err != nil {
+			return fail(err)
+		}
+		if !holds {
+			result.Status, result.At = StepSkipped, time.Now().UTC()
+			return result
+		}
+	}
+
+	var output string
+	var err error
+	switch step.Kind() {
+	case KindPrompt:
+		var prompt string
+		if prompt, err = render(step.ID, step.Prompt, run.Vars); err == nil {
+			output, err = e.runner.Prompt(ctx, run, prompt)
+		}
+	case KindCommand:
+		var varsFile string
+		if varsFile, err = e.writeVars(run); err == nil {
+			output, err = e.runner.Command(ctx, run, step.Skill, step.Command, varsFile)
+		}
+	case KindNotify:
+		if output, err = render(step.ID, step.Notify, run.Vars); err == nil {
+			wf := e.workflows[run.Workflow]
+			err = e.runner.Notify(ctx, wf, run, output)
+		}
+	case KindApproval:
+		if run.Pending, err = render(step.ID, step.Approval, run.Vars); err == nil {
+			return StepResult{}
+		}
+	}
+	if err != nil {
+		return fail(err)
+	}
+	setOutput(run, step.ID, output)
+	result.Output, result.At = utils.Truncate(output, maxResultOutput), time.Now().UTC()
+	return result
+}
+
+// render fills in a step's template with the run's variables.
+func render(name, text string, vars map[string]any) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// setOutput stores a step's output for later steps, parsed when it is a
+// JSON object.
+func setOutput(run *Run, stepID, output string) {
+	steps, ok := run.Vars["steps"].(map[string]any)
+	if !ok {
+		steps = map[string]any{}
+		run.Vars["steps"] = steps
+	}
+	output = strings.TrimSpace(output)
+	var parsed map[string]any
+	if json.Unmarshal([]byte(output), &parsed) == nil {
+		steps[stepID] = parsed
+		return
+	}
+	steps[stepID] = output
+}
+
+// writeVars saves the run's variables for a command step to read.
+func (e *Engine) writeVars(run *Run) (string, error) {
+	if err := os.MkdirAll(e.varsDir, 0o700); err != nil {
+		return "", fmt.Errorf("failed to create workflow vars directory: %w", err)
+	}
+	data, err := json.Marshal(run.Vars)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal workflow vars: %w", err)
+	}
+	path := filepath.Join(e.varsDir, run.ID+".json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return "", fmt.Errorf("failed to write workflow vars: %w", err)
+	}
+	return path, nil
+}
@@ -0,0 +1,217 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/cron"
+	"github.com/sipeed/picoclaw/pkg/subscriptions"
+)
+
+type fakeRunner struct {
+	mu       sync.Mutex
+	prompts  []string
+	notified []string
+	reports  []string
+	vars     string
+	fail     error
+}
+
+func (f *fakeRunner) Prompt(ctx context.Context, run *Run, prompt string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.prompts = append(f.prompts, prompt)
+	if f.fail != nil {
+		return "", f.fail
+	}
+	return `{"category": "travel"}`, nil
+}
+
+func (f *fakeRunner) Command(ctx context.Context, run *Run, skill, command, varsFile string) (string, error) {
+	data, err := os.ReadFile(varsFile)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.vars = string(data)
+	return "ran " + command, err
+}
+
+func (f *fakeRunner) Notify(ctx context.Context, wf *Workflow, run *Run, message string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.notified = append(f.notified, message)
+	return nil
+}
+
+func (f *fakeRunner) Report(ctx context.Context, wf *Workflow, run *Run) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.reports = append(f.reports, run.Status)
+}
+
+const expenseWorkflow = `
+name: expenses
+business_id: biz-1
+trigger:
+  event: receipt.parsed
+steps:
+  - id: classify
+    prompt: "Classify {{.event.vendor}}"
+  - id: small
+    if: event.amount < 100
+    notify: "Small expense"
+  - id: check
+    if: steps.classify.category == travel
+    approval: "Post {{.event.amount}} of {{.steps.classify.category}}?"
+  - id: post
+    command: ./post.sh
+  - id: done
+    notify: "Posted {{.event.vendor}}: {{.steps.post}}"
+`
+
+func newTestEngine(t *testing.T, text string) (*Engine, *fakeRunner) {
+	t.Helper()
+	workspace := t.TempDir()
+	writeWorkflow(t, filepath.Join(workspace, "workflows"), "wf.yaml", text)
+	runner := &fakeRunner{}
+	e, err := New(workspace, runner)
+	require.NoError(t, err)
+	return e, runner
+}
+
+// waitFor waits until the run leaves StatusRunning and returns it.
+func waitFor(t *testing.T, e *Engine, id string) *Run {
+	t.Helper()
+	var run *Run
+	require.Eventually(t, func() bool {
+		e.mu.Lock()
+		active := e.active[id]
+		e.mu.Unlock()
+		var err error
+		run, err = e.Get(id)
+		return err == nil && !active && run.Status != StatusRunning
+	}, 2*time.Second, 5*time.Millisecond)
+	return run
+}
+
+func TestEngineApproval(t *testing.T) {
+	e, runner := newTestEngine(t, expenseWorkflow)
+
+	run, err := e.Start("expenses", TriggerManual, "", map[string]any{"vendor": "Air Co", "amount": "540"})
+	require.NoError(t, err)
+	assert.Equal(t, "biz-1", run.BusinessID)
+
+	run = waitFor(t, e, run.ID)
+	require.Equal(t, StatusWaiting, run.Status)
+	assert.Equal(t, "Post 540 of travel?", run.Pending)
+	assert.Equal(t, []string{"Classify Air Co"}, runner.prompts)
+	assert.Empty(t, runner.notified)
+	assert.Equal(t, []string{StatusWaiting}, runner.reports)
+	require.Len(t, run.Results, 2)
+	assert.Equal(t, StepSkipped, run.Results[1].Status)
+
+	_, err = e.Resume(run.ID)
+	assert.Error(t, err)
+	_, err = e.Decide(run.ID, true, "telegram:42")
+	require.NoError(t, err)
+	run = waitFor(t, e, run.ID)
+	assert.Equal(t, StatusDone, run.Status)
+	assert.Equal(t, []string{"Posted Air Co: ran ./post.sh"}, runner.notified)
+	assert.Contains(t, runner.vars, `"category":"travel"`)
+	assert.Equal(t, StepApproved, run.Results[2].Status)
+	assert.Equal(t, "telegram:42", run.Results[2].Output)
+
+	_, err = e.Decide(run.ID, true, "telegram:42")
+	assert.ErrorIs(t, err, ErrNotWaiting)
+}
+
+func TestEngineReject(t *testing.T) {
+	e, runner := newTestEngine(t, expenseWorkflow)
+
+	run, err := e.Start("expenses", TriggerManual, "", map[string]any{"vendor": "Air Co", "amount": 540})
+	require.NoError(t, err)
+	waitFor(t, e, run.ID)
+	run, err = e.Decide(run.ID, false, "admin")
+	require.NoError(t, err)
+	assert.Equal(t, StatusRejected, run.Status)
+	assert.True(t, run.Finished())
+	assert.Empty(t, runner.notified)
+
+	_, err = e.Decide("missing", true, "admin")
+	assert.ErrorIs(t, err, ErrRunNotFound)
+}
+
+func TestEngineFailAndResume(t *testing.T) {
+	e, runner := newTestEngine(t, expenseWorkflow)
+	runner.fail = errors.New("provider down")
+
+	run, err := e.Start("expenses", TriggerManual, "biz-2", map[string]any{"vendor": "Deli", "amount": 12})
+	require.NoError(t, err)
+	run = waitFor(t, e, run.ID)
+	assert.Equal(t, StatusFailed, run.Status)
+	assert.Equal(t, "step classify: provider down", run.Error)
+	assert.Equal(t, 0, run.Step)
+
+	runner.mu.Lock()
+	runner.fail = nil
+	runner.mu.Unlock()
+	_, err = e.Resume(run.ID)
+	require.NoError(t, err)
+	run = waitFor(t, e, run.ID)
+	assert.Equal(t, StatusWaiting, run.Status, run.Error)
+	assert.Equal(t, []string{"Small expense"}, runner.notified)
+	assert.Equal(t, []string{StatusFailed, StatusWaiting}, runner.reports)
+}
+
+func TestEngineEventTrigger(t *testing.T) {
+	e, runner := newTestEngine(t, expenseWorkflow)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		e.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	require.Eventually(t, func() bool {
+		subscriptions.Publish(subscriptions.Event{
+			Type:       subscriptions.EventReceiptParsed,
+			BusinessID: "biz-3",
+			Data:       map[string]any{"vendor": "Deli", "amount": "8.50"},
+		})
+		runs, err := e.Runs(0)
+		return err == nil && len(runs) > 0
+	}, 2*time.Second, 50*time.Millisecond)
+
+	runs, err := e.Runs(1)
+	require.NoError(t, err)
+	run := waitFor(t, e, runs[0].ID)
+	assert.Equal(t, subscriptions.EventReceiptParsed, run.Trigger)
+	assert.Equal(t, "biz-3", run.BusinessID)
+	assert.Equal(t, StatusWaiting, run.Status)
+	runner.mu.Lock()
+	assert.Contains(t, runner.notified, "Small expense")
+	runner.mu.Unlock()
+}
+
+func TestScheduledJobs(t *testing.T) {
+	e, _ := newTestEngine(t, "name: weekly\ntrigger:\n  schedule: \"0 9 * * 1\"\nsteps:\n  - notify: hi\n")
+	jobs := e.ScheduledJobs()
+	require.Len(t, jobs, 1)
+	assert.Equal(t, cron.WorkflowJobPrefix+"weekly", jobs[0].ID)
+	assert.Equal(t, cron.PayloadKindWorkflow, jobs[0].Payload.Kind)
+	assert.Equal(t, "weekly", jobs[0].Payload.Workflow)
+
+	_, err := e.Start("missing", TriggerManual, "", nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,162 @@
+package workflow
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Run statuses.
+const (
+	StatusRunning  = "running"  // executing, or interrupted by a restart
+	StatusWaiting  = "waiting"  // stopped at an approval step
+	StatusDone     = "done"     // every step ran or was skipped
+	StatusFailed   = "failed"   // a step failed; Resume retries it
+	StatusRejected = "rejected" // an approval was turned down
+)
+
+// Step result statuses.
+const (
+	StepDone     = "done"
+	StepSkipped  = "skipped"
+	StepFailed   = "failed"
+	StepApproved = "approved"
+	StepRejected = "rejected"
+)
+
+// Run triggers.
+const (
+	TriggerManual   = "manual"
+	TriggerSchedule = "schedule"
+)
+
+// maxRuns is how many finished runs are kept as history.
+const maxRuns = 500
+
+// ErrRunNotFound is returned for a run ID with no saved run.
+var ErrRunNotFound = errors.New("workflow run not found")
+
+// Run is one execution of a workflow.
+type Run struct {
+	ID         string `json:"id"`
+	Workflow   string `json:"workflow"`
+	Trigger    string `json:"trigger"` // manual, schedule, or the event type
+	Status     string `json:"status"`
+	BusinessID string `json:"business_id,omitempty"`
+	// Step is the index of the step to run next.
+	Step int `json:"step"`
+	// Vars holds the trigger event under "event" and each step's output
+	// under "steps.<id>"; outputs that are JSON objects are stored parsed.
+	Vars    map[string]any `json:"vars"`
+	Results []StepResult   `json:"results,omitempty"`
+	// Pending is the question an approval step asks while the run waits.
+	Pending   string    `json:"pending,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// StepResult records what one step did.
+type StepResult struct {
+	Step   string    `json:"step"`
+	Status string    `json:"status"`
+	Output string    `json:"output,omitempty"`
+	Error  string    `json:"error,omitempty"`
+	At     time.Time `json:"at"`
+}
+
+// Finished reports whether the run will do nothing more.
+func (r *Run) Finished() bool {
+	return r.Status == StatusDone || r.Status == StatusRejected
+}
+
+// store keeps runs as <dir>/<id>.json.
+type store struct {
+	dir string
+}
+
+func newRunID() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (s *store) path(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+func (s *store) save(run *Run) error {
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create workflow run directory: %w", err)
+	}
+	data, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal workflow run: %w", err)
+	}
+	path := s.path(run.ID)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write workflow run: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *store) load(id string) (*Run, error) {
+	if id == "" || strings.ContainsAny(id, "/\\.") {
+		return nil, ErrRunNotFound
+	}
+	data, err := os.ReadFile(s.path(id))
+	if os.IsNotExist(err) {
+		return nil, ErrRunNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var run Run
+	if err := json.Unmarshal(data, &run); err != nil {
+		return nil, fmt.Errorf("failed to read workflow run %s: %w", id, err)
+	}
+	return &run, nil
+}
+
+// list returns the saved runs, newest first.
+func (s *store) list() ([]*Run, error) {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	runs := make([]*Run, 0, len(paths))
+	for _, path := range paths {
+		run, err := s.load(strings.TrimSuffix(filepath.Base(path), ".json"))
+		if err != nil {
+			continue
+		}
+		runs = append(runs, run)
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].StartedAt.After(runs[j].StartedAt) })
+	return runs, nil
+}
+
+// prune removes the oldest finished runs beyond maxRuns.
+func (s *store) prune() {
+	runs, err := s.list()
+	if err != nil {
+		return
+	}
+	kept := 0
+	for _, run := range runs {
+		if !run.Finished() && run.Status != StatusFailed {
+			continue
+		}
+		if kept++; kept > maxRuns {
+			os.Remove(s.path(run.ID))
+		}
+	}
+}
@@ -0,0 +1,210 @@
+// Package workflow runs declarative multi-step automations. A workflow is a
+// YAML file under <workspace>/workflows that chains agent prompts, skill
+// commands, notifications and approvals, with conditions on earlier
+// results, for example:
+//
+//	name: large-receipts
+//	trigger:
+//	  event: receipt.parsed
+//	steps:
+//	  - id: check
+//	    if: event.amount > 500
+//	    approval: "Post {{.event.vendor}} for {{.event.amount}}?"
+//	  - id: post
+//	    if: event.amount > 500
+//	    prompt: "Post draft {{.event.transaction_id}} to the ledger."
+//
+// Workflows start on an event, on a cron schedule, or by hand. Each run is
+// saved after every step, so a run interrupted by a restart resumes where
+// it stopped, and a run waiting for approval survives one.
+package workflow
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/adhocore/gronx"
+	"gopkg.in/yaml.v3"
+
+	"github.com/sipeed/picoclaw/pkg/subscriptions"
+)
+
+// TriggerEvents are the events a workflow can start on. Responses are left
+// out, since a workflow's own prompts would start it again.
+var TriggerEvents = []string{subscriptions.EventReceiptParsed, subscriptions.EventPairingCreated}
+
+// Workflow is one automation, as read from its YAML file.
+type Workflow struct {
+	Name        string  `yaml:"name"`
+	Description string  `yaml:"description,omitempty"`
+	Trigger     Trigger `yaml:"trigger"`
+	// BusinessID is the business of scheduled and manual runs; event runs
+	// use the event's business.
+	BusinessID string `yaml:"business_id,omitempty"`
+	// Channel and To are the chat that notifications and approval requests
+	// go to, instead of the business's last active chat.
+	Channel string `yaml:"channel,omitempty"`
+	To      string `yaml:"to,omitempty"`
+	Steps   []Step `yaml:"steps"`
+
+	Path string `yaml:"-"`
+}
+
+// Trigger starts a workflow on an event, on a cron schedule, or both. A
+// workflow without a trigger only runs by hand.
+type Trigger struct {
+	Event    string `yaml:"event,omitempty"`
+	Schedule string `yaml:"schedule,omitempty"`
+	TZ       string `yaml:"tz,omitempty"`
+}
+
+// Step is one action of a workflow: exactly one of Prompt, Command,
+// Notify and Approval. Prompt, Notify and Approval are Go templates over
+// the run's variables; Command is not, and reads them from the JSON file
+// named by $PICOCLAW_WORKFLOW_VARS instead, so event data never reaches a
+// shell.
+type Step struct {
+	ID string `yaml:"id"`
+	// If skips the step unless the condition holds (see Eval).
+	If       string `yaml:"if,omitempty"`
+	Prompt   string `yaml:"prompt,omitempty"`
+	Skill    string `yaml:"skill,omitempty"` // the skill directory Command runs in
+	Command  string `yaml:"command,omitempty"`
+	Notify   string `yaml:"notify,omitempty"`
+	Approval string `yaml:"approval,omitempty"`
+}
+
+// Step kinds.
+const (
+	KindPrompt   = "prompt"
+	KindCommand  = "command"
+	KindNotify   = "notify"
+	KindApproval = "approval"
+)
+
+// Kind returns which action the step takes.
+func (s Step) Kind() string {
+	switch {
+	case s.Prompt != "":
+		return KindPrompt
+	case s.Command != "":
+		return KindCommand
+	case s.Notify != "":
+		return KindNotify
+	default:
+		return KindApproval
+	}
+}
+
+// Load reads every *.yaml and *.yml file in dir. A missing directory has
+// no workflows.
+func Load(dir string) ([]*Workflow, error) {
+	var paths []string
+	for _, pattern := range []string{"*.yaml", "*.yml"} {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, matches...)
+	}
+	slices.Sort(paths)
+
+	var workflows []*Workflow
+	names := map[string]string{}
+	for _, path := range paths {
+		wf, err := LoadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if other, ok := names[wf.Name]; ok {
+			return nil, fmt.Errorf("%s: workflow %q is also defined in %s", path, wf.Name, other)
+		}
+		names[wf.Name] = path
+		workflows = append(workflows, wf)
+	}
+	return workflows, nil
+}
+
+// LoadFile reads and checks one workflow file.
+func LoadFile(path string) (*Workflow, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var wf Workflow
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&wf); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	wf.Path = path
+	if err := wf.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &wf, nil
+}
+
+// Validate checks the workflow's trigger and steps, and names steps that
+// have no ID after their position ("step1", "step2", ...).
+func (wf *Workflow) Validate() error {
+	if wf.Name == "" || strings.ContainsAny(wf.Name, " /\\") {
+		return fmt.Errorf("name is required and may not contain spaces or slashes")
+	}
+	if e := wf.Trigger.Event; e != "" && !slices.Contains(TriggerEvents, e) {
+		return fmt.Errorf("unknown trigger event %q; expected one of %v", e, TriggerEvents)
+	}
+	if s := wf.Trigger.Schedule; s != "" {
+		if !gronx.New().IsValid(s) {
+			return fmt.Errorf("invalid schedule %q", s)
+		}
+	}
+	if tz := wf.Trigger.TZ; tz != "" {
+		if _, err := time.LoadLocation(tz); err != nil {
+			return fmt.Errorf("invalid tz %q: %w", tz, err)
+		}
+	}
+	if len(wf.Steps) == 0 {
+		return fmt.Errorf("no steps")
+	}
+	ids := map[string]bool{}
+	for i := range wf.Steps {
+		step := &wf.Steps[i]
+		if step.ID == "" {
+			step.ID = fmt.Sprintf("step%d", i+1)
+		}
+		if ids[step.ID] {
+			return fmt.Errorf("step id %q is used twice", step.ID)
+		}
+		ids[step.ID] = true
+
+		actions := 0
+		for _, a := range []string{step.Prompt, step.Command, step.Notify, step.Approval} {
+			if a != "" {
+				actions++
+			}
+		}
+		if actions != 1 {
+			return fmt.Errorf("step %s: needs exactly one of prompt, command, notify or approval", step.ID)
+		}
+		if step.Skill != "" && step.Command == "" {
+			return fmt.Errorf("step %s: skill is only used with command", step.ID)
+		}
+		if step.If != "" {
+			if _, err := parseCondition(step.If); err != nil {
+				return fmt.Errorf("step %s: %w", step.ID, err)
+			}
+		}
+		for _, text := range []string{step.Prompt, step.Notify, step.Approval} {
+			if _, err := template.New(step.ID).Parse(text); err != nil {
+				return fmt.Errorf("step %s: %w", step.ID, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package workflow
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeWorkflow(t *testing.T, dir, file, text string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, file), []byte(text), 0o644))
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	workflows, err := Load(filepath.Join(dir, "missing"))
+	require.NoError(t, err)
+	assert.Empty(t, workflows)
+
+	writeWorkflow(t, dir, "b.yml", `
+name: weekly
+trigger:
+  schedule: "0 9 * * 1"
+  tz: Europe/Paris
+steps:
+  - notify: "Weekly check for {{.business_id}}"
+  - command: ./report.sh
+    skill: reports
+`)
+	writeWorkflow(t, dir, "a.yaml", `
+name: receipts
+trigger:
+  event: receipt.parsed
+steps:
+  - id: check
+    if: event.amount > 500
+    approval: "Post {{.event.vendor}}?"
+`)
+	writeWorkflow(t, dir, "notes.txt", "not a workflow")
+
+	workflows, err = Load(dir)
+	require.NoError(t, err)
+	require.Len(t, workflows, 2)
+	assert.Equal(t, "receipts", workflows[0].Name)
+	assert.Equal(t, KindApproval, workflows[0].Steps[0].Kind())
+	assert.Equal(t, "weekly", workflows[1].Name)
+	assert.Equal(t, []string{"step1", "step2"}, []string{workflows[1].Steps[0].ID, workflows[1].Steps[1].ID})
+	assert.Equal(t, KindCommand, workflows[1].Steps[1].Kind())
+
+	writeWorkflow(t, dir, "c.yaml", "name: receipts\nsteps:\n  - notify: hi\n")
+	_, err = Load(dir)
+	assert.ErrorContains(t, err, "also defined")
+}
+
+func TestValidate(t *testing.T) {
+	for name, text := range map[string]string{
+		"no name":        "steps:\n  - notify: hi\n",
+		"unknown field":  "name: x\nstpes: []\n",
+		"unknown event":  "name: x\ntrigger:\n  event: response.completed\nsteps:\n  - notify: hi\n",
+		"bad schedule":   "name: x\ntrigger:\n  schedule: every day\nsteps:\n  - notify: hi\n",
+		"bad tz":         "name: x\ntrigger:\n  schedule: \"0 9 * * *\"\n  tz: Mars/Base\nsteps:\n  - notify: hi\n",
+		"no steps":       "name: x\n",
+		"two actions":    "name: x\nsteps:\n  - notify: hi\n    prompt: hi\n",
+		"duplicate id":   "name: x\nsteps:\n  - id: a\n    notify: hi\n  - id: a\n    notify: hi\n",
+		"skill":          "name: x\nsteps:\n  - notify: hi\n    skill: reports\n",
+		"bad condition":  "name: x\nsteps:\n  - if: \"> 5\"\n    notify: hi\n",
+		"bad template":   "name: x\nsteps:\n  - notify: \"{{.event\"\n",
+		"name with path": "name: a/b\nsteps:\n  - notify: hi\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeWorkflow(t, dir, "wf.yaml", text)
+			_, err := LoadFile(filepath.Join(dir, "wf.yaml"))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestEval(t *testing.T) {
+	vars := map[string]any{
+		"event": map[string]any{"amount": "542.10", "vendor": "Corner Cafe", "count": float64(3)},
+		"steps": map[string]any{"classify": map[string]any{"category": "travel", "urgent": false}},
+	}
+	for text, want := range map[string]bool{
+		"event.amount > 500":                  true,
+		"event.amount <= 500":                 false,
+		"event.count == 3":                    true,
+		"event.count != 3":                    false,
+		`steps.classify.category == "travel"`: true,
+		"steps.classify.category != travel":   false,
+		"event.vendor contains cafe":          true,
+		"steps.classify.urgent":               false,
+		"event.vendor":                        true,
+		"event.missing":                       false,
+		"event.missing == x":                  false,
+	} {
+		got, err := Eval(text, vars)
+		require.NoError(t, err, text)
+		assert.Equal(t, want, got, text)
+	}
+
+	_, err := Eval("event.vendor > 5", vars)
+	assert.Error(t, err)
+}
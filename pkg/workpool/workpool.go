@@ -0,0 +1,135 @@
+// Package workpool bounds how many agent runs execute at once. Runs beyond
+// the limit wait in a bounded queue; once the queue is full new runs are
+// rejected instead of piling up in memory.
+package workpool
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/telemetry"
+)
+
+// ErrQueueFull is returned by Acquire when every worker is busy and the
+// queue holds as many waiting runs as allowed.
+var ErrQueueFull = errors.New("too many requests in progress, try again later")
+
+// latencyWeight is the weight of the newest sample in the moving averages of
+// queue wait and run time.
+const latencyWeight = 0.2
+
+// Pool is a counting semaphore with a bounded wait queue. A nil *Pool does not
+// limit anything.
+type Pool struct {
+	slots     chan struct{}
+	maxQueued int
+	queued    atomic.Int64
+
+	mu      sync.Mutex
+	avgWait time.Duration // moving average of time spent queued
+	avgRun  time.Duration // moving average of time holding a worker
+}
+
+// New returns a pool that runs up to workers runs at once and lets up to
+// maxQueued more wait for a worker.
+func New(workers, maxQueued int) *Pool {
+	return &Pool{
+		slots:     make(chan struct{}, max(workers, 1)),
+		maxQueued: max(maxQueued, 0),
+	}
+}
+
+// Acquire waits for a free worker and returns the function that frees it
+// again. It fails with ErrQueueFull when the queue is full, or with the
+// context's error if ctx ends while waiting.
+func (p *Pool) Acquire(ctx context.Context) (release func(), err error) {
+	if p == nil {
+		return func() {}, nil
+	}
+	select {
+	case p.slots <- struct{}{}:
+		return p.releaser(), nil
+	default:
+	}
+
+	if p.queued.Add(1) > int64(p.maxQueued) {
+		p.queued.Add(-1)
+		return nil, ErrQueueFull
+	}
+	telemetry.SetGauge("picoclaw.requests.queued", p.queued.Load())
+	defer func() {
+		telemetry.SetGauge("picoclaw.requests.queued", p.queued.Add(-1))
+	}()
+
+	start := time.Now()
+	select {
+	case p.slots <- struct{}{}:
+		wait := time.Since(start)
+		telemetry.RecordDuration("picoclaw.requests.queue_wait", wait)
+		p.mu.Lock()
+		p.avgWait = average(p.avgWait, wait)
+		p.mu.Unlock()
+		return p.releaser(), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// releaser returns the function that frees the worker taken at the time of
+// the call and records how long it was held.
+func (p *Pool) releaser() func() {
+	start := time.Now()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			p.mu.Lock()
+			p.avgRun = average(p.avgRun, time.Since(start))
+			p.mu.Unlock()
+			<-p.slots
+		})
+	}
+}
+
+// RetryAfter estimates how long a rejected caller should wait before trying
+// again: the time runs currently spend queued, or, before any run has
+// queued, the time to work through the queue at the average run time. It is
+// at least one second.
+func (p *Pool) RetryAfter() time.Duration {
+	if p == nil {
+		return time.Second
+	}
+	p.mu.Lock()
+	estimate := p.avgWait
+	if estimate == 0 {
+		estimate = p.avgRun * time.Duration(p.Queued()+1) / time.Duration(cap(p.slots))
+	}
+	p.mu.Unlock()
+	return max(time.Duration(math.Ceil(estimate.Seconds()))*time.Second, time.Second)
+}
+
+// Running returns the number of runs holding a worker.
+func (p *Pool) Running() int {
+	if p == nil {
+		return 0
+	}
+	return len(p.slots)
+}
+
+// Queued returns the number of runs waiting for a worker.
+func (p *Pool) Queued() int {
+	if p == nil {
+		return 0
+	}
+	return int(p.queued.Load())
+}
+
+func average(avg, sample time.Duration) time.Duration {
+	if avg == 0 {
+		return sample
+	}
+	return time.Duration(latencyWeight*float64(sample) + (1-latencyWeight)*float64(avg))
+}
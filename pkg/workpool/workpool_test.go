@@ -0,0 +1,82 @@
+package workpool
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcquireQueuesAndRejects(t *testing.T) {
+	p := New(1, 1)
+
+	release, err := p.Acquire(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, p.Running())
+
+	acquired := make(chan func())
+	go func() {
+		r, err := p.Acquire(context.Background())
+		assert.NoError(t, err)
+		acquired <- r
+	}()
+	require.Eventually(t, func() bool { return p.Queued() == 1 }, time.Second, time.Millisecond)
+
+	// The worker is busy and the queue is full.
+	_, err = p.Acquire(context.Background())
+	assert.ErrorIs(t, err, ErrQueueFull)
+
+	release()
+	second := <-acquired
+	assert.Equal(t, 0, p.Queued())
+	assert.Equal(t, 1, p.Running())
+	second()
+	assert.Equal(t, 0, p.Running())
+}
+
+func TestAcquireHonorsContext(t *testing.T) {
+	p := New(1, 5)
+	release, err := p.Acquire(context.Background())
+	require.NoError(t, err)
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = p.Acquire(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 0, p.Queued())
+}
+
+func TestNilPoolDoesNotLimit(t *testing.T) {
+	var p *Pool
+	release, err := p.Acquire(context.Background())
+	require.NoError(t, err)
+	release()
+	assert.Equal(t, 0, p.Running())
+}
+
+func TestRetryAfterFollowsQueueLatency(t *testing.T) {
+	p := New(1, 1)
+	assert.Equal(t, time.Second, p.RetryAfter())
+
+	release, err := p.Acquire(context.Background())
+	require.NoError(t, err)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		release()
+	}()
+	second, err := p.Acquire(context.Background())
+	require.NoError(t, err)
+	second()
+
+	p.mu.Lock()
+	assert.GreaterOrEqual(t, p.avgWait, 40*time.Millisecond)
+	p.mu.Unlock()
+
+	p.mu.Lock()
+	p.avgWait = 2500 * time.Millisecond
+	p.mu.Unlock()
+	assert.Equal(t, 3*time.Second, p.RetryAfter())
+}